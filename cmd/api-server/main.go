@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/api"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	configFile string
+	logger     *zap.Logger
+)
+
+func main() {
+	var err error
+	logger, err = zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-api",
+		Short: "Serve the aws-slurm-burst REST API",
+		Long: `Serve a versioned localhost REST API for external orchestration such as
+Open OnDemand and ASBA: launch with an execution plan, terminate nodes, node and
+instance status, cost summary and validation. Requests need the bearer token
+from api.token_file.`,
+		RunE: serve,
+	}
+
+	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+
+	if err := rootCmd.Execute(); err != nil {
+		logger.Error("Command execution failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func serve(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	backend, err := api.NewLocalBackend(logger, configFile, cfg)
+	if err != nil {
+		return err
+	}
+
+	server, err := api.NewServer(logger, &cfg.API, backend)
+	if err != nil {
+		return err
+	}
+
+	return server.Run(ctx)
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/costs"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/policysync"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/spend"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var logger *zap.Logger
+
+func main() {
+	var err error
+	logger, err = zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-cost",
+		Short: "Report AWS burst spend",
+	}
+
+	rootCmd.AddCommand(reportCmd())
+	rootCmd.AddCommand(ceilingsCmd())
+
+	if err := rootCmd.Execute(); err != nil {
+		logger.Error("Command execution failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func reportCmd() *cobra.Command {
+	var (
+		configFile string
+		dir        string
+		since      string
+		until      string
+		groupBy    string
+		format     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Aggregate per-job costs by account, partition or user",
+		Long: `Aggregate the per-job cost reconciliation records written by
+aws-slurm-burst-export-performance --format=asbb-reconciliation into summaries
+for PIs and department administrators, including spot savings and spend wasted
+on failed jobs.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sinceTime, err := costs.ParseDate(since)
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			untilTime, err := costs.ParseDate(until)
+			if err != nil {
+				return fmt.Errorf("invalid --until: %w", err)
+			}
+
+			if dir == "" {
+				cfg, err := config.Load(configFile)
+				if err != nil {
+					return fmt.Errorf("failed to load config: %w", err)
+				}
+				dir = cfg.ASBB.ReconciliationDir
+			}
+
+			records, skipped, err := costs.LoadRecords(dir)
+			if err != nil {
+				return err
+			}
+			if len(skipped) > 0 {
+				logger.Warn("Skipped unreadable cost records", zap.Strings("files", skipped))
+			}
+
+			summaries, err := costs.Aggregate(costs.Filter(records, sinceTime, untilTime), groupBy)
+			if err != nil {
+				return err
+			}
+
+			switch format {
+			case "csv":
+				return costs.WriteCSV(os.Stdout, groupBy, summaries)
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(map[string]interface{}{
+					"group_by": groupBy,
+					"since":    since,
+					"until":    until,
+					"groups":   summaries,
+					"total":    costs.Total(summaries),
+				})
+			case "table":
+				return printTable(groupBy, summaries)
+			default:
+				return fmt.Errorf("unsupported format %q (use table, csv or json)", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+	cmd.Flags().StringVar(&dir, "dir", "", "Directory of reconciliation records (default asbb.reconciliation_dir)")
+	cmd.Flags().StringVar(&since, "since", "", "Include jobs completed on or after this date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&until, "until", "", "Include jobs completed before this date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&groupBy, "group-by", costs.GroupByAccount, "Group by account, partition or user")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format (table, csv, json)")
+
+	return cmd
+}
+
+func ceilingsCmd() *cobra.Command {
+	var (
+		configFile string
+		override   string
+		format     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "ceilings",
+		Short: "Show this month's spend against the configured ceilings",
+		Long: `Show this month's burst spend of each partition, account and user from the
+spend ledger, against the ceilings in costs.ceilings.limits and the monthly
+ceilings of policy rules. A partition, account or user that has reached its
+ceiling does not burst until the month rolls over, or until --override
+unlocks it for the rest of the month.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(configFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			if err := policysync.Apply(cfg); err != nil {
+				logger.Warn("Policy ceilings cover only the users and accounts rules name", zap.Error(err))
+			}
+			ledger := spend.New(cfg.Costs.Ceilings.LedgerDir)
+
+			if override != "" {
+				known := false
+				for _, ceiling := range cfg.SpendLimits() {
+					known = known || spend.Scope(ceiling) == override
+				}
+				if !known {
+					return fmt.Errorf("no ceiling caps %q (use partition:<name>, account:<name> or user:<name>)", override)
+				}
+				if err := ledger.Override(override); err != nil {
+					return err
+				}
+				logger.Info("Spend ceiling overridden for the rest of the month", zap.String("scope", override))
+			}
+
+			totals, err := ledger.Totals(cfg.SpendLimits())
+			if err != nil {
+				return err
+			}
+
+			switch format {
+			case "json":
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(totals)
+			case "table":
+				writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(writer, "SCOPE\tSPENT\tCEILING\tSTATUS")
+				for _, total := range totals {
+					ceiling, status := "-", ""
+					if total.CeilingUSD > 0 {
+						ceiling = fmt.Sprintf("$%.2f", total.CeilingUSD)
+						if total.SpentUSD >= total.CeilingUSD {
+							status = "locked"
+						}
+					}
+					if total.Overridden {
+						status = "overridden"
+					}
+					fmt.Fprintf(writer, "%s\t$%.2f\t%s\t%s\n", total.Scope, total.SpentUSD, ceiling, status)
+				}
+				return writer.Flush()
+			default:
+				return fmt.Errorf("unsupported format %q (use table or json)", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+	cmd.Flags().StringVar(&override, "override", "", "Unlock a scope (partition:<name>, account:<name> or user:<name>) until the month rolls over")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format (table, json)")
+
+	return cmd
+}
+
+// printTable writes summaries as an aligned table with a total row
+func printTable(groupBy string, summaries []costs.Summary) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(writer, "%s\tJOBS\tFAILED\tTOTAL\tSPOT SAVINGS\tWASTED\tHOURS\t\n", groupBy)
+	for _, summary := range append(summaries, costs.Total(summaries)) {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t$%.2f\t$%.2f\t$%.2f\t%.1f\t\n",
+			summary.Group,
+			summary.Jobs,
+			summary.FailedJobs,
+			summary.TotalUSD,
+			summary.SpotSavingsUSD,
+			summary.WastedUSD,
+			summary.Hours)
+	}
+	return writer.Flush()
+}
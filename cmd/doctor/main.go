@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/doctor"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ecosystem"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/epilog"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	configFile   string
+	outputFormat string
+	skipAWS      bool
+	checkTimeout time.Duration
+	logger       *zap.Logger
+)
+
+func main() {
+	// Findings go to stdout; the log only carries warnings
+	loggerConfig := zap.NewProductionConfig()
+	loggerConfig.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
+	var err error
+	logger, err = loggerConfig.Build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-doctor",
+		Short: "Diagnose the aws-slurm-burst environment end to end",
+		Long: `Check everything a burst needs: configuration, Slurm binaries and slurmctld,
+power saving settings, AWS credentials and launch permissions, each node group's
+subnets, security groups and the Slurm, srun and MPI traffic their rules allow,
+launch template, the munge key burst nodes
+fetch, ASBA/ASBB, the data exchange directory and clock skew. Every problem comes with a remediation step.
+
+Exits non-zero when any check fails; warnings do not affect the exit code.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          runDoctor,
+	}
+
+	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "text", "Output format (text, json)")
+	rootCmd.Flags().BoolVar(&skipAWS, "skip-aws", false, "Skip the checks that call AWS APIs")
+	rootCmd.Flags().DurationVar(&checkTimeout, "timeout", 30*time.Second, "Timeout for each check")
+
+	if err := rootCmd.Execute(); err != nil {
+		logger.Error("Doctor found problems", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	if outputFormat != "text" && outputFormat != "json" {
+		return fmt.Errorf("unsupported output format %q (use text or json)", outputFormat)
+	}
+
+	cfg, loadErr := config.Load(configFile)
+	checks := []doctor.Check{doctor.ConfigCheck(configFile, loadErr)}
+	region := "us-east-1"
+
+	// Without a valid config only the host itself can be checked
+	if loadErr == nil {
+		region = cfg.AWS.Region
+		checks = append(checks, doctor.SlurmChecks(&cfg.Slurm, epilog.ExecRunner)...)
+		if !skipAWS {
+			ports := func(ctx context.Context) doctor.SlurmPorts {
+				return doctor.ReadSlurmPorts(ctx, &cfg.Slurm, epilog.ExecRunner)
+			}
+			checks = append(checks, doctor.AWSChecks(cfg, ports, func(ctx context.Context) (doctor.AWSProbe, error) {
+				return aws.NewDiagnostics(ctx, logger, &cfg.AWS)
+			})...)
+			if cfg.MungeKey.Enabled() {
+				checks = append(checks, doctor.MungeKeyCheck(&cfg.MungeKey, cfg.AWS.Region, epilog.ExecRunner))
+			}
+		}
+		checks = append(checks,
+			doctor.EcosystemCheck(ecosystem.NewEcosystemDetector(logger).DetectEcosystem),
+			doctor.ExchangeCheck(cfg.Ecosystem.DataExchangeDir))
+	}
+	checks = append(checks, doctor.ClockCheck(&http.Client{Timeout: 10 * time.Second},
+		fmt.Sprintf("https://sts.%s.amazonaws.com/", region)))
+
+	report := doctor.Run(cmd.Context(), checks, checkTimeout)
+
+	var err error
+	if outputFormat == "json" {
+		err = doctor.WriteJSON(os.Stdout, report)
+	} else {
+		err = doctor.WriteText(os.Stdout, report)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	if !report.Healthy {
+		return fmt.Errorf("%d checks failed", report.Failed)
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/emergency"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// clearedReason is the Slurm reason on nodes returned to service by clear; it
+// replaces emergency.NodeReason so the state manager tends the nodes again
+const clearedReason = "emergency_stop_cleared"
+
+var (
+	configFile string
+	logger     *zap.Logger
+)
+
+func main() {
+	var err error
+	logger, err = zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := stopCmd()
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+
+	rootCmd.AddCommand(clearCmd())
+	rootCmd.AddCommand(statusCmd())
+
+	if err := rootCmd.Execute(); err != nil {
+		logger.Error("Command execution failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func stopCmd() *cobra.Command {
+	var (
+		partitions []string
+		reason     string
+		dryRun     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "aws-slurm-burst-emergency-stop",
+		Short: "Terminate burst instances and stop bursting, for runaway-cost incidents",
+		Long: `Stop bursting in every partition, or those given with --partition, then
+terminate every instance aws-slurm-burst manages in them, warm pool and
+stopped instances included, and mark their Slurm nodes DOWN with the reason
+emergency_stop. Jobs running on the nodes are killed. Resume refuses to
+burst, and the state manager launches nothing, until the stop is cleared with
+the clear subcommand. Stops and clears are appended to the audit log.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(partitions)
+			if err != nil {
+				return err
+			}
+			return stopBursting(cfg, partitions, reason, dryRun)
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&partitions, "partition", "p", nil, "Partition to stop (repeatable; default every partition)")
+	cmd.Flags().StringVar(&reason, "reason", "", "Why bursting is stopped, for the audit log")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the instances and nodes that would be stopped")
+	return cmd
+}
+
+func clearCmd() *cobra.Command {
+	var partitions []string
+
+	cmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Clear emergency stops so partitions burst again",
+		Long: `Clear the emergency stop of the partitions given with --partition, or every
+stop, and power down the nodes the stop marked DOWN so Slurm may resume them.
+A single partition cannot be cleared while every partition is stopped.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(partitions)
+			if err != nil {
+				return err
+			}
+			return clearStops(cfg, partitions)
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&partitions, "partition", "p", nil, "Partition to clear (repeatable; default every stop)")
+	return cmd
+}
+
+func statusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "List the emergency stops in force",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(nil)
+			if err != nil {
+				return err
+			}
+			state, err := emergency.Load(cfg.EmergencyStop.StateFile)
+			if err != nil {
+				return err
+			}
+			if len(state.Stops) == 0 {
+				fmt.Println("No emergency stop in force")
+				return nil
+			}
+
+			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(writer, "PARTITION\tSINCE\tOPERATOR\tREASON")
+			for _, stop := range state.Stops {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n",
+					partitionLabel(stop.Partition), stop.StoppedAt.Local().Format("2006-01-02 15:04 MST"), stop.Operator, stop.Reason)
+			}
+			return writer.Flush()
+		},
+	}
+}
+
+// loadConfig loads the configuration and checks that the partitions are
+// configured
+func loadConfig(partitions []string) (*config.Config, error) {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	for _, partition := range partitions {
+		if cfg.FindPartition(partition) == nil {
+			return nil, fmt.Errorf("partition %q is not configured", partition)
+		}
+	}
+	return cfg, nil
+}
+
+// stopBursting engages the stop before terminating anything, so no resume
+// launches behind it, and records the event even when a step fails. It runs
+// until every instance is terminated, however many there are, unless
+// interrupted.
+func stopBursting(cfg *config.Config, partitions []string, reason string, dryRun bool) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	event := emergency.Event{
+		Time:       time.Now().UTC(),
+		Action:     emergency.ActionStop,
+		Partitions: partitions,
+		Operator:   emergency.Operator(),
+		Reason:     reason,
+	}
+	var errs []error
+
+	if !dryRun {
+		scopes := partitions
+		if len(scopes) == 0 {
+			scopes = []string{""}
+		}
+		for _, partition := range scopes {
+			if err := emergency.Engage(cfg.EmergencyStop.StateFile, emergency.Stop{
+				Partition: partition,
+				Reason:    reason,
+				Operator:  event.Operator,
+				StoppedAt: event.Time,
+			}); err != nil {
+				errs = append(errs, fmt.Errorf("failed to engage emergency stop: %w", err))
+			}
+		}
+	}
+
+	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to create AWS client: %w", err))
+	} else {
+		instances, err := awsClient.TerminateAll(ctx, partitions, dryRun)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to terminate instances: %w", err))
+		}
+		for _, instance := range instances {
+			event.Instances = append(event.Instances, instance.InstanceID)
+			fmt.Printf("%s %s %s\n", terminatedLabel(dryRun), instance.InstanceID, instance.NodeName)
+		}
+	}
+
+	slurmClient := slurm.NewClient(logger, &cfg.Slurm)
+	nodeNames, err := partitionNodes(cfg, slurmClient, partitions)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if dryRun {
+		fmt.Printf("Would mark %d nodes DOWN\n", len(nodeNames))
+	} else if err := slurmClient.SetNodesState(nodeNames, "DOWN", emergency.NodeReason); err != nil {
+		errs = append(errs, fmt.Errorf("failed to mark nodes down: %w", err))
+	} else {
+		event.Nodes = nodeNames
+		fmt.Printf("Marked %d nodes DOWN\n", len(nodeNames))
+	}
+
+	err = errors.Join(errs...)
+	if dryRun {
+		return err
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if auditErr := emergency.Record(cfg.EmergencyStop.AuditLog, event); auditErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to record audit event: %w", auditErr))
+	}
+	logger.Warn("Emergency stop engaged",
+		zap.Strings("partitions", partitions),
+		zap.String("operator", event.Operator),
+		zap.String("reason", reason),
+		zap.Int("instances", len(event.Instances)),
+		zap.Int("nodes", len(event.Nodes)))
+	return err
+}
+
+// clearStops lifts the stops and powers down the nodes that still carry the
+// stop's reason; the state manager then returns them to service as usual
+func clearStops(cfg *config.Config, partitions []string) error {
+	event := emergency.Event{
+		Time:       time.Now().UTC(),
+		Action:     emergency.ActionClear,
+		Partitions: partitions,
+		Operator:   emergency.Operator(),
+	}
+
+	scopes := partitions
+	if len(scopes) == 0 {
+		scopes = []string{""}
+	}
+	var cleared []emergency.Stop
+	for _, partition := range scopes {
+		stops, err := emergency.Clear(cfg.EmergencyStop.StateFile, partition)
+		if err != nil {
+			return err
+		}
+		cleared = append(cleared, stops...)
+	}
+	if len(cleared) == 0 {
+		fmt.Println("No emergency stop in force")
+		return nil
+	}
+
+	var errs []error
+	slurmClient := slurm.NewClient(logger, &cfg.Slurm)
+	nodeNames, err := partitionNodes(cfg, slurmClient, partitions)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	nodes, err := slurmClient.GetNodeState(nodeNames)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to get node states: %w", err))
+	}
+	for _, node := range nodes {
+		if node.Reason == emergency.NodeReason {
+			event.Nodes = append(event.Nodes, node.NodeName)
+		}
+	}
+	if err := slurmClient.SetNodesState(event.Nodes, "POWER_DOWN", clearedReason); err != nil {
+		errs = append(errs, fmt.Errorf("failed to power down stopped nodes: %w", err))
+	}
+	for _, stop := range cleared {
+		fmt.Printf("Cleared emergency stop of %s\n", partitionLabel(stop.Partition))
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		event.Error = err.Error()
+	}
+	if auditErr := emergency.Record(cfg.EmergencyStop.AuditLog, event); auditErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to record audit event: %w", auditErr))
+	}
+	logger.Warn("Emergency stop cleared",
+		zap.Strings("partitions", partitions),
+		zap.String("operator", event.Operator),
+		zap.Int("nodes", len(event.Nodes)))
+	return err
+}
+
+// partitionNodes expands the node groups of the partitions, or of every
+// partition, into their Slurm node names
+func partitionNodes(cfg *config.Config, slurmClient *slurm.Client, partitions []string) ([]string, error) {
+	var nodeNames []string
+	for _, partition := range cfg.Slurm.Partitions {
+		if len(partitions) > 0 && !slices.Contains(partitions, partition.PartitionName) {
+			continue
+		}
+		for _, nodeGroup := range partition.NodeGroups {
+			nodeRange := cfg.GetNodeRange(partition.PartitionName, nodeGroup.NodeGroupName, nodeGroup.MaxNodes)
+			names, err := slurmClient.ParseNodeList(nodeRange)
+			if err != nil {
+				return nodeNames, fmt.Errorf("failed to parse node list %s: %w", nodeRange, err)
+			}
+			nodeNames = append(nodeNames, names...)
+		}
+	}
+	return nodeNames, nil
+}
+
+func partitionLabel(partition string) string {
+	if partition == "" {
+		return "all partitions"
+	}
+	return partition
+}
+
+func terminatedLabel(dryRun bool) string {
+	if dryRun {
+		return "Would terminate"
+	}
+	return "Terminated"
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/batch"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/epilog"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	configFile string
+	syncExport bool
+	logger     *zap.Logger
+)
+
+func main() {
+	var err error
+	logger, err = zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-epilog",
+		Short: "Queue completed burst jobs for performance and cost export",
+		Long: `Run from Slurm's EpilogSlurmctld. Detects jobs that ran on burst nodes and
+queues them for the state manager, which runs the configured
+aws-slurm-burst-export-performance formats with per-export timeouts.
+
+The epilog never waits on AWS and always exits 0, so it cannot hold up
+slurmctld or affect job state. Use --sync to export inline instead, for
+example when testing.`,
+		RunE: runEpilog,
+	}
+
+	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+	rootCmd.Flags().BoolVar(&syncExport, "sync", false, "Export immediately instead of queueing for the state manager")
+
+	if err := rootCmd.Execute(); err != nil {
+		// Errors are logged but never returned to slurmctld
+		logger.Error("Epilog failed", zap.Error(err))
+	}
+}
+
+func runEpilog(cmd *cobra.Command, args []string) error {
+	req := &epilog.Request{
+		JobID:     os.Getenv("SLURM_JOB_ID"),
+		Partition: os.Getenv("SLURM_JOB_PARTITION"),
+		NodeList:  os.Getenv("SLURM_JOB_NODELIST"),
+		Account:   os.Getenv("SLURM_JOB_ACCOUNT"),
+		User:      os.Getenv("SLURM_JOB_USER"),
+		QueuedAt:  time.Now(),
+	}
+	if req.JobID == "" {
+		return fmt.Errorf("SLURM_JOB_ID is not set; run from EpilogSlurmctld")
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if !epilog.IsBurstJob(cfg, req.Partition, req.NodeList) {
+		logger.Debug("Job did not use burst nodes",
+			zap.String("job_id", req.JobID),
+			zap.String("partition", req.Partition))
+		return nil
+	}
+
+	// Tasks handed to AWS Batch are exported with their Batch job
+	if handedOffToBatch(cfg) {
+		logger.Debug("Array task ran on AWS Batch", zap.String("job_id", req.JobID))
+		return nil
+	}
+
+	if syncExport {
+		processor := epilog.NewProcessor(logger, cfg, configFile, epilog.ExecRunner)
+		return processor.Export(context.Background(), req)
+	}
+
+	file, err := epilog.Enqueue(cfg.Epilog.QueueDir, req)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Queued burst job for export",
+		zap.String("job_id", req.JobID),
+		zap.String("partition", req.Partition),
+		zap.String("file", file))
+	return nil
+}
+
+// handedOffToBatch reports whether resume cancelled this array task when it
+// handed its array job to AWS Batch
+func handedOffToBatch(cfg *config.Config) bool {
+	arrayJobID := os.Getenv("SLURM_ARRAY_JOB_ID")
+	task, err := strconv.Atoi(os.Getenv("SLURM_ARRAY_TASK_ID"))
+	if cfg.Ecosystem.DataExchangeDir == "" || arrayJobID == "" || err != nil {
+		return false
+	}
+	record, err := batch.ReadRecord(cfg.Ecosystem.DataExchangeDir, arrayJobID)
+	return err == nil && record != nil && record.Includes(task)
+}
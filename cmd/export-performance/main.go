@@ -1,27 +1,45 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/scttfrdmn/aws-slurm-burst/internal/anonymize"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/batch"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/costs"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/elastic"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/learning"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/mpiprofile"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/nodemetrics"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/policysync"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/prediction"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/spend"
 	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
 var (
-	configFile   string
-	jobID        string
-	outputDir    string
-	outputFormat string
-	anonymize    bool
-	logger       *zap.Logger
+	configFile    string
+	jobID         string
+	outputDir     string
+	output        string
+	outputFormat  string
+	anonymizeData bool
+	logger        *zap.Logger
 )
 
 func main() {
@@ -51,14 +69,18 @@ for automatic performance data collection.`,
 	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
 	rootCmd.Flags().StringVar(&jobID, "job-id", "", "Slurm job ID to export performance data for (required)")
 	rootCmd.Flags().StringVar(&outputDir, "output-dir", "/var/spool/asba/learning", "Directory to write performance data")
-	rootCmd.Flags().StringVar(&outputFormat, "format", "asba-learning", "Output format: asba-learning, json, slurm-comment, asbb-reconciliation")
-	rootCmd.Flags().BoolVar(&anonymize, "anonymize", false, "Anonymize user and project data for institutional sharing")
+	rootCmd.Flags().StringVar(&output, "output", "", "Upload to s3://bucket/prefix instead of writing to --output-dir")
+	rootCmd.Flags().StringVar(&outputFormat, "format", "asba-learning", "Output format: asba-learning, json, csv, parquet, slurm-comment, asbb-reconciliation")
+	rootCmd.Flags().BoolVar(&anonymizeData, "anonymize", false, "Hash user and account IDs, redact configured fields and verify output before sharing")
 
 	if err := rootCmd.MarkFlagRequired("job-id"); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to mark flag as required: %v\n", err)
 		os.Exit(1)
 	}
 
+	rootCmd.AddCommand(aggregateCmd())
+	rootCmd.AddCommand(verifyCmd())
+
 	if err := rootCmd.Execute(); err != nil {
 		logger.Error("Performance export failed", zap.Error(err))
 		os.Exit(1)
@@ -78,11 +100,21 @@ func exportPerformanceData(cmd *cobra.Command, args []string) error {
 	// Initialize Slurm client
 	slurmClient := slurm.NewClient(logger, &cfg.Slurm)
 
+	var dest destination = localDestination{dir: outputDir}
+	if output != "" {
+		sink, err := aws.NewS3Sink(ctx, logger, &cfg.AWS, &cfg.Export, output)
+		if err != nil {
+			return fmt.Errorf("failed to configure S3 output: %w", err)
+		}
+		dest = s3Destination{sink: sink}
+	}
+
 	logger.Info("Exporting performance data",
 		zap.String("job_id", jobID),
 		zap.String("format", outputFormat),
 		zap.String("output_dir", outputDir),
-		zap.Bool("anonymize", anonymize))
+		zap.String("output", output),
+		zap.Bool("anonymize", anonymizeData))
 
 	// Collect performance data
 	perfData, err := collectPerformanceData(ctx, slurmClient, jobID)
@@ -90,24 +122,239 @@ func exportPerformanceData(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to collect performance data: %w", err)
 	}
 
-	// Apply anonymization if requested
-	if anonymize {
-		anonymizePerformanceData(perfData)
+	// Measured node utilization replaces the estimates when the collector ran
+	nodeSummaries, err := loadNodeMetrics(ctx, cfg, jobID)
+	if err != nil {
+		logger.Warn("Failed to load node metrics", zap.String("job_id", jobID), zap.Error(err))
+	}
+	nodemetrics.Apply(&perfData.AWSPerformanceMetrics, nodeSummaries)
+
+	// Profiled mpirun launches replace the estimated MPI communication figures
+	if cfg.MPI.Profiling.Enabled {
+		profiles, err := mpiprofile.LoadDir(cfg.MPI.Profiling.OutputDir, jobID)
+		if err != nil {
+			logger.Warn("Failed to load MPI profiles", zap.String("job_id", jobID), zap.Error(err))
+		}
+		if len(profiles) > 0 {
+			if perfData.MPIOptimizationResults == nil {
+				perfData.MPIOptimizationResults = &types.MPIOptimizationResults{}
+			}
+			mpiprofile.Apply(perfData.MPIOptimizationResults, profiles)
+		}
+	}
+
+	// Elastic MPI jobs' interrupted nodes and their replacements, as the state manager recorded them
+	if cfg.ElasticMPI.Enabled && cfg.Ecosystem.DataExchangeDir != "" {
+		record, err := elastic.ReadRecord(cfg.Ecosystem.DataExchangeDir, jobID)
+		if err != nil {
+			logger.Warn("Failed to read elastic MPI record", zap.String("job_id", jobID), zap.Error(err))
+		}
+		if record != nil && len(record.Events) > 0 {
+			if perfData.MPIOptimizationResults == nil {
+				perfData.MPIOptimizationResults = &types.MPIOptimizationResults{}
+			}
+			perfData.MPIOptimizationResults.ElasticResizes = record.Events
+			perfData.AWSPerformanceMetrics.SpotInterruptions = len(record.Events)
+		}
+	}
+
+	// Array jobs handed to AWS Batch ran on no burst nodes and are priced from their Batch record
+	var batchRecord *batch.Record
+	if cfg.Ecosystem.DataExchangeDir != "" {
+		if batchRecord, err = batch.ReadRecord(cfg.Ecosystem.DataExchangeDir, jobID); err != nil {
+			logger.Warn("Failed to read AWS Batch record", zap.String("job_id", jobID), zap.Error(err))
+		}
+	}
+	if batchRecord != nil && batchRecord.Done() {
+		applyBatchExecution(perfData, batchRecord)
+	} else {
+		// Resume's execution result replaces the launch figures read from the job comment
+		result, err := applyExecutionResult(cfg, perfData)
+		if err != nil {
+			logger.Warn("Failed to read execution result", zap.String("job_id", jobID), zap.Error(err))
+		}
+
+		applyCostModels(cfg, perfData, result, nodeSummaries)
+	}
+
+	// Charge the job to its month's spend before anonymization replaces its account
+	if err := policysync.Apply(cfg); err != nil {
+		logger.Warn("Policy ceilings cover only the users and accounts rules name", zap.Error(err))
+	}
+	if len(cfg.SpendLimits()) > 0 {
+		if err := recordSpend(cfg, perfData); err != nil {
+			logger.Warn("Failed to record job spend", zap.String("job_id", jobID), zap.Error(err))
+		}
+	}
+
+	if err := validatePrediction(cfg, perfData); err != nil {
+		logger.Warn("Failed to validate ASBA prediction", zap.String("job_id", jobID), zap.Error(err))
+	}
+
+	// Apply anonymization if requested; anonymized output is verified before it is written
+	var verifier *anonymize.Verifier
+	if anonymizeData {
+		if verifier, err = anonymizePerformanceData(&cfg.Export.Anonymize, perfData); err != nil {
+			return fmt.Errorf("failed to anonymize performance data: %w", err)
+		}
 	}
 
 	// Export in requested format
-	if err := exportData(perfData, outputFormat, outputDir); err != nil {
+	location, err := exportData(ctx, perfData, outputFormat, dest, verifier)
+	if err != nil {
 		return fmt.Errorf("failed to export data: %w", err)
 	}
 
 	logger.Info("Performance data exported successfully",
 		zap.String("job_id", jobID),
-		zap.String("output_dir", outputDir),
+		zap.String("location", location),
 		zap.Float64("total_cost", perfData.CostAnalysis.TotalCostUSD))
 
 	return nil
 }
 
+// aggregateCmd summarizes previously exported performance data for institutional reporting
+func aggregateCmd() *cobra.Command {
+	var (
+		learningDir string
+		since       string
+		until       string
+		outputFile  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "aggregate",
+		Short: "Summarize exported performance data over a date range",
+		Long: `Scan the learning directory for exported job performance files and emit a
+LearningDataSummary with domain breakdowns, instance type efficiency and
+improvement metrics for jobs that ended in the date range.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sinceTime, err := costs.ParseDate(since)
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			untilTime, err := costs.ParseDate(until)
+			if err != nil {
+				return fmt.Errorf("invalid --until: %w", err)
+			}
+
+			feedback, skipped, err := learning.LoadFeedback(learningDir)
+			if err != nil {
+				return err
+			}
+			if len(skipped) > 0 {
+				logger.Warn("Skipped unreadable performance files", zap.Strings("files", skipped))
+			}
+
+			summary := learning.Summarize(feedback, sinceTime, untilTime)
+			data, err := json.MarshalIndent(summary, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal learning summary: %w", err)
+			}
+
+			if outputFile == "" {
+				fmt.Println(string(data))
+				return nil
+			}
+			if err := os.WriteFile(outputFile, data, 0600); err != nil {
+				return fmt.Errorf("failed to write learning summary: %w", err)
+			}
+			logger.Info("Learning summary exported",
+				zap.String("file", outputFile),
+				zap.Int("job_count", summary.JobCount))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&learningDir, "learning-dir", "/var/spool/asba/learning", "Directory of exported performance data")
+	cmd.Flags().StringVar(&since, "since", "", "Include jobs that ended on or after this date (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&until, "until", "", "Include jobs that ended before this date (YYYY-MM-DD)")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write the summary to this file instead of stdout")
+
+	return cmd
+}
+
+// verifyCmd scans export files for personal data before they are shared
+func verifyCmd() *cobra.Command {
+	var configFile string
+
+	cmd := &cobra.Command{
+		Use:   "verify FILE|DIR...",
+		Short: "Scan exports for personal data before sharing",
+		Long: `Scan export files, or every file in the given directories, for email
+addresses, IP addresses, home directories, AWS access keys and the configured
+export.anonymize.pii_patterns. Exits non-zero when anything is found.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(configFile)
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			verifier, err := anonymize.NewVerifier(cfg.Export.Anonymize.PIIPatterns, nil)
+			if err != nil {
+				return err
+			}
+
+			files, err := exportFiles(args)
+			if err != nil {
+				return err
+			}
+
+			flagged := 0
+			for _, file := range files {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %w", file, err)
+				}
+				findings := verifier.Scan(data)
+				if len(findings) == 0 {
+					continue
+				}
+				flagged++
+				for _, finding := range findings {
+					fmt.Printf("%s: %s\n", file, finding)
+				}
+			}
+
+			if flagged > 0 {
+				return fmt.Errorf("%d of %d files contain possible personal data", flagged, len(files))
+			}
+			fmt.Printf("%d files verified, no personal data found\n", len(files))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+
+	return cmd
+}
+
+// exportFiles expands directories to the regular files directly inside them
+func exportFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
 // collectPerformanceData gathers comprehensive performance metrics for a job
 func collectPerformanceData(ctx context.Context, slurmClient *slurm.Client, jobID string) (*types.PerformanceFeedback, error) {
 	// Get job information from Slurm accounting
@@ -130,18 +377,21 @@ func collectPerformanceData(ctx context.Context, slurmClient *slurm.Client, jobI
 				ExecutionDuration: types.Duration(jobInfo.Elapsed),
 				Success:           jobInfo.State == "COMPLETED",
 				NodeCount:         jobInfo.NodeCount,
+				Nodes:             slurm.ExpandHostlist(jobInfo.NodeList),
+				CPUs:              jobInfo.CPUs,
+				MemoryMB:          jobInfo.Memory,
 				StartTime:         jobInfo.Start,
 				EndTime:           jobInfo.End,
 			},
 		},
-		PredictionValidation:  calculatePredictionAccuracy(jobInfo),
 		AWSPerformanceMetrics: collectAWSMetrics(ctx, jobInfo),
 		CostAnalysis:          analyzeCosts(jobInfo),
 		ExecutionContext: types.ExecutionContext{
 			AWSRegion:     "us-east-1", // TODO: Get from config
 			PluginVersion: "0.2.0",
 			ExecutionMode: determineExecutionMode(jobInfo.Comment),
-			SlurmVersion:  getSlurmVersion(),
+			TraceID:       parseTraceIDFromComment(jobInfo.Comment),
+			SlurmVersion:  getSlurmVersion(slurmClient),
 		},
 	}
 
@@ -167,6 +417,7 @@ type JobAccountingInfo struct {
 	Partition string
 	State     string
 	NodeCount int
+	NodeList  string
 	CPUs      int
 	Memory    int
 	Start     time.Time
@@ -187,44 +438,279 @@ func getJobAccountingInfo(ctx context.Context, slurmClient *slurm.Client, jobID
 		Partition: "aws-cpu",
 		State:     "COMPLETED",
 		NodeCount: 4,
+		NodeList:  "aws-cpu-[001-004]",
 		CPUs:      16,
 		Memory:    32768,
 		Start:     time.Now().Add(-2 * time.Hour),
 		End:       time.Now(),
 		Elapsed:   2 * time.Hour,
-		Comment:   "aws_meta:{\"instances\":[\"c5n.xlarge\"],\"cost\":12.45,\"efa\":true}",
+		Comment:   "aws_meta:{\"instances\":[\"c5n.xlarge\"],\"cost\":12.45,\"efa\":true,\"node_azs\":{\"aws-cpu-001\":\"us-east-1a\",\"aws-cpu-002\":\"us-east-1a\",\"aws-cpu-003\":\"us-east-1b\",\"aws-cpu-004\":\"us-east-1b\"},\"spot_scores\":{\"us-east-1a\":9,\"us-east-1b\":7}}",
 	}, nil
 }
 
 // Helper functions for data processing
+func parseTraceIDFromComment(comment string) string {
+	meta, err := types.ParseAWSJobMetadata(comment)
+	if err != nil {
+		return ""
+	}
+	return meta.TraceID
+}
+
 func parseInstanceTypesFromComment(comment string) []string {
 	// Parse AWS metadata from Slurm job comment
-	// For now, return mock data
-	return []string{"c5n.xlarge"}
+	meta, err := types.ParseAWSJobMetadata(comment)
+	if err != nil {
+		return nil
+	}
+	return meta.Instances
 }
 
 func parseActualCostFromComment(comment string) float64 {
 	// Parse cost from AWS metadata in comment
-	// For now, return mock data
-	return 12.45
+	meta, err := types.ParseAWSJobMetadata(comment)
+	if err != nil {
+		return 0
+	}
+	return meta.Cost
 }
 
-func calculatePredictionAccuracy(jobInfo *JobAccountingInfo) types.PredictionValidation {
-	// Calculate how accurate ASBA predictions were
-	// For now, return mock data
-	return types.PredictionValidation{
-		CostAccuracy:           0.94,
-		RuntimeAccuracy:        0.91,
-		InstanceTypeOptimal:    true,
-		DomainDetectionCorrect: true,
-		OverallAccuracyScore:   0.92,
+// parseNodeZonesFromComment returns each node's AZ and the distinct AZs used
+func parseNodeZonesFromComment(comment string) (map[string]string, []string) {
+	meta, err := types.ParseAWSJobMetadata(comment)
+	if err != nil || len(meta.NodeAvailabilityZones) == 0 {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	var zones []string
+	for _, az := range meta.NodeAvailabilityZones {
+		if !seen[az] {
+			seen[az] = true
+			zones = append(zones, az)
+		}
 	}
+	sort.Strings(zones)
+
+	return meta.NodeAvailabilityZones, zones
+}
+
+// validatePrediction compares the plan resume recorded for the job with its actual
+// execution. Jobs launched without an ASBA plan are left unvalidated.
+func validatePrediction(cfg *config.Config, perfData *types.PerformanceFeedback) error {
+	record, err := prediction.Load(cfg.Ecosystem.DataExchangeDir, perfData.JobMetadata.JobID)
+	if err != nil || record == nil {
+		return err
+	}
+
+	perfData.JobMetadata.OriginalASBAPrediction = record.Plan
+	perfData.PredictionValidation = prediction.Validate(record, perfData.JobMetadata.ActualExecution, perfData.MPIOptimizationResults != nil)
+	return nil
+}
+
+// applyExecutionResult fills provisioning time, placement, spot placement
+// scores, fallbacks, the instance types used and the per-instance costs and
+// spot savings from the execution result resume recorded for the job, and
+// returns the result, when there is one. Jobs whose nodes another job's
+// resume launched use that resume's result, cut down to their nodes, and are
+// charged their share of instances in packed node groups.
+func applyExecutionResult(cfg *config.Config, perfData *types.PerformanceFeedback) (*types.ExecutionResult, error) {
+	if cfg.Ecosystem.DataExchangeDir == "" {
+		return nil, nil
+	}
+	result, err := readExecutionResult(cfg.Ecosystem.DataExchangeDir, perfData)
+	if err != nil || result == nil {
+		return nil, err
+	}
+
+	metrics := &perfData.AWSPerformanceMetrics
+	metrics.ProvisioningTime = result.ExecutionDuration
+	if len(result.SpotPlacementScores) > 0 {
+		metrics.SpotPlacementScores = result.SpotPlacementScores
+	}
+	if len(result.Fallbacks) > 0 {
+		metrics.LaunchFallbacks = result.Fallbacks
+	}
+
+	nodeZones := make(map[string]string)
+	var zones, instanceTypes []string
+	for _, instance := range result.LaunchedInstances {
+		if instance.AvailabilityZone != "" {
+			nodeZones[instance.NodeName] = instance.AvailabilityZone
+			if !slices.Contains(zones, instance.AvailabilityZone) {
+				zones = append(zones, instance.AvailabilityZone)
+			}
+		}
+		if instance.InstanceType != "" && !slices.Contains(instanceTypes, instance.InstanceType) {
+			instanceTypes = append(instanceTypes, instance.InstanceType)
+		}
+	}
+	if len(nodeZones) > 0 {
+		sort.Strings(zones)
+		metrics.NodeAvailabilityZones = nodeZones
+		metrics.AvailabilityZones = zones
+	}
+	if len(instanceTypes) > 0 {
+		perfData.JobMetadata.ActualExecution.InstanceTypesUsed = instanceTypes
+	}
+
+	execution := perfData.JobMetadata.ActualExecution
+	instances, shares := costs.PackInstances(cfg, jobAllocation(perfData), result.LaunchedInstances)
+	perfData.CostAnalysis.ApplyLaunchPrices(instances, execution.EndTime, time.Duration(execution.ExecutionDuration))
+	costs.ApplyPackedShares(&perfData.CostAnalysis, shares)
+	return result, nil
+}
+
+// applyBatchExecution replaces the job's execution and costs with those of
+// the AWS Batch job its tasks were handed to: the tasks' run time priced at
+// the batch_fallback rates, with no nodes, storage or data transfer of its own
+func applyBatchExecution(perfData *types.PerformanceFeedback, record *batch.Record) {
+	tasks := len(record.Tasks)
+	metadata := &perfData.JobMetadata
+	metadata.JobName = record.JobName
+	metadata.Partition = record.Partition
+	if record.Account != "" {
+		metadata.ProjectID = record.Account
+	}
+	metadata.ActualExecution = types.ActualExecution{
+		ActualCostUSD:     record.CostUSD,
+		ExecutionDuration: types.Duration(record.EndTime.Sub(record.StartTime)),
+		Success:           record.Status == batch.StatusSucceeded,
+		CPUs:              record.VCPUs * tasks,
+		MemoryMB:          record.MemoryMB * tasks,
+		StartTime:         record.StartTime,
+		EndTime:           record.EndTime,
+	}
+	if record.Failed > 0 {
+		metadata.ActualExecution.ErrorDetails = fmt.Sprintf("%d of %d tasks failed on AWS Batch", record.Failed, tasks)
+		if record.StatusReason != "" {
+			metadata.ActualExecution.ErrorDetails += ": " + record.StatusReason
+		}
+	}
+
+	if !record.StartTime.IsZero() {
+		perfData.AWSPerformanceMetrics.ProvisioningTime = types.Duration(record.StartTime.Sub(record.SubmittedAt))
+	}
+	perfData.ExecutionContext.ExecutionMode = "aws_batch"
+
+	perfData.CostAnalysis = types.ActualCostAnalysis{
+		ComputeCostUSD: record.CostUSD,
+		TotalCostUSD:   record.CostUSD,
+	}
+	if cpuHours := record.TaskHours * float64(record.VCPUs); cpuHours > 0 {
+		perfData.CostAnalysis.CostPerCPUHour = record.CostUSD / cpuHours
+	}
+}
+
+// readExecutionResult loads the job's own execution result or else, for a
+// job packed onto nodes launched for another, the result of a resume its
+// nodes' trace IDs name, with only the job's nodes' instances
+func readExecutionResult(root string, perfData *types.PerformanceFeedback) (*types.ExecutionResult, error) {
+	result, err := exchange.ReadResult(root, perfData.JobMetadata.JobID)
+	if err != nil || result != nil {
+		return result, err
+	}
+
+	nodes := perfData.JobMetadata.ActualExecution.Nodes
+	for _, traceID := range strings.Split(perfData.ExecutionContext.TraceID, ",") {
+		if traceID == "" {
+			continue
+		}
+		if result, err = exchange.ReadResult(root, "trace-"+traceID); err != nil || result == nil {
+			continue
+		}
+		if len(nodes) > 0 {
+			result.LaunchedInstances = slices.DeleteFunc(result.LaunchedInstances, func(instance types.InstanceInfo) bool {
+				return !slices.Contains(nodes, instance.NodeName)
+			})
+		}
+		return result, nil
+	}
+	return nil, err
+}
+
+// jobAllocation is what the job was allocated on each node
+func jobAllocation(perfData *types.PerformanceFeedback) costs.Allocation {
+	execution := perfData.JobMetadata.ActualExecution
+	nodes := max(execution.NodeCount, 1)
+	return costs.Allocation{CPUsPerNode: execution.CPUs / nodes, MemoryMBPerNode: execution.MemoryMB / nodes}
+}
+
+// applyCostModels prices the job's data transfer, from the traffic its nodes
+// measured when the collector ran, and its storage, from the EBS volumes its
+// instances launched with and the FSx filesystems its partition mounts. Usage
+// on committed capacity is priced at its discounted rate, so it is not
+// charged again at on-demand prices.
+func applyCostModels(cfg *config.Config, perfData *types.PerformanceFeedback, result *types.ExecutionResult, nodeSummaries []types.NodeMetricsSummary) {
+	execution := perfData.JobMetadata.ActualExecution
+	zones := 1
+	if len(perfData.AWSPerformanceMetrics.NodeAvailabilityZones) > 0 {
+		zones = len(perfData.AWSPerformanceMetrics.AvailabilityZones)
+	}
+
+	transfer := costs.EstimateTransfer(cfg.Costs.DataTransfer, costs.TransferUsage{
+		Nodes:     execution.NodeCount,
+		NodeHours: float64(execution.NodeCount) * time.Duration(execution.ExecutionDuration).Hours(),
+		Zones:     zones,
+		Summaries: nodeSummaries,
+	})
+
+	storageUsage := costs.StorageUsage{
+		Partition: perfData.JobMetadata.Partition,
+		Nodes:     execution.NodeCount,
+		End:       execution.EndTime,
+		Elapsed:   time.Duration(execution.ExecutionDuration),
+	}
+	if result != nil {
+		storageUsage.Instances, storageUsage.Shares = costs.PackInstances(cfg, jobAllocation(perfData), result.LaunchedInstances)
+	}
+	storage := costs.EstimateStorage(cfg, storageUsage)
+
+	analysis := &perfData.CostAnalysis
+	analysis.ComputeCostUSD = max(analysis.ComputeCostUSD-costs.ApplyCommitments(cfg.Costs.Commitments, analysis), 0)
+	analysis.DataTransfer = transfer
+	analysis.NetworkCostUSD = transfer.CostUSD
+	analysis.Storage = storage
+	analysis.StorageCostUSD = storage.CostUSD
+	analysis.TotalCostUSD = analysis.ComputeCostUSD + analysis.StorageCostUSD + analysis.NetworkCostUSD
+}
+
+// recordSpend charges the job's total cost to its partition, account and user
+// in the spend ledger resume checks ceilings against
+func recordSpend(cfg *config.Config, perfData *types.PerformanceFeedback) error {
+	return spend.New(cfg.Costs.Ceilings.LedgerDir).Record(perfData.JobMetadata.JobID, perfData.JobMetadata.ActualExecution.EndTime, spend.Charge{
+		Partition: perfData.JobMetadata.Partition,
+		Account:   perfData.JobMetadata.ProjectID,
+		User:      perfData.JobMetadata.UserID,
+		CostUSD:   perfData.CostAnalysis.TotalCostUSD,
+	})
+}
+
+// loadNodeMetrics reads the per-node summaries the node metrics collector wrote for
+// the job. It returns none when the collector is disabled.
+func loadNodeMetrics(ctx context.Context, cfg *config.Config, jobID string) ([]types.NodeMetricsSummary, error) {
+	if !cfg.NodeMetrics.Enabled {
+		return nil, nil
+	}
+	if !aws.IsS3URL(cfg.NodeMetrics.Destination) {
+		return nodemetrics.LoadDir(cfg.NodeMetrics.Destination, jobID)
+	}
+
+	source, err := aws.NewS3Source(ctx, logger, &cfg.AWS, &cfg.Export, cfg.NodeMetrics.Destination)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := source.ReadAll(ctx, nodemetrics.JobDir(jobID))
+	if err != nil {
+		return nil, err
+	}
+	return nodemetrics.Decode(objects)
 }
 
 func collectAWSMetrics(ctx context.Context, jobInfo *JobAccountingInfo) types.AWSPerformanceMetrics {
 	// Collect AWS-specific performance metrics
 	// For now, return mock data
-	return types.AWSPerformanceMetrics{
+	metrics := types.AWSPerformanceMetrics{
 		EFAUtilization:              0.89,
 		PlacementGroupEffectiveness: 0.95,
 		SpotInterruptions:           0,
@@ -234,16 +720,27 @@ func collectAWSMetrics(ctx context.Context, jobInfo *JobAccountingInfo) types.AW
 		ProvisioningTime:            types.Duration(5 * time.Minute),
 		AvailabilityZones:           []string{"us-east-1a", "us-east-1b"},
 	}
+
+	// Per-node placement is recorded on the job at resume time
+	if nodeZones, zones := parseNodeZonesFromComment(jobInfo.Comment); len(nodeZones) > 0 {
+		metrics.NodeAvailabilityZones = nodeZones
+		metrics.AvailabilityZones = zones
+	}
+	if meta, err := types.ParseAWSJobMetadata(jobInfo.Comment); err == nil {
+		metrics.SpotPlacementScores = meta.SpotPlacementScores
+		metrics.LaunchFallbacks = meta.Fallbacks
+	}
+
+	return metrics
 }
 
 func analyzeCosts(jobInfo *JobAccountingInfo) types.ActualCostAnalysis {
-	// Analyze actual costs vs predictions
+	// Analyze actual costs vs predictions; spot savings come from the prices
+	// resume recorded at launch, in applyExecutionResult, and network and
+	// storage costs from their models, in applyCostModels
 	return types.ActualCostAnalysis{
 		ComputeCostUSD: 12.45,
-		StorageCostUSD: 0.25,
-		NetworkCostUSD: 0.05,
-		TotalCostUSD:   12.75,
-		SpotSavingsUSD: 3.21,
+		TotalCostUSD:   12.45,
 		CostPerCPUHour: 0.78,
 	}
 }
@@ -267,67 +764,146 @@ func collectMPIMetrics(ctx context.Context, jobInfo *JobAccountingInfo) (*types.
 }
 
 func determineExecutionMode(comment string) string {
+	if meta, err := types.ParseAWSJobMetadata(comment); err == nil && meta.ExecutionMode != "" {
+		return meta.ExecutionMode
+	}
 	if comment != "" && (contains(comment, "asba") || contains(comment, "execution_plan")) {
 		return "asba"
 	}
 	return "standalone"
 }
 
-func getSlurmVersion() string {
-	// Get Slurm version from system
-	return "21.08.8" // Mock for development
+func getSlurmVersion(slurmClient *slurm.Client) string {
+	version, err := slurmClient.Version()
+	if err != nil {
+		logger.Debug("Slurm version unknown", zap.Error(err))
+		return "unknown"
+	}
+	return version.String()
 }
 
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || (len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || len(s) > len(substr) && s[1:len(substr)+1] == substr)))
 }
 
-func anonymizePerformanceData(perfData *types.PerformanceFeedback) {
-	// Remove or hash identifying information
-	perfData.JobMetadata.UserID = "anonymous"
-	perfData.JobMetadata.ProjectID = "anonymized"
-	perfData.ExecutionContext.EnvironmentVariables = map[string]string{}
+// anonymizePerformanceData replaces identifiers with salted hashes and redacts the
+// configured fields, returning a verifier for the encoded output
+func anonymizePerformanceData(anonymizeConfig *config.AnonymizeConfig, perfData *types.PerformanceFeedback) (*anonymize.Verifier, error) {
+	key, err := anonymize.LoadKey(anonymizeConfig.SaltFile)
+	if err != nil {
+		return nil, err
+	}
+
+	anonymizer := anonymize.New(key, anonymizeConfig.Redact)
+	if err := anonymizer.Apply(perfData); err != nil {
+		return nil, err
+	}
+
+	return anonymize.NewVerifier(anonymizeConfig.PIIPatterns, anonymizer.Originals())
+}
+
+// destination stores an encoded export
+type destination interface {
+	write(ctx context.Context, format, name string, data []byte, date time.Time) (string, error)
 }
 
-func exportData(perfData *types.PerformanceFeedback, format, outputDir string) error {
+// localDestination writes exports to a directory, as the epilog expects
+type localDestination struct {
+	dir string
+}
+
+func (d localDestination) write(ctx context.Context, format, name string, data []byte, date time.Time) (string, error) {
 	// Ensure output directory exists
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	filename := filepath.Join(d.dir, name)
+	if err := os.WriteFile(filename, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write %s data: %w", format, err)
 	}
+	return filename, nil
+}
+
+// s3Destination uploads exports under date-partitioned keys
+type s3Destination struct {
+	sink *aws.S3Sink
+}
+
+func (d s3Destination) write(ctx context.Context, format, name string, data []byte, date time.Time) (string, error) {
+	return d.sink.Upload(ctx, d.sink.Key(format, name, date), data)
+}
+
+func exportData(ctx context.Context, perfData *types.PerformanceFeedback, format string, dest destination, verifier *anonymize.Verifier) (string, error) {
+	var (
+		name string
+		data []byte
+		err  error
+	)
 
 	switch format {
 	case "asba-learning", "json":
-		return exportJSON(perfData, outputDir)
+		// Both names produce the same document, so they share an S3 prefix
+		format = "json"
+		name, data, err = encodeJSON(perfData)
+	case "csv":
+		name, data, err = encodeTable(perfData, format, learning.WriteCSV)
+	case "parquet":
+		name, data, err = encodeTable(perfData, format, learning.WriteParquet)
 	case "slurm-comment":
-		return exportSlurmComment(perfData, outputDir)
+		name, data, err = encodeSlurmComment(perfData)
 	case "asbb-reconciliation":
-		return exportASBBReconciliation(perfData, outputDir)
+		name, data, err = encodeASBBReconciliation(perfData)
 	default:
-		return fmt.Errorf("unsupported export format: %s", format)
+		return "", fmt.Errorf("unsupported export format: %s", format)
+	}
+	if err != nil {
+		return "", err
 	}
-}
 
-func exportJSON(perfData *types.PerformanceFeedback, outputDir string) error {
-	filename := filepath.Join(outputDir, fmt.Sprintf("job-%s-performance.json", perfData.JobMetadata.JobID))
+	if verifier != nil {
+		if findings := verifier.Scan(data); len(findings) > 0 {
+			return "", fmt.Errorf("export withheld, possible personal data remains: %v", findings)
+		}
+	}
 
-	data, err := json.MarshalIndent(perfData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal performance data: %w", err)
+	// Partition by when the job ended so reruns of an export land in the same place
+	date := perfData.JobMetadata.ActualExecution.EndTime
+	if date.IsZero() {
+		date = time.Now()
 	}
 
-	if err := os.WriteFile(filename, data, 0600); err != nil {
-		return fmt.Errorf("failed to write performance data: %w", err)
+	location, err := dest.write(ctx, format, name, data, date)
+	if err != nil {
+		return "", err
 	}
 
 	logger.Info("Performance data exported",
-		zap.String("format", "json"),
-		zap.String("file", filename),
+		zap.String("format", format),
+		zap.String("location", location),
 		zap.Int("size_bytes", len(data)))
 
-	return nil
+	return location, nil
+}
+
+func encodeJSON(perfData *types.PerformanceFeedback) (string, []byte, error) {
+	data, err := json.MarshalIndent(perfData, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal performance data: %w", err)
+	}
+	return fmt.Sprintf("job-%s-performance.json", perfData.JobMetadata.JobID), data, nil
 }
 
-func exportSlurmComment(perfData *types.PerformanceFeedback, outputDir string) error {
+// encodeTable encodes the job as one row of the flat learning schema
+func encodeTable(perfData *types.PerformanceFeedback, format string, write func(io.Writer, []types.PerformanceFeedback) error) (string, []byte, error) {
+	var buf bytes.Buffer
+	if err := write(&buf, []types.PerformanceFeedback{*perfData}); err != nil {
+		return "", nil, fmt.Errorf("failed to encode %s performance data: %w", format, err)
+	}
+	return fmt.Sprintf("job-%s-performance.%s", perfData.JobMetadata.JobID, format), buf.Bytes(), nil
+}
+
+func encodeSlurmComment(perfData *types.PerformanceFeedback) (string, []byte, error) {
 	// Create compact metadata for Slurm comment field
 	metadata := map[string]interface{}{
 		"cost":      perfData.CostAnalysis.TotalCostUSD,
@@ -342,58 +918,39 @@ func exportSlurmComment(perfData *types.PerformanceFeedback, outputDir string) e
 
 	jsonData, err := json.Marshal(metadata)
 	if err != nil {
-		return fmt.Errorf("failed to marshal comment data: %w", err)
+		return "", nil, fmt.Errorf("failed to marshal comment data: %w", err)
 	}
 
 	commentData := fmt.Sprintf("aws_meta:%s", string(jsonData))
-	filename := filepath.Join(outputDir, fmt.Sprintf("job-%s-comment.txt", perfData.JobMetadata.JobID))
-
-	if err := os.WriteFile(filename, []byte(commentData), 0600); err != nil {
-		return fmt.Errorf("failed to write comment data: %w", err)
-	}
-
-	logger.Info("Slurm comment data exported",
-		zap.String("format", "slurm-comment"),
-		zap.String("file", filename),
-		zap.String("comment", commentData))
-
-	return nil
+	return fmt.Sprintf("job-%s-comment.txt", perfData.JobMetadata.JobID), []byte(commentData), nil
 }
 
-func exportASBBReconciliation(perfData *types.PerformanceFeedback, outputDir string) error {
+func encodeASBBReconciliation(perfData *types.PerformanceFeedback) (string, []byte, error) {
 	// Create ASBB-compatible cost reconciliation data
-	reconciliationData := map[string]interface{}{
-		"job_id":         perfData.JobMetadata.JobID,
-		"account":        perfData.JobMetadata.ProjectID,
-		"user_id":        perfData.JobMetadata.UserID,
-		"partition":      perfData.JobMetadata.Partition,
-		"actual_cost":    perfData.CostAnalysis.TotalCostUSD,
-		"compute_cost":   perfData.CostAnalysis.ComputeCostUSD,
-		"storage_cost":   perfData.CostAnalysis.StorageCostUSD,
-		"network_cost":   perfData.CostAnalysis.NetworkCostUSD,
-		"spot_savings":   perfData.CostAnalysis.SpotSavingsUSD,
-		"instance_types": perfData.JobMetadata.ActualExecution.InstanceTypesUsed,
-		"duration_hours": time.Duration(perfData.JobMetadata.ActualExecution.ExecutionDuration).Hours(),
-		"success":        perfData.JobMetadata.ActualExecution.Success,
-		"export_time":    time.Now().Format(time.RFC3339),
-		"plugin_version": perfData.ExecutionContext.PluginVersion,
-	}
-
-	filename := filepath.Join(outputDir, fmt.Sprintf("job-%s-asbb-reconciliation.json", perfData.JobMetadata.JobID))
+	reconciliationData := types.CostReconciliation{
+		JobID:         perfData.JobMetadata.JobID,
+		Account:       perfData.JobMetadata.ProjectID,
+		UserID:        perfData.JobMetadata.UserID,
+		Partition:     perfData.JobMetadata.Partition,
+		ActualCost:    perfData.CostAnalysis.TotalCostUSD,
+		ComputeCost:   perfData.CostAnalysis.ComputeCostUSD,
+		StorageCost:   perfData.CostAnalysis.StorageCostUSD,
+		NetworkCost:   perfData.CostAnalysis.NetworkCostUSD,
+		SpotSavings:   perfData.CostAnalysis.SpotSavingsUSD,
+		CommittedCost: perfData.CostAnalysis.CommittedCostUSD,
+		InstanceTypes: perfData.JobMetadata.ActualExecution.InstanceTypesUsed,
+		DurationHours: time.Duration(perfData.JobMetadata.ActualExecution.ExecutionDuration).Hours(),
+		Success:       perfData.JobMetadata.ActualExecution.Success,
+		ExportTime:    time.Now().Format(time.RFC3339),
+		PluginVersion: perfData.ExecutionContext.PluginVersion,
+	}
+	if endTime := perfData.JobMetadata.ActualExecution.EndTime; !endTime.IsZero() {
+		reconciliationData.EndTime = endTime.Format(time.RFC3339)
+	}
 
 	data, err := json.MarshalIndent(reconciliationData, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal ASBB reconciliation data: %w", err)
+		return "", nil, fmt.Errorf("failed to marshal ASBB reconciliation data: %w", err)
 	}
-
-	if err := os.WriteFile(filename, data, 0600); err != nil {
-		return fmt.Errorf("failed to write ASBB reconciliation data: %w", err)
-	}
-
-	logger.Info("ASBB reconciliation data exported",
-		zap.String("format", "asbb-reconciliation"),
-		zap.String("file", filename),
-		zap.Float64("total_cost", perfData.CostAnalysis.TotalCostUSD))
-
-	return nil
+	return fmt.Sprintf("job-%s-asbb-reconciliation.json", perfData.JobMetadata.JobID), data, nil
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/infra"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var logger *zap.Logger
+
+func main() {
+	var err error
+	logger, err = zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-generate",
+		Short: "Generate files derived from the configuration",
+	}
+	rootCmd.AddCommand(infraCmd())
+
+	if err := rootCmd.Execute(); err != nil {
+		logger.Error("Command failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func infraCmd() *cobra.Command {
+	var configFile, format, outputFile string
+	cmd := &cobra.Command{
+		Use:   "infra",
+		Short: "Generate Terraform or CloudFormation for the AWS prerequisites",
+		Long: `Generate the AWS resources the configuration refers to: a single-AZ subnet
+that cluster placement groups can use, a security group for Slurm, MPI and EFA
+traffic, the node groups' IAM roles, instance profiles and launch templates, and
+a least-privilege policy to attach to the head node's role.
+
+The VPC, availability zone, subnet CIDR, head node CIDR and AMIs are inputs
+of the generated template. Node groups in another region or using an existing
+launch_template_id are left out.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(configFile)
+			if err != nil {
+				return fmt.Errorf("configuration validation failed: %w", err)
+			}
+			spec, err := infra.FromConfig(cfg)
+			if err != nil {
+				return err
+			}
+			for _, skipped := range spec.Skipped {
+				logger.Warn("Node group left out", zap.String("reason", skipped))
+			}
+
+			var out []byte
+			switch format {
+			case "terraform":
+				text, err := infra.Terraform(spec, configFile)
+				if err != nil {
+					return fmt.Errorf("failed to render Terraform: %w", err)
+				}
+				out = []byte(text)
+			case "cloudformation":
+				if out, err = infra.CloudFormation(spec, configFile); err != nil {
+					return fmt.Errorf("failed to render CloudFormation: %w", err)
+				}
+				out = append(out, '\n')
+			default:
+				return fmt.Errorf("unsupported format %q (use terraform or cloudformation)", format)
+			}
+
+			if outputFile == "" {
+				_, err = os.Stdout.Write(out)
+				return err
+			}
+			if err := os.WriteFile(outputFile, out, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", outputFile, err)
+			}
+			logger.Info("✅ Infrastructure generated",
+				zap.String("file", outputFile),
+				zap.String("format", format),
+				zap.Int("launch_templates", len(spec.LaunchTemplates)),
+				zap.Strings("instance_profiles", spec.InstanceProfiles))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+	cmd.Flags().StringVarP(&format, "format", "f", "terraform", "Output format (terraform, cloudformation)")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "File to write instead of stdout")
+	return cmd
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/bootstrap"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	configFile    string
+	slurmConfFile string
+	region        string
+	profile       string
+	skipAWS       bool
+	force         bool
+	logger        *zap.Logger
+)
+
+func main() {
+	// Questions go to stdout; the log only carries warnings
+	loggerConfig := zap.NewProductionConfig()
+	loggerConfig.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
+	var err error
+	logger, err = loggerConfig.Build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	defaultRegion := os.Getenv("AWS_REGION")
+	if defaultRegion == "" {
+		defaultRegion = "us-east-1"
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-init",
+		Short: "Create a first configuration interactively",
+		Long: `Bootstrap a working aws-slurm-burst deployment. The wizard discovers the
+region's default VPC, subnets and security group and lists the EFA-capable
+instance types, asks a few questions, and writes a configuration that has been
+validated plus the slurm.conf lines that match it.
+
+Discovery uses the AWS credentials of the current shell (or --profile); with
+--skip-aws every resource is typed in.`,
+		SilenceUsage: true,
+		RunE:         runInit,
+	}
+
+	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file to write")
+	rootCmd.Flags().StringVar(&slurmConfFile, "slurm-conf", "", "slurm.conf snippet to write (default: aws-burst-slurm.conf next to the configuration)")
+	rootCmd.Flags().StringVar(&region, "region", defaultRegion, "AWS region offered as the default")
+	rootCmd.Flags().StringVar(&profile, "profile", "", "AWS profile used for discovery")
+	rootCmd.Flags().BoolVar(&skipAWS, "skip-aws", false, "Do not discover resources through AWS APIs")
+	rootCmd.Flags().BoolVar(&force, "force", false, "Overwrite existing files")
+
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	configPath, err := filepath.Abs(configFile)
+	if err != nil {
+		return fmt.Errorf("invalid configuration path: %w", err)
+	}
+	if slurmConfFile == "" {
+		slurmConfFile = filepath.Join(filepath.Dir(configPath), "aws-burst-slurm.conf")
+	}
+
+	prompter := bootstrap.NewPrompter(os.Stdin, os.Stdout)
+	answers := bootstrap.DefaultAnswers(region, configPath)
+
+	var discovery bootstrap.Discovery
+	if !skipAWS {
+		ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+		defer cancel()
+		discovery, err = connect(ctx)
+		if err != nil {
+			prompter.Printf("AWS discovery unavailable, enter resources by hand: %v\n", err)
+			discovery = nil
+		}
+	}
+
+	if err := bootstrap.Run(cmd.Context(), discovery, prompter, answers); err != nil {
+		return err
+	}
+	if err := bootstrap.Write(answers, configPath, slurmConfFile, force); err != nil {
+		return err
+	}
+
+	prompter.Printf("\nWrote %s and %s.\n", configPath, slurmConfFile)
+	prompter.Printf("Next: merge the snippet into slurm.conf, restart slurmctld and run\n")
+	prompter.Printf("  aws-slurm-burst-doctor --config=%s\n", configPath)
+	return nil
+}
+
+// connect authenticates with the shell's credentials rather than resume's
+// instance profile, since init usually runs before the head node is set up
+func connect(ctx context.Context) (*aws.Diagnostics, error) {
+	awsConfig := &config.AWSConfig{Region: region, AuthenticationMethod: "default"}
+	if profile != "" {
+		awsConfig.AuthenticationMethod = "profile"
+		awsConfig.Profile = profile
+	}
+	return aws.NewDiagnostics(ctx, logger, awsConfig)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/mpiprofile"
+	"go.uber.org/zap"
+)
+
+// defaultConfigFile is used unless AWS_SLURM_BURST_CONFIG points elsewhere
+const defaultConfigFile = "/etc/slurm/aws-burst.yaml"
+
+var logger *zap.Logger
+
+// aws-slurm-burst-mpirun is a drop-in for mpirun in job scripts. Arguments are
+// passed to the configured launcher unchanged. With mpi.profiling enabled inside
+// a Slurm job, ranks run with mpiP preloaded and the report is summarized for
+// aws-slurm-burst-export-performance. Profiling problems are logged and never
+// change the launch or its exit code.
+func main() {
+	// Only warnings go to the job's stderr
+	loggerConfig := zap.NewProductionConfig()
+	loggerConfig.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
+	var err error
+	logger, err = loggerConfig.Build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+
+	code := run(os.Args[1:])
+	_ = logger.Sync()
+	os.Exit(code)
+}
+
+func run(args []string) int {
+	configFile := os.Getenv("AWS_SLURM_BURST_CONFIG")
+	if configFile == "" {
+		configFile = defaultConfigFile
+	}
+
+	profiling := config.MPIProfilingConfig{Launcher: "mpirun"}
+	if cfg, err := config.Load(configFile); err != nil {
+		logger.Warn("Failed to load config, running without profiling", zap.Error(err))
+	} else {
+		profiling = cfg.MPI.Profiling
+	}
+
+	launcher, err := resolveLauncher(profiling.Launcher)
+	if err != nil {
+		logger.Error("Cannot find MPI launcher", zap.Error(err))
+		return 127
+	}
+
+	jobID := os.Getenv("SLURM_JOB_ID")
+	if !profiling.Enabled || jobID == "" {
+		return launch(launcher, args, os.Environ())
+	}
+	if _, err := os.Stat(profiling.MPIPLibrary); err != nil {
+		logger.Warn("mpiP library not available, running without profiling", zap.Error(err))
+		return launch(launcher, args, os.Environ())
+	}
+
+	runName := fmt.Sprintf("run-%s-%d", time.Now().UTC().Format("20060102T150405"), os.Getpid())
+	reportDir := filepath.Join(profiling.OutputDir, mpiprofile.JobDir(jobID), runName)
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		logger.Warn("Failed to create profile directory, running without profiling", zap.Error(err))
+		return launch(launcher, args, os.Environ())
+	}
+
+	version, _ := exec.Command(launcher, "--version").CombinedOutput()
+	code := launch(launcher,
+		mpiprofile.LauncherArgs(mpiprofile.IsOpenMPI(string(version)), args),
+		mpiprofile.Environment(os.Environ(), profiling.MPIPLibrary, reportDir))
+
+	if err := summarize(profiling.OutputDir, jobID, runName, reportDir); err != nil {
+		logger.Warn("Failed to summarize MPI profile", zap.String("report_dir", reportDir), zap.Error(err))
+	}
+	return code
+}
+
+// resolveLauncher finds the real launcher, refusing to run this wrapper again
+// when it is installed under the launcher's name
+func resolveLauncher(name string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+
+	self, err := os.Executable()
+	if err == nil {
+		resolved, _ := filepath.EvalSymlinks(path)
+		if resolvedSelf, _ := filepath.EvalSymlinks(self); resolved == resolvedSelf {
+			return "", fmt.Errorf("launcher %q is this wrapper; set mpi.profiling.launcher to the real launcher", name)
+		}
+	}
+	return path, nil
+}
+
+// launch runs the launcher in the foreground, forwarding termination signals, and
+// returns its exit code
+func launch(launcher string, args, env []string) int {
+	cmd := exec.Command(launcher, args...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		logger.Error("Failed to start MPI launcher", zap.String("launcher", launcher), zap.Error(err))
+		return 127
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	default:
+		logger.Error("MPI launcher failed", zap.Error(err))
+		return 1
+	}
+}
+
+// summarize parses the launch's mpiP report and stores its summary beside the
+// job's other runs
+func summarize(outputDir, jobID, runName, reportDir string) error {
+	report, err := mpiprofile.LatestReport(reportDir)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(report)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	summary, err := mpiprofile.ParseMPIP(file)
+	if err != nil {
+		return err
+	}
+	summary.JobID = jobID
+
+	_, err = mpiprofile.WriteSummary(outputDir, runName, summary)
+	return err
+}
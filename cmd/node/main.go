@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ssm"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	configFile string
+	logger     *zap.Logger
+)
+
+func main() {
+	var err error
+	logger, err = zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-node",
+		Short: "Operate on burst nodes through AWS Systems Manager",
+		Long: `Run operations on burst nodes through SSM Run Command, without SSH keys or
+inbound ports on the nodes. Nodes are given as a Slurm hostlist; each node's
+command goes to the region its instance runs in. The nodes need the SSM agent
+and the AmazonSSMManagedInstanceCore permissions.`,
+	}
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+
+	rootCmd.AddCommand(logsCmd())
+	rootCmd.AddCommand(cleanupCmd())
+
+	if err := rootCmd.Execute(); err != nil {
+		logger.Error("Command execution failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func logsCmd() *cobra.Command {
+	var lines int
+
+	cmd := &cobra.Command{
+		Use:   "logs <nodes>",
+		Short: "Print the slurmd, cloud-init and aws-slurm-burst logs of nodes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if lines < 1 {
+				return fmt.Errorf("--lines must be positive")
+			}
+			return runOnNodes(args[0], ssm.Command{
+				Script:     ssm.LogsScript(lines),
+				Comment:    "aws-slurm-burst log collection",
+				Timeout:    time.Minute,
+				FullOutput: true,
+			})
+		},
+	}
+
+	cmd.Flags().IntVarP(&lines, "lines", "n", 100, "Lines to print from the end of each log")
+	return cmd
+}
+
+func cleanupCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "cleanup <nodes>",
+		Short: "Kill user processes and restart slurmd on nodes",
+		Long: `Kill every process of a user account (UID 1000 and above) on the nodes and
+restart slurmd, for jobs Slurm could not clean up. Drain the nodes first, since
+any job running on them is killed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runOnNodes(args[0], ssm.Command{
+				Script:  ssm.CleanupScript(),
+				Comment: "aws-slurm-burst emergency cleanup",
+				Timeout: 2 * time.Minute,
+			})
+		},
+	}
+}
+
+// runOnNodes runs the command on a hostlist of nodes and prints each node's
+// output; it fails if the command did not succeed on every node
+func runOnNodes(hostlist string, command ssm.Command) error {
+	nodeNames := slurm.ExpandHostlist(hostlist)
+	if len(nodeNames) == 0 {
+		return fmt.Errorf("no nodes in %q", hostlist)
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), command.Timeout+time.Minute)
+	defer cancel()
+
+	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+	instances, err := awsClient.ListInstances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	results, runErr := ssm.NewNodes(logger, cfg, instances).Run(ctx, nodeNames, command)
+	var failed []string
+	for _, node := range nodeNames {
+		result, ok := results[node]
+		if !ok {
+			failed = append(failed, node)
+			continue
+		}
+		fmt.Printf("==> %s (%s) <==\n%s", node, result.Status, result.Output)
+		if result.Output != "" && result.Output[len(result.Output)-1] != '\n' {
+			fmt.Println()
+		}
+		if result.Status != "Success" {
+			failed = append(failed, node)
+		}
+	}
+	if runErr != nil {
+		return runErr
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("command did not succeed on %s", slurm.CompressHostlist(failed))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/epilog"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	configFile string
+	logger     *zap.Logger
+)
+
+// prologTimeout bounds the scontrol calls so a busy controller cannot hold up job start
+const prologTimeout = 10 * time.Second
+
+func main() {
+	var err error
+	logger, err = zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-prolog",
+		Short: "Stamp AWS placement metadata into burst jobs at start",
+		Long: `Run from Slurm's PrologSlurmctld. For jobs starting on burst nodes, collects
+the placement that aws-slurm-burst-resume recorded on each node (instance
+types, AZs, fleet ID, execution mode and trace ID) and writes it as compact
+aws_meta JSON into the job's metadata field (slurm.job_metadata.field,
+AdminComment by default), so sacct-based tooling can link
+the job to its AWS resources even if the export fails later.
+
+Existing comment text is kept and existing aws_meta fields take precedence.
+The prolog only talks to slurmctld and always exits 0, so it cannot delay
+or fail a job.`,
+		RunE: runProlog,
+	}
+
+	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+
+	if err := rootCmd.Execute(); err != nil {
+		// Errors are logged but never returned to slurmctld
+		logger.Error("Prolog failed", zap.Error(err))
+	}
+}
+
+func runProlog(cmd *cobra.Command, args []string) error {
+	jobID := os.Getenv("SLURM_JOB_ID")
+	partition := os.Getenv("SLURM_JOB_PARTITION")
+	nodeList := os.Getenv("SLURM_JOB_NODELIST")
+	if jobID == "" {
+		return fmt.Errorf("SLURM_JOB_ID is not set; run from PrologSlurmctld")
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if !epilog.IsBurstJob(cfg, partition, nodeList) {
+		logger.Debug("Job is not starting on burst nodes",
+			zap.String("job_id", jobID),
+			zap.String("partition", partition))
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), prologTimeout)
+	defer cancel()
+
+	slurmClient := slurm.NewClient(logger, &cfg.Slurm)
+	nodes, err := slurmClient.ParseNodeList(nodeList)
+	if err != nil {
+		return fmt.Errorf("failed to parse node list '%s': %w", nodeList, err)
+	}
+
+	placement, err := slurmClient.NodePlacement(ctx, nodes)
+	if err != nil {
+		return fmt.Errorf("failed to read node placement: %w", err)
+	}
+	if placement == nil {
+		logger.Warn("No placement recorded on burst nodes",
+			zap.String("job_id", jobID),
+			zap.Strings("nodes", nodes))
+		return nil
+	}
+
+	// Metadata resume already recorded on the job (spot scores, fallbacks) wins
+	meta, err := slurmClient.JobMetadata(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		meta = &types.AWSJobMetadata{}
+	}
+	meta.Merge(placement)
+
+	if err := slurmClient.SetJobMetadata(ctx, jobID, meta); err != nil {
+		return err
+	}
+
+	logger.Info("Stamped AWS metadata into job",
+		zap.String("job_id", jobID),
+		zap.String("field", cfg.Slurm.JobMetadata.Field),
+		zap.Strings("instance_types", meta.Instances),
+		zap.String("fleet_id", meta.FleetID),
+		zap.String("trace_id", meta.TraceID))
+	return nil
+}
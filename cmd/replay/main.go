@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/replay"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	configFile    string
+	planFile      string
+	format        string
+	checkCapacity bool
+	logger        *zap.Logger
+)
+
+func main() {
+	var err error
+	logger, err = zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-replay <result.json>",
+		Short: "Reconstruct the timeline of a past resume",
+		Long: `Reconstruct what a past resume did from the execution result it wrote to
+the data exchange directory: the plan, spot placement scores, capacity
+fallbacks, launches, failures, errors and the Slurm updates that followed.
+
+A result in <data_exchange_dir>/results is paired with the plan recorded for
+the same job in <data_exchange_dir>/records; --plan names another plan file.
+With --check-capacity the resume's dry-run checks run again against EC2 as it
+is now, to tell a capacity problem that has passed from one that would recur.`,
+		Args: cobra.ExactArgs(1),
+		RunE: replayResult,
+	}
+
+	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path (for --check-capacity)")
+	rootCmd.Flags().StringVar(&planFile, "plan", "", "Execution plan or plan record the resume ran with")
+	rootCmd.Flags().StringVar(&format, "format", "text", "Output format (text, json)")
+	rootCmd.Flags().BoolVar(&checkCapacity, "check-capacity", false, "Re-run the dry-run checks against current EC2 capacity")
+
+	if err := rootCmd.Execute(); err != nil {
+		logger.Error("Command execution failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func replayResult(cmd *cobra.Command, args []string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unsupported format %q (use text or json)", format)
+	}
+
+	timeline, err := replay.Load(args[0], planFile)
+	if err != nil {
+		return err
+	}
+
+	var checks []replay.Check
+	if checkCapacity {
+		if checks, err = recheck(timeline); err != nil {
+			return err
+		}
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			*replay.Timeline
+			Capacity []replay.Check `json:"capacity,omitempty"`
+		}{timeline, checks})
+	}
+	return replay.Render(os.Stdout, timeline, checks)
+}
+
+// recheck runs the dry-run checks for the timeline's node groups against EC2
+func recheck(timeline *replay.Timeline) ([]replay.Check, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	diagnostics, err := aws.NewDiagnostics(ctx, logger, &cfg.AWS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS diagnostics: %w", err)
+	}
+	return replay.Recheck(ctx, diagnostics, cfg, timeline)
+}
@@ -2,24 +2,48 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/scttfrdmn/aws-slurm-burst/internal/asba"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/batch"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/capacity"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/costs"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ecosystem"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/elastic"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/healthcheck"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/hooks"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/hostsfile"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/policysync"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/prediction"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/resume"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/scheduler"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ssm"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
 	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
 	configFile    string
 	executionPlan string
 	dryRun        bool
+	simulateRun   bool
+	ignoreWindows bool
 	logger        *zap.Logger
 )
 
@@ -51,6 +75,8 @@ and cost optimization decisions are made by ASBA.`,
 	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
 	rootCmd.Flags().StringVar(&executionPlan, "execution-plan", "", "Path to ASBA execution plan JSON file (optional)")
 	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done without executing")
+	rootCmd.Flags().BoolVar(&simulateRun, "simulate", false, "Run against in-memory EC2 and Slurm and print the result")
+	rootCmd.Flags().BoolVar(&ignoreWindows, "ignore-windows", false, "Burst outside burst windows and during blackouts, for urgent jobs")
 
 	if err := rootCmd.Execute(); err != nil {
 		logger.Error("Command execution failed", zap.Error(err))
@@ -58,7 +84,7 @@ and cost optimization decisions are made by ASBA.`,
 	}
 }
 
-func resumeNodes(cmd *cobra.Command, args []string) error {
+func resumeNodes(cmd *cobra.Command, args []string) (err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
@@ -67,9 +93,64 @@ func resumeNodes(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	// Policy rules selecting by association match what the state manager last synced
+	if err := policysync.Apply(cfg); err != nil {
+		logger.Warn("Policy rules match only the users and accounts they name", zap.Error(err))
+	}
 
-	// Determine execution mode: ASBA-driven or standalone
+	var sim *simulation
+	if simulateRun {
+		sim = newSimulation(cfg)
+	}
+
+	// Initialize Slurm client
+	slurmClient := slurm.NewClient(logger, &cfg.Slurm)
+	if sim != nil {
+		slurmClient = sim.slurmClient(cfg)
+	}
+	slurmClient.InjectFailures(cfg.FailureInjection)
+
+	// Parse node list
+	nodeList := args[0]
+	nodes, err := slurmClient.ParseNodeList(nodeList)
+	if err != nil {
+		return fmt.Errorf("failed to parse node list '%s': %w", nodeList, err)
+	}
+
+	// Every launch attempt from here on leaves an execution result, failed ones included
+	started := time.Now()
 	var plan *types.ExecutionPlan
+	var result *types.ExecutionResult
+	defer func() {
+		if !dryRun {
+			recordResult(cfg, plan, nodes, started, result, err)
+		}
+	}()
+
+	// Launches that may not burst are dropped and their nodes powered back down
+	gate := &resume.Gate{Logger: logger, Slurm: slurmClient, DryRun: dryRun, IgnoreWindows: ignoreWindows}
+
+	// Each partition and node group is launched as its own fleet
+	groups, err := slurmClient.GroupNodes(nodes)
+	if err != nil {
+		return fmt.Errorf("failed to group node list '%s': %w", nodeList, err)
+	}
+
+	// Partitions under an emergency stop do not burst until it is cleared
+	if groups, err = gate.EnforceEmergencyStop(cfg.EmergencyStop.StateFile, groups); err != nil {
+		return err
+	}
+
+	// Determine execution mode: ASBA-driven or standalone
+	var exchangedJobID string
+	if executionPlan == "" {
+		// ASBA may have left a plan for the pending job in the data exchange directory
+		executionPlan, exchangedJobID = exchangePlanFile(ctx, cfg, slurmClient, nodes)
+	}
+	if executionPlan == "" {
+		// Otherwise ASBA may be asked for one directly
+		executionPlan, exchangedJobID = requestASBAPlan(ctx, cfg, slurmClient, nodes)
+	}
 
 	if executionPlan != "" {
 		// ASBA Mode: Load execution plan from ASBA
@@ -77,48 +158,113 @@ func resumeNodes(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("failed to load execution plan: %w", err)
 		}
+		if plan.ExecutionMetadata.JobID == "" {
+			plan.ExecutionMetadata.JobID = exchangedJobID
+		}
 
+		if plan, err = negotiatePlan(cfg, plan); err != nil {
+			return err
+		}
+	}
+
+	var launches []resume.Launch
+	if plan != nil {
 		// Check if ASBA recommends bursting
 		if !plan.ShouldBurst {
 			logger.Info("ASBA recommends not bursting - job should run on-premises")
 			return nil
 		}
 
+		// The job's plan applies to every node group it spans; its script's
+		// staging directives come from Slurm, not the plan
+		var staging *types.StagingSpec
+		if cfg.Staging.Enabled {
+			if job, err := slurmClient.GetJobForNodes(ctx, nodes); err == nil {
+				staging = job.Staging
+			}
+		}
+		for _, group := range groups {
+			launches = append(launches, resume.Launch{NodeGroupNodes: group, Plan: plan, Staging: staging})
+		}
 		logger.Info("Using ASBA execution plan", zap.String("plan_file", executionPlan))
 	} else {
-		// Standalone Mode: Generate default execution plan from configuration
-		plan, err = generateDefaultExecutionPlan(cfg, args[0])
-		if err != nil {
-			return fmt.Errorf("failed to generate default execution plan: %w", err)
+		// Standalone Mode: Generate a default execution plan from each node group's configuration
+		for _, group := range groups {
+			groupPlan, err := generateDefaultExecutionPlan(cfg, group.Partition, group.NodeGroup)
+			if err != nil {
+				return fmt.Errorf("failed to generate default execution plan: %w", err)
+			}
+
+			// Size instances for the pending job rather than the template overrides alone
+			job := applyJobRequirements(ctx, slurmClient, &cfg.Slurm, groupPlan, group.Nodes)
+			resume.ApplyJobPacking(logger, cfg.FindNodeGroup(group.Partition, group.NodeGroup), groupPlan, job)
+
+			launch := resume.Launch{NodeGroupNodes: group, Plan: groupPlan, Standalone: true, Job: job}
+			if job != nil {
+				launch.Staging = job.Staging
+			}
+			launch.Purchasing = applyPurchasingPolicy(&cfg.PurchasingPolicy, groupPlan, job)
+			resume.ApplyJobOverrides(logger, &cfg.JobOverrides, &launch)
+			launches = append(launches, launch)
+		}
+
+		// Site decision modules may veto or modify each plan
+		if launches, err = gate.ApplyPlanModules(ctx, cfg.PlanModules, launches); err != nil {
+			return err
 		}
+		plan = launches[0].Plan
 
-		logger.Info("Using standalone mode with static configuration")
+		logger.Info("Using standalone mode with static configuration", zap.Int("node_groups", len(launches)))
 	}
 
-	// Validate execution plan
-	if err := plan.ValidateExecutionPlan(); err != nil {
-		return fmt.Errorf("invalid execution plan: %w", err)
+	// Validate execution plans
+	for _, launch := range launches {
+		if err := launch.Plan.ValidateExecutionPlan(); err != nil {
+			return fmt.Errorf("invalid execution plan for partition '%s' nodegroup '%s': %w", launch.Partition, launch.NodeGroup, err)
+		}
+	}
+
+	// Heterogeneous plans map specific nodes to specific instance types; a
+	// job's roles may span its node groups
+	if err := resume.AssignNodeRoles(nodes, launches); err != nil {
+		return err
+	}
+
+	// Plans for a Capacity Block launch only while the block is open
+	if err := scheduleCapacityBlock(ctx, slurmClient, plan, nodes); err != nil {
+		return err
+	}
+
+	// Launches their burst policy refuses, outside their burst windows or over
+	// their spend ceiling do not burst
+	if launches, err = gate.EnforceBurstPolicy(ctx, cfg, nodes, launches); err != nil {
+		return err
+	}
+
+	// Loosely-coupled array jobs may run on AWS Batch instead
+	if launches = handOffToBatch(ctx, cfg, slurmClient, launches); len(launches) == 0 {
+		return nil
 	}
 
 	// Initialize AWS client
-	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
-	if err != nil {
+	var awsClient *aws.Client
+	if sim != nil {
+		awsClient = sim.awsClient(cfg)
+	} else if awsClient, err = aws.NewClient(logger, &cfg.AWS, cfg); err != nil {
 		return fmt.Errorf("failed to create AWS client: %w", err)
 	}
 
-	// Initialize Slurm client
-	slurmClient := slurm.NewClient(logger, &cfg.Slurm)
-
-	// Parse node list
-	nodeList := args[0]
-	nodes, err := slurmClient.ParseNodeList(nodeList)
-	if err != nil {
-		return fmt.Errorf("failed to parse node list '%s': %w", nodeList, err)
+	// Nodes that could never register with slurmctld are not launched
+	if launches, err = checkNetworkPath(ctx, cfg, awsClient, slurmClient, launches); err != nil {
+		return err
 	}
+	// The first launch may have been dropped; the job is what remains
+	plan = launches[0].Plan
 
 	logger.Info("Executing ASBA plan",
 		zap.String("nodes", nodeList),
 		zap.Int("node_count", len(nodes)),
+		zap.Int("node_groups", len(launches)),
 		zap.String("plan_file", executionPlan),
 		zap.Bool("mpi_job", plan.MPIConfig.IsMPIJob),
 		zap.Strings("instance_types", plan.InstanceSpec.InstanceTypes),
@@ -126,11 +272,21 @@ func resumeNodes(cmd *cobra.Command, args []string) error {
 		zap.Bool("dry_run", dryRun))
 
 	if dryRun {
-		return executeDryRun(plan, nodes)
+		for _, launch := range launches {
+			if err := executeDryRun(ctx, cfg, awsClient, launch); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
 	// Execute the plan
-	result, err := executeProvisioningPlan(ctx, awsClient, slurmClient, plan, nodes)
+	result, err = executeProvisioningPlan(ctx, cfg, awsClient, slurmClient, launches)
+	if sim != nil {
+		if reportErr := sim.report(result, err); reportErr != nil {
+			return reportErr
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to execute provisioning plan: %w", err)
 	}
@@ -140,12 +296,334 @@ func resumeNodes(cmd *cobra.Command, args []string) error {
 		zap.Bool("success", result.Success),
 		zap.Int("launched", len(result.LaunchedInstances)),
 		zap.Int("failed", len(result.FailedInstances)),
+		zap.Int("waiting_for_capacity", len(result.WaitingForCapacity)),
 		zap.String("fleet_id", result.FleetID),
+		zap.String("trace_id", result.TraceID),
+		zap.Int("fallbacks", len(result.Fallbacks)),
+		zap.Int("warm_pool_instances", result.WarmPoolInstances),
+		zap.Int("restarted_instances", result.RestartedInstances),
+		zap.Int("lingering_instances", result.LingeringInstances),
 		zap.Float64("estimated_cost", result.TotalCostEstimate))
 
 	return nil
 }
 
+// batchFallbackReason is the Slurm reason on nodes powered down because
+// their array job's tasks were handed to AWS Batch
+const batchFallbackReason = "aws_batch_fallback"
+
+// handOffToBatch submits the array jobs of standalone launches that are
+// eligible for batch_fallback to AWS Batch, and powers their nodes back down.
+// Launches that are not eligible, or whose hand-off fails, launch as usual.
+func handOffToBatch(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client, launches []resume.Launch) []resume.Launch {
+	if !cfg.BatchFallback.Enabled || dryRun || simulateRun {
+		return launches
+	}
+
+	mpiScheduler, err := scheduler.NewMPISchedulerFromConfig(logger, &cfg.MPI)
+	if err != nil {
+		logger.Warn("Cannot classify jobs for AWS Batch, launching nodes", zap.Error(err))
+		return launches
+	}
+	var batchClient *batch.Client
+	var remaining []resume.Launch
+	for _, launch := range launches {
+		if !launch.Standalone {
+			remaining = append(remaining, launch)
+			continue
+		}
+		if launch.Job != nil {
+			_ = mpiScheduler.AnalyzeJob(ctx, launch.Job)
+		}
+		if reason := batch.Eligible(&cfg.BatchFallback, launch.Job); reason != "" {
+			logger.Debug("Not handing job to AWS Batch", zap.Strings("nodes", launch.Nodes), zap.String("reason", reason))
+			remaining = append(remaining, launch)
+			continue
+		}
+
+		if batchClient == nil {
+			if batchClient, err = batch.NewClient(ctx, logger, cfg); err != nil {
+				logger.Warn("Failed to create AWS Batch client, launching nodes", zap.Error(err))
+				return launches
+			}
+		}
+		record, err := batchClient.Submit(ctx, slurmClient, launch.Job, launch.Nodes)
+		if err != nil {
+			logger.Warn("Failed to hand array job to AWS Batch, launching nodes",
+				zap.String("job_id", launch.Job.JobID),
+				zap.Error(err))
+			remaining = append(remaining, launch)
+			continue
+		}
+
+		logger.Info("Array job runs on AWS Batch instead of burst nodes",
+			zap.String("array_job_id", record.ArrayJobID),
+			zap.String("batch_job_id", record.BatchJobID),
+			zap.Int("tasks", len(record.Tasks)),
+			zap.Strings("nodes", launch.Nodes))
+		if err := slurmClient.SetNodesState(launch.Nodes, "POWER_DOWN", batchFallbackReason); err != nil {
+			logger.Error("Failed to power down nodes of a job handed to AWS Batch", zap.Error(err))
+		}
+	}
+	return remaining
+}
+
+// networkPathDownReason is the Slurm reason on nodes powered down because their
+// subnets cannot reach slurmctld
+const networkPathDownReason = "network_path_down"
+
+// checkNetworkPath drops the launches whose subnets have no working route to
+// slurmctld, such as while the site VPN is down, and powers their nodes back
+// down so Slurm retries them later. A check that cannot run never blocks a
+// launch.
+func checkNetworkPath(ctx context.Context, cfg *config.Config, awsClient *aws.Client, slurmClient *slurm.Client, launches []resume.Launch) ([]resume.Launch, error) {
+	if !cfg.NetworkCheck.Enabled {
+		return launches, nil
+	}
+	controller := cfg.NetworkCheck.Controller()
+
+	var allowed []resume.Launch
+	var refusal error
+	for _, launch := range launches {
+		problems, err := awsClient.CheckNetworkPath(ctx, launch.Partition, launch.NodeGroup, controller)
+		if err != nil {
+			logger.Warn("Could not check the network path to slurmctld, launching anyway",
+				zap.String("partition", launch.Partition),
+				zap.String("node_group", launch.NodeGroup),
+				zap.Error(err))
+		}
+		if len(problems) == 0 {
+			allowed = append(allowed, launch)
+			continue
+		}
+
+		descriptions := make([]string, len(problems))
+		for i, problem := range problems {
+			descriptions[i] = problem.String()
+		}
+		refusal = fmt.Errorf("node group %s/%s cannot reach slurmctld at %s (%s); restore the VPN or Direct Connect link before bursting",
+			launch.Partition, launch.NodeGroup, controller, strings.Join(descriptions, "; "))
+		logger.Error("Refusing to burst",
+			zap.String("reason", networkPathDownReason),
+			zap.Strings("problems", descriptions),
+			zap.Strings("nodes", launch.Nodes),
+			zap.Bool("dry_run", dryRun))
+		if dryRun {
+			continue
+		}
+		if err := slurmClient.SetNodesState(launch.Nodes, "POWER_DOWN", networkPathDownReason); err != nil {
+			logger.Error("Failed to power down nodes without a network path", zap.Error(err))
+		}
+	}
+
+	if len(allowed) == 0 {
+		return nil, refusal
+	}
+	return allowed, nil
+}
+
+// capacityBlockPendingReason is the Slurm reason on nodes powered down until
+// their plan's Capacity Block opens
+const capacityBlockPendingReason = "capacity_block_pending"
+
+// scheduleCapacityBlock checks that the plan's Capacity Block is open. Before
+// it opens, the job is deferred to the block's start and the nodes are powered
+// back down, so Slurm resumes them once the block can be launched into.
+func scheduleCapacityBlock(ctx context.Context, slurmClient *slurm.Client, plan *types.ExecutionPlan, nodes []string) error {
+	block := plan.CapacityBlock
+	if block == nil {
+		return nil
+	}
+	now := time.Now()
+	if !now.Before(block.End) {
+		return fmt.Errorf("capacity block %s ended at %s", block.ReservationID, block.End.Format(time.RFC3339))
+	}
+
+	if block.Active(now) {
+		// Instances in the block are terminated when it ends, job or not
+		hours := plan.CostConstraints.MaxDurationHours
+		if hours > 0 && now.Add(time.Duration(hours*float64(time.Hour))).After(block.End) {
+			logger.Warn("Job may outlast its capacity block",
+				zap.String("reservation_id", block.ReservationID),
+				zap.Time("block_end", block.End),
+				zap.Float64("max_duration_hours", hours))
+		}
+		logger.Info("Launching into capacity block",
+			zap.String("reservation_id", block.ReservationID),
+			zap.String("instance_type", block.InstanceType),
+			zap.Time("block_end", block.End))
+		return nil
+	}
+
+	jobID := plan.ExecutionMetadata.JobID
+	logger.Info("Capacity block not open yet, deferring job",
+		zap.String("reservation_id", block.ReservationID),
+		zap.String("job_id", jobID),
+		zap.Time("block_start", block.Start),
+		zap.Bool("dry_run", dryRun))
+	if !dryRun {
+		if jobID != "" {
+			if err := slurmClient.DeferJob(ctx, jobID, block.Start); err != nil {
+				logger.Error("Failed to defer job to its capacity block", zap.Error(err))
+			}
+		}
+		if err := slurmClient.SetNodesState(nodes, "POWER_DOWN", capacityBlockPendingReason); err != nil {
+			logger.Error("Failed to power down nodes until their capacity block opens", zap.Error(err))
+		}
+	}
+	return fmt.Errorf("capacity block %s opens at %s", block.ReservationID, block.Start.Format(time.RFC3339))
+}
+
+// exchangePlanFile returns the plan file ASBA wrote to the data exchange directory
+// for the job pending on nodes and that job's ID, or "" when there is none
+func exchangePlanFile(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client, nodes []string) (string, string) {
+	dir := cfg.Ecosystem.DataExchangeDir
+	if dir == "" || !exchange.HasPlans(dir) {
+		return "", ""
+	}
+
+	job, err := slurmClient.GetJobForNodes(ctx, nodes)
+	if err != nil {
+		logger.Debug("No pending job found for nodes, not checking exchanged plans", zap.Error(err))
+		return "", ""
+	}
+	path := exchange.Path(dir, exchange.Plans, job.JobID)
+	if _, err := os.Stat(path); err != nil {
+		return "", ""
+	}
+	logger.Info("Found ASBA execution plan in data exchange directory", zap.String("job_id", job.JobID))
+	return path, job.JobID
+}
+
+// requestASBAPlan asks ASBA for a plan for the job pending on nodes and stores it
+// in the data exchange directory as if ASBA had written it there. Any failure,
+// including an open circuit breaker, leaves resume in standalone mode.
+func requestASBAPlan(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client, nodes []string) (string, string) {
+	if !asbaPlansEnabled(cfg) {
+		return "", ""
+	}
+
+	job, err := slurmClient.GetJobForNodes(ctx, nodes)
+	if err != nil {
+		logger.Debug("No pending job found for nodes, not asking ASBA", zap.Error(err))
+		return "", ""
+	}
+	plan, err := asba.NewClientFromConfig(logger, cfg).GenerateExecutionPlan(ctx, job.JobID)
+	if err != nil {
+		logger.Warn("ASBA unavailable, using standalone mode", zap.String("job_id", job.JobID), zap.Error(err))
+		return "", ""
+	}
+	path, err := exchange.Write(cfg.Ecosystem.DataExchangeDir, exchange.Plans, job.JobID, plan)
+	if err != nil {
+		logger.Warn("Failed to store ASBA execution plan, using standalone mode", zap.String("job_id", job.JobID), zap.Error(err))
+		return "", ""
+	}
+	logger.Info("Received ASBA execution plan", zap.String("job_id", job.JobID))
+	return path, job.JobID
+}
+
+// asbaPlansEnabled reports whether resume should invoke ASBA itself. With
+// auto-detect it trusts only the cached detection, so resume never waits for
+// the companion tools to be probed.
+func asbaPlansEnabled(cfg *config.Config) bool {
+	if cfg.Ecosystem.DataExchangeDir == "" {
+		return false
+	}
+	switch cfg.ASBA.Enabled {
+	case "true":
+		return true
+	case "auto-detect":
+		cached, err := ecosystem.LoadCache(cfg.Ecosystem.DataExchangeDir)
+		return err == nil && cached != nil && cached.ASBA.Available &&
+			cached.ASBA.SupportsExecPlan && cached.ASBA.CompatibilityErr == ""
+	}
+	return false
+}
+
+// recordResult writes the outcome of a launch attempt to the data exchange
+// directory, under the ID of the job the plan names or, without one, the
+// trace ID. A resume that failed before launching is recorded as a failed
+// result for all its nodes.
+func recordResult(cfg *config.Config, plan *types.ExecutionPlan, nodes []string, started time.Time, result *types.ExecutionResult, resumeErr error) {
+	if cfg.Ecosystem.DataExchangeDir == "" || (result == nil && resumeErr == nil) {
+		return
+	}
+
+	now := time.Now()
+	if result == nil {
+		result = &types.ExecutionResult{
+			ExecutionStartTime: started,
+			ExecutionMode:      executionMode(),
+			TraceID:            newTraceID(),
+			Errors: []types.ExecutionError{{
+				Type:      "resume",
+				Message:   resumeErr.Error(),
+				Timestamp: now,
+			}},
+		}
+		for _, node := range nodes {
+			result.FailedInstances = append(result.FailedInstances, types.FailedInstance{NodeName: node, ErrorMessage: resumeErr.Error()})
+		}
+	}
+	if result.ExecutionEndTime.IsZero() {
+		result.ExecutionEndTime = now
+		result.ExecutionDuration = types.Duration(now.Sub(result.ExecutionStartTime))
+	}
+	if plan != nil && plan.ExecutionMetadata.JobID != "standalone" {
+		result.JobID = plan.ExecutionMetadata.JobID
+	}
+
+	ids := []string{exchange.ResultID(result)}
+	// Later jobs packed onto the instances find them by the trace ID their nodes carry
+	if result.JobID != "" && launchedPacked(cfg, result.LaunchedInstances) {
+		ids = append(ids, "trace-"+result.TraceID)
+	}
+	for _, id := range ids {
+		if _, err := exchange.WriteResult(cfg.Ecosystem.DataExchangeDir, id, result); err != nil {
+			logger.Warn("Failed to write execution result", zap.String("result_id", id), zap.Error(err))
+			return
+		}
+		logger.Debug("Recorded execution result", zap.String("result_id", id), zap.Bool("success", result.Success))
+	}
+}
+
+// launchedPacked reports whether any instance launched into a node group that
+// packs several jobs per node
+func launchedPacked(cfg *config.Config, instances []types.InstanceInfo) bool {
+	for _, instance := range instances {
+		if nodeGroup := cfg.FindNodeGroup(instance.Partition, instance.NodeGroup); nodeGroup != nil && nodeGroup.JobPacking.Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiatePlan checks that this release can execute the plan's schema. An
+// incompatible plan fails the resume, or with asba.incompatible_plans set to
+// standalone is dropped so the nodes launch from the static configuration.
+func negotiatePlan(cfg *config.Config, plan *types.ExecutionPlan) (*types.ExecutionPlan, error) {
+	compat, err := ecosystem.NegotiatePlan(plan)
+	if err != nil {
+		if cfg.ASBA.IncompatiblePlans != config.IncompatiblePlansStandalone {
+			return nil, fmt.Errorf("incompatible execution plan %s: %w", executionPlan, err)
+		}
+		logger.Error("Ignoring incompatible execution plan, falling back to standalone mode",
+			zap.String("plan_file", executionPlan),
+			zap.Error(err))
+		executionPlan = ""
+		return nil, nil
+	}
+
+	for _, warning := range compat.Warnings {
+		logger.Warn(warning, zap.String("plan_file", executionPlan))
+	}
+	logger.Debug("Negotiated execution plan schema",
+		zap.String("schema", compat.Schema),
+		zap.String("source", compat.Source),
+		zap.Bool("downgraded", compat.Downgraded))
+	return plan, nil
+}
+
 // loadExecutionPlan loads and parses the ASBA execution plan
 func loadExecutionPlan(planPath string) (*types.ExecutionPlan, error) {
 	data, err := os.ReadFile(planPath)
@@ -167,23 +645,72 @@ func loadExecutionPlan(planPath string) (*types.ExecutionPlan, error) {
 	return &plan, nil
 }
 
-// executeDryRun shows what would be executed without doing it
-func executeDryRun(plan *types.ExecutionPlan, nodes []string) error {
+// executeDryRun shows what would be executed for one node group without doing it
+func executeDryRun(ctx context.Context, cfg *config.Config, awsClient *aws.Client, launch resume.Launch) error {
+	plan, nodes := launch.Plan, launch.Nodes
+
 	logger.Info("DRY RUN: Would execute the following plan:")
+	logger.Info("  Node Group", zap.String("partition", launch.Partition), zap.String("node_group", launch.NodeGroup))
 	logger.Info("  Instance Types", zap.Strings("types", plan.InstanceSpec.InstanceTypes))
 	logger.Info("  Purchasing", zap.String("option", plan.InstanceSpec.PurchasingOption))
+	if architecture, err := plan.ResolveArchitecture(); err == nil {
+		logger.Info("  Architecture", zap.String("architecture", architecture))
+	}
+	if plan.InstanceSpec.MinCPUs > 0 || plan.InstanceSpec.MinMemoryMB > 0 {
+		logger.Info("  Job Requirements",
+			zap.Int("min_cpus", plan.InstanceSpec.MinCPUs),
+			zap.Int("min_memory_mb", plan.InstanceSpec.MinMemoryMB),
+			zap.Int("gpus", plan.InstanceSpec.GPUs))
+	}
 	logger.Info("  Max Spot Price", zap.Float64("price", plan.InstanceSpec.MaxSpotPrice))
 	logger.Info("  Subnets", zap.Strings("subnet_ids", plan.InstanceSpec.SubnetIds))
 	logger.Info("  Nodes", zap.Strings("node_list", nodes))
 
+	if len(launch.NodeRoles) > 0 {
+		logger.Info("  Node Roles:")
+		for _, role := range aws.NodeRoleLaunchesFromPlan(plan, launch.NodeRoles) {
+			logger.Info("    Role", zap.String("role", role.Role),
+				zap.Strings("nodes", role.NodeIds),
+				zap.Strings("instance_types", role.InstanceTypes))
+		}
+	}
+
+	userDataParts, err := buildUserData(cfg, plan)
+	if err != nil {
+		return fmt.Errorf("failed to build user data: %w", err)
+	}
+	for _, part := range userDataParts {
+		logger.Info("  User Data Part", zap.String("filename", part.Filename), zap.String("content_type", part.ContentType))
+	}
+
 	if plan.MPIConfig.IsMPIJob {
 		logger.Info("  MPI Configuration:")
 		logger.Info("    Process Count", zap.Int("processes", plan.MPIConfig.ProcessCount))
+		if plan.MPIConfig.WorkloadClass != "" {
+			logger.Info("    Workload Class", zap.String("class", string(plan.MPIConfig.WorkloadClass)))
+		}
 		logger.Info("    Requires EFA", zap.Bool("efa", plan.MPIConfig.RequiresEFA))
 		logger.Info("    Gang Scheduling", zap.Bool("gang", plan.MPIConfig.RequiresGangScheduling))
 		logger.Info("    Placement Group", zap.String("type", plan.NetworkConfig.PlacementGroupType))
 	}
 
+	launchReq, err := buildLaunchRequest(cfg, launch)
+	if err != nil {
+		return err
+	}
+	if strategy, err := awsClient.PlanSpotStrategy(ctx, launchReq); err != nil {
+		logger.Warn("  Spot Strategy: launch would be rejected", zap.Error(err))
+	} else if strategy != nil {
+		onDemand, spot := strategy.CapacitySplit(len(nodes))
+		logger.Info("  Spot Strategy",
+			zap.Float64("spot_ratio", strategy.SpotAllocationRatio),
+			zap.Int("on_demand_nodes", onDemand),
+			zap.Int("spot_nodes", spot),
+			zap.Float64("max_spot_price", strategy.MaxSpotPrice),
+			zap.Bool("on_demand_fallback", strategy.OnDemandFallback),
+			zap.Bool("over_budget", strategy.BudgetFallback))
+	}
+
 	logger.Info("  Cost Constraints:")
 	logger.Info("    Max Total Cost", zap.Float64("total", plan.CostConstraints.MaxTotalCost))
 	logger.Info("    Max Cost/Hour", zap.Float64("hourly", plan.CostConstraints.MaxCostPerHour))
@@ -194,27 +721,510 @@ func executeDryRun(plan *types.ExecutionPlan, nodes []string) error {
 	return nil
 }
 
-// executeProvisioningPlan executes the ASBA plan by launching AWS instances
+// launchOutcome is how one node group's launch went
+type launchOutcome struct {
+	launch  resume.Launch
+	request *aws.LaunchRequest
+	result  *aws.LaunchResult
+	err     error
+}
+
+// split divides a launch that failed after some of its nodes got suspended,
+// lingering or warm pool instances into those nodes, which launched, and the
+// rest, which failed
+func (o launchOutcome) split() (served, failed launchOutcome) {
+	nodes := o.result.ServedNodes()
+	served = launchOutcome{launch: o.launch, result: o.result}
+	served.launch.Nodes = nodes
+	failed = launchOutcome{launch: o.launch, err: o.err}
+	failed.launch.Nodes = slices.DeleteFunc(slices.Clone(o.launch.Nodes), func(node string) bool {
+		return slices.Contains(nodes, node)
+	})
+	if o.request != nil {
+		served.request = o.request.WithNodes(nodes)
+		failed.request = o.request.WithoutNodes(nodes)
+	}
+	return served, failed
+}
+
+// executeProvisioningPlan executes the plans by launching AWS instances, one
+// fleet per node group, concurrently. A node group that fails is marked down
+// while the others keep their instances, as do its own nodes that suspended,
+// lingering or warm pool instances served. The resume fails only when nothing
+// launched or a gang-scheduled node group failed; the rest of the gang is then
+// terminated, since the job cannot start without every node.
 func executeProvisioningPlan(
 	ctx context.Context,
+	cfg *config.Config,
 	awsClient *aws.Client,
 	slurmClient *slurm.Client,
-	plan *types.ExecutionPlan,
-	nodes []string,
+	launches []resume.Launch,
 ) (*types.ExecutionResult, error) {
 
 	result := &types.ExecutionResult{
 		ExecutionStartTime: time.Now(),
+		ExecutionMode:      executionMode(),
+		TraceID:            newTraceID(),
+	}
+
+	var fleetIDs, failedNodes, waitingNodes, gangNodes []string
+	var launchErrs, gangErrs []error
+	var launched []*aws.LaunchRequest
+	nodeCount := 0
+	lifecycleHooks := hooks.NewRunner(logger, cfg.Hooks)
+	var outcomes []launchOutcome
+	for _, outcome := range launchNodeGroups(ctx, cfg, awsClient, launches) {
+		if outcome.err != nil && outcome.result != nil && len(outcome.result.Instances) > 0 {
+			served, failed := outcome.split()
+			outcomes = append(outcomes, served, failed)
+		} else {
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	for _, outcome := range outcomes {
+		launch := outcome.launch
+		if outcome.result != nil {
+			result.Fallbacks = append(result.Fallbacks, outcome.result.Fallbacks...)
+		}
+		if outcome.err != nil {
+			err := fmt.Errorf("failed to launch instances for partition '%s' nodegroup '%s': %w", launch.Partition, launch.NodeGroup, outcome.err)
+			result.Errors = append(result.Errors, types.ExecutionError{
+				Type:        "aws_api",
+				Message:     err.Error(),
+				Timestamp:   time.Now(),
+				Recoverable: true,
+			})
+			// Launches waiting for capacity run their post_launch hooks from the state manager
+			if queueForCapacity(ctx, cfg, outcome) {
+				waitingNodes = append(waitingNodes, launch.Nodes...)
+				continue
+			}
+			runPostLaunchHooks(ctx, lifecycleHooks, outcome)
+			for _, node := range launch.Nodes {
+				result.FailedInstances = append(result.FailedInstances, types.FailedInstance{NodeName: node, ErrorMessage: outcome.err.Error()})
+			}
+			failedNodes = append(failedNodes, launch.Nodes...)
+			launchErrs = append(launchErrs, err)
+			if gangRequired(launch) {
+				gangErrs = append(gangErrs, err)
+			}
+			continue
+		}
+
+		runPostLaunchHooks(ctx, lifecycleHooks, outcome)
+		result.LaunchedInstances = append(result.LaunchedInstances, outcome.result.Instances...)
+		if outcome.request != nil {
+			launched = append(launched, outcome.request)
+		}
+		if outcome.result.FleetId != "" {
+			fleetIDs = append(fleetIDs, outcome.result.FleetId)
+		}
+		for zone, score := range outcome.result.SpotPlacementScores {
+			if result.SpotPlacementScores == nil {
+				result.SpotPlacementScores = make(map[string]int)
+			}
+			result.SpotPlacementScores[zone] = score
+		}
+		result.WarmPoolInstances += outcome.result.WarmPoolInstances
+		result.RestartedInstances += outcome.result.RestartedInstances
+		result.LingeringInstances += outcome.result.LingeringInstances
+		// Capacity already committed to is priced at its discounted rate
+		result.TotalCostEstimate += launch.Plan.GetCostEstimate(len(launch.Nodes), launch.Plan.CostConstraints.MaxDurationHours) *
+			costs.CommittedMultiplier(cfg.Costs.Commitments, outcome.result.Instances)
+		nodeCount += len(launch.Nodes)
+		if gangRequired(launch) {
+			gangNodes = append(gangNodes, launch.Nodes...)
+		}
+	}
+	result.FleetID = strings.Join(fleetIDs, ",")
+
+	if len(gangErrs) > 0 {
+		if len(gangNodes) > 0 {
+			logger.Warn("Terminating the rest of the gang after a node group failed to launch", zap.Strings("nodes", gangNodes))
+			terminated := hooks.Payload{Event: config.HookEventPreTerminate, Nodes: gangNodes, JobID: launches[0].Plan.ExecutionMetadata.JobID}
+			_ = lifecycleHooks.Run(ctx, terminated)
+			terminated.Event = config.HookEventPostTerminate
+			if err := awsClient.TerminateInstances(ctx, gangNodes); err != nil {
+				logger.Error("Failed to terminate gang instances", zap.Strings("nodes", gangNodes), zap.Error(err))
+				terminated.Error = err.Error()
+			}
+			_ = lifecycleHooks.Run(ctx, terminated)
+		}
+		markLaunchFailed(slurmClient, append(failedNodes, gangNodes...))
+		result.Success = false
+		return result, errors.Join(gangErrs...)
+	}
+	if len(result.LaunchedInstances) == 0 && len(waitingNodes) == 0 && len(failedNodes) > 0 {
+		markLaunchFailed(slurmClient, failedNodes)
+		result.Success = false
+		return result, errors.Join(launchErrs...)
+	}
+
+	// Update Slurm with instance information
+	if err := slurmClient.UpdateNodesWithInstanceInfo(ctx, result.LaunchedInstances); err != nil {
+		logger.Error("Failed to update Slurm nodes", zap.Error(err))
+		result.Errors = append(result.Errors, types.ExecutionError{
+			Type:        "slurm_api",
+			Message:     err.Error(),
+			Timestamp:   time.Now(),
+			Recoverable: true,
+		})
+		// Don't fail the operation - instances are launched
+	}
+	if err := addHostsEntries(cfg, result.LaunchedInstances); err != nil {
+		logger.Error("Failed to add burst nodes to the hosts file", zap.Error(err))
+		result.Errors = append(result.Errors, types.ExecutionError{
+			Type:        "hosts_file",
+			Message:     err.Error(),
+			Timestamp:   time.Now(),
+			Recoverable: true,
+		})
+	}
+	if cfg.HealthCheck.Enabled {
+		// New jobs wait on the drained nodes until the state manager's health
+		// checks pass; the health check prolog holds the job already allocated them
+		var launched []string
+		for _, instance := range result.LaunchedInstances {
+			launched = append(launched, instance.NodeName)
+		}
+		if err := slurmClient.SetNodesState(launched, "DRAIN", healthcheck.PendingReason); err != nil {
+			logger.Warn("Failed to hold nodes for health checks", zap.String("nodes", slurm.CompressHostlist(launched)), zap.Error(err))
+		}
+	}
+	if err := registerNodeRecords(ctx, cfg, result.LaunchedInstances); err != nil {
+		logger.Error("Failed to register burst nodes in DNS", zap.Error(err))
+		result.Errors = append(result.Errors, types.ExecutionError{
+			Type:        "dns",
+			Message:     err.Error(),
+			Timestamp:   time.Now(),
+			Recoverable: true,
+		})
+	}
+
+	markLaunchFailed(slurmClient, failedNodes)
+
+	// Draining keeps the job on the waiting nodes while showing why they are not up
+	result.WaitingForCapacity = waitingNodes
+	if err := slurmClient.SetNodesState(waitingNodes, "DRAIN", capacity.WaitingReason); err != nil {
+		logger.Warn("Failed to mark nodes waiting for capacity", zap.String("nodes", slurm.CompressHostlist(waitingNodes)), zap.Error(err))
+	}
+
+	// Job-level settings (job ID, MPI, placement) are the same in every launch's plan
+	plan := launches[0].Plan
+	recordPlacement(ctx, cfg, slurmClient, plan, result)
+	registerElasticJob(cfg, launches[0], launched, result.LaunchedInstances)
+
+	result.Success = true
+	result.ExecutionEndTime = time.Now()
+	result.ExecutionDuration = types.Duration(result.ExecutionEndTime.Sub(result.ExecutionStartTime))
+
+	recordPlan(cfg, plan, nodeCount, result)
+
+	return result, nil
+}
+
+// runPostLaunchHooks tells the post_launch hooks about a node group's launch:
+// its instances, or why it failed
+func runPostLaunchHooks(ctx context.Context, lifecycleHooks *hooks.Runner, outcome launchOutcome) {
+	launch := outcome.launch
+	payload := hooks.Payload{
+		Event:     config.HookEventPostLaunch,
+		Partition: launch.Partition,
+		NodeGroup: launch.NodeGroup,
+		Nodes:     launch.Nodes,
+		JobID:     launch.Plan.ExecutionMetadata.JobID,
+	}
+	if outcome.err != nil {
+		payload.Error = outcome.err.Error()
+	} else {
+		payload.Instances = outcome.result.Instances
+	}
+	// Only pre_launch hooks can be required
+	_ = lifecycleHooks.Run(ctx, payload)
+}
+
+// registerNodeRecords points the launched nodes' DNS records at their private
+// IPs when dns is configured
+func registerNodeRecords(ctx context.Context, cfg *config.Config, instances []types.InstanceInfo) error {
+	if !cfg.DNS.Enabled() || len(instances) == 0 {
+		return nil
+	}
+	registrar, err := aws.NewDNSRegistrar(ctx, logger, &cfg.AWS, &cfg.DNS)
+	if err != nil {
+		return err
+	}
+	return registrar.Register(ctx, instances)
+}
+
+// addHostsEntries adds the launched nodes to the controller's hosts file when
+// hosts_file is enabled
+func addHostsEntries(cfg *config.Config, instances []types.InstanceInfo) error {
+	if !cfg.HostsFile.Enabled {
+		return nil
+	}
+	addresses := make(map[string]string, len(instances))
+	for _, instance := range instances {
+		if instance.PrivateIP != "" {
+			addresses[instance.NodeName] = instance.PrivateIP
+		}
+	}
+	return hostsfile.New(cfg.HostsFile.Path).Add(addresses)
+}
+
+// launchFailedReason is the Slurm reason on nodes whose node group failed to launch
+const launchFailedReason = "aws_launch_failed"
+
+// markLaunchFailed marks nodes that did not launch down, so Slurm requeues
+// their jobs instead of waiting out ResumeTimeout
+func markLaunchFailed(slurmClient *slurm.Client, nodes []string) {
+	if err := slurmClient.SetNodesState(nodes, "DOWN", launchFailedReason); err != nil {
+		logger.Warn("Failed to mark nodes down after launch failure", zap.String("nodes", slurm.CompressHostlist(nodes)), zap.Error(err))
+	}
+}
+
+// queueForCapacity hands a launch that failed for lack of capacity to the state
+// manager to retry, when the capacity queue is enabled. With arbitration, so
+// are launches over the vCPU quota or held back for launches waiting for it.
+// Gang-scheduled launches are not queued; the rest of the gang is terminated
+// instead.
+func queueForCapacity(ctx context.Context, cfg *config.Config, outcome launchOutcome) bool {
+	if !cfg.CapacityQueue.Enabled || outcome.request == nil || gangRequired(outcome.launch) || !capacity.Waits(&cfg.CapacityQueue, outcome.err) {
+		return false
+	}
+
+	req, err := capacity.NewQueue(cfg.CapacityQueue).Enqueue(outcome.request, outcome.err)
+	if err != nil {
+		logger.Error("Failed to queue launch for capacity", zap.Strings("nodes", outcome.launch.Nodes), zap.Error(err))
+		return false
+	}
+	logger.Info("Launch waiting for AWS capacity",
+		zap.String("partition", outcome.launch.Partition),
+		zap.String("node_group", outcome.launch.NodeGroup),
+		zap.String("nodes", slurm.CompressHostlist(outcome.launch.Nodes)),
+		zap.Time("next_attempt", req.NextAttempt),
+		zap.Time("deadline", req.Deadline))
+	if err := capacity.Notify(ctx, cfg.CapacityQueue.NotifyCommand, capacity.EventWaiting, req); err != nil {
+		logger.Warn("Failed to notify of launch waiting for capacity", zap.Error(err))
+	}
+	return true
+}
+
+// launchNodeGroups launches every node group at once and returns the outcomes in
+// launch order. A failed gang-scheduled node group cancels the other gang launches.
+func launchNodeGroups(ctx context.Context, cfg *config.Config, awsClient *aws.Client, launches []resume.Launch) []launchOutcome {
+	outcomes := make([]launchOutcome, len(launches))
+	group, gangCtx := errgroup.WithContext(ctx)
+
+	for i, launch := range launches {
+		group.Go(func() error {
+			launchCtx := ctx
+			if gangRequired(launch) {
+				launchCtx = gangCtx
+			}
+
+			launchReq, launchResult, err := launchNodeGroup(launchCtx, cfg, awsClient, launch)
+			outcomes[i] = launchOutcome{launch: launch, request: launchReq, result: launchResult, err: err}
+			if err != nil && gangRequired(launch) {
+				return err
+			}
+			return nil
+		})
+	}
+
+	// Every failure is in its outcome
+	_ = group.Wait()
+	return outcomes
+}
+
+// gangRequired reports whether a launch's nodes are only useful together with the
+// rest of the job's nodes
+func gangRequired(launch resume.Launch) bool {
+	return launch.Plan.MPIConfig.RequiresGangScheduling
+}
+
+// launchNodeGroup launches the fleet for one node group, returning the request
+// it launched
+func launchNodeGroup(ctx context.Context, cfg *config.Config, awsClient *aws.Client, launch resume.Launch) (*aws.LaunchRequest, *aws.LaunchResult, error) {
+	launchReq, err := buildLaunchRequest(cfg, launch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Decide the spot/on-demand split and enforce the spot price budget before launching
+	strategy, err := awsClient.PlanSpotStrategy(ctx, launchReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("spot pricing validation failed: %w", err)
+	}
+	if strategy != nil {
+		onDemand, spot := strategy.CapacitySplit(len(launch.Nodes))
+		logger.Info("Using spot strategy",
+			zap.String("partition", launch.Partition),
+			zap.String("node_group", launch.NodeGroup),
+			zap.Int("on_demand_nodes", onDemand),
+			zap.Int("spot_nodes", spot),
+			zap.Bool("over_budget", strategy.BudgetFallback))
+	}
+	launchReq.SpotStrategy = strategy
+
+	// A required pre_launch hook, such as a license checkout, can refuse the launch
+	if err := hooks.NewRunner(logger, cfg.Hooks).Run(ctx, hooks.Payload{
+		Event:     config.HookEventPreLaunch,
+		Partition: launch.Partition,
+		NodeGroup: launch.NodeGroup,
+		Nodes:     launch.Nodes,
+		JobID:     launch.Plan.ExecutionMetadata.JobID,
+	}); err != nil {
+		return nil, nil, fmt.Errorf("pre_launch %w", err)
+	}
+
+	// Launches already waiting for vCPU quota get it first, in the order the
+	// state manager ranks them
+	if cfg.CapacityQueue.Enabled && cfg.CapacityQueue.Arbitrated() && !gangRequired(launch) {
+		contended, err := capacity.NewQueue(cfg.CapacityQueue).QuotaContended()
+		if err != nil {
+			logger.Warn("Failed to read the capacity queue", zap.Error(err))
+		} else if contended {
+			return launchReq, nil, capacity.ErrQuotaContended
+		}
+	}
+
+	launchResult, err := awsClient.LaunchInstances(ctx, launchReq)
+	if launchResult != nil && len(launchResult.ClaimedNodes) > 0 {
+		claimed, claimErr := startClaimedNodes(ctx, cfg, awsClient, launchReq, launchResult)
+		if claimErr != nil {
+			return launchReq, nil, claimErr
+		}
+		launchResult = claimed
+	}
+	return launchReq, launchResult, err
+}
+
+// startClaimedNodes starts slurmd as the new node on each lingering instance
+// the launch claimed. Instances the claim fails on are terminated and their
+// nodes launched on new instances.
+func startClaimedNodes(ctx context.Context, cfg *config.Config, awsClient *aws.Client, launchReq *aws.LaunchRequest, result *aws.LaunchResult) (*aws.LaunchResult, error) {
+	claimCommand := ""
+	if nodeGroup := cfg.FindNodeGroup(launchReq.Partition, launchReq.NodeGroup); nodeGroup != nil {
+		claimCommand = nodeGroup.Linger.ClaimCommand
+	}
+
+	// SSM finds each instance's region from its node group
+	instances := slices.Clone(result.Instances)
+	for i := range instances {
+		instances[i].Partition = launchReq.Partition
+		instances[i].NodeGroup = launchReq.NodeGroup
+	}
+	results, err := ssm.NewNodes(logger, cfg, instances).Run(ctx, result.ClaimedNodes, ssm.Command{
+		Script:  ssm.ClaimScript(claimCommand),
+		Comment: "aws-slurm-burst claim",
+		Timeout: 2 * time.Minute,
+	})
+	if err != nil {
+		logger.Warn("Failed to start slurmd on claimed instances through SSM", zap.Error(err))
+	}
+
+	var claimed, failed []string
+	for _, node := range result.ClaimedNodes {
+		if claim := results[node]; claim.Status == "Success" {
+			claimed = append(claimed, node)
+		} else {
+			logger.Warn("Claimed instance failed to start slurmd, relaunching the node",
+				zap.String("node", node),
+				zap.String("status", claim.Status),
+				zap.String("output", claim.Output))
+			failed = append(failed, node)
+		}
+	}
+	if len(failed) == 0 {
+		return result, nil
+	}
+
+	if err := awsClient.TerminateNodeGroupInstances(ctx, launchReq.Partition, launchReq.NodeGroup, failed); err != nil {
+		return nil, fmt.Errorf("failed to terminate claimed instances that did not start slurmd: %w", err)
+	}
+	relaunch := *launchReq
+	relaunch.NodeIds = failed
+	relaunch.NewInstancesOnly = true
+	relaunched, err := awsClient.LaunchInstances(ctx, &relaunch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to relaunch nodes whose claimed instances did not start slurmd: %w", err)
+	}
+
+	result.Instances = slices.DeleteFunc(result.Instances, func(instance types.InstanceInfo) bool {
+		return slices.Contains(failed, instance.NodeName)
+	})
+	result.Instances = append(result.Instances, relaunched.Instances...)
+	result.Fallbacks = append(result.Fallbacks, relaunched.Fallbacks...)
+	result.LingeringInstances = len(claimed)
+	result.ClaimedNodes = claimed
+	if result.FleetId == "" {
+		result.FleetId = relaunched.FleetId
+	}
+	return result, nil
+}
+
+// recordPlan keeps the ASBA plan the job launched with so the performance export
+// can score its predictions. Standalone plans predict nothing and are not kept.
+func recordPlan(cfg *config.Config, plan *types.ExecutionPlan, nodeCount int, result *types.ExecutionResult) {
+	jobID := plan.ExecutionMetadata.JobID
+	if result.ExecutionMode != "asba" || jobID == "" || cfg.Ecosystem.DataExchangeDir == "" {
+		return
+	}
+
+	path, err := prediction.Save(cfg.Ecosystem.DataExchangeDir, &prediction.Record{
+		JobID:            jobID,
+		RecordedAt:       result.ExecutionEndTime,
+		TraceID:          result.TraceID,
+		NodeCount:        nodeCount,
+		EstimatedCostUSD: result.TotalCostEstimate,
+		Plan:             plan,
+	})
+	if err != nil {
+		logger.Warn("Failed to record execution plan", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+	logger.Debug("Recorded execution plan", zap.String("job_id", jobID), zap.String("path", path))
+}
+
+// registerElasticJob records an elastic MPI job's launches so the state
+// manager can replace its interrupted spot nodes mid-job
+func registerElasticJob(cfg *config.Config, launch resume.Launch, requests []*aws.LaunchRequest, instances []types.InstanceInfo) {
+	jobID := launch.Plan.ExecutionMetadata.JobID
+	requested := launch.Plan.MPIConfig.Elastic || cfg.ElasticMPI.ListsPartition(launch.Partition)
+	if !cfg.ElasticMPI.Enabled || !requested || jobID == "" || len(requests) == 0 || simulateRun {
+		if requested && !cfg.ElasticMPI.Enabled {
+			logger.Warn("Execution plan asks for elastic MPI but elastic_mpi is disabled", zap.String("job_id", jobID))
+		}
+		return
+	}
+	if err := elastic.Register(cfg.Ecosystem.DataExchangeDir, jobID, requests, instances); err != nil {
+		logger.Warn("Failed to register elastic MPI job", zap.String("job_id", jobID), zap.Error(err))
+		return
+	}
+	logger.Info("Registered elastic MPI job for spot node replacement", zap.String("job_id", jobID))
+}
+
+// buildLaunchRequest translates a node group's execution plan into an AWS launch request
+func buildLaunchRequest(cfg *config.Config, launch resume.Launch) (*aws.LaunchRequest, error) {
+	plan, nodes := launch.Plan, launch.Nodes
+
+	architecture, err := plan.ResolveArchitecture()
+	if err != nil {
+		return nil, fmt.Errorf("invalid execution plan architecture: %w", err)
 	}
 
 	// Build launch request from execution plan
 	launchReq := &aws.LaunchRequest{
 		NodeIds:   nodes,
-		Partition: "aws", // TODO: Extract from node names
-		NodeGroup: "cpu", // TODO: Extract from node names
+		Partition: launch.Partition,
+		NodeGroup: launch.NodeGroup,
 		InstanceRequirements: &types.InstanceRequirements{
 			// Direct execution of ASBA decisions
 			InstanceFamilies:   plan.InstanceSpec.InstanceTypes,
+			MinCPUs:            plan.InstanceSpec.MinCPUs,
+			MinMemoryMB:        plan.InstanceSpec.MinMemoryMB,
+			GPUs:               plan.InstanceSpec.GPUs,
+			Architecture:       architecture,
 			RequiresEFA:        plan.MPIConfig.RequiresEFA,
 			PlacementGroupType: plan.NetworkConfig.PlacementGroupType,
 			MaxSpotPrice:       plan.InstanceSpec.MaxSpotPrice,
@@ -227,52 +1237,200 @@ func executeProvisioningPlan(
 			IsMPIJob:     plan.MPIConfig.IsMPIJob,
 			MPIProcesses: plan.MPIConfig.ProcessCount,
 		},
+		// Without an ASBA plan, launch the overrides exactly as configured
+		UseConfiguredOverrides: launch.Standalone,
+	}
+	// Containerized jobs launch through the node group's container backend
+	if nodeGroup := cfg.FindNodeGroup(launch.Partition, launch.NodeGroup); nodeGroup != nil && launch.Job != nil {
+		launchReq.Containerized = nodeGroup.ContainerBackend.Serves(launch.Job.Container != "", launch.Job.Constraints.Features)
+	}
+	if launch.Purchasing != nil {
+		launchReq.CapacityReservations = launch.Purchasing.CapacityReservations
+		launchReq.QueueForCapacity = launch.Purchasing.QueueForCapacity
 	}
 
-	// Launch instances
-	launchResult, err := awsClient.LaunchInstances(ctx, launchReq)
+	// A Capacity Block holds instances of one type only
+	if block := plan.CapacityBlock; block != nil {
+		launchReq.CapacityBlock = block
+		if block.InstanceType != "" {
+			launchReq.InstanceRequirements.InstanceFamilies = []string{block.InstanceType}
+		}
+	}
+
+	// Bootstrap and MPI setup are layered onto the launch template's user data
+	userDataParts, err := buildUserData(cfg, plan)
 	if err != nil {
-		result.Success = false
-		result.Errors = append(result.Errors, types.ExecutionError{
-			Type:        "aws_api",
-			Message:     err.Error(),
-			Timestamp:   time.Now(),
-			Recoverable: true,
-		})
-		return result, fmt.Errorf("failed to launch instances: %w", err)
+		return nil, fmt.Errorf("failed to build user data: %w", err)
+	}
+	launchReq.UserDataParts = userDataParts
+	if err := applyStaging(cfg, launch, launchReq); err != nil {
+		return nil, fmt.Errorf("failed to set up staging: %w", err)
 	}
 
-	result.LaunchedInstances = launchResult.Instances
-	result.FleetID = launchResult.FleetId
+	// Heterogeneous plans map specific nodes to specific instance types
+	if len(launch.NodeRoles) > 0 {
+		launchReq.NodeRoles = aws.NodeRoleLaunchesFromPlan(plan, launch.NodeRoles)
+	}
 
-	// Update Slurm with instance information
-	if err := slurmClient.UpdateNodesWithInstanceInfo(ctx, launchResult.Instances); err != nil {
-		logger.Error("Failed to update Slurm nodes", zap.Error(err))
-		result.Errors = append(result.Errors, types.ExecutionError{
-			Type:        "slurm_api",
-			Message:     err.Error(),
-			Timestamp:   time.Now(),
-			Recoverable: true,
-		})
-		// Don't fail the operation - instances are launched
+	return launchReq, nil
+}
+
+// recordPlacement records where each node landed: as az- node features when the
+// launch may span AZs, in each node's comment for the prolog, and with spot
+// placement scores on the job itself for the performance export
+func recordPlacement(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client, plan *types.ExecutionPlan, result *types.ExecutionResult) {
+	instances := result.LaunchedInstances
+	if len(instances) == 0 {
+		return
 	}
+	zones := slurm.AvailabilityZones(instances)
 
-	result.Success = true
-	result.ExecutionEndTime = time.Now()
-	result.ExecutionDuration = types.Duration(result.ExecutionEndTime.Sub(result.ExecutionStartTime))
-	result.TotalCostEstimate = plan.GetCostEstimate(len(nodes), plan.CostConstraints.MaxDurationHours)
+	if len(zones) > 0 && cfg.Slurm.AZNodeFeatures && slurm.NeedsAZHints(plan.NetworkConfig.PlacementGroupType, instances) {
+		if err := slurmClient.SetNodeAvailabilityZones(ctx, instances); err != nil {
+			logger.Warn("Failed to set node availability zone features", zap.Error(err))
+		} else {
+			logger.Info("Tagged nodes with availability zone features", zap.Strings("availability_zones", zones))
+		}
+	}
 
-	return result, nil
+	meta := types.NewAWSJobMetadata(instances)
+	meta.SpotPlacementScores = result.SpotPlacementScores
+	meta.Fallbacks = result.Fallbacks
+	meta.FleetID = result.FleetID
+	meta.ExecutionMode = result.ExecutionMode
+	meta.TraceID = result.TraceID
+	if err := slurmClient.SetNodePlacement(ctx, instances, meta); err != nil {
+		logger.Warn("Failed to record node placement", zap.Error(err))
+	}
+	if err := slurmClient.RecordJobPlacement(ctx, plan.ExecutionMetadata.JobID, meta); err != nil {
+		logger.Warn("Failed to record job placement", zap.Error(err))
+	}
 }
 
-// generateDefaultExecutionPlan creates a basic execution plan from static configuration (original plugin style)
-func generateDefaultExecutionPlan(cfg *config.Config, nodeList string) (*types.ExecutionPlan, error) {
-	// Parse node list to determine partition/nodegroup
-	partition, nodeGroup, err := parseNodeListForPartition(nodeList)
+// buildUserData returns the user-data parts layered onto the launch template:
+// the plan's bootstrap, MPI setup and, when enabled, the node metrics collector
+// and the spot interruption checkpointer
+func buildUserData(cfg *config.Config, plan *types.ExecutionPlan) ([]userdata.Part, error) {
+	parts, err := userdata.ForExecutionPlan(plan, cfg.MPI.UserDataSetup)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.NodeMetrics.Enabled {
+		script, err := userdata.NodeMetricsScript(cfg.NodeMetrics.IntervalSeconds, cfg.NodeMetrics.Destination)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, userdata.NewPart(userdata.NodeMetricsFilename, script))
+	}
+	// Drained nodes still run the job already allocated them; the prolog holds it
+	if cfg.HealthCheck.Enabled {
+		parts = append(parts, userdata.NewPart(healthcheck.PrologFilename, healthcheck.PrologScript(&cfg.HealthCheck)))
+	}
+
+	if !cfg.Checkpoint.Enabled {
+		if plan.Checkpoint != nil {
+			logger.Warn("Execution plan declares the job checkpointable, but checkpoint is not enabled; spot interruptions will not checkpoint it",
+				zap.String("job_id", plan.ExecutionMetadata.JobID))
+		}
+		return parts, nil
+	}
+	script, err := userdata.CheckpointScript(userdata.Checkpoint{
+		Method:       cfg.Checkpoint.Method,
+		Signal:       cfg.Checkpoint.Signal,
+		Command:      cfg.Checkpoint.Command,
+		Directory:    cfg.Checkpoint.Directory,
+		GraceSeconds: cfg.Checkpoint.GraceSeconds,
+		PollSeconds:  cfg.Checkpoint.PollSeconds,
+		Partitions:   cfg.Checkpoint.Partitions,
+		PlanJobID:    plan.ExecutionMetadata.JobID,
+		Plan:         plan.Checkpoint,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(parts, userdata.NewPart(userdata.CheckpointFilename, script)), nil
+}
+
+// applyStaging gives the launch the scratch volume and data staging the job
+// script's burst buffer directives declare, capped to the configured limits
+func applyStaging(cfg *config.Config, launch resume.Launch, launchReq *aws.LaunchRequest) error {
+	spec := launch.Staging
+	if spec.Empty() {
+		return nil
+	}
+	jobID := launchReq.Job.JobID
+	if !cfg.Staging.Enabled {
+		logger.Warn("Job script has burst buffer directives, but staging is not enabled; they are ignored",
+			zap.String("job_id", jobID))
+		return nil
+	}
+	for _, ignored := range spec.Ignored {
+		logger.Warn("Ignoring burst buffer directive", zap.String("job_id", jobID), zap.String("directive", ignored))
+	}
+
+	staging := &cfg.Staging
+	scratchGB := spec.ScratchGB
+	if scratchGB > staging.MaxScratchGB {
+		logger.Warn("Scratch request exceeds max_scratch_gb; capping it",
+			zap.String("job_id", jobID), zap.Int("requested_gb", scratchGB), zap.Int("max_scratch_gb", staging.MaxScratchGB))
+		scratchGB = staging.MaxScratchGB
+	}
+	retainHours := int(math.Ceil(time.Duration(spec.Retention).Hours()))
+	if retainHours > staging.MaxRetentionHours {
+		logger.Warn("Scratch retention exceeds max_retention_hours; shortening it",
+			zap.String("job_id", jobID), zap.Int("requested_hours", retainHours), zap.Int("max_retention_hours", staging.MaxRetentionHours))
+		retainHours = staging.MaxRetentionHours
+	}
+
+	script, err := userdata.StagingScript(userdata.Staging{
+		JobID:                  jobID,
+		ScratchMount:           staging.ScratchMount,
+		ScratchGB:              scratchGB,
+		TransferTimeoutMinutes: staging.TransferTimeoutMinutes,
+		StageIn:                spec.StageIn,
+		StageOut:               spec.StageOut,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse node list: %w", err)
+		return err
+	}
+	launchReq.UserDataParts = append(launchReq.UserDataParts, userdata.NewPart(userdata.StagingFilename, script))
+	if scratchGB > 0 {
+		launchReq.Scratch = &aws.ScratchVolume{
+			DeviceName:  staging.DeviceName,
+			VolumeType:  staging.VolumeType,
+			SizeGB:      scratchGB,
+			RetainHours: retainHours,
+		}
+	}
+
+	logger.Info("Staging job data for burst buffer directives",
+		zap.String("job_id", jobID),
+		zap.Int("scratch_gb", scratchGB),
+		zap.Int("retain_hours", retainHours),
+		zap.Int("stage_in", len(spec.StageIn)),
+		zap.Int("stage_out", len(spec.StageOut)))
+	return nil
+}
+
+// executionMode reports whether this launch follows an ASBA plan or the static configuration
+func executionMode() string {
+	if executionPlan != "" {
+		return "asba"
 	}
+	return "standalone"
+}
 
+// newTraceID returns a random ID linking a launch's resume, prolog and export records
+func newTraceID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id)
+}
+
+// generateDefaultExecutionPlan creates a basic execution plan from a node group's static configuration (original plugin style)
+func generateDefaultExecutionPlan(cfg *config.Config, partition, nodeGroup string) (*types.ExecutionPlan, error) {
 	// Find matching node group configuration
 	nodeGroupConfig := cfg.FindNodeGroup(partition, nodeGroup)
 	if nodeGroupConfig == nil {
@@ -296,11 +1454,13 @@ func generateDefaultExecutionPlan(cfg *config.Config, nodeList string) (*types.E
 			LaunchTemplateID:   nodeGroupConfig.LaunchTemplateSpec.LaunchTemplateID,
 			SecurityGroupIds:   nodeGroupConfig.SecurityGroupIds,
 			IAMInstanceProfile: nodeGroupConfig.IAMInstanceProfile,
+			Architecture:       nodeGroupConfig.EffectiveArchitecture(),
 		},
 		MPIConfig: types.MPIConfiguration{
 			IsMPIJob:               false, // Default to non-MPI (ASBA would detect this)
 			RequiresEFA:            false,
 			RequiresGangScheduling: false,
+			Accelerator:            resume.NeuronAccelerator(instanceTypes),
 		},
 		CostConstraints: types.CostConstraints{
 			PreferSpot:        nodeGroupConfig.PurchasingOption == "spot",
@@ -329,16 +1489,123 @@ func generateDefaultExecutionPlan(cfg *config.Config, nodeList string) (*types.E
 	return plan, nil
 }
 
-// parseNodeListForPartition extracts partition and nodegroup from node list like "aws-cpu-[001-004]"
-func parseNodeListForPartition(nodeList string) (string, string, error) {
-	// Simple parsing for node lists like "aws-cpu-001" or "aws-gpu-[001-004]"
-	parts := strings.Split(nodeList, "-")
-	if len(parts) < 2 {
-		return "", "", fmt.Errorf("invalid node list format: %s", nodeList)
+// applyJobRequirements fills the plan's per-node requirements from the pending
+// Slurm job, and returns the job or nil when none was found
+func applyJobRequirements(ctx context.Context, slurmClient *slurm.Client, slurmConfig *config.SlurmConfig, plan *types.ExecutionPlan, nodes []string) *types.SlurmJob {
+	job, err := slurmClient.GetJobForNodes(ctx, nodes)
+	if err != nil {
+		logger.Debug("No pending job found for nodes, using configured instance types as-is", zap.Error(err))
+		return nil
 	}
 
-	partition := parts[0]
-	nodeGroup := parts[1]
+	plan.InstanceSpec.MinCPUs = job.Resources.CPUsPerNode
+	plan.InstanceSpec.MinMemoryMB = job.Resources.NodeMemoryMB()
+	plan.InstanceSpec.GPUs = job.Resources.GPUs
+	plan.ExecutionMetadata.JobID = job.JobID
+	plan.ExecutionMetadata.DecisionFactors = append(plan.ExecutionMetadata.DecisionFactors, "job_requirements")
+
+	applyFeatureConstraints(slurmConfig, plan, job.Constraints.FeatureTerms())
+	resume.ApplyNeuronHints(logger, plan, job)
+
+	logger.Info("Sizing instances for pending job",
+		zap.String("job_id", job.JobID),
+		zap.Int("cpus_per_node", plan.InstanceSpec.MinCPUs),
+		zap.Int("memory_mb_per_node", plan.InstanceSpec.MinMemoryMB),
+		zap.Int("gpus", plan.InstanceSpec.GPUs))
+
+	return job
+}
+
+// applyPurchasingPolicy sets how a standalone plan buys capacity from the
+// priority the job's QOS gives it, and returns that priority's purchasing, or
+// nil when the policy leaves the node group's purchasing in place
+func applyPurchasingPolicy(policy *config.PurchasingPolicyConfig, plan *types.ExecutionPlan, job *types.SlurmJob) *config.PriorityPurchasingConfig {
+	qos := ""
+	if job != nil {
+		qos = job.QOS
+	}
+	purchasing := policy.ForQOS(qos)
+	if purchasing == nil {
+		return nil
+	}
 
-	return partition, nodeGroup, nil
+	plan.InstanceSpec.PurchasingOption = purchasing.PurchasingOption
+	if purchasing.MaxSpotPrice > 0 {
+		plan.InstanceSpec.MaxSpotPrice = purchasing.MaxSpotPrice
+	}
+	plan.CostConstraints.PreferSpot = purchasing.PurchasingOption == "spot"
+	plan.CostConstraints.AllowMixedPricing = purchasing.PurchasingOption == "mixed"
+	plan.ExecutionMetadata.Priority = purchasing.Priority
+	plan.ExecutionMetadata.DecisionFactors = append(plan.ExecutionMetadata.DecisionFactors, "purchasing_policy")
+
+	logger.Info("Purchasing for job priority",
+		zap.String("qos", qos),
+		zap.String("priority", purchasing.Priority),
+		zap.String("purchasing", purchasing.PurchasingOption),
+		zap.Bool("capacity_reservations", purchasing.CapacityReservations),
+		zap.Bool("queue_for_capacity", purchasing.QueueForCapacity))
+	return purchasing
+}
+
+// applyFeatureConstraints steers the plan's instance types using the terms of
+// the job's --constraint, each listing the features that satisfy it
+func applyFeatureConstraints(slurmConfig *config.SlurmConfig, plan *types.ExecutionPlan, features [][]string) {
+	if len(features) == 0 {
+		return
+	}
+
+	mappings, unmatched := slurmConfig.MatchFeatureGroups(features)
+	if len(unmatched) > 0 {
+		logger.Debug("Job features without instance mapping", zap.Strings("features", unmatched))
+	}
+	if len(mappings) == 0 {
+		return
+	}
+
+	requirements, err := config.CombineFeatureMappings(mappings)
+	if err != nil {
+		logger.Warn("Ignoring job feature constraints", zap.Any("features", features), zap.Error(err))
+		return
+	}
+
+	if requirements.Architecture != "" && requirements.Architecture != plan.InstanceSpec.Architecture {
+		logger.Warn("Job features require a different architecture than the node group, ignoring feature constraints",
+			zap.String("feature_architecture", requirements.Architecture),
+			zap.String("node_group_architecture", plan.InstanceSpec.Architecture))
+		return
+	}
+
+	if requirements.InstanceFamilies != nil {
+		allowed := make(map[string]bool, len(requirements.InstanceFamilies))
+		for _, family := range requirements.InstanceFamilies {
+			allowed[family] = true
+		}
+
+		var instanceTypes []string
+		for _, instanceType := range plan.InstanceSpec.InstanceTypes {
+			if allowed[types.InstanceFamilyOf(instanceType)] {
+				instanceTypes = append(instanceTypes, instanceType)
+			}
+		}
+
+		// None of the configured overrides carry the feature; let the fleet size the mapped families
+		if len(instanceTypes) == 0 {
+			instanceTypes = requirements.InstanceFamilies
+		}
+		plan.InstanceSpec.InstanceTypes = instanceTypes
+	}
+
+	if requirements.GPUs > plan.InstanceSpec.GPUs {
+		plan.InstanceSpec.GPUs = requirements.GPUs
+	}
+	if requirements.RequiresEFA {
+		plan.MPIConfig.RequiresEFA = true
+	}
+	plan.ExecutionMetadata.DecisionFactors = append(plan.ExecutionMetadata.DecisionFactors, "feature_constraints")
+
+	logger.Info("Applied job feature constraints",
+		zap.Any("features", features),
+		zap.Strings("instance_types", plan.InstanceSpec.InstanceTypes),
+		zap.Int("gpus", plan.InstanceSpec.GPUs),
+		zap.Bool("requires_efa", plan.MPIConfig.RequiresEFA))
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/simulate"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// simulation is the in-memory EC2 and Slurm a --simulate resume runs against
+type simulation struct {
+	ec2   *simulate.EC2
+	slurm *simulate.Slurm
+}
+
+// newSimulation creates the simulated EC2 and Slurm. The configuration is
+// changed to keep a simulated resume from touching shared state: no data
+// exchange, so no ASBA requests or recorded results, no shared rate limits
+// and no instance catalog refresh.
+func newSimulation(cfg *config.Config) *simulation {
+	cfg.Ecosystem.DataExchangeDir = ""
+	cfg.Slurm.RateLimitDir = ""
+	cfg.AWS.InstanceCatalog.Enabled = false
+	// Site hooks would check out licenses or register instances that do not exist
+	cfg.Hooks = nil
+
+	logger.Info("Simulating EC2 and Slurm in memory",
+		zap.Int("ec2_latency_ms", cfg.Simulation.EC2LatencyMS),
+		zap.Int("slurm_latency_ms", cfg.Simulation.SlurmLatencyMS),
+		zap.Int("boot_seconds", cfg.Simulation.BootSeconds),
+		zap.Int("capacity", cfg.Simulation.Capacity))
+
+	return &simulation{
+		ec2:   simulate.NewEC2(cfg.AWS.Region, cfg.Simulation),
+		slurm: simulate.NewSlurm(cfg.Simulation),
+	}
+}
+
+func (s *simulation) slurmClient(cfg *config.Config) *slurm.Client {
+	return slurm.NewClientWithRunner(logger, &cfg.Slurm, s.slurm.Run)
+}
+
+func (s *simulation) awsClient(cfg *config.Config) *aws.Client {
+	return aws.NewClientWithEC2API(logger, &cfg.AWS, cfg, s.ec2)
+}
+
+// simulationReport is what a simulated resume prints
+type simulationReport struct {
+	Result       *types.ExecutionResult `json:"result"`
+	Error        string                 `json:"error,omitempty"`
+	SlurmUpdates []simulate.Update      `json:"slurm_updates"`
+}
+
+// report prints the execution result, with its timing and cost estimate,
+// and the node updates Slurm received, as JSON on stdout
+func (s *simulation) report(result *types.ExecutionResult, err error) error {
+	report := simulationReport{Result: result, SlurmUpdates: s.slurm.Updates()}
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to write simulation report: %w", err)
+	}
+	return nil
+}
@@ -3,12 +3,30 @@ package main
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/batch"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/bootdiagnostics"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/capacity"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ecosystem"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/elastic"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/emergency"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/epilog"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/healthcheck"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/hooks"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/policysync"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/scaling"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ssm"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/status"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -36,7 +54,10 @@ func main() {
 		Use:   "aws-slurm-burst-state-manager",
 		Short: "Manage Slurm node states for AWS instances",
 		Long: `Periodic state management for Slurm nodes in AWS partitions.
-Handles stuck nodes, failed launches, and state transitions.`,
+Handles stuck nodes, failed launches, and state transitions, and powers nodes
+up and down ahead of demand for partitions with a scaling policy. Writes the
+burst status document when status.output_file is set, keeps the cached ASBA/ASBB
+detection current, and exports the jobs queued by aws-slurm-burst-epilog.`,
 		RunE: manageStates,
 	}
 
@@ -97,6 +118,9 @@ func manageStates(cmd *cobra.Command, args []string) error {
 		zap.Int("total_nodes", len(allNodes)),
 		zap.Int("state_entries", len(nodeStates)))
 
+	// Before the node state fixes power down the nodes Slurm gave up on
+	captureUnresponsiveNodes(ctx, cfg, nodeStates)
+
 	// Process each node state and fix issues
 	for _, nodeInfo := range nodeStates {
 		if err := processNodeState(ctx, slurmClient, nodeInfo); err != nil {
@@ -107,11 +131,572 @@ func manageStates(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Nothing launches instances while an emergency stop is in force
+	stopped := emergencyStopped(cfg)
+	if !stopped {
+		retryCapacityQueue(ctx, cfg, slurmClient)
+
+		replaceElasticNodes(ctx, cfg, slurmClient)
+	}
+
+	applyScalingPolicies(ctx, slurmClient, cfg)
+
+	manageInstances(ctx, cfg, slurmClient, stopped)
+
+	cleanupExchangeDir(cfg)
+
+	refreshEcosystem(ctx, cfg)
+
+	syncPolicyIdentities(ctx, cfg, slurmClient)
+
+	gateNodeHealth(cfg, slurmClient, nodeStates)
+
+	trackBatchJobs(ctx, cfg)
+
+	// Exports run last so slow AWS calls cannot delay node state fixes
+	processEpilogQueue(cfg)
+
 	logger.Info("State management cycle completed")
 	return nil
 }
 
+// emergencyStopped reports whether an emergency stop of any partition is in
+// force, or the stop state cannot be read
+func emergencyStopped(cfg *config.Config) bool {
+	state, err := emergency.Load(cfg.EmergencyStop.StateFile)
+	if err != nil {
+		logger.Error("Cannot read emergency stop state, launching nothing", zap.Error(err))
+		return true
+	}
+	if len(state.Stops) > 0 {
+		logger.Warn("Emergency stop in force, launching nothing", zap.Int("stops", len(state.Stops)))
+		return true
+	}
+	return false
+}
+
+// cleanupExchangeDir removes per-job data exchange files past their retention
+func cleanupExchangeDir(cfg *config.Config) {
+	if dryRun || cfg.Ecosystem.DataExchangeDir == "" {
+		return
+	}
+
+	retention := time.Duration(cfg.Ecosystem.RetentionDays) * 24 * time.Hour
+	removed, err := exchange.Cleanup(cfg.Ecosystem.DataExchangeDir, retention, time.Now())
+	if err != nil {
+		logger.Error("Failed to clean up data exchange directory", zap.Error(err))
+	}
+	if len(removed) > 0 {
+		logger.Info("Cleaned up data exchange directory", zap.Int("removed", len(removed)))
+	}
+}
+
+// refreshEcosystem re-probes ASBA and ASBB once the cached detection is half way
+// to its TTL, so readers of the cache rarely find it expired and probe themselves
+func refreshEcosystem(ctx context.Context, cfg *config.Config) {
+	ttl := time.Duration(cfg.Ecosystem.DetectionTTLMinutes) * time.Minute
+	if dryRun || !cfg.Ecosystem.AutoDetect || cfg.Ecosystem.DataExchangeDir == "" || ttl <= 0 {
+		return
+	}
+
+	ecosystem.NewEcosystemDetector(logger).CachedEcosystem(ctx, cfg.Ecosystem.DataExchangeDir, ttl/2)
+}
+
+// syncPolicyIdentities re-reads the Slurm associations once the last sync is
+// policies.sync.interval_minutes old and resolves the policy rules' qos and
+// parent_accounts selectors for resume. A failed sync keeps the last one.
+func syncPolicyIdentities(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client) {
+	sync := &cfg.Policies.Sync
+	if dryRun || !sync.Enabled {
+		return
+	}
+
+	last, err := policysync.Load(sync.StateFile)
+	if err != nil {
+		logger.Warn("Ignoring unreadable policy sync state", zap.Error(err))
+	}
+	if !policysync.Due(last, sync, time.Now()) {
+		return
+	}
+	snapshot, err := policysync.Sync(ctx, cfg, slurmClient, time.Now())
+	if err != nil {
+		logger.Error("Failed to sync policy identities", zap.String("source", sync.Source), zap.Error(err))
+		return
+	}
+	logger.Info("Synced policy identities",
+		zap.String("source", sync.Source),
+		zap.Int("associations", snapshot.Associations),
+		zap.Int("rules_matched", len(snapshot.Rules)))
+}
+
+// replaceElasticNodes replaces the interrupted spot nodes of running elastic
+// MPI jobs and signals the jobs once the replacements have registered
+func replaceElasticNodes(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client) {
+	if !cfg.ElasticMPI.Enabled || dryRun {
+		return
+	}
+
+	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
+	if err != nil {
+		logger.Error("Failed to create AWS client", zap.Error(err))
+		return
+	}
+	if err := elastic.NewReplacer(logger, cfg, awsClient, slurmClient).Run(ctx); err != nil {
+		logger.Error("Failed to check elastic MPI jobs", zap.Error(err))
+	}
+}
+
+// trackBatchJobs follows the array jobs resume handed to AWS Batch and queues
+// those Batch has finished for the epilog exports, as the epilog does for jobs
+// that ran on burst nodes
+func trackBatchJobs(ctx context.Context, cfg *config.Config) {
+	if !cfg.BatchFallback.Enabled || dryRun {
+		return
+	}
+
+	batchClient, err := batch.NewClient(ctx, logger, cfg)
+	if err != nil {
+		logger.Error("Failed to create AWS Batch client", zap.Error(err))
+		return
+	}
+	finished, err := batchClient.Track(ctx)
+	if err != nil {
+		logger.Error("Failed to track AWS Batch jobs", zap.Error(err))
+	}
+	for _, record := range finished {
+		logger.Info("AWS Batch finished array job",
+			zap.String("array_job_id", record.ArrayJobID),
+			zap.String("batch_job_id", record.BatchJobID),
+			zap.String("status", record.Status),
+			zap.Int("succeeded", record.Succeeded),
+			zap.Int("failed", record.Failed),
+			zap.Float64("cost_usd", record.CostUSD))
+		if _, err := epilog.Enqueue(cfg.Epilog.QueueDir, &epilog.Request{
+			JobID:     record.ArrayJobID,
+			Partition: record.Partition,
+			Account:   record.Account,
+			QueuedAt:  time.Now(),
+		}); err != nil {
+			logger.Error("Failed to queue AWS Batch job for export", zap.String("array_job_id", record.ArrayJobID), zap.Error(err))
+		}
+	}
+}
+
+// processEpilogQueue exports the completed burst jobs queued by aws-slurm-burst-epilog.
+// It has its own time budget, always enough for one job's exports to time out,
+// so a hanging export uses up its attempts instead of stalling the queue.
+func processEpilogQueue(cfg *config.Config) {
+	if dryRun {
+		pending, err := epilog.Pending(cfg.Epilog.QueueDir)
+		if err == nil && len(pending) > 0 {
+			logger.Info("DRY RUN: Would export queued jobs", zap.Int("jobs", len(pending)))
+		}
+		return
+	}
+
+	budget := time.Duration(cfg.Epilog.TimeoutSeconds*len(cfg.Epilog.Formats))*time.Second + time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	result, err := epilog.NewProcessor(logger, cfg, configFile, epilog.ExecRunner).ProcessQueue(ctx)
+	if err != nil {
+		logger.Error("Failed to process epilog queue", zap.Error(err))
+		return
+	}
+	if len(result.Exported)+len(result.Retrying)+len(result.Failed) > 0 {
+		logger.Info("Processed epilog queue",
+			zap.Strings("exported", result.Exported),
+			zap.Strings("retrying", result.Retrying),
+			zap.Strings("failed", result.Failed))
+	}
+}
+
+// retryCapacityQueue retries the launches resume queued for lack of AWS capacity.
+// Nodes that get their instances are undrained so their job starts; nodes whose
+// launch runs out of time are marked down so Slurm requeues the job.
+func retryCapacityQueue(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client) {
+	if !cfg.CapacityQueue.Enabled {
+		return
+	}
+	queue := capacity.NewQueue(cfg.CapacityQueue)
+	if dryRun {
+		pending, err := queue.Pending()
+		if err == nil && len(pending) > 0 {
+			logger.Info("DRY RUN: Would retry launches waiting for capacity", zap.Int("launches", len(pending)))
+		}
+		return
+	}
+
+	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
+	if err != nil {
+		logger.Error("Failed to create AWS client", zap.Error(err))
+		return
+	}
+	result, err := queue.Retry(ctx, awsClient, slurmClient)
+	if err != nil {
+		logger.Error("Capacity queue retries incomplete", zap.Error(err))
+	}
+	if result.StandingsErr != nil {
+		logger.Warn("Failed to look up job standings for capacity arbitration", zap.Error(result.StandingsErr))
+	}
+
+	// Resume ran the pre_launch hooks before the launch first failed
+	lifecycleHooks := hooks.NewRunner(logger, cfg.Hooks)
+	for _, launched := range result.Launched {
+		_ = lifecycleHooks.Run(ctx, postLaunchPayload(launched.Request.Launch, launched.Result.Instances, ""))
+		nodes := launched.Request.Launch.NodeIds
+		if err := slurmClient.UpdateNodesWithInstanceInfo(ctx, launched.Result.Instances); err != nil {
+			logger.Error("Failed to update Slurm nodes", zap.Error(err))
+		}
+		if err := slurmClient.SetNodesState(nodes, "UNDRAIN", ""); err != nil {
+			logger.Error("Failed to undrain nodes after capacity wait", zap.String("nodes", slurm.CompressHostlist(nodes)), zap.Error(err))
+		}
+		logger.Info("Launch got AWS capacity",
+			zap.String("nodes", slurm.CompressHostlist(nodes)),
+			zap.Int("attempts", launched.Request.Attempts+1),
+			zap.Duration("waited", time.Since(launched.Request.QueuedAt)))
+		notifyCapacity(ctx, cfg, capacity.EventLaunched, launched.Request)
+	}
+	for _, expired := range result.Expired {
+		_ = lifecycleHooks.Run(ctx, postLaunchPayload(expired.Launch, nil, "gave up waiting for AWS capacity: "+expired.LastError))
+		nodes := expired.Launch.NodeIds
+		if err := slurmClient.SetNodesState(nodes, "DOWN", "aws_launch_failed"); err != nil {
+			logger.Error("Failed to mark nodes down after capacity wait", zap.String("nodes", slurm.CompressHostlist(nodes)), zap.Error(err))
+		}
+		logger.Warn("Launch gave up waiting for AWS capacity",
+			zap.String("nodes", slurm.CompressHostlist(nodes)),
+			zap.Int("attempts", expired.Attempts),
+			zap.String("last_error", expired.LastError))
+		notifyCapacity(ctx, cfg, capacity.EventExpired, expired)
+	}
+	// The node reasons show which launch a deferred one waits behind
+	for _, deferred := range result.Deferred {
+		nodes := deferred.Launch.NodeIds
+		if err := slurmClient.SetNodesState(nodes, "DRAIN", capacity.DeferredReason(deferred)); err != nil {
+			logger.Error("Failed to mark deferred nodes", zap.String("nodes", slurm.CompressHostlist(nodes)), zap.Error(err))
+		}
+		logger.Info("Launch deferred behind a higher-ranked launch waiting for vCPU quota",
+			zap.String("nodes", slurm.CompressHostlist(nodes)),
+			zap.String("job_id", deferred.JobID()),
+			zap.Int64("priority", deferred.Priority),
+			zap.Float64("fair_share", deferred.FairShare),
+			zap.String("behind", deferred.DeferredBehind))
+	}
+	for _, released := range result.Released {
+		nodes := released.Launch.NodeIds
+		if err := slurmClient.SetNodesState(nodes, "DRAIN", capacity.WaitingReason); err != nil {
+			logger.Error("Failed to mark released nodes", zap.String("nodes", slurm.CompressHostlist(nodes)), zap.Error(err))
+		}
+	}
+	if len(result.Waiting) > 0 {
+		logger.Info("Launches still waiting for AWS capacity", zap.Int("launches", len(result.Waiting)))
+	}
+}
+
+// postLaunchPayload describes a queued launch's outcome to the post_launch hooks
+func postLaunchPayload(launch *aws.LaunchRequest, instances []types.InstanceInfo, launchErr string) hooks.Payload {
+	payload := hooks.Payload{
+		Event:     config.HookEventPostLaunch,
+		Partition: launch.Partition,
+		NodeGroup: launch.NodeGroup,
+		Nodes:     launch.NodeIds,
+		Instances: instances,
+		Error:     launchErr,
+	}
+	if launch.Job != nil {
+		payload.JobID = launch.Job.JobID
+	}
+	return payload
+}
+
+// gateNodeHealth runs the health checks on the nodes resume drained for them.
+// Nodes that pass are undrained so their job starts; nodes still failing at
+// the timeout are terminated and marked down so Slurm requeues the job. The
+// checks have their own time budget, since they wait for SSM.
+func gateNodeHealth(cfg *config.Config, slurmClient *slurm.Client, nodeStates []slurm.NodeInfo) {
+	if !cfg.HealthCheck.Enabled {
+		return
+	}
+	var pending []string
+	for _, nodeInfo := range nodeStates {
+		if nodeInfo.Reason == healthcheck.PendingReason {
+			pending = append(pending, nodeInfo.NodeName)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	if dryRun {
+		logger.Info("DRY RUN: Would run health checks on new nodes", zap.String("nodes", slurm.CompressHostlist(pending)))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthcheck.CommandTimeout+30*time.Second)
+	defer cancel()
+	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
+	if err != nil {
+		logger.Error("Failed to create AWS client", zap.Error(err))
+		return
+	}
+	instances, err := awsClient.ListInstances(ctx)
+	if err != nil {
+		logger.Error("Failed to list instances for health checks", zap.Error(err))
+		return
+	}
+	nodes := ssm.NewNodes(logger, cfg, instances)
+	checked := make([]healthcheck.Node, 0, len(pending))
+	for _, node := range pending {
+		checkedNode := healthcheck.Node{Name: node}
+		if instance, ok := nodes.Instance(node); ok {
+			checkedNode.LaunchTime, _ = time.Parse(time.RFC3339, instance.LaunchTime)
+		}
+		checked = append(checked, checkedNode)
+	}
+
+	result, err := healthcheck.Check(ctx, nodes, &cfg.HealthCheck, checked, time.Now())
+	if err != nil {
+		logger.Error("Failed to run node health checks", zap.Error(err))
+		if result == nil {
+			return
+		}
+	}
+	passed, failed := result.Passed, result.Failed
+	for node, reason := range result.Waiting {
+		logger.Debug("Node health checks not passed yet", zap.String("node", node), zap.String("reason", reason))
+	}
+
+	if err := slurmClient.SetNodesState(passed, "UNDRAIN", ""); err != nil {
+		logger.Error("Failed to undrain nodes after health checks", zap.String("nodes", slurm.CompressHostlist(passed)), zap.Error(err))
+	} else if len(passed) > 0 {
+		logger.Info("Nodes passed health checks", zap.String("nodes", slurm.CompressHostlist(passed)))
+	}
+	if len(failed) == 0 {
+		return
+	}
+	failedNodes := slices.Sorted(maps.Keys(failed))
+	var bootFailures []bootdiagnostics.Failure
+	for _, node := range failedNodes {
+		logger.Warn("Node failed health checks, replacing it", zap.String("node", node), zap.String("reason", failed[node]))
+		if instance, ok := nodes.Instance(node); ok {
+			bootFailures = append(bootFailures, bootdiagnostics.Failure{Event: bootdiagnostics.EventHealthCheckFailed, Reason: failed[node], Instance: instance})
+		}
+	}
+	captureBootFailures(ctx, cfg, awsClient, bootFailures)
+	lifecycleHooks := hooks.NewRunner(logger, cfg.Hooks)
+	terminated := hooks.Payload{Event: config.HookEventPreTerminate, Nodes: failedNodes}
+	for _, failure := range bootFailures {
+		terminated.Instances = append(terminated.Instances, failure.Instance)
+	}
+	_ = lifecycleHooks.Run(ctx, terminated)
+	terminated.Event = config.HookEventPostTerminate
+	if err := awsClient.TerminateInstances(ctx, failedNodes); err != nil {
+		logger.Error("Failed to terminate nodes that failed health checks", zap.Error(err))
+		terminated.Error = err.Error()
+	}
+	_ = lifecycleHooks.Run(ctx, terminated)
+	if err := slurmClient.SetNodesState(failedNodes, "DOWN", healthcheck.FailedReason); err != nil {
+		logger.Error("Failed to mark nodes down after health checks", zap.String("nodes", slurm.CompressHostlist(failedNodes)), zap.Error(err))
+	}
+}
+
+// captureUnresponsiveNodes saves the console output of nodes that never
+// registered or stopped responding, while their instances still exist, and
+// removes records past their retention
+func captureUnresponsiveNodes(ctx context.Context, cfg *config.Config, nodeStates []slurm.NodeInfo) {
+	if !cfg.BootDiagnostics.Enabled {
+		return
+	}
+	retention := time.Duration(cfg.BootDiagnostics.RetentionDays) * 24 * time.Hour
+	if removed, err := bootdiagnostics.Cleanup(cfg.BootDiagnostics.Dir, retention, time.Now()); err != nil {
+		logger.Warn("Failed to clean up boot diagnostics", zap.Error(err))
+	} else if len(removed) > 0 {
+		logger.Info("Cleaned up boot diagnostics", zap.Int("removed", len(removed)))
+	}
+
+	unresponsive := slices.ContainsFunc(nodeStates, func(nodeInfo slurm.NodeInfo) bool {
+		_, ok := bootdiagnostics.Unresponsive(nodeInfo)
+		return ok
+	})
+	if !unresponsive {
+		return
+	}
+
+	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
+	if err != nil {
+		logger.Error("Failed to create AWS client", zap.Error(err))
+		return
+	}
+	instances, err := awsClient.ListInstances(ctx)
+	if err != nil {
+		logger.Error("Failed to list instances for boot diagnostics", zap.Error(err))
+		return
+	}
+	failures := bootdiagnostics.UnresponsiveFailures(nodeStates, instances)
+	captureBootFailures(ctx, cfg, awsClient, failures)
+}
+
+// captureBootFailures saves the console output of failed instances when boot
+// diagnostics are enabled
+func captureBootFailures(ctx context.Context, cfg *config.Config, awsClient *aws.Client, failures []bootdiagnostics.Failure) {
+	if !cfg.BootDiagnostics.Enabled || len(failures) == 0 {
+		return
+	}
+	if dryRun {
+		logger.Info("DRY RUN: Would capture console output of failed nodes", zap.Int("nodes", len(failures)))
+		return
+	}
+	written, err := bootdiagnostics.NewRecorder(&cfg.BootDiagnostics, awsClient).Capture(ctx, failures)
+	if err != nil {
+		logger.Warn("Boot diagnostics incomplete", zap.Error(err))
+	}
+	for _, path := range written {
+		logger.Info("Captured console output of failed node", zap.String("record", path))
+	}
+}
+
+// notifyCapacity runs the capacity queue's notify command for a launch
+func notifyCapacity(ctx context.Context, cfg *config.Config, event string, req *capacity.Request) {
+	if err := capacity.Notify(ctx, cfg.CapacityQueue.NotifyCommand, event, req); err != nil {
+		logger.Warn("Failed to notify of capacity wait", zap.String("event", event), zap.Error(err))
+	}
+}
+
+// applyScalingPolicies powers nodes up and down from queue depth for partitions
+// with a scaling policy
+func applyScalingPolicies(ctx context.Context, slurmClient *slurm.Client, cfg *config.Config) {
+	decisions, err := scaling.NewEngine(logger, slurmClient, cfg).Run(ctx, dryRun)
+	if err != nil {
+		logger.Error("Scaling policy evaluation incomplete", zap.Error(err))
+	}
+	for _, decision := range decisions {
+		logger.Info("Applied scaling policy",
+			zap.String("partition", decision.Partition),
+			zap.Int("pending_jobs", decision.PendingJobs),
+			zap.Int("active_nodes", decision.Active),
+			zap.Int("target_nodes", decision.Target),
+			zap.Strings("power_up", decision.PowerUp),
+			zap.Strings("power_down", decision.PowerDown),
+			zap.Bool("dry_run", dryRun))
+	}
+}
+
+// manageInstances maintains EC2 resources that outlive a single resume or suspend
+// (warm pools, stopped instances of suspended nodes and retained scratch
+// volumes) and reports burst status. Warm pools are not refilled while
+// stopped.
+func manageInstances(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client, stopped bool) {
+	warmPools, suspendModes, lingering := false, false, false
+	for _, partition := range cfg.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			warmPools = warmPools || nodeGroup.WarmPool.Enabled()
+			suspendModes = suspendModes || nodeGroup.KeepsStoppedInstances()
+			lingering = lingering || nodeGroup.Linger.Enabled()
+		}
+	}
+	if !warmPools && !suspendModes && !lingering && !cfg.Staging.Enabled && cfg.Status.OutputFile == "" {
+		return
+	}
+
+	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
+	if err != nil {
+		logger.Error("Failed to create AWS client", zap.Error(err))
+		return
+	}
+
+	if warmPools && !stopped {
+		manageWarmPools(ctx, awsClient)
+	}
+	if suspendModes {
+		reapSuspendedInstances(ctx, awsClient)
+	}
+	if lingering {
+		reapLingeringInstances(ctx, awsClient)
+	}
+	if cfg.Staging.Enabled {
+		expireScratchVolumes(ctx, awsClient)
+	}
+	if cfg.Status.OutputFile != "" {
+		writeStatus(ctx, cfg, slurmClient, awsClient)
+	}
+}
+
+// writeStatus writes the burst status document for portals such as Open OnDemand
+func writeStatus(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client, awsClient *aws.Client) {
+	doc := status.NewCollector(logger, cfg, slurmClient, awsClient).Collect(ctx, time.Now())
+	if dryRun {
+		logger.Info("DRY RUN: Would write burst status",
+			zap.String("file", cfg.Status.OutputFile),
+			zap.Int("burst_nodes", len(doc.BurstNodes)))
+		return
+	}
+	if err := status.WriteFile(doc, cfg.Status.OutputFile); err != nil {
+		logger.Error("Failed to write burst status", zap.Error(err))
+	}
+}
+
+// reapSuspendedInstances terminates stopped instances idle past their node group's limit
+func reapSuspendedInstances(ctx context.Context, awsClient *aws.Client) {
+	reaped, err := awsClient.ReapSuspendedInstances(ctx, dryRun)
+	if err != nil {
+		logger.Error("Suspended instance cleanup incomplete", zap.Error(err))
+	}
+	if len(reaped) > 0 {
+		logger.Info("Terminated idle suspended instances",
+			zap.Strings("instance_ids", reaped),
+			zap.Bool("dry_run", dryRun))
+	}
+}
+
+// reapLingeringInstances terminates lingering instances no resume claimed in time
+func reapLingeringInstances(ctx context.Context, awsClient *aws.Client) {
+	reaped, err := awsClient.ReapLingeringInstances(ctx, dryRun)
+	if err != nil {
+		logger.Error("Lingering instance cleanup incomplete", zap.Error(err))
+	}
+	if len(reaped) > 0 {
+		logger.Info("Terminated unclaimed lingering instances",
+			zap.Strings("instance_ids", reaped),
+			zap.Bool("dry_run", dryRun))
+	}
+}
+
+// expireScratchVolumes deletes retained scratch volumes past their retention
+func expireScratchVolumes(ctx context.Context, awsClient *aws.Client) {
+	expired, err := awsClient.ExpireScratchVolumes(ctx, time.Now(), dryRun)
+	if err != nil {
+		logger.Error("Scratch volume cleanup incomplete", zap.Error(err))
+	}
+	if len(expired) > 0 {
+		logger.Info("Deleted expired scratch volumes",
+			zap.Strings("volume_ids", expired),
+			zap.Bool("dry_run", dryRun))
+	}
+}
+
+// manageWarmPools replenishes node group warm pools and recycles aged instances
+func manageWarmPools(ctx context.Context, awsClient *aws.Client) {
+	reports, err := awsClient.ReplenishWarmPools(ctx, dryRun)
+	if err != nil {
+		logger.Error("Warm pool replenishment incomplete", zap.Error(err))
+	}
+	for _, report := range reports {
+		logger.Info("Warm pool replenished",
+			zap.String("pool", report.Pool),
+			zap.Int("ready", report.Ready),
+			zap.Int("warming", report.Warming),
+			zap.Int("stopped", report.Stopped),
+			zap.Int("recycled", report.Recycled),
+			zap.Int("launched", report.Launched))
+	}
+}
+
 func processNodeState(ctx context.Context, slurmClient *slurm.Client, nodeInfo slurm.NodeInfo) error {
+	// Nodes waiting for capacity or health checks are drained on purpose, and
+	// those of an emergency stop stay down until it is cleared
+	if capacity.IsWaitingReason(nodeInfo.Reason) || nodeInfo.Reason == healthcheck.PendingReason || nodeInfo.Reason == emergency.NodeReason {
+		return nil
+	}
+
 	// Parse node states (can be comma-separated like "IDLE+CLOUD+POWER")
 	states := parseNodeStates(nodeInfo.State)
 
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/asba"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ecosystem"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/status"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	configFile   string
+	outputFormat string
+	showAll      bool
+	logger       *zap.Logger
+)
+
+func main() {
+	var err error
+	logger, err = zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-status",
+		Short: "Summarize burst nodes, instances and jobs",
+		Long: `Join Slurm node states, running jobs and live EC2 instances into one view:
+node, state, instance ID, type, AZ, uptime, estimated cost so far and job.
+Powered-down nodes without an instance are hidden unless --all is given.`,
+		RunE: showStatus,
+	}
+
+	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+	rootCmd.Flags().StringVarP(&outputFormat, "output", "o", "table", "Output format (table, json)")
+	rootCmd.Flags().BoolVar(&showAll, "all", false, "Include powered-down nodes without an instance")
+
+	if err := rootCmd.Execute(); err != nil {
+		logger.Error("Command execution failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func showStatus(cmd *cobra.Command, args []string) error {
+	if outputFormat != "table" && outputFormat != "json" {
+		return fmt.Errorf("unsupported output format %q (use table or json)", outputFormat)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	slurmClient := slurm.NewClient(logger, &cfg.Slurm)
+	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+
+	nodeNames, err := status.ConfiguredNodes(cfg, slurmClient)
+	if err != nil {
+		return err
+	}
+	nodes, err := slurmClient.GetNodeState(nodeNames)
+	if err != nil {
+		return err
+	}
+
+	instances, err := awsClient.ListInstances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	var partitions []string
+	for _, partition := range cfg.Slurm.Partitions {
+		partitions = append(partitions, partition.PartitionName)
+	}
+	jobs, err := slurmClient.NodeJobs(ctx, partitions)
+	if err != nil {
+		// Instance JobID tags still identify the launching job
+		logger.Warn("Failed to read running jobs", zap.Error(err))
+	}
+
+	var instanceTypes []string
+	for _, instance := range instances {
+		instanceTypes = append(instanceTypes, instance.InstanceType)
+	}
+	pricing, err := awsClient.InstancePricing(ctx, instanceTypes)
+	if err != nil {
+		logger.Warn("Failed to price instances", zap.Error(err))
+	}
+
+	rows := status.BuildNodeRows(nodes, instances, jobs, pricing, time.Now(), showAll)
+
+	if outputFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+	if err := printTable(rows); err != nil {
+		return err
+	}
+
+	// Only the cache is read; the state manager keeps it current
+	if cfg.Ecosystem.DataExchangeDir != "" {
+		cached, err := ecosystem.LoadCache(cfg.Ecosystem.DataExchangeDir)
+		if err != nil {
+			logger.Warn("Failed to read ecosystem cache", zap.Error(err))
+		}
+		fmt.Printf("\nEcosystem: %s\n", ecosystemSummary(cached, time.Now()))
+
+		if availability, err := asba.LoadAvailability(cfg.Ecosystem.DataExchangeDir); err != nil {
+			logger.Warn("Failed to read ASBA availability", zap.Error(err))
+		} else if availability != nil {
+			fmt.Printf("ASBA calls: %s\n", asbaSummary(availability, time.Now()))
+		}
+	}
+	return nil
+}
+
+// asbaSummary describes the ASBA call counts and circuit breaker in one line
+func asbaSummary(availability *asba.Availability, now time.Time) string {
+	summary := fmt.Sprintf("%d made, %d failed, %d skipped", availability.Calls, availability.Failures, availability.Rejected)
+	if availability.Open(now) {
+		return summary + fmt.Sprintf("; circuit open until %s (%s)",
+			availability.OpenUntil.Local().Format("15:04"), availability.LastError)
+	}
+	return summary + "; circuit closed"
+}
+
+// ecosystemSummary describes the cached companion tool detection in one line
+func ecosystemSummary(cached *ecosystem.EcosystemStatus, now time.Time) string {
+	if cached == nil {
+		return "not detected yet"
+	}
+
+	asba := "ASBA not installed"
+	if cached.ASBA.Available {
+		asba = "ASBA " + strings.TrimSpace(cached.ASBA.Version)
+		if cached.ASBA.SupportsExecPlan {
+			asba += " (execution plans)"
+		}
+	}
+	asbb := "ASBB not installed"
+	if cached.ASBB.Available {
+		asbb = "ASBB " + strings.TrimSpace(cached.ASBB.Version)
+		if cached.ASBB.SupportsReconciliation {
+			asbb += " (reconciliation)"
+		}
+	}
+	return fmt.Sprintf("%s, %s; checked %s ago", asba, asbb, now.Sub(cached.DetectedAt).Truncate(time.Minute))
+}
+
+// printTable writes the rows as an aligned table with a cost total
+func printTable(rows []status.NodeRow) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NODE\tSTATE\tINSTANCE\tTYPE\tAZ\tUPTIME\tEST. COST\tJOB")
+
+	var total float64
+	for _, row := range rows {
+		total += row.EstimatedCostUSD
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.Node,
+			row.State,
+			orDash(row.InstanceID),
+			orDash(row.InstanceType),
+			orDash(row.AvailabilityZone),
+			formatUptime(row.UptimeSeconds),
+			formatCost(row.EstimatedCostUSD),
+			orDash(strings.Join(row.Jobs, ",")))
+	}
+	fmt.Fprintf(writer, "\t\t\t\t\t\t%s\t\n", formatCost(total))
+
+	return writer.Flush()
+}
+
+func formatUptime(seconds int64) string {
+	if seconds <= 0 {
+		return "-"
+	}
+	return (time.Duration(seconds) * time.Second).Truncate(time.Minute).String()
+}
+
+func formatCost(cost float64) string {
+	if cost <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
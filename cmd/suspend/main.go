@@ -4,11 +4,17 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/bootdiagnostics"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/hooks"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/hostsfile"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ssm"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -16,6 +22,7 @@ import (
 var (
 	configFile string
 	dryRun     bool
+	force      bool
 	logger     *zap.Logger
 )
 
@@ -35,13 +42,17 @@ func main() {
 	rootCmd := &cobra.Command{
 		Use:   "aws-slurm-burst-suspend [node-list]",
 		Short: "Suspend AWS instances for Slurm nodes",
-		Long:  `Suspend (terminate) AWS instances associated with Slurm nodes to save costs.`,
-		Args:  cobra.ExactArgs(1),
-		RunE:  suspendNodes,
+		Long: `Suspend AWS instances associated with Slurm nodes to save costs. Instances are
+terminated, or stopped/hibernated for node groups with suspend_mode stop or hibernate.
+Node groups with linger keep instances running briefly for the next resume to claim.
+Nodes are drained first so running job steps and epilogs can finish; --force skips the drain.`,
+		Args: cobra.ExactArgs(1),
+		RunE: suspendNodes,
 	}
 
 	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
 	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be done without actually doing it")
+	rootCmd.Flags().BoolVar(&force, "force", false, "Skip draining nodes and suspend immediately")
 
 	if err := rootCmd.Execute(); err != nil {
 		logger.Error("Command execution failed", zap.Error(err))
@@ -50,17 +61,23 @@ func main() {
 }
 
 func suspendNodes(cmd *cobra.Command, args []string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
 	// Load configuration
 	cfg, err := config.Load(configFile)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	// Leave room for the drain wait and shutdown hooks on top of the suspend itself
+	timeout := 5 * time.Minute
+	if cfg.Slurm.Drain.Enabled && !force {
+		timeout += time.Duration(cfg.Slurm.Drain.TimeoutSeconds+cfg.Slurm.Drain.HookTimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
 	// Initialize components
 	slurmClient := slurm.NewClient(logger, &cfg.Slurm)
+	slurmClient.InjectFailures(cfg.FailureInjection)
 	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create AWS client: %w", err)
@@ -76,63 +93,315 @@ func suspendNodes(cmd *cobra.Command, args []string) error {
 	logger.Info("Suspend request received",
 		zap.String("nodes", nodeList),
 		zap.Int("node_count", len(nodes)),
-		zap.Bool("dry_run", dryRun))
+		zap.Bool("dry_run", dryRun),
+		zap.Bool("force", force))
 
 	if dryRun {
-		logger.Info("DRY RUN: Would terminate instances for nodes", zap.Strings("nodes", nodes))
+		if cfg.Slurm.Drain.Enabled && !force {
+			logger.Info("DRY RUN: Would drain nodes before suspending",
+				zap.Strings("nodes", nodes),
+				zap.Int("timeout_seconds", cfg.Slurm.Drain.TimeoutSeconds),
+				zap.String("shutdown_hook", cfg.Slurm.Drain.ShutdownHook))
+		}
+		logger.Info("DRY RUN: Would suspend instances for nodes", zap.Strings("nodes", nodes))
 		return nil
 	}
 
-	// Group nodes by partition and node group
-	nodeGroups := slurmClient.ParseNodeNames(nodes)
+	// The console output of nodes that never came up is lost with their instances
+	captureUnresponsiveNodes(ctx, cfg, slurmClient, awsClient, nodes)
 
-	for partition, nodesByGroup := range nodeGroups {
-		for nodeGroup, nodeIds := range nodesByGroup {
-			if err := suspendNodeGroup(ctx, awsClient, partition, nodeGroup, nodeIds); err != nil {
-				logger.Error("Failed to suspend node group",
-					zap.String("partition", partition),
-					zap.String("node_group", nodeGroup),
-					zap.Strings("nodes", nodeIds),
-					zap.Error(err))
-				// Continue with other node groups
-			}
+	// Drain first so running job steps and epilogs finish before instances go away
+	var drained []string
+	if cfg.Slurm.Drain.Enabled && !force {
+		drained = drainNodes(ctx, cfg, slurmClient, awsClient, nodes)
+	}
+
+	// Suspend every node group at once; terminations are batched per region
+	suspends := suspendsByNodeGroup(slurmClient, nodes)
+	lifecycleHooks := hooks.NewRunner(logger, cfg.Hooks)
+	terminations := terminationPayloads(ctx, lifecycleHooks, awsClient, suspends)
+	for _, payload := range terminations {
+		_ = lifecycleHooks.Run(ctx, payload)
+	}
+	suspendErr := awsClient.SuspendNodeGroups(ctx, lingerNodes(ctx, cfg, awsClient, suspends))
+	for _, payload := range terminations {
+		payload.Event = config.HookEventPostTerminate
+		if suspendErr != nil {
+			payload.Error = suspendErr.Error()
+		}
+		_ = lifecycleHooks.Run(ctx, payload)
+	}
+	if suspendErr != nil {
+		// Failed node groups do not hold up the others or the Slurm cleanup below
+		logger.Error("Failed to suspend some node groups", zap.Error(suspendErr))
+	} else {
+		logger.Info("Successfully initiated instance suspension",
+			zap.Int("node_groups", len(suspends)),
+			zap.Int("node_count", len(nodes)))
+	}
+
+	// Stale records would resolve to whatever instance gets the address next
+	if err := deregisterNodeRecords(ctx, cfg, nodes); err != nil {
+		logger.Warn("Failed to remove burst nodes from DNS", zap.Error(err))
+	}
+	if cfg.HostsFile.Enabled {
+		if err := hostsfile.New(cfg.HostsFile.Path).Remove(nodes); err != nil {
+			logger.Warn("Failed to remove burst nodes from the hosts file", zap.Error(err))
+		}
+	}
+
+	// Clear our drain so Slurm can power the nodes up again for new jobs
+	if len(drained) > 0 {
+		if err := slurmClient.UndrainNodes(ctx, drained); err != nil {
+			logger.Warn("Failed to undrain suspended nodes", zap.Error(err))
+		}
+	}
+
+	// Drop placement metadata so the next job on these nodes is not linked to gone instances
+	if err := slurmClient.ClearNodePlacement(ctx, nodes); err != nil {
+		logger.Warn("Failed to clear node placement", zap.Error(err))
+	}
+
+	// Drop AZ features so powered-down nodes are not scheduled for a zone they may not return to
+	if cfg.Slurm.AZNodeFeatures {
+		if err := slurmClient.ClearNodeAvailabilityZones(ctx, nodes); err != nil {
+			logger.Warn("Failed to clear node availability zone features", zap.Error(err))
 		}
 	}
 
 	return nil
 }
 
-func suspendNodeGroup(ctx context.Context, awsClient *aws.Client, partition, nodeGroup string, nodeIds []string) error {
-	logger.Info("Suspending node group",
-		zap.String("partition", partition),
-		zap.String("node_group", nodeGroup),
-		zap.Strings("nodes", nodeIds),
-		zap.Int("count", len(nodeIds)))
+// captureUnresponsiveNodes saves the console output of the nodes Slurm is
+// powering down because they never registered or stopped responding
+func captureUnresponsiveNodes(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client, awsClient *aws.Client, nodes []string) {
+	if !cfg.BootDiagnostics.Enabled {
+		return
+	}
+	nodeStates, err := slurmClient.GetNodeState(nodes)
+	if err != nil {
+		logger.Warn("Failed to get node states for boot diagnostics", zap.Error(err))
+		return
+	}
+	unresponsive := slices.ContainsFunc(nodeStates, func(nodeInfo slurm.NodeInfo) bool {
+		_, ok := bootdiagnostics.Unresponsive(nodeInfo)
+		return ok
+	})
+	if !unresponsive {
+		return
+	}
 
-	if dryRun {
-		logger.Info("DRY RUN: Would terminate instances for nodes",
-			zap.String("partition", partition),
-			zap.String("node_group", nodeGroup),
-			zap.Strings("node_ids", nodeIds))
+	instances, err := awsClient.ListInstances(ctx)
+	if err != nil {
+		logger.Warn("Failed to list instances for boot diagnostics", zap.Error(err))
+		return
+	}
+	failures := bootdiagnostics.UnresponsiveFailures(nodeStates, instances)
+	written, err := bootdiagnostics.NewRecorder(&cfg.BootDiagnostics, awsClient).Capture(ctx, failures)
+	if err != nil {
+		logger.Warn("Boot diagnostics incomplete", zap.Error(err))
+	}
+	for _, path := range written {
+		logger.Info("Captured console output of failed node", zap.String("record", path))
+	}
+}
+
+// terminationPayloads describes each node group's power down to the
+// pre_terminate and post_terminate hooks, with the instances going away. It
+// returns nothing when no hook runs at either event.
+func terminationPayloads(ctx context.Context, lifecycleHooks *hooks.Runner, awsClient *aws.Client, suspends []aws.NodeGroupSuspend) []hooks.Payload {
+	if !lifecycleHooks.Handles(config.HookEventPreTerminate) && !lifecycleHooks.Handles(config.HookEventPostTerminate) {
 		return nil
 	}
+	instances, err := awsClient.ListInstances(ctx)
+	if err != nil {
+		logger.Warn("Failed to list instances for terminate hooks", zap.Error(err))
+	}
 
-	// Generate full node names for termination
-	var nodeNames []string
-	for _, nodeId := range nodeIds {
-		nodeName := fmt.Sprintf("%s-%s-%s", partition, nodeGroup, nodeId)
-		nodeNames = append(nodeNames, nodeName)
+	payloads := make([]hooks.Payload, 0, len(suspends))
+	for _, suspend := range suspends {
+		payload := hooks.Payload{
+			Event:     config.HookEventPreTerminate,
+			Partition: suspend.Partition,
+			NodeGroup: suspend.NodeGroup,
+			Nodes:     suspend.NodeNames,
+		}
+		for _, instance := range instances {
+			if slices.Contains(suspend.NodeNames, instance.NodeName) && instance.State != "shutting-down" && instance.State != "terminated" {
+				payload.Instances = append(payload.Instances, instance)
+			}
+		}
+		payloads = append(payloads, payload)
 	}
+	return payloads
+}
 
-	// Terminate instances
-	if err := awsClient.TerminateInstances(ctx, nodeNames); err != nil {
-		return fmt.Errorf("failed to terminate instances: %w", err)
+// lingerNodes stops slurmd on the nodes of node groups with linger and moves
+// their instances into the node group's linger pool. It returns what is left
+// to power down as usual: other node groups, and the nodes whose slurmd did not
+// stop cleanly or whose instance could not linger.
+func lingerNodes(ctx context.Context, cfg *config.Config, awsClient *aws.Client, suspends []aws.NodeGroupSuspend) []aws.NodeGroupSuspend {
+	var lingering, remaining []aws.NodeGroupSuspend
+	for _, suspend := range suspends {
+		if nodeGroup := cfg.FindNodeGroup(suspend.Partition, suspend.NodeGroup); nodeGroup != nil && nodeGroup.Linger.Enabled() {
+			lingering = append(lingering, suspend)
+		} else {
+			remaining = append(remaining, suspend)
+		}
+	}
+	if len(lingering) == 0 {
+		return suspends
 	}
 
-	logger.Info("Successfully initiated instance termination",
-		zap.String("partition", partition),
-		zap.String("node_group", nodeGroup),
-		zap.Strings("node_names", nodeNames))
+	instances, err := awsClient.ListInstances(ctx)
+	if err != nil {
+		logger.Warn("Failed to list instances to linger, terminating them", zap.Error(err))
+		return suspends
+	}
+	nodes := ssm.NewNodes(logger, cfg, instances)
 
-	return nil
+	for _, suspend := range lingering {
+		results, err := nodes.Run(ctx, suspend.NodeNames, ssm.Command{
+			Script:  ssm.LingerStopScript(),
+			Comment: "aws-slurm-burst linger",
+			Timeout: time.Minute,
+		})
+		if err != nil {
+			logger.Warn("Failed to stop slurmd through SSM", zap.Error(err))
+		}
+
+		var stopped, terminate []string
+		for _, node := range suspend.NodeNames {
+			if results[node].Status == "Success" {
+				stopped = append(stopped, node)
+			} else {
+				terminate = append(terminate, node)
+			}
+		}
+
+		if len(stopped) > 0 {
+			missing, err := awsClient.LingerInstances(ctx, suspend.Partition, suspend.NodeGroup, stopped)
+			if err != nil {
+				logger.Warn("Failed to linger instances, terminating them", zap.Strings("nodes", stopped), zap.Error(err))
+				terminate = append(terminate, stopped...)
+			} else {
+				terminate = append(terminate, missing...)
+				logger.Info("Instances lingering for the next resume",
+					zap.String("partition", suspend.Partition),
+					zap.String("node_group", suspend.NodeGroup),
+					zap.Int("count", len(stopped)-len(missing)))
+			}
+		}
+
+		if len(terminate) > 0 {
+			suspend.NodeNames = terminate
+			remaining = append(remaining, suspend)
+		}
+	}
+	return remaining
+}
+
+// deregisterNodeRecords deletes the nodes' DNS records when dns is configured
+func deregisterNodeRecords(ctx context.Context, cfg *config.Config, nodes []string) error {
+	if !cfg.DNS.Enabled() {
+		return nil
+	}
+	registrar, err := aws.NewDNSRegistrar(ctx, logger, &cfg.AWS, &cfg.DNS)
+	if err != nil {
+		return err
+	}
+	return registrar.Deregister(ctx, nodes)
+}
+
+// drainNodes drains the nodes, waits for their running work up to the drain timeout
+// and runs the shutdown hook on each. Failures are logged and do not block suspend.
+// It returns the nodes it drained.
+func drainNodes(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client, awsClient *aws.Client, nodes []string) []string {
+	drain := &cfg.Slurm.Drain
+	drained, err := slurmClient.DrainNodes(ctx, nodes)
+	if err != nil {
+		logger.Warn("Failed to drain nodes", zap.Error(err))
+	}
+
+	busy, err := slurmClient.WaitForNodesIdle(ctx, nodes, time.Duration(drain.TimeoutSeconds)*time.Second)
+	if err != nil {
+		logger.Warn("Failed to check nodes for running work", zap.Error(err))
+	} else if len(busy) > 0 {
+		logger.Warn("Drain timed out, suspending nodes with running work",
+			zap.Strings("nodes", busy),
+			zap.Int("timeout_seconds", drain.TimeoutSeconds))
+	}
+
+	if drain.ShutdownHook != "" && drain.HookTransport == config.HookTransportSSM {
+		runShutdownHookSSM(ctx, cfg, awsClient, nodes)
+	} else if drain.ShutdownHook != "" {
+		hookTimeout := time.Duration(drain.HookTimeoutSeconds) * time.Second
+		var wg sync.WaitGroup
+		for _, node := range nodes {
+			wg.Add(1)
+			go func(node string) {
+				defer wg.Done()
+				if err := slurmClient.RunShutdownHook(ctx, node, drain.ShutdownHook, hookTimeout); err != nil {
+					logger.Warn("Shutdown hook failed", zap.String("node", node), zap.Error(err))
+				}
+			}(node)
+		}
+		wg.Wait()
+	}
+
+	return drained
+}
+
+// runShutdownHookSSM runs the shutdown hook on the nodes through SSM Run Command
+func runShutdownHookSSM(ctx context.Context, cfg *config.Config, awsClient *aws.Client, nodes []string) {
+	hookTimeout := time.Duration(cfg.Slurm.Drain.HookTimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout+30*time.Second)
+	defer cancel()
+
+	instances, err := awsClient.ListInstances(ctx)
+	if err != nil {
+		logger.Warn("Failed to list instances for the shutdown hook", zap.Error(err))
+		return
+	}
+	results, err := ssm.NewNodes(logger, cfg, instances).Run(ctx, nodes, ssm.Command{
+		Script:  cfg.Slurm.Drain.ShutdownHook,
+		Comment: "aws-slurm-burst shutdown hook",
+		Timeout: hookTimeout,
+	})
+	if err != nil {
+		logger.Warn("Failed to run shutdown hook through SSM", zap.Error(err))
+	}
+	for _, node := range nodes {
+		result, ok := results[node]
+		switch {
+		case !ok:
+			continue
+		case result.Status == "Success":
+			logger.Info("Ran shutdown hook", zap.String("node", node), zap.String("hook", cfg.Slurm.Drain.ShutdownHook))
+		default:
+			logger.Warn("Shutdown hook failed", zap.String("node", node), zap.String("status", result.Status), zap.String("output", result.Output))
+		}
+	}
+}
+
+// suspendsByNodeGroup groups nodes by partition and node group, skipping names
+// that follow neither
+func suspendsByNodeGroup(slurmClient *slurm.Client, nodes []string) []aws.NodeGroupSuspend {
+	var suspends []aws.NodeGroupSuspend
+	for partition, nodesByGroup := range slurmClient.ParseNodeNames(nodes) {
+		for nodeGroup, nodeIds := range nodesByGroup {
+			suspend := aws.NodeGroupSuspend{Partition: partition, NodeGroup: nodeGroup}
+			for _, nodeId := range nodeIds {
+				suspend.NodeNames = append(suspend.NodeNames, slurmClient.NodeName(partition, nodeGroup, nodeId))
+			}
+
+			logger.Info("Suspending node group",
+				zap.String("partition", partition),
+				zap.String("node_group", nodeGroup),
+				zap.Strings("nodes", suspend.NodeNames),
+				zap.Int("count", len(suspend.NodeNames)))
+			suspends = append(suspends, suspend)
+		}
+	}
+	return suspends
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/amitest"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	configFile string
+	nodeGroup  string
+	partition  string
+	timeout    time.Duration
+	keep       bool
+	format     string
+	logger     *zap.Logger
+)
+
+func main() {
+	var err error
+	logger, err = zap.NewProduction()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if syncErr := logger.Sync(); syncErr != nil {
+			fmt.Printf("Warning: failed to sync logger: %v\n", syncErr)
+		}
+	}()
+
+	rootCmd := &cobra.Command{
+		Use:   "aws-slurm-burst-test-ami",
+		Short: "Smoke-test a node group's AMI on one instance",
+		Long: `Launch one instance from a node group's launch template, wait for its SSM
+agent, check that cloud-init finished the bootstrap, that slurmd is installed,
+that munge works when munge_key is configured and that the health checks pass,
+then terminate the instance. Each step is reported with its timing, so a new
+AMI can be validated before production bursts launch it.
+
+The instance is named <partition>-<node group>-amitest and tagged JobID
+ami-test. It is never taken from the warm pool or suspended instances. The
+command exits non-zero when any step fails.`,
+		Args: cobra.NoArgs,
+		RunE: testAMI,
+	}
+
+	rootCmd.Flags().StringVarP(&configFile, "config", "c", "/etc/slurm/aws-burst.yaml", "Configuration file path")
+	rootCmd.Flags().StringVar(&nodeGroup, "node-group", "", "Node group whose AMI to test")
+	rootCmd.Flags().StringVar(&partition, "partition", "", "Partition of the node group, when several have one of that name")
+	rootCmd.Flags().DurationVar(&timeout, "timeout", 20*time.Minute, "How long the instance may take to pass")
+	rootCmd.Flags().BoolVar(&keep, "keep", false, "Leave the instance running for inspection")
+	rootCmd.Flags().StringVar(&format, "format", "text", "Output format (text, json)")
+	if err := rootCmd.MarkFlagRequired("node-group"); err != nil {
+		logger.Fatal("Failed to mark flag required", zap.Error(err))
+	}
+
+	if err := rootCmd.Execute(); err != nil {
+		logger.Error("Command execution failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func testAMI(cmd *cobra.Command, args []string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unsupported format %q (use text or json)", format)
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	testPartition, err := amitest.FindNodeGroup(cfg, partition, nodeGroup)
+	if err != nil {
+		return err
+	}
+	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create AWS client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	report := amitest.NewTester(logger, cfg, awsClient).Run(ctx, testPartition, nodeGroup, keep)
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = amitest.Render(os.Stdout, report)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.Passed {
+		return fmt.Errorf("AMI test of %s/%s failed", testPartition, nodeGroup)
+	}
+	return nil
+}
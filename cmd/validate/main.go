@@ -5,10 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
+	"time"
 
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/doctor"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/ecosystem"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/planlint"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
 	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -38,8 +43,10 @@ for correctness and completeness before deployment.`,
 
 	// Add subcommands
 	rootCmd.AddCommand(configCmd())
+	rootCmd.AddCommand(fragmentCmd())
 	rootCmd.AddCommand(executionPlanCmd())
 	rootCmd.AddCommand(integrationCmd())
+	rootCmd.AddCommand(exchangeCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		logger.Error("Validation failed", zap.Error(err))
@@ -68,21 +75,65 @@ func configCmd() *cobra.Command {
 				return fmt.Errorf("configuration incomplete: %w", err)
 			}
 
+			included, err := cfg.IncludedFiles(configFile)
+			if err != nil {
+				return err
+			}
+
+			warnSlurmVersion(cfg)
+
 			logger.Info("✅ Configuration file is valid",
 				zap.String("file", configFile),
 				zap.String("aws_region", cfg.AWS.Region),
-				zap.Int("partition_count", len(cfg.Slurm.Partitions)))
+				zap.Int("partition_count", len(cfg.Slurm.Partitions)),
+				zap.Strings("included", included))
 
 			return nil
 		},
 	}
 }
 
-func executionPlanCmd() *cobra.Command {
+func fragmentCmd() *cobra.Command {
 	return &cobra.Command{
+		Use:   "fragment [fragment-file]",
+		Short: "Validate a partition fragment on its own",
+		Long: `Validate a fragment of partitions and node groups included by the main
+configuration, without the main configuration. Conflicts with other files
+are only detected by validating the main configuration.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fragmentFile := args[0]
+
+			fragment, err := config.LoadFragment(fragmentFile)
+			if err != nil {
+				return fmt.Errorf("fragment validation failed: %w", err)
+			}
+
+			for _, partition := range fragment.Partitions {
+				var nodeGroups []string
+				for _, nodeGroup := range partition.NodeGroups {
+					nodeGroups = append(nodeGroups, nodeGroup.NodeGroupName)
+				}
+				logger.Info("✅ Fragment partition is valid",
+					zap.String("file", fragmentFile),
+					zap.String("partition", partition.PartitionName),
+					zap.Strings("node_groups", nodeGroups))
+			}
+			return nil
+		},
+	}
+}
+
+func executionPlanCmd() *cobra.Command {
+	var strict bool
+	var nodeCount int
+	cmd := &cobra.Command{
 		Use:   "execution-plan [plan-file]",
 		Short: "Validate ASBA execution plan JSON file",
-		Args:  cobra.ExactArgs(1),
+		Long: `Validate an ASBA execution plan, then lint it for valid but risky
+settings such as large gang-scheduled spot jobs or missing cost ceilings.
+Lint findings are reported without failing unless --strict is set.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			planFile := args[0]
 
@@ -99,6 +150,15 @@ func executionPlanCmd() *cobra.Command {
 				return fmt.Errorf("failed to parse execution plan JSON: %w", err)
 			}
 
+			// Check the schema first so an incompatible plan is reported as such
+			compat, err := ecosystem.NegotiatePlan(&plan)
+			if err != nil {
+				return fmt.Errorf("execution plan is incompatible: %w", err)
+			}
+			for _, warning := range compat.Warnings {
+				logger.Warn(warning)
+			}
+
 			// Validate execution plan
 			if err := plan.ValidateExecutionPlan(); err != nil {
 				return fmt.Errorf("execution plan validation failed: %w", err)
@@ -109,27 +169,57 @@ func executionPlanCmd() *cobra.Command {
 				return fmt.Errorf("execution plan incomplete: %w", err)
 			}
 
+			findings := planlint.Lint(&plan, planlint.Options{NodeCount: nodeCount})
+			for _, finding := range findings {
+				fields := []zap.Field{zap.String("rule", finding.Rule), zap.String("severity", string(finding.Severity))}
+				switch finding.Severity {
+				case planlint.SeverityError:
+					logger.Error(finding.Message, fields...)
+				case planlint.SeverityWarning:
+					logger.Warn(finding.Message, fields...)
+				default:
+					logger.Info(finding.Message, fields...)
+				}
+			}
+			if strict && planlint.Fails(findings, planlint.SeverityWarning) {
+				return fmt.Errorf("execution plan has %d lint finding(s) and --strict is set", len(findings))
+			}
+
 			logger.Info("✅ Execution plan is valid",
 				zap.String("file", planFile),
+				zap.String("schema", compat.Schema),
 				zap.Bool("should_burst", plan.ShouldBurst),
 				zap.Strings("instance_types", plan.InstanceSpec.InstanceTypes),
-				zap.Bool("mpi_job", plan.MPIConfig.IsMPIJob))
+				zap.Bool("mpi_job", plan.MPIConfig.IsMPIJob),
+				zap.Int("lint_findings", len(findings)))
 
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&strict, "strict", false, "Fail on lint warnings and errors")
+	cmd.Flags().IntVar(&nodeCount, "nodes", 0, "Nodes the plan will launch, for rules that depend on job size (default: from node roles)")
+	return cmd
 }
 
 func integrationCmd() *cobra.Command {
-	return &cobra.Command{
+	var configFile, planFile, partition string
+	var offline bool
+	cmd := &cobra.Command{
 		Use:   "integration",
-		Short: "Validate integration and check ecosystem status",
+		Short: "Validate an execution plan against the configuration and check ecosystem status",
+		Long: `Cross-validate an ASBA execution plan against the configuration: the
+subnets, security groups and launch template it names must be configured for
+a node group or exist in AWS, its instance types must be offered in the node
+groups' regions, and its MPI and EFA settings must be consistent. Without
+--plan only the ecosystem status is reported.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			logger.Info("Running integration validation tests")
-
-			// Test basic functionality
-			if err := validateBasicIntegration(); err != nil {
-				return fmt.Errorf("integration validation failed: %w", err)
+			if planFile != "" {
+				if configFile == "" {
+					return fmt.Errorf("--plan needs --config")
+				}
+				if err := validatePlanAgainstConfig(cmd.Context(), configFile, planFile, partition, offline); err != nil {
+					return err
+				}
 			}
 
 			// Check ecosystem status
@@ -142,6 +232,93 @@ func integrationCmd() *cobra.Command {
 			return nil
 		},
 	}
+	cmd.Flags().StringVar(&configFile, "config", "", "Configuration file")
+	cmd.Flags().StringVar(&planFile, "plan", "", "Execution plan JSON file to check against the configuration")
+	cmd.Flags().StringVar(&partition, "partition", "", "Only consider this partition's node groups")
+	cmd.Flags().BoolVar(&offline, "offline", false, "Skip the checks that query AWS")
+	return cmd
+}
+
+// validatePlanAgainstConfig runs the execution plan checks and prints their report
+func validatePlanAgainstConfig(ctx context.Context, configFile, planFile, partition string, offline bool) error {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("configuration validation failed: %w", err)
+	}
+	data, err := os.ReadFile(planFile)
+	if err != nil {
+		return fmt.Errorf("failed to read execution plan: %w", err)
+	}
+	var plan types.ExecutionPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("failed to parse execution plan JSON: %w", err)
+	}
+
+	var connect func(ctx context.Context) (doctor.AWSProbe, error)
+	if !offline {
+		connect = func(ctx context.Context) (doctor.AWSProbe, error) {
+			return aws.NewDiagnostics(ctx, logger, &cfg.AWS)
+		}
+	}
+
+	report := doctor.Run(ctx, doctor.PlanChecks(cfg, &plan, partition, connect), 30*time.Second)
+	if err := doctor.WriteText(os.Stdout, report); err != nil {
+		return err
+	}
+	if !report.Healthy {
+		return fmt.Errorf("execution plan %s does not match %s: %d problem(s)", planFile, configFile, report.Failed)
+	}
+	return nil
+}
+
+func exchangeCmd() *cobra.Command {
+	var initLayout bool
+	cmd := &cobra.Command{
+		Use:   "exchange [config-file]",
+		Short: "Check the ecosystem data exchange directory",
+		Long: `Check that ecosystem.data_exchange_dir has the plans, results, records,
+locks and tmp directories and that files can be written and renamed into them.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(args[0])
+			if err != nil {
+				return fmt.Errorf("configuration validation failed: %w", err)
+			}
+			dir := cfg.Ecosystem.DataExchangeDir
+
+			if initLayout {
+				if err := exchange.Init(dir); err != nil {
+					return err
+				}
+			}
+
+			problems := exchange.Check(dir)
+			for _, problem := range problems {
+				logger.Error("Data exchange directory problem", zap.Error(problem))
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("data exchange directory %s has %d problem(s)", dir, len(problems))
+			}
+
+			logger.Info("✅ Data exchange directory is usable", zap.String("dir", dir))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&initLayout, "init", false, "Create the directory layout before checking")
+	return cmd
+}
+
+// warnSlurmVersion logs what the installed Slurm release lacks. Hosts without
+// Slurm, e.g. where configurations are prepared, are skipped.
+func warnSlurmVersion(cfg *config.Config) {
+	version, err := slurm.NewClient(logger, &cfg.Slurm).Version()
+	if err != nil {
+		logger.Debug("Skipping Slurm release checks", zap.Error(err))
+		return
+	}
+	for _, warning := range slurm.VersionWarnings(version, "") {
+		logger.Warn(warning, zap.String("slurm_version", version.String()))
+	}
 }
 
 // validateConfigCompleteness performs additional configuration validation
@@ -206,26 +383,6 @@ func validateExecutionPlanCompleteness(plan *types.ExecutionPlan) error {
 	return nil
 }
 
-// validateBasicIntegration tests basic integration scenarios
-func validateBasicIntegration() error {
-	// Test that we can parse node lists correctly
-	testNodeLists := []string{
-		"aws-cpu-001",
-		"aws-gpu-[001-004]",
-		"aws-hpc-[001-008]",
-	}
-
-	for _, nodeList := range testNodeLists {
-		parts := strings.Split(nodeList, "-")
-		if len(parts) < 2 {
-			return fmt.Errorf("failed to parse node list: %s", nodeList)
-		}
-	}
-
-	logger.Info("Basic integration tests passed")
-	return nil
-}
-
 // validateEcosystemStatus checks ecosystem companion tool availability
 func validateEcosystemStatus() error {
 	detector := ecosystem.NewEcosystemDetector(logger)
@@ -239,7 +396,11 @@ func validateEcosystemStatus() error {
 			zap.String("command", status.ASBA.Command),
 			zap.String("version", status.ASBA.Version),
 			zap.Bool("execution_plan_support", status.ASBA.SupportsExecPlan),
-			zap.Bool("burst_command_support", status.ASBA.SupportsBurst))
+			zap.Bool("burst_command_support", status.ASBA.SupportsBurst),
+			zap.String("plan_schema", status.ASBA.PlanSchema))
+		if status.ASBA.CompatibilityErr != "" {
+			logger.Warn("❌ ASBA plans cannot be executed", zap.String("reason", status.ASBA.CompatibilityErr))
+		}
 	} else {
 		logger.Info("⚠️  ASBA (Intelligence) Not Found - Operating in standalone mode")
 		logger.Info("   Install ASBA for intelligent job analysis: https://github.com/scttfrdmn/aws-slurm-burst-advisor")
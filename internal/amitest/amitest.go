@@ -0,0 +1,356 @@
+// Package amitest smoke-tests the AMI of a node group before production
+// bursts use it: it launches one instance from the node group's launch
+// template, waits for its SSM agent, runs the bootstrap and health checks on it
+// through SSM Run Command and terminates it, timing each step.
+package amitest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/healthcheck"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ssm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// JobID is the JobID tag of test instances, to find any left running
+const JobID = "ami-test"
+
+// NodeID names the test node within its node group, e.g. aws-gpu-amitest
+const NodeID = "amitest"
+
+// Steps besides the checks, in the order they run
+const (
+	StepLaunch    = "launch"
+	StepSSMAgent  = "ssm_agent"
+	StepBootstrap = "bootstrap" // cloud-init finished without errors
+	StepSlurm     = "slurm"     // slurmd is installed
+	StepMunge     = "munge"     // munge encodes and decodes a credential
+	StepTerminate = "terminate"
+)
+
+// terminateTimeout bounds the termination, which runs after the test's
+// context may have run out
+const terminateTimeout = 2 * time.Minute
+
+// Launcher launches and terminates the test instance; aws.Client in production
+type Launcher interface {
+	LaunchInstances(ctx context.Context, req *aws.LaunchRequest) (*aws.LaunchResult, error)
+	TerminateNodeGroupInstances(ctx context.Context, partition, nodeGroup string, nodeNames []string) error
+}
+
+// Step is the outcome of one step of the test
+type Step struct {
+	Name    string  `json:"name"`
+	Passed  bool    `json:"passed"`
+	Seconds float64 `json:"seconds"`
+	Detail  string  `json:"detail,omitempty"` // Why it failed, or what it found
+}
+
+// Report is the outcome of testing a node group's AMI
+type Report struct {
+	Partition    string  `json:"partition"`
+	NodeGroup    string  `json:"node_group"`
+	Node         string  `json:"node"`
+	InstanceID   string  `json:"instance_id,omitempty"`
+	InstanceType string  `json:"instance_type,omitempty"`
+	Steps        []Step  `json:"steps"`
+	Passed       bool    `json:"passed"`
+	Seconds      float64 `json:"seconds"`
+}
+
+// Tester runs the smoke test
+type Tester struct {
+	logger   *zap.Logger
+	config   *config.Config
+	launcher Launcher
+
+	newRunner    func(instances []types.InstanceInfo) healthcheck.Runner
+	pollInterval time.Duration // Between checks for the SSM agent
+}
+
+// NewTester creates a tester launching through launcher and running commands
+// through SSM
+func NewTester(logger *zap.Logger, cfg *config.Config, launcher Launcher) *Tester {
+	return &Tester{
+		logger:   logger,
+		config:   cfg,
+		launcher: launcher,
+		newRunner: func(instances []types.InstanceInfo) healthcheck.Runner {
+			return ssm.NewNodes(logger, cfg, instances)
+		},
+		pollInterval: 10 * time.Second,
+	}
+}
+
+// FindNodeGroup returns the partition holding the named node group. The
+// partition may be empty when only one partition has a node group of that name.
+func FindNodeGroup(cfg *config.Config, partition, nodeGroup string) (string, error) {
+	var found []string
+	for _, p := range cfg.Slurm.Partitions {
+		if partition != "" && p.PartitionName != partition {
+			continue
+		}
+		if cfg.FindNodeGroup(p.PartitionName, nodeGroup) != nil {
+			found = append(found, p.PartitionName)
+		}
+	}
+	switch len(found) {
+	case 0:
+		if partition != "" {
+			return "", fmt.Errorf("no node group %q in partition %q", nodeGroup, partition)
+		}
+		return "", fmt.Errorf("no node group %q", nodeGroup)
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("node group %q is in partitions %s; choose one with --partition", nodeGroup, strings.Join(found, ", "))
+}
+
+// Run tests the node group's AMI, terminating the instance afterwards unless
+// keep is set. A failing step fails the report, not the call; the checks
+// after a failed launch or SSM agent are not run.
+func (t *Tester) Run(ctx context.Context, partition, nodeGroup string, keep bool) *Report {
+	start := time.Now()
+	node := t.config.GetNodeName(partition, nodeGroup, NodeID)
+	report := &Report{Partition: partition, NodeGroup: nodeGroup, Node: node}
+	defer func() {
+		report.Seconds = seconds(time.Since(start))
+		report.Passed = len(report.Steps) > 0 && !slices.ContainsFunc(report.Steps, func(step Step) bool { return !step.Passed })
+	}()
+
+	instance, ok := t.launch(ctx, report)
+	if !ok {
+		return report
+	}
+	if keep {
+		t.logger.Info("Keeping the test instance", zap.String("node", node), zap.String("instance_id", instance.InstanceID))
+	} else {
+		defer t.terminate(report)
+	}
+
+	runner := t.newRunner([]types.InstanceInfo{instance})
+	if !t.waitForAgent(ctx, runner, report) {
+		return report
+	}
+	t.runChecks(ctx, runner, report)
+	return report
+}
+
+// launch launches the test instance from the node group's template, never
+// from its warm pool or suspended instances
+func (t *Tester) launch(ctx context.Context, report *Report) (types.InstanceInfo, bool) {
+	start := time.Now()
+	result, err := t.launcher.LaunchInstances(ctx, &aws.LaunchRequest{
+		NodeIds:                []string{report.Node},
+		Partition:              report.Partition,
+		NodeGroup:              report.NodeGroup,
+		InstanceRequirements:   &types.InstanceRequirements{},
+		Job:                    &types.SlurmJob{JobID: JobID},
+		UseConfiguredOverrides: true,
+		NewInstancesOnly:       true,
+	})
+	step := Step{Name: StepLaunch, Seconds: seconds(time.Since(start))}
+	if err == nil && len(result.Instances) == 0 {
+		err = errors.New("no instance launched")
+	}
+	if err != nil {
+		step.Detail = err.Error()
+		report.Steps = append(report.Steps, step)
+		return types.InstanceInfo{}, false
+	}
+
+	instance := result.Instances[0]
+	instance.NodeName, instance.Partition, instance.NodeGroup = report.Node, report.Partition, report.NodeGroup
+	report.InstanceID, report.InstanceType = instance.InstanceID, instance.InstanceType
+	step.Passed = true
+	step.Detail = fmt.Sprintf("%s %s in %s", instance.InstanceID, instance.InstanceType, instance.AvailabilityZone)
+	report.Steps = append(report.Steps, step)
+	return instance, true
+}
+
+// waitForAgent sends a no-op command until the instance's SSM agent takes it
+func (t *Tester) waitForAgent(ctx context.Context, runner healthcheck.Runner, report *Report) bool {
+	start := time.Now()
+	step := Step{Name: StepSSMAgent}
+	for !step.Passed && ctx.Err() == nil {
+		results, err := runner.Run(ctx, []string{report.Node}, ssm.Command{Script: "true", Comment: "aws-slurm-burst AMI test", Timeout: 30 * time.Second})
+		result, ok := results[report.Node]
+		switch {
+		case ok && result.Status != ssm.StatusUndeliverable:
+			step.Passed = true
+			continue
+		case err != nil:
+			step.Detail = err.Error()
+		case ok:
+			step.Detail = result.Output
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(t.pollInterval):
+		}
+	}
+	if !step.Passed {
+		step.Detail = strings.TrimSuffix("timed out waiting for the SSM agent: "+step.Detail, ": ")
+	}
+	step.Seconds = seconds(time.Since(start))
+	report.Steps = append(report.Steps, step)
+	return step.Passed
+}
+
+// runChecks runs the smoke test script and records a step per check
+func (t *Tester) runChecks(ctx context.Context, runner healthcheck.Runner, report *Report) {
+	names, script := Script(t.config)
+	timeout := 15 * time.Minute
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	results, err := runner.Run(ctx, []string{report.Node}, ssm.Command{
+		Script:     script,
+		Comment:    "aws-slurm-burst AMI test",
+		Timeout:    timeout,
+		FullOutput: true,
+	})
+	result, ok := results[report.Node]
+	steps := ParseOutput(names, result.Output)
+	if !ok || len(steps) < len(names) {
+		// The script did not finish: each check it did not report fails with why
+		why := "not run: command " + result.Status
+		if err != nil {
+			why = err.Error()
+		} else if !ok || result.Status == "Pending" || result.Status == "InProgress" {
+			why = "timed out waiting for the checks"
+		}
+		for _, name := range names[len(steps):] {
+			steps = append(steps, Step{Name: name, Detail: why})
+		}
+	}
+	report.Steps = append(report.Steps, steps...)
+}
+
+// terminate terminates the test instance, even when the test's context ran out
+func (t *Tester) terminate(report *Report) {
+	ctx, cancel := context.WithTimeout(context.Background(), terminateTimeout)
+	defer cancel()
+	start := time.Now()
+	step := Step{Name: StepTerminate, Passed: true}
+	if err := t.launcher.TerminateNodeGroupInstances(ctx, report.Partition, report.NodeGroup, []string{report.Node}); err != nil {
+		step.Passed = false
+		step.Detail = fmt.Sprintf("%v; terminate %s by hand", err, report.InstanceID)
+	}
+	step.Seconds = seconds(time.Since(start))
+	report.Steps = append(report.Steps, step)
+}
+
+// checks returns the health checks the smoke test runs: the configured ones,
+// or all that apply without health_check enabled, except slurmd, which does
+// not start on a node Slurm did not resume
+func checks(healthCheck *config.HealthCheckConfig) []string {
+	configured := healthCheck.Checks
+	if len(configured) == 0 {
+		configured = []string{config.HealthCheckEFA, config.HealthCheckClock}
+		if healthCheck.ScratchPath != "" {
+			configured = append(configured, config.HealthCheckScratch)
+		}
+	}
+	return slices.DeleteFunc(slices.Clone(configured), func(check string) bool { return check == config.HealthCheckSlurmd })
+}
+
+// Script returns the names of the checks in the order the smoke test script
+// reports them, and the script. The script prints "PASS <check>" or
+// "FAIL <check>: <why>" for each check, after "TIME <check> <seconds>" for the
+// bootstrap.
+func Script(cfg *config.Config) ([]string, string) {
+	names := []string{StepBootstrap, StepSlurm}
+	var b strings.Builder
+	b.WriteString(`#!/bin/bash
+# Generated by aws-slurm-burst: AMI smoke test
+failed=0
+fail() { echo "FAIL $1: $2"; failed=1; checkfailed=1; }
+check() { checkfailed=0; "${@:2}"; [ "$checkfailed" = 0 ] && echo "PASS $1"; }
+bootstrap() {
+  command -v cloud-init >/dev/null || { fail bootstrap "cloud-init is not installed"; return; }
+  status=$(cloud-init status --wait 2>/dev/null | tail -n 1)
+  # Seconds from boot until cloud-init finished
+  echo "TIME bootstrap $(cut -d. -f1 /proc/uptime)"
+  case "$status" in
+    *done*) ;;
+    *) fail bootstrap "cloud-init ${status:-failed}; see /var/log/cloud-init-output.log" ;;
+  esac
+}
+check bootstrap bootstrap
+check slurm eval 'command -v slurmd >/dev/null || fail slurm "slurmd is not installed"'
+`)
+	if cfg.MungeKey.Source != "" {
+		names = append(names, StepMunge)
+		b.WriteString(`check munge eval 'munge -n 2>/dev/null | unmunge >/dev/null 2>&1 || fail munge "munge cannot encode and decode a credential"'
+`)
+	}
+	for _, check := range checks(&cfg.HealthCheck) {
+		names = append(names, check)
+		fmt.Fprintf(&b, "check %s eval %s\n", check, shellQuote(healthcheck.Commands(&cfg.HealthCheck, []string{check})))
+	}
+	b.WriteString("exit $failed\n")
+	return names, b.String()
+}
+
+// seconds rounds d to tenths of a second
+func seconds(d time.Duration) float64 {
+	return d.Round(100 * time.Millisecond).Seconds()
+}
+
+// shellQuote quotes s as one shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ParseOutput returns the steps of the checks the script reported, in order,
+// stopping at the first check it did not report
+func ParseOutput(names []string, output string) []Step {
+	steps := make(map[string]*Step, len(names))
+	for _, name := range names {
+		steps[name] = &Step{Name: name}
+	}
+	reported := make(map[string]bool, len(names))
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.TrimSuffix(fields[1], ":")
+		step, ok := steps[name]
+		if !ok {
+			continue
+		}
+		switch fields[0] {
+		case "PASS":
+			step.Passed, reported[name] = true, true
+		case "FAIL":
+			_, step.Detail, _ = strings.Cut(line, ": ")
+			step.Passed, reported[name] = false, true
+		case "TIME":
+			if len(fields) == 3 {
+				if seconds, err := strconv.ParseFloat(fields[2], 64); err == nil {
+					step.Seconds = seconds
+				}
+			}
+		}
+	}
+
+	var parsed []Step
+	for _, name := range names {
+		if !reported[name] {
+			break
+		}
+		parsed = append(parsed, *steps[name])
+	}
+	return parsed
+}
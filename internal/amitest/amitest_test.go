@@ -0,0 +1,212 @@
+package amitest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/healthcheck"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ssm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeLauncher struct {
+	launched   []*aws.LaunchRequest
+	launchErr  error
+	terminated []string
+}
+
+func (f *fakeLauncher) LaunchInstances(ctx context.Context, req *aws.LaunchRequest) (*aws.LaunchResult, error) {
+	f.launched = append(f.launched, req)
+	if f.launchErr != nil {
+		return nil, f.launchErr
+	}
+	return &aws.LaunchResult{Instances: []types.InstanceInfo{{
+		NodeName: req.NodeIds[0], InstanceID: "i-1", InstanceType: "g5.xlarge", AvailabilityZone: "us-east-1a", State: "running",
+	}}}, nil
+}
+
+func (f *fakeLauncher) TerminateNodeGroupInstances(ctx context.Context, partition, nodeGroup string, nodeNames []string) error {
+	f.terminated = append(f.terminated, nodeNames...)
+	return nil
+}
+
+// fakeRunner is offline for the first offline commands, then returns output
+// for the smoke test script
+type fakeRunner struct {
+	offline int
+	output  string
+	scripts []string
+}
+
+func (f *fakeRunner) Run(ctx context.Context, nodes []string, command ssm.Command) (map[string]ssm.CommandResult, error) {
+	f.scripts = append(f.scripts, command.Script)
+	if f.offline > 0 {
+		f.offline--
+		return map[string]ssm.CommandResult{nodes[0]: {Status: ssm.StatusUndeliverable, Output: "SSM agent not online"}}, nil
+	}
+	if command.Script == "true" {
+		return map[string]ssm.CommandResult{nodes[0]: {Status: "Success"}}, nil
+	}
+	return map[string]ssm.CommandResult{nodes[0]: {Status: "Failed", Output: f.output}}, nil
+}
+
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Slurm.Partitions = []config.PartitionConfig{
+		{PartitionName: "aws", NodeGroups: []config.NodeGroupConfig{{NodeGroupName: "gpu"}, {NodeGroupName: "cpu"}}},
+		{PartitionName: "spot", NodeGroups: []config.NodeGroupConfig{{NodeGroupName: "cpu"}}},
+	}
+	return cfg
+}
+
+func newTestTester(launcher *fakeLauncher, runner *fakeRunner) *Tester {
+	tester := NewTester(zap.NewNop(), testConfig(), launcher)
+	tester.newRunner = func(instances []types.InstanceInfo) healthcheck.Runner { return runner }
+	tester.pollInterval = time.Millisecond
+	return tester
+}
+
+func TestTester_Run(t *testing.T) {
+	launcher := &fakeLauncher{}
+	runner := &fakeRunner{offline: 2, output: "TIME bootstrap 95\nPASS bootstrap\nFAIL slurm: slurmd is not installed\nPASS efa\nPASS clock\n"}
+
+	report := newTestTester(launcher, runner).Run(context.Background(), "aws", "gpu", false)
+
+	require.Len(t, launcher.launched, 1)
+	req := launcher.launched[0]
+	assert.Equal(t, []string{"aws-gpu-amitest"}, req.NodeIds)
+	assert.Equal(t, JobID, req.Job.JobID)
+	assert.True(t, req.NewInstancesOnly)
+	assert.True(t, req.UseConfiguredOverrides)
+
+	assert.False(t, report.Passed)
+	assert.Equal(t, "i-1", report.InstanceID)
+	assert.Equal(t, "g5.xlarge", report.InstanceType)
+	var names []string
+	for _, step := range report.Steps {
+		names = append(names, step.Name)
+	}
+	assert.Equal(t, []string{StepLaunch, StepSSMAgent, StepBootstrap, StepSlurm, config.HealthCheckEFA, config.HealthCheckClock, StepTerminate}, names)
+	assert.Equal(t, Step{Name: StepBootstrap, Passed: true, Seconds: 95}, report.Steps[2])
+	assert.Equal(t, Step{Name: StepSlurm, Detail: "slurmd is not installed"}, report.Steps[3])
+	assert.Len(t, runner.scripts, 4, "three pings until the agent is online, then the checks")
+	assert.Equal(t, []string{"aws-gpu-amitest"}, launcher.terminated)
+}
+
+func TestTester_Run_Failures(t *testing.T) {
+	// A failed launch has nothing to check or terminate
+	launcher := &fakeLauncher{launchErr: errors.New("InsufficientInstanceCapacity")}
+	report := newTestTester(launcher, &fakeRunner{}).Run(context.Background(), "aws", "gpu", false)
+	assert.False(t, report.Passed)
+	assert.Equal(t, []Step{{Name: StepLaunch, Detail: "InsufficientInstanceCapacity"}}, report.Steps)
+	assert.Empty(t, launcher.terminated)
+
+	// An agent that never comes online fails the test, which still terminates
+	launcher = &fakeLauncher{}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	report = newTestTester(launcher, &fakeRunner{offline: 1 << 30}).Run(ctx, "aws", "gpu", false)
+	assert.False(t, report.Passed)
+	require.Len(t, report.Steps, 3)
+	assert.Equal(t, "timed out waiting for the SSM agent: SSM agent not online", report.Steps[1].Detail)
+	assert.Equal(t, StepTerminate, report.Steps[2].Name)
+	assert.Equal(t, []string{"aws-gpu-amitest"}, launcher.terminated)
+
+	// Checks the script did not report fail; a kept instance is not terminated
+	launcher = &fakeLauncher{}
+	report = newTestTester(launcher, &fakeRunner{output: "PASS bootstrap\n"}).Run(context.Background(), "aws", "gpu", true)
+	assert.False(t, report.Passed)
+	assert.Equal(t, Step{Name: StepSlurm, Detail: "not run: command Failed"}, report.Steps[3])
+	assert.Empty(t, launcher.terminated)
+}
+
+func TestFindNodeGroup(t *testing.T) {
+	cfg := testConfig()
+	partition, err := FindNodeGroup(cfg, "", "gpu")
+	require.NoError(t, err)
+	assert.Equal(t, "aws", partition)
+
+	partition, err = FindNodeGroup(cfg, "spot", "cpu")
+	require.NoError(t, err)
+	assert.Equal(t, "spot", partition)
+
+	_, err = FindNodeGroup(cfg, "", "cpu")
+	assert.ErrorContains(t, err, "in partitions aws, spot; choose one with --partition")
+	_, err = FindNodeGroup(cfg, "spot", "gpu")
+	assert.ErrorContains(t, err, `no node group "gpu" in partition "spot"`)
+}
+
+// TestScript runs the script against stand-ins for the node's commands
+func TestScript(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+	bin := t.TempDir()
+	stub := func(name, script string) {
+		require.NoError(t, os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"+script+"\n"), 0755))
+	}
+	stub("cloud-init", `echo "status: error"`)
+	stub("munge", "echo credential")
+	stub("unmunge", "exit 0")
+	stub("timedatectl", "echo yes")
+	stub("mountpoint", "exit 1")
+	for _, tool := range []string{"cat", "cut", "tail", "grep"} {
+		path, err := exec.LookPath(tool)
+		require.NoError(t, err)
+		require.NoError(t, os.Symlink(path, filepath.Join(bin, tool)))
+	}
+
+	cfg := testConfig()
+	cfg.MungeKey.Source = config.MungeKeySourceSSM
+	cfg.HealthCheck = config.HealthCheckConfig{Enabled: true, ScratchPath: "/scratch",
+		Checks: []string{config.HealthCheckSlurmd, config.HealthCheckClock, config.HealthCheckScratch}}
+	names, script := Script(cfg)
+	assert.Equal(t, []string{StepBootstrap, StepSlurm, StepMunge, config.HealthCheckClock, config.HealthCheckScratch}, names)
+	assert.NotContains(t, script, "systemctl is-active", "slurmd does not run on the test node")
+
+	cmd := exec.Command(bash, "-c", script)
+	cmd.Env = []string{"PATH=" + bin}
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.ExitCode())
+
+	steps := ParseOutput(names, string(output))
+	require.Len(t, steps, len(names), string(output))
+	assert.Equal(t, "cloud-init status: error; see /var/log/cloud-init-output.log", steps[0].Detail)
+	assert.Equal(t, Step{Name: StepSlurm, Detail: "slurmd is not installed"}, steps[1])
+	assert.Equal(t, Step{Name: StepMunge, Passed: true}, steps[2])
+	assert.Equal(t, Step{Name: config.HealthCheckClock, Passed: true}, steps[3])
+	assert.Equal(t, Step{Name: config.HealthCheckScratch, Detail: "/scratch is not mounted"}, steps[4])
+}
+
+func TestRender(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Render(&out, &Report{
+		Partition: "aws", NodeGroup: "gpu", Node: "aws-gpu-amitest", InstanceID: "i-1", InstanceType: "g5.xlarge",
+		Steps: []Step{
+			{Name: StepLaunch, Passed: true, Seconds: 12.3, Detail: "i-1 g5.xlarge in us-east-1a"},
+			{Name: StepSlurm, Detail: "slurmd is not installed"},
+		},
+		Seconds: 312,
+	}))
+	assert.Equal(t, `AMI test of aws/gpu as aws-gpu-amitest (i-1, g5.xlarge)
+
+PASS  launch  12.3s  i-1 g5.xlarge in us-east-1a
+FAIL  slurm   0s     slurmd is not installed
+
+FAILED: 1 of 2 steps failed, in 5m12s
+`, out.String())
+}
@@ -0,0 +1,43 @@
+package amitest
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Render writes the report as a table of steps with their timings
+func Render(w io.Writer, report *Report) error {
+	fmt.Fprintf(w, "AMI test of %s/%s as %s", report.Partition, report.NodeGroup, report.Node)
+	if report.InstanceID != "" {
+		fmt.Fprintf(w, " (%s, %s)", report.InstanceID, report.InstanceType)
+	}
+	fmt.Fprint(w, "\n\n")
+
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, step := range report.Steps {
+		status := "PASS"
+		if !step.Passed {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", status, step.Name, duration(step.Seconds), step.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if report.Passed {
+		fmt.Fprintf(w, "\nPASSED in %s\n", duration(report.Seconds))
+	} else {
+		fmt.Fprintf(w, "\nFAILED: %d of %d steps failed, in %s\n", failed, len(report.Steps), duration(report.Seconds))
+	}
+	return nil
+}
+
+// duration formats seconds like time.Duration
+func duration(seconds float64) string {
+	return (time.Duration(seconds * float64(time.Second))).Round(100 * time.Millisecond).String()
+}
@@ -0,0 +1,127 @@
+// Package anonymize removes identifying information from performance exports
+// before they are shared outside the institution.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// minKeyLength is the shortest salt accepted; shorter keys make hashes guessable
+const minKeyLength = 16
+
+// Anonymizer replaces user and account IDs with keyed hashes and clears redacted fields
+type Anonymizer struct {
+	key       []byte
+	redact    [][]string
+	originals map[string]bool // Identifiers replaced so far, for the Verifier
+}
+
+// LoadKey reads the HMAC salt from a file
+func LoadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read anonymization salt: %w", err)
+	}
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) < minKeyLength {
+		return nil, fmt.Errorf("anonymization salt in %s must be at least %d bytes", path, minKeyLength)
+	}
+	return key, nil
+}
+
+// New creates an anonymizer. redact lists dotted JSON field paths, e.g.
+// "job_metadata.job_name", to clear from every export.
+func New(key []byte, redact []string) *Anonymizer {
+	a := &Anonymizer{key: key, originals: make(map[string]bool)}
+	for _, path := range redact {
+		a.redact = append(a.redact, strings.Split(path, "."))
+	}
+	return a
+}
+
+// HashID returns the stable pseudonym for an identifier. kind separates
+// namespaces so a user and an account with the same name get different hashes.
+// The same key always yields the same pseudonym, so jobs can be followed across
+// exports without revealing who ran them.
+func (a *Anonymizer) HashID(kind, id string) string {
+	if id == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(kind + ":" + id))
+	return kind[:1] + "-" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// pseudonym hashes id and remembers the original for verification
+func (a *Anonymizer) pseudonym(kind, id string) string {
+	if id != "" {
+		a.originals[id] = true
+	}
+	return a.HashID(kind, id)
+}
+
+// Apply anonymizes perf in place
+func (a *Anonymizer) Apply(perf *types.PerformanceFeedback) error {
+	perf.JobMetadata.UserID = a.pseudonym("user", perf.JobMetadata.UserID)
+	perf.JobMetadata.ProjectID = a.pseudonym("account", perf.JobMetadata.ProjectID)
+	if plan := perf.JobMetadata.OriginalASBAPrediction; plan != nil {
+		plan.ExecutionMetadata.UserID = a.pseudonym("user", plan.ExecutionMetadata.UserID)
+		plan.ExecutionMetadata.ProjectID = a.pseudonym("account", plan.ExecutionMetadata.ProjectID)
+	}
+
+	if len(a.redact) == 0 {
+		return nil
+	}
+
+	// Redaction paths are JSON names, so clear them on the encoded document
+	data, err := json.Marshal(perf)
+	if err != nil {
+		return fmt.Errorf("failed to encode performance data for redaction: %w", err)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to decode performance data for redaction: %w", err)
+	}
+	for _, path := range a.redact {
+		redactPath(document, path)
+	}
+	if data, err = json.Marshal(document); err != nil {
+		return fmt.Errorf("failed to encode redacted performance data: %w", err)
+	}
+
+	var redacted types.PerformanceFeedback
+	if err := json.Unmarshal(data, &redacted); err != nil {
+		return fmt.Errorf("failed to decode redacted performance data: %w", err)
+	}
+	*perf = redacted
+	return nil
+}
+
+// redactPath removes the field at path; missing fields are ignored
+func redactPath(document map[string]interface{}, path []string) {
+	for _, name := range path[:len(path)-1] {
+		child, ok := document[name].(map[string]interface{})
+		if !ok {
+			return
+		}
+		document = child
+	}
+	delete(document, path[len(path)-1])
+}
+
+// Originals returns the identifiers this anonymizer has replaced
+func (a *Anonymizer) Originals() []string {
+	originals := make([]string, 0, len(a.originals))
+	for id := range a.originals {
+		originals = append(originals, id)
+	}
+	return originals
+}
@@ -0,0 +1,79 @@
+package anonymize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "salt")
+
+	require.NoError(t, os.WriteFile(path, append(testKey, '\n'), 0600))
+	key, err := LoadKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, testKey, key)
+
+	require.NoError(t, os.WriteFile(path, []byte("short"), 0600))
+	_, err = LoadKey(path)
+	assert.Error(t, err)
+
+	_, err = LoadKey(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestHashID(t *testing.T) {
+	a := New(testKey, nil)
+
+	hashed := a.HashID("user", "alice")
+	assert.Regexp(t, `^u-[0-9a-f]{16}$`, hashed)
+	assert.Equal(t, hashed, New(testKey, nil).HashID("user", "alice"), "stable across exports")
+	assert.NotEqual(t, hashed, a.HashID("account", "alice"), "namespaced by kind")
+	assert.NotEqual(t, hashed, New([]byte("fedcba9876543210fedcba9876543210"), nil).HashID("user", "alice"), "keyed")
+	assert.Empty(t, a.HashID("user", ""))
+}
+
+func TestApply(t *testing.T) {
+	perf := types.PerformanceFeedback{
+		JobMetadata: types.JobMetadata{
+			JobID:     "42",
+			JobName:   "alice-thesis-run",
+			UserID:    "alice",
+			ProjectID: "NSF-ABC123",
+			OriginalASBAPrediction: &types.ExecutionPlan{
+				ExecutionMetadata: types.ExecutionMetadata{UserID: "alice", ProjectID: "NSF-ABC123"},
+			},
+			ActualExecution: types.ActualExecution{ErrorDetails: "/home/alice/run.sh failed", NodeCount: 4},
+		},
+		ExecutionContext: types.ExecutionContext{
+			AWSRegion:            "us-east-1",
+			EnvironmentVariables: map[string]string{"HOME": "/home/alice"},
+		},
+	}
+
+	a := New(testKey, []string{
+		"job_metadata.job_name",
+		"job_metadata.actual_execution.error_details",
+		"execution_context.environment_variables",
+		"job_metadata.not_a_field.anything",
+	})
+	require.NoError(t, a.Apply(&perf))
+
+	assert.Equal(t, "42", perf.JobMetadata.JobID)
+	assert.Equal(t, a.HashID("user", "alice"), perf.JobMetadata.UserID)
+	assert.Equal(t, a.HashID("account", "NSF-ABC123"), perf.JobMetadata.ProjectID)
+	assert.Equal(t, perf.JobMetadata.UserID, perf.JobMetadata.OriginalASBAPrediction.ExecutionMetadata.UserID)
+	assert.Empty(t, perf.JobMetadata.JobName)
+	assert.Empty(t, perf.JobMetadata.ActualExecution.ErrorDetails)
+	assert.Empty(t, perf.ExecutionContext.EnvironmentVariables)
+	assert.Equal(t, 4, perf.JobMetadata.ActualExecution.NodeCount)
+	assert.Equal(t, "us-east-1", perf.ExecutionContext.AWSRegion)
+	assert.ElementsMatch(t, []string{"alice", "NSF-ABC123"}, a.Originals())
+}
@@ -0,0 +1,78 @@
+package anonymize
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Finding is a suspected piece of personal data in an export
+type Finding struct {
+	Pattern string `json:"pattern"`
+	Match   string `json:"match"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %q", f.Pattern, f.Match)
+}
+
+// builtinPatterns match personal data that should never leave the cluster
+var builtinPatterns = map[string]*regexp.Regexp{
+	"email":          regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	"ipv4_address":   regexp.MustCompile(`\b(?:25[0-5]|2[0-4]\d|1?\d?\d)(?:\.(?:25[0-5]|2[0-4]\d|1?\d?\d)){3}\b`),
+	"home_directory": regexp.MustCompile(`/(?:home|Users)/[A-Za-z0-9._-]+`),
+	"aws_access_key": regexp.MustCompile(`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`),
+}
+
+// Verifier scans encoded exports for personal data before they are shared
+type Verifier struct {
+	patterns    map[string]*regexp.Regexp
+	identifiers map[string]*regexp.Regexp
+}
+
+// NewVerifier creates a verifier with the built-in patterns plus extra regular
+// expressions. identifiers are literal values, such as the user and account IDs
+// an Anonymizer replaced, that must not appear in the output.
+func NewVerifier(extra []string, identifiers []string) (*Verifier, error) {
+	v := &Verifier{patterns: make(map[string]*regexp.Regexp), identifiers: make(map[string]*regexp.Regexp)}
+	for name, pattern := range builtinPatterns {
+		v.patterns[name] = pattern
+	}
+	for _, expr := range extra {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PII pattern %q: %w", expr, err)
+		}
+		v.patterns[expr] = pattern
+	}
+	for _, id := range identifiers {
+		if id != "" {
+			// Match whole tokens so an ID like "abc" is not found inside a hash
+			v.identifiers[id] = regexp.MustCompile(`(?:^|[^A-Za-z0-9_-])` + regexp.QuoteMeta(id) + `(?:[^A-Za-z0-9_-]|$)`)
+		}
+	}
+	return v, nil
+}
+
+// Scan returns every suspected piece of personal data in data, sorted by pattern
+func (v *Verifier) Scan(data []byte) []Finding {
+	var findings []Finding
+	for name, pattern := range v.patterns {
+		for _, match := range pattern.FindAll(data, -1) {
+			findings = append(findings, Finding{Pattern: name, Match: string(match)})
+		}
+	}
+	for id, pattern := range v.identifiers {
+		if pattern.Match(data) {
+			findings = append(findings, Finding{Pattern: "original_identifier", Match: id})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Pattern != findings[j].Pattern {
+			return findings[i].Pattern < findings[j].Pattern
+		}
+		return findings[i].Match < findings[j].Match
+	})
+	return findings
+}
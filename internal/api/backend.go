@@ -0,0 +1,250 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/status"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// Default install paths of the programs Slurm runs, used when the config does not name them
+const (
+	defaultResumeProgram  = "/usr/local/bin/aws-slurm-burst-resume"
+	defaultSuspendProgram = "/usr/local/bin/aws-slurm-burst-suspend"
+)
+
+// Backend performs the operations the API exposes
+type Backend interface {
+	Launch(ctx context.Context, req *LaunchRequest) error
+	Terminate(ctx context.Context, req *TerminateRequest) error
+	Nodes(ctx context.Context) ([]NodeStatus, error)
+	Costs(ctx context.Context) (*aws.CostSummary, error)
+	Validate(ctx context.Context, req *ValidateRequest) (*ValidationReport, error)
+	Status(ctx context.Context) (*status.Document, error)
+}
+
+// LocalBackend serves the API on the Slurm controller. Launch and terminate run
+// the same resume and suspend programs Slurm uses, so API-driven bursts behave
+// exactly like power-save ones.
+type LocalBackend struct {
+	logger     *zap.Logger
+	configFile string
+	config     *config.Config
+	slurm      *slurm.Client
+	aws        *aws.Client
+
+	// The AWS client and viper-based config loading are not safe for concurrent use
+	mu sync.Mutex
+}
+
+// NewLocalBackend creates a backend for the configuration loaded from configFile
+func NewLocalBackend(logger *zap.Logger, configFile string, cfg *config.Config) (*LocalBackend, error) {
+	awsClient, err := aws.NewClient(logger, &cfg.AWS, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS client: %w", err)
+	}
+
+	return &LocalBackend{
+		logger:     logger,
+		configFile: configFile,
+		config:     cfg,
+		slurm:      slurm.NewClient(logger, &cfg.Slurm),
+		aws:        awsClient,
+	}, nil
+}
+
+// Launch runs the resume program for the nodes, with the execution plan when given
+func (b *LocalBackend) Launch(ctx context.Context, req *LaunchRequest) error {
+	if err := checkNodes(b.config, req.Nodes); err != nil {
+		return err
+	}
+
+	args := []string{"--config", b.configFile}
+	if req.ExecutionPlan != nil {
+		planFile, err := writePlanFile(req.ExecutionPlan)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(planFile)
+		args = append(args, "--execution-plan", planFile)
+	}
+	if req.DryRun {
+		args = append(args, "--dry-run")
+	}
+
+	return b.run(ctx, programOrDefault(b.config.Slurm.ResumeProgram, defaultResumeProgram), append(args, "--", req.Nodes)...)
+}
+
+// Terminate runs the suspend program for the nodes
+func (b *LocalBackend) Terminate(ctx context.Context, req *TerminateRequest) error {
+	if err := checkNodes(b.config, req.Nodes); err != nil {
+		return err
+	}
+
+	args := []string{"--config", b.configFile}
+	if req.Force {
+		args = append(args, "--force")
+	}
+	if req.DryRun {
+		args = append(args, "--dry-run")
+	}
+
+	return b.run(ctx, programOrDefault(b.config.Slurm.SuspendProgram, defaultSuspendProgram), append(args, "--", req.Nodes)...)
+}
+
+// Nodes reports every configured burst node with its Slurm state and instance
+func (b *LocalBackend) Nodes(ctx context.Context) ([]NodeStatus, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	nodeNames, err := status.ConfiguredNodes(b.config, b.slurm)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := b.slurm.GetNodeState(nodeNames)
+	if err != nil {
+		return nil, err
+	}
+	instances, err := b.aws.ListInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return joinNodeStatus(nodes, instances), nil
+}
+
+// Costs estimates the cost of the running burst instances
+func (b *LocalBackend) Costs(ctx context.Context) (*aws.CostSummary, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.aws.SummarizeCosts(ctx)
+}
+
+// Status collects the burst status document
+func (b *LocalBackend) Status(ctx context.Context) (*status.Document, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return status.NewCollector(b.logger, b.config, b.slurm, b.aws).Collect(ctx, time.Now()), nil
+}
+
+// Validate reloads the configuration file and checks the execution plan, if given
+func (b *LocalBackend) Validate(ctx context.Context, req *ValidateRequest) (*ValidationReport, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	report := &ValidationReport{ConfigValid: true}
+	if _, err := config.Load(b.configFile); err != nil {
+		report.ConfigValid = false
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	if req.ExecutionPlan != nil {
+		planValid := true
+		if err := req.ExecutionPlan.ValidateExecutionPlan(); err != nil {
+			planValid = false
+			report.Errors = append(report.Errors, err.Error())
+		}
+		report.PlanValid = &planValid
+	}
+
+	return report, nil
+}
+
+// run executes a program, returning its last output line on failure
+func (b *LocalBackend) run(ctx context.Context, program string, args ...string) error {
+	b.logger.Info("Running program for API request", zap.String("program", program), zap.Strings("args", args))
+
+	output, err := exec.CommandContext(ctx, program, args...).CombinedOutput()
+	if err != nil {
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		return fmt.Errorf("%s failed: %w: %s", program, err, lines[len(lines)-1])
+	}
+	return nil
+}
+
+// checkNodes rejects a hostlist naming nodes outside the configured node
+// groups, so a request can only reach the programs with burst node names
+func checkNodes(cfg *config.Config, hostlist string) error {
+	configured := make(map[string]bool)
+	for _, partition := range cfg.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			nodeRange := cfg.GetNodeRange(partition.PartitionName, nodeGroup.NodeGroupName, nodeGroup.MaxNodes)
+			for _, node := range slurm.ExpandHostlist(nodeRange) {
+				configured[node] = true
+			}
+		}
+	}
+
+	nodes := slurm.ExpandHostlist(hostlist)
+	if len(nodes) == 0 {
+		return fmt.Errorf("%w: nodes %q name no node", ErrInvalidRequest, hostlist)
+	}
+	for _, node := range nodes {
+		if !configured[node] {
+			return fmt.Errorf("%w: node %q is not in a configured node group", ErrInvalidRequest, node)
+		}
+	}
+	return nil
+}
+
+// joinNodeStatus pairs Slurm nodes with the instances serving them
+func joinNodeStatus(nodes []slurm.NodeInfo, instances []types.InstanceInfo) []NodeStatus {
+	byNode := make(map[string]types.InstanceInfo)
+	for _, instance := range instances {
+		if instance.NodeName != "" {
+			byNode[instance.NodeName] = instance
+		}
+	}
+
+	statuses := make([]NodeStatus, 0, len(nodes))
+	for _, node := range nodes {
+		status := NodeStatus{Node: node.NodeName, State: node.State, Reason: node.Reason}
+		if instance, ok := byNode[node.NodeName]; ok {
+			status.Instance = &instance
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// writePlanFile stores an execution plan where the resume program can read it
+func writePlanFile(plan *types.ExecutionPlan) (string, error) {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode execution plan: %w", err)
+	}
+
+	file, err := os.CreateTemp("", "aws-burst-plan-*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to create execution plan file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write execution plan file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// programOrDefault returns the configured program path, or the default install path
+func programOrDefault(program, fallback string) string {
+	if program != "" {
+		return program
+	}
+	return fallback
+}
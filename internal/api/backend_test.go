@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// newTestBackend returns a backend for the aws partition's cpu node group of
+// four nodes whose resume and suspend programs write their arguments to the
+// returned file, one per line
+func newTestBackend(t *testing.T) (*LocalBackend, string) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	program := filepath.Join(dir, "program")
+	require.NoError(t, os.WriteFile(program, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\" > "+argsFile+"\n"), 0755))
+
+	cfg := &config.Config{Slurm: config.SlurmConfig{
+		ResumeProgram:  program,
+		SuspendProgram: program,
+		Partitions: []config.PartitionConfig{{
+			PartitionName: "aws",
+			NodeGroups:    []config.NodeGroupConfig{{NodeGroupName: "cpu", MaxNodes: 4}},
+		}},
+	}}
+	return &LocalBackend{logger: zaptest.NewLogger(t), configFile: "/etc/slurm/aws-burst.yaml", config: cfg}, argsFile
+}
+
+func TestLocalBackend_Nodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		nodes       string
+		expectError bool
+	}{
+		{name: "configured node", nodes: "aws-cpu-1"},
+		{name: "configured range", nodes: "aws-cpu-[0-3]"},
+		{name: "range past the node group", nodes: "aws-cpu-[2-4]", expectError: true},
+		{name: "node of another group", nodes: "aws-gpu-0", expectError: true},
+		{name: "option", nodes: "--help", expectError: true},
+		{name: "empty list", nodes: ",", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, argsFile := newTestBackend(t)
+
+			launchErr := backend.Launch(context.Background(), &LaunchRequest{Nodes: tt.nodes})
+			terminateErr := backend.Terminate(context.Background(), &TerminateRequest{Nodes: tt.nodes})
+			if tt.expectError {
+				assert.ErrorIs(t, launchErr, ErrInvalidRequest)
+				assert.ErrorIs(t, terminateErr, ErrInvalidRequest)
+				assert.NoFileExists(t, argsFile)
+				return
+			}
+			require.NoError(t, launchErr)
+			require.NoError(t, terminateErr)
+
+			// The hostlist follows "--", so the programs never read it as a flag
+			data, err := os.ReadFile(argsFile)
+			require.NoError(t, err)
+			args := strings.Split(strings.TrimSpace(string(data)), "\n")
+			assert.Equal(t, []string{"--", tt.nodes}, args[len(args)-2:])
+		})
+	}
+}
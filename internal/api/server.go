@@ -0,0 +1,249 @@
+// Package api serves a versioned localhost REST API so portals and ASBA can drive
+// bursting without executing the resume and suspend programs themselves.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// LaunchRequest launches instances for Slurm nodes
+type LaunchRequest struct {
+	Nodes         string               `json:"nodes"` // Slurm hostlist, e.g. aws-cpu-[0-3]
+	ExecutionPlan *types.ExecutionPlan `json:"execution_plan,omitempty"`
+	DryRun        bool                 `json:"dry_run,omitempty"`
+}
+
+// TerminateRequest suspends Slurm nodes and terminates their instances
+type TerminateRequest struct {
+	Nodes  string `json:"nodes"`
+	Force  bool   `json:"force,omitempty"` // Skip draining the nodes
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// ValidateRequest checks the configuration and, optionally, an execution plan
+type ValidateRequest struct {
+	ExecutionPlan *types.ExecutionPlan `json:"execution_plan,omitempty"`
+}
+
+// ValidationReport is the outcome of a validation request
+type ValidationReport struct {
+	ConfigValid bool     `json:"config_valid"`
+	PlanValid   *bool    `json:"plan_valid,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// NodeStatus is a burst node's Slurm state and the instance serving it, if any
+type NodeStatus struct {
+	Node     string              `json:"node"`
+	State    string              `json:"state"`
+	Reason   string              `json:"reason,omitempty"`
+	Instance *types.InstanceInfo `json:"instance,omitempty"`
+}
+
+// ErrInvalidRequest is wrapped by backend errors caused by the request itself;
+// the API answers them with 400 rather than 500
+var ErrInvalidRequest = errors.New("invalid request")
+
+// errorResponse is the body of every failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server serves the API for a backend
+type Server struct {
+	logger  *zap.Logger
+	config  *config.APIConfig
+	backend Backend
+	token   string
+}
+
+// NewServer creates an API server, reading the bearer token clients must present.
+// The server refuses to start without a token.
+func NewServer(logger *zap.Logger, apiConfig *config.APIConfig, backend Backend) (*Server, error) {
+	data, err := os.ReadFile(apiConfig.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API token: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return nil, fmt.Errorf("API token file %s is empty", apiConfig.TokenFile)
+	}
+
+	return &Server{
+		logger:  logger,
+		config:  apiConfig,
+		backend: backend,
+		token:   token,
+	}, nil
+}
+
+// Handler returns the API routes. Everything but the health check requires the token.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.Handle("POST /v1/launch", s.authenticated(s.handleLaunch))
+	mux.Handle("POST /v1/terminate", s.authenticated(s.handleTerminate))
+	mux.Handle("GET /v1/nodes", s.authenticated(s.handleNodes))
+	mux.Handle("GET /v1/costs", s.authenticated(s.handleCosts))
+	mux.Handle("POST /v1/validate", s.authenticated(s.handleValidate))
+	mux.Handle("GET /v1/status", s.authenticated(s.handleStatus))
+	return mux
+}
+
+// Run serves the API until the context is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.config.Listen,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			s.logger.Warn("API server shutdown incomplete", zap.Error(err))
+		}
+	}()
+
+	s.logger.Info("API server listening", zap.String("listen", s.config.Listen))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("API server failed: %w", err)
+	}
+	return nil
+}
+
+// authenticated rejects requests without the bearer token
+func (s *Server) authenticated(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "missing or invalid bearer token"})
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func (s *Server) handleLaunch(w http.ResponseWriter, r *http.Request) {
+	var req LaunchRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if req.Nodes == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "nodes is required"})
+		return
+	}
+	if req.ExecutionPlan != nil {
+		if err := req.ExecutionPlan.ValidateExecutionPlan(); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	if err := s.backend.Launch(r.Context(), &req); err != nil {
+		s.fail(w, "launch", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"nodes": req.Nodes, "dry_run": req.DryRun, "status": "completed"})
+}
+
+func (s *Server) handleTerminate(w http.ResponseWriter, r *http.Request) {
+	var req TerminateRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	if req.Nodes == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "nodes is required"})
+		return
+	}
+
+	if err := s.backend.Terminate(r.Context(), &req); err != nil {
+		s.fail(w, "terminate", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"nodes": req.Nodes, "dry_run": req.DryRun, "status": "completed"})
+}
+
+func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request) {
+	nodes, err := s.backend.Nodes(r.Context())
+	if err != nil {
+		s.fail(w, "nodes", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"nodes": nodes})
+}
+
+func (s *Server) handleCosts(w http.ResponseWriter, r *http.Request) {
+	summary, err := s.backend.Costs(r.Context())
+	if err != nil {
+		s.fail(w, "costs", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.backend.Status(r.Context())
+	if err != nil {
+		s.fail(w, "status", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	var req ValidateRequest
+	if r.ContentLength != 0 && !decodeRequest(w, r, &req) {
+		return
+	}
+
+	report, err := s.backend.Validate(r.Context(), &req)
+	if err != nil {
+		s.fail(w, "validate", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// fail reports a backend error to the client, logging those the request did
+// not cause
+func (s *Server) fail(w http.ResponseWriter, operation string, err error) {
+	if errors.Is(err, ErrInvalidRequest) {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	s.logger.Error("API operation failed", zap.String("operation", operation), zap.Error(err))
+	writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+}
+
+// decodeRequest parses a JSON body, answering 400 when it is malformed
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err := decoder.Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid request body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
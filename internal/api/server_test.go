@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/status"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeBackend records the requests it receives
+type fakeBackend struct {
+	launched   *LaunchRequest
+	terminated *TerminateRequest
+	launchErr  error
+}
+
+func (b *fakeBackend) Launch(ctx context.Context, req *LaunchRequest) error {
+	b.launched = req
+	return b.launchErr
+}
+
+func (b *fakeBackend) Terminate(ctx context.Context, req *TerminateRequest) error {
+	b.terminated = req
+	return nil
+}
+
+func (b *fakeBackend) Nodes(ctx context.Context) ([]NodeStatus, error) {
+	return []NodeStatus{{Node: "aws-cpu-0", State: "IDLE+CLOUD"}}, nil
+}
+
+func (b *fakeBackend) Costs(ctx context.Context) (*aws.CostSummary, error) {
+	return &aws.CostSummary{Instances: 2, HourlyUSD: 0.384}, nil
+}
+
+func (b *fakeBackend) Validate(ctx context.Context, req *ValidateRequest) (*ValidationReport, error) {
+	return &ValidationReport{ConfigValid: true}, nil
+}
+
+func (b *fakeBackend) Status(ctx context.Context) (*status.Document, error) {
+	return &status.Document{PendingJobs: 3}, nil
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *fakeBackend) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s3cret\n"), 0600))
+
+	backend := &fakeBackend{}
+	server, err := NewServer(zaptest.NewLogger(t), &config.APIConfig{TokenFile: tokenFile}, backend)
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(httpServer.Close)
+	return httpServer, backend
+}
+
+func request(t *testing.T, method, url, token, body string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestServer(t *testing.T) {
+	server, backend := newTestServer(t)
+
+	status, _ := request(t, http.MethodGet, server.URL+"/v1/health", "", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = request(t, http.MethodGet, server.URL+"/v1/nodes", "", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = request(t, http.MethodGet, server.URL+"/v1/nodes", "wrong", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body := request(t, http.MethodGet, server.URL+"/v1/nodes", "s3cret", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"node":"aws-cpu-0"`)
+
+	status, body = request(t, http.MethodGet, server.URL+"/v1/costs", "s3cret", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"hourly_usd":0.384`)
+
+	status, body = request(t, http.MethodGet, server.URL+"/v1/status", "s3cret", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"pending_jobs":3`)
+
+	status, _ = request(t, http.MethodPost, server.URL+"/v1/launch", "s3cret", `{}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = request(t, http.MethodPost, server.URL+"/v1/launch", "s3cret", `{"nodes": "aws-cpu-[0-1]", "dry_run": true}`)
+	assert.Equal(t, http.StatusOK, status)
+	require.NotNil(t, backend.launched)
+	assert.Equal(t, "aws-cpu-[0-1]", backend.launched.Nodes)
+	assert.True(t, backend.launched.DryRun)
+
+	backend.launchErr = fmt.Errorf("%w: node \"aws-gpu-0\" is not in a configured node group", ErrInvalidRequest)
+	status, body = request(t, http.MethodPost, server.URL+"/v1/launch", "s3cret", `{"nodes": "aws-gpu-0"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "not in a configured node group")
+	backend.launchErr = errors.New("resume failed")
+	status, _ = request(t, http.MethodPost, server.URL+"/v1/launch", "s3cret", `{"nodes": "aws-cpu-0"}`)
+	assert.Equal(t, http.StatusInternalServerError, status)
+
+	status, _ = request(t, http.MethodPost, server.URL+"/v1/terminate", "s3cret", `{"nodes": "aws-cpu-0", "force": true}`)
+	assert.Equal(t, http.StatusOK, status)
+	require.NotNil(t, backend.terminated)
+	assert.True(t, backend.terminated.Force)
+
+	status, body = request(t, http.MethodPost, server.URL+"/v1/validate", "s3cret", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"config_valid":true`)
+}
+
+func TestNewServer_RequiresToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("  \n"), 0600))
+
+	_, err := NewServer(zaptest.NewLogger(t), &config.APIConfig{TokenFile: tokenFile}, &fakeBackend{})
+	assert.Error(t, err)
+}
+
+func TestJoinNodeStatus(t *testing.T) {
+	statuses := joinNodeStatus(
+		[]slurm.NodeInfo{{NodeName: "aws-cpu-0", State: "ALLOCATED+CLOUD"}, {NodeName: "aws-cpu-1", State: "IDLE+CLOUD+POWERED_DOWN"}},
+		[]types.InstanceInfo{{NodeName: "aws-cpu-0", InstanceID: "i-0abc"}},
+	)
+
+	require.Len(t, statuses, 2)
+	require.NotNil(t, statuses[0].Instance)
+	assert.Equal(t, "i-0abc", statuses[0].Instance.InstanceID)
+	assert.Nil(t, statuses[1].Instance)
+}
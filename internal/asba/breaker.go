@@ -0,0 +1,122 @@
+package asba
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+)
+
+// AvailabilityFile holds the breaker state and call counts in the data exchange directory
+const AvailabilityFile = "asba-availability.json"
+
+// ErrCircuitOpen is returned without running asba while the breaker is open
+var ErrCircuitOpen = errors.New("ASBA circuit breaker is open after repeated failures")
+
+// Availability counts ASBA invocations. Each resume is a new process, so the
+// counts and breaker state are shared through the data exchange directory.
+type Availability struct {
+	Calls               int64     `json:"calls"`    // Invocations that ran asba, retries included once
+	Failures            int64     `json:"failures"` // Invocations that failed after all retries
+	Rejected            int64     `json:"rejected"` // Invocations skipped while the breaker was open
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	OpenUntil           time.Time `json:"open_until,omitempty"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+	LastFailure         time.Time `json:"last_failure,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+// Open reports whether the breaker rejects calls at now
+func (a *Availability) Open(now time.Time) bool {
+	return now.Before(a.OpenUntil)
+}
+
+// LoadAvailability returns the shared ASBA call counts, or nil before the first call
+func LoadAvailability(exchangeDir string) (*Availability, error) {
+	var availability Availability
+	found, err := exchange.ReadShared(exchangeDir, AvailabilityFile, &availability)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &availability, nil
+}
+
+// Breaker stops invoking asba after threshold consecutive failures, until
+// cooldown has passed. The next call after the cooldown is a trial: success
+// closes the breaker and failure opens it again.
+type Breaker struct {
+	exchangeDir string // Empty keeps the state in memory only
+	threshold   int    // Zero disables the breaker
+	cooldown    time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	local Availability
+}
+
+// NewBreaker creates a breaker sharing its state through exchangeDir
+func NewBreaker(exchangeDir string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{exchangeDir: exchangeDir, threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether asba may be invoked, counting a rejection when not
+func (b *Breaker) Allow() bool {
+	allowed := true
+	b.update(func(a *Availability, now time.Time) {
+		if b.threshold > 0 && a.Open(now) {
+			a.Rejected++
+			allowed = false
+		}
+	})
+	return allowed
+}
+
+// Record counts an invocation and opens the breaker after too many failures in a row
+func (b *Breaker) Record(err error) {
+	b.update(func(a *Availability, now time.Time) {
+		a.Calls++
+		if err == nil {
+			a.ConsecutiveFailures = 0
+			a.OpenUntil = time.Time{}
+			a.LastSuccess = now
+			return
+		}
+		a.Failures++
+		a.ConsecutiveFailures++
+		a.LastFailure = now
+		a.LastError = err.Error()
+		if b.threshold > 0 && a.ConsecutiveFailures >= b.threshold {
+			a.OpenUntil = now.Add(b.cooldown)
+		}
+	})
+}
+
+// update applies change to the shared state under the exchange lock. Problems
+// with the shared file fall back to this process's own state; the breaker must
+// never be the reason a launch fails.
+func (b *Breaker) update(change func(a *Availability, now time.Time)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+
+	if b.exchangeDir == "" {
+		change(&b.local, now)
+		return
+	}
+	unlock, err := exchange.Lock(b.exchangeDir, "asba-availability", 2*time.Second)
+	if err != nil {
+		change(&b.local, now)
+		return
+	}
+	defer unlock()
+
+	availability, err := LoadAvailability(b.exchangeDir)
+	if err != nil || availability == nil {
+		availability = &Availability{}
+	}
+	change(availability, now)
+	if _, err := exchange.WriteShared(b.exchangeDir, AvailabilityFile, availability); err != nil {
+		change(&b.local, now)
+	}
+}
@@ -1,31 +1,157 @@
+// Package asba is the single integration point with aws-slurm-burst-advisor
+// (ASBA). Callers should depend on the Client interface.
 package asba
 
 import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"os/exec"
 	"strings"
 	"time"
 
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
 	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"go.uber.org/zap"
 )
 
+// Client is the ASBA integration used by aws-slurm-burst
+type Client interface {
+	AnalyzeBurstDecision(ctx context.Context, job *types.SlurmJob) (*types.ASBADecision, error)
+	EnrichJobWithASBAData(ctx context.Context, job *types.SlurmJob, instanceReq *types.InstanceRequirements) error
+	GenerateExecutionPlan(ctx context.Context, jobID string) (*types.ExecutionPlan, error)
+	GetRecommendedInstanceTypes(ctx context.Context, job *types.SlurmJob) ([]string, error)
+	ValidateASBAAvailability(ctx context.Context) error
+}
+
+var _ Client = (*ASBAClient)(nil)
+
+// Runner executes the asba command and returns its standard output; it is replaced in tests
+type Runner func(ctx context.Context, program string, args ...string) ([]byte, error)
+
+// execRunner runs program as a subprocess, keeping stderr out of the JSON output
+func execRunner(ctx context.Context, program string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, program, args...).Output()
+}
+
+// Retry backoff bounds; each wait is jittered between half and all of the step
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+)
+
 // ASBAClient handles integration with aws-slurm-burst-advisor
 type ASBAClient struct {
 	logger      *zap.Logger
 	asbaCommand string // Path to asba command
 	configPath  string // Path to ASBA configuration
+	run         Runner
+
+	timeout time.Duration // Per attempt; zero leaves it to the caller's context
+	retries int           // Extra attempts after a failure
+	breaker *Breaker
+	sleep   func(ctx context.Context, d time.Duration) error
 }
 
-// NewASBAClient creates a new ASBA integration client
+// NewASBAClient creates a new ASBA integration client that runs asba once per
+// call with no breaker
 func NewASBAClient(logger *zap.Logger, asbaCommand, configPath string) *ASBAClient {
 	return &ASBAClient{
 		logger:      logger,
 		asbaCommand: asbaCommand,
 		configPath:  configPath,
+		run:         execRunner,
+		breaker:     NewBreaker("", 0, 0),
+		sleep:       sleepContext,
+	}
+}
+
+// NewClientFromConfig creates a client with the configured timeout, retries and a
+// circuit breaker shared through the data exchange directory
+func NewClientFromConfig(logger *zap.Logger, cfg *config.Config) *ASBAClient {
+	client := NewASBAClient(logger, cfg.ASBA.Command, cfg.ASBA.ConfigPath)
+	client.timeout = time.Duration(cfg.ASBA.Timeout) * time.Second
+	client.retries = cfg.ASBA.MaxRetries
+	client.breaker = NewBreaker(cfg.Ecosystem.DataExchangeDir, cfg.ASBA.BreakerThreshold,
+		time.Duration(cfg.ASBA.BreakerCooldownSeconds)*time.Second)
+	return client
+}
+
+// invoke runs asba with a timeout per attempt and jittered exponential backoff
+// between attempts. The breaker sees one result per invocation.
+func (c *ASBAClient) invoke(ctx context.Context, args ...string) ([]byte, error) {
+	if !c.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			delay := backoff(attempt)
+			c.logger.Debug("Retrying ASBA", zap.Int("attempt", attempt+1), zap.Duration("delay", delay), zap.Error(err))
+			if c.sleep(ctx, delay) != nil {
+				break
+			}
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if c.timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		}
+		var output []byte
+		output, err = c.run(attemptCtx, c.asbaCommand, args...)
+		cancel()
+		if err == nil {
+			c.breaker.Record(nil)
+			return output, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	c.breaker.Record(err)
+	return nil, err
+}
+
+// backoff returns the jittered wait before the given retry attempt
+func backoff(attempt int) time.Duration {
+	step := retryBaseDelay << (attempt - 1)
+	if step > retryMaxDelay || step <= 0 {
+		step = retryMaxDelay
+	}
+	return step/2 + time.Duration(rand.Int63n(int64(step/2)+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// GenerateExecutionPlan asks ASBA for the execution plan of a pending job
+func (c *ASBAClient) GenerateExecutionPlan(ctx context.Context, jobID string) (*types.ExecutionPlan, error) {
+	args := []string{"execution-plan", "--job-id=" + jobID, "--output=json"}
+	if c.configPath != "" {
+		args = append(args, "--config="+c.configPath)
+	}
+
+	output, err := c.invoke(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ASBA execution plan failed: %w", err)
+	}
+
+	var plan types.ExecutionPlan
+	if err := json.Unmarshal(output, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse ASBA execution plan: %w", err)
 	}
+	return &plan, nil
 }
 
 // AnalyzeBurstDecision consults ASBA to determine if a job should burst to AWS
@@ -65,8 +191,7 @@ func (c *ASBAClient) AnalyzeBurstDecision(ctx context.Context, job *types.SlurmJ
 	}
 
 	// Execute ASBA command
-	cmd := exec.CommandContext(ctx, c.asbaCommand, args...)
-	output, err := cmd.Output()
+	output, err := c.invoke(ctx, args...)
 	if err != nil {
 		return nil, fmt.Errorf("ASBA command failed: %w", err)
 	}
@@ -159,8 +284,7 @@ echo "Job analysis for %s"
 
 // ValidateASBAAvailability checks if ASBA is available and configured
 func (c *ASBAClient) ValidateASBAAvailability(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, c.asbaCommand, "--version")
-	output, err := cmd.Output()
+	output, err := c.invoke(ctx, "--version")
 	if err != nil {
 		return fmt.Errorf("ASBA command not available: %w", err)
 	}
@@ -189,8 +313,7 @@ func (c *ASBAClient) GetRecommendedInstanceTypes(ctx context.Context, job *types
 		}
 	}
 
-	cmd := exec.CommandContext(ctx, c.asbaCommand, args...)
-	output, err := cmd.Output()
+	output, err := c.invoke(ctx, args...)
 	if err != nil {
 		return nil, fmt.Errorf("ASBA instance recommendation failed: %w", err)
 	}
@@ -0,0 +1,186 @@
+package asba
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// fakeASBA answers asba subcommands from canned output and records the arguments
+type fakeASBA struct {
+	outputs map[string]string
+	calls   [][]string
+}
+
+func (f *fakeASBA) run(ctx context.Context, program string, args ...string) ([]byte, error) {
+	f.calls = append(f.calls, append([]string{program}, args...))
+	output, ok := f.outputs[args[0]]
+	if !ok {
+		return nil, errors.New("exit status 2")
+	}
+	return []byte(output), nil
+}
+
+func newTestClient(fake *fakeASBA) *ASBAClient {
+	client := NewASBAClient(zap.NewNop(), "/usr/local/bin/asba", "/etc/asba.yaml")
+	client.run = fake.run
+	return client
+}
+
+func mpiJob() *types.SlurmJob {
+	return &types.SlurmJob{
+		JobID:        "1234",
+		Resources:    types.ResourceSpec{Nodes: 4, CPUsPerNode: 16},
+		IsMPIJob:     true,
+		MPIProcesses: 64,
+		MPITopology:  types.TopologyCluster,
+		Script:       "/home/alice/job.sbatch",
+	}
+}
+
+func TestAnalyzeBurstDecision(t *testing.T) {
+	fake := &fakeASBA{outputs: map[string]string{
+		"analyze": `{"should_burst": true, "recommended_action": "burst", "confidence": 0.9,
+			"cost_analysis": {"aws_cost": 8.0, "savings_percent": 40},
+			"performance_model": {"onpremise_wait_time": "2h0m0s", "aws_provision_time": "5m0s",
+				"network_latency": "1ms", "storage_latency": "1ms"}}`,
+	}}
+	client := newTestClient(fake)
+
+	decision, err := client.AnalyzeBurstDecision(context.Background(), mpiJob())
+	require.NoError(t, err)
+	assert.True(t, decision.ShouldBurst)
+	assert.Equal(t, 0.9, decision.Confidence)
+
+	args := strings.Join(fake.calls[0], " ")
+	assert.Contains(t, args, "/usr/local/bin/asba analyze /home/alice/job.sbatch --output=json --job-id=1234 --config=/etc/asba.yaml")
+	assert.Contains(t, args, "--processes=64 --nodes=4 --requires-low-latency=true")
+}
+
+func TestEnrichJobWithASBAData(t *testing.T) {
+	fake := &fakeASBA{outputs: map[string]string{
+		"analyze": `{"should_burst": true, "cost_analysis": {"aws_cost": 8.0, "savings_percent": 40},
+			"performance_model": {"onpremise_wait_time": "2h0m0s", "aws_provision_time": "5m0s",
+				"network_latency": "1ms", "storage_latency": "1ms"}}`,
+	}}
+	var req types.InstanceRequirements
+	require.NoError(t, newTestClient(fake).EnrichJobWithASBAData(context.Background(), mpiJob(), &req))
+
+	assert.InDelta(t, 1.6, req.MaxSpotPrice, 1e-9, "80% of the per-node cost")
+	assert.True(t, req.AllowMixedPricing, "a two hour wait favors reliability")
+	assert.False(t, req.PreferSpot)
+
+	// ASBA failures never fail the job
+	req = types.InstanceRequirements{MaxSpotPrice: 1.0}
+	require.NoError(t, newTestClient(&fakeASBA{}).EnrichJobWithASBAData(context.Background(), mpiJob(), &req))
+	assert.Equal(t, 1.0, req.MaxSpotPrice)
+}
+
+func TestGetRecommendedInstanceTypes(t *testing.T) {
+	fake := &fakeASBA{outputs: map[string]string{
+		"recommend-instances": `{"instance_types": ["hpc7a.12xlarge", "c6in.8xlarge"], "reasoning": "EFA"}`,
+	}}
+	instanceTypes, err := newTestClient(fake).GetRecommendedInstanceTypes(context.Background(), mpiJob())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hpc7a.12xlarge", "c6in.8xlarge"}, instanceTypes)
+	assert.Contains(t, strings.Join(fake.calls[0], " "), "--workload-type=mpi --network-intensive=true")
+
+	fake.outputs["recommend-instances"] = "not json"
+	_, err = newTestClient(fake).GetRecommendedInstanceTypes(context.Background(), mpiJob())
+	assert.Error(t, err)
+}
+
+func TestValidateASBAAvailability(t *testing.T) {
+	var client Client = newTestClient(&fakeASBA{outputs: map[string]string{"--version": "asba 1.2.0\n"}})
+	assert.NoError(t, client.ValidateASBAAvailability(context.Background()))
+
+	client = newTestClient(&fakeASBA{})
+	assert.ErrorContains(t, client.ValidateASBAAvailability(context.Background()), "ASBA command not available")
+}
+
+func TestInvokeRetriesAndBreaker(t *testing.T) {
+	dir := t.TempDir()
+	fails := 2
+	var calls int
+	client := newTestClient(&fakeASBA{})
+	client.retries = 2
+	client.breaker = NewBreaker(dir, 2, time.Minute)
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		assert.LessOrEqual(t, d, retryMaxDelay)
+		return nil
+	}
+	client.run = func(ctx context.Context, program string, args ...string) ([]byte, error) {
+		calls++
+		if calls <= fails {
+			return nil, errors.New("signal: killed")
+		}
+		return []byte(`{"should_burst": true, "schema_version": "1.1"}`), nil
+	}
+
+	plan, err := client.GenerateExecutionPlan(context.Background(), "42")
+	require.NoError(t, err, "third attempt succeeds")
+	assert.Equal(t, "1.1", plan.SchemaVersion)
+	assert.Equal(t, 3, calls)
+
+	// Two invocations that fail every attempt open the breaker
+	calls, fails = 0, 100
+	for i := 0; i < 2; i++ {
+		_, err = client.GenerateExecutionPlan(context.Background(), "42")
+		assert.ErrorContains(t, err, "signal: killed")
+	}
+	assert.Equal(t, 6, calls)
+
+	_, err = client.GenerateExecutionPlan(context.Background(), "42")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 6, calls, "an open breaker does not run asba")
+
+	availability, err := LoadAvailability(dir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), availability.Calls)
+	assert.Equal(t, int64(2), availability.Failures)
+	assert.Equal(t, int64(1), availability.Rejected)
+	assert.True(t, availability.Open(time.Now()))
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	breaker := NewBreaker(t.TempDir(), 1, 5*time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	breaker.Record(errors.New("timeout"))
+	assert.False(t, breaker.Allow())
+
+	// After the cooldown one trial call is let through; failing reopens it
+	now = now.Add(6 * time.Minute)
+	assert.True(t, breaker.Allow())
+	breaker.Record(errors.New("timeout"))
+	assert.False(t, breaker.Allow())
+
+	now = now.Add(6 * time.Minute)
+	assert.True(t, breaker.Allow())
+	breaker.Record(nil)
+	assert.True(t, breaker.Allow())
+
+	disabled := NewBreaker("", 0, time.Minute)
+	for i := 0; i < 5; i++ {
+		disabled.Record(errors.New("timeout"))
+	}
+	assert.True(t, disabled.Allow(), "threshold 0 disables the breaker")
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 10; attempt++ {
+		delay := backoff(attempt)
+		assert.Greater(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, retryMaxDelay)
+	}
+	assert.LessOrEqual(t, backoff(1), retryBaseDelay)
+}
@@ -0,0 +1,209 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.uber.org/zap"
+)
+
+// azCandidate is one availability zone a gang-scheduled launch can be pinned to
+type azCandidate struct {
+	Zone      string
+	SubnetIds []string
+	Offered   int     // Requested instance types offered in the zone
+	SpotPrice float64 // Lowest current spot price among requested types; 0 if unknown
+	SpotScore int     // Spot placement score (1-10); 0 if not scored
+}
+
+// rankAvailabilityZones groups the request's subnets by AZ and orders the AZs by
+// how likely a single-AZ launch is to succeed: zones that offer none of the
+// instance types are dropped, and spot requests prefer the best placement
+// score, then the cheapest zone.
+func (f *FleetManager) rankAvailabilityZones(ctx context.Context, req *FleetRequest, instanceTypes []string) ([]azCandidate, error) {
+	zoneOf, err := f.subnetZones(ctx, req.SubnetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := groupSubnetsByZone(req.SubnetIds, zoneOf)
+	zones := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		zones = append(zones, candidate.Zone)
+	}
+
+	offered, err := f.instanceTypeOfferingsByZone(ctx, instanceTypes, zones)
+	if err != nil {
+		// Without offering data every zone stays a candidate; the launch itself is the real test
+		f.logger.Warn("Failed to check instance type offerings, trying all availability zones", zap.Error(err))
+	}
+
+	var prices map[string]float64
+	if req.InstanceRequirements.PreferSpot {
+		if prices, err = f.lowestSpotPriceByZone(ctx, instanceTypes, zones); err != nil {
+			f.logger.Warn("Failed to get spot prices, ordering availability zones by offerings", zap.Error(err))
+		}
+	}
+
+	var ranked []azCandidate
+	for _, candidate := range candidates {
+		if offered != nil {
+			candidate.Offered = offered[candidate.Zone]
+			if candidate.Offered == 0 {
+				f.logger.Debug("Skipping availability zone without requested instance types",
+					zap.String("availability_zone", candidate.Zone))
+				continue
+			}
+		}
+		candidate.SpotPrice = prices[candidate.Zone]
+		candidate.SpotScore = req.spotScores[candidate.Zone]
+		ranked = append(ranked, candidate)
+	}
+
+	orderAZCandidates(ranked, req.InstanceRequirements.PreferSpot)
+
+	if len(ranked) == 0 {
+		return nil, fmt.Errorf("no availability zone in the target subnets offers the required instance types")
+	}
+
+	return ranked, nil
+}
+
+// groupSubnetsByZone groups subnets by AZ, keeping the configured subnet order
+func groupSubnetsByZone(subnetIds []string, zoneOf map[string]string) []azCandidate {
+	var candidates []azCandidate
+	index := make(map[string]int)
+
+	for _, subnetID := range subnetIds {
+		zone, ok := zoneOf[subnetID]
+		if !ok {
+			continue
+		}
+		if i, seen := index[zone]; seen {
+			candidates[i].SubnetIds = append(candidates[i].SubnetIds, subnetID)
+			continue
+		}
+		index[zone] = len(candidates)
+		candidates = append(candidates, azCandidate{Zone: zone, SubnetIds: []string{subnetID}})
+	}
+
+	return candidates
+}
+
+// orderAZCandidates sorts zones best-first. Spot requests try the zone with the
+// best placement score, then the cheapest known price; otherwise zones offering
+// more of the requested types go first. Ties keep the configured subnet order.
+func orderAZCandidates(candidates []azCandidate, preferSpot bool) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if preferSpot && a.SpotScore != b.SpotScore {
+			return a.SpotScore > b.SpotScore
+		}
+		if preferSpot && a.SpotPrice != b.SpotPrice {
+			if a.SpotPrice == 0 || b.SpotPrice == 0 {
+				return b.SpotPrice == 0 // Unknown prices sort last
+			}
+			return a.SpotPrice < b.SpotPrice
+		}
+		return a.Offered > b.Offered
+	})
+}
+
+// subnetZones maps subnet IDs to their availability zones
+func (f *FleetManager) subnetZones(ctx context.Context, subnetIds []string) (map[string]string, error) {
+	result, err := f.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		SubnetIds: subnetIds,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe subnets: %w", err)
+	}
+
+	zoneOf := make(map[string]string, len(result.Subnets))
+	for _, subnet := range result.Subnets {
+		zoneOf[aws.ToString(subnet.SubnetId)] = aws.ToString(subnet.AvailabilityZone)
+	}
+
+	return zoneOf, nil
+}
+
+// instanceTypeOfferingsByZone counts how many of the instance types each zone offers
+func (f *FleetManager) instanceTypeOfferingsByZone(ctx context.Context, instanceTypes, zones []string) (map[string]int, error) {
+	input := &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: types.LocationTypeAvailabilityZone,
+		Filters: []types.Filter{
+			{Name: aws.String("instance-type"), Values: instanceTypes},
+			{Name: aws.String("location"), Values: zones},
+		},
+	}
+
+	offered := make(map[string]int)
+	paginator := ec2.NewDescribeInstanceTypeOfferingsPaginator(f.ec2Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instance type offerings: %w", err)
+		}
+		for _, offering := range page.InstanceTypeOfferings {
+			offered[aws.ToString(offering.Location)]++
+		}
+	}
+
+	return offered, nil
+}
+
+// lowestSpotPriceByZone returns the lowest current spot price per zone across the instance types
+func (f *FleetManager) lowestSpotPriceByZone(ctx context.Context, instanceTypes, zones []string) (map[string]float64, error) {
+	typeEnums := make([]types.InstanceType, 0, len(instanceTypes))
+	for _, instanceType := range instanceTypes {
+		typeEnums = append(typeEnums, types.InstanceType(instanceType))
+	}
+
+	// A start time of now returns only the current price for each type and zone
+	result, err := f.ec2Client.DescribeSpotPriceHistory(ctx, &ec2.DescribeSpotPriceHistoryInput{
+		InstanceTypes:       typeEnums,
+		ProductDescriptions: []string{"Linux/UNIX"},
+		StartTime:           aws.Time(time.Now()),
+		Filters: []types.Filter{
+			{Name: aws.String("availability-zone"), Values: zones},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spot prices: %w", err)
+	}
+
+	prices := make(map[string]float64)
+	for _, entry := range result.SpotPriceHistory {
+		price, err := strconv.ParseFloat(aws.ToString(entry.SpotPrice), 64)
+		if err != nil || price <= 0 {
+			continue
+		}
+		zone := aws.ToString(entry.AvailabilityZone)
+		if current, ok := prices[zone]; !ok || price < current {
+			prices[zone] = price
+		}
+	}
+
+	return prices, nil
+}
+
+// subnetsInZone returns the subnets from subnetIds that are in the given zone
+func (f *FleetManager) subnetsInZone(ctx context.Context, subnetIds []string, zone string) ([]string, error) {
+	zoneOf, err := f.subnetZones(ctx, subnetIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range groupSubnetsByZone(subnetIds, zoneOf) {
+		if candidate.Zone == zone {
+			return candidate.SubnetIds, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no target subnet in availability zone %s", zone)
+}
@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupSubnetsByZone(t *testing.T) {
+	zoneOf := map[string]string{
+		"subnet-a1": "us-east-1a",
+		"subnet-b1": "us-east-1b",
+		"subnet-a2": "us-east-1a",
+	}
+
+	candidates := groupSubnetsByZone([]string{"subnet-b1", "subnet-a1", "subnet-unknown", "subnet-a2"}, zoneOf)
+
+	assert.Equal(t, []azCandidate{
+		{Zone: "us-east-1b", SubnetIds: []string{"subnet-b1"}},
+		{Zone: "us-east-1a", SubnetIds: []string{"subnet-a1", "subnet-a2"}},
+	}, candidates)
+}
+
+func TestOrderAZCandidates(t *testing.T) {
+	zones := func(candidates []azCandidate) []string {
+		var names []string
+		for _, candidate := range candidates {
+			names = append(names, candidate.Zone)
+		}
+		return names
+	}
+
+	t.Run("spot prefers cheapest known price", func(t *testing.T) {
+		candidates := []azCandidate{
+			{Zone: "us-east-1a", Offered: 3, SpotPrice: 0.90},
+			{Zone: "us-east-1b", Offered: 3},
+			{Zone: "us-east-1c", Offered: 1, SpotPrice: 0.45},
+		}
+		orderAZCandidates(candidates, true)
+		assert.Equal(t, []string{"us-east-1c", "us-east-1a", "us-east-1b"}, zones(candidates))
+	})
+
+	t.Run("spot placement score outranks price", func(t *testing.T) {
+		candidates := []azCandidate{
+			{Zone: "us-east-1a", Offered: 3, SpotPrice: 0.40, SpotScore: 3},
+			{Zone: "us-east-1b", Offered: 3, SpotPrice: 0.90, SpotScore: 9},
+		}
+		orderAZCandidates(candidates, true)
+		assert.Equal(t, []string{"us-east-1b", "us-east-1a"}, zones(candidates))
+	})
+
+	t.Run("on-demand prefers most offered types", func(t *testing.T) {
+		candidates := []azCandidate{
+			{Zone: "us-east-1a", Offered: 1, SpotPrice: 0.10},
+			{Zone: "us-east-1b", Offered: 3},
+			{Zone: "us-east-1c", Offered: 3},
+		}
+		orderAZCandidates(candidates, false)
+		assert.Equal(t, []string{"us-east-1b", "us-east-1c", "us-east-1a"}, zones(candidates))
+	})
+}
+
+func TestPrioritizeOverridesBySpotScore(t *testing.T) {
+	override := func(instanceType, subnetID string) types.FleetLaunchTemplateOverridesRequest {
+		return types.FleetLaunchTemplateOverridesRequest{
+			InstanceType: types.InstanceType(instanceType),
+			SubnetId:     aws.String(subnetID),
+		}
+	}
+
+	overrides := prioritizeOverridesBySpotScore(
+		[]types.FleetLaunchTemplateOverridesRequest{
+			override("c6i.xlarge", "subnet-a"),
+			override("c6i.xlarge", "subnet-b"),
+			override("c6i.xlarge", "subnet-c"),
+		},
+		map[string]string{"subnet-a": "us-east-1a", "subnet-b": "us-east-1b", "subnet-c": "us-east-1c"},
+		map[string]int{"us-east-1a": 4, "us-east-1b": 9},
+	)
+
+	var order []string
+	var priorities []float64
+	for _, o := range overrides {
+		order = append(order, aws.ToString(o.SubnetId))
+		priorities = append(priorities, aws.ToFloat64(o.Priority))
+	}
+	assert.Equal(t, []string{"subnet-b", "subnet-a", "subnet-c"}, order)
+	assert.Equal(t, []float64{1, 6, 10}, priorities)
+}
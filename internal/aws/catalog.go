@@ -0,0 +1,323 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// maxSizesPerFamily limits how many sizes of one family are offered to EC2 Fleet
+const maxSizesPerFamily = 2
+
+// InstanceCatalog caches EC2 instance type information from DescribeInstanceTypes
+type InstanceCatalog struct {
+	logger          *zap.Logger
+	ec2Client       EC2API
+	region          string
+	cachePath       string
+	refreshInterval time.Duration
+
+	mu          sync.RWMutex
+	entries     map[string]burstTypes.InstanceTypeInfo
+	refreshedAt time.Time
+}
+
+// catalogCacheFile is the on-disk representation of the catalog
+type catalogCacheFile struct {
+	Region        string                        `json:"region"`
+	RefreshedAt   time.Time                     `json:"refreshed_at"`
+	InstanceTypes []burstTypes.InstanceTypeInfo `json:"instance_types"`
+}
+
+// NewInstanceCatalog creates a new instance catalog. An empty cacheDir disables disk persistence.
+func NewInstanceCatalog(logger *zap.Logger, ec2Client EC2API, region, cacheDir string, refreshInterval time.Duration) *InstanceCatalog {
+	catalog := &InstanceCatalog{
+		logger:          logger,
+		ec2Client:       ec2Client,
+		region:          region,
+		refreshInterval: refreshInterval,
+		entries:         make(map[string]burstTypes.InstanceTypeInfo),
+	}
+
+	if cacheDir != "" {
+		catalog.cachePath = filepath.Join(cacheDir, fmt.Sprintf("instance-catalog-%s.json", region))
+	}
+
+	return catalog
+}
+
+// EnsureLoaded loads the catalog from disk or refreshes it from EC2 when stale
+func (c *InstanceCatalog) EnsureLoaded(ctx context.Context) error {
+	if !c.IsStale() {
+		return nil
+	}
+
+	if err := c.loadFromDisk(); err != nil {
+		c.logger.Debug("Instance catalog cache not usable", zap.String("path", c.cachePath), zap.Error(err))
+	}
+
+	if !c.IsStale() {
+		return nil
+	}
+
+	return c.Refresh(ctx)
+}
+
+// IsStale reports whether the catalog is empty or older than the refresh interval
+func (c *InstanceCatalog) IsStale() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.entries) == 0 {
+		return true
+	}
+	return c.refreshInterval > 0 && time.Since(c.refreshedAt) > c.refreshInterval
+}
+
+// Refresh repopulates the catalog from EC2 DescribeInstanceTypes and persists it
+func (c *InstanceCatalog) Refresh(ctx context.Context) error {
+	if c.ec2Client == nil {
+		return fmt.Errorf("no EC2 client configured for instance catalog")
+	}
+
+	entries := make(map[string]burstTypes.InstanceTypeInfo)
+	paginator := ec2.NewDescribeInstanceTypesPaginator(c.ec2Client, &ec2.DescribeInstanceTypesInput{})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to describe instance types: %w", err)
+		}
+
+		for _, info := range page.InstanceTypes {
+			entry := convertInstanceTypeInfo(info)
+			entries[entry.InstanceType] = entry
+		}
+	}
+
+	c.mu.Lock()
+	c.entries = entries
+	c.refreshedAt = time.Now()
+	c.mu.Unlock()
+
+	c.logger.Info("Instance catalog refreshed",
+		zap.String("region", c.region),
+		zap.Int("instance_types", len(entries)))
+
+	if err := c.saveToDisk(); err != nil {
+		c.logger.Warn("Failed to persist instance catalog", zap.String("path", c.cachePath), zap.Error(err))
+	}
+
+	return nil
+}
+
+// Lookup returns catalog information for an instance type
+func (c *InstanceCatalog) Lookup(instanceType string) (burstTypes.InstanceTypeInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	info, ok := c.entries[instanceType]
+	return info, ok
+}
+
+// Family returns all catalog entries of a family ordered from smallest to largest
+func (c *InstanceCatalog) Family(family string) []burstTypes.InstanceTypeInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var members []burstTypes.InstanceTypeInfo
+	for _, info := range c.entries {
+		if info.Family == family {
+			members = append(members, info)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].VCPUs != members[j].VCPUs {
+			return members[i].VCPUs < members[j].VCPUs
+		}
+		if members[i].MemoryMiB != members[j].MemoryMiB {
+			return members[i].MemoryMiB < members[j].MemoryMiB
+		}
+		return members[i].InstanceType < members[j].InstanceType
+	})
+
+	return members
+}
+
+// SizesForFamily returns the smallest instance types of a family that satisfy the requirements
+func (c *InstanceCatalog) SizesForFamily(family string, req *burstTypes.InstanceRequirements) []string {
+	var sizes []string
+	for _, info := range c.Family(family) {
+		if !fitsRequirements(info, req) {
+			continue
+		}
+		sizes = append(sizes, info.InstanceType)
+		if len(sizes) == maxSizesPerFamily {
+			break
+		}
+	}
+	return sizes
+}
+
+// Len returns the number of instance types in the catalog
+func (c *InstanceCatalog) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// fitsRequirements checks whether an instance type satisfies the job's resource requirements
+func fitsRequirements(info burstTypes.InstanceTypeInfo, req *burstTypes.InstanceRequirements) bool {
+	if req == nil {
+		return true
+	}
+	if info.VCPUs < req.MinCPUs || info.MemoryMiB < req.MinMemoryMB {
+		return false
+	}
+	if req.GPUs > 0 && info.GPUCount < req.GPUs {
+		return false
+	}
+	if req.RequiresEFA && !info.SupportsEFA {
+		return false
+	}
+	if req.Accelerator == burstTypes.AcceleratorNeuron && info.NeuronDevices == 0 {
+		return false
+	}
+	return true
+}
+
+// loadFromDisk loads a previously persisted catalog
+func (c *InstanceCatalog) loadFromDisk() error {
+	if c.cachePath == "" {
+		return fmt.Errorf("catalog persistence disabled")
+	}
+
+	data, err := os.ReadFile(c.cachePath)
+	if err != nil {
+		return err
+	}
+
+	var cache catalogCacheFile
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return fmt.Errorf("failed to parse instance catalog cache: %w", err)
+	}
+
+	if cache.Region != c.region {
+		return fmt.Errorf("catalog cache is for region %s, not %s", cache.Region, c.region)
+	}
+
+	entries := make(map[string]burstTypes.InstanceTypeInfo, len(cache.InstanceTypes))
+	for _, info := range cache.InstanceTypes {
+		entries[info.InstanceType] = info
+	}
+
+	c.mu.Lock()
+	c.entries = entries
+	c.refreshedAt = cache.RefreshedAt
+	c.mu.Unlock()
+
+	c.logger.Debug("Loaded instance catalog from disk",
+		zap.String("path", c.cachePath),
+		zap.Int("instance_types", len(entries)),
+		zap.Time("refreshed_at", cache.RefreshedAt))
+
+	return nil
+}
+
+// saveToDisk writes the catalog atomically to the cache path
+func (c *InstanceCatalog) saveToDisk() error {
+	if c.cachePath == "" {
+		return nil
+	}
+
+	c.mu.RLock()
+	cache := catalogCacheFile{
+		Region:      c.region,
+		RefreshedAt: c.refreshedAt,
+	}
+	for _, info := range c.entries {
+		cache.InstanceTypes = append(cache.InstanceTypes, info)
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(cache.InstanceTypes, func(i, j int) bool {
+		return cache.InstanceTypes[i].InstanceType < cache.InstanceTypes[j].InstanceType
+	})
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal instance catalog: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.cachePath), 0755); err != nil {
+		return fmt.Errorf("failed to create catalog cache directory: %w", err)
+	}
+
+	tmpPath := c.cachePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write instance catalog: %w", err)
+	}
+
+	return os.Rename(tmpPath, c.cachePath)
+}
+
+// convertInstanceTypeInfo converts the EC2 API representation into a catalog entry
+func convertInstanceTypeInfo(info types.InstanceTypeInfo) burstTypes.InstanceTypeInfo {
+	instanceType := string(info.InstanceType)
+	entry := burstTypes.InstanceTypeInfo{
+		InstanceType: instanceType,
+		Family:       burstTypes.InstanceFamilyOf(instanceType),
+	}
+
+	if info.VCpuInfo != nil {
+		entry.VCPUs = int(aws.ToInt32(info.VCpuInfo.DefaultVCpus))
+	}
+
+	if info.MemoryInfo != nil {
+		entry.MemoryMiB = int(aws.ToInt64(info.MemoryInfo.SizeInMiB))
+	}
+
+	if info.NetworkInfo != nil {
+		entry.SupportsEFA = aws.ToBool(info.NetworkInfo.EfaSupported)
+		entry.NetworkPerformance = aws.ToString(info.NetworkInfo.NetworkPerformance)
+		if info.NetworkInfo.EfaInfo != nil {
+			entry.MaxEFAInterfaces = int(aws.ToInt32(info.NetworkInfo.EfaInfo.MaximumEfaInterfaces))
+		}
+	}
+
+	if info.GpuInfo != nil {
+		for _, gpu := range info.GpuInfo.Gpus {
+			entry.GPUCount += int(aws.ToInt32(gpu.Count))
+			if entry.GPUType == "" {
+				entry.GPUType = strings.ToLower(aws.ToString(gpu.Name))
+			}
+		}
+	}
+
+	if info.NeuronInfo != nil {
+		for _, device := range info.NeuronInfo.NeuronDevices {
+			entry.NeuronDevices += int(aws.ToInt32(device.Count))
+		}
+	}
+
+	if info.ProcessorInfo != nil {
+		for _, arch := range info.ProcessorInfo.SupportedArchitectures {
+			entry.Architectures = append(entry.Architectures, string(arch))
+		}
+	}
+
+	return entry
+}
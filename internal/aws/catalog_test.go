@@ -0,0 +1,120 @@
+package aws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestCatalog(t *testing.T, cacheDir string) *InstanceCatalog {
+	catalog := NewInstanceCatalog(zaptest.NewLogger(t), nil, "us-east-1", cacheDir, 24*time.Hour)
+	catalog.entries = map[string]types.InstanceTypeInfo{
+		"c6i.large":    {InstanceType: "c6i.large", Family: "c6i", VCPUs: 2, MemoryMiB: 4096},
+		"c6i.xlarge":   {InstanceType: "c6i.xlarge", Family: "c6i", VCPUs: 4, MemoryMiB: 8192},
+		"c6i.2xlarge":  {InstanceType: "c6i.2xlarge", Family: "c6i", VCPUs: 8, MemoryMiB: 16384},
+		"c6i.32xlarge": {InstanceType: "c6i.32xlarge", Family: "c6i", VCPUs: 128, MemoryMiB: 262144, SupportsEFA: true},
+		"p4d.24xlarge": {InstanceType: "p4d.24xlarge", Family: "p4d", VCPUs: 96, MemoryMiB: 1179648, GPUCount: 8, SupportsEFA: true},
+	}
+	catalog.refreshedAt = time.Now()
+	return catalog
+}
+
+func TestInstanceCatalog_SizesForFamily(t *testing.T) {
+	catalog := newTestCatalog(t, "")
+
+	tests := []struct {
+		name         string
+		family       string
+		requirements *types.InstanceRequirements
+		expected     []string
+	}{
+		{
+			name:         "smallest fitting sizes first",
+			family:       "c6i",
+			requirements: &types.InstanceRequirements{MinCPUs: 4, MinMemoryMB: 6144},
+			expected:     []string{"c6i.xlarge", "c6i.2xlarge"},
+		},
+		{
+			name:         "EFA requirement filters sizes",
+			family:       "c6i",
+			requirements: &types.InstanceRequirements{MinCPUs: 2, RequiresEFA: true},
+			expected:     []string{"c6i.32xlarge"},
+		},
+		{
+			name:         "GPU requirement",
+			family:       "p4d",
+			requirements: &types.InstanceRequirements{GPUs: 4},
+			expected:     []string{"p4d.24xlarge"},
+		},
+		{
+			name:         "nothing fits",
+			family:       "c6i",
+			requirements: &types.InstanceRequirements{MinCPUs: 256},
+			expected:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, catalog.SizesForFamily(tt.family, tt.requirements))
+		})
+	}
+}
+
+func TestInstanceCatalog_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	catalog := newTestCatalog(t, dir)
+	require.NoError(t, catalog.saveToDisk())
+
+	_, err := os.Stat(filepath.Join(dir, "instance-catalog-us-east-1.json"))
+	require.NoError(t, err)
+
+	reloaded := NewInstanceCatalog(zaptest.NewLogger(t), nil, "us-east-1", dir, 24*time.Hour)
+	assert.True(t, reloaded.IsStale())
+	require.NoError(t, reloaded.loadFromDisk())
+	assert.False(t, reloaded.IsStale())
+	assert.Equal(t, 5, reloaded.Len())
+
+	info, ok := reloaded.Lookup("p4d.24xlarge")
+	require.True(t, ok)
+	assert.Equal(t, 8, info.GPUCount)
+
+	// Cache from another region must not be used
+	otherRegion := NewInstanceCatalog(zaptest.NewLogger(t), nil, "us-west-2", dir, 24*time.Hour)
+	otherRegion.cachePath = filepath.Join(dir, "instance-catalog-us-east-1.json")
+	assert.Error(t, otherRegion.loadFromDisk())
+}
+
+func TestInstanceCatalog_StaleAfterInterval(t *testing.T) {
+	catalog := newTestCatalog(t, "")
+	assert.False(t, catalog.IsStale())
+
+	catalog.refreshedAt = time.Now().Add(-48 * time.Hour)
+	assert.True(t, catalog.IsStale())
+}
+
+func TestConvertInstanceTypeInfo_Neuron(t *testing.T) {
+	info := convertInstanceTypeInfo(ec2types.InstanceTypeInfo{
+		InstanceType: ec2types.InstanceTypeTrn132xlarge,
+		VCpuInfo:     &ec2types.VCpuInfo{DefaultVCpus: aws.Int32(128)},
+		NetworkInfo:  &ec2types.NetworkInfo{EfaSupported: aws.Bool(true)},
+		NeuronInfo: &ec2types.NeuronInfo{NeuronDevices: []ec2types.NeuronDeviceInfo{
+			{Count: aws.Int32(16), Name: aws.String("Trainium")},
+		}},
+	})
+	assert.Equal(t, "trn1", info.Family)
+	assert.Equal(t, 16, info.NeuronDevices)
+	assert.True(t, info.SupportsEFA)
+
+	neuron := &types.InstanceRequirements{MinCPUs: 8, Accelerator: types.AcceleratorNeuron}
+	assert.True(t, fitsRequirements(info, neuron))
+	assert.False(t, fitsRequirements(types.InstanceTypeInfo{InstanceType: "c6i.32xlarge", VCPUs: 128}, neuron))
+}
@@ -2,11 +2,21 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/chaos"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ratelimit"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
 	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 // Client provides AWS integration functionality
@@ -15,6 +25,29 @@ type Client struct {
 	config       *config.AWSConfig
 	appConfig    *config.Config
 	fleetManager *FleetManager
+
+	// Creates the fleet manager of a region; NewFleetManager unless a test set it
+	newFleetManager func(logger *zap.Logger, awsConfig *config.AWSConfig) (*FleetManager, error)
+
+	// Fleet managers for node groups outside the default region; resume launches
+	// node groups concurrently
+	regionsMu      sync.Mutex
+	regionalFleets map[string]*FleetManager
+
+	// EKS, Auto Scaling and Kubernetes APIs of each region with container
+	// backends, created by newEKSAPI unless a test set newClusterAPI
+	clusterAPIs   map[string]*eksAPI
+	newClusterAPI func(ctx context.Context, region string) (*eksAPI, error)
+
+	// PCS and ParallelCluster APIs of each region with delegated node groups,
+	// created by newDelegateAPI unless a test replaced it
+	delegateAPIs   map[string]*delegateAPI
+	newDelegateAPI func(ctx context.Context, region string) (*delegateAPI, error)
+
+	// Shared by every region's fleet manager; nil limiters never wait
+	launchLimiter    *ratelimit.Limiter
+	terminateLimiter *ratelimit.Limiter
+	faults           *chaos.Injector
 }
 
 // LaunchRequest represents a request to launch AWS instances
@@ -24,42 +57,480 @@ type LaunchRequest struct {
 	NodeGroup            string
 	InstanceRequirements *types.InstanceRequirements
 	Job                  *types.SlurmJob
+	NodeRoles            []NodeRoleLaunch     // Optional heterogeneous per-role launches
+	UserDataParts        []userdata.Part      // Added to the launch template's user data
+	SpotStrategy         *SpotPricingStrategy // On-demand/spot split from PlanSpotStrategy
+
+	// Use the node group's launch_template_overrides as configured (spot price,
+	// subnet, weight) instead of generating them; set in standalone mode
+	UseConfiguredOverrides bool
+
+	CapacityReservations bool // Launch on-demand capacity into open capacity reservations first
+	QueueForCapacity     bool // All spot, never falling back to on-demand; a shortfall fails so the job waits
+
+	// Launch into this EC2 Capacity Block instead of buying capacity
+	CapacityBlock *types.CapacityBlockSpec
+
+	// The job is containerized, so its node group's container backend, when it
+	// has one, launches the nodes instead of EC2 Fleet
+	Containerized bool
+
+	// Launch fresh instances only, never restarting suspended or warm pool
+	// instances, which run the AMI they were launched with; set to test an AMI
+	NewInstancesOnly bool
+
+	// Give each instance this scratch volume for the job's burst buffer directives
+	Scratch *ScratchVolume
 }
 
 // LaunchResult represents the result of launching instances
 type LaunchResult struct {
-	Instances []types.InstanceInfo
-	FleetId   string
+	Instances           []types.InstanceInfo
+	FleetId             string
+	SpotPlacementScores map[string]int         // Spot placement score by AZ, when scored
+	Fallbacks           []types.LaunchFallback // Retries after capacity failures, in order
+	WarmPoolInstances   int                    // Instances started from the warm pool
+	RestartedInstances  int                    // Suspended instances restarted
+	LingeringInstances  int                    // Lingering instances claimed
+
+	// Nodes served by claimed lingering instances, whose slurmd the caller
+	// must start as the new node
+	ClaimedNodes []string
+}
+
+// ServedNodes returns the nodes the result has instances for
+func (r *LaunchResult) ServedNodes() []string {
+	if r == nil {
+		return nil
+	}
+	nodes := make([]string, 0, len(r.Instances))
+	for _, instance := range r.Instances {
+		nodes = append(nodes, instance.NodeName)
+	}
+	return nodes
+}
+
+// merge adds a later launch for the nodes a partial result did not serve
+func (r *LaunchResult) merge(later *LaunchResult) *LaunchResult {
+	if r == nil {
+		return later
+	}
+	if later == nil {
+		return r
+	}
+	merged := *later
+	merged.Instances = append(slices.Clone(r.Instances), later.Instances...)
+	merged.WarmPoolInstances += r.WarmPoolInstances
+	merged.RestartedInstances += r.RestartedInstances
+	merged.LingeringInstances += r.LingeringInstances
+	merged.ClaimedNodes = append(slices.Clone(r.ClaimedNodes), later.ClaimedNodes...)
+	return &merged
+}
+
+// WithNodes copies the request for only the given nodes, and their node roles
+func (r *LaunchRequest) WithNodes(nodeIds []string) *LaunchRequest {
+	keep := func(node string) bool { return !slices.Contains(nodeIds, node) }
+	copied := *r
+	copied.NodeIds = slices.DeleteFunc(slices.Clone(r.NodeIds), keep)
+	copied.NodeRoles = nil
+	for _, role := range r.NodeRoles {
+		role.NodeIds = slices.DeleteFunc(slices.Clone(role.NodeIds), keep)
+		if len(role.NodeIds) > 0 {
+			copied.NodeRoles = append(copied.NodeRoles, role)
+		}
+	}
+	return &copied
+}
+
+// WithoutNodes copies the request without the given nodes
+func (r *LaunchRequest) WithoutNodes(nodeIds []string) *LaunchRequest {
+	return r.WithNodes(slices.DeleteFunc(slices.Clone(r.NodeIds), func(node string) bool {
+		return slices.Contains(nodeIds, node)
+	}))
 }
 
 // NewClient creates a new AWS client
 func NewClient(logger *zap.Logger, awsConfig *config.AWSConfig, appConfig *config.Config) (*Client, error) {
-	fleetManager, err := NewFleetManager(logger, awsConfig)
+	return newClient(logger, awsConfig, appConfig, NewFleetManager)
+}
+
+// NewClientWithEC2API creates an AWS client whose fleet managers, in every
+// region, call ec2Client instead of EC2; tests pass a fake
+func NewClientWithEC2API(logger *zap.Logger, awsConfig *config.AWSConfig, appConfig *config.Config, ec2Client EC2API) *Client {
+	client, _ := newClient(logger, awsConfig, appConfig, func(logger *zap.Logger, awsConfig *config.AWSConfig) (*FleetManager, error) {
+		return NewFleetManagerWithAPI(logger, awsConfig, ec2Client), nil
+	})
+	return client
+}
+
+func newClient(logger *zap.Logger, awsConfig *config.AWSConfig, appConfig *config.Config,
+	newFleetManager func(logger *zap.Logger, awsConfig *config.AWSConfig) (*FleetManager, error)) (*Client, error) {
+	fleetManager, err := newFleetManager(logger, awsConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create fleet manager: %w", err)
 	}
 
-	return &Client{
-		logger:       logger,
-		config:       awsConfig,
-		appConfig:    appConfig,
-		fleetManager: fleetManager,
-	}, nil
+	client := &Client{
+		logger:          logger,
+		config:          awsConfig,
+		appConfig:       appConfig,
+		fleetManager:    fleetManager,
+		newFleetManager: newFleetManager,
+	}
+
+	// Cap node launches and terminations at Slurm's resume and suspend rates
+	if appConfig != nil {
+		client.launchLimiter = ratelimit.New(appConfig.Slurm.RateLimitDir, "launch", appConfig.Slurm.ResumeRate)
+		client.terminateLimiter = ratelimit.New(appConfig.Slurm.RateLimitDir, "terminate", appConfig.Slurm.SuspendRate)
+		client.faults = chaos.New(logger, appConfig.FailureInjection)
+	}
+	client.applyLimiters(fleetManager)
+
+	return client, nil
 }
 
-// LaunchInstances launches EC2 instances for the specified nodes
+// applyLimiters gives a fleet manager the client's shared rate limiters,
+// failure injection and pool locks
+func (c *Client) applyLimiters(fleetManager *FleetManager) {
+	fleetManager.launchLimiter = c.launchLimiter
+	fleetManager.terminateLimiter = c.terminateLimiter
+	fleetManager.faults = c.faults
+	if c.appConfig != nil {
+		fleetManager.poolLockDir = c.appConfig.Ecosystem.DataExchangeDir
+	}
+}
+
+// LaunchInstances launches EC2 instances for the specified nodes. When the
+// launch fails after suspended, lingering or warm pool instances started some
+// of the nodes, those nodes keep their instances: the result holds them along
+// with the error, which covers only the rest. Gang-scheduled jobs need every
+// node, so their started instances are terminated instead.
 func (c *Client) LaunchInstances(ctx context.Context, req *LaunchRequest) (*LaunchResult, error) {
 	c.logger.Info("Launching instances",
 		zap.Strings("nodes", req.NodeIds),
 		zap.String("partition", req.Partition),
 		zap.String("node_group", req.NodeGroup))
 
+	result, err := c.launch(ctx, req)
+	if err != nil && c.config.LaunchFallback.Enabled {
+		return c.launchWithFallback(ctx, req, result, err)
+	}
+
+	return result, err
+}
+
+// launch launches a request with the fleet manager for its node group's region
+func (c *Client) launch(ctx context.Context, req *LaunchRequest) (*LaunchResult, error) {
+	fleetReq, err := c.buildFleetRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fleetManager, err := c.fleetManagerFor(c.nodeGroupRegion(req.Partition, req.NodeGroup))
+	if err != nil {
+		return nil, err
+	}
+
+	// Delegated node groups launch through their PCS or ParallelCluster queue
+	if delegate := c.delegateFor(req); delegate != nil {
+		return c.launchThroughDelegate(ctx, fleetManager, req, fleetReq, *delegate)
+	}
+
+	// Containerized jobs launch through the node group's cluster
+	if backend := c.containerBackendFor(req); backend != nil {
+		return c.launchThroughCluster(ctx, fleetManager, req, fleetReq, *backend)
+	}
+
+	// Heterogeneous jobs launch one fleet per node role
+	if len(req.NodeRoles) > 0 {
+		fleetResult, err := fleetManager.LaunchNodeRoles(ctx, fleetReq, req.NodeRoles)
+		if err != nil {
+			return nil, err
+		}
+		c.recordLaunchDetails(ctx, fleetManager, req, fleetResult.templateData, fleetResult.Instances)
+		return &LaunchResult{
+			Instances:           fleetResult.Instances,
+			FleetId:             fleetResult.FleetId,
+			SpotPlacementScores: fleetResult.SpotPlacementScores,
+		}, nil
+	}
+
+	// Suspended instances restart and warm pool instances start in seconds, and
+	// lingering instances are already running; the fleet launches only the nodes
+	// none could serve. Capacity Block launches use only the block's instances.
+	nodeGroupConfig := c.findNodeGroupConfig(req.Partition, req.NodeGroup)
+	var restarted, lingering, warm *FleetResponse
+	if nodeGroupConfig != nil && nodeGroupConfig.KeepsStoppedInstances() && req.CapacityBlock == nil && !req.NewInstancesOnly {
+		restarted, fleetReq.NodeIds = fleetManager.RestartSuspendedInstances(ctx, fleetReq)
+	}
+	if len(fleetReq.NodeIds) > 0 && !req.NewInstancesOnly && lingerServes(nodeGroupConfig, fleetReq, req.NodeRoles) {
+		lingering, fleetReq.NodeIds = fleetManager.ClaimLingeringInstances(ctx, fleetReq, warmPoolName(req.Partition, req.NodeGroup))
+	}
+	if len(fleetReq.NodeIds) > 0 && !req.NewInstancesOnly && warmPoolServes(nodeGroupConfig, fleetReq, req.NodeRoles) {
+		warm, fleetReq.NodeIds = fleetManager.ClaimWarmInstances(ctx, fleetReq, warmPoolName(req.Partition, req.NodeGroup))
+	}
+
+	result := &LaunchResult{}
+	started := preLaunched(restarted, lingering, warm)
+	if started != nil {
+		result.Instances = started.Instances
+		result.RestartedInstances = instanceCount(restarted)
+		result.LingeringInstances = instanceCount(lingering)
+		result.WarmPoolInstances = instanceCount(warm)
+	}
+	if lingering != nil {
+		for _, instance := range lingering.Instances {
+			result.ClaimedNodes = append(result.ClaimedNodes, instance.NodeName)
+		}
+	}
+	if len(fleetReq.NodeIds) == 0 {
+		c.recordLaunchDetails(ctx, fleetManager, req, nil, result.Instances)
+		return result, nil
+	}
+
+	// Launch fleet
+	fleetResult, err := fleetManager.LaunchInstanceFleet(ctx, fleetReq)
+	if err != nil {
+		// Instances earlier fleet chunks launched are not left running for
+		// nodes Slurm marks down. A gang needs every node, so started
+		// instances are not left running alone; otherwise their nodes keep
+		// them and only the rest failed.
+		fleetManager.gangScheduler.cleanupPartialLaunch(ctx, fleetResult)
+		if started == nil || isGangRequest(fleetReq) {
+			fleetManager.gangScheduler.cleanupPartialLaunch(ctx, started)
+			return nil, err
+		}
+		c.recordLaunchDetails(ctx, fleetManager, req, nil, result.Instances)
+		return result, err
+	}
+
+	result.Instances = append(result.Instances, fleetResult.Instances...)
+	result.FleetId = fleetResult.FleetId
+	result.SpotPlacementScores = fleetResult.SpotPlacementScores
+	c.recordLaunchDetails(ctx, fleetManager, req, fleetResult.templateData, result.Instances)
+
+	return result, nil
+}
+
+// recordLaunchDetails records the prices, capacity and EBS volumes instances
+// launched with, for cost reporting. templateData is the launch template as
+// the launch described it, or nil to describe it again.
+func (c *Client) recordLaunchDetails(ctx context.Context, fleetManager *FleetManager, req *LaunchRequest, templateData *ec2types.ResponseLaunchTemplateData, instances []types.InstanceInfo) {
+	fleetManager.recordLaunchPrices(ctx, instances)
+	fleetManager.recordLaunchCapacity(instances)
+	if nodeGroupConfig := c.findNodeGroupConfig(req.Partition, req.NodeGroup); nodeGroupConfig != nil {
+		fleetManager.recordLaunchVolumes(ctx, nodeGroupConfig, templateData, instances)
+	}
+	if scratch := req.Scratch; scratch != nil {
+		for i := range instances {
+			instances[i].Volumes = append(slices.Clip(instances[i].Volumes), types.EBSVolume{
+				DeviceName: scratch.DeviceName,
+				VolumeType: scratch.VolumeType,
+				SizeGB:     scratch.SizeGB,
+			})
+		}
+	}
+}
+
+// SuspendInstances powers down a node group's instances per its suspend_mode:
+// terminated, or stopped/hibernated so a resume within the idle window restarts them
+func (c *Client) SuspendInstances(ctx context.Context, partition, nodeGroup string, nodeNames []string) error {
+	region := c.nodeGroupRegion(partition, nodeGroup)
+	nodeGroupConfig := c.findNodeGroupConfig(partition, nodeGroup)
+	if nodeGroupConfig == nil || !nodeGroupConfig.KeepsStoppedInstances() {
+		return c.terminateInRegion(ctx, region, nodeNames)
+	}
+
+	fleetManager, err := c.fleetManagerFor(region)
+	if err != nil {
+		return err
+	}
+
+	hibernate := nodeGroupConfig.EffectiveSuspendMode() == config.SuspendModeHibernate
+	missing, err := fleetManager.StopInstances(ctx, nodeNames, hibernate)
+	if err != nil {
+		return err
+	}
+
+	// Nodes launched elsewhere by a fallback are terminated wherever they are
+	if len(missing) > 0 {
+		return c.terminateInRegion(ctx, region, missing)
+	}
+	return nil
+}
+
+// LingerInstances moves a lingering node group's nodes' instances into its
+// linger pool instead of terminating them. The nodes' slurmd must already be
+// stopped. It returns the nodes with no running instance in the node group's
+// region.
+func (c *Client) LingerInstances(ctx context.Context, partition, nodeGroup string, nodeNames []string) ([]string, error) {
+	nodeGroupConfig := c.findNodeGroupConfig(partition, nodeGroup)
+	if nodeGroupConfig == nil || !nodeGroupConfig.Linger.Enabled() {
+		return nil, fmt.Errorf("partition '%s' nodegroup '%s' does not linger", partition, nodeGroup)
+	}
+	fleetManager, err := c.fleetManagerFor(c.nodeGroupRegion(partition, nodeGroup))
+	if err != nil {
+		return nil, err
+	}
+	return fleetManager.LingerInstances(ctx, warmPoolName(partition, nodeGroup), nodeNames, nodeGroupConfig.Linger.Window())
+}
+
+// ReapLingeringInstances terminates lingering instances whose node group's
+// linger window ended without a resume claiming them
+func (c *Client) ReapLingeringInstances(ctx context.Context, dryRun bool) ([]string, error) {
+	var reaped []string
+	var failed []string
+
+	for _, partition := range c.appConfig.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			if !nodeGroup.Linger.Enabled() {
+				continue
+			}
+
+			pool := warmPoolName(partition.PartitionName, nodeGroup.NodeGroupName)
+			fleetManager, err := c.fleetManagerFor(c.nodeGroupRegion(partition.PartitionName, nodeGroup.NodeGroupName))
+			if err == nil {
+				var expired []string
+				expired, err = fleetManager.ReapLingeringInstances(ctx, pool, dryRun)
+				reaped = append(reaped, expired...)
+			}
+			if err != nil {
+				c.logger.Error("Failed to reap lingering instances", zap.String("pool", pool), zap.Error(err))
+				failed = append(failed, pool)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return reaped, fmt.Errorf("failed to reap lingering instances: %s", strings.Join(failed, ", "))
+	}
+	return reaped, nil
+}
+
+// ReapSuspendedInstances terminates stopped instances idle past their node group's
+// terminate_after_idle_minutes
+func (c *Client) ReapSuspendedInstances(ctx context.Context, dryRun bool) ([]string, error) {
+	var reaped []string
+	var failed []string
+
+	for _, partition := range c.appConfig.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			if !nodeGroup.KeepsStoppedInstances() {
+				continue
+			}
+
+			name := partition.PartitionName + "/" + nodeGroup.NodeGroupName
+			fleetManager, err := c.fleetManagerFor(c.nodeGroupRegion(partition.PartitionName, nodeGroup.NodeGroupName))
+			if err == nil {
+				var expired []string
+				idle := time.Duration(nodeGroup.TerminateAfterIdleMinutes) * time.Minute
+				expired, err = fleetManager.ReapSuspendedInstances(ctx, partition.PartitionName, nodeGroup.NodeGroupName, idle, dryRun)
+				reaped = append(reaped, expired...)
+			}
+			if err != nil {
+				c.logger.Error("Failed to reap suspended instances", zap.String("node_group", name), zap.Error(err))
+				failed = append(failed, name)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return reaped, fmt.Errorf("failed to reap suspended instances: %s", strings.Join(failed, ", "))
+	}
+	return reaped, nil
+}
+
+// instanceCount returns the number of instances in a possibly nil response
+func instanceCount(response *FleetResponse) int {
+	if response == nil {
+		return 0
+	}
+	return len(response.Instances)
+}
+
+// ReplenishWarmPools runs one replenishment pass over every configured warm pool
+func (c *Client) ReplenishWarmPools(ctx context.Context, dryRun bool) ([]*WarmPoolReport, error) {
+	var reports []*WarmPoolReport
+	var failed []string
+
+	for _, partition := range c.appConfig.Slurm.Partitions {
+		for i := range partition.NodeGroups {
+			nodeGroup := &partition.NodeGroups[i]
+			if !nodeGroup.WarmPool.Enabled() {
+				continue
+			}
+
+			spec := WarmPoolSpecFor(partition.PartitionName, nodeGroup)
+			fleetManager, err := c.fleetManagerFor(c.nodeGroupRegion(spec.Partition, spec.NodeGroup))
+			if err == nil {
+				var report *WarmPoolReport
+				report, err = fleetManager.ReplenishWarmPool(ctx, spec, dryRun)
+				if report != nil {
+					reports = append(reports, report)
+				}
+			}
+			if err != nil {
+				c.logger.Error("Failed to replenish warm pool", zap.String("pool", spec.Name()), zap.Error(err))
+				failed = append(failed, spec.Name())
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return reports, fmt.Errorf("failed to replenish warm pools: %s", strings.Join(failed, ", "))
+	}
+	return reports, nil
+}
+
+// PlanSpotStrategy decides how much of a spot or mixed launch runs on spot and
+// checks current spot prices against MaxSpotPrice. It returns nil for on-demand
+// launches, and an error when spot is over budget and on-demand fallback is off.
+func (c *Client) PlanSpotStrategy(ctx context.Context, req *LaunchRequest) (*SpotPricingStrategy, error) {
+	if req.InstanceRequirements == nil ||
+		(!req.InstanceRequirements.PreferSpot && !req.InstanceRequirements.AllowMixedPricing) {
+		return nil, nil
+	}
+
+	fleetReq, err := c.buildFleetRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy, err := c.fleetManager.spotManager.OptimizeSpotStrategy(ctx, fleetReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine spot strategy: %w", err)
+	}
+	if req.QueueForCapacity {
+		strategy.SpotAllocationRatio = 1
+		strategy.OnDemandFallback = false
+	}
+
+	// Price the concrete instance types the fleet would launch, not bare families
+	requirements := *fleetReq.InstanceRequirements
+	requirements.InstanceFamilies = c.fleetManager.selectInstanceTypes(fleetReq.InstanceRequirements)
+	priced := *fleetReq
+	priced.InstanceRequirements = &requirements
+
+	if err := c.fleetManager.spotManager.ValidateSpotPricing(ctx, &priced, strategy); err != nil {
+		return nil, err
+	}
+
+	return strategy, nil
+}
+
+// buildFleetRequest resolves the node group's AWS settings for a launch request
+func (c *Client) buildFleetRequest(req *LaunchRequest) (*FleetRequest, error) {
 	// Find node group configuration to get AWS settings
 	nodeGroupConfig := c.findNodeGroupConfig(req.Partition, req.NodeGroup)
 	if nodeGroupConfig == nil {
 		return nil, fmt.Errorf("no configuration found for partition '%s' nodegroup '%s'", req.Partition, req.NodeGroup)
 	}
 
+	// Default to the node group's architecture so x86 jobs never land on ARM nodes
+	if req.InstanceRequirements != nil && req.InstanceRequirements.Architecture == "" {
+		req.InstanceRequirements.Architecture = nodeGroupConfig.EffectiveArchitecture()
+	}
+
 	// Build fleet request from launch request
 	fleetReq := &FleetRequest{
 		NodeIds:              req.NodeIds,
@@ -80,23 +551,187 @@ func (c *Client) LaunchInstances(ctx context.Context, req *LaunchRequest) (*Laun
 			"ManagedBy": "aws-slurm-burst",
 			"JobID":     req.Job.JobID,
 		},
+		UserDataParts:   req.UserDataParts,
+		SpotStrategy:    req.SpotStrategy,
+		SpotOptions:     nodeGroupConfig.SpotOptions,
+		OnDemandOptions: nodeGroupConfig.OnDemandOptions,
 	}
-
-	// Launch fleet
-	fleetResult, err := c.fleetManager.LaunchInstanceFleet(ctx, fleetReq)
-	if err != nil {
-		return nil, err
+	if req.UseConfiguredOverrides {
+		fleetReq.ConfiguredOverrides = nodeGroupConfig.LaunchTemplateOverrides
+	}
+	if req.CapacityReservations {
+		fleetReq.OnDemandOptions.CapacityReservationPreference = string(ec2types.FleetCapacityReservationUsageStrategyUseCapacityReservationsFirst)
+	}
+	fleetReq.CapacityBlock = req.CapacityBlock
+	fleetReq.Scratch = req.Scratch
+	if bootstrap := c.appConfig.NodeBootstrap; bootstrap.Enabled {
+		fleetReq.NodeBootstrap = &userdata.NodeBootstrap{
+			Distro:         userdata.Distro(bootstrap.Distro),
+			ConfServer:     bootstrap.ConfServer,
+			InstallCommand: bootstrap.InstallCommand,
+		}
+	}
+	switch mungeKey := c.appConfig.MungeKey; mungeKey.Source {
+	case config.MungeKeySourceSSM:
+		fleetReq.MungeKey = &userdata.MungeKey{Parameter: mungeKey.Parameter}
+	case config.MungeKeySourceS3:
+		fleetReq.MungeKey = &userdata.MungeKey{S3URL: mungeKey.S3URL}
 	}
 
-	return &LaunchResult{
-		Instances: fleetResult.Instances,
-		FleetId:   fleetResult.FleetId,
-	}, nil
+	return fleetReq, nil
 }
 
 // TerminateInstances terminates instances for the specified node names
 func (c *Client) TerminateInstances(ctx context.Context, nodeNames []string) error {
-	return c.fleetManager.TerminateInstances(ctx, nodeNames)
+	return c.terminateInRegion(ctx, c.config.Region, nodeNames)
+}
+
+// TerminateNodeGroupInstances terminates instances of a node group's nodes in
+// the node group's region
+func (c *Client) TerminateNodeGroupInstances(ctx context.Context, partition, nodeGroup string, nodeNames []string) error {
+	return c.terminateInRegion(ctx, c.nodeGroupRegion(partition, nodeGroup), nodeNames)
+}
+
+// terminateInRegion terminates nodes' instances in region and in the secondary
+// node group's region, where fallback launches may have placed them
+func (c *Client) terminateInRegion(ctx context.Context, region string, nodeNames []string) error {
+	fleetManager, err := c.fleetManagerFor(region)
+	if err != nil {
+		return err
+	}
+	// Instances a cluster launched go back to it, or it would replace them
+	if c.clusterBacked() {
+		if nodeNames, err = c.releaseClusterNodes(ctx, region, fleetManager, nodeNames); err != nil {
+			return err
+		}
+	}
+	// Delegates' capacity drops first, or they would replace the instances
+	if c.delegated() {
+		if err := c.releaseDelegatedNodes(ctx, region, fleetManager, nodeNames); err != nil {
+			return err
+		}
+	}
+	if err := fleetManager.TerminateInstances(ctx, nodeNames); err != nil {
+		return err
+	}
+
+	fallback := c.config.LaunchFallback
+	if !fallback.Enabled || fallback.SecondaryNodeGroup == "" {
+		return nil
+	}
+	fallbackRegion := c.nodeGroupRegion(fallback.SecondaryPartition, fallback.SecondaryNodeGroup)
+	if fallbackRegion == region {
+		return nil
+	}
+
+	fleetManager, err = c.fleetManagerFor(fallbackRegion)
+	if err != nil {
+		return err
+	}
+	return fleetManager.TerminateInstances(ctx, nodeNames)
+}
+
+// NodeGroupSuspend is the nodes of one node group to power down
+type NodeGroupSuspend struct {
+	Partition string
+	NodeGroup string
+	NodeNames []string
+}
+
+// SuspendNodeGroups powers down several node groups' nodes concurrently. Node
+// groups whose instances terminate are batched into one termination per region;
+// node groups that stop or hibernate are suspended individually. Every failure
+// is returned, without stopping the other node groups.
+func (c *Client) SuspendNodeGroups(ctx context.Context, groups []NodeGroupSuspend) error {
+	terminateByRegion, keepStopped := c.planSuspend(groups)
+
+	var mu sync.Mutex
+	var errs []error
+	record := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+
+	var group errgroup.Group
+	for region, nodeNames := range terminateByRegion {
+		group.Go(func() error {
+			if err := c.terminateInRegion(ctx, region, nodeNames); err != nil {
+				record(fmt.Errorf("region %s: %w", region, err))
+			}
+			return nil
+		})
+	}
+	for _, suspend := range keepStopped {
+		group.Go(func() error {
+			if err := c.SuspendInstances(ctx, suspend.Partition, suspend.NodeGroup, suspend.NodeNames); err != nil {
+				record(fmt.Errorf("partition '%s' nodegroup '%s': %w", suspend.Partition, suspend.NodeGroup, err))
+			}
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	return errors.Join(errs...)
+}
+
+// planSuspend splits node groups into nodes to terminate, by region, and node
+// groups that keep stopped instances
+func (c *Client) planSuspend(groups []NodeGroupSuspend) (map[string][]string, []NodeGroupSuspend) {
+	terminateByRegion := make(map[string][]string)
+	var keepStopped []NodeGroupSuspend
+	for _, group := range groups {
+		nodeGroupConfig := c.findNodeGroupConfig(group.Partition, group.NodeGroup)
+		if nodeGroupConfig != nil && nodeGroupConfig.KeepsStoppedInstances() {
+			keepStopped = append(keepStopped, group)
+			continue
+		}
+		region := c.nodeGroupRegion(group.Partition, group.NodeGroup)
+		terminateByRegion[region] = append(terminateByRegion[region], group.NodeNames...)
+	}
+	return terminateByRegion, keepStopped
+}
+
+// nodeGroupRegion returns the region a node group launches in
+func (c *Client) nodeGroupRegion(partition, nodeGroup string) string {
+	if nodeGroupConfig := c.findNodeGroupConfig(partition, nodeGroup); nodeGroupConfig != nil && nodeGroupConfig.Region != "" {
+		return nodeGroupConfig.Region
+	}
+	return c.config.Region
+}
+
+// fleetManagerFor returns the fleet manager for a region, creating one on first use
+// for regions other than the default
+func (c *Client) fleetManagerFor(region string) (*FleetManager, error) {
+	if region == "" || region == c.config.Region {
+		return c.fleetManager, nil
+	}
+
+	c.regionsMu.Lock()
+	defer c.regionsMu.Unlock()
+	if fleetManager, ok := c.regionalFleets[region]; ok {
+		return fleetManager, nil
+	}
+
+	regionalConfig := *c.config
+	regionalConfig.Region = region
+	newFleetManager := c.newFleetManager
+	if newFleetManager == nil {
+		newFleetManager = NewFleetManager
+	}
+	fleetManager, err := newFleetManager(c.logger, &regionalConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fleet manager for region %s: %w", region, err)
+	}
+
+	c.applyLimiters(fleetManager)
+
+	if c.regionalFleets == nil {
+		c.regionalFleets = make(map[string]*FleetManager)
+	}
+	c.regionalFleets[region] = fleetManager
+
+	return fleetManager, nil
 }
 
 // findNodeGroupConfig finds the configuration for a specific partition and node group
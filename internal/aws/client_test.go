@@ -0,0 +1,305 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/chaos"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ratelimit"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// newFakeEC2Client returns a client calling a fake EC2, with partition "aws"
+// holding node group "cpu", which terminates on suspend, node group "stop",
+// which stops, and node group "linger", whose instances linger for five minutes
+func newFakeEC2Client(t *testing.T) (*Client, *fakeEC2) {
+	nodeGroup := func(name, suspendMode string) config.NodeGroupConfig {
+		return config.NodeGroupConfig{
+			NodeGroupName:      name,
+			LaunchTemplateSpec: config.LaunchTemplateSpec{LaunchTemplateName: "compute", Version: "$Latest"},
+			SubnetIds:          []string{"subnet-a"},
+			SuspendMode:        suspendMode,
+		}
+	}
+	appConfig := &config.Config{Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{{
+		PartitionName: "aws",
+		NodeGroups:    []config.NodeGroupConfig{nodeGroup("cpu", ""), nodeGroup("stop", config.SuspendModeStop), nodeGroup("linger", "")},
+	}}}}
+	appConfig.Slurm.Partitions[0].NodeGroups[2].Linger = config.LingerConfig{Seconds: 300}
+
+	ec2 := &fakeEC2{}
+	return NewClientWithEC2API(zaptest.NewLogger(t), &config.AWSConfig{Region: "us-east-1"}, appConfig, ec2), ec2
+}
+
+func TestClient_LaunchInstances(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+
+	result, err := client.LaunchInstances(context.Background(), &LaunchRequest{
+		NodeIds:              []string{"aws-cpu-1", "aws-cpu-2"},
+		Partition:            "aws",
+		NodeGroup:            "cpu",
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}},
+		Job:                  &burstTypes.SlurmJob{JobID: "42"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, ec2.fleets, 1)
+	fleet := ec2.fleets[0]
+	assert.Equal(t, int32(2), aws.ToInt32(fleet.TargetCapacitySpecification.TotalTargetCapacity))
+	assert.Equal(t, "compute", aws.ToString(fleet.LaunchTemplateConfigs[0].LaunchTemplateSpecification.LaunchTemplateName))
+
+	assert.Equal(t, "fleet-1", result.FleetId)
+	require.Len(t, result.Instances, 2)
+	for i, nodeName := range []string{"aws-cpu-1", "aws-cpu-2"} {
+		assert.Equal(t, nodeName, result.Instances[i].NodeName)
+		assert.Equal(t, "running", result.Instances[i].State)
+		assert.Equal(t, 0.096, result.Instances[i].OnDemandPriceUSD, "priced at launch")
+		assert.Equal(t, nodeName, nodeNameOf(ec2.instances[i]), "instance tagged with its node")
+	}
+}
+
+func TestClient_LaunchInstances_NewInstancesOnly(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	ec2.addInstance("i-stopped", "aws-stop-1", "")
+	ec2.setState([]string{"i-stopped"}, types.InstanceStateNameStopped)
+
+	result, err := client.LaunchInstances(context.Background(), &LaunchRequest{
+		NodeIds:                []string{"aws-stop-1"},
+		Partition:              "aws",
+		NodeGroup:              "stop",
+		InstanceRequirements:   &burstTypes.InstanceRequirements{},
+		Job:                    &burstTypes.SlurmJob{JobID: "ami-test"},
+		UseConfiguredOverrides: true,
+		NewInstancesOnly:       true,
+	})
+	require.NoError(t, err)
+	require.Len(t, ec2.fleets, 1, "the suspended instance is not restarted")
+	require.Len(t, result.Instances, 1)
+	assert.Equal(t, "i-1", result.Instances[0].InstanceID)
+	assert.Zero(t, result.RestartedInstances)
+
+	require.NoError(t, client.TerminateNodeGroupInstances(context.Background(), "aws", "stop", []string{"aws-stop-1"}))
+	assert.Contains(t, ec2.terminated, "i-1")
+}
+
+func TestClient_PurchasingFlags(t *testing.T) {
+	client, _ := newFakeEC2Client(t)
+	req := func() *LaunchRequest {
+		return &LaunchRequest{
+			NodeIds:              []string{"aws-cpu-1", "aws-cpu-2"},
+			Partition:            "aws",
+			NodeGroup:            "cpu",
+			InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}, PreferSpot: true},
+			Job:                  &burstTypes.SlurmJob{JobID: "42"},
+		}
+	}
+
+	reserved := req()
+	reserved.CapacityReservations = true
+	fleetReq, err := client.buildFleetRequest(reserved)
+	require.NoError(t, err)
+	assert.Equal(t, "use-capacity-reservations-first", fleetReq.OnDemandOptions.CapacityReservationPreference)
+
+	queued := req()
+	queued.QueueForCapacity = true
+	strategy, err := client.PlanSpotStrategy(context.Background(), queued)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, strategy.SpotAllocationRatio, "all spot")
+	assert.False(t, strategy.OnDemandFallback)
+
+	// Spot is $0.04 an hour
+	queued.InstanceRequirements.MaxSpotPrice = 0.01
+	_, err = client.PlanSpotStrategy(context.Background(), queued)
+	assert.Error(t, err, "over the price cap the job waits rather than launching on demand")
+}
+
+func TestClient_LaunchInstances_CapacityBlock(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	ec2.templateData = &types.ResponseLaunchTemplateData{}
+	req := &LaunchRequest{
+		NodeIds:              []string{"aws-cpu-1", "aws-cpu-2"},
+		Partition:            "aws",
+		NodeGroup:            "cpu",
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"p5.48xlarge"}},
+		Job:                  &burstTypes.SlurmJob{JobID: "42"},
+		CapacityBlock:        &burstTypes.CapacityBlockSpec{ReservationID: "cr-0123456789abcdef0"},
+	}
+	client.config.LaunchFallback = config.LaunchFallbackConfig{Enabled: true, OnDemand: true, AlternateFamilies: map[string][]string{"p5": {"p4d"}}}
+	assert.Empty(t, client.fallbackStages(req), "nothing but the block may be used")
+
+	_, err := client.LaunchInstances(context.Background(), req)
+	require.NoError(t, err)
+
+	require.Len(t, ec2.versions, 1)
+	data := ec2.versions[0].LaunchTemplateData
+	assert.Equal(t, types.MarketTypeCapacityBlock, data.InstanceMarketOptions.MarketType)
+	assert.Equal(t, "cr-0123456789abcdef0", aws.ToString(data.CapacityReservationSpecification.CapacityReservationTarget.CapacityReservationId))
+	assert.Nil(t, data.UserData, "the template's user data is kept")
+
+	require.Len(t, ec2.fleets, 1)
+	fleet := ec2.fleets[0]
+	assert.Equal(t, types.DefaultTargetCapacityTypeCapacityBlock, fleet.TargetCapacitySpecification.DefaultTargetCapacityType)
+	assert.Equal(t, "2", aws.ToString(fleet.LaunchTemplateConfigs[0].LaunchTemplateSpecification.Version), "launched from the block's version")
+	assert.Nil(t, fleet.OnDemandOptions)
+}
+
+func TestClient_LaunchInstances_NodeBootstrap(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	client.appConfig.NodeBootstrap = config.NodeBootstrapConfig{Enabled: true, ConfServer: "head-node"}
+	client.appConfig.MungeKey = config.MungeKeyConfig{Source: config.MungeKeySourceSSM, Parameter: "/slurm/munge-key"}
+	ec2.templateData = &types.ResponseLaunchTemplateData{ImageId: aws.String("ami-0123")}
+	ec2.image = types.Image{Name: aws.String("ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20240701"), Architecture: types.ArchitectureValuesX8664}
+	launch := func() (*LaunchResult, error) {
+		return client.LaunchInstances(context.Background(), &LaunchRequest{
+			NodeIds:              []string{"aws-cpu-1"},
+			Partition:            "aws",
+			NodeGroup:            "cpu",
+			InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}},
+			Job:                  &burstTypes.SlurmJob{JobID: "42"},
+		})
+	}
+
+	_, err := launch()
+	require.NoError(t, err)
+	require.Len(t, ec2.versions, 1)
+	userData, err := base64.StdEncoding.DecodeString(aws.ToString(ec2.versions[0].LaunchTemplateData.UserData))
+	require.NoError(t, err)
+	assert.Contains(t, string(userData), "apt-get install -y munge slurmd", "the AMI's distribution was detected")
+	assert.Contains(t, string(userData), userdata.SlurmdStartFilename)
+	assert.Contains(t, string(userData), userdata.MungeKeyFilename)
+	assert.Contains(t, string(userData), `--name "/slurm/munge-key"`)
+
+	ec2.image.Name = aws.String("Windows_Server-2022-English-Full-Base")
+	ec2.image.Platform = types.PlatformValuesWindows
+	_, err = launch()
+	assert.ErrorContains(t, err, "runs Windows")
+
+	ec2.image = types.Image{Name: aws.String("site-image-v7"), Architecture: types.ArchitectureValuesX8664}
+	_, err = launch()
+	assert.ErrorContains(t, err, "set node_bootstrap.distro")
+	client.appConfig.NodeBootstrap.Distro = "rocky9"
+	_, err = launch()
+	require.NoError(t, err)
+	require.Len(t, ec2.versions, 2)
+}
+
+func TestClient_LaunchInstances_InjectedFailure(t *testing.T) {
+	t.Setenv(config.FailureInjectionEnv, "1")
+	client, ec2 := newFakeEC2Client(t)
+	client.faults = chaos.New(zaptest.NewLogger(t), config.FailureInjectionConfig{Enabled: true, FleetErrorRate: 1})
+	client.applyLimiters(client.fleetManager)
+
+	_, err := client.LaunchInstances(context.Background(), &LaunchRequest{
+		NodeIds:              []string{"aws-cpu-1"},
+		Partition:            "aws",
+		NodeGroup:            "cpu",
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}},
+		Job:                  &burstTypes.SlurmJob{JobID: "42"},
+	})
+
+	var launchErr *FleetLaunchError
+	require.ErrorAs(t, err, &launchErr)
+	assert.Equal(t, "InsufficientInstanceCapacity", capacityErrorCode(err), "fails like a launch without capacity")
+	assert.Empty(t, ec2.fleets, "no fleet created")
+}
+
+func TestClient_LaunchInstances_LaterChunkFails(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	// Chunks of a minute's budget; the second chunk waits a tenth of a second
+	client.launchLimiter = ratelimit.New("", "launch", 600)
+	client.applyLimiters(client.fleetManager)
+	ec2.fleetCapacity = 1
+
+	var nodeIds []string
+	for i := 1; i <= 601; i++ {
+		nodeIds = append(nodeIds, fmt.Sprintf("aws-cpu-%d", i))
+	}
+	result, err := client.LaunchInstances(context.Background(), &LaunchRequest{
+		NodeIds:              nodeIds,
+		Partition:            "aws",
+		NodeGroup:            "cpu",
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}},
+		Job:                  &burstTypes.SlurmJob{JobID: "42"},
+	})
+
+	var launchErr *FleetLaunchError
+	require.ErrorAs(t, err, &launchErr)
+	assert.Nil(t, result)
+	require.Len(t, ec2.fleets, 2, "the second chunk found no capacity")
+	require.Len(t, ec2.instances, 600, "the first chunk launched")
+	for _, instance := range ec2.instances {
+		assert.NotEqual(t, types.InstanceStateNameRunning, instance.State.Name, "%s left running", aws.ToString(instance.InstanceId))
+	}
+	assert.Len(t, ec2.terminated, 600)
+}
+
+func TestClient_LaunchInstances_FleetFailsAfterRestart(t *testing.T) {
+	launch := func(t *testing.T, gang bool) (*LaunchResult, error, *fakeEC2) {
+		t.Setenv(config.FailureInjectionEnv, "1")
+		client, ec2 := newFakeEC2Client(t)
+		client.faults = chaos.New(zaptest.NewLogger(t), config.FailureInjectionConfig{Enabled: true, FleetErrorRate: 1})
+		client.applyLimiters(client.fleetManager)
+		ec2.addInstance("i-stopped", "aws-stop-1", "")
+		ec2.setState([]string{"i-stopped"}, types.InstanceStateNameStopped)
+		ec2.instances[0].Tags = append(ec2.instances[0].Tags, types.Tag{Key: aws.String(suspendedAtTag), Value: aws.String("2026-01-01T00:00:00Z")})
+
+		result, err := client.LaunchInstances(context.Background(), &LaunchRequest{
+			NodeIds:              []string{"aws-stop-1", "aws-stop-2"},
+			Partition:            "aws",
+			NodeGroup:            "stop",
+			InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}, RequiresEFA: gang},
+			Job:                  &burstTypes.SlurmJob{JobID: "42", IsMPIJob: gang},
+		})
+		return result, err, ec2
+	}
+
+	t.Run("restarted node keeps its instance", func(t *testing.T) {
+		result, err, ec2 := launch(t, false)
+
+		var launchErr *FleetLaunchError
+		require.ErrorAs(t, err, &launchErr)
+		require.NotNil(t, result)
+		assert.Equal(t, []string{"aws-stop-1"}, result.ServedNodes())
+		assert.Equal(t, 1, result.RestartedInstances)
+		assert.Empty(t, ec2.terminated)
+	})
+
+	t.Run("gang leaves no instance running", func(t *testing.T) {
+		result, err, ec2 := launch(t, true)
+
+		require.Error(t, err)
+		assert.Nil(t, result)
+		assert.Equal(t, []string{"i-stopped"}, ec2.terminated)
+	})
+}
+
+func TestClient_SuspendNodeGroups(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	ec2.addInstance("i-1", "aws-cpu-1", "")
+	ec2.addInstance("i-2", "aws-cpu-2", types.InstanceLifecycleTypeSpot)
+	ec2.addInstance("i-3", "aws-stop-1", "")
+	ec2.addInstance("i-4", "aws-stop-2", types.InstanceLifecycleTypeSpot)
+	ec2.addInstance("i-5", "aws-cpu-3", "")
+
+	err := client.SuspendNodeGroups(context.Background(), []NodeGroupSuspend{
+		{Partition: "aws", NodeGroup: "cpu", NodeNames: []string{"aws-cpu-1", "aws-cpu-2"}},
+		{Partition: "aws", NodeGroup: "stop", NodeNames: []string{"aws-stop-1", "aws-stop-2"}},
+	})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"i-1", "i-2", "i-4"}, ec2.terminated, "spot instances cannot be stopped")
+	assert.Equal(t, []string{"i-3"}, ec2.stopped)
+	_, suspended := suspendedTime(ec2.instances[2])
+	assert.True(t, suspended, "stopped instance tagged with its suspend time")
+	assert.Equal(t, types.InstanceStateNameRunning, ec2.instances[4].State.Name, "other nodes untouched")
+}
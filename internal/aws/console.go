@@ -0,0 +1,38 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// GetConsoleOutput returns the serial console output of a node group's
+// instance: kernel, systemd and cloud-init messages from its boot. EC2 keeps
+// the last 64 KB; it is empty until a few minutes after launch.
+func (c *Client) GetConsoleOutput(ctx context.Context, partition, nodeGroup, instanceID string) (string, error) {
+	fleetManager, err := c.fleetManagerFor(c.nodeGroupRegion(partition, nodeGroup))
+	if err != nil {
+		return "", err
+	}
+	return fleetManager.getConsoleOutput(ctx, instanceID)
+}
+
+// getConsoleOutput asks for the latest output, which only Nitro instances
+// support, then for the output buffered at boot
+func (f *FleetManager) getConsoleOutput(ctx context.Context, instanceID string) (string, error) {
+	output, err := f.ec2Client.GetConsoleOutput(ctx, &ec2.GetConsoleOutputInput{InstanceId: aws.String(instanceID), Latest: aws.Bool(true)})
+	if err != nil {
+		output, err = f.ec2Client.GetConsoleOutput(ctx, &ec2.GetConsoleOutputInput{InstanceId: aws.String(instanceID)})
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get console output of %s: %w", instanceID, err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(aws.ToString(output.Output))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode console output of %s: %w", instanceID, err)
+	}
+	return string(decoded), nil
+}
@@ -0,0 +1,29 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetConsoleOutput(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	ec2.consoleOutput = map[string]string{
+		"i-nitro": "[    0.000000] Linux version 6.1\ncloud-init: failed to mount /scratch\n",
+		"i-xen":   "[    0.000000] Linux version 4.14\n",
+	}
+	ec2.notNitro = map[string]bool{"i-xen": true}
+
+	output, err := client.GetConsoleOutput(context.Background(), "aws", "cpu", "i-nitro")
+	require.NoError(t, err)
+	assert.Contains(t, output, "failed to mount /scratch")
+
+	output, err = client.GetConsoleOutput(context.Background(), "aws", "cpu", "i-xen")
+	require.NoError(t, err)
+	assert.Equal(t, "[    0.000000] Linux version 4.14\n", output)
+
+	_, err = client.GetConsoleOutput(context.Background(), "aws", "cpu", "i-gone")
+	assert.ErrorContains(t, err, "failed to get console output of i-gone")
+}
@@ -0,0 +1,490 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// Container backend instance tags
+const (
+	clusterBackendTag  = "SlurmClusterBackend"  // Backend type that launched the instance
+	clusterResourceTag = "SlurmClusterResource" // "<cluster>/<node group>" or "<cluster>/<NodeClaim>" owning it
+)
+
+// Tags EKS and Karpenter put on the instances they launch
+const (
+	eksClusterTag   = "eks:cluster-name"
+	eksNodeGroupTag = "eks:nodegroup-name"
+)
+
+// karpenterNodeClaims is the path of Karpenter's NodeClaims
+const karpenterNodeClaims = "/apis/karpenter.sh/v1/nodeclaims"
+
+// containerBackendFor returns the container backend that launches a request:
+// its node group's, for containerized jobs or every job as configured. Roles,
+// Capacity Blocks and job-specific user data need EC2 Fleet.
+func (c *Client) containerBackendFor(req *LaunchRequest) *burstConfig.ContainerBackendConfig {
+	nodeGroupConfig := c.findNodeGroupConfig(req.Partition, req.NodeGroup)
+	if nodeGroupConfig == nil || !nodeGroupConfig.ContainerBackend.Enabled() {
+		return nil
+	}
+	backend := nodeGroupConfig.ContainerBackend
+	if !backend.Serves(req.Containerized, nil) || len(req.NodeRoles) > 0 || req.CapacityBlock != nil || len(req.UserDataParts) > 0 {
+		return nil
+	}
+	return &backend
+}
+
+// launchThroughCluster launches a request's nodes with its node group's
+// container backend instead of EC2 Fleet. The cluster picks the instances,
+// within the request's instance types and purchase option for Karpenter;
+// they are tagged with their node names like fleet instances.
+func (c *Client) launchThroughCluster(ctx context.Context, fleetManager *FleetManager, req *LaunchRequest, fleetReq *FleetRequest, backend burstConfig.ContainerBackendConfig) (*LaunchResult, error) {
+	api, err := c.clusterAPIFor(ctx, c.nodeGroupRegion(req.Partition, req.NodeGroup))
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Info("Launching nodes through the cluster",
+		zap.String("backend", backend.Type),
+		zap.String("cluster", backend.ClusterName),
+		zap.Strings("nodes", fleetReq.NodeIds))
+
+	ctx, cancel := context.WithTimeout(ctx, backend.ReadyTimeout())
+	defer cancel()
+
+	var instances []burstTypes.InstanceInfo
+	switch backend.Type {
+	case burstConfig.ContainerBackendEKSNodeGroup:
+		instances, err = fleetManager.launchNodeGroupInstances(ctx, api, backend, fleetReq)
+	case burstConfig.ContainerBackendKarpenter:
+		instances, err = fleetManager.launchNodeClaims(ctx, api, backend, fleetReq)
+	default:
+		err = fmt.Errorf("unknown container backend %q", backend.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.recordLaunchDetails(ctx, fleetManager, req, nil, instances)
+	return &LaunchResult{Instances: instances}, nil
+}
+
+// launchNodeGroupInstances scales a managed node group up by the request's
+// nodes and takes the instances it adds. The node group's launch template
+// decides their types.
+func (f *FleetManager) launchNodeGroupInstances(ctx context.Context, api *eksAPI, backend burstConfig.ContainerBackendConfig, req *FleetRequest) ([]burstTypes.InstanceInfo, error) {
+	tags := map[string]string{eksClusterTag: backend.ClusterName, eksNodeGroupTag: backend.NodeGroupName}
+	existing, err := f.taggedInstances(ctx, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := api.describeNodeGroup(ctx, backend.ClusterName, backend.NodeGroupName)
+	if err != nil {
+		return nil, err
+	}
+	scaling := group.ScalingConfig
+	scaling.DesiredSize += len(req.NodeIds)
+	if scaling.DesiredSize > scaling.MaxSize {
+		return nil, fmt.Errorf("node group %s/%s has room for %d more nodes, %d requested",
+			backend.ClusterName, backend.NodeGroupName, max(group.ScalingConfig.MaxSize-group.ScalingConfig.DesiredSize, 0), len(req.NodeIds))
+	}
+	if err := api.scaleNodeGroup(ctx, backend.ClusterName, backend.NodeGroupName, scaling); err != nil {
+		return nil, err
+	}
+
+	resource := backend.ClusterName + "/" + backend.NodeGroupName
+	added, err := f.awaitAddedInstances(ctx, tags, existing, len(req.NodeIds), api.pollInterval)
+	if err != nil {
+		// Scale back down rather than leave the node group paying for nodes no job waits on
+		f.logger.Warn("Node group instances did not appear, scaling it back down",
+			zap.String("node_group", resource), zap.Int("appeared", len(added)))
+		if current, err := api.describeNodeGroup(context.Background(), backend.ClusterName, backend.NodeGroupName); err == nil {
+			current.ScalingConfig.DesiredSize = max(current.ScalingConfig.DesiredSize-len(req.NodeIds), current.ScalingConfig.MinSize)
+			if err := api.scaleNodeGroup(context.Background(), backend.ClusterName, backend.NodeGroupName, current.ScalingConfig); err != nil {
+				f.logger.Warn("Failed to scale node group back down", zap.Error(err))
+			}
+		}
+		return nil, fmt.Errorf("node group %s added %d of %d instances: %w", resource, len(added), len(req.NodeIds), err)
+	}
+	return f.adoptClusterInstances(ctx, added, req, backend.Type, func(types.Instance) string { return resource })
+}
+
+// awaitAddedInstances polls the pending and running instances with tags until
+// count appear that were not among existing and serve no node yet, and
+// returns them sorted by ID. On timeout it returns those that appeared.
+func (f *FleetManager) awaitAddedInstances(ctx context.Context, tags map[string]string, existing []types.Instance, count int, interval time.Duration) ([]types.Instance, error) {
+	known := make(map[string]bool, len(existing))
+	for _, instance := range existing {
+		known[aws.ToString(instance.InstanceId)] = true
+	}
+
+	for {
+		instances, err := f.taggedInstances(ctx, tags)
+		if err != nil {
+			return nil, err
+		}
+		var added []types.Instance
+		for _, instance := range instances {
+			if !known[aws.ToString(instance.InstanceId)] && !servesNode(instance) {
+				added = append(added, instance)
+			}
+		}
+		slices.SortFunc(added, func(a, b types.Instance) int {
+			return strings.Compare(aws.ToString(a.InstanceId), aws.ToString(b.InstanceId))
+		})
+		if len(added) >= count {
+			return added[:count], nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return added, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// taggedInstances returns the pending and running instances with tags
+func (f *FleetManager) taggedInstances(ctx context.Context, tags map[string]string) ([]types.Instance, error) {
+	filters := []types.Filter{{Name: aws.String("instance-state-name"), Values: []string{"pending", "running"}}}
+	for key, value := range tags {
+		filters = append(filters, types.Filter{Name: aws.String("tag:" + key), Values: []string{value}})
+	}
+	result, err := f.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{Filters: filters})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe cluster instances: %w", err)
+	}
+
+	var instances []types.Instance
+	for _, reservation := range result.Reservations {
+		instances = append(instances, reservation.Instances...)
+	}
+	return instances, nil
+}
+
+// nodeClaim is the part of a Karpenter NodeClaim a launch writes and reads
+type nodeClaim struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   nodeClaimMetadata `json:"metadata"`
+	Spec       nodeClaimSpec     `json:"spec"`
+	Status     struct {
+		ProviderID string `json:"providerID,omitempty"` // "aws:///<zone>/<instance ID>" once launched
+	} `json:"status,omitempty"`
+}
+
+type nodeClaimMetadata struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type nodeClaimSpec struct {
+	NodeClassRef nodeClassRef           `json:"nodeClassRef"`
+	Requirements []nodeClaimRequirement `json:"requirements,omitempty"`
+	Resources    struct {
+		Requests map[string]string `json:"requests,omitempty"`
+	} `json:"resources,omitempty"`
+}
+
+type nodeClassRef struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+}
+
+type nodeClaimRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+}
+
+// newNodeClaim builds the NodeClaim for a node: the request's instance types,
+// purchase option and per-node resources in the backend's NodePool
+func (f *FleetManager) newNodeClaim(backend burstConfig.ContainerBackendConfig, req *FleetRequest, nodeName string) nodeClaim {
+	claim := nodeClaim{
+		APIVersion: "karpenter.sh/v1",
+		Kind:       "NodeClaim",
+		Metadata: nodeClaimMetadata{
+			Name: nodeName,
+			Labels: map[string]string{
+				"karpenter.sh/nodepool":        backend.NodePool,
+				"aws-slurm-burst/node":         nodeName,
+				"app.kubernetes.io/managed-by": "aws-slurm-burst",
+			},
+		},
+		Spec: nodeClaimSpec{
+			NodeClassRef: nodeClassRef{Group: "karpenter.k8s.aws", Kind: "EC2NodeClass", Name: backend.NodeClassName()},
+		},
+	}
+
+	requirements := req.InstanceRequirements
+	if instanceTypes := f.selectInstanceTypes(requirements); len(instanceTypes) > 0 {
+		claim.Spec.Requirements = append(claim.Spec.Requirements, nodeClaimRequirement{
+			Key: "node.kubernetes.io/instance-type", Operator: "In", Values: instanceTypes,
+		})
+	}
+	capacityTypes := []string{"on-demand"}
+	switch {
+	case requirements.AllowMixedPricing:
+		capacityTypes = []string{"spot", "on-demand"}
+	case requirements.PreferSpot:
+		capacityTypes = []string{"spot"}
+	}
+	claim.Spec.Requirements = append(claim.Spec.Requirements, nodeClaimRequirement{
+		Key: "karpenter.sh/capacity-type", Operator: "In", Values: capacityTypes,
+	})
+
+	requests := make(map[string]string)
+	if requirements.MinCPUs > 0 {
+		requests["cpu"] = fmt.Sprint(requirements.MinCPUs)
+	}
+	if requirements.MinMemoryMB > 0 {
+		requests["memory"] = fmt.Sprintf("%dMi", requirements.MinMemoryMB)
+	}
+	if requirements.GPUs > 0 {
+		requests["nvidia.com/gpu"] = fmt.Sprint(requirements.GPUs)
+	}
+	if len(requests) > 0 {
+		claim.Spec.Resources.Requests = requests
+	}
+	return claim
+}
+
+// launchNodeClaims creates a Karpenter NodeClaim for each node and takes the
+// instances Karpenter launches for them. Every NodeClaim is deleted when any
+// fails to launch, so the job's nodes launch together or not at all.
+func (f *FleetManager) launchNodeClaims(ctx context.Context, api *eksAPI, backend burstConfig.ContainerBackendConfig, req *FleetRequest) ([]burstTypes.InstanceInfo, error) {
+	kube, err := api.kubeClient(ctx, backend.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []string
+	release := func() {
+		for _, name := range created {
+			if err := kube.do(context.Background(), http.MethodDelete, karpenterNodeClaims+"/"+name, nil, nil); err != nil && !errors.Is(err, errKubeNotFound) {
+				f.logger.Warn("Failed to delete NodeClaim", zap.String("node_claim", name), zap.Error(err))
+			}
+		}
+	}
+
+	for _, nodeName := range req.NodeIds {
+		claim := f.newNodeClaim(backend, req, nodeName)
+		if err := kube.do(ctx, http.MethodPost, karpenterNodeClaims, claim, nil); err != nil {
+			release()
+			return nil, fmt.Errorf("failed to create NodeClaim for node %s: %w", nodeName, err)
+		}
+		created = append(created, nodeName)
+	}
+
+	instanceNodes := make(map[string]string) // Instance ID -> node
+	var instanceIds []string
+	for {
+		for _, nodeName := range req.NodeIds {
+			if slices.ContainsFunc(instanceIds, func(id string) bool { return instanceNodes[id] == nodeName }) {
+				continue
+			}
+			var claim nodeClaim
+			if err := kube.do(ctx, http.MethodGet, karpenterNodeClaims+"/"+nodeName, nil, &claim); err != nil {
+				release()
+				return nil, fmt.Errorf("failed to read NodeClaim for node %s: %w", nodeName, err)
+			}
+			if providerID := claim.Status.ProviderID; providerID != "" {
+				instanceID := providerID[strings.LastIndex(providerID, "/")+1:]
+				instanceNodes[instanceID] = nodeName
+				instanceIds = append(instanceIds, instanceID)
+			}
+		}
+		if len(instanceIds) == len(req.NodeIds) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			release()
+			return nil, fmt.Errorf("only %d of %d NodeClaims launched: %w", len(instanceIds), len(req.NodeIds), ctx.Err())
+		case <-time.After(api.pollInterval):
+		}
+	}
+
+	result, err := f.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{InstanceIds: instanceIds})
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("failed to describe NodeClaim instances: %w", err)
+	}
+	var launched []types.Instance
+	for _, reservation := range result.Reservations {
+		launched = append(launched, reservation.Instances...)
+	}
+	slices.SortFunc(launched, func(a, b types.Instance) int {
+		return slices.Index(req.NodeIds, instanceNodes[aws.ToString(a.InstanceId)]) - slices.Index(req.NodeIds, instanceNodes[aws.ToString(b.InstanceId)])
+	})
+
+	instances, err := f.adoptClusterInstances(ctx, launched, req, backend.Type, func(instance types.Instance) string {
+		return backend.ClusterName + "/" + instanceNodes[aws.ToString(instance.InstanceId)]
+	})
+	if err != nil {
+		release()
+	}
+	return instances, err
+}
+
+// adoptClusterInstances tags instances a cluster launched with the request's
+// node names, in order, and with the backend that releases them on suspend
+func (f *FleetManager) adoptClusterInstances(ctx context.Context, launched []types.Instance, req *FleetRequest, backendType string, resourceOf func(types.Instance) string) ([]burstTypes.InstanceInfo, error) {
+	instances := make([]burstTypes.InstanceInfo, 0, len(launched))
+	for i, instance := range launched {
+		instances = append(instances, newInstanceInfo(instance, req.NodeIds[i]))
+		if _, err := f.ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{
+			Resources: []string{aws.ToString(instance.InstanceId)},
+			Tags: []types.Tag{
+				{Key: aws.String(clusterBackendTag), Value: aws.String(backendType)},
+				{Key: aws.String(clusterResourceTag), Value: aws.String(resourceOf(instance))},
+			},
+		}); err != nil {
+			return nil, fmt.Errorf("failed to tag cluster instance %s: %w", aws.ToString(instance.InstanceId), err)
+		}
+	}
+	if err := f.tagInstancesWithNodeNames(ctx, instances); err != nil {
+		return nil, err
+	}
+
+	instanceIds := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		instanceIds = append(instanceIds, instance.InstanceID)
+	}
+	f.tagInstances(ctx, instanceIds, req.Tags)
+	return instances, nil
+}
+
+// releaseClusterNodes hands nodes' instances that a cluster launched back to
+// it, and returns the nodes whose instances EC2 terminates. Managed node
+// group instances are terminated through Auto Scaling, lowering the node
+// group's desired size so they are not replaced; NodeClaims are deleted and
+// Karpenter terminates their instances.
+func (c *Client) releaseClusterNodes(ctx context.Context, region string, fleetManager *FleetManager, nodeNames []string) ([]string, error) {
+	instances, err := fleetManager.describeNodeInstances(ctx, nodeNames, []string{"pending", "running"}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	released := make(map[string]bool)
+	var errs []error
+	for _, instance := range instances {
+		backendType, resource := clusterResourceOf(instance)
+		if backendType == "" {
+			continue
+		}
+		api, err := c.clusterAPIFor(ctx, region)
+		if err != nil {
+			return nil, err
+		}
+
+		switch backendType {
+		case burstConfig.ContainerBackendEKSNodeGroup:
+			err = api.terminateInAutoScalingGroup(ctx, aws.ToString(instance.InstanceId))
+		case burstConfig.ContainerBackendKarpenter:
+			cluster, claim, _ := strings.Cut(resource, "/")
+			var kube *kubeAPI
+			if kube, err = api.kubeClient(ctx, cluster); err == nil {
+				if err = kube.do(ctx, http.MethodDelete, karpenterNodeClaims+"/"+claim, nil, nil); errors.Is(err, errKubeNotFound) {
+					err = nil
+				}
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", nodeNameOf(instance), err))
+			continue
+		}
+		released[nodeNameOf(instance)] = true
+		c.logger.Info("Released node to its cluster",
+			zap.String("node", nodeNameOf(instance)),
+			zap.String("backend", backendType),
+			zap.String("resource", resource))
+	}
+
+	remaining := slices.DeleteFunc(slices.Clone(nodeNames), func(nodeName string) bool { return released[nodeName] })
+	return remaining, errors.Join(errs...)
+}
+
+// servesNode reports whether an instance is tagged as a Slurm node's
+func servesNode(instance types.Instance) bool {
+	return slices.ContainsFunc(instance.Tags, func(tag types.Tag) bool { return aws.ToString(tag.Key) == "SlurmNode" })
+}
+
+// clusterResourceOf returns the container backend that launched an instance
+// and the cluster resource owning it, both empty for fleet instances
+func clusterResourceOf(instance types.Instance) (string, string) {
+	var backendType, resource string
+	for _, tag := range instance.Tags {
+		switch aws.ToString(tag.Key) {
+		case clusterBackendTag:
+			backendType = aws.ToString(tag.Value)
+		case clusterResourceTag:
+			resource = aws.ToString(tag.Value)
+		}
+	}
+	return backendType, resource
+}
+
+// clusterBacked reports whether any node group launches through a cluster,
+// so terminations must look for cluster instances first
+func (c *Client) clusterBacked() bool {
+	if c.appConfig == nil {
+		return false
+	}
+	for _, partition := range c.appConfig.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			if nodeGroup.ContainerBackend.Enabled() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// clusterAPIFor returns the cluster APIs of a region, creating them on first use
+func (c *Client) clusterAPIFor(ctx context.Context, region string) (*eksAPI, error) {
+	if region == "" {
+		region = c.config.Region
+	}
+
+	c.regionsMu.Lock()
+	defer c.regionsMu.Unlock()
+	if api, ok := c.clusterAPIs[region]; ok {
+		return api, nil
+	}
+
+	newAPI := c.newClusterAPI
+	if newAPI == nil {
+		newAPI = func(ctx context.Context, region string) (*eksAPI, error) {
+			return newEKSAPI(ctx, c.logger, c.config, region)
+		}
+	}
+	api, err := newAPI(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cluster APIs for region %s: %w", region, err)
+	}
+
+	if c.clusterAPIs == nil {
+		c.clusterAPIs = make(map[string]*eksAPI)
+	}
+	c.clusterAPIs[region] = api
+	return api, nil
+}
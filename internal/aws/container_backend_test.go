@@ -0,0 +1,310 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeCluster fakes the EKS and Auto Scaling APIs of one cluster and serves
+// its Kubernetes API. Scaling its managed node group up and creating NodeClaims start
+// instances in the fake EC2.
+type fakeCluster struct {
+	ec2    *fakeEC2
+	server *httptest.Server
+
+	mu          sync.Mutex
+	scaling     eksScalingConfig
+	claims      map[string]nodeClaim
+	deleted     []string
+	asgReleased []string
+	launched    int
+}
+
+func newFakeCluster(t *testing.T, ec2 *fakeEC2) *fakeCluster {
+	cluster := &fakeCluster{ec2: ec2, scaling: eksScalingConfig{MaxSize: 3, DesiredSize: 1}, claims: make(map[string]nodeClaim)}
+	cluster.server = httptest.NewTLSServer(cluster)
+	t.Cleanup(cluster.server.Close)
+	return cluster
+}
+
+// api returns cluster APIs calling the fake, with tokens presigned offline
+func (c *fakeCluster) api(t *testing.T) *eksAPI {
+	stsClient := sts.New(sts.Options{Region: "us-east-1", Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", "")})
+	return &eksAPI{
+		logger:       zaptest.NewLogger(t),
+		eks:          c,
+		autoscaling:  c,
+		sts:          sts.NewPresignClient(stsClient),
+		pollInterval: time.Millisecond,
+	}
+}
+
+// launch starts an instance in the fake EC2 with tags
+func (c *fakeCluster) launch(tags map[string]string) string {
+	c.launched++
+	id := fmt.Sprintf("i-cluster%d", c.launched)
+	addTaggedInstance(c.ec2, id, tags)
+	return id
+}
+
+// addTaggedInstance starts an instance launched outside EC2 Fleet, with tags
+func addTaggedInstance(ec2 *fakeEC2, id string, tags map[string]string) {
+	instance := types.Instance{
+		InstanceId:   aws.String(id),
+		InstanceType: types.InstanceTypeM6iXlarge,
+		LaunchTime:   aws.Time(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+		Placement:    &types.Placement{AvailabilityZone: aws.String("us-east-1b")},
+		State:        &types.InstanceState{Name: types.InstanceStateNameRunning},
+	}
+	for key, value := range tags {
+		instance.Tags = append(instance.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	ec2.mu.Lock()
+	ec2.instances = append(ec2.instances, instance)
+	ec2.mu.Unlock()
+}
+
+func (c *fakeCluster) DescribeCluster(ctx context.Context, params *eks.DescribeClusterInput, optFns ...func(*eks.Options)) (*eks.DescribeClusterOutput, error) {
+	if aws.ToString(params.Name) != "hpc" {
+		return nil, &eksTypes.ResourceNotFoundException{Message: aws.String("No cluster found")}
+	}
+	caData := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.server.Certificate().Raw}))
+	return &eks.DescribeClusterOutput{Cluster: &eksTypes.Cluster{
+		Endpoint:             aws.String(c.server.URL),
+		CertificateAuthority: &eksTypes.Certificate{Data: aws.String(caData)},
+	}}, nil
+}
+
+func (c *fakeCluster) DescribeNodegroup(ctx context.Context, params *eks.DescribeNodegroupInput, optFns ...func(*eks.Options)) (*eks.DescribeNodegroupOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if aws.ToString(params.ClusterName) != "hpc" || aws.ToString(params.NodegroupName) != "slurm" {
+		return nil, &eksTypes.ResourceNotFoundException{Message: aws.String("No node group found")}
+	}
+	return &eks.DescribeNodegroupOutput{Nodegroup: &eksTypes.Nodegroup{
+		Status: eksTypes.NodegroupStatusActive,
+		ScalingConfig: &eksTypes.NodegroupScalingConfig{
+			MinSize:     aws.Int32(int32(c.scaling.MinSize)),
+			MaxSize:     aws.Int32(int32(c.scaling.MaxSize)),
+			DesiredSize: aws.Int32(int32(c.scaling.DesiredSize)),
+		},
+	}}, nil
+}
+
+func (c *fakeCluster) UpdateNodegroupConfig(ctx context.Context, params *eks.UpdateNodegroupConfigInput, optFns ...func(*eks.Options)) (*eks.UpdateNodegroupConfigOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	scaling := eksScalingConfig{
+		MinSize:     int(aws.ToInt32(params.ScalingConfig.MinSize)),
+		MaxSize:     int(aws.ToInt32(params.ScalingConfig.MaxSize)),
+		DesiredSize: int(aws.ToInt32(params.ScalingConfig.DesiredSize)),
+	}
+	for range scaling.DesiredSize - c.scaling.DesiredSize {
+		c.launch(map[string]string{eksClusterTag: "hpc", eksNodeGroupTag: "slurm", "Name": "hpc-slurm-node"})
+	}
+	c.scaling = scaling
+	return &eks.UpdateNodegroupConfigOutput{Update: &eksTypes.Update{Status: eksTypes.UpdateStatusInProgress}}, nil
+}
+
+func (c *fakeCluster) TerminateInstanceInAutoScalingGroup(ctx context.Context, params *autoscaling.TerminateInstanceInAutoScalingGroupInput, optFns ...func(*autoscaling.Options)) (*autoscaling.TerminateInstanceInAutoScalingGroupOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !aws.ToBool(params.ShouldDecrementDesiredCapacity) {
+		return nil, errors.New("ValidationError: the node group would replace the instance")
+	}
+	c.asgReleased = append(c.asgReleased, aws.ToString(params.InstanceId))
+	c.scaling.DesiredSize--
+	return &autoscaling.TerminateInstanceInAutoScalingGroupOutput{}, nil
+}
+
+// ServeHTTP serves the cluster's Kubernetes API
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	if !strings.HasPrefix(r.URL.Path, "/apis/") {
+		http.Error(w, `{"kind":"Status","reason":"NotFound","message":"not found"}`, http.StatusNotFound)
+		return
+	}
+	c.serveKubernetes(w, r, body)
+}
+
+// serveKubernetes serves NodeClaims, which Karpenter launches at once
+func (c *fakeCluster) serveKubernetes(w http.ResponseWriter, r *http.Request, body []byte) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer k8s-aws-v1.")
+	presigned, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.Contains(string(presigned), "Action=GetCallerIdentity") || !strings.Contains(string(presigned), "x-k8s-aws-id") ||
+		!strings.Contains(string(presigned), "X-Amz-Expires=60") {
+		http.Error(w, `{"kind":"Status","reason":"Unauthorized","message":"bad token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, karpenterNodeClaims), "/")
+	switch r.Method {
+	case http.MethodPost:
+		var claim nodeClaim
+		_ = json.Unmarshal(body, &claim)
+		id := c.launch(map[string]string{"karpenter.sh/nodeclaim": claim.Metadata.Name})
+		claim.Status.ProviderID = "aws:///us-east-1b/" + id
+		c.claims[claim.Metadata.Name] = claim
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(claim)
+	case http.MethodGet:
+		claim, ok := c.claims[name]
+		if !ok {
+			http.Error(w, `{"kind":"Status","reason":"NotFound","message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(claim)
+	case http.MethodDelete:
+		delete(c.claims, name)
+		c.deleted = append(c.deleted, name)
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+func newClusterClient(t *testing.T) (*Client, *fakeEC2, *fakeCluster) {
+	nodeGroup := func(name string, backend config.ContainerBackendConfig) config.NodeGroupConfig {
+		return config.NodeGroupConfig{
+			NodeGroupName:      name,
+			LaunchTemplateSpec: config.LaunchTemplateSpec{LaunchTemplateName: "compute", Version: "$Latest"},
+			SubnetIds:          []string{"subnet-a"},
+			ContainerBackend:   backend,
+		}
+	}
+	appConfig := &config.Config{Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{{
+		PartitionName: "aws",
+		NodeGroups: []config.NodeGroupConfig{
+			nodeGroup("eks", config.ContainerBackendConfig{Type: config.ContainerBackendEKSNodeGroup, ClusterName: "hpc", NodeGroupName: "slurm"}),
+			nodeGroup("karpenter", config.ContainerBackendConfig{Type: config.ContainerBackendKarpenter, ClusterName: "hpc", NodePool: "slurm"}),
+		},
+	}}}}
+
+	ec2 := &fakeEC2{}
+	client := NewClientWithEC2API(zaptest.NewLogger(t), &config.AWSConfig{Region: "us-east-1"}, appConfig, ec2)
+	cluster := newFakeCluster(t, ec2)
+	client.newClusterAPI = func(ctx context.Context, region string) (*eksAPI, error) {
+		return cluster.api(t), nil
+	}
+	return client, ec2, cluster
+}
+
+func TestClient_LaunchThroughNodeGroup(t *testing.T) {
+	client, ec2, cluster := newClusterClient(t)
+	cluster.launch(map[string]string{eksClusterTag: "hpc", eksNodeGroupTag: "slurm"}) // A node the cluster already runs
+	ctx := context.Background()
+
+	request := &LaunchRequest{
+		NodeIds:              []string{"aws-eks-1", "aws-eks-2"},
+		Partition:            "aws",
+		NodeGroup:            "eks",
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"m6i.xlarge"}},
+		Job:                  &burstTypes.SlurmJob{JobID: "42"},
+	}
+
+	// Jobs that are not containerized launch with EC2 Fleet
+	result, err := client.LaunchInstances(ctx, request)
+	require.NoError(t, err)
+	assert.Len(t, ec2.fleets, 1)
+	assert.Equal(t, 1, cluster.scaling.DesiredSize)
+	require.NoError(t, client.TerminateNodeGroupInstances(ctx, "aws", "eks", request.NodeIds))
+	assert.Len(t, ec2.terminated, len(result.Instances))
+
+	request.Containerized = true
+	result, err = client.LaunchInstances(ctx, request)
+	require.NoError(t, err)
+	assert.Len(t, ec2.fleets, 1, "the cluster launched the nodes")
+	assert.Equal(t, 3, cluster.scaling.DesiredSize)
+
+	require.Len(t, result.Instances, 2)
+	for i, nodeName := range request.NodeIds {
+		instance := result.Instances[i]
+		assert.Equal(t, nodeName, instance.NodeName)
+		assert.Equal(t, fmt.Sprintf("i-cluster%d", i+2), instance.InstanceID, "the instances the scale-up added")
+		assert.Equal(t, "m6i.xlarge", instance.InstanceType)
+		assert.Equal(t, nodeName, tagValue(ec2.instances[i+1+len(ec2.terminated)], "SlurmNode"))
+	}
+
+	// The node group cannot grow past its maximum
+	_, err = client.LaunchInstances(ctx, &LaunchRequest{
+		NodeIds: []string{"aws-eks-3"}, Partition: "aws", NodeGroup: "eks", Containerized: true,
+		InstanceRequirements: &burstTypes.InstanceRequirements{}, Job: request.Job,
+	})
+	assert.ErrorContains(t, err, "has room for 0 more nodes")
+
+	// Suspend hands the instances back to the node group rather than terminating them
+	terminated := len(ec2.terminated)
+	require.NoError(t, client.TerminateNodeGroupInstances(ctx, "aws", "eks", request.NodeIds))
+	assert.Equal(t, []string{"i-cluster2", "i-cluster3"}, cluster.asgReleased)
+	assert.Len(t, ec2.terminated, terminated)
+	assert.Equal(t, 1, cluster.scaling.DesiredSize)
+}
+
+func TestClient_LaunchThroughKarpenter(t *testing.T) {
+	client, ec2, cluster := newClusterClient(t)
+	ctx := context.Background()
+
+	request := &LaunchRequest{
+		NodeIds:   []string{"aws-karpenter-1", "aws-karpenter-2"},
+		Partition: "aws",
+		NodeGroup: "karpenter",
+		InstanceRequirements: &burstTypes.InstanceRequirements{
+			InstanceFamilies: []string{"m6i.xlarge"},
+			MinCPUs:          4,
+			MinMemoryMB:      8192,
+			PreferSpot:       true,
+		},
+		Job:           &burstTypes.SlurmJob{JobID: "42"},
+		Containerized: true,
+	}
+	result, err := client.LaunchInstances(ctx, request)
+	require.NoError(t, err)
+	assert.Empty(t, ec2.fleets)
+
+	claim := cluster.claims["aws-karpenter-1"]
+	assert.Equal(t, "slurm", claim.Metadata.Labels["karpenter.sh/nodepool"])
+	assert.Equal(t, nodeClassRef{Group: "karpenter.k8s.aws", Kind: "EC2NodeClass", Name: "default"}, claim.Spec.NodeClassRef)
+	assert.Equal(t, []nodeClaimRequirement{
+		{Key: "node.kubernetes.io/instance-type", Operator: "In", Values: []string{"m6i.xlarge"}},
+		{Key: "karpenter.sh/capacity-type", Operator: "In", Values: []string{"spot"}},
+	}, claim.Spec.Requirements)
+	assert.Equal(t, map[string]string{"cpu": "4", "memory": "8192Mi"}, claim.Spec.Resources.Requests)
+
+	require.Len(t, result.Instances, 2)
+	for i, nodeName := range request.NodeIds {
+		assert.Equal(t, nodeName, result.Instances[i].NodeName)
+		assert.Equal(t, nodeName, tagValue(ec2.instances[i], "SlurmNode"))
+		assert.Equal(t, "hpc/"+nodeName, tagValue(ec2.instances[i], clusterResourceTag))
+		assert.Equal(t, "42", tagValue(ec2.instances[i], "JobID"))
+	}
+
+	// Suspend deletes the NodeClaims and Karpenter terminates their instances
+	require.NoError(t, client.TerminateNodeGroupInstances(ctx, "aws", "karpenter", request.NodeIds))
+	assert.Equal(t, request.NodeIds, cluster.deleted)
+	assert.Empty(t, ec2.terminated)
+}
@@ -0,0 +1,254 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"go.uber.org/zap"
+)
+
+// Tags PCS and ParallelCluster put on the instances they launch
+const (
+	pcsComputeNodeGroupTag     = "aws:pcs:compute-node-group-id"
+	parallelClusterNameTag     = "parallelcluster:cluster-name"
+	parallelClusterQueueTag    = "parallelcluster:queue-name"
+	parallelClusterResourceTag = "parallelcluster:compute-resource-name"
+)
+
+// delegateFor returns the provisioning delegate of a request's node group
+func (c *Client) delegateFor(req *LaunchRequest) *burstConfig.DelegateConfig {
+	nodeGroupConfig := c.findNodeGroupConfig(req.Partition, req.NodeGroup)
+	if nodeGroupConfig == nil || !nodeGroupConfig.Delegate.Enabled() {
+		return nil
+	}
+	delegate := nodeGroupConfig.Delegate
+	return &delegate
+}
+
+// launchThroughDelegate launches a request's nodes by raising its node
+// group's delegate's static capacity and taking the instances it adds. The
+// delegate's own configuration decides their types; the launch is recorded
+// like a fleet's, so executions still feed back into planning.
+func (c *Client) launchThroughDelegate(ctx context.Context, fleetManager *FleetManager, req *LaunchRequest, fleetReq *FleetRequest, delegate burstConfig.DelegateConfig) (*LaunchResult, error) {
+	if len(req.NodeRoles) > 0 || req.CapacityBlock != nil || len(req.UserDataParts) > 0 {
+		return nil, fmt.Errorf("node group %s delegates provisioning to %s, which cannot launch node roles, Capacity Blocks or job user data",
+			req.NodeGroup, delegate.Type)
+	}
+	api, err := c.delegateAPIFor(ctx, c.nodeGroupRegion(req.Partition, req.NodeGroup))
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Info("Launching nodes through the delegate",
+		zap.String("delegate", delegate.Type),
+		zap.String("resource", delegate.Resource()),
+		zap.Strings("nodes", fleetReq.NodeIds))
+
+	ctx, cancel := context.WithTimeout(ctx, delegate.ReadyTimeout())
+	defer cancel()
+
+	tags, err := api.instanceTags(ctx, delegate)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := fleetManager.taggedInstances(ctx, tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.scaleDelegate(ctx, delegate, len(fleetReq.NodeIds)); err != nil {
+		return nil, err
+	}
+
+	added, err := fleetManager.awaitAddedInstances(ctx, tags, existing, len(fleetReq.NodeIds), api.pollInterval)
+	if err != nil {
+		// Scale back down rather than leave the delegate paying for nodes no job waits on
+		c.logger.Warn("Delegate instances did not appear, scaling it back down",
+			zap.String("resource", delegate.Resource()), zap.Int("appeared", len(added)))
+		releaseCtx, releaseCancel := context.WithTimeout(context.Background(), delegate.ReadyTimeout())
+		defer releaseCancel()
+		if err := api.scaleDelegate(releaseCtx, delegate, -len(fleetReq.NodeIds)); err != nil {
+			c.logger.Warn("Failed to scale delegate back down", zap.Error(err))
+		}
+		return nil, fmt.Errorf("%s %s added %d of %d instances: %w", delegate.Type, delegate.Resource(), len(added), len(fleetReq.NodeIds), err)
+	}
+
+	instances, err := fleetManager.adoptClusterInstances(ctx, added, fleetReq, delegate.Type, func(types.Instance) string { return delegate.Resource() })
+	if err != nil {
+		return nil, err
+	}
+	c.recordLaunchDetails(ctx, fleetManager, req, nil, instances)
+	return &LaunchResult{Instances: instances}, nil
+}
+
+// instanceTags returns the tags the delegate puts on its instances. PCS tags
+// them with the compute node group's ID, which the configuration may name.
+func (d *delegateAPI) instanceTags(ctx context.Context, delegate burstConfig.DelegateConfig) (map[string]string, error) {
+	if delegate.Type == burstConfig.DelegateParallelCluster {
+		return map[string]string{
+			parallelClusterNameTag:     delegate.ClusterName,
+			parallelClusterQueueTag:    delegate.Queue,
+			parallelClusterResourceTag: delegate.ComputeResource,
+		}, nil
+	}
+	group, err := d.getComputeNodeGroup(ctx, delegate.ClusterName, delegate.ComputeNodeGroup)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{pcsComputeNodeGroupTag: group.ID}, nil
+}
+
+// scaleDelegate changes a delegate's static capacity by delta instances,
+// waiting out updates already in progress
+func (d *delegateAPI) scaleDelegate(ctx context.Context, delegate burstConfig.DelegateConfig, delta int) error {
+	for {
+		var updating bool
+		switch delegate.Type {
+		case burstConfig.DelegatePCS:
+			group, err := d.getComputeNodeGroup(ctx, delegate.ClusterName, delegate.ComputeNodeGroup)
+			if err != nil {
+				return err
+			}
+			if updating = group.Status == "CREATING" || group.Status == "UPDATING"; updating {
+				break
+			}
+			scaling := group.ScalingConfiguration
+			scaling.MinInstanceCount = max(scaling.MinInstanceCount+delta, 0)
+			if scaling.MinInstanceCount > scaling.MaxInstanceCount {
+				return fmt.Errorf("compute node group %s has room for %d more nodes, %d requested",
+					delegate.Resource(), max(group.ScalingConfiguration.MaxInstanceCount-group.ScalingConfiguration.MinInstanceCount, 0), delta)
+			}
+			return d.updateComputeNodeGroup(ctx, delegate.ClusterName, delegate.ComputeNodeGroup, scaling)
+		case burstConfig.DelegateParallelCluster:
+			cluster, err := d.describeParallelCluster(ctx, delegate.APIEndpoint, delegate.ClusterName)
+			if err != nil {
+				return err
+			}
+			if updating = cluster.updating(); updating {
+				break
+			}
+			configuration, err := d.parallelClusterConfiguration(ctx, cluster)
+			if err != nil {
+				return err
+			}
+			scaled, err := scaleComputeResource(configuration, delegate.Queue, delegate.ComputeResource, delta)
+			if err != nil {
+				return err
+			}
+			return d.updateParallelCluster(ctx, delegate.APIEndpoint, delegate.ClusterName, scaled)
+		default:
+			return fmt.Errorf("unknown delegate %q", delegate.Type)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s %s is still updating: %w", delegate.Type, delegate.Resource(), ctx.Err())
+		case <-time.After(d.pollInterval):
+		}
+	}
+}
+
+// releaseDelegatedNodes lowers the static capacity of the delegates that
+// launched nodes' instances by the nodes suspended, so the delegates do not
+// replace them. The instances are then terminated like fleet instances.
+func (c *Client) releaseDelegatedNodes(ctx context.Context, region string, fleetManager *FleetManager, nodeNames []string) error {
+	instances, err := fleetManager.describeNodeInstances(ctx, nodeNames, []string{"pending", "running"}, false)
+	if err != nil {
+		return err
+	}
+
+	released := make(map[string]int) // Delegate resource -> nodes
+	delegates := make(map[string]burstConfig.DelegateConfig)
+	for _, instance := range instances {
+		backendType, resource := clusterResourceOf(instance)
+		if delegate := c.findDelegate(backendType, resource); delegate != nil {
+			released[resource]++
+			delegates[resource] = *delegate
+		}
+	}
+	if len(released) == 0 {
+		return nil
+	}
+
+	api, err := c.delegateAPIFor(ctx, region)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for resource, count := range released {
+		if err := api.scaleDelegate(ctx, delegates[resource], -count); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		c.logger.Info("Lowered delegate capacity for suspended nodes",
+			zap.String("delegate", delegates[resource].Type),
+			zap.String("resource", resource),
+			zap.Int("nodes", count))
+	}
+	return errors.Join(errs...)
+}
+
+// findDelegate returns the configured delegate of a type owning resource
+func (c *Client) findDelegate(delegateType, resource string) *burstConfig.DelegateConfig {
+	if c.appConfig == nil || delegateType == "" {
+		return nil
+	}
+	for _, partition := range c.appConfig.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			if nodeGroup.Delegate.Type == delegateType && nodeGroup.Delegate.Resource() == resource {
+				delegate := nodeGroup.Delegate
+				return &delegate
+			}
+		}
+	}
+	return nil
+}
+
+// delegated reports whether any node group delegates provisioning, so
+// terminations must lower delegates' capacity first
+func (c *Client) delegated() bool {
+	if c.appConfig == nil {
+		return false
+	}
+	for _, partition := range c.appConfig.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			if nodeGroup.Delegate.Enabled() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// delegateAPIFor returns the delegate APIs of a region, creating them on first use
+func (c *Client) delegateAPIFor(ctx context.Context, region string) (*delegateAPI, error) {
+	if region == "" {
+		region = c.config.Region
+	}
+
+	c.regionsMu.Lock()
+	defer c.regionsMu.Unlock()
+	if api, ok := c.delegateAPIs[region]; ok {
+		return api, nil
+	}
+
+	newAPI := c.newDelegateAPI
+	if newAPI == nil {
+		newAPI = func(ctx context.Context, region string) (*delegateAPI, error) {
+			return newDelegateAPI(ctx, c.logger, c.config, region)
+		}
+	}
+	api, err := newAPI(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create delegate APIs for region %s: %w", region, err)
+	}
+
+	if c.delegateAPIs == nil {
+		c.delegateAPIs = make(map[string]*delegateAPI)
+	}
+	c.delegateAPIs[region] = api
+	return api, nil
+}
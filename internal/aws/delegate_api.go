@@ -0,0 +1,311 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/pcs"
+	pcsTypes "github.com/aws/aws-sdk-go-v2/service/pcs/types"
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// PCSAPI is the part of the AWS PCS client PCS delegates call; tests
+// substitute a fake
+type PCSAPI interface {
+	GetComputeNodeGroup(ctx context.Context, params *pcs.GetComputeNodeGroupInput, optFns ...func(*pcs.Options)) (*pcs.GetComputeNodeGroupOutput, error)
+	UpdateComputeNodeGroup(ctx context.Context, params *pcs.UpdateComputeNodeGroupInput, optFns ...func(*pcs.Options)) (*pcs.UpdateComputeNodeGroupOutput, error)
+}
+
+var _ PCSAPI = (*pcs.Client)(nil)
+
+// delegateAPI calls the AWS PCS API of one region and the ParallelCluster
+// APIs deployed in it. ParallelCluster APIs sit behind API Gateway, which has
+// no SDK client, so their requests are signed here.
+type delegateAPI struct {
+	logger      *zap.Logger
+	pcs         PCSAPI
+	credentials aws.CredentialsProvider // Signs ParallelCluster API requests
+	signer      *v4.Signer
+	httpClient  *http.Client // Calls ParallelCluster APIs and reads configurations from their presigned URLs
+	region      string
+
+	pollInterval time.Duration
+}
+
+// newDelegateAPI creates the delegate APIs of region
+func newDelegateAPI(ctx context.Context, logger *zap.Logger, awsConfig *burstConfig.AWSConfig, region string) (*delegateAPI, error) {
+	cfg, err := LoadConfig(ctx, logger, awsConfig, region)
+	if err != nil {
+		return nil, err
+	}
+	return &delegateAPI{
+		logger:       logger,
+		pcs:          pcs.NewFromConfig(cfg),
+		credentials:  cfg.Credentials,
+		signer:       v4.NewSigner(),
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		region:       region,
+		pollInterval: 15 * time.Second,
+	}, nil
+}
+
+// pcsComputeNodeGroup is the part of a PCS compute node group a launch needs
+type pcsComputeNodeGroup struct {
+	ID                   string
+	Name                 string
+	Status               string
+	ScalingConfiguration pcsScalingConfiguration
+}
+
+type pcsScalingConfiguration struct {
+	MinInstanceCount int
+	MaxInstanceCount int
+}
+
+// getComputeNodeGroup returns a PCS compute node group
+func (d *delegateAPI) getComputeNodeGroup(ctx context.Context, cluster, computeNodeGroup string) (*pcsComputeNodeGroup, error) {
+	output, err := d.pcs.GetComputeNodeGroup(ctx, &pcs.GetComputeNodeGroupInput{
+		ClusterIdentifier:          aws.String(cluster),
+		ComputeNodeGroupIdentifier: aws.String(computeNodeGroup),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PCS compute node group %s/%s: %w", cluster, computeNodeGroup, err)
+	}
+	group := &pcsComputeNodeGroup{
+		ID:     aws.ToString(output.ComputeNodeGroup.Id),
+		Name:   aws.ToString(output.ComputeNodeGroup.Name),
+		Status: string(output.ComputeNodeGroup.Status),
+	}
+	if scaling := output.ComputeNodeGroup.ScalingConfiguration; scaling != nil {
+		group.ScalingConfiguration = pcsScalingConfiguration{
+			MinInstanceCount: int(scaling.MinInstanceCount),
+			MaxInstanceCount: int(scaling.MaxInstanceCount),
+		}
+	}
+	return group, nil
+}
+
+// updateComputeNodeGroup sets a PCS compute node group's scaling
+func (d *delegateAPI) updateComputeNodeGroup(ctx context.Context, cluster, computeNodeGroup string, scaling pcsScalingConfiguration) error {
+	_, err := d.pcs.UpdateComputeNodeGroup(ctx, &pcs.UpdateComputeNodeGroupInput{
+		ClusterIdentifier:          aws.String(cluster),
+		ComputeNodeGroupIdentifier: aws.String(computeNodeGroup),
+		ScalingConfiguration: &pcsTypes.ScalingConfigurationRequest{
+			MinInstanceCount: int32(scaling.MinInstanceCount),
+			MaxInstanceCount: int32(scaling.MaxInstanceCount),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update PCS compute node group %s/%s: %w", cluster, computeNodeGroup, err)
+	}
+	return nil
+}
+
+// parallelCluster is the part of a ParallelCluster cluster a launch needs
+type parallelCluster struct {
+	ClusterStatus        string `json:"clusterStatus"`
+	ClusterConfiguration struct {
+		URL string `json:"url"` // Presigned S3 URL of the cluster's configuration
+	} `json:"clusterConfiguration"`
+}
+
+// updating reports whether the cluster is mid-update and cannot take another
+func (p *parallelCluster) updating() bool {
+	return strings.HasSuffix(p.ClusterStatus, "_IN_PROGRESS")
+}
+
+// describeParallelCluster returns a ParallelCluster cluster
+func (d *delegateAPI) describeParallelCluster(ctx context.Context, endpoint, cluster string) (*parallelCluster, error) {
+	var output parallelCluster
+	if err := d.callParallelCluster(ctx, http.MethodGet, endpoint, cluster, nil, &output); err != nil {
+		return nil, fmt.Errorf("failed to describe ParallelCluster cluster %s: %w", cluster, err)
+	}
+	return &output, nil
+}
+
+// parallelClusterConfiguration reads a cluster's YAML configuration
+func (d *delegateAPI) parallelClusterConfiguration(ctx context.Context, cluster *parallelCluster) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, cluster.ClusterConfiguration.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := d.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster configuration: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to read cluster configuration: HTTP %d", response.StatusCode)
+	}
+	return io.ReadAll(response.Body)
+}
+
+// updateParallelCluster starts a cluster update to configuration
+func (d *delegateAPI) updateParallelCluster(ctx context.Context, endpoint, cluster string, configuration []byte) error {
+	input := map[string]string{"clusterConfiguration": string(configuration)}
+	if err := d.callParallelCluster(ctx, http.MethodPut, endpoint, cluster, input, &struct{}{}); err != nil {
+		return fmt.Errorf("failed to update ParallelCluster cluster %s: %w", cluster, err)
+	}
+	return nil
+}
+
+// callParallelCluster sends a signed request for a cluster to a ParallelCluster
+// API and decodes the response into output
+func (d *delegateAPI) callParallelCluster(ctx context.Context, method, endpoint, cluster string, input, output interface{}) error {
+	var body []byte
+	header := http.Header{}
+	if input != nil {
+		var err error
+		if body, err = json.Marshal(input); err != nil {
+			return err
+		}
+		header.Set("Content-Type", "application/json")
+	}
+	target := strings.TrimSuffix(endpoint, "/") + "/v3/clusters/" + url.PathEscape(cluster) + "?region=" + url.QueryEscape(d.region)
+	status, responseBody, err := d.signedRequest(ctx, method, target, header, body)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		// Rejected updates list why in their validation errors
+		var apiErr struct {
+			Message                string `json:"message"`
+			UpdateValidationErrors []struct {
+				Message string `json:"message"`
+			} `json:"updateValidationErrors"`
+		}
+		if json.Unmarshal(responseBody, &apiErr) == nil && apiErr.Message != "" {
+			messages := []string{apiErr.Message}
+			for _, validationErr := range apiErr.UpdateValidationErrors {
+				messages = append(messages, validationErr.Message)
+			}
+			return fmt.Errorf("HTTP %d: %s", status, strings.Join(messages, "; "))
+		}
+		return fmt.Errorf("HTTP %d from the ParallelCluster API", status)
+	}
+	return json.Unmarshal(responseBody, output)
+}
+
+// signedRequest signs a request for API Gateway's execute-api with Signature
+// Version 4 and sends it, returning the response status and body
+func (d *delegateAPI) signedRequest(ctx context.Context, method, target string, header http.Header, body []byte) (int, []byte, error) {
+	request, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	credentials, err := d.credentials.Retrieve(ctx)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to retrieve credentials: %w", err)
+	}
+	payloadHash := sha256.Sum256(body)
+	if err := d.signer.SignHTTP(ctx, credentials, request, hex.EncodeToString(payloadHash[:]), "execute-api", d.region, time.Now()); err != nil {
+		return 0, nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	response, err := d.httpClient.Do(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(response.Body)
+	return response.StatusCode, responseBody, err
+}
+
+// errNoComputeResource is returned for configurations without the delegate's
+// queue and compute resource
+var errNoComputeResource = errors.New("compute resource not found in the cluster configuration")
+
+// scaleComputeResource changes the MinCount of a queue's compute resource in
+// a ParallelCluster configuration by delta, leaving the rest as written. It
+// fails when MinCount would pass MaxCount, which ParallelCluster defaults to 10.
+func scaleComputeResource(configuration []byte, queue, computeResource string, delta int) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(configuration, &document); err != nil {
+		return nil, fmt.Errorf("invalid cluster configuration: %w", err)
+	}
+	if len(document.Content) == 0 {
+		return nil, errNoComputeResource
+	}
+
+	var resource *yaml.Node
+	for _, queueNode := range yamlValue(yamlValue(document.Content[0], "Scheduling"), "SlurmQueues").Content {
+		if yamlString(queueNode, "Name") != queue {
+			continue
+		}
+		for _, resourceNode := range yamlValue(queueNode, "ComputeResources").Content {
+			if yamlString(resourceNode, "Name") == computeResource {
+				resource = resourceNode
+			}
+		}
+	}
+	if resource == nil {
+		return nil, fmt.Errorf("%w: %s/%s", errNoComputeResource, queue, computeResource)
+	}
+
+	minCount, maxCount := 0, 10
+	if value := yamlValue(resource, "MinCount"); value.Value != "" {
+		if err := value.Decode(&minCount); err != nil {
+			return nil, fmt.Errorf("invalid MinCount of %s/%s: %w", queue, computeResource, err)
+		}
+	}
+	if value := yamlValue(resource, "MaxCount"); value.Value != "" {
+		if err := value.Decode(&maxCount); err != nil {
+			return nil, fmt.Errorf("invalid MaxCount of %s/%s: %w", queue, computeResource, err)
+		}
+	}
+	scaled := max(minCount+delta, 0)
+	if scaled > maxCount {
+		return nil, fmt.Errorf("compute resource %s/%s has room for %d more nodes, %d requested", queue, computeResource, max(maxCount-minCount, 0), delta)
+	}
+
+	if value := yamlValue(resource, "MinCount"); value.Value != "" {
+		value.Value = fmt.Sprint(scaled)
+	} else {
+		resource.Content = append(resource.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "MinCount"},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprint(scaled)})
+	}
+	var scaledConfiguration bytes.Buffer
+	encoder := yaml.NewEncoder(&scaledConfiguration)
+	encoder.SetIndent(2) // As ParallelCluster writes configurations
+	if err := encoder.Encode(&document); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return scaledConfiguration.Bytes(), nil
+}
+
+// yamlValue returns the value of a mapping's key, or an empty node
+func yamlValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping != nil && mapping.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			if mapping.Content[i].Value == key {
+				return mapping.Content[i+1]
+			}
+		}
+	}
+	return &yaml.Node{}
+}
+
+func yamlString(mapping *yaml.Node, key string) string {
+	return yamlValue(mapping, key).Value
+}
@@ -0,0 +1,294 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/pcs"
+	pcsTypes "github.com/aws/aws-sdk-go-v2/service/pcs/types"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"gopkg.in/yaml.v3"
+)
+
+const parallelClusterConfiguration = `Region: us-east-1
+Image:
+  Os: alinux2023
+Scheduling:
+  Scheduler: slurm
+  SlurmQueues:
+    - Name: compute
+      ComputeResources:
+        - Name: c6i
+          InstanceType: c6i.4xlarge
+          MinCount: 0
+          MaxCount: 2
+        - Name: m6i
+          InstanceType: m6i.4xlarge
+`
+
+// fakeDelegates fakes a PCS compute node group and serves a ParallelCluster API.
+// Raising either's static capacity starts instances in the fake EC2.
+type fakeDelegates struct {
+	ec2    *fakeEC2
+	server *httptest.Server
+
+	mu            sync.Mutex
+	pcsScaling    pcsScalingConfiguration
+	configuration string
+	updatesLeft   int // Describes still reporting an update in progress
+	launched      int
+}
+
+func newFakeDelegates(t *testing.T, ec2 *fakeEC2) *fakeDelegates {
+	delegates := &fakeDelegates{ec2: ec2, pcsScaling: pcsScalingConfiguration{MaxInstanceCount: 2}, configuration: parallelClusterConfiguration}
+	delegates.server = httptest.NewServer(delegates)
+	t.Cleanup(delegates.server.Close)
+	return delegates
+}
+
+// api returns delegate APIs calling the fake
+func (d *fakeDelegates) api(t *testing.T) *delegateAPI {
+	return &delegateAPI{
+		logger:       zaptest.NewLogger(t),
+		pcs:          d,
+		credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		signer:       v4.NewSigner(),
+		httpClient:   d.server.Client(),
+		region:       "us-east-1",
+		pollInterval: time.Millisecond,
+	}
+}
+
+// launch starts count instances in the fake EC2 with tags
+func (d *fakeDelegates) launch(count int, tags map[string]string) {
+	for range count {
+		d.launched++
+		addTaggedInstance(d.ec2, fmt.Sprintf("i-delegate%d", d.launched), tags)
+	}
+}
+
+func (d *fakeDelegates) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	if r.URL.Path == "/config" {
+		_, _ = w.Write([]byte(d.configuration))
+		return
+	}
+	if !strings.Contains(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+		http.Error(w, `{"message":"unsigned"}`, http.StatusForbidden)
+		return
+	}
+
+	if r.URL.Path == "/prod/v3/clusters/hpc" && r.URL.Query().Get("region") == "us-east-1" {
+		d.serveParallelCluster(w, r.Method, body)
+		return
+	}
+	http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+}
+
+func (d *fakeDelegates) GetComputeNodeGroup(ctx context.Context, params *pcs.GetComputeNodeGroupInput, optFns ...func(*pcs.Options)) (*pcs.GetComputeNodeGroupOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if aws.ToString(params.ClusterIdentifier) != "hpc" || aws.ToString(params.ComputeNodeGroupIdentifier) != "compute" {
+		return nil, &pcsTypes.ResourceNotFoundException{Message: aws.String("no such compute node group")}
+	}
+	return &pcs.GetComputeNodeGroupOutput{ComputeNodeGroup: &pcsTypes.ComputeNodeGroup{
+		Id:     aws.String("pcs_abc123"),
+		Name:   aws.String("compute"),
+		Status: pcsTypes.ComputeNodeGroupStatusActive,
+		ScalingConfiguration: &pcsTypes.ScalingConfiguration{
+			MinInstanceCount: int32(d.pcsScaling.MinInstanceCount),
+			MaxInstanceCount: int32(d.pcsScaling.MaxInstanceCount),
+		},
+	}}, nil
+}
+
+func (d *fakeDelegates) UpdateComputeNodeGroup(ctx context.Context, params *pcs.UpdateComputeNodeGroupInput, optFns ...func(*pcs.Options)) (*pcs.UpdateComputeNodeGroupOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if aws.ToString(params.ClusterIdentifier) != "hpc" || aws.ToString(params.ComputeNodeGroupIdentifier) != "compute" {
+		return nil, &pcsTypes.ResourceNotFoundException{Message: aws.String("no such compute node group")}
+	}
+	scaling := pcsScalingConfiguration{
+		MinInstanceCount: int(params.ScalingConfiguration.MinInstanceCount),
+		MaxInstanceCount: int(params.ScalingConfiguration.MaxInstanceCount),
+	}
+	d.launch(scaling.MinInstanceCount-d.pcsScaling.MinInstanceCount, map[string]string{pcsComputeNodeGroupTag: "pcs_abc123"})
+	d.pcsScaling = scaling
+	return &pcs.UpdateComputeNodeGroupOutput{ComputeNodeGroup: &pcsTypes.ComputeNodeGroup{Status: pcsTypes.ComputeNodeGroupStatusUpdating}}, nil
+}
+
+func (d *fakeDelegates) serveParallelCluster(w http.ResponseWriter, method string, body []byte) {
+	switch method {
+	case http.MethodGet:
+		status := "UPDATE_COMPLETE"
+		if d.updatesLeft > 0 {
+			d.updatesLeft--
+			status = "UPDATE_IN_PROGRESS"
+		}
+		_, _ = fmt.Fprintf(w, `{"clusterStatus":%q,"clusterConfiguration":{"url":%q}}`, status, d.server.URL+"/config")
+	case http.MethodPut:
+		var input struct {
+			ClusterConfiguration string `json:"clusterConfiguration"`
+		}
+		_ = json.Unmarshal(body, &input)
+		d.launch(computeResourceMinCount(input.ClusterConfiguration)-computeResourceMinCount(d.configuration), map[string]string{
+			parallelClusterNameTag: "hpc", parallelClusterQueueTag: "compute", parallelClusterResourceTag: "c6i",
+		})
+		d.configuration = input.ClusterConfiguration
+		d.updatesLeft = 2
+		_, _ = w.Write([]byte(`{"cluster":{"clusterStatus":"UPDATE_IN_PROGRESS"}}`))
+	}
+}
+
+// computeResourceMinCount reads the MinCount of compute/c6i
+func computeResourceMinCount(configuration string) int {
+	var parsed struct {
+		Scheduling struct {
+			SlurmQueues []struct {
+				ComputeResources []struct {
+					MinCount int `yaml:"MinCount"`
+				} `yaml:"ComputeResources"`
+			} `yaml:"SlurmQueues"`
+		} `yaml:"Scheduling"`
+	}
+	_ = yaml.Unmarshal([]byte(configuration), &parsed)
+	return parsed.Scheduling.SlurmQueues[0].ComputeResources[0].MinCount
+}
+
+func newDelegatedClient(t *testing.T) (*Client, *fakeEC2, *fakeDelegates) {
+	nodeGroup := func(name string, delegate config.DelegateConfig) config.NodeGroupConfig {
+		return config.NodeGroupConfig{
+			NodeGroupName:      name,
+			LaunchTemplateSpec: config.LaunchTemplateSpec{LaunchTemplateName: "compute", Version: "$Latest"},
+			SubnetIds:          []string{"subnet-a"},
+			Delegate:           delegate,
+		}
+	}
+	ec2 := &fakeEC2{}
+	delegates := newFakeDelegates(t, ec2)
+	appConfig := &config.Config{Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{{
+		PartitionName: "aws",
+		NodeGroups: []config.NodeGroupConfig{
+			nodeGroup("pcs", config.DelegateConfig{Type: config.DelegatePCS, ClusterName: "hpc", ComputeNodeGroup: "compute"}),
+			nodeGroup("pcluster", config.DelegateConfig{
+				Type: config.DelegateParallelCluster, ClusterName: "hpc", Queue: "compute", ComputeResource: "c6i",
+				APIEndpoint: delegates.server.URL + "/prod/",
+			}),
+		},
+	}}}}
+
+	client := NewClientWithEC2API(zaptest.NewLogger(t), &config.AWSConfig{Region: "us-east-1"}, appConfig, ec2)
+	client.newDelegateAPI = func(ctx context.Context, region string) (*delegateAPI, error) {
+		return delegates.api(t), nil
+	}
+	return client, ec2, delegates
+}
+
+func TestClient_LaunchThroughPCS(t *testing.T) {
+	client, ec2, delegates := newDelegatedClient(t)
+	ctx := context.Background()
+
+	request := &LaunchRequest{
+		NodeIds:              []string{"aws-pcs-1", "aws-pcs-2"},
+		Partition:            "aws",
+		NodeGroup:            "pcs",
+		InstanceRequirements: &burstTypes.InstanceRequirements{},
+		Job:                  &burstTypes.SlurmJob{JobID: "42"},
+	}
+	result, err := client.LaunchInstances(ctx, request)
+	require.NoError(t, err)
+	assert.Empty(t, ec2.fleets, "the delegate launched the nodes")
+	assert.Equal(t, 2, delegates.pcsScaling.MinInstanceCount)
+
+	require.Len(t, result.Instances, 2)
+	for i, nodeName := range request.NodeIds {
+		assert.Equal(t, nodeName, result.Instances[i].NodeName)
+		assert.Equal(t, fmt.Sprintf("i-delegate%d", i+1), result.Instances[i].InstanceID)
+		assert.Equal(t, nodeName, tagValue(ec2.instances[i], "SlurmNode"))
+		assert.Equal(t, "hpc/compute", tagValue(ec2.instances[i], clusterResourceTag))
+	}
+
+	// The compute node group cannot grow past its maximum
+	_, err = client.LaunchInstances(ctx, &LaunchRequest{
+		NodeIds: []string{"aws-pcs-3"}, Partition: "aws", NodeGroup: "pcs",
+		InstanceRequirements: &burstTypes.InstanceRequirements{}, Job: request.Job,
+	})
+	assert.ErrorContains(t, err, "has room for 0 more nodes")
+
+	// Delegates cannot launch what only EC2 Fleet can
+	_, err = client.LaunchInstances(ctx, &LaunchRequest{
+		NodeIds: []string{"aws-pcs-3"}, Partition: "aws", NodeGroup: "pcs",
+		InstanceRequirements: &burstTypes.InstanceRequirements{}, Job: request.Job,
+		UserDataParts: []userdata.Part{userdata.NewPart("setup.sh", "#!/bin/sh")},
+	})
+	assert.ErrorContains(t, err, "delegates provisioning to pcs")
+
+	// Suspend lowers the static capacity before terminating the instances
+	require.NoError(t, client.TerminateNodeGroupInstances(ctx, "aws", "pcs", request.NodeIds))
+	assert.Equal(t, 0, delegates.pcsScaling.MinInstanceCount)
+	assert.ElementsMatch(t, []string{"i-delegate1", "i-delegate2"}, ec2.terminated)
+}
+
+func TestClient_LaunchThroughParallelCluster(t *testing.T) {
+	client, ec2, delegates := newDelegatedClient(t)
+	delegates.updatesLeft = 1 // An update another resume started
+	ctx := context.Background()
+
+	request := &LaunchRequest{
+		NodeIds:              []string{"aws-pcluster-1"},
+		Partition:            "aws",
+		NodeGroup:            "pcluster",
+		InstanceRequirements: &burstTypes.InstanceRequirements{},
+		Job:                  &burstTypes.SlurmJob{JobID: "42"},
+	}
+	result, err := client.LaunchInstances(ctx, request)
+	require.NoError(t, err)
+	assert.Empty(t, ec2.fleets)
+	assert.Equal(t, 1, computeResourceMinCount(delegates.configuration))
+	assert.Contains(t, delegates.configuration, "Os: alinux2023", "the rest of the configuration is kept")
+
+	require.Len(t, result.Instances, 1)
+	assert.Equal(t, "aws-pcluster-1", result.Instances[0].NodeName)
+	assert.Equal(t, "hpc/compute/c6i", tagValue(ec2.instances[0], clusterResourceTag))
+
+	require.NoError(t, client.TerminateNodeGroupInstances(ctx, "aws", "pcluster", request.NodeIds))
+	assert.Equal(t, 0, computeResourceMinCount(delegates.configuration))
+	assert.Equal(t, []string{"i-delegate1"}, ec2.terminated)
+}
+
+func TestScaleComputeResource(t *testing.T) {
+	scaled, err := scaleComputeResource([]byte(parallelClusterConfiguration), "compute", "m6i", 3)
+	require.NoError(t, err)
+	assert.Contains(t, string(scaled), "InstanceType: m6i.4xlarge\n          MinCount: 3")
+	assert.Contains(t, string(scaled), "InstanceType: c6i.4xlarge\n          MinCount: 0\n          MaxCount: 2")
+
+	scaled, err = scaleComputeResource(scaled, "compute", "m6i", -5)
+	require.NoError(t, err)
+	assert.Contains(t, string(scaled), "MinCount: 0\n", "never below zero")
+
+	_, err = scaleComputeResource([]byte(parallelClusterConfiguration), "compute", "c6i", 3)
+	assert.ErrorContains(t, err, "has room for 2 more nodes")
+	_, err = scaleComputeResource([]byte(parallelClusterConfiguration), "compute", "m6i", 11)
+	assert.ErrorContains(t, err, "has room for 10 more nodes", "MaxCount defaults to 10")
+	_, err = scaleComputeResource([]byte(parallelClusterConfiguration), "gpu", "c6i", 1)
+	assert.ErrorIs(t, err, errNoComputeResource)
+}
@@ -0,0 +1,332 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Diagnostics answers read-only questions about the AWS account for
+// aws-slurm-burst-doctor and aws-slurm-burst-init. Launch permissions are checked with EC2 dry runs.
+type Diagnostics struct {
+	auth    *AuthenticationProvider
+	base    aws.Config
+	clients map[string]*ec2.Client
+}
+
+// NewDiagnostics authenticates the way resume does, so it fails when resume's
+// credentials would
+func NewDiagnostics(ctx context.Context, logger *zap.Logger, awsConfig *burstConfig.AWSConfig) (*Diagnostics, error) {
+	auth := NewAuthenticationProvider(logger, authenticationConfigFor(awsConfig))
+	cfg, err := auth.GetAWSConfig(ctx, awsConfig.Region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure AWS authentication: %w", err)
+	}
+	return &Diagnostics{auth: auth, base: cfg, clients: make(map[string]*ec2.Client)}, nil
+}
+
+// Identity returns the account and principal the credentials belong to
+func (d *Diagnostics) Identity(ctx context.Context) (*CredentialInfo, error) {
+	return d.auth.GetCredentialInfo(ctx, d.base)
+}
+
+// SubnetFreeIPs returns the available IP addresses of each subnet that exists;
+// missing subnets are absent from the result
+func (d *Diagnostics) SubnetFreeIPs(ctx context.Context, region string, subnetIDs []string) (map[string]int, error) {
+	output, err := d.ec2For(region).DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []types.Filter{{Name: aws.String("subnet-id"), Values: subnetIDs}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	free := make(map[string]int, len(output.Subnets))
+	for _, subnet := range output.Subnets {
+		free[aws.ToString(subnet.SubnetId)] = int(aws.ToInt32(subnet.AvailableIpAddressCount))
+	}
+	return free, nil
+}
+
+// SecurityGroups returns which of the security groups exist
+func (d *Diagnostics) SecurityGroups(ctx context.Context, region string, groupIDs []string) (map[string]bool, error) {
+	output, err := d.ec2For(region).DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []types.Filter{{Name: aws.String("group-id"), Values: groupIDs}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(output.SecurityGroups))
+	for _, group := range output.SecurityGroups {
+		found[aws.ToString(group.GroupId)] = true
+	}
+	return found, nil
+}
+
+// SecurityGroupRule is one rule of a security group, with one source or
+// destination: a CIDR block, another security group or a prefix list
+type SecurityGroupRule struct {
+	GroupID      string
+	Egress       bool
+	Protocol     string // "tcp", "udp", "icmp" or "-1" for all traffic
+	FromPort     int32  // -1 for all ports
+	ToPort       int32
+	CIDR         string
+	Group        string
+	PrefixListID string
+}
+
+// SecurityGroupRules returns the inbound and outbound rules of the security groups
+func (d *Diagnostics) SecurityGroupRules(ctx context.Context, region string, groupIDs []string) ([]SecurityGroupRule, error) {
+	var rules []SecurityGroupRule
+	paginator := ec2.NewDescribeSecurityGroupRulesPaginator(d.ec2For(region), &ec2.DescribeSecurityGroupRulesInput{
+		Filters: []types.Filter{{Name: aws.String("group-id"), Values: groupIDs}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range page.SecurityGroupRules {
+			converted := SecurityGroupRule{
+				GroupID:      aws.ToString(rule.GroupId),
+				Egress:       aws.ToBool(rule.IsEgress),
+				Protocol:     aws.ToString(rule.IpProtocol),
+				FromPort:     aws.ToInt32(rule.FromPort),
+				ToPort:       aws.ToInt32(rule.ToPort),
+				CIDR:         aws.ToString(rule.CidrIpv4) + aws.ToString(rule.CidrIpv6),
+				PrefixListID: aws.ToString(rule.PrefixListId),
+			}
+			if rule.ReferencedGroupInfo != nil {
+				converted.Group = aws.ToString(rule.ReferencedGroupInfo.GroupId)
+			}
+			rules = append(rules, converted)
+		}
+	}
+	return rules, nil
+}
+
+// LaunchTemplateExists reports whether the node group's launch template exists
+func (d *Diagnostics) LaunchTemplateExists(ctx context.Context, region string, spec burstConfig.LaunchTemplateSpec) (bool, error) {
+	input := &ec2.DescribeLaunchTemplatesInput{}
+	if spec.LaunchTemplateID != "" {
+		input.LaunchTemplateIds = []string{spec.LaunchTemplateID}
+	} else {
+		input.LaunchTemplateNames = []string{spec.LaunchTemplateName}
+	}
+
+	output, err := d.ec2For(region).DescribeLaunchTemplates(ctx, input)
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "InvalidLaunchTemplateId.NotFound", "InvalidLaunchTemplateId.Malformed", "InvalidLaunchTemplateName.NotFoundException":
+			return false, nil
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(output.LaunchTemplates) > 0, nil
+}
+
+// OfferedInstanceTypes maps each of the instance types offered in region to
+// whether it supports EFA; types not offered there are absent from the result
+func (d *Diagnostics) OfferedInstanceTypes(ctx context.Context, region string, instanceTypes []string) (map[string]bool, error) {
+	client := d.ec2For(region)
+	var offered []types.InstanceType
+	paginator := ec2.NewDescribeInstanceTypeOfferingsPaginator(client, &ec2.DescribeInstanceTypeOfferingsInput{
+		LocationType: types.LocationTypeRegion,
+		Filters:      []types.Filter{{Name: aws.String("instance-type"), Values: instanceTypes}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, offering := range page.InstanceTypeOfferings {
+			offered = append(offered, offering.InstanceType)
+		}
+	}
+
+	efa := make(map[string]bool, len(offered))
+	if len(offered) == 0 {
+		return efa, nil
+	}
+	described := ec2.NewDescribeInstanceTypesPaginator(client, &ec2.DescribeInstanceTypesInput{InstanceTypes: offered})
+	for described.HasMorePages() {
+		page, err := described.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range page.InstanceTypes {
+			efa[string(info.InstanceType)] = info.NetworkInfo != nil && aws.ToBool(info.NetworkInfo.EfaSupported)
+		}
+	}
+	return efa, nil
+}
+
+// SubnetInfo is a subnet found by DefaultVPC
+type SubnetInfo struct {
+	SubnetID         string
+	AvailabilityZone string
+	FreeIPs          int
+}
+
+// DefaultVPC is a region's default VPC, its subnets and its default security group
+type DefaultVPC struct {
+	VPCID           string
+	Subnets         []SubnetInfo // Sorted by availability zone
+	SecurityGroupID string
+}
+
+// DefaultVPC describes the region's default VPC for aws-slurm-burst-init. It
+// returns nil without an error when the account has no default VPC there.
+func (d *Diagnostics) DefaultVPC(ctx context.Context, region string) (*DefaultVPC, error) {
+	client := d.ec2For(region)
+	vpcs, err := client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
+		Filters: []types.Filter{{Name: aws.String("is-default"), Values: []string{"true"}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(vpcs.Vpcs) == 0 {
+		return nil, nil
+	}
+	vpc := &DefaultVPC{VPCID: aws.ToString(vpcs.Vpcs[0].VpcId)}
+	inVPC := types.Filter{Name: aws.String("vpc-id"), Values: []string{vpc.VPCID}}
+
+	subnets := ec2.NewDescribeSubnetsPaginator(client, &ec2.DescribeSubnetsInput{Filters: []types.Filter{inVPC}})
+	for subnets.HasMorePages() {
+		page, err := subnets.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, subnet := range page.Subnets {
+			vpc.Subnets = append(vpc.Subnets, SubnetInfo{
+				SubnetID:         aws.ToString(subnet.SubnetId),
+				AvailabilityZone: aws.ToString(subnet.AvailabilityZone),
+				FreeIPs:          int(aws.ToInt32(subnet.AvailableIpAddressCount)),
+			})
+		}
+	}
+	sort.Slice(vpc.Subnets, func(i, j int) bool { return vpc.Subnets[i].AvailabilityZone < vpc.Subnets[j].AvailabilityZone })
+
+	groups, err := client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: []types.Filter{inVPC, {Name: aws.String("group-name"), Values: []string{"default"}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(groups.SecurityGroups) > 0 {
+		vpc.SecurityGroupID = aws.ToString(groups.SecurityGroups[0].GroupId)
+	}
+	return vpc, nil
+}
+
+// EFAInstanceTypes lists the instance types in region that support EFA, sorted by name
+func (d *Diagnostics) EFAInstanceTypes(ctx context.Context, region string) ([]burstTypes.InstanceTypeInfo, error) {
+	return d.describeInstanceTypes(ctx, region, &ec2.DescribeInstanceTypesInput{
+		Filters: []types.Filter{{Name: aws.String("network-info.efa-supported"), Values: []string{"true"}}},
+	})
+}
+
+// InstanceTypes describes the named instance types in region, sorted by name
+func (d *Diagnostics) InstanceTypes(ctx context.Context, region string, instanceTypes []string) ([]burstTypes.InstanceTypeInfo, error) {
+	input := &ec2.DescribeInstanceTypesInput{}
+	for _, instanceType := range instanceTypes {
+		input.InstanceTypes = append(input.InstanceTypes, types.InstanceType(instanceType))
+	}
+	return d.describeInstanceTypes(ctx, region, input)
+}
+
+func (d *Diagnostics) describeInstanceTypes(ctx context.Context, region string, input *ec2.DescribeInstanceTypesInput) ([]burstTypes.InstanceTypeInfo, error) {
+	var infos []burstTypes.InstanceTypeInfo
+	paginator := ec2.NewDescribeInstanceTypesPaginator(d.ec2For(region), input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range page.InstanceTypes {
+			infos = append(infos, convertInstanceTypeInfo(info))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].InstanceType < infos[j].InstanceType })
+	return infos, nil
+}
+
+// DryRunFleet asks EC2 whether the credentials may launch one instance of the node
+// group's first instance type in its first subnet. It returns nil when EC2
+// answers that the request would have succeeded.
+func (d *Diagnostics) DryRunFleet(ctx context.Context, region string, nodeGroup *burstConfig.NodeGroupConfig) error {
+	version := nodeGroup.LaunchTemplateSpec.Version
+	if version == "" {
+		version = "$Default"
+	}
+	spec := &types.FleetLaunchTemplateSpecificationRequest{Version: aws.String(version)}
+	if nodeGroup.LaunchTemplateSpec.LaunchTemplateID != "" {
+		spec.LaunchTemplateId = aws.String(nodeGroup.LaunchTemplateSpec.LaunchTemplateID)
+	} else {
+		spec.LaunchTemplateName = aws.String(nodeGroup.LaunchTemplateSpec.LaunchTemplateName)
+	}
+
+	override := types.FleetLaunchTemplateOverridesRequest{}
+	if len(nodeGroup.LaunchTemplateOverrides) > 0 {
+		override.InstanceType = types.InstanceType(nodeGroup.LaunchTemplateOverrides[0].InstanceType)
+	}
+	if len(nodeGroup.SubnetIds) > 0 {
+		override.SubnetId = aws.String(nodeGroup.SubnetIds[0])
+	}
+
+	_, err := d.ec2For(region).CreateFleet(ctx, &ec2.CreateFleetInput{
+		DryRun: aws.Bool(true),
+		Type:   types.FleetTypeInstant,
+		LaunchTemplateConfigs: []types.FleetLaunchTemplateConfigRequest{{
+			LaunchTemplateSpecification: spec,
+			Overrides:                   []types.FleetLaunchTemplateOverridesRequest{override},
+		}},
+		TargetCapacitySpecification: &types.TargetCapacitySpecificationRequest{
+			TotalTargetCapacity:       aws.Int32(1),
+			DefaultTargetCapacityType: types.DefaultTargetCapacityTypeOnDemand,
+		},
+	})
+	return dryRunResult(err)
+}
+
+// dryRunResult maps EC2's DryRunOperation answer to success
+func dryRunResult(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "DryRunOperation" {
+		return nil
+	}
+	if err == nil {
+		return fmt.Errorf("dry run unexpectedly succeeded")
+	}
+	return err
+}
+
+// ec2For returns an EC2 client for region, creating one on first use
+func (d *Diagnostics) ec2For(region string) *ec2.Client {
+	if region == "" {
+		region = d.base.Region
+	}
+	if client, ok := d.clients[region]; ok {
+		return client
+	}
+
+	cfg := d.base.Copy()
+	cfg.Region = region
+	client := ec2.NewFromConfig(cfg)
+	d.clients[region] = client
+	return client
+}
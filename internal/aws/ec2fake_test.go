@@ -0,0 +1,375 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// fakeEC2 is an in-memory EC2 for launch and suspend tests. CreateFleet starts
+// running instances, DescribeInstances filters them by ID, tags and state,
+// and the calls that change instances are recorded. Calls it does not
+// implement fail the test with a nil pointer panic through the embedded
+// interface.
+type fakeEC2 struct {
+	EC2API
+
+	mu         sync.Mutex
+	instances  []types.Instance
+	fleets     []*ec2.CreateFleetInput
+	terminated []string
+	stopped    []string
+	started    []string
+	versions   []*ec2.CreateLaunchTemplateVersionInput
+
+	// Returned for every launch template; nil finds none
+	templateData *types.ResponseLaunchTemplateData
+	// Returned for every image ID
+	image types.Image
+
+	// The network between subnets, all in vpc-1, and the controller
+	routeTables    []types.RouteTable
+	vpnConnections []types.VpnConnection
+	tgwAttachments []types.TransitGatewayVpcAttachment
+
+	// Console output by instance ID, and instances without the latest output
+	consoleOutput map[string]string
+	notNitro      map[string]bool
+
+	// Spot requests returned whatever the filters
+	spotRequests []types.SpotInstanceRequest
+
+	// Volumes, filtered by tag key and status, and the volumes deleted
+	volumes        []types.Volume
+	deletedVolumes []string
+
+	// Instances TerminateInstances refuses, failing the whole call
+	unterminable []string
+
+	// Fleets CreateFleet fills before reporting insufficient capacity; zero
+	// fills every fleet
+	fleetCapacity int
+
+	// Delays DescribeInstances results, widening races between concurrent callers
+	describeDelay time.Duration
+}
+
+// addInstance adds a running instance serving nodeName
+func (f *fakeEC2) addInstance(id, nodeName string, lifecycle types.InstanceLifecycleType) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.instances = append(f.instances, types.Instance{
+		InstanceId:        aws.String(id),
+		InstanceType:      types.InstanceTypeC6iLarge,
+		InstanceLifecycle: lifecycle,
+		PrivateIpAddress:  aws.String("10.0.0." + id[len(id)-1:]),
+		LaunchTime:        aws.Time(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
+		Placement:         &types.Placement{AvailabilityZone: aws.String("us-east-1a")},
+		State:             &types.InstanceState{Name: types.InstanceStateNameRunning},
+		Tags:              []types.Tag{{Key: aws.String("Name"), Value: aws.String(nodeName)}},
+	})
+}
+
+func (f *fakeEC2) CreateFleet(ctx context.Context, params *ec2.CreateFleetInput, optFns ...func(*ec2.Options)) (*ec2.CreateFleetOutput, error) {
+	f.mu.Lock()
+	f.fleets = append(f.fleets, params)
+	fleetID := fmt.Sprintf("fleet-%d", len(f.fleets))
+	first := len(f.instances)
+	full := f.fleetCapacity > 0 && len(f.fleets) > f.fleetCapacity
+	f.mu.Unlock()
+
+	if full {
+		return &ec2.CreateFleetOutput{
+			FleetId: aws.String(fleetID),
+			Errors: []types.CreateFleetError{{
+				ErrorCode:    aws.String("InsufficientInstanceCapacity"),
+				ErrorMessage: aws.String("There is no Spot capacity available that matches your request."),
+			}},
+		}, nil
+	}
+
+	var ids []string
+	for i := 0; i < int(aws.ToInt32(params.TargetCapacitySpecification.TotalTargetCapacity)); i++ {
+		id := fmt.Sprintf("i-%d", first+i)
+		f.addInstance(id, "", "")
+		ids = append(ids, id)
+	}
+	return &ec2.CreateFleetOutput{
+		FleetId:   aws.String(fleetID),
+		Instances: []types.CreateFleetInstance{{InstanceIds: ids}},
+	}, nil
+}
+
+// DescribeInstanceTypeOfferings offers nothing anywhere, so gang launches
+// find no availability zone
+func (f *fakeEC2) DescribeInstanceTypeOfferings(ctx context.Context, params *ec2.DescribeInstanceTypeOfferingsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypeOfferingsOutput, error) {
+	return &ec2.DescribeInstanceTypeOfferingsOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeSpotInstanceRequests(ctx context.Context, params *ec2.DescribeSpotInstanceRequestsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSpotInstanceRequestsOutput, error) {
+	return &ec2.DescribeSpotInstanceRequestsOutput{SpotInstanceRequests: f.spotRequests}, nil
+}
+
+func (f *fakeEC2) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	f.mu.Lock()
+	defer time.Sleep(f.describeDelay)
+	defer f.mu.Unlock()
+
+	var matched []types.Instance
+	for _, instance := range f.instances {
+		if len(params.InstanceIds) > 0 && !slices.Contains(params.InstanceIds, aws.ToString(instance.InstanceId)) {
+			continue
+		}
+		if matchesFilters(instance, params.Filters) {
+			// Callers keep the tags as described, whatever is retagged later
+			instance.Tags = slices.Clone(instance.Tags)
+			matched = append(matched, instance)
+		}
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: matched}}}, nil
+}
+
+// matchesFilters reports whether an instance passes the tag:<key>,
+// instance-state-name and tag-key filters
+func matchesFilters(instance types.Instance, filters []types.Filter) bool {
+	for _, filter := range filters {
+		var value string
+		switch name := aws.ToString(filter.Name); {
+		case strings.HasPrefix(name, "tag:"):
+			for _, tag := range instance.Tags {
+				if aws.ToString(tag.Key) == name[len("tag:"):] {
+					value = aws.ToString(tag.Value)
+				}
+			}
+		case name == "instance-state-name":
+			value = string(instance.State.Name)
+		case name == "tag-key":
+			for _, tag := range instance.Tags {
+				if slices.Contains(filter.Values, aws.ToString(tag.Key)) {
+					value = aws.ToString(tag.Key)
+				}
+			}
+		default:
+			panic("fakeEC2: unsupported filter " + name)
+		}
+		if !slices.Contains(filter.Values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeEC2) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.instances {
+		if !slices.Contains(params.Resources, aws.ToString(f.instances[i].InstanceId)) {
+			continue
+		}
+		for _, tag := range params.Tags {
+			f.instances[i].Tags = slices.DeleteFunc(f.instances[i].Tags, func(existing types.Tag) bool {
+				return aws.ToString(existing.Key) == aws.ToString(tag.Key)
+			})
+			f.instances[i].Tags = append(f.instances[i].Tags, tag)
+		}
+	}
+	for i := range f.volumes {
+		if slices.Contains(params.Resources, aws.ToString(f.volumes[i].VolumeId)) {
+			f.volumes[i].Tags = append(f.volumes[i].Tags, params.Tags...)
+		}
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// DeleteTags deletes tags by key, whatever their value
+func (f *fakeEC2) DeleteTags(ctx context.Context, params *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.instances {
+		if !slices.Contains(params.Resources, aws.ToString(f.instances[i].InstanceId)) {
+			continue
+		}
+		for _, tag := range params.Tags {
+			f.instances[i].Tags = slices.DeleteFunc(f.instances[i].Tags, func(existing types.Tag) bool {
+				return aws.ToString(existing.Key) == aws.ToString(tag.Key)
+			})
+		}
+	}
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+func (f *fakeEC2) TerminateInstances(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
+	for _, id := range params.InstanceIds {
+		if slices.Contains(f.unterminable, id) {
+			return nil, fmt.Errorf("instance %s cannot be terminated", id)
+		}
+	}
+	f.setState(params.InstanceIds, types.InstanceStateNameShuttingDown)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.terminated = append(f.terminated, params.InstanceIds...)
+	return &ec2.TerminateInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	f.setState(params.InstanceIds, types.InstanceStateNameStopping)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = append(f.stopped, params.InstanceIds...)
+	return &ec2.StopInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) StartInstances(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error) {
+	f.setState(params.InstanceIds, types.InstanceStateNameRunning)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.started = append(f.started, params.InstanceIds...)
+	return &ec2.StartInstancesOutput{}, nil
+}
+
+// DescribeLaunchTemplateVersions finds no template unless templateData is
+// set, so launches skip the AMI architecture check
+func (f *fakeEC2) DescribeLaunchTemplateVersions(ctx context.Context, params *ec2.DescribeLaunchTemplateVersionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	if f.templateData == nil {
+		return &ec2.DescribeLaunchTemplateVersionsOutput{}, nil
+	}
+	return &ec2.DescribeLaunchTemplateVersionsOutput{
+		LaunchTemplateVersions: []types.LaunchTemplateVersion{{LaunchTemplateData: f.templateData}},
+	}, nil
+}
+
+// DescribeImages describes every image as image
+func (f *fakeEC2) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	image := f.image
+	image.ImageId = aws.String(params.ImageIds[0])
+	return &ec2.DescribeImagesOutput{Images: []types.Image{image}}, nil
+}
+
+// DescribeSubnets places every subnet in vpc-1
+func (f *fakeEC2) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	output := &ec2.DescribeSubnetsOutput{}
+	for _, subnetID := range params.SubnetIds {
+		output.Subnets = append(output.Subnets, types.Subnet{SubnetId: aws.String(subnetID), VpcId: aws.String("vpc-1")})
+	}
+	return output, nil
+}
+
+// DescribeRouteTables returns every route table
+func (f *fakeEC2) GetConsoleOutput(ctx context.Context, params *ec2.GetConsoleOutputInput, optFns ...func(*ec2.Options)) (*ec2.GetConsoleOutputOutput, error) {
+	id := aws.ToString(params.InstanceId)
+	if aws.ToBool(params.Latest) && f.notNitro[id] {
+		return nil, fmt.Errorf("UnsupportedOperation: latest console output is only available on Nitro instances")
+	}
+	output, ok := f.consoleOutput[id]
+	if !ok {
+		return nil, fmt.Errorf("InvalidInstanceID.NotFound: %s", id)
+	}
+	return &ec2.GetConsoleOutputOutput{InstanceId: params.InstanceId, Output: aws.String(base64.StdEncoding.EncodeToString([]byte(output)))}, nil
+}
+
+func (f *fakeEC2) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	return &ec2.DescribeRouteTablesOutput{RouteTables: f.routeTables}, nil
+}
+
+// DescribeVpnConnections returns every VPN connection
+func (f *fakeEC2) DescribeVpnConnections(ctx context.Context, params *ec2.DescribeVpnConnectionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpnConnectionsOutput, error) {
+	return &ec2.DescribeVpnConnectionsOutput{VpnConnections: f.vpnConnections}, nil
+}
+
+// DescribeTransitGatewayVpcAttachments returns every attachment
+func (f *fakeEC2) DescribeTransitGatewayVpcAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayVpcAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayVpcAttachmentsOutput, error) {
+	return &ec2.DescribeTransitGatewayVpcAttachmentsOutput{TransitGatewayVpcAttachments: f.tgwAttachments}, nil
+}
+
+// CreateLaunchTemplateVersion records the version and numbers it after the
+// versions created before
+func (f *fakeEC2) CreateLaunchTemplateVersion(ctx context.Context, params *ec2.CreateLaunchTemplateVersionInput, optFns ...func(*ec2.Options)) (*ec2.CreateLaunchTemplateVersionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.versions = append(f.versions, params)
+	return &ec2.CreateLaunchTemplateVersionOutput{LaunchTemplateVersion: &types.LaunchTemplateVersion{
+		VersionNumber: aws.Int64(int64(1 + len(f.versions))),
+	}}, nil
+}
+
+func (f *fakeEC2) DeleteLaunchTemplateVersions(ctx context.Context, params *ec2.DeleteLaunchTemplateVersionsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteLaunchTemplateVersionsOutput, error) {
+	return &ec2.DeleteLaunchTemplateVersionsOutput{}, nil
+}
+
+// DescribeSpotPriceHistory prices every requested type at $0.04 an hour in
+// every requested zone, or in us-east-1a when no zone is requested
+func (f *fakeEC2) DescribeSpotPriceHistory(ctx context.Context, params *ec2.DescribeSpotPriceHistoryInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSpotPriceHistoryOutput, error) {
+	zones := []string{"us-east-1a"}
+	for _, filter := range params.Filters {
+		if aws.ToString(filter.Name) == "availability-zone" {
+			zones = filter.Values
+		}
+	}
+
+	output := &ec2.DescribeSpotPriceHistoryOutput{}
+	for _, instanceType := range params.InstanceTypes {
+		for _, zone := range zones {
+			output.SpotPriceHistory = append(output.SpotPriceHistory, types.SpotPrice{
+				InstanceType:     instanceType,
+				AvailabilityZone: aws.String(zone),
+				SpotPrice:        aws.String("0.040000"),
+			})
+		}
+	}
+	return output, nil
+}
+
+// DescribeVolumes supports the tag-key and status filters
+func (f *fakeEC2) DescribeVolumes(ctx context.Context, params *ec2.DescribeVolumesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVolumesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var matched []types.Volume
+	for _, volume := range f.volumes {
+		matches := true
+		for _, filter := range params.Filters {
+			switch name := aws.ToString(filter.Name); name {
+			case "tag-key":
+				matches = matches && slices.ContainsFunc(volume.Tags, func(tag types.Tag) bool {
+					return slices.Contains(filter.Values, aws.ToString(tag.Key))
+				})
+			case "status":
+				matches = matches && slices.Contains(filter.Values, string(volume.State))
+			default:
+				panic("fakeEC2: unsupported volume filter " + name)
+			}
+		}
+		if matches {
+			matched = append(matched, volume)
+		}
+	}
+	return &ec2.DescribeVolumesOutput{Volumes: matched}, nil
+}
+
+func (f *fakeEC2) DeleteVolume(ctx context.Context, params *ec2.DeleteVolumeInput, optFns ...func(*ec2.Options)) (*ec2.DeleteVolumeOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deletedVolumes = append(f.deletedVolumes, aws.ToString(params.VolumeId))
+	f.volumes = slices.DeleteFunc(f.volumes, func(volume types.Volume) bool {
+		return aws.ToString(volume.VolumeId) == aws.ToString(params.VolumeId)
+	})
+	return &ec2.DeleteVolumeOutput{}, nil
+}
+
+func (f *fakeEC2) setState(instanceIds []string, state types.InstanceStateName) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.instances {
+		if slices.Contains(instanceIds, aws.ToString(f.instances[i].InstanceId)) {
+			f.instances[i].State = &types.InstanceState{Name: state}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"go.uber.org/zap"
+)
+
+// EKSAPI is the part of the EKS client container backends call; tests
+// substitute a fake
+type EKSAPI interface {
+	DescribeCluster(ctx context.Context, params *eks.DescribeClusterInput, optFns ...func(*eks.Options)) (*eks.DescribeClusterOutput, error)
+	DescribeNodegroup(ctx context.Context, params *eks.DescribeNodegroupInput, optFns ...func(*eks.Options)) (*eks.DescribeNodegroupOutput, error)
+	UpdateNodegroupConfig(ctx context.Context, params *eks.UpdateNodegroupConfigInput, optFns ...func(*eks.Options)) (*eks.UpdateNodegroupConfigOutput, error)
+}
+
+// AutoScalingAPI is the part of the Auto Scaling client container backends
+// call; tests substitute a fake
+type AutoScalingAPI interface {
+	TerminateInstanceInAutoScalingGroup(ctx context.Context, params *autoscaling.TerminateInstanceInAutoScalingGroupInput, optFns ...func(*autoscaling.Options)) (*autoscaling.TerminateInstanceInAutoScalingGroupOutput, error)
+}
+
+var (
+	_ EKSAPI         = (*eks.Client)(nil)
+	_ AutoScalingAPI = (*autoscaling.Client)(nil)
+)
+
+// eksAPI calls the EKS and Auto Scaling APIs of one region, and the Kubernetes
+// API of its clusters with tokens from a presigned STS request, as aws-iam-
+// authenticator does
+type eksAPI struct {
+	logger      *zap.Logger
+	eks         EKSAPI
+	autoscaling AutoScalingAPI
+	sts         *sts.PresignClient
+
+	pollInterval time.Duration // Between checks for a launch's instances
+}
+
+// newEKSAPI creates the cluster APIs of region
+func newEKSAPI(ctx context.Context, logger *zap.Logger, awsConfig *burstConfig.AWSConfig, region string) (*eksAPI, error) {
+	cfg, err := LoadConfig(ctx, logger, awsConfig, region)
+	if err != nil {
+		return nil, err
+	}
+	return &eksAPI{
+		logger:       logger,
+		eks:          eks.NewFromConfig(cfg),
+		autoscaling:  autoscaling.NewFromConfig(cfg),
+		sts:          sts.NewPresignClient(sts.NewFromConfig(cfg)),
+		pollInterval: 10 * time.Second,
+	}, nil
+}
+
+// eksNodeGroup is the part of a managed node group a launch needs
+type eksNodeGroup struct {
+	ScalingConfig eksScalingConfig
+}
+
+type eksScalingConfig struct {
+	MinSize     int
+	MaxSize     int
+	DesiredSize int
+}
+
+// describeNodeGroup returns a managed node group's scaling
+func (e *eksAPI) describeNodeGroup(ctx context.Context, cluster, nodeGroup string) (*eksNodeGroup, error) {
+	output, err := e.eks.DescribeNodegroup(ctx, &eks.DescribeNodegroupInput{ClusterName: aws.String(cluster), NodegroupName: aws.String(nodeGroup)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe node group %s/%s: %w", cluster, nodeGroup, err)
+	}
+	group := &eksNodeGroup{}
+	if scaling := output.Nodegroup.ScalingConfig; scaling != nil {
+		group.ScalingConfig = eksScalingConfig{
+			MinSize:     int(aws.ToInt32(scaling.MinSize)),
+			MaxSize:     int(aws.ToInt32(scaling.MaxSize)),
+			DesiredSize: int(aws.ToInt32(scaling.DesiredSize)),
+		}
+	}
+	return group, nil
+}
+
+// scaleNodeGroup sets a managed node group's desired size
+func (e *eksAPI) scaleNodeGroup(ctx context.Context, cluster, nodeGroup string, scaling eksScalingConfig) error {
+	_, err := e.eks.UpdateNodegroupConfig(ctx, &eks.UpdateNodegroupConfigInput{
+		ClusterName:   aws.String(cluster),
+		NodegroupName: aws.String(nodeGroup),
+		ScalingConfig: &eksTypes.NodegroupScalingConfig{
+			MinSize:     aws.Int32(int32(scaling.MinSize)),
+			MaxSize:     aws.Int32(int32(scaling.MaxSize)),
+			DesiredSize: aws.Int32(int32(scaling.DesiredSize)),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scale node group %s/%s to %d: %w", cluster, nodeGroup, scaling.DesiredSize, err)
+	}
+	return nil
+}
+
+// describeCluster returns a cluster's Kubernetes API endpoint and the base64
+// PEM of its certificate authority
+func (e *eksAPI) describeCluster(ctx context.Context, cluster string) (string, string, error) {
+	output, err := e.eks.DescribeCluster(ctx, &eks.DescribeClusterInput{Name: aws.String(cluster)})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to describe cluster %s: %w", cluster, err)
+	}
+	var caData string
+	if output.Cluster.CertificateAuthority != nil {
+		caData = aws.ToString(output.Cluster.CertificateAuthority.Data)
+	}
+	return aws.ToString(output.Cluster.Endpoint), caData, nil
+}
+
+// terminateInAutoScalingGroup terminates an instance of a managed node group
+// and lowers the group's desired capacity, so it is not replaced
+func (e *eksAPI) terminateInAutoScalingGroup(ctx context.Context, instanceID string) error {
+	_, err := e.autoscaling.TerminateInstanceInAutoScalingGroup(ctx, &autoscaling.TerminateInstanceInAutoScalingGroupInput{
+		InstanceId:                     aws.String(instanceID),
+		ShouldDecrementDesiredCapacity: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to terminate instance %s in its Auto Scaling group: %w", instanceID, err)
+	}
+	return nil
+}
+
+// kubeToken returns a bearer token for a cluster's Kubernetes API: a
+// presigned STS GetCallerIdentity request naming the cluster
+func (e *eksAPI) kubeToken(ctx context.Context, cluster string) (string, error) {
+	presigned, err := e.sts.PresignGetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}, func(options *sts.PresignOptions) {
+		options.ClientOptions = append(options.ClientOptions, func(options *sts.Options) {
+			options.APIOptions = append(options.APIOptions,
+				smithyhttp.SetHeaderValue("x-k8s-aws-id", cluster),
+				smithyhttp.SetHeaderValue("X-Amz-Expires", "60"))
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to presign a token for cluster %s: %w", cluster, err)
+	}
+	return "k8s-aws-v1." + base64.RawURLEncoding.EncodeToString([]byte(presigned.URL)), nil
+}
+
+// kubeClient returns a client for a cluster's Kubernetes API
+func (e *eksAPI) kubeClient(ctx context.Context, cluster string) (*kubeAPI, error) {
+	endpoint, caData, err := e.describeCluster(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	pem, err := base64.StdEncoding.DecodeString(caData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate authority for cluster %s: %w", cluster, err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("invalid certificate authority for cluster %s", cluster)
+	}
+	token, err := e.kubeToken(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	return &kubeAPI{
+		endpoint: endpoint,
+		token:    token,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}},
+		},
+	}, nil
+}
+
+// kubeAPI calls one cluster's Kubernetes API. Tokens last 15 minutes, longer
+// than any launch waits.
+type kubeAPI struct {
+	endpoint   string
+	token      string
+	httpClient *http.Client
+}
+
+// errKubeNotFound is returned for objects the cluster does not have
+var errKubeNotFound = errors.New("not found in the cluster")
+
+// do sends a request for a path and decodes the response into output, when
+// given. Missing objects return errKubeNotFound.
+func (k *kubeAPI) do(ctx context.Context, method, path string, input, output interface{}) error {
+	var body io.Reader
+	if input != nil {
+		encoded, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(k.endpoint, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+k.token)
+	request.Header.Set("Accept", "application/json")
+	if input != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := k.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return errKubeNotFound
+	}
+	if response.StatusCode >= 300 {
+		var status struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(responseBody, &status) == nil && status.Message != "" {
+			return fmt.Errorf("%s: %s", status.Reason, status.Message)
+		}
+		return fmt.Errorf("HTTP %d from the Kubernetes API", response.StatusCode)
+	}
+	if output == nil {
+		return nil
+	}
+	return json.Unmarshal(responseBody, output)
+}
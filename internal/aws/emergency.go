@@ -0,0 +1,105 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// TerminateAll terminates every managed instance in every region a node group
+// uses, or those of the partitions given: running and stopped nodes, warm pool
+// instances and lingering instances alike. Delegate and cluster capacity is
+// released first so it is not replaced, and termination is not paced by the
+// suspend rate. It returns the instances it terminated, or with dryRun would
+// terminate, and every failure, without stopping at the first region that
+// fails.
+func (c *Client) TerminateAll(ctx context.Context, partitions []string, dryRun bool) ([]burstTypes.InstanceInfo, error) {
+	regions := []string{c.config.Region}
+	for _, partition := range c.appConfig.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			if region := c.nodeGroupRegion(partition.PartitionName, nodeGroup.NodeGroupName); !slices.Contains(regions, region) {
+				regions = append(regions, region)
+			}
+		}
+	}
+
+	var terminated []burstTypes.InstanceInfo
+	var errs []error
+	for _, region := range regions {
+		instances, err := c.terminateAllInRegion(ctx, region, partitions, dryRun)
+		terminated = append(terminated, instances...)
+		if err != nil {
+			c.logger.Error("Failed to terminate managed instances", zap.String("region", region), zap.Error(err))
+			errs = append(errs, fmt.Errorf("region %s: %w", region, err))
+		}
+	}
+	return terminated, errors.Join(errs...)
+}
+
+// terminateAllInRegion runs TerminateAll in one region
+func (c *Client) terminateAllInRegion(ctx context.Context, region string, partitions []string, dryRun bool) ([]burstTypes.InstanceInfo, error) {
+	fleetManager, err := c.fleetManagerFor(region)
+	if err != nil {
+		return nil, err
+	}
+	managed, err := fleetManager.ListManagedInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []burstTypes.InstanceInfo
+	var nodeNames []string
+	for _, instance := range managed {
+		if len(partitions) > 0 && !slices.Contains(partitions, instance.Partition) {
+			continue
+		}
+		instances = append(instances, instance)
+		if instance.NodeName != "" {
+			nodeNames = append(nodeNames, instance.NodeName)
+		}
+	}
+	if len(instances) == 0 || dryRun {
+		return instances, nil
+	}
+
+	// Instances a cluster launched go back to it, which terminates them
+	remaining := nodeNames
+	if c.clusterBacked() {
+		if remaining, err = c.releaseClusterNodes(ctx, region, fleetManager, nodeNames); err != nil {
+			return nil, err
+		}
+	}
+	if c.delegated() {
+		if err := c.releaseDelegatedNodes(ctx, region, fleetManager, remaining); err != nil {
+			return nil, err
+		}
+	}
+	var instanceIds []string
+	for _, instance := range instances {
+		if instance.NodeName == "" || slices.Contains(remaining, instance.NodeName) {
+			instanceIds = append(instanceIds, instance.InstanceID)
+		}
+	}
+	terminatedIds, err := fleetManager.terminateUnpaced(ctx, instanceIds)
+	if err != nil {
+		// Report the instances released and those terminated before the
+		// failure, so they are audited with it
+		var done []burstTypes.InstanceInfo
+		for _, instance := range instances {
+			if !slices.Contains(instanceIds, instance.InstanceID) || slices.Contains(terminatedIds, instance.InstanceID) {
+				done = append(done, instance)
+			}
+		}
+		return done, err
+	}
+
+	c.logger.Warn("Terminated managed instances",
+		zap.String("region", region),
+		zap.Strings("partitions", partitions),
+		zap.Strings("instance_ids", instanceIds))
+	return instances, nil
+}
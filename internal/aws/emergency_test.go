@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_TerminateAll(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	manage := func(id, nodeName, partition string) {
+		ec2.addInstance(id, nodeName, "")
+		instance := &ec2.instances[len(ec2.instances)-1]
+		instance.Tags = append(instance.Tags,
+			types.Tag{Key: aws.String("ManagedBy"), Value: aws.String("aws-slurm-burst")},
+			types.Tag{Key: aws.String("Partition"), Value: aws.String(partition)})
+	}
+	manage("i-1", "aws-cpu-1", "aws")
+	manage("i-2", "", "aws") // A warm pool instance serves no node yet
+	manage("i-3", "gpu-a100-1", "gpu")
+	ec2.addInstance("i-4", "login-1", "")
+	ctx := context.Background()
+
+	instances, err := client.TerminateAll(ctx, []string{"aws"}, true)
+	require.NoError(t, err)
+	assert.Len(t, instances, 2)
+	assert.Empty(t, ec2.terminated, "dry run")
+
+	instances, err = client.TerminateAll(ctx, []string{"aws"}, false)
+	require.NoError(t, err)
+	assert.Len(t, instances, 2)
+	assert.ElementsMatch(t, []string{"i-1", "i-2"}, ec2.terminated)
+
+	instances, err = client.TerminateAll(ctx, nil, false)
+	require.NoError(t, err)
+	require.Len(t, instances, 1, "terminating instances are no longer managed")
+	assert.Equal(t, "gpu-a100-1", instances[0].NodeName)
+	assert.ElementsMatch(t, []string{"i-1", "i-2", "i-3"}, ec2.terminated, "unmanaged instances are left alone")
+}
+
+func TestFleetManager_terminateBatches(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	for _, id := range []string{"i-1", "i-2", "i-3"} {
+		ec2.addInstance(id, "", "")
+	}
+	ec2.unterminable = []string{"i-2"}
+	fleetManager, err := client.fleetManagerFor("us-east-1")
+	require.NoError(t, err)
+
+	terminated, err := fleetManager.terminateBatches(context.Background(), []string{"i-1", "i-2", "i-3"}, 1, nil)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"i-1"}, terminated, "the batches before the failure are reported")
+	assert.Equal(t, []string{"i-1"}, ec2.terminated)
+}
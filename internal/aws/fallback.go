@@ -0,0 +1,282 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/smithy-go"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// Launch fallback stages, tried in this order
+const (
+	FallbackStageOnDemand           = "on-demand"
+	FallbackStageAlternateFamilies  = "alternate-families"
+	FallbackStageSecondaryNodeGroup = "secondary-node-group"
+)
+
+// capacityErrorCodes are the EC2 error codes a retry with different capacity can fix
+var capacityErrorCodes = map[string]bool{
+	"InsufficientInstanceCapacity": true,
+	"InsufficientHostCapacity":     true,
+	"SpotMaxPriceTooLow":           true,
+	"MaxSpotInstanceCountExceeded": true,
+	"UnfulfillableCapacity":        true,
+}
+
+// quotaErrorCodes are the EC2 error codes of launches over the account's
+// service quotas, which only terminations or a quota increase can fix
+var quotaErrorCodes = map[string]bool{
+	"VcpuLimitExceeded":     true,
+	"InstanceLimitExceeded": true,
+}
+
+// FleetLaunchError reports a fleet that launched fewer instances than requested,
+// with the EC2 Fleet error codes explaining the shortfall
+type FleetLaunchError struct {
+	Launched  int
+	Requested int
+	Codes     []string
+	Messages  []string
+}
+
+func (e *FleetLaunchError) Error() string {
+	message := fmt.Sprintf("launched %d of %d required instances", e.Launched, e.Requested)
+	if len(e.Messages) > 0 {
+		message += ": " + strings.Join(e.Messages, "; ")
+	}
+	return message
+}
+
+// zoneErrors collects the per-AZ failures of a gang-scheduled launch
+type zoneErrors []error
+
+func (e zoneErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e zoneErrors) Unwrap() []error {
+	return e
+}
+
+// capacityErrorCode returns the EC2 error code when err is a capacity failure that
+// a fallback launch may avoid, or "" for any other error
+func capacityErrorCode(err error) string {
+	return errorCode(err, capacityErrorCodes)
+}
+
+// errorCode returns the first of codes that err, or a fleet error it wraps,
+// carries, or ""
+func errorCode(err error, codes map[string]bool) string {
+	switch e := err.(type) {
+	case *FleetLaunchError:
+		for _, code := range e.Codes {
+			if codes[code] {
+				return code
+			}
+		}
+	case smithy.APIError:
+		if codes[e.ErrorCode()] {
+			return e.ErrorCode()
+		}
+	}
+
+	// Search every wrapped error; a gang launch wraps one failure per AZ
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, wrapped := range e.Unwrap() {
+			if code := errorCode(wrapped, codes); code != "" {
+				return code
+			}
+		}
+	case interface{ Unwrap() error }:
+		return errorCode(e.Unwrap(), codes)
+	}
+
+	return ""
+}
+
+// IsCapacityError reports whether a launch failed for lack of capacity, which
+// retrying later may fix
+func IsCapacityError(err error) bool {
+	return capacityErrorCode(err) != ""
+}
+
+// IsQuotaError reports whether a launch failed because the account is at its
+// EC2 vCPU or instance quota, which frees up as other instances terminate
+func IsQuotaError(err error) bool {
+	return errorCode(err, quotaErrorCodes) != ""
+}
+
+// fallbackStage is one retry in the launch fallback chain
+type fallbackStage struct {
+	Name string
+	Req  *LaunchRequest
+}
+
+// fallbackStages builds the configured retries for a failed launch. Each stage keeps
+// the relaxations of the stages before it: on-demand, then alternate families, then
+// the secondary node group with both the original and alternate families.
+func (c *Client) fallbackStages(req *LaunchRequest) []fallbackStage {
+	policy := c.config.LaunchFallback
+	// A Capacity Block is the only capacity its plan may use
+	if req.InstanceRequirements == nil || req.CapacityBlock != nil {
+		return nil
+	}
+
+	var stages []fallbackStage
+	current := req
+
+	// Launches queued for spot capacity wait for it rather than paying on demand
+	if policy.OnDemand && !req.QueueForCapacity && (req.InstanceRequirements.PreferSpot || req.InstanceRequirements.AllowMixedPricing) {
+		current = withRequirements(current, func(r *types.InstanceRequirements) {
+			r.PreferSpot = false
+			r.AllowMixedPricing = false
+		})
+		current.SpotStrategy = nil
+		stages = append(stages, fallbackStage{Name: FallbackStageOnDemand, Req: current})
+	}
+
+	// Heterogeneous roles pin their own instance types
+	alternates := alternateFamilies(req.InstanceRequirements.InstanceFamilies, policy.AlternateFamilies)
+	if len(alternates) > 0 && len(req.NodeRoles) == 0 {
+		current = withRequirements(current, func(r *types.InstanceRequirements) {
+			r.InstanceFamilies = alternates
+		})
+		stages = append(stages, fallbackStage{Name: FallbackStageAlternateFamilies, Req: current})
+	}
+
+	if policy.SecondaryPartition != "" && policy.SecondaryNodeGroup != "" &&
+		(policy.SecondaryPartition != req.Partition || policy.SecondaryNodeGroup != req.NodeGroup) {
+		secondary := withRequirements(current, func(r *types.InstanceRequirements) {
+			r.InstanceFamilies = dedupe(append(append([]string{}, req.InstanceRequirements.InstanceFamilies...), r.InstanceFamilies...))
+		})
+		secondary.Partition = policy.SecondaryPartition
+		secondary.NodeGroup = policy.SecondaryNodeGroup
+		stages = append(stages, fallbackStage{Name: FallbackStageSecondaryNodeGroup, Req: secondary})
+	}
+
+	return stages
+}
+
+// launchWithFallback walks the fallback chain after a capacity failure, recording
+// every retry. The chain stops at the first success or at a failure that is not
+// about capacity. Nodes a partial launch served keep their instances; the
+// retries launch only the rest.
+func (c *Client) launchWithFallback(ctx context.Context, req *LaunchRequest, partial *LaunchResult, launchErr error) (*LaunchResult, error) {
+	reason := capacityErrorCode(launchErr)
+	if reason == "" {
+		return partial, launchErr
+	}
+
+	var fallbacks []types.LaunchFallback
+	for _, stage := range c.fallbackStages(req) {
+		if partial != nil {
+			stage.Req = stage.Req.WithoutNodes(partial.ServedNodes())
+		}
+		c.logger.Warn("Launch failed for lack of capacity, trying fallback",
+			zap.String("stage", stage.Name),
+			zap.String("reason", reason),
+			zap.String("partition", stage.Req.Partition),
+			zap.String("node_group", stage.Req.NodeGroup))
+
+		result, err := c.launch(ctx, stage.Req)
+		record := types.LaunchFallback{
+			Stage:            stage.Name,
+			Reason:           reason,
+			Partition:        stage.Req.Partition,
+			NodeGroup:        stage.Req.NodeGroup,
+			Region:           c.nodeGroupRegion(stage.Req.Partition, stage.Req.NodeGroup),
+			InstanceFamilies: stage.Req.InstanceRequirements.InstanceFamilies,
+			PurchaseOption:   purchaseOption(stage.Req.InstanceRequirements),
+			Succeeded:        err == nil,
+		}
+
+		if err == nil {
+			result.Fallbacks = append(fallbacks, record)
+			c.logger.Info("Fallback launch succeeded",
+				zap.String("stage", stage.Name),
+				zap.Int("instances", len(result.Instances)))
+			return partial.merge(result), nil
+		}
+
+		record.Error = err.Error()
+		fallbacks = append(fallbacks, record)
+		launchErr = err
+		partial = partial.merge(result)
+
+		if reason = capacityErrorCode(err); reason == "" {
+			break
+		}
+	}
+
+	if len(fallbacks) == 0 {
+		return partial, launchErr
+	}
+
+	return partial.merge(&LaunchResult{Fallbacks: fallbacks}), fmt.Errorf("launch failed after %d fallback attempts: %w", len(fallbacks), launchErr)
+}
+
+// withRequirements copies a launch request with modified instance requirements
+func withRequirements(req *LaunchRequest, modify func(*types.InstanceRequirements)) *LaunchRequest {
+	requirements := *req.InstanceRequirements
+	modify(&requirements)
+
+	copied := *req
+	copied.InstanceRequirements = &requirements
+	return &copied
+}
+
+// alternateFamilies returns the configured alternates for the requested families,
+// in order and without any family already requested
+func alternateFamilies(requested []string, alternatesByFamily map[string][]string) []string {
+	tried := make(map[string]bool)
+	for _, family := range requested {
+		tried[family] = true
+	}
+
+	var alternates []string
+	for _, family := range requested {
+		// Requests may name concrete types (c5n.18xlarge); alternates are keyed by family
+		family = strings.SplitN(family, ".", 2)[0]
+		for _, alternate := range alternatesByFamily[family] {
+			if !tried[alternate] {
+				tried[alternate] = true
+				alternates = append(alternates, alternate)
+			}
+		}
+	}
+
+	return alternates
+}
+
+// dedupe removes repeated entries, keeping the first occurrence
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// purchaseOption describes how a request buys capacity
+func purchaseOption(req *types.InstanceRequirements) string {
+	switch {
+	case req.AllowMixedPricing:
+		return "mixed"
+	case req.PreferSpot:
+		return "spot"
+	default:
+		return "on-demand"
+	}
+}
@@ -0,0 +1,140 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCapacityErrorCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "fleet error with capacity code",
+			err:      fmt.Errorf("failed to process fleet result: %w", &FleetLaunchError{Requested: 4, Codes: []string{"InsufficientInstanceCapacity"}}),
+			expected: "InsufficientInstanceCapacity",
+		},
+		{
+			name:     "API error",
+			err:      fmt.Errorf("EC2 CreateFleet failed: %w", &smithy.GenericAPIError{Code: "SpotMaxPriceTooLow"}),
+			expected: "SpotMaxPriceTooLow",
+		},
+		{
+			name: "capacity failure in a later AZ",
+			err: fmt.Errorf("gang scheduling failed in all availability zones: %w", zoneErrors{
+				fmt.Errorf("us-east-1a: %w", &FleetLaunchError{Codes: []string{"InvalidParameterValue"}}),
+				fmt.Errorf("us-east-1b: %w", &FleetLaunchError{Codes: []string{"InsufficientInstanceCapacity"}}),
+			}),
+			expected: "InsufficientInstanceCapacity",
+		},
+		{
+			name:     "non-capacity API error",
+			err:      &smithy.GenericAPIError{Code: "UnauthorizedOperation"},
+			expected: "",
+		},
+		{
+			name:     "plain error",
+			err:      errors.New("architecture validation failed"),
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, capacityErrorCode(tt.err))
+			assert.Equal(t, tt.expected != "", IsCapacityError(tt.err))
+		})
+	}
+}
+
+func TestIsQuotaError(t *testing.T) {
+	assert.True(t, IsQuotaError(fmt.Errorf("failed to process fleet result: %w", &FleetLaunchError{Requested: 4, Codes: []string{"VcpuLimitExceeded"}})))
+	assert.True(t, IsQuotaError(&smithy.GenericAPIError{Code: "InstanceLimitExceeded"}))
+	assert.False(t, IsQuotaError(&FleetLaunchError{Codes: []string{"InsufficientInstanceCapacity"}}))
+	assert.False(t, IsCapacityError(&FleetLaunchError{Codes: []string{"VcpuLimitExceeded"}}), "fallback launches do not help")
+}
+
+func TestClient_fallbackStages(t *testing.T) {
+	appConfig := &config.Config{
+		Slurm: config.SlurmConfig{
+			Partitions: []config.PartitionConfig{
+				{PartitionName: "aws", NodeGroups: []config.NodeGroupConfig{{NodeGroupName: "cpu", Region: "us-east-1"}}},
+				{PartitionName: "awswest", NodeGroups: []config.NodeGroupConfig{{NodeGroupName: "cpu", Region: "us-west-2"}}},
+			},
+		},
+	}
+	client := &Client{
+		logger:    zaptest.NewLogger(t),
+		appConfig: appConfig,
+		config: &config.AWSConfig{
+			Region: "us-east-1",
+			LaunchFallback: config.LaunchFallbackConfig{
+				Enabled:            true,
+				OnDemand:           true,
+				AlternateFamilies:  map[string][]string{"c5n": {"c6in", "c5n", "m5n"}},
+				SecondaryPartition: "awswest",
+				SecondaryNodeGroup: "cpu",
+			},
+		},
+	}
+
+	req := &LaunchRequest{
+		NodeIds:   []string{"aws-cpu-1", "aws-cpu-2"},
+		Partition: "aws",
+		NodeGroup: "cpu",
+		InstanceRequirements: &types.InstanceRequirements{
+			InstanceFamilies: []string{"c5n.18xlarge"},
+			PreferSpot:       true,
+		},
+		SpotStrategy: &SpotPricingStrategy{SpotAllocationRatio: 1},
+	}
+
+	stages := client.fallbackStages(req)
+	require.Len(t, stages, 3)
+
+	assert.Equal(t, FallbackStageOnDemand, stages[0].Name)
+	assert.False(t, stages[0].Req.InstanceRequirements.PreferSpot)
+	assert.Nil(t, stages[0].Req.SpotStrategy)
+
+	assert.Equal(t, FallbackStageAlternateFamilies, stages[1].Name)
+	assert.Equal(t, []string{"c6in", "c5n", "m5n"}, stages[1].Req.InstanceRequirements.InstanceFamilies)
+	assert.False(t, stages[1].Req.InstanceRequirements.PreferSpot)
+
+	assert.Equal(t, FallbackStageSecondaryNodeGroup, stages[2].Name)
+	assert.Equal(t, "awswest", stages[2].Req.Partition)
+	assert.Equal(t, []string{"c5n.18xlarge", "c6in", "c5n", "m5n"}, stages[2].Req.InstanceRequirements.InstanceFamilies)
+	assert.Equal(t, "us-west-2", client.nodeGroupRegion(stages[2].Req.Partition, stages[2].Req.NodeGroup))
+
+	// The original request is untouched
+	assert.True(t, req.InstanceRequirements.PreferSpot)
+	assert.Equal(t, []string{"c5n.18xlarge"}, req.InstanceRequirements.InstanceFamilies)
+
+	t.Run("on-demand request skips the on-demand stage", func(t *testing.T) {
+		onDemand := *req
+		onDemand.InstanceRequirements = &types.InstanceRequirements{InstanceFamilies: []string{"c5n"}}
+
+		stages := client.fallbackStages(&onDemand)
+		require.Len(t, stages, 2)
+		assert.Equal(t, FallbackStageAlternateFamilies, stages[0].Name)
+	})
+
+	t.Run("request queued for capacity stays on spot", func(t *testing.T) {
+		queued := *req
+		queued.QueueForCapacity = true
+
+		stages := client.fallbackStages(&queued)
+		require.Len(t, stages, 2)
+		assert.Equal(t, FallbackStageAlternateFamilies, stages[0].Name)
+		assert.True(t, stages[0].Req.InstanceRequirements.PreferSpot)
+	})
+}
@@ -3,13 +3,19 @@ package aws
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/chaos"
 	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ratelimit"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
 	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"go.uber.org/zap"
 )
@@ -17,14 +23,64 @@ import (
 // FleetManager handles EC2 Fleet operations for Slurm node provisioning
 type FleetManager struct {
 	logger        *zap.Logger
-	ec2Client     *ec2.Client
+	ec2Client     EC2API
 	region        string
 	gangScheduler *GangScheduler
+	spotManager   *SpotManager
+	catalog       *InstanceCatalog
+
+	spotScoresEnabled bool // Rank spot pools with GetSpotPlacementScores
+
+	// Node launch and termination budgets from slurm.resume_rate and suspend_rate
+	launchLimiter    *ratelimit.Limiter
+	terminateLimiter *ratelimit.Limiter
+
+	faults *chaos.Injector // Game-day failure injection, nil unless configured
+
+	poolLockDir string // Exchange directory holding the warm and linger pool locks; empty takes none
 }
 
 // NewFleetManager creates a new fleet manager
 func NewFleetManager(logger *zap.Logger, awsConfig *burstConfig.AWSConfig) (*FleetManager, error) {
-	// Create authentication configuration
+	// Create authentication provider
+	authProvider := NewAuthenticationProvider(logger, authenticationConfigFor(awsConfig))
+
+	// Get AWS configuration with secure authentication
+	cfg, err := authProvider.GetAWSConfig(context.Background(), awsConfig.Region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure AWS authentication: %w", err)
+	}
+
+	return NewFleetManagerWithAPI(logger, awsConfig, ec2.NewFromConfig(cfg)), nil
+}
+
+// NewFleetManagerWithAPI creates a fleet manager that calls ec2Client, e.g. a
+// fake in tests, for awsConfig.Region
+func NewFleetManagerWithAPI(logger *zap.Logger, awsConfig *burstConfig.AWSConfig, ec2Client EC2API) *FleetManager {
+	fleetManager := &FleetManager{
+		logger:    logger,
+		ec2Client: ec2Client,
+		region:    awsConfig.Region,
+
+		spotScoresEnabled: awsConfig.SpotPlacementScores,
+	}
+
+	// Initialize gang scheduler and spot strategy
+	fleetManager.gangScheduler = NewGangScheduler(logger, ec2Client, fleetManager)
+	fleetManager.spotManager = NewSpotManager(logger, ec2Client, awsConfig.Region)
+
+	// Initialize instance type catalog (loaded lazily on first launch)
+	if awsConfig.InstanceCatalog.Enabled {
+		refreshInterval := time.Duration(awsConfig.InstanceCatalog.RefreshIntervalHours) * time.Hour
+		fleetManager.catalog = NewInstanceCatalog(logger, ec2Client, awsConfig.Region, awsConfig.InstanceCatalog.CacheDir, refreshInterval)
+	}
+
+	return fleetManager
+}
+
+// authenticationConfigFor maps the configured AWS authentication settings onto the
+// authentication provider's configuration
+func authenticationConfigFor(awsConfig *burstConfig.AWSConfig) *AuthenticationConfig {
 	authConfig := &AuthenticationConfig{
 		Method:  AuthenticationMethod(awsConfig.AuthenticationMethod),
 		Profile: awsConfig.Profile,
@@ -80,26 +136,17 @@ func NewFleetManager(logger *zap.Logger, awsConfig *burstConfig.AWSConfig) (*Fle
 		authConfig.Method = AuthMethodInstanceProfile
 	}
 
-	// Create authentication provider
-	authProvider := NewAuthenticationProvider(logger, authConfig)
+	return authConfig
+}
 
-	// Get AWS configuration with secure authentication
-	cfg, err := authProvider.GetAWSConfig(context.Background(), awsConfig.Region)
+// LoadConfig returns the SDK configuration for region with the configured
+// authentication, for building service clients
+func LoadConfig(ctx context.Context, logger *zap.Logger, awsConfig *burstConfig.AWSConfig, region string) (aws.Config, error) {
+	cfg, err := NewAuthenticationProvider(logger, authenticationConfigFor(awsConfig)).GetAWSConfig(ctx, region)
 	if err != nil {
-		return nil, fmt.Errorf("failed to configure AWS authentication: %w", err)
-	}
-
-	ec2Client := ec2.NewFromConfig(cfg)
-	fleetManager := &FleetManager{
-		logger:    logger,
-		ec2Client: ec2Client,
-		region:    awsConfig.Region,
+		return aws.Config{}, fmt.Errorf("failed to configure AWS authentication: %w", err)
 	}
-
-	// Initialize gang scheduler
-	fleetManager.gangScheduler = NewGangScheduler(logger, ec2Client, fleetManager)
-
-	return fleetManager, nil
+	return cfg, nil
 }
 
 // FleetRequest represents a request to launch EC2 instances
@@ -113,6 +160,32 @@ type FleetRequest struct {
 	SubnetIds            []string
 	SecurityGroupIds     []string
 	Tags                 map[string]string
+	UserDataParts        []userdata.Part      // Merged into the launch template's user data
+	SpotStrategy         *SpotPricingStrategy // Optional on-demand/spot capacity split
+	SpotOptions          burstConfig.SpotOptionsConfig
+	OnDemandOptions      burstConfig.OnDemandOptionsConfig
+	ConfiguredOverrides  []burstConfig.LaunchTemplateOverride // Used as written for their instance types; standalone mode
+	CapacityBlock        *burstTypes.CapacityBlockSpec        // Reserved capacity the fleet launches into
+	NodeBootstrap        *userdata.NodeBootstrap              // Installs slurmd for the AMI's distribution; Distro empty detects it
+	MungeKey             *userdata.MungeKey                   // Installs the controller's munge key from SSM or S3
+	Scratch              *ScratchVolume                       // Added to every instance's block devices
+
+	spotScores  map[string]int    // Spot placement score by AZ, set for spot-heavy fleets
+	subnetZones map[string]string // Subnet ID -> AZ, set alongside spotScores
+
+	// The launch template version launched from, once a launch step has
+	// described it; empty when the version was not found
+	templateData *types.ResponseLaunchTemplateData
+}
+
+// maxSpotPrice is the spot price cap per instance-hour: the lower of the
+// execution plan's and the node group's, or zero when neither sets one
+func (req *FleetRequest) maxSpotPrice() float64 {
+	price := req.InstanceRequirements.MaxSpotPrice
+	if configured := req.SpotOptions.MaxPrice; configured > 0 && (price <= 0 || configured < price) {
+		price = configured
+	}
+	return price
 }
 
 // LaunchTemplateConfig represents launch template configuration
@@ -124,13 +197,22 @@ type LaunchTemplateConfig struct {
 
 // FleetResponse represents the result of launching instances
 type FleetResponse struct {
-	Instances []burstTypes.InstanceInfo
-	FleetId   string
-	Errors    []string
+	Instances  []burstTypes.InstanceInfo
+	FleetId    string
+	Errors     []string
+	ErrorCodes []string // EC2 error codes, parallel to Errors
+
+	SpotPlacementScores map[string]int // Spot placement score by AZ at launch time
+
+	templateData *types.ResponseLaunchTemplateData // As described at launch; nil when no launch step described it
 }
 
 // LaunchInstanceFleet launches EC2 instances using EC2 Fleet API
 func (f *FleetManager) LaunchInstanceFleet(ctx context.Context, req *FleetRequest) (*FleetResponse, error) {
+	// Launch steps record what they learn on the request, not the caller's
+	launch := *req
+	req = &launch
+
 	f.logger.Info("Launching EC2 Fleet",
 		zap.Strings("node_ids", req.NodeIds),
 		zap.String("partition", req.Partition),
@@ -144,6 +226,142 @@ func (f *FleetManager) LaunchInstanceFleet(ctx context.Context, req *FleetReques
 		return nil, fmt.Errorf("fleet request validation failed: %w", err)
 	}
 
+	// Load instance catalog; static heuristics are used if it is unavailable
+	if f.catalog != nil {
+		if err := f.catalog.EnsureLoaded(ctx); err != nil {
+			f.logger.Warn("Instance catalog unavailable, using built-in instance heuristics", zap.Error(err))
+		}
+	}
+
+	// Ensure the launch template AMI runs Linux on the requested CPU architecture
+	image, err := f.validateLaunchTemplateImage(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("launch template AMI validation failed: %w", err)
+	}
+
+	// Nodes whose AMI lacks Slurm install it for the AMI's distribution
+	if req.NodeBootstrap != nil {
+		parts, err := f.nodeBootstrapParts(req, image)
+		if err != nil {
+			return nil, fmt.Errorf("node bootstrap: %w", err)
+		}
+		bootstrapped := *req
+		bootstrapped.UserDataParts = append(parts, req.UserDataParts...)
+		req = &bootstrapped
+	}
+	if req.MungeKey != nil {
+		script, err := userdata.MungeKeyScript(*req.MungeKey)
+		if err != nil {
+			return nil, err
+		}
+		keyed := *req
+		keyed.UserDataParts = append([]userdata.Part{userdata.NewPart(userdata.MungeKeyFilename, script)}, req.UserDataParts...)
+		req = &keyed
+	}
+
+	// A Capacity Block is in one AZ, so only its subnets can launch into it
+	if block := req.CapacityBlock; block != nil && block.AvailabilityZone != "" {
+		subnetIds, err := f.subnetsInZone(ctx, req.SubnetIds, block.AvailabilityZone)
+		if err != nil {
+			return nil, fmt.Errorf("capacity block %s: %w", block.ReservationID, err)
+		}
+		inZone := *req
+		inZone.SubnetIds = subnetIds
+		req = &inZone
+	}
+
+	// Launch from a temporary template version when the job adds user data,
+	// targets a Capacity Block or needs a scratch volume
+	if len(req.UserDataParts) > 0 || req.CapacityBlock != nil || req.Scratch != nil {
+		version, cleanup, err := f.prepareLaunchTemplateVersion(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare launch template: %w", err)
+		}
+		defer cleanup()
+
+		prepared := *req
+		prepared.LaunchTemplate.Version = version
+		prepared.UserDataParts = nil
+		req = &prepared
+	}
+
+	// Score spot pools once; both AZ ordering and override priorities use the scores
+	req = f.scoreSpotPools(ctx, req)
+
+	// Mixed-pricing requests without an explicit split get one from the spot manager
+	if req.SpotStrategy == nil && req.InstanceRequirements.AllowMixedPricing && f.spotManager != nil {
+		strategy, err := f.spotManager.OptimizeSpotStrategy(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine spot strategy: %w", err)
+		}
+		withStrategy := *req
+		withStrategy.SpotStrategy = strategy
+		req = &withStrategy
+	}
+
+	// Gang scheduling pins MPI jobs to one AZ per attempt, launching each through launchFleet
+	var response *FleetResponse
+	if req.Job.IsMPIJob && req.InstanceRequirements.RequiresEFA {
+		// The gang launches as one fleet, so it waits for its whole node count
+		if err := f.launchLimiter.Wait(ctx, len(req.NodeIds)); err != nil {
+			return nil, err
+		}
+		f.logger.Info("Using gang scheduling for MPI job")
+		response, err = f.gangScheduler.AtomicProvision(ctx, req)
+	} else {
+		response, err = f.launchFleetChunks(ctx, req)
+	}
+	if response != nil {
+		response.templateData = req.templateData
+	}
+	return response, err
+}
+
+// launchFleetChunks launches a request as fleets of at most a minute's launch
+// budget, waiting for the budget before each. A failed chunk stops the launch;
+// the response holds the instances earlier chunks launched.
+func (f *FleetManager) launchFleetChunks(ctx context.Context, req *FleetRequest) (*FleetResponse, error) {
+	chunks := ratelimit.Chunks(req.NodeIds, f.launchLimiter.Burst())
+	if len(chunks) == 1 {
+		if err := f.launchLimiter.Wait(ctx, len(req.NodeIds)); err != nil {
+			return nil, err
+		}
+		return f.launchFleet(ctx, req)
+	}
+
+	f.logger.Info("Launching fleet in chunks to respect the resume rate",
+		zap.Int("nodes", len(req.NodeIds)),
+		zap.Int("chunks", len(chunks)))
+
+	merged := &FleetResponse{}
+	var fleetIDs []string
+	for _, nodeIds := range chunks {
+		if err := f.launchLimiter.Wait(ctx, len(nodeIds)); err != nil {
+			return merged, err
+		}
+
+		chunk := *req
+		chunk.NodeIds = nodeIds
+		response, err := f.launchFleet(ctx, &chunk)
+		if response != nil {
+			merged.Instances = append(merged.Instances, response.Instances...)
+			merged.Errors = append(merged.Errors, response.Errors...)
+			merged.ErrorCodes = append(merged.ErrorCodes, response.ErrorCodes...)
+			merged.SpotPlacementScores = response.SpotPlacementScores
+			if response.FleetId != "" {
+				fleetIDs = append(fleetIDs, response.FleetId)
+			}
+		}
+		merged.FleetId = strings.Join(fleetIDs, ",")
+		if err != nil {
+			return merged, err
+		}
+	}
+	return merged, nil
+}
+
+// launchFleet creates the placement group and fleet for an already validated request
+func (f *FleetManager) launchFleet(ctx context.Context, req *FleetRequest) (*FleetResponse, error) {
 	// Create placement group if needed for MPI jobs
 	var placementGroupName string
 	if req.InstanceRequirements.PlacementGroupType != "" && req.Job.IsMPIJob {
@@ -160,16 +378,18 @@ func (f *FleetManager) LaunchInstanceFleet(ctx context.Context, req *FleetReques
 		return nil, fmt.Errorf("failed to build fleet request: %w", err)
 	}
 
-	// Use gang scheduling for MPI jobs requiring atomic provisioning
-	if req.Job.IsMPIJob && req.InstanceRequirements.RequiresEFA {
-		f.logger.Info("Using gang scheduling for MPI job")
-		return f.gangScheduler.AtomicProvision(ctx, req)
-	}
-
-	// Launch fleet normally for non-MPI jobs
-	fleetResult, err := f.ec2Client.CreateFleet(ctx, fleetRequest)
-	if err != nil {
-		return nil, fmt.Errorf("EC2 CreateFleet failed: %w", err)
+	var fleetResult *ec2.CreateFleetOutput
+	if injected := f.faults.Fleet(strings.Join(req.NodeIds, ",")); injected != nil {
+		// Fail the way a launch without capacity does, so fallbacks and rollbacks run
+		fleetResult = &ec2.CreateFleetOutput{Errors: []types.CreateFleetError{{
+			ErrorCode:    aws.String("InsufficientInstanceCapacity"),
+			ErrorMessage: aws.String(injected.Error()),
+		}}}
+	} else {
+		fleetResult, err = f.ec2Client.CreateFleet(ctx, fleetRequest)
+		if err != nil {
+			return nil, fmt.Errorf("EC2 CreateFleet failed: %w", err)
+		}
 	}
 
 	f.logger.Info("EC2 Fleet created",
@@ -178,9 +398,13 @@ func (f *FleetManager) LaunchInstanceFleet(ctx context.Context, req *FleetReques
 
 	// Process results and get instance information
 	response, err := f.processFleetResult(ctx, fleetResult, req.NodeIds)
+	if needsOnDemandFallback(req, response, err) {
+		return f.launchOnDemandFallback(ctx, req, response)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to process fleet result: %w", err)
+		return response, fmt.Errorf("failed to process fleet result: %w", err)
 	}
+	response.SpotPlacementScores = req.spotScores
 
 	return response, nil
 }
@@ -215,6 +439,9 @@ func (f *FleetManager) buildFleetRequest(req *FleetRequest, placementGroupName s
 	if req.InstanceRequirements.PreferSpot {
 		purchasingOption = types.DefaultTargetCapacityTypeSpot
 	}
+	if req.CapacityBlock != nil {
+		purchasingOption = types.DefaultTargetCapacityTypeCapacityBlock
+	}
 
 	fleetRequest := &ec2.CreateFleetInput{
 		LaunchTemplateConfigs: []types.FleetLaunchTemplateConfigRequest{
@@ -227,23 +454,72 @@ func (f *FleetManager) buildFleetRequest(req *FleetRequest, placementGroupName s
 		Type: types.FleetTypeInstant, // Synchronous launching for gang scheduling
 	}
 
+	// A spot strategy splits capacity explicitly into on-demand anchors and spot nodes
+	useSpot := req.InstanceRequirements.PreferSpot
+	useOnDemand := (!req.InstanceRequirements.PreferSpot || req.InstanceRequirements.AllowMixedPricing) && req.CapacityBlock == nil
+	spotCapacity := nodeCount
+	if req.SpotStrategy != nil {
+		onDemandCount, spotCount := req.SpotStrategy.CapacitySplit(nodeCount)
+		spotCapacity = spotCount
+		fleetRequest.TargetCapacitySpecification.OnDemandTargetCapacity = aws.Int32(int32(onDemandCount))
+		fleetRequest.TargetCapacitySpecification.SpotTargetCapacity = aws.Int32(int32(spotCount))
+		useSpot = spotCount > 0
+		useOnDemand = onDemandCount > 0
+		if useSpot {
+			fleetRequest.TargetCapacitySpecification.DefaultTargetCapacityType = types.DefaultTargetCapacityTypeSpot
+		} else {
+			fleetRequest.TargetCapacitySpecification.DefaultTargetCapacityType = types.DefaultTargetCapacityTypeOnDemand
+		}
+	}
+
 	// Configure spot options if using spot instances
-	if req.InstanceRequirements.PreferSpot {
+	if useSpot {
 		fleetRequest.SpotOptions = &types.SpotOptionsRequest{
 			AllocationStrategy:           types.SpotAllocationStrategyLowestPrice,
 			InstanceInterruptionBehavior: types.SpotInstanceInterruptionBehaviorTerminate,
 		}
 
-		if req.InstanceRequirements.MaxSpotPrice > 0 {
-			fleetRequest.SpotOptions.MaxTotalPrice = aws.String(fmt.Sprintf("%.4f", req.InstanceRequirements.MaxSpotPrice))
+		// Scored pools launch in priority order instead of chasing the lowest price
+		if len(req.spotScores) > 0 {
+			fleetRequest.SpotOptions.AllocationStrategy = types.SpotAllocationStrategyCapacityOptimizedPrioritized
+		}
+		if req.SpotOptions.AllocationStrategy != "" {
+			fleetRequest.SpotOptions.AllocationStrategy = types.SpotAllocationStrategy(req.SpotOptions.AllocationStrategy)
+		}
+		if req.SpotOptions.InstanceInterruptionBehavior != "" {
+			fleetRequest.SpotOptions.InstanceInterruptionBehavior = types.SpotInstanceInterruptionBehavior(req.SpotOptions.InstanceInterruptionBehavior)
+		}
+
+		// MaxSpotPrice is per instance-hour; the fleet limit covers all spot capacity
+		if maxPrice := req.maxSpotPrice(); maxPrice > 0 {
+			maxTotal := maxPrice * float64(spotCapacity)
+			fleetRequest.SpotOptions.MaxTotalPrice = aws.String(fmt.Sprintf("%.4f", maxTotal))
 		}
 	}
 
 	// Configure on-demand options
-	if !req.InstanceRequirements.PreferSpot || req.InstanceRequirements.AllowMixedPricing {
+	if useOnDemand {
 		fleetRequest.OnDemandOptions = &types.OnDemandOptionsRequest{
 			AllocationStrategy: types.FleetOnDemandAllocationStrategyLowestPrice,
 		}
+		if req.OnDemandOptions.AllocationStrategy != "" {
+			fleetRequest.OnDemandOptions.AllocationStrategy = types.FleetOnDemandAllocationStrategy(req.OnDemandOptions.AllocationStrategy)
+		}
+
+		// Prioritized on-demand capacity follows the override order unless spot
+		// placement scores already ranked the overrides
+		if fleetRequest.OnDemandOptions.AllocationStrategy == types.FleetOnDemandAllocationStrategyPrioritized {
+			for i := range overrides {
+				if overrides[i].Priority == nil {
+					overrides[i].Priority = aws.Float64(float64(i))
+				}
+			}
+		}
+		if req.OnDemandOptions.CapacityReservationPreference == string(types.FleetCapacityReservationUsageStrategyUseCapacityReservationsFirst) {
+			fleetRequest.OnDemandOptions.CapacityReservationOptions = &types.CapacityReservationOptionsRequest{
+				UsageStrategy: types.FleetCapacityReservationUsageStrategyUseCapacityReservationsFirst,
+			}
+		}
 	}
 
 	// Add fleet-level tags
@@ -274,8 +550,19 @@ func (f *FleetManager) buildLaunchTemplateOverrides(req *FleetRequest, placement
 	// Select optimal instance types based on requirements
 	instanceTypes := f.selectInstanceTypes(req.InstanceRequirements)
 
-	// Create overrides for each instance type in each subnet
+	// Create overrides for each instance type in each subnet; configured
+	// overrides for a type replace the generated ones
 	for _, instanceType := range instanceTypes {
+		if configured, ok := configuredOverrides(req, instanceType); ok {
+			for _, override := range configured {
+				if placementGroupName != "" {
+					override.Placement = &types.Placement{GroupName: aws.String(placementGroupName)}
+				}
+				overrides = append(overrides, override)
+			}
+			continue
+		}
+
 		for _, subnetId := range req.SubnetIds {
 			override := types.FleetLaunchTemplateOverridesRequest{
 				InstanceType:     types.InstanceType(instanceType),
@@ -294,11 +581,21 @@ func (f *FleetManager) buildLaunchTemplateOverrides(req *FleetRequest, placement
 		}
 	}
 
+	// Never mix architectures: drop instance types the AMI cannot boot on
+	if req.InstanceRequirements.Architecture != "" {
+		overrides = f.filterOverridesByArchitecture(overrides, req.InstanceRequirements.Architecture)
+	}
+
 	// Filter for EFA-capable instances if EFA is required
 	if req.InstanceRequirements.RequiresEFA {
 		overrides = f.enhanceLaunchTemplateForEFA(overrides, true)
 	}
 
+	// Prefer the AZs most likely to have spot capacity
+	if len(req.spotScores) > 0 {
+		overrides = prioritizeOverridesBySpotScore(overrides, req.subnetZones, req.spotScores)
+	}
+
 	f.logger.Debug("Built launch template overrides",
 		zap.Int("override_count", len(overrides)),
 		zap.Strings("instance_types", instanceTypes),
@@ -308,19 +605,64 @@ func (f *FleetManager) buildLaunchTemplateOverrides(req *FleetRequest, placement
 	return overrides
 }
 
+// configuredOverrides turns the node group's overrides for instanceType into
+// fleet overrides as written: their spot price cap, weight and pinned subnet.
+// Unpinned overrides cover every subnet of the request; pinned ones are dropped
+// when the launch was narrowed to other subnets, e.g. one AZ for gang jobs.
+// ok reports whether the type is configured at all.
+func configuredOverrides(req *FleetRequest, instanceType string) (overrides []types.FleetLaunchTemplateOverridesRequest, ok bool) {
+	for _, configured := range req.ConfiguredOverrides {
+		if configured.InstanceType != instanceType {
+			continue
+		}
+		ok = true
+
+		subnetIds := req.SubnetIds
+		if configured.SubnetID != "" {
+			if !slices.Contains(req.SubnetIds, configured.SubnetID) {
+				continue
+			}
+			subnetIds = []string{configured.SubnetID}
+		}
+
+		weight := configured.WeightedCapacity
+		if weight <= 0 {
+			weight = 1.0
+		}
+		for _, subnetId := range subnetIds {
+			override := types.FleetLaunchTemplateOverridesRequest{
+				InstanceType:     types.InstanceType(instanceType),
+				SubnetId:         aws.String(subnetId),
+				WeightedCapacity: aws.Float64(weight),
+			}
+			if configured.SpotPrice != "" {
+				override.MaxPrice = aws.String(configured.SpotPrice)
+			}
+			overrides = append(overrides, override)
+		}
+	}
+	return overrides, ok
+}
+
 // selectInstanceTypes chooses optimal instance types based on job requirements
 func (f *FleetManager) selectInstanceTypes(req *burstTypes.InstanceRequirements) []string {
 	// Use instance families from requirements if specified (ASBA mode)
 	if len(req.InstanceFamilies) > 0 {
-		// ASBA has already selected specific instance types, use them directly
-		return req.InstanceFamilies
+		return f.resolveRequestedInstanceTypes(req)
 	}
 
 	// Fallback instance selection for standalone mode
 	var instanceTypes []string
 
 	// Select instance types based on resource requirements
-	if req.GPUs > 0 {
+	if req.Architecture == burstTypes.ArchitectureARM64 {
+		// Graviton workloads
+		if req.RequiresEFA {
+			instanceTypes = append(instanceTypes, "hpc7g.16xlarge", "c7gn.16xlarge")
+		} else {
+			instanceTypes = append(instanceTypes, "c7g.large", "c7g.xlarge", "m7g.large", "m7g.xlarge")
+		}
+	} else if req.GPUs > 0 {
 		// GPU workloads
 		instanceTypes = append(instanceTypes, "p3.2xlarge", "g4dn.xlarge")
 	} else if req.RequiresEFA {
@@ -334,8 +676,67 @@ func (f *FleetManager) selectInstanceTypes(req *burstTypes.InstanceRequirements)
 	return instanceTypes
 }
 
+// resolveRequestedInstanceTypes expands bare families into sizes and replaces
+// specific instance types that are too small for the job with larger sizes of
+// the same family. Types the catalog does not know are passed through as-is.
+func (f *FleetManager) resolveRequestedInstanceTypes(req *burstTypes.InstanceRequirements) []string {
+	var instanceTypes []string
+	seen := make(map[string]bool)
+	add := func(candidates ...string) {
+		for _, candidate := range candidates {
+			if !seen[candidate] {
+				seen[candidate] = true
+				instanceTypes = append(instanceTypes, candidate)
+			}
+		}
+	}
+
+	for _, requested := range req.InstanceFamilies {
+		if !strings.Contains(requested, ".") {
+			add(f.getInstanceSizesForFamily(requested, req)...)
+			continue
+		}
+
+		if f.catalog == nil {
+			add(requested)
+			continue
+		}
+
+		info, known := f.catalog.Lookup(requested)
+		if !known || fitsRequirements(info, req) {
+			add(requested)
+			continue
+		}
+
+		resized := f.catalog.SizesForFamily(info.Family, req)
+		f.logger.Info("Instance type too small for job, resizing within family",
+			zap.String("instance_type", requested),
+			zap.Int("min_cpus", req.MinCPUs),
+			zap.Int("min_memory_mb", req.MinMemoryMB),
+			zap.Strings("replacements", resized))
+		add(resized...)
+	}
+
+	if len(instanceTypes) == 0 {
+		f.logger.Warn("No requested instance type fits job requirements, using requested types as-is",
+			zap.Strings("requested", req.InstanceFamilies))
+		return req.InstanceFamilies
+	}
+
+	return instanceTypes
+}
+
 // getInstanceSizesForFamily returns appropriate instance sizes for a family
 func (f *FleetManager) getInstanceSizesForFamily(family string, req *burstTypes.InstanceRequirements) []string {
+	// Prefer real instance data from the catalog
+	if f.catalog != nil && f.catalog.Len() > 0 {
+		if sizes := f.catalog.SizesForFamily(family, req); len(sizes) > 0 {
+			return sizes
+		}
+		f.logger.Debug("No catalog instance types fit requirements, using size heuristics",
+			zap.String("family", family))
+	}
+
 	// Determine minimum instance size based on requirements
 	var sizes []string
 
@@ -343,7 +744,7 @@ func (f *FleetManager) getInstanceSizesForFamily(family string, req *burstTypes.
 	memoryGB := req.MinMemoryMB / 1024
 	cpus := req.MinCPUs
 
-	// Instance size mapping (simplified - in production would use AWS API)
+	// Instance size mapping (fallback when the catalog is unavailable)
 	switch {
 	case memoryGB <= 8 && cpus <= 2:
 		sizes = []string{family + ".large"}
@@ -401,28 +802,33 @@ func (f *FleetManager) processFleetResult(ctx context.Context, result *ec2.Creat
 	}
 
 	// Check for errors
-	if len(result.Errors) > 0 {
-		for _, fleetError := range result.Errors {
-			response.Errors = append(response.Errors, aws.ToString(fleetError.ErrorMessage))
-		}
+	var errorCodes []string
+	for _, fleetError := range result.Errors {
+		response.Errors = append(response.Errors, aws.ToString(fleetError.ErrorMessage))
+		errorCodes = append(errorCodes, aws.ToString(fleetError.ErrorCode))
 	}
+	response.ErrorCodes = errorCodes
 
 	// Process launched instances
 	if len(result.Instances) == 0 {
-		return response, fmt.Errorf("no instances were launched")
+		return response, &FleetLaunchError{
+			Requested: len(nodeIds),
+			Codes:     errorCodes,
+			Messages:  response.Errors,
+		}
 	}
 
-	// Get detailed instance information
+	// Get detailed instance information; each entry groups instances by pool
 	var instanceIds []string
 	for _, instance := range result.Instances {
-		if len(instance.InstanceIds) > 0 {
-			instanceIds = append(instanceIds, instance.InstanceIds[0])
-		}
+		instanceIds = append(instanceIds, instance.InstanceIds...)
 	}
 
 	// Wait for instances to be running and get their details
 	instanceInfos, err := f.waitForInstancesRunning(ctx, instanceIds, nodeIds)
 	if err != nil {
+		// Keep the IDs so callers can terminate what was launched
+		response.Instances = instancesWithIds(instanceIds)
 		return response, fmt.Errorf("failed to get instance details: %w", err)
 	}
 
@@ -437,8 +843,12 @@ func (f *FleetManager) processFleetResult(ctx context.Context, result *ec2.Creat
 	return response, nil
 }
 
-// waitForInstancesRunning waits for instances to reach running state and retrieves their information
-func (f *FleetManager) waitForInstancesRunning(ctx context.Context, instanceIds, nodeIds []string) ([]burstTypes.InstanceInfo, error) {
+// waitRunning waits for instances to reach the running state and describes them
+func (f *FleetManager) waitRunning(ctx context.Context, instanceIds []string) ([]types.Instance, error) {
+	if err := f.faults.WaiterDelay(ctx); err != nil {
+		return nil, fmt.Errorf("instances failed to reach running state: %w", err)
+	}
+
 	// Wait for instances to be running (with timeout)
 	waiter := ec2.NewInstanceRunningWaiter(f.ec2Client)
 	waitInput := &ec2.DescribeInstancesInput{
@@ -455,38 +865,56 @@ func (f *FleetManager) waitForInstancesRunning(ctx context.Context, instanceIds,
 		return nil, fmt.Errorf("failed to describe instances: %w", err)
 	}
 
-	var instances []burstTypes.InstanceInfo
-	instanceIndex := 0
-
+	var described []types.Instance
 	for _, reservation := range result.Reservations {
-		for _, instance := range reservation.Instances {
-			if instanceIndex >= len(nodeIds) {
-				break
-			}
+		described = append(described, reservation.Instances...)
+	}
+	return described, nil
+}
 
-			// Map instance to node name
-			nodeName := nodeIds[instanceIndex]
-			instanceInfo := burstTypes.InstanceInfo{
-				NodeName:   nodeName,
-				InstanceID: aws.ToString(instance.InstanceId),
-				PrivateIP:  aws.ToString(instance.PrivateIpAddress),
-				State:      string(instance.State.Name),
-				LaunchTime: instance.LaunchTime.Format(time.RFC3339),
-			}
+// instancesWithIds wraps bare instance IDs, e.g. so a failed launch can still be cleaned up
+func instancesWithIds(instanceIds []string) []burstTypes.InstanceInfo {
+	instances := make([]burstTypes.InstanceInfo, 0, len(instanceIds))
+	for _, instanceID := range instanceIds {
+		instances = append(instances, burstTypes.InstanceInfo{InstanceID: instanceID})
+	}
+	return instances
+}
 
-			if instance.PublicIpAddress != nil {
-				instanceInfo.PublicIP = aws.ToString(instance.PublicIpAddress)
-			}
+// waitForInstancesRunning waits for instances to reach running state and retrieves their information
+func (f *FleetManager) waitForInstancesRunning(ctx context.Context, instanceIds, nodeIds []string) ([]burstTypes.InstanceInfo, error) {
+	described, err := f.waitRunning(ctx, instanceIds)
+	if err != nil {
+		return nil, err
+	}
 
-			instances = append(instances, instanceInfo)
-			instanceIndex++
+	// On-demand anchors take the first node names, so rank 0 of an MPI job
+	// lands on an instance that cannot be reclaimed
+	sort.SliceStable(described, func(i, j int) bool {
+		return described[i].InstanceLifecycle != types.InstanceLifecycleTypeSpot &&
+			described[j].InstanceLifecycle == types.InstanceLifecycleTypeSpot
+	})
 
-			f.logger.Debug("Instance mapped to node",
-				zap.String("node_name", nodeName),
-				zap.String("instance_id", instanceInfo.InstanceID),
-				zap.String("private_ip", instanceInfo.PrivateIP),
-				zap.String("state", instanceInfo.State))
+	var instances []burstTypes.InstanceInfo
+	instanceIndex := 0
+
+	for _, instance := range described {
+		if instanceIndex >= len(nodeIds) {
+			break
 		}
+
+		// Map instance to node name
+		nodeName := nodeIds[instanceIndex]
+		instanceInfo := newInstanceInfo(instance, nodeName)
+
+		instances = append(instances, instanceInfo)
+		instanceIndex++
+
+		f.logger.Debug("Instance mapped to node",
+			zap.String("node_name", nodeName),
+			zap.String("instance_id", instanceInfo.InstanceID),
+			zap.String("private_ip", instanceInfo.PrivateIP),
+			zap.String("state", instanceInfo.State))
 	}
 
 	// Tag instances with node names for identification
@@ -498,6 +926,33 @@ func (f *FleetManager) waitForInstancesRunning(ctx context.Context, instanceIds,
 	return instances, nil
 }
 
+// newInstanceInfo describes an EC2 instance serving a Slurm node
+func newInstanceInfo(instance types.Instance, nodeName string) burstTypes.InstanceInfo {
+	purchaseOption := "on-demand"
+	if instance.InstanceLifecycle == types.InstanceLifecycleTypeSpot {
+		purchaseOption = "spot"
+	}
+
+	instanceInfo := burstTypes.InstanceInfo{
+		NodeName:       nodeName,
+		InstanceID:     aws.ToString(instance.InstanceId),
+		InstanceType:   string(instance.InstanceType),
+		PrivateIP:      aws.ToString(instance.PrivateIpAddress),
+		State:          string(instance.State.Name),
+		LaunchTime:     instance.LaunchTime.Format(time.RFC3339),
+		PurchaseOption: purchaseOption,
+	}
+
+	if instance.PublicIpAddress != nil {
+		instanceInfo.PublicIP = aws.ToString(instance.PublicIpAddress)
+	}
+	if instance.Placement != nil {
+		instanceInfo.AvailabilityZone = aws.ToString(instance.Placement.AvailabilityZone)
+	}
+
+	return instanceInfo
+}
+
 // tagInstancesWithNodeNames tags EC2 instances with their corresponding Slurm node names
 func (f *FleetManager) tagInstancesWithNodeNames(ctx context.Context, instances []burstTypes.InstanceInfo) error {
 	for _, instance := range instances {
@@ -548,13 +1003,8 @@ func (f *FleetManager) TerminateInstances(ctx context.Context, nodeNames []strin
 		return nil
 	}
 
-	// Terminate instances
-	_, err = f.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
-		InstanceIds: instanceIds,
-	})
-
-	if err != nil {
-		return fmt.Errorf("failed to terminate instances: %w", err)
+	if err := f.terminateInstanceIDs(ctx, instanceIds); err != nil {
+		return err
 	}
 
 	f.logger.Info("Instances termination initiated",
@@ -564,38 +1014,100 @@ func (f *FleetManager) TerminateInstances(ctx context.Context, nodeNames []strin
 	return nil
 }
 
+// terminateInstanceIDs terminates instances in batches within the API limit
+// and a minute's suspend budget
+func (f *FleetManager) terminateInstanceIDs(ctx context.Context, instanceIds []string) error {
+	_, err := f.terminateBatches(ctx, instanceIds, f.terminateBatchSize(), f.terminateLimiter)
+	return err
+}
+
+// terminateUnpaced terminates instances in batches of the API limit without
+// waiting for the suspend budget, which an emergency stop must not queue
+// behind, and returns the instances terminated before any failure
+func (f *FleetManager) terminateUnpaced(ctx context.Context, instanceIds []string) ([]string, error) {
+	return f.terminateBatches(ctx, instanceIds, maxInstanceIdsPerCall, nil)
+}
+
+// terminateBatches terminates instances in batches of size, each paced by the
+// limiter unless it is nil and retried when EC2 throttles, and returns the
+// instances of the batches that succeeded
+func (f *FleetManager) terminateBatches(ctx context.Context, instanceIds []string, size int, limiter *ratelimit.Limiter) ([]string, error) {
+	var terminated []string
+	for _, batch := range ratelimit.Chunks(instanceIds, size) {
+		if err := limiter.Wait(ctx, len(batch)); err != nil {
+			return terminated, err
+		}
+		err := retryThrottled(ctx, func() error {
+			_, err := f.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{
+				InstanceIds: batch,
+			})
+			return err
+		})
+		if err != nil {
+			return terminated, fmt.Errorf("failed to terminate instances: %w", err)
+		}
+		terminated = append(terminated, batch...)
+	}
+	return terminated, nil
+}
+
 // findInstancesByNodeNames finds EC2 instance IDs by their Slurm node name tags
 func (f *FleetManager) findInstancesByNodeNames(ctx context.Context, nodeNames []string) ([]string, error) {
-	// Build filter for instance names
-	filters := []types.Filter{
-		{
-			Name:   aws.String("tag:Name"),
-			Values: nodeNames,
-		},
+	instances, err := f.describeByNodeNames(ctx, nodeNames, []types.Filter{
 		{
 			Name:   aws.String("instance-state-name"),
 			Values: []string{"pending", "running", "shutting-down", "stopping", "stopped"},
 		},
-	}
-
-	result, err := f.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-		Filters: filters,
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe instances: %w", err)
+		return nil, err
 	}
 
 	var instanceIds []string
-	for _, reservation := range result.Reservations {
-		for _, instance := range reservation.Instances {
-			instanceIds = append(instanceIds, aws.ToString(instance.InstanceId))
-		}
+	for _, instance := range instances {
+		instanceIds = append(instanceIds, aws.ToString(instance.InstanceId))
 	}
 
 	return instanceIds, nil
 }
 
+// describeByNodeNames returns the instances tagged with the node names that
+// match filters. Large node lists are split to fit the API's filter limit and
+// every page is read, retrying when EC2 throttles the calls.
+func (f *FleetManager) describeByNodeNames(ctx context.Context, nodeNames []string, filters []types.Filter) ([]types.Instance, error) {
+	var instances []types.Instance
+	for _, names := range ratelimit.Chunks(nodeNames, maxFilterValues) {
+		input := &ec2.DescribeInstancesInput{
+			Filters: append([]types.Filter{{Name: aws.String("tag:Name"), Values: names}}, filters...),
+		}
+		paginator := ec2.NewDescribeInstancesPaginator(f.ec2Client, input)
+		for paginator.HasMorePages() {
+			var page *ec2.DescribeInstancesOutput
+			err := retryThrottled(ctx, func() error {
+				var err error
+				page, err = paginator.NextPage(ctx)
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe instances: %w", err)
+			}
+			for _, reservation := range page.Reservations {
+				instances = append(instances, reservation.Instances...)
+			}
+		}
+	}
+	return instances, nil
+}
+
+// terminateBatchSize is the most instances one TerminateInstances call takes:
+// the API limit, or a minute's suspend budget when that is smaller
+func (f *FleetManager) terminateBatchSize() int {
+	if burst := f.terminateLimiter.Burst(); burst > 0 && burst < maxInstanceIdsPerCall {
+		return burst
+	}
+	return maxInstanceIdsPerCall
+}
+
 // GetInstancePricing retrieves current pricing for instance types
 func (f *FleetManager) GetInstancePricing(ctx context.Context, instanceTypes []string) (map[string]float64, error) {
 	// Note: Real AWS Pricing API integration planned for Phase 3
@@ -619,6 +1131,176 @@ func (f *FleetManager) GetInstancePricing(ctx context.Context, instanceTypes []s
 	return pricing, nil
 }
 
+// recordLaunchPrices sets the hourly prices instances launched at: the
+// current spot price in its zone for each spot instance, and the on-demand
+// price of every instance's type. Prices are for cost reporting, so a failed
+// lookup leaves them unset rather than failing the launch.
+func (f *FleetManager) recordLaunchPrices(ctx context.Context, instances []burstTypes.InstanceInfo) {
+	var instanceTypes, spotTypes, spotZones []string
+	for _, instance := range instances {
+		if instance.InstanceType == "" {
+			continue
+		}
+		instanceTypes = append(instanceTypes, instance.InstanceType)
+		if instance.PurchaseOption == "spot" && instance.AvailabilityZone != "" {
+			spotTypes = append(spotTypes, instance.InstanceType)
+			spotZones = append(spotZones, instance.AvailabilityZone)
+		}
+	}
+	if len(instanceTypes) == 0 {
+		return
+	}
+
+	onDemand, err := f.GetInstancePricing(ctx, dedupe(instanceTypes))
+	if err != nil {
+		f.logger.Warn("Failed to get on-demand prices", zap.Error(err))
+	}
+	var spot map[string]float64
+	if len(spotTypes) > 0 {
+		if spot, err = f.spotPricesByTypeAndZone(ctx, dedupe(spotTypes), dedupe(spotZones)); err != nil {
+			f.logger.Warn("Failed to get spot prices", zap.Error(err))
+		}
+	}
+
+	for i := range instances {
+		instances[i].OnDemandPriceUSD = onDemand[instances[i].InstanceType]
+		if instances[i].PurchaseOption == "spot" {
+			instances[i].SpotPriceUSD = spot[spotPriceKey(instances[i].InstanceType, instances[i].AvailabilityZone)]
+		}
+	}
+}
+
+// recordLaunchCapacity sets the vCPUs and memory of instances' types from the
+// instance catalog. Types the catalog does not know are left unset.
+func (f *FleetManager) recordLaunchCapacity(instances []burstTypes.InstanceInfo) {
+	if f.catalog == nil {
+		return
+	}
+	for i := range instances {
+		if info, ok := f.catalog.Lookup(instances[i].InstanceType); ok {
+			instances[i].VCPUs = info.VCPUs
+			instances[i].MemoryMiB = info.MemoryMiB
+		}
+	}
+}
+
+// recordLaunchVolumes sets the EBS volumes instances launched with: the node
+// group's configured volumes, or else its launch template's block device
+// mappings, from templateData when the launch described the template.
+// Mappings whose size comes from the AMI are left out.
+func (f *FleetManager) recordLaunchVolumes(ctx context.Context, nodeGroup *burstConfig.NodeGroupConfig, templateData *types.ResponseLaunchTemplateData, instances []burstTypes.InstanceInfo) {
+	if len(instances) == 0 {
+		return
+	}
+
+	var volumes []burstTypes.EBSVolume
+	for _, volume := range nodeGroup.Volumes {
+		volumes = append(volumes, burstTypes.EBSVolume{
+			DeviceName:     volume.DeviceName,
+			VolumeType:     volume.VolumeType,
+			SizeGB:         volume.SizeGB,
+			IOPS:           volume.IOPS,
+			ThroughputMBps: volume.ThroughputMBps,
+		})
+	}
+	if len(volumes) == 0 && templateData != nil {
+		volumes = templateVolumes(templateData)
+	} else if len(volumes) == 0 {
+		var err error
+		if volumes, err = f.launchTemplateVolumes(ctx, nodeGroup.LaunchTemplateSpec); err != nil {
+			f.logger.Warn("Failed to read launch template volumes", zap.Error(err))
+			return
+		}
+	}
+
+	for i := range instances {
+		instances[i].Volumes = volumes
+	}
+}
+
+// launchTemplateVolumes returns the EBS volumes in a launch template's block
+// device mappings
+func (f *FleetManager) launchTemplateVolumes(ctx context.Context, spec burstConfig.LaunchTemplateSpec) ([]burstTypes.EBSVolume, error) {
+	version := spec.Version
+	if version == "" {
+		version = "$Default"
+	}
+	input := &ec2.DescribeLaunchTemplateVersionsInput{Versions: []string{version}}
+	if spec.LaunchTemplateID != "" {
+		input.LaunchTemplateId = aws.String(spec.LaunchTemplateID)
+	} else {
+		input.LaunchTemplateName = aws.String(spec.LaunchTemplateName)
+	}
+
+	described, err := f.ec2Client.DescribeLaunchTemplateVersions(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe launch template: %w", err)
+	}
+	if len(described.LaunchTemplateVersions) == 0 || described.LaunchTemplateVersions[0].LaunchTemplateData == nil {
+		return nil, nil
+	}
+	return templateVolumes(described.LaunchTemplateVersions[0].LaunchTemplateData), nil
+}
+
+// templateVolumes returns the EBS volumes in launch template data's block
+// device mappings
+func templateVolumes(data *types.ResponseLaunchTemplateData) []burstTypes.EBSVolume {
+	var volumes []burstTypes.EBSVolume
+	for _, mapping := range data.BlockDeviceMappings {
+		if mapping.Ebs == nil || mapping.Ebs.VolumeSize == nil {
+			continue
+		}
+		volumeType := string(mapping.Ebs.VolumeType)
+		if volumeType == "" {
+			volumeType = string(types.VolumeTypeGp2) // EC2's default for volumes that name no type
+		}
+		volumes = append(volumes, burstTypes.EBSVolume{
+			DeviceName:     aws.ToString(mapping.DeviceName),
+			VolumeType:     volumeType,
+			SizeGB:         int(aws.ToInt32(mapping.Ebs.VolumeSize)),
+			IOPS:           int(aws.ToInt32(mapping.Ebs.Iops)),
+			ThroughputMBps: int(aws.ToInt32(mapping.Ebs.Throughput)),
+		})
+	}
+	return volumes
+}
+
+// spotPricesByTypeAndZone returns the current spot price of each instance type
+// in each zone, keyed by spotPriceKey
+func (f *FleetManager) spotPricesByTypeAndZone(ctx context.Context, instanceTypes, zones []string) (map[string]float64, error) {
+	typeEnums := make([]types.InstanceType, 0, len(instanceTypes))
+	for _, instanceType := range instanceTypes {
+		typeEnums = append(typeEnums, types.InstanceType(instanceType))
+	}
+
+	// A start time of now returns only the current price for each type and zone
+	result, err := f.ec2Client.DescribeSpotPriceHistory(ctx, &ec2.DescribeSpotPriceHistoryInput{
+		InstanceTypes:       typeEnums,
+		ProductDescriptions: []string{"Linux/UNIX"},
+		StartTime:           aws.Time(time.Now()),
+		Filters: []types.Filter{
+			{Name: aws.String("availability-zone"), Values: zones},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spot prices: %w", err)
+	}
+
+	prices := make(map[string]float64)
+	for _, entry := range result.SpotPriceHistory {
+		price, err := strconv.ParseFloat(aws.ToString(entry.SpotPrice), 64)
+		if err != nil || price <= 0 {
+			continue
+		}
+		prices[spotPriceKey(string(entry.InstanceType), aws.ToString(entry.AvailabilityZone))] = price
+	}
+	return prices, nil
+}
+
+func spotPriceKey(instanceType, zone string) string {
+	return instanceType + "/" + zone
+}
+
 // validateFleetRequest validates that a fleet request has all required fields
 func (f *FleetManager) validateFleetRequest(req *FleetRequest) error {
 	if len(req.NodeIds) == 0 {
@@ -683,23 +1365,139 @@ func (f *FleetManager) enhanceLaunchTemplateForEFA(overrides []types.FleetLaunch
 	return efaOverrides
 }
 
-// supportsEFA checks if an instance type supports EFA
-func (f *FleetManager) supportsEFA(instanceType string) bool {
-	// List of EFA-capable instance families
-	efaFamilies := []string{
-		"c5n", "c6i", "c6in", "c7i",
-		"hpc6a", "hpc6id", "hpc7a", "hpc7g",
-		"m5n", "m5dn", "m6i", "m6in", "m7i",
-		"r5n", "r5dn", "r6i", "r6in", "r7i",
-		"p3dn", "p4d", "p5",
-		"trn1", "trn1n",
+// filterOverridesByArchitecture removes overrides whose instance type does not match the architecture
+func (f *FleetManager) filterOverridesByArchitecture(overrides []types.FleetLaunchTemplateOverridesRequest, architecture string) []types.FleetLaunchTemplateOverridesRequest {
+	var filtered []types.FleetLaunchTemplateOverridesRequest
+	for _, override := range overrides {
+		instanceType := string(override.InstanceType)
+		if f.instanceArchitectureMatches(instanceType, architecture) {
+			filtered = append(filtered, override)
+		} else {
+			f.logger.Warn("Filtering out instance type with mismatched architecture",
+				zap.String("instance_type", instanceType),
+				zap.String("architecture", architecture))
+		}
 	}
+	return filtered
+}
 
-	for _, family := range efaFamilies {
-		if strings.HasPrefix(instanceType, family+".") {
-			return true
+// instanceArchitectureMatches checks an instance type against the requested architecture
+func (f *FleetManager) instanceArchitectureMatches(instanceType, architecture string) bool {
+	if f.catalog != nil {
+		if info, ok := f.catalog.Lookup(instanceType); ok && len(info.Architectures) > 0 {
+			for _, arch := range info.Architectures {
+				if arch == architecture {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return burstTypes.ArchitectureOf(instanceType) == architecture
+}
+
+// validateLaunchTemplateImage verifies the launch template AMI runs Linux on
+// the requested architecture, and returns it when it could be described
+func (f *FleetManager) validateLaunchTemplateImage(ctx context.Context, req *FleetRequest) (*types.Image, error) {
+	if req.InstanceRequirements.Architecture == "" && (req.NodeBootstrap == nil || req.NodeBootstrap.Distro != "") {
+		return nil, nil
+	}
+
+	versionInput := &ec2.DescribeLaunchTemplateVersionsInput{
+		ResolveAlias: aws.Bool(true),
+	}
+	if req.LaunchTemplate.ID != "" {
+		versionInput.LaunchTemplateId = aws.String(req.LaunchTemplate.ID)
+	} else {
+		versionInput.LaunchTemplateName = aws.String(req.LaunchTemplate.Name)
+	}
+	if req.LaunchTemplate.Version != "" {
+		versionInput.Versions = []string{req.LaunchTemplate.Version}
+	}
+
+	versions, err := f.ec2Client.DescribeLaunchTemplateVersions(ctx, versionInput)
+	if err != nil {
+		f.logger.Warn("Unable to inspect launch template AMI", zap.Error(err))
+		return nil, nil
+	}
+
+	if len(versions.LaunchTemplateVersions) == 0 || versions.LaunchTemplateVersions[0].LaunchTemplateData == nil {
+		if req.LaunchTemplate.Version != "" {
+			req.templateData = &types.ResponseLaunchTemplateData{}
+		}
+		return nil, nil
+	}
+	if req.LaunchTemplate.Version != "" {
+		req.templateData = versions.LaunchTemplateVersions[0].LaunchTemplateData
+	}
+
+	imageID := aws.ToString(versions.LaunchTemplateVersions[0].LaunchTemplateData.ImageId)
+	if imageID == "" {
+		return nil, nil
+	}
+
+	images, err := f.ec2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{ImageIds: []string{imageID}})
+	if err != nil || len(images.Images) == 0 {
+		f.logger.Warn("Unable to describe launch template AMI", zap.String("image_id", imageID), zap.Error(err))
+		return nil, nil
+	}
+	image := &images.Images[0]
+
+	// Slurm nodes, and every script aws-slurm-burst adds to user data, need Linux
+	if image.Platform == types.PlatformValuesWindows {
+		return nil, fmt.Errorf("launch template AMI %s runs Windows; burst nodes must run Linux", imageID)
+	}
+
+	if req.InstanceRequirements.Architecture != "" {
+		amiArchitecture := normalizeArchitecture(string(image.Architecture))
+		if amiArchitecture != req.InstanceRequirements.Architecture {
+			return nil, fmt.Errorf("launch template AMI %s is %s but node group requires %s",
+				imageID, amiArchitecture, req.InstanceRequirements.Architecture)
+		}
+	}
+
+	return image, nil
+}
+
+// nodeBootstrapParts returns the user-data parts that install and start slurmd,
+// for the configured distribution or the one the AMI's name reveals
+func (f *FleetManager) nodeBootstrapParts(req *FleetRequest, image *types.Image) ([]userdata.Part, error) {
+	bootstrap := *req.NodeBootstrap
+	if bootstrap.Distro == "" {
+		if image == nil {
+			return nil, fmt.Errorf("the launch template AMI could not be described to detect its distribution; set node_bootstrap.distro")
+		}
+		distro, err := userdata.DetectDistro(aws.ToString(image.Name), aws.ToString(image.Description))
+		if err != nil {
+			return nil, fmt.Errorf("%w; set node_bootstrap.distro", err)
+		}
+		bootstrap.Distro = distro
+		f.logger.Info("Detected launch template AMI distribution",
+			zap.String("image_id", aws.ToString(image.ImageId)),
+			zap.String("distro", string(distro)))
+	}
+	bootstrap.EFA = req.InstanceRequirements.RequiresEFA
+	return userdata.NodeBootstrapParts(bootstrap)
+}
+
+// normalizeArchitecture maps EC2 image architecture names to instance architecture names
+func normalizeArchitecture(architecture string) string {
+	switch architecture {
+	case "arm64", "arm64_mac", "aarch64":
+		return burstTypes.ArchitectureARM64
+	default:
+		return burstTypes.ArchitectureX86_64
+	}
+}
+
+// supportsEFA checks if an instance type supports EFA
+func (f *FleetManager) supportsEFA(instanceType string) bool {
+	if f.catalog != nil {
+		if info, ok := f.catalog.Lookup(instanceType); ok {
+			return info.SupportsEFA
 		}
 	}
 
-	return false
+	// Fall back to the known EFA-capable instance families
+	return strings.Contains(instanceType, ".") && burstTypes.KnownEFAFamily(burstTypes.InstanceFamilyOf(instanceType))
 }
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
 	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"github.com/stretchr/testify/assert"
@@ -257,6 +259,54 @@ func TestFleetManager_GetInstancePricing(t *testing.T) {
 	assert.Less(t, pricing["c5.xlarge"], pricing["m5.2xlarge"], "xlarge should cost less than 2xlarge")
 }
 
+func TestFleetManager_recordLaunchPrices(t *testing.T) {
+	fleetManager := NewFleetManagerWithAPI(zaptest.NewLogger(t), &config.AWSConfig{Region: "us-east-1"}, &fakeEC2{})
+
+	instances := []types.InstanceInfo{
+		{InstanceID: "i-1", InstanceType: "c6i.large", AvailabilityZone: "us-east-1a", PurchaseOption: "on-demand"},
+		{InstanceID: "i-2", InstanceType: "c6i.xlarge", AvailabilityZone: "us-east-1b", PurchaseOption: "spot"},
+	}
+	fleetManager.recordLaunchPrices(context.Background(), instances)
+
+	assert.Equal(t, 0.096, instances[0].OnDemandPriceUSD)
+	assert.Zero(t, instances[0].SpotPriceUSD, "on-demand instances pay no spot price")
+	assert.Equal(t, 0.192, instances[1].OnDemandPriceUSD, "the on-demand equivalent of a spot instance")
+	assert.Equal(t, 0.04, instances[1].SpotPriceUSD)
+}
+
+func TestFleetManager_recordLaunchVolumes(t *testing.T) {
+	ec2Client := &fakeEC2{templateData: &ec2types.ResponseLaunchTemplateData{
+		BlockDeviceMappings: []ec2types.LaunchTemplateBlockDeviceMapping{
+			{DeviceName: aws.String("/dev/xvda")}, // Sized by the AMI
+			{DeviceName: aws.String("/dev/sdb"), Ebs: &ec2types.LaunchTemplateEbsBlockDevice{
+				VolumeType: ec2types.VolumeTypeGp3, VolumeSize: aws.Int32(500), Iops: aws.Int32(6000), Throughput: aws.Int32(250),
+			}},
+		},
+	}}
+	fleetManager := NewFleetManagerWithAPI(zaptest.NewLogger(t), &config.AWSConfig{Region: "us-east-1"}, ec2Client)
+
+	instances := []types.InstanceInfo{{InstanceID: "i-1"}, {InstanceID: "i-2"}}
+	fleetManager.recordLaunchVolumes(context.Background(), &config.NodeGroupConfig{}, nil, instances)
+	for _, instance := range instances {
+		assert.Equal(t, []types.EBSVolume{{DeviceName: "/dev/sdb", VolumeType: "gp3", SizeGB: 500, IOPS: 6000, ThroughputMBps: 250}}, instance.Volumes)
+	}
+
+	// Configured volumes take precedence over the template
+	fleetManager.recordLaunchVolumes(context.Background(), &config.NodeGroupConfig{
+		Volumes: []config.VolumeConfig{{DeviceName: "/dev/xvda", VolumeType: "gp3", SizeGB: 100}},
+	}, nil, instances)
+	assert.Equal(t, []types.EBSVolume{{DeviceName: "/dev/xvda", VolumeType: "gp3", SizeGB: 100}}, instances[0].Volumes)
+
+	// The template as described at launch is not described again
+	ec2Client.templateData = nil
+	fleetManager.recordLaunchVolumes(context.Background(), &config.NodeGroupConfig{}, &ec2types.ResponseLaunchTemplateData{
+		BlockDeviceMappings: []ec2types.LaunchTemplateBlockDeviceMapping{
+			{DeviceName: aws.String("/dev/sdc"), Ebs: &ec2types.LaunchTemplateEbsBlockDevice{VolumeSize: aws.Int32(50)}},
+		},
+	}, instances)
+	assert.Equal(t, []types.EBSVolume{{DeviceName: "/dev/sdc", VolumeType: "gp2", SizeGB: 50}}, instances[0].Volumes)
+}
+
 // Benchmark test for instance type selection performance
 func BenchmarkInstanceTypeSelection(b *testing.B) {
 	logger := zaptest.NewLogger(b)
@@ -281,3 +331,57 @@ func BenchmarkInstanceTypeSelection(b *testing.B) {
 		}
 	}
 }
+
+func TestFleetManager_resolveRequestedInstanceTypes(t *testing.T) {
+	fleetManager := &FleetManager{
+		logger:  zaptest.NewLogger(t),
+		catalog: newTestCatalog(t, ""),
+	}
+
+	tests := []struct {
+		name         string
+		requirements *types.InstanceRequirements
+		expected     []string
+	}{
+		{
+			name: "fitting type kept as-is",
+			requirements: &types.InstanceRequirements{
+				MinCPUs:          2,
+				MinMemoryMB:      4096,
+				InstanceFamilies: []string{"c6i.large"},
+			},
+			expected: []string{"c6i.large"},
+		},
+		{
+			name: "too small type resized within family",
+			requirements: &types.InstanceRequirements{
+				MinCPUs:          4,
+				MinMemoryMB:      12288, // 4 CPUs x 3GB --mem-per-cpu
+				InstanceFamilies: []string{"c6i.large"},
+			},
+			expected: []string{"c6i.2xlarge", "c6i.32xlarge"},
+		},
+		{
+			name: "bare family expanded",
+			requirements: &types.InstanceRequirements{
+				MinCPUs:          4,
+				InstanceFamilies: []string{"c6i"},
+			},
+			expected: []string{"c6i.xlarge", "c6i.2xlarge"},
+		},
+		{
+			name: "unknown type passed through",
+			requirements: &types.InstanceRequirements{
+				MinCPUs:          64,
+				InstanceFamilies: []string{"x2iedn.32xlarge"},
+			},
+			expected: []string{"x2iedn.32xlarge"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, fleetManager.selectInstanceTypes(tt.requirements))
+		})
+	}
+}
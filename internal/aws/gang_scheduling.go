@@ -5,21 +5,19 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"go.uber.org/zap"
 )
 
 // GangScheduler handles atomic provisioning for MPI jobs
 type GangScheduler struct {
 	logger       *zap.Logger
-	ec2Client    *ec2.Client
+	ec2Client    EC2API
 	fleetManager *FleetManager
 }
 
 // NewGangScheduler creates a new gang scheduler
-func NewGangScheduler(logger *zap.Logger, ec2Client *ec2.Client, fleetManager *FleetManager) *GangScheduler {
+func NewGangScheduler(logger *zap.Logger, ec2Client EC2API, fleetManager *FleetManager) *GangScheduler {
 	return &GangScheduler{
 		logger:       logger,
 		ec2Client:    ec2Client,
@@ -27,7 +25,10 @@ func NewGangScheduler(logger *zap.Logger, ec2Client *ec2.Client, fleetManager *F
 	}
 }
 
-// AtomicProvision ensures all instances launch successfully or none do (gang scheduling)
+// AtomicProvision ensures all instances launch successfully or none do (gang scheduling).
+// A cluster placement group lives in a single AZ, so each attempt is pinned to the
+// subnets of one AZ; if an attempt falls short it is rolled back and the next AZ,
+// in order of capacity and spot price, is tried.
 func (g *GangScheduler) AtomicProvision(ctx context.Context, req *FleetRequest) (*FleetResponse, error) {
 	if !req.Job.IsMPIJob || !req.InstanceRequirements.RequiresEFA {
 		// Non-MPI jobs don't need gang scheduling
@@ -39,83 +40,68 @@ func (g *GangScheduler) AtomicProvision(ctx context.Context, req *FleetRequest)
 		zap.Int("required_nodes", len(req.NodeIds)),
 		zap.Bool("requires_efa", req.InstanceRequirements.RequiresEFA))
 
-	// Pre-flight capacity check
-	if err := g.checkCapacityAvailability(ctx, req); err != nil {
+	// Pre-flight capacity check: only AZs offering the instance types are candidates
+	instanceTypes := g.fleetManager.selectInstanceTypes(req.InstanceRequirements)
+	zones, err := g.fleetManager.rankAvailabilityZones(ctx, req, instanceTypes)
+	if err != nil {
 		return nil, fmt.Errorf("pre-flight capacity check failed: %w", err)
 	}
 
-	// Attempt atomic launch
-	response, err := g.attemptAtomicLaunch(ctx, req)
-	if err != nil {
-		// If atomic launch fails, cleanup any partial instances
-		g.cleanupPartialLaunch(ctx, response)
-		return nil, fmt.Errorf("gang scheduling failed: %w", err)
-	}
+	var failures zoneErrors
+	for _, zone := range zones {
+		pinned := *req
+		pinned.SubnetIds = zone.SubnetIds
+
+		response, err := g.attemptZone(ctx, &pinned, zone)
+		if err != nil {
+			g.logger.Warn("Gang launch failed in availability zone, rolling back",
+				zap.String("job_id", req.Job.JobID),
+				zap.String("availability_zone", zone.Zone),
+				zap.Error(err))
+			g.cleanupPartialLaunch(ctx, response)
+			failures = append(failures, fmt.Errorf("%s: %w", zone.Zone, err))
+			continue
+		}
 
-	// Verify all instances are running before returning success
-	if err := g.verifyAllInstancesRunning(ctx, response); err != nil {
-		// Cleanup on verification failure
-		g.cleanupPartialLaunch(ctx, response)
-		return nil, fmt.Errorf("instance verification failed: %w", err)
-	}
+		g.logger.Info("Gang scheduling completed successfully",
+			zap.String("job_id", req.Job.JobID),
+			zap.String("availability_zone", zone.Zone),
+			zap.Int("launched_instances", len(response.Instances)),
+			zap.String("fleet_id", response.FleetId))
 
-	g.logger.Info("Gang scheduling completed successfully",
-		zap.String("job_id", req.Job.JobID),
-		zap.Int("launched_instances", len(response.Instances)),
-		zap.String("fleet_id", response.FleetId))
+		return response, nil
+	}
 
-	return response, nil
+	return nil, fmt.Errorf("gang scheduling failed in all availability zones: %w", failures)
 }
 
-// checkCapacityAvailability performs pre-flight checks for instance availability
-func (g *GangScheduler) checkCapacityAvailability(ctx context.Context, req *FleetRequest) error {
-	// Get instance type availability in target subnets
-	instanceTypes := g.fleetManager.selectInstanceTypes(req.InstanceRequirements)
+// attemptZone launches the whole gang in one AZ and verifies every instance is running
+func (g *GangScheduler) attemptZone(ctx context.Context, req *FleetRequest, zone azCandidate) (*FleetResponse, error) {
+	g.logger.Info("Attempting gang launch in availability zone",
+		zap.String("availability_zone", zone.Zone),
+		zap.Strings("subnet_ids", zone.SubnetIds),
+		zap.Float64("spot_price", zone.SpotPrice),
+		zap.Int("spot_score", zone.SpotScore))
 
-	for _, subnet := range req.SubnetIds {
-		for _, instanceType := range instanceTypes {
-			available, err := g.checkInstanceTypeAvailability(ctx, instanceType, subnet)
-			if err != nil {
-				g.logger.Warn("Failed to check capacity",
-					zap.String("instance_type", instanceType),
-					zap.String("subnet", subnet),
-					zap.Error(err))
-				continue
-			}
-
-			if available {
-				g.logger.Debug("Capacity confirmed",
-					zap.String("instance_type", instanceType),
-					zap.String("subnet", subnet))
-				return nil // At least one instance type has capacity
-			}
-		}
+	response, err := g.attemptAtomicLaunch(ctx, req)
+	if err != nil {
+		return response, err
 	}
 
-	return fmt.Errorf("insufficient capacity in target subnets for required instance types")
-}
-
-// checkInstanceTypeAvailability checks if instance type is available in subnet
-func (g *GangScheduler) checkInstanceTypeAvailability(ctx context.Context, instanceType, subnetID string) (bool, error) {
-	// Use EC2 describe-instance-type-offerings to check availability
-	input := &ec2.DescribeInstanceTypeOfferingsInput{
-		LocationType: types.LocationTypeAvailabilityZone,
-		Filters: []types.Filter{
-			{
-				Name:   aws.String("instance-type"),
-				Values: []string{instanceType},
-			},
-		},
+	if len(response.Instances) < len(req.NodeIds) {
+		return response, &FleetLaunchError{
+			Launched:  len(response.Instances),
+			Requested: len(req.NodeIds),
+			Codes:     response.ErrorCodes,
+			Messages:  response.Errors,
+		}
 	}
 
-	result, err := g.ec2Client.DescribeInstanceTypeOfferings(ctx, input)
-	if err != nil {
-		return false, err
+	if err := g.verifyAllInstancesRunning(ctx, response); err != nil {
+		return response, fmt.Errorf("instance verification failed: %w", err)
 	}
 
-	// If any offerings exist, assume capacity is available
-	// Note: This is a simplified check - real capacity checking would require Spot Fleet dry-run
-	return len(result.InstanceTypeOfferings) > 0, nil
+	return response, nil
 }
 
 // attemptAtomicLaunch tries to launch all instances atomically
@@ -124,7 +110,7 @@ func (g *GangScheduler) attemptAtomicLaunch(ctx context.Context, req *FleetReque
 	g.logger.Info("Attempting atomic instance launch",
 		zap.Int("required_count", len(req.NodeIds)))
 
-	return g.fleetManager.LaunchInstanceFleet(ctx, req)
+	return g.fleetManager.launchFleet(ctx, req)
 }
 
 // verifyAllInstancesRunning ensures all instances reach running state
@@ -160,12 +146,13 @@ func (g *GangScheduler) verifyAllInstancesRunning(ctx context.Context, response
 }
 
 // cleanupPartialLaunch terminates any instances that were launched during a failed gang schedule
+// or fleet launch
 func (g *GangScheduler) cleanupPartialLaunch(ctx context.Context, response *FleetResponse) {
 	if response == nil || len(response.Instances) == 0 {
 		return
 	}
 
-	g.logger.Warn("Cleaning up partial launch due to gang scheduling failure",
+	g.logger.Warn("Cleaning up partial launch after launch failure",
 		zap.Int("instances_to_cleanup", len(response.Instances)))
 
 	var instanceIds []string
@@ -0,0 +1,135 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// NodeRoleLaunch describes the nodes and instance types for one role of a heterogeneous job
+type NodeRoleLaunch struct {
+	Role          string
+	NodeIds       []string
+	InstanceTypes []string
+}
+
+// LaunchNodeRoles launches one fleet per node role so that specific node names map to
+// specific instance types. If any role fails, instances from completed roles are
+// terminated so the job never starts on a partial allocation.
+func (f *FleetManager) LaunchNodeRoles(ctx context.Context, base *FleetRequest, roles []NodeRoleLaunch) (*FleetResponse, error) {
+	combined := &FleetResponse{}
+	var fleetIds []string
+
+	f.logger.Info("Launching heterogeneous node roles",
+		zap.Int("roles", len(roles)),
+		zap.Int("total_nodes", len(base.NodeIds)))
+
+	// Gang-scheduled roles share one cluster placement group, so later roles
+	// follow the AZ the first role landed in
+	gang := base.Job.IsMPIJob && base.InstanceRequirements.RequiresEFA
+	var pinnedSubnets []string
+
+	for _, role := range roles {
+		if len(role.NodeIds) == 0 {
+			continue
+		}
+
+		roleReq := f.buildRoleFleetRequest(base, role)
+		if pinnedSubnets != nil {
+			roleReq.SubnetIds = pinnedSubnets
+		}
+		response, err := f.LaunchInstanceFleet(ctx, roleReq)
+		if err != nil {
+			f.logger.Error("Node role launch failed, rolling back heterogeneous launch",
+				zap.String("role", role.Role),
+				zap.Strings("nodes", role.NodeIds),
+				zap.Error(err))
+			if response != nil {
+				combined.Instances = append(combined.Instances, response.Instances...)
+			}
+			f.gangScheduler.cleanupPartialLaunch(ctx, combined)
+			return nil, fmt.Errorf("failed to launch node role %s: %w", role.Role, err)
+		}
+
+		for i := range response.Instances {
+			response.Instances[i].Role = role.Role
+		}
+
+		if gang && pinnedSubnets == nil && len(response.Instances) > 0 {
+			zone := response.Instances[0].AvailabilityZone
+			subnets, err := f.subnetsInZone(ctx, base.SubnetIds, zone)
+			if err != nil {
+				combined.Instances = append(combined.Instances, response.Instances...)
+				f.gangScheduler.cleanupPartialLaunch(ctx, combined)
+				return nil, fmt.Errorf("failed to pin node roles to availability zone %s: %w", zone, err)
+			}
+			pinnedSubnets = subnets
+		}
+
+		combined.Instances = append(combined.Instances, response.Instances...)
+		if combined.SpotPlacementScores == nil {
+			combined.SpotPlacementScores = response.SpotPlacementScores
+		}
+		if combined.templateData == nil {
+			combined.templateData = response.templateData
+		}
+		combined.Errors = append(combined.Errors, response.Errors...)
+		fleetIds = append(fleetIds, response.FleetId)
+
+		f.logger.Info("Node role launched",
+			zap.String("role", role.Role),
+			zap.String("fleet_id", response.FleetId),
+			zap.Strings("instance_types", role.InstanceTypes),
+			zap.Int("instances", len(response.Instances)))
+	}
+
+	combined.FleetId = strings.Join(fleetIds, ",")
+	return combined, nil
+}
+
+// buildRoleFleetRequest derives a per-role fleet request from the job-level request
+func (f *FleetManager) buildRoleFleetRequest(base *FleetRequest, role NodeRoleLaunch) *FleetRequest {
+	requirements := *base.InstanceRequirements
+	requirements.InstanceFamilies = role.InstanceTypes
+
+	tags := make(map[string]string, len(base.Tags)+1)
+	for key, value := range base.Tags {
+		tags[key] = value
+	}
+	tags["NodeRole"] = role.Role
+
+	roleReq := *base
+	roleReq.NodeIds = role.NodeIds
+	roleReq.InstanceRequirements = &requirements
+	roleReq.Tags = tags
+
+	return &roleReq
+}
+
+// NodeRoleLaunchesFromPlan converts plan role assignments into per-role launches
+func NodeRoleLaunchesFromPlan(plan *burstTypes.ExecutionPlan, assignments map[string][]string) []NodeRoleLaunch {
+	var launches []NodeRoleLaunch
+
+	for _, spec := range plan.NodeRoles {
+		if nodes := assignments[spec.Role]; len(nodes) > 0 {
+			launches = append(launches, NodeRoleLaunch{
+				Role:          spec.Role,
+				NodeIds:       nodes,
+				InstanceTypes: spec.InstanceTypes,
+			})
+		}
+	}
+
+	if nodes := assignments[burstTypes.DefaultNodeRole]; len(nodes) > 0 {
+		launches = append(launches, NodeRoleLaunch{
+			Role:          burstTypes.DefaultNodeRole,
+			NodeIds:       nodes,
+			InstanceTypes: plan.InstanceSpec.InstanceTypes,
+		})
+	}
+
+	return launches
+}
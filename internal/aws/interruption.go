@@ -0,0 +1,55 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// spotInterruptionCodes are the spot request status codes EC2 sets when it
+// gives its two-minute interruption notice
+var spotInterruptionCodes = []string{"marked-for-termination", "marked-for-stop", "marked-for-hibernation"}
+
+// InterruptedInstances returns which of a node group's instances EC2 has
+// given a spot interruption notice, with the time of the notice
+func (c *Client) InterruptedInstances(ctx context.Context, partition, nodeGroup string, instanceIDs []string) (map[string]time.Time, error) {
+	interrupted := make(map[string]time.Time)
+	if len(instanceIDs) == 0 {
+		return interrupted, nil
+	}
+	fleetManager, err := c.fleetManagerFor(c.nodeGroupRegion(partition, nodeGroup))
+	if err != nil {
+		return nil, err
+	}
+
+	input := &ec2.DescribeSpotInstanceRequestsInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("instance-id"), Values: instanceIDs},
+			{Name: aws.String("status-code"), Values: spotInterruptionCodes},
+		},
+	}
+	for {
+		output, err := fleetManager.ec2Client.DescribeSpotInstanceRequests(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe spot instance requests: %w", err)
+		}
+		for _, request := range output.SpotInstanceRequests {
+			if request.InstanceId == nil {
+				continue
+			}
+			noticed := time.Now()
+			if request.Status != nil && request.Status.UpdateTime != nil {
+				noticed = *request.Status.UpdateTime
+			}
+			interrupted[*request.InstanceId] = noticed
+		}
+		if aws.ToString(output.NextToken) == "" {
+			return interrupted, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
@@ -0,0 +1,30 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_InterruptedInstances(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	ctx := context.Background()
+
+	interrupted, err := client.InterruptedInstances(ctx, "aws", "cpu", nil)
+	require.NoError(t, err)
+	assert.Empty(t, interrupted)
+
+	noticed := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	ec2.spotRequests = []types.SpotInstanceRequest{
+		{InstanceId: aws.String("i-1"), Status: &types.SpotInstanceStatus{Code: aws.String("marked-for-termination"), UpdateTime: aws.Time(noticed)}},
+		{SpotInstanceRequestId: aws.String("sir-unfulfilled")},
+	}
+	interrupted, err = client.InterruptedInstances(ctx, "aws", "cpu", []string{"i-1", "i-2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Time{"i-1": noticed}, interrupted)
+}
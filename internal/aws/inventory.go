@@ -0,0 +1,133 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// CostSummary estimates what the running burst instances cost
+type CostSummary struct {
+	Instances   int                `json:"instances"`
+	HourlyUSD   float64            `json:"hourly_usd"`  // Current run rate
+	AccruedUSD  float64            `json:"accrued_usd"` // Since each instance launched
+	ByPartition map[string]float64 `json:"by_partition_hourly_usd"`
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// ListManagedInstances returns the live instances this tool launched, including
+// stopped warm pool and suspended instances
+func (f *FleetManager) ListManagedInstances(ctx context.Context) ([]burstTypes.InstanceInfo, error) {
+	input := &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("tag:ManagedBy"), Values: []string{"aws-slurm-burst"}},
+			{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}},
+		},
+	}
+
+	var instances []burstTypes.InstanceInfo
+	paginator := ec2.NewDescribeInstancesPaginator(f.ec2Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe managed instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				info := newInstanceInfo(instance, nodeNameOf(instance))
+				for _, tag := range instance.Tags {
+					switch aws.ToString(tag.Key) {
+					case "Partition":
+						info.Partition = aws.ToString(tag.Value)
+					case "NodeGroup":
+						info.NodeGroup = aws.ToString(tag.Value)
+					case "JobID":
+						info.JobID = aws.ToString(tag.Value)
+					}
+				}
+				instances = append(instances, info)
+			}
+		}
+	}
+
+	return instances, nil
+}
+
+// ListInstances returns the managed instances in every region a node group uses
+func (c *Client) ListInstances(ctx context.Context) ([]burstTypes.InstanceInfo, error) {
+	regions := []string{c.config.Region}
+	seen := map[string]bool{c.config.Region: true}
+	for _, partition := range c.appConfig.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			if nodeGroup.Region != "" && !seen[nodeGroup.Region] {
+				seen[nodeGroup.Region] = true
+				regions = append(regions, nodeGroup.Region)
+			}
+		}
+	}
+
+	var instances []burstTypes.InstanceInfo
+	for _, region := range regions {
+		fleetManager, err := c.fleetManagerFor(region)
+		if err != nil {
+			return nil, err
+		}
+		regional, err := fleetManager.ListManagedInstances(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", region, err)
+		}
+		instances = append(instances, regional...)
+	}
+
+	return instances, nil
+}
+
+// SummarizeCosts estimates the run rate and accrued cost of the managed instances
+func (c *Client) SummarizeCosts(ctx context.Context) (*CostSummary, error) {
+	instances, err := c.ListInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var instanceTypes []string
+	for _, instance := range instances {
+		instanceTypes = append(instanceTypes, instance.InstanceType)
+	}
+	pricing, err := c.fleetManager.GetInstancePricing(ctx, dedupe(instanceTypes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to price instances: %w", err)
+	}
+
+	return summarizeCosts(instances, pricing, time.Now()), nil
+}
+
+// InstancePricing returns hourly prices for instance types
+func (c *Client) InstancePricing(ctx context.Context, instanceTypes []string) (map[string]float64, error) {
+	return c.fleetManager.GetInstancePricing(ctx, instanceTypes)
+}
+
+// summarizeCosts prices the running instances; stopped instances only pay for storage
+// and are not counted
+func summarizeCosts(instances []burstTypes.InstanceInfo, pricing map[string]float64, now time.Time) *CostSummary {
+	summary := &CostSummary{ByPartition: make(map[string]float64), GeneratedAt: now}
+	for _, instance := range instances {
+		if instance.State != string(types.InstanceStateNameRunning) && instance.State != string(types.InstanceStateNamePending) {
+			continue
+		}
+
+		hourly := pricing[instance.InstanceType]
+		summary.Instances++
+		summary.HourlyUSD += hourly
+		summary.ByPartition[instance.Partition] += hourly
+
+		if launched, err := time.Parse(time.RFC3339, instance.LaunchTime); err == nil && now.After(launched) {
+			summary.AccruedUSD += hourly * now.Sub(launched).Hours()
+		}
+	}
+	return summary
+}
@@ -0,0 +1,25 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeCosts(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	instances := []burstTypes.InstanceInfo{
+		{InstanceType: "c6i.xlarge", State: "running", Partition: "aws", LaunchTime: now.Add(-2 * time.Hour).Format(time.RFC3339)},
+		{InstanceType: "c6i.2xlarge", State: "pending", Partition: "gpu", LaunchTime: now.Format(time.RFC3339)},
+		{InstanceType: "c6i.2xlarge", State: "stopped", Partition: "aws", LaunchTime: now.Add(-time.Hour).Format(time.RFC3339)},
+	}
+	pricing := map[string]float64{"c6i.xlarge": 0.2, "c6i.2xlarge": 0.4}
+
+	summary := summarizeCosts(instances, pricing, now)
+	assert.Equal(t, 2, summary.Instances)
+	assert.InDelta(t, 0.6, summary.HourlyUSD, 1e-9)
+	assert.InDelta(t, 0.4, summary.AccruedUSD, 1e-9)
+	assert.InDelta(t, 0.2, summary.ByPartition["aws"], 1e-9)
+}
@@ -0,0 +1,243 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// Lingering instance tags
+const (
+	lingerPoolTag  = "LingerPool"  // Pool name, present only while the instance waits to be claimed
+	lingerUntilTag = "LingerUntil" // RFC3339 end of the linger window, after which the instance is terminated
+)
+
+// lingerServes reports whether a request can claim the node group's lingering
+// instances. They already ran the node group's user data, node bootstrap and
+// munge key delivery, so only job-specific user data rules them out; gang jobs
+// need instances launched together into one placement.
+func lingerServes(nodeGroup *burstConfig.NodeGroupConfig, req *FleetRequest, roles []NodeRoleLaunch) bool {
+	return nodeGroup != nil && nodeGroup.Linger.Enabled() && len(roles) == 0 && len(req.UserDataParts) == 0 &&
+		req.CapacityBlock == nil && !isGangRequest(req)
+}
+
+// LingerInstances moves nodes' running instances into a node group's linger
+// pool until the window ends: they stop answering to their node names, so a
+// terminate or launch for those nodes no longer finds them, and the next
+// resume of the node group can claim them. It returns the nodes with no
+// running instance here.
+func (f *FleetManager) LingerInstances(ctx context.Context, pool string, nodeNames []string, window time.Duration) ([]string, error) {
+	if len(nodeNames) == 0 {
+		return nil, nil
+	}
+
+	instances, err := f.describeNodeInstances(ctx, nodeNames, []string{"running"}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+	var instanceIds []string
+	for _, instance := range instances {
+		found[nodeNameOf(instance)] = true
+		instanceIds = append(instanceIds, aws.ToString(instance.InstanceId))
+	}
+
+	var missing []string
+	for _, nodeName := range nodeNames {
+		if !found[nodeName] {
+			missing = append(missing, nodeName)
+		}
+	}
+	if len(instanceIds) == 0 {
+		return missing, nil
+	}
+
+	until := time.Now().Add(window).UTC().Format(time.RFC3339)
+	if _, err := f.ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{
+		Resources: instanceIds,
+		Tags: []types.Tag{
+			{Key: aws.String("Name"), Value: aws.String(pool + "-lingering")},
+			{Key: aws.String(lingerPoolTag), Value: aws.String(pool)},
+			{Key: aws.String(lingerUntilTag), Value: aws.String(until)},
+		},
+	}); err != nil {
+		return missing, fmt.Errorf("failed to tag lingering instances: %w", err)
+	}
+	if _, err := f.ec2Client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: instanceIds,
+		Tags:      []types.Tag{{Key: aws.String("SlurmNode")}, {Key: aws.String("JobID")}},
+	}); err != nil {
+		f.logger.Warn("Failed to clear node tags of lingering instances", zap.Error(err))
+	}
+
+	f.logger.Info("Instances lingering for the next resume",
+		zap.String("pool", pool),
+		zap.Strings("instance_ids", instanceIds),
+		zap.String("until", until))
+
+	return missing, nil
+}
+
+// ClaimLingeringInstances takes over lingering pool instances for as many of
+// the request's nodes as the pool can serve, and returns the nodes still
+// needing a launch. Claimed instances are running but their slurmd is stopped;
+// the caller restarts it as the new node. Failures are advisory: the fleet
+// launches whatever the pool could not serve.
+func (f *FleetManager) ClaimLingeringInstances(ctx context.Context, req *FleetRequest, pool string) (*FleetResponse, []string) {
+	unlock, err := f.lockPool("linger-pool-" + pool)
+	if err != nil {
+		f.logger.Warn("Linger pool busy, launching fleet", zap.String("pool", pool), zap.Error(err))
+		return nil, req.NodeIds
+	}
+	defer unlock()
+
+	available, err := f.listLingering(ctx, pool)
+	if err != nil {
+		f.logger.Warn("Linger pool unavailable, launching fleet", zap.String("pool", pool), zap.Error(err))
+		return nil, req.NodeIds
+	}
+
+	ready := f.claimableInstances(available, req, time.Now())
+	if len(ready) == 0 {
+		return nil, req.NodeIds
+	}
+	count := min(len(ready), len(req.NodeIds))
+	ready = ready[:count]
+
+	instanceIds := make([]string, 0, count)
+	claimed := make([]burstTypes.InstanceInfo, 0, count)
+	for i, instance := range ready {
+		instanceIds = append(instanceIds, aws.ToString(instance.InstanceId))
+		claimed = append(claimed, newInstanceInfo(instance, req.NodeIds[i]))
+	}
+
+	// Leave the pool before retagging so a concurrent resume or the reaper skips them
+	if _, err := f.ec2Client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: instanceIds,
+		Tags: []types.Tag{
+			{Key: aws.String(lingerPoolTag), Value: aws.String(pool)},
+			{Key: aws.String(lingerUntilTag)},
+		},
+	}); err != nil {
+		f.logger.Warn("Failed to claim lingering instances, launching fleet", zap.Error(err))
+		return nil, req.NodeIds
+	}
+
+	if err := f.tagInstancesWithNodeNames(ctx, claimed); err != nil {
+		// Half-tagged instances answer to no pool and no node
+		f.logger.Warn("Failed to tag claimed instances, terminating them", zap.Error(err))
+		f.gangScheduler.cleanupPartialLaunch(ctx, &FleetResponse{Instances: claimed})
+		return nil, req.NodeIds
+	}
+	f.tagInstances(ctx, instanceIds, req.Tags)
+
+	f.logger.Info("Claimed lingering instances",
+		zap.String("pool", pool),
+		zap.Strings("instance_ids", instanceIds),
+		zap.Strings("nodes", req.NodeIds[:count]))
+
+	return &FleetResponse{Instances: claimed}, req.NodeIds[count:]
+}
+
+// claimableInstances returns the running pool instances a request can use
+// that are still inside their window, those closest to termination first
+func (f *FleetManager) claimableInstances(available []types.Instance, req *FleetRequest, now time.Time) []types.Instance {
+	acceptable := make(map[string]bool)
+	for _, instanceType := range f.selectInstanceTypes(req.InstanceRequirements) {
+		acceptable[instanceType] = true
+	}
+
+	var ready []types.Instance
+	for _, instance := range available {
+		until, ok := lingerUntil(instance)
+		if !ok || !now.Before(until) {
+			continue
+		}
+		if instance.State == nil || instance.State.Name != types.InstanceStateNameRunning {
+			continue
+		}
+		if len(acceptable) > 0 && !acceptable[string(instance.InstanceType)] {
+			continue
+		}
+		ready = append(ready, instance)
+	}
+
+	sort.SliceStable(ready, func(i, j int) bool {
+		until, _ := lingerUntil(ready[i])
+		other, _ := lingerUntil(ready[j])
+		return until.Before(other)
+	})
+	return ready
+}
+
+// ReapLingeringInstances terminates a pool's instances whose linger window
+// has ended without a resume claiming them
+func (f *FleetManager) ReapLingeringInstances(ctx context.Context, pool string, dryRun bool) ([]string, error) {
+	unlock, err := f.lockPool("linger-pool-" + pool)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	instances, err := f.listLingering(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []string
+	now := time.Now()
+	for _, instance := range instances {
+		if until, ok := lingerUntil(instance); !ok || !now.Before(until) {
+			expired = append(expired, aws.ToString(instance.InstanceId))
+		}
+	}
+
+	if len(expired) == 0 || dryRun {
+		return expired, nil
+	}
+
+	if _, err := f.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: expired}); err != nil {
+		return nil, fmt.Errorf("failed to terminate expired lingering instances: %w", err)
+	}
+
+	return expired, nil
+}
+
+// listLingering returns the instances waiting in a linger pool
+func (f *FleetManager) listLingering(ctx context.Context, pool string) ([]types.Instance, error) {
+	result, err := f.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("tag:" + lingerPoolTag), Values: []string{pool}},
+			{Name: aws.String("instance-state-name"), Values: []string{"pending", "running"}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe linger pool %s: %w", pool, err)
+	}
+
+	var instances []types.Instance
+	for _, reservation := range result.Reservations {
+		instances = append(instances, reservation.Instances...)
+	}
+	return instances, nil
+}
+
+// lingerUntil returns when a lingering instance's window ends
+func lingerUntil(instance types.Instance) (time.Time, bool) {
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == lingerUntilTag {
+			until, err := time.Parse(time.RFC3339, aws.ToString(tag.Value))
+			return until, err == nil
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,140 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tagValue returns an instance's tag, or "" without it
+func tagValue(instance types.Instance, key string) string {
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
+func TestClient_LingerAndClaim(t *testing.T) {
+	ctx := context.Background()
+	client, ec2 := newFakeEC2Client(t)
+	ec2.addInstance("i-a", "aws-linger-1", "")
+	ec2.addInstance("i-b", "aws-linger-2", "")
+
+	missing, err := client.LingerInstances(ctx, "aws", "linger", []string{"aws-linger-1", "aws-linger-3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws-linger-3"}, missing)
+
+	lingering := ec2.instances[0]
+	assert.Equal(t, "aws-linger-lingering", nodeNameOf(lingering), "no longer answers to its node name")
+	assert.Equal(t, "aws-linger", tagValue(lingering, lingerPoolTag))
+	until, ok := lingerUntil(lingering)
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), until, time.Minute)
+	assert.Empty(t, ec2.terminated)
+
+	_, err = client.LingerInstances(ctx, "aws", "cpu", []string{"aws-cpu-1"})
+	assert.Error(t, err, "node group does not linger")
+
+	result, err := client.LaunchInstances(ctx, &LaunchRequest{
+		NodeIds:              []string{"aws-linger-4", "aws-linger-5"},
+		Partition:            "aws",
+		NodeGroup:            "linger",
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}},
+		Job:                  &burstTypes.SlurmJob{JobID: "43"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, result.LingeringInstances)
+	assert.Equal(t, []string{"aws-linger-4"}, result.ClaimedNodes)
+	require.Len(t, ec2.fleets, 1)
+	assert.Equal(t, int32(1), aws.ToInt32(ec2.fleets[0].TargetCapacitySpecification.TotalTargetCapacity), "fleet launches only the unclaimed node")
+	require.Len(t, result.Instances, 2)
+	assert.Equal(t, "i-a", result.Instances[0].InstanceID)
+	assert.Equal(t, "aws-linger-4", result.Instances[0].NodeName)
+
+	claimed := ec2.instances[0]
+	assert.Equal(t, "aws-linger-4", nodeNameOf(claimed))
+	assert.Equal(t, "aws-linger-4", tagValue(claimed, "SlurmNode"))
+	assert.Equal(t, "43", tagValue(claimed, "JobID"))
+	assert.Empty(t, tagValue(claimed, lingerPoolTag), "left the pool")
+}
+
+func TestClient_ReapLingeringInstances(t *testing.T) {
+	ctx := context.Background()
+	client, ec2 := newFakeEC2Client(t)
+	ec2.addInstance("i-a", "aws-linger-1", "")
+	ec2.addInstance("i-b", "aws-linger-2", "")
+
+	_, err := client.LingerInstances(ctx, "aws", "linger", []string{"aws-linger-1", "aws-linger-2"})
+	require.NoError(t, err)
+	for i, tag := range ec2.instances[1].Tags {
+		if aws.ToString(tag.Key) == lingerUntilTag {
+			ec2.instances[1].Tags[i].Value = aws.String(time.Now().Add(-time.Second).UTC().Format(time.RFC3339))
+		}
+	}
+
+	reaped, err := client.ReapLingeringInstances(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i-b"}, reaped)
+	assert.Empty(t, ec2.terminated, "dry run")
+
+	reaped, err = client.ReapLingeringInstances(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i-b"}, reaped)
+	assert.Equal(t, []string{"i-b"}, ec2.terminated)
+
+	result, err := client.LaunchInstances(ctx, &LaunchRequest{
+		NodeIds:              []string{"aws-linger-3"},
+		Partition:            "aws",
+		NodeGroup:            "linger",
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}},
+		Job:                  &burstTypes.SlurmJob{JobID: "44"},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Instances, 1)
+	assert.Equal(t, "i-a", result.Instances[0].InstanceID, "only the instance inside its window is claimed")
+}
+
+func TestFleetManager_ClaimLingeringInstances_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	client, ec2 := newFakeEC2Client(t)
+	ec2.addInstance("i-a", "aws-linger-1", "")
+	_, err := client.LingerInstances(ctx, "aws", "linger", []string{"aws-linger-1"})
+	require.NoError(t, err)
+
+	ec2.describeDelay = 10 * time.Millisecond
+	fleetManager := client.fleetManager
+	fleetManager.poolLockDir = t.TempDir()
+
+	var wg sync.WaitGroup
+	claims := make([]*FleetResponse, 8)
+	for i := range claims {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claims[i], _ = fleetManager.ClaimLingeringInstances(ctx, &FleetRequest{
+				NodeIds:              []string{fmt.Sprintf("aws-linger-%d", i+2)},
+				InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}},
+			}, "aws-linger")
+		}()
+	}
+	wg.Wait()
+
+	var claimed int
+	for _, claim := range claims {
+		if claim != nil {
+			claimed += len(claim.Instances)
+		}
+	}
+	assert.Equal(t, 1, claimed, "one resume claims the instance")
+}
@@ -0,0 +1,87 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// CapacitySplit divides a fleet between on-demand and spot capacity per the
+// strategy's spot ratio. When the strategy allows mixing, a multi-node fleet
+// always keeps at least one on-demand anchor unless the ratio is exactly 1.
+func (s *SpotPricingStrategy) CapacitySplit(total int) (onDemand, spot int) {
+	if s == nil || total <= 0 {
+		return total, 0
+	}
+
+	ratio := math.Max(0, math.Min(1, s.SpotAllocationRatio))
+	spot = int(math.Round(float64(total) * ratio))
+	if s.AllowMixedPricing && ratio < 1 && total > 1 && spot == total {
+		spot = total - 1
+	}
+
+	return total - spot, spot
+}
+
+// needsOnDemandFallback reports whether spot capacity fell short and the strategy
+// allows the missing nodes to launch on-demand. Instances that launched but failed
+// to start are an error, not a capacity shortfall.
+func needsOnDemandFallback(req *FleetRequest, response *FleetResponse, err error) bool {
+	if req.SpotStrategy == nil || !req.SpotStrategy.OnDemandFallback || response == nil {
+		return false
+	}
+	if err != nil && len(response.Instances) > 0 {
+		return false
+	}
+	return len(response.Instances) < len(req.NodeIds)
+}
+
+// launchOnDemandFallback launches the nodes a mixed fleet left unfilled as on-demand
+func (f *FleetManager) launchOnDemandFallback(ctx context.Context, req *FleetRequest, partial *FleetResponse) (*FleetResponse, error) {
+	remaining := req.NodeIds[len(partial.Instances):]
+
+	f.logger.Warn("Spot capacity unavailable, launching remaining nodes on-demand",
+		zap.Int("launched", len(partial.Instances)),
+		zap.Strings("fallback_nodes", remaining))
+
+	requirements := *req.InstanceRequirements
+	requirements.PreferSpot = false
+	requirements.AllowMixedPricing = false
+
+	fallbackReq := *req
+	fallbackReq.NodeIds = remaining
+	fallbackReq.InstanceRequirements = &requirements
+	fallbackReq.SpotStrategy = nil
+	fallbackReq.spotScores = nil
+
+	fallback, err := f.launchFleet(ctx, &fallbackReq)
+
+	combined := &FleetResponse{
+		Instances:           partial.Instances,
+		Errors:              partial.Errors,
+		ErrorCodes:          partial.ErrorCodes,
+		SpotPlacementScores: req.spotScores,
+	}
+	fleetIds := []string{}
+	if partial.FleetId != "" {
+		fleetIds = append(fleetIds, partial.FleetId)
+	}
+	if fallback != nil {
+		combined.Instances = append(combined.Instances, fallback.Instances...)
+		combined.Errors = append(combined.Errors, fallback.Errors...)
+		combined.ErrorCodes = append(combined.ErrorCodes, fallback.ErrorCodes...)
+		if fallback.FleetId != "" {
+			fleetIds = append(fleetIds, fallback.FleetId)
+		}
+	}
+	combined.FleetId = strings.Join(fleetIds, ",")
+
+	if err != nil {
+		return combined, fmt.Errorf("on-demand fallback failed: %w", err)
+	}
+
+	return combined, nil
+}
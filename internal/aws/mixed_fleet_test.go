@@ -0,0 +1,174 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSpotPricingStrategy_CapacitySplit(t *testing.T) {
+	tests := []struct {
+		name             string
+		total            int
+		strategy         *SpotPricingStrategy
+		expectedOnDemand int
+		expectedSpot     int
+	}{
+		{"no strategy", 4, nil, 4, 0},
+		{"30% on-demand anchors", 10, &SpotPricingStrategy{SpotAllocationRatio: 0.7, AllowMixedPricing: true}, 3, 7},
+		{"EFA MPI mostly on-demand", 4, &SpotPricingStrategy{SpotAllocationRatio: 0.3, AllowMixedPricing: true}, 3, 1},
+		{"keeps one anchor", 2, &SpotPricingStrategy{SpotAllocationRatio: 0.9, AllowMixedPricing: true}, 1, 1},
+		{"all spot", 4, &SpotPricingStrategy{SpotAllocationRatio: 1.0, AllowMixedPricing: true}, 0, 4},
+		{"single node", 1, &SpotPricingStrategy{SpotAllocationRatio: 0.9, AllowMixedPricing: true}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			onDemand, spot := tt.strategy.CapacitySplit(tt.total)
+			assert.Equal(t, tt.expectedOnDemand, onDemand)
+			assert.Equal(t, tt.expectedSpot, spot)
+		})
+	}
+}
+
+func TestFleetManager_buildFleetRequest_MixedCapacity(t *testing.T) {
+	fleetManager := &FleetManager{
+		logger:  zaptest.NewLogger(t),
+		catalog: newTestCatalog(t, ""),
+	}
+
+	req := &FleetRequest{
+		NodeIds: []string{"n1", "n2", "n3", "n4", "n5", "n6", "n7", "n8", "n9", "n10"},
+		InstanceRequirements: &burstTypes.InstanceRequirements{
+			InstanceFamilies:  []string{"c6i.xlarge"},
+			AllowMixedPricing: true,
+		},
+		Job:            &burstTypes.SlurmJob{JobID: "123"},
+		LaunchTemplate: LaunchTemplateConfig{Name: "compute", Version: "$Latest"},
+		SubnetIds:      []string{"subnet-a"},
+		SpotStrategy:   &SpotPricingStrategy{SpotAllocationRatio: 0.7, AllowMixedPricing: true, OnDemandFallback: true},
+	}
+
+	input, err := fleetManager.buildFleetRequest(req, "")
+	require.NoError(t, err)
+
+	capacity := input.TargetCapacitySpecification
+	assert.Equal(t, int32(10), aws.ToInt32(capacity.TotalTargetCapacity))
+	assert.Equal(t, int32(3), aws.ToInt32(capacity.OnDemandTargetCapacity))
+	assert.Equal(t, int32(7), aws.ToInt32(capacity.SpotTargetCapacity))
+	assert.Equal(t, types.DefaultTargetCapacityTypeSpot, capacity.DefaultTargetCapacityType)
+	assert.NotNil(t, input.SpotOptions)
+	assert.NotNil(t, input.OnDemandOptions)
+}
+
+func TestNeedsOnDemandFallback(t *testing.T) {
+	req := &FleetRequest{
+		NodeIds:      []string{"n1", "n2", "n3"},
+		SpotStrategy: &SpotPricingStrategy{OnDemandFallback: true},
+	}
+	partial := &FleetResponse{Instances: []burstTypes.InstanceInfo{{InstanceID: "i-1"}}}
+
+	assert.True(t, needsOnDemandFallback(req, partial, nil))
+	assert.True(t, needsOnDemandFallback(req, &FleetResponse{}, errors.New("no instances were launched")))
+	assert.False(t, needsOnDemandFallback(req, partial, errors.New("instances failed to reach running state")))
+
+	noFallback := *req
+	noFallback.SpotStrategy = &SpotPricingStrategy{OnDemandFallback: false}
+	assert.False(t, needsOnDemandFallback(&noFallback, partial, nil))
+}
+
+func TestFleetManager_buildFleetRequest_FleetOptions(t *testing.T) {
+	fleetManager := &FleetManager{
+		logger:  zaptest.NewLogger(t),
+		catalog: newTestCatalog(t, ""),
+	}
+
+	req := &FleetRequest{
+		NodeIds: []string{"n1", "n2", "n3", "n4"},
+		InstanceRequirements: &burstTypes.InstanceRequirements{
+			InstanceFamilies:  []string{"c6i.xlarge", "c6i.2xlarge"},
+			PreferSpot:        true,
+			AllowMixedPricing: true,
+			MaxSpotPrice:      0.50,
+		},
+		Job:            &burstTypes.SlurmJob{JobID: "123"},
+		LaunchTemplate: LaunchTemplateConfig{Name: "compute", Version: "$Latest"},
+		SubnetIds:      []string{"subnet-a"},
+		SpotOptions: burstConfig.SpotOptionsConfig{
+			AllocationStrategy:           "price-capacity-optimized",
+			MaxPrice:                     0.20,
+			InstanceInterruptionBehavior: "stop",
+		},
+		OnDemandOptions: burstConfig.OnDemandOptionsConfig{
+			AllocationStrategy:            "prioritized",
+			CapacityReservationPreference: "use-capacity-reservations-first",
+		},
+	}
+
+	input, err := fleetManager.buildFleetRequest(req, "")
+	require.NoError(t, err)
+
+	spot := input.SpotOptions
+	assert.Equal(t, types.SpotAllocationStrategyPriceCapacityOptimized, spot.AllocationStrategy)
+	assert.Equal(t, types.SpotInstanceInterruptionBehaviorStop, spot.InstanceInterruptionBehavior)
+	assert.Equal(t, "0.8000", aws.ToString(spot.MaxTotalPrice), "the lower node group price caps the plan's")
+
+	onDemand := input.OnDemandOptions
+	assert.Equal(t, types.FleetOnDemandAllocationStrategyPrioritized, onDemand.AllocationStrategy)
+	assert.Equal(t, types.FleetCapacityReservationUsageStrategyUseCapacityReservationsFirst, onDemand.CapacityReservationOptions.UsageStrategy)
+	for i, override := range input.LaunchTemplateConfigs[0].Overrides {
+		assert.Equal(t, float64(i), aws.ToFloat64(override.Priority), "prioritized capacity follows override order")
+	}
+}
+
+func TestFleetManager_buildLaunchTemplateOverrides_Configured(t *testing.T) {
+	fleetManager := &FleetManager{
+		logger:  zaptest.NewLogger(t),
+		catalog: newTestCatalog(t, ""),
+	}
+
+	req := &FleetRequest{
+		InstanceRequirements: &burstTypes.InstanceRequirements{
+			InstanceFamilies: []string{"c6i.xlarge", "c6i.2xlarge"},
+			PreferSpot:       true,
+		},
+		SubnetIds: []string{"subnet-a", "subnet-b"},
+		ConfiguredOverrides: []burstConfig.LaunchTemplateOverride{
+			{InstanceType: "c6i.xlarge", SpotPrice: "0.08", SubnetID: "subnet-b", WeightedCapacity: 2},
+			{InstanceType: "p4d.24xlarge", SpotPrice: "9.00"}, // Not selected for this job
+		},
+	}
+
+	type override struct {
+		instanceType string
+		subnet       string
+		maxPrice     string
+		weight       float64
+	}
+	summarize := func(overrides []types.FleetLaunchTemplateOverridesRequest) []override {
+		var result []override
+		for _, o := range overrides {
+			result = append(result, override{string(o.InstanceType), aws.ToString(o.SubnetId), aws.ToString(o.MaxPrice), aws.ToFloat64(o.WeightedCapacity)})
+		}
+		return result
+	}
+
+	assert.Equal(t, []override{
+		{"c6i.xlarge", "subnet-b", "0.08", 2},
+		{"c6i.2xlarge", "subnet-a", "", 1},
+		{"c6i.2xlarge", "subnet-b", "", 1},
+	}, summarize(fleetManager.buildLaunchTemplateOverrides(req, "")), "configured overrides are used as written; other types are generated")
+
+	// A launch narrowed to other subnets drops overrides pinned elsewhere
+	req.SubnetIds = []string{"subnet-a"}
+	assert.Equal(t, []override{
+		{"c6i.2xlarge", "subnet-a", "", 1},
+	}, summarize(fleetManager.buildLaunchTemplateOverrides(req, "")))
+}
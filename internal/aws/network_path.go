@@ -0,0 +1,204 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.uber.org/zap"
+)
+
+// NetworkPathProblem is a subnet whose instances could not reach the controller
+type NetworkPathProblem struct {
+	SubnetID string
+	Reason   string
+}
+
+func (p NetworkPathProblem) String() string {
+	return p.SubnetID + ": " + p.Reason
+}
+
+// CheckNetworkPath checks that instances in a node group's subnets can reach
+// the controller at address: each subnet's route to it is active, and the VPN
+// connections or transit gateway attachment the route uses are up. Paths EC2
+// cannot see, such as Direct Connect without a VPN, count as up. The error is
+// for checks that could not run; a path that is down is a problem.
+func (c *Client) CheckNetworkPath(ctx context.Context, partition, nodeGroup string, address netip.Addr) ([]NetworkPathProblem, error) {
+	nodeGroupConfig := c.findNodeGroupConfig(partition, nodeGroup)
+	if nodeGroupConfig == nil {
+		return nil, fmt.Errorf("node group %s/%s not found in configuration", partition, nodeGroup)
+	}
+	fleetManager, err := c.fleetManagerFor(c.nodeGroupRegion(partition, nodeGroup))
+	if err != nil {
+		return nil, err
+	}
+	return fleetManager.checkNetworkPath(ctx, nodeGroupConfig.SubnetIds, address)
+}
+
+// checkNetworkPath checks the route from each subnet to address
+func (f *FleetManager) checkNetworkPath(ctx context.Context, subnetIds []string, address netip.Addr) ([]NetworkPathProblem, error) {
+	subnets, err := f.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{SubnetIds: subnetIds})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe subnets: %w", err)
+	}
+	vpcOf := make(map[string]string, len(subnets.Subnets))
+	var vpcIds []string
+	for _, subnet := range subnets.Subnets {
+		vpcID := aws.ToString(subnet.VpcId)
+		vpcOf[aws.ToString(subnet.SubnetId)] = vpcID
+		if !slices.Contains(vpcIds, vpcID) {
+			vpcIds = append(vpcIds, vpcID)
+		}
+	}
+
+	var tables []types.RouteTable
+	paginator := ec2.NewDescribeRouteTablesPaginator(f.ec2Client, &ec2.DescribeRouteTablesInput{
+		Filters: []types.Filter{{Name: aws.String("vpc-id"), Values: vpcIds}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe route tables: %w", err)
+		}
+		tables = append(tables, page.RouteTables...)
+	}
+
+	// Gateways are checked once however many subnets route through them
+	gatewayProblems := make(map[string]string)
+	var problems []NetworkPathProblem
+	for _, subnetID := range subnetIds {
+		table := subnetRouteTable(tables, subnetID, vpcOf[subnetID])
+		if table == nil {
+			problems = append(problems, NetworkPathProblem{subnetID, "no route table found"})
+			continue
+		}
+		route := routeTo(table.Routes, address)
+		if route == nil {
+			problems = append(problems, NetworkPathProblem{subnetID,
+				fmt.Sprintf("route table %s has no route to %s", aws.ToString(table.RouteTableId), address)})
+			continue
+		}
+		destination := aws.ToString(route.DestinationCidrBlock) + aws.ToString(route.DestinationIpv6CidrBlock)
+		if route.State == types.RouteStateBlackhole {
+			problems = append(problems, NetworkPathProblem{subnetID,
+				fmt.Sprintf("route %s in %s is a blackhole; its target was deleted or detached", destination, aws.ToString(table.RouteTableId))})
+			continue
+		}
+
+		gateway := aws.ToString(route.TransitGatewayId)
+		if gateway == "" && strings.HasPrefix(aws.ToString(route.GatewayId), "vgw-") {
+			gateway = aws.ToString(route.GatewayId)
+		}
+		if gateway == "" {
+			continue
+		}
+		reason, checked := gatewayProblems[gateway]
+		if !checked {
+			if reason, err = f.gatewayProblem(ctx, gateway, vpcOf[subnetID]); err != nil {
+				return nil, err
+			}
+			gatewayProblems[gateway] = reason
+		}
+		if reason != "" {
+			problems = append(problems, NetworkPathProblem{subnetID, fmt.Sprintf("route %s through %s: %s", destination, gateway, reason)})
+		}
+	}
+
+	f.logger.Debug("Checked network path to controller",
+		zap.String("controller", address.String()),
+		zap.Strings("subnet_ids", subnetIds),
+		zap.Int("problems", len(problems)))
+	return problems, nil
+}
+
+// gatewayProblem explains why a virtual private gateway or transit gateway
+// cannot carry traffic from vpcID, or returns "" when it can
+func (f *FleetManager) gatewayProblem(ctx context.Context, gateway, vpcID string) (string, error) {
+	filter := "vpn-gateway-id"
+	if strings.HasPrefix(gateway, "tgw-") {
+		filter = "transit-gateway-id"
+
+		attachments, err := f.ec2Client.DescribeTransitGatewayVpcAttachments(ctx, &ec2.DescribeTransitGatewayVpcAttachmentsInput{
+			Filters: []types.Filter{
+				{Name: aws.String("transit-gateway-id"), Values: []string{gateway}},
+				{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to describe transit gateway attachments: %w", err)
+		}
+		attached := false
+		for _, attachment := range attachments.TransitGatewayVpcAttachments {
+			attached = attached || attachment.State == types.TransitGatewayAttachmentStateAvailable
+		}
+		if !attached {
+			return fmt.Sprintf("%s has no available attachment to %s", gateway, vpcID), nil
+		}
+	}
+
+	connections, err := f.ec2Client.DescribeVpnConnections(ctx, &ec2.DescribeVpnConnectionsInput{
+		Filters: []types.Filter{{Name: aws.String(filter), Values: []string{gateway}}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe VPN connections: %w", err)
+	}
+
+	// Without VPN connections the gateway carries Direct Connect, which EC2 cannot see
+	var down []string
+	for _, connection := range connections.VpnConnections {
+		if connection.State == types.VpnStateDeleted || connection.State == types.VpnStateDeleting {
+			continue
+		}
+		if connection.State == types.VpnStateAvailable && slices.ContainsFunc(connection.VgwTelemetry, func(tunnel types.VgwTelemetry) bool {
+			return tunnel.Status == types.TelemetryStatusUp
+		}) {
+			return "", nil
+		}
+		down = append(down, aws.ToString(connection.VpnConnectionId))
+	}
+	if len(down) > 0 {
+		return fmt.Sprintf("no VPN tunnel is up (%s)", strings.Join(down, ", ")), nil
+	}
+	return "", nil
+}
+
+// subnetRouteTable returns the route table associated with a subnet, or its
+// VPC's main route table
+func subnetRouteTable(tables []types.RouteTable, subnetID, vpcID string) *types.RouteTable {
+	var main *types.RouteTable
+	for i := range tables {
+		for _, association := range tables[i].Associations {
+			if aws.ToString(association.SubnetId) == subnetID {
+				return &tables[i]
+			}
+			if aws.ToBool(association.Main) && aws.ToString(tables[i].VpcId) == vpcID {
+				main = &tables[i]
+			}
+		}
+	}
+	return main
+}
+
+// routeTo returns the most specific route whose destination contains address.
+// Prefix list destinations are not expanded.
+func routeTo(routes []types.Route, address netip.Addr) *types.Route {
+	var best *types.Route
+	bestBits := -1
+	for i, route := range routes {
+		destination := aws.ToString(route.DestinationCidrBlock)
+		if address.Is6() {
+			destination = aws.ToString(route.DestinationIpv6CidrBlock)
+		}
+		prefix, err := netip.ParsePrefix(destination)
+		if err != nil || !prefix.Contains(address) || prefix.Bits() <= bestBits {
+			continue
+		}
+		best, bestBits = &routes[i], prefix.Bits()
+	}
+	return best
+}
@@ -0,0 +1,82 @@
+package aws
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CheckNetworkPath(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	controller := netip.MustParseAddr("192.168.10.5")
+	route := func(destination, gateway string, state types.RouteState) types.Route {
+		route := types.Route{DestinationCidrBlock: aws.String(destination), State: state}
+		if gateway[:4] == "tgw-" {
+			route.TransitGatewayId = aws.String(gateway)
+		} else {
+			route.GatewayId = aws.String(gateway)
+		}
+		return route
+	}
+	tunnels := func(statuses ...types.TelemetryStatus) []types.VgwTelemetry {
+		var telemetry []types.VgwTelemetry
+		for _, status := range statuses {
+			telemetry = append(telemetry, types.VgwTelemetry{Status: status})
+		}
+		return telemetry
+	}
+	check := func() []NetworkPathProblem {
+		problems, err := client.CheckNetworkPath(context.Background(), "aws", "cpu", controller)
+		require.NoError(t, err)
+		return problems
+	}
+
+	// The main route table serves subnets without their own; the most specific route wins
+	ec2.routeTables = []types.RouteTable{{
+		RouteTableId: aws.String("rtb-main"),
+		VpcId:        aws.String("vpc-1"),
+		Associations: []types.RouteTableAssociation{{Main: aws.Bool(true)}},
+		Routes: []types.Route{
+			route("10.0.0.0/16", "local", types.RouteStateActive),
+			route("0.0.0.0/0", "igw-1", types.RouteStateActive),
+			route("192.168.0.0/16", "vgw-1", types.RouteStateActive),
+		},
+	}}
+	ec2.vpnConnections = []types.VpnConnection{{VpnConnectionId: aws.String("vpn-1"), State: types.VpnStateAvailable,
+		VgwTelemetry: tunnels(types.TelemetryStatusDown, types.TelemetryStatusUp)}}
+	assert.Empty(t, check(), "one tunnel is enough")
+
+	ec2.vpnConnections[0].VgwTelemetry = tunnels(types.TelemetryStatusDown, types.TelemetryStatusDown)
+	problems := check()
+	require.Len(t, problems, 1)
+	assert.Equal(t, "subnet-a: route 192.168.0.0/16 through vgw-1: no VPN tunnel is up (vpn-1)", problems[0].String())
+
+	// A gateway without VPN connections carries Direct Connect
+	ec2.vpnConnections = nil
+	assert.Empty(t, check())
+
+	ec2.routeTables[0].Routes[2].State = types.RouteStateBlackhole
+	require.Len(t, check(), 1)
+	assert.Contains(t, check()[0].Reason, "blackhole")
+
+	// The subnet's own table has only the local route
+	ec2.routeTables = append(ec2.routeTables, types.RouteTable{
+		RouteTableId: aws.String("rtb-a"),
+		VpcId:        aws.String("vpc-1"),
+		Associations: []types.RouteTableAssociation{{SubnetId: aws.String("subnet-a")}},
+		Routes:       []types.Route{route("10.0.0.0/16", "local", types.RouteStateActive)},
+	})
+	require.Len(t, check(), 1)
+	assert.Equal(t, "route table rtb-a has no route to 192.168.10.5", check()[0].Reason)
+
+	ec2.routeTables[1].Routes = append(ec2.routeTables[1].Routes, route("192.168.10.0/24", "tgw-1", types.RouteStateActive))
+	require.Len(t, check(), 1)
+	assert.Contains(t, check()[0].Reason, "tgw-1 has no available attachment to vpc-1")
+	ec2.tgwAttachments = []types.TransitGatewayVpcAttachment{{State: types.TransitGatewayAttachmentStateAvailable}}
+	assert.Empty(t, check())
+}
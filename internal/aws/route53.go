@@ -0,0 +1,158 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// route53MaxChanges keeps a change batch under Route 53's limit of 1000
+// records, which counts an UPSERT twice
+const route53MaxChanges = 500
+
+// Route53API is the part of the Route 53 client the DNS registrar calls;
+// tests substitute a fake
+type Route53API interface {
+	ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error)
+	ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error)
+}
+
+var _ Route53API = (*route53.Client)(nil)
+
+// DNSRegistrar keeps an A record for each burst node in a Route 53 private
+// hosted zone
+type DNSRegistrar struct {
+	logger *zap.Logger
+	config *burstConfig.DNSConfig
+	api    Route53API
+}
+
+// NewDNSRegistrar creates a registrar for the configured hosted zone. Route 53
+// is global; the region only selects its partition.
+func NewDNSRegistrar(ctx context.Context, logger *zap.Logger, awsConfig *burstConfig.AWSConfig, dnsConfig *burstConfig.DNSConfig) (*DNSRegistrar, error) {
+	cfg, err := LoadConfig(ctx, logger, awsConfig, awsConfig.Region)
+	if err != nil {
+		return nil, err
+	}
+	return newDNSRegistrar(logger, dnsConfig, route53.NewFromConfig(cfg)), nil
+}
+
+func newDNSRegistrar(logger *zap.Logger, dnsConfig *burstConfig.DNSConfig, api Route53API) *DNSRegistrar {
+	return &DNSRegistrar{
+		logger: logger,
+		config: dnsConfig,
+		api:    api,
+	}
+}
+
+// Register points each instance's node record at its private IP, replacing
+// the record of an earlier instance
+func (d *DNSRegistrar) Register(ctx context.Context, instances []types.InstanceInfo) error {
+	var changes []route53Types.Change
+	for _, instance := range instances {
+		if instance.PrivateIP == "" {
+			continue
+		}
+		changes = append(changes, route53Types.Change{
+			Action: route53Types.ChangeActionUpsert,
+			ResourceRecordSet: &route53Types.ResourceRecordSet{
+				Name:            aws.String(d.config.RecordName(instance.NodeName)),
+				Type:            route53Types.RRTypeA,
+				TTL:             aws.Int64(d.config.TTL),
+				ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String(instance.PrivateIP)}},
+			},
+		})
+	}
+	if err := d.change(ctx, changes); err != nil {
+		return err
+	}
+	d.logger.Info("Registered burst nodes in DNS",
+		zap.String("hosted_zone_id", d.config.HostedZoneID),
+		zap.String("domain", d.config.Domain),
+		zap.Int("records", len(changes)))
+	return nil
+}
+
+// Deregister deletes the nodes' records. Route 53 only deletes a record given
+// its current value, so the domain's records are listed first; nodes without
+// one are skipped.
+func (d *DNSRegistrar) Deregister(ctx context.Context, nodeNames []string) error {
+	wanted := make(map[string]bool, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		wanted[d.config.RecordName(nodeName)] = true
+	}
+	records, err := d.listRecords(ctx)
+	if err != nil {
+		return err
+	}
+
+	var changes []route53Types.Change
+	for _, record := range records {
+		if record.Type == route53Types.RRTypeA && wanted[aws.ToString(record.Name)] {
+			changes = append(changes, route53Types.Change{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &record})
+		}
+	}
+	if err := d.change(ctx, changes); err != nil {
+		return err
+	}
+	d.logger.Info("Removed burst nodes from DNS",
+		zap.String("hosted_zone_id", d.config.HostedZoneID),
+		zap.Int("records", len(changes)))
+	return nil
+}
+
+// change applies changes in batches Route 53 accepts
+func (d *DNSRegistrar) change(ctx context.Context, changes []route53Types.Change) error {
+	for start := 0; start < len(changes); start += route53MaxChanges {
+		batch := changes[start:min(start+route53MaxChanges, len(changes))]
+		_, err := d.api.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(d.config.HostedZoneID),
+			ChangeBatch:  &route53Types.ChangeBatch{Comment: aws.String("aws-slurm-burst"), Changes: batch},
+		})
+		// Route 53 explains a rejected batch in messages the error text leaves out
+		var invalid *route53Types.InvalidChangeBatch
+		if errors.As(err, &invalid) && len(invalid.Messages) > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.Join(invalid.Messages, "; "))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to change records in hosted zone %s: %w", d.config.HostedZoneID, err)
+		}
+	}
+	return nil
+}
+
+// listRecords lists the records at and under the domain. Route 53 orders
+// records by their labels from the right, so they follow the domain itself.
+func (d *DNSRegistrar) listRecords(ctx context.Context) ([]route53Types.ResourceRecordSet, error) {
+	suffix := "." + d.config.Domain + "."
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(d.config.HostedZoneID),
+		StartRecordName: aws.String(d.config.Domain + "."),
+	}
+	var records []route53Types.ResourceRecordSet
+	for {
+		page, err := d.api.ListResourceRecordSets(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list records in hosted zone %s: %w", d.config.HostedZoneID, err)
+		}
+		for _, record := range page.ResourceRecordSets {
+			name := aws.ToString(record.Name)
+			if name != d.config.Domain+"." && !strings.HasSuffix(name, suffix) {
+				return records, nil
+			}
+			records = append(records, record)
+		}
+		if !page.IsTruncated {
+			return records, nil
+		}
+		input.StartRecordName, input.StartRecordType = page.NextRecordName, page.NextRecordType
+	}
+}
@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeRoute53 serves a hosted zone's records two to a page
+type fakeRoute53 struct {
+	records []route53Types.ResourceRecordSet
+	changes []route53Types.Change
+}
+
+func (f *fakeRoute53) ChangeResourceRecordSets(ctx context.Context, params *route53.ChangeResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ChangeResourceRecordSetsOutput, error) {
+	if aws.ToString(params.HostedZoneId) != "Z123" {
+		return nil, &route53Types.NoSuchHostedZone{Message: aws.String("no such zone")}
+	}
+	for _, change := range params.ChangeBatch.Changes {
+		if change.Action == route53Types.ChangeActionDelete && aws.ToString(change.ResourceRecordSet.ResourceRecords[0].Value) == "10.0.0.99" {
+			return nil, &route53Types.InvalidChangeBatch{Messages: []string{"Tried to delete resource record set but it was not found"}}
+		}
+	}
+	f.changes = append(f.changes, params.ChangeBatch.Changes...)
+	return &route53.ChangeResourceRecordSetsOutput{}, nil
+}
+
+func (f *fakeRoute53) ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput, optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error) {
+	start := 0
+	for start < len(f.records) && aws.ToString(f.records[start].Name) != aws.ToString(params.StartRecordName) {
+		start++
+	}
+	page := &route53.ListResourceRecordSetsOutput{ResourceRecordSets: f.records[start:min(start+2, len(f.records))]}
+	if start+2 < len(f.records) {
+		page.IsTruncated, page.NextRecordName, page.NextRecordType = true, f.records[start+2].Name, f.records[start+2].Type
+	}
+	return page, nil
+}
+
+func TestDNSRegistrar(t *testing.T) {
+	a := func(name, ip string) route53Types.ResourceRecordSet {
+		return route53Types.ResourceRecordSet{
+			Name: aws.String(name), Type: route53Types.RRTypeA, TTL: aws.Int64(60),
+			ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String(ip)}},
+		}
+	}
+	route53 := &fakeRoute53{records: []route53Types.ResourceRecordSet{
+		{Name: aws.String("burst.cluster.internal."), Type: route53Types.RRTypeSoa, TTL: aws.Int64(900),
+			ResourceRecords: []route53Types.ResourceRecord{{Value: aws.String("ns-1.awsdns-01.org. admin. 1 7200 900 1209600 86400")}}},
+		a("aws-cpu-001.burst.cluster.internal.", "10.0.0.11"),
+		a("aws-cpu-002.burst.cluster.internal.", "10.0.0.12"),
+		a("aws-cpu-003.burst.cluster.internal.", "10.0.0.13"),
+		a("login.cluster.internal.", "10.0.1.5"),
+	}}
+
+	dnsConfig := &config.DNSConfig{HostedZoneID: "Z123", Domain: "burst.cluster.internal", TTL: 30}
+	registrar := newDNSRegistrar(zap.NewNop(), dnsConfig, route53)
+
+	require.NoError(t, registrar.Register(context.Background(), []types.InstanceInfo{
+		{NodeName: "aws-cpu-001", PrivateIP: "10.0.0.21"},
+		{NodeName: "aws-cpu-004"}, // No address yet
+	}))
+	upserted := a("aws-cpu-001.burst.cluster.internal.", "10.0.0.21")
+	upserted.TTL = aws.Int64(30)
+	assert.Equal(t, []route53Types.Change{{Action: route53Types.ChangeActionUpsert, ResourceRecordSet: &upserted}}, route53.changes)
+
+	// The listing pages through the domain and stops at the login node's record
+	route53.changes = nil
+	require.NoError(t, registrar.Deregister(context.Background(), []string{"aws-cpu-003", "aws-cpu-002", "aws-cpu-009"}))
+	deleted := []route53Types.ResourceRecordSet{
+		a("aws-cpu-002.burst.cluster.internal.", "10.0.0.12"),
+		a("aws-cpu-003.burst.cluster.internal.", "10.0.0.13"),
+	}
+	assert.Equal(t, []route53Types.Change{
+		{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &deleted[0]},
+		{Action: route53Types.ChangeActionDelete, ResourceRecordSet: &deleted[1]},
+	}, route53.changes)
+
+	route53.records[1] = a("aws-cpu-001.burst.cluster.internal.", "10.0.0.99")
+	err := registrar.Deregister(context.Background(), []string{"aws-cpu-001"})
+	var invalid *route53Types.InvalidChangeBatch
+	assert.ErrorAs(t, err, &invalid)
+	assert.ErrorContains(t, err, "Tried to delete resource record set but it was not found")
+
+	dnsConfig.HostedZoneID = "Z999"
+	assert.ErrorContains(t, registrar.Register(context.Background(), []types.InstanceInfo{{NodeName: "aws-cpu-001", PrivateIP: "10.0.0.21"}}),
+		"failed to change records in hosted zone Z999")
+
+	// Nothing to change sends no request
+	route53.changes = nil
+	require.NoError(t, registrar.Register(context.Background(), nil))
+	assert.Empty(t, route53.changes)
+}
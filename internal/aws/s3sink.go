@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"go.uber.org/zap"
+)
+
+// S3Sink uploads exported performance and cost records to a central bucket so many
+// clusters can share learning data without NFS mounts
+type S3Sink struct {
+	logger   *zap.Logger
+	uploader *manager.Uploader
+	bucket   string
+	prefix   string
+	cluster  string
+	kmsKeyID string
+}
+
+// IsS3URL reports whether an output destination is an s3:// URL
+func IsS3URL(output string) bool {
+	return strings.HasPrefix(output, "s3://")
+}
+
+// ParseS3URL splits s3://bucket/prefix into bucket and prefix (without slashes)
+func ParseS3URL(output string) (bucket, prefix string, err error) {
+	if !IsS3URL(output) {
+		return "", "", fmt.Errorf("not an s3:// URL: %s", output)
+	}
+	bucket, prefix, _ = strings.Cut(strings.TrimPrefix(output, "s3://"), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("missing bucket in %s", output)
+	}
+	return bucket, strings.Trim(prefix, "/"), nil
+}
+
+// NewS3Sink creates a sink for output, an s3://bucket/prefix URL
+func NewS3Sink(ctx context.Context, logger *zap.Logger, awsConfig *burstConfig.AWSConfig, exportConfig *burstConfig.ExportConfig, output string) (*S3Sink, error) {
+	bucket, prefix, err := ParseS3URL(output)
+	if err != nil {
+		return nil, err
+	}
+
+	region := exportConfig.Region
+	if region == "" {
+		region = awsConfig.Region
+	}
+	cfg, err := NewAuthenticationProvider(logger, authenticationConfigFor(awsConfig)).GetAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure AWS authentication: %w", err)
+	}
+
+	cluster := exportConfig.Cluster
+	if cluster == "" {
+		if cluster, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("failed to determine cluster name: %w", err)
+		}
+	}
+
+	return &S3Sink{
+		logger:   logger,
+		uploader: manager.NewUploader(s3.NewFromConfig(cfg)),
+		bucket:   bucket,
+		prefix:   prefix,
+		cluster:  cluster,
+		kmsKeyID: exportConfig.KMSKeyID,
+	}, nil
+}
+
+// Key returns the object key for a record: Hive-style cluster and date partitions
+// under a per-format prefix, so each format can be its own Athena/Glue table
+func (s *S3Sink) Key(format, name string, date time.Time) string {
+	date = date.UTC()
+	return path.Join(s.prefix, format,
+		"cluster="+s.cluster,
+		fmt.Sprintf("year=%04d", date.Year()),
+		fmt.Sprintf("month=%02d", date.Month()),
+		fmt.Sprintf("day=%02d", date.Day()),
+		name)
+}
+
+// Upload writes data to key with SSE-KMS. Large objects are sent as multipart uploads.
+func (s *S3Sink) Upload(ctx context.Context, key string, data []byte) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(key),
+		Body:                 bytes.NewReader(data),
+		ServerSideEncryption: s3types.ServerSideEncryptionAwsKms,
+	}
+	if s.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s.kmsKeyID)
+	}
+
+	if _, err := s.uploader.Upload(ctx, input); err != nil {
+		return "", fmt.Errorf("failed to upload s3://%s/%s: %w", s.bucket, key, err)
+	}
+
+	location := fmt.Sprintf("s3://%s/%s", s.bucket, key)
+	s.logger.Info("Uploaded export to S3",
+		zap.String("location", location),
+		zap.Int("size_bytes", len(data)))
+	return location, nil
+}
@@ -0,0 +1,40 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseS3URL(t *testing.T) {
+	bucket, prefix, err := ParseS3URL("s3://learning-bucket/clusters/hpc/")
+	require.NoError(t, err)
+	assert.Equal(t, "learning-bucket", bucket)
+	assert.Equal(t, "clusters/hpc", prefix)
+
+	bucket, prefix, err = ParseS3URL("s3://learning-bucket")
+	require.NoError(t, err)
+	assert.Equal(t, "learning-bucket", bucket)
+	assert.Empty(t, prefix)
+
+	_, _, err = ParseS3URL("s3:///prefix")
+	assert.Error(t, err)
+	_, _, err = ParseS3URL("/var/spool/asba/learning")
+	assert.Error(t, err)
+}
+
+func TestS3SinkKey(t *testing.T) {
+	sink := &S3Sink{prefix: "learning", cluster: "hpc1"}
+	date := time.Date(2026, 3, 5, 23, 30, 0, 0, time.FixedZone("EST", -5*3600))
+
+	assert.Equal(t,
+		"learning/parquet/cluster=hpc1/year=2026/month=03/day=06/job-42-performance.parquet",
+		sink.Key("parquet", "job-42-performance.parquet", date))
+
+	sink.prefix = ""
+	assert.Equal(t,
+		"asbb-reconciliation/cluster=hpc1/year=2026/month=03/day=06/job-42-asbb-reconciliation.json",
+		sink.Key("asbb-reconciliation", "job-42-asbb-reconciliation.json", date))
+}
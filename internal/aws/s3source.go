@@ -0,0 +1,87 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"go.uber.org/zap"
+)
+
+// S3Source reads the objects burst nodes write under an s3:// prefix, such as
+// node metrics summaries
+type S3Source struct {
+	logger *zap.Logger
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Source creates a source for input, an s3://bucket/prefix URL. The bucket
+// region is taken from the export settings, as for S3Sink.
+func NewS3Source(ctx context.Context, logger *zap.Logger, awsConfig *burstConfig.AWSConfig, exportConfig *burstConfig.ExportConfig, input string) (*S3Source, error) {
+	bucket, prefix, err := ParseS3URL(input)
+	if err != nil {
+		return nil, err
+	}
+
+	region := exportConfig.Region
+	if region == "" {
+		region = awsConfig.Region
+	}
+	cfg, err := NewAuthenticationProvider(logger, authenticationConfigFor(awsConfig)).GetAWSConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure AWS authentication: %w", err)
+	}
+
+	return &S3Source{
+		logger: logger,
+		client: s3.NewFromConfig(cfg),
+		bucket: bucket,
+		prefix: prefix,
+	}, nil
+}
+
+// ReadAll returns every object under dir, relative to the source prefix, keyed by
+// object name within dir
+func (s *S3Source) ReadAll(ctx context.Context, dir string) (map[string][]byte, error) {
+	prefix := path.Join(s.prefix, dir) + "/"
+	objects := make(map[string][]byte)
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", s.bucket, prefix, err)
+		}
+
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+				Bucket: aws.String(s.bucket),
+				Key:    aws.String(key),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read s3://%s/%s: %w", s.bucket, key, err)
+			}
+			data, err := io.ReadAll(result.Body)
+			result.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read s3://%s/%s: %w", s.bucket, key, err)
+			}
+			objects[path.Base(key)] = data
+		}
+	}
+
+	s.logger.Debug("Read objects from S3",
+		zap.String("location", fmt.Sprintf("s3://%s/%s", s.bucket, prefix)),
+		zap.Int("objects", len(objects)))
+	return objects, nil
+}
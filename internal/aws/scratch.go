@@ -0,0 +1,200 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.uber.org/zap"
+)
+
+// Tags on scratch volumes kept past their instance: the job they served, how
+// long to keep them, and when the state manager first saw them detached
+const (
+	ScratchJobTag       = "aws-slurm-burst:scratch-job"
+	ScratchRetentionTag = "aws-slurm-burst:retain-hours"
+	ScratchReleasedTag  = "aws-slurm-burst:released-at"
+)
+
+// ScratchVolume is an EBS volume added to each instance of a launch for a
+// job's burst buffer directives
+type ScratchVolume struct {
+	DeviceName  string
+	VolumeType  string
+	SizeGB      int
+	RetainHours int // Keep the volume this long after its instance terminates; zero deletes it with the instance
+}
+
+// scratchBlockDevices returns the launch template's block device mappings with
+// the scratch volume in place of any mapping on its device. EC2 replaces a
+// version's mappings as a whole, so the template's own are carried over.
+func scratchBlockDevices(source *ec2types.ResponseLaunchTemplateData, scratch *ScratchVolume) []ec2types.LaunchTemplateBlockDeviceMappingRequest {
+	var mappings []ec2types.LaunchTemplateBlockDeviceMappingRequest
+	if source != nil {
+		for _, mapping := range source.BlockDeviceMappings {
+			if aws.ToString(mapping.DeviceName) == scratch.DeviceName {
+				continue
+			}
+			request := ec2types.LaunchTemplateBlockDeviceMappingRequest{
+				DeviceName:  mapping.DeviceName,
+				NoDevice:    mapping.NoDevice,
+				VirtualName: mapping.VirtualName,
+			}
+			if ebs := mapping.Ebs; ebs != nil {
+				request.Ebs = &ec2types.LaunchTemplateEbsBlockDeviceRequest{
+					DeleteOnTermination:      ebs.DeleteOnTermination,
+					Encrypted:                ebs.Encrypted,
+					Iops:                     ebs.Iops,
+					KmsKeyId:                 ebs.KmsKeyId,
+					SnapshotId:               ebs.SnapshotId,
+					Throughput:               ebs.Throughput,
+					VolumeInitializationRate: ebs.VolumeInitializationRate,
+					VolumeSize:               ebs.VolumeSize,
+					VolumeType:               ebs.VolumeType,
+				}
+			}
+			mappings = append(mappings, request)
+		}
+	}
+
+	return append(mappings, ec2types.LaunchTemplateBlockDeviceMappingRequest{
+		DeviceName: aws.String(scratch.DeviceName),
+		Ebs: &ec2types.LaunchTemplateEbsBlockDeviceRequest{
+			DeleteOnTermination: aws.Bool(scratch.RetainHours == 0),
+			Encrypted:           aws.Bool(true),
+			VolumeSize:          aws.Int32(int32(scratch.SizeGB)),
+			VolumeType:          ec2types.VolumeType(scratch.VolumeType),
+		},
+	})
+}
+
+// scratchTagSpecifications returns the launch template's tag specifications
+// with the retention tags added to its volumes, so the state manager finds
+// retained scratch volumes once their instance is gone
+func scratchTagSpecifications(source *ec2types.ResponseLaunchTemplateData, scratch *ScratchVolume, jobID string) []ec2types.LaunchTemplateTagSpecificationRequest {
+	retention := []ec2types.Tag{
+		{Key: aws.String(ScratchJobTag), Value: aws.String(jobID)},
+		{Key: aws.String(ScratchRetentionTag), Value: aws.String(strconv.Itoa(scratch.RetainHours))},
+	}
+
+	var specs []ec2types.LaunchTemplateTagSpecificationRequest
+	tagged := false
+	if source != nil {
+		for _, spec := range source.TagSpecifications {
+			tags := slices.Clone(spec.Tags)
+			if spec.ResourceType == ec2types.ResourceTypeVolume {
+				tags = append(tags, retention...)
+				tagged = true
+			}
+			specs = append(specs, ec2types.LaunchTemplateTagSpecificationRequest{ResourceType: spec.ResourceType, Tags: tags})
+		}
+	}
+	if !tagged {
+		specs = append(specs, ec2types.LaunchTemplateTagSpecificationRequest{ResourceType: ec2types.ResourceTypeVolume, Tags: retention})
+	}
+	return specs
+}
+
+// ExpireScratchVolumes deletes retained scratch volumes whose retention has
+// passed. A volume's retention starts when it is first seen detached, which
+// is recorded in a tag; it returns the volumes deleted.
+func (c *Client) ExpireScratchVolumes(ctx context.Context, now time.Time, dryRun bool) ([]string, error) {
+	regions := []string{c.config.Region}
+	for _, partition := range c.appConfig.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			if region := c.nodeGroupRegion(partition.PartitionName, nodeGroup.NodeGroupName); !slices.Contains(regions, region) {
+				regions = append(regions, region)
+			}
+		}
+	}
+
+	var expired []string
+	var failed []string
+	for _, region := range regions {
+		fleetManager, err := c.fleetManagerFor(region)
+		if err == nil {
+			var deleted []string
+			deleted, err = fleetManager.expireScratchVolumes(ctx, now, dryRun)
+			expired = append(expired, deleted...)
+		}
+		if err != nil {
+			c.logger.Error("Failed to expire scratch volumes", zap.String("region", region), zap.Error(err))
+			failed = append(failed, region)
+		}
+	}
+
+	if len(failed) > 0 {
+		return expired, fmt.Errorf("failed to expire scratch volumes: %s", strings.Join(failed, ", "))
+	}
+	return expired, nil
+}
+
+// expireScratchVolumes runs ExpireScratchVolumes in the fleet manager's region
+func (f *FleetManager) expireScratchVolumes(ctx context.Context, now time.Time, dryRun bool) ([]string, error) {
+	input := &ec2.DescribeVolumesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("tag-key"), Values: []string{ScratchRetentionTag}},
+			{Name: aws.String("status"), Values: []string{string(ec2types.VolumeStateAvailable)}},
+		},
+	}
+
+	var expired []string
+	for {
+		output, err := f.ec2Client.DescribeVolumes(ctx, input)
+		if err != nil {
+			return expired, fmt.Errorf("failed to describe volumes: %w", err)
+		}
+		for _, volume := range output.Volumes {
+			volumeID := aws.ToString(volume.VolumeId)
+			tags := make(map[string]string)
+			for _, tag := range volume.Tags {
+				tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+			hours, err := strconv.Atoi(tags[ScratchRetentionTag])
+			if err != nil {
+				f.logger.Warn("Scratch volume has an invalid retention tag", zap.String("volume_id", volumeID), zap.String("retain_hours", tags[ScratchRetentionTag]))
+				continue
+			}
+
+			released, err := time.Parse(time.RFC3339, tags[ScratchReleasedTag])
+			if err != nil {
+				if dryRun {
+					continue
+				}
+				_, err := f.ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{
+					Resources: []string{volumeID},
+					Tags:      []ec2types.Tag{{Key: aws.String(ScratchReleasedTag), Value: aws.String(now.UTC().Format(time.RFC3339))}},
+				})
+				if err != nil {
+					return expired, fmt.Errorf("failed to tag scratch volume %s: %w", volumeID, err)
+				}
+				continue
+			}
+			if now.Before(released.Add(time.Duration(hours) * time.Hour)) {
+				continue
+			}
+
+			expired = append(expired, volumeID)
+			if dryRun {
+				continue
+			}
+			if _, err := f.ec2Client.DeleteVolume(ctx, &ec2.DeleteVolumeInput{VolumeId: aws.String(volumeID)}); err != nil {
+				return expired[:len(expired)-1], fmt.Errorf("failed to delete scratch volume %s: %w", volumeID, err)
+			}
+			f.logger.Info("Deleted expired scratch volume",
+				zap.String("volume_id", volumeID),
+				zap.String("job_id", tags[ScratchJobTag]),
+				zap.Int("retain_hours", hours))
+		}
+		if aws.ToString(output.NextToken) == "" {
+			return expired, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
@@ -0,0 +1,93 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func TestClient_LaunchInstances_Scratch(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	ec2.templateData = &types.ResponseLaunchTemplateData{
+		BlockDeviceMappings: []types.LaunchTemplateBlockDeviceMapping{
+			{DeviceName: aws.String("/dev/xvda"), Ebs: &types.LaunchTemplateEbsBlockDevice{VolumeSize: aws.Int32(50), VolumeType: types.VolumeTypeGp3}},
+			{DeviceName: aws.String("/dev/sdz"), Ebs: &types.LaunchTemplateEbsBlockDevice{VolumeSize: aws.Int32(10)}},
+		},
+		TagSpecifications: []types.LaunchTemplateTagSpecification{
+			{ResourceType: types.ResourceTypeVolume, Tags: []types.Tag{{Key: aws.String("Team"), Value: aws.String("cfd")}}},
+		},
+	}
+
+	result, err := client.LaunchInstances(context.Background(), &LaunchRequest{
+		NodeIds:              []string{"aws-cpu-1", "aws-cpu-2"},
+		Partition:            "aws",
+		NodeGroup:            "cpu",
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}},
+		Job:                  &burstTypes.SlurmJob{JobID: "42"},
+		UserDataParts:        []userdata.Part{userdata.NewPart(userdata.StagingFilename, "#!/bin/bash\n")},
+		Scratch:              &ScratchVolume{DeviceName: "/dev/sdz", VolumeType: "gp3", SizeGB: 200, RetainHours: 24},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, ec2.versions, 1)
+	data := ec2.versions[0].LaunchTemplateData
+	require.Len(t, data.BlockDeviceMappings, 2, "the scratch volume replaces the template's mapping on its device")
+	assert.Equal(t, "/dev/xvda", aws.ToString(data.BlockDeviceMappings[0].DeviceName))
+	assert.Equal(t, int32(50), aws.ToInt32(data.BlockDeviceMappings[0].Ebs.VolumeSize))
+	scratch := data.BlockDeviceMappings[1]
+	assert.Equal(t, "/dev/sdz", aws.ToString(scratch.DeviceName))
+	assert.Equal(t, int32(200), aws.ToInt32(scratch.Ebs.VolumeSize))
+	assert.False(t, aws.ToBool(scratch.Ebs.DeleteOnTermination), "retained")
+
+	require.Len(t, data.TagSpecifications, 1)
+	assert.Equal(t, []types.Tag{
+		{Key: aws.String("Team"), Value: aws.String("cfd")},
+		{Key: aws.String(ScratchJobTag), Value: aws.String("42")},
+		{Key: aws.String(ScratchRetentionTag), Value: aws.String("24")},
+	}, data.TagSpecifications[0].Tags)
+
+	require.Len(t, result.Instances, 2)
+	for _, instance := range result.Instances {
+		assert.Contains(t, instance.Volumes, burstTypes.EBSVolume{DeviceName: "/dev/sdz", VolumeType: "gp3", SizeGB: 200}, "charged for")
+	}
+}
+
+func TestClient_ExpireScratchVolumes(t *testing.T) {
+	client, ec2 := newFakeEC2Client(t)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	volume := func(id string, state types.VolumeState, tags ...string) types.Volume {
+		v := types.Volume{VolumeId: aws.String(id), State: state}
+		for i := 0; i < len(tags); i += 2 {
+			v.Tags = append(v.Tags, types.Tag{Key: aws.String(tags[i]), Value: aws.String(tags[i+1])})
+		}
+		return v
+	}
+	ec2.volumes = []types.Volume{
+		volume("vol-new", types.VolumeStateAvailable, ScratchRetentionTag, "24"),
+		volume("vol-kept", types.VolumeStateAvailable, ScratchRetentionTag, "24", ScratchReleasedTag, now.Add(-23*time.Hour).Format(time.RFC3339)),
+		volume("vol-expired", types.VolumeStateAvailable, ScratchRetentionTag, "24", ScratchReleasedTag, now.Add(-25*time.Hour).Format(time.RFC3339)),
+		volume("vol-attached", types.VolumeStateInUse, ScratchRetentionTag, "0"),
+		volume("vol-other", types.VolumeStateAvailable),
+	}
+
+	expired, err := client.ExpireScratchVolumes(context.Background(), now, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vol-expired"}, expired)
+	assert.Empty(t, ec2.deletedVolumes, "dry run")
+	assert.Len(t, ec2.volumes[0].Tags, 1, "dry run")
+
+	expired, err = client.ExpireScratchVolumes(context.Background(), now, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vol-expired"}, expired)
+	assert.Equal(t, []string{"vol-expired"}, ec2.deletedVolumes)
+	assert.Contains(t, ec2.volumes[0].Tags, types.Tag{Key: aws.String(ScratchReleasedTag), Value: aws.String(now.Format(time.RFC3339))}, "retention starts")
+	assert.Len(t, ec2.volumes, 4)
+}
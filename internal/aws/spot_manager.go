@@ -14,12 +14,12 @@ import (
 // SpotManager handles spot instance lifecycle and interruption management
 type SpotManager struct {
 	logger    *zap.Logger
-	ec2Client *ec2.Client
+	ec2Client EC2API
 	region    string
 }
 
 // NewSpotManager creates a new spot instance manager
-func NewSpotManager(logger *zap.Logger, ec2Client *ec2.Client, region string) *SpotManager {
+func NewSpotManager(logger *zap.Logger, ec2Client EC2API, region string) *SpotManager {
 	return &SpotManager{
 		logger:    logger,
 		ec2Client: ec2Client,
@@ -33,6 +33,7 @@ type SpotPricingStrategy struct {
 	AllowMixedPricing   bool    `json:"allow_mixed_pricing"`
 	SpotAllocationRatio float64 `json:"spot_allocation_ratio"` // 0.0-1.0, percentage of instances to launch as spot
 	OnDemandFallback    bool    `json:"on_demand_fallback"`    // Fallback to on-demand if spot unavailable
+	BudgetFallback      bool    `json:"budget_fallback"`       // Spot prices exceeded MaxSpotPrice, so all capacity is on-demand
 }
 
 // OptimizeSpotStrategy determines optimal spot instance strategy for a job
@@ -100,10 +101,16 @@ func (s *SpotManager) GetCurrentSpotPrices(ctx context.Context, instanceTypes []
 		instanceTypeEnums = append(instanceTypeEnums, types.InstanceType(instanceType))
 	}
 
+	// A start time of now returns only the current price in each AZ
 	input := &ec2.DescribeSpotPriceHistoryInput{
 		InstanceTypes:       instanceTypeEnums,
 		ProductDescriptions: []string{"Linux/UNIX"},
-		MaxResults:          aws.Int32(100),
+		StartTime:           aws.Time(time.Now()),
+	}
+	if len(availabilityZones) > 0 {
+		input.Filters = []types.Filter{
+			{Name: aws.String("availability-zone"), Values: availabilityZones},
+		}
 	}
 
 	result, err := s.ec2Client.DescribeSpotPriceHistory(ctx, input)
@@ -111,7 +118,7 @@ func (s *SpotManager) GetCurrentSpotPrices(ctx context.Context, instanceTypes []
 		return nil, fmt.Errorf("failed to get spot prices: %w", err)
 	}
 
-	// Get most recent price for each instance type
+	// Keep the lowest current price for each instance type across AZs
 	priceMap := make(map[string]float64)
 	for _, price := range result.SpotPriceHistory {
 		instanceType := string(price.InstanceType)
@@ -119,9 +126,12 @@ func (s *SpotManager) GetCurrentSpotPrices(ctx context.Context, instanceTypes []
 		if price.SpotPrice != nil {
 			if _, err := fmt.Sscanf(aws.ToString(price.SpotPrice), "%f", &spotPrice); err != nil {
 				s.logger.Debug("Failed to parse spot price", zap.String("price", aws.ToString(price.SpotPrice)))
+				continue
 			}
 		}
-		priceMap[instanceType] = spotPrice
+		if current, ok := priceMap[instanceType]; !ok || spotPrice < current {
+			priceMap[instanceType] = spotPrice
+		}
 	}
 
 	s.logger.Debug("Retrieved current spot prices",
@@ -131,9 +141,11 @@ func (s *SpotManager) GetCurrentSpotPrices(ctx context.Context, instanceTypes []
 	return priceMap, nil
 }
 
-// ValidateSpotPricing checks if spot pricing is within acceptable limits
+// ValidateSpotPricing checks if spot pricing is within acceptable limits. When no
+// instance type is within MaxSpotPrice, the strategy falls back to all on-demand
+// if it allows that, and an error is returned otherwise.
 func (s *SpotManager) ValidateSpotPricing(ctx context.Context, req *FleetRequest, strategy *SpotPricingStrategy) error {
-	if !req.InstanceRequirements.PreferSpot {
+	if !req.InstanceRequirements.PreferSpot && !req.InstanceRequirements.AllowMixedPricing {
 		return nil // No validation needed for on-demand
 	}
 
@@ -161,8 +173,15 @@ func (s *SpotManager) ValidateSpotPricing(ctx context.Context, req *FleetRequest
 		}
 	}
 
-	if !withinBudget && !strategy.OnDemandFallback {
-		return fmt.Errorf("no instance types within spot price budget and on-demand fallback disabled")
+	if !withinBudget && len(currentPrices) > 0 {
+		if !strategy.OnDemandFallback {
+			return fmt.Errorf("no instance types within spot price budget $%.4f/hour and on-demand fallback disabled", strategy.MaxSpotPrice)
+		}
+
+		s.logger.Warn("Spot prices exceed budget, launching on-demand",
+			zap.Float64("max_spot_price", strategy.MaxSpotPrice))
+		strategy.SpotAllocationRatio = 0
+		strategy.BudgetFallback = true
 	}
 
 	return nil
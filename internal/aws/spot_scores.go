@@ -0,0 +1,112 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.uber.org/zap"
+)
+
+// spotPlacementScores asks EC2 how likely each AZ is to fulfil the request's spot
+// capacity with the selected instance types. Scores run from 1 (unlikely) to 10
+// (very likely) and are keyed by AZ name.
+func (f *FleetManager) spotPlacementScores(ctx context.Context, req *FleetRequest, instanceTypes []string) (map[string]int, error) {
+	if len(instanceTypes) == 0 {
+		return nil, fmt.Errorf("no instance types to score")
+	}
+
+	result, err := f.ec2Client.GetSpotPlacementScores(ctx, &ec2.GetSpotPlacementScoresInput{
+		InstanceTypes:          instanceTypes,
+		TargetCapacity:         aws.Int32(int32(len(req.NodeIds))),
+		TargetCapacityUnitType: types.TargetCapacityUnitTypeUnits,
+		SingleAvailabilityZone: aws.Bool(true),
+		RegionNames:            []string{f.region},
+		MaxResults:             aws.Int32(10),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spot placement scores: %w", err)
+	}
+
+	// Scores identify zones by ID (use1-az1); overrides and subnets use names (us-east-1a)
+	zoneNames, err := f.availabilityZoneNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]int)
+	for _, score := range result.SpotPlacementScores {
+		if name, ok := zoneNames[aws.ToString(score.AvailabilityZoneId)]; ok {
+			scores[name] = int(aws.ToInt32(score.Score))
+		}
+	}
+
+	f.logger.Debug("Retrieved spot placement scores",
+		zap.Strings("instance_types", instanceTypes),
+		zap.Any("scores", scores))
+
+	return scores, nil
+}
+
+// availabilityZoneNames maps AZ IDs to AZ names for the fleet manager's region
+func (f *FleetManager) availabilityZoneNames(ctx context.Context) (map[string]string, error) {
+	result, err := f.ec2Client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe availability zones: %w", err)
+	}
+
+	names := make(map[string]string, len(result.AvailabilityZones))
+	for _, zone := range result.AvailabilityZones {
+		names[aws.ToString(zone.ZoneId)] = aws.ToString(zone.ZoneName)
+	}
+
+	return names, nil
+}
+
+// scoreSpotPools attaches spot placement scores to a spot-heavy request so
+// overrides and AZ ordering prefer pools likely to have capacity. Scoring is
+// advisory: on failure the request is returned unchanged.
+func (f *FleetManager) scoreSpotPools(ctx context.Context, req *FleetRequest) *FleetRequest {
+	if !f.spotScoresEnabled || !req.InstanceRequirements.PreferSpot {
+		return req
+	}
+
+	scores, err := f.spotPlacementScores(ctx, req, f.selectInstanceTypes(req.InstanceRequirements))
+	if err == nil && len(scores) > 0 {
+		var subnetZones map[string]string
+		if subnetZones, err = f.subnetZones(ctx, req.SubnetIds); err == nil {
+			scored := *req
+			scored.spotScores = scores
+			scored.subnetZones = subnetZones
+			return &scored
+		}
+	}
+
+	if err != nil {
+		f.logger.Warn("Spot placement scores unavailable, using default pool ordering", zap.Error(err))
+	}
+	return req
+}
+
+// prioritizeOverridesBySpotScore orders overrides best-scored AZ first and sets
+// their priority for the capacity-optimized-prioritized allocation strategy
+// (lower priority values are tried first)
+func prioritizeOverridesBySpotScore(overrides []types.FleetLaunchTemplateOverridesRequest, subnetZones map[string]string, scores map[string]int) []types.FleetLaunchTemplateOverridesRequest {
+	scoreOf := func(override types.FleetLaunchTemplateOverridesRequest) int {
+		return scores[subnetZones[aws.ToString(override.SubnetId)]]
+	}
+
+	sort.SliceStable(overrides, func(i, j int) bool {
+		return scoreOf(overrides[i]) > scoreOf(overrides[j])
+	})
+
+	for i := range overrides {
+		// Unscored pools get the lowest priority
+		overrides[i].Priority = aws.Float64(float64(10 - scoreOf(overrides[i])))
+	}
+
+	return overrides
+}
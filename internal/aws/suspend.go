@@ -0,0 +1,284 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"go.uber.org/zap"
+)
+
+// suspendedAtTag records when a node's instance was stopped (RFC3339), marking it
+// as restartable until the idle reaper terminates it
+const suspendedAtTag = "SuspendedAt"
+
+// StopInstances powers down nodes' instances but keeps their EBS volumes (and,
+// when hibernating, memory) so resume can restart them. Spot instances cannot be
+// stopped and are terminated. It returns the nodes with no running instance here.
+func (f *FleetManager) StopInstances(ctx context.Context, nodeNames []string, hibernate bool) ([]string, error) {
+	if len(nodeNames) == 0 {
+		return nil, nil
+	}
+	if err := f.terminateLimiter.Wait(ctx, len(nodeNames)); err != nil {
+		return nil, err
+	}
+
+	instances, err := f.describeNodeInstances(ctx, nodeNames, []string{"pending", "running"}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+	var stop, terminate []string
+	for _, instance := range instances {
+		found[nodeNameOf(instance)] = true
+		if instance.InstanceLifecycle == types.InstanceLifecycleTypeSpot {
+			terminate = append(terminate, aws.ToString(instance.InstanceId))
+		} else {
+			stop = append(stop, aws.ToString(instance.InstanceId))
+		}
+	}
+
+	var missing []string
+	for _, nodeName := range nodeNames {
+		if !found[nodeName] {
+			missing = append(missing, nodeName)
+		}
+	}
+
+	if len(terminate) > 0 {
+		err := retryThrottled(ctx, func() error {
+			_, err := f.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: terminate})
+			return err
+		})
+		if err != nil {
+			return missing, fmt.Errorf("failed to terminate spot instances: %w", err)
+		}
+		f.logger.Info("Terminated spot instances that cannot be stopped", zap.Strings("instance_ids", terminate))
+	}
+
+	if len(stop) == 0 {
+		return missing, nil
+	}
+
+	f.tagInstances(ctx, stop, map[string]string{suspendedAtTag: time.Now().UTC().Format(time.RFC3339)})
+
+	err = retryThrottled(ctx, func() error {
+		_, err := f.ec2Client.StopInstances(ctx, &ec2.StopInstancesInput{InstanceIds: stop, Hibernate: aws.Bool(hibernate)})
+		return err
+	})
+	if err != nil && hibernate && !isThrottled(err) {
+		// Hibernation needs support in the launch template, AMI and instance size
+		f.logger.Warn("Hibernation failed, stopping instances instead", zap.Error(err))
+		err = retryThrottled(ctx, func() error {
+			_, err := f.ec2Client.StopInstances(ctx, &ec2.StopInstancesInput{InstanceIds: stop})
+			return err
+		})
+	}
+	if err != nil {
+		return missing, fmt.Errorf("failed to stop instances: %w", err)
+	}
+
+	f.logger.Info("Instances stop initiated",
+		zap.Strings("instance_ids", stop),
+		zap.Bool("hibernate", hibernate))
+
+	return missing, nil
+}
+
+// RestartSuspendedInstances starts the stopped instances of the request's nodes and
+// returns the nodes still needing a fleet launch. Stopped instances that cannot
+// serve the job (wrong instance type, job-specific user data, or a gang job they
+// cannot fully cover in one AZ) are terminated so they do not linger.
+func (f *FleetManager) RestartSuspendedInstances(ctx context.Context, req *FleetRequest) (*FleetResponse, []string) {
+	stopped, err := f.describeNodeInstances(ctx, req.NodeIds, []string{"stopped"}, true)
+	if err != nil {
+		f.logger.Warn("Failed to find suspended instances, launching fleet", zap.Error(err))
+		return nil, req.NodeIds
+	}
+	if len(stopped) == 0 {
+		return nil, req.NodeIds
+	}
+
+	usable, unusable := f.partitionSuspendedInstances(stopped, req)
+	f.terminateSuspended(ctx, unusable)
+	if len(usable) == 0 {
+		return nil, req.NodeIds
+	}
+
+	var instanceIds []string
+	for _, instance := range usable {
+		instanceIds = append(instanceIds, aws.ToString(instance.InstanceId))
+	}
+
+	f.logger.Info("Restarting suspended instances", zap.Strings("instance_ids", instanceIds))
+
+	if _, err := f.ec2Client.StartInstances(ctx, &ec2.StartInstancesInput{InstanceIds: instanceIds}); err != nil {
+		// Usually InsufficientInstanceCapacity; a fresh fleet can pick other pools
+		f.logger.Warn("Failed to restart suspended instances, launching fleet", zap.Error(err))
+		f.terminateSuspended(ctx, instanceIds)
+		return nil, req.NodeIds
+	}
+
+	if _, err := f.ec2Client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: instanceIds,
+		Tags:      []types.Tag{{Key: aws.String(suspendedAtTag)}},
+	}); err != nil {
+		f.logger.Warn("Failed to clear suspended tag", zap.Error(err))
+	}
+	f.tagInstances(ctx, instanceIds, req.Tags)
+
+	running, err := f.waitRunning(ctx, instanceIds)
+	if err != nil {
+		f.logger.Warn("Suspended instances failed to restart, launching fleet", zap.Error(err))
+		f.terminateSuspended(ctx, instanceIds)
+		return nil, req.NodeIds
+	}
+
+	restarted := make(map[string]bool)
+	response := &FleetResponse{}
+	for _, instance := range running {
+		nodeName := nodeNameOf(instance)
+		restarted[nodeName] = true
+		response.Instances = append(response.Instances, newInstanceInfo(instance, nodeName))
+	}
+
+	var remaining []string
+	for _, nodeName := range req.NodeIds {
+		if !restarted[nodeName] {
+			remaining = append(remaining, nodeName)
+		}
+	}
+
+	return response, remaining
+}
+
+// partitionSuspendedInstances splits stopped instances into those that can serve
+// the request and those that cannot
+func (f *FleetManager) partitionSuspendedInstances(stopped []types.Instance, req *FleetRequest) (usable []types.Instance, unusable []string) {
+	acceptable := make(map[string]bool)
+	for _, instanceType := range f.selectInstanceTypes(req.InstanceRequirements) {
+		acceptable[instanceType] = true
+	}
+
+	seen := make(map[string]bool)
+	zones := make(map[string]bool)
+	for _, instance := range stopped {
+		nodeName := nodeNameOf(instance)
+		fits := len(req.UserDataParts) == 0 && !seen[nodeName] &&
+			(len(acceptable) == 0 || acceptable[string(instance.InstanceType)])
+		if !fits {
+			unusable = append(unusable, aws.ToString(instance.InstanceId))
+			continue
+		}
+		seen[nodeName] = true
+		usable = append(usable, instance)
+		if instance.Placement != nil {
+			zones[aws.ToString(instance.Placement.AvailabilityZone)] = true
+		}
+	}
+
+	// A gang job must not mix restarted and new instances, or span AZs
+	if isGangRequest(req) && (len(usable) < len(req.NodeIds) || len(zones) > 1) {
+		for _, instance := range usable {
+			unusable = append(unusable, aws.ToString(instance.InstanceId))
+		}
+		usable = nil
+	}
+
+	return usable, unusable
+}
+
+// ReapSuspendedInstances terminates a node group's stopped instances once they
+// have been idle longer than idle, when their EBS cost outweighs a faster resume
+func (f *FleetManager) ReapSuspendedInstances(ctx context.Context, partition, nodeGroup string, idle time.Duration, dryRun bool) ([]string, error) {
+	result, err := f.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("tag:Partition"), Values: []string{partition}},
+			{Name: aws.String("tag:NodeGroup"), Values: []string{nodeGroup}},
+			{Name: aws.String("tag-key"), Values: []string{suspendedAtTag}},
+			{Name: aws.String("instance-state-name"), Values: []string{"stopped"}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe suspended instances: %w", err)
+	}
+
+	var expired []string
+	now := time.Now()
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			if suspendedAt, ok := suspendedTime(instance); ok && now.Sub(suspendedAt) > idle {
+				expired = append(expired, aws.ToString(instance.InstanceId))
+			}
+		}
+	}
+
+	if len(expired) == 0 || dryRun {
+		return expired, nil
+	}
+
+	if _, err := f.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: expired}); err != nil {
+		return nil, fmt.Errorf("failed to terminate idle suspended instances: %w", err)
+	}
+
+	return expired, nil
+}
+
+// describeNodeInstances finds the instances tagged with the node names in the given states
+func (f *FleetManager) describeNodeInstances(ctx context.Context, nodeNames, states []string, suspendedOnly bool) ([]types.Instance, error) {
+	filters := []types.Filter{
+		{Name: aws.String("instance-state-name"), Values: states},
+	}
+	if suspendedOnly {
+		filters = append(filters, types.Filter{Name: aws.String("tag-key"), Values: []string{suspendedAtTag}})
+	}
+	return f.describeByNodeNames(ctx, nodeNames, filters)
+}
+
+// terminateSuspended terminates stopped instances that will not be restarted
+func (f *FleetManager) terminateSuspended(ctx context.Context, instanceIds []string) {
+	if len(instanceIds) == 0 {
+		return
+	}
+	f.gangScheduler.cleanupPartialLaunch(ctx, &FleetResponse{Instances: instancesWithIds(instanceIds)})
+}
+
+// nodeNameOf returns the Slurm node name an instance is tagged with
+func nodeNameOf(instance types.Instance) string {
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == "Name" {
+			return aws.ToString(tag.Value)
+		}
+	}
+	return ""
+}
+
+// suspendedTime returns when an instance was suspended
+func suspendedTime(instance types.Instance) (time.Time, bool) {
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == suspendedAtTag {
+			suspendedAt, err := time.Parse(time.RFC3339, aws.ToString(tag.Value))
+			return suspendedAt, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+// preLaunched merges instances served without a fleet (restarted, lingering or warm) into one response
+func preLaunched(responses ...*FleetResponse) *FleetResponse {
+	var merged *FleetResponse
+	for _, response := range responses {
+		if response == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &FleetResponse{}
+		}
+		merged.Instances = append(merged.Instances, response.Instances...)
+	}
+	return merged
+}
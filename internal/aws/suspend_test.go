@@ -0,0 +1,86 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+)
+
+func suspendedInstance(id, nodeName, instanceType, zone string) types.Instance {
+	return types.Instance{
+		InstanceId:   aws.String(id),
+		InstanceType: types.InstanceType(instanceType),
+		Placement:    &types.Placement{AvailabilityZone: aws.String(zone)},
+		Tags: []types.Tag{
+			{Key: aws.String("Name"), Value: aws.String(nodeName)},
+			{Key: aws.String(suspendedAtTag), Value: aws.String("2026-01-01T00:00:00Z")},
+		},
+	}
+}
+
+func TestFleetManager_partitionSuspendedInstances(t *testing.T) {
+	fm := &FleetManager{logger: zaptest.NewLogger(t), catalog: newTestCatalog(t, "")}
+
+	stopped := []types.Instance{
+		suspendedInstance("i-1", "aws-cpu-1", "c6i.32xlarge", "us-east-1a"),
+		suspendedInstance("i-2", "aws-cpu-2", "p4d.24xlarge", "us-east-1a"),
+		suspendedInstance("i-3", "aws-cpu-1", "c6i.32xlarge", "us-east-1a"),
+		suspendedInstance("i-4", "aws-cpu-3", "c6i.32xlarge", "us-east-1b"),
+	}
+
+	ids := func(instances []types.Instance) []string {
+		var result []string
+		for _, instance := range instances {
+			result = append(result, aws.ToString(instance.InstanceId))
+		}
+		return result
+	}
+
+	t.Run("keeps one matching instance per node", func(t *testing.T) {
+		req := &FleetRequest{
+			NodeIds:              []string{"aws-cpu-1", "aws-cpu-2", "aws-cpu-3"},
+			Job:                  &burstTypes.SlurmJob{},
+			InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.32xlarge"}},
+		}
+		usable, unusable := fm.partitionSuspendedInstances(stopped, req)
+		assert.Equal(t, []string{"i-1", "i-4"}, ids(usable))
+		assert.Equal(t, []string{"i-2", "i-3"}, unusable)
+	})
+
+	t.Run("gang job spanning AZs restarts nothing", func(t *testing.T) {
+		req := &FleetRequest{
+			NodeIds:              []string{"aws-cpu-1", "aws-cpu-3"},
+			Job:                  &burstTypes.SlurmJob{IsMPIJob: true},
+			InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.32xlarge"}, RequiresEFA: true},
+		}
+		usable, unusable := fm.partitionSuspendedInstances(stopped, req)
+		assert.Empty(t, usable)
+		assert.ElementsMatch(t, []string{"i-1", "i-2", "i-3", "i-4"}, unusable)
+	})
+
+	t.Run("job-specific user data needs fresh instances", func(t *testing.T) {
+		req := &FleetRequest{
+			NodeIds:              []string{"aws-cpu-1"},
+			Job:                  &burstTypes.SlurmJob{},
+			InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.32xlarge"}},
+			UserDataParts:        []userdata.Part{userdata.NewPart("setup.sh", "#!/bin/bash\n")},
+		}
+		usable, _ := fm.partitionSuspendedInstances(stopped[:1], req)
+		assert.Empty(t, usable)
+	})
+}
+
+func TestSuspendedTime(t *testing.T) {
+	suspendedAt, ok := suspendedTime(suspendedInstance("i-1", "aws-cpu-1", "c6i.large", "us-east-1a"))
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), suspendedAt)
+
+	_, ok = suspendedTime(types.Instance{})
+	assert.False(t, ok)
+}
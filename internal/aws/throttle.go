@@ -0,0 +1,70 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// EC2 API limits for batched calls
+const (
+	maxInstanceIdsPerCall = 1000 // TerminateInstances
+	maxFilterValues       = 200  // Values in one DescribeInstances filter
+)
+
+// Retries of EC2 calls that were throttled
+const (
+	throttleMaxAttempts = 5
+	throttleBaseDelay   = time.Second
+	throttleMaxDelay    = 16 * time.Second
+)
+
+// throttleErrorCodes are the EC2 error codes for exceeding the API request rate
+var throttleErrorCodes = map[string]bool{
+	"RequestLimitExceeded": true,
+	"Throttling":           true,
+	"ThrottlingException":  true,
+}
+
+// throttleSleep waits between throttled attempts; tests replace it
+var throttleSleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isThrottled reports whether err is EC2 rejecting the request rate
+func isThrottled(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && throttleErrorCodes[apiErr.ErrorCode()]
+}
+
+// retryThrottled runs call until it succeeds, fails for another reason or has
+// been throttled throttleMaxAttempts times, backing off with jitter in between.
+// It sits on top of the SDK's own retries, which give up too soon when a whole
+// cluster scales down at once.
+func retryThrottled(ctx context.Context, call func() error) error {
+	delay := throttleBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || !isThrottled(err) || attempt == throttleMaxAttempts {
+			return err
+		}
+
+		// Jitter keeps concurrent suspends from retrying in lockstep
+		if err := throttleSleep(ctx, delay/2+time.Duration(rand.Int63n(int64(delay/2)+1))); err != nil {
+			return err
+		}
+		if delay *= 2; delay > throttleMaxDelay {
+			delay = throttleMaxDelay
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryThrottled(t *testing.T) {
+	var sleeps []time.Duration
+	defer func(original func(context.Context, time.Duration) error) { throttleSleep = original }(throttleSleep)
+	throttleSleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	throttled := &smithy.GenericAPIError{Code: "RequestLimitExceeded", Message: "Request limit exceeded."}
+	ctx := context.Background()
+
+	// Throttled calls are retried until they succeed
+	calls := 0
+	require.NoError(t, retryThrottled(ctx, func() error {
+		if calls++; calls < 3 {
+			return throttled
+		}
+		return nil
+	}))
+	assert.Equal(t, 3, calls)
+	require.Len(t, sleeps, 2)
+	assert.True(t, sleeps[0] >= throttleBaseDelay/2 && sleeps[0] <= throttleBaseDelay)
+	assert.True(t, sleeps[1] >= throttleBaseDelay && sleeps[1] <= 2*throttleBaseDelay, "the delay doubles")
+
+	// ...but not forever
+	calls = 0
+	assert.ErrorIs(t, retryThrottled(ctx, func() error { calls++; return throttled }), throttled)
+	assert.Equal(t, throttleMaxAttempts, calls)
+
+	// Other failures are returned at once
+	calls = 0
+	other := &smithy.GenericAPIError{Code: "InvalidInstanceID.NotFound"}
+	assert.ErrorIs(t, retryThrottled(ctx, func() error { calls++; return other }), other)
+	assert.Equal(t, 1, calls)
+
+	assert.True(t, isThrottled(errors.Join(errors.New("terminating"), throttled)))
+	assert.False(t, isThrottled(errors.New("Throttling")))
+}
+
+func TestClient_planSuspend(t *testing.T) {
+	appConfig := &config.Config{Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{{
+		PartitionName: "aws",
+		NodeGroups: []config.NodeGroupConfig{
+			{NodeGroupName: "cpu"},
+			{NodeGroupName: "gpu", Region: "us-west-2"},
+			{NodeGroupName: "mem"},
+			{NodeGroupName: "warm", SuspendMode: config.SuspendModeStop},
+		},
+	}}}}
+	client := &Client{config: &config.AWSConfig{Region: "us-east-1"}, appConfig: appConfig}
+
+	warm := NodeGroupSuspend{Partition: "aws", NodeGroup: "warm", NodeNames: []string{"aws-warm-0"}}
+	terminateByRegion, keepStopped := client.planSuspend([]NodeGroupSuspend{
+		{Partition: "aws", NodeGroup: "cpu", NodeNames: []string{"aws-cpu-0", "aws-cpu-1"}},
+		{Partition: "aws", NodeGroup: "gpu", NodeNames: []string{"aws-gpu-0"}},
+		warm,
+		{Partition: "aws", NodeGroup: "mem", NodeNames: []string{"aws-mem-0"}},
+	})
+
+	assert.Equal(t, map[string][]string{
+		"us-east-1": {"aws-cpu-0", "aws-cpu-1", "aws-mem-0"},
+		"us-west-2": {"aws-gpu-0"},
+	}, terminateByRegion, "terminating node groups share one batch per region")
+	assert.Equal(t, []NodeGroupSuspend{warm}, keepStopped)
+}
@@ -0,0 +1,135 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
+	"go.uber.org/zap"
+)
+
+// prepareLaunchTemplateVersion creates a launch template version carrying the
+// request's user-data parts, merged with the launch template's own user data,
+// its Capacity Block reservation and its scratch volume. EC2 Fleet can override
+// none of these directly, so the fleet launches from this version; the
+// returned cleanup deletes it once instances exist.
+func (f *FleetManager) prepareLaunchTemplateVersion(ctx context.Context, req *FleetRequest) (string, func(), error) {
+	noop := func() {}
+	if len(req.UserDataParts) == 0 && req.CapacityBlock == nil && req.Scratch == nil {
+		return req.LaunchTemplate.Version, noop, nil
+	}
+
+	sourceVersion := req.LaunchTemplate.Version
+	if sourceVersion == "" {
+		sourceVersion = "$Default"
+	}
+
+	describeInput := &ec2.DescribeLaunchTemplateVersionsInput{
+		Versions: []string{sourceVersion},
+	}
+	if req.LaunchTemplate.ID != "" {
+		describeInput.LaunchTemplateId = aws.String(req.LaunchTemplate.ID)
+	} else {
+		describeInput.LaunchTemplateName = aws.String(req.LaunchTemplate.Name)
+	}
+
+	described, err := f.ec2Client.DescribeLaunchTemplateVersions(ctx, describeInput)
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to describe launch template: %w", err)
+	}
+	if len(described.LaunchTemplateVersions) == 0 {
+		return "", noop, fmt.Errorf("launch template version %s not found", sourceVersion)
+	}
+
+	source := described.LaunchTemplateVersions[0].LaunchTemplateData
+	if source != nil {
+		req.templateData = source
+	} else {
+		req.templateData = &types.ResponseLaunchTemplateData{}
+	}
+	data := &types.RequestLaunchTemplateData{}
+	var combined string
+	if len(req.UserDataParts) > 0 {
+		var templateUserData string
+		if source != nil && source.UserData != nil {
+			decoded, err := base64.StdEncoding.DecodeString(aws.ToString(source.UserData))
+			if err != nil {
+				return "", noop, fmt.Errorf("failed to decode launch template user data: %w", err)
+			}
+			templateUserData = string(decoded)
+		}
+
+		if combined, err = mergeUserData(templateUserData, req.UserDataParts); err != nil {
+			return "", noop, err
+		}
+		data.UserData = aws.String(base64.StdEncoding.EncodeToString([]byte(combined)))
+	}
+	if block := req.CapacityBlock; block != nil {
+		data.InstanceMarketOptions = &types.LaunchTemplateInstanceMarketOptionsRequest{MarketType: types.MarketTypeCapacityBlock}
+		data.CapacityReservationSpecification = &types.LaunchTemplateCapacityReservationSpecificationRequest{
+			CapacityReservationTarget: &types.CapacityReservationTarget{CapacityReservationId: aws.String(block.ReservationID)},
+		}
+	}
+	if scratch := req.Scratch; scratch != nil {
+		data.BlockDeviceMappings = scratchBlockDevices(source, scratch)
+		if scratch.RetainHours > 0 {
+			data.TagSpecifications = scratchTagSpecifications(source, scratch, req.Job.JobID)
+		}
+	}
+
+	templateID := aws.ToString(described.LaunchTemplateVersions[0].LaunchTemplateId)
+	created, err := f.ec2Client.CreateLaunchTemplateVersion(ctx, &ec2.CreateLaunchTemplateVersionInput{
+		LaunchTemplateId:   aws.String(templateID),
+		SourceVersion:      aws.String(strconv.FormatInt(aws.ToInt64(described.LaunchTemplateVersions[0].VersionNumber), 10)),
+		VersionDescription: aws.String(fmt.Sprintf("aws-slurm-burst job %s", req.Job.JobID)),
+		LaunchTemplateData: data,
+	})
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create launch template version: %w", err)
+	}
+
+	version := strconv.FormatInt(aws.ToInt64(created.LaunchTemplateVersion.VersionNumber), 10)
+	fields := []zap.Field{
+		zap.String("launch_template_id", templateID),
+		zap.String("version", version),
+		zap.Int("user_data_bytes", len(combined)),
+	}
+	if req.CapacityBlock != nil {
+		fields = append(fields, zap.String("capacity_block", req.CapacityBlock.ReservationID))
+	}
+	if req.Scratch != nil {
+		fields = append(fields, zap.Int("scratch_gb", req.Scratch.SizeGB))
+	}
+	f.logger.Info("Created launch template version for job", fields...)
+
+	cleanup := func() {
+		_, err := f.ec2Client.DeleteLaunchTemplateVersions(context.Background(), &ec2.DeleteLaunchTemplateVersionsInput{
+			LaunchTemplateId: aws.String(templateID),
+			Versions:         []string{version},
+		})
+		if err != nil {
+			f.logger.Warn("Failed to delete temporary launch template version",
+				zap.String("launch_template_id", templateID),
+				zap.String("version", version),
+				zap.Error(err))
+		}
+	}
+
+	return version, cleanup, nil
+}
+
+// mergeUserData combines the launch template's user data with additional parts
+func mergeUserData(templateUserData string, extra []userdata.Part) (string, error) {
+	parts, err := userdata.ParseParts("launch-template", templateUserData)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse launch template user data: %w", err)
+	}
+	parts = append(parts, extra...)
+
+	return userdata.Combine(parts)
+}
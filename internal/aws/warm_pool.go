@@ -0,0 +1,445 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	burstConfig "github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"go.uber.org/zap"
+)
+
+// Warm pool instance tags
+const (
+	warmPoolTag        = "WarmPool"        // Pool name, present only while the instance waits in the pool
+	warmPoolCreatedTag = "WarmPoolCreated" // RFC3339 creation time; LaunchTime resets on every start
+)
+
+// poolLockTimeout is the longest a claim waits for another resume, or a
+// replenishment or reaping pass, to release a pool
+const poolLockTimeout = 30 * time.Second
+
+// WarmPoolSpec describes one node group's warm pool
+type WarmPoolSpec struct {
+	Partition          string
+	NodeGroup          string
+	Size               int
+	InstanceType       string
+	Hibernate          bool
+	Warmup             time.Duration
+	MaxAge             time.Duration
+	PlacementGroupName string
+	LaunchTemplate     LaunchTemplateConfig
+	SubnetIds          []string
+	SecurityGroupIds   []string
+}
+
+// WarmPoolSpecFor builds the warm pool spec for a node group
+func WarmPoolSpecFor(partition string, nodeGroup *burstConfig.NodeGroupConfig) WarmPoolSpec {
+	return WarmPoolSpec{
+		Partition:          partition,
+		NodeGroup:          nodeGroup.NodeGroupName,
+		Size:               nodeGroup.WarmPool.Size,
+		InstanceType:       nodeGroup.WarmPoolInstanceType(),
+		Hibernate:          nodeGroup.WarmPool.Hibernate,
+		Warmup:             time.Duration(nodeGroup.WarmPool.WarmupMinutes) * time.Minute,
+		MaxAge:             time.Duration(nodeGroup.WarmPool.MaxAgeHours) * time.Hour,
+		PlacementGroupName: nodeGroup.WarmPool.PlacementGroupName,
+		LaunchTemplate: LaunchTemplateConfig{
+			Name:    nodeGroup.LaunchTemplateSpec.LaunchTemplateName,
+			ID:      nodeGroup.LaunchTemplateSpec.LaunchTemplateID,
+			Version: nodeGroup.LaunchTemplateSpec.Version,
+		},
+		SubnetIds:        nodeGroup.SubnetIds,
+		SecurityGroupIds: nodeGroup.SecurityGroupIds,
+	}
+}
+
+// Name returns the pool's tag value, e.g. "aws-cpu"
+func (s WarmPoolSpec) Name() string {
+	return warmPoolName(s.Partition, s.NodeGroup)
+}
+
+func warmPoolName(partition, nodeGroup string) string {
+	return fmt.Sprintf("%s-%s", partition, nodeGroup)
+}
+
+// WarmPoolReport summarizes one replenishment pass over a pool
+type WarmPoolReport struct {
+	Pool     string
+	Ready    int // Stopped and ready to start
+	Warming  int // Running user data before being stopped
+	Stopped  int // Warmed instances stopped this pass
+	Recycled int // Instances terminated for age or a changed instance type
+	Launched int // New instances launched this pass
+}
+
+// listWarmPool returns the instances waiting in a pool
+func (f *FleetManager) listWarmPool(ctx context.Context, pool string) ([]types.Instance, error) {
+	result, err := f.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []types.Filter{
+			{Name: aws.String("tag:" + warmPoolTag), Values: []string{pool}},
+			{Name: aws.String("instance-state-name"), Values: []string{"pending", "running", "stopping", "stopped"}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe warm pool %s: %w", pool, err)
+	}
+
+	var instances []types.Instance
+	for _, reservation := range result.Reservations {
+		instances = append(instances, reservation.Instances...)
+	}
+	return instances, nil
+}
+
+// warmPoolServes reports whether a request can use the node group's warm pool.
+// Pool instances are already booted, so job-specific user data, node
+// bootstrap and munge key delivery cannot apply, and cluster-placed gang jobs
+// need a pool launched into a placement group.
+func warmPoolServes(nodeGroup *burstConfig.NodeGroupConfig, req *FleetRequest, roles []NodeRoleLaunch) bool {
+	if nodeGroup == nil || !nodeGroup.WarmPool.Enabled() || len(roles) > 0 || len(req.UserDataParts) > 0 ||
+		req.CapacityBlock != nil || req.NodeBootstrap != nil || req.MungeKey != nil {
+		return false
+	}
+	if isGangRequest(req) && req.InstanceRequirements.PlacementGroupType == "cluster" {
+		return nodeGroup.WarmPool.PlacementGroupName != ""
+	}
+	return true
+}
+
+// lockPool takes the named pool lock, so resumes claiming from a pool and the
+// state manager tending it never take the same instances: listing a pool and
+// retagging what was listed is not atomic in EC2. Without an exchange
+// directory it locks nothing.
+func (f *FleetManager) lockPool(name string) (func(), error) {
+	if f.poolLockDir == "" {
+		return func() {}, nil
+	}
+	return exchange.Lock(f.poolLockDir, name, poolLockTimeout)
+}
+
+// isGangRequest reports whether a request must launch all-or-nothing
+func isGangRequest(req *FleetRequest) bool {
+	return req.Job.IsMPIJob && req.InstanceRequirements.RequiresEFA
+}
+
+// ClaimWarmInstances starts stopped pool instances for as many of the request's
+// nodes as the pool can serve, and returns the nodes still needing a fleet launch.
+// Gang-scheduled requests are served only when one AZ of the pool covers every
+// node, so a job never spans warm and fleet capacity. Failures are advisory: the
+// fleet launches whatever the pool could not start.
+func (f *FleetManager) ClaimWarmInstances(ctx context.Context, req *FleetRequest, pool string) (*FleetResponse, []string) {
+	unlock, err := f.lockPool("warm-pool-" + pool)
+	if err != nil {
+		f.logger.Warn("Warm pool busy, launching fleet", zap.String("pool", pool), zap.Error(err))
+		return nil, req.NodeIds
+	}
+	// Released once the instances leave the pool, not after they start
+	unlock = sync.OnceFunc(unlock)
+	defer unlock()
+
+	available, err := f.listWarmPool(ctx, pool)
+	if err != nil {
+		f.logger.Warn("Warm pool unavailable, launching fleet", zap.String("pool", pool), zap.Error(err))
+		return nil, req.NodeIds
+	}
+
+	ready := f.readyWarmInstances(available, req)
+	if len(ready) == 0 {
+		return nil, req.NodeIds
+	}
+
+	count := len(req.NodeIds)
+	if len(ready) < count {
+		if isGangRequest(req) {
+			f.logger.Info("Warm pool cannot cover gang-scheduled job, launching fleet",
+				zap.String("pool", pool),
+				zap.Int("ready", len(ready)),
+				zap.Int("required", count))
+			return nil, req.NodeIds
+		}
+		count = len(ready)
+	}
+	ready = ready[:count]
+
+	instanceIds := make([]string, 0, count)
+	for _, instance := range ready {
+		instanceIds = append(instanceIds, aws.ToString(instance.InstanceId))
+	}
+
+	f.logger.Info("Starting warm pool instances",
+		zap.String("pool", pool),
+		zap.Strings("instance_ids", instanceIds),
+		zap.Strings("nodes", req.NodeIds[:count]))
+
+	// Leave the pool before starting so a concurrent resume or replenishment skips them
+	if err := f.untagWarmPool(ctx, instanceIds, pool); err != nil {
+		f.logger.Warn("Failed to claim warm pool instances, launching fleet", zap.Error(err))
+		return nil, req.NodeIds
+	}
+	unlock()
+
+	if _, err := f.ec2Client.StartInstances(ctx, &ec2.StartInstancesInput{InstanceIds: instanceIds}); err != nil {
+		f.logger.Warn("Failed to start warm pool instances, returning them to the pool", zap.Error(err))
+		f.tagInstances(ctx, instanceIds, map[string]string{warmPoolTag: pool})
+		return nil, req.NodeIds
+	}
+
+	f.tagInstances(ctx, instanceIds, req.Tags)
+
+	instances, err := f.waitForInstancesRunning(ctx, instanceIds, req.NodeIds[:count])
+	if err != nil {
+		f.logger.Warn("Warm pool instances failed to start, terminating them", zap.Error(err))
+		f.gangScheduler.cleanupPartialLaunch(ctx, &FleetResponse{Instances: instancesWithIds(instanceIds)})
+		return nil, req.NodeIds
+	}
+
+	return &FleetResponse{Instances: instances}, req.NodeIds[count:]
+}
+
+// readyWarmInstances returns the stopped pool instances a request can use, oldest
+// first. Gang requests get only instances from the AZ with the most ready.
+func (f *FleetManager) readyWarmInstances(available []types.Instance, req *FleetRequest) []types.Instance {
+	acceptable := make(map[string]bool)
+	for _, instanceType := range f.selectInstanceTypes(req.InstanceRequirements) {
+		acceptable[instanceType] = true
+	}
+
+	var ready []types.Instance
+	for _, instance := range available {
+		if instance.State == nil || instance.State.Name != types.InstanceStateNameStopped {
+			continue
+		}
+		if len(acceptable) > 0 && !acceptable[string(instance.InstanceType)] {
+			continue
+		}
+		ready = append(ready, instance)
+	}
+
+	sort.SliceStable(ready, func(i, j int) bool {
+		return warmInstanceCreated(ready[i]).Before(warmInstanceCreated(ready[j]))
+	})
+
+	if !isGangRequest(req) {
+		return ready
+	}
+
+	byZone := make(map[string][]types.Instance)
+	var best string
+	for _, instance := range ready {
+		zone := ""
+		if instance.Placement != nil {
+			zone = aws.ToString(instance.Placement.AvailabilityZone)
+		}
+		byZone[zone] = append(byZone[zone], instance)
+		if len(byZone[zone]) > len(byZone[best]) {
+			best = zone
+		}
+	}
+	return byZone[best]
+}
+
+// ReplenishWarmPool moves a pool toward its configured size: instances past their
+// max age or of a replaced instance type are terminated, instances that have
+// finished warming up are stopped (or hibernated), and the shortfall is launched.
+// Each pass only issues API calls, so it fits in a periodic state manager run.
+func (f *FleetManager) ReplenishWarmPool(ctx context.Context, spec WarmPoolSpec, dryRun bool) (*WarmPoolReport, error) {
+	pool := spec.Name()
+	unlock, err := f.lockPool("warm-pool-" + pool)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	instances, err := f.listWarmPool(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &WarmPoolReport{Pool: pool}
+	now := time.Now()
+	var recycle, stop []string
+
+	for _, instance := range instances {
+		instanceID := aws.ToString(instance.InstanceId)
+		age := now.Sub(warmInstanceCreated(instance))
+
+		switch {
+		case age > spec.MaxAge || string(instance.InstanceType) != spec.InstanceType:
+			recycle = append(recycle, instanceID)
+		case instance.State.Name == types.InstanceStateNameRunning && age >= spec.Warmup:
+			stop = append(stop, instanceID)
+		case instance.State.Name == types.InstanceStateNameStopped:
+			report.Ready++
+		default:
+			report.Warming++
+		}
+	}
+
+	deficit := spec.Size - (len(instances) - len(recycle))
+
+	f.logger.Info("Warm pool status",
+		zap.String("pool", pool),
+		zap.Int("size", spec.Size),
+		zap.Int("ready", report.Ready),
+		zap.Int("warming", report.Warming),
+		zap.Int("to_stop", len(stop)),
+		zap.Int("to_recycle", len(recycle)),
+		zap.Int("to_launch", max(deficit, 0)),
+		zap.Bool("dry_run", dryRun))
+
+	if dryRun {
+		return report, nil
+	}
+
+	if len(recycle) > 0 {
+		if _, err := f.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: recycle}); err != nil {
+			return report, fmt.Errorf("failed to recycle warm pool instances: %w", err)
+		}
+		report.Recycled = len(recycle)
+	}
+
+	if len(stop) > 0 {
+		if _, err := f.ec2Client.StopInstances(ctx, &ec2.StopInstancesInput{
+			InstanceIds: stop,
+			Hibernate:   aws.Bool(spec.Hibernate),
+		}); err != nil {
+			return report, fmt.Errorf("failed to stop warmed instances: %w", err)
+		}
+		report.Stopped = len(stop)
+	}
+
+	if deficit > 0 {
+		launched, err := f.launchWarmInstances(ctx, spec, deficit)
+		report.Launched = launched
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// launchWarmInstances launches new pool instances, spread across the pool's subnets
+// unless the pool uses a placement group, which keeps them in the first subnet
+func (f *FleetManager) launchWarmInstances(ctx context.Context, spec WarmPoolSpec, count int) (int, error) {
+	if len(spec.SubnetIds) == 0 {
+		return 0, fmt.Errorf("warm pool %s has no subnets", spec.Name())
+	}
+
+	subnets := spec.SubnetIds
+	if spec.PlacementGroupName != "" {
+		subnets = subnets[:1]
+	}
+	perSubnet := make(map[string]int)
+	for i := 0; i < count; i++ {
+		perSubnet[subnets[i%len(subnets)]]++
+	}
+
+	launched := 0
+	for _, subnetID := range subnets {
+		if perSubnet[subnetID] == 0 {
+			continue
+		}
+		result, err := f.ec2Client.RunInstances(ctx, f.buildWarmPoolInput(spec, subnetID, perSubnet[subnetID]))
+		if err != nil {
+			return launched, fmt.Errorf("failed to launch warm pool instances in %s: %w", subnetID, err)
+		}
+		launched += len(result.Instances)
+	}
+
+	f.logger.Info("Launched warm pool instances",
+		zap.String("pool", spec.Name()),
+		zap.Int("count", launched))
+
+	return launched, nil
+}
+
+// buildWarmPoolInput creates the RunInstances request for new pool instances
+func (f *FleetManager) buildWarmPoolInput(spec WarmPoolSpec, subnetID string, count int) *ec2.RunInstancesInput {
+	launchTemplate := &types.LaunchTemplateSpecification{
+		Version: aws.String(spec.LaunchTemplate.Version),
+	}
+	if spec.LaunchTemplate.ID != "" {
+		launchTemplate.LaunchTemplateId = aws.String(spec.LaunchTemplate.ID)
+	} else {
+		launchTemplate.LaunchTemplateName = aws.String(spec.LaunchTemplate.Name)
+	}
+
+	input := &ec2.RunInstancesInput{
+		LaunchTemplate: launchTemplate,
+		InstanceType:   types.InstanceType(spec.InstanceType),
+		MinCount:       aws.Int32(1),
+		MaxCount:       aws.Int32(int32(count)),
+		SubnetId:       aws.String(subnetID),
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeInstance,
+				Tags: []types.Tag{
+					{Key: aws.String("Name"), Value: aws.String(spec.Name() + "-warm")},
+					{Key: aws.String(warmPoolTag), Value: aws.String(spec.Name())},
+					{Key: aws.String(warmPoolCreatedTag), Value: aws.String(time.Now().UTC().Format(time.RFC3339))},
+					{Key: aws.String("Partition"), Value: aws.String(spec.Partition)},
+					{Key: aws.String("NodeGroup"), Value: aws.String(spec.NodeGroup)},
+					{Key: aws.String("ManagedBy"), Value: aws.String("aws-slurm-burst")},
+				},
+			},
+		},
+	}
+
+	if len(spec.SecurityGroupIds) > 0 {
+		input.SecurityGroupIds = spec.SecurityGroupIds
+	}
+	if spec.Hibernate {
+		input.HibernationOptions = &types.HibernationOptionsRequest{Configured: aws.Bool(true)}
+	}
+	if spec.PlacementGroupName != "" {
+		input.Placement = &types.Placement{GroupName: aws.String(spec.PlacementGroupName)}
+	}
+
+	return input
+}
+
+// warmInstanceCreated returns when a pool instance was created
+func warmInstanceCreated(instance types.Instance) time.Time {
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == warmPoolCreatedTag {
+			if created, err := time.Parse(time.RFC3339, aws.ToString(tag.Value)); err == nil {
+				return created
+			}
+		}
+	}
+	return aws.ToTime(instance.LaunchTime)
+}
+
+// untagWarmPool removes instances from a pool
+func (f *FleetManager) untagWarmPool(ctx context.Context, instanceIds []string, pool string) error {
+	_, err := f.ec2Client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: instanceIds,
+		Tags:      []types.Tag{{Key: aws.String(warmPoolTag), Value: aws.String(pool)}},
+	})
+	return err
+}
+
+// tagInstances adds tags to instances; failures are logged, not returned
+func (f *FleetManager) tagInstances(ctx context.Context, instanceIds []string, tags map[string]string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	var ec2Tags []types.Tag
+	for key, value := range tags {
+		ec2Tags = append(ec2Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	if _, err := f.ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{Resources: instanceIds, Tags: ec2Tags}); err != nil {
+		f.logger.Warn("Failed to tag instances", zap.Strings("instance_ids", instanceIds), zap.Error(err))
+	}
+}
@@ -0,0 +1,152 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func warmInstance(id, instanceType, zone string, state types.InstanceStateName, created time.Time) types.Instance {
+	return types.Instance{
+		InstanceId:   aws.String(id),
+		InstanceType: types.InstanceType(instanceType),
+		State:        &types.InstanceState{Name: state},
+		Placement:    &types.Placement{AvailabilityZone: aws.String(zone)},
+		Tags: []types.Tag{
+			{Key: aws.String(warmPoolCreatedTag), Value: aws.String(created.UTC().Format(time.RFC3339))},
+		},
+	}
+}
+
+func TestWarmPoolServes(t *testing.T) {
+	nodeGroup := &config.NodeGroupConfig{NodeGroupName: "cpu", WarmPool: config.WarmPoolConfig{Size: 4}}
+	clusteredPool := &config.NodeGroupConfig{NodeGroupName: "cpu", WarmPool: config.WarmPoolConfig{Size: 4, PlacementGroupName: "hpc-pg"}}
+
+	plain := &FleetRequest{Job: &burstTypes.SlurmJob{}, InstanceRequirements: &burstTypes.InstanceRequirements{}}
+	gang := &FleetRequest{
+		Job:                  &burstTypes.SlurmJob{IsMPIJob: true},
+		InstanceRequirements: &burstTypes.InstanceRequirements{RequiresEFA: true, PlacementGroupType: "cluster"},
+	}
+	withUserData := &FleetRequest{
+		Job:                  &burstTypes.SlurmJob{},
+		InstanceRequirements: &burstTypes.InstanceRequirements{},
+		UserDataParts:        []userdata.Part{userdata.NewPart("setup.sh", "#!/bin/bash\n")},
+	}
+
+	assert.True(t, warmPoolServes(nodeGroup, plain, nil))
+	assert.False(t, warmPoolServes(&config.NodeGroupConfig{}, plain, nil), "pool disabled")
+	assert.False(t, warmPoolServes(nodeGroup, plain, []NodeRoleLaunch{{Role: "head"}}), "heterogeneous roles")
+	assert.False(t, warmPoolServes(nodeGroup, withUserData, nil), "job-specific user data")
+	assert.False(t, warmPoolServes(nodeGroup, gang, nil), "cluster gang job without a placement group pool")
+	assert.True(t, warmPoolServes(clusteredPool, gang, nil))
+}
+
+func TestFleetManager_readyWarmInstances(t *testing.T) {
+	fm := &FleetManager{logger: zaptest.NewLogger(t), catalog: newTestCatalog(t, "")}
+	now := time.Now()
+
+	available := []types.Instance{
+		warmInstance("i-new", "c6i.32xlarge", "us-east-1a", types.InstanceStateNameStopped, now.Add(-time.Hour)),
+		warmInstance("i-old", "c6i.32xlarge", "us-east-1b", types.InstanceStateNameStopped, now.Add(-2*time.Hour)),
+		warmInstance("i-warming", "c6i.32xlarge", "us-east-1a", types.InstanceStateNameRunning, now),
+		warmInstance("i-wrong-type", "p4d.24xlarge", "us-east-1a", types.InstanceStateNameStopped, now),
+		warmInstance("i-b2", "c6i.32xlarge", "us-east-1b", types.InstanceStateNameStopped, now.Add(-30*time.Minute)),
+	}
+
+	ids := func(instances []types.Instance) []string {
+		var result []string
+		for _, instance := range instances {
+			result = append(result, aws.ToString(instance.InstanceId))
+		}
+		return result
+	}
+
+	req := &FleetRequest{
+		Job:                  &burstTypes.SlurmJob{},
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.32xlarge"}},
+	}
+	assert.Equal(t, []string{"i-old", "i-new", "i-b2"}, ids(fm.readyWarmInstances(available, req)))
+
+	// Gang jobs only take instances from a single AZ
+	gang := &FleetRequest{
+		Job:                  &burstTypes.SlurmJob{IsMPIJob: true},
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.32xlarge"}, RequiresEFA: true},
+	}
+	assert.Equal(t, []string{"i-old", "i-b2"}, ids(fm.readyWarmInstances(available, gang)))
+}
+
+func TestFleetManager_buildWarmPoolInput(t *testing.T) {
+	fm := &FleetManager{logger: zaptest.NewLogger(t)}
+	spec := WarmPoolSpecFor("aws", &config.NodeGroupConfig{
+		NodeGroupName:           "cpu",
+		LaunchTemplateSpec:      config.LaunchTemplateSpec{LaunchTemplateName: "slurm-compute", Version: "$Latest"},
+		LaunchTemplateOverrides: []config.LaunchTemplateOverride{{InstanceType: "c6i.xlarge"}},
+		SubnetIds:               []string{"subnet-a"},
+		WarmPool:                config.WarmPoolConfig{Size: 2, Hibernate: true, PlacementGroupName: "hpc-pg"},
+	})
+
+	input := fm.buildWarmPoolInput(spec, "subnet-a", 2)
+	assert.Equal(t, types.InstanceType("c6i.xlarge"), input.InstanceType)
+	assert.Equal(t, int32(2), aws.ToInt32(input.MaxCount))
+	assert.Equal(t, "slurm-compute", aws.ToString(input.LaunchTemplate.LaunchTemplateName))
+	require.NotNil(t, input.HibernationOptions)
+	assert.True(t, aws.ToBool(input.HibernationOptions.Configured))
+	assert.Equal(t, "hpc-pg", aws.ToString(input.Placement.GroupName))
+
+	tags := make(map[string]string)
+	for _, tag := range input.TagSpecifications[0].Tags {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	assert.Equal(t, "aws-cpu", tags[warmPoolTag])
+	assert.Equal(t, "aws-cpu-warm", tags["Name"])
+}
+
+func TestFleetManager_ClaimWarmInstances_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	client, ec2 := newFakeEC2Client(t)
+	pool := warmPoolName("aws", "cpu")
+	ec2.addInstance("i-warm", "", "")
+	ec2.setState([]string{"i-warm"}, types.InstanceStateNameStopped)
+	ec2.instances[0].Tags = append(ec2.instances[0].Tags,
+		types.Tag{Key: aws.String(warmPoolTag), Value: aws.String(pool)},
+		types.Tag{Key: aws.String(warmPoolCreatedTag), Value: aws.String(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))})
+
+	ec2.describeDelay = 10 * time.Millisecond
+	fleetManager := client.fleetManager
+	fleetManager.poolLockDir = t.TempDir()
+
+	var wg sync.WaitGroup
+	claims := make([]*FleetResponse, 8)
+	for i := range claims {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claims[i], _ = fleetManager.ClaimWarmInstances(ctx, &FleetRequest{
+				NodeIds:              []string{fmt.Sprintf("aws-cpu-%d", i+1)},
+				Job:                  &burstTypes.SlurmJob{},
+				InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c6i.large"}},
+			}, pool)
+		}()
+	}
+	wg.Wait()
+
+	var claimed int
+	for _, claim := range claims {
+		if claim != nil {
+			claimed += len(claim.Instances)
+		}
+	}
+	assert.Equal(t, 1, claimed, "one resume claims the instance")
+	assert.Equal(t, []string{"i-warm"}, ec2.started, "started once")
+}
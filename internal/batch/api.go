@@ -0,0 +1,135 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsBatch "github.com/aws/aws-sdk-go-v2/service/batch"
+	batchTypes "github.com/aws/aws-sdk-go-v2/service/batch/types"
+)
+
+// API is the part of the AWS Batch client a hand-off and its tracking call;
+// tests substitute a fake
+type API interface {
+	RegisterJobDefinition(ctx context.Context, params *awsBatch.RegisterJobDefinitionInput, optFns ...func(*awsBatch.Options)) (*awsBatch.RegisterJobDefinitionOutput, error)
+	DeregisterJobDefinition(ctx context.Context, params *awsBatch.DeregisterJobDefinitionInput, optFns ...func(*awsBatch.Options)) (*awsBatch.DeregisterJobDefinitionOutput, error)
+	SubmitJob(ctx context.Context, params *awsBatch.SubmitJobInput, optFns ...func(*awsBatch.Options)) (*awsBatch.SubmitJobOutput, error)
+	TerminateJob(ctx context.Context, params *awsBatch.TerminateJobInput, optFns ...func(*awsBatch.Options)) (*awsBatch.TerminateJobOutput, error)
+	DescribeJobs(ctx context.Context, params *awsBatch.DescribeJobsInput, optFns ...func(*awsBatch.Options)) (*awsBatch.DescribeJobsOutput, error)
+	ListJobs(ctx context.Context, params *awsBatch.ListJobsInput, optFns ...func(*awsBatch.Options)) (*awsBatch.ListJobsOutput, error)
+}
+
+var _ API = (*awsBatch.Client)(nil)
+
+// batchJob is the part of a job DescribeJobs and ListJobs return that
+// tracking needs. Times are milliseconds since the epoch.
+type batchJob struct {
+	JobID        string
+	Status       string
+	StatusReason string
+	StartedAt    int64
+	StoppedAt    int64
+	ArraySize    int // Children of an array job; 0 for other jobs
+}
+
+// terminal reports whether the job has finished
+func (j *batchJob) terminal() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// registerJobDefinition registers a job definition and returns its ARN
+func (c *Client) registerJobDefinition(ctx context.Context, definition *awsBatch.RegisterJobDefinitionInput) (string, error) {
+	output, err := c.api.RegisterJobDefinition(ctx, definition)
+	if err != nil {
+		return "", fmt.Errorf("failed to register job definition %s: %w", aws.ToString(definition.JobDefinitionName), err)
+	}
+	return aws.ToString(output.JobDefinitionArn), nil
+}
+
+// deregisterJobDefinition marks a job definition inactive
+func (c *Client) deregisterJobDefinition(ctx context.Context, arn string) error {
+	if _, err := c.api.DeregisterJobDefinition(ctx, &awsBatch.DeregisterJobDefinitionInput{JobDefinition: aws.String(arn)}); err != nil {
+		return fmt.Errorf("failed to deregister job definition %s: %w", arn, err)
+	}
+	return nil
+}
+
+// submitJob submits a job of size tasks to the queue; jobs of more than one
+// task are array jobs. It returns the Batch job ID.
+func (c *Client) submitJob(ctx context.Context, name, queue, definitionARN string, size int, tags map[string]string) (string, error) {
+	input := &awsBatch.SubmitJobInput{
+		JobName:       aws.String(name),
+		JobQueue:      aws.String(queue),
+		JobDefinition: aws.String(definitionARN),
+		Tags:          tags,
+		PropagateTags: aws.Bool(true),
+	}
+	if size > 1 {
+		input.ArrayProperties = &batchTypes.ArrayProperties{Size: aws.Int32(int32(size))}
+	}
+	output, err := c.api.SubmitJob(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to submit job %s to %s: %w", name, queue, err)
+	}
+	return aws.ToString(output.JobId), nil
+}
+
+// terminateJob stops a job and its array children
+func (c *Client) terminateJob(ctx context.Context, jobID, reason string) error {
+	if _, err := c.api.TerminateJob(ctx, &awsBatch.TerminateJobInput{JobId: aws.String(jobID), Reason: aws.String(reason)}); err != nil {
+		return fmt.Errorf("failed to terminate job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// describeJob returns a job, or nil once Batch no longer knows it
+func (c *Client) describeJob(ctx context.Context, jobID string) (*batchJob, error) {
+	output, err := c.api.DescribeJobs(ctx, &awsBatch.DescribeJobsInput{Jobs: []string{jobID}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe job %s: %w", jobID, err)
+	}
+	if len(output.Jobs) == 0 {
+		return nil, nil
+	}
+	detail := output.Jobs[0]
+	job := &batchJob{
+		JobID:        aws.ToString(detail.JobId),
+		Status:       string(detail.Status),
+		StatusReason: aws.ToString(detail.StatusReason),
+		StartedAt:    aws.ToInt64(detail.StartedAt),
+		StoppedAt:    aws.ToInt64(detail.StoppedAt),
+	}
+	if detail.ArrayProperties != nil {
+		job.ArraySize = int(aws.ToInt32(detail.ArrayProperties.Size))
+	}
+	return job, nil
+}
+
+// arrayChildren lists an array job's finished children
+func (c *Client) arrayChildren(ctx context.Context, arrayJobID string) ([]batchJob, error) {
+	var children []batchJob
+	for _, status := range []batchTypes.JobStatus{batchTypes.JobStatusSucceeded, batchTypes.JobStatusFailed} {
+		input := &awsBatch.ListJobsInput{ArrayJobId: aws.String(arrayJobID), JobStatus: status, MaxResults: aws.Int32(100)}
+		for {
+			output, err := c.api.ListJobs(ctx, input)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list children of array job %s: %w", arrayJobID, err)
+			}
+			for _, summary := range output.JobSummaryList {
+				children = append(children, batchJob{
+					JobID:        aws.ToString(summary.JobId),
+					Status:       string(summary.Status),
+					StatusReason: aws.ToString(summary.StatusReason),
+					StartedAt:    aws.ToInt64(summary.StartedAt),
+					StoppedAt:    aws.ToInt64(summary.StoppedAt),
+				})
+			}
+			if output.NextToken == nil {
+				break
+			}
+			input.NextToken = output.NextToken
+		}
+	}
+	return children, nil
+}
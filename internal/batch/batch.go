@@ -0,0 +1,302 @@
+// Package batch runs loosely-coupled Slurm array jobs on AWS Batch instead
+// of burst nodes (batch_fallback). Resume hands an eligible array job's
+// unstarted tasks to Batch as one array job, with a job definition generated
+// from the batch script, and cancels them in Slurm. Each submission is
+// recorded in the data exchange directory; the state manager follows it until
+// Batch finishes and then queues it for the epilog exports, which price it
+// from the record.
+package batch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsBatch "github.com/aws/aws-sdk-go-v2/service/batch"
+	burstAWS "github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// Batch job states tracking waits for
+const (
+	StatusSucceeded = "SUCCEEDED"
+	StatusFailed    = "FAILED"
+)
+
+// lockTimeout bounds the wait for another resume handing off the same array job
+const lockTimeout = 30 * time.Second
+
+// Tasks is the part of the Slurm client a hand-off needs
+type Tasks interface {
+	UnstartedArrayTasks(ctx context.Context, arrayJobID string) ([]slurm.ArrayTask, error)
+	CancelArrayTasks(ctx context.Context, arrayJobID string, tasks []int) error
+}
+
+// Client submits array jobs to the configured job queue and follows them
+type Client struct {
+	logger *zap.Logger
+	cfg    *config.Config
+	api    API
+}
+
+// NewClient creates a client for the Batch job queue in the configured region
+func NewClient(ctx context.Context, logger *zap.Logger, cfg *config.Config) (*Client, error) {
+	awsConfig, err := burstAWS.LoadConfig(ctx, logger, &cfg.AWS, cfg.AWS.Region)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(logger, cfg, awsBatch.NewFromConfig(awsConfig)), nil
+}
+
+func newClient(logger *zap.Logger, cfg *config.Config, api API) *Client {
+	return &Client{logger: logger, cfg: cfg, api: api}
+}
+
+// Record is an array job handed to AWS Batch, stored in the data exchange
+// directory under the Slurm array job ID
+type Record struct {
+	ArrayJobID       string    `json:"array_job_id"`
+	JobName          string    `json:"job_name"`
+	Partition        string    `json:"partition"`
+	Account          string    `json:"account,omitempty"`
+	Tasks            []int     `json:"tasks"`
+	Nodes            []string  `json:"nodes"` // Powered down instead of launched
+	BatchJobID       string    `json:"batch_job_id"`
+	JobQueue         string    `json:"job_queue"`
+	JobDefinitionARN string    `json:"job_definition_arn"`
+	Platform         string    `json:"platform"`
+	Image            string    `json:"image"`
+	VCPUs            int       `json:"vcpus"` // Per task
+	MemoryMB         int       `json:"memory_mb"`
+	GPUs             int       `json:"gpus,omitempty"`
+	SubmittedAt      time.Time `json:"submitted_at"`
+
+	// Filled in once Batch has finished the job
+	Status       string    `json:"status,omitempty"`
+	StatusReason string    `json:"status_reason,omitempty"`
+	Succeeded    int       `json:"succeeded,omitempty"`
+	Failed       int       `json:"failed,omitempty"`
+	StartTime    time.Time `json:"start_time,omitempty"`
+	EndTime      time.Time `json:"end_time,omitempty"`
+	TaskHours    float64   `json:"task_hours,omitempty"` // Summed over the tasks that ran
+	CostUSD      float64   `json:"cost_usd,omitempty"`
+}
+
+// Done reports whether Batch has finished the job
+func (r *Record) Done() bool {
+	return r.Status != ""
+}
+
+// Includes reports whether the Slurm array task was handed to Batch
+func (r *Record) Includes(task int) bool {
+	return slices.Contains(r.Tasks, task)
+}
+
+// ReadRecord loads the record of an array job handed to Batch, or nil
+func ReadRecord(root, arrayJobID string) (*Record, error) {
+	var record Record
+	found, err := exchange.Read(root, exchange.Batch, arrayJobID, &record)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Submit hands the unstarted tasks of the array job being resumed for to
+// Batch and cancels them in Slurm. nodes are the nodes Slurm is resuming for
+// it; each must be allocated to one of the tasks, or the hand-off would leave
+// another job without its nodes. On error nothing has been handed off.
+func (c *Client) Submit(ctx context.Context, slurmTasks Tasks, job *types.SlurmJob, nodes []string) (*Record, error) {
+	root := c.cfg.Ecosystem.DataExchangeDir
+	arrayJobID := job.ArrayJobID()
+	unlock, err := exchange.Lock(root, "batch-"+arrayJobID, lockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if existing, err := ReadRecord(root, arrayJobID); err != nil || existing != nil {
+		if err == nil {
+			err = fmt.Errorf("array job %s was already handed to AWS Batch as %s", arrayJobID, existing.BatchJobID)
+		}
+		return nil, err
+	}
+
+	unstarted, err := slurmTasks.UnstartedArrayTasks(ctx, arrayJobID)
+	if err != nil {
+		return nil, err
+	}
+	allocated := make(map[string]bool)
+	var tasks []int
+	for _, task := range unstarted {
+		tasks = append(tasks, task.Task)
+		for _, node := range task.Nodes {
+			allocated[node] = true
+		}
+	}
+	for _, node := range nodes {
+		if !allocated[node] {
+			return nil, fmt.Errorf("node %s is not allocated to a task of array job %s", node, arrayJobID)
+		}
+	}
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("array job %s has no unstarted tasks", arrayJobID)
+	}
+
+	batchConfig := &c.cfg.BatchFallback
+	definition := newJobDefinition(batchConfig, job, tasks)
+	definitionARN, err := c.registerJobDefinition(ctx, definition)
+	if err != nil {
+		return nil, err
+	}
+	batchJobID, err := c.submitJob(ctx, "slurm-"+arrayJobID, batchConfig.JobQueue, definitionARN, len(tasks), jobTags(job))
+	if err != nil {
+		c.discardDefinition(ctx, definitionARN)
+		return nil, err
+	}
+
+	vcpus, memoryMB, gpus := taskResources(job)
+	record := &Record{
+		ArrayJobID:       arrayJobID,
+		JobName:          job.Name,
+		Partition:        job.Partition,
+		Account:          job.Account,
+		Tasks:            tasks,
+		Nodes:            nodes,
+		BatchJobID:       batchJobID,
+		JobQueue:         batchConfig.JobQueue,
+		JobDefinitionARN: definitionARN,
+		Platform:         batchConfig.Platform,
+		Image:            aws.ToString(definition.ContainerProperties.Image),
+		VCPUs:            vcpus,
+		MemoryMB:         memoryMB,
+		GPUs:             gpus,
+		SubmittedAt:      time.Now(),
+	}
+	path, err := exchange.Write(root, exchange.Batch, arrayJobID, record)
+	if err == nil {
+		if err = slurmTasks.CancelArrayTasks(ctx, arrayJobID, tasks); err != nil {
+			os.Remove(path)
+		}
+	}
+	if err != nil {
+		// The tasks must not run in both places
+		if terminateErr := c.terminateJob(ctx, batchJobID, "aws-slurm-burst could not hand off the Slurm tasks"); terminateErr != nil {
+			c.logger.Error("Failed to terminate AWS Batch job after a failed hand-off",
+				zap.String("batch_job_id", batchJobID), zap.Error(terminateErr))
+		}
+		c.discardDefinition(ctx, definitionARN)
+		return nil, err
+	}
+
+	c.logger.Info("Handed array job to AWS Batch",
+		zap.String("array_job_id", arrayJobID),
+		zap.String("batch_job_id", batchJobID),
+		zap.String("tasks", slurm.ArrayTaskRanges(tasks)),
+		zap.String("job_queue", batchConfig.JobQueue))
+	return record, nil
+}
+
+// Track checks the submitted jobs Batch has not finished yet, and records and
+// returns those it now has, priced at the configured rates
+func (c *Client) Track(ctx context.Context) ([]*Record, error) {
+	root := c.cfg.Ecosystem.DataExchangeDir
+	ids, err := exchange.List(root, exchange.Batch)
+	if err != nil {
+		return nil, err
+	}
+
+	var finished []*Record
+	for _, id := range ids {
+		record, err := ReadRecord(root, id)
+		if err != nil || record == nil || record.Done() {
+			if err != nil {
+				c.logger.Warn("Skipping unreadable AWS Batch record", zap.String("array_job_id", id), zap.Error(err))
+			}
+			continue
+		}
+
+		done, err := c.update(ctx, record)
+		if err != nil {
+			c.logger.Warn("Failed to check AWS Batch job", zap.String("batch_job_id", record.BatchJobID), zap.Error(err))
+			continue
+		}
+		if !done {
+			continue
+		}
+		if _, err := exchange.Write(root, exchange.Batch, id, record); err != nil {
+			return finished, err
+		}
+		c.discardDefinition(ctx, record.JobDefinitionARN)
+		finished = append(finished, record)
+	}
+	return finished, nil
+}
+
+// update fills in the record from its Batch job once the job has finished,
+// and reports whether it has
+func (c *Client) update(ctx context.Context, record *Record) (bool, error) {
+	job, err := c.describeJob(ctx, record.BatchJobID)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		// Batch forgets finished jobs after about a week
+		record.Status, record.StatusReason = StatusFailed, "job no longer known to AWS Batch"
+		record.Failed = len(record.Tasks)
+		return true, nil
+	}
+	if !job.terminal() {
+		return false, nil
+	}
+
+	record.Status, record.StatusReason = job.Status, job.StatusReason
+	tasks := []batchJob{*job}
+	if job.ArraySize > 0 {
+		if tasks, err = c.arrayChildren(ctx, job.JobID); err != nil {
+			return false, err
+		}
+	}
+	for _, task := range tasks {
+		if task.Status == StatusSucceeded {
+			record.Succeeded++
+		} else {
+			record.Failed++
+		}
+		if task.StartedAt == 0 || task.StoppedAt < task.StartedAt {
+			continue
+		}
+		start, end := time.UnixMilli(task.StartedAt), time.UnixMilli(task.StoppedAt)
+		if record.StartTime.IsZero() || start.Before(record.StartTime) {
+			record.StartTime = start
+		}
+		if end.After(record.EndTime) {
+			record.EndTime = end
+		}
+		record.TaskHours += end.Sub(start).Hours()
+	}
+	record.CostUSD = Cost(&c.cfg.BatchFallback, record)
+	return true, nil
+}
+
+// Cost prices the tasks' run time at the configured per-resource rates
+func Cost(cfg *config.BatchFallbackConfig, record *Record) float64 {
+	perTaskHour := float64(record.VCPUs)*cfg.VCPUHourUSD +
+		float64(record.MemoryMB)/1024*cfg.GBHourUSD +
+		float64(record.GPUs)*cfg.GPUHourUSD
+	return record.TaskHours * perTaskHour
+}
+
+// discardDefinition deregisters a job definition no job needs anymore
+func (c *Client) discardDefinition(ctx context.Context, arn string) {
+	if err := c.deregisterJobDefinition(ctx, arn); err != nil {
+		c.logger.Warn("Failed to deregister AWS Batch job definition", zap.Error(err))
+	}
+}
@@ -0,0 +1,205 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsBatch "github.com/aws/aws-sdk-go-v2/service/batch"
+	batchTypes "github.com/aws/aws-sdk-go-v2/service/batch/types"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeBatch runs submitted array jobs whose children each take an hour
+type fakeBatch struct {
+	definitions  map[string]*awsBatch.RegisterJobDefinitionInput // By ARN
+	deregistered []string
+	submitted    map[string]int // Array sizes by job ID
+	terminated   []string
+	finished     bool
+}
+
+func (f *fakeBatch) RegisterJobDefinition(ctx context.Context, params *awsBatch.RegisterJobDefinitionInput, optFns ...func(*awsBatch.Options)) (*awsBatch.RegisterJobDefinitionOutput, error) {
+	arn := "arn:aws:batch:us-east-1:123456789012:job-definition/" + aws.ToString(params.JobDefinitionName) + ":1"
+	f.definitions[arn] = params
+	return &awsBatch.RegisterJobDefinitionOutput{JobDefinitionArn: aws.String(arn)}, nil
+}
+
+func (f *fakeBatch) DeregisterJobDefinition(ctx context.Context, params *awsBatch.DeregisterJobDefinitionInput, optFns ...func(*awsBatch.Options)) (*awsBatch.DeregisterJobDefinitionOutput, error) {
+	f.deregistered = append(f.deregistered, aws.ToString(params.JobDefinition))
+	return &awsBatch.DeregisterJobDefinitionOutput{}, nil
+}
+
+func (f *fakeBatch) SubmitJob(ctx context.Context, params *awsBatch.SubmitJobInput, optFns ...func(*awsBatch.Options)) (*awsBatch.SubmitJobOutput, error) {
+	if _, ok := f.definitions[aws.ToString(params.JobDefinition)]; !ok {
+		return nil, &batchTypes.ClientException{Message: aws.String("job definition not found")}
+	}
+	size := 0
+	if params.ArrayProperties != nil {
+		size = int(aws.ToInt32(params.ArrayProperties.Size))
+	}
+	jobID := "job-" + aws.ToString(params.JobName)
+	f.submitted[jobID] = size
+	return &awsBatch.SubmitJobOutput{JobId: aws.String(jobID)}, nil
+}
+
+func (f *fakeBatch) TerminateJob(ctx context.Context, params *awsBatch.TerminateJobInput, optFns ...func(*awsBatch.Options)) (*awsBatch.TerminateJobOutput, error) {
+	f.terminated = append(f.terminated, aws.ToString(params.JobId))
+	return &awsBatch.TerminateJobOutput{}, nil
+}
+
+func (f *fakeBatch) DescribeJobs(ctx context.Context, params *awsBatch.DescribeJobsInput, optFns ...func(*awsBatch.Options)) (*awsBatch.DescribeJobsOutput, error) {
+	jobID := params.Jobs[0]
+	size, ok := f.submitted[jobID]
+	if !ok {
+		return &awsBatch.DescribeJobsOutput{}, nil
+	}
+	status := batchTypes.JobStatusRunning
+	if f.finished {
+		status = batchTypes.JobStatusFailed
+	}
+	return &awsBatch.DescribeJobsOutput{Jobs: []batchTypes.JobDetail{{
+		JobId:           aws.String(jobID),
+		Status:          status,
+		ArrayProperties: &batchTypes.ArrayPropertiesDetail{Size: aws.Int32(int32(size))},
+	}}}, nil
+}
+
+func (f *fakeBatch) ListJobs(ctx context.Context, params *awsBatch.ListJobsInput, optFns ...func(*awsBatch.Options)) (*awsBatch.ListJobsOutput, error) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC).UnixMilli()
+	jobID := aws.ToString(params.ArrayJobId)
+	output := &awsBatch.ListJobsOutput{}
+	for index := 0; index < f.submitted[jobID]; index++ {
+		// The last child fails
+		status := batchTypes.JobStatusSucceeded
+		if index == f.submitted[jobID]-1 {
+			status = batchTypes.JobStatusFailed
+		}
+		if status != params.JobStatus {
+			continue
+		}
+		output.JobSummaryList = append(output.JobSummaryList, batchTypes.JobSummary{
+			JobId:           aws.String(jobID + ":" + string(rune('0'+index))),
+			Status:          status,
+			StartedAt:       aws.Int64(start),
+			StoppedAt:       aws.Int64(start + time.Hour.Milliseconds()),
+			ArrayProperties: &batchTypes.ArrayPropertiesSummary{Index: aws.Int32(int32(index))},
+		})
+	}
+	return output, nil
+}
+
+// fakeTasks is an array job with one task configuring on two nodes and two pending
+type fakeTasks struct {
+	cancelled []int
+	cancelErr error
+}
+
+func (f *fakeTasks) UnstartedArrayTasks(ctx context.Context, arrayJobID string) ([]slurm.ArrayTask, error) {
+	return []slurm.ArrayTask{
+		{Task: 4, State: "CONFIGURING", Nodes: []string{"aws-cpu-001"}},
+		{Task: 5, State: "PENDING"},
+		{Task: 6, State: "PENDING"},
+	}, nil
+}
+
+func (f *fakeTasks) CancelArrayTasks(ctx context.Context, arrayJobID string, tasks []int) error {
+	if f.cancelErr != nil {
+		return f.cancelErr
+	}
+	f.cancelled = append(f.cancelled, tasks...)
+	return nil
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeBatch) {
+	batch := &fakeBatch{definitions: map[string]*awsBatch.RegisterJobDefinitionInput{}, submitted: map[string]int{}}
+
+	cfg := &config.Config{
+		Ecosystem: config.EcosystemConfig{DataExchangeDir: t.TempDir()},
+		BatchFallback: config.BatchFallbackConfig{
+			Enabled: true, JobQueue: "burst", Platform: config.BatchPlatformEC2, Image: "al2023",
+			VCPUHourUSD: 0.04, GBHourUSD: 0.005,
+		},
+	}
+	return newClient(zap.NewNop(), cfg, batch), batch
+}
+
+func arrayTask() *types.SlurmJob {
+	return &types.SlurmJob{
+		JobID:     "1234_4",
+		Name:      "sweep",
+		Partition: "aws",
+		Resources: types.ResourceSpec{Nodes: 1, CPUsPerNode: 2, MemoryMB: 4096},
+		Script:    "#!/bin/bash\n./simulate --seed $SLURM_ARRAY_TASK_ID\n",
+	}
+}
+
+func TestClient_SubmitAndTrack(t *testing.T) {
+	client, batch := newTestClient(t)
+	tasks := &fakeTasks{}
+	ctx := context.Background()
+
+	record, err := client.Submit(ctx, tasks, arrayTask(), []string{"aws-cpu-001"})
+	require.NoError(t, err)
+	assert.Equal(t, "job-slurm-1234", record.BatchJobID)
+	assert.Equal(t, []int{4, 5, 6}, record.Tasks)
+	assert.Equal(t, []int{4, 5, 6}, tasks.cancelled)
+	assert.Equal(t, 3, batch.submitted["job-slurm-1234"])
+
+	_, err = client.Submit(ctx, tasks, arrayTask(), []string{"aws-cpu-001"})
+	assert.ErrorContains(t, err, "already handed to AWS Batch")
+
+	finished, err := client.Track(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, finished, "still running")
+
+	batch.finished = true
+	finished, err = client.Track(ctx)
+	require.NoError(t, err)
+	require.Len(t, finished, 1)
+	done := finished[0]
+	assert.Equal(t, StatusFailed, done.Status)
+	assert.Equal(t, 2, done.Succeeded)
+	assert.Equal(t, 1, done.Failed)
+	assert.InDelta(t, 3.0, done.TaskHours, 1e-9)
+	assert.InDelta(t, 3*(2*0.04+4*0.005), done.CostUSD, 1e-9)
+	assert.Equal(t, time.Hour, done.EndTime.Sub(done.StartTime))
+	assert.Equal(t, []string{done.JobDefinitionARN}, batch.deregistered)
+
+	stored, err := ReadRecord(client.cfg.Ecosystem.DataExchangeDir, "1234")
+	require.NoError(t, err)
+	assert.True(t, stored.Done())
+	assert.True(t, stored.Includes(5))
+	assert.False(t, stored.Includes(3))
+
+	finished, err = client.Track(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, finished, "reported once")
+}
+
+func TestClient_SubmitRefusals(t *testing.T) {
+	client, batch := newTestClient(t)
+	ctx := context.Background()
+
+	_, err := client.Submit(ctx, &fakeTasks{}, arrayTask(), []string{"aws-cpu-001", "aws-cpu-002"})
+	assert.ErrorContains(t, err, "aws-cpu-002 is not allocated")
+	assert.Empty(t, batch.submitted)
+
+	// Tasks Slurm would not cancel must not also run on Batch
+	_, err = client.Submit(ctx, &fakeTasks{cancelErr: errors.New("scancel failed")}, arrayTask(), []string{"aws-cpu-001"})
+	assert.EqualError(t, err, "scancel failed")
+	assert.Equal(t, []string{"job-slurm-1234"}, batch.terminated)
+	assert.Len(t, batch.deregistered, 1)
+
+	ids, err := exchange.List(client.cfg.Ecosystem.DataExchangeDir, exchange.Batch)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
@@ -0,0 +1,170 @@
+package batch
+
+import (
+	"encoding/base64"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsBatch "github.com/aws/aws-sdk-go-v2/service/batch"
+	batchTypes "github.com/aws/aws-sdk-go-v2/service/batch/types"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// defaultMemoryMB is the memory a task gets when its job requested none
+const defaultMemoryMB = 2048
+
+// taskWrapper runs the batch script in the container. Batch numbers an array
+// job's children from 0, so the wrapper maps its index to the Slurm task ID
+// the script expects, expanding the task ranges with seq.
+const taskWrapper = `set -e
+tasks=($(for range in ${ASB_ARRAY_TASKS//,/ }; do seq "${range%-*}" "${range#*-}"; done))
+export SLURM_ARRAY_TASK_ID=${tasks[${AWS_BATCH_JOB_ARRAY_INDEX:-0}]}
+export SLURM_JOB_ID=${SLURM_ARRAY_JOB_ID}_${SLURM_ARRAY_TASK_ID}
+script=$(mktemp)
+echo "$ASB_JOB_SCRIPT" | base64 -d > "$script"
+chmod +x "$script"
+exec "$script"`
+
+// Eligible returns why the job cannot run on AWS Batch, or "" when it can:
+// it must be a task of an array job in an allowed partition whose tasks each
+// fit on one node, run no MPI and name none of the shared filesystems
+func Eligible(cfg *config.BatchFallbackConfig, job *types.SlurmJob) string {
+	switch {
+	case job == nil:
+		return "no pending job found"
+	case job.ArrayJobID() == "":
+		return "not an array job"
+	case !cfg.AllowsPartition(job.Partition):
+		return "partition not in batch_fallback.partitions"
+	case job.IsMPIJob || job.WorkloadClass == types.WorkloadMPI || job.WorkloadClass == types.WorkloadDistributedML:
+		return "tasks are tightly coupled"
+	case job.Resources.Nodes > 1:
+		return "tasks span several nodes"
+	case job.Resources.GPUs > 0 && cfg.Platform == config.BatchPlatformFargate:
+		return "Fargate has no GPUs"
+	case job.Script == "":
+		return "batch script unavailable"
+	case cfg.MaxScriptBytes > 0 && len(job.Script) > cfg.MaxScriptBytes:
+		return "batch script too large"
+	}
+	if shared := sharedPath(cfg.SharedPaths, job.Script); shared != "" {
+		return "script uses shared filesystem " + shared
+	}
+	return ""
+}
+
+// pathReference matches absolute paths in a script
+var pathReference = regexp.MustCompile(`/[^\s"'$;|&<>()]*`)
+
+// sharedPath returns the first shared filesystem the script names a path on.
+// $HOME and ~ count as /home.
+func sharedPath(sharedPaths []string, script string) string {
+	references := pathReference.FindAllString(script, -1)
+	for _, shared := range sharedPaths {
+		shared = path.Clean(shared)
+		for _, reference := range references {
+			if reference == shared || strings.HasPrefix(reference, shared+"/") {
+				return shared
+			}
+		}
+		if shared == "/home" && (strings.Contains(script, "$HOME") || strings.Contains(script, "${HOME}") || strings.Contains(script, "~/")) {
+			return shared
+		}
+	}
+	return ""
+}
+
+// newJobDefinition generates a job definition that runs the job's batch
+// script as each of tasks, sized for one task
+func newJobDefinition(cfg *config.BatchFallbackConfig, job *types.SlurmJob, tasks []int) *awsBatch.RegisterJobDefinitionInput {
+	arrayJobID := job.ArrayJobID()
+	vcpus, memoryMB, gpus := taskResources(job)
+
+	resources := []batchTypes.ResourceRequirement{
+		{Type: batchTypes.ResourceTypeVcpu, Value: aws.String(strconv.Itoa(vcpus))},
+		{Type: batchTypes.ResourceTypeMemory, Value: aws.String(strconv.Itoa(memoryMB))},
+	}
+	if gpus > 0 {
+		resources = append(resources, batchTypes.ResourceRequirement{Type: batchTypes.ResourceTypeGpu, Value: aws.String(strconv.Itoa(gpus))})
+	}
+
+	first, last := tasks[0], tasks[0]
+	for _, task := range tasks {
+		first, last = min(first, task), max(last, task)
+	}
+	variable := func(name, value string) batchTypes.KeyValuePair {
+		return batchTypes.KeyValuePair{Name: aws.String(name), Value: aws.String(value)}
+	}
+	environment := []batchTypes.KeyValuePair{
+		variable("ASB_JOB_SCRIPT", base64.StdEncoding.EncodeToString([]byte(job.Script))),
+		variable("ASB_ARRAY_TASKS", slurm.ArrayTaskRanges(tasks)),
+		variable("SLURM_ARRAY_JOB_ID", arrayJobID),
+		variable("SLURM_ARRAY_TASK_COUNT", strconv.Itoa(len(tasks))),
+		variable("SLURM_ARRAY_TASK_MIN", strconv.Itoa(first)),
+		variable("SLURM_ARRAY_TASK_MAX", strconv.Itoa(last)),
+		variable("SLURM_JOB_NAME", job.Name),
+		variable("SLURM_JOB_PARTITION", job.Partition),
+		variable("SLURM_JOB_ACCOUNT", job.Account),
+		variable("SLURM_CPUS_ON_NODE", strconv.Itoa(vcpus)),
+	}
+
+	definition := &awsBatch.RegisterJobDefinitionInput{
+		JobDefinitionName:    aws.String("aws-slurm-burst-job-" + arrayJobID),
+		Type:                 batchTypes.JobDefinitionTypeContainer,
+		PlatformCapabilities: []batchTypes.PlatformCapability{batchTypes.PlatformCapability(cfg.Platform)},
+		ContainerProperties: &batchTypes.ContainerProperties{
+			Image:                aws.String(taskImage(cfg, job)),
+			Command:              []string{"/bin/bash", "-c", taskWrapper},
+			ResourceRequirements: resources,
+			Environment:          environment,
+		},
+		RetryStrategy: &batchTypes.RetryStrategy{Attempts: aws.Int32(1)}, // Slurm would not rerun a failed task either
+		Tags:          jobTags(job),
+		PropagateTags: aws.Bool(true),
+	}
+	if cfg.JobRoleARN != "" {
+		definition.ContainerProperties.JobRoleArn = aws.String(cfg.JobRoleARN)
+	}
+	if cfg.ExecutionRoleARN != "" {
+		definition.ContainerProperties.ExecutionRoleArn = aws.String(cfg.ExecutionRoleARN)
+	}
+	if limit := time.Duration(job.TimeLimit); limit > 0 {
+		// Batch kills attempts after at least a minute
+		definition.Timeout = &batchTypes.JobTimeout{AttemptDurationSeconds: aws.Int32(int32(max(int(limit.Seconds()), 60)))}
+	}
+	return definition
+}
+
+// taskResources returns the vCPUs, memory in MiB and GPUs one task needs
+func taskResources(job *types.SlurmJob) (int, int, int) {
+	memoryMB := job.Resources.NodeMemoryMB()
+	if memoryMB <= 0 {
+		memoryMB = defaultMemoryMB
+	}
+	return max(job.Resources.CPUsPerNode, 1), memoryMB, job.Resources.GPUs
+}
+
+// taskImage is the container image the job asked pyxis for, when it names a
+// registry image, or else the configured one
+func taskImage(cfg *config.BatchFallbackConfig, job *types.SlurmJob) string {
+	if job.Container == "" || strings.HasPrefix(job.Container, "/") || strings.HasPrefix(job.Container, ".") {
+		return cfg.Image
+	}
+	// pyxis separates the registry with # where Docker uses /
+	return strings.Replace(job.Container, "#", "/", 1)
+}
+
+// jobTags tag the job definition and, propagated, the tasks' ECS resources
+func jobTags(job *types.SlurmJob) map[string]string {
+	return map[string]string{
+		"Partition": job.Partition,
+		"ManagedBy": "aws-slurm-burst",
+		"JobID":     job.ArrayJobID(),
+	}
+}
@@ -0,0 +1,95 @@
+package batch
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	batchTypes "github.com/aws/aws-sdk-go-v2/service/batch/types"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEligible(t *testing.T) {
+	cfg := &config.BatchFallbackConfig{Platform: config.BatchPlatformEC2, SharedPaths: []string{"/home", "/fsx/"}, MaxScriptBytes: 1024}
+	assert.Equal(t, "", Eligible(cfg, arrayTask()))
+
+	for reason, modify := range map[string]func(job *types.SlurmJob){
+		"not an array job":                           func(job *types.SlurmJob) { job.JobID = "1234" },
+		"tasks are tightly coupled":                  func(job *types.SlurmJob) { job.IsMPIJob = true },
+		"tasks span several nodes":                   func(job *types.SlurmJob) { job.Resources.Nodes = 2 },
+		"batch script unavailable":                   func(job *types.SlurmJob) { job.Script = "" },
+		"script uses shared filesystem /fsx":         func(job *types.SlurmJob) { job.Script += "cp out.dat /fsx/results/\n" },
+		"script uses shared filesystem /home":        func(job *types.SlurmJob) { job.Script += "cd $HOME/project\n" },
+		"batch script too large":                     func(job *types.SlurmJob) { job.Script += string(make([]byte, 1024)) },
+		"partition not in batch_fallback.partitions": func(job *types.SlurmJob) { job.Partition = "gpu" },
+	} {
+		job := arrayTask()
+		modify(job)
+		limited := *cfg
+		limited.Partitions = []string{"aws"}
+		assert.Equal(t, reason, Eligible(&limited, job))
+	}
+
+	// Paths that merely share a prefix with a shared filesystem are not on it
+	job := arrayTask()
+	job.Script += "ls /homework /fsxdata\n"
+	assert.Equal(t, "", Eligible(cfg, job))
+
+	gpu := arrayTask()
+	gpu.Resources.GPUs = 1
+	assert.Equal(t, "", Eligible(cfg, gpu))
+	assert.Equal(t, "Fargate has no GPUs", Eligible(&config.BatchFallbackConfig{Platform: config.BatchPlatformFargate}, gpu))
+}
+
+func TestNewJobDefinition(t *testing.T) {
+	cfg := &config.BatchFallbackConfig{Platform: config.BatchPlatformFargate, Image: "al2023", ExecutionRoleARN: "arn:aws:iam::123456789012:role/exec"}
+	job := arrayTask()
+	job.TimeLimit = types.Duration(30 * time.Second)
+	job.Resources = types.ResourceSpec{Nodes: 1, CPUsPerNode: 4, MemoryPerCPUMB: 2048}
+
+	definition := newJobDefinition(cfg, job, []int{4, 5, 6, 9})
+	assert.Equal(t, "aws-slurm-burst-job-1234", aws.ToString(definition.JobDefinitionName))
+	assert.Equal(t, []batchTypes.PlatformCapability{batchTypes.PlatformCapabilityFargate}, definition.PlatformCapabilities)
+	assert.Equal(t, "al2023", aws.ToString(definition.ContainerProperties.Image))
+	assert.Equal(t, "arn:aws:iam::123456789012:role/exec", aws.ToString(definition.ContainerProperties.ExecutionRoleArn))
+	assert.Nil(t, definition.ContainerProperties.JobRoleArn)
+	assert.Equal(t, []batchTypes.ResourceRequirement{
+		{Type: batchTypes.ResourceTypeVcpu, Value: aws.String("4")},
+		{Type: batchTypes.ResourceTypeMemory, Value: aws.String("8192")},
+	}, definition.ContainerProperties.ResourceRequirements)
+	assert.Equal(t, int32(60), aws.ToInt32(definition.Timeout.AttemptDurationSeconds), "Batch's minimum")
+	assert.Contains(t, definition.ContainerProperties.Environment, batchTypes.KeyValuePair{Name: aws.String("ASB_ARRAY_TASKS"), Value: aws.String("4-6,9")})
+	assert.Contains(t, definition.ContainerProperties.Environment, batchTypes.KeyValuePair{Name: aws.String("SLURM_ARRAY_TASK_MAX"), Value: aws.String("9")})
+
+	job.Container = "nvcr.io#nvidia/pytorch:24.01-py3"
+	assert.Equal(t, "nvcr.io/nvidia/pytorch:24.01-py3", aws.ToString(newJobDefinition(cfg, job, []int{4}).ContainerProperties.Image))
+	job.Container = "/shared/images/pytorch.sqsh"
+	assert.Equal(t, "al2023", aws.ToString(newJobDefinition(cfg, job, []int{4}).ContainerProperties.Image))
+}
+
+func TestTaskWrapper(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+
+	job := arrayTask()
+	job.Script = "#!/bin/sh\necho \"$SLURM_JOB_ID $SLURM_ARRAY_TASK_ID\"\n"
+	definition := newJobDefinition(&config.BatchFallbackConfig{Platform: config.BatchPlatformEC2}, job, []int{4, 5, 6, 9})
+	env := os.Environ()
+	for _, variable := range definition.ContainerProperties.Environment {
+		env = append(env, aws.ToString(variable.Name)+"="+aws.ToString(variable.Value))
+	}
+
+	// Batch's child 3 is the fourth task handed off
+	command := exec.Command(bash, "-c", taskWrapper)
+	command.Env = append(env, "AWS_BATCH_JOB_ARRAY_INDEX=3")
+	output, err := command.CombinedOutput()
+	require.NoError(t, err, string(output))
+	assert.Equal(t, "1234_9 9\n", string(output))
+}
@@ -0,0 +1,226 @@
+// Package bootdiagnostics saves the EC2 console output of burst nodes that
+// fail to come up: nodes failing their health checks, and nodes Slurm gave up
+// on because they never registered or stopped responding. The output is
+// captured before the instance is terminated, written as one JSON record per
+// instance and passed to a notify command, so boot failures can be diagnosed
+// after the instance is gone.
+package bootdiagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Why a node's console output was captured
+const (
+	EventHealthCheckFailed = "health_check_failed"
+	EventNotResponding     = "not_responding"
+	EventResumeTimeout     = "resume_timeout"
+)
+
+// notifyTailLines is how much of the console output the notify command gets
+// in its environment; the record has all of it
+const notifyTailLines = 40
+
+// ConsoleReader reads an instance's console output; aws.Client in production
+type ConsoleReader interface {
+	GetConsoleOutput(ctx context.Context, partition, nodeGroup, instanceID string) (string, error)
+}
+
+// Failure is a node whose instance failed to come up
+type Failure struct {
+	Event    string
+	Reason   string
+	Instance types.InstanceInfo
+}
+
+// Record is the saved diagnosis of one failed instance
+type Record struct {
+	Event            string    `json:"event"`
+	Reason           string    `json:"reason,omitempty"`
+	Node             string    `json:"node"`
+	InstanceID       string    `json:"instance_id"`
+	InstanceType     string    `json:"instance_type,omitempty"`
+	Partition        string    `json:"partition,omitempty"`
+	NodeGroup        string    `json:"node_group,omitempty"`
+	AvailabilityZone string    `json:"availability_zone,omitempty"`
+	LaunchTime       string    `json:"launch_time,omitempty"`
+	CapturedAt       time.Time `json:"captured_at"`
+	ConsoleOutput    string    `json:"console_output"`
+	CaptureError     string    `json:"capture_error,omitempty"` // Why the console output could not be read
+}
+
+// Recorder captures and saves the console output of failed instances
+type Recorder struct {
+	config  *config.BootDiagnosticsConfig
+	console ConsoleReader
+	now     func() time.Time
+}
+
+// NewRecorder creates a recorder writing to the configured directory
+func NewRecorder(diagnostics *config.BootDiagnosticsConfig, console ConsoleReader) *Recorder {
+	return &Recorder{config: diagnostics, console: console, now: time.Now}
+}
+
+// Path returns the record file of a node's instance
+func Path(dir, node, instanceID string) string {
+	return filepath.Join(dir, node+"-"+instanceID+".json")
+}
+
+// Capture saves a record for each failure whose instance has none yet and runs
+// the notify command for it. A console output that cannot be read is noted in
+// the record rather than failing it. It returns the records written.
+func (r *Recorder) Capture(ctx context.Context, failures []Failure) ([]string, error) {
+	if err := os.MkdirAll(r.config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create boot diagnostics directory: %w", err)
+	}
+
+	var written []string
+	var errs []error
+	for _, failure := range failures {
+		instance := failure.Instance
+		path := Path(r.config.Dir, instance.NodeName, instance.InstanceID)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+
+		record := &Record{
+			Event:            failure.Event,
+			Reason:           failure.Reason,
+			Node:             instance.NodeName,
+			InstanceID:       instance.InstanceID,
+			InstanceType:     instance.InstanceType,
+			Partition:        instance.Partition,
+			NodeGroup:        instance.NodeGroup,
+			AvailabilityZone: instance.AvailabilityZone,
+			LaunchTime:       instance.LaunchTime,
+			CapturedAt:       r.now().UTC(),
+		}
+		output, err := r.console.GetConsoleOutput(ctx, instance.Partition, instance.NodeGroup, instance.InstanceID)
+		if err != nil {
+			record.CaptureError = err.Error()
+		} else if output == "" {
+			record.CaptureError = "EC2 has no console output for the instance yet"
+		}
+		record.ConsoleOutput = strings.ReplaceAll(output, "\r", "")
+
+		if err := writeRecord(path, record); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		written = append(written, path)
+		if err := Notify(ctx, r.config.NotifyCommand, record, path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", instance.NodeName, err))
+		}
+	}
+	return written, errors.Join(errs...)
+}
+
+// writeRecord writes a record through a temporary file renamed into place
+func writeRecord(path string, record *Record) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write boot diagnostics %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write boot diagnostics %s: %w", path, err)
+	}
+	return nil
+}
+
+// Notify runs the notify command for a record through the shell, with the
+// failure, the record's path and the end of the console output in its
+// environment
+func Notify(ctx context.Context, command string, record *Record, path string) error {
+	if command == "" {
+		return nil
+	}
+	consoleTail := record.ConsoleOutput
+	if lines := strings.Split(strings.TrimRight(consoleTail, "\n"), "\n"); len(lines) > notifyTailLines {
+		consoleTail = strings.Join(lines[len(lines)-notifyTailLines:], "\n") + "\n"
+	}
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"AWS_SLURM_BURST_BOOT_EVENT="+record.Event,
+		"AWS_SLURM_BURST_NODE="+record.Node,
+		"AWS_SLURM_BURST_INSTANCE_ID="+record.InstanceID,
+		"AWS_SLURM_BURST_PARTITION="+record.Partition,
+		"AWS_SLURM_BURST_NODE_GROUP="+record.NodeGroup,
+		"AWS_SLURM_BURST_REASON="+record.Reason,
+		"AWS_SLURM_BURST_RECORD="+path,
+		"AWS_SLURM_BURST_CONSOLE_TAIL="+consoleTail,
+		"AWS_SLURM_BURST_CAPTURE_ERROR="+record.CaptureError)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notify command failed: %w: %s", err, output)
+	}
+	return nil
+}
+
+// Cleanup removes records older than retention and returns their paths
+func Cleanup(dir string, retention time.Duration, now time.Time) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || now.Sub(info.ModTime()) <= retention {
+			continue
+		}
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+		removed = append(removed, file)
+	}
+	return removed, nil
+}
+
+// Unresponsive reports whether Slurm gave up on a node, either because it did
+// not register within ResumeTimeout or because it stopped responding, and the
+// event to record
+func Unresponsive(nodeInfo slurm.NodeInfo) (string, bool) {
+	if strings.Contains(nodeInfo.Reason, "ResumeTimeout") {
+		return EventResumeTimeout, true
+	}
+	for _, flag := range strings.Split(nodeInfo.State, "+") {
+		if strings.HasSuffix(flag, "*") || strings.EqualFold(flag, "NOT_RESPONDING") {
+			return EventNotResponding, true
+		}
+	}
+	return "", false
+}
+
+// UnresponsiveFailures returns the failures of the nodes Slurm gave up on that
+// still have a live instance
+func UnresponsiveFailures(nodeStates []slurm.NodeInfo, instances []types.InstanceInfo) []Failure {
+	events, reasons := make(map[string]string), make(map[string]string)
+	for _, nodeInfo := range nodeStates {
+		if event, ok := Unresponsive(nodeInfo); ok {
+			events[nodeInfo.NodeName], reasons[nodeInfo.NodeName] = event, nodeInfo.Reason
+		}
+	}
+	var failures []Failure
+	for _, instance := range instances {
+		if event, ok := events[instance.NodeName]; ok && (instance.State == "pending" || instance.State == "running") {
+			failures = append(failures, Failure{Event: event, Reason: reasons[instance.NodeName], Instance: instance})
+		}
+	}
+	return failures
+}
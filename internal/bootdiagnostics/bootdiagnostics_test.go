@@ -0,0 +1,133 @@
+package bootdiagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConsole struct {
+	output map[string]string
+	reads  int
+}
+
+func (f *fakeConsole) GetConsoleOutput(ctx context.Context, partition, nodeGroup, instanceID string) (string, error) {
+	f.reads++
+	output, ok := f.output[instanceID]
+	if !ok {
+		return "", errors.New("InvalidInstanceID.NotFound")
+	}
+	return output, nil
+}
+
+func TestRecorder_Capture(t *testing.T) {
+	dir := t.TempDir()
+	notified := filepath.Join(dir, "notified")
+	console := &fakeConsole{output: map[string]string{
+		"i-1": "[    0.0] Linux version 6.1\r\n" + strings.Repeat("cloud-init: retrying\n", 50) + "cloud-init: failed to mount /scratch\n",
+		"i-2": "",
+	}}
+	recorder := NewRecorder(&config.BootDiagnosticsConfig{
+		Dir:           filepath.Join(dir, "records"),
+		NotifyCommand: `echo "$AWS_SLURM_BURST_BOOT_EVENT $AWS_SLURM_BURST_NODE $AWS_SLURM_BURST_RECORD" >> ` + notified + `; echo "$AWS_SLURM_BURST_CONSOLE_TAIL" | wc -l >> ` + notified,
+	}, console)
+	recorder.now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+
+	failures := []Failure{
+		{Event: EventHealthCheckFailed, Reason: "scratch: /scratch is not mounted",
+			Instance: types.InstanceInfo{NodeName: "aws-cpu-001", InstanceID: "i-1", Partition: "aws", NodeGroup: "cpu", LaunchTime: "2026-10-18T11:50:00Z"}},
+		{Event: EventResumeTimeout, Instance: types.InstanceInfo{NodeName: "aws-cpu-002", InstanceID: "i-2"}},
+		{Event: EventNotResponding, Instance: types.InstanceInfo{NodeName: "aws-cpu-003", InstanceID: "i-3"}},
+	}
+	written, err := recorder.Capture(context.Background(), failures)
+	require.NoError(t, err)
+	require.Len(t, written, 3)
+	assert.Equal(t, Path(filepath.Join(dir, "records"), "aws-cpu-001", "i-1"), written[0])
+
+	data, err := os.ReadFile(written[0])
+	require.NoError(t, err)
+	var record Record
+	require.NoError(t, json.Unmarshal(data, &record))
+	assert.Equal(t, EventHealthCheckFailed, record.Event)
+	assert.Equal(t, "scratch: /scratch is not mounted", record.Reason)
+	assert.Equal(t, "i-1", record.InstanceID)
+	assert.Equal(t, "2026-10-18T12:00:00Z", record.CapturedAt.Format(time.RFC3339))
+	assert.True(t, strings.HasPrefix(record.ConsoleOutput, "[    0.0] Linux version 6.1\n"), "carriage returns removed")
+	assert.Empty(t, record.CaptureError)
+
+	data, err = os.ReadFile(written[1])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "EC2 has no console output for the instance yet")
+	data, err = os.ReadFile(written[2])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "InvalidInstanceID.NotFound")
+
+	data, err = os.ReadFile(notified)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 6)
+	assert.Equal(t, "health_check_failed aws-cpu-001 "+written[0], lines[0])
+	assert.Equal(t, "41", strings.TrimSpace(lines[1]), "the last 40 lines and echo's newline")
+
+	// Instances already recorded are skipped
+	written, err = recorder.Capture(context.Background(), failures)
+	require.NoError(t, err)
+	assert.Empty(t, written)
+	assert.Equal(t, 3, console.reads)
+}
+
+func TestCleanup(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old, recent := filepath.Join(dir, "aws-cpu-001-i-1.json"), filepath.Join(dir, "aws-cpu-002-i-2.json")
+	for _, file := range []string{old, recent} {
+		require.NoError(t, os.WriteFile(file, []byte("{}"), 0644))
+	}
+	require.NoError(t, os.Chtimes(old, now.Add(-31*24*time.Hour), now.Add(-31*24*time.Hour)))
+
+	removed, err := Cleanup(dir, 30*24*time.Hour, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{old}, removed)
+	assert.FileExists(t, recent)
+}
+
+func TestUnresponsive(t *testing.T) {
+	tests := []struct {
+		node  slurm.NodeInfo
+		event string
+	}{
+		{slurm.NodeInfo{State: "DOWN+CLOUD+POWERED_DOWN", Reason: "ResumeTimeout reached"}, EventResumeTimeout},
+		{slurm.NodeInfo{State: "DOWN*+CLOUD"}, EventNotResponding},
+		{slurm.NodeInfo{State: "IDLE+CLOUD+NOT_RESPONDING"}, EventNotResponding},
+		{slurm.NodeInfo{State: "IDLE+CLOUD+POWERING_UP"}, ""},
+		{slurm.NodeInfo{State: "ALLOCATED+CLOUD"}, ""},
+	}
+	for _, tt := range tests {
+		event, ok := Unresponsive(tt.node)
+		assert.Equal(t, tt.event, event, tt.node.State)
+		assert.Equal(t, tt.event != "", ok, tt.node.State)
+	}
+
+	failures := UnresponsiveFailures([]slurm.NodeInfo{
+		{NodeName: "aws-cpu-001", State: "DOWN+CLOUD", Reason: "ResumeTimeout reached"},
+		{NodeName: "aws-cpu-002", State: "IDLE*+CLOUD"},
+		{NodeName: "aws-cpu-003", State: "IDLE+CLOUD"},
+	}, []types.InstanceInfo{
+		{NodeName: "aws-cpu-001", InstanceID: "i-1", State: "running"},
+		{NodeName: "aws-cpu-002", InstanceID: "i-2", State: "stopped"},
+		{NodeName: "aws-cpu-003", InstanceID: "i-3", State: "running"},
+	})
+	assert.Equal(t, []Failure{{Event: EventResumeTimeout, Reason: "ResumeTimeout reached",
+		Instance: types.InstanceInfo{NodeName: "aws-cpu-001", InstanceID: "i-1", State: "running"}}}, failures)
+}
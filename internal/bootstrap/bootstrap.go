@@ -0,0 +1,328 @@
+// Package bootstrap backs aws-slurm-burst-init. It discovers a region's default
+// network and EFA-capable instance types, asks the few questions a first
+// deployment needs, and writes a validated configuration with a matching
+// slurm.conf snippet.
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// defaultInstanceType is offered when it supports EFA in the region, or when
+// nothing could be discovered
+const defaultInstanceType = "c5n.18xlarge"
+
+// realMemoryPercent of an instance's memory is given to Slurm as RealMemory;
+// the rest is left for the OS so slurmd does not drain the node
+const realMemoryPercent = 95
+
+// Discovery looks up what the wizard offers as defaults; aws.Diagnostics implements it
+type Discovery interface {
+	DefaultVPC(ctx context.Context, region string) (*aws.DefaultVPC, error)
+	EFAInstanceTypes(ctx context.Context, region string) ([]types.InstanceTypeInfo, error)
+	InstanceTypes(ctx context.Context, region string, instanceTypes []string) ([]types.InstanceTypeInfo, error)
+}
+
+// Answers hold everything the generated files depend on. Fields set before
+// Run are offered as defaults.
+type Answers struct {
+	Region             string
+	PartitionName      string
+	NodeGroupName      string
+	MaxNodes           int
+	PurchasingOption   string
+	InstanceTypes      []string
+	SubnetIDs          []string
+	SecurityGroupIDs   []string
+	LaunchTemplateName string
+	IAMInstanceProfile string
+
+	// Node sizes for slurm.conf, from the smallest instance type; zero leaves them to Slurm
+	CPUs          int
+	RealMemoryMiB int
+
+	ConfigPath string // Where the configuration is installed, for ResumeProgram
+	BinDir     string // Where the aws-slurm-burst binaries are installed
+}
+
+// DefaultAnswers are the answers offered before anything is discovered
+func DefaultAnswers(region, configPath string) *Answers {
+	return &Answers{
+		Region:           region,
+		PartitionName:    "aws",
+		NodeGroupName:    "cpu",
+		MaxNodes:         10,
+		PurchasingOption: "on-demand",
+		InstanceTypes:    []string{defaultInstanceType},
+		ConfigPath:       configPath,
+		BinDir:           "/usr/local/bin",
+	}
+}
+
+// Run asks the questions, offering discovered resources as defaults. A nil
+// discovery, or one that fails, leaves the user to type them in.
+func Run(ctx context.Context, discovery Discovery, prompter *Prompter, answers *Answers) error {
+	var err error
+	if answers.Region, err = prompter.Ask("AWS region", answers.Region, required); err != nil {
+		return err
+	}
+
+	if discovery != nil {
+		discoverNetwork(ctx, discovery, prompter, answers)
+		discoverInstanceTypes(ctx, discovery, prompter, answers)
+	}
+
+	if answers.PartitionName, err = prompter.Ask("Slurm partition name", answers.PartitionName, alphanumeric); err != nil {
+		return err
+	}
+	if answers.NodeGroupName, err = prompter.Ask("Node group name", answers.NodeGroupName, alphanumeric); err != nil {
+		return err
+	}
+	if answers.MaxNodes, err = prompter.AskInt("Maximum nodes", answers.MaxNodes); err != nil {
+		return err
+	}
+	if answers.PurchasingOption, err = prompter.Ask("Purchasing option (spot or on-demand)", answers.PurchasingOption, oneOf("spot", "on-demand")); err != nil {
+		return err
+	}
+	if answers.InstanceTypes, err = prompter.AskList("Instance types", answers.InstanceTypes); err != nil {
+		return err
+	}
+	if answers.SubnetIDs, err = prompter.AskList("Subnet IDs", answers.SubnetIDs); err != nil {
+		return err
+	}
+	if answers.SecurityGroupIDs, err = prompter.AskList("Security group IDs", answers.SecurityGroupIDs); err != nil {
+		return err
+	}
+	if answers.LaunchTemplateName, err = prompter.Ask("Launch template name", answers.LaunchTemplateName, required); err != nil {
+		return err
+	}
+	if answers.IAMInstanceProfile, err = prompter.Ask("IAM instance profile (optional)", answers.IAMInstanceProfile, nil); err != nil {
+		return err
+	}
+
+	if discovery != nil {
+		sizeNodes(ctx, discovery, prompter, answers)
+	}
+	return nil
+}
+
+// discoverNetwork offers the default VPC's subnets and security group
+func discoverNetwork(ctx context.Context, discovery Discovery, prompter *Prompter, answers *Answers) {
+	vpc, err := discovery.DefaultVPC(ctx, answers.Region)
+	switch {
+	case err != nil:
+		prompter.Printf("Could not discover the default VPC: %v\n", err)
+		return
+	case vpc == nil:
+		prompter.Printf("No default VPC in %s; enter the subnets and security groups to use.\n", answers.Region)
+		return
+	}
+
+	prompter.Printf("Default VPC %s:\n", vpc.VPCID)
+	answers.SubnetIDs = nil
+	for _, subnet := range vpc.Subnets {
+		prompter.Printf("  %s  %s  %d free IPs\n", subnet.SubnetID, subnet.AvailabilityZone, subnet.FreeIPs)
+		answers.SubnetIDs = append(answers.SubnetIDs, subnet.SubnetID)
+	}
+	if vpc.SecurityGroupID != "" {
+		answers.SecurityGroupIDs = []string{vpc.SecurityGroupID}
+	}
+}
+
+// discoverInstanceTypes lists the EFA-capable instance types and offers one
+func discoverInstanceTypes(ctx context.Context, discovery Discovery, prompter *Prompter, answers *Answers) {
+	efaTypes, err := discovery.EFAInstanceTypes(ctx, answers.Region)
+	if err != nil {
+		prompter.Printf("Could not list EFA-capable instance types: %v\n", err)
+		return
+	}
+	if len(efaTypes) == 0 {
+		prompter.Printf("No EFA-capable instance types in %s.\n", answers.Region)
+		return
+	}
+
+	names := make([]string, len(efaTypes))
+	for i, info := range efaTypes {
+		names[i] = info.InstanceType
+	}
+	prompter.Printf("EFA-capable instance types in %s:\n  %s\n", answers.Region, strings.Join(names, " "))
+
+	// Keep the default when it is among them, otherwise offer the first
+	for _, name := range names {
+		if len(answers.InstanceTypes) > 0 && name == answers.InstanceTypes[0] {
+			return
+		}
+	}
+	answers.InstanceTypes = []string{names[0]}
+}
+
+// sizeNodes sets the Slurm node size from the smallest chosen instance type,
+// since Slurm drains nodes that have fewer CPUs or less memory than declared
+func sizeNodes(ctx context.Context, discovery Discovery, prompter *Prompter, answers *Answers) {
+	infos, err := discovery.InstanceTypes(ctx, answers.Region, answers.InstanceTypes)
+	if err != nil {
+		prompter.Printf("Could not describe the instance types, leaving CPUs and RealMemory to Slurm: %v\n", err)
+		return
+	}
+
+	found := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		found[info.InstanceType] = true
+		realMemory := info.MemoryMiB * realMemoryPercent / 100
+		if answers.CPUs == 0 || info.VCPUs < answers.CPUs {
+			answers.CPUs = info.VCPUs
+		}
+		if answers.RealMemoryMiB == 0 || realMemory < answers.RealMemoryMiB {
+			answers.RealMemoryMiB = realMemory
+		}
+	}
+	for _, instanceType := range answers.InstanceTypes {
+		if !found[instanceType] {
+			prompter.Printf("Warning: %s is not an instance type in %s\n", instanceType, answers.Region)
+		}
+	}
+}
+
+// configTemplate renders the answers as a configuration; every string is
+// quoted so IDs and names survive YAML parsing unchanged
+var configTemplate = template.Must(template.New("config").Funcs(template.FuncMap{"quote": strconv.Quote}).Parse(
+	`# Generated by aws-slurm-burst-init. Every other setting keeps its default;
+# see docs/DEPLOYMENT.md.
+aws:
+  region: {{quote .Region}}
+
+slurm:
+  partitions:
+    - partition_name: {{quote .PartitionName}}
+      node_groups:
+        - node_group_name: {{quote .NodeGroupName}}
+          max_nodes: {{.MaxNodes}}
+          region: {{quote .Region}}
+          purchasing_option: {{quote .PurchasingOption}}
+          launch_template_specification:
+            launch_template_name: {{quote .LaunchTemplateName}}
+          launch_template_overrides:
+{{- range .InstanceTypes}}
+            - instance_type: {{quote .}}
+{{- end}}
+          subnet_ids:
+{{- range .SubnetIDs}}
+            - {{quote .}}
+{{- end}}
+{{- if .SecurityGroupIDs}}
+          security_group_ids:
+{{- range .SecurityGroupIDs}}
+            - {{quote .}}
+{{- end}}
+{{- end}}
+{{- if .IAMInstanceProfile}}
+          iam_instance_profile: {{quote .IAMInstanceProfile}}
+{{- end}}
+`))
+
+// RenderConfig returns the configuration file for the answers
+func RenderConfig(answers *Answers) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := configTemplate.Execute(&buf, answers); err != nil {
+		return nil, fmt.Errorf("failed to render configuration: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderSlurmConf returns the slurm.conf lines that hand cfg's node groups to
+// aws-slurm-burst
+func RenderSlurmConf(cfg *config.Config, answers *Answers) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by aws-slurm-burst-init for %s\n", answers.ConfigPath)
+	fmt.Fprintf(&b, "# Merge into slurm.conf, then restart slurmctld.\n")
+	fmt.Fprintf(&b, "PrivateData=cloud\n")
+	fmt.Fprintf(&b, "ResumeProgram=%s --config=%s\n", filepath.Join(answers.BinDir, "aws-slurm-burst-resume"), answers.ConfigPath)
+	fmt.Fprintf(&b, "SuspendProgram=%s --config=%s\n", filepath.Join(answers.BinDir, "aws-slurm-burst-suspend"), answers.ConfigPath)
+	fmt.Fprintf(&b, "ResumeRate=%d\n", cfg.Slurm.ResumeRate)
+	fmt.Fprintf(&b, "SuspendRate=%d\n", cfg.Slurm.SuspendRate)
+	fmt.Fprintf(&b, "ResumeTimeout=%d\n", cfg.Slurm.ResumeTimeout)
+	fmt.Fprintf(&b, "SuspendTime=%d\n", cfg.Slurm.SuspendTime)
+	fmt.Fprintf(&b, "TreeWidth=%d\n", cfg.Slurm.TreeWidth)
+
+	for _, partition := range cfg.Slurm.Partitions {
+		var ranges []string
+		b.WriteString("\n")
+		for _, nodeGroup := range partition.NodeGroups {
+			nodeRange := cfg.GetNodeRange(partition.PartitionName, nodeGroup.NodeGroupName, nodeGroup.MaxNodes)
+			ranges = append(ranges, nodeRange)
+			fmt.Fprintf(&b, "NodeName=%s State=CLOUD", nodeRange)
+			if answers.CPUs > 0 {
+				fmt.Fprintf(&b, " CPUs=%d", answers.CPUs)
+			}
+			if answers.RealMemoryMiB > 0 {
+				fmt.Fprintf(&b, " RealMemory=%d", answers.RealMemoryMiB)
+			}
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "PartitionName=%s Nodes=%s MaxTime=INFINITE State=UP\n", partition.PartitionName, strings.Join(ranges, ","))
+	}
+	return b.String()
+}
+
+// Write renders the configuration, validates it the way resume loads it and
+// only then installs it at configPath, followed by the slurm.conf snippet at
+// slurmConfPath. Existing files are kept unless overwrite is set.
+func Write(answers *Answers, configPath, slurmConfPath string, overwrite bool) error {
+	if !overwrite {
+		for _, path := range []string{configPath, slurmConfPath} {
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+			}
+		}
+	}
+
+	data, err := RenderConfig(answers)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create configuration directory: %w", err)
+	}
+
+	// Validate under the final extension so viper picks the same format
+	ext := filepath.Ext(configPath)
+	tmp, err := os.CreateTemp(filepath.Dir(configPath), "."+strings.TrimSuffix(filepath.Base(configPath), ext)+"-*"+ext)
+	if err != nil {
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+
+	cfg, err := config.Load(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("generated configuration is invalid: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), configPath); err != nil {
+		return fmt.Errorf("failed to install configuration: %w", err)
+	}
+
+	if err := os.WriteFile(slurmConfPath, []byte(RenderSlurmConf(cfg, answers)), 0644); err != nil {
+		return fmt.Errorf("failed to write slurm.conf snippet: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// fakeDiscovery serves canned answers, or errors when err is set
+type fakeDiscovery struct {
+	vpc   *aws.DefaultVPC
+	types []types.InstanceTypeInfo
+	err   error
+}
+
+func (f *fakeDiscovery) DefaultVPC(ctx context.Context, region string) (*aws.DefaultVPC, error) {
+	return f.vpc, f.err
+}
+
+func (f *fakeDiscovery) EFAInstanceTypes(ctx context.Context, region string) ([]types.InstanceTypeInfo, error) {
+	var efa []types.InstanceTypeInfo
+	for _, info := range f.types {
+		if info.SupportsEFA {
+			efa = append(efa, info)
+		}
+	}
+	return efa, f.err
+}
+
+func (f *fakeDiscovery) InstanceTypes(ctx context.Context, region string, instanceTypes []string) ([]types.InstanceTypeInfo, error) {
+	var found []types.InstanceTypeInfo
+	for _, info := range f.types {
+		for _, name := range instanceTypes {
+			if info.InstanceType == name {
+				found = append(found, info)
+			}
+		}
+	}
+	return found, f.err
+}
+
+func discovery() *fakeDiscovery {
+	return &fakeDiscovery{
+		vpc: &aws.DefaultVPC{
+			VPCID:           "vpc-1",
+			Subnets:         []aws.SubnetInfo{{SubnetID: "subnet-a", AvailabilityZone: "us-west-2a"}, {SubnetID: "subnet-b", AvailabilityZone: "us-west-2b"}},
+			SecurityGroupID: "sg-default",
+		},
+		types: []types.InstanceTypeInfo{
+			{InstanceType: "c6in.32xlarge", VCPUs: 128, MemoryMiB: 262144, SupportsEFA: true},
+			{InstanceType: "hpc7a.96xlarge", VCPUs: 192, MemoryMiB: 786432, SupportsEFA: true},
+			{InstanceType: "c6i.xlarge", VCPUs: 4, MemoryMiB: 8192},
+		},
+	}
+}
+
+func TestRunAcceptsDiscoveredDefaults(t *testing.T) {
+	// Region, partition, node group, max nodes, purchasing, types, subnets, groups, template, profile
+	input := strings.Join([]string{"us-west-2", "", "", "", "", "", "", "", "burst-nodes", ""}, "\n") + "\n"
+	var out bytes.Buffer
+	answers := DefaultAnswers("us-east-1", "/etc/slurm/aws-burst.yaml")
+
+	require.NoError(t, Run(context.Background(), discovery(), NewPrompter(strings.NewReader(input), &out), answers))
+
+	assert.Equal(t, "us-west-2", answers.Region)
+	assert.Equal(t, []string{"c6in.32xlarge"}, answers.InstanceTypes, "the first EFA type replaces a default the region lacks")
+	assert.Equal(t, []string{"subnet-a", "subnet-b"}, answers.SubnetIDs)
+	assert.Equal(t, []string{"sg-default"}, answers.SecurityGroupIDs)
+	assert.Equal(t, "burst-nodes", answers.LaunchTemplateName)
+	assert.Equal(t, 128, answers.CPUs)
+	assert.Equal(t, 262144*95/100, answers.RealMemoryMiB)
+	assert.Contains(t, out.String(), "c6in.32xlarge hpc7a.96xlarge")
+}
+
+func TestRunAsksAgainUntilValid(t *testing.T) {
+	input := strings.Join([]string{
+		"", "bad-name", "hpc", "", "0", "4", "reserved", "spot",
+		"c6i.xlarge, hpc7a.96xlarge", "subnet-x", "sg-x", "", "burst-nodes", "burst-profile",
+	}, "\n") + "\n"
+	var out bytes.Buffer
+	answers := DefaultAnswers("us-east-1", "/etc/slurm/aws-burst.yaml")
+
+	require.NoError(t, Run(context.Background(), discovery(), NewPrompter(strings.NewReader(input), &out), answers))
+
+	assert.Equal(t, "hpc", answers.PartitionName)
+	assert.Equal(t, 4, answers.MaxNodes)
+	assert.Equal(t, "spot", answers.PurchasingOption)
+	assert.Equal(t, []string{"c6i.xlarge", "hpc7a.96xlarge"}, answers.InstanceTypes)
+	assert.Equal(t, 4, answers.CPUs, "the smallest type sizes the nodes")
+	assert.Equal(t, "burst-profile", answers.IAMInstanceProfile)
+	assert.Contains(t, out.String(), "use only letters and digits")
+	assert.Contains(t, out.String(), "enter one of: spot, on-demand")
+	assert.Contains(t, out.String(), "an answer is required")
+}
+
+func TestRunWithoutDiscovery(t *testing.T) {
+	input := strings.Join([]string{"", "", "", "", "", "", "subnet-x", "sg-x", "burst-nodes", ""}, "\n") + "\n"
+	answers := DefaultAnswers("us-east-1", "/etc/slurm/aws-burst.yaml")
+	failing := &fakeDiscovery{err: fmt.Errorf("no credentials")}
+	var out bytes.Buffer
+
+	require.NoError(t, Run(context.Background(), failing, NewPrompter(strings.NewReader(input), &out), answers))
+	assert.Equal(t, []string{defaultInstanceType}, answers.InstanceTypes)
+	assert.Equal(t, []string{"subnet-x"}, answers.SubnetIDs)
+	assert.Zero(t, answers.CPUs)
+	assert.Contains(t, out.String(), "no credentials")
+
+	err := Run(context.Background(), nil, NewPrompter(strings.NewReader("us-east-1\n"), &out), DefaultAnswers("", ""))
+	assert.ErrorContains(t, err, "input ended")
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	answers := DefaultAnswers("us-west-2", "/etc/slurm/aws-burst.yaml")
+	answers.SubnetIDs = []string{"subnet-a", "subnet-b"}
+	answers.SecurityGroupIDs = []string{"sg-default"}
+	answers.LaunchTemplateName = "burst-nodes"
+	answers.CPUs, answers.RealMemoryMiB = 72, 181862
+	configPath := filepath.Join(dir, "aws-burst.yaml")
+	slurmConfPath := filepath.Join(dir, "aws-burst-slurm.conf")
+
+	require.NoError(t, Write(answers, configPath, slurmConfPath, false))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "the validation copy is removed")
+
+	snippet, err := os.ReadFile(slurmConfPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(snippet), "ResumeProgram=/usr/local/bin/aws-slurm-burst-resume --config=/etc/slurm/aws-burst.yaml\n")
+	assert.Contains(t, string(snippet), "ResumeRate=100\n")
+	assert.Contains(t, string(snippet), "NodeName=aws-cpu-[0-9] State=CLOUD CPUs=72 RealMemory=181862\n")
+	assert.Contains(t, string(snippet), "PartitionName=aws Nodes=aws-cpu-[0-9] MaxTime=INFINITE State=UP\n")
+
+	assert.ErrorContains(t, Write(answers, configPath, slurmConfPath, false), "already exists")
+	require.NoError(t, Write(answers, configPath, slurmConfPath, true))
+
+	// A configuration that would not load is not installed
+	answers.LaunchTemplateName = ""
+	answers.SubnetIDs = nil
+	invalidPath := filepath.Join(dir, "invalid.yaml")
+	assert.ErrorContains(t, Write(answers, invalidPath, filepath.Join(dir, "invalid.conf"), false), "generated configuration is invalid")
+	assert.NoFileExists(t, invalidPath)
+}
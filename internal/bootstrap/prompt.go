@@ -0,0 +1,123 @@
+package bootstrap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Prompter asks questions on a terminal, offering a default that an empty
+// answer accepts and asking again until the answer is valid
+type Prompter struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// NewPrompter reads answers from in and writes questions to out
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{in: bufio.NewScanner(in), out: out}
+}
+
+// Printf writes informational output between questions
+func (p *Prompter) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(p.out, format, args...)
+}
+
+// Ask returns the answer to question, or defaultValue for an empty answer.
+// A nil check accepts anything, including an empty answer.
+func (p *Prompter) Ask(question, defaultValue string, check func(string) error) (string, error) {
+	for {
+		if defaultValue != "" {
+			p.Printf("%s [%s]: ", question, defaultValue)
+		} else {
+			p.Printf("%s: ", question)
+		}
+
+		if !p.in.Scan() {
+			if err := p.in.Err(); err != nil {
+				return "", err
+			}
+			return "", fmt.Errorf("input ended before %q was answered", question)
+		}
+		answer := strings.TrimSpace(p.in.Text())
+		if answer == "" {
+			answer = defaultValue
+		}
+
+		if check == nil {
+			return answer, nil
+		}
+		if err := check(answer); err != nil {
+			p.Printf("  %v\n", err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// AskInt asks for a positive number
+func (p *Prompter) AskInt(question string, defaultValue int) (int, error) {
+	answer, err := p.Ask(question, strconv.Itoa(defaultValue), func(answer string) error {
+		if n, err := strconv.Atoi(answer); err != nil || n <= 0 {
+			return fmt.Errorf("enter a positive number")
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(answer)
+}
+
+// AskList asks for one or more values separated by commas or spaces
+func (p *Prompter) AskList(question string, defaultValue []string) ([]string, error) {
+	answer, err := p.Ask(question, strings.Join(defaultValue, ","), func(answer string) error {
+		if len(splitList(answer)) == 0 {
+			return fmt.Errorf("enter at least one value")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return splitList(answer), nil
+}
+
+func splitList(answer string) []string {
+	return strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// required rejects empty answers
+func required(answer string) error {
+	if answer == "" {
+		return fmt.Errorf("an answer is required")
+	}
+	return nil
+}
+
+// alphanumeric accepts the names the configuration allows for partitions and node groups
+func alphanumeric(answer string) error {
+	if answer == "" {
+		return fmt.Errorf("an answer is required")
+	}
+	for _, r := range answer {
+		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return fmt.Errorf("use only letters and digits")
+		}
+	}
+	return nil
+}
+
+// oneOf accepts only the given answers
+func oneOf(allowed ...string) func(string) error {
+	return func(answer string) error {
+		for _, value := range allowed {
+			if answer == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("enter one of: %s", strings.Join(allowed, ", "))
+	}
+}
@@ -0,0 +1,114 @@
+package capacity
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+)
+
+// ErrQuotaContended is why resume queues a launch without trying it: queued
+// launches are already waiting for vCPU quota, and the state manager decides
+// which of them gets it first
+var ErrQuotaContended = errors.New("launches queued ahead are waiting for EC2 vCPU quota")
+
+// DeferredReasonPrefix starts the Slurm reason on nodes whose launch is held
+// behind a higher-ranked launch waiting for vCPU quota
+const DeferredReasonPrefix = "quota_deferred_behind_"
+
+// Standings looks up what arbitration ranks launches by; *slurm.Client is one
+type Standings interface {
+	JobPriorities(ctx context.Context, jobIDs []string) (map[string]int64, error)
+	AccountFairShares(ctx context.Context) (map[string]float64, error)
+}
+
+// Waits reports whether a launch that failed with err waits in the queue:
+// capacity failures always do, and with arbitration so do quota failures
+func Waits(cfg *config.CapacityQueueConfig, err error) bool {
+	if aws.IsCapacityError(err) {
+		return true
+	}
+	return cfg.Arbitrated() && (aws.IsQuotaError(err) || errors.Is(err, ErrQuotaContended))
+}
+
+// DeferredReason is the Slurm reason on the nodes of a launch held behind
+// another, naming that launch's job
+func DeferredReason(req *Request) string {
+	return DeferredReasonPrefix + req.DeferredBehind
+}
+
+// IsWaitingReason reports whether reason is one the queue drains waiting
+// nodes with
+func IsWaitingReason(reason string) bool {
+	return reason == WaitingReason || strings.HasPrefix(reason, DeferredReasonPrefix)
+}
+
+// QuotaContended reports whether any queued launch is waiting for vCPU quota
+func (q *Queue) QuotaContended() (bool, error) {
+	pending, err := q.Pending()
+	if err != nil {
+		return false, err
+	}
+	for _, req := range pending {
+		if req.QuotaLimited {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// label names the launch in the reasons of launches held behind it
+func (r *Request) label() string {
+	if jobID := r.JobID(); jobID != "" {
+		return "job_" + jobID
+	}
+	return r.Launch.NodeIds[0]
+}
+
+// rank refreshes the launches' standings and orders them for arbitration:
+// by job priority, or by the account's fair-share factor and then priority,
+// and longest waiting first among equals. Launches whose standings cannot be
+// looked up keep those they were last ranked with.
+func (q *Queue) rank(ctx context.Context, pending []*Request, standings Standings) error {
+	var jobIDs []string
+	for _, req := range pending {
+		if jobID := req.JobID(); jobID != "" {
+			jobIDs = append(jobIDs, jobID)
+		}
+	}
+
+	var errs []error
+	if len(jobIDs) > 0 {
+		priorities, err := standings.JobPriorities(ctx, jobIDs)
+		errs = append(errs, err)
+		for _, req := range pending {
+			if priority, ok := priorities[req.JobID()]; ok {
+				req.Priority = priority
+			}
+		}
+	}
+	fairShare := q.cfg.Arbitration == config.CapacityArbitrationFairShare
+	if fairShare {
+		shares, err := standings.AccountFairShares(ctx)
+		errs = append(errs, err)
+		for _, req := range pending {
+			if req.Launch.Job == nil {
+				continue
+			}
+			if share, ok := shares[req.Launch.Job.Account]; ok {
+				req.FairShare = share
+			}
+		}
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		if fairShare && pending[i].FairShare != pending[j].FairShare {
+			return pending[i].FairShare > pending[j].FairShare
+		}
+		return pending[i].Priority > pending[j].Priority
+	})
+	return errors.Join(errs...)
+}
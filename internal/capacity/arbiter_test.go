@@ -0,0 +1,143 @@
+package capacity
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errQuota = &aws.FleetLaunchError{Requested: 4, Codes: []string{"VcpuLimitExceeded"}}
+
+// quotaLauncher fails the launches of the nodes over quota and records every try
+type quotaLauncher struct {
+	overQuota map[string]bool
+	tried     []string
+}
+
+func (f *quotaLauncher) LaunchInstances(ctx context.Context, req *aws.LaunchRequest) (*aws.LaunchResult, error) {
+	f.tried = append(f.tried, req.NodeIds[0])
+	if f.overQuota[req.NodeIds[0]] {
+		return nil, errQuota
+	}
+	return &aws.LaunchResult{Instances: []types.InstanceInfo{{NodeName: req.NodeIds[0], InstanceID: "i-1"}}}, nil
+}
+
+type fakeStandings struct {
+	priorities map[string]int64
+	shares     map[string]float64
+	err        error
+}
+
+func (f *fakeStandings) JobPriorities(ctx context.Context, jobIDs []string) (map[string]int64, error) {
+	return f.priorities, f.err
+}
+
+func (f *fakeStandings) AccountFairShares(ctx context.Context) (map[string]float64, error) {
+	return f.shares, f.err
+}
+
+func launchFor(node, jobID, account string) *aws.LaunchRequest {
+	return &aws.LaunchRequest{NodeIds: []string{node}, Job: &types.SlurmJob{JobID: jobID, Account: account}}
+}
+
+func TestQueueRetry_Arbitration(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	queue := NewQueue(config.CapacityQueueConfig{QueueDir: t.TempDir(), MaxWaitMinutes: 30, InitialBackoffSeconds: 60, MaxBackoffSeconds: 240,
+		Arbitration: config.CapacityArbitrationPriority})
+	queue.now = func() time.Time { return now }
+
+	_, err := queue.Enqueue(launchFor("aws-cpu-1", "1", "chem"), errQuota)
+	require.NoError(t, err)
+	now = now.Add(time.Second)
+	_, err = queue.Enqueue(launchFor("aws-cpu-2", "2", "physics"), errQuota)
+	require.NoError(t, err)
+	contended, err := queue.QuotaContended()
+	require.NoError(t, err)
+	assert.True(t, contended)
+	req, err := queue.Enqueue(launchFor("aws-cpu-3", "3", "bio"), ErrQuotaContended)
+	require.NoError(t, err)
+	assert.Equal(t, now, req.NextAttempt, "a launch never tried is tried on the next pass")
+
+	// Job 2 ranks first and is still over quota, so the rest wait behind it
+	standings := &fakeStandings{priorities: map[string]int64{"1": 100, "2": 900, "3": 500}}
+	launcher := &quotaLauncher{overQuota: map[string]bool{"aws-cpu-2": true}}
+	now = now.Add(time.Minute)
+	result, err := queue.Retry(context.Background(), launcher, standings)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws-cpu-2"}, launcher.tried)
+	require.Len(t, result.Waiting, 3)
+	require.Len(t, result.Deferred, 2)
+	assert.Equal(t, "quota_deferred_behind_job_2", DeferredReason(result.Deferred[0]))
+	assert.Equal(t, "aws-cpu-3", result.Deferred[0].Launch.NodeIds[0], "deferred in rank order")
+	assert.True(t, IsWaitingReason(DeferredReason(result.Deferred[1])))
+
+	// Still held while job 2 backs off, without reporting the hold again
+	launcher.tried = nil
+	now = now.Add(time.Minute)
+	result, err = queue.Retry(context.Background(), launcher, standings)
+	require.NoError(t, err)
+	assert.Empty(t, launcher.tried)
+	assert.Empty(t, result.Deferred)
+
+	// Once job 2 launches the others go in rank order; job 1 now leads the quota wait
+	delete(launcher.overQuota, "aws-cpu-2")
+	launcher.overQuota["aws-cpu-1"] = true
+	now = now.Add(time.Minute)
+	result, err = queue.Retry(context.Background(), launcher, standings)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws-cpu-2", "aws-cpu-3", "aws-cpu-1"}, launcher.tried)
+	assert.Len(t, result.Launched, 2)
+	require.Len(t, result.Released, 1)
+	assert.Equal(t, "aws-cpu-1", result.Released[0].Launch.NodeIds[0])
+	assert.Empty(t, result.Released[0].DeferredBehind)
+}
+
+func TestQueueRetry_FIFOQuota(t *testing.T) {
+	queue := NewQueue(config.CapacityQueueConfig{QueueDir: t.TempDir(), MaxWaitMinutes: 30, InitialBackoffSeconds: 60, MaxBackoffSeconds: 240,
+		Arbitration: config.CapacityArbitrationFIFO})
+	now := time.Now()
+	queue.now = func() time.Time { return now }
+	_, err := queue.Enqueue(launchFor("aws-cpu-1", "1", "chem"), errNoCapacity)
+	require.NoError(t, err)
+
+	// Without arbitration, quota failures do not wait
+	now = now.Add(time.Minute)
+	result, err := queue.Retry(context.Background(), &quotaLauncher{overQuota: map[string]bool{"aws-cpu-1": true}}, &fakeStandings{})
+	require.NoError(t, err)
+	assert.Len(t, result.Expired, 1)
+	assert.False(t, Waits(&queue.cfg, errQuota))
+	assert.True(t, Waits(&queue.cfg, errNoCapacity))
+}
+
+func TestQueue_rank(t *testing.T) {
+	queue := NewQueue(config.CapacityQueueConfig{Arbitration: config.CapacityArbitrationFairShare})
+	pending := []*Request{
+		{Launch: launchFor("aws-cpu-1", "1", "chem")},
+		{Launch: launchFor("aws-cpu-2", "2", "physics")},
+		{Launch: launchFor("aws-cpu-3", "3", "physics")},
+		{Launch: &aws.LaunchRequest{NodeIds: []string{"aws-cpu-4"}}},
+	}
+	standings := &fakeStandings{
+		priorities: map[string]int64{"1": 900, "2": 100, "3": 200},
+		shares:     map[string]float64{"chem": 0.1, "physics": 0.8},
+	}
+	require.NoError(t, queue.rank(context.Background(), pending, standings))
+	var order []string
+	for _, req := range pending {
+		order = append(order, req.Launch.NodeIds[0])
+	}
+	assert.Equal(t, []string{"aws-cpu-3", "aws-cpu-2", "aws-cpu-1", "aws-cpu-4"}, order, "the under-served account first, then priority")
+
+	// Standings that cannot be looked up keep those last ranked with
+	standings = &fakeStandings{err: errors.New("sshare: command not found")}
+	assert.ErrorContains(t, queue.rank(context.Background(), pending, standings), "sshare")
+	assert.Equal(t, 0.8, pending[0].FairShare)
+	assert.Equal(t, int64(200), pending[0].Priority)
+}
@@ -0,0 +1,312 @@
+// Package capacity keeps the launches waiting for AWS capacity. Resume queues a
+// node group's launch after it fails for lack of capacity, and the state manager
+// retries the queued launches with backoff until they launch or run out of time.
+// With arbitration, launches over the account's vCPU quota wait too, and the
+// state manager gives freed quota to the highest-ranked launch first.
+package capacity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/filelock"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+)
+
+// WaitingReason is the Slurm reason on nodes whose launch waits for capacity
+const WaitingReason = "waiting_for_aws_capacity"
+
+// requestPattern matches queued launch files
+const requestPattern = "launch-*.json"
+
+// Events passed to the notify command
+const (
+	EventWaiting  = "waiting"
+	EventLaunched = "launched"
+	EventExpired  = "expired"
+)
+
+// Request is a launch waiting for capacity
+type Request struct {
+	Launch      *aws.LaunchRequest `json:"launch"`
+	QueuedAt    time.Time          `json:"queued_at"`
+	Deadline    time.Time          `json:"deadline"` // When the launch stops waiting and its nodes go down
+	Attempts    int                `json:"attempts"`
+	NextAttempt time.Time          `json:"next_attempt"`
+	LastError   string             `json:"last_error"`
+
+	// Arbitration of launches contending for vCPU quota
+	QuotaLimited   bool    `json:"quota_limited,omitempty"`   // Last failed, or was held back, for vCPU quota
+	Priority       int64   `json:"priority,omitempty"`        // The job's Slurm priority, looked up each pass
+	FairShare      float64 `json:"fair_share,omitempty"`      // The job account's fair-share factor, looked up each pass
+	DeferredBehind string  `json:"deferred_behind,omitempty"` // The higher-ranked launch this one is held behind
+
+	path string
+}
+
+// JobID is the job the launch is for, when known
+func (r *Request) JobID() string {
+	if r.Launch.Job == nil {
+		return ""
+	}
+	return r.Launch.Job.JobID
+}
+
+// Launcher launches instances; *aws.Client is one
+type Launcher interface {
+	LaunchInstances(ctx context.Context, req *aws.LaunchRequest) (*aws.LaunchResult, error)
+}
+
+// Launched is a queued launch that got its capacity
+type Launched struct {
+	Request *Request
+	Result  *aws.LaunchResult
+}
+
+// Result summarizes one pass over the queue
+type Result struct {
+	Launched []Launched
+	Waiting  []*Request // Still queued, including those not due for a retry
+	Expired  []*Request // Out of time, or failed for a reason other than capacity
+
+	// With arbitration, waiting launches newly held behind a higher-ranked
+	// launch blocked on quota, and those no longer held
+	Deferred []*Request
+	Released []*Request
+	// Why the launches could not be ranked afresh; they keep their last ranking
+	StandingsErr error
+}
+
+// Queue is the launches waiting for capacity in a directory
+type Queue struct {
+	cfg config.CapacityQueueConfig
+	now func() time.Time
+}
+
+// NewQueue creates the queue in cfg.QueueDir
+func NewQueue(cfg config.CapacityQueueConfig) *Queue {
+	return &Queue{cfg: cfg, now: time.Now}
+}
+
+// Enqueue queues a launch that failed for lack of capacity, to be retried once
+// the initial backoff has passed
+func (q *Queue) Enqueue(launch *aws.LaunchRequest, launchErr error) (*Request, error) {
+	if len(launch.NodeIds) == 0 {
+		return nil, fmt.Errorf("launch has no nodes to queue")
+	}
+	now := q.now()
+	req := &Request{
+		Launch:       launch,
+		QueuedAt:     now,
+		Deadline:     now.Add(q.cfg.MaxWait()),
+		Attempts:     1,
+		NextAttempt:  now.Add(q.cfg.Backoff(1)),
+		LastError:    launchErr.Error(),
+		QuotaLimited: aws.IsQuotaError(launchErr) || errors.Is(launchErr, ErrQuotaContended),
+		path:         filepath.Join(q.cfg.QueueDir, fmt.Sprintf("launch-%s.json", launch.NodeIds[0])),
+	}
+	// A launch never tried is ranked and tried on the state manager's next pass
+	if errors.Is(launchErr, ErrQuotaContended) {
+		req.NextAttempt = now
+	}
+
+	if err := os.MkdirAll(q.cfg.QueueDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create capacity queue: %w", err)
+	}
+	if err := req.save(); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Pending returns the queued launches, longest waiting first
+func (q *Queue) Pending() ([]*Request, error) {
+	files, err := filepath.Glob(filepath.Join(q.cfg.QueueDir, requestPattern))
+	if err != nil {
+		return nil, err
+	}
+
+	var requests []*Request
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queued launch: %w", err)
+		}
+		req := &Request{path: file}
+		if err := json.Unmarshal(data, req); err != nil || req.Launch == nil {
+			return nil, fmt.Errorf("invalid queued launch %s: %v", file, err)
+		}
+		requests = append(requests, req)
+	}
+	sort.SliceStable(requests, func(i, j int) bool { return requests[i].QueuedAt.Before(requests[j].QueuedAt) })
+	return requests, nil
+}
+
+// Retry launches the queued launches that are due. A launch that fails for
+// lack of capacity waits again, backing off further, unless its next retry
+// would come after its deadline. With arbitration, the launches are tried in
+// the order of their jobs' standings, and every launch ranked below one
+// blocked on vCPU quota is held until that one launches or gives up. Only one
+// pass runs at a time; a pass that finds another running returns an empty
+// result.
+func (q *Queue) Retry(ctx context.Context, launcher Launcher, standings Standings) (Result, error) {
+	var result Result
+	if _, err := os.Stat(q.cfg.QueueDir); errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	unlock, err := lockDir(q.cfg.QueueDir)
+	if err != nil || unlock == nil {
+		return result, err
+	}
+	defer unlock()
+
+	pending, err := q.Pending()
+	if err != nil {
+		return result, err
+	}
+	arbitrated := q.cfg.Arbitrated() && standings != nil
+	if arbitrated {
+		result.StandingsErr = q.rank(ctx, pending, standings)
+	}
+
+	// The highest-ranked launch waiting for quota, which the rest wait behind
+	var blocker *Request
+	for _, req := range pending {
+		now := q.now()
+		if !now.Before(req.Deadline) {
+			result.Expired = append(result.Expired, req)
+			req.remove()
+			continue
+		}
+		if blocker != nil || now.Before(req.NextAttempt) || ctx.Err() != nil {
+			if err := q.wait(req, blocker, &result); err != nil {
+				return result, err
+			}
+			if arbitrated && blocker == nil && req.QuotaLimited {
+				blocker = req
+			}
+			continue
+		}
+
+		launched, err := launcher.LaunchInstances(ctx, req.Launch)
+		if err == nil {
+			result.Launched = append(result.Launched, Launched{Request: req, Result: launched})
+			req.remove()
+			continue
+		}
+		// Nodes a partial launch served keep their instances; the rest wait on
+		if served := launched.ServedNodes(); len(served) > 0 {
+			servedReq := *req
+			servedReq.Launch = req.Launch.WithNodes(served)
+			result.Launched = append(result.Launched, Launched{Request: &servedReq, Result: launched})
+			req.Launch = req.Launch.WithoutNodes(served)
+		}
+
+		req.Attempts++
+		req.LastError = err.Error()
+		req.QuotaLimited = aws.IsQuotaError(err)
+		req.NextAttempt = q.now().Add(q.cfg.Backoff(req.Attempts))
+		if !Waits(&q.cfg, err) || !req.NextAttempt.Before(req.Deadline) {
+			result.Expired = append(result.Expired, req)
+			req.remove()
+			continue
+		}
+		if err := req.save(); err != nil {
+			return result, err
+		}
+		if err := q.wait(req, nil, &result); err != nil {
+			return result, err
+		}
+		if arbitrated && req.QuotaLimited {
+			blocker = req
+		}
+	}
+	return result, nil
+}
+
+// wait keeps a launch queued, held behind blocker when there is one, and
+// reports a change in what it waits behind
+func (q *Queue) wait(req, blocker *Request, result *Result) error {
+	behind := ""
+	if blocker != nil {
+		behind = blocker.label()
+	}
+	if behind != req.DeferredBehind {
+		req.DeferredBehind = behind
+		if err := req.save(); err != nil {
+			return err
+		}
+		if behind == "" {
+			result.Released = append(result.Released, req)
+		} else {
+			result.Deferred = append(result.Deferred, req)
+		}
+	}
+	result.Waiting = append(result.Waiting, req)
+	return nil
+}
+
+// save atomically writes the request to its file
+func (r *Request) save() error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode queued launch: %w", err)
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write queued launch: %w", err)
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// remove takes the request off the queue
+func (r *Request) remove() {
+	_ = os.Remove(r.path)
+}
+
+// lockDir takes an exclusive flock(2) on the queue directory's lock file. It
+// returns a nil unlock function when another pass holds the lock.
+func lockDir(dir string) (func(), error) {
+	unlock, err := filelock.Lock(filepath.Join(dir, ".lock"), 0)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock capacity queue: %w", err)
+	}
+	return unlock, nil
+}
+
+// Notify runs the notify command for a launch through the shell, with the event
+// and the launch's wait in its environment
+func Notify(ctx context.Context, command, event string, req *Request) error {
+	if command == "" {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"AWS_SLURM_BURST_CAPACITY_EVENT="+event,
+		"AWS_SLURM_BURST_JOB_ID="+req.JobID(),
+		"AWS_SLURM_BURST_NODES="+slurm.CompressHostlist(req.Launch.NodeIds),
+		"AWS_SLURM_BURST_PARTITION="+req.Launch.Partition,
+		"AWS_SLURM_BURST_NODE_GROUP="+req.Launch.NodeGroup,
+		"AWS_SLURM_BURST_ATTEMPTS="+strconv.Itoa(req.Attempts),
+		"AWS_SLURM_BURST_NEXT_ATTEMPT="+req.NextAttempt.Format(time.RFC3339),
+		"AWS_SLURM_BURST_DEADLINE="+req.Deadline.Format(time.RFC3339),
+		"AWS_SLURM_BURST_LAST_ERROR="+req.LastError)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notify command failed: %w: %s", err, output)
+	}
+	return nil
+}
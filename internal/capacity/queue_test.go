@@ -0,0 +1,146 @@
+package capacity
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLauncher fails each launch with the next error, then succeeds. A
+// failed launch still serves its first node when served is set.
+type fakeLauncher struct {
+	errs     []error
+	served   bool
+	launched [][]string
+}
+
+func (f *fakeLauncher) LaunchInstances(ctx context.Context, req *aws.LaunchRequest) (*aws.LaunchResult, error) {
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if f.served {
+			return &aws.LaunchResult{Instances: []types.InstanceInfo{{NodeName: req.NodeIds[0], InstanceID: "i-warm"}}}, err
+		}
+		return nil, err
+	}
+	f.launched = append(f.launched, req.NodeIds)
+	return &aws.LaunchResult{Instances: []types.InstanceInfo{{NodeName: req.NodeIds[0], InstanceID: "i-1"}}}, nil
+}
+
+var errNoCapacity = &aws.FleetLaunchError{Requested: 2, Codes: []string{"InsufficientInstanceCapacity"}}
+
+func TestQueueRetry(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	queue := NewQueue(config.CapacityQueueConfig{QueueDir: t.TempDir(), MaxWaitMinutes: 10, InitialBackoffSeconds: 60, MaxBackoffSeconds: 240})
+	queue.now = func() time.Time { return now }
+
+	gpu := &aws.LaunchRequest{NodeIds: []string{"aws-gpu-1", "aws-gpu-2"}, Partition: "aws", NodeGroup: "gpu", Job: &types.SlurmJob{JobID: "42"}}
+	req, err := queue.Enqueue(gpu, errNoCapacity)
+	require.NoError(t, err)
+	assert.Equal(t, "42", req.JobID())
+	assert.Equal(t, now.Add(10*time.Minute), req.Deadline)
+	assert.Equal(t, now.Add(time.Minute), req.NextAttempt)
+
+	launcher := &fakeLauncher{errs: []error{errNoCapacity}}
+	result, err := queue.Retry(context.Background(), launcher, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Waiting, 1, "not due yet")
+	assert.Empty(t, launcher.launched)
+
+	now = now.Add(time.Minute)
+	result, err = queue.Retry(context.Background(), launcher, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Waiting, 1, "still no capacity")
+	assert.Equal(t, 2, result.Waiting[0].Attempts)
+	assert.Equal(t, now.Add(2*time.Minute), result.Waiting[0].NextAttempt, "backing off")
+	assert.Contains(t, result.Waiting[0].LastError, "launched 0 of 2")
+
+	now = now.Add(2 * time.Minute)
+	result, err = queue.Retry(context.Background(), launcher, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Launched, 1)
+	assert.Equal(t, []string{"aws-gpu-1", "aws-gpu-2"}, launcher.launched[0], "the queued request is launched")
+	assert.Equal(t, "i-1", result.Launched[0].Result.Instances[0].InstanceID)
+
+	pending, err := queue.Pending()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestQueueRetryPartialLaunch(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	queue := NewQueue(config.CapacityQueueConfig{QueueDir: t.TempDir(), MaxWaitMinutes: 10, InitialBackoffSeconds: 60, MaxBackoffSeconds: 240})
+	queue.now = func() time.Time { return now }
+
+	_, err := queue.Enqueue(&aws.LaunchRequest{NodeIds: []string{"aws-gpu-1", "aws-gpu-2"}}, errNoCapacity)
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	launcher := &fakeLauncher{errs: []error{errNoCapacity}, served: true}
+	result, err := queue.Retry(context.Background(), launcher, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Launched, 1, "the served node keeps its instance")
+	assert.Equal(t, []string{"aws-gpu-1"}, result.Launched[0].Request.Launch.NodeIds)
+	assert.Equal(t, "i-warm", result.Launched[0].Result.Instances[0].InstanceID)
+	require.Len(t, result.Waiting, 1)
+	assert.Equal(t, []string{"aws-gpu-2"}, result.Waiting[0].Launch.NodeIds, "only the rest waits")
+
+	pending, err := queue.Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, []string{"aws-gpu-2"}, pending[0].Launch.NodeIds)
+}
+
+func TestQueueRetryExpires(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	queue := NewQueue(config.CapacityQueueConfig{QueueDir: t.TempDir(), MaxWaitMinutes: 5, InitialBackoffSeconds: 60, MaxBackoffSeconds: 240})
+	queue.now = func() time.Time { return now }
+
+	_, err := queue.Enqueue(&aws.LaunchRequest{NodeIds: []string{"aws-gpu-1"}}, errNoCapacity)
+	require.NoError(t, err)
+	_, err = queue.Enqueue(&aws.LaunchRequest{NodeIds: []string{"aws-gpu-2"}}, errNoCapacity)
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	launcher := &fakeLauncher{errs: []error{errors.New("UnauthorizedOperation"), errNoCapacity}}
+	result, err := queue.Retry(context.Background(), launcher, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Expired, 1, "only capacity failures wait")
+	assert.Equal(t, "UnauthorizedOperation", result.Expired[0].LastError)
+	require.Len(t, result.Waiting, 1)
+
+	// The next retry, two minutes on, would be after the deadline
+	now = now.Add(2 * time.Minute)
+	launcher.errs = []error{errNoCapacity}
+	result, err = queue.Retry(context.Background(), launcher, nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Expired, 1)
+	assert.Empty(t, result.Waiting)
+
+	files, err := filepath.Glob(filepath.Join(queue.cfg.QueueDir, requestPattern))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestNotify(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "notified")
+	req := &Request{
+		Launch:   &aws.LaunchRequest{NodeIds: []string{"aws-gpu-1", "aws-gpu-2"}, Job: &types.SlurmJob{JobID: "42"}},
+		Deadline: time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC),
+	}
+
+	require.NoError(t, Notify(context.Background(),
+		`echo "$AWS_SLURM_BURST_CAPACITY_EVENT $AWS_SLURM_BURST_JOB_ID $AWS_SLURM_BURST_NODES $AWS_SLURM_BURST_DEADLINE" > `+output, EventWaiting, req))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "waiting 42 aws-gpu-[1-2] 2026-10-18T12:30:00Z\n", string(data))
+}
@@ -0,0 +1,108 @@
+// Package chaos injects failures into fleet launches, Slurm node updates and
+// instance waits for game-day rehearsals. Which operations fail is decided by
+// hashing what they act on, so the same nodes fail the same way on every run
+// and across the separate resume and suspend processes Slurm starts.
+package chaos
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"go.uber.org/zap"
+)
+
+// Injector decides which operations fail. A nil Injector injects nothing.
+type Injector struct {
+	logger *zap.Logger
+	config config.FailureInjectionConfig
+}
+
+// New returns an injector for the configuration, or nil when failure
+// injection is not active
+func New(logger *zap.Logger, failureInjection config.FailureInjectionConfig) *Injector {
+	if !failureInjection.Active() {
+		return nil
+	}
+
+	logger.Warn("Failure injection active: fleet launches, Slurm node updates and instance waits may fail on purpose",
+		zap.Float64("fleet_error_rate", failureInjection.FleetErrorRate),
+		zap.Float64("slurm_update_error_rate", failureInjection.SlurmUpdateErrorRate),
+		zap.Int("waiter_delay_seconds", failureInjection.WaiterDelaySeconds))
+	return &Injector{logger: logger, config: failureInjection}
+}
+
+// InjectedError is a failure the injector made up
+type InjectedError struct {
+	Operation string
+	Target    string
+}
+
+func (e *InjectedError) Error() string {
+	return fmt.Sprintf("injected %s failure for %s", e.Operation, e.Target)
+}
+
+// Fleet returns an error for the fleet_error_rate share of fleet launches,
+// chosen by the nodes launched
+func (i *Injector) Fleet(nodes string) error {
+	if i == nil {
+		return nil
+	}
+	return i.fail("fleet", nodes, i.config.FleetErrorRate)
+}
+
+// SlurmUpdate returns an error for the slurm_update_error_rate share of
+// scontrol node updates, chosen by the nodes updated
+func (i *Injector) SlurmUpdate(nodes string) error {
+	if i == nil {
+		return nil
+	}
+	return i.fail("slurm_update", nodes, i.config.SlurmUpdateErrorRate)
+}
+
+// WaiterDelay sleeps waiter_delay_seconds before an instance wait, or until
+// ctx ends
+func (i *Injector) WaiterDelay(ctx context.Context) error {
+	if i == nil || i.config.WaiterDelaySeconds <= 0 {
+		return nil
+	}
+
+	delay := time.Duration(i.config.WaiterDelaySeconds) * time.Second
+	i.logger.Warn("Injected instance wait delay", zap.Duration("delay", delay))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *Injector) fail(operation, target string, rate float64) error {
+	if !selected(operation, target, rate) {
+		return nil
+	}
+
+	err := &InjectedError{Operation: operation, Target: target}
+	i.logger.Warn("Injected failure", zap.String("operation", operation), zap.String("target", target))
+	return err
+}
+
+// selected reports whether the operation on target falls within rate, by
+// hashing both into [0, 1)
+func selected(operation, target string, rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	if rate >= 1 {
+		return true
+	}
+
+	sum := sha256.Sum256([]byte(operation + "\x00" + target))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11)/(1<<53) < rate
+}
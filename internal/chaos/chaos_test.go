@@ -0,0 +1,61 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	failureInjection := config.FailureInjectionConfig{Enabled: true, FleetErrorRate: 1}
+
+	assert.Nil(t, New(zaptest.NewLogger(t), failureInjection), "inactive without the environment variable")
+
+	t.Setenv(config.FailureInjectionEnv, "1")
+	assert.Nil(t, New(zaptest.NewLogger(t), config.FailureInjectionConfig{FleetErrorRate: 1}), "inactive unless enabled")
+
+	injector := New(zaptest.NewLogger(t), failureInjection)
+	require.NotNil(t, injector)
+
+	var injected *InjectedError
+	require.True(t, errors.As(injector.Fleet("aws-cpu-[1-4]"), &injected))
+	assert.Equal(t, "fleet", injected.Operation)
+	assert.NoError(t, injector.SlurmUpdate("aws-cpu-1"))
+}
+
+func TestInjector_Nil(t *testing.T) {
+	var injector *Injector
+	assert.NoError(t, injector.Fleet("aws-cpu-1"))
+	assert.NoError(t, injector.SlurmUpdate("aws-cpu-1"))
+	assert.NoError(t, injector.WaiterDelay(context.Background()))
+}
+
+func TestSelected(t *testing.T) {
+	failed := 0
+	for i := 0; i < 10000; i++ {
+		target := fmt.Sprintf("aws-cpu-%d", i)
+		if selected("fleet", target, 0.25) {
+			failed++
+		}
+		assert.Equal(t, selected("fleet", target, 0.25), selected("fleet", target, 0.25), "deterministic")
+		assert.False(t, selected("fleet", target, 0))
+		assert.True(t, selected("fleet", target, 1))
+	}
+	assert.InDelta(t, 2500, failed, 250)
+}
+
+func TestInjector_WaiterDelay(t *testing.T) {
+	injector := &Injector{logger: zaptest.NewLogger(t), config: config.FailureInjectionConfig{WaiterDelaySeconds: 60}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, injector.WaiterDelay(ctx), context.DeadlineExceeded, "the wait ends with the context")
+}
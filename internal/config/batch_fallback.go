@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Compute environments a Batch fallback job queue can be backed by
+const (
+	BatchPlatformEC2     = "EC2"
+	BatchPlatformFargate = "FARGATE"
+)
+
+// BatchFallbackConfig sends loosely-coupled array jobs to AWS Batch instead of
+// provisioning Slurm nodes for them. Resume submits a pending array job whose
+// tasks each fit on one node, run no MPI and read no shared filesystem as a
+// Batch array job, using a job definition generated from its batch script,
+// cancels the Slurm tasks and powers the nodes back down. The state manager
+// follows the Batch job and hands it to the epilog exporters when it ends, so
+// its cost is reconciled like any other burst job.
+type BatchFallbackConfig struct {
+	Enabled          bool     `mapstructure:"enabled"`
+	JobQueue         string   `mapstructure:"job_queue"`          // Batch job queue name or ARN
+	Platform         string   `mapstructure:"platform"`           // Compute environment behind the queue: EC2 (default) or FARGATE
+	Image            string   `mapstructure:"image"`              // Container image the batch script runs in; it must provide bash
+	JobRoleARN       string   `mapstructure:"job_role_arn"`       // Role the tasks' AWS calls use
+	ExecutionRoleARN string   `mapstructure:"execution_role_arn"` // Role ECS pulls the image and writes logs with; required on Fargate
+	Partitions       []string `mapstructure:"partitions"`         // Partitions whose array jobs may fall back; empty means all
+	SharedPaths      []string `mapstructure:"shared_paths"`       // Filesystems Batch containers cannot see; scripts naming them stay in Slurm
+	MaxScriptBytes   int      `mapstructure:"max_script_bytes"`   // Larger scripts overflow Batch's container overrides and stay in Slurm
+
+	// Prices used when reconciling Batch runs, per task-hour of each resource
+	VCPUHourUSD float64 `mapstructure:"vcpu_hour_usd"`
+	GBHourUSD   float64 `mapstructure:"gb_hour_usd"`
+	GPUHourUSD  float64 `mapstructure:"gpu_hour_usd"`
+}
+
+// AllowsPartition reports whether array jobs in partition may fall back
+func (b *BatchFallbackConfig) AllowsPartition(partition string) bool {
+	if len(b.Partitions) == 0 {
+		return true
+	}
+	for _, allowed := range b.Partitions {
+		if allowed == partition {
+			return true
+		}
+	}
+	return false
+}
+
+// validateBatchFallback checks the job queue, image and platform, and that
+// listed partitions are configured
+func validateBatchFallback(config *Config) error {
+	batch := &config.BatchFallback
+	if !batch.Enabled {
+		return nil
+	}
+	if batch.JobQueue == "" {
+		return fmt.Errorf("batch_fallback.job_queue is required")
+	}
+	if batch.Image == "" {
+		return fmt.Errorf("batch_fallback.image is required")
+	}
+	if config.Ecosystem.DataExchangeDir == "" {
+		return fmt.Errorf("batch_fallback records its submissions under ecosystem.data_exchange_dir, which is not set")
+	}
+	batch.Platform = strings.ToUpper(batch.Platform)
+	switch batch.Platform {
+	case "":
+		batch.Platform = BatchPlatformEC2
+	case BatchPlatformEC2:
+	case BatchPlatformFargate:
+		if batch.ExecutionRoleARN == "" {
+			return fmt.Errorf("batch_fallback.execution_role_arn is required on Fargate")
+		}
+	default:
+		return fmt.Errorf("batch_fallback.platform must be %s or %s, got %q", BatchPlatformEC2, BatchPlatformFargate, batch.Platform)
+	}
+	for _, partition := range batch.Partitions {
+		if config.FindPartition(partition) == nil {
+			return fmt.Errorf("batch_fallback.partitions: %q is not a configured partition", partition)
+		}
+	}
+	if batch.MaxScriptBytes < 0 {
+		return fmt.Errorf("batch_fallback.max_script_bytes cannot be negative")
+	}
+	if batch.VCPUHourUSD < 0 || batch.GBHourUSD < 0 || batch.GPUHourUSD < 0 {
+		return fmt.Errorf("batch_fallback rates cannot be negative")
+	}
+	return nil
+}
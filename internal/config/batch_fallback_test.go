@@ -0,0 +1,49 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBatchFallback(t *testing.T) {
+	assert.NoError(t, validateBatchFallback(&Config{}), "disabled")
+
+	config := func(batch BatchFallbackConfig) *Config {
+		return &Config{
+			Slurm:         SlurmConfig{Partitions: []PartitionConfig{{PartitionName: "aws"}}},
+			Ecosystem:     EcosystemConfig{DataExchangeDir: "/var/spool/asbx/ecosystem"},
+			BatchFallback: batch,
+		}
+	}
+	valid := config(BatchFallbackConfig{Enabled: true, JobQueue: "burst", Image: "public.ecr.aws/amazonlinux/amazonlinux:2023", Partitions: []string{"aws"}})
+	assert.NoError(t, validateBatchFallback(valid))
+	assert.Equal(t, BatchPlatformEC2, valid.BatchFallback.Platform)
+
+	fargate := config(BatchFallbackConfig{Enabled: true, JobQueue: "burst", Image: "al2023", Platform: "fargate", ExecutionRoleARN: "arn:aws:iam::123456789012:role/ecsTaskExecution"})
+	assert.NoError(t, validateBatchFallback(fargate))
+	assert.Equal(t, BatchPlatformFargate, fargate.BatchFallback.Platform)
+
+	for _, invalid := range []BatchFallbackConfig{
+		{Enabled: true, Image: "al2023"},
+		{Enabled: true, JobQueue: "burst"},
+		{Enabled: true, JobQueue: "burst", Image: "al2023", Platform: "ECS"},
+		{Enabled: true, JobQueue: "burst", Image: "al2023", Platform: BatchPlatformFargate},
+		{Enabled: true, JobQueue: "burst", Image: "al2023", Partitions: []string{"gpu"}},
+		{Enabled: true, JobQueue: "burst", Image: "al2023", VCPUHourUSD: -1},
+		{Enabled: true, JobQueue: "burst", Image: "al2023", MaxScriptBytes: -1},
+	} {
+		assert.Error(t, validateBatchFallback(config(invalid)), "%+v", invalid)
+	}
+
+	noExchange := config(valid.BatchFallback)
+	noExchange.Ecosystem.DataExchangeDir = ""
+	assert.Error(t, validateBatchFallback(noExchange))
+}
+
+func TestBatchFallbackConfig_AllowsPartition(t *testing.T) {
+	assert.True(t, (&BatchFallbackConfig{}).AllowsPartition("aws"))
+	listed := BatchFallbackConfig{Partitions: []string{"aws"}}
+	assert.True(t, listed.AllowsPartition("aws"))
+	assert.False(t, listed.AllowsPartition("gpu"))
+}
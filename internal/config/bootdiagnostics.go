@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// BootDiagnosticsConfig saves the EC2 console output of burst nodes that fail
+// their health checks or never register with slurmctld, before their
+// instances are terminated, and passes it to a notify command
+type BootDiagnosticsConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	Dir           string `mapstructure:"dir"`            // One JSON record per failed instance, written by the Slurm user
+	NotifyCommand string `mapstructure:"notify_command"` // Run through the shell for each record
+	RetentionDays int    `mapstructure:"retention_days"` // Records older than this are removed
+}
+
+// Defaults for boot diagnostics
+const (
+	DefaultBootDiagnosticsDir           = "/var/log/aws-slurm-burst/boot-failures"
+	DefaultBootDiagnosticsRetentionDays = 30
+)
+
+// validateBootDiagnostics defaults the directory and retention
+func validateBootDiagnostics(diagnostics *BootDiagnosticsConfig) error {
+	if diagnostics.Dir == "" {
+		diagnostics.Dir = DefaultBootDiagnosticsDir
+	}
+	if !filepath.IsAbs(diagnostics.Dir) {
+		return fmt.Errorf("boot_diagnostics.dir must be an absolute path, got %q", diagnostics.Dir)
+	}
+	if diagnostics.RetentionDays == 0 {
+		diagnostics.RetentionDays = DefaultBootDiagnosticsRetentionDays
+	}
+	if diagnostics.RetentionDays < 0 {
+		return fmt.Errorf("boot_diagnostics.retention_days must be positive, got %d", diagnostics.RetentionDays)
+	}
+	return nil
+}
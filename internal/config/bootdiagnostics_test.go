@@ -0,0 +1,17 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateBootDiagnostics(t *testing.T) {
+	diagnostics := BootDiagnosticsConfig{Enabled: true}
+	assert.NoError(t, validateBootDiagnostics(&diagnostics))
+	assert.Equal(t, DefaultBootDiagnosticsDir, diagnostics.Dir)
+	assert.Equal(t, DefaultBootDiagnosticsRetentionDays, diagnostics.RetentionDays)
+
+	assert.Error(t, validateBootDiagnostics(&BootDiagnosticsConfig{Enabled: true, Dir: "boot-failures"}))
+	assert.Error(t, validateBootDiagnostics(&BootDiagnosticsConfig{Enabled: true, RetentionDays: -1}))
+}
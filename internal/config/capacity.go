@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// CapacityQueueConfig lets launches that fail for lack of AWS capacity wait for
+// it instead of failing the job. Resume queues the node group's launch in
+// QueueDir and drains its nodes with the waiting_for_aws_capacity reason; the
+// state manager retries the launch with exponential backoff until MaxWaitMinutes
+// have passed, then marks the nodes down. Gang-scheduled launches never wait.
+type CapacityQueueConfig struct {
+	Enabled               bool   `mapstructure:"enabled"`
+	QueueDir              string `mapstructure:"queue_dir"`
+	MaxWaitMinutes        int    `mapstructure:"max_wait_minutes"` // Must end before slurm.resume_timeout requeues the job
+	InitialBackoffSeconds int    `mapstructure:"initial_backoff_seconds"`
+	MaxBackoffSeconds     int    `mapstructure:"max_backoff_seconds"`
+	NotifyCommand         string `mapstructure:"notify_command"` // Run through the shell when a launch starts or stops waiting
+	Arbitration           string `mapstructure:"arbitration"`    // Order launches contending for vCPU quota: fifo (default), priority or fair_share
+}
+
+// How the state manager orders queued launches that contend for vCPU quota
+const (
+	CapacityArbitrationFIFO      = "fifo"       // Longest waiting first; quota failures are not queued
+	CapacityArbitrationPriority  = "priority"   // Highest Slurm job priority first
+	CapacityArbitrationFairShare = "fair_share" // Highest account fair-share factor first, then job priority
+)
+
+// Arbitrated reports whether launches contending for vCPU quota wait in the
+// queue, ordered by Slurm priority or fair share
+func (q *CapacityQueueConfig) Arbitrated() bool {
+	return q.Arbitration == CapacityArbitrationPriority || q.Arbitration == CapacityArbitrationFairShare
+}
+
+// MaxWait is how long a queued launch waits for capacity
+func (q *CapacityQueueConfig) MaxWait() time.Duration {
+	return time.Duration(q.MaxWaitMinutes) * time.Minute
+}
+
+// Backoff is the wait before the retry after a launch's attempts-th failure,
+// doubling from the initial backoff up to the maximum
+func (q *CapacityQueueConfig) Backoff(attempts int) time.Duration {
+	backoff := time.Duration(q.InitialBackoffSeconds) * time.Second
+	limit := time.Duration(q.MaxBackoffSeconds) * time.Second
+	for i := 1; i < attempts && backoff < limit; i++ {
+		backoff *= 2
+	}
+	return min(backoff, limit)
+}
+
+// validateCapacityQueue checks the backoff and that a launch stops waiting
+// before Slurm gives up on its nodes
+func validateCapacityQueue(queue *CapacityQueueConfig, resumeTimeoutSeconds int) error {
+	if !queue.Enabled {
+		return nil
+	}
+	if queue.QueueDir == "" {
+		return fmt.Errorf("capacity_queue.queue_dir is required")
+	}
+	if queue.MaxWaitMinutes <= 0 || queue.InitialBackoffSeconds <= 0 || queue.MaxBackoffSeconds <= 0 {
+		return fmt.Errorf("capacity_queue.max_wait_minutes, initial_backoff_seconds and max_backoff_seconds must be positive")
+	}
+	switch queue.Arbitration {
+	case "":
+		queue.Arbitration = CapacityArbitrationFIFO
+	case CapacityArbitrationFIFO, CapacityArbitrationPriority, CapacityArbitrationFairShare:
+	default:
+		return fmt.Errorf("capacity_queue.arbitration must be %s, %s or %s, got %q",
+			CapacityArbitrationFIFO, CapacityArbitrationPriority, CapacityArbitrationFairShare, queue.Arbitration)
+	}
+	if queue.MaxBackoffSeconds < queue.InitialBackoffSeconds {
+		return fmt.Errorf("capacity_queue.max_backoff_seconds must be at least initial_backoff_seconds")
+	}
+	if queue.MaxWait() >= time.Duration(resumeTimeoutSeconds)*time.Second {
+		return fmt.Errorf("capacity_queue.max_wait_minutes (%d) must end before slurm.resume_timeout (%ds), or Slurm requeues the job first",
+			queue.MaxWaitMinutes, resumeTimeoutSeconds)
+	}
+	return nil
+}
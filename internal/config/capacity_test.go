@@ -0,0 +1,98 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapacityQueueBackoff(t *testing.T) {
+	queue := CapacityQueueConfig{InitialBackoffSeconds: 60, MaxBackoffSeconds: 300}
+
+	assert.Equal(t, time.Minute, queue.Backoff(1))
+	assert.Equal(t, 2*time.Minute, queue.Backoff(2))
+	assert.Equal(t, 4*time.Minute, queue.Backoff(3))
+	assert.Equal(t, 5*time.Minute, queue.Backoff(4))
+	assert.Equal(t, 5*time.Minute, queue.Backoff(40), "capped without overflowing")
+}
+
+func TestValidateCapacityQueue(t *testing.T) {
+	tests := []struct {
+		name                 string
+		queue                CapacityQueueConfig
+		resumeTimeoutSeconds int
+		expectError          string
+		expectArbitration    string
+		expectArbitrated     bool
+	}{
+		{
+			name:                 "disabled",
+			queue:                CapacityQueueConfig{},
+			resumeTimeoutSeconds: 300,
+		},
+		{
+			name:                 "valid queue defaults to fifo",
+			queue:                CapacityQueueConfig{Enabled: true, QueueDir: "/var/spool/aws-slurm-burst/capacity", MaxWaitMinutes: 30, InitialBackoffSeconds: 60, MaxBackoffSeconds: 600},
+			resumeTimeoutSeconds: 3600,
+			expectArbitration:    CapacityArbitrationFIFO,
+		},
+		{
+			name:                 "fair share arbitration",
+			queue:                CapacityQueueConfig{Enabled: true, QueueDir: "/var/spool/aws-slurm-burst/capacity", MaxWaitMinutes: 30, InitialBackoffSeconds: 60, MaxBackoffSeconds: 600, Arbitration: CapacityArbitrationFairShare},
+			resumeTimeoutSeconds: 3600,
+			expectArbitration:    CapacityArbitrationFairShare,
+			expectArbitrated:     true,
+		},
+		{
+			name:                 "wait outlasts resume timeout",
+			queue:                CapacityQueueConfig{Enabled: true, QueueDir: "/var/spool/aws-slurm-burst/capacity", MaxWaitMinutes: 30, InitialBackoffSeconds: 60, MaxBackoffSeconds: 600},
+			resumeTimeoutSeconds: 1800,
+			expectError:          "capacity_queue.max_wait_minutes (30) must end before slurm.resume_timeout (1800s), or Slurm requeues the job first",
+		},
+		{
+			name:                 "no queue dir",
+			queue:                CapacityQueueConfig{Enabled: true, MaxWaitMinutes: 30, InitialBackoffSeconds: 60, MaxBackoffSeconds: 600},
+			resumeTimeoutSeconds: 3600,
+			expectError:          "capacity_queue.queue_dir is required",
+		},
+		{
+			name:                 "no max wait",
+			queue:                CapacityQueueConfig{Enabled: true, QueueDir: "/var/spool/aws-slurm-burst/capacity", InitialBackoffSeconds: 60, MaxBackoffSeconds: 600},
+			resumeTimeoutSeconds: 3600,
+			expectError:          "capacity_queue.max_wait_minutes, initial_backoff_seconds and max_backoff_seconds must be positive",
+		},
+		{
+			name:                 "negative initial backoff",
+			queue:                CapacityQueueConfig{Enabled: true, QueueDir: "/var/spool/aws-slurm-burst/capacity", MaxWaitMinutes: 30, InitialBackoffSeconds: -1, MaxBackoffSeconds: 600},
+			resumeTimeoutSeconds: 3600,
+			expectError:          "capacity_queue.max_wait_minutes, initial_backoff_seconds and max_backoff_seconds must be positive",
+		},
+		{
+			name:                 "max backoff below initial",
+			queue:                CapacityQueueConfig{Enabled: true, QueueDir: "/var/spool/aws-slurm-burst/capacity", MaxWaitMinutes: 30, InitialBackoffSeconds: 60, MaxBackoffSeconds: 30},
+			resumeTimeoutSeconds: 3600,
+			expectError:          "capacity_queue.max_backoff_seconds must be at least initial_backoff_seconds",
+		},
+		{
+			name:                 "unknown arbitration",
+			queue:                CapacityQueueConfig{Enabled: true, QueueDir: "/var/spool/aws-slurm-burst/capacity", MaxWaitMinutes: 30, InitialBackoffSeconds: 60, MaxBackoffSeconds: 600, Arbitration: "lottery"},
+			resumeTimeoutSeconds: 3600,
+			expectError:          `capacity_queue.arbitration must be fifo, priority or fair_share, got "lottery"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := tt.queue
+			err := validateCapacityQueue(&queue, tt.resumeTimeoutSeconds)
+			if tt.expectError != "" {
+				assert.EqualError(t, err, tt.expectError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectArbitration, queue.Arbitration)
+			assert.Equal(t, tt.expectArbitrated, queue.Arbitrated())
+		})
+	}
+}
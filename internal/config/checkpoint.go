@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// spotWarningSeconds is how long EC2 warns before reclaiming a spot instance
+const spotWarningSeconds = 120
+
+// CheckpointConfig installs a spot interruption watcher on burst nodes through
+// user data. When a node's instance gets its two-minute interruption warning,
+// the watcher checkpoints the checkpointable jobs running on it and requeues
+// them, so Slurm resumes replacement nodes and the jobs restore from their
+// checkpoints there. A job is checkpointable when its execution plan says so,
+// its comment contains "asb:checkpoint" (or "asb:checkpoint=<method>"), or it
+// runs in one of Partitions.
+type CheckpointConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	Method       string   `mapstructure:"method"`        // dmtcp, signal (default) or command
+	Signal       string   `mapstructure:"signal"`        // Sent to every process of the job by the signal method
+	Command      string   `mapstructure:"command"`       // Shell run by the command method, with SLURM_JOB_ID and ASB_CHECKPOINT_DIR set
+	Directory    string   `mapstructure:"directory"`     // Shared directory holding a checkpoint directory per job
+	GraceSeconds int      `mapstructure:"grace_seconds"` // Time a checkpoint may take before the job is requeued anyway
+	PollSeconds  int      `mapstructure:"poll_seconds"`  // How often nodes check for an interruption warning
+	Partitions   []string `mapstructure:"partitions"`    // Partitions whose jobs are all checkpointable
+}
+
+// validateCheckpoint checks the method and directory, that checkpoints finish
+// within the interruption warning, and that listed partitions are configured
+func validateCheckpoint(config *Config) error {
+	checkpoint := &config.Checkpoint
+	if !checkpoint.Enabled {
+		return nil
+	}
+	if !slices.Contains(types.CheckpointMethods, checkpoint.Method) {
+		return fmt.Errorf("checkpoint.method must be one of %s, got %q", strings.Join(types.CheckpointMethods, ", "), checkpoint.Method)
+	}
+	if checkpoint.Method == types.CheckpointCommand && strings.TrimSpace(checkpoint.Command) == "" {
+		return fmt.Errorf("checkpoint.command is required by the command method")
+	}
+	if checkpoint.Signal == "" || !types.ValidSignalName(checkpoint.Signal) {
+		return fmt.Errorf("checkpoint.signal must be a signal name such as USR1, got %q", checkpoint.Signal)
+	}
+	if !filepath.IsAbs(checkpoint.Directory) || strings.ContainsAny(checkpoint.Directory, "\"$`\\ \n") {
+		return fmt.Errorf("checkpoint.directory must be an absolute directory shared by the burst nodes, got %q", checkpoint.Directory)
+	}
+	if checkpoint.PollSeconds <= 0 {
+		return fmt.Errorf("checkpoint.poll_seconds must be positive")
+	}
+	// The requeue must reach slurmctld before the instance is reclaimed
+	if checkpoint.GraceSeconds <= 0 || checkpoint.GraceSeconds+checkpoint.PollSeconds > spotWarningSeconds-10 {
+		return fmt.Errorf("checkpoint.grace_seconds plus poll_seconds must leave 10 seconds of the %ds spot warning, got %d+%d",
+			spotWarningSeconds, checkpoint.GraceSeconds, checkpoint.PollSeconds)
+	}
+	for _, partition := range checkpoint.Partitions {
+		if config.FindPartition(partition) == nil {
+			return fmt.Errorf("checkpoint.partitions: %q is not a configured partition", partition)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCheckpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		checkpoint  CheckpointConfig
+		expectError string
+	}{
+		{
+			name:       "disabled",
+			checkpoint: CheckpointConfig{},
+		},
+		{
+			name: "valid signal checkpoint",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/checkpoints",
+				GraceSeconds: 90, PollSeconds: 5, Partitions: []string{"aws"},
+			},
+		},
+		{
+			name: "valid dmtcp checkpoint",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointDMTCP, Signal: "USR1", Directory: "/fsx/checkpoints",
+				GraceSeconds: 90, PollSeconds: 5,
+			},
+		},
+		{
+			name: "valid command checkpoint",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointCommand, Command: "/opt/site/checkpoint-job", Signal: "USR1",
+				Directory: "/fsx/checkpoints", GraceSeconds: 90, PollSeconds: 5,
+			},
+		},
+		{
+			name: "unknown method",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: "criu", Signal: "USR1", Directory: "/fsx/checkpoints",
+				GraceSeconds: 90, PollSeconds: 5,
+			},
+			expectError: `checkpoint.method must be one of dmtcp, signal, command, got "criu"`,
+		},
+		{
+			name: "command method without command",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointCommand, Signal: "USR1", Directory: "/fsx/checkpoints",
+				GraceSeconds: 90, PollSeconds: 5,
+			},
+			expectError: "checkpoint.command is required by the command method",
+		},
+		{
+			name: "signal with prefix",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointSignal, Signal: "SIGUSR1", Directory: "/fsx/checkpoints",
+				GraceSeconds: 90, PollSeconds: 5,
+			},
+			expectError: `checkpoint.signal must be a signal name such as USR1, got "SIGUSR1"`,
+		},
+		{
+			name: "relative directory",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointSignal, Signal: "USR1", Directory: "checkpoints",
+				GraceSeconds: 90, PollSeconds: 5,
+			},
+			expectError: `checkpoint.directory must be an absolute directory shared by the burst nodes, got "checkpoints"`,
+		},
+		{
+			name: "directory with shell",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/$(reboot)",
+				GraceSeconds: 90, PollSeconds: 5,
+			},
+			expectError: `checkpoint.directory must be an absolute directory shared by the burst nodes, got "/fsx/$(reboot)"`,
+		},
+		{
+			name: "no polling",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/checkpoints",
+				GraceSeconds: 90,
+			},
+			expectError: "checkpoint.poll_seconds must be positive",
+		},
+		{
+			name: "grace past warning",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/checkpoints",
+				GraceSeconds: 110, PollSeconds: 5,
+			},
+			expectError: "checkpoint.grace_seconds plus poll_seconds must leave 10 seconds of the 120s spot warning, got 110+5",
+		},
+		{
+			name: "no grace",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/checkpoints",
+				PollSeconds: 5,
+			},
+			expectError: "checkpoint.grace_seconds plus poll_seconds must leave 10 seconds of the 120s spot warning, got 0+5",
+		},
+		{
+			name: "unconfigured partition",
+			checkpoint: CheckpointConfig{
+				Enabled: true, Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/checkpoints",
+				GraceSeconds: 90, PollSeconds: 5, Partitions: []string{"gpu"},
+			},
+			expectError: `checkpoint.partitions: "gpu" is not a configured partition`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Slurm:      SlurmConfig{Partitions: []PartitionConfig{{PartitionName: "aws"}}},
+				Checkpoint: tt.checkpoint,
+			}
+			err := validateCheckpoint(config)
+			if tt.expectError != "" {
+				assert.EqualError(t, err, tt.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -2,10 +2,16 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/mpidetect"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
@@ -19,14 +25,266 @@ type Config struct {
 	MPI       MPIConfig     `mapstructure:"mpi"`
 	Logging   LoggingConfig `mapstructure:"logging"`
 	Ecosystem EcosystemConfig `mapstructure:"ecosystem"`
+	API       APIConfig     `mapstructure:"api"`
+	Status    StatusConfig  `mapstructure:"status"`
+	Export    ExportConfig  `mapstructure:"export"`
+	Epilog    EpilogConfig  `mapstructure:"epilog"`
+
+	NodeMetrics NodeMetricsConfig `mapstructure:"node_metrics"`
+
+	// Cost model for what burst jobs pay beyond their instances
+	Costs CostsConfig `mapstructure:"costs"`
+
+	// When resume may burst, by partition and account
+	BurstWindows BurstWindowsConfig `mapstructure:"burst_windows"`
+
+	// How standalone jobs' capacity is bought, by job priority
+	PurchasingPolicy PurchasingPolicyConfig `mapstructure:"purchasing_policy"`
+
+	// Launches that wait for AWS capacity instead of failing
+	CapacityQueue CapacityQueueConfig `mapstructure:"capacity_queue"`
+
+	// slurmd and munge installed from user data, for AMIs without Slurm
+	NodeBootstrap NodeBootstrapConfig `mapstructure:"node_bootstrap"`
+
+	// The controller's munge key, fetched by burst nodes from SSM or S3
+	MungeKey MungeKeyConfig `mapstructure:"munge_key"`
+
+	// Bursts refused while the VPN or Direct Connect path to slurmctld is down
+	NetworkCheck NetworkCheckConfig `mapstructure:"network_check"`
+
+	// Burst node records in a Route 53 private hosted zone
+	DNS DNSConfig `mapstructure:"dns"`
+
+	// Burst node entries in the controller's /etc/hosts, for sites without DNS
+	HostsFile HostsFileConfig `mapstructure:"hosts_file"`
+
+	// New nodes stay drained until health checks run through SSM pass
+	HealthCheck HealthCheckConfig `mapstructure:"health_check"`
+
+	// Console output of nodes that fail health checks or never register
+	BootDiagnostics BootDiagnosticsConfig `mapstructure:"boot_diagnostics"`
+
+	// Site scripts and webhooks run before and after launches and terminations
+	Hooks []HookConfig `mapstructure:"hooks"`
+
+	// External programs that can veto or modify standalone execution plans
+	PlanModules []PlanModuleConfig `mapstructure:"plan_modules"`
+
+	// Array jobs that run on AWS Batch instead of burst nodes
+	BatchFallback BatchFallbackConfig `mapstructure:"batch_fallback"`
+
+	// Spot interruptions checkpoint and requeue checkpointable jobs
+	Checkpoint CheckpointConfig `mapstructure:"checkpoint"`
+
+	// Experimental: interrupted spot nodes of elastic MPI jobs replaced mid-job
+	ElasticMPI ElasticMPIConfig `mapstructure:"elastic_mpi"`
+
+	// Burst buffer directives in job scripts get scratch volumes and data staging
+	Staging StagingConfig `mapstructure:"staging"`
+
+	// #ASBX pragmas in job scripts override standalone-mode bursting per job
+	JobOverrides JobOverridesConfig `mapstructure:"job_overrides"`
+
+	// Institutional defaults for the jobs of Slurm users and accounts
+	Policies PoliciesConfig `mapstructure:"policies"`
+
+	// Where the emergency-stop command keeps its state and audit log
+	EmergencyStop EmergencyStopConfig `mapstructure:"emergency_stop"`
+
+	// Deliberate failures for game-day rehearsals; never set in production
+	FailureInjection FailureInjectionConfig `mapstructure:"failure_injection"`
+
+	// In-memory AWS and Slurm used by resume --simulate
+	Simulation SimulationConfig `mapstructure:"simulation"`
+
+	// Glob patterns of fragment files, relative to this file, whose partitions
+	// are merged into slurm.partitions
+	Include []string `mapstructure:"include"`
+}
+
+// SimulationConfig shapes the in-memory EC2 and Slurm that resume --simulate
+// runs against, so execution plans can be tried without either
+type SimulationConfig struct {
+	EC2LatencyMS   int `mapstructure:"ec2_latency_ms"`   // Added to every simulated EC2 call
+	SlurmLatencyMS int `mapstructure:"slurm_latency_ms"` // Added to every simulated Slurm command
+	BootSeconds    int `mapstructure:"boot_seconds"`     // Time a simulated instance takes to reach running
+	Capacity       int `mapstructure:"capacity"`         // Most simulated instances at once; 0 is unlimited
+}
+
+// FailureInjectionEnv must be set to 1 in the environment of the resume, suspend
+// and state-manager processes for failure_injection to take effect
+const FailureInjectionEnv = "AWS_SLURM_BURST_FAILURE_INJECTION"
+
+// FailureInjectionConfig makes a share of fleet launches and Slurm node updates
+// fail and delays instance waits, so operators can rehearse stuck-node and
+// partial-launch runbooks and check that the state manager recovers. It only
+// takes effect with FailureInjectionEnv set, so a copied configuration cannot
+// inject failures on a production cluster.
+type FailureInjectionConfig struct {
+	Enabled              bool    `mapstructure:"enabled"`
+	FleetErrorRate       float64 `mapstructure:"fleet_error_rate"`        // Share of fleet launches failing with InsufficientInstanceCapacity, 0-1
+	SlurmUpdateErrorRate float64 `mapstructure:"slurm_update_error_rate"` // Share of scontrol node updates failing, 0-1
+	WaiterDelaySeconds   int     `mapstructure:"waiter_delay_seconds"`    // Added before waiting for launched instances to run
+}
+
+// Active reports whether failures are injected
+func (f FailureInjectionConfig) Active() bool {
+	return f.Enabled && os.Getenv(FailureInjectionEnv) == "1"
+}
+
+// NodeMetricsConfig controls the node-side collector installed through user data.
+// Each node samples CPU, memory, network and EFA counters while jobs run and writes
+// a per-node summary to Destination from its node epilog.
+type NodeMetricsConfig struct {
+	Enabled         bool   `mapstructure:"enabled"`
+	IntervalSeconds int    `mapstructure:"interval_seconds"` // Sampling interval on each node
+	Destination     string `mapstructure:"destination"`      // s3://bucket/prefix or a directory shared with the controller
+}
+
+// CostsConfig prices the parts of a burst job's cost that instance prices do
+// not cover
+type CostsConfig struct {
+	DataTransfer DataTransferConfig `mapstructure:"data_transfer"`
+	Storage      StorageCostConfig  `mapstructure:"storage"`
+	Commitments  CommitmentsConfig  `mapstructure:"commitments"`
+	Ceilings     CeilingsConfig     `mapstructure:"ceilings"`
+}
+
+// CeilingsConfig caps the monthly burst spend of partitions, accounts and users.
+// Job costs are recorded in a ledger as they are exported; once a scope's
+// spend reaches its ceiling, resume refuses to burst for it until the month
+// rolls over or an administrator overrides the lockout.
+type CeilingsConfig struct {
+	LedgerDir     string          `mapstructure:"ledger_dir"`     // Monthly spend ledgers, shared by export and resume
+	NotifyCommand string          `mapstructure:"notify_command"` // Run once per scope and month when a ceiling is reached
+	Limits        []CeilingConfig `mapstructure:"limits"`
+}
+
+// CeilingConfig is the monthly ceiling of one partition, account or user
+type CeilingConfig struct {
+	Partition  string  `mapstructure:"partition"`
+	Account    string  `mapstructure:"account"`
+	User       string  `mapstructure:"user"`
+	MonthlyUSD float64 `mapstructure:"monthly_usd"`
+}
+
+// CommitmentsConfig is the committed-use coverage on-demand burst instances
+// run on, so their usage is priced at the discounted rate and reported as
+// already paid for. Coverage is the share of usage a commitment covers, as
+// Cost Explorer's coverage reports give it. Reserved instances apply first,
+// and the savings plan covers its share of the rest. Spot usage is never
+// covered.
+type CommitmentsConfig struct {
+	SavingsPlan       SavingsPlanConfig        `mapstructure:"savings_plan"` // Compute Savings Plan
+	ReservedInstances []ReservedInstanceConfig `mapstructure:"reserved_instances"`
+}
+
+// SavingsPlanConfig is a Compute Savings Plan's discount and coverage
+type SavingsPlanConfig struct {
+	Discount float64 `mapstructure:"discount"` // Share off the on-demand price, 0-1
+	Coverage float64 `mapstructure:"coverage"` // Share of on-demand usage covered, 0-1
+}
+
+// ReservedInstanceConfig is the reserved capacity of an instance family
+type ReservedInstanceConfig struct {
+	InstanceFamily string  `mapstructure:"instance_family"` // e.g. "c6i"
+	Discount       float64 `mapstructure:"discount"`        // Share off the on-demand price, 0-1
+	Coverage       float64 `mapstructure:"coverage"`        // Share of the family's on-demand usage covered, 0-1
+}
+
+// StorageCostConfig prices the storage burst jobs use: the EBS volumes of
+// their instances, and FSx filesystems shared by the partitions that mount
+// them
+type StorageCostConfig struct {
+	EBSRatesPerGBMonth map[string]float64 `mapstructure:"ebs_rates_per_gb_month"` // $/GB-month by volume type, replacing the built-in list prices
+	FileSystems        []FileSystemConfig `mapstructure:"file_systems"`
+}
+
+// FileSystemConfig is an FSx filesystem burst nodes mount. Its cost is split
+// evenly over the nodes its partitions can run, so a job is charged its
+// nodes' share for as long as it ran; capacity no job used is not attributed.
+type FileSystemConfig struct {
+	Name           string   `mapstructure:"name"`
+	StorageGB      int      `mapstructure:"storage_gb"`
+	RatePerGBMonth float64  `mapstructure:"rate_per_gb_month"` // Defaults to the scratch list price
+	Partitions     []string `mapstructure:"partitions"`        // Partitions whose nodes mount it
+}
+
+// Data transfer classes, each with its own $/GB rate
+const (
+	TransferInternet      = "internet"       // Out to the internet, including on-premises sites reached over it
+	TransferDirectConnect = "direct_connect" // Out to on premises over Direct Connect
+	TransferVPN           = "vpn"            // Out to on premises over Site-to-Site VPN
+	TransferCrossRegion   = "cross_region"   // To another AWS region
+	TransferCrossAZ       = "cross_az"       // Between nodes in different zones, both directions
+	TransferNATGateway    = "nat_gateway"    // NAT gateway processing, on top of the egress class
+)
+
+// EgressClasses are the classes data_transfer.egress_class may name
+var EgressClasses = []string{TransferInternet, TransferDirectConnect, TransferVPN, TransferCrossRegion}
+
+// DataTransferConfig models what burst jobs pay to move data. Each job's
+// traffic is what its nodes sent, measured by the node metrics collector, or
+// EstimatedGBPerNodeHour for jobs without measurements. EgressShare of it
+// leaves AWS as EgressClass; the rest stays in the cluster and is charged as
+// cross-AZ traffic when the job's nodes span zones.
+type DataTransferConfig struct {
+	EgressClass            string             `mapstructure:"egress_class"`               // One of EgressClasses; empty is internet
+	EgressShare            float64            `mapstructure:"egress_share"`               // Share of node traffic leaving AWS, 0-1
+	NATGateway             bool               `mapstructure:"nat_gateway"`                // Egress passes a NAT gateway and pays its processing charge
+	EstimatedGBPerNodeHour float64            `mapstructure:"estimated_gb_per_node_hour"` // Assumed traffic for jobs without node measurements
+	RatesPerGB             map[string]float64 `mapstructure:"rates_per_gb"`               // $/GB by class, replacing the built-in list prices
+}
+
+// EpilogConfig controls the exports run for completed burst jobs. The epilog queues
+// jobs in QueueDir and the state manager exports them.
+type EpilogConfig struct {
+	QueueDir       string   `mapstructure:"queue_dir"`
+	Formats        []string `mapstructure:"formats"`         // export-performance formats run per job
+	OutputDir      string   `mapstructure:"output_dir"`      // Cost records go to asbb.reconciliation_dir
+	Output         string   `mapstructure:"output"`          // Optional s3://bucket/prefix instead of local files
+	ExportProgram  string   `mapstructure:"export_program"`
+	TimeoutSeconds int      `mapstructure:"timeout_seconds"` // Per export format
+	MaxAttempts    int      `mapstructure:"max_attempts"`    // Before a job is moved to queue_dir/failed
+}
+
+// ExportConfig controls uploads of performance and cost exports to s3:// outputs
+type ExportConfig struct {
+	Cluster  string `mapstructure:"cluster"`    // Partition key in object keys; defaults to the hostname
+	Region   string `mapstructure:"region"`     // Bucket region; defaults to aws.region
+	KMSKeyID string `mapstructure:"kms_key_id"` // SSE-KMS key; empty uses the AWS managed key
+
+	Anonymize AnonymizeConfig `mapstructure:"anonymize"` // Applied with --anonymize
+}
+
+// AnonymizeConfig controls how exports are anonymized for sharing
+type AnonymizeConfig struct {
+	SaltFile    string   `mapstructure:"salt_file"`    // HMAC key for user and account pseudonyms
+	Redact      []string `mapstructure:"redact"`       // Dotted JSON field paths to clear
+	PIIPatterns []string `mapstructure:"pii_patterns"` // Extra regular expressions output must not match
+}
+
+// StatusConfig controls the burst status document for portals such as Open OnDemand
+type StatusConfig struct {
+	OutputFile         string `mapstructure:"output_file"`          // Written by the state manager; empty disables
+	FailureWindowHours int    `mapstructure:"failure_window_hours"` // How far back job failures are listed
+}
+
+// APIConfig configures the localhost REST API external orchestrators use
+type APIConfig struct {
+	Listen    string `mapstructure:"listen"`     // Loopback host:port
+	TokenFile string `mapstructure:"token_file"` // Bearer token clients must present
 }
 
 // EcosystemConfig contains ecosystem-wide configuration
 type EcosystemConfig struct {
-	AutoDetect         bool   `mapstructure:"auto_detect"`
-	SharedConfigPath   string `mapstructure:"shared_config_path"`   // Optional shared config across all tools
-	DataExchangeDir    string `mapstructure:"data_exchange_dir"`    // Directory for inter-tool communication
-	EnableCrossProject bool   `mapstructure:"enable_cross_project"` // Enable cross-project features
+	AutoDetect          bool   `mapstructure:"auto_detect"`
+	SharedConfigPath    string `mapstructure:"shared_config_path"`    // Optional shared config across all tools
+	DataExchangeDir     string `mapstructure:"data_exchange_dir"`     // Directory for inter-tool communication
+	RetentionDays       int    `mapstructure:"retention_days"`        // Per-job exchange files older than this are removed; 0 keeps them
+	DetectionTTLMinutes int    `mapstructure:"detection_ttl_minutes"` // How long cached ASBA/ASBB detection stays valid; 0 probes every time
+	EnableCrossProject  bool   `mapstructure:"enable_cross_project"`  // Enable cross-project features
 }
 
 // AWSConfig contains AWS-specific configuration
@@ -44,6 +302,33 @@ type AWSConfig struct {
 	CrossAccount        *CrossAccountConfig    `mapstructure:"cross_account"`
 	AccessKeys          *AccessKeysConfig      `mapstructure:"access_keys"`
 	TokenRefresh        *TokenRefreshConfig    `mapstructure:"token_refresh"`
+
+	// Instance type catalog (DescribeInstanceTypes cache)
+	InstanceCatalog InstanceCatalogConfig `mapstructure:"instance_catalog"`
+
+	// Rank spot pools with GetSpotPlacementScores before spot launches
+	SpotPlacementScores bool `mapstructure:"spot_placement_scores"`
+
+	// Retries after capacity failures (InsufficientInstanceCapacity, SpotMaxPriceTooLow)
+	LaunchFallback LaunchFallbackConfig `mapstructure:"launch_fallback"`
+}
+
+// LaunchFallbackConfig defines the retries tried, in order, when a launch fails for
+// lack of capacity: on-demand, alternate instance families, then a secondary node
+// group, which may be in another region
+type LaunchFallbackConfig struct {
+	Enabled            bool                `mapstructure:"enabled"`
+	OnDemand           bool                `mapstructure:"on_demand"`
+	AlternateFamilies  map[string][]string `mapstructure:"alternate_families"` // Requested family -> families to try instead
+	SecondaryPartition string              `mapstructure:"secondary_partition"`
+	SecondaryNodeGroup string              `mapstructure:"secondary_node_group"`
+}
+
+// InstanceCatalogConfig controls the cached EC2 instance type catalog
+type InstanceCatalogConfig struct {
+	Enabled              bool   `mapstructure:"enabled"`
+	CacheDir             string `mapstructure:"cache_dir"`
+	RefreshIntervalHours int    `mapstructure:"refresh_interval_hours"`
 }
 
 // AccessKeysConfig contains static access key configuration (DISCOURAGED)
@@ -101,10 +386,72 @@ type SlurmConfig struct {
 	SuspendProgram string            `mapstructure:"suspend_program"`
 	ResumeRate     int               `mapstructure:"resume_rate"`
 	SuspendRate    int               `mapstructure:"suspend_rate"`
+	RateLimitDir   string            `mapstructure:"rate_limit_dir"` // Shares the rate budgets between resume and suspend runs
 	ResumeTimeout  int               `mapstructure:"resume_timeout"`
 	SuspendTime    int               `mapstructure:"suspend_time"`
 	TreeWidth      int               `mapstructure:"tree_width"`
 	Partitions     []PartitionConfig `mapstructure:"partitions"`
+
+	// Maps sbatch --constraint features to instance attributes for standalone mode
+	FeatureMappings []FeatureMapping `mapstructure:"feature_mappings"`
+
+	// Tag burst nodes with an az-<zone> feature when launches span availability zones
+	AZNodeFeatures bool `mapstructure:"az_node_features"`
+
+	// Drain nodes before suspend so running job steps and epilogs can finish
+	Drain DrainConfig `mapstructure:"drain"`
+
+	// Where burst metadata (aws_meta) is kept on jobs
+	JobMetadata JobMetadataConfig `mapstructure:"job_metadata"`
+
+	// How node names are built from the partition, node group and node number
+	NodeNaming NodeNamingConfig `mapstructure:"node_naming"`
+}
+
+// NodeNamingConfig shapes node names; the defaults give the original plugin's
+// aws-cpu-0. Every command builds and parses names with the same settings, so
+// changing them means redefining the nodes in slurm.conf.
+type NodeNamingConfig struct {
+	Prefix    string `mapstructure:"prefix"`    // Optional cluster prefix, e.g. "prod" for prod-aws-cpu-0
+	Separator string `mapstructure:"separator"` // Between parts; "-" by default
+	IDFormat  string `mapstructure:"id_format"` // printf verb for the node number, "%d" by default or e.g. "%03d"
+	FirstID   int    `mapstructure:"first_id"`  // Number of each node group's first node, 0 by default
+}
+
+// JobMetadataFields are the job fields that can hold burst metadata. Only operators
+// can set AdminComment and SystemComment; users can overwrite Comment.
+var JobMetadataFields = []string{"AdminComment", "Comment", "SystemComment"}
+
+// JobMetadataConfig chooses the job comment field burst metadata is written to
+// and read from, and how large it may grow
+type JobMetadataConfig struct {
+	Field          string   `mapstructure:"field"`           // AdminComment or Comment
+	MaxBytes       int      `mapstructure:"max_bytes"`       // Per field, including existing comment text
+	OverflowFields []string `mapstructure:"overflow_fields"` // Fields for further chunks of oversized metadata, e.g. SystemComment
+}
+
+// Fields returns the metadata field followed by its overflow fields
+func (m *JobMetadataConfig) Fields() []string {
+	field := m.Field
+	if field == "" {
+		field = "AdminComment"
+	}
+	return append([]string{field}, m.OverflowFields...)
+}
+
+// Transports the shutdown hook runs over
+const (
+	HookTransportSSH = "ssh"
+	HookTransportSSM = "ssm" // SSM Run Command, without SSH keys or inbound ports on the nodes
+)
+
+// DrainConfig controls the drain step suspend runs before powering nodes down
+type DrainConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	TimeoutSeconds     int    `mapstructure:"timeout_seconds"`      // Longest wait for running work to finish
+	ShutdownHook       string `mapstructure:"shutdown_hook"`        // Command run on each node, e.g. to flush scratch to S3
+	HookTimeoutSeconds int    `mapstructure:"hook_timeout_seconds"` // Longest run of the shutdown hook per node
+	HookTransport      string `mapstructure:"hook_transport"`       // ssh (default) or ssm
 }
 
 // PartitionConfig defines Slurm partition configuration
@@ -112,6 +459,19 @@ type PartitionConfig struct {
 	PartitionName    string            `mapstructure:"partition_name"`
 	NodeGroups       []NodeGroupConfig `mapstructure:"node_groups"`
 	PartitionOptions map[string]string `mapstructure:"partition_options"`
+
+	// Proactive scaling from queue depth for array and high-throughput workloads
+	Scaling ScalingPolicyConfig `mapstructure:"scaling"`
+}
+
+// ScalingPolicyConfig powers partition nodes up and down from the pending queue
+// instead of waiting for Slurm's power-save to react. Only single-node jobs count
+// toward demand; multi-node (gang) jobs are left to power-save.
+type ScalingPolicyConfig struct {
+	Enabled     bool `mapstructure:"enabled"`
+	MinNodes    int  `mapstructure:"min_nodes"`     // Nodes kept powered up even with an empty queue
+	MaxNodes    int  `mapstructure:"max_nodes"`     // Upper bound on powered-up nodes; 0 means every partition node
+	JobsPerNode int  `mapstructure:"jobs_per_node"` // Pending jobs one node absorbs; defaults to 1
 }
 
 // NodeGroupConfig defines node group configuration within a partition
@@ -121,14 +481,125 @@ type NodeGroupConfig struct {
 	Region                  string                   `mapstructure:"region"`
 	SlurmSpecifications     map[string]string        `mapstructure:"slurm_specifications"`
 	PurchasingOption        string                   `mapstructure:"purchasing_option"` // "spot" or "on-demand"
-	OnDemandOptions         map[string]interface{}   `mapstructure:"on_demand_options"`
-	SpotOptions             map[string]interface{}   `mapstructure:"spot_options"`
+	Architecture            string                   `mapstructure:"architecture"`      // "x86_64" or "arm64"; inferred from overrides if empty
+	OnDemandOptions         OnDemandOptionsConfig    `mapstructure:"on_demand_options"`
+	SpotOptions             SpotOptionsConfig        `mapstructure:"spot_options"`
 	LaunchTemplateSpec      LaunchTemplateSpec       `mapstructure:"launch_template_specification"`
 	LaunchTemplateOverrides []LaunchTemplateOverride `mapstructure:"launch_template_overrides"`
 	SubnetIds               []string                 `mapstructure:"subnet_ids"`
 	SecurityGroupIds        []string                 `mapstructure:"security_group_ids"`
 	IAMInstanceProfile      string                   `mapstructure:"iam_instance_profile"`
 	Tags                    []AWSTag                 `mapstructure:"tags"`
+	WarmPool                WarmPoolConfig           `mapstructure:"warm_pool"`
+
+	// EBS volumes each instance launches with, for storage costs; empty reads
+	// them from the launch template's block device mappings
+	Volumes []VolumeConfig `mapstructure:"volumes"`
+
+	// How suspended nodes' instances are powered down; stopped instances restart on
+	// resume and are terminated once idle for TerminateAfterIdleMinutes
+	SuspendMode               string `mapstructure:"suspend_mode"` // "terminate" (default), "stop" or "hibernate"
+	TerminateAfterIdleMinutes int    `mapstructure:"terminate_after_idle_minutes"`
+
+	// Keep suspended nodes' instances running for the next resume to claim
+	Linger LingerConfig `mapstructure:"linger"`
+
+	// Size instances for several jobs per node and split their cost between them
+	JobPacking JobPackingConfig `mapstructure:"job_packing"`
+
+	// Launch containerized jobs' nodes through an EKS cluster instead of EC2 Fleet
+	ContainerBackend ContainerBackendConfig `mapstructure:"container_backend"`
+
+	// Hand provisioning to an existing AWS PCS or ParallelCluster queue
+	Delegate DelegateConfig `mapstructure:"delegate"`
+}
+
+// EBS volume types, as EC2 names them
+var VolumeTypes = []string{"gp2", "gp3", "io1", "io2", "st1", "sc1", "standard"}
+
+// VolumeConfig describes an EBS volume a node group's instances launch with
+type VolumeConfig struct {
+	DeviceName     string `mapstructure:"device_name"`
+	VolumeType     string `mapstructure:"volume_type"` // One of VolumeTypes
+	SizeGB         int    `mapstructure:"size_gb"`
+	IOPS           int    `mapstructure:"iops"`            // Provisioned IOPS for gp3, io1 and io2
+	ThroughputMBps int    `mapstructure:"throughput_mbps"` // Provisioned gp3 throughput
+}
+
+// SpotOptionsConfig tunes the spot capacity of a node group's fleets; empty
+// fields keep the EC2 Fleet defaults aws-slurm-burst uses
+type SpotOptionsConfig struct {
+	AllocationStrategy           string  `mapstructure:"allocation_strategy"`            // Defaults to lowest-price, or capacity-optimized-prioritized with spot placement scores
+	MaxPrice                     float64 `mapstructure:"max_price"`                      // USD per instance-hour; a lower execution plan price still applies
+	InstanceInterruptionBehavior string  `mapstructure:"instance_interruption_behavior"` // terminate (default), stop or hibernate
+
+	Unknown map[string]interface{} `mapstructure:",remain"` // Misspelled settings, rejected by validation
+}
+
+// OnDemandOptionsConfig tunes the on-demand capacity of a node group's fleets
+type OnDemandOptionsConfig struct {
+	AllocationStrategy            string `mapstructure:"allocation_strategy"`             // lowest-price (default) or prioritized, in override order
+	CapacityReservationPreference string `mapstructure:"capacity_reservation_preference"` // none (default) or use-capacity-reservations-first
+
+	Unknown map[string]interface{} `mapstructure:",remain"` // Misspelled settings, rejected by validation
+}
+
+// Fleet option values accepted by EC2
+var (
+	SpotAllocationStrategies = []string{
+		"lowest-price", "diversified", "capacity-optimized",
+		"capacity-optimized-prioritized", "price-capacity-optimized",
+	}
+	SpotInterruptionBehaviors      = []string{"terminate", "stop", "hibernate"}
+	OnDemandAllocationStrategies   = []string{"lowest-price", "prioritized"}
+	CapacityReservationPreferences = []string{"none", "use-capacity-reservations-first"}
+)
+
+// Suspend modes for node groups
+const (
+	SuspendModeTerminate = "terminate"
+	SuspendModeStop      = "stop"
+	SuspendModeHibernate = "hibernate"
+)
+
+// EffectiveSuspendMode returns the node group's suspend mode, defaulting to terminate
+func (n *NodeGroupConfig) EffectiveSuspendMode() string {
+	if n.SuspendMode == "" {
+		return SuspendModeTerminate
+	}
+	return n.SuspendMode
+}
+
+// KeepsStoppedInstances reports whether suspend stops rather than terminates instances
+func (n *NodeGroupConfig) KeepsStoppedInstances() bool {
+	return n.EffectiveSuspendMode() != SuspendModeTerminate
+}
+
+// WarmPoolConfig keeps stopped, pre-configured instances that resume starts instead
+// of launching a fleet. The state manager replenishes the pool and recycles old instances.
+type WarmPoolConfig struct {
+	Size               int    `mapstructure:"size"`                 // Stopped instances to keep; 0 disables the pool
+	InstanceType       string `mapstructure:"instance_type"`        // Defaults to the first launch template override
+	Hibernate          bool   `mapstructure:"hibernate"`            // Hibernate instead of stop; the AMI must support it
+	WarmupMinutes      int    `mapstructure:"warmup_minutes"`       // Time for user data to finish before the instance is stopped
+	MaxAgeHours        int    `mapstructure:"max_age_hours"`        // Instances older than this are replaced
+	PlacementGroupName string `mapstructure:"placement_group_name"` // Optional existing cluster placement group for MPI jobs
+}
+
+// Enabled reports whether the node group keeps a warm pool
+func (w WarmPoolConfig) Enabled() bool {
+	return w.Size > 0
+}
+
+// WarmPoolInstanceType returns the warm pool's instance type
+func (n *NodeGroupConfig) WarmPoolInstanceType() string {
+	if n.WarmPool.InstanceType != "" {
+		return n.WarmPool.InstanceType
+	}
+	if len(n.LaunchTemplateOverrides) > 0 {
+		return n.LaunchTemplateOverrides[0].InstanceType
+	}
+	return ""
 }
 
 // LaunchTemplateSpec defines EC2 launch template specification
@@ -154,12 +625,23 @@ type AWSTag struct {
 
 // ASBAConfig contains configuration for ASBA integration
 type ASBAConfig struct {
-	Enabled    string `mapstructure:"enabled"`    // "auto-detect", "true", "false"
-	Command    string `mapstructure:"command"`
-	ConfigPath string `mapstructure:"config_path"`
-	Timeout    int    `mapstructure:"timeout_seconds"`
+	Enabled           string `mapstructure:"enabled"` // "auto-detect", "true", "false"
+	Command           string `mapstructure:"command"`
+	ConfigPath        string `mapstructure:"config_path"`
+	Timeout           int    `mapstructure:"timeout_seconds"`    // Per attempt
+	IncompatiblePlans string `mapstructure:"incompatible_plans"` // "refuse" or "standalone" for plans with an unsupported schema
+
+	MaxRetries             int `mapstructure:"max_retries"`              // Extra attempts after a failed call, with jittered backoff
+	BreakerThreshold       int `mapstructure:"breaker_threshold"`        // Consecutive failed calls before resume stops calling ASBA; 0 disables
+	BreakerCooldownSeconds int `mapstructure:"breaker_cooldown_seconds"` // How long resume stays in standalone mode once the breaker opens
 }
 
+// What resume does with an execution plan whose schema it cannot execute
+const (
+	IncompatiblePlansRefuse     = "refuse"     // Fail the resume with the reason
+	IncompatiblePlansStandalone = "standalone" // Launch from the static configuration instead
+)
+
 // ASBBConfig contains configuration for ASBB integration
 type ASBBConfig struct {
 	Enabled           string `mapstructure:"enabled"`             // "auto-detect", "true", "false"
@@ -175,6 +657,51 @@ type MPIConfig struct {
 	PlacementGroupThreshold  int    `mapstructure:"placement_group_threshold"`
 	ForceClusterPG           bool   `mapstructure:"force_cluster_placement_group"`
 	EnableEnhancedNetworking bool   `mapstructure:"enable_enhanced_networking"`
+	UserDataSetup            bool   `mapstructure:"user_data_setup"` // Install EFA/MPI/Neuron via user data when plans request it
+
+	// MPI detection tuning
+	DetectionThreshold float64                   `mapstructure:"detection_threshold"` // Confidence above which a job is MPI
+	Detectors          map[string]DetectorConfig `mapstructure:"detectors"`           // Per-detector enable/weight, keyed by name
+	CustomDetectors    []CustomDetectorConfig    `mapstructure:"custom_detectors"`    // Site-specific pattern detectors
+
+	// Optional profiling of launches through aws-slurm-burst-mpirun
+	Profiling MPIProfilingConfig `mapstructure:"profiling"`
+}
+
+// MPIProfilingConfig controls the aws-slurm-burst-mpirun wrapper, which preloads
+// mpiP into MPI ranks and records a communication summary per launch
+type MPIProfilingConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Launcher    string `mapstructure:"launcher"`     // Real launcher the wrapper runs, e.g. mpirun or mpiexec
+	MPIPLibrary string `mapstructure:"mpip_library"` // libmpiP.so; launches run unprofiled when it is missing
+	OutputDir   string `mapstructure:"output_dir"`   // Shared with the controller; reports and summaries per job
+}
+
+// DetectorConfig enables, disables, or weights an MPI detector
+type DetectorConfig struct {
+	Enabled *bool   `mapstructure:"enabled"` // Defaults to true
+	Weight  float64 `mapstructure:"weight"`  // Multiplies detector confidence; defaults to 1.0
+}
+
+// CustomDetectorConfig defines a pattern-based MPI detector, e.g. for site wrapper scripts
+type CustomDetectorConfig struct {
+	Name           string   `mapstructure:"name"`
+	ScriptPatterns []string `mapstructure:"script_patterns"`
+	Confidence     float64  `mapstructure:"confidence"`
+	Topology       string   `mapstructure:"topology"` // "cluster" (default), "spread", "partition", "any"
+}
+
+// IsEnabled reports whether the detector should run
+func (d DetectorConfig) IsEnabled() bool {
+	return d.Enabled == nil || *d.Enabled
+}
+
+// EffectiveWeight returns the detector weight, defaulting to 1.0
+func (d DetectorConfig) EffectiveWeight() float64 {
+	if d.Weight == 0 {
+		return 1.0
+	}
+	return d.Weight
 }
 
 // LoggingConfig contains logging configuration
@@ -204,6 +731,20 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// Node groups inherit the node group defaults before anything else reads them
+	if err := applyNodeGroupDefaults(viper.GetViper(), &config.Slurm); err != nil {
+		return nil, fmt.Errorf("invalid node group defaults: %w", err)
+	}
+
+	// Expand ${ENV_VAR} and ${file:/path} references before validating
+	if err := interpolate(&config); err != nil {
+		return nil, fmt.Errorf("config interpolation failed: %w", err)
+	}
+
+	if err := mergeIncludes(&config, configPath); err != nil {
+		return nil, fmt.Errorf("config include failed: %w", err)
+	}
+
 	// Validate and normalize configuration
 	if err := validate(&config); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
@@ -220,6 +761,12 @@ func setDefaults() {
 	viper.SetDefault("aws.retry_max_attempts", 3)
 	viper.SetDefault("aws.retry_mode", "adaptive")
 	viper.SetDefault("aws.authentication_method", "instance_profile") // Secure default for AWS deployments
+	viper.SetDefault("aws.instance_catalog.enabled", true)
+	viper.SetDefault("aws.instance_catalog.cache_dir", "/var/cache/aws-slurm-burst")
+	viper.SetDefault("aws.instance_catalog.refresh_interval_hours", 24)
+	viper.SetDefault("aws.spot_placement_scores", true)
+	viper.SetDefault("aws.launch_fallback.enabled", true)
+	viper.SetDefault("aws.launch_fallback.on_demand", true)
 
 	// Slurm defaults (from original plugin)
 	viper.SetDefault("slurm.bin_path", "/usr/bin")
@@ -227,14 +774,26 @@ func setDefaults() {
 	viper.SetDefault("slurm.private_data", "CLOUD")
 	viper.SetDefault("slurm.resume_rate", 100)
 	viper.SetDefault("slurm.suspend_rate", 100)
+	viper.SetDefault("slurm.rate_limit_dir", "/var/spool/aws-slurm-burst/ratelimit")
 	viper.SetDefault("slurm.resume_timeout", 300)
 	viper.SetDefault("slurm.suspend_time", 350)
 	viper.SetDefault("slurm.tree_width", 60000)
+	viper.SetDefault("slurm.az_node_features", true)
+	viper.SetDefault("slurm.drain.enabled", true)
+	viper.SetDefault("slurm.drain.timeout_seconds", 120)
+	viper.SetDefault("slurm.drain.hook_timeout_seconds", 300)
+	viper.SetDefault("slurm.drain.hook_transport", HookTransportSSH)
+	viper.SetDefault("slurm.job_metadata.field", "AdminComment")
+	viper.SetDefault("slurm.job_metadata.max_bytes", 4096)
 
 	// ASBA defaults
 	viper.SetDefault("asba.enabled", "auto-detect")
 	viper.SetDefault("asba.command", "asba")
 	viper.SetDefault("asba.timeout_seconds", 30)
+	viper.SetDefault("asba.incompatible_plans", IncompatiblePlansRefuse)
+	viper.SetDefault("asba.max_retries", 2)
+	viper.SetDefault("asba.breaker_threshold", 3)
+	viper.SetDefault("asba.breaker_cooldown_seconds", 300)
 
 	// ASBB defaults
 	viper.SetDefault("asbb.enabled", "auto-detect")
@@ -245,6 +804,8 @@ func setDefaults() {
 	// Ecosystem defaults
 	viper.SetDefault("ecosystem.auto_detect", true)
 	viper.SetDefault("ecosystem.data_exchange_dir", "/var/spool/asbx/ecosystem")
+	viper.SetDefault("ecosystem.retention_days", 30)
+	viper.SetDefault("ecosystem.detection_ttl_minutes", 60)
 	viper.SetDefault("ecosystem.enable_cross_project", true)
 
 	// MPI defaults
@@ -253,6 +814,12 @@ func setDefaults() {
 	viper.SetDefault("mpi.placement_group_threshold", 2)
 	viper.SetDefault("mpi.force_cluster_placement_group", false)
 	viper.SetDefault("mpi.enable_enhanced_networking", true)
+	viper.SetDefault("mpi.user_data_setup", true)
+	viper.SetDefault("mpi.detection_threshold", 0.5)
+	viper.SetDefault("mpi.profiling.enabled", false)
+	viper.SetDefault("mpi.profiling.launcher", "mpirun")
+	viper.SetDefault("mpi.profiling.mpip_library", "/usr/lib64/libmpiP.so")
+	viper.SetDefault("mpi.profiling.output_dir", "/var/spool/asba/mpi-profiles")
 
 	// Logging defaults
 	viper.SetDefault("logging.level", "info")
@@ -261,6 +828,83 @@ func setDefaults() {
 	viper.SetDefault("logging.max_size_mb", 100)
 	viper.SetDefault("logging.max_age_days", 30)
 	viper.SetDefault("logging.compress", true)
+
+	// API defaults
+	viper.SetDefault("api.listen", "127.0.0.1:8787")
+	viper.SetDefault("api.token_file", "/etc/slurm/aws-burst-api.token")
+
+	// Status defaults
+	viper.SetDefault("status.failure_window_hours", 24)
+
+	// Epilog defaults
+	viper.SetDefault("epilog.queue_dir", "/var/spool/aws-slurm-burst/epilog")
+	viper.SetDefault("epilog.formats", []string{"asba-learning", "asbb-reconciliation"})
+	viper.SetDefault("epilog.output_dir", "/var/spool/asba/learning")
+	viper.SetDefault("epilog.export_program", "/usr/local/bin/aws-slurm-burst-export-performance")
+	viper.SetDefault("epilog.timeout_seconds", 120)
+	viper.SetDefault("epilog.max_attempts", 3)
+
+	// Simulation defaults
+	viper.SetDefault("simulation.ec2_latency_ms", 150)
+	viper.SetDefault("simulation.slurm_latency_ms", 10)
+
+	// Node metrics defaults
+	viper.SetDefault("node_metrics.enabled", false)
+	viper.SetDefault("node_metrics.interval_seconds", 15)
+
+	// Cost model defaults
+	viper.SetDefault("costs.data_transfer.egress_class", TransferInternet)
+	viper.SetDefault("costs.data_transfer.egress_share", 1.0)
+	viper.SetDefault("costs.ceilings.ledger_dir", "/var/spool/aws-slurm-burst/spend")
+
+	// Capacity queue defaults
+	viper.SetDefault("capacity_queue.queue_dir", "/var/spool/aws-slurm-burst/capacity")
+	viper.SetDefault("capacity_queue.max_wait_minutes", 30)
+	viper.SetDefault("capacity_queue.initial_backoff_seconds", 60)
+	viper.SetDefault("capacity_queue.max_backoff_seconds", 600)
+
+	// Batch fallback defaults
+	viper.SetDefault("batch_fallback.platform", BatchPlatformEC2)
+	viper.SetDefault("batch_fallback.shared_paths", []string{"/home", "/shared", "/fsx", "/efs", "/scratch"})
+	viper.SetDefault("batch_fallback.max_script_bytes", 12288)
+	viper.SetDefault("batch_fallback.vcpu_hour_usd", 0.04048)
+	viper.SetDefault("batch_fallback.gb_hour_usd", 0.004445)
+
+	// Checkpoint defaults
+	viper.SetDefault("checkpoint.method", types.CheckpointSignal)
+	viper.SetDefault("checkpoint.signal", "USR1")
+	viper.SetDefault("checkpoint.grace_seconds", 90)
+	viper.SetDefault("checkpoint.poll_seconds", 5)
+
+	// Elastic MPI defaults
+	viper.SetDefault("elastic_mpi.resize_signal", "USR2")
+	viper.SetDefault("elastic_mpi.on_demand_replacements", true)
+	viper.SetDefault("elastic_mpi.max_replacements", 4)
+	viper.SetDefault("elastic_mpi.ready_timeout_minutes", 10)
+
+	// Staging defaults
+	viper.SetDefault("staging.scratch_mount", "/mnt/scratch")
+	viper.SetDefault("staging.volume_type", "gp3")
+	viper.SetDefault("staging.device_name", "/dev/sdz")
+	viper.SetDefault("staging.max_scratch_gb", 4096)
+	viper.SetDefault("staging.max_retention_hours", 168)
+	viper.SetDefault("staging.transfer_timeout_minutes", 60)
+
+	// Job override defaults
+	viper.SetDefault("job_overrides.purchasing_options", PurchasingOptions)
+
+	// Policy sync defaults
+	viper.SetDefault("policies.sync.source", PolicySyncSacctmgr)
+	viper.SetDefault("policies.sync.interval_minutes", 60)
+	viper.SetDefault("policies.sync.state_file", "/var/spool/aws-slurm-burst/policy-identities.json")
+
+	// Export defaults
+	viper.SetDefault("export.anonymize.salt_file", "/etc/slurm/aws-burst-anonymize.salt")
+	viper.SetDefault("export.anonymize.redact", []string{
+		"job_metadata.job_name",
+		"job_metadata.actual_execution.error_details",
+		"execution_context.environment_variables",
+	})
 }
 
 // validate performs comprehensive configuration validation following original plugin patterns
@@ -277,6 +921,336 @@ func validate(config *Config) error {
 	if err := validateLogging(&config.Logging); err != nil {
 		return err
 	}
+	if err := validateLaunchFallback(config); err != nil {
+		return err
+	}
+	if err := validateAPI(&config.API); err != nil {
+		return err
+	}
+	if err := validateAnonymize(&config.Export.Anonymize); err != nil {
+		return err
+	}
+	if err := validateEpilog(&config.Epilog); err != nil {
+		return err
+	}
+	if err := validateNodeMetrics(&config.NodeMetrics); err != nil {
+		return err
+	}
+	if err := validateEcosystem(&config.Ecosystem); err != nil {
+		return err
+	}
+	if err := validateASBA(&config.ASBA); err != nil {
+		return err
+	}
+	if err := validateFailureInjection(&config.FailureInjection); err != nil {
+		return err
+	}
+	if err := validateSimulation(&config.Simulation); err != nil {
+		return err
+	}
+	if err := validateDataTransfer(&config.Costs.DataTransfer); err != nil {
+		return err
+	}
+	if err := validateStorageCosts(config); err != nil {
+		return err
+	}
+	if err := validateCommitments(&config.Costs.Commitments); err != nil {
+		return err
+	}
+	if err := validateCeilings(config); err != nil {
+		return err
+	}
+	if err := validateBurstWindows(&config.BurstWindows); err != nil {
+		return err
+	}
+	if err := validatePurchasingPolicy(&config.PurchasingPolicy); err != nil {
+		return err
+	}
+	if err := validateCapacityQueue(&config.CapacityQueue, config.Slurm.ResumeTimeout); err != nil {
+		return err
+	}
+	if err := validateNetworkCheck(&config.NetworkCheck); err != nil {
+		return err
+	}
+	if err := validateDNS(&config.DNS); err != nil {
+		return err
+	}
+	if err := validateHostsFile(&config.HostsFile); err != nil {
+		return err
+	}
+	if err := validateHealthCheck(&config.HealthCheck); err != nil {
+		return err
+	}
+	if err := validateBootDiagnostics(&config.BootDiagnostics); err != nil {
+		return err
+	}
+	if err := validateHooks(config.Hooks); err != nil {
+		return err
+	}
+	if err := validatePlanModules(config.PlanModules); err != nil {
+		return err
+	}
+	if err := validateBatchFallback(config); err != nil {
+		return err
+	}
+	if err := validateCheckpoint(config); err != nil {
+		return err
+	}
+	if err := validateElasticMPI(config); err != nil {
+		return err
+	}
+	if err := validateStaging(config); err != nil {
+		return err
+	}
+	if err := validateJobOverrides(config); err != nil {
+		return err
+	}
+	if err := validatePolicies(config); err != nil {
+		return err
+	}
+	if err := validateEmergencyStop(&config.EmergencyStop); err != nil {
+		return err
+	}
+	if err := validateMungeKey(&config.MungeKey); err != nil {
+		return err
+	}
+	if err := validateNodeBootstrap(&config.NodeBootstrap, &config.MungeKey); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateCeilings checks that each ceiling is positive and caps exactly one
+// configured partition or one account, once
+func validateCeilings(config *Config) error {
+	scopes := make(map[string]bool)
+	for i, ceiling := range config.Costs.Ceilings.Limits {
+		prefix := fmt.Sprintf("costs.ceilings.limits[%d]", i)
+		named := 0
+		for _, name := range []string{ceiling.Partition, ceiling.Account, ceiling.User} {
+			if name != "" {
+				named++
+			}
+		}
+		if named != 1 {
+			return fmt.Errorf("%s must set exactly one of partition, account and user", prefix)
+		}
+		if ceiling.Partition != "" && config.FindPartition(ceiling.Partition) == nil {
+			return fmt.Errorf("%s.partition %q is not a configured partition", prefix, ceiling.Partition)
+		}
+		scope := "partition:" + ceiling.Partition + "/account:" + ceiling.Account + "/user:" + ceiling.User
+		if scopes[scope] {
+			return fmt.Errorf("%s caps a scope another limit already caps", prefix)
+		}
+		scopes[scope] = true
+		if ceiling.MonthlyUSD <= 0 {
+			return fmt.Errorf("%s.monthly_usd must be positive", prefix)
+		}
+	}
+	return nil
+}
+
+// validateCommitments checks that discounts and coverage are shares and that
+// each family has one reservation
+func validateCommitments(commitments *CommitmentsConfig) error {
+	share := func(value float64) bool { return value >= 0 && value <= 1 }
+
+	if !share(commitments.SavingsPlan.Discount) || !share(commitments.SavingsPlan.Coverage) {
+		return fmt.Errorf("costs.commitments.savings_plan discount and coverage must be between 0 and 1")
+	}
+	families := make(map[string]bool)
+	for i, reserved := range commitments.ReservedInstances {
+		prefix := fmt.Sprintf("costs.commitments.reserved_instances[%d]", i)
+		if reserved.InstanceFamily == "" || families[reserved.InstanceFamily] {
+			return fmt.Errorf("%s.instance_family is required and must be unique", prefix)
+		}
+		families[reserved.InstanceFamily] = true
+		if !share(reserved.Discount) || !share(reserved.Coverage) {
+			return fmt.Errorf("%s discount and coverage must be between 0 and 1", prefix)
+		}
+	}
+	return nil
+}
+
+// validateStorageCosts checks the EBS rates and that each FSx filesystem has
+// a size and is mounted by configured partitions
+func validateStorageCosts(config *Config) error {
+	storage := &config.Costs.Storage
+	for volumeType, rate := range storage.EBSRatesPerGBMonth {
+		if !slices.Contains(VolumeTypes, volumeType) {
+			return fmt.Errorf("costs.storage.ebs_rates_per_gb_month has unknown volume type %q", volumeType)
+		}
+		if rate < 0 {
+			return fmt.Errorf("costs.storage.ebs_rates_per_gb_month.%s must not be negative", volumeType)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, fileSystem := range storage.FileSystems {
+		prefix := fmt.Sprintf("costs.storage.file_systems[%d]", i)
+		if fileSystem.Name == "" || names[fileSystem.Name] {
+			return fmt.Errorf("%s.name is required and must be unique", prefix)
+		}
+		names[fileSystem.Name] = true
+		if fileSystem.StorageGB <= 0 {
+			return fmt.Errorf("%s.storage_gb must be positive", prefix)
+		}
+		if fileSystem.RatePerGBMonth < 0 {
+			return fmt.Errorf("%s.rate_per_gb_month must not be negative", prefix)
+		}
+		if len(fileSystem.Partitions) == 0 {
+			return fmt.Errorf("%s.partitions cannot be empty", prefix)
+		}
+		for _, partition := range fileSystem.Partitions {
+			if config.FindPartition(partition) == nil {
+				return fmt.Errorf("%s.partitions names unknown partition %q", prefix, partition)
+			}
+		}
+	}
+	return nil
+}
+
+// validateDataTransfer checks the egress class, share, estimate and rates
+func validateDataTransfer(dataTransfer *DataTransferConfig) error {
+	if dataTransfer.EgressClass != "" && !slices.Contains(EgressClasses, dataTransfer.EgressClass) {
+		return fmt.Errorf("costs.data_transfer.egress_class must be one of %s, got %q", strings.Join(EgressClasses, ", "), dataTransfer.EgressClass)
+	}
+	if dataTransfer.EgressShare < 0 || dataTransfer.EgressShare > 1 {
+		return fmt.Errorf("costs.data_transfer.egress_share must be between 0 and 1")
+	}
+	if dataTransfer.EstimatedGBPerNodeHour < 0 {
+		return fmt.Errorf("costs.data_transfer.estimated_gb_per_node_hour must not be negative")
+	}
+	for class, rate := range dataTransfer.RatesPerGB {
+		if !slices.Contains(EgressClasses, class) && class != TransferCrossAZ && class != TransferNATGateway {
+			return fmt.Errorf("costs.data_transfer.rates_per_gb has unknown class %q", class)
+		}
+		if rate < 0 {
+			return fmt.Errorf("costs.data_transfer.rates_per_gb.%s must not be negative", class)
+		}
+	}
+	return nil
+}
+
+// validateSimulation checks the simulated latencies and capacity
+func validateSimulation(simulation *SimulationConfig) error {
+	if simulation.EC2LatencyMS < 0 || simulation.SlurmLatencyMS < 0 || simulation.BootSeconds < 0 || simulation.Capacity < 0 {
+		return fmt.Errorf("simulation.ec2_latency_ms, slurm_latency_ms, boot_seconds and capacity must not be negative")
+	}
+	return nil
+}
+
+// validateFailureInjection checks the failure rates and wait delay
+func validateFailureInjection(failureInjection *FailureInjectionConfig) error {
+	if rate := failureInjection.FleetErrorRate; rate < 0 || rate > 1 {
+		return fmt.Errorf("failure_injection.fleet_error_rate must be between 0 and 1, got %g", rate)
+	}
+	if rate := failureInjection.SlurmUpdateErrorRate; rate < 0 || rate > 1 {
+		return fmt.Errorf("failure_injection.slurm_update_error_rate must be between 0 and 1, got %g", rate)
+	}
+	if failureInjection.WaiterDelaySeconds < 0 {
+		return fmt.Errorf("failure_injection.waiter_delay_seconds must not be negative")
+	}
+	return nil
+}
+
+// validateASBA checks the ASBA integration settings
+func validateASBA(asba *ASBAConfig) error {
+	switch asba.IncompatiblePlans {
+	case "", IncompatiblePlansRefuse, IncompatiblePlansStandalone:
+	default:
+		return fmt.Errorf("asba.incompatible_plans must be %q or %q, got %q",
+			IncompatiblePlansRefuse, IncompatiblePlansStandalone, asba.IncompatiblePlans)
+	}
+	if asba.Timeout < 0 || asba.MaxRetries < 0 || asba.BreakerThreshold < 0 || asba.BreakerCooldownSeconds < 0 {
+		return fmt.Errorf("asba.timeout_seconds, max_retries, breaker_threshold and breaker_cooldown_seconds must not be negative")
+	}
+	return nil
+}
+
+// validateEcosystem checks the data exchange directory settings
+func validateEcosystem(ecosystem *EcosystemConfig) error {
+	if ecosystem.DataExchangeDir != "" && !filepath.IsAbs(ecosystem.DataExchangeDir) {
+		return fmt.Errorf("ecosystem.data_exchange_dir must be an absolute path, got %q", ecosystem.DataExchangeDir)
+	}
+	if ecosystem.RetentionDays < 0 {
+		return fmt.Errorf("ecosystem.retention_days must not be negative")
+	}
+	if ecosystem.DetectionTTLMinutes < 0 {
+		return fmt.Errorf("ecosystem.detection_ttl_minutes must not be negative")
+	}
+	return nil
+}
+
+// validateNodeMetrics checks the node collector settings when it is enabled
+func validateNodeMetrics(nodeMetrics *NodeMetricsConfig) error {
+	if !nodeMetrics.Enabled {
+		return nil
+	}
+	if nodeMetrics.IntervalSeconds <= 0 {
+		return fmt.Errorf("node_metrics.interval_seconds must be positive")
+	}
+	if !strings.HasPrefix(nodeMetrics.Destination, "s3://") && !filepath.IsAbs(nodeMetrics.Destination) {
+		return fmt.Errorf("node_metrics.destination must be an s3:// URL or an absolute directory, got %q", nodeMetrics.Destination)
+	}
+	return nil
+}
+
+// validateEpilog checks the per-job export settings
+func validateEpilog(epilog *EpilogConfig) error {
+	if epilog.TimeoutSeconds <= 0 || epilog.MaxAttempts <= 0 {
+		return fmt.Errorf("epilog.timeout_seconds and epilog.max_attempts must be positive")
+	}
+	if epilog.Output != "" && !strings.HasPrefix(epilog.Output, "s3://") {
+		return fmt.Errorf("epilog.output must be an s3:// URL, got %q", epilog.Output)
+	}
+	return nil
+}
+
+// validateAnonymize checks that extra PII patterns compile
+func validateAnonymize(anonymize *AnonymizeConfig) error {
+	for _, pattern := range anonymize.PIIPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("export.anonymize.pii_patterns: invalid pattern %q: %w", pattern, err)
+		}
+	}
+	for _, path := range anonymize.Redact {
+		if path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") {
+			return fmt.Errorf("export.anonymize.redact: invalid field path %q", path)
+		}
+	}
+	return nil
+}
+
+// validateAPI keeps the API on a loopback address; it is not meant to be reachable
+// from other hosts
+func validateAPI(api *APIConfig) error {
+	if api.Listen == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(api.Listen)
+	if err != nil {
+		return fmt.Errorf("api.listen must be host:port: %w", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("api.listen must be a loopback address, got %q", host)
+	}
+	return nil
+}
+
+// validateLaunchFallback checks that a secondary node group names a configured node group
+func validateLaunchFallback(config *Config) error {
+	fallback := config.AWS.LaunchFallback
+	if fallback.SecondaryPartition == "" && fallback.SecondaryNodeGroup == "" {
+		return nil
+	}
+	if fallback.SecondaryPartition == "" || fallback.SecondaryNodeGroup == "" {
+		return fmt.Errorf("aws.launch_fallback.secondary_partition and secondary_node_group must be set together")
+	}
+	if config.FindNodeGroup(fallback.SecondaryPartition, fallback.SecondaryNodeGroup) == nil {
+		return fmt.Errorf("aws.launch_fallback secondary node group %s/%s is not configured",
+			fallback.SecondaryPartition, fallback.SecondaryNodeGroup)
+	}
 	return nil
 }
 
@@ -299,9 +1273,53 @@ func validateSlurm(slurm *SlurmConfig) error {
 	if err := validateSlurmRates(slurm); err != nil {
 		return err
 	}
+	if err := validateFeatureMappings(slurm.FeatureMappings); err != nil {
+		return err
+	}
+	if slurm.Drain.Enabled && slurm.Drain.TimeoutSeconds < 0 {
+		return fmt.Errorf("slurm.drain.timeout_seconds must not be negative")
+	}
+	if slurm.Drain.ShutdownHook != "" && slurm.Drain.HookTimeoutSeconds <= 0 {
+		return fmt.Errorf("slurm.drain.hook_timeout_seconds must be positive when a shutdown hook is set")
+	}
+	if slurm.Drain.HookTransport != HookTransportSSH && slurm.Drain.HookTransport != HookTransportSSM {
+		return fmt.Errorf("slurm.drain.hook_transport must be %s or %s, got %q", HookTransportSSH, HookTransportSSM, slurm.Drain.HookTransport)
+	}
+	if err := validateJobMetadata(&slurm.JobMetadata); err != nil {
+		return err
+	}
+	if err := validateNodeNaming(&slurm.NodeNaming); err != nil {
+		return err
+	}
 	return validatePartitions(slurm.Partitions)
 }
 
+// validateJobMetadata checks the job metadata fields and size limit
+func validateJobMetadata(metadata *JobMetadataConfig) error {
+	if metadata.Field != "AdminComment" && metadata.Field != "Comment" {
+		return fmt.Errorf("slurm.job_metadata.field must be 'AdminComment' or 'Comment'")
+	}
+	if metadata.MaxBytes < 256 {
+		return fmt.Errorf("slurm.job_metadata.max_bytes must be at least 256")
+	}
+
+	valid := make(map[string]bool)
+	for _, field := range JobMetadataFields {
+		valid[field] = true
+	}
+	seen := map[string]bool{metadata.Field: true}
+	for _, field := range metadata.OverflowFields {
+		if !valid[field] {
+			return fmt.Errorf("slurm.job_metadata.overflow_fields: unknown field '%s', must be one of %v", field, JobMetadataFields)
+		}
+		if seen[field] {
+			return fmt.Errorf("slurm.job_metadata.overflow_fields: field '%s' is used twice", field)
+		}
+		seen[field] = true
+	}
+	return nil
+}
+
 // validateSlurmRates validates Slurm rate configurations
 func validateSlurmRates(slurm *SlurmConfig) error {
 	if slurm.ResumeRate <= 0 || slurm.ResumeRate > 1000 {
@@ -335,12 +1353,59 @@ func validatePartitions(partitions []PartitionConfig) error {
 // validateMPI validates MPI configuration
 func validateMPI(mpi *MPIConfig) error {
 	validEFAOptions := []string{"required", "preferred", "optional", "disabled"}
+	validEFA := false
 	for _, option := range validEFAOptions {
 		if mpi.EFADefault == option {
-			return nil
+			validEFA = true
+			break
 		}
 	}
-	return fmt.Errorf("mpi.efa_default must be one of: %s", strings.Join(validEFAOptions, ", "))
+	if !validEFA {
+		return fmt.Errorf("mpi.efa_default must be one of: %s", strings.Join(validEFAOptions, ", "))
+	}
+	if err := validateMPIProfiling(&mpi.Profiling); err != nil {
+		return err
+	}
+	return validateMPIDetection(mpi)
+}
+
+// validateMPIDetection validates detector thresholds, weights and custom detectors
+// validateMPIProfiling checks the mpirun wrapper settings when profiling is enabled
+func validateMPIProfiling(profiling *MPIProfilingConfig) error {
+	if !profiling.Enabled {
+		return nil
+	}
+	if profiling.Launcher == "" {
+		return fmt.Errorf("mpi.profiling.launcher is required when profiling is enabled")
+	}
+	if !filepath.IsAbs(profiling.MPIPLibrary) || !filepath.IsAbs(profiling.OutputDir) {
+		return fmt.Errorf("mpi.profiling.mpip_library and mpi.profiling.output_dir must be absolute paths")
+	}
+	return nil
+}
+
+func validateMPIDetection(mpi *MPIConfig) error {
+	if mpi.DetectionThreshold < 0 || mpi.DetectionThreshold >= 1 {
+		return fmt.Errorf("mpi.detection_threshold must be between 0 and 1")
+	}
+
+	for name, detector := range mpi.Detectors {
+		if detector.Weight < 0 {
+			return fmt.Errorf("mpi.detectors.%s.weight cannot be negative", name)
+		}
+	}
+
+	for i, custom := range mpi.CustomDetectors {
+		topology, err := mpidetect.ParseTopology(custom.Topology)
+		if err != nil {
+			return fmt.Errorf("mpi.custom_detectors[%d]: %w", i, err)
+		}
+		if _, err := mpidetect.NewPatternDetector(custom.Name, custom.ScriptPatterns, custom.Confidence, topology); err != nil {
+			return fmt.Errorf("mpi.custom_detectors[%d]: %w", i, err)
+		}
+	}
+
+	return nil
 }
 
 // validateLogging validates logging configuration
@@ -377,6 +1442,14 @@ func validatePartition(partition PartitionConfig, index int) error {
 		}
 	}
 
+	scaling := partition.Scaling
+	if scaling.MinNodes < 0 || scaling.MaxNodes < 0 || scaling.JobsPerNode < 0 {
+		return fmt.Errorf("partitions[%d].scaling bounds must not be negative", index)
+	}
+	if scaling.MaxNodes > 0 && scaling.MinNodes > scaling.MaxNodes {
+		return fmt.Errorf("partitions[%d].scaling.min_nodes must not exceed max_nodes", index)
+	}
+
 	return nil
 }
 
@@ -409,10 +1482,149 @@ func validateNodeGroup(nodeGroup NodeGroupConfig, partitionIndex, nodeGroupIndex
 		return fmt.Errorf("partitions[%d].node_groups[%d].launch_template_overrides cannot be empty", partitionIndex, nodeGroupIndex)
 	}
 
+	if err := validateNodeGroupArchitecture(nodeGroup, partitionIndex, nodeGroupIndex); err != nil {
+		return err
+	}
+
 	if len(nodeGroup.SubnetIds) == 0 {
 		return fmt.Errorf("partitions[%d].node_groups[%d].subnet_ids cannot be empty", partitionIndex, nodeGroupIndex)
 	}
 
+	if nodeGroup.WarmPool.Size < 0 || nodeGroup.WarmPool.Size > nodeGroup.MaxNodes {
+		return fmt.Errorf("partitions[%d].node_groups[%d].warm_pool.size must be between 0 and max_nodes", partitionIndex, nodeGroupIndex)
+	}
+
+	switch nodeGroup.EffectiveSuspendMode() {
+	case SuspendModeTerminate, SuspendModeStop, SuspendModeHibernate:
+	default:
+		return fmt.Errorf("partitions[%d].node_groups[%d].suspend_mode must be 'terminate', 'stop' or 'hibernate'", partitionIndex, nodeGroupIndex)
+	}
+
+	if err := validateFleetOptions(nodeGroup, partitionIndex, nodeGroupIndex); err != nil {
+		return err
+	}
+
+	if err := validateLaunchTemplateOverrides(nodeGroup, partitionIndex, nodeGroupIndex); err != nil {
+		return err
+	}
+
+	if err := validateVolumes(nodeGroup, partitionIndex, nodeGroupIndex); err != nil {
+		return err
+	}
+
+	if err := validateLinger(nodeGroup, partitionIndex, nodeGroupIndex); err != nil {
+		return err
+	}
+
+	if err := validateJobPacking(nodeGroup, partitionIndex, nodeGroupIndex); err != nil {
+		return err
+	}
+
+	if err := validateContainerBackend(nodeGroup, partitionIndex, nodeGroupIndex); err != nil {
+		return err
+	}
+
+	if err := validateDelegate(nodeGroup, partitionIndex, nodeGroupIndex); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateVolumes checks the EBS volumes a node group's costs are based on
+func validateVolumes(nodeGroup NodeGroupConfig, partitionIndex, nodeGroupIndex int) error {
+	for k, volume := range nodeGroup.Volumes {
+		prefix := fmt.Sprintf("partitions[%d].node_groups[%d].volumes[%d]", partitionIndex, nodeGroupIndex, k)
+		if !slices.Contains(VolumeTypes, volume.VolumeType) {
+			return fmt.Errorf("%s.volume_type must be one of %s, got %q", prefix, strings.Join(VolumeTypes, ", "), volume.VolumeType)
+		}
+		if volume.SizeGB <= 0 || volume.IOPS < 0 || volume.ThroughputMBps < 0 {
+			return fmt.Errorf("%s.size_gb must be positive and iops and throughput_mbps not negative", prefix)
+		}
+	}
+	return nil
+}
+
+// validateLaunchTemplateOverrides checks the settings standalone launches use
+// as written: spot price caps, pinned subnets and weights
+func validateLaunchTemplateOverrides(nodeGroup NodeGroupConfig, partitionIndex, nodeGroupIndex int) error {
+	for k, override := range nodeGroup.LaunchTemplateOverrides {
+		prefix := fmt.Sprintf("partitions[%d].node_groups[%d].launch_template_overrides[%d]", partitionIndex, nodeGroupIndex, k)
+
+		if override.InstanceType == "" {
+			return fmt.Errorf("%s.instance_type is required", prefix)
+		}
+		if override.SpotPrice != "" {
+			if price, err := strconv.ParseFloat(override.SpotPrice, 64); err != nil || price <= 0 {
+				return fmt.Errorf("%s.spot_price must be a positive USD amount, got %q", prefix, override.SpotPrice)
+			}
+		}
+		if override.SubnetID != "" && !slices.Contains(nodeGroup.SubnetIds, override.SubnetID) {
+			return fmt.Errorf("%s.subnet_id %s must be one of the node group's subnet_ids", prefix, override.SubnetID)
+		}
+		if override.WeightedCapacity < 0 {
+			return fmt.Errorf("%s.weighted_capacity must not be negative", prefix)
+		}
+	}
+	return nil
+}
+
+// validateFleetOptions checks a node group's spot and on-demand options,
+// including settings that are misspelled and would otherwise be ignored
+func validateFleetOptions(nodeGroup NodeGroupConfig, partitionIndex, nodeGroupIndex int) error {
+	prefix := fmt.Sprintf("partitions[%d].node_groups[%d]", partitionIndex, nodeGroupIndex)
+	spot := nodeGroup.SpotOptions
+	onDemand := nodeGroup.OnDemandOptions
+
+	for key := range spot.Unknown {
+		return fmt.Errorf("%s.spot_options has unknown setting %q", prefix, key)
+	}
+	for key := range onDemand.Unknown {
+		return fmt.Errorf("%s.on_demand_options has unknown setting %q", prefix, key)
+	}
+
+	checks := []struct {
+		field   string
+		value   string
+		allowed []string
+	}{
+		{"spot_options.allocation_strategy", spot.AllocationStrategy, SpotAllocationStrategies},
+		{"spot_options.instance_interruption_behavior", spot.InstanceInterruptionBehavior, SpotInterruptionBehaviors},
+		{"on_demand_options.allocation_strategy", onDemand.AllocationStrategy, OnDemandAllocationStrategies},
+		{"on_demand_options.capacity_reservation_preference", onDemand.CapacityReservationPreference, CapacityReservationPreferences},
+	}
+	for _, check := range checks {
+		if check.value != "" && !slices.Contains(check.allowed, check.value) {
+			return fmt.Errorf("%s.%s must be one of %s, got %q", prefix, check.field, strings.Join(check.allowed, ", "), check.value)
+		}
+	}
+
+	if spot.MaxPrice < 0 {
+		return fmt.Errorf("%s.spot_options.max_price must not be negative", prefix)
+	}
+	return nil
+}
+
+// validateNodeGroupArchitecture ensures all instance type overrides share one CPU architecture
+func validateNodeGroupArchitecture(nodeGroup NodeGroupConfig, partitionIndex, nodeGroupIndex int) error {
+	arch := nodeGroup.Architecture
+	if arch != "" && arch != types.ArchitectureX86_64 && arch != types.ArchitectureARM64 {
+		return fmt.Errorf("partitions[%d].node_groups[%d].architecture must be '%s' or '%s'",
+			partitionIndex, nodeGroupIndex, types.ArchitectureX86_64, types.ArchitectureARM64)
+	}
+
+	for _, override := range nodeGroup.LaunchTemplateOverrides {
+		overrideArch := types.ArchitectureOf(override.InstanceType)
+		if arch == "" {
+			arch = overrideArch
+			continue
+		}
+		if overrideArch != arch {
+			return fmt.Errorf("partitions[%d].node_groups[%d] mixes architectures: %s is %s, expected %s",
+				partitionIndex, nodeGroupIndex, override.InstanceType, overrideArch, arch)
+		}
+	}
+
 	return nil
 }
 
@@ -423,6 +1635,31 @@ func normalize(config *Config) {
 		config.Slurm.BinPath += "/"
 	}
 
+	// Warm pools default to a 10 minute warm-up and weekly recycling; stopped
+	// instances are terminated after an hour idle
+	for i := range config.Slurm.Partitions {
+		if scaling := &config.Slurm.Partitions[i].Scaling; scaling.JobsPerNode <= 0 {
+			scaling.JobsPerNode = 1
+		}
+		for j := range config.Slurm.Partitions[i].NodeGroups {
+			nodeGroup := &config.Slurm.Partitions[i].NodeGroups[j]
+			if nodeGroup.KeepsStoppedInstances() && nodeGroup.TerminateAfterIdleMinutes <= 0 {
+				nodeGroup.TerminateAfterIdleMinutes = 60
+			}
+
+			warmPool := &nodeGroup.WarmPool
+			if !warmPool.Enabled() {
+				continue
+			}
+			if warmPool.WarmupMinutes <= 0 {
+				warmPool.WarmupMinutes = 10
+			}
+			if warmPool.MaxAgeHours <= 0 {
+				warmPool.MaxAgeHours = 168
+			}
+		}
+	}
+
 	// Ensure log file directory exists
 	if config.Logging.File != "" {
 		dir := filepath.Dir(config.Logging.File)
@@ -433,20 +1670,37 @@ func normalize(config *Config) {
 	}
 }
 
-// GetNodeName generates node names following original plugin pattern: [partition]-[nodegroup]-[id]
+// GetNodeName generates node names following slurm.node_naming, by default
+// the original plugin pattern: [partition]-[nodegroup]-[id]
 func (c *Config) GetNodeName(partitionName, nodeGroupName string, nodeID string) string {
-	if nodeID == "" {
-		return fmt.Sprintf("%s-%s", partitionName, nodeGroupName)
-	}
-	return fmt.Sprintf("%s-%s-%s", partitionName, nodeGroupName, nodeID)
+	return c.Slurm.NodeNaming.NodeName(partitionName, nodeGroupName, nodeID)
 }
 
-// GetNodeRange generates node ranges following original plugin pattern: [partition]-[nodegroup]-[0-N]
+// GetNodeRange generates node ranges following slurm.node_naming, by default
+// the original plugin pattern: [partition]-[nodegroup]-[0-N]
 func (c *Config) GetNodeRange(partitionName, nodeGroupName string, maxNodes int) string {
-	if maxNodes > 1 {
-		return fmt.Sprintf("%s-%s-[0-%d]", partitionName, nodeGroupName, maxNodes-1)
+	return c.Slurm.NodeNaming.NodeRange(partitionName, nodeGroupName, maxNodes)
+}
+
+// EffectiveArchitecture returns the node group's CPU architecture, inferring it from the overrides when unset
+func (n *NodeGroupConfig) EffectiveArchitecture() string {
+	if n.Architecture != "" {
+		return n.Architecture
 	}
-	return fmt.Sprintf("%s-%s-0", partitionName, nodeGroupName)
+	if len(n.LaunchTemplateOverrides) > 0 {
+		return types.ArchitectureOf(n.LaunchTemplateOverrides[0].InstanceType)
+	}
+	return types.ArchitectureX86_64
+}
+
+// FindPartition finds a partition configuration by name
+func (c *Config) FindPartition(partitionName string) *PartitionConfig {
+	for i := range c.Slurm.Partitions {
+		if c.Slurm.Partitions[i].PartitionName == partitionName {
+			return &c.Slurm.Partitions[i]
+		}
+	}
+	return nil
 }
 
 // FindNodeGroup finds a node group configuration by partition and node group name
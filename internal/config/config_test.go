@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -67,6 +68,52 @@ slurm:
             - instance_type: c5.large
           subnet_ids:
             - subnet-123456
+`,
+			expectError: true,
+		},
+		{
+			name: "shutdown hook without timeout",
+			configContent: `
+aws:
+  region: us-east-1
+slurm:
+  drain:
+    shutdown_hook: /usr/local/sbin/flush-scratch
+    hook_timeout_seconds: 0
+  partitions:
+    - partition_name: aws
+      node_groups:
+        - node_group_name: cpu
+          max_nodes: 10
+          region: us-east-1
+          purchasing_option: on-demand
+          launch_template_overrides:
+            - instance_type: c5.large
+          subnet_ids:
+            - subnet-123456
+`,
+			expectError: true,
+		},
+		{
+			name: "unknown shutdown hook transport",
+			configContent: `
+aws:
+  region: us-east-1
+slurm:
+  drain:
+    shutdown_hook: /usr/local/sbin/flush-scratch
+    hook_transport: rsh
+  partitions:
+    - partition_name: aws
+      node_groups:
+        - node_group_name: cpu
+          max_nodes: 10
+          region: us-east-1
+          purchasing_option: on-demand
+          launch_template_overrides:
+            - instance_type: c5.large
+          subnet_ids:
+            - subnet-123456
 `,
 			expectError: true,
 		},
@@ -93,6 +140,8 @@ slurm:
 			require.NoError(t, err)
 			assert.Equal(t, tt.expectedAWS.Region, config.AWS.Region)
 			assert.Equal(t, tt.expectedAWS.Profile, config.AWS.Profile)
+			assert.True(t, config.Slurm.Drain.Enabled)
+			assert.Equal(t, 120, config.Slurm.Drain.TimeoutSeconds)
 		})
 	}
 }
@@ -122,6 +171,66 @@ func TestValidatePartition(t *testing.T) {
 			},
 			expectError: false,
 		},
+		{
+			name: "warm pool larger than max nodes",
+			partition: PartitionConfig{
+				PartitionName: "aws",
+				NodeGroups: []NodeGroupConfig{
+					{
+						NodeGroupName:    "cpu",
+						MaxNodes:         2,
+						Region:           "us-east-1",
+						PurchasingOption: "on-demand",
+						LaunchTemplateOverrides: []LaunchTemplateOverride{
+							{InstanceType: "c5.large"},
+						},
+						SubnetIds: []string{"subnet-123456"},
+						WarmPool:  WarmPoolConfig{Size: 3},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "scaling minimum above maximum",
+			partition: PartitionConfig{
+				PartitionName: "aws",
+				NodeGroups: []NodeGroupConfig{
+					{
+						NodeGroupName:    "cpu",
+						MaxNodes:         8,
+						Region:           "us-east-1",
+						PurchasingOption: "on-demand",
+						LaunchTemplateOverrides: []LaunchTemplateOverride{
+							{InstanceType: "c5.large"},
+						},
+						SubnetIds: []string{"subnet-123456"},
+					},
+				},
+				Scaling: ScalingPolicyConfig{Enabled: true, MinNodes: 4, MaxNodes: 2},
+			},
+			expectError: true,
+		},
+		{
+			name: "unknown suspend mode",
+			partition: PartitionConfig{
+				PartitionName: "aws",
+				NodeGroups: []NodeGroupConfig{
+					{
+						NodeGroupName:    "cpu",
+						MaxNodes:         2,
+						Region:           "us-east-1",
+						PurchasingOption: "on-demand",
+						LaunchTemplateOverrides: []LaunchTemplateOverride{
+							{InstanceType: "c5.large"},
+						},
+						SubnetIds:   []string{"subnet-123456"},
+						SuspendMode: "sleep",
+					},
+				},
+			},
+			expectError: true,
+		},
 		{
 			name: "empty partition name",
 			partition: PartitionConfig{
@@ -247,6 +356,53 @@ func TestGetNodeRange(t *testing.T) {
 	}
 }
 
+func TestNodeNaming(t *testing.T) {
+	naming := NodeNamingConfig{Prefix: "prod", Separator: "_", IDFormat: "%03d", FirstID: 1}
+
+	assert.Equal(t, "prod_aws_cpu_007", naming.NodeName("aws", "cpu", naming.FormatID(7)))
+	assert.Equal(t, "prod_aws_cpu_", naming.NamePrefix("aws", "cpu"))
+	assert.Equal(t, "prod_aws_cpu_[001-020]", naming.NodeRange("aws", "cpu", 20))
+	assert.Equal(t, "prod_aws_cpu_001", naming.NodeRange("aws", "cpu", 1))
+
+	partition, nodeGroup, nodeID, ok := naming.ParseNodeName("prod_aws_cpu_012")
+	require.True(t, ok)
+	assert.Equal(t, []string{"aws", "cpu", "012"}, []string{partition, nodeGroup, nodeID})
+
+	_, _, _, ok = naming.ParseNodeName("aws-cpu-012")
+	assert.False(t, ok)
+	_, _, _, ok = naming.ParseNodeName("dev_aws_cpu_012")
+	assert.False(t, ok)
+
+	// The zero value keeps the original partition-nodegroup-N scheme
+	_, _, nodeID, ok = NodeNamingConfig{}.ParseNodeName("aws-gpu-3")
+	require.True(t, ok)
+	assert.Equal(t, "3", nodeID)
+}
+
+func TestValidateNodeNaming(t *testing.T) {
+	tests := []struct {
+		naming NodeNamingConfig
+		err    string
+	}{
+		{naming: NodeNamingConfig{}},
+		{naming: NodeNamingConfig{Prefix: "prod", Separator: ".", IDFormat: "%04d", FirstID: 1}},
+		{naming: NodeNamingConfig{Separator: "x"}, err: "separator must not contain letters or digits"},
+		{naming: NodeNamingConfig{Prefix: "a,b"}, err: "must not contain commas"},
+		{naming: NodeNamingConfig{IDFormat: "%x"}, err: "id_format"},
+		{naming: NodeNamingConfig{IDFormat: "n%d"}, err: "id_format"},
+		{naming: NodeNamingConfig{FirstID: -1}, err: "first_id"},
+	}
+
+	for _, tt := range tests {
+		err := validateNodeNaming(&tt.naming)
+		if tt.err == "" {
+			assert.NoError(t, err, "%+v", tt.naming)
+		} else {
+			assert.ErrorContains(t, err, tt.err, "%+v", tt.naming)
+		}
+	}
+}
+
 func TestFindNodeGroup(t *testing.T) {
 	config := &Config{
 		Slurm: SlurmConfig{
@@ -428,6 +584,7 @@ logging:
 	assert.Equal(t, "/opt/slurm/bin/", config.Slurm.BinPath) // Should be normalized
 	assert.Equal(t, "CLOUD", config.Slurm.PrivateData)
 	assert.Equal(t, 50, config.Slurm.ResumeRate)
+	assert.Equal(t, "/var/spool/aws-slurm-burst/ratelimit", config.Slurm.RateLimitDir)
 
 	require.Len(t, config.Slurm.Partitions, 1)
 	partition := config.Slurm.Partitions[0]
@@ -457,3 +614,345 @@ logging:
 	assert.Equal(t, "json", config.Logging.Format)
 	assert.Equal(t, 50, config.Logging.MaxSize)
 }
+
+func TestValidateMPIDetection(t *testing.T) {
+	valid := &MPIConfig{
+		EFADefault:         "preferred",
+		DetectionThreshold: 0.6,
+		Detectors:          map[string]DetectorConfig{"application": {Weight: 0.5}},
+		CustomDetectors: []CustomDetectorConfig{
+			{Name: "site_wrapper", ScriptPatterns: []string{"site-mpirun"}, Confidence: 0.9, Topology: "cluster"},
+		},
+	}
+	assert.NoError(t, validateMPI(valid))
+
+	badThreshold := *valid
+	badThreshold.DetectionThreshold = 1.2
+	assert.Error(t, validateMPI(&badThreshold))
+
+	badWeight := *valid
+	badWeight.Detectors = map[string]DetectorConfig{"application": {Weight: -1}}
+	assert.Error(t, validateMPI(&badWeight))
+
+	badPattern := *valid
+	badPattern.CustomDetectors = []CustomDetectorConfig{{Name: "broken", ScriptPatterns: []string{"("}, Confidence: 0.9}}
+	assert.Error(t, validateMPI(&badPattern))
+
+	badTopology := *valid
+	badTopology.CustomDetectors = []CustomDetectorConfig{{Name: "x", ScriptPatterns: []string{"x"}, Confidence: 0.9, Topology: "mesh"}}
+	assert.Error(t, validateMPI(&badTopology))
+}
+
+func TestValidateLaunchFallback(t *testing.T) {
+	config := &Config{
+		Slurm: SlurmConfig{
+			Partitions: []PartitionConfig{
+				{PartitionName: "aws", NodeGroups: []NodeGroupConfig{{NodeGroupName: "cpu"}}},
+				{PartitionName: "awswest", NodeGroups: []NodeGroupConfig{{NodeGroupName: "cpu", Region: "us-west-2"}}},
+			},
+		},
+	}
+	assert.NoError(t, validateLaunchFallback(config))
+
+	config.AWS.LaunchFallback = LaunchFallbackConfig{SecondaryPartition: "awswest", SecondaryNodeGroup: "cpu"}
+	assert.NoError(t, validateLaunchFallback(config))
+
+	config.AWS.LaunchFallback = LaunchFallbackConfig{SecondaryPartition: "awswest"}
+	assert.Error(t, validateLaunchFallback(config))
+
+	config.AWS.LaunchFallback = LaunchFallbackConfig{SecondaryPartition: "awswest", SecondaryNodeGroup: "gpu"}
+	assert.Error(t, validateLaunchFallback(config))
+}
+
+func TestValidateAPI(t *testing.T) {
+	assert.NoError(t, validateAPI(&APIConfig{Listen: "127.0.0.1:8787"}))
+	assert.NoError(t, validateAPI(&APIConfig{Listen: "localhost:8787"}))
+	assert.NoError(t, validateAPI(&APIConfig{Listen: "[::1]:8787"}))
+	assert.Error(t, validateAPI(&APIConfig{Listen: "0.0.0.0:8787"}))
+	assert.Error(t, validateAPI(&APIConfig{Listen: "8787"}))
+}
+
+func TestValidateAnonymize(t *testing.T) {
+	assert.NoError(t, validateAnonymize(&AnonymizeConfig{
+		Redact:      []string{"job_metadata.job_name"},
+		PIIPatterns: []string{`\bu[0-9]{7}\b`},
+	}))
+	assert.Error(t, validateAnonymize(&AnonymizeConfig{PIIPatterns: []string{"("}}))
+	assert.Error(t, validateAnonymize(&AnonymizeConfig{Redact: []string{"job_metadata."}}))
+}
+
+func TestValidateEpilog(t *testing.T) {
+	valid := EpilogConfig{TimeoutSeconds: 120, MaxAttempts: 3}
+	assert.NoError(t, validateEpilog(&valid))
+
+	withS3 := valid
+	withS3.Output = "s3://learning/cluster-a"
+	assert.NoError(t, validateEpilog(&withS3))
+
+	withDir := valid
+	withDir.Output = "/var/spool/asba/learning"
+	assert.Error(t, validateEpilog(&withDir))
+
+	assert.Error(t, validateEpilog(&EpilogConfig{MaxAttempts: 3}))
+}
+
+func TestValidateFailureInjection(t *testing.T) {
+	assert.NoError(t, validateFailureInjection(&FailureInjectionConfig{}))
+	assert.NoError(t, validateFailureInjection(&FailureInjectionConfig{Enabled: true, FleetErrorRate: 1, SlurmUpdateErrorRate: 0.2, WaiterDelaySeconds: 120}))
+	assert.Error(t, validateFailureInjection(&FailureInjectionConfig{FleetErrorRate: 1.5}))
+	assert.Error(t, validateFailureInjection(&FailureInjectionConfig{SlurmUpdateErrorRate: -0.1}))
+	assert.Error(t, validateFailureInjection(&FailureInjectionConfig{WaiterDelaySeconds: -1}))
+}
+
+func TestValidateSimulation(t *testing.T) {
+	assert.NoError(t, validateSimulation(&SimulationConfig{EC2LatencyMS: 150, BootSeconds: 30, Capacity: 8}))
+	assert.Error(t, validateSimulation(&SimulationConfig{Capacity: -1}))
+}
+
+func TestValidateDataTransfer(t *testing.T) {
+	assert.NoError(t, validateDataTransfer(&DataTransferConfig{}))
+	assert.NoError(t, validateDataTransfer(&DataTransferConfig{EgressClass: TransferVPN, EgressShare: 0.5, NATGateway: true,
+		RatesPerGB: map[string]float64{TransferVPN: 0.05, TransferCrossAZ: 0.02, TransferNATGateway: 0.045}}))
+	assert.Error(t, validateDataTransfer(&DataTransferConfig{EgressClass: TransferCrossAZ}), "not an egress class")
+	assert.Error(t, validateDataTransfer(&DataTransferConfig{EgressShare: 1.5}))
+	assert.Error(t, validateDataTransfer(&DataTransferConfig{EstimatedGBPerNodeHour: -1}))
+	assert.Error(t, validateDataTransfer(&DataTransferConfig{RatesPerGB: map[string]float64{"satellite": 1}}))
+	assert.Error(t, validateDataTransfer(&DataTransferConfig{RatesPerGB: map[string]float64{TransferInternet: -0.09}}))
+}
+
+func TestValidateVolumes(t *testing.T) {
+	valid := NodeGroupConfig{Volumes: []VolumeConfig{{DeviceName: "/dev/xvda", VolumeType: "gp3", SizeGB: 100, IOPS: 6000, ThroughputMBps: 250}}}
+	assert.NoError(t, validateVolumes(valid, 0, 0))
+	assert.ErrorContains(t, validateVolumes(NodeGroupConfig{Volumes: []VolumeConfig{{VolumeType: "gp4", SizeGB: 100}}}, 1, 2),
+		"partitions[1].node_groups[2].volumes[0].volume_type")
+	assert.Error(t, validateVolumes(NodeGroupConfig{Volumes: []VolumeConfig{{VolumeType: "gp3"}}}, 0, 0))
+}
+
+func TestValidateStorageCosts(t *testing.T) {
+	cfg := &Config{Slurm: SlurmConfig{Partitions: []PartitionConfig{{PartitionName: "aws"}}}}
+	cfg.Costs.Storage = StorageCostConfig{
+		EBSRatesPerGBMonth: map[string]float64{"gp3": 0.08},
+		FileSystems:        []FileSystemConfig{{Name: "scratch", StorageGB: 1200, Partitions: []string{"aws"}}},
+	}
+	assert.NoError(t, validateStorageCosts(cfg))
+
+	for _, change := range []func(storage *StorageCostConfig){
+		func(storage *StorageCostConfig) { storage.EBSRatesPerGBMonth = map[string]float64{"gp4": 0.08} },
+		func(storage *StorageCostConfig) {
+			storage.FileSystems = append(storage.FileSystems, storage.FileSystems[0])
+		},
+		func(storage *StorageCostConfig) { storage.FileSystems[0].StorageGB = 0 },
+		func(storage *StorageCostConfig) { storage.FileSystems[0].Partitions = []string{"missing"} },
+	} {
+		invalid := *cfg
+		invalid.Costs.Storage = StorageCostConfig{
+			EBSRatesPerGBMonth: cfg.Costs.Storage.EBSRatesPerGBMonth,
+			FileSystems:        []FileSystemConfig{{Name: "scratch", StorageGB: 1200, Partitions: []string{"aws"}}},
+		}
+		change(&invalid.Costs.Storage)
+		assert.Error(t, validateStorageCosts(&invalid))
+	}
+}
+
+func TestValidateCommitments(t *testing.T) {
+	assert.NoError(t, validateCommitments(&CommitmentsConfig{}))
+	assert.NoError(t, validateCommitments(&CommitmentsConfig{
+		SavingsPlan:       SavingsPlanConfig{Discount: 0.28, Coverage: 0.5},
+		ReservedInstances: []ReservedInstanceConfig{{InstanceFamily: "c6i", Discount: 0.4, Coverage: 1}},
+	}))
+	assert.Error(t, validateCommitments(&CommitmentsConfig{SavingsPlan: SavingsPlanConfig{Discount: 28}}))
+	assert.Error(t, validateCommitments(&CommitmentsConfig{ReservedInstances: []ReservedInstanceConfig{{Discount: 0.4}}}))
+	assert.Error(t, validateCommitments(&CommitmentsConfig{ReservedInstances: []ReservedInstanceConfig{
+		{InstanceFamily: "c6i", Discount: 0.4}, {InstanceFamily: "c6i", Discount: 0.3},
+	}}))
+	assert.Error(t, validateCommitments(&CommitmentsConfig{ReservedInstances: []ReservedInstanceConfig{{InstanceFamily: "c6i", Coverage: 1.2}}}))
+}
+
+func TestValidateCeilings(t *testing.T) {
+	cfg := &Config{Slurm: SlurmConfig{Partitions: []PartitionConfig{{PartitionName: "aws"}}}}
+	cfg.Costs.Ceilings.Limits = []CeilingConfig{{Partition: "aws", MonthlyUSD: 5000}, {Account: "chem", MonthlyUSD: 800}, {User: "alice", MonthlyUSD: 300}}
+	assert.NoError(t, validateCeilings(cfg))
+
+	for _, limit := range []CeilingConfig{
+		{MonthlyUSD: 100},
+		{Partition: "aws", Account: "chem", MonthlyUSD: 100},
+		{Partition: "missing", MonthlyUSD: 100},
+		{Account: "chem", MonthlyUSD: 100},
+		{Account: "bio"},
+		{Account: "bio", User: "alice", MonthlyUSD: 100},
+		{User: "alice"},
+	} {
+		invalid := *cfg
+		invalid.Costs.Ceilings.Limits = append(slices.Clone(cfg.Costs.Ceilings.Limits), limit)
+		assert.Error(t, validateCeilings(&invalid), "%+v", limit)
+	}
+}
+
+func TestValidateJobMetadata(t *testing.T) {
+	valid := JobMetadataConfig{Field: "AdminComment", MaxBytes: 4096}
+	assert.NoError(t, validateJobMetadata(&valid))
+	assert.Equal(t, []string{"AdminComment"}, valid.Fields())
+
+	withOverflow := valid
+	withOverflow.OverflowFields = []string{"SystemComment"}
+	assert.NoError(t, validateJobMetadata(&withOverflow))
+	assert.Equal(t, []string{"AdminComment", "SystemComment"}, withOverflow.Fields())
+
+	withDuplicate := valid
+	withDuplicate.OverflowFields = []string{"AdminComment"}
+	assert.Error(t, validateJobMetadata(&withDuplicate))
+
+	withUnknown := valid
+	withUnknown.OverflowFields = []string{"WorkDir"}
+	assert.Error(t, validateJobMetadata(&withUnknown))
+
+	assert.Error(t, validateJobMetadata(&JobMetadataConfig{Field: "SystemComment", MaxBytes: 4096}))
+	assert.Error(t, validateJobMetadata(&JobMetadataConfig{Field: "Comment", MaxBytes: 100}))
+}
+
+func TestValidateNodeMetrics(t *testing.T) {
+	assert.NoError(t, validateNodeMetrics(&NodeMetricsConfig{}))
+
+	valid := NodeMetricsConfig{Enabled: true, IntervalSeconds: 15, Destination: "s3://metrics/cluster-a"}
+	assert.NoError(t, validateNodeMetrics(&valid))
+
+	shared := valid
+	shared.Destination = "/shared/asbx/node-metrics"
+	assert.NoError(t, validateNodeMetrics(&shared))
+
+	relative := valid
+	relative.Destination = "node-metrics"
+	assert.Error(t, validateNodeMetrics(&relative))
+
+	noInterval := valid
+	noInterval.IntervalSeconds = 0
+	assert.Error(t, validateNodeMetrics(&noInterval))
+}
+
+func TestValidateMPIProfiling(t *testing.T) {
+	assert.NoError(t, validateMPIProfiling(&MPIProfilingConfig{}))
+
+	valid := MPIProfilingConfig{Enabled: true, Launcher: "mpirun", MPIPLibrary: "/usr/lib64/libmpiP.so", OutputDir: "/shared/mpi-profiles"}
+	assert.NoError(t, validateMPIProfiling(&valid))
+
+	relative := valid
+	relative.OutputDir = "mpi-profiles"
+	assert.Error(t, validateMPIProfiling(&relative))
+
+	noLauncher := valid
+	noLauncher.Launcher = ""
+	assert.Error(t, validateMPIProfiling(&noLauncher))
+}
+
+func TestValidateEcosystem(t *testing.T) {
+	assert.NoError(t, validateEcosystem(&EcosystemConfig{}))
+	assert.NoError(t, validateEcosystem(&EcosystemConfig{DataExchangeDir: "/var/spool/asbx/ecosystem", RetentionDays: 30}))
+	assert.Error(t, validateEcosystem(&EcosystemConfig{DataExchangeDir: "ecosystem"}))
+	assert.Error(t, validateEcosystem(&EcosystemConfig{DataExchangeDir: "/var/spool/asbx/ecosystem", RetentionDays: -1}))
+	assert.Error(t, validateEcosystem(&EcosystemConfig{DataExchangeDir: "/var/spool/asbx/ecosystem", DetectionTTLMinutes: -1}))
+}
+
+func TestValidateASBA(t *testing.T) {
+	assert.NoError(t, validateASBA(&ASBAConfig{}))
+	assert.NoError(t, validateASBA(&ASBAConfig{IncompatiblePlans: IncompatiblePlansStandalone}))
+	assert.Error(t, validateASBA(&ASBAConfig{IncompatiblePlans: "ignore"}))
+	assert.Error(t, validateASBA(&ASBAConfig{MaxRetries: -1}))
+}
+
+func TestNormalizeNodeGroupDefaults(t *testing.T) {
+	config := Config{
+		Slurm: SlurmConfig{
+			Partitions: []PartitionConfig{
+				{PartitionName: "aws", NodeGroups: []NodeGroupConfig{
+					{NodeGroupName: "cpu", WarmPool: WarmPoolConfig{Size: 2}},
+					{NodeGroupName: "gpu", WarmPool: WarmPoolConfig{Size: 1, WarmupMinutes: 20, MaxAgeHours: 24}},
+					{NodeGroupName: "mem"},
+					{NodeGroupName: "hib", SuspendMode: SuspendModeHibernate},
+				}},
+			},
+		},
+	}
+	normalize(&config)
+
+	nodeGroups := config.Slurm.Partitions[0].NodeGroups
+	assert.Equal(t, 10, nodeGroups[0].WarmPool.WarmupMinutes)
+	assert.Equal(t, 168, nodeGroups[0].WarmPool.MaxAgeHours)
+	assert.Equal(t, 20, nodeGroups[1].WarmPool.WarmupMinutes)
+	assert.Equal(t, 24, nodeGroups[1].WarmPool.MaxAgeHours)
+	assert.Zero(t, nodeGroups[2].WarmPool.WarmupMinutes)
+	assert.Zero(t, nodeGroups[2].TerminateAfterIdleMinutes)
+	assert.Equal(t, 60, nodeGroups[3].TerminateAfterIdleMinutes)
+}
+
+func TestValidateFleetOptions(t *testing.T) {
+	assert.NoError(t, validateFleetOptions(NodeGroupConfig{}, 0, 0))
+	assert.NoError(t, validateFleetOptions(NodeGroupConfig{
+		SpotOptions: SpotOptionsConfig{
+			AllocationStrategy:           "price-capacity-optimized",
+			MaxPrice:                     0.25,
+			InstanceInterruptionBehavior: "terminate",
+		},
+		OnDemandOptions: OnDemandOptionsConfig{
+			AllocationStrategy:            "prioritized",
+			CapacityReservationPreference: "use-capacity-reservations-first",
+		},
+	}, 0, 0))
+
+	assert.ErrorContains(t, validateFleetOptions(NodeGroupConfig{
+		SpotOptions: SpotOptionsConfig{AllocationStrategy: "cheapest"},
+	}, 1, 2), "partitions[1].node_groups[2].spot_options.allocation_strategy must be one of")
+	assert.Error(t, validateFleetOptions(NodeGroupConfig{SpotOptions: SpotOptionsConfig{MaxPrice: -1}}, 0, 0))
+	assert.Error(t, validateFleetOptions(NodeGroupConfig{
+		OnDemandOptions: OnDemandOptionsConfig{CapacityReservationPreference: "open"},
+	}, 0, 0))
+}
+
+func TestLoadRejectsMisspelledFleetOptions(t *testing.T) {
+	configContent := `
+aws:
+  region: us-east-1
+slurm:
+  partitions:
+    - partition_name: aws
+      node_groups:
+        - node_group_name: cpu
+          max_nodes: 10
+          region: us-east-1
+          purchasing_option: spot
+          spot_options:
+            allocation_strategy: capacity-optimized
+            max_prise: 0.25
+          launch_template_overrides:
+            - instance_type: c5.large
+          subnet_ids:
+            - subnet-123456
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	_, err := Load(configPath)
+	assert.ErrorContains(t, err, `spot_options has unknown setting "max_prise"`)
+}
+
+func TestValidateLaunchTemplateOverrides(t *testing.T) {
+	nodeGroup := NodeGroupConfig{
+		SubnetIds: []string{"subnet-a", "subnet-b"},
+		LaunchTemplateOverrides: []LaunchTemplateOverride{
+			{InstanceType: "c6i.xlarge", SpotPrice: "0.08", SubnetID: "subnet-b", WeightedCapacity: 1},
+			{InstanceType: "c6i.2xlarge"},
+		},
+	}
+	assert.NoError(t, validateLaunchTemplateOverrides(nodeGroup, 0, 0))
+
+	invalid := func(override LaunchTemplateOverride) NodeGroupConfig {
+		broken := nodeGroup
+		broken.LaunchTemplateOverrides = []LaunchTemplateOverride{override}
+		return broken
+	}
+	assert.ErrorContains(t, validateLaunchTemplateOverrides(invalid(LaunchTemplateOverride{InstanceType: "c6i.xlarge", SubnetID: "subnet-z"}), 0, 1),
+		"partitions[0].node_groups[1].launch_template_overrides[0].subnet_id subnet-z must be one of the node group's subnet_ids")
+	assert.Error(t, validateLaunchTemplateOverrides(invalid(LaunchTemplateOverride{InstanceType: "c6i.xlarge", SpotPrice: "cheap"}), 0, 0))
+	assert.Error(t, validateLaunchTemplateOverrides(invalid(LaunchTemplateOverride{InstanceType: "c6i.xlarge", SpotPrice: "0"}), 0, 0))
+	assert.Error(t, validateLaunchTemplateOverrides(invalid(LaunchTemplateOverride{InstanceType: "c6i.xlarge", WeightedCapacity: -1}), 0, 0))
+	assert.Error(t, validateLaunchTemplateOverrides(invalid(LaunchTemplateOverride{SpotPrice: "0.08"}), 0, 0))
+}
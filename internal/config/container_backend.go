@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Container backend types
+const (
+	ContainerBackendEKSNodeGroup = "eks_node_group" // Scale an EKS managed node group
+	ContainerBackendKarpenter    = "karpenter"      // Create Karpenter NodeClaims
+)
+
+// Which jobs a container backend launches nodes for
+const (
+	ContainerJobsContainerized = "containerized" // Jobs run with --container or --container-image, or requesting the feature
+	ContainerJobsAll           = "all"           // Every launch, including ASBA plans that name no job
+)
+
+// ContainerBackendConfig provisions a node group's nodes for containerized
+// jobs through an EKS cluster's capacity instead of EC2 Fleet, for sites that
+// run Slurm and Kubernetes on the same nodes. The execution plan still picks
+// the instance types and purchase option; the cluster launches the instances
+// and each is tagged with its node name like a fleet instance. Nodes start
+// slurmd from the cluster's launch template or a DaemonSet, reading their
+// name from the SlurmNode tag.
+type ContainerBackendConfig struct {
+	Type          string `mapstructure:"type"`            // "eks_node_group" or "karpenter"; empty launches with EC2 Fleet
+	ClusterName   string `mapstructure:"cluster_name"`    // EKS cluster, in the node group's region
+	NodeGroupName string `mapstructure:"node_group_name"` // eks_node_group: managed node group scaled up for each launch
+	NodePool      string `mapstructure:"node_pool"`       // karpenter: NodePool that owns the NodeClaims
+	NodeClass     string `mapstructure:"node_class"`      // karpenter: EC2NodeClass the NodeClaims launch with; default "default"
+	Jobs          string `mapstructure:"jobs"`            // "containerized" (default) or "all"
+	Feature       string `mapstructure:"feature"`         // Slurm feature marking containerized jobs; default "container"
+
+	ReadyTimeoutSeconds int `mapstructure:"ready_timeout_seconds"` // How long instances get to appear; default 600
+}
+
+// Enabled reports whether the node group can launch through a cluster
+func (b ContainerBackendConfig) Enabled() bool {
+	return b.Type != ""
+}
+
+// Serves reports whether the backend launches nodes for a job: any job when
+// jobs is "all", else jobs flagged as containerized by their container
+// options or by requesting the backend's feature
+func (b ContainerBackendConfig) Serves(containerized bool, features []string) bool {
+	if !b.Enabled() {
+		return false
+	}
+	if b.Jobs == ContainerJobsAll {
+		return true
+	}
+	feature := b.Feature
+	if feature == "" {
+		feature = "container"
+	}
+	return containerized || slices.Contains(features, feature)
+}
+
+// NodeClassName returns the EC2NodeClass Karpenter NodeClaims reference
+func (b ContainerBackendConfig) NodeClassName() string {
+	if b.NodeClass == "" {
+		return "default"
+	}
+	return b.NodeClass
+}
+
+// ReadyTimeout returns how long a launch waits for the cluster's instances
+func (b ContainerBackendConfig) ReadyTimeout() time.Duration {
+	if b.ReadyTimeoutSeconds <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(b.ReadyTimeoutSeconds) * time.Second
+}
+
+// validateContainerBackend checks a node group's container backend
+func validateContainerBackend(nodeGroup NodeGroupConfig, partitionIndex, nodeGroupIndex int) error {
+	prefix := fmt.Sprintf("partitions[%d].node_groups[%d].container_backend", partitionIndex, nodeGroupIndex)
+	backend := nodeGroup.ContainerBackend
+	if !backend.Enabled() {
+		return nil
+	}
+
+	switch backend.Type {
+	case ContainerBackendEKSNodeGroup:
+		if backend.NodeGroupName == "" {
+			return fmt.Errorf("%s.node_group_name is required for type '%s'", prefix, backend.Type)
+		}
+	case ContainerBackendKarpenter:
+		if backend.NodePool == "" {
+			return fmt.Errorf("%s.node_pool is required for type '%s'", prefix, backend.Type)
+		}
+	default:
+		return fmt.Errorf("%s.type must be '%s' or '%s'", prefix, ContainerBackendEKSNodeGroup, ContainerBackendKarpenter)
+	}
+	if backend.ClusterName == "" {
+		return fmt.Errorf("%s.cluster_name is required", prefix)
+	}
+	if !slices.Contains([]string{"", ContainerJobsContainerized, ContainerJobsAll}, backend.Jobs) {
+		return fmt.Errorf("%s.jobs must be '%s' or '%s'", prefix, ContainerJobsContainerized, ContainerJobsAll)
+	}
+	if backend.ReadyTimeoutSeconds < 0 {
+		return fmt.Errorf("%s.ready_timeout_seconds cannot be negative", prefix)
+	}
+	// The cluster owns its instances, so suspend cannot stop them or leave them lingering
+	if nodeGroup.KeepsStoppedInstances() || nodeGroup.Linger.Enabled() {
+		return fmt.Errorf("%s requires suspend_mode 'terminate' and no linger", prefix)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateContainerBackend(t *testing.T) {
+	assert.NoError(t, validateContainerBackend(NodeGroupConfig{}, 0, 0), "disabled")
+
+	nodeGroup := NodeGroupConfig{ContainerBackend: ContainerBackendConfig{Type: ContainerBackendEKSNodeGroup, ClusterName: "hpc", NodeGroupName: "slurm"}}
+	assert.NoError(t, validateContainerBackend(nodeGroup, 0, 0))
+	karpenter := NodeGroupConfig{ContainerBackend: ContainerBackendConfig{Type: ContainerBackendKarpenter, ClusterName: "hpc", NodePool: "slurm", Jobs: ContainerJobsAll}}
+	assert.NoError(t, validateContainerBackend(karpenter, 0, 0))
+	assert.Equal(t, "default", karpenter.ContainerBackend.NodeClassName())
+	assert.Equal(t, 10*time.Minute, karpenter.ContainerBackend.ReadyTimeout())
+
+	for _, invalid := range []NodeGroupConfig{
+		{ContainerBackend: ContainerBackendConfig{Type: "ecs", ClusterName: "hpc"}},
+		{ContainerBackend: ContainerBackendConfig{Type: ContainerBackendEKSNodeGroup, ClusterName: "hpc"}},
+		{ContainerBackend: ContainerBackendConfig{Type: ContainerBackendKarpenter, ClusterName: "hpc"}},
+		{ContainerBackend: ContainerBackendConfig{Type: ContainerBackendKarpenter, NodePool: "slurm"}},
+		{ContainerBackend: ContainerBackendConfig{Type: ContainerBackendKarpenter, ClusterName: "hpc", NodePool: "slurm", Jobs: "some"}},
+		{ContainerBackend: ContainerBackendConfig{Type: ContainerBackendKarpenter, ClusterName: "hpc", NodePool: "slurm", ReadyTimeoutSeconds: -1}},
+		{ContainerBackend: nodeGroup.ContainerBackend, SuspendMode: SuspendModeStop},
+		{ContainerBackend: nodeGroup.ContainerBackend, Linger: LingerConfig{Seconds: 60}},
+	} {
+		assert.Error(t, validateContainerBackend(invalid, 0, 0), "%+v", invalid.ContainerBackend)
+	}
+}
+
+func TestContainerBackendServes(t *testing.T) {
+	assert.False(t, ContainerBackendConfig{}.Serves(true, nil), "disabled")
+
+	backend := ContainerBackendConfig{Type: ContainerBackendKarpenter}
+	assert.True(t, backend.Serves(true, nil))
+	assert.True(t, backend.Serves(false, []string{"container"}))
+	assert.False(t, backend.Serves(false, []string{"avx512"}))
+
+	backend.Feature = "k8s"
+	assert.True(t, backend.Serves(false, []string{"k8s"}))
+
+	backend.Jobs = ContainerJobsAll
+	assert.True(t, backend.Serves(false, nil))
+}
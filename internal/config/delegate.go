@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Provisioning delegate types
+const (
+	DelegatePCS             = "pcs"             // Scale an AWS PCS compute node group
+	DelegateParallelCluster = "parallelcluster" // Scale an AWS ParallelCluster compute resource
+)
+
+// DelegateConfig hands a node group's provisioning to an existing AWS PCS or
+// AWS ParallelCluster queue, for sites already invested in one. Resume still
+// plans each launch, with ASBA when configured, and records its execution for
+// the feedback loop; the delegate launches the instances from its own
+// configuration, raising its static capacity by the nodes requested, and each
+// is tagged with its node name like a fleet instance. Suspend lowers the
+// capacity again before the instances are terminated.
+type DelegateConfig struct {
+	Type        string `mapstructure:"type"`         // "pcs" or "parallelcluster"; empty launches with EC2 Fleet
+	ClusterName string `mapstructure:"cluster_name"` // PCS cluster name or ID, or ParallelCluster cluster name
+
+	ComputeNodeGroup string `mapstructure:"compute_node_group"` // pcs: compute node group name or ID
+
+	APIEndpoint     string `mapstructure:"api_endpoint"`     // parallelcluster: invoke URL of the deployed ParallelCluster API
+	Queue           string `mapstructure:"queue"`            // parallelcluster: Slurm queue
+	ComputeResource string `mapstructure:"compute_resource"` // parallelcluster: compute resource of the queue
+
+	ReadyTimeoutSeconds int `mapstructure:"ready_timeout_seconds"` // How long instances get to appear; default 900, 1800 for parallelcluster
+}
+
+// Enabled reports whether the node group delegates provisioning
+func (d DelegateConfig) Enabled() bool {
+	return d.Type != ""
+}
+
+// Resource names the delegate's capacity: "<cluster>/<compute node group>"
+// or "<cluster>/<queue>/<compute resource>"
+func (d DelegateConfig) Resource() string {
+	if d.Type == DelegateParallelCluster {
+		return d.ClusterName + "/" + d.Queue + "/" + d.ComputeResource
+	}
+	return d.ClusterName + "/" + d.ComputeNodeGroup
+}
+
+// ReadyTimeout returns how long a launch waits for the delegate's instances.
+// ParallelCluster applies capacity changes as cluster updates, which are slower.
+func (d DelegateConfig) ReadyTimeout() time.Duration {
+	switch {
+	case d.ReadyTimeoutSeconds > 0:
+		return time.Duration(d.ReadyTimeoutSeconds) * time.Second
+	case d.Type == DelegateParallelCluster:
+		return 30 * time.Minute
+	default:
+		return 15 * time.Minute
+	}
+}
+
+// validateDelegate checks a node group's provisioning delegate
+func validateDelegate(nodeGroup NodeGroupConfig, partitionIndex, nodeGroupIndex int) error {
+	prefix := fmt.Sprintf("partitions[%d].node_groups[%d].delegate", partitionIndex, nodeGroupIndex)
+	delegate := nodeGroup.Delegate
+	if !delegate.Enabled() {
+		return nil
+	}
+
+	switch delegate.Type {
+	case DelegatePCS:
+		if delegate.ComputeNodeGroup == "" {
+			return fmt.Errorf("%s.compute_node_group is required for type '%s'", prefix, delegate.Type)
+		}
+	case DelegateParallelCluster:
+		if delegate.Queue == "" || delegate.ComputeResource == "" {
+			return fmt.Errorf("%s.queue and compute_resource are required for type '%s'", prefix, delegate.Type)
+		}
+		if endpoint, err := url.Parse(delegate.APIEndpoint); err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+			return fmt.Errorf("%s.api_endpoint must be the https invoke URL of the ParallelCluster API", prefix)
+		}
+	default:
+		return fmt.Errorf("%s.type must be '%s' or '%s'", prefix, DelegatePCS, DelegateParallelCluster)
+	}
+	if delegate.ClusterName == "" {
+		return fmt.Errorf("%s.cluster_name is required", prefix)
+	}
+	if delegate.ReadyTimeoutSeconds < 0 {
+		return fmt.Errorf("%s.ready_timeout_seconds cannot be negative", prefix)
+	}
+	// The delegate owns its instances, so nothing else may launch them or keep them
+	if nodeGroup.ContainerBackend.Enabled() || nodeGroup.WarmPool.Size > 0 {
+		return fmt.Errorf("%s cannot be combined with container_backend or warm_pool", prefix)
+	}
+	if nodeGroup.KeepsStoppedInstances() || nodeGroup.Linger.Enabled() {
+		return fmt.Errorf("%s requires suspend_mode 'terminate' and no linger", prefix)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDelegate(t *testing.T) {
+	assert.NoError(t, validateDelegate(NodeGroupConfig{}, 0, 0), "disabled")
+
+	pcs := NodeGroupConfig{Delegate: DelegateConfig{Type: DelegatePCS, ClusterName: "hpc", ComputeNodeGroup: "compute"}}
+	assert.NoError(t, validateDelegate(pcs, 0, 0))
+	assert.Equal(t, "hpc/compute", pcs.Delegate.Resource())
+	assert.Equal(t, 15*time.Minute, pcs.Delegate.ReadyTimeout())
+
+	parallelCluster := NodeGroupConfig{Delegate: DelegateConfig{
+		Type: DelegateParallelCluster, ClusterName: "hpc", Queue: "compute", ComputeResource: "c6i",
+		APIEndpoint: "https://abc123.execute-api.us-east-1.amazonaws.com/prod",
+	}}
+	assert.NoError(t, validateDelegate(parallelCluster, 0, 0))
+	assert.Equal(t, "hpc/compute/c6i", parallelCluster.Delegate.Resource())
+	assert.Equal(t, 30*time.Minute, parallelCluster.Delegate.ReadyTimeout())
+
+	for _, invalid := range []NodeGroupConfig{
+		{Delegate: DelegateConfig{Type: "slurm", ClusterName: "hpc"}},
+		{Delegate: DelegateConfig{Type: DelegatePCS, ClusterName: "hpc"}},
+		{Delegate: DelegateConfig{Type: DelegatePCS, ComputeNodeGroup: "compute"}},
+		{Delegate: DelegateConfig{Type: DelegateParallelCluster, ClusterName: "hpc", Queue: "compute", APIEndpoint: "https://api.example.com"}},
+		{Delegate: DelegateConfig{Type: DelegateParallelCluster, ClusterName: "hpc", Queue: "compute", ComputeResource: "c6i", APIEndpoint: "http://api.example.com"}},
+		{Delegate: DelegateConfig{Type: DelegatePCS, ClusterName: "hpc", ComputeNodeGroup: "compute", ReadyTimeoutSeconds: -1}},
+		{Delegate: pcs.Delegate, ContainerBackend: ContainerBackendConfig{Type: ContainerBackendKarpenter}},
+		{Delegate: pcs.Delegate, WarmPool: WarmPoolConfig{Size: 2}},
+		{Delegate: pcs.Delegate, SuspendMode: SuspendModeHibernate},
+		{Delegate: pcs.Delegate, Linger: LingerConfig{Seconds: 60}},
+	} {
+		assert.Error(t, validateDelegate(invalid, 0, 0), "%+v", invalid.Delegate)
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DNSConfig registers burst nodes in a Route 53 private hosted zone. Resume
+// points node-name.domain at each node's private IP and suspend removes the
+// record, so clusters resolving NodeHostname through DNS need no /etc/hosts
+// entries on the controller.
+type DNSConfig struct {
+	HostedZoneID string `mapstructure:"hosted_zone_id"` // Private hosted zone; empty leaves DNS alone
+	Domain       string `mapstructure:"domain"`         // The zone's domain or a subdomain of it, e.g. burst.cluster.internal
+	TTL          int64  `mapstructure:"ttl"`            // Seconds; short so a replaced node's address is picked up
+}
+
+// DefaultDNSTTL is the record TTL in seconds
+const DefaultDNSTTL = 60
+
+// Enabled reports whether burst nodes are registered in Route 53
+func (d *DNSConfig) Enabled() bool {
+	return d.HostedZoneID != ""
+}
+
+// RecordName is the fully qualified record name of a node, with the trailing
+// dot Route 53 returns
+func (d *DNSConfig) RecordName(nodeName string) string {
+	return strings.ToLower(nodeName) + "." + d.Domain + "."
+}
+
+var (
+	hostedZoneIDPattern = regexp.MustCompile(`^Z[A-Z0-9]{1,31}$`)
+	domainPattern       = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+// validateDNS checks the zone ID and domain, dropping the /hostedzone/ prefix
+// the console shows and the domain's trailing dot
+func validateDNS(dns *DNSConfig) error {
+	dns.HostedZoneID = strings.TrimPrefix(dns.HostedZoneID, "/hostedzone/")
+	dns.Domain = strings.ToLower(strings.TrimSuffix(dns.Domain, "."))
+	if !dns.Enabled() {
+		if dns.Domain != "" {
+			return fmt.Errorf("dns.domain is set without dns.hosted_zone_id")
+		}
+		return nil
+	}
+	if !hostedZoneIDPattern.MatchString(dns.HostedZoneID) {
+		return fmt.Errorf("dns.hosted_zone_id must be a Route 53 hosted zone ID such as Z0123456789ABCDEFGHIJ, got %q", dns.HostedZoneID)
+	}
+	if !domainPattern.MatchString(dns.Domain) {
+		return fmt.Errorf("dns.domain must be the domain node records are created under, got %q", dns.Domain)
+	}
+	if dns.TTL == 0 {
+		dns.TTL = DefaultDNSTTL
+	}
+	if dns.TTL < 0 || dns.TTL > 86400 {
+		return fmt.Errorf("dns.ttl must be between 1 and 86400 seconds, got %d", dns.TTL)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDNS(t *testing.T) {
+	assert.NoError(t, validateDNS(&DNSConfig{}), "disabled")
+	assert.Error(t, validateDNS(&DNSConfig{Domain: "burst.cluster.internal"}), "domain without zone")
+
+	dns := DNSConfig{HostedZoneID: "/hostedzone/Z0123456789ABC", Domain: "Burst.Cluster.Internal."}
+	assert.NoError(t, validateDNS(&dns))
+	assert.Equal(t, DNSConfig{HostedZoneID: "Z0123456789ABC", Domain: "burst.cluster.internal", TTL: DefaultDNSTTL}, dns)
+	assert.Equal(t, "aws-cpu-001.burst.cluster.internal.", dns.RecordName("aws-cpu-001"))
+
+	for _, invalid := range []DNSConfig{
+		{HostedZoneID: "zone-1", Domain: "cluster.internal"},
+		{HostedZoneID: "Z0123456789ABC"},
+		{HostedZoneID: "Z0123456789ABC", Domain: "cluster..internal"},
+		{HostedZoneID: "Z0123456789ABC", Domain: "*.cluster.internal"},
+		{HostedZoneID: "Z0123456789ABC", Domain: "cluster.internal", TTL: -1},
+	} {
+		assert.Error(t, validateDNS(&invalid), "%+v", invalid)
+	}
+}
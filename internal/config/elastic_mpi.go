@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// ElasticMPIConfig is an experimental mode for MPI applications that can lose
+// and regain ranks, such as Charm++ shrink/expand or PMIx-based codes. When
+// EC2 gives notice that a spot instance of a running elastic job will be
+// reclaimed, the state manager launches a replacement instance for the same
+// Slurm node, points Slurm at it and, once it registers, sends ResizeSignal to
+// the job's batch script so its resize hook can expand onto the node again.
+// A job is elastic when its execution plan sets mpi_configuration.elastic or
+// it runs in one of Partitions. Jobs must be submitted with --no-kill, and
+// the job's batch host cannot be replaced.
+type ElasticMPIConfig struct {
+	Enabled              bool     `mapstructure:"enabled"`
+	Partitions           []string `mapstructure:"partitions"`             // Partitions whose burst jobs are all elastic
+	ResizeSignal         string   `mapstructure:"resize_signal"`          // Sent to the batch script once a replacement registers
+	OnDemandReplacements bool     `mapstructure:"on_demand_replacements"` // Replace on on-demand capacity, which is not interrupted again
+	MaxReplacements      int      `mapstructure:"max_replacements"`       // Per job; later interruptions shrink the job for good
+	ReadyTimeoutMinutes  int      `mapstructure:"ready_timeout_minutes"`  // How long a replacement may take to register
+}
+
+// ListsPartition reports whether every burst job in partition is elastic
+func (e *ElasticMPIConfig) ListsPartition(partition string) bool {
+	return slices.Contains(e.Partitions, partition)
+}
+
+// validateElasticMPI checks the signal and limits, that listed partitions are
+// configured and that there is a data exchange directory to track jobs in
+func validateElasticMPI(config *Config) error {
+	elastic := &config.ElasticMPI
+	if !elastic.Enabled {
+		return nil
+	}
+	if config.Ecosystem.DataExchangeDir == "" {
+		return fmt.Errorf("elastic_mpi tracks its jobs under ecosystem.data_exchange_dir, which is not set")
+	}
+	if elastic.ResizeSignal == "" || !types.ValidSignalName(elastic.ResizeSignal) {
+		return fmt.Errorf("elastic_mpi.resize_signal must be a signal name such as USR2, got %q", elastic.ResizeSignal)
+	}
+	if elastic.MaxReplacements <= 0 {
+		return fmt.Errorf("elastic_mpi.max_replacements must be positive")
+	}
+	if elastic.ReadyTimeoutMinutes <= 0 {
+		return fmt.Errorf("elastic_mpi.ready_timeout_minutes must be positive")
+	}
+	for _, partition := range elastic.Partitions {
+		if config.FindPartition(partition) == nil {
+			return fmt.Errorf("elastic_mpi.partitions: %q is not a configured partition", partition)
+		}
+	}
+	return nil
+}
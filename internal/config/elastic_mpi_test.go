@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateElasticMPI(t *testing.T) {
+	tests := []struct {
+		name            string
+		elastic         ElasticMPIConfig
+		dataExchangeDir string
+		expectError     string
+	}{
+		{
+			name:    "disabled",
+			elastic: ElasticMPIConfig{},
+		},
+		{
+			name:            "valid elastic mpi",
+			elastic:         ElasticMPIConfig{Enabled: true, Partitions: []string{"aws"}, ResizeSignal: "USR2", MaxReplacements: 4, ReadyTimeoutMinutes: 10},
+			dataExchangeDir: "/var/spool/asbx/ecosystem",
+		},
+		{
+			name:        "no data exchange dir",
+			elastic:     ElasticMPIConfig{Enabled: true, ResizeSignal: "USR2", MaxReplacements: 4, ReadyTimeoutMinutes: 10},
+			expectError: "elastic_mpi tracks its jobs under ecosystem.data_exchange_dir, which is not set",
+		},
+		{
+			name:            "no signal",
+			elastic:         ElasticMPIConfig{Enabled: true, MaxReplacements: 4, ReadyTimeoutMinutes: 10},
+			dataExchangeDir: "/var/spool/asbx/ecosystem",
+			expectError:     `elastic_mpi.resize_signal must be a signal name such as USR2, got ""`,
+		},
+		{
+			name:            "signal with prefix",
+			elastic:         ElasticMPIConfig{Enabled: true, ResizeSignal: "SIGUSR2", MaxReplacements: 4, ReadyTimeoutMinutes: 10},
+			dataExchangeDir: "/var/spool/asbx/ecosystem",
+			expectError:     `elastic_mpi.resize_signal must be a signal name such as USR2, got "SIGUSR2"`,
+		},
+		{
+			name:            "no replacements",
+			elastic:         ElasticMPIConfig{Enabled: true, ResizeSignal: "USR2", ReadyTimeoutMinutes: 10},
+			dataExchangeDir: "/var/spool/asbx/ecosystem",
+			expectError:     "elastic_mpi.max_replacements must be positive",
+		},
+		{
+			name:            "no ready timeout",
+			elastic:         ElasticMPIConfig{Enabled: true, ResizeSignal: "USR2", MaxReplacements: 4},
+			dataExchangeDir: "/var/spool/asbx/ecosystem",
+			expectError:     "elastic_mpi.ready_timeout_minutes must be positive",
+		},
+		{
+			name:            "unconfigured partition",
+			elastic:         ElasticMPIConfig{Enabled: true, Partitions: []string{"gpu"}, ResizeSignal: "USR2", MaxReplacements: 4, ReadyTimeoutMinutes: 10},
+			dataExchangeDir: "/var/spool/asbx/ecosystem",
+			expectError:     `elastic_mpi.partitions: "gpu" is not a configured partition`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Slurm:      SlurmConfig{Partitions: []PartitionConfig{{PartitionName: "aws"}}},
+				Ecosystem:  EcosystemConfig{DataExchangeDir: tt.dataExchangeDir},
+				ElasticMPI: tt.elastic,
+			}
+			err := validateElasticMPI(config)
+			if tt.expectError != "" {
+				assert.EqualError(t, err, tt.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestElasticMPIConfig_ListsPartition(t *testing.T) {
+	elastic := ElasticMPIConfig{Enabled: true, Partitions: []string{"aws"}}
+	assert.True(t, elastic.ListsPartition("aws"))
+	assert.False(t, elastic.ListsPartition("gpu"))
+	assert.False(t, (&ElasticMPIConfig{}).ListsPartition("aws"), "only plans opt in")
+}
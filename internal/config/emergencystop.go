@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// EmergencyStopConfig locates the files of the emergency-stop command. The
+// state file holds the stops in force, which resume refuses to burst past;
+// every stop and clear is appended to the audit log.
+type EmergencyStopConfig struct {
+	StateFile string `mapstructure:"state_file"`
+	AuditLog  string `mapstructure:"audit_log"` // JSON lines, one per event
+}
+
+// Default emergency stop files, used when none are set
+const (
+	DefaultEmergencyStopStateFile = "/var/spool/aws-slurm-burst/emergency-stop.json"
+	DefaultEmergencyStopAuditLog  = "/var/log/aws-slurm-burst/audit.jsonl"
+)
+
+// validateEmergencyStop defaults the files and checks that they are absolute
+func validateEmergencyStop(emergencyStop *EmergencyStopConfig) error {
+	if emergencyStop.StateFile == "" {
+		emergencyStop.StateFile = DefaultEmergencyStopStateFile
+	}
+	if emergencyStop.AuditLog == "" {
+		emergencyStop.AuditLog = DefaultEmergencyStopAuditLog
+	}
+	if !filepath.IsAbs(emergencyStop.StateFile) {
+		return fmt.Errorf("emergency_stop.state_file must be an absolute path, got %q", emergencyStop.StateFile)
+	}
+	if !filepath.IsAbs(emergencyStop.AuditLog) {
+		return fmt.Errorf("emergency_stop.audit_log must be an absolute path, got %q", emergencyStop.AuditLog)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEmergencyStop(t *testing.T) {
+	emergencyStop := EmergencyStopConfig{}
+	assert.NoError(t, validateEmergencyStop(&emergencyStop))
+	assert.Equal(t, DefaultEmergencyStopStateFile, emergencyStop.StateFile)
+	assert.Equal(t, DefaultEmergencyStopAuditLog, emergencyStop.AuditLog)
+
+	assert.Error(t, validateEmergencyStop(&EmergencyStopConfig{StateFile: "emergency-stop.json"}))
+	assert.Error(t, validateEmergencyStop(&EmergencyStopConfig{AuditLog: "audit.jsonl"}))
+}
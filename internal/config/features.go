@@ -0,0 +1,214 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// FeatureMapping maps a Slurm node feature (sbatch --constraint) to EC2 instance attributes
+type FeatureMapping struct {
+	Feature          string   `mapstructure:"feature"`
+	InstanceFamilies []string `mapstructure:"instance_families"` // Restrict to these families, e.g. ["c6i", "m6i"]
+	Architecture     string   `mapstructure:"architecture"`      // "x86_64" or "arm64"
+	GPUs             int      `mapstructure:"gpus"`              // Minimum GPUs per node
+	GPUType          string   `mapstructure:"gpu_type"`
+	RequiresEFA      bool     `mapstructure:"requires_efa"`
+}
+
+// DefaultFeatureMappings returns the built-in feature table used when slurm.feature_mappings is not configured
+func DefaultFeatureMappings() []FeatureMapping {
+	return []FeatureMapping{
+		{Feature: "avx512", InstanceFamilies: []string{"c6i", "m6i", "r6i", "c6id", "m6id", "r6id", "c7i", "m7i", "r7i", "hpc6id"}, Architecture: types.ArchitectureX86_64},
+		{Feature: "icelake", InstanceFamilies: []string{"c6i", "m6i", "r6i", "c6id", "m6id", "r6id", "c6in", "hpc6id"}, Architecture: types.ArchitectureX86_64},
+		{Feature: "sapphirerapids", InstanceFamilies: []string{"c7i", "m7i", "r7i"}, Architecture: types.ArchitectureX86_64},
+		{Feature: "amd", InstanceFamilies: []string{"c6a", "m6a", "r6a", "hpc6a", "hpc7a"}, Architecture: types.ArchitectureX86_64},
+		{Feature: "nvme", InstanceFamilies: []string{"c6id", "m6id", "r6id", "i4i", "hpc6id"}},
+		{Feature: "efa", RequiresEFA: true},
+		{Feature: "a100", InstanceFamilies: []string{"p4d"}, GPUs: 1, GPUType: "a100"},
+		{Feature: "h100", InstanceFamilies: []string{"p5"}, GPUs: 1, GPUType: "h100"},
+		{Feature: "v100", InstanceFamilies: []string{"p3"}, GPUs: 1, GPUType: "v100"},
+		{Feature: "t4", InstanceFamilies: []string{"g4dn"}, GPUs: 1, GPUType: "t4"},
+		{Feature: "a10g", InstanceFamilies: []string{"g5"}, GPUs: 1, GPUType: "a10g"},
+		{Feature: "trainium", InstanceFamilies: types.TrainiumFamilies},
+		{Feature: "inferentia", InstanceFamilies: types.InferentiaFamilies},
+	}
+}
+
+// EffectiveFeatureMappings returns the configured feature table, or the built-in one when none is configured
+func (s *SlurmConfig) EffectiveFeatureMappings() []FeatureMapping {
+	if len(s.FeatureMappings) > 0 {
+		return s.FeatureMappings
+	}
+	return DefaultFeatureMappings()
+}
+
+// MatchFeatures returns the mappings for the given job features and the features that have no mapping.
+// Constraint expressions such as "avx512&nvme" should be split by the caller.
+func (s *SlurmConfig) MatchFeatures(features []string) ([]FeatureMapping, []string) {
+	mappings := make(map[string]FeatureMapping)
+	for _, mapping := range s.EffectiveFeatureMappings() {
+		mappings[strings.ToLower(mapping.Feature)] = mapping
+	}
+
+	var matched []FeatureMapping
+	var unmatched []string
+	for _, feature := range features {
+		feature = strings.TrimSpace(feature)
+		if feature == "" {
+			continue
+		}
+		if mapping, ok := mappings[strings.ToLower(feature)]; ok {
+			matched = append(matched, mapping)
+		} else {
+			unmatched = append(unmatched, feature)
+		}
+	}
+
+	return matched, unmatched
+}
+
+// MatchFeatureGroups returns the mappings for each term of a constraint, whose
+// features are alternatives, and the features that have no mapping. A term
+// with an unmapped alternative is left out: a node with that feature may be
+// any instance type.
+func (s *SlurmConfig) MatchFeatureGroups(groups [][]string) ([][]FeatureMapping, []string) {
+	var matched [][]FeatureMapping
+	var unmatched []string
+	for _, group := range groups {
+		mappings, missing := s.MatchFeatures(group)
+		unmatched = append(unmatched, missing...)
+		if len(missing) == 0 && len(mappings) > 0 {
+			matched = append(matched, mappings)
+		}
+	}
+	return matched, unmatched
+}
+
+// validateFeatureMappings validates the feature-to-instance mapping table
+func validateFeatureMappings(mappings []FeatureMapping) error {
+	seen := make(map[string]bool)
+	for i, mapping := range mappings {
+		if mapping.Feature == "" {
+			return fmt.Errorf("slurm.feature_mappings[%d].feature is required", i)
+		}
+
+		key := strings.ToLower(mapping.Feature)
+		if seen[key] {
+			return fmt.Errorf("slurm.feature_mappings[%d].feature '%s' is defined more than once", i, mapping.Feature)
+		}
+		seen[key] = true
+
+		arch := mapping.Architecture
+		if arch != "" && arch != types.ArchitectureX86_64 && arch != types.ArchitectureARM64 {
+			return fmt.Errorf("slurm.feature_mappings[%d].architecture must be '%s' or '%s'",
+				i, types.ArchitectureX86_64, types.ArchitectureARM64)
+		}
+
+		for _, family := range mapping.InstanceFamilies {
+			if strings.Contains(family, ".") {
+				return fmt.Errorf("slurm.feature_mappings[%d].instance_families must list families, not instance types: %s", i, family)
+			}
+		}
+
+		if mapping.GPUs < 0 {
+			return fmt.Errorf("slurm.feature_mappings[%d].gpus cannot be negative", i)
+		}
+	}
+	return nil
+}
+
+// FeatureRequirements is the combined effect of all features a job requested
+type FeatureRequirements struct {
+	InstanceFamilies []string // nil when no feature restricts families
+	Architecture     string
+	GPUs             int
+	GPUType          string
+	RequiresEFA      bool
+}
+
+// CombineFeatureMappings merges the mappings of a constraint's terms. Any
+// alternative satisfies a term, so its family lists are united; every term
+// must hold at once, so the terms' family lists are intersected.
+func CombineFeatureMappings(groups [][]FeatureMapping) (*FeatureRequirements, error) {
+	combined := &FeatureRequirements{}
+
+	for _, group := range groups {
+		mapping := unionFeatureMappings(group)
+		if len(mapping.InstanceFamilies) > 0 {
+			if combined.InstanceFamilies == nil {
+				combined.InstanceFamilies = append([]string{}, mapping.InstanceFamilies...)
+			} else {
+				combined.InstanceFamilies = intersectFamilies(combined.InstanceFamilies, mapping.InstanceFamilies)
+			}
+			if len(combined.InstanceFamilies) == 0 {
+				return nil, fmt.Errorf("feature '%s' has no instance family in common with the other requested features", mapping.Feature)
+			}
+		}
+
+		if mapping.Architecture != "" {
+			if combined.Architecture != "" && combined.Architecture != mapping.Architecture {
+				return nil, fmt.Errorf("feature '%s' requires %s but another requested feature requires %s",
+					mapping.Feature, mapping.Architecture, combined.Architecture)
+			}
+			combined.Architecture = mapping.Architecture
+		}
+
+		if mapping.GPUs > combined.GPUs {
+			combined.GPUs = mapping.GPUs
+		}
+		if mapping.GPUType != "" {
+			combined.GPUType = mapping.GPUType
+		}
+		combined.RequiresEFA = combined.RequiresEFA || mapping.RequiresEFA
+	}
+
+	return combined, nil
+}
+
+// unionFeatureMappings merges the alternatives of one term into the mapping
+// they all satisfy: families restrict only when every alternative restricts
+// them, and architecture, GPUs and EFA only as far as the alternatives agree
+func unionFeatureMappings(alternatives []FeatureMapping) FeatureMapping {
+	union := alternatives[0]
+	union.InstanceFamilies = append([]string{}, union.InstanceFamilies...)
+	for _, mapping := range alternatives[1:] {
+		union.Feature += "|" + mapping.Feature
+		if len(union.InstanceFamilies) == 0 || len(mapping.InstanceFamilies) == 0 {
+			union.InstanceFamilies = nil
+		} else {
+			for _, family := range mapping.InstanceFamilies {
+				if !slices.Contains(union.InstanceFamilies, family) {
+					union.InstanceFamilies = append(union.InstanceFamilies, family)
+				}
+			}
+		}
+		if mapping.Architecture != union.Architecture {
+			union.Architecture = ""
+		}
+		union.GPUs = min(union.GPUs, mapping.GPUs)
+		if mapping.GPUType != union.GPUType {
+			union.GPUType = ""
+		}
+		union.RequiresEFA = union.RequiresEFA && mapping.RequiresEFA
+	}
+	return union
+}
+
+// intersectFamilies returns the families present in both lists, preserving the order of the first
+func intersectFamilies(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, family := range b {
+		inB[family] = true
+	}
+
+	result := []string{}
+	for _, family := range a {
+		if inB[family] {
+			result = append(result, family)
+		}
+	}
+	return result
+}
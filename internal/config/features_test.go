@@ -0,0 +1,139 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchFeatures(t *testing.T) {
+	slurm := &SlurmConfig{
+		FeatureMappings: []FeatureMapping{
+			{Feature: "avx512", InstanceFamilies: []string{"c6i", "m6i"}},
+			{Feature: "nvme", InstanceFamilies: []string{"c6id", "m6i"}},
+		},
+	}
+
+	matched, unmatched := slurm.MatchFeatures([]string{"AVX512", "nvme", "bigscratch"})
+	require.Len(t, matched, 2)
+	assert.Equal(t, "avx512", matched[0].Feature)
+	assert.Equal(t, []string{"bigscratch"}, unmatched)
+
+	// Built-in table is used when nothing is configured
+	defaults := &SlurmConfig{}
+	matched, unmatched = defaults.MatchFeatures([]string{"a100"})
+	require.Len(t, matched, 1)
+	assert.Equal(t, []string{"p4d"}, matched[0].InstanceFamilies)
+	assert.Empty(t, unmatched)
+}
+
+func TestMatchFeatureGroups(t *testing.T) {
+	slurm := &SlurmConfig{}
+
+	groups, unmatched := slurm.MatchFeatureGroups([][]string{{"icelake", "sapphirerapids"}, {"nvme", "bigscratch"}, {"efa"}})
+	require.Len(t, groups, 2, "a term with an unmapped alternative restricts nothing")
+	assert.Len(t, groups[0], 2)
+	assert.Equal(t, "efa", groups[1][0].Feature)
+	assert.Equal(t, []string{"bigscratch"}, unmatched)
+
+	requirements, err := CombineFeatureMappings(groups)
+	require.NoError(t, err, "[icelake|sapphirerapids] has no family common to both")
+	assert.Contains(t, requirements.InstanceFamilies, "c6i")
+	assert.Contains(t, requirements.InstanceFamilies, "c7i")
+	assert.True(t, requirements.RequiresEFA)
+}
+
+func TestCombineFeatureMappings(t *testing.T) {
+	tests := []struct {
+		name        string
+		groups      [][]FeatureMapping
+		expected    *FeatureRequirements
+		expectError bool
+	}{
+		{
+			name: "families intersected",
+			groups: [][]FeatureMapping{
+				{{Feature: "avx512", InstanceFamilies: []string{"c6i", "m6i", "r6i"}, Architecture: "x86_64"}},
+				{{Feature: "nvme", InstanceFamilies: []string{"m6i", "c6id"}}},
+				{{Feature: "efa", RequiresEFA: true}},
+			},
+			expected: &FeatureRequirements{
+				InstanceFamilies: []string{"m6i"},
+				Architecture:     "x86_64",
+				RequiresEFA:      true,
+			},
+		},
+		{
+			name: "no common family",
+			groups: [][]FeatureMapping{
+				{{Feature: "a100", InstanceFamilies: []string{"p4d"}}},
+				{{Feature: "h100", InstanceFamilies: []string{"p5"}}},
+			},
+			expectError: true,
+		},
+		{
+			name: "conflicting architectures",
+			groups: [][]FeatureMapping{
+				{{Feature: "graviton", Architecture: "arm64"}},
+				{{Feature: "avx512", Architecture: "x86_64"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "alternatives united",
+			groups: [][]FeatureMapping{
+				{
+					{Feature: "icelake", InstanceFamilies: []string{"c6i", "m6i"}, Architecture: "x86_64"},
+					{Feature: "sapphirerapids", InstanceFamilies: []string{"c7i", "m7i"}, Architecture: "x86_64"},
+				},
+				{{Feature: "nvme", InstanceFamilies: []string{"c6id", "m6i", "c7i"}}},
+			},
+			expected: &FeatureRequirements{
+				InstanceFamilies: []string{"m6i", "c7i"},
+				Architecture:     "x86_64",
+			},
+		},
+		{
+			name: "alternatives only as strict as the loosest",
+			groups: [][]FeatureMapping{
+				{
+					{Feature: "a100", InstanceFamilies: []string{"p4d"}, GPUs: 1, GPUType: "a100", RequiresEFA: true},
+					{Feature: "graviton", Architecture: "arm64"},
+				},
+			},
+			expected: &FeatureRequirements{},
+		},
+		{
+			name: "GPU minimum",
+			groups: [][]FeatureMapping{
+				{{Feature: "a100", InstanceFamilies: []string{"p4d"}, GPUs: 1, GPUType: "a100"}},
+			},
+			expected: &FeatureRequirements{
+				InstanceFamilies: []string{"p4d"},
+				GPUs:             1,
+				GPUType:          "a100",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := CombineFeatureMappings(tt.groups)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestValidateFeatureMappings(t *testing.T) {
+	assert.NoError(t, validateFeatureMappings(DefaultFeatureMappings()))
+	assert.Error(t, validateFeatureMappings([]FeatureMapping{{InstanceFamilies: []string{"c6i"}}}))
+	assert.Error(t, validateFeatureMappings([]FeatureMapping{{Feature: "x", InstanceFamilies: []string{"c6i.large"}}}))
+	assert.Error(t, validateFeatureMappings([]FeatureMapping{{Feature: "x", Architecture: "sparc"}}))
+	assert.Error(t, validateFeatureMappings([]FeatureMapping{{Feature: "x"}, {Feature: "X"}}))
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// Node health checks
+const (
+	HealthCheckSlurmd  = "slurmd"  // slurmd is active
+	HealthCheckEFA     = "efa"     // An EFA device is present, on instance types with EFA
+	HealthCheckScratch = "scratch" // scratch_path is a mount point
+	HealthCheckClock   = "clock"   // The clock is synchronized
+)
+
+// HealthCheckConfig keeps newly launched nodes drained until health checks,
+// run on them through SSM Run Command, pass. Nodes that still fail when the
+// timeout runs out are terminated and marked down, so Slurm requeues the job
+// onto new nodes instead of starting MPI ranks on a half-configured one.
+type HealthCheckConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	Checks         []string `mapstructure:"checks"`          // Default: all checks, scratch only with scratch_path
+	ScratchPath    string   `mapstructure:"scratch_path"`    // Mount point of the node's scratch filesystem
+	TimeoutSeconds int      `mapstructure:"timeout_seconds"` // From launch until a node still failing is replaced
+}
+
+// DefaultHealthCheckTimeoutSeconds leaves time for boot, the SSM agent and user data
+const DefaultHealthCheckTimeoutSeconds = 600
+
+// validateHealthCheck defaults the checks and timeout and checks that the
+// scratch check has a path
+func validateHealthCheck(healthCheck *HealthCheckConfig) error {
+	if !healthCheck.Enabled {
+		return nil
+	}
+	if len(healthCheck.Checks) == 0 {
+		healthCheck.Checks = []string{HealthCheckSlurmd, HealthCheckEFA, HealthCheckClock}
+		if healthCheck.ScratchPath != "" {
+			healthCheck.Checks = append(healthCheck.Checks, HealthCheckScratch)
+		}
+	}
+	for _, check := range healthCheck.Checks {
+		if !slices.Contains([]string{HealthCheckSlurmd, HealthCheckEFA, HealthCheckScratch, HealthCheckClock}, check) {
+			return fmt.Errorf("health_check.checks: unknown check %q; use %s, %s, %s or %s",
+				check, HealthCheckSlurmd, HealthCheckEFA, HealthCheckScratch, HealthCheckClock)
+		}
+	}
+	if slices.Contains(healthCheck.Checks, HealthCheckScratch) &&
+		(!filepath.IsAbs(healthCheck.ScratchPath) || !bootstrapValuePattern.MatchString(healthCheck.ScratchPath)) {
+		return fmt.Errorf("health_check.scratch_path must be the absolute path of the scratch mount point, got %q", healthCheck.ScratchPath)
+	}
+	if healthCheck.TimeoutSeconds == 0 {
+		healthCheck.TimeoutSeconds = DefaultHealthCheckTimeoutSeconds
+	}
+	if healthCheck.TimeoutSeconds < 60 {
+		return fmt.Errorf("health_check.timeout_seconds must be at least 60, got %d", healthCheck.TimeoutSeconds)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateHealthCheck(t *testing.T) {
+	assert.NoError(t, validateHealthCheck(&HealthCheckConfig{Checks: []string{"bogus"}}), "disabled")
+
+	healthCheck := HealthCheckConfig{Enabled: true, ScratchPath: "/scratch"}
+	assert.NoError(t, validateHealthCheck(&healthCheck))
+	assert.Equal(t, []string{HealthCheckSlurmd, HealthCheckEFA, HealthCheckClock, HealthCheckScratch}, healthCheck.Checks)
+	assert.Equal(t, DefaultHealthCheckTimeoutSeconds, healthCheck.TimeoutSeconds)
+
+	healthCheck = HealthCheckConfig{Enabled: true}
+	assert.NoError(t, validateHealthCheck(&healthCheck))
+	assert.NotContains(t, healthCheck.Checks, HealthCheckScratch, "no scratch path")
+
+	for _, invalid := range []HealthCheckConfig{
+		{Enabled: true, Checks: []string{"gpu"}},
+		{Enabled: true, Checks: []string{HealthCheckScratch}},
+		{Enabled: true, Checks: []string{HealthCheckScratch}, ScratchPath: "scratch"},
+		{Enabled: true, Checks: []string{HealthCheckScratch}, ScratchPath: "/scratch; reboot"},
+		{Enabled: true, TimeoutSeconds: 30},
+	} {
+		assert.Error(t, validateHealthCheck(&invalid), "%+v", invalid)
+	}
+}
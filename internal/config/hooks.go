@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Lifecycle events hooks run at
+const (
+	HookEventPreLaunch     = "pre_launch"     // Before a node group's instances launch
+	HookEventPostLaunch    = "post_launch"    // After a node group's launch, with its instances or why it failed
+	HookEventPreTerminate  = "pre_terminate"  // Before nodes' instances are terminated or stopped
+	HookEventPostTerminate = "post_terminate" // After they were
+)
+
+// HookEvents are the events in lifecycle order
+var HookEvents = []string{HookEventPreLaunch, HookEventPostLaunch, HookEventPreTerminate, HookEventPostTerminate}
+
+// DefaultHookTimeoutSeconds bounds a hook without timeout_seconds
+const DefaultHookTimeoutSeconds = 30
+
+// HookConfig is a site script or webhook given a JSON description of each
+// event it subscribes to, for license checkout, CMDB registration or
+// accounting. A failing hook is logged and the lifecycle goes on, except a
+// required pre_launch hook, whose failure fails the launch.
+type HookConfig struct {
+	Name           string            `mapstructure:"name"`
+	Events         []string          `mapstructure:"events"`
+	Command        string            `mapstructure:"command"`         // Run through the shell with the payload on stdin
+	URL            string            `mapstructure:"url"`             // Webhook the payload is POSTed to, instead of a command
+	Headers        map[string]string `mapstructure:"headers"`         // Webhook headers; ${VAR} expands from the environment
+	TimeoutSeconds int               `mapstructure:"timeout_seconds"` // Longest run of the hook per event
+	Required       bool              `mapstructure:"required"`        // pre_launch only: a failure fails the launch
+}
+
+// Timeout bounds one run of the hook
+func (h *HookConfig) Timeout() time.Duration {
+	return time.Duration(h.TimeoutSeconds) * time.Second
+}
+
+// Handles reports whether the hook subscribes to event
+func (h *HookConfig) Handles(event string) bool {
+	return slices.Contains(h.Events, event)
+}
+
+// validateHooks checks that each hook has a unique name, known events and
+// exactly one of a command and a webhook URL, and defaults the timeouts
+func validateHooks(hooks []HookConfig) error {
+	names := make(map[string]bool, len(hooks))
+	for i := range hooks {
+		hook := &hooks[i]
+		if hook.Name == "" {
+			return fmt.Errorf("hooks[%d].name is required", i)
+		}
+		if names[hook.Name] {
+			return fmt.Errorf("hooks: duplicate hook name %q", hook.Name)
+		}
+		names[hook.Name] = true
+
+		if len(hook.Events) == 0 {
+			return fmt.Errorf("hooks.%s.events is required", hook.Name)
+		}
+		for _, event := range hook.Events {
+			if !slices.Contains(HookEvents, event) {
+				return fmt.Errorf("hooks.%s.events: unknown event %q; use %s, %s, %s or %s",
+					hook.Name, event, HookEventPreLaunch, HookEventPostLaunch, HookEventPreTerminate, HookEventPostTerminate)
+			}
+		}
+		if hook.Required && (len(hook.Events) != 1 || hook.Events[0] != HookEventPreLaunch) {
+			return fmt.Errorf("hooks.%s.required only applies to a hook run at %s alone; a failure at other events cannot undo them",
+				hook.Name, HookEventPreLaunch)
+		}
+
+		if (hook.Command == "") == (hook.URL == "") {
+			return fmt.Errorf("hooks.%s needs exactly one of command and url", hook.Name)
+		}
+		if hook.URL != "" {
+			parsed, err := url.Parse(hook.URL)
+			if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+				return fmt.Errorf("hooks.%s.url must be an http or https URL, got %q", hook.Name, hook.URL)
+			}
+		} else if len(hook.Headers) > 0 {
+			return fmt.Errorf("hooks.%s.headers only apply to a webhook url", hook.Name)
+		}
+
+		if hook.TimeoutSeconds == 0 {
+			hook.TimeoutSeconds = DefaultHookTimeoutSeconds
+		}
+		if hook.TimeoutSeconds < 0 {
+			return fmt.Errorf("hooks.%s.timeout_seconds must be positive, got %d", hook.Name, hook.TimeoutSeconds)
+		}
+	}
+	return nil
+}
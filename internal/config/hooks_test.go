@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateHooks(t *testing.T) {
+	hooks := []HookConfig{
+		{Name: "licenses", Events: []string{HookEventPreLaunch}, Command: "/usr/local/bin/checkout-license", Required: true},
+		{Name: "cmdb", Events: []string{HookEventPostLaunch, HookEventPostTerminate}, URL: "https://cmdb.example.com/burst",
+			Headers: map[string]string{"Authorization": "Bearer ${CMDB_TOKEN}"}, TimeoutSeconds: 10},
+	}
+	require.NoError(t, validateHooks(hooks))
+	assert.Equal(t, DefaultHookTimeoutSeconds, hooks[0].TimeoutSeconds)
+	assert.Equal(t, 10, hooks[1].TimeoutSeconds)
+	assert.True(t, hooks[1].Handles(HookEventPostTerminate))
+	assert.False(t, hooks[1].Handles(HookEventPreTerminate))
+
+	tests := []struct {
+		name string
+		hook HookConfig
+		err  string
+	}{
+		{"no name", HookConfig{Events: []string{HookEventPreLaunch}, Command: "true"}, "hooks[0].name is required"},
+		{"no events", HookConfig{Name: "a", Command: "true"}, "hooks.a.events is required"},
+		{"unknown event", HookConfig{Name: "a", Events: []string{"pre_suspend"}, Command: "true"}, `unknown event "pre_suspend"`},
+		{"command and url", HookConfig{Name: "a", Events: []string{HookEventPreLaunch}, Command: "true", URL: "https://example.com"}, "exactly one of command and url"},
+		{"neither", HookConfig{Name: "a", Events: []string{HookEventPreLaunch}}, "exactly one of command and url"},
+		{"bad url", HookConfig{Name: "a", Events: []string{HookEventPreLaunch}, URL: "cmdb.example.com"}, "must be an http or https URL"},
+		{"headers on command", HookConfig{Name: "a", Events: []string{HookEventPreLaunch}, Command: "true", Headers: map[string]string{"X": "y"}}, "only apply to a webhook"},
+		{"required post_launch", HookConfig{Name: "a", Events: []string{HookEventPostLaunch}, Command: "true", Required: true}, "required only applies"},
+		{"required on several events", HookConfig{Name: "a", Events: []string{HookEventPreLaunch, HookEventPostLaunch}, Command: "true", Required: true}, "required only applies"},
+		{"negative timeout", HookConfig{Name: "a", Events: []string{HookEventPreLaunch}, Command: "true", TimeoutSeconds: -1}, "timeout_seconds must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, validateHooks([]HookConfig{tt.hook}), tt.err)
+		})
+	}
+
+	assert.ErrorContains(t, validateHooks([]HookConfig{
+		{Name: "a", Events: []string{HookEventPreLaunch}, Command: "true"},
+		{Name: "a", Events: []string{HookEventPostLaunch}, Command: "true"},
+	}), `duplicate hook name "a"`)
+}
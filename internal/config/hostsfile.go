@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// HostsFileConfig keeps burst node entries in the controller's hosts file,
+// for sites without DNS for burst nodes. Resume adds each launched node's
+// private IP between marker comments and suspend removes it.
+type HostsFileConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"` // Written by the Slurm user; may be a symlink to a file it owns
+}
+
+// DefaultHostsFile is the hosts file updated when no path is set
+const DefaultHostsFile = "/etc/hosts"
+
+// validateHostsFile defaults the path and checks that it is absolute
+func validateHostsFile(hostsFile *HostsFileConfig) error {
+	if hostsFile.Path == "" {
+		hostsFile.Path = DefaultHostsFile
+	}
+	if !filepath.IsAbs(hostsFile.Path) {
+		return fmt.Errorf("hosts_file.path must be an absolute path, got %q", hostsFile.Path)
+	}
+	return nil
+}
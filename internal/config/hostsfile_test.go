@@ -0,0 +1,16 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateHostsFile(t *testing.T) {
+	hostsFile := HostsFileConfig{Enabled: true}
+	assert.NoError(t, validateHostsFile(&hostsFile))
+	assert.Equal(t, DefaultHostsFile, hostsFile.Path)
+
+	assert.NoError(t, validateHostsFile(&HostsFileConfig{Enabled: true, Path: "/var/lib/aws-slurm-burst/hosts"}))
+	assert.Error(t, validateHostsFile(&HostsFileConfig{Enabled: true, Path: "hosts"}))
+}
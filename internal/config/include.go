@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// Fragment is a file of partitions merged into the main configuration through
+// its include patterns, so each department can own its node groups
+type Fragment struct {
+	Partitions []PartitionConfig `mapstructure:"partitions"`
+}
+
+// LoadFragment reads and validates a fragment on its own, without the main
+// configuration. Errors name the fragment file.
+func LoadFragment(path string) (*Fragment, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read fragment %s: %w", path, err)
+	}
+
+	var fragment Fragment
+	if err := v.Unmarshal(&fragment); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal fragment %s: %w", path, err)
+	}
+	if err := interpolate(&fragment); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(fragment.Partitions) == 0 {
+		return nil, fmt.Errorf("%s: fragment defines no partitions", path)
+	}
+	for i, partition := range fragment.Partitions {
+		if err := validatePartition(partition, i); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return &fragment, nil
+}
+
+// IncludedFiles expands the configuration's include patterns, relative to the
+// directory of configPath, in the order their fragments are merged
+func (c *Config) IncludedFiles(configPath string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, pattern := range c.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(configPath), pattern)
+		}
+		// Glob sorts its matches, so conf.d/10-*.yaml merges before conf.d/20-*.yaml
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	return files, nil
+}
+
+// mergeIncludes merges every included fragment into config
+func mergeIncludes(config *Config, configPath string) error {
+	files, err := config.IncludedFiles(configPath)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fragment, err := LoadFragment(file)
+		if err != nil {
+			return err
+		}
+		if err := mergeFragment(config, fragment, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFragment adds the fragment's partitions to config. A partition that is
+// already defined only gains node groups; its settings stay with the file that
+// defined it, and node group names must stay unique within the partition.
+func mergeFragment(config *Config, fragment *Fragment, file string) error {
+	for _, partition := range fragment.Partitions {
+		existing := findPartition(config.Slurm.Partitions, partition.PartitionName)
+		if existing == nil {
+			config.Slurm.Partitions = append(config.Slurm.Partitions, partition)
+			continue
+		}
+
+		if len(partition.PartitionOptions) > 0 || partition.Scaling != (ScalingPolicyConfig{}) {
+			return fmt.Errorf("%s: partition %q is already defined; fragments may only add node groups to it",
+				file, partition.PartitionName)
+		}
+		for _, nodeGroup := range partition.NodeGroups {
+			for _, other := range existing.NodeGroups {
+				if other.NodeGroupName == nodeGroup.NodeGroupName {
+					return fmt.Errorf("%s: node group %q is already defined in partition %q",
+						file, nodeGroup.NodeGroupName, partition.PartitionName)
+				}
+			}
+			existing.NodeGroups = append(existing.NodeGroups, nodeGroup)
+		}
+	}
+	return nil
+}
+
+// findPartition returns the named partition, or nil
+func findPartition(partitions []PartitionConfig, name string) *PartitionConfig {
+	for i := range partitions {
+		if partitions[i].PartitionName == name {
+			return &partitions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mainConfigWithIncludes = `
+aws:
+  region: us-east-1
+include:
+  - conf.d/*.yaml
+slurm:
+  partitions:
+    - partition_name: aws
+      node_groups:
+        - node_group_name: cpu
+          max_nodes: 10
+          region: us-east-1
+          purchasing_option: on-demand
+          launch_template_overrides:
+            - instance_type: c5.large
+          subnet_ids:
+            - subnet-123456
+`
+
+func nodeGroupFragment(partition, nodeGroup string) string {
+	return `
+partitions:
+  - partition_name: ` + partition + `
+    node_groups:
+      - node_group_name: ` + nodeGroup + `
+        max_nodes: 4
+        region: us-east-1
+        purchasing_option: spot
+        launch_template_overrides:
+          - instance_type: g5.xlarge
+        subnet_ids:
+          - subnet-654321
+`
+}
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return filepath.Join(dir, "config.yaml")
+}
+
+func TestLoadIncludes(t *testing.T) {
+	configPath := writeConfigFiles(t, map[string]string{
+		"config.yaml":         mainConfigWithIncludes,
+		"conf.d/20-bio.yaml":  nodeGroupFragment("bio", "gpu"),
+		"conf.d/10-chem.yaml": nodeGroupFragment("aws", "chem"),
+		"conf.d/notes.txt":    "not a fragment",
+	})
+
+	config, err := Load(configPath)
+	require.NoError(t, err)
+	require.Len(t, config.Slurm.Partitions, 2)
+
+	aws := config.Slurm.Partitions[0]
+	assert.Equal(t, "aws", aws.PartitionName)
+	require.Len(t, aws.NodeGroups, 2, "fragments add node groups to existing partitions")
+	assert.Equal(t, "chem", aws.NodeGroups[1].NodeGroupName)
+
+	bio := config.Slurm.Partitions[1]
+	assert.Equal(t, "bio", bio.PartitionName)
+	assert.Equal(t, "gpu", bio.NodeGroups[0].NodeGroupName)
+	assert.NotNil(t, config.FindNodeGroup("bio", "gpu"))
+}
+
+func TestLoadIncludesRejectsConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+		errMsg   string
+	}{
+		{
+			name:     "duplicate node group",
+			fragment: nodeGroupFragment("aws", "cpu"),
+			errMsg:   `node group "cpu" is already defined in partition "aws"`,
+		},
+		{
+			name: "partition settings",
+			fragment: `
+partitions:
+  - partition_name: aws
+    partition_options:
+      MaxTime: "60"
+    node_groups:
+      - node_group_name: extra
+        max_nodes: 1
+        region: us-east-1
+        purchasing_option: spot
+        launch_template_overrides:
+          - instance_type: c5.large
+        subnet_ids:
+          - subnet-1
+`,
+			errMsg: `partition "aws" is already defined`,
+		},
+		{
+			name:     "invalid fragment",
+			fragment: nodeGroupFragment("bio", "gpu-a"),
+			errMsg:   "dept.yaml: partitions[0].node_groups[0].node_group_name must contain only alphanumeric characters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := writeConfigFiles(t, map[string]string{
+				"config.yaml":      mainConfigWithIncludes,
+				"conf.d/dept.yaml": tt.fragment,
+			})
+			_, err := Load(configPath)
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestLoadFragment(t *testing.T) {
+	configPath := writeConfigFiles(t, map[string]string{"bio.yaml": nodeGroupFragment("bio", "gpu")})
+	fragment, err := LoadFragment(filepath.Join(filepath.Dir(configPath), "bio.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "gpu", fragment.Partitions[0].NodeGroups[0].NodeGroupName)
+
+	configPath = writeConfigFiles(t, map[string]string{"empty.yaml": "partitions: []\n"})
+	_, err = LoadFragment(filepath.Join(filepath.Dir(configPath), "empty.yaml"))
+	assert.ErrorContains(t, err, "fragment defines no partitions")
+}
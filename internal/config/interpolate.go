@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// interpolationPattern matches ${NAME} and ${file:/path} references. A
+// reference written as $${...} is kept literally, minus one dollar sign.
+var interpolationPattern = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// interpolate expands references in every string of a configuration or
+// fragment (a pointer to a struct), so one file can be promoted across
+// accounts with the differences kept in the environment or in secret files
+func interpolate(config any) error {
+	return interpolateValue(reflect.ValueOf(config).Elem(), "")
+}
+
+// interpolateValue walks v, naming fields by their mapstructure keys in errors
+func interpolateValue(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			// Values held in interfaces cannot be set in place
+			expanded := reflect.New(v.Elem().Type()).Elem()
+			expanded.Set(v.Elem())
+			if err := interpolateValue(expanded, path); err != nil {
+				return err
+			}
+			v.Set(expanded)
+			return nil
+		}
+		return interpolateValue(v.Elem(), path)
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			key := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+			if key == "" {
+				key = strings.ToLower(field.Name)
+			}
+			if err := interpolateValue(v.Field(i), joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := interpolateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// Map values are not addressable; expand a copy and store it back
+			expanded := reflect.New(v.Type().Elem()).Elem()
+			expanded.Set(v.MapIndex(key))
+			if err := interpolateValue(expanded, joinPath(path, fmt.Sprint(key.Interface()))); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, expanded)
+		}
+
+	case reflect.String:
+		expanded, err := expandString(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.SetString(expanded)
+	}
+	return nil
+}
+
+// expandString replaces ${NAME} with the environment variable and
+// ${file:/path} with the file's contents, without the trailing newline. Unset
+// variables and unreadable files are errors rather than empty strings, which
+// would otherwise surface later as confusing AWS failures.
+func expandString(value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var firstErr error
+	expanded := interpolationPattern.ReplaceAllStringFunc(value, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		reference := interpolationPattern.FindStringSubmatch(match)[1]
+
+		if path, ok := strings.CutPrefix(reference, "file:"); ok {
+			data, err := os.ReadFile(path)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to read %s: %w", match, err)
+			}
+			return strings.TrimRight(string(data), "\r\n")
+		}
+
+		resolved, ok := os.LookupEnv(reference)
+		if !ok && firstErr == nil {
+			firstErr = fmt.Errorf("environment variable %s is not set", reference)
+		}
+		return resolved
+	})
+	return expanded, firstErr
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandString(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "role-arn")
+	require.NoError(t, os.WriteFile(secret, []byte("arn:aws:iam::123456789012:role/burst\n"), 0600))
+	t.Setenv("BURST_ENV", "prod")
+
+	tests := []struct {
+		value    string
+		expected string
+		errMsg   string
+	}{
+		{value: "no references", expected: "no references"},
+		{value: "${BURST_ENV}-profile", expected: "prod-profile"},
+		{value: "${file:" + secret + "}", expected: "arn:aws:iam::123456789012:role/burst"},
+		{value: "cost $5 at $${BURST_ENV}", expected: "cost $5 at ${BURST_ENV}"},
+		{value: "${BURST_UNSET_VARIABLE}", errMsg: "BURST_UNSET_VARIABLE is not set"},
+		{value: "${file:/nonexistent/role-arn}", errMsg: "failed to read ${file:/nonexistent/role-arn}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			expanded, err := expandString(tt.value)
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, expanded)
+		})
+	}
+}
+
+func TestLoadInterpolation(t *testing.T) {
+	t.Setenv("BURST_REGION", "us-west-2")
+	t.Setenv("BURST_SUBNET", "subnet-0abc")
+	t.Setenv("BURST_PROFILE", "staging")
+
+	configContent := `
+aws:
+  region: ${BURST_REGION}
+  profile: ${BURST_PROFILE}
+slurm:
+  partitions:
+    - partition_name: aws
+      node_groups:
+        - node_group_name: cpu
+          max_nodes: 10
+          region: ${BURST_REGION}
+          purchasing_option: on-demand
+          launch_template_overrides:
+            - instance_type: c5.large
+          subnet_ids:
+            - ${BURST_SUBNET}
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(configContent), 0644))
+
+	config, err := Load(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", config.AWS.Region)
+	assert.Equal(t, "staging", config.AWS.Profile)
+	nodeGroup := config.Slurm.Partitions[0].NodeGroups[0]
+	assert.Equal(t, "us-west-2", nodeGroup.Region)
+	assert.Equal(t, []string{"subnet-0abc"}, nodeGroup.SubnetIds)
+
+	// Unset variables name the setting that needs them
+	require.NoError(t, os.Unsetenv("BURST_SUBNET"))
+	_, err = Load(configPath)
+	assert.ErrorContains(t, err, "slurm.partitions[0].node_groups[0].subnet_ids[0]: environment variable BURST_SUBNET is not set")
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// JobOverridesConfig lets job scripts steer standalone-mode bursting with
+// #ASBX pragmas: which of the node group's instance types to launch, how to
+// buy them and the most to pay per instance-hour. Sites limit the purchasing
+// options jobs may choose, e.g. to keep users off on-demand capacity.
+type JobOverridesConfig struct {
+	Enabled           bool     `mapstructure:"enabled"`
+	PurchasingOptions []string `mapstructure:"purchasing_options"` // Purchasing options a job may choose
+}
+
+// AllowsPurchasing reports whether jobs may choose the purchasing option
+func (c *JobOverridesConfig) AllowsPurchasing(option string) bool {
+	return slices.Contains(c.PurchasingOptions, option)
+}
+
+// validateJobOverrides checks the purchasing options jobs may choose
+func validateJobOverrides(config *Config) error {
+	overrides := &config.JobOverrides
+	if !overrides.Enabled {
+		return nil
+	}
+	for _, option := range overrides.PurchasingOptions {
+		if !slices.Contains(PurchasingOptions, option) {
+			return fmt.Errorf("job_overrides.purchasing_options must be from %v, got %q", PurchasingOptions, option)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateJobOverrides(t *testing.T) {
+	assert.NoError(t, validateJobOverrides(&Config{}), "disabled")
+
+	valid := JobOverridesConfig{Enabled: true, PurchasingOptions: []string{"spot", "mixed"}}
+	assert.NoError(t, validateJobOverrides(&Config{JobOverrides: valid}))
+	assert.True(t, valid.AllowsPurchasing("spot"))
+	assert.False(t, valid.AllowsPurchasing("on-demand"))
+
+	assert.NoError(t, validateJobOverrides(&Config{JobOverrides: JobOverridesConfig{Enabled: true}}), "no purchasing choice")
+	assert.Error(t, validateJobOverrides(&Config{JobOverrides: JobOverridesConfig{Enabled: true, PurchasingOptions: []string{"reserved"}}}))
+}
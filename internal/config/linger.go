@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// LingerConfig keeps a suspended node's instance running, with slurmd stopped,
+// for a short window in which the next resume of the node group claims it for
+// another node instead of launching a new instance. It suits dense campaigns
+// of short jobs, where terminate-and-relaunch churn dominates. Claiming needs
+// the SSM agent on the nodes; lingering instances past the window are
+// terminated by the state manager.
+type LingerConfig struct {
+	Seconds      int    `mapstructure:"seconds"`       // How long an idle instance waits to be claimed; 0 disables lingering
+	ClaimCommand string `mapstructure:"claim_command"` // Run on a claimed node with NODE set, instead of restarting slurmd as NODE
+}
+
+// Enabled reports whether the node group's instances linger after suspend
+func (l LingerConfig) Enabled() bool {
+	return l.Seconds > 0
+}
+
+// Window returns how long an instance lingers
+func (l LingerConfig) Window() time.Duration {
+	return time.Duration(l.Seconds) * time.Second
+}
+
+// validateLinger checks the linger window. Lingering replaces termination, so
+// node groups that stop their instances on suspend cannot linger.
+func validateLinger(nodeGroup NodeGroupConfig, partitionIndex, nodeGroupIndex int) error {
+	prefix := fmt.Sprintf("partitions[%d].node_groups[%d].linger", partitionIndex, nodeGroupIndex)
+	if nodeGroup.Linger.Seconds < 0 {
+		return fmt.Errorf("%s.seconds cannot be negative", prefix)
+	}
+	if nodeGroup.Linger.ClaimCommand != "" && !nodeGroup.Linger.Enabled() {
+		return fmt.Errorf("%s.claim_command requires seconds", prefix)
+	}
+	if nodeGroup.Linger.Enabled() && nodeGroup.KeepsStoppedInstances() {
+		return fmt.Errorf("%s requires suspend_mode 'terminate'", prefix)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLinger(t *testing.T) {
+	assert.NoError(t, validateLinger(NodeGroupConfig{}, 0, 0), "disabled")
+
+	lingering := NodeGroupConfig{Linger: LingerConfig{Seconds: 120, ClaimCommand: "/opt/site/claim.sh"}}
+	assert.NoError(t, validateLinger(lingering, 0, 0))
+	assert.True(t, lingering.Linger.Enabled())
+	assert.Equal(t, 2*time.Minute, lingering.Linger.Window())
+
+	for _, invalid := range []NodeGroupConfig{
+		{Linger: LingerConfig{Seconds: -1}},
+		{Linger: LingerConfig{ClaimCommand: "/opt/site/claim.sh"}},
+		{Linger: LingerConfig{Seconds: 120}, SuspendMode: SuspendModeStop},
+	} {
+		assert.Error(t, validateLinger(invalid, 0, 0), "%+v", invalid.Linger)
+	}
+}
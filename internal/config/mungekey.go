@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Munge key sources
+const (
+	MungeKeySourceSSM = "ssm"
+	MungeKeySourceS3  = "s3"
+)
+
+// MungeKeyConfig delivers the controller's munge key to burst nodes from user
+// data, so they authenticate to slurmctld without a key baked into the AMI.
+// The node role only needs to read the one parameter or object, and KMS
+// decrypts it on the way.
+type MungeKeyConfig struct {
+	Source    string `mapstructure:"source"`     // "ssm" or "s3"; empty leaves the key to the AMI
+	Parameter string `mapstructure:"parameter"`  // SSM SecureString holding the base64 munge key
+	S3URL     string `mapstructure:"s3_url"`     // s3://bucket/key of the raw key, stored with SSE-KMS
+	KMSKeyID  string `mapstructure:"kms_key_id"` // Customer managed key encrypting it; empty for the AWS managed key
+	KeyFile   string `mapstructure:"key_file"`   // The controller's key, which doctor compares against
+}
+
+// DefaultMungeKeyFile is where munge keeps its key
+const DefaultMungeKeyFile = "/etc/munge/munge.key"
+
+// Enabled reports whether nodes fetch the munge key from user data
+func (m *MungeKeyConfig) Enabled() bool {
+	return m.Source != ""
+}
+
+// Location names where the key is stored, for logs and messages
+func (m *MungeKeyConfig) Location() string {
+	if m.Source == MungeKeySourceS3 {
+		return m.S3URL
+	}
+	return "ssm:" + m.Parameter
+}
+
+// validateMungeKey checks that the configured source has a location the
+// user-data script can use
+func validateMungeKey(mungeKey *MungeKeyConfig) error {
+	if mungeKey.KeyFile == "" {
+		mungeKey.KeyFile = DefaultMungeKeyFile
+	}
+	switch mungeKey.Source {
+	case "":
+		return nil
+	case MungeKeySourceSSM:
+		if !strings.HasPrefix(mungeKey.Parameter, "/") || !bootstrapValuePattern.MatchString(mungeKey.Parameter) {
+			return fmt.Errorf("munge_key.parameter must be an SSM parameter path such as /slurm/munge-key, got %q", mungeKey.Parameter)
+		}
+	case MungeKeySourceS3:
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(mungeKey.S3URL, "s3://"), "/")
+		if !strings.HasPrefix(mungeKey.S3URL, "s3://") || bucket == "" || key == "" || strings.HasSuffix(key, "/") ||
+			!bootstrapValuePattern.MatchString(mungeKey.S3URL) {
+			return fmt.Errorf("munge_key.s3_url must be the s3://bucket/key of the munge key, got %q", mungeKey.S3URL)
+		}
+	default:
+		return fmt.Errorf("munge_key.source must be %q or %q, got %q", MungeKeySourceSSM, MungeKeySourceS3, mungeKey.Source)
+	}
+	if mungeKey.KMSKeyID != "" && !bootstrapValuePattern.MatchString(mungeKey.KMSKeyID) {
+		return fmt.Errorf("munge_key.kms_key_id must be a KMS key ID or ARN, got %q", mungeKey.KMSKeyID)
+	}
+	return nil
+}
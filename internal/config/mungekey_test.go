@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateMungeKey(t *testing.T) {
+	disabled := MungeKeyConfig{}
+	assert.NoError(t, validateMungeKey(&disabled))
+	assert.Equal(t, DefaultMungeKeyFile, disabled.KeyFile)
+
+	for _, valid := range []MungeKeyConfig{
+		{Source: "ssm", Parameter: "/slurm/munge-key"},
+		{Source: "s3", S3URL: "s3://cluster-secrets/slurm/munge.key", KMSKeyID: "arn:aws:kms:us-west-2:123456789012:key/abcd-1234"},
+	} {
+		assert.NoError(t, validateMungeKey(&valid), "%+v", valid)
+	}
+
+	for _, invalid := range []MungeKeyConfig{
+		{Source: "vault", Parameter: "/slurm/munge-key"},
+		{Source: "ssm"},
+		{Source: "ssm", Parameter: "munge-key"},
+		{Source: "ssm", Parameter: "/slurm/munge key"},
+		{Source: "s3", S3URL: "s3://cluster-secrets"},
+		{Source: "s3", S3URL: "s3://cluster-secrets/slurm/"},
+		{Source: "s3", S3URL: "https://cluster-secrets/munge.key"},
+		{Source: "ssm", Parameter: "/slurm/munge-key", KMSKeyID: "alias/$(reboot)"},
+	} {
+		assert.Error(t, validateMungeKey(&invalid), "%+v", invalid)
+	}
+}
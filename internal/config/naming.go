@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// idFormatPattern accepts the printf verbs a node number can be written with
+var idFormatPattern = regexp.MustCompile(`^%(0[1-9][0-9]?)?d$`)
+
+// separator returns the separator between name parts, "-" by default
+func (n NodeNamingConfig) separator() string {
+	if n.Separator == "" {
+		return "-"
+	}
+	return n.Separator
+}
+
+// FormatID writes a node number the way node names carry it
+func (n NodeNamingConfig) FormatID(id int) string {
+	if n.IDFormat == "" {
+		return fmt.Sprint(id)
+	}
+	return fmt.Sprintf(n.IDFormat, id)
+}
+
+// NodeName joins the prefix, partition, node group and node ID, e.g.
+// prod-aws-cpu-001. Without a node ID it returns the node group's name stem.
+func (n NodeNamingConfig) NodeName(partitionName, nodeGroupName, nodeID string) string {
+	parts := []string{partitionName, nodeGroupName}
+	if n.Prefix != "" {
+		parts = append([]string{n.Prefix}, parts...)
+	}
+	if nodeID != "" {
+		parts = append(parts, nodeID)
+	}
+	return strings.Join(parts, n.separator())
+}
+
+// NamePrefix is the start all of a node group's node names share, e.g. aws-cpu-
+func (n NodeNamingConfig) NamePrefix(partitionName, nodeGroupName string) string {
+	return n.NodeName(partitionName, nodeGroupName, "") + n.separator()
+}
+
+// NodeRange is the Slurm hostlist of a node group's maxNodes nodes, e.g.
+// aws-cpu-[001-020]
+func (n NodeNamingConfig) NodeRange(partitionName, nodeGroupName string, maxNodes int) string {
+	if maxNodes > 1 {
+		ids := fmt.Sprintf("[%s-%s]", n.FormatID(n.FirstID), n.FormatID(n.FirstID+maxNodes-1))
+		return n.NodeName(partitionName, nodeGroupName, ids)
+	}
+	return n.NodeName(partitionName, nodeGroupName, n.FormatID(n.FirstID))
+}
+
+// Pattern matches node names, capturing the partition, node group and node
+// ID. Partition and node group names are alphanumeric and the separator is
+// not, so every name splits one way only.
+func (n NodeNamingConfig) Pattern() *regexp.Regexp {
+	sep := regexp.QuoteMeta(n.separator())
+	prefix := ""
+	if n.Prefix != "" {
+		prefix = regexp.QuoteMeta(n.Prefix) + sep
+	}
+	return regexp.MustCompile(fmt.Sprintf(`^%s([a-zA-Z0-9]+)%s([a-zA-Z0-9]+)%s([0-9]+)$`, prefix, sep, sep))
+}
+
+// ParseNodeName splits a node name into its partition, node group and node ID
+func (n NodeNamingConfig) ParseNodeName(nodeName string) (partitionName, nodeGroupName, nodeID string, ok bool) {
+	matches := n.Pattern().FindStringSubmatch(nodeName)
+	if matches == nil {
+		return "", "", "", false
+	}
+	return matches[1], matches[2], matches[3], true
+}
+
+// validateNodeNaming rejects naming schemes whose names could not be parsed back
+func validateNodeNaming(naming *NodeNamingConfig) error {
+	if naming.Separator != "" && strings.ContainsFunc(naming.Separator, isAlphanumeric) {
+		return fmt.Errorf("slurm.node_naming.separator must not contain letters or digits, got %q", naming.Separator)
+	}
+	if strings.ContainsAny(naming.Separator, ",[] ") || strings.ContainsAny(naming.Prefix, ",[] ") {
+		return fmt.Errorf("slurm.node_naming.prefix and separator must not contain commas, brackets or spaces")
+	}
+	if naming.IDFormat != "" && !idFormatPattern.MatchString(naming.IDFormat) {
+		return fmt.Errorf("slurm.node_naming.id_format must be %%d or a zero-padded %%0Nd, got %q", naming.IDFormat)
+	}
+	if naming.FirstID < 0 {
+		return fmt.Errorf("slurm.node_naming.first_id must not be negative")
+	}
+	return nil
+}
+
+func isAlphanumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
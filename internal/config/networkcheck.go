@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// NetworkCheckConfig checks the hybrid network path before each burst. Each
+// node group subnet's route to the controller must be active, and the VPN
+// tunnels or transit gateway attachment it uses must be up. Nodes launched
+// while the path is down could never register with slurmctld.
+type NetworkCheckConfig struct {
+	Enabled           bool   `mapstructure:"enabled"`
+	ControllerAddress string `mapstructure:"controller_address"` // slurmctld's IP address as burst nodes reach it
+	ControllerCIDR    string `mapstructure:"controller_cidr"`    // Hosts running slurmctld and srun; defaults to controller_address
+}
+
+// Controller returns the controller address; validation has parsed it
+func (n *NetworkCheckConfig) Controller() netip.Addr {
+	addr, _ := netip.ParseAddr(n.ControllerAddress)
+	return addr
+}
+
+// ControllerNetwork returns the hosts burst nodes exchange Slurm traffic
+// with, and false when neither the CIDR nor the address is set
+func (n *NetworkCheckConfig) ControllerNetwork() (netip.Prefix, bool) {
+	if prefix, err := netip.ParsePrefix(n.ControllerCIDR); err == nil {
+		return prefix.Masked(), true
+	}
+	if addr, err := netip.ParseAddr(n.ControllerAddress); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+	return netip.Prefix{}, false
+}
+
+// validateNetworkCheck checks that the controller address is an IP address
+// and the controller CIDR a network
+func validateNetworkCheck(networkCheck *NetworkCheckConfig) error {
+	if networkCheck.Enabled || networkCheck.ControllerAddress != "" {
+		if _, err := netip.ParseAddr(networkCheck.ControllerAddress); err != nil {
+			return fmt.Errorf("network_check.controller_address must be slurmctld's IP address, got %q", networkCheck.ControllerAddress)
+		}
+	}
+	if networkCheck.ControllerCIDR != "" {
+		if _, err := netip.ParsePrefix(networkCheck.ControllerCIDR); err != nil {
+			return fmt.Errorf("network_check.controller_cidr must be a CIDR block such as 192.168.10.0/24, got %q", networkCheck.ControllerCIDR)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateNetworkCheck(t *testing.T) {
+	assert.NoError(t, validateNetworkCheck(&NetworkCheckConfig{}), "disabled")
+
+	valid := NetworkCheckConfig{Enabled: true, ControllerAddress: "10.20.0.5"}
+	assert.NoError(t, validateNetworkCheck(&valid))
+	assert.Equal(t, "10.20.0.5", valid.Controller().String())
+
+	for _, address := range []string{"", "head-node", "10.20.0.0/16"} {
+		assert.Error(t, validateNetworkCheck(&NetworkCheckConfig{Enabled: true, ControllerAddress: address}), address)
+	}
+	assert.Error(t, validateNetworkCheck(&NetworkCheckConfig{ControllerCIDR: "10.20.0.5"}))
+}
+
+func TestNetworkCheckConfig_ControllerNetwork(t *testing.T) {
+	_, ok := (&NetworkCheckConfig{}).ControllerNetwork()
+	assert.False(t, ok)
+
+	network, ok := (&NetworkCheckConfig{ControllerAddress: "10.20.0.5"}).ControllerNetwork()
+	assert.True(t, ok)
+	assert.Equal(t, "10.20.0.5/32", network.String())
+
+	// Login nodes running srun are part of the controller network
+	network, _ = (&NetworkCheckConfig{ControllerAddress: "10.20.0.5", ControllerCIDR: "10.20.0.9/24"}).ControllerNetwork()
+	assert.Equal(t, "10.20.0.0/24", network.String())
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
+)
+
+// NodeBootstrapConfig installs and starts slurmd from user data, so burst nodes
+// can boot stock AMIs. The distribution's packages are used, detected from the
+// launch template AMI unless Distro names it. Each node gets the munge key
+// from munge_key and its configuration from the controller (configless Slurm).
+type NodeBootstrapConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	Distro         string `mapstructure:"distro"`          // "al2023", "ubuntu22.04" or "rocky9"; empty detects it
+	ConfServer     string `mapstructure:"conf_server"`     // slurmctld host[:port]
+	InstallCommand string `mapstructure:"install_command"` // Installs slurmd and munge instead of distro packages
+}
+
+// bootstrapValuePattern keeps values written into bootstrap scripts to plain
+// host names, ports and parameter paths
+var bootstrapValuePattern = regexp.MustCompile(`^[A-Za-z0-9._:/-]+$`)
+
+// validateNodeBootstrap checks the distribution and the values the bootstrap
+// scripts need. Installed munge has no key, so munge_key must deliver one.
+func validateNodeBootstrap(bootstrap *NodeBootstrapConfig, mungeKey *MungeKeyConfig) error {
+	if !bootstrap.Enabled {
+		return nil
+	}
+	if bootstrap.Distro != "" {
+		if _, err := userdata.ParseDistro(bootstrap.Distro); err != nil {
+			return fmt.Errorf("node_bootstrap.distro: %w", err)
+		}
+	}
+	if !bootstrapValuePattern.MatchString(bootstrap.ConfServer) {
+		return fmt.Errorf("node_bootstrap.conf_server must be the controller's host[:port], got %q", bootstrap.ConfServer)
+	}
+	if !mungeKey.Enabled() {
+		return fmt.Errorf("node_bootstrap needs munge_key.source so bootstrapped nodes get the controller's munge key")
+	}
+	return nil
+}
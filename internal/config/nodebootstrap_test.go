@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateNodeBootstrap(t *testing.T) {
+	mungeKey := MungeKeyConfig{Source: MungeKeySourceSSM, Parameter: "/slurm/munge-key"}
+	assert.NoError(t, validateNodeBootstrap(&NodeBootstrapConfig{}, &MungeKeyConfig{}), "disabled")
+
+	valid := NodeBootstrapConfig{Enabled: true, ConfServer: "head-node:6817"}
+	assert.NoError(t, validateNodeBootstrap(&valid, &mungeKey))
+	valid.Distro = "rocky9"
+	assert.NoError(t, validateNodeBootstrap(&valid, &mungeKey))
+	assert.ErrorContains(t, validateNodeBootstrap(&valid, &MungeKeyConfig{}), "munge_key.source", "bootstrapped munge has no key")
+
+	for _, invalid := range []NodeBootstrapConfig{
+		{Enabled: true},
+		{Enabled: true, ConfServer: "head-node; reboot"},
+		{Enabled: true, ConfServer: "head-node", Distro: "centos7"},
+	} {
+		assert.Error(t, validateNodeBootstrap(&invalid, &mungeKey), "%+v", invalid)
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// Node group settings shared by many node groups are declared once in
+// slurm.node_group_defaults, and per partition in its node_group_defaults.
+// Each node group is deep-merged over its partition's defaults, which are
+// merged over the global ones: nested settings such as warm_pool merge key by
+// key, while lists such as subnet_ids and tags replace the defaults whole.
+// The merged node groups are validated like any other.
+const nodeGroupDefaultsKey = "node_group_defaults"
+
+// applyNodeGroupDefaults merges the node group defaults of the configuration
+// read into v into its node groups and unmarshals the merged partitions into
+// slurm. It leaves slurm as it is when there are no defaults.
+func applyNodeGroupDefaults(v *viper.Viper, slurm *SlurmConfig) error {
+	global, err := defaultsMap(v.Get("slurm."+nodeGroupDefaultsKey), "slurm."+nodeGroupDefaultsKey)
+	if err != nil {
+		return err
+	}
+	rawPartitions, ok := v.Get("slurm.partitions").([]interface{})
+	if !ok {
+		return nil
+	}
+
+	merged := false
+	partitions := make([]interface{}, len(rawPartitions))
+	for i, rawPartition := range rawPartitions {
+		partitions[i] = rawPartition
+		partition, ok := rawPartition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		prefix := fmt.Sprintf("partitions[%d]", i)
+		local, err := defaultsMap(partition[nodeGroupDefaultsKey], prefix+"."+nodeGroupDefaultsKey)
+		if err != nil {
+			return err
+		}
+		defaults := deepMerge(global, local)
+		if defaults == nil {
+			continue
+		}
+
+		rawNodeGroups, _ := partition["node_groups"].([]interface{})
+		nodeGroups := make([]interface{}, len(rawNodeGroups))
+		for j, rawNodeGroup := range rawNodeGroups {
+			nodeGroup, ok := rawNodeGroup.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s.node_groups[%d] must be a mapping", prefix, j)
+			}
+			nodeGroups[j] = deepMerge(defaults, nodeGroup)
+		}
+
+		copied := deepMerge(partition, nil)
+		copied["node_groups"] = nodeGroups
+		delete(copied, nodeGroupDefaultsKey)
+		partitions[i] = copied
+		merged = true
+	}
+
+	if !merged {
+		return nil
+	}
+	// A viper of its own decodes the merged partitions as v would, without
+	// overriding v for later loads
+	decoder := viper.New()
+	decoder.Set("partitions", partitions)
+	slurm.Partitions = nil
+	if err := decoder.UnmarshalKey("partitions", &slurm.Partitions); err != nil {
+		return fmt.Errorf("failed to unmarshal merged node groups: %w", err)
+	}
+	return nil
+}
+
+// defaultsMap checks that node group defaults are a mapping that names no node
+// group; it returns nil when there are none
+func defaultsMap(raw interface{}, key string) (map[string]interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	defaults, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be a mapping of node group settings", key)
+	}
+	if _, ok := defaults["node_group_name"]; ok {
+		return nil, fmt.Errorf("%s cannot set node_group_name", key)
+	}
+	return defaults, nil
+}
+
+// deepMerge returns a copy of base with overlay merged over it. Mappings
+// present in both are merged recursively; any other overlay value replaces
+// the base value. It returns nil when both are empty.
+func deepMerge(base, overlay map[string]interface{}) map[string]interface{} {
+	if len(base) == 0 && len(overlay) == 0 {
+		return nil
+	}
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overlay {
+		overlayMap, overlayIsMap := value.(map[string]interface{})
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		if overlayIsMap && baseIsMap {
+			merged[key] = deepMerge(baseMap, overlayMap)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const nodeGroupDefaultsConfig = `
+aws:
+  region: us-east-1
+slurm:
+  node_group_defaults:
+    region: us-east-1
+    purchasing_option: spot
+    subnet_ids: [subnet-a, subnet-b]
+    security_group_ids: [sg-slurm]
+    tags:
+      - key: Project
+        value: hpc
+    warm_pool:
+      warmup_minutes: 15
+  partitions:
+    - partition_name: aws
+      node_group_defaults:
+        warm_pool:
+          size: 2
+      node_groups:
+        - node_group_name: cpu
+          max_nodes: 10
+          launch_template_overrides:
+            - instance_type: c5.large
+        - node_group_name: gpu
+          max_nodes: 2
+          purchasing_option: on-demand
+          subnet_ids: [subnet-gpu]
+          warm_pool:
+            size: 1
+          launch_template_overrides:
+            - instance_type: g5.xlarge
+    - partition_name: mem
+      node_groups:
+        - node_group_name: big
+          max_nodes: 4
+          launch_template_overrides:
+            - instance_type: r6i.4xlarge
+`
+
+func loadTestConfig(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return Load(path)
+}
+
+func TestNodeGroupDefaults(t *testing.T) {
+	cfg, err := loadTestConfig(t, nodeGroupDefaultsConfig)
+	require.NoError(t, err)
+
+	cpu := cfg.FindNodeGroup("aws", "cpu")
+	require.NotNil(t, cpu)
+	assert.Equal(t, 10, cpu.MaxNodes)
+	assert.Equal(t, "spot", cpu.PurchasingOption)
+	assert.Equal(t, []string{"subnet-a", "subnet-b"}, cpu.SubnetIds)
+	assert.Equal(t, []string{"sg-slurm"}, cpu.SecurityGroupIds)
+	assert.Equal(t, []AWSTag{{Key: "Project", Value: "hpc"}}, cpu.Tags)
+	assert.Equal(t, WarmPoolConfig{Size: 2, WarmupMinutes: 15, MaxAgeHours: 168}, cpu.WarmPool, "partition defaults merge over global ones")
+
+	gpu := cfg.FindNodeGroup("aws", "gpu")
+	require.NotNil(t, gpu)
+	assert.Equal(t, "on-demand", gpu.PurchasingOption)
+	assert.Equal(t, []string{"subnet-gpu"}, gpu.SubnetIds, "lists replace the defaults")
+	assert.Equal(t, 1, gpu.WarmPool.Size)
+	assert.Equal(t, 15, gpu.WarmPool.WarmupMinutes, "mappings merge key by key")
+
+	big := cfg.FindNodeGroup("mem", "big")
+	require.NotNil(t, big)
+	assert.Equal(t, "us-east-1", big.Region)
+	assert.Zero(t, big.WarmPool.Size)
+}
+
+func TestNodeGroupDefaultsInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"not a mapping": `
+aws:
+  region: us-east-1
+slurm:
+  node_group_defaults: [spot]
+  partitions:
+    - partition_name: aws
+      node_groups:
+        - node_group_name: cpu
+          max_nodes: 1
+`,
+		"names a node group": `
+aws:
+  region: us-east-1
+slurm:
+  partitions:
+    - partition_name: aws
+      node_group_defaults:
+        node_group_name: cpu
+      node_groups:
+        - max_nodes: 1
+`,
+		"merged result": `
+aws:
+  region: us-east-1
+slurm:
+  node_group_defaults:
+    purchasing_option: reserved
+  partitions:
+    - partition_name: aws
+      node_groups:
+        - node_group_name: cpu
+          max_nodes: 1
+          region: us-east-1
+          launch_template_overrides:
+            - instance_type: c5.large
+`,
+	} {
+		_, err := loadTestConfig(t, content)
+		assert.Error(t, err, name)
+	}
+}
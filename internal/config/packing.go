@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Cost share bases for packed node groups
+const (
+	CostShareDominant = "dominant" // The larger of the job's CPU and memory fractions
+	CostShareCPU      = "cpu"
+	CostShareMemory   = "memory"
+)
+
+// JobPackingConfig sizes a node group's instances for several jobs per node,
+// for partitions whose slurm.conf allocates cores and memory rather than
+// whole nodes (SelectType=select/cons_tres with CR_Core_Memory and no
+// OverSubscribe=EXCLUSIVE). Each job running on an instance is charged the
+// share of it that its CPUs or memory took.
+type JobPackingConfig struct {
+	JobsPerNode int    `mapstructure:"jobs_per_node"` // Jobs each instance is sized for; 0 or 1 disables packing
+	CostShare   string `mapstructure:"cost_share"`    // "dominant" (default), "cpu" or "memory"
+}
+
+// Enabled reports whether the node group's instances host several jobs
+func (p JobPackingConfig) Enabled() bool {
+	return p.JobsPerNode > 1
+}
+
+// Basis returns how a shared instance's cost is split between its jobs
+func (p JobPackingConfig) Basis() string {
+	if p.CostShare == "" {
+		return CostShareDominant
+	}
+	return p.CostShare
+}
+
+// validateJobPacking checks a node group's job packing
+func validateJobPacking(nodeGroup NodeGroupConfig, partitionIndex, nodeGroupIndex int) error {
+	prefix := fmt.Sprintf("partitions[%d].node_groups[%d].job_packing", partitionIndex, nodeGroupIndex)
+	packing := nodeGroup.JobPacking
+	if packing.JobsPerNode < 0 {
+		return fmt.Errorf("%s.jobs_per_node cannot be negative", prefix)
+	}
+	if !slices.Contains([]string{"", CostShareDominant, CostShareCPU, CostShareMemory}, packing.CostShare) {
+		return fmt.Errorf("%s.cost_share must be 'dominant', 'cpu' or 'memory'", prefix)
+	}
+	if packing.CostShare != "" && !packing.Enabled() {
+		return fmt.Errorf("%s.cost_share requires jobs_per_node above 1", prefix)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateJobPacking(t *testing.T) {
+	assert.NoError(t, validateJobPacking(NodeGroupConfig{}, 0, 0), "disabled")
+	assert.NoError(t, validateJobPacking(NodeGroupConfig{JobPacking: JobPackingConfig{JobsPerNode: 1}}, 0, 0), "one job per node")
+
+	packed := NodeGroupConfig{JobPacking: JobPackingConfig{JobsPerNode: 4}}
+	assert.NoError(t, validateJobPacking(packed, 0, 0))
+	assert.True(t, packed.JobPacking.Enabled())
+	assert.Equal(t, CostShareDominant, packed.JobPacking.Basis())
+
+	packed.JobPacking.CostShare = CostShareMemory
+	assert.NoError(t, validateJobPacking(packed, 0, 0))
+	assert.Equal(t, CostShareMemory, packed.JobPacking.Basis())
+
+	for _, invalid := range []JobPackingConfig{
+		{JobsPerNode: -1},
+		{JobsPerNode: 4, CostShare: "gpu"},
+		{CostShare: CostShareCPU},
+	} {
+		assert.Error(t, validateJobPacking(NodeGroupConfig{JobPacking: invalid}, 0, 0), "%+v", invalid)
+	}
+}
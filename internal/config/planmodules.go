@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// What resume does when a plan module fails or answers nonsense
+const (
+	PlanModuleOnErrorAccept = "accept" // Launch the plan as it was before the module
+	PlanModuleOnErrorVeto   = "veto"   // Refuse the launch
+)
+
+// DefaultPlanModuleTimeoutSeconds bounds a module without timeout_seconds;
+// resume waits for it before launching
+const DefaultPlanModuleTimeoutSeconds = 10
+
+// PlanModuleConfig is an external decision module run on each standalone
+// execution plan before launch, such as a campus fair-share or data-locality
+// policy. It gets the plan and the job as JSON on stdin and answers on stdout
+// whether to accept, modify or veto the plan. Modules run in order, each on
+// the plan the previous one returned.
+type PlanModuleConfig struct {
+	Name           string   `mapstructure:"name"`
+	Command        string   `mapstructure:"command"`         // Run through the shell
+	Partitions     []string `mapstructure:"partitions"`      // Only plans for these partitions; empty for all
+	TimeoutSeconds int      `mapstructure:"timeout_seconds"` // Longest run per plan
+	OnError        string   `mapstructure:"on_error"`        // accept (default) or veto
+}
+
+// Timeout bounds one run of the module
+func (m *PlanModuleConfig) Timeout() time.Duration {
+	return time.Duration(m.TimeoutSeconds) * time.Second
+}
+
+// Applies reports whether the module decides plans for partition
+func (m *PlanModuleConfig) Applies(partition string) bool {
+	return len(m.Partitions) == 0 || slices.Contains(m.Partitions, partition)
+}
+
+// validatePlanModules checks that each module has a unique name and a
+// command, and defaults the timeout and error handling
+func validatePlanModules(modules []PlanModuleConfig) error {
+	names := make(map[string]bool, len(modules))
+	for i := range modules {
+		module := &modules[i]
+		if module.Name == "" {
+			return fmt.Errorf("plan_modules[%d].name is required", i)
+		}
+		if names[module.Name] {
+			return fmt.Errorf("plan_modules: duplicate module name %q", module.Name)
+		}
+		names[module.Name] = true
+
+		if module.Command == "" {
+			return fmt.Errorf("plan_modules.%s.command is required", module.Name)
+		}
+		if module.TimeoutSeconds == 0 {
+			module.TimeoutSeconds = DefaultPlanModuleTimeoutSeconds
+		}
+		if module.TimeoutSeconds < 0 {
+			return fmt.Errorf("plan_modules.%s.timeout_seconds must be positive, got %d", module.Name, module.TimeoutSeconds)
+		}
+		if module.OnError == "" {
+			module.OnError = PlanModuleOnErrorAccept
+		}
+		if module.OnError != PlanModuleOnErrorAccept && module.OnError != PlanModuleOnErrorVeto {
+			return fmt.Errorf("plan_modules.%s.on_error must be %s or %s, got %q",
+				module.Name, PlanModuleOnErrorAccept, PlanModuleOnErrorVeto, module.OnError)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePlanModules(t *testing.T) {
+	modules := []PlanModuleConfig{
+		{Name: "fairshare", Command: "/usr/local/libexec/fairshare"},
+		{Name: "locality", Command: "/usr/local/libexec/locality", Partitions: []string{"gpu"}, TimeoutSeconds: 3, OnError: PlanModuleOnErrorVeto},
+	}
+	require.NoError(t, validatePlanModules(modules))
+	assert.Equal(t, DefaultPlanModuleTimeoutSeconds, modules[0].TimeoutSeconds)
+	assert.Equal(t, PlanModuleOnErrorAccept, modules[0].OnError)
+	assert.True(t, modules[0].Applies("cpu"))
+	assert.True(t, modules[1].Applies("gpu"))
+	assert.False(t, modules[1].Applies("cpu"))
+
+	assert.ErrorContains(t, validatePlanModules([]PlanModuleConfig{{Command: "true"}}), "plan_modules[0].name is required")
+	assert.ErrorContains(t, validatePlanModules([]PlanModuleConfig{{Name: "a"}}), "plan_modules.a.command is required")
+	assert.ErrorContains(t, validatePlanModules([]PlanModuleConfig{{Name: "a", Command: "true", OnError: "retry"}}), "on_error must be accept or veto")
+	assert.ErrorContains(t, validatePlanModules([]PlanModuleConfig{{Name: "a", Command: "true", TimeoutSeconds: -1}}), "timeout_seconds must be positive")
+	assert.ErrorContains(t, validatePlanModules([]PlanModuleConfig{{Name: "a", Command: "true"}, {Name: "a", Command: "true"}}), `duplicate module name "a"`)
+}
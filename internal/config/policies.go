@@ -0,0 +1,199 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PoliciesConfig sets institutional defaults for the jobs of Slurm users and
+// accounts, which resume enforces in ASBA and standalone modes alike. A job
+// takes the first rule naming its user, or else the first naming its
+// account, or else the first whose association selectors matched its user
+// or account at the last sync; jobs no rule names burst unrestricted.
+type PoliciesConfig struct {
+	Rules []PolicyConfig   `mapstructure:"rules"`
+	Sync  PolicySyncConfig `mapstructure:"sync"`
+
+	synced map[string]PolicyIdentities // By rule name, from the last sync
+}
+
+// PolicyConfig is what the jobs of some users or accounts may burst to
+type PolicyConfig struct {
+	Name              string   `mapstructure:"name"`
+	Users             []string `mapstructure:"users"`
+	Accounts          []string `mapstructure:"accounts"`
+	QOS               []string `mapstructure:"qos"`                 // Users whose associations may use these QOS, once synced
+	ParentAccounts    []string `mapstructure:"parent_accounts"`     // These accounts and those under them in the association tree, once synced
+	MonthlyCeilingUSD float64  `mapstructure:"monthly_ceiling_usd"` // Each named user or account; zero for none
+	NodeGroups        []string `mapstructure:"node_groups"`         // "partition/nodegroup" or "partition/*"; empty for all
+	PurchasingOptions []string `mapstructure:"purchasing_options"`  // In order of preference; empty for all
+	MaxNodes          int      `mapstructure:"max_nodes"`           // Per job; zero for no limit
+}
+
+// Policy sync sources
+const (
+	PolicySyncSacctmgr = "sacctmgr" // Slurm's association database
+	PolicySyncCommand  = "command"  // A site command, e.g. an LDAP query
+)
+
+// PolicySyncConfig resolves the association selectors of policy rules, qos
+// and parent_accounts, to users and accounts. The state manager re-reads the
+// associations every interval and writes what each rule selects to a state
+// file that resume reads.
+type PolicySyncConfig struct {
+	Enabled         bool   `mapstructure:"enabled"`
+	Source          string `mapstructure:"source"`           // sacctmgr (default) or command
+	Command         string `mapstructure:"command"`          // Prints Account|User|ParentName|QOS lines, as sacctmgr --parsable2 does
+	IntervalMinutes int    `mapstructure:"interval_minutes"` // How often the state manager syncs
+	StateFile       string `mapstructure:"state_file"`
+}
+
+// PolicyIdentities are the users and accounts a rule's association
+// selectors resolved to
+type PolicyIdentities struct {
+	Users    []string `json:"users,omitempty"`
+	Accounts []string `json:"accounts,omitempty"`
+}
+
+// SetSynced sets the users and accounts each rule's association selectors
+// resolved to at the last sync, by rule name
+func (p *PoliciesConfig) SetSynced(synced map[string]PolicyIdentities) {
+	p.synced = synced
+}
+
+// For returns the rule for a job of the user in the account, or nil
+func (p *PoliciesConfig) For(user, account string) *PolicyConfig {
+	matches := []func(rule *PolicyConfig) bool{
+		func(rule *PolicyConfig) bool { return user != "" && slices.Contains(rule.Users, user) },
+		func(rule *PolicyConfig) bool { return account != "" && slices.Contains(rule.Accounts, account) },
+		func(rule *PolicyConfig) bool { return user != "" && slices.Contains(p.synced[rule.Name].Users, user) },
+		func(rule *PolicyConfig) bool {
+			return account != "" && slices.Contains(p.synced[rule.Name].Accounts, account)
+		},
+	}
+	for _, match := range matches {
+		for i := range p.Rules {
+			if match(&p.Rules[i]) {
+				return &p.Rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+// AllowsNodeGroup reports whether the rule's jobs may burst to the node group
+func (p *PolicyConfig) AllowsNodeGroup(partition, nodeGroup string) bool {
+	return len(p.NodeGroups) == 0 ||
+		slices.Contains(p.NodeGroups, partition+"/"+nodeGroup) ||
+		slices.Contains(p.NodeGroups, partition+"/*")
+}
+
+// AllowsPurchasing reports whether the rule's jobs may buy capacity this way
+func (p *PolicyConfig) AllowsPurchasing(option string) bool {
+	return len(p.PurchasingOptions) == 0 || slices.Contains(p.PurchasingOptions, option)
+}
+
+// SpendLimits returns the ceilings resume checks and export records spend
+// for: costs.ceilings.limits, and the monthly ceiling of each user and
+// account a policy rule names or synced that no limit already caps
+func (c *Config) SpendLimits() []CeilingConfig {
+	limits := slices.Clone(c.Costs.Ceilings.Limits)
+	capped := func(ceiling CeilingConfig) bool {
+		return slices.ContainsFunc(limits, func(limit CeilingConfig) bool {
+			return limit.Partition == "" && limit.Account == ceiling.Account && limit.User == ceiling.User
+		})
+	}
+	for _, rule := range c.Policies.Rules {
+		if rule.MonthlyCeilingUSD <= 0 {
+			continue
+		}
+		synced := c.Policies.synced[rule.Name]
+		for _, user := range slices.Concat(rule.Users, synced.Users) {
+			if ceiling := (CeilingConfig{User: user, MonthlyUSD: rule.MonthlyCeilingUSD}); !capped(ceiling) {
+				limits = append(limits, ceiling)
+			}
+		}
+		for _, account := range slices.Concat(rule.Accounts, synced.Accounts) {
+			if ceiling := (CeilingConfig{Account: account, MonthlyUSD: rule.MonthlyCeilingUSD}); !capped(ceiling) {
+				limits = append(limits, ceiling)
+			}
+		}
+	}
+	return limits
+}
+
+// validatePolicies checks the sync that association selectors need, and that
+// each rule is named, selects users or accounts, names none another rule
+// does, and limits jobs to configured node groups and known purchasing options
+func validatePolicies(config *Config) error {
+	sync := &config.Policies.Sync
+	if sync.Enabled {
+		switch sync.Source {
+		case PolicySyncSacctmgr:
+		case PolicySyncCommand:
+			if sync.Command == "" {
+				return fmt.Errorf("policies.sync.command is required for the command source")
+			}
+		default:
+			return fmt.Errorf("policies.sync.source must be %s or %s, got %q", PolicySyncSacctmgr, PolicySyncCommand, sync.Source)
+		}
+		if sync.IntervalMinutes <= 0 {
+			return fmt.Errorf("policies.sync.interval_minutes must be positive")
+		}
+		if !filepath.IsAbs(sync.StateFile) {
+			return fmt.Errorf("policies.sync.state_file must be an absolute path, got %q", sync.StateFile)
+		}
+	}
+
+	names := make(map[string]bool)
+	users := make(map[string]string)
+	accounts := make(map[string]string)
+	for i, rule := range config.Policies.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("policies.rules[%d].name is required", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("policies.rules: duplicate rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+		prefix := "policies.rules." + rule.Name
+
+		selectsByAssociation := len(rule.QOS) > 0 || len(rule.ParentAccounts) > 0
+		if len(rule.Users) == 0 && len(rule.Accounts) == 0 && !selectsByAssociation {
+			return fmt.Errorf("%s must name users or accounts, or select them by qos or parent_accounts", prefix)
+		}
+		if selectsByAssociation && !sync.Enabled {
+			return fmt.Errorf("%s selects by qos or parent_accounts, which needs policies.sync.enabled", prefix)
+		}
+		for _, user := range rule.Users {
+			if other, ok := users[user]; ok {
+				return fmt.Errorf("%s.users: %q is already in rule %s", prefix, user, other)
+			}
+			users[user] = rule.Name
+		}
+		for _, account := range rule.Accounts {
+			if other, ok := accounts[account]; ok {
+				return fmt.Errorf("%s.accounts: %q is already in rule %s", prefix, account, other)
+			}
+			accounts[account] = rule.Name
+		}
+
+		for _, nodeGroup := range rule.NodeGroups {
+			partition, group, ok := strings.Cut(nodeGroup, "/")
+			if !ok || config.FindPartition(partition) == nil || group != "*" && config.FindNodeGroup(partition, group) == nil {
+				return fmt.Errorf("%s.node_groups: %q is not a configured partition/nodegroup", prefix, nodeGroup)
+			}
+		}
+		for _, option := range rule.PurchasingOptions {
+			if !slices.Contains(PurchasingOptions, option) {
+				return fmt.Errorf("%s.purchasing_options must be from %v, got %q", prefix, PurchasingOptions, option)
+			}
+		}
+		if rule.MonthlyCeilingUSD < 0 || rule.MaxNodes < 0 {
+			return fmt.Errorf("%s monthly_ceiling_usd and max_nodes must not be negative", prefix)
+		}
+	}
+	return nil
+}
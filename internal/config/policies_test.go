@@ -0,0 +1,76 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicies(t *testing.T) {
+	cfg := &Config{Slurm: SlurmConfig{Partitions: []PartitionConfig{
+		{PartitionName: "aws", NodeGroups: []NodeGroupConfig{{NodeGroupName: "cpu"}, {NodeGroupName: "gpu"}}},
+	}}}
+	cfg.Costs.Ceilings.Limits = []CeilingConfig{{Partition: "aws", MonthlyUSD: 5000}, {Account: "chem", MonthlyUSD: 800}}
+	cfg.Policies.Rules = []PolicyConfig{
+		{Name: "students", Accounts: []string{"chem", "bio"}, MonthlyCeilingUSD: 200, NodeGroups: []string{"aws/cpu"}, PurchasingOptions: []string{"spot"}, MaxNodes: 4},
+		{Name: "faculty", Users: []string{"alice"}, MonthlyCeilingUSD: 1000, NodeGroups: []string{"aws/*"}},
+	}
+	assert.NoError(t, validatePolicies(cfg))
+
+	assert.Equal(t, "faculty", cfg.Policies.For("alice", "chem").Name, "user rules come first")
+	assert.Equal(t, "students", cfg.Policies.For("bob", "bio").Name)
+	assert.Nil(t, cfg.Policies.For("bob", "physics"))
+
+	students, faculty := &cfg.Policies.Rules[0], &cfg.Policies.Rules[1]
+	assert.True(t, students.AllowsNodeGroup("aws", "cpu"))
+	assert.False(t, students.AllowsNodeGroup("aws", "gpu"))
+	assert.True(t, faculty.AllowsNodeGroup("aws", "gpu"))
+	assert.False(t, students.AllowsPurchasing("on-demand"))
+	assert.True(t, faculty.AllowsPurchasing("on-demand"))
+
+	assert.Equal(t, []CeilingConfig{
+		{Partition: "aws", MonthlyUSD: 5000},
+		{Account: "chem", MonthlyUSD: 800},
+		{Account: "bio", MonthlyUSD: 200},
+		{User: "alice", MonthlyUSD: 1000},
+	}, cfg.SpendLimits(), "costs.ceilings.limits win over rule ceilings")
+
+	for name, rule := range map[string]PolicyConfig{
+		"unnamed":            {Accounts: []string{"physics"}},
+		"duplicate name":     {Name: "students", Accounts: []string{"physics"}},
+		"nobody":             {Name: "empty"},
+		"account twice":      {Name: "again", Accounts: []string{"bio"}},
+		"user twice":         {Name: "again", Users: []string{"alice"}},
+		"unknown node group": {Name: "gpu", Accounts: []string{"physics"}, NodeGroups: []string{"aws/hpc"}},
+		"bare partition":     {Name: "gpu", Accounts: []string{"physics"}, NodeGroups: []string{"aws"}},
+		"purchasing option":  {Name: "gpu", Accounts: []string{"physics"}, PurchasingOptions: []string{"reserved"}},
+		"negative max nodes": {Name: "gpu", Accounts: []string{"physics"}, MaxNodes: -1},
+	} {
+		invalid := *cfg
+		invalid.Policies.Rules = append(append([]PolicyConfig{}, cfg.Policies.Rules...), rule)
+		assert.Error(t, validatePolicies(&invalid), name)
+	}
+}
+
+func TestValidatePolicySync(t *testing.T) {
+	byQOS := PolicyConfig{Name: "students", QOS: []string{"student"}}
+	cfg := &Config{}
+	cfg.Policies.Rules = []PolicyConfig{byQOS}
+	assert.Error(t, validatePolicies(cfg), "association selectors need sync")
+
+	valid := PolicySyncConfig{Enabled: true, Source: PolicySyncSacctmgr, IntervalMinutes: 60, StateFile: "/var/spool/aws-slurm-burst/policy-identities.json"}
+	cfg.Policies.Sync = valid
+	assert.NoError(t, validatePolicies(cfg))
+
+	for name, modify := range map[string]func(sync *PolicySyncConfig){
+		"unknown source":      func(sync *PolicySyncConfig) { sync.Source = "ldap" },
+		"command source":      func(sync *PolicySyncConfig) { sync.Source = PolicySyncCommand },
+		"no interval":         func(sync *PolicySyncConfig) { sync.IntervalMinutes = 0 },
+		"relative state file": func(sync *PolicySyncConfig) { sync.StateFile = "identities.json" },
+	} {
+		invalid := *cfg
+		invalid.Policies.Sync = valid
+		modify(&invalid.Policies.Sync)
+		assert.Error(t, validatePolicies(&invalid), name)
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// PurchasingPolicyConfig maps the priority of standalone jobs to how their
+// capacity is bought. A job's priority comes from its Slurm QOS; jobs in no
+// listed QOS are normal priority. ASBA plans set their own purchasing.
+type PurchasingPolicyConfig struct {
+	Enabled    bool                       `mapstructure:"enabled"`
+	Priorities []PriorityPurchasingConfig `mapstructure:"priorities"` // Replaces DefaultPriorityPurchasing when set
+}
+
+// PriorityPurchasingConfig is how the capacity of one priority is bought
+type PriorityPurchasingConfig struct {
+	Priority             string   `mapstructure:"priority"`              // One of types.Priorities
+	QOS                  []string `mapstructure:"qos"`                   // QOS whose jobs have this priority
+	PurchasingOption     string   `mapstructure:"purchasing_option"`     // "on-demand", "mixed" or "spot"
+	CapacityReservations bool     `mapstructure:"capacity_reservations"` // Use open capacity reservations first for on-demand capacity
+	MaxSpotPrice         float64  `mapstructure:"max_spot_price"`        // USD per instance-hour; 0 keeps the node group's cap
+	QueueForCapacity     bool     `mapstructure:"queue_for_capacity"`    // All spot with no on-demand fallback; the job waits for capacity
+}
+
+// PurchasingOptions are the purchasing options a priority may use
+var PurchasingOptions = []string{"on-demand", "mixed", "spot"}
+
+// DefaultPriorityPurchasing returns the built-in policy used when
+// purchasing_policy.priorities is not configured: urgent jobs run on demand in
+// capacity reservations, normal jobs mix spot and on-demand, and low jobs wait
+// for spot capacity.
+func DefaultPriorityPurchasing() []PriorityPurchasingConfig {
+	return []PriorityPurchasingConfig{
+		{Priority: types.PriorityUrgent, QOS: []string{"urgent"}, PurchasingOption: "on-demand", CapacityReservations: true},
+		{Priority: types.PriorityNormal, PurchasingOption: "mixed"},
+		{Priority: types.PriorityLow, QOS: []string{"low", "scavenger"}, PurchasingOption: "spot", QueueForCapacity: true},
+	}
+}
+
+// EffectivePriorities returns the configured priorities, or the built-in ones
+// when none are configured
+func (p *PurchasingPolicyConfig) EffectivePriorities() []PriorityPurchasingConfig {
+	if len(p.Priorities) > 0 {
+		return p.Priorities
+	}
+	return DefaultPriorityPurchasing()
+}
+
+// ForQOS returns how capacity is bought for a job in the QOS: the priority
+// listing the QOS, or else the normal priority. It returns nil when the policy
+// is disabled or sets no purchasing for the job's priority.
+func (p *PurchasingPolicyConfig) ForQOS(qos string) *PriorityPurchasingConfig {
+	if !p.Enabled {
+		return nil
+	}
+	priorities := p.EffectivePriorities()
+	priority := types.PriorityNormal
+	for _, candidate := range priorities {
+		if qos != "" && slices.Contains(candidate.QOS, qos) {
+			priority = candidate.Priority
+			break
+		}
+	}
+	for i := range priorities {
+		if priorities[i].Priority == priority {
+			return &priorities[i]
+		}
+	}
+	return nil
+}
+
+// validatePurchasingPolicy checks each priority's purchasing and that
+// priorities and their QOS are listed once
+func validatePurchasingPolicy(policy *PurchasingPolicyConfig) error {
+	priorities := make(map[string]bool)
+	qosPriority := make(map[string]string)
+	for i, priority := range policy.Priorities {
+		prefix := fmt.Sprintf("purchasing_policy.priorities[%d]", i)
+		if !slices.Contains(types.Priorities, priority.Priority) {
+			return fmt.Errorf("%s.priority must be one of %v, got %q", prefix, types.Priorities, priority.Priority)
+		}
+		if priorities[priority.Priority] {
+			return fmt.Errorf("%s.priority %q is listed twice", prefix, priority.Priority)
+		}
+		priorities[priority.Priority] = true
+		for _, qos := range priority.QOS {
+			if other, ok := qosPriority[qos]; ok {
+				return fmt.Errorf("%s.qos %q is already %s priority", prefix, qos, other)
+			}
+			qosPriority[qos] = priority.Priority
+		}
+
+		if !slices.Contains(PurchasingOptions, priority.PurchasingOption) {
+			return fmt.Errorf("%s.purchasing_option must be one of %v, got %q", prefix, PurchasingOptions, priority.PurchasingOption)
+		}
+		if priority.CapacityReservations && priority.PurchasingOption == "spot" {
+			return fmt.Errorf("%s.capacity_reservations needs on-demand capacity", prefix)
+		}
+		if priority.QueueForCapacity && priority.PurchasingOption != "spot" {
+			return fmt.Errorf("%s.queue_for_capacity is only for spot purchasing", prefix)
+		}
+		if priority.MaxSpotPrice < 0 {
+			return fmt.Errorf("%s.max_spot_price must not be negative", prefix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurchasingPolicyForQOS(t *testing.T) {
+	policy := PurchasingPolicyConfig{}
+	assert.Nil(t, policy.ForQOS("urgent"), "disabled")
+
+	policy.Enabled = true
+	require.NoError(t, validatePurchasingPolicy(&PurchasingPolicyConfig{Priorities: DefaultPriorityPurchasing()}))
+
+	urgent := policy.ForQOS("urgent")
+	require.NotNil(t, urgent)
+	assert.Equal(t, types.PriorityUrgent, urgent.Priority)
+	assert.Equal(t, "on-demand", urgent.PurchasingOption)
+	assert.True(t, urgent.CapacityReservations)
+
+	low := policy.ForQOS("scavenger")
+	require.NotNil(t, low)
+	assert.Equal(t, "spot", low.PurchasingOption)
+	assert.True(t, low.QueueForCapacity)
+
+	assert.Equal(t, types.PriorityNormal, policy.ForQOS("").Priority)
+	assert.Equal(t, "mixed", policy.ForQOS("standard").PurchasingOption, "unlisted QOS are normal priority")
+
+	policy.Priorities = []PriorityPurchasingConfig{{Priority: types.PriorityLow, QOS: []string{"cheap"}, PurchasingOption: "spot", MaxSpotPrice: 0.05}}
+	assert.Equal(t, 0.05, policy.ForQOS("cheap").MaxSpotPrice)
+	assert.Nil(t, policy.ForQOS("normal"), "normal jobs keep the node group's purchasing")
+}
+
+func TestValidatePurchasingPolicy(t *testing.T) {
+	assert.NoError(t, validatePurchasingPolicy(&PurchasingPolicyConfig{}))
+
+	for _, priorities := range [][]PriorityPurchasingConfig{
+		{{Priority: "critical", PurchasingOption: "on-demand"}},
+		{{Priority: types.PriorityLow, PurchasingOption: "spot"}, {Priority: types.PriorityLow, PurchasingOption: "mixed"}},
+		{{Priority: types.PriorityLow, QOS: []string{"low"}, PurchasingOption: "spot"}, {Priority: types.PriorityUrgent, QOS: []string{"low"}, PurchasingOption: "on-demand"}},
+		{{Priority: types.PriorityNormal, PurchasingOption: "reserved"}},
+		{{Priority: types.PriorityLow, PurchasingOption: "spot", CapacityReservations: true}},
+		{{Priority: types.PriorityNormal, PurchasingOption: "mixed", QueueForCapacity: true}},
+		{{Priority: types.PriorityLow, PurchasingOption: "spot", MaxSpotPrice: -1}},
+	} {
+		assert.Error(t, validatePurchasingPolicy(&PurchasingPolicyConfig{Priorities: priorities}), "%+v", priorities)
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// maxEBSVolumeGB is the largest gp3, io2 or st1 volume EBS creates
+const maxEBSVolumeGB = 16384
+
+// scratchVolumeTypes are the EBS volume types scratch volumes may use
+var scratchVolumeTypes = []string{"gp2", "gp3", "io1", "io2", "st1"}
+
+// scratchDevicePattern matches a secondary block device name
+var scratchDevicePattern = regexp.MustCompile(`^/dev/(sd|xvd)[b-z]$`)
+
+// StagingConfig honours the burst buffer directives of job scripts (#BB, #DW
+// and #ASBX). Each node of a job that asks for scratch launches with an EBS
+// volume of that size, mounted at ScratchMount; stage_in transfers run at
+// boot, before slurmd starts, and stage_out transfers run from the batch
+// host's node epilog. A job that asks for retention keeps its scratch volumes
+// that long after their nodes are gone; the state manager then deletes them.
+type StagingConfig struct {
+	Enabled                bool   `mapstructure:"enabled"`
+	ScratchMount           string `mapstructure:"scratch_mount"`            // Where nodes mount the job's scratch volume
+	VolumeType             string `mapstructure:"volume_type"`              // EBS volume type of scratch volumes
+	DeviceName             string `mapstructure:"device_name"`              // Block device scratch volumes attach as
+	MaxScratchGB           int    `mapstructure:"max_scratch_gb"`           // Larger requests are capped to this
+	MaxRetentionHours      int    `mapstructure:"max_retention_hours"`      // Longer retention is shortened to this
+	TransferTimeoutMinutes int    `mapstructure:"transfer_timeout_minutes"` // Per stage_in or stage_out pass
+}
+
+// validateStaging checks the mount point, the scratch volume settings and the limits
+func validateStaging(config *Config) error {
+	staging := &config.Staging
+	if !staging.Enabled {
+		return nil
+	}
+	if !filepath.IsAbs(staging.ScratchMount) || !bootstrapValuePattern.MatchString(staging.ScratchMount) || filepath.Clean(staging.ScratchMount) == "/" {
+		return fmt.Errorf("staging.scratch_mount must be an absolute directory, got %q", staging.ScratchMount)
+	}
+	if !slices.Contains(scratchVolumeTypes, staging.VolumeType) {
+		return fmt.Errorf("staging.volume_type must be one of %v, got %q", scratchVolumeTypes, staging.VolumeType)
+	}
+	if !scratchDevicePattern.MatchString(staging.DeviceName) {
+		return fmt.Errorf("staging.device_name must be a secondary device such as /dev/sdz, got %q", staging.DeviceName)
+	}
+	if staging.MaxScratchGB <= 0 || staging.MaxScratchGB > maxEBSVolumeGB {
+		return fmt.Errorf("staging.max_scratch_gb must be between 1 and %d", maxEBSVolumeGB)
+	}
+	if staging.MaxRetentionHours < 0 {
+		return fmt.Errorf("staging.max_retention_hours cannot be negative")
+	}
+	if staging.TransferTimeoutMinutes <= 0 {
+		return fmt.Errorf("staging.transfer_timeout_minutes must be positive")
+	}
+	return nil
+}
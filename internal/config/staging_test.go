@@ -0,0 +1,102 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateStaging(t *testing.T) {
+	tests := []struct {
+		name        string
+		staging     StagingConfig
+		expectError string
+	}{
+		{
+			name:    "disabled",
+			staging: StagingConfig{},
+		},
+		{
+			name: "valid staging",
+			staging: StagingConfig{
+				Enabled: true, ScratchMount: "/mnt/scratch", VolumeType: "gp3", DeviceName: "/dev/sdz",
+				MaxScratchGB: 4096, MaxRetentionHours: 168, TransferTimeoutMinutes: 60,
+			},
+		},
+		{
+			name: "relative mount",
+			staging: StagingConfig{
+				Enabled: true, ScratchMount: "scratch", VolumeType: "gp3", DeviceName: "/dev/sdz",
+				MaxScratchGB: 4096, MaxRetentionHours: 168, TransferTimeoutMinutes: 60,
+			},
+			expectError: `staging.scratch_mount must be an absolute directory, got "scratch"`,
+		},
+		{
+			name: "root mount",
+			staging: StagingConfig{
+				Enabled: true, ScratchMount: "/", VolumeType: "gp3", DeviceName: "/dev/sdz",
+				MaxScratchGB: 4096, MaxRetentionHours: 168, TransferTimeoutMinutes: 60,
+			},
+			expectError: `staging.scratch_mount must be an absolute directory, got "/"`,
+		},
+		{
+			name: "mount with spaces",
+			staging: StagingConfig{
+				Enabled: true, ScratchMount: "/mnt/job scratch", VolumeType: "gp3", DeviceName: "/dev/sdz",
+				MaxScratchGB: 4096, MaxRetentionHours: 168, TransferTimeoutMinutes: 60,
+			},
+			expectError: `staging.scratch_mount must be an absolute directory, got "/mnt/job scratch"`,
+		},
+		{
+			name: "unsupported volume type",
+			staging: StagingConfig{
+				Enabled: true, ScratchMount: "/mnt/scratch", VolumeType: "standard", DeviceName: "/dev/sdz",
+				MaxScratchGB: 4096, MaxRetentionHours: 168, TransferTimeoutMinutes: 60,
+			},
+			expectError: `staging.volume_type must be one of [gp2 gp3 io1 io2 st1], got "standard"`,
+		},
+		{
+			name: "root device",
+			staging: StagingConfig{
+				Enabled: true, ScratchMount: "/mnt/scratch", VolumeType: "gp3", DeviceName: "/dev/sda",
+				MaxScratchGB: 4096, MaxRetentionHours: 168, TransferTimeoutMinutes: 60,
+			},
+			expectError: `staging.device_name must be a secondary device such as /dev/sdz, got "/dev/sda"`,
+		},
+		{
+			name: "oversized scratch",
+			staging: StagingConfig{
+				Enabled: true, ScratchMount: "/mnt/scratch", VolumeType: "gp3", DeviceName: "/dev/sdz",
+				MaxScratchGB: 20000, MaxRetentionHours: 168, TransferTimeoutMinutes: 60,
+			},
+			expectError: "staging.max_scratch_gb must be between 1 and 16384",
+		},
+		{
+			name: "negative retention",
+			staging: StagingConfig{
+				Enabled: true, ScratchMount: "/mnt/scratch", VolumeType: "gp3", DeviceName: "/dev/sdz",
+				MaxScratchGB: 4096, MaxRetentionHours: -1, TransferTimeoutMinutes: 60,
+			},
+			expectError: "staging.max_retention_hours cannot be negative",
+		},
+		{
+			name: "no transfer timeout",
+			staging: StagingConfig{
+				Enabled: true, ScratchMount: "/mnt/scratch", VolumeType: "gp3", DeviceName: "/dev/sdz",
+				MaxScratchGB: 4096, MaxRetentionHours: 168,
+			},
+			expectError: "staging.transfer_timeout_minutes must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStaging(&Config{Staging: tt.staging})
+			if tt.expectError != "" {
+				assert.EqualError(t, err, tt.expectError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // Burst window timezones must load on hosts without a zoneinfo database
+)
+
+// BurstWindowsConfig restricts when resume bursts. A launch that any window
+// applies to bursts only inside one of those windows, and no launch bursts
+// during a blackout that applies to it. Jobs in an override QOS burst
+// regardless, as does resume --ignore-windows.
+type BurstWindowsConfig struct {
+	Timezone    string              `mapstructure:"timezone"`     // IANA zone the windows and blackouts are in; default the host's
+	OverrideQOS []string            `mapstructure:"override_qos"` // QOS of urgent jobs, e.g. ["urgent"]
+	Windows     []BurstWindowConfig `mapstructure:"windows"`
+	Blackouts   []BlackoutConfig    `mapstructure:"blackouts"`
+}
+
+// BurstWindowConfig is a recurring daily time range bursting is allowed in.
+// Partitions and accounts scope it; empty applies to all.
+type BurstWindowConfig struct {
+	Name       string   `mapstructure:"name"`
+	Partitions []string `mapstructure:"partitions"`
+	Accounts   []string `mapstructure:"accounts"`
+	Days       []string `mapstructure:"days"`  // "mon" to "sun"; empty is every day
+	Start      string   `mapstructure:"start"` // "HH:MM"
+	End        string   `mapstructure:"end"`   // "HH:MM"; at or before start, the window runs past midnight
+}
+
+// BlackoutConfig is a period nothing it applies to bursts in, e.g. maintenance
+type BlackoutConfig struct {
+	Name       string   `mapstructure:"name"`
+	Partitions []string `mapstructure:"partitions"`
+	Accounts   []string `mapstructure:"accounts"`
+	Start      string   `mapstructure:"start"` // BlackoutLayout
+	End        string   `mapstructure:"end"`
+}
+
+// BlackoutLayout is how blackout start and end times are written
+const BlackoutLayout = "2006-01-02 15:04"
+
+// weekdays are the names burst window days are written with
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// BurstRefusal is why a launch may not burst now
+type BurstRefusal struct {
+	Blackout string // Name of the blackout, or empty outside every window
+	Until    time.Time
+}
+
+// Reason is the Slurm node reason for the refusal
+func (r *BurstRefusal) Reason() string {
+	if r.Blackout != "" {
+		return "burst_blackout_" + r.Blackout
+	}
+	return "outside_burst_window"
+}
+
+// Location is the timezone the windows and blackouts are in
+func (w *BurstWindowsConfig) Location() (*time.Location, error) {
+	if w.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(w.Timezone)
+}
+
+// Overridden reports whether a job in the QOS bursts regardless of windows
+func (w *BurstWindowsConfig) Overridden(qos string) bool {
+	return qos != "" && slices.Contains(w.OverrideQOS, qos)
+}
+
+// Check returns why a launch in the partition for the account may not burst
+// at now, or nil when it may. Configuration is validated at load, so entries
+// that do not parse are skipped.
+func (w *BurstWindowsConfig) Check(now time.Time, partition, account string) *BurstRefusal {
+	location, err := w.Location()
+	if err != nil {
+		return nil
+	}
+	now = now.In(location)
+
+	for _, blackout := range w.Blackouts {
+		if !appliesTo(blackout.Partitions, blackout.Accounts, partition, account) {
+			continue
+		}
+		start, end, err := blackout.period(location)
+		if err == nil && !now.Before(start) && now.Before(end) {
+			return &BurstRefusal{Blackout: blackout.Name, Until: end}
+		}
+	}
+
+	applicable := false
+	var next time.Time
+	for _, window := range w.Windows {
+		if !appliesTo(window.Partitions, window.Accounts, partition, account) {
+			continue
+		}
+		applicable = true
+		opens, open := window.opening(now)
+		if open {
+			return nil
+		}
+		if !opens.IsZero() && (next.IsZero() || opens.Before(next)) {
+			next = opens
+		}
+	}
+	if !applicable {
+		return nil
+	}
+	return &BurstRefusal{Until: next}
+}
+
+// opening reports whether the window is open at now, and otherwise when it
+// next opens within a week
+func (w *BurstWindowConfig) opening(now time.Time) (time.Time, bool) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return time.Time{}, false
+	}
+	length := end - start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+
+	// A window that runs past midnight may have opened the day before
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for day := -1; day <= 7; day++ {
+		date := midnight.AddDate(0, 0, day)
+		if !w.onDay(date.Weekday()) {
+			continue
+		}
+		opens := date.Add(start)
+		if !now.Before(opens) && now.Before(opens.Add(length)) {
+			return opens, true
+		}
+		if opens.After(now) {
+			return opens, false
+		}
+	}
+	return time.Time{}, false
+}
+
+func (w *BurstWindowConfig) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// period is when the blackout starts and ends
+func (b *BlackoutConfig) period(location *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(BlackoutLayout, b.Start, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := time.ParseInLocation(BlackoutLayout, b.End, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end, nil
+}
+
+// appliesTo reports whether a scope of partitions and accounts, each empty
+// for all, covers the partition and account
+func appliesTo(partitions, accounts []string, partition, account string) bool {
+	return (len(partitions) == 0 || slices.Contains(partitions, partition)) &&
+		(len(accounts) == 0 || slices.Contains(accounts, account))
+}
+
+// parseClock parses an "HH:MM" time of day into the duration since midnight
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an HH:MM time of day", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// validateBurstWindows checks the timezone, that windows have valid days and
+// times, and that blackouts end after they start
+func validateBurstWindows(windows *BurstWindowsConfig) error {
+	location, err := windows.Location()
+	if err != nil {
+		return fmt.Errorf("burst_windows.timezone: %w", err)
+	}
+
+	for i, window := range windows.Windows {
+		prefix := fmt.Sprintf("burst_windows.windows[%d]", i)
+		for _, day := range window.Days {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("%s.days has unknown day %q (use mon to sun)", prefix, day)
+			}
+		}
+		if _, err := parseClock(window.Start); err != nil {
+			return fmt.Errorf("%s.start: %w", prefix, err)
+		}
+		if _, err := parseClock(window.End); err != nil {
+			return fmt.Errorf("%s.end: %w", prefix, err)
+		}
+	}
+
+	for i, blackout := range windows.Blackouts {
+		prefix := fmt.Sprintf("burst_windows.blackouts[%d]", i)
+		if blackout.Name == "" || strings.ContainsAny(blackout.Name, " \t") {
+			return fmt.Errorf("%s.name is required and must not contain spaces", prefix)
+		}
+		start, end, err := blackout.period(location)
+		if err != nil {
+			return fmt.Errorf("%s start and end must be %q times: %w", prefix, BlackoutLayout, err)
+		}
+		if !end.After(start) {
+			return fmt.Errorf("%s must end after it starts", prefix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBurstWindowsCheck(t *testing.T) {
+	windows := BurstWindowsConfig{
+		Timezone:    "America/New_York",
+		OverrideQOS: []string{"urgent"},
+		Windows: []BurstWindowConfig{
+			{Name: "nights", Accounts: []string{"chem"}, Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "20:00", End: "06:00"},
+			{Name: "weekends", Accounts: []string{"chem"}, Days: []string{"Sat", "sun"}, Start: "00:00", End: "00:00"},
+		},
+		Blackouts: []BlackoutConfig{
+			{Name: "maintenance", Partitions: []string{"aws"}, Start: "2026-10-21 08:00", End: "2026-10-21 12:00"},
+		},
+	}
+	require.NoError(t, validateBurstWindows(&windows))
+	location, err := windows.Location()
+	require.NoError(t, err)
+	at := func(value string) time.Time {
+		when, err := time.ParseInLocation(BlackoutLayout, value, location)
+		require.NoError(t, err)
+		return when
+	}
+
+	// Wednesday 2026-10-21
+	assert.Nil(t, windows.Check(at("2026-10-21 14:00"), "aws", "bio"), "no window applies to bio")
+	refusal := windows.Check(at("2026-10-21 14:00"), "aws", "chem")
+	require.NotNil(t, refusal)
+	assert.Equal(t, "outside_burst_window", refusal.Reason())
+	assert.Equal(t, at("2026-10-21 20:00"), refusal.Until)
+
+	assert.Nil(t, windows.Check(at("2026-10-21 23:30"), "aws", "chem"))
+	assert.Nil(t, windows.Check(at("2026-10-22 05:59"), "aws", "chem"), "the night window runs past midnight")
+	assert.Nil(t, windows.Check(at("2026-10-24 13:00"), "aws", "chem"), "Saturday")
+	assert.Nil(t, windows.Check(at("2026-10-27 03:00"), "aws", "chem"), "Monday night's window opened on Monday")
+	assert.Nil(t, windows.Check(at("2026-10-25 23:59"), "aws", "chem"), "Sunday's window lasts all day")
+	refusal = windows.Check(at("2026-10-26 03:00"), "aws", "chem")
+	require.NotNil(t, refusal, "no window opened on Sunday night")
+	assert.Equal(t, at("2026-10-26 20:00"), refusal.Until)
+
+	refusal = windows.Check(at("2026-10-21 09:00").UTC(), "aws", "bio")
+	require.NotNil(t, refusal, "times in other zones are converted")
+	assert.Equal(t, "burst_blackout_maintenance", refusal.Reason())
+	assert.Equal(t, at("2026-10-21 12:00"), refusal.Until)
+	assert.Nil(t, windows.Check(at("2026-10-21 09:00"), "other", "bio"), "the blackout is scoped to aws")
+
+	assert.True(t, windows.Overridden("urgent"))
+	assert.False(t, windows.Overridden("normal"))
+	assert.False(t, windows.Overridden(""))
+}
+
+func TestValidateBurstWindows(t *testing.T) {
+	assert.NoError(t, validateBurstWindows(&BurstWindowsConfig{}))
+
+	for _, invalid := range []BurstWindowsConfig{
+		{Timezone: "Mars/Olympus"},
+		{Windows: []BurstWindowConfig{{Days: []string{"funday"}, Start: "20:00", End: "06:00"}}},
+		{Windows: []BurstWindowConfig{{Start: "8pm", End: "06:00"}}},
+		{Blackouts: []BlackoutConfig{{Start: "2026-10-21 08:00", End: "2026-10-21 12:00"}}},
+		{Blackouts: []BlackoutConfig{{Name: "maintenance", Start: "2026-10-21", End: "2026-10-22"}}},
+		{Blackouts: []BlackoutConfig{{Name: "maintenance", Start: "2026-10-21 12:00", End: "2026-10-21 08:00"}}},
+	} {
+		assert.Error(t, validateBurstWindows(&invalid), "%+v", invalid)
+	}
+}
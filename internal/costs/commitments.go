@@ -0,0 +1,73 @@
+package costs
+
+import (
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// CommittedRate is how committed capacity prices an instance's usage
+type CommittedRate struct {
+	Covered    float64 // Share of the usage on committed capacity, 0-1
+	Multiplier float64 // Effective price over the on-demand price
+}
+
+// RateFor returns the committed rate of an instance: reserved instances of
+// its family cover their share of on-demand usage, and the savings plan its
+// share of the rest. Spot instances are never covered.
+func RateFor(model config.CommitmentsConfig, instanceType, purchaseOption string) CommittedRate {
+	rate := CommittedRate{Multiplier: 1}
+	if purchaseOption == "spot" {
+		return rate
+	}
+
+	uncovered := 1.0
+	family := types.InstanceFamilyOf(instanceType)
+	for _, reserved := range model.ReservedInstances {
+		if reserved.InstanceFamily == family {
+			rate.Covered += reserved.Coverage
+			rate.Multiplier -= reserved.Coverage * reserved.Discount
+			uncovered -= reserved.Coverage
+			break
+		}
+	}
+
+	planned := uncovered * model.SavingsPlan.Coverage
+	rate.Covered += planned
+	rate.Multiplier -= planned * model.SavingsPlan.Discount
+	return rate
+}
+
+// ApplyCommitments prices the on-demand instances in the analysis's breakdown
+// at their committed rates, totals the cost of their covered usage and
+// returns what the commitments took off their on-demand cost
+func ApplyCommitments(model config.CommitmentsConfig, analysis *types.ActualCostAnalysis) float64 {
+	analysis.CommittedCostUSD = 0
+	discount := 0.0
+	for i := range analysis.InstanceCostBreakdown {
+		details := &analysis.InstanceCostBreakdown[i]
+		rate := RateFor(model, details.InstanceType, details.PurchaseOption)
+		if rate.Covered == 0 {
+			continue
+		}
+
+		details.CommittedShare = rate.Covered
+		details.CostUSD = details.OnDemandCostUSD * rate.Multiplier
+		// The covered share at its discounted price; the rest is paid on demand
+		analysis.CommittedCostUSD += details.CostUSD - details.OnDemandCostUSD*(1-rate.Covered)
+		discount += details.OnDemandCostUSD - details.CostUSD
+	}
+	return discount
+}
+
+// CommittedMultiplier is the average effective price over on demand of the
+// instances, for scaling cost estimates made at on-demand prices
+func CommittedMultiplier(model config.CommitmentsConfig, instances []types.InstanceInfo) float64 {
+	if len(instances) == 0 {
+		return 1
+	}
+	total := 0.0
+	for _, instance := range instances {
+		total += RateFor(model, instance.InstanceType, instance.PurchaseOption).Multiplier
+	}
+	return total / float64(len(instances))
+}
@@ -0,0 +1,46 @@
+package costs
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyCommitments(t *testing.T) {
+	model := config.CommitmentsConfig{
+		SavingsPlan:       config.SavingsPlanConfig{Discount: 0.2, Coverage: 0.5},
+		ReservedInstances: []config.ReservedInstanceConfig{{InstanceFamily: "c6i", Discount: 0.4, Coverage: 0.5}},
+	}
+	analysis := &types.ActualCostAnalysis{InstanceCostBreakdown: []types.InstanceCostDetails{
+		{InstanceType: "c6i.xlarge", PurchaseOption: "on-demand", CostUSD: 10, OnDemandCostUSD: 10},
+		{InstanceType: "m6i.xlarge", PurchaseOption: "on-demand", CostUSD: 10, OnDemandCostUSD: 10},
+		{InstanceType: "c6i.xlarge", PurchaseOption: "spot", CostUSD: 3, OnDemandCostUSD: 10},
+	}}
+
+	discount := ApplyCommitments(model, analysis)
+
+	// Half reserved at 40% off, half the rest on the plan at 20% off
+	reserved := analysis.InstanceCostBreakdown[0]
+	assert.InDelta(t, 0.75, reserved.CommittedShare, 1e-9)
+	assert.InDelta(t, 5*0.6+2.5*0.8+2.5, reserved.CostUSD, 1e-9)
+
+	planned := analysis.InstanceCostBreakdown[1]
+	assert.InDelta(t, 0.5, planned.CommittedShare, 1e-9)
+	assert.InDelta(t, 5*0.8+5, planned.CostUSD, 1e-9)
+
+	spot := analysis.InstanceCostBreakdown[2]
+	assert.Zero(t, spot.CommittedShare, "spot usage is never committed")
+	assert.Equal(t, 3.0, spot.CostUSD)
+
+	assert.InDelta(t, (5*0.6+2.5*0.8)+5*0.8, analysis.CommittedCostUSD, 1e-9)
+	assert.InDelta(t, 20-reserved.CostUSD-planned.CostUSD, discount, 1e-9)
+
+	instances := []types.InstanceInfo{
+		{InstanceType: "m6i.xlarge", PurchaseOption: "on-demand"},
+		{InstanceType: "m6i.xlarge", PurchaseOption: "spot"},
+	}
+	assert.InDelta(t, (0.9+1)/2, CommittedMultiplier(model, instances), 1e-9)
+	assert.Equal(t, 1.0, CommittedMultiplier(config.CommitmentsConfig{}, instances), "nothing committed")
+}
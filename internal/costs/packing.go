@@ -0,0 +1,92 @@
+package costs
+
+import (
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Allocation is what Slurm allocated a job on each of its nodes
+type Allocation struct {
+	CPUsPerNode     int
+	MemoryMBPerNode int
+}
+
+// PackedShare returns the share of a shared instance a job is charged: the
+// fraction of the instance's vCPUs or memory it was allocated, or the larger
+// of the two. Without the instance's capacity or the job's allocation the
+// instance is split evenly between the jobs it was sized for.
+func PackedShare(packing config.JobPackingConfig, alloc Allocation, instance types.InstanceInfo) float64 {
+	cpu, memory := -1.0, -1.0
+	if instance.VCPUs > 0 && alloc.CPUsPerNode > 0 {
+		cpu = float64(alloc.CPUsPerNode) / float64(instance.VCPUs)
+	}
+	if instance.MemoryMiB > 0 && alloc.MemoryMBPerNode > 0 {
+		memory = float64(alloc.MemoryMBPerNode) / float64(instance.MemoryMiB)
+	}
+
+	var share float64
+	switch packing.Basis() {
+	case config.CostShareCPU:
+		share = cpu
+	case config.CostShareMemory:
+		share = memory
+	default:
+		share = max(cpu, memory)
+	}
+	if share < 0 {
+		share = 1 / float64(packing.JobsPerNode)
+	}
+	return min(share, 1)
+}
+
+// PackInstances returns the shares of a job's instances in packed node
+// groups, by instance ID, and the instances with those in packed node groups
+// priced for the hours the job ran rather than from their launch
+func PackInstances(cfg *config.Config, alloc Allocation, instances []types.InstanceInfo) ([]types.InstanceInfo, map[string]float64) {
+	shares := make(map[string]float64)
+	packed := make([]types.InstanceInfo, 0, len(instances))
+	for _, instance := range instances {
+		nodeGroup := cfg.FindNodeGroup(instance.Partition, instance.NodeGroup)
+		if nodeGroup != nil && nodeGroup.JobPacking.Enabled() {
+			shares[instance.InstanceID] = PackedShare(nodeGroup.JobPacking, alloc, instance)
+			instance.LaunchTime = ""
+		}
+		packed = append(packed, instance)
+	}
+	return packed, shares
+}
+
+// ApplyPackedShares charges the job its share of each shared instance in the
+// analysis's breakdown and spot savings, and scales the compute cost by the
+// share of the breakdown's cost that remains
+func ApplyPackedShares(analysis *types.ActualCostAnalysis, shares map[string]float64) {
+	if len(shares) == 0 {
+		return
+	}
+
+	full, charged := 0.0, 0.0
+	analysis.SpotSavingsUSD = 0
+	for i := range analysis.InstanceCostBreakdown {
+		details := &analysis.InstanceCostBreakdown[i]
+		full += details.CostUSD
+		if share, ok := shares[details.InstanceID]; ok {
+			details.CostUSD *= share
+			details.OnDemandCostUSD *= share
+		}
+		charged += details.CostUSD
+		if details.PurchaseOption == "spot" {
+			analysis.SpotSavingsUSD += details.OnDemandCostUSD - details.CostUSD
+		}
+	}
+
+	if full > 0 {
+		analysis.ComputeCostUSD *= charged / full
+		return
+	}
+	// Without recorded prices the job is charged its average share
+	total := 0.0
+	for _, share := range shares {
+		total += share
+	}
+	analysis.ComputeCostUSD *= total / float64(len(shares))
+}
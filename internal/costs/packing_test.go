@@ -0,0 +1,72 @@
+package costs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackedShare(t *testing.T) {
+	instance := types.InstanceInfo{VCPUs: 16, MemoryMiB: 65536}
+	alloc := Allocation{CPUsPerNode: 4, MemoryMBPerNode: 32768}
+
+	packing := config.JobPackingConfig{JobsPerNode: 4}
+	assert.InDelta(t, 0.5, PackedShare(packing, alloc, instance), 1e-9, "memory dominates")
+
+	packing.CostShare = config.CostShareCPU
+	assert.InDelta(t, 0.25, PackedShare(packing, alloc, instance), 1e-9)
+
+	packing.CostShare = config.CostShareMemory
+	assert.InDelta(t, 0.25, PackedShare(packing, Allocation{MemoryMBPerNode: 16384}, instance), 1e-9)
+
+	assert.InDelta(t, 0.25, PackedShare(packing, alloc, types.InstanceInfo{}), 1e-9, "unknown capacity splits evenly")
+	assert.Equal(t, 1.0, PackedShare(packing, Allocation{MemoryMBPerNode: 131072}, instance), "capped at the whole instance")
+}
+
+func TestPackInstances(t *testing.T) {
+	cfg := &config.Config{Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{{
+		PartitionName: "aws",
+		NodeGroups: []config.NodeGroupConfig{
+			{NodeGroupName: "shared", JobPacking: config.JobPackingConfig{JobsPerNode: 4, CostShare: config.CostShareCPU}},
+			{NodeGroupName: "cpu"},
+		},
+	}}}}
+	launched := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := launched.Add(10 * time.Hour)
+
+	instances, shares := PackInstances(cfg, Allocation{CPUsPerNode: 4}, []types.InstanceInfo{
+		{InstanceID: "i-shared", Partition: "aws", NodeGroup: "shared", InstanceType: "m6i.4xlarge", PurchaseOption: "spot",
+			VCPUs: 16, LaunchTime: launched.Format(time.RFC3339), SpotPriceUSD: 0.3, OnDemandPriceUSD: 0.8},
+		{InstanceID: "i-own", Partition: "aws", NodeGroup: "cpu", InstanceType: "c6i.xlarge", PurchaseOption: "on-demand",
+			LaunchTime: launched.Format(time.RFC3339), OnDemandPriceUSD: 0.2},
+	})
+	assert.Equal(t, map[string]float64{"i-shared": 0.25}, shares)
+	assert.Empty(t, instances[0].LaunchTime, "shared instances are priced for the job's hours")
+	assert.NotEmpty(t, instances[1].LaunchTime)
+
+	analysis := &types.ActualCostAnalysis{ComputeCostUSD: 7}
+	analysis.ApplyLaunchPrices(instances, end, 2*time.Hour)
+	ApplyPackedShares(analysis, shares)
+
+	require.Len(t, analysis.InstanceCostBreakdown, 2)
+	shared := analysis.InstanceCostBreakdown[0]
+	assert.InDelta(t, 0.3*2*0.25, shared.CostUSD, 1e-9)
+	assert.InDelta(t, 0.8*2*0.25, shared.OnDemandCostUSD, 1e-9)
+	assert.InDelta(t, 0.2*10, analysis.InstanceCostBreakdown[1].CostUSD, 1e-9)
+	assert.InDelta(t, (0.8-0.3)*2*0.25, analysis.SpotSavingsUSD, 1e-9)
+	assert.InDelta(t, 7*(0.15+2)/(0.6+2), analysis.ComputeCostUSD, 1e-9)
+
+	// Storage on a shared instance is charged at the same share
+	storage := EstimateStorage(cfg, StorageUsage{
+		Partition: "aws",
+		End:       end,
+		Elapsed:   2 * time.Hour,
+		Instances: []types.InstanceInfo{{InstanceID: "i-shared", Volumes: []types.EBSVolume{{VolumeType: "gp2", SizeGB: 100}}}},
+		Shares:    shares,
+	})
+	assert.InDelta(t, 100*2*0.25, storage.VolumeGBHours, 1e-9)
+}
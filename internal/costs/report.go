@@ -0,0 +1,201 @@
+// Package costs aggregates per-job cost reconciliation records into reports for
+// PIs and department administrators, and models the costs of a job that
+// instance prices do not cover.
+package costs
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Report groupings
+const (
+	GroupByAccount   = "account"
+	GroupByPartition = "partition"
+	GroupByUser      = "user"
+)
+
+// reconciliationPattern matches the files written by export-performance --format=asbb-reconciliation
+const reconciliationPattern = "job-*-asbb-reconciliation.json"
+
+// Summary is the spend of one account, partition or user
+type Summary struct {
+	Group          string  `json:"group"`
+	Jobs           int     `json:"jobs"`
+	FailedJobs     int     `json:"failed_jobs"`
+	TotalUSD       float64 `json:"total_usd"`
+	ComputeUSD     float64 `json:"compute_usd"`
+	StorageUSD     float64 `json:"storage_usd"`
+	NetworkUSD     float64 `json:"network_usd"`
+	SpotSavingsUSD float64 `json:"spot_savings_usd"`
+	WastedUSD      float64 `json:"wasted_usd"` // Spent on jobs that failed
+	Hours          float64 `json:"hours"`
+}
+
+// LoadRecords reads every reconciliation file in dir. Unreadable or malformed files
+// are returned in skipped rather than failing the report.
+func LoadRecords(dir string) (records []types.CostReconciliation, skipped []string, err error) {
+	files, err := filepath.Glob(filepath.Join(dir, reconciliationPattern))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list reconciliation files: %w", err)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			skipped = append(skipped, file)
+			continue
+		}
+
+		var record types.CostReconciliation
+		if err := json.Unmarshal(data, &record); err != nil {
+			skipped = append(skipped, file)
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, skipped, nil
+}
+
+// ParseDate parses an optional YYYY-MM-DD report bound in local time; an
+// empty value is the zero, open bound
+func ParseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// Filter keeps the records of jobs completed in [since, until); zero bounds are open
+func Filter(records []types.CostReconciliation, since, until time.Time) []types.CostReconciliation {
+	var kept []types.CostReconciliation
+	for _, record := range records {
+		completed := record.CompletedAt()
+		if !since.IsZero() && completed.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !completed.Before(until) {
+			continue
+		}
+		kept = append(kept, record)
+	}
+	return kept
+}
+
+// Aggregate totals records by group, most expensive first
+func Aggregate(records []types.CostReconciliation, groupBy string) ([]Summary, error) {
+	key, err := groupKey(groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	byGroup := make(map[string]*Summary)
+	for _, record := range records {
+		group := key(record)
+		if group == "" {
+			group = "(none)"
+		}
+		summary, ok := byGroup[group]
+		if !ok {
+			summary = &Summary{Group: group}
+			byGroup[group] = summary
+		}
+
+		summary.Jobs++
+		summary.TotalUSD += record.ActualCost
+		summary.ComputeUSD += record.ComputeCost
+		summary.StorageUSD += record.StorageCost
+		summary.NetworkUSD += record.NetworkCost
+		summary.SpotSavingsUSD += record.SpotSavings
+		summary.Hours += record.DurationHours
+		if !record.Success {
+			summary.FailedJobs++
+			summary.WastedUSD += record.ActualCost
+		}
+	}
+
+	summaries := make([]Summary, 0, len(byGroup))
+	for _, summary := range byGroup {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].TotalUSD != summaries[j].TotalUSD {
+			return summaries[i].TotalUSD > summaries[j].TotalUSD
+		}
+		return summaries[i].Group < summaries[j].Group
+	})
+
+	return summaries, nil
+}
+
+// Total sums summaries into one "total" row
+func Total(summaries []Summary) Summary {
+	total := Summary{Group: "total"}
+	for _, summary := range summaries {
+		total.Jobs += summary.Jobs
+		total.FailedJobs += summary.FailedJobs
+		total.TotalUSD += summary.TotalUSD
+		total.ComputeUSD += summary.ComputeUSD
+		total.StorageUSD += summary.StorageUSD
+		total.NetworkUSD += summary.NetworkUSD
+		total.SpotSavingsUSD += summary.SpotSavingsUSD
+		total.WastedUSD += summary.WastedUSD
+		total.Hours += summary.Hours
+	}
+	return total
+}
+
+// WriteCSV writes summaries with a header naming the grouping
+func WriteCSV(w io.Writer, groupBy string, summaries []Summary) error {
+	writer := csv.NewWriter(w)
+	header := []string{groupBy, "jobs", "failed_jobs", "total_usd", "compute_usd", "storage_usd", "network_usd", "spot_savings_usd", "wasted_usd", "hours"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	money := func(value float64) string { return strconv.FormatFloat(value, 'f', 2, 64) }
+	for _, summary := range summaries {
+		row := []string{
+			summary.Group,
+			strconv.Itoa(summary.Jobs),
+			strconv.Itoa(summary.FailedJobs),
+			money(summary.TotalUSD),
+			money(summary.ComputeUSD),
+			money(summary.StorageUSD),
+			money(summary.NetworkUSD),
+			money(summary.SpotSavingsUSD),
+			money(summary.WastedUSD),
+			strconv.FormatFloat(summary.Hours, 'f', 1, 64),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// groupKey returns how records are grouped
+func groupKey(groupBy string) (func(types.CostReconciliation) string, error) {
+	switch groupBy {
+	case GroupByAccount:
+		return func(r types.CostReconciliation) string { return r.Account }, nil
+	case GroupByPartition:
+		return func(r types.CostReconciliation) string { return r.Partition }, nil
+	case GroupByUser:
+		return func(r types.CostReconciliation) string { return r.UserID }, nil
+	default:
+		return nil, fmt.Errorf("unsupported grouping %q (use %s, %s or %s)", groupBy, GroupByAccount, GroupByPartition, GroupByUser)
+	}
+}
@@ -0,0 +1,74 @@
+package costs
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	date, err := ParseDate("2026-01-05")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local), date)
+
+	date, err = ParseDate("")
+	require.NoError(t, err)
+	assert.True(t, date.IsZero(), "an empty bound is open")
+
+	_, err = ParseDate("01/05/2026")
+	assert.Error(t, err)
+}
+
+func writeRecord(t *testing.T, dir string, record types.CostReconciliation) {
+	data, err := json.Marshal(record)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "job-"+record.JobID+"-asbb-reconciliation.json"), data, 0600))
+}
+
+func TestReport(t *testing.T) {
+	dir := t.TempDir()
+	writeRecord(t, dir, types.CostReconciliation{JobID: "1", Account: "chem", Partition: "aws", ActualCost: 10, SpotSavings: 4, DurationHours: 2, Success: true, EndTime: "2026-01-05T10:00:00Z"})
+	writeRecord(t, dir, types.CostReconciliation{JobID: "2", Account: "chem", Partition: "gpu", ActualCost: 6, DurationHours: 1, Success: false, EndTime: "2026-01-20T10:00:00Z"})
+	writeRecord(t, dir, types.CostReconciliation{JobID: "3", Account: "bio", Partition: "aws", ActualCost: 3, DurationHours: 1, Success: true, ExportTime: "2026-02-02T10:00:00Z"})
+	writeRecord(t, dir, types.CostReconciliation{JobID: "4", Account: "bio", Partition: "aws", ActualCost: 50, Success: true, EndTime: "2025-12-30T10:00:00Z"})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "job-5-asbb-reconciliation.json"), []byte("{"), 0600))
+
+	records, skipped, err := LoadRecords(dir)
+	require.NoError(t, err)
+	assert.Len(t, records, 4)
+	assert.Len(t, skipped, 1)
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	filtered := Filter(records, since, time.Time{})
+	require.Len(t, filtered, 3)
+
+	byAccount, err := Aggregate(filtered, GroupByAccount)
+	require.NoError(t, err)
+	require.Len(t, byAccount, 2)
+	assert.Equal(t, Summary{Group: "chem", Jobs: 2, FailedJobs: 1, TotalUSD: 16, SpotSavingsUSD: 4, WastedUSD: 6, Hours: 3}, byAccount[0])
+	assert.Equal(t, "bio", byAccount[1].Group)
+
+	total := Total(byAccount)
+	assert.Equal(t, 3, total.Jobs)
+	assert.InDelta(t, 19, total.TotalUSD, 1e-9)
+
+	byPartition, err := Aggregate(Filter(records, since, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)), GroupByPartition)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws", "gpu"}, []string{byPartition[0].Group, byPartition[1].Group})
+
+	_, err = Aggregate(filtered, "department")
+	assert.Error(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, WriteCSV(&out, GroupByAccount, byAccount))
+	assert.Equal(t, "account,jobs,failed_jobs,total_usd,compute_usd,storage_usd,network_usd,spot_savings_usd,wasted_usd,hours\n"+
+		"chem,2,1,16.00,0.00,0.00,0.00,4.00,6.00,3.0\n"+
+		"bio,1,0,3.00,0.00,0.00,0.00,0.00,0.00,1.0\n", out.String())
+}
@@ -0,0 +1,118 @@
+package costs
+
+import (
+	"slices"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// hoursPerMonth is the month AWS prorates monthly storage prices over
+const hoursPerMonth = 730
+
+// DefaultEBSRates are us-east-1 list prices in $/GB-month, used for volume
+// types costs.storage.ebs_rates_per_gb_month does not price
+var DefaultEBSRates = map[string]float64{
+	"gp2":      0.10,
+	"gp3":      0.08,
+	"io1":      0.125,
+	"io2":      0.125,
+	"st1":      0.045,
+	"sc1":      0.015,
+	"standard": 0.05,
+}
+
+// Provisioned performance list prices, per month
+const (
+	gp3IncludedIOPS       = 3000
+	gp3IOPSRate           = 0.005 // Per IOPS above those included
+	gp3IncludedThroughput = 125
+	gp3ThroughputRate     = 0.04  // Per MB/s above that included
+	provisionedIOPSRate   = 0.065 // Per io1 and io2 IOPS
+)
+
+// DefaultFSxScratchRate is the FSx for Lustre scratch list price in $/GB-month
+const DefaultFSxScratchRate = 0.14
+
+// StorageUsage is what a job held storage with
+type StorageUsage struct {
+	Partition string
+	Nodes     int
+	End       time.Time
+	Elapsed   time.Duration // How long the job ran
+	Instances []types.InstanceInfo
+	Shares    map[string]float64 // Instance ID -> share of a shared instance the job is charged
+}
+
+// EstimateStorage prices the EBS volumes of the job's instances from launch
+// to the job's end, at its share of shared instances, and its share of the FSx filesystems its partition
+// mounts: the job's nodes over all the nodes those filesystems' partitions
+// can run, for as long as it ran
+func EstimateStorage(cfg *config.Config, usage StorageUsage) *types.StorageCost {
+	storage := &types.StorageCost{}
+	rates := cfg.Costs.Storage.EBSRatesPerGBMonth
+
+	for _, instance := range usage.Instances {
+		hours := instance.RunHours(usage.End, usage.Elapsed)
+		if share, ok := usage.Shares[instance.InstanceID]; ok {
+			hours *= share
+		}
+		for _, volume := range instance.Volumes {
+			storage.VolumeGBHours += float64(volume.SizeGB) * hours
+			storage.EBSCostUSD += volumeMonthlyCost(rates, volume) * hours / hoursPerMonth
+		}
+	}
+
+	for _, fileSystem := range cfg.Costs.Storage.FileSystems {
+		if !slices.Contains(fileSystem.Partitions, usage.Partition) {
+			continue
+		}
+		capacity := 0
+		for _, partitionName := range fileSystem.Partitions {
+			if partition := cfg.FindPartition(partitionName); partition != nil {
+				for _, nodeGroup := range partition.NodeGroups {
+					capacity += nodeGroup.MaxNodes
+				}
+			}
+		}
+		if capacity == 0 {
+			continue
+		}
+
+		rate := fileSystem.RatePerGBMonth
+		if rate == 0 {
+			rate = DefaultFSxScratchRate
+		}
+		share := float64(min(usage.Nodes, capacity)) / float64(capacity)
+		cost := float64(fileSystem.StorageGB) * rate * usage.Elapsed.Hours() / hoursPerMonth * share
+
+		if storage.FileSystems == nil {
+			storage.FileSystems = make(map[string]float64)
+		}
+		storage.FileSystems[fileSystem.Name] = cost
+		storage.FSxCostUSD += cost
+	}
+
+	storage.CostUSD = storage.EBSCostUSD + storage.FSxCostUSD
+	return storage
+}
+
+// volumeMonthlyCost is a volume's capacity and provisioned performance price
+// per month
+func volumeMonthlyCost(rates map[string]float64, volume types.EBSVolume) float64 {
+	rate, ok := rates[volume.VolumeType]
+	if !ok {
+		rate = DefaultEBSRates[volume.VolumeType]
+	}
+
+	cost := rate * float64(volume.SizeGB)
+	switch volume.VolumeType {
+	case "gp3":
+		cost += float64(max(volume.IOPS-gp3IncludedIOPS, 0)) * gp3IOPSRate
+		cost += float64(max(volume.ThroughputMBps-gp3IncludedThroughput, 0)) * gp3ThroughputRate
+	case "io1", "io2":
+		cost += float64(volume.IOPS) * provisionedIOPSRate
+	}
+	return cost
+}
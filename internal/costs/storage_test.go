@@ -0,0 +1,58 @@
+package costs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateStorage(t *testing.T) {
+	cfg := &config.Config{
+		Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{
+			{PartitionName: "aws", NodeGroups: []config.NodeGroupConfig{{NodeGroupName: "cpu", MaxNodes: 6}, {NodeGroupName: "gpu", MaxNodes: 2}}},
+			{PartitionName: "other", NodeGroups: []config.NodeGroupConfig{{NodeGroupName: "cpu", MaxNodes: 2}}},
+		}},
+		Costs: config.CostsConfig{Storage: config.StorageCostConfig{
+			EBSRatesPerGBMonth: map[string]float64{"gp2": 0.073},
+			FileSystems: []config.FileSystemConfig{
+				{Name: "scratch", StorageGB: 7300, Partitions: []string{"aws", "other"}},
+				{Name: "elsewhere", StorageGB: 1200, Partitions: []string{"other"}},
+			},
+		}},
+	}
+
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	storage := EstimateStorage(cfg, StorageUsage{
+		Partition: "aws",
+		Nodes:     2,
+		End:       end,
+		Elapsed:   10 * time.Hour,
+		Instances: []types.InstanceInfo{
+			{InstanceID: "i-1", LaunchTime: end.Add(-73 * time.Hour).Format(time.RFC3339), Volumes: []types.EBSVolume{
+				{VolumeType: "gp3", SizeGB: 100, IOPS: 4000, ThroughputMBps: 125},
+			}},
+			{InstanceID: "i-2", Volumes: []types.EBSVolume{{VolumeType: "gp2", SizeGB: 100}}},
+		},
+	})
+
+	assert.InDelta(t, 100*73+100*10, storage.VolumeGBHours, 1e-9)
+	gp3 := (100*0.08 + 1000*0.005) * 73 / 730
+	gp2 := 100 * 0.073 * 10 / 730
+	assert.InDelta(t, gp3+gp2, storage.EBSCostUSD, 1e-9, "configured rates replace the list price")
+
+	// Two of the ten nodes that mount it, for ten hours
+	assert.Equal(t, []string{"scratch"}, keys(storage.FileSystems), "only filesystems the partition mounts")
+	assert.InDelta(t, 7300*0.14*10/730*0.2, storage.FSxCostUSD, 1e-9)
+	assert.InDelta(t, storage.EBSCostUSD+storage.FSxCostUSD, storage.CostUSD, 1e-9)
+}
+
+func keys(values map[string]float64) []string {
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	return names
+}
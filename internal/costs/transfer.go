@@ -0,0 +1,79 @@
+package costs
+
+import (
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// bytesPerGB is the gigabyte AWS bills data transfer by
+const bytesPerGB = 1 << 30
+
+// DefaultTransferRates are us-east-1 list prices in $/GB, used for classes
+// costs.data_transfer.rates_per_gb does not price
+var DefaultTransferRates = map[string]float64{
+	config.TransferInternet:      0.09,
+	config.TransferDirectConnect: 0.02,
+	config.TransferVPN:           0.09, // VPN traffic is billed as data transfer out
+	config.TransferCrossRegion:   0.02,
+	config.TransferCrossAZ:       0.02, // $0.01 out of one zone and $0.01 into the other
+	config.TransferNATGateway:    0.045,
+}
+
+// TransferUsage is what is known of the traffic of a job's nodes
+type TransferUsage struct {
+	Nodes     int     // Nodes the job ran on
+	NodeHours float64 // For the estimate when no node was measured
+	Zones     int     // Zones the nodes ran in
+	Summaries []types.NodeMetricsSummary
+}
+
+// EstimateTransfer prices a job's data transfer under the model. Nodes whose
+// summaries record traffic are measured and scaled up to all of the job's
+// nodes; without any, the model's per node hour estimate is used.
+func EstimateTransfer(model config.DataTransferConfig, usage TransferUsage) *types.DataTransferCost {
+	var sent float64
+	measured := 0
+	for _, summary := range usage.Summaries {
+		if summary.NetworkOutBytes > 0 {
+			sent += summary.NetworkOutBytes
+			measured++
+		}
+	}
+
+	totalGB := model.EstimatedGBPerNodeHour * usage.NodeHours
+	if measured > 0 {
+		totalGB = sent / bytesPerGB
+		if usage.Nodes > measured {
+			totalGB *= float64(usage.Nodes) / float64(measured)
+		}
+	}
+
+	class := model.EgressClass
+	if class == "" {
+		class = config.TransferInternet
+	}
+	transfer := &types.DataTransferCost{
+		EgressClass: class,
+		EgressGB:    totalGB * model.EgressShare,
+		Measured:    measured > 0,
+	}
+	// Traffic between nodes spread evenly over zones mostly crosses them
+	if usage.Zones > 1 {
+		transfer.CrossAZGB = totalGB * (1 - model.EgressShare) * float64(usage.Zones-1) / float64(usage.Zones)
+	}
+
+	egressRate := transferRate(model, class)
+	if model.NATGateway {
+		egressRate += transferRate(model, config.TransferNATGateway)
+	}
+	transfer.CostUSD = transfer.EgressGB*egressRate + transfer.CrossAZGB*transferRate(model, config.TransferCrossAZ)
+	return transfer
+}
+
+// transferRate is the model's $/GB for a class, or the list price
+func transferRate(model config.DataTransferConfig, class string) float64 {
+	if rate, ok := model.RatesPerGB[class]; ok {
+		return rate
+	}
+	return DefaultTransferRates[class]
+}
@@ -0,0 +1,36 @@
+package costs
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateTransfer(t *testing.T) {
+	model := config.DataTransferConfig{EgressClass: config.TransferDirectConnect, EgressShare: 0.25, EstimatedGBPerNodeHour: 1}
+
+	// Two of four nodes sent 10 GB each
+	measured := EstimateTransfer(model, TransferUsage{Nodes: 4, NodeHours: 8, Zones: 2, Summaries: []types.NodeMetricsSummary{
+		{NodeName: "aws-cpu-1", Samples: 5, NetworkOutBytes: 10 * bytesPerGB},
+		{NodeName: "aws-cpu-2", Samples: 5, NetworkOutBytes: 10 * bytesPerGB},
+		{NodeName: "aws-cpu-3", Samples: 5},
+	}})
+	assert.True(t, measured.Measured)
+	assert.Equal(t, config.TransferDirectConnect, measured.EgressClass)
+	assert.InDelta(t, 10.0, measured.EgressGB, 1e-9, "a quarter of 40 GB leaves AWS")
+	assert.InDelta(t, 15.0, measured.CrossAZGB, 1e-9, "half of the rest crosses zones")
+	assert.InDelta(t, 10*0.02+15*0.02, measured.CostUSD, 1e-9)
+
+	estimated := EstimateTransfer(model, TransferUsage{Nodes: 4, NodeHours: 8, Zones: 1})
+	assert.False(t, estimated.Measured)
+	assert.InDelta(t, 2.0, estimated.EgressGB, 1e-9)
+	assert.Zero(t, estimated.CrossAZGB, "nodes in one zone")
+
+	model = config.DataTransferConfig{EgressShare: 1, NATGateway: true, EstimatedGBPerNodeHour: 1,
+		RatesPerGB: map[string]float64{config.TransferInternet: 0.05}}
+	viaNAT := EstimateTransfer(model, TransferUsage{Nodes: 1, NodeHours: 10})
+	assert.Equal(t, config.TransferInternet, viaNAT.EgressClass)
+	assert.InDelta(t, 10*(0.05+0.045), viaNAT.CostUSD, 1e-9, "configured rate plus NAT processing")
+}
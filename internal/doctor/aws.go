@@ -0,0 +1,159 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+)
+
+// AWSProbe answers the questions the AWS checks ask; *aws.Diagnostics implements it
+type AWSProbe interface {
+	Identity(ctx context.Context) (*aws.CredentialInfo, error)
+	SubnetFreeIPs(ctx context.Context, region string, subnetIDs []string) (map[string]int, error)
+	SecurityGroups(ctx context.Context, region string, groupIDs []string) (map[string]bool, error)
+	SecurityGroupRules(ctx context.Context, region string, groupIDs []string) ([]aws.SecurityGroupRule, error)
+	LaunchTemplateExists(ctx context.Context, region string, spec config.LaunchTemplateSpec) (bool, error)
+	OfferedInstanceTypes(ctx context.Context, region string, instanceTypes []string) (map[string]bool, error)
+	DryRunFleet(ctx context.Context, region string, nodeGroup *config.NodeGroupConfig) error
+}
+
+// AWSChecks verify the credentials and each node group's subnets, security groups
+// and their rules, launch template and launch permission. connect creates the
+// probe; when it fails, the node group checks are skipped. ports reads the
+// Slurm ports the security group rules must allow.
+func AWSChecks(cfg *config.Config, ports func(ctx context.Context) SlurmPorts, connect func(ctx context.Context) (AWSProbe, error)) []Check {
+	var probe AWSProbe
+	var connectErr error
+
+	checks := []Check{{Category: "AWS", Name: "credentials", Run: func(ctx context.Context) []Result {
+		probe, connectErr = connect(ctx)
+		if connectErr != nil {
+			return []Result{fail(fmt.Sprintf("Check aws.authentication_method (%s) and the credentials it uses, e.g. the instance profile", cfg.AWS.AuthenticationMethod),
+				"%v", connectErr)}
+		}
+		identity, err := probe.Identity(ctx)
+		if err != nil {
+			return []Result{fail("Check the credentials with aws sts get-caller-identity", "%v", err)}
+		}
+		return []Result{pass("%s in account %s", identity.ARN, identity.Account)}
+	}}}
+
+	controller, _ := cfg.NetworkCheck.ControllerNetwork()
+	for _, partition := range cfg.Slurm.Partitions {
+		for i := range partition.NodeGroups {
+			nodeGroup := &partition.NodeGroups[i]
+			name := partition.PartitionName + "/" + nodeGroup.NodeGroupName
+			region := nodeGroup.Region
+			if region == "" {
+				region = cfg.AWS.Region
+			}
+
+			checks = append(checks, Check{Category: "AWS", Name: name, Run: func(ctx context.Context) []Result {
+				if connectErr != nil || probe == nil {
+					return []Result{skip("needs working AWS credentials")}
+				}
+				return []Result{
+					checkSubnets(ctx, probe, name, region, nodeGroup),
+					checkSecurityGroups(ctx, probe, name, region, nodeGroup),
+					checkSecurityGroupRules(ctx, probe, name, region, nodeGroup, controller, ports(ctx)),
+					checkLaunchTemplate(ctx, probe, name, region, nodeGroup),
+					checkLaunchPermission(ctx, probe, name, region, nodeGroup),
+				}
+			}})
+		}
+	}
+	return checks
+}
+
+func checkSubnets(ctx context.Context, probe AWSProbe, name, region string, nodeGroup *config.NodeGroupConfig) Result {
+	result := Result{Name: name + " subnets"}
+	if len(nodeGroup.SubnetIds) == 0 {
+		return withStatus(result, fail("Add subnet_ids to the node group", "no subnets configured"))
+	}
+
+	free, err := probe.SubnetFreeIPs(ctx, region, nodeGroup.SubnetIds)
+	if err != nil {
+		return withStatus(result, fail("Grant ec2:DescribeSubnets", "cannot describe subnets in %s: %v", region, err))
+	}
+	var missing []string
+	total := 0
+	for _, id := range nodeGroup.SubnetIds {
+		ips, ok := free[id]
+		if !ok {
+			missing = append(missing, id)
+		}
+		total += ips
+	}
+	if len(missing) > 0 {
+		return withStatus(result, fail("Fix subnet_ids; subnets must be in "+region,
+			"subnets not found in %s: %s", region, strings.Join(missing, ", ")))
+	}
+	if total < nodeGroup.MaxNodes {
+		return withStatus(result, warn("Add subnets or lower max_nodes",
+			"%d free IP addresses for up to %d nodes", total, nodeGroup.MaxNodes))
+	}
+	return withStatus(result, pass("%d subnets, %d free IP addresses", len(nodeGroup.SubnetIds), total))
+}
+
+func checkSecurityGroups(ctx context.Context, probe AWSProbe, name, region string, nodeGroup *config.NodeGroupConfig) Result {
+	result := Result{Name: name + " security groups"}
+	if len(nodeGroup.SecurityGroupIds) == 0 {
+		return withStatus(result, skip("none configured; the launch template's groups apply"))
+	}
+
+	found, err := probe.SecurityGroups(ctx, region, nodeGroup.SecurityGroupIds)
+	if err != nil {
+		return withStatus(result, fail("Grant ec2:DescribeSecurityGroups", "cannot describe security groups in %s: %v", region, err))
+	}
+	var missing []string
+	for _, id := range nodeGroup.SecurityGroupIds {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return withStatus(result, fail("Fix security_group_ids; groups must be in the subnets' VPC",
+			"security groups not found in %s: %s", region, strings.Join(missing, ", ")))
+	}
+	return withStatus(result, pass("%d security groups", len(nodeGroup.SecurityGroupIds)))
+}
+
+func checkLaunchTemplate(ctx context.Context, probe AWSProbe, name, region string, nodeGroup *config.NodeGroupConfig) Result {
+	result := Result{Name: name + " launch template"}
+	spec := nodeGroup.LaunchTemplateSpec
+	label := spec.LaunchTemplateID
+	if label == "" {
+		label = spec.LaunchTemplateName
+	}
+	if label == "" {
+		return withStatus(result, fail("Set launch_template_specification.launch_template_name or launch_template_id", "no launch template configured"))
+	}
+
+	exists, err := probe.LaunchTemplateExists(ctx, region, spec)
+	if err != nil {
+		return withStatus(result, fail("Grant ec2:DescribeLaunchTemplates", "cannot describe launch templates in %s: %v", region, err))
+	}
+	if !exists {
+		return withStatus(result, fail("Create the launch template or fix launch_template_specification",
+			"launch template %s not found in %s", label, region))
+	}
+	return withStatus(result, pass("%s", label))
+}
+
+func checkLaunchPermission(ctx context.Context, probe AWSProbe, name, region string, nodeGroup *config.NodeGroupConfig) Result {
+	result := Result{Name: name + " launch permission"}
+	if err := probe.DryRunFleet(ctx, region, nodeGroup); err != nil {
+		return withStatus(result, fail("Grant ec2:CreateFleet, ec2:RunInstances, ec2:CreateTags and iam:PassRole for the instance profile",
+			"CreateFleet dry run failed: %v", err))
+	}
+	return withStatus(result, pass("CreateFleet dry run allowed"))
+}
+
+// withStatus fills result's outcome from a finding
+func withStatus(result, finding Result) Result {
+	result.Status, result.Message, result.Remediation = finding.Status, finding.Message, finding.Remediation
+	return result
+}
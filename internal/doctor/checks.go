@@ -0,0 +1,244 @@
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ecosystem"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+)
+
+// Clock skew limits. AWS rejects requests signed more than five minutes off.
+const (
+	clockSkewWarn = 30 * time.Second
+	clockSkewFail = 5 * time.Minute
+)
+
+// ConfigCheck reports whether the configuration loaded and validated
+func ConfigCheck(path string, loadErr error) Check {
+	return Check{Category: "Configuration", Name: "config", Run: func(ctx context.Context) []Result {
+		if loadErr != nil {
+			return []Result{fail("Fix the reported field, then run aws-slurm-burst-validate config "+path, "%v", loadErr)}
+		}
+		return []Result{pass("%s is valid", path)}
+	}}
+}
+
+// SlurmChecks verify the Slurm client binaries, that slurmctld answers and that
+// power saving calls aws-slurm-burst
+func SlurmChecks(slurmConfig *config.SlurmConfig, run Runner) []Check {
+	binary := func(name string) string { return slurmConfig.BinPath + name }
+
+	return []Check{
+		{Category: "Slurm", Name: "binaries", Run: func(ctx context.Context) []Result {
+			var results []Result
+			for _, name := range []string{"scontrol", "squeue", "sinfo", "sacct"} {
+				output, err := run(ctx, binary(name), "--version")
+				switch {
+				case err == nil:
+					results = append(results, Result{Name: name, Status: StatusPass, Message: strings.TrimSpace(string(output))})
+				case name == "sacct":
+					results = append(results, Result{Name: name, Status: StatusWarn, Message: fmt.Sprintf("%s not usable: %v", binary(name), err),
+						Remediation: "Install Slurm accounting (slurmdbd) so performance and cost exports can read finished jobs"})
+				default:
+					results = append(results, Result{Name: name, Status: StatusFail, Message: fmt.Sprintf("%s not usable: %v", binary(name), err),
+						Remediation: "Install the Slurm client tools or set slurm.bin_path to their directory"})
+				}
+			}
+			return results
+		}},
+		{Category: "Slurm", Name: "slurmctld", Run: func(ctx context.Context) []Result {
+			output, err := run(ctx, binary("scontrol"), "ping")
+			if err != nil || !strings.Contains(string(output), "is UP") {
+				return []Result{fail("Check systemctl status slurmctld and that this host can reach the controller",
+					"slurmctld is not responding: %s", firstLine(output, err))}
+			}
+			return []Result{pass("%s", firstLine(output, nil))}
+		}},
+		{Category: "Slurm", Name: "power-saving", Run: func(ctx context.Context) []Result {
+			output, err := run(ctx, binary("scontrol"), "show", "config")
+			if err != nil {
+				return []Result{skip("slurm.conf not readable: %v", err)}
+			}
+			settings := parseSlurmConfig(string(output))
+
+			var results []Result
+			for _, program := range []struct{ key, expected, binary string }{
+				{"ResumeProgram", slurmConfig.ResumeProgram, "aws-slurm-burst-resume"},
+				{"SuspendProgram", slurmConfig.SuspendProgram, "aws-slurm-burst-suspend"},
+			} {
+				actual := settings[program.key]
+				ok := actual == program.expected
+				if program.expected == "" {
+					ok = strings.Contains(filepath.Base(actual), program.binary)
+				}
+				if ok {
+					results = append(results, Result{Name: program.key, Status: StatusPass, Message: actual})
+					continue
+				}
+				want := program.expected
+				if want == "" {
+					want = "/usr/local/bin/" + program.binary
+				}
+				results = append(results, Result{Name: program.key, Status: StatusFail,
+					Message:     fmt.Sprintf("slurm.conf has %s=%s", program.key, actual),
+					Remediation: fmt.Sprintf("Set %s=%s in slurm.conf and run scontrol reconfigure", program.key, want)})
+			}
+			return results
+		}},
+		{Category: "Slurm", Name: "version", Run: func(ctx context.Context) []Result {
+			output, err := run(ctx, binary("scontrol"), "--version")
+			if err != nil {
+				return []Result{skip("scontrol not usable: %v", err)}
+			}
+			version, err := slurm.ParseVersion(string(output))
+			if err != nil {
+				return []Result{warn("Check that slurm.bin_path points at the Slurm client tools", "%v", err)}
+			}
+
+			var slurmctldParameters string
+			if config, err := run(ctx, binary("scontrol"), "show", "config"); err == nil {
+				slurmctldParameters = parseSlurmConfig(string(config))["SlurmctldParameters"]
+			}
+
+			var results []Result
+			for _, warning := range slurm.VersionWarnings(version, slurmctldParameters) {
+				if version.Less(slurm.MinimumVersion) {
+					results = append(results, fail("Upgrade Slurm on the controller and this host", "%s", warning))
+					break
+				}
+				results = append(results, warn("Upgrade Slurm to use the feature", "%s", warning))
+			}
+			if len(results) == 0 {
+				results = append(results, pass("Slurm %s supports every feature aws-slurm-burst uses", version))
+			}
+			return results
+		}},
+	}
+}
+
+// parseSlurmConfig reads the "Key = Value" lines of scontrol show config
+func parseSlurmConfig(output string) map[string]string {
+	settings := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if found {
+			settings[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return settings
+}
+
+// EcosystemCheck reports the companion tools found. Missing tools are skipped
+// rather than failed, since standalone mode needs neither.
+func EcosystemCheck(detect func(ctx context.Context) *ecosystem.EcosystemStatus) Check {
+	return Check{Category: "Ecosystem", Name: "companions", Run: func(ctx context.Context) []Result {
+		status := detect(ctx)
+
+		asba := Result{Name: "asba"}
+		if status.ASBA.Available {
+			asba.Status, asba.Message = StatusPass, fmt.Sprintf("%s %s", status.ASBA.Command, status.ASBA.Version)
+			switch {
+			case status.ASBA.CompatibilityErr != "":
+				asba.Status, asba.Remediation = StatusFail, "Upgrade aws-slurm-burst or ASBA so they share a plan schema, or set asba.incompatible_plans to standalone"
+				asba.Message += ": " + status.ASBA.CompatibilityErr
+			case !status.ASBA.SupportsExecPlan:
+				asba.Status, asba.Remediation = StatusWarn, "Upgrade ASBA to a version with execution plan output"
+				asba.Message += " without execution plan support"
+			case status.ASBA.PlanSchema != "":
+				asba.Message += ", plan schema " + status.ASBA.PlanSchema
+			}
+		} else {
+			asba.Status, asba.Message = StatusSkip, "not installed; resume runs in standalone mode"
+		}
+
+		asbb := Result{Name: "asbb"}
+		if status.ASBB.Available {
+			asbb.Status, asbb.Message = StatusPass, fmt.Sprintf("%s %s", status.ASBB.Command, status.ASBB.Version)
+		} else {
+			asbb.Status, asbb.Message = StatusSkip, "not installed; budget reconciliation is off"
+		}
+		return []Result{asba, asbb}
+	}}
+}
+
+// ExchangeCheck verifies the data exchange directory layout and atomic writes
+func ExchangeCheck(dir string) Check {
+	return Check{Category: "Ecosystem", Name: "data-exchange-dir", Run: func(ctx context.Context) []Result {
+		problems := exchange.Check(dir)
+		if len(problems) == 0 {
+			return []Result{pass("%s is writable", dir)}
+		}
+
+		messages := make([]string, len(problems))
+		for i, problem := range problems {
+			messages[i] = problem.Error()
+		}
+		return []Result{fail("Run aws-slurm-burst-validate exchange --init with the same config, as the Slurm user",
+			"%s", strings.Join(messages, "; "))}
+	}}
+}
+
+// ClockCheck compares the local clock with the Date header of url, normally the
+// region's STS endpoint
+func ClockCheck(client *http.Client, url string) Check {
+	return Check{Category: "System", Name: "clock-skew", Run: func(ctx context.Context) []Result {
+		skew, err := ClockSkew(ctx, client, url)
+		if err != nil {
+			return []Result{skip("could not read the time from %s: %v", url, err)}
+		}
+
+		remediation := "Enable time sync (chronyd or systemd-timesyncd) on this host and the burst nodes' AMI"
+		switch abs := time.Duration(math.Abs(float64(skew))).Round(time.Second); {
+		case abs >= clockSkewFail:
+			return []Result{fail(remediation, "clock is %s off; AWS rejects signed requests", abs)}
+		case abs >= clockSkewWarn:
+			return []Result{warn(remediation, "clock is %s off", abs)}
+		default:
+			return []Result{pass("clock within %s", clockSkewWarn)}
+		}
+	}}
+}
+
+// ClockSkew returns how far the local clock is ahead of the server at url,
+// measured against the midpoint of the request
+func ClockSkew(ctx context.Context, client *http.Client, url string) (time.Duration, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	received := time.Now()
+
+	serverTime, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("no usable Date header: %w", err)
+	}
+	midpoint := sent.Add(received.Sub(sent) / 2)
+	// Date has one-second resolution
+	return midpoint.Sub(serverTime.Add(500 * time.Millisecond)), nil
+}
+
+// firstLine returns the first line of a command's output, or its error
+func firstLine(output []byte, err error) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	if line == "" && err != nil {
+		return err.Error()
+	}
+	return line
+}
@@ -0,0 +1,142 @@
+// Package doctor runs the environment checks behind aws-slurm-burst-doctor. Each
+// check reports pass, warn, fail or skip with a remediation step for anything
+// that needs fixing.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Result is one finding
+type Result struct {
+	Category    string `json:"category"`
+	Name        string `json:"name"`
+	Status      Status `json:"status"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Check produces the findings for one area. Checks that cover several resources,
+// such as one per node group, return a result for each.
+type Check struct {
+	Category string
+	Name     string
+	Run      func(ctx context.Context) []Result
+}
+
+// Report is the outcome of a doctor run
+type Report struct {
+	Results  []Result  `json:"results"`
+	Passed   int       `json:"passed"`
+	Warnings int       `json:"warnings"`
+	Failed   int       `json:"failed"`
+	Skipped  int       `json:"skipped"`
+	Healthy  bool      `json:"healthy"` // No check failed
+	RunAt    time.Time `json:"run_at"`
+}
+
+// Runner executes a program; it is replaced in tests
+type Runner func(ctx context.Context, program string, args ...string) ([]byte, error)
+
+// Run runs the checks in order, each with its own timeout
+func Run(ctx context.Context, checks []Check, timeout time.Duration) *Report {
+	report := &Report{RunAt: time.Now().UTC()}
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		results := check.Run(checkCtx)
+		if checkCtx.Err() == context.DeadlineExceeded && len(results) == 0 {
+			results = []Result{{Status: StatusFail, Message: fmt.Sprintf("timed out after %s", timeout)}}
+		}
+		cancel()
+
+		for _, result := range results {
+			if result.Category == "" {
+				result.Category = check.Category
+			}
+			if result.Name == "" {
+				result.Name = check.Name
+			}
+			report.add(result)
+		}
+	}
+	report.Healthy = report.Failed == 0
+	return report
+}
+
+func (r *Report) add(result Result) {
+	r.Results = append(r.Results, result)
+	switch result.Status {
+	case StatusPass:
+		r.Passed++
+	case StatusWarn:
+		r.Warnings++
+	case StatusFail:
+		r.Failed++
+	default:
+		r.Skipped++
+	}
+}
+
+// WriteJSON writes the report for scripts and monitoring
+func WriteJSON(w io.Writer, report *Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// WriteText writes the report for people, with the remediation under each problem
+func WriteText(w io.Writer, report *Report) error {
+	icons := map[Status]string{StatusPass: "✅", StatusWarn: "⚠️ ", StatusFail: "❌", StatusSkip: "⏭️ "}
+
+	category := ""
+	for _, result := range report.Results {
+		if result.Category != category {
+			category = result.Category
+			if _, err := fmt.Fprintf(w, "\n%s\n", category); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "  %s %s: %s\n", icons[result.Status], result.Name, result.Message); err != nil {
+			return err
+		}
+		if result.Remediation != "" && (result.Status == StatusWarn || result.Status == StatusFail) {
+			if _, err := fmt.Fprintf(w, "     → %s\n", result.Remediation); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		report.Passed, report.Warnings, report.Failed, report.Skipped)
+	return err
+}
+
+func pass(message string, args ...interface{}) Result {
+	return Result{Status: StatusPass, Message: fmt.Sprintf(message, args...)}
+}
+
+func skip(message string, args ...interface{}) Result {
+	return Result{Status: StatusSkip, Message: fmt.Sprintf(message, args...)}
+}
+
+func warn(remediation, message string, args ...interface{}) Result {
+	return Result{Status: StatusWarn, Message: fmt.Sprintf(message, args...), Remediation: remediation}
+}
+
+func fail(remediation, message string, args ...interface{}) Result {
+	return Result{Status: StatusFail, Message: fmt.Sprintf(message, args...), Remediation: remediation}
+}
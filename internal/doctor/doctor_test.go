@@ -0,0 +1,356 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ecosystem"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// fakeSlurm answers scontrol, squeue, sinfo and sacct from canned output
+func fakeSlurm(outputs map[string]string) Runner {
+	return func(ctx context.Context, program string, args ...string) ([]byte, error) {
+		key := strings.TrimPrefix(program, "/usr/bin/") + " " + strings.Join(args, " ")
+		output, ok := outputs[key]
+		if !ok {
+			return nil, errors.New("executable file not found")
+		}
+		return []byte(output), nil
+	}
+}
+
+func TestSlurmChecks(t *testing.T) {
+	slurmConfig := &config.SlurmConfig{BinPath: "/usr/bin/"}
+	run := fakeSlurm(map[string]string{
+		"scontrol --version": "slurm 23.11.4",
+		"squeue --version":   "slurm 23.11.4",
+		"sinfo --version":    "slurm 23.11.4",
+		"scontrol ping":      "Slurmctld(primary) at head is UP\n",
+		"scontrol show config": "Configuration data as of 2026-10-17T12:00:00\n" +
+			"ResumeProgram           = /usr/local/bin/aws-slurm-burst-resume\n" +
+			"SuspendProgram          = (null)\n",
+	})
+
+	report := Run(context.Background(), SlurmChecks(slurmConfig, run), time.Second)
+
+	byName := make(map[string]Result)
+	for _, result := range report.Results {
+		byName[result.Name] = result
+	}
+	assert.Equal(t, StatusPass, byName["scontrol"].Status)
+	assert.Equal(t, StatusWarn, byName["sacct"].Status, "accounting is only needed for exports")
+	assert.Equal(t, StatusPass, byName["slurmctld"].Status)
+	assert.Equal(t, StatusPass, byName["ResumeProgram"].Status)
+	assert.Equal(t, StatusFail, byName["SuspendProgram"].Status)
+	assert.Contains(t, byName["SuspendProgram"].Remediation, "SuspendProgram=/usr/local/bin/aws-slurm-burst-suspend")
+	assert.Equal(t, 1, report.Failed)
+	assert.False(t, report.Healthy)
+
+	slurmConfig.ResumeProgram = "/opt/burst/resume.sh"
+	report = Run(context.Background(), SlurmChecks(slurmConfig, run)[2:], time.Second)
+	assert.Equal(t, StatusFail, report.Results[0].Status, "configured resume program must match slurm.conf")
+}
+
+func TestSlurmChecks_Version(t *testing.T) {
+	slurmConfig := &config.SlurmConfig{BinPath: "/usr/bin/"}
+	versionCheck := func(version, parameters string) []Result {
+		run := fakeSlurm(map[string]string{
+			"scontrol --version":   version,
+			"scontrol show config": "SlurmctldParameters     = " + parameters + "\n",
+		})
+		checks := SlurmChecks(slurmConfig, run)
+		return Run(context.Background(), checks[len(checks)-1:], time.Second).Results
+	}
+
+	results := versionCheck("slurm 23.11.4", "cloud_reg_addrs")
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusPass, results[0].Status)
+
+	results = versionCheck("slurm 21.08.8", "cloud_reg_addrs")
+	require.Len(t, results, 2)
+	assert.Equal(t, StatusWarn, results[0].Status)
+	assert.Contains(t, results[0].Message, "cloud_reg_addrs")
+
+	results = versionCheck("slurm 20.11.9", "")
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusFail, results[0].Status)
+}
+
+type fakeProbe struct {
+	subnets  map[string]int
+	groups   map[string]bool
+	rules    []aws.SecurityGroupRule
+	template bool
+	offered  map[string]bool
+	fleetErr error
+}
+
+func (f *fakeProbe) Identity(ctx context.Context) (*aws.CredentialInfo, error) {
+	return &aws.CredentialInfo{Account: "123456789012", ARN: "arn:aws:sts::123456789012:assumed-role/slurm/i-1"}, nil
+}
+
+func (f *fakeProbe) SubnetFreeIPs(ctx context.Context, region string, ids []string) (map[string]int, error) {
+	return f.subnets, nil
+}
+
+func (f *fakeProbe) SecurityGroups(ctx context.Context, region string, ids []string) (map[string]bool, error) {
+	return f.groups, nil
+}
+
+func (f *fakeProbe) SecurityGroupRules(ctx context.Context, region string, ids []string) ([]aws.SecurityGroupRule, error) {
+	return f.rules, nil
+}
+
+func (f *fakeProbe) LaunchTemplateExists(ctx context.Context, region string, spec config.LaunchTemplateSpec) (bool, error) {
+	return f.template, nil
+}
+
+func (f *fakeProbe) OfferedInstanceTypes(ctx context.Context, region string, instanceTypes []string) (map[string]bool, error) {
+	return f.offered, nil
+}
+
+func (f *fakeProbe) DryRunFleet(ctx context.Context, region string, nodeGroup *config.NodeGroupConfig) error {
+	return f.fleetErr
+}
+
+func TestAWSChecks(t *testing.T) {
+	cfg := &config.Config{
+		AWS: config.AWSConfig{Region: "us-east-1"},
+		Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{{
+			PartitionName: "aws",
+			NodeGroups: []config.NodeGroupConfig{{
+				NodeGroupName:      "cpu",
+				MaxNodes:           10,
+				SubnetIds:          []string{"subnet-a", "subnet-b"},
+				SecurityGroupIds:   []string{"sg-1"},
+				LaunchTemplateSpec: config.LaunchTemplateSpec{LaunchTemplateName: "burst"},
+			}},
+		}}},
+	}
+
+	probe := &fakeProbe{subnets: map[string]int{"subnet-a": 4, "subnet-b": 3}, groups: map[string]bool{}, template: true, fleetErr: errors.New("UnauthorizedOperation")}
+	ports := func(ctx context.Context) SlurmPorts { return DefaultSlurmPorts }
+	report := Run(context.Background(), AWSChecks(cfg, ports, func(ctx context.Context) (AWSProbe, error) { return probe, nil }), time.Second)
+
+	statuses := make(map[string]Status)
+	for _, result := range report.Results {
+		statuses[result.Name] = result.Status
+	}
+	assert.Equal(t, StatusPass, statuses["credentials"])
+	assert.Equal(t, StatusWarn, statuses["aws/cpu subnets"], "7 free IPs for 10 nodes")
+	assert.Equal(t, StatusFail, statuses["aws/cpu security groups"])
+	assert.Equal(t, StatusPass, statuses["aws/cpu launch template"])
+	assert.Equal(t, StatusFail, statuses["aws/cpu launch permission"])
+	assert.Equal(t, StatusFail, statuses["aws/cpu security group rules"], "no rules")
+
+	report = Run(context.Background(), AWSChecks(cfg, ports, func(ctx context.Context) (AWSProbe, error) {
+		return nil, errors.New("no EC2 IMDS role found")
+	}), time.Second)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, StatusFail, report.Results[0].Status)
+	assert.Equal(t, StatusSkip, report.Results[1].Status, "node groups need credentials")
+}
+
+func TestCheckSecurityGroupRules(t *testing.T) {
+	nodeGroup := &config.NodeGroupConfig{SecurityGroupIds: []string{"sg-nodes", "sg-slurm"}}
+	controller := netip.MustParsePrefix("192.168.10.0/24")
+	ports := ReadSlurmPorts(context.Background(), &config.SlurmConfig{BinPath: "/usr/bin/"}, fakeSlurm(map[string]string{
+		"scontrol show config": "SlurmctldPort           = 6817-6820\nSlurmdPort              = 6818\nSrunPortRange           = 60001-63000\n",
+	}))
+	assert.Equal(t, SlurmPorts{Slurmctld: 6817, Slurmd: 6818, SrunLow: 60001, SrunHigh: 63000, SrunPortRange: true}, ports)
+	assert.Equal(t, DefaultSlurmPorts, ReadSlurmPorts(context.Background(), &config.SlurmConfig{}, fakeSlurm(nil)))
+
+	rule := func(egress bool, protocol string, from, to int32, peer string) aws.SecurityGroupRule {
+		rule := aws.SecurityGroupRule{GroupID: "sg-slurm", Egress: egress, Protocol: protocol, FromPort: from, ToPort: to, CIDR: peer}
+		if strings.HasPrefix(peer, "sg-") {
+			rule.CIDR, rule.Group = "", peer
+		}
+		return rule
+	}
+	probe := &fakeProbe{rules: []aws.SecurityGroupRule{
+		rule(false, "-1", -1, -1, "sg-nodes"),
+		rule(true, "-1", -1, -1, "sg-nodes"),
+		rule(false, "tcp", 6818, 6818, "192.168.0.0/16"),
+		rule(true, "tcp", 6817, 6817, "192.168.10.5/32"),
+		rule(true, "tcp", 60001, 62000, "192.168.10.0/24"),
+		rule(true, "tcp", 62001, 63000, "192.168.10.0/24"),
+	}}
+	check := func() Result {
+		return checkSecurityGroupRules(context.Background(), probe, "aws/cpu", "us-east-1", nodeGroup, controller, ports)
+	}
+
+	// slurmd reaches only the controller itself, not the login nodes in the CIDR
+	result := check()
+	assert.Equal(t, StatusFail, result.Status)
+	assert.Equal(t, "missing outbound TCP 6817 to 192.168.10.0/24 (slurmd to slurmctld)", result.Message)
+	assert.Equal(t, "Add the missing rules to sg-nodes, sg-slurm", result.Remediation)
+
+	probe.rules[3].CIDR = "0.0.0.0/0"
+	assert.Equal(t, StatusPass, check().Status, "split srun range, open egress")
+
+	probe.rules[4].ToPort = 61000
+	assert.Contains(t, check().Message, "outbound TCP 60001-63000 to 192.168.10.0/24 (srun on SrunPortRange)")
+
+	// Only traffic between nodes is checked without the controller network
+	probe.rules = probe.rules[1:]
+	result = checkSecurityGroupRules(context.Background(), probe, "aws/cpu", "us-east-1", nodeGroup, netip.Prefix{}, ports)
+	assert.Equal(t, "missing inbound all traffic from the node group's security group itself (MPI and EFA between nodes)", result.Message)
+	probe.rules = append(probe.rules, rule(false, "-1", -1, -1, "0.0.0.0/0"))
+	assert.Equal(t, StatusWarn, checkSecurityGroupRules(context.Background(), probe, "aws/cpu", "us-east-1", nodeGroup, netip.Prefix{}, ports).Status)
+}
+
+func TestPlanChecks(t *testing.T) {
+	cfg := &config.Config{
+		AWS: config.AWSConfig{Region: "us-east-1"},
+		Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{{
+			PartitionName: "aws",
+			NodeGroups: []config.NodeGroupConfig{{
+				NodeGroupName:      "hpc",
+				SubnetIds:          []string{"subnet-a"},
+				SecurityGroupIds:   []string{"sg-1"},
+				LaunchTemplateSpec: config.LaunchTemplateSpec{LaunchTemplateName: "burst"},
+			}},
+		}}},
+	}
+	plan := &types.ExecutionPlan{
+		SchemaVersion: types.PlanSchemaVersion,
+		ShouldBurst:   true,
+		InstanceSpec: types.InstanceSpecification{
+			InstanceTypes:      []string{"hpc7a.96xlarge", "c6i.32xlarge", "c7i"},
+			PurchasingOption:   "on-demand",
+			SubnetIds:          []string{"subnet-a", "subnet-z"},
+			SecurityGroupIds:   []string{"sg-1", "sg-2"},
+			LaunchTemplateName: "burst",
+		},
+		MPIConfig:     types.MPIConfiguration{IsMPIJob: true, ProcessCount: 384, RequiresGangScheduling: true, RequiresEFA: true},
+		NetworkConfig: types.NetworkConfiguration{PlacementGroupType: "cluster", EnhancedNetworking: true},
+	}
+	probe := &fakeProbe{
+		subnets: map[string]int{},
+		groups:  map[string]bool{"sg-2": true},
+		offered: map[string]bool{"hpc7a.96xlarge": true, "c6i.32xlarge": false},
+	}
+
+	statuses := func(report *Report) map[string]Status {
+		byName := make(map[string]Status)
+		for _, result := range report.Results {
+			byName[result.Name] = result.Status
+		}
+		return byName
+	}
+
+	report := Run(context.Background(), PlanChecks(cfg, plan, "aws", func(ctx context.Context) (AWSProbe, error) { return probe, nil }), time.Second)
+	byName := statuses(report)
+	assert.Equal(t, StatusPass, byName["schema"])
+	assert.Equal(t, StatusFail, byName["subnets"], "subnet-z is neither configured nor in AWS")
+	assert.Equal(t, StatusWarn, byName["security groups"], "sg-2 exists but no node group uses it")
+	assert.Equal(t, StatusPass, byName["launch template"])
+	assert.Equal(t, StatusFail, byName["instance types in us-east-1"], "c6i.32xlarge lacks EFA")
+	assert.Equal(t, StatusPass, byName["MPI/EFA"])
+	assert.False(t, report.Healthy)
+
+	// Offline, resources outside the configuration only warn and offerings are skipped
+	plan.MPIConfig.GPUDirectRDMA, plan.MPIConfig.RequiresEFA = true, false
+	report = Run(context.Background(), PlanChecks(cfg, plan, "", nil), time.Second)
+	byName = statuses(report)
+	assert.Equal(t, StatusWarn, byName["subnets"])
+	assert.Equal(t, StatusSkip, byName["instance types"])
+	assert.Equal(t, StatusFail, byName["MPI/EFA"], "GPUDirect RDMA needs EFA")
+
+	plan.MPIConfig.GPUDirectRDMA, plan.MPIConfig.Accelerator = false, types.AcceleratorNeuron
+	byName = statuses(Run(context.Background(), PlanChecks(cfg, plan, "", nil), time.Second))
+	assert.Equal(t, StatusFail, byName["MPI/EFA"], "Neuron setup on non-Neuron instances")
+}
+
+func TestEcosystemCheck(t *testing.T) {
+	report := Run(context.Background(), []Check{EcosystemCheck(func(ctx context.Context) *ecosystem.EcosystemStatus {
+		return &ecosystem.EcosystemStatus{ASBA: ecosystem.ASBAStatus{Available: true, Command: "asba", Version: "1.2.0"}}
+	})}, time.Second)
+
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, StatusWarn, report.Results[0].Status, "ASBA without execution plans")
+	assert.Equal(t, StatusSkip, report.Results[1].Status)
+	assert.True(t, report.Healthy)
+}
+
+func TestMungeKeyCheck(t *testing.T) {
+	key := bytes.Repeat([]byte{0x5a}, 1024)
+	keyFile := filepath.Join(t.TempDir(), "munge.key")
+	require.NoError(t, os.WriteFile(keyFile, key, 0o400))
+	mungeKey := &config.MungeKeyConfig{Source: config.MungeKeySourceSSM, Parameter: "/slurm/munge-key", KeyFile: keyFile}
+	getParameter := "aws ssm get-parameter --region us-west-2 --name /slurm/munge-key --with-decryption --query Parameter.Value --output text"
+
+	check := func(outputs map[string]string) Result {
+		report := Run(context.Background(), []Check{MungeKeyCheck(mungeKey, "us-west-2", fakeSlurm(outputs))}, time.Second)
+		require.Len(t, report.Results, 1)
+		return report.Results[0]
+	}
+
+	assert.Equal(t, StatusPass, check(map[string]string{getParameter: base64.StdEncoding.EncodeToString(key) + "\n"}).Status)
+	assert.Equal(t, StatusWarn, check(nil).Status, "the head node may not be allowed to read it")
+
+	other := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x11}, 1024))
+	result := check(map[string]string{getParameter: other})
+	assert.Equal(t, StatusFail, result.Status)
+	assert.Contains(t, result.Message, "differs")
+	assert.Equal(t, StatusFail, check(map[string]string{getParameter: base64.StdEncoding.EncodeToString([]byte("short"))}).Status)
+
+	mungeKey.Source, mungeKey.S3URL = config.MungeKeySourceS3, "s3://cluster-secrets/munge.key"
+	assert.Equal(t, StatusPass, check(map[string]string{"aws s3 cp --only-show-errors s3://cluster-secrets/munge.key -": string(key)}).Status)
+}
+
+func TestClockSkew(t *testing.T) {
+	offset := 0 * time.Second
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(offset).UTC().Format(http.TimeFormat))
+	}))
+	defer server.Close()
+
+	skew, err := ClockSkew(context.Background(), server.Client(), server.URL)
+	require.NoError(t, err)
+	assert.InDelta(t, 0, skew.Seconds(), 1.5)
+
+	offset = -10 * time.Minute
+	report := Run(context.Background(), []Check{ClockCheck(server.Client(), server.URL)}, time.Second)
+	assert.Equal(t, StatusFail, report.Results[0].Status)
+
+	offset = time.Minute
+	report = Run(context.Background(), []Check{ClockCheck(server.Client(), server.URL)}, time.Second)
+	assert.Equal(t, StatusWarn, report.Results[0].Status)
+}
+
+func TestRunTimeoutAndOutput(t *testing.T) {
+	slow := Check{Category: "System", Name: "slow", Run: func(ctx context.Context) []Result {
+		<-ctx.Done()
+		return nil
+	}}
+	report := Run(context.Background(), []Check{slow, ConfigCheck("/etc/slurm/aws-burst.yaml", nil)}, 10*time.Millisecond)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, StatusFail, report.Results[0].Status)
+	assert.Equal(t, "slow", report.Results[0].Name)
+
+	var text bytes.Buffer
+	require.NoError(t, WriteText(&text, report))
+	assert.Contains(t, text.String(), "❌ slow: timed out")
+	assert.Contains(t, text.String(), "1 passed, 0 warnings, 1 failed, 0 skipped")
+
+	var data bytes.Buffer
+	require.NoError(t, WriteJSON(&data, report))
+	assert.Contains(t, data.String(), `"healthy": false`)
+}
@@ -0,0 +1,63 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/userdata"
+)
+
+// MungeKeyCheck fetches the munge key burst nodes install, with the AWS CLI
+// as the nodes do, and compares it with the controller's key. Nodes with a
+// different key cannot authenticate to slurmctld and never come up.
+func MungeKeyCheck(mungeKey *config.MungeKeyConfig, region string, run Runner) Check {
+	return Check{Category: "Slurm", Name: "munge-key", Run: func(ctx context.Context) []Result {
+		stored, err := fetchMungeKey(ctx, mungeKey, region, run)
+		if err != nil {
+			// The head node role does not need to read the key, only the nodes' role
+			return []Result{warn("Store the key as described in DEPLOYMENT.md, and check this host can read it to compare",
+				"could not fetch the munge key from %s: %v", mungeKey.Location(), err)}
+		}
+		if err := userdata.CheckMungeKey(stored); err != nil {
+			return []Result{fail("Store the controller's "+mungeKey.KeyFile+" at "+mungeKey.Location(), "%v", err)}
+		}
+
+		local, err := os.ReadFile(mungeKey.KeyFile)
+		if err != nil {
+			return []Result{warn("Run doctor as root to compare it with the controller's key",
+				"munge key at %s is readable but %s is not: %v", mungeKey.Location(), mungeKey.KeyFile, err)}
+		}
+		if !bytes.Equal(stored, local) {
+			return []Result{fail("Store the controller's "+mungeKey.KeyFile+" at "+mungeKey.Location(),
+				"munge key at %s differs from %s; burst nodes could not authenticate", mungeKey.Location(), mungeKey.KeyFile)}
+		}
+		return []Result{pass("munge key at %s matches %s", mungeKey.Location(), mungeKey.KeyFile)}
+	}}
+}
+
+// fetchMungeKey reads the stored key the way the node user-data script does
+func fetchMungeKey(ctx context.Context, mungeKey *config.MungeKeyConfig, region string, run Runner) ([]byte, error) {
+	args := []string{"ssm", "get-parameter", "--region", region, "--name", mungeKey.Parameter,
+		"--with-decryption", "--query", "Parameter.Value", "--output", "text"}
+	if mungeKey.Source == config.MungeKeySourceS3 {
+		args = []string{"s3", "cp", "--only-show-errors", mungeKey.S3URL, "-"}
+	}
+	output, err := run(ctx, "aws", args...)
+	if err != nil {
+		return nil, errors.New(firstLine(output, err))
+	}
+	if mungeKey.Source == config.MungeKeySourceS3 {
+		return output, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(output)))
+	if err != nil {
+		return nil, fmt.Errorf("parameter %s is not base64: %w", mungeKey.Parameter, err)
+	}
+	return key, nil
+}
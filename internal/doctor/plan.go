@@ -0,0 +1,303 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ecosystem"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// PlanChecks cross-validate an execution plan against the configuration: the
+// subnets, security groups and launch template it names, the instance types
+// it asks for and its MPI and EFA settings. Resume launches into the node
+// group's own network resources, so plan resources only pass when a node group
+// uses them. Only node groups of partition are considered, or all when it is
+// empty. A nil connect skips the checks that need AWS.
+func PlanChecks(cfg *config.Config, plan *types.ExecutionPlan, partition string, connect func(ctx context.Context) (AWSProbe, error)) []Check {
+	const category = "Execution plan"
+	nodeGroups := planNodeGroups(cfg, partition)
+	regions := nodeGroupRegions(cfg, nodeGroups)
+
+	var probe AWSProbe
+	var connectErr error
+	awsProbe := func(ctx context.Context) (AWSProbe, error) {
+		if probe == nil && connectErr == nil {
+			if connect == nil {
+				connectErr = fmt.Errorf("AWS checks disabled")
+			} else {
+				probe, connectErr = connect(ctx)
+			}
+		}
+		return probe, connectErr
+	}
+
+	configured := func(values func(nodeGroup *config.NodeGroupConfig) []string) map[string]bool {
+		set := make(map[string]bool)
+		for _, nodeGroup := range nodeGroups {
+			for _, value := range values(nodeGroup) {
+				set[value] = true
+			}
+		}
+		return set
+	}
+
+	return []Check{
+		{Category: category, Name: "schema", Run: func(ctx context.Context) []Result {
+			compat, err := ecosystem.NegotiatePlan(plan)
+			if err != nil {
+				return []Result{fail("Upgrade aws-slurm-burst or ASBA so they share a plan schema", "%v", err)}
+			}
+			if err := plan.ValidateExecutionPlan(); err != nil {
+				return []Result{fail("Fix the plan or regenerate it with ASBA", "%v", err)}
+			}
+			results := []Result{pass("schema %s", compat.Schema)}
+			for _, warning := range compat.Warnings {
+				results = append(results, warn("Upgrade aws-slurm-burst to use every plan field", "%s", warning))
+			}
+			return results
+		}},
+
+		{Category: category, Name: "subnets", Run: func(ctx context.Context) []Result {
+			inConfig := configured(func(nodeGroup *config.NodeGroupConfig) []string { return nodeGroup.SubnetIds })
+			return []Result{checkPlanResources(ctx, "subnets", plan.InstanceSpec.SubnetIds, inConfig, regions, awsProbe,
+				func(ctx context.Context, probe AWSProbe, region string, ids []string) (map[string]bool, error) {
+					free, err := probe.SubnetFreeIPs(ctx, region, ids)
+					found := make(map[string]bool, len(free))
+					for id := range free {
+						found[id] = true
+					}
+					return found, err
+				})}
+		}},
+
+		{Category: category, Name: "security groups", Run: func(ctx context.Context) []Result {
+			inConfig := configured(func(nodeGroup *config.NodeGroupConfig) []string { return nodeGroup.SecurityGroupIds })
+			return []Result{checkPlanResources(ctx, "security groups", plan.InstanceSpec.SecurityGroupIds, inConfig, regions, awsProbe,
+				func(ctx context.Context, probe AWSProbe, region string, ids []string) (map[string]bool, error) {
+					return probe.SecurityGroups(ctx, region, ids)
+				})}
+		}},
+
+		{Category: category, Name: "launch template", Run: func(ctx context.Context) []Result {
+			spec := config.LaunchTemplateSpec{
+				LaunchTemplateName: plan.InstanceSpec.LaunchTemplateName,
+				LaunchTemplateID:   plan.InstanceSpec.LaunchTemplateID,
+			}
+			label := spec.LaunchTemplateID
+			if label == "" {
+				label = spec.LaunchTemplateName
+			}
+			if label == "" {
+				return []Result{skip("the plan names none; node groups' templates apply")}
+			}
+			inConfig := configured(func(nodeGroup *config.NodeGroupConfig) []string {
+				return []string{nodeGroup.LaunchTemplateSpec.LaunchTemplateName, nodeGroup.LaunchTemplateSpec.LaunchTemplateID}
+			})
+			return []Result{checkPlanResources(ctx, "launch template", []string{label}, inConfig, regions, awsProbe,
+				func(ctx context.Context, probe AWSProbe, region string, ids []string) (map[string]bool, error) {
+					exists, err := probe.LaunchTemplateExists(ctx, region, spec)
+					return map[string]bool{label: exists}, err
+				})}
+		}},
+
+		{Category: category, Name: "instance types", Run: func(ctx context.Context) []Result {
+			instanceTypes := planInstanceTypes(plan)
+			if len(instanceTypes) == 0 {
+				return []Result{skip("the plan names no instance types")}
+			}
+			probe, err := awsProbe(ctx)
+			if err != nil {
+				return []Result{skip("needs AWS to check offerings: %v", err)}
+			}
+
+			var results []Result
+			for _, region := range regions {
+				result := Result{Name: "instance types in " + region}
+				offered, err := probe.OfferedInstanceTypes(ctx, region, instanceTypes)
+				if err != nil {
+					results = append(results, withStatus(result, fail("Grant ec2:DescribeInstanceTypeOfferings and ec2:DescribeInstanceTypes",
+						"cannot describe instance types in %s: %v", region, err)))
+					continue
+				}
+
+				var missing, withoutEFA []string
+				for _, instanceType := range instanceTypes {
+					efa, ok := offered[instanceType]
+					switch {
+					case !ok:
+						missing = append(missing, instanceType)
+					case plan.MPIConfig.RequiresEFA && !efa:
+						withoutEFA = append(withoutEFA, instanceType)
+					}
+				}
+				switch {
+				case len(missing) > 0:
+					result = withStatus(result, fail("Choose instance types offered in "+region+" or burst into another region",
+						"not offered in %s: %s", region, strings.Join(missing, ", ")))
+				case len(withoutEFA) > 0:
+					result = withStatus(result, fail("Choose EFA-capable instance types or drop requires_efa",
+						"EFA requested but not supported by %s", strings.Join(withoutEFA, ", ")))
+				default:
+					result = withStatus(result, pass("%d instance types offered", len(instanceTypes)))
+				}
+				results = append(results, result)
+			}
+			return results
+		}},
+
+		{Category: category, Name: "MPI/EFA", Run: func(ctx context.Context) []Result {
+			return checkPlanMPI(plan)
+		}},
+	}
+}
+
+// checkPlanResources passes resources a node group uses. Others fail when AWS
+// does not know them in any node group region, and warn when they exist but
+// resume would not launch into them.
+func checkPlanResources(ctx context.Context, kind string, ids []string, inConfig map[string]bool, regions []string,
+	awsProbe func(ctx context.Context) (AWSProbe, error),
+	lookup func(ctx context.Context, probe AWSProbe, region string, ids []string) (map[string]bool, error)) Result {
+
+	if len(ids) == 0 {
+		return skip("the plan names no %s; node groups' %s apply", kind, kind)
+	}
+
+	var unconfigured []string
+	for _, id := range ids {
+		if !inConfig[id] {
+			unconfigured = append(unconfigured, id)
+		}
+	}
+	if len(unconfigured) == 0 {
+		return pass("%s configured for the node groups", strings.Join(ids, ", "))
+	}
+
+	remediation := fmt.Sprintf("Add the %s to the node group configuration; resume launches with the node group's own", kind)
+	probe, err := awsProbe(ctx)
+	if err != nil {
+		return warn(remediation, "not in the configuration: %s (AWS not checked: %v)", strings.Join(unconfigured, ", "), err)
+	}
+
+	found := make(map[string]bool)
+	for _, region := range regions {
+		inRegion, err := lookup(ctx, probe, region, unconfigured)
+		if err != nil {
+			return fail("Check the credentials' EC2 describe permissions", "cannot look up %s in %s: %v", kind, region, err)
+		}
+		for id, ok := range inRegion {
+			found[id] = found[id] || ok
+		}
+	}
+
+	var missing []string
+	for _, id := range unconfigured {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return fail("Fix the plan's "+kind, "not found in the configuration or in %s: %s", strings.Join(regions, ", "), strings.Join(missing, ", "))
+	}
+	return warn(remediation, "exist in AWS but no node group uses them: %s", strings.Join(unconfigured, ", "))
+}
+
+// checkPlanMPI reports MPI, EFA, accelerator and placement settings that
+// contradict each other
+func checkPlanMPI(plan *types.ExecutionPlan) []Result {
+	mpi, network := plan.MPIConfig, plan.NetworkConfig
+	var results []Result
+
+	if mpi.IsMPIJob && mpi.ProcessCount <= 0 {
+		results = append(results, fail("Set mpi_configuration.process_count", "MPI job without a process count"))
+	}
+	if mpi.RequiresGangScheduling && network.PlacementGroupType == "" {
+		results = append(results, fail("Set network_configuration.placement_group_type, usually cluster", "gang scheduling without a placement group"))
+	}
+	if mpi.RequiresEFA && !network.EnhancedNetworking {
+		results = append(results, fail("Enable network_configuration.enhanced_networking", "EFA requires enhanced networking"))
+	}
+	if mpi.GPUDirectRDMA && !mpi.RequiresEFA {
+		results = append(results, fail("Set mpi_configuration.requires_efa", "GPUDirect RDMA runs over EFA"))
+	}
+	if mpi.EFAGeneration != 0 && mpi.EFAGeneration != 1 && mpi.EFAGeneration != 2 {
+		results = append(results, fail("Set mpi_configuration.efa_generation to 1 or 2", "unknown EFA generation %d", mpi.EFAGeneration))
+	}
+	if mpi.EFAGeneration != 0 && !mpi.RequiresEFA {
+		results = append(results, warn("Set requires_efa or drop efa_generation", "efa_generation %d is ignored without requires_efa", mpi.EFAGeneration))
+	}
+	if mpi.RequiresEFA && !mpi.IsMPIJob && mpi.WorkloadClass != types.WorkloadDistributedML {
+		results = append(results, warn("Mark the job as MPI or distributed ML, or drop requires_efa", "EFA requested for a job that is neither MPI nor distributed ML"))
+	}
+	if mpi.Accelerator == types.AcceleratorNeuron && !types.NeuronOnly(plan.InstanceSpec.InstanceTypes) {
+		results = append(results, fail("Use only Trainium or Inferentia instance types",
+			"the Neuron accelerator needs trn1, trn1n or inf2 instances, got %s", strings.Join(plan.InstanceSpec.InstanceTypes, ", ")))
+	}
+	if mpi.RequiresEFA && network.PlacementGroupType != "" && network.PlacementGroupType != "cluster" {
+		results = append(results, warn("Use a cluster placement group for EFA traffic",
+			"EFA with a %s placement group loses most of its latency benefit", network.PlacementGroupType))
+	}
+
+	if len(results) == 0 {
+		results = append(results, pass("MPI, EFA and placement settings are consistent"))
+	}
+	return results
+}
+
+// planNodeGroups returns the node groups of partition, or every node group
+func planNodeGroups(cfg *config.Config, partition string) []*config.NodeGroupConfig {
+	var nodeGroups []*config.NodeGroupConfig
+	for i := range cfg.Slurm.Partitions {
+		if partition != "" && cfg.Slurm.Partitions[i].PartitionName != partition {
+			continue
+		}
+		for j := range cfg.Slurm.Partitions[i].NodeGroups {
+			nodeGroups = append(nodeGroups, &cfg.Slurm.Partitions[i].NodeGroups[j])
+		}
+	}
+	return nodeGroups
+}
+
+// nodeGroupRegions returns the sorted regions the node groups launch in
+func nodeGroupRegions(cfg *config.Config, nodeGroups []*config.NodeGroupConfig) []string {
+	seen := make(map[string]bool)
+	var regions []string
+	for _, nodeGroup := range nodeGroups {
+		region := nodeGroup.Region
+		if region == "" {
+			region = cfg.AWS.Region
+		}
+		if !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+	if len(regions) == 0 {
+		regions = append(regions, cfg.AWS.Region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// planInstanceTypes lists the plan's instance types, including its node roles'.
+// Bare families such as c6i are left out; resume picks their sizes at launch.
+func planInstanceTypes(plan *types.ExecutionPlan) []string {
+	seen := make(map[string]bool)
+	var instanceTypes []string
+	add := func(candidates []string) {
+		for _, instanceType := range candidates {
+			if strings.Contains(instanceType, ".") && !seen[instanceType] {
+				seen[instanceType] = true
+				instanceTypes = append(instanceTypes, instanceType)
+			}
+		}
+	}
+	add(plan.InstanceSpec.InstanceTypes)
+	for _, role := range plan.NodeRoles {
+		add(role.InstanceTypes)
+	}
+	return instanceTypes
+}
@@ -0,0 +1,195 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+)
+
+// SlurmPorts are the ports burst nodes exchange Slurm traffic on
+type SlurmPorts struct {
+	Slurmctld         int32 // slurmd registers with slurmctld here
+	Slurmd            int32 // slurmctld and srun reach slurmd here
+	SrunLow, SrunHigh int32 // slurmstepd connects back to srun here
+	SrunPortRange     bool  // The srun ports are SrunPortRange rather than ephemeral ports
+}
+
+// DefaultSlurmPorts are Slurm's defaults. Without SrunPortRange, srun listens
+// on any ephemeral port.
+var DefaultSlurmPorts = SlurmPorts{Slurmctld: 6817, Slurmd: 6818, SrunLow: 1024, SrunHigh: 65535}
+
+// ReadSlurmPorts reads the ports from scontrol show config, keeping the
+// default for any it cannot read
+func ReadSlurmPorts(ctx context.Context, slurmConfig *config.SlurmConfig, run Runner) SlurmPorts {
+	ports := DefaultSlurmPorts
+	output, err := run(ctx, slurmConfig.BinPath+"scontrol", "show", "config")
+	if err != nil {
+		return ports
+	}
+	settings := parseSlurmConfig(string(output))
+
+	// SlurmctldPort may be a range for high-throughput controllers; slurmd uses the first
+	if low, _, ok := parsePortRange(settings["SlurmctldPort"]); ok {
+		ports.Slurmctld = low
+	}
+	if port, _, ok := parsePortRange(settings["SlurmdPort"]); ok {
+		ports.Slurmd = port
+	}
+	if low, high, ok := parsePortRange(settings["SrunPortRange"]); ok && low > 0 {
+		ports.SrunLow, ports.SrunHigh, ports.SrunPortRange = low, high, true
+	}
+	return ports
+}
+
+// parsePortRange reads "6817" or "60001-63000"
+func parsePortRange(value string) (int32, int32, bool) {
+	lowText, highText, isRange := strings.Cut(value, "-")
+	if !isRange {
+		highText = lowText
+	}
+	low, lowErr := strconv.ParseUint(strings.TrimSpace(lowText), 10, 16)
+	high, highErr := strconv.ParseUint(strings.TrimSpace(highText), 10, 16)
+	if lowErr != nil || highErr != nil || high < low {
+		return 0, 0, false
+	}
+	return int32(low), int32(high), true
+}
+
+// requiredRule is traffic burst nodes need their security groups to allow,
+// with the controller network or between the nodes themselves
+type requiredRule struct {
+	egress     bool
+	allTraffic bool // Any protocol and port, as EFA needs
+	low, high  int32
+	network    netip.Prefix // The peer; invalid for the node group's own groups
+	purpose    string
+}
+
+func (r requiredRule) String() string {
+	direction, preposition := "inbound", "from"
+	if r.egress {
+		direction, preposition = "outbound", "to"
+	}
+	traffic := fmt.Sprintf("TCP %d", r.low)
+	if r.high != r.low {
+		traffic = fmt.Sprintf("TCP %d-%d", r.low, r.high)
+	}
+	if r.allTraffic {
+		traffic = "all traffic"
+	}
+	peer := "the node group's security group itself"
+	if r.network.IsValid() {
+		peer = r.network.String()
+	}
+	return fmt.Sprintf("%s %s %s %s (%s)", direction, traffic, preposition, peer, r.purpose)
+}
+
+// requiredRules lists the traffic burst nodes need. Without the controller
+// network only the traffic between nodes can be checked.
+func requiredRules(controller netip.Prefix, ports SlurmPorts) []requiredRule {
+	rules := []requiredRule{
+		{allTraffic: true, purpose: "MPI and EFA between nodes"},
+		{egress: true, allTraffic: true, purpose: "MPI and EFA between nodes"},
+	}
+	if !controller.IsValid() {
+		return rules
+	}
+
+	srun := "srun on SrunPortRange"
+	if !ports.SrunPortRange {
+		srun = "srun on ephemeral ports; set SrunPortRange to narrow this"
+	}
+	return append(rules,
+		requiredRule{low: ports.Slurmd, high: ports.Slurmd, network: controller, purpose: "slurmctld and srun to slurmd"},
+		requiredRule{egress: true, low: ports.Slurmctld, high: ports.Slurmctld, network: controller, purpose: "slurmd to slurmctld"},
+		requiredRule{egress: true, low: ports.SrunLow, high: ports.SrunHigh, network: controller, purpose: srun},
+	)
+}
+
+// allows reports whether the security groups' rules together allow required
+func allows(rules []aws.SecurityGroupRule, groupIDs []string, required requiredRule) bool {
+	var ranges [][2]int32
+	for _, rule := range rules {
+		if rule.Egress != required.egress || !rulePeerMatches(rule, groupIDs, required.network) {
+			continue
+		}
+		allPorts := rule.Protocol == "-1" || rule.FromPort == -1
+		switch {
+		case required.allTraffic && rule.Protocol == "-1":
+			return true
+		case required.allTraffic || (rule.Protocol != "tcp" && rule.Protocol != "6" && rule.Protocol != "-1"):
+			continue
+		case allPorts:
+			ranges = append(ranges, [2]int32{0, 65535})
+		default:
+			ranges = append(ranges, [2]int32{rule.FromPort, rule.ToPort})
+		}
+	}
+
+	// The rules may split the port range between them
+	slices.SortFunc(ranges, func(a, b [2]int32) int { return int(a[0] - b[0]) })
+	next := required.low
+	for _, portRange := range ranges {
+		if portRange[0] > next {
+			break
+		}
+		if portRange[1] >= next {
+			next = portRange[1] + 1
+		}
+		if next > required.high {
+			return true
+		}
+	}
+	return false
+}
+
+// rulePeerMatches reports whether a rule's source or destination covers the
+// controller network or, without one, the node group's own security groups.
+// Rules open to everywhere cover both.
+func rulePeerMatches(rule aws.SecurityGroupRule, groupIDs []string, network netip.Prefix) bool {
+	if rule.Group != "" {
+		return !network.IsValid() && slices.Contains(groupIDs, rule.Group)
+	}
+	prefix, err := netip.ParsePrefix(rule.CIDR)
+	if err != nil {
+		return false
+	}
+	if !network.IsValid() {
+		return prefix.Bits() == 0
+	}
+	return prefix.Addr().Is4() == network.Addr().Is4() && prefix.Bits() <= network.Bits() && prefix.Contains(network.Addr())
+}
+
+func checkSecurityGroupRules(ctx context.Context, probe AWSProbe, name, region string, nodeGroup *config.NodeGroupConfig,
+	controller netip.Prefix, ports SlurmPorts) Result {
+	result := Result{Name: name + " security group rules"}
+	if len(nodeGroup.SecurityGroupIds) == 0 {
+		return withStatus(result, skip("none configured; the launch template's groups apply"))
+	}
+
+	rules, err := probe.SecurityGroupRules(ctx, region, nodeGroup.SecurityGroupIds)
+	if err != nil {
+		return withStatus(result, fail("Grant ec2:DescribeSecurityGroupRules", "cannot describe security group rules in %s: %v", region, err))
+	}
+	var missing []string
+	for _, required := range requiredRules(controller, ports) {
+		if !allows(rules, nodeGroup.SecurityGroupIds, required) {
+			missing = append(missing, required.String())
+		}
+	}
+	groups := strings.Join(nodeGroup.SecurityGroupIds, ", ")
+	if len(missing) > 0 {
+		return withStatus(result, fail("Add the missing rules to "+groups, "missing %s", strings.Join(missing, "; ")))
+	}
+	if !controller.IsValid() {
+		return withStatus(result, warn("Set network_check.controller_cidr to check the Slurm ports too",
+			"traffic between nodes allowed; Slurm ports not checked without the controller's address"))
+	}
+	return withStatus(result, pass("Slurm traffic with %s and traffic between nodes allowed", controller))
+}
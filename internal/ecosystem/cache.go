@@ -0,0 +1,62 @@
+package ecosystem
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+)
+
+// CacheFile holds the last detection result in the data exchange directory
+const CacheFile = "ecosystem.json"
+
+// LoadCache returns the cached detection result, or nil when there is none
+func LoadCache(exchangeDir string) (*EcosystemStatus, error) {
+	var status EcosystemStatus
+	found, err := exchange.ReadShared(exchangeDir, CacheFile, &status)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SaveCache stores a detection result for other processes to reuse
+func SaveCache(exchangeDir string, status *EcosystemStatus) error {
+	_, err := exchange.WriteShared(exchangeDir, CacheFile, status)
+	return err
+}
+
+// Fresh reports whether the status was detected less than ttl before now
+func (s *EcosystemStatus) Fresh(ttl time.Duration, now time.Time) bool {
+	return !s.DetectedAt.IsZero() && now.Sub(s.DetectedAt) < ttl
+}
+
+// CachedEcosystem returns the cached status while it is younger than ttl, and
+// otherwise detects and caches a new one. Probing runs the companion tools with
+// 5 second timeouts, so latency-sensitive callers should prefer the cache. A
+// zero ttl or empty exchangeDir always detects.
+func (e *EcosystemDetector) CachedEcosystem(ctx context.Context, exchangeDir string, ttl time.Duration) *EcosystemStatus {
+	if exchangeDir == "" || ttl <= 0 {
+		return e.DetectEcosystem(ctx)
+	}
+
+	cached, err := LoadCache(exchangeDir)
+	if err != nil {
+		e.logger.Warn("Ignoring unreadable ecosystem cache", zap.Error(err))
+	}
+	if cached != nil && cached.Fresh(ttl, time.Now()) {
+		return cached
+	}
+	return e.refreshCache(ctx, exchangeDir)
+}
+
+// refreshCache detects the companion tools and replaces the cached status
+func (e *EcosystemDetector) refreshCache(ctx context.Context, exchangeDir string) *EcosystemStatus {
+	status := e.DetectEcosystem(ctx)
+	if err := SaveCache(exchangeDir, status); err != nil {
+		e.logger.Warn("Failed to cache ecosystem detection", zap.Error(err))
+	}
+	return status
+}
@@ -0,0 +1,55 @@
+package ecosystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeASBA puts an asba script that supports every subcommand first on PATH
+func fakeASBA(t *testing.T) {
+	bin := t.TempDir()
+	script := "#!/bin/sh\n[ \"$1\" = --version ] && echo 0.3.1\nexit 0\n"
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "asba"), []byte(script), 0755))
+	t.Setenv("PATH", bin)
+}
+
+func TestCachedEcosystem(t *testing.T) {
+	dir := t.TempDir()
+	detector := NewEcosystemDetector(zap.NewNop())
+
+	cached, err := LoadCache(dir)
+	require.NoError(t, err)
+	assert.Nil(t, cached)
+
+	fakeASBA(t)
+	status := detector.CachedEcosystem(context.Background(), dir, time.Hour)
+	assert.True(t, status.ASBA.Available)
+	assert.True(t, status.ASBA.SupportsExecPlan)
+	assert.Equal(t, "0.3.1\n", status.ASBA.Version)
+
+	cached, err = LoadCache(dir)
+	require.NoError(t, err)
+	require.NotNil(t, cached)
+	assert.True(t, cached.Fresh(time.Hour, time.Now()))
+	assert.False(t, cached.Fresh(time.Hour, time.Now().Add(2*time.Hour)))
+
+	// ASBA is gone, but the cache answers until it expires
+	t.Setenv("PATH", t.TempDir())
+	status = detector.CachedEcosystem(context.Background(), dir, time.Hour)
+	assert.True(t, status.ASBA.Available)
+
+	cached.DetectedAt = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, SaveCache(dir, cached))
+	status = detector.CachedEcosystem(context.Background(), dir, time.Hour)
+	assert.False(t, status.ASBA.Available)
+
+	status = detector.CachedEcosystem(context.Background(), "", time.Hour)
+	assert.False(t, status.ASBA.Available, "no exchange directory means no cache")
+}
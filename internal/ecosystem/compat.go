@@ -0,0 +1,159 @@
+package ecosystem
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Version is a semantic version; pre-release and build suffixes are ignored
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// versionPattern finds the version in output such as "asba version v1.2.0-rc1"
+var versionPattern = regexp.MustCompile(`v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseVersion extracts the first version number from s
+func ParseVersion(s string) (Version, error) {
+	match := versionPattern.FindStringSubmatch(s)
+	if match == nil {
+		return Version{}, fmt.Errorf("no version number in %q", s)
+	}
+	var v Version
+	v.Major, _ = strconv.Atoi(match[1])
+	v.Minor, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		v.Patch, _ = strconv.Atoi(match[3])
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 as v is older than, equal to or newer than other
+func (v Version) Compare(other Version) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if diff < 0 {
+			return -1
+		}
+		if diff > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// asbaPlanSchemas lists the first ASBA release to emit each plan schema, oldest first
+var asbaPlanSchemas = []struct {
+	asba   Version
+	schema string
+}{
+	{Version{0, 3, 0}, "1.0"}, // Execution plans
+	{Version{1, 2, 0}, "1.1"}, // Node roles, architecture, job requirements, workload class
+}
+
+// PlanSchemaForASBA returns the plan schema an ASBA release emits. It is false for
+// releases before execution plans.
+func PlanSchemaForASBA(asba Version) (string, bool) {
+	schema := ""
+	for _, entry := range asbaPlanSchemas {
+		if asba.Compare(entry.asba) >= 0 {
+			schema = entry.schema
+		}
+	}
+	return schema, schema != ""
+}
+
+// ASBACompatibility checks `asba --version` output against the compatibility
+// matrix and returns the plan schema that ASBA emits. It fails when this release
+// cannot execute that ASBA's plans.
+func ASBACompatibility(versionOutput string) (string, error) {
+	asba, err := ParseVersion(versionOutput)
+	if err != nil {
+		return "", fmt.Errorf("cannot tell the ASBA version: %w", err)
+	}
+	schema, ok := PlanSchemaForASBA(asba)
+	if !ok {
+		return "", fmt.Errorf("ASBA %s predates execution plans; upgrade ASBA to %s or later", asba, asbaPlanSchemas[0].asba)
+	}
+	if _, err := checkPlanSchema(schema); err != nil {
+		return schema, err
+	}
+	return schema, nil
+}
+
+// PlanCompatibility is how a plan's schema was negotiated
+type PlanCompatibility struct {
+	Schema     string   // Schema the plan is read as
+	Source     string   // "schema_version", "asba_version" or "default"
+	Downgraded bool     // Newer minor schema; fields added since types.PlanSchemaVersion are ignored
+	Warnings   []string // For the operator
+}
+
+// NegotiatePlan decides whether this release can execute plan. The plan's
+// schema_version wins; without it the schema is inferred from asba_version.
+// Plans with a newer major schema are refused with an error saying what to
+// upgrade, rather than failing validation on fields that changed meaning.
+func NegotiatePlan(plan *types.ExecutionPlan) (*PlanCompatibility, error) {
+	compat := &PlanCompatibility{Schema: plan.SchemaVersion, Source: "schema_version"}
+
+	if compat.Schema == "" {
+		asbaVersion := plan.ExecutionMetadata.ASBAVersion
+		if asbaVersion == "" {
+			compat.Schema, compat.Source = types.DefaultPlanSchemaVersion, "default"
+		} else {
+			asba, err := ParseVersion(asbaVersion)
+			if err != nil {
+				return nil, fmt.Errorf("execution plan has no schema_version and an unreadable asba_version %q", asbaVersion)
+			}
+			schema, ok := PlanSchemaForASBA(asba)
+			if !ok {
+				return nil, fmt.Errorf("execution plan claims ASBA %s, which predates execution plans", asba)
+			}
+			compat.Schema, compat.Source = schema, "asba_version"
+
+			newest := asbaPlanSchemas[len(asbaPlanSchemas)-1].asba
+			if asba.Major > newest.Major {
+				compat.Warnings = append(compat.Warnings, fmt.Sprintf(
+					"ASBA %s is newer than any release this version knows; reading its plan as schema %s. Have ASBA set schema_version to be sure", asba, schema))
+			}
+		}
+	}
+
+	downgraded, err := checkPlanSchema(compat.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("%w (schema from %s)", err, compat.Source)
+	}
+	if downgraded {
+		compat.Downgraded = true
+		compat.Warnings = append(compat.Warnings, fmt.Sprintf(
+			"execution plan schema %s is newer than %s; fields added since %s are ignored. Upgrade aws-slurm-burst to use them",
+			compat.Schema, types.PlanSchemaVersion, types.PlanSchemaVersion))
+	}
+	return compat, nil
+}
+
+// checkPlanSchema compares a plan schema with types.PlanSchemaVersion. It reports
+// a newer minor version as downgraded and a different major version as an error.
+func checkPlanSchema(schema string) (bool, error) {
+	plan, err := ParseVersion(schema)
+	if err != nil {
+		return false, fmt.Errorf("invalid execution plan schema_version %q", schema)
+	}
+	supported, _ := ParseVersion(types.PlanSchemaVersion)
+
+	if plan.Major != supported.Major {
+		action := "upgrade aws-slurm-burst"
+		if plan.Major < supported.Major {
+			action = "upgrade ASBA"
+		}
+		return false, fmt.Errorf("execution plan schema %d.%d cannot be executed by this aws-slurm-burst, which supports schema %d.0 to %s; %s",
+			plan.Major, plan.Minor, supported.Major, types.PlanSchemaVersion, action)
+	}
+	return plan.Minor > supported.Minor, nil
+}
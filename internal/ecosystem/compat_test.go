@@ -0,0 +1,92 @@
+package ecosystem
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func TestParseVersion(t *testing.T) {
+	for input, expected := range map[string]Version{
+		"1.2.0":                     {1, 2, 0},
+		"asba version v0.3.1\n":     {0, 3, 1},
+		"asba 2.0-rc1 (abc123)":     {2, 0, 0},
+		"ASBA 1.10.4+build.7 linux": {1, 10, 4},
+	} {
+		version, err := ParseVersion(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, version, input)
+	}
+
+	_, err := ParseVersion("asba development build")
+	assert.Error(t, err)
+
+	assert.Equal(t, 1, Version{1, 10, 0}.Compare(Version{1, 9, 9}))
+	assert.Equal(t, 0, Version{1, 2, 0}.Compare(Version{1, 2, 0}))
+	assert.Equal(t, -1, Version{0, 3, 1}.Compare(Version{1, 0, 0}))
+}
+
+func TestASBACompatibility(t *testing.T) {
+	schema, err := ASBACompatibility("asba 0.3.1")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", schema)
+
+	schema, err = ASBACompatibility("asba 1.4.2")
+	require.NoError(t, err)
+	assert.Equal(t, types.PlanSchemaVersion, schema)
+
+	_, err = ASBACompatibility("asba 0.2.9")
+	assert.ErrorContains(t, err, "predates execution plans")
+
+	_, err = ASBACompatibility("unknown")
+	assert.Error(t, err)
+}
+
+func TestNegotiatePlan(t *testing.T) {
+	plan := func(schema, asbaVersion string) *types.ExecutionPlan {
+		return &types.ExecutionPlan{
+			SchemaVersion:     schema,
+			ExecutionMetadata: types.ExecutionMetadata{ASBAVersion: asbaVersion},
+		}
+	}
+
+	compat, err := NegotiatePlan(plan("", ""))
+	require.NoError(t, err)
+	assert.Equal(t, types.DefaultPlanSchemaVersion, compat.Schema)
+	assert.Equal(t, "default", compat.Source)
+
+	compat, err = NegotiatePlan(plan("", "0.3.0"))
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", compat.Schema)
+	assert.Equal(t, "asba_version", compat.Source)
+	assert.Empty(t, compat.Warnings)
+
+	compat, err = NegotiatePlan(plan("1.1", "0.3.0"))
+	require.NoError(t, err)
+	assert.Equal(t, "1.1", compat.Schema, "schema_version wins over asba_version")
+
+	compat, err = NegotiatePlan(plan("1.4", ""))
+	require.NoError(t, err)
+	assert.True(t, compat.Downgraded)
+	require.Len(t, compat.Warnings, 1)
+	assert.Contains(t, compat.Warnings[0], "fields added since 1.1 are ignored")
+
+	compat, err = NegotiatePlan(plan("", "7.0.0"))
+	require.NoError(t, err)
+	assert.Len(t, compat.Warnings, 1, "unknown ASBA major versions are read as the newest schema with a warning")
+
+	_, err = NegotiatePlan(plan("2.0", ""))
+	assert.ErrorContains(t, err, "upgrade aws-slurm-burst")
+
+	_, err = NegotiatePlan(plan("0.9", ""))
+	assert.ErrorContains(t, err, "upgrade ASBA")
+
+	_, err = NegotiatePlan(plan("", "0.2.0"))
+	assert.ErrorContains(t, err, "predates execution plans")
+
+	_, err = NegotiatePlan(plan("latest", ""))
+	assert.Error(t, err)
+}
@@ -11,8 +11,9 @@ import (
 
 // EcosystemStatus represents the availability of companion tools
 type EcosystemStatus struct {
-	ASBA ASBAStatus `json:"asba"`
-	ASBB ASBBStatus `json:"asbb"`
+	ASBA       ASBAStatus `json:"asba"`
+	ASBB       ASBBStatus `json:"asbb"`
+	DetectedAt time.Time  `json:"detected_at"`
 }
 
 // ASBAStatus represents ASBA availability and capabilities
@@ -22,6 +23,8 @@ type ASBAStatus struct {
 	Command          string `json:"command,omitempty"`
 	SupportsExecPlan bool   `json:"supports_execution_plan"`
 	SupportsBurst    bool   `json:"supports_burst_command"`
+	PlanSchema       string `json:"plan_schema,omitempty"`         // Execution plan schema this ASBA emits
+	CompatibilityErr string `json:"compatibility_error,omitempty"` // Why its plans cannot be executed
 	LastChecked      string `json:"last_checked"`
 }
 
@@ -50,8 +53,9 @@ func NewEcosystemDetector(logger *zap.Logger) *EcosystemDetector {
 // DetectEcosystem checks for availability of companion tools
 func (e *EcosystemDetector) DetectEcosystem(ctx context.Context) *EcosystemStatus {
 	status := &EcosystemStatus{
-		ASBA: e.detectASBA(ctx),
-		ASBB: e.detectASBB(ctx),
+		ASBA:       e.detectASBA(ctx),
+		ASBB:       e.detectASBB(ctx),
+		DetectedAt: time.Now().UTC(),
 	}
 
 	e.logger.Info("Ecosystem detection complete",
@@ -82,6 +86,13 @@ func (e *EcosystemDetector) detectASBA(ctx context.Context) ASBAStatus {
 				status.Version = version
 			}
 
+			// Check the version against the plan schema compatibility matrix
+			schema, err := ASBACompatibility(status.Version)
+			status.PlanSchema = schema
+			if err != nil {
+				status.CompatibilityErr = err.Error()
+			}
+
 			// Check capabilities
 			status.SupportsExecPlan = e.checkASBAExecutionPlanSupport(ctx, cmd)
 			status.SupportsBurst = e.checkASBABurstSupport(ctx, cmd)
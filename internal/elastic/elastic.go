@@ -0,0 +1,403 @@
+// Package elastic replaces interrupted spot nodes of running elastic MPI jobs
+// (elastic_mpi, experimental). Resume records the launches of each elastic
+// job in the data exchange directory. Each state manager cycle asks EC2 which
+// of the job's spot instances have interruption notices; for each one it
+// launches a replacement instance under the same Slurm node name and points
+// Slurm at it. Once the replacement's slurmd has registered, the job's batch
+// script is signalled so the application's resize hook can grow back onto the
+// node. The events are exported with the job's MPI results.
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// lockTimeout bounds the wait for another process updating the same job
+const lockTimeout = 30 * time.Second
+
+// activeJobStates are the Slurm job states of jobs that may still run
+var activeJobStates = []string{"PENDING", "CONFIGURING", "RUNNING", "SUSPENDED", "REQUEUED", "REQUEUE_HOLD", "RESIZING"}
+
+// Record is an elastic MPI job's launches and node replacements, stored in
+// the data exchange directory under the Slurm job ID
+type Record struct {
+	JobID     string                     `json:"job_id"`
+	Launches  []*aws.LaunchRequest       `json:"launches"`         // Replayed for replacements
+	Instances []types.InstanceInfo       `json:"instances"`        // The current instance of each node
+	Events    []types.ElasticResizeEvent `json:"events,omitempty"` // In order
+	Closed    bool                       `json:"closed,omitempty"` // The job has ended
+}
+
+// Replacements counts the replacement instances launched for the job
+func (r *Record) Replacements() int {
+	count := 0
+	for _, event := range r.Events {
+		if event.ReplacementInstanceID != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// handled reports whether an event was already recorded for the instance
+func (r *Record) handled(instanceID string) bool {
+	for _, event := range r.Events {
+		if event.InterruptedInstanceID == instanceID {
+			return true
+		}
+	}
+	return false
+}
+
+// launchFor finds the launch request the node was launched by
+func (r *Record) launchFor(node string) *aws.LaunchRequest {
+	for _, launch := range r.Launches {
+		if slices.Contains(launch.NodeIds, node) {
+			return launch
+		}
+		for _, role := range launch.NodeRoles {
+			if slices.Contains(role.NodeIds, node) {
+				return launch
+			}
+		}
+	}
+	return nil
+}
+
+// setInstance makes instance the current instance of its node
+func (r *Record) setInstance(instance types.InstanceInfo) {
+	r.Instances = slices.DeleteFunc(r.Instances, func(existing types.InstanceInfo) bool {
+		return existing.NodeName == instance.NodeName
+	})
+	r.Instances = append(r.Instances, instance)
+}
+
+// ReadRecord loads an elastic MPI job's record, or nil
+func ReadRecord(root, jobID string) (*Record, error) {
+	var record Record
+	found, err := exchange.Read(root, exchange.Elastic, jobID, &record)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Register records launches made for an elastic MPI job and the instances
+// they started. A job resumed in several parts, or requeued, adds to its
+// record.
+func Register(root, jobID string, launches []*aws.LaunchRequest, instances []types.InstanceInfo) error {
+	unlock, err := exchange.Lock(root, "elastic-"+jobID, lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	record, err := ReadRecord(root, jobID)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		record = &Record{JobID: jobID}
+	}
+	record.Closed = false
+	record.Launches = append(record.Launches, launches...)
+	for _, instance := range instances {
+		record.setInstance(instance)
+	}
+	_, err = exchange.Write(root, exchange.Elastic, jobID, record)
+	return err
+}
+
+// Cloud is the part of the AWS client node replacement needs
+type Cloud interface {
+	InterruptedInstances(ctx context.Context, partition, nodeGroup string, instanceIDs []string) (map[string]time.Time, error)
+	LaunchInstances(ctx context.Context, req *aws.LaunchRequest) (*aws.LaunchResult, error)
+}
+
+// Nodes is the part of the Slurm client node replacement needs
+type Nodes interface {
+	JobStatus(ctx context.Context, jobID string) (*slurm.JobStatus, error)
+	GetNodeState(nodeNames []string) ([]slurm.NodeInfo, error)
+	UpdateNodesWithInstanceInfo(ctx context.Context, instances []types.InstanceInfo) error
+	SignalJob(ctx context.Context, jobID, signal string) error
+}
+
+// Replacer replaces the interrupted nodes of the recorded elastic MPI jobs
+type Replacer struct {
+	logger *zap.Logger
+	cfg    *config.ElasticMPIConfig
+	root   string
+	cloud  Cloud
+	nodes  Nodes
+	now    func() time.Time
+}
+
+// NewReplacer creates a replacer for the jobs in the data exchange directory
+func NewReplacer(logger *zap.Logger, cfg *config.Config, cloud Cloud, nodes Nodes) *Replacer {
+	return &Replacer{
+		logger: logger,
+		cfg:    &cfg.ElasticMPI,
+		root:   cfg.Ecosystem.DataExchangeDir,
+		cloud:  cloud,
+		nodes:  nodes,
+		now:    time.Now,
+	}
+}
+
+// Run takes each open job one step: replacements whose nodes have
+// registered are announced to the job, and newly interrupted nodes get
+// replacements launched
+func (r *Replacer) Run(ctx context.Context) error {
+	ids, err := exchange.List(r.root, exchange.Elastic)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := r.runJob(ctx, id); err != nil {
+			r.logger.Warn("Failed to check elastic MPI job", zap.String("job_id", id), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (r *Replacer) runJob(ctx context.Context, jobID string) error {
+	unlock, err := exchange.Lock(r.root, "elastic-"+jobID, lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	record, err := ReadRecord(r.root, jobID)
+	if err != nil || record == nil || record.Closed {
+		return err
+	}
+
+	status, err := r.nodes.JobStatus(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if status == nil || !slices.Contains(activeJobStates, status.State) {
+		record.Closed = true
+		for i := range record.Events {
+			if record.Events[i].Status == types.ElasticReplacing {
+				r.fail(record, &record.Events[i], "job ended before the replacement registered")
+			}
+		}
+		return r.write(record)
+	}
+	if status.State != "RUNNING" {
+		return nil
+	}
+
+	changed := r.advance(ctx, record)
+	if r.replaceInterrupted(ctx, record, status.BatchHost) {
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return r.write(record)
+}
+
+// advance signals the job for each replacement whose slurmd has registered,
+// and gives up on those that took longer than ready_timeout_minutes
+func (r *Replacer) advance(ctx context.Context, record *Record) bool {
+	var pending []string
+	for _, event := range record.Events {
+		if event.Status == types.ElasticReplacing {
+			pending = append(pending, event.NodeName)
+		}
+	}
+	if len(pending) == 0 {
+		return false
+	}
+	nodes, err := r.nodes.GetNodeState(pending)
+	if err != nil {
+		r.logger.Warn("Failed to get replacement node states", zap.String("job_id", record.JobID), zap.Error(err))
+		return false
+	}
+	states := make(map[string]slurm.NodeInfo, len(nodes))
+	for _, node := range nodes {
+		states[node.NodeName] = node
+	}
+
+	changed := false
+	timeout := time.Duration(r.cfg.ReadyTimeoutMinutes) * time.Minute
+	for i := range record.Events {
+		event := &record.Events[i]
+		if event.Status != types.ElasticReplacing {
+			continue
+		}
+		node, ok := states[event.NodeName]
+		if ok && registered(node, event.LaunchedAt) {
+			if err := r.nodes.SignalJob(ctx, record.JobID, r.cfg.ResizeSignal); err != nil {
+				r.logger.Warn("Failed to signal elastic MPI job", zap.String("job_id", record.JobID), zap.Error(err))
+				continue
+			}
+			event.Status, event.ReadyAt = types.ElasticResized, r.now()
+			changed = true
+			r.logger.Info("Replacement node ready, signalled elastic MPI job",
+				zap.String("job_id", record.JobID),
+				zap.String("node", event.NodeName),
+				zap.String("signal", r.cfg.ResizeSignal))
+			continue
+		}
+		if r.now().Sub(event.LaunchedAt) > timeout {
+			r.fail(record, event, fmt.Sprintf("replacement did not register within %d minutes", r.cfg.ReadyTimeoutMinutes))
+			changed = true
+		}
+	}
+	return changed
+}
+
+// registered reports whether the node's slurmd registered after the
+// replacement launched and the node is responding
+func registered(node slurm.NodeInfo, launchedAt time.Time) bool {
+	if node.SlurmdStartTime.Before(launchedAt) {
+		return false
+	}
+	for _, state := range []string{"DOWN", "NOT_RESPONDING", "FAIL"} {
+		if strings.Contains(node.State, state) {
+			return false
+		}
+	}
+	return !strings.HasSuffix(node.State, "*")
+}
+
+// replaceInterrupted launches a replacement for each of the job's spot
+// instances with a new interruption notice
+func (r *Replacer) replaceInterrupted(ctx context.Context, record *Record, batchHost string) bool {
+	// Each node group's spot instances, checked with one call
+	type group struct{ partition, nodeGroup string }
+	spot := make(map[group][]string)
+	nodeOf := make(map[string]string)
+	for _, instance := range record.Instances {
+		launch := record.launchFor(instance.NodeName)
+		if instance.PurchaseOption != "spot" || launch == nil || record.handled(instance.InstanceID) {
+			continue
+		}
+		key := group{launch.Partition, launch.NodeGroup}
+		spot[key] = append(spot[key], instance.InstanceID)
+		nodeOf[instance.InstanceID] = instance.NodeName
+	}
+
+	changed := false
+	for key, instanceIDs := range spot {
+		interrupted, err := r.cloud.InterruptedInstances(ctx, key.partition, key.nodeGroup, instanceIDs)
+		if err != nil {
+			r.logger.Warn("Failed to check spot interruptions", zap.String("job_id", record.JobID), zap.Error(err))
+			continue
+		}
+		for _, instanceID := range instanceIDs {
+			at, ok := interrupted[instanceID]
+			if !ok {
+				continue
+			}
+			r.replace(ctx, record, nodeOf[instanceID], instanceID, at, batchHost)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// replace records the interruption of the node's instance and launches its
+// replacement, unless the job cannot continue on one
+func (r *Replacer) replace(ctx context.Context, record *Record, node, instanceID string, at time.Time, batchHost string) {
+	record.Events = append(record.Events, types.ElasticResizeEvent{
+		NodeName:              node,
+		InterruptedInstanceID: instanceID,
+		InterruptedAt:         at,
+		Status:                types.ElasticReplacing,
+	})
+	event := &record.Events[len(record.Events)-1]
+	r.logger.Warn("Elastic MPI job's spot node interrupted",
+		zap.String("job_id", record.JobID),
+		zap.String("node", node),
+		zap.String("instance_id", instanceID))
+
+	switch {
+	case node == batchHost:
+		r.fail(record, event, "the batch host was interrupted; its batch script cannot move")
+		return
+	case record.Replacements() >= r.cfg.MaxReplacements:
+		r.fail(record, event, fmt.Sprintf("max_replacements (%d) reached", r.cfg.MaxReplacements))
+		return
+	}
+
+	request := r.replacementRequest(record.launchFor(node), node)
+	result, err := r.cloud.LaunchInstances(ctx, request)
+	if err == nil && len(result.Instances) == 0 {
+		err = fmt.Errorf("no instance launched")
+	}
+	if err != nil {
+		r.fail(record, event, "replacement launch failed: "+err.Error())
+		return
+	}
+
+	replacement := result.Instances[0]
+	replacement.NodeName = node
+	event.ReplacementInstanceID = replacement.InstanceID
+	event.ReplacementInstanceType = replacement.InstanceType
+	event.ReplacementPurchase = replacement.PurchaseOption
+	event.LaunchedAt = r.now()
+	record.setInstance(replacement)
+	if err := r.nodes.UpdateNodesWithInstanceInfo(ctx, []types.InstanceInfo{replacement}); err != nil {
+		// The replacement launched; its slurmd registering does the rest
+		r.logger.Warn("Failed to update replaced Slurm node", zap.String("node", node), zap.Error(err))
+	}
+	r.logger.Info("Launched replacement for interrupted node",
+		zap.String("job_id", record.JobID),
+		zap.String("node", node),
+		zap.String("instance_id", replacement.InstanceID),
+		zap.String("instance_type", replacement.InstanceType),
+		zap.String("purchase_option", replacement.PurchaseOption))
+}
+
+// replacementRequest derives the launch of one fresh instance for the node
+// from the launch that started it
+func (r *Replacer) replacementRequest(original *aws.LaunchRequest, node string) *aws.LaunchRequest {
+	request := *original
+	request.NodeIds = []string{node}
+	request.NodeRoles = nil
+	for _, role := range original.NodeRoles {
+		if slices.Contains(role.NodeIds, node) {
+			request.NodeRoles = []aws.NodeRoleLaunch{{Role: role.Role, NodeIds: []string{node}, InstanceTypes: role.InstanceTypes}}
+		}
+	}
+	request.NewInstancesOnly = true
+	request.QueueForCapacity = false
+	if r.cfg.OnDemandReplacements {
+		if original.InstanceRequirements != nil {
+			requirements := *original.InstanceRequirements
+			requirements.PreferSpot, requirements.AllowMixedPricing = false, false
+			request.InstanceRequirements = &requirements
+		}
+		request.SpotStrategy = nil
+	}
+	return &request
+}
+
+// fail marks the event failed
+func (r *Replacer) fail(record *Record, event *types.ElasticResizeEvent, reason string) {
+	event.Status, event.Error = types.ElasticFailed, reason
+	r.logger.Warn("Elastic MPI node replacement failed",
+		zap.String("job_id", record.JobID),
+		zap.String("node", event.NodeName),
+		zap.String("reason", reason))
+}
+
+func (r *Replacer) write(record *Record) error {
+	_, err := exchange.Write(r.root, exchange.Elastic, record.JobID, record)
+	return err
+}
@@ -0,0 +1,181 @@
+package elastic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeCloud reports interruption notices and launches one on-demand instance per request
+type fakeCloud struct {
+	interrupted map[string]time.Time
+	launched    []*aws.LaunchRequest
+}
+
+func (f *fakeCloud) InterruptedInstances(ctx context.Context, partition, nodeGroup string, instanceIDs []string) (map[string]time.Time, error) {
+	found := make(map[string]time.Time)
+	for _, id := range instanceIDs {
+		if at, ok := f.interrupted[id]; ok {
+			found[id] = at
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeCloud) LaunchInstances(ctx context.Context, req *aws.LaunchRequest) (*aws.LaunchResult, error) {
+	f.launched = append(f.launched, req)
+	return &aws.LaunchResult{Instances: []types.InstanceInfo{
+		{NodeName: req.NodeIds[0], InstanceID: "i-new", InstanceType: "c5n.18xlarge", PurchaseOption: "on-demand"},
+	}}, nil
+}
+
+// fakeNodes is a running job whose batch script runs on aws-cpu-001
+type fakeNodes struct {
+	status  *slurm.JobStatus
+	nodes   map[string]slurm.NodeInfo
+	updated []types.InstanceInfo
+	signals []string
+}
+
+func (f *fakeNodes) JobStatus(ctx context.Context, jobID string) (*slurm.JobStatus, error) {
+	return f.status, nil
+}
+
+func (f *fakeNodes) GetNodeState(nodeNames []string) ([]slurm.NodeInfo, error) {
+	var nodes []slurm.NodeInfo
+	for _, name := range nodeNames {
+		if node, ok := f.nodes[name]; ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+func (f *fakeNodes) UpdateNodesWithInstanceInfo(ctx context.Context, instances []types.InstanceInfo) error {
+	f.updated = append(f.updated, instances...)
+	return nil
+}
+
+func (f *fakeNodes) SignalJob(ctx context.Context, jobID, signal string) error {
+	f.signals = append(f.signals, jobID+":"+signal)
+	return nil
+}
+
+func newTestReplacer(t *testing.T) (*Replacer, *fakeCloud, *fakeNodes) {
+	cfg := &config.Config{
+		Ecosystem: config.EcosystemConfig{DataExchangeDir: t.TempDir()},
+		ElasticMPI: config.ElasticMPIConfig{
+			Enabled: true, ResizeSignal: "USR2", OnDemandReplacements: true, MaxReplacements: 1, ReadyTimeoutMinutes: 10,
+		},
+	}
+	launch := &aws.LaunchRequest{
+		NodeIds:              []string{"aws-cpu-001", "aws-cpu-002", "aws-cpu-003"},
+		Partition:            "aws",
+		NodeGroup:            "cpu",
+		InstanceRequirements: &types.InstanceRequirements{PreferSpot: true, AllowMixedPricing: true},
+		SpotStrategy:         &aws.SpotPricingStrategy{SpotAllocationRatio: 0.5},
+		Job:                  &types.SlurmJob{JobID: "77"},
+	}
+	require.NoError(t, Register(cfg.Ecosystem.DataExchangeDir, "77", []*aws.LaunchRequest{launch}, []types.InstanceInfo{
+		{NodeName: "aws-cpu-001", InstanceID: "i-1", PurchaseOption: "spot"},
+		{NodeName: "aws-cpu-002", InstanceID: "i-2", PurchaseOption: "spot"},
+		{NodeName: "aws-cpu-003", InstanceID: "i-3", PurchaseOption: "spot"},
+	}))
+
+	cloud := &fakeCloud{interrupted: map[string]time.Time{}}
+	nodes := &fakeNodes{status: &slurm.JobStatus{State: "RUNNING", BatchHost: "aws-cpu-001"}, nodes: map[string]slurm.NodeInfo{}}
+	return NewReplacer(zap.NewNop(), cfg, cloud, nodes), cloud, nodes
+}
+
+func TestReplacer_Run(t *testing.T) {
+	replacer, cloud, nodes := newTestReplacer(t)
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	replacer.now = func() time.Time { return now }
+
+	require.NoError(t, replacer.Run(ctx))
+	assert.Empty(t, cloud.launched, "no interruptions")
+
+	// An interrupted spot node gets a fresh on-demand instance under its name
+	cloud.interrupted["i-2"] = now.Add(-time.Minute)
+	require.NoError(t, replacer.Run(ctx))
+	require.Len(t, cloud.launched, 1)
+	request := cloud.launched[0]
+	assert.Equal(t, []string{"aws-cpu-002"}, request.NodeIds)
+	assert.True(t, request.NewInstancesOnly)
+	assert.False(t, request.InstanceRequirements.PreferSpot)
+	assert.False(t, request.InstanceRequirements.AllowMixedPricing)
+	assert.Nil(t, request.SpotStrategy)
+	assert.Equal(t, []types.InstanceInfo{{NodeName: "aws-cpu-002", InstanceID: "i-new", InstanceType: "c5n.18xlarge", PurchaseOption: "on-demand"}}, nodes.updated)
+
+	record, err := ReadRecord(replacer.root, "77")
+	require.NoError(t, err)
+	require.Len(t, record.Events, 1)
+	assert.Equal(t, types.ElasticReplacing, record.Events[0].Status)
+	assert.True(t, record.Launches[0].InstanceRequirements.PreferSpot, "stored launch unchanged")
+	assert.Len(t, record.Instances, 3)
+
+	// The job is signalled once the replacement's slurmd registers
+	nodes.nodes["aws-cpu-002"] = slurm.NodeInfo{NodeName: "aws-cpu-002", State: "ALLOCATED", SlurmdStartTime: now.Add(-time.Hour)}
+	require.NoError(t, replacer.Run(ctx))
+	assert.Empty(t, nodes.signals, "slurmd still the interrupted instance's")
+	assert.Len(t, cloud.launched, 1, "interruption handled once")
+
+	nodes.nodes["aws-cpu-002"] = slurm.NodeInfo{NodeName: "aws-cpu-002", State: "ALLOCATED", SlurmdStartTime: now.Add(time.Minute)}
+	require.NoError(t, replacer.Run(ctx))
+	assert.Equal(t, []string{"77:USR2"}, nodes.signals)
+
+	// The batch host cannot be replaced, and nor can nodes past max_replacements
+	cloud.interrupted["i-1"] = now
+	cloud.interrupted["i-3"] = now
+	require.NoError(t, replacer.Run(ctx))
+	assert.Len(t, cloud.launched, 1)
+
+	record, err = ReadRecord(replacer.root, "77")
+	require.NoError(t, err)
+	require.Len(t, record.Events, 3)
+	assert.Equal(t, types.ElasticResized, record.Events[0].Status)
+	assert.Equal(t, now, record.Events[0].ReadyAt)
+	assert.Equal(t, "i-new", record.Events[0].ReplacementInstanceID)
+	for _, event := range record.Events[1:] {
+		assert.Equal(t, types.ElasticFailed, event.Status)
+	}
+	errors := []string{record.Events[1].Error, record.Events[2].Error}
+	assert.Contains(t, errors, "the batch host was interrupted; its batch script cannot move")
+	assert.Contains(t, errors, "max_replacements (1) reached")
+
+	nodes.status = nil
+	require.NoError(t, replacer.Run(ctx))
+	record, err = ReadRecord(replacer.root, "77")
+	require.NoError(t, err)
+	assert.True(t, record.Closed)
+}
+
+func TestReplacer_ReadyTimeout(t *testing.T) {
+	replacer, cloud, nodes := newTestReplacer(t)
+	ctx := context.Background()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	replacer.now = func() time.Time { return now }
+
+	cloud.interrupted["i-2"] = now
+	require.NoError(t, replacer.Run(ctx))
+
+	now = now.Add(11 * time.Minute)
+	nodes.nodes["aws-cpu-002"] = slurm.NodeInfo{NodeName: "aws-cpu-002", State: "DOWN+NOT_RESPONDING", SlurmdStartTime: now}
+	require.NoError(t, replacer.Run(ctx))
+	assert.Empty(t, nodes.signals)
+
+	record, err := ReadRecord(replacer.root, "77")
+	require.NoError(t, err)
+	require.Len(t, record.Events, 1)
+	assert.Equal(t, types.ElasticFailed, record.Events[0].Status)
+	assert.Equal(t, "replacement did not register within 10 minutes", record.Events[0].Error)
+}
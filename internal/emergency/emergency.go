@@ -0,0 +1,161 @@
+// Package emergency keeps the emergency stops the emergency-stop command
+// engages and its audit log. A stop covers one partition, or every partition,
+// and blocks resume from bursting until an operator clears it.
+package emergency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// NodeReason is the Slurm reason set on the nodes of a stopped partition
+const NodeReason = "emergency_stop"
+
+// Audit log actions
+const (
+	ActionStop  = "stop"
+	ActionClear = "clear"
+)
+
+// Stop is an emergency stop in force
+type Stop struct {
+	Partition string    `json:"partition,omitempty"` // Empty for every partition
+	Reason    string    `json:"reason,omitempty"`
+	Operator  string    `json:"operator"`
+	StoppedAt time.Time `json:"stopped_at"`
+}
+
+// State is the emergency stops in force
+type State struct {
+	Stops []Stop `json:"stops"`
+}
+
+// Stopped returns the stop that blocks bursting in the partition, or nil
+func (s *State) Stopped(partition string) *Stop {
+	for i := range s.Stops {
+		if s.Stops[i].Partition == "" || s.Stops[i].Partition == partition {
+			return &s.Stops[i]
+		}
+	}
+	return nil
+}
+
+// Load reads the state at path. There are no stops when it does not exist.
+func Load(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read emergency stop state %s: %w", path, err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid emergency stop state %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// Engage adds the stop to the state at path, replacing any earlier stop of
+// the same partition
+func Engage(path string, stop Stop) error {
+	state, err := Load(path)
+	if err != nil {
+		return err
+	}
+	state.Stops = slices.DeleteFunc(state.Stops, func(s Stop) bool { return s.Partition == stop.Partition })
+	state.Stops = append(state.Stops, stop)
+	return save(path, state)
+}
+
+// Clear removes the stop of the partition from the state at path, or every
+// stop for an empty partition, and returns the stops it removed. A partition
+// cannot be cleared while every partition is stopped.
+func Clear(path string, partition string) ([]Stop, error) {
+	state, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if partition != "" && slices.ContainsFunc(state.Stops, func(s Stop) bool { return s.Partition == "" }) {
+		return nil, fmt.Errorf("every partition is stopped; clear the stop of all partitions first")
+	}
+
+	var cleared, kept []Stop
+	for _, stop := range state.Stops {
+		if partition == "" || stop.Partition == partition {
+			cleared = append(cleared, stop)
+		} else {
+			kept = append(kept, stop)
+		}
+	}
+	if len(cleared) == 0 {
+		return nil, nil
+	}
+	state.Stops = kept
+	return cleared, save(path, state)
+}
+
+// save writes the state to path, replacing the earlier one atomically
+func save(path string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create emergency stop directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write emergency stop state %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Event is one audit log entry
+type Event struct {
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	Partitions []string  `json:"partitions,omitempty"` // Empty for every partition
+	Operator   string    `json:"operator"`
+	Reason     string    `json:"reason,omitempty"`
+	Instances  []string  `json:"instances,omitempty"` // Instance IDs terminated
+	Nodes      []string  `json:"nodes,omitempty"`     // Slurm nodes marked down
+	Error      string    `json:"error,omitempty"`
+}
+
+// Record appends the event to the audit log at path
+func Record(path string, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write audit log %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// Operator names who runs the command, looking past sudo
+func Operator() string {
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+		return sudoUser
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
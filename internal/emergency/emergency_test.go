@@ -0,0 +1,68 @@
+package emergency
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngageAndClear(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool", "emergency-stop.json")
+	state, err := Load(path)
+	require.NoError(t, err)
+	assert.Nil(t, state.Stopped("aws"), "no state file")
+
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, Engage(path, Stop{Partition: "gpu", Reason: "runaway", Operator: "alice", StoppedAt: now}))
+	state, err = Load(path)
+	require.NoError(t, err)
+	assert.Nil(t, state.Stopped("aws"))
+	require.NotNil(t, state.Stopped("gpu"))
+	assert.Equal(t, "runaway", state.Stopped("gpu").Reason)
+
+	require.NoError(t, Engage(path, Stop{Operator: "alice", StoppedAt: now}))
+	state, err = Load(path)
+	require.NoError(t, err)
+	assert.NotNil(t, state.Stopped("aws"), "every partition")
+
+	_, err = Clear(path, "gpu")
+	assert.Error(t, err, "every partition is stopped")
+
+	cleared, err := Clear(path, "")
+	require.NoError(t, err)
+	assert.Len(t, cleared, 2)
+	state, err = Load(path)
+	require.NoError(t, err)
+	assert.Empty(t, state.Stops)
+
+	cleared, err = Clear(path, "gpu")
+	require.NoError(t, err)
+	assert.Empty(t, cleared, "nothing to clear")
+}
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log", "audit.jsonl")
+	require.NoError(t, Record(path, Event{Action: ActionStop, Partitions: []string{"gpu"}, Operator: "alice", Instances: []string{"i-1"}}))
+	require.NoError(t, Record(path, Event{Action: ActionClear, Partitions: []string{"gpu"}, Operator: "bob"}))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.Len(t, events, 2)
+	assert.Equal(t, ActionStop, events[0].Action)
+	assert.Equal(t, []string{"i-1"}, events[0].Instances)
+	assert.Equal(t, "bob", events[1].Operator)
+}
@@ -0,0 +1,165 @@
+package epilog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"go.uber.org/zap"
+)
+
+// reconciliationFormat is the cost record format; it is written where cost reports read
+const reconciliationFormat = "asbb-reconciliation"
+
+// Runner executes an export program; it is replaced in tests
+type Runner func(ctx context.Context, program string, args ...string) ([]byte, error)
+
+// ExecRunner runs program as a subprocess
+func ExecRunner(ctx context.Context, program string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, program, args...).CombinedOutput()
+}
+
+// Processor runs the exports for queued requests
+type Processor struct {
+	logger     *zap.Logger
+	cfg        *config.Config
+	configFile string
+	run        Runner
+}
+
+// NewProcessor creates a processor that passes configFile to the export program
+func NewProcessor(logger *zap.Logger, cfg *config.Config, configFile string, run Runner) *Processor {
+	return &Processor{logger: logger, cfg: cfg, configFile: configFile, run: run}
+}
+
+// Result summarizes one pass over the queue
+type Result struct {
+	Exported []string // Job IDs fully exported
+	Retrying []string // Job IDs left queued after a failure
+	Failed   []string // Job IDs moved to the failed directory
+}
+
+// ProcessQueue exports queued jobs until the queue is empty or ctx ends. Jobs not
+// reached before ctx ends stay queued without using an attempt.
+func (p *Processor) ProcessQueue(ctx context.Context) (Result, error) {
+	var result Result
+	files, err := Pending(p.cfg.Epilog.QueueDir)
+	if err != nil {
+		return result, fmt.Errorf("failed to list epilog queue: %w", err)
+	}
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+
+		req, err := readRequest(file)
+		if err != nil {
+			p.logger.Error("Discarding unreadable epilog request", zap.String("file", file), zap.Error(err))
+			p.moveToFailed(file)
+			continue
+		}
+
+		err = p.Export(ctx, req)
+		switch {
+		case err == nil:
+			if removeErr := os.Remove(file); removeErr != nil {
+				p.logger.Warn("Failed to remove epilog request", zap.String("file", file), zap.Error(removeErr))
+			}
+			result.Exported = append(result.Exported, req.JobID)
+		case ctx.Err() != nil:
+			// Out of time for this cycle, not a failure of the job's export
+			return result, nil
+		default:
+			req.Attempts++
+			req.LastError = err.Error()
+			if req.Attempts >= p.cfg.Epilog.MaxAttempts {
+				p.logger.Error("Giving up on job export",
+					zap.String("job_id", req.JobID),
+					zap.Int("attempts", req.Attempts),
+					zap.Error(err))
+				if _, writeErr := requestFile(filepath.Dir(file), req); writeErr == nil {
+					p.moveToFailed(file)
+				}
+				result.Failed = append(result.Failed, req.JobID)
+				continue
+			}
+			p.logger.Warn("Job export failed, will retry",
+				zap.String("job_id", req.JobID),
+				zap.Int("attempts", req.Attempts),
+				zap.Error(err))
+			if _, writeErr := requestFile(filepath.Dir(file), req); writeErr != nil {
+				p.logger.Error("Failed to record export attempt", zap.String("job_id", req.JobID), zap.Error(writeErr))
+			}
+			result.Retrying = append(result.Retrying, req.JobID)
+		}
+	}
+
+	return result, nil
+}
+
+// Export runs every configured export format for a job, each with its own timeout
+func (p *Processor) Export(ctx context.Context, req *Request) error {
+	program := p.cfg.Epilog.ExportProgram
+	timeout := time.Duration(p.cfg.Epilog.TimeoutSeconds) * time.Second
+
+	var failures []string
+	for _, format := range p.cfg.Epilog.Formats {
+		args := []string{"--job-id", req.JobID, "--config", p.configFile, "--format", format}
+		switch {
+		case p.cfg.Epilog.Output != "":
+			args = append(args, "--output", p.cfg.Epilog.Output)
+		case format == reconciliationFormat:
+			args = append(args, "--output-dir", p.cfg.ASBB.ReconciliationDir)
+		default:
+			args = append(args, "--output-dir", p.cfg.Epilog.OutputDir)
+		}
+
+		exportCtx, cancel := context.WithTimeout(ctx, timeout)
+		output, err := p.run(exportCtx, program, args...)
+		timedOut := errors.Is(exportCtx.Err(), context.DeadlineExceeded)
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			if timedOut {
+				err = fmt.Errorf("timed out after %s", timeout)
+			}
+			p.logger.Warn("Job export format failed",
+				zap.String("job_id", req.JobID),
+				zap.String("format", format),
+				zap.String("output", strings.TrimSpace(string(output))),
+				zap.Error(err))
+			failures = append(failures, fmt.Sprintf("%s: %v", format, err))
+			continue
+		}
+
+		p.logger.Info("Exported job data",
+			zap.String("job_id", req.JobID),
+			zap.String("format", format))
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("export failed for %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// moveToFailed keeps a request that will not be retried
+func (p *Processor) moveToFailed(file string) {
+	dir := filepath.Join(filepath.Dir(file), failedDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		p.logger.Error("Failed to create failed epilog directory", zap.Error(err))
+		return
+	}
+	if err := os.Rename(file, filepath.Join(dir, filepath.Base(file))); err != nil {
+		p.logger.Error("Failed to move epilog request", zap.String("file", file), zap.Error(err))
+	}
+}
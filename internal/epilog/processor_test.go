@@ -0,0 +1,110 @@
+package epilog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeRunner records export invocations and fails formats listed in fail
+type fakeRunner struct {
+	calls [][]string
+	fail  map[string]bool
+}
+
+func (f *fakeRunner) run(ctx context.Context, program string, args ...string) ([]byte, error) {
+	f.calls = append(f.calls, append([]string{program}, args...))
+	for i, arg := range args {
+		if arg == "--format" && f.fail[args[i+1]] {
+			return []byte("AccessDenied"), errors.New("exit status 1")
+		}
+	}
+	return nil, nil
+}
+
+func TestExportArguments(t *testing.T) {
+	cfg := testConfig(t.TempDir())
+	runner := &fakeRunner{}
+	processor := NewProcessor(zap.NewNop(), cfg, "/etc/slurm/aws-burst.yaml", runner.run)
+
+	require.NoError(t, processor.Export(context.Background(), &Request{JobID: "42"}))
+	assert.Equal(t, [][]string{
+		{cfg.Epilog.ExportProgram, "--job-id", "42", "--config", "/etc/slurm/aws-burst.yaml", "--format", "asba-learning", "--output-dir", "/var/spool/asba/learning"},
+		{cfg.Epilog.ExportProgram, "--job-id", "42", "--config", "/etc/slurm/aws-burst.yaml", "--format", "asbb-reconciliation", "--output-dir", "/var/spool/asbb/costs"},
+	}, runner.calls)
+
+	cfg.Epilog.Output = "s3://learning/cluster-a"
+	runner.calls = nil
+	require.NoError(t, processor.Export(context.Background(), &Request{JobID: "42"}))
+	for _, call := range runner.calls {
+		assert.Equal(t, []string{"--output", "s3://learning/cluster-a"}, call[len(call)-2:])
+	}
+}
+
+func TestProcessQueue(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig(dir)
+	for _, id := range []string{"1", "2"} {
+		_, err := Enqueue(dir, &Request{JobID: id, Partition: "aws"})
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "job-3.json"), []byte("{"), 0600))
+
+	runner := &fakeRunner{fail: map[string]bool{"asbb-reconciliation": true}}
+	processor := NewProcessor(zap.NewNop(), cfg, "/etc/slurm/aws-burst.yaml", runner.run)
+
+	// Every job fails its reconciliation export and is retried
+	result, err := processor.ProcessQueue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, result.Retrying)
+	req, err := readRequest(filepath.Join(dir, "job-1.json"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, req.Attempts)
+	assert.True(t, strings.Contains(req.LastError, "asbb-reconciliation"))
+	assert.FileExists(t, filepath.Join(dir, failedDir, "job-3.json"), "unreadable requests are set aside")
+
+	// The second failure uses up max_attempts
+	result, err = processor.ProcessQueue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, result.Failed)
+	failed, err := readRequest(filepath.Join(dir, failedDir, "job-1.json"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, failed.Attempts)
+
+	// Successful exports are removed from the queue
+	_, err = Enqueue(dir, &Request{JobID: "4", Partition: "aws"})
+	require.NoError(t, err)
+	runner.fail = nil
+	result, err = processor.ProcessQueue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4"}, result.Exported)
+	pending, err := Pending(dir)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestProcessQueueStopsWhenOutOfTime(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig(dir)
+	_, err := Enqueue(dir, &Request{JobID: "1", Partition: "aws"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runner := &fakeRunner{}
+	result, err := NewProcessor(zap.NewNop(), cfg, "cfg.yaml", runner.run).ProcessQueue(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, result.Exported)
+	assert.Empty(t, runner.calls)
+
+	req, err := readRequest(filepath.Join(dir, "job-1.json"))
+	require.NoError(t, err)
+	assert.Zero(t, req.Attempts)
+}
@@ -0,0 +1,123 @@
+// Package epilog hands completed burst jobs from Slurm's EpilogSlurmctld to the
+// state manager, which runs the performance and cost exports. The epilog only
+// writes a small request file so AWS API slowness can never hold up slurmctld.
+package epilog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+)
+
+// requestPattern matches queued request files
+const requestPattern = "job-*.json"
+
+// failedDir holds requests that ran out of attempts, for inspection
+const failedDir = "failed"
+
+// Request is a completed burst job waiting for export
+type Request struct {
+	JobID     string    `json:"job_id"`
+	Partition string    `json:"partition"`
+	NodeList  string    `json:"node_list,omitempty"`
+	Account   string    `json:"account,omitempty"`
+	User      string    `json:"user,omitempty"`
+	QueuedAt  time.Time `json:"queued_at"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// IsBurstJob reports whether a job ran in a configured partition or on any
+// configured node group's nodes. nodeList is the job's compressed hostlist;
+// Slurm only compresses numeric suffixes, so node group prefixes appear literally.
+func IsBurstJob(cfg *config.Config, partition, nodeList string) bool {
+	for _, p := range cfg.Slurm.Partitions {
+		if p.PartitionName == partition {
+			return true
+		}
+	}
+
+	for _, entry := range strings.Split(nodeList, ",") {
+		for _, p := range cfg.Slurm.Partitions {
+			for _, nodeGroup := range p.NodeGroups {
+				if strings.HasPrefix(entry, cfg.Slurm.NodeNaming.NamePrefix(p.PartitionName, nodeGroup.NodeGroupName)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Enqueue writes req to the queue directory. The file appears atomically, so the
+// state manager never reads a partial request.
+func Enqueue(dir string, req *Request) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create epilog queue: %w", err)
+	}
+	return requestFile(dir, req)
+}
+
+// requestFile atomically writes req to its file in dir
+func requestFile(dir string, req *Request) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode epilog request: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("job-%s.json", req.JobID))
+	tmp, err := os.CreateTemp(dir, ".job-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to write epilog request: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write epilog request: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write epilog request: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to write epilog request: %w", err)
+	}
+	return path, nil
+}
+
+// Pending returns the queued request files, oldest job first
+func Pending(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, requestPattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return jobOrder(files[i]) < jobOrder(files[j])
+	})
+	return files, nil
+}
+
+// jobOrder sorts numeric job IDs numerically, e.g. job-9 before job-10
+func jobOrder(path string) string {
+	id := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "job-"), ".json")
+	return fmt.Sprintf("%20s", id)
+}
+
+// readRequest loads a queued request
+func readRequest(path string) (*Request, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid epilog request %s: %w", path, err)
+	}
+	return &req, nil
+}
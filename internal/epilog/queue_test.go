@@ -0,0 +1,69 @@
+package epilog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig(dir string) *config.Config {
+	return &config.Config{
+		Slurm: config.SlurmConfig{
+			Partitions: []config.PartitionConfig{
+				{PartitionName: "aws", NodeGroups: []config.NodeGroupConfig{{NodeGroupName: "cpu"}, {NodeGroupName: "gpu"}}},
+			},
+		},
+		ASBB: config.ASBBConfig{ReconciliationDir: "/var/spool/asbb/costs"},
+		Epilog: config.EpilogConfig{
+			QueueDir:       dir,
+			Formats:        []string{"asba-learning", "asbb-reconciliation"},
+			OutputDir:      "/var/spool/asba/learning",
+			ExportProgram:  "/usr/local/bin/aws-slurm-burst-export-performance",
+			TimeoutSeconds: 5,
+			MaxAttempts:    2,
+		},
+	}
+}
+
+func TestIsBurstJob(t *testing.T) {
+	cfg := testConfig(t.TempDir())
+
+	assert.True(t, IsBurstJob(cfg, "aws", ""))
+	assert.True(t, IsBurstJob(cfg, "mixed", "node[01-04],aws-gpu-[0-1]"))
+	assert.True(t, IsBurstJob(cfg, "mixed", "aws-cpu-3"))
+	assert.False(t, IsBurstJob(cfg, "batch", "node[01-04]"))
+	assert.False(t, IsBurstJob(cfg, "batch", "aws-mem-0"))
+	assert.False(t, IsBurstJob(cfg, "batch", ""))
+}
+
+func TestEnqueuePending(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+
+	for _, id := range []string{"10", "9", "100"} {
+		_, err := Enqueue(dir, &Request{JobID: id, Partition: "aws", QueuedAt: time.Now()})
+		require.NoError(t, err)
+	}
+
+	pending, err := Pending(dir)
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+	assert.Equal(t, []string{"job-9.json", "job-10.json", "job-100.json"},
+		[]string{filepath.Base(pending[0]), filepath.Base(pending[1]), filepath.Base(pending[2])})
+
+	req, err := readRequest(pending[0])
+	require.NoError(t, err)
+	assert.Equal(t, "9", req.JobID)
+
+	info, err := os.Stat(pending[0])
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3, "no temporary files left behind")
+}
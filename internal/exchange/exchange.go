@@ -0,0 +1,327 @@
+// Package exchange implements the data exchange directory that aws-slurm-burst
+// shares with ASBA, ASBB and ASBX (ecosystem.data_exchange_dir):
+//
+//	plans/job-<id>.json    execution plans ASBA writes for resume to pick up
+//	results/job-<id>.json  execution results resume writes after every launch attempt
+//	records/job-<id>.json  plans kept for prediction validation at export
+//	batch/job-<id>.json    array jobs resume handed to AWS Batch, followed by the state manager
+//	elastic/job-<id>.json  elastic MPI jobs' launches and node replacements, kept by the state manager
+//	locks/<name>.lock      advisory flock(2) locks for multi-step updates
+//	tmp/                   staging area; files are renamed into place
+//	ecosystem.json         cached ASBA/ASBB detection, refreshed by the state manager
+//
+// Every writer stages its file in tmp and renames it, so readers see a whole
+// file or none. tmp must therefore be on the same filesystem as the rest.
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/filelock"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Kind is a directory of per-job files
+type Kind string
+
+const (
+	Plans   Kind = "plans"
+	Results Kind = "results"
+	Records Kind = "records"
+	Batch   Kind = "batch"
+	Elastic Kind = "elastic"
+)
+
+// Kinds lists the per-job directories in the layout
+var Kinds = []Kind{Plans, Results, Records, Batch, Elastic}
+
+const (
+	locksDir = "locks"
+	tmpDir   = "tmp"
+
+	// staleTmpAge is how long a staged file may sit in tmp before cleanup treats
+	// it as left behind by a crashed writer
+	staleTmpAge = time.Hour
+)
+
+// jobIDPattern keeps job IDs to a single safe path component
+var jobIDPattern = regexp.MustCompile(`^[A-Za-z0-9_+-][A-Za-z0-9_.+-]*$`)
+
+// Path returns the file holding a job's data of the given kind
+func Path(root string, kind Kind, jobID string) string {
+	return filepath.Join(root, string(kind), "job-"+jobID+".json")
+}
+
+// Init creates the directory layout under root
+func Init(root string) error {
+	if root == "" {
+		return fmt.Errorf("data exchange directory is not configured")
+	}
+	for _, dir := range append(kindDirs(), locksDir, tmpDir) {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return fmt.Errorf("failed to create data exchange directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// Write stores v as JSON for the job, replacing any earlier file atomically
+func Write(root string, kind Kind, jobID string, v interface{}) (string, error) {
+	if !jobIDPattern.MatchString(jobID) {
+		return "", fmt.Errorf("invalid job ID %q", jobID)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s for job %s: %w", kind, jobID, err)
+	}
+	if err := Init(root); err != nil {
+		return "", err
+	}
+
+	path := Path(root, kind, jobID)
+	if err := writeAtomic(filepath.Join(root, tmpDir), path, data); err != nil {
+		return "", fmt.Errorf("failed to write %s for job %s: %w", kind, jobID, err)
+	}
+	return path, nil
+}
+
+// Read loads the job's file of the given kind into v. It returns false and no
+// error when the job has none.
+func Read(root string, kind Kind, jobID string, v interface{}) (bool, error) {
+	if !jobIDPattern.MatchString(jobID) {
+		return false, fmt.Errorf("invalid job ID %q", jobID)
+	}
+	data, err := os.ReadFile(Path(root, kind, jobID))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s for job %s: %w", kind, jobID, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("invalid %s for job %s: %w", kind, jobID, err)
+	}
+	return true, nil
+}
+
+// WriteShared stores v as JSON in a file at the top of root that is not tied to
+// a job, replacing any earlier file atomically
+func WriteShared(root, name string, v interface{}) (string, error) {
+	if !jobIDPattern.MatchString(name) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	if err := Init(root); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(root, name)
+	if err := writeAtomic(filepath.Join(root, tmpDir), path, data); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return path, nil
+}
+
+// ReadShared loads a file written by WriteShared into v. It returns false and no
+// error when the file does not exist.
+func ReadShared(root, name string, v interface{}) (bool, error) {
+	if !jobIDPattern.MatchString(name) {
+		return false, fmt.Errorf("invalid file name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(root, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// WriteResult stores the outcome of launching the job's nodes
+func WriteResult(root, jobID string, result *types.ExecutionResult) (string, error) {
+	return Write(root, Results, jobID, result)
+}
+
+// ResultID is the ID a result is kept under: its job's ID or, for a resume
+// that knew of no job, "trace-" and its trace ID
+func ResultID(result *types.ExecutionResult) string {
+	if result.JobID != "" {
+		return result.JobID
+	}
+	return "trace-" + result.TraceID
+}
+
+// ReadResult loads the result kept under id. It returns nil and no error when
+// there is none.
+func ReadResult(root, id string) (*types.ExecutionResult, error) {
+	var result types.ExecutionResult
+	found, err := Read(root, Results, id, &result)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// HasPlans reports whether any plans are waiting, so callers can skip looking up
+// job IDs when ASBA has written nothing
+// List returns the IDs of the jobs with a file of the given kind
+func List(root string, kind Kind) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(root, string(kind), "job-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), "job-"), ".json")
+	}
+	return ids, nil
+}
+
+func HasPlans(root string) bool {
+	matches, err := filepath.Glob(filepath.Join(root, string(Plans), "job-*.json"))
+	return err == nil && len(matches) > 0
+}
+
+// Lock takes the named advisory lock, waiting up to timeout. The returned
+// function releases it.
+func Lock(root, name string, timeout time.Duration) (func(), error) {
+	if strings.ContainsAny(name, `/\`) || name == "" {
+		return nil, fmt.Errorf("invalid lock name %q", name)
+	}
+	if err := Init(root); err != nil {
+		return nil, err
+	}
+
+	return filelock.Lock(filepath.Join(root, locksDir, name+".lock"), timeout)
+}
+
+// Cleanup removes per-job files older than retention and staged files left by
+// crashed writers. A zero retention keeps job files forever. It returns the
+// removed paths.
+func Cleanup(root string, retention time.Duration, now time.Time) ([]string, error) {
+	if _, err := os.Stat(root); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	unlock, err := Lock(root, "cleanup", 0)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		// Another cleaner is running
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var removed []string
+	remove := func(dir, pattern string, maxAge time.Duration) error {
+		files, err := filepath.Glob(filepath.Join(root, dir, pattern))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			info, err := os.Stat(file)
+			if err != nil || now.Sub(info.ModTime()) <= maxAge {
+				continue
+			}
+			if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove %s: %w", file, err)
+			}
+			removed = append(removed, file)
+		}
+		return nil
+	}
+
+	if err := remove(tmpDir, "*", staleTmpAge); err != nil {
+		return removed, err
+	}
+	if retention > 0 {
+		for _, dir := range kindDirs() {
+			if err := remove(dir, "job-*.json", retention); err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// Check reports problems that would stop tools exchanging files through root:
+// a missing or unwritable layout and renames that fail between tmp and the
+// per-job directories
+func Check(root string) []error {
+	if root == "" {
+		return []error{fmt.Errorf("ecosystem.data_exchange_dir is not set")}
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return []error{fmt.Errorf("data exchange directory %s: %w", root, err)}
+	}
+	if !info.IsDir() {
+		return []error{fmt.Errorf("data exchange directory %s is not a directory", root)}
+	}
+
+	var problems []error
+	for _, dir := range append(kindDirs(), locksDir, tmpDir) {
+		path := filepath.Join(root, dir)
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Errorf("missing %s; create it with aws-slurm-burst-validate exchange --init", path))
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+
+	for _, dir := range kindDirs() {
+		probe := filepath.Join(root, dir, fmt.Sprintf(".probe-%d", os.Getpid()))
+		if err := writeAtomic(filepath.Join(root, tmpDir), probe, []byte("{}")); err != nil {
+			problems = append(problems, fmt.Errorf("cannot write to %s: %w", filepath.Join(root, dir), err))
+			continue
+		}
+		os.Remove(probe)
+	}
+	return problems
+}
+
+func kindDirs() []string {
+	dirs := make([]string, len(Kinds))
+	for i, kind := range Kinds {
+		dirs[i] = string(kind)
+	}
+	return dirs
+}
+
+// writeAtomic stages data in stageDir and renames it to path
+func writeAtomic(stageDir, path string, data []byte) error {
+	tmp, err := os.CreateTemp(stageDir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,118 @@
+package exchange
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func TestWriteRead(t *testing.T) {
+	root := t.TempDir()
+
+	path, err := WriteResult(root, "42", &types.ExecutionResult{Success: true, FleetID: "fleet-1"})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "results", "job-42.json"), path)
+
+	var result types.ExecutionResult
+	found, err := Read(root, Results, "42", &result)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "fleet-1", result.FleetID)
+
+	found, err = Read(root, Results, "43", &result)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	read, err := ReadResult(root, "42")
+	require.NoError(t, err)
+	require.NotNil(t, read)
+	assert.True(t, read.Success)
+	read, err = ReadResult(root, "43")
+	require.NoError(t, err)
+	assert.Nil(t, read)
+
+	assert.Equal(t, "42", ResultID(&types.ExecutionResult{JobID: "42", TraceID: "abc"}))
+	assert.Equal(t, "trace-abc", ResultID(&types.ExecutionResult{TraceID: "abc"}))
+
+	staged, err := os.ReadDir(filepath.Join(root, tmpDir))
+	require.NoError(t, err)
+	assert.Empty(t, staged, "staged files are renamed into place")
+
+	for _, jobID := range []string{"", "../etc", "a/b", ".hidden"} {
+		_, err := Write(root, Plans, jobID, struct{}{})
+		assert.Error(t, err, jobID)
+	}
+	_, err = Write(root, Plans, "42_1", struct{}{})
+	assert.NoError(t, err, "array job IDs are allowed")
+	assert.True(t, HasPlans(root))
+
+	ids, err := List(root, Results)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"42"}, ids)
+}
+
+func TestLock(t *testing.T) {
+	root := t.TempDir()
+
+	unlock, err := Lock(root, "job-42", time.Second)
+	require.NoError(t, err)
+
+	_, err = Lock(root, "job-42", 100*time.Millisecond)
+	assert.Error(t, err, "lock is held")
+
+	unlock()
+	unlock, err = Lock(root, "job-42", 0)
+	require.NoError(t, err)
+	unlock()
+
+	_, err = Lock(root, "../escape", 0)
+	assert.Error(t, err)
+}
+
+func TestCleanup(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	old := now.Add(-40 * 24 * time.Hour)
+
+	oldPlan, err := Write(root, Plans, "1", struct{}{})
+	require.NoError(t, err)
+	require.NoError(t, os.Chtimes(oldPlan, old, old))
+	newPlan, err := Write(root, Plans, "2", struct{}{})
+	require.NoError(t, err)
+	staged := filepath.Join(root, tmpDir, "job-3.json.123.tmp")
+	require.NoError(t, os.WriteFile(staged, nil, 0644))
+	require.NoError(t, os.Chtimes(staged, now.Add(-2*time.Hour), now.Add(-2*time.Hour)))
+
+	kept, err := Cleanup(root, 0, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{staged}, kept, "zero retention only removes stale staged files")
+
+	removed, err := Cleanup(root, 30*24*time.Hour, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{oldPlan}, removed)
+	assert.FileExists(t, newPlan)
+
+	removed, err = Cleanup(filepath.Join(root, "missing"), time.Hour, now)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+}
+
+func TestCheck(t *testing.T) {
+	assert.NotEmpty(t, Check(""))
+
+	root := t.TempDir()
+	assert.Len(t, Check(root), 7, "every layout directory is missing")
+
+	require.NoError(t, Init(root))
+	assert.Empty(t, Check(root))
+
+	entries, err := os.ReadDir(filepath.Join(root, "plans"))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "probes are removed")
+}
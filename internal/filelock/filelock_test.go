@@ -0,0 +1,25 @@
+package filelock
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool", "state.lock")
+	unlock, err := Lock(path, time.Second)
+	require.NoError(t, err)
+
+	_, err = Lock(path, 100*time.Millisecond)
+	assert.ErrorIs(t, err, syscall.EWOULDBLOCK, "held by another open file")
+
+	unlock()
+	unlock, err = Lock(path, 0)
+	require.NoError(t, err)
+	unlock()
+}
@@ -0,0 +1,189 @@
+// Package healthcheck gates newly launched burst nodes on health checks. Resume
+// drains the nodes it launches with PendingReason; the state manager runs the
+// checks on them through SSM Run Command and undrains those that pass, so jobs
+// only start on nodes that are fully configured. Draining does not hold back
+// the job that resumed the nodes, which Slurm already allocated them, so a
+// node prolog hook runs the same checks on the node before any job starts and
+// fails the job when they do not pass; Slurm then drains the node and
+// requeues the job.
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ssm"
+)
+
+// Slurm reasons of nodes waiting for and failing their health checks
+const (
+	PendingReason = "health_check_pending"
+	FailedReason  = "health_check_failed"
+)
+
+// CommandTimeout bounds one run of the checks on a node; the state manager
+// waits this long for the results
+const CommandTimeout = 60 * time.Second
+
+// PrologFilename is the user-data part installing the prolog hook
+const PrologFilename = "05-aws-slurm-burst-health-check.sh"
+
+// prologWait is how long the prolog hook retries failing checks, since the
+// job's first prolog may run while user data is still finishing
+const prologWait = 2 * time.Minute
+
+// Runner runs commands on nodes by name; ssm.Nodes in production
+type Runner interface {
+	Run(ctx context.Context, nodes []string, command ssm.Command) (map[string]ssm.CommandResult, error)
+}
+
+// Node is a node waiting for its health checks
+type Node struct {
+	Name       string
+	LaunchTime time.Time // Zero when the node has no running instance
+}
+
+// Result sorts the checked nodes
+type Result struct {
+	Passed  []string
+	Failed  map[string]string // Why each node failed, by node name
+	Waiting map[string]string // Why each node has not passed yet, by node name
+}
+
+// Script returns the shell script running the configured checks. It prints
+// "FAIL <check>: <why>" for each failing check and exits 1 if any failed.
+func Script(healthCheck *config.HealthCheckConfig) string {
+	return `#!/bin/bash
+# Generated by aws-slurm-burst: node health checks
+failed=0
+fail() { echo "FAIL $1: $2"; failed=1; }
+` + Commands(healthCheck, healthCheck.Checks) + "exit $failed\n"
+}
+
+// PrologScript returns a user-data script installing a node prolog hook that
+// runs the configured checks before each job starts. The hook retries failing
+// checks for a while, then fails the job.
+func PrologScript(healthCheck *config.HealthCheckConfig) string {
+	return fmt.Sprintf(`#!/bin/bash
+# Generated by aws-slurm-burst: health check prolog hook
+mkdir -p /etc/slurm/prolog.d
+cat > /etc/slurm/prolog.d/40-aws-slurm-burst-health-check.sh <<'HOOK_EOF'
+#!/bin/bash
+exec >> /var/log/aws-slurm-burst-health-check.log 2>&1
+checks() {
+failed=0
+fail() { echo "FAIL $1: $2"; failed=1; }
+%sreturn $failed
+}
+deadline=$((SECONDS + %d))
+until checks; do
+  if [ $SECONDS -ge $deadline ]; then
+    echo "Health checks failed before job $SLURM_JOB_ID"
+    exit 1
+  fi
+  sleep 5
+done
+exit 0
+HOOK_EOF
+chmod 755 /etc/slurm/prolog.d/40-aws-slurm-burst-health-check.sh
+`, Commands(healthCheck, healthCheck.Checks), int(prologWait.Seconds()))
+}
+
+// Commands returns the shell commands running checks, for a script that
+// defines fail
+func Commands(healthCheck *config.HealthCheckConfig, checks []string) string {
+	var b strings.Builder
+	for _, check := range checks {
+		switch check {
+		case config.HealthCheckSlurmd:
+			b.WriteString(`systemctl is-active --quiet slurmd || fail slurmd "slurmd is not active"
+`)
+		case config.HealthCheckEFA:
+			// Only instances launched with an EFA interface have the device
+			b.WriteString(`if grep -qs 0xefa /sys/bus/pci/devices/*/device; then
+  /opt/amazon/efa/bin/fi_info -p efa >/dev/null 2>&1 || fail efa "EFA device attached but libfabric cannot use it"
+fi
+`)
+		case config.HealthCheckScratch:
+			fmt.Fprintf(&b, "mountpoint -q %s || fail scratch \"%s is not mounted\"\n", healthCheck.ScratchPath, healthCheck.ScratchPath)
+		case config.HealthCheckClock:
+			b.WriteString(`if [ "$(timedatectl show -p NTPSynchronized --value 2>/dev/null)" != yes ] &&
+   ! chronyc tracking 2>/dev/null | grep -q "Leap status *: Normal"; then
+  fail clock "clock is not synchronized"
+fi
+`)
+		}
+	}
+	return b.String()
+}
+
+// Check runs the health checks on the nodes. A node that does not pass stays
+// waiting until the timeout from its launch runs out, since its SSM agent or
+// user data may still be starting; then it fails. Nodes left out by a runner
+// error, such as a region whose SSM calls failed, keep waiting whatever their
+// age, so an SSM outage does not replace healthy nodes.
+func Check(ctx context.Context, runner Runner, healthCheck *config.HealthCheckConfig, nodes []Node, now time.Time) (*Result, error) {
+	result := &Result{Failed: make(map[string]string), Waiting: make(map[string]string)}
+	if len(nodes) == 0 {
+		return result, nil
+	}
+
+	names := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		names = append(names, node.Name)
+	}
+	outcomes, err := runner.Run(ctx, names, ssm.Command{
+		Script:  Script(healthCheck),
+		Comment: "aws-slurm-burst node health check",
+		Timeout: CommandTimeout,
+	})
+	if err != nil && len(outcomes) == 0 {
+		return nil, err
+	}
+
+	timeout := time.Duration(healthCheck.TimeoutSeconds) * time.Second
+	for _, node := range nodes {
+		outcome, ran := outcomes[node.Name]
+		var reason string
+		switch {
+		case ran && outcome.Status == "Success":
+			result.Passed = append(result.Passed, node.Name)
+			continue
+		case ran && outcome.Status == ssm.StatusUndeliverable:
+			reason = outcome.Output
+		case !ran:
+			result.Waiting[node.Name] = "health checks not sent"
+			continue
+		case !outcome.Done():
+			reason = "health checks still running"
+		default:
+			reason = failures(outcome)
+		}
+		if now.Sub(node.LaunchTime) >= timeout {
+			result.Failed[node.Name] = reason
+		} else {
+			result.Waiting[node.Name] = reason
+		}
+	}
+	return result, err
+}
+
+// failures summarizes the checks that failed from the script's output
+func failures(outcome ssm.CommandResult) string {
+	var failed []string
+	for _, line := range strings.Split(outcome.Output, "\n") {
+		if check, ok := strings.CutPrefix(strings.TrimSpace(line), "FAIL "); ok {
+			failed = append(failed, check)
+		}
+	}
+	if len(failed) == 0 && outcome.Status == "TimedOut" {
+		return "health checks timed out"
+	}
+	if len(failed) == 0 {
+		return "health checks " + strings.ToLower(outcome.Status)
+	}
+	return strings.Join(failed, "; ")
+}
@@ -0,0 +1,90 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRunner struct {
+	results map[string]ssm.CommandResult
+	err     error
+	ran     []string
+}
+
+func (f *fakeRunner) Run(ctx context.Context, nodes []string, command ssm.Command) (map[string]ssm.CommandResult, error) {
+	f.ran = nodes
+	return f.results, f.err
+}
+
+func TestScript(t *testing.T) {
+	script := Script(&config.HealthCheckConfig{Checks: []string{config.HealthCheckSlurmd, config.HealthCheckScratch}, ScratchPath: "/scratch"})
+	assert.Contains(t, script, "systemctl is-active --quiet slurmd")
+	assert.Contains(t, script, `mountpoint -q /scratch || fail scratch "/scratch is not mounted"`)
+	assert.NotContains(t, script, "fi_info", "EFA not checked")
+	assert.Contains(t, script, "exit $failed\n")
+}
+
+func TestPrologScript(t *testing.T) {
+	script := PrologScript(&config.HealthCheckConfig{Checks: []string{config.HealthCheckScratch}, ScratchPath: "/scratch"})
+	assert.Contains(t, script, "cat > /etc/slurm/prolog.d/40-aws-slurm-burst-health-check.sh <<'HOOK_EOF'")
+	assert.Contains(t, script, `mountpoint -q /scratch || fail scratch "/scratch is not mounted"`)
+	assert.Contains(t, script, "deadline=$((SECONDS + 120))")
+	assert.Contains(t, script, "    exit 1\n", "fails the job once the checks run out of time")
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	healthCheck := &config.HealthCheckConfig{Checks: []string{config.HealthCheckSlurmd, config.HealthCheckEFA}, TimeoutSeconds: 600}
+	runner := &fakeRunner{
+		results: map[string]ssm.CommandResult{
+			"aws-cpu-001": {InstanceID: "i-1", Status: "Success"},
+			"aws-cpu-002": {InstanceID: "i-2", Status: "Failed", Output: "FAIL slurmd: slurmd is not active\nFAIL efa: EFA device attached but libfabric cannot use it\n"},
+			"aws-cpu-003": {InstanceID: "i-3", Status: "Failed", Output: "FAIL efa: EFA device attached but libfabric cannot use it\n"},
+			"aws-cpu-004": {InstanceID: "i-4", Status: ssm.StatusUndeliverable, Output: "SSM agent not online"},
+			"aws-cpu-005": {InstanceID: "i-5", Status: "TimedOut"},
+			"aws-cpu-006": {Status: ssm.StatusUndeliverable, Output: "no running instance"},
+		},
+	}
+	nodes := []Node{
+		{Name: "aws-cpu-001", LaunchTime: now.Add(-3 * time.Minute)},
+		{Name: "aws-cpu-002", LaunchTime: now.Add(-3 * time.Minute)},
+		{Name: "aws-cpu-003", LaunchTime: now.Add(-11 * time.Minute)},
+		{Name: "aws-cpu-004", LaunchTime: now.Add(-time.Minute)},
+		{Name: "aws-cpu-005", LaunchTime: now.Add(-10 * time.Minute)},
+		{Name: "aws-cpu-006"},
+	}
+
+	result, err := Check(context.Background(), runner, healthCheck, nodes, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws-cpu-001", "aws-cpu-002", "aws-cpu-003", "aws-cpu-004", "aws-cpu-005", "aws-cpu-006"}, runner.ran)
+	assert.Equal(t, []string{"aws-cpu-001"}, result.Passed)
+	assert.Equal(t, map[string]string{
+		"aws-cpu-002": "slurmd: slurmd is not active; efa: EFA device attached but libfabric cannot use it",
+		"aws-cpu-004": "SSM agent not online",
+	}, result.Waiting)
+	assert.Equal(t, map[string]string{
+		"aws-cpu-003": "efa: EFA device attached but libfabric cannot use it",
+		"aws-cpu-005": "health checks timed out",
+		"aws-cpu-006": "no running instance",
+	}, result.Failed)
+
+	// Nodes in a region whose SSM calls failed keep waiting past the timeout
+	runner.results = map[string]ssm.CommandResult{"aws-cpu-001": {InstanceID: "i-1", Status: "Success"}}
+	runner.err = errors.New("us-west-2: SSM DescribeInstanceInformation failed")
+	result, err = Check(context.Background(), runner, healthCheck, nodes[:3], now)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"aws-cpu-001"}, result.Passed)
+	assert.Equal(t, map[string]string{"aws-cpu-002": "health checks not sent", "aws-cpu-003": "health checks not sent"}, result.Waiting)
+	assert.Empty(t, result.Failed)
+
+	runner.results = nil
+	_, err = Check(context.Background(), runner, healthCheck, nodes, now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,158 @@
+// Package hooks runs site hooks at points of the burst node lifecycle: before
+// and after a node group's instances launch, and before and after nodes'
+// instances are terminated or stopped. A hook is a command given the event's
+// JSON payload on stdin, or a webhook the payload is POSTed to, so sites can
+// check out licenses, register nodes in a CMDB or account usage without
+// changing aws-slurm-burst.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// Payload describes an event to the hooks
+type Payload struct {
+	Event     string               `json:"event"`
+	Time      time.Time            `json:"time"`
+	Partition string               `json:"partition,omitempty"`
+	NodeGroup string               `json:"node_group,omitempty"`
+	Nodes     []string             `json:"nodes"`
+	JobID     string               `json:"job_id,omitempty"`
+	Instances []types.InstanceInfo `json:"instances,omitempty"` // Launched, or about to be terminated
+	Error     string               `json:"error,omitempty"`     // Why the launch or termination failed
+}
+
+// maxResponseBytes is how much of a failed webhook's response is reported
+const maxResponseBytes = 512
+
+// Runner runs the configured hooks of an event in order
+type Runner struct {
+	logger *zap.Logger
+	hooks  []config.HookConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewRunner creates a runner for the configured hooks
+func NewRunner(logger *zap.Logger, hooks []config.HookConfig) *Runner {
+	return &Runner{logger: logger, hooks: hooks, client: &http.Client{}, now: time.Now}
+}
+
+// Handles reports whether any hook runs at event, for callers with payload
+// details that cost API calls to gather
+func (r *Runner) Handles(event string) bool {
+	for i := range r.hooks {
+		if r.hooks[i].Handles(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs the hooks subscribed to the payload's event in configuration
+// order. A failing hook is logged and the next one runs, except a required
+// hook, whose failure stops the event's hooks and is returned.
+func (r *Runner) Run(ctx context.Context, payload Payload) error {
+	if !r.Handles(payload.Event) {
+		return nil
+	}
+	if payload.Time.IsZero() {
+		payload.Time = r.now().UTC()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s hook payload: %w", payload.Event, err)
+	}
+	body = append(body, '\n')
+
+	for i := range r.hooks {
+		hook := &r.hooks[i]
+		if !hook.Handles(payload.Event) {
+			continue
+		}
+		start := time.Now()
+		err := r.runHook(ctx, hook, &payload, body)
+		if err == nil {
+			r.logger.Debug("Ran hook",
+				zap.String("hook", hook.Name),
+				zap.String("event", payload.Event),
+				zap.Duration("duration", time.Since(start)))
+			continue
+		}
+		if hook.Required {
+			return fmt.Errorf("hook %s: %w", hook.Name, err)
+		}
+		r.logger.Warn("Hook failed",
+			zap.String("hook", hook.Name),
+			zap.String("event", payload.Event),
+			zap.String("nodes", slurm.CompressHostlist(payload.Nodes)),
+			zap.Error(err))
+	}
+	return nil
+}
+
+// runHook runs one hook within its timeout
+func (r *Runner) runHook(ctx context.Context, hook *config.HookConfig, payload *Payload, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, hook.Timeout())
+	defer cancel()
+	if hook.URL != "" {
+		return r.post(ctx, hook, payload, body)
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", hook.Command)
+	// A hook past its timeout is killed with everything it started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"AWS_SLURM_BURST_HOOK_EVENT="+payload.Event,
+		"AWS_SLURM_BURST_HOOK_NAME="+hook.Name,
+		"AWS_SLURM_BURST_NODES="+slurm.CompressHostlist(payload.Nodes),
+		"AWS_SLURM_BURST_PARTITION="+payload.Partition,
+		"AWS_SLURM_BURST_NODE_GROUP="+payload.NodeGroup,
+		"AWS_SLURM_BURST_JOB_ID="+payload.JobID)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("command failed: %w: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// post POSTs the payload to a webhook, which must answer with a 2xx status
+func (r *Runner) post(ctx context.Context, hook *config.HookConfig, payload *Payload, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "aws-slurm-burst")
+	request.Header.Set("X-AWS-Slurm-Burst-Event", payload.Event)
+	for name, value := range hook.Headers {
+		request.Header.Set(name, os.ExpandEnv(value))
+	}
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("webhook failed: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, maxResponseBytes))
+		return fmt.Errorf("webhook returned %s: %s", response.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
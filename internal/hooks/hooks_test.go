@@ -0,0 +1,98 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRunner_Run(t *testing.T) {
+	dir := t.TempDir()
+	commandOut := filepath.Join(dir, "command")
+
+	var received []Payload
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &payload))
+		received, headers = append(received, payload), append(headers, r.Header)
+		if payload.Event == config.HookEventPostTerminate {
+			http.Error(w, "cmdb unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("CMDB_TOKEN", "secret")
+	runner := NewRunner(zap.NewNop(), []config.HookConfig{
+		{Name: "licenses", Events: []string{config.HookEventPreLaunch}, TimeoutSeconds: 5,
+			Command: `cat > ` + commandOut + `; echo "$AWS_SLURM_BURST_HOOK_EVENT $AWS_SLURM_BURST_NODES $AWS_SLURM_BURST_JOB_ID" >> ` + commandOut},
+		{Name: "cmdb", Events: []string{config.HookEventPostLaunch, config.HookEventPostTerminate}, URL: server.URL, TimeoutSeconds: 5,
+			Headers: map[string]string{"Authorization": "Bearer ${CMDB_TOKEN}"}},
+	})
+	runner.now = func() time.Time { return time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC) }
+	assert.True(t, runner.Handles(config.HookEventPostTerminate))
+	assert.False(t, runner.Handles(config.HookEventPreTerminate))
+
+	require.NoError(t, runner.Run(context.Background(), Payload{
+		Event: config.HookEventPreLaunch, Partition: "aws", NodeGroup: "cpu", Nodes: []string{"aws-cpu-001", "aws-cpu-002"}, JobID: "42",
+	}))
+	data, err := os.ReadFile(commandOut)
+	require.NoError(t, err)
+	payloadJSON, env, _ := strings.Cut(string(data), "}\n")
+	var payload Payload
+	require.NoError(t, json.Unmarshal([]byte(payloadJSON+"}"), &payload))
+	assert.Equal(t, "2026-10-18T12:00:00Z", payload.Time.Format(time.RFC3339))
+	assert.Equal(t, []string{"aws-cpu-001", "aws-cpu-002"}, payload.Nodes)
+	assert.Equal(t, "pre_launch aws-cpu-[001-002] 42\n", env)
+	assert.Empty(t, received, "the webhook does not handle pre_launch")
+
+	instances := []types.InstanceInfo{{NodeName: "aws-cpu-001", InstanceID: "i-1", PrivateIP: "10.0.0.1"}}
+	require.NoError(t, runner.Run(context.Background(), Payload{Event: config.HookEventPostLaunch, Nodes: []string{"aws-cpu-001"}, Instances: instances}))
+	require.Len(t, received, 1)
+	assert.Equal(t, instances, received[0].Instances)
+	assert.Equal(t, "Bearer secret", headers[0].Get("Authorization"))
+	assert.Equal(t, config.HookEventPostLaunch, headers[0].Get("X-AWS-Slurm-Burst-Event"))
+	assert.Equal(t, "application/json", headers[0].Get("Content-Type"))
+
+	// Optional hooks fail without failing the event
+	assert.NoError(t, runner.Run(context.Background(), Payload{Event: config.HookEventPostTerminate, Nodes: []string{"aws-cpu-001"}}))
+	assert.Len(t, received, 2)
+}
+
+func TestRunner_Required(t *testing.T) {
+	dir := t.TempDir()
+	ran := filepath.Join(dir, "ran")
+	runner := NewRunner(zap.NewNop(), []config.HookConfig{
+		{Name: "optional", Events: []string{config.HookEventPreLaunch}, Command: "exit 3", TimeoutSeconds: 5},
+		{Name: "licenses", Events: []string{config.HookEventPreLaunch}, Command: "echo no licenses left; exit 1", TimeoutSeconds: 5, Required: true},
+		{Name: "after", Events: []string{config.HookEventPreLaunch}, Command: "touch " + ran, TimeoutSeconds: 5},
+	})
+	err := runner.Run(context.Background(), Payload{Event: config.HookEventPreLaunch, Nodes: []string{"aws-cpu-001"}})
+	assert.ErrorContains(t, err, "hook licenses: command failed: exit status 1: no licenses left")
+	assert.NoFileExists(t, ran, "a required failure stops the event's hooks")
+
+	// A hook past its timeout is killed
+	runner = NewRunner(zap.NewNop(), []config.HookConfig{
+		{Name: "slow", Events: []string{config.HookEventPreLaunch}, Command: "sleep 5", TimeoutSeconds: 1, Required: true},
+	})
+	start := time.Now()
+	assert.Error(t, runner.Run(context.Background(), Payload{Event: config.HookEventPreLaunch}))
+	assert.Less(t, time.Since(start), 4*time.Second)
+
+	// Nothing runs without hooks
+	assert.NoError(t, NewRunner(zap.NewNop(), nil).Run(context.Background(), Payload{Event: config.HookEventPreLaunch}))
+}
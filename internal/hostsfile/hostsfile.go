@@ -0,0 +1,153 @@
+// Package hostsfile keeps burst node entries in the controller's hosts file,
+// for sites without DNS for burst nodes. The entries live in a block between
+// marker comments, so the rest of the file is left as the site wrote it.
+// Resume and suspend run concurrently, so each change takes an advisory lock
+// and replaces the file atomically.
+package hostsfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/filelock"
+)
+
+// The markers around the managed block
+const (
+	BeginMarker = "# BEGIN aws-slurm-burst nodes"
+	EndMarker   = "# END aws-slurm-burst nodes"
+)
+
+// File is a hosts file with a managed block of burst nodes
+type File struct {
+	path string
+}
+
+// New returns the hosts file at path. A symlink is followed, so the file can
+// live in a directory the Slurm user may write.
+func New(path string) *File {
+	return &File{path: path}
+}
+
+// Add points each node name at its address, replacing the node's earlier entry
+func (f *File) Add(addresses map[string]string) error {
+	if len(addresses) == 0 {
+		return nil
+	}
+	return f.update(func(entries map[string]string) {
+		for node, address := range addresses {
+			entries[node] = address
+		}
+	})
+}
+
+// Remove drops the nodes' entries; nodes without one are ignored
+func (f *File) Remove(nodes []string) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	return f.update(func(entries map[string]string) {
+		for _, node := range nodes {
+			delete(entries, node)
+		}
+	})
+}
+
+// update locks the file, applies change to the managed entries and writes the
+// file back through a temporary file renamed over it
+func (f *File) update(change func(entries map[string]string)) error {
+	path, err := filepath.EvalSymlinks(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve hosts file %s: %w", f.path, err)
+	}
+	unlock, err := filelock.Lock(path+".aws-slurm-burst.lock", 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to lock hosts file %s: %w", path, err)
+	}
+	defer unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read hosts file %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read hosts file %s: %w", path, err)
+	}
+	outside, entries, err := parse(string(data))
+	if err != nil {
+		return fmt.Errorf("hosts file %s: %w", path, err)
+	}
+	change(entries)
+
+	tmp := path + ".aws-slurm-burst.tmp"
+	if err := os.WriteFile(tmp, []byte(render(outside, entries)), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write hosts file %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace hosts file %s: %w", path, err)
+	}
+	return nil
+}
+
+// parse splits a hosts file into the lines outside the managed block and the
+// block's entries
+func parse(data string) (string, map[string]string, error) {
+	var outside strings.Builder
+	entries := make(map[string]string)
+	inBlock := false
+	for _, line := range strings.SplitAfter(data, "\n") {
+		switch trimmed := strings.TrimSpace(line); {
+		case trimmed == BeginMarker:
+			if inBlock {
+				return "", nil, fmt.Errorf("nested %q", BeginMarker)
+			}
+			inBlock = true
+		case trimmed == EndMarker:
+			if !inBlock {
+				return "", nil, fmt.Errorf("%q without %q", EndMarker, BeginMarker)
+			}
+			inBlock = false
+		case inBlock:
+			if fields := strings.Fields(trimmed); len(fields) >= 2 && !strings.HasPrefix(fields[0], "#") {
+				entries[fields[1]] = fields[0]
+			}
+		default:
+			outside.WriteString(line)
+		}
+	}
+	if inBlock {
+		return "", nil, fmt.Errorf("%q without %q", BeginMarker, EndMarker)
+	}
+	return outside.String(), entries, nil
+}
+
+// render appends the managed block, sorted by node name, to the rest of the
+// file; without entries the block is left out
+func render(outside string, entries map[string]string) string {
+	if len(entries) == 0 {
+		return outside
+	}
+	nodes := make([]string, 0, len(entries))
+	for node := range entries {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	var b strings.Builder
+	b.WriteString(outside)
+	if outside != "" && !strings.HasSuffix(outside, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(BeginMarker + "\n")
+	for _, node := range nodes {
+		fmt.Fprintf(&b, "%s\t%s\n", entries[node], node)
+	}
+	b.WriteString(EndMarker + "\n")
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package hostsfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts")
+	site := "127.0.0.1\tlocalhost\n10.0.1.5\thead-node # controller\n"
+	require.NoError(t, os.WriteFile(path, []byte(site), 0644))
+
+	// The file is reached through a symlink, as /etc/hosts would be
+	link := filepath.Join(dir, "hosts-link")
+	require.NoError(t, os.Symlink(path, link))
+	hosts := New(link)
+
+	require.NoError(t, hosts.Add(map[string]string{"aws-cpu-002": "10.0.0.12", "aws-cpu-001": "10.0.0.11"}))
+	require.NoError(t, hosts.Add(map[string]string{"aws-cpu-001": "10.0.0.21", "aws-gpu-001": "10.0.0.31"}), "replaced instance")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, site+BeginMarker+"\n10.0.0.21\taws-cpu-001\n10.0.0.12\taws-cpu-002\n10.0.0.31\taws-gpu-001\n"+EndMarker+"\n", string(data))
+
+	// Site edits outside the block are kept
+	require.NoError(t, os.WriteFile(path, append(data, "10.0.1.6\tlogin\n"...), 0644))
+	require.NoError(t, hosts.Remove([]string{"aws-cpu-001", "aws-cpu-009"}))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, site+"10.0.1.6\tlogin\n"+BeginMarker+"\n10.0.0.12\taws-cpu-002\n10.0.0.31\taws-gpu-001\n"+EndMarker+"\n", string(data))
+
+	require.NoError(t, hosts.Remove([]string{"aws-cpu-002", "aws-gpu-001"}))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, site+"10.0.1.6\tlogin\n", string(data), "an empty block is removed")
+	target, err := os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, path, target, "the symlink is kept")
+
+	// A damaged block is not rewritten
+	require.NoError(t, os.WriteFile(path, []byte(site+BeginMarker+"\n10.0.0.11\taws-cpu-001\n"), 0644))
+	assert.ErrorContains(t, hosts.Add(map[string]string{"aws-cpu-002": "10.0.0.12"}), "without")
+}
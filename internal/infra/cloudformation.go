@@ -0,0 +1,114 @@
+package infra
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// CloudFormation renders spec as a CloudFormation template in JSON. source
+// names the configuration it was generated from.
+func CloudFormation(spec *Spec, source string) ([]byte, error) {
+	ref := func(name string) map[string]string { return map[string]string{"Ref": name} }
+	managedTags := []map[string]string{{"Key": managedByTag, "Value": managedByValue}}
+
+	parameters := map[string]interface{}{
+		"VpcId":            map[string]string{"Type": "AWS::EC2::VPC::Id", "Description": "VPC the burst nodes launch in"},
+		"AvailabilityZone": map[string]string{"Type": "AWS::EC2::AvailabilityZone::Name", "Description": "Availability zone of the burst subnet; cluster placement groups need a single zone"},
+		"SubnetCidr":       map[string]string{"Type": "String", "Description": "CIDR block of the burst subnet"},
+		"HeadNodeCidr":     map[string]string{"Type": "String", "Description": "CIDR block of the Slurm head node, allowed to reach slurmd and MPI ports"},
+	}
+	for _, architecture := range spec.Architectures {
+		parameters[identifier(amiParameter(architecture), true)] = map[string]string{
+			"Type":        "AWS::EC2::Image::Id",
+			"Description": "AMI of the " + architecture + " burst nodes, with slurmd installed",
+		}
+	}
+
+	arn := func(pattern string) interface{} {
+		return map[string]string{"Fn::Sub": strings.ReplaceAll(pattern, "{account}", "${AWS::AccountId}")}
+	}
+	resources := map[string]interface{}{
+		"BurstSubnet": map[string]interface{}{
+			"Type": "AWS::EC2::Subnet",
+			"Properties": map[string]interface{}{
+				"VpcId":            ref("VpcId"),
+				"CidrBlock":        ref("SubnetCidr"),
+				"AvailabilityZone": ref("AvailabilityZone"),
+				"Tags":             append([]map[string]string{{"Key": "Name", "Value": "aws-slurm-burst"}}, managedTags...),
+			},
+		},
+		"BurstNodesSecurityGroup": map[string]interface{}{
+			"Type": "AWS::EC2::SecurityGroup",
+			"Properties": map[string]interface{}{
+				"GroupName":        "aws-slurm-burst-nodes",
+				"GroupDescription": "Slurm, MPI and EFA traffic for aws-slurm-burst nodes",
+				"VpcId":            ref("VpcId"),
+				"SecurityGroupIngress": []map[string]interface{}{
+					{"Description": "From the Slurm head node", "IpProtocol": "-1", "CidrIp": ref("HeadNodeCidr")},
+				},
+				"SecurityGroupEgress": []map[string]interface{}{{"IpProtocol": "-1", "CidrIp": "0.0.0.0/0"}},
+				"Tags":                managedTags,
+			},
+		},
+		// A separate rule, since a group cannot reference itself while being created
+		"BurstNodesSelfIngress": map[string]interface{}{
+			"Type": "AWS::EC2::SecurityGroupIngress",
+			"Properties": map[string]interface{}{
+				"Description":           "Between burst nodes",
+				"GroupId":               ref("BurstNodesSecurityGroup"),
+				"IpProtocol":            "-1",
+				"SourceSecurityGroupId": ref("BurstNodesSecurityGroup"),
+			},
+		},
+		"ControllerPolicy": map[string]interface{}{
+			"Type": "AWS::IAM::ManagedPolicy",
+			"Properties": map[string]interface{}{
+				"ManagedPolicyName": "aws-slurm-burst-controller",
+				"Description":       "Attach to the head node role running aws-slurm-burst",
+				"PolicyDocument":    controllerPolicy(spec, arn),
+			},
+		},
+	}
+
+	for _, profile := range spec.InstanceProfiles {
+		id := identifier(profile, true)
+		role := map[string]interface{}{"RoleName": profile, "AssumeRolePolicyDocument": assumeRolePolicy}
+		if nodes := nodePolicy(spec, arn); nodes != nil {
+			role["Policies"] = []map[string]interface{}{{"PolicyName": "aws-slurm-burst-node", "PolicyDocument": nodes}}
+		}
+		resources[id+"Role"] = map[string]interface{}{"Type": "AWS::IAM::Role", "Properties": role}
+		resources[id+"InstanceProfile"] = map[string]interface{}{
+			"Type":       "AWS::IAM::InstanceProfile",
+			"Properties": map[string]interface{}{"InstanceProfileName": profile, "Roles": []interface{}{ref(id + "Role")}},
+		}
+	}
+
+	for _, template := range spec.LaunchTemplates {
+		resources[identifier(template.Name, true)+"LaunchTemplate"] = map[string]interface{}{
+			"Type": "AWS::EC2::LaunchTemplate",
+			"Properties": map[string]interface{}{
+				"LaunchTemplateName": template.Name,
+				"LaunchTemplateData": map[string]interface{}{
+					"ImageId":            ref(identifier(amiParameter(template.Architecture), true)),
+					"IamInstanceProfile": map[string]interface{}{"Name": ref(identifier(template.InstanceProfile, true) + "InstanceProfile")},
+					"SecurityGroupIds":   []interface{}{ref("BurstNodesSecurityGroup")},
+					"MetadataOptions":    map[string]string{"HttpEndpoint": "enabled", "HttpTokens": "required"},
+				},
+				"TagSpecifications": []map[string]interface{}{{"ResourceType": "launch-template", "Tags": managedTags}},
+			},
+		}
+	}
+
+	template := map[string]interface{}{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Description":              "aws-slurm-burst prerequisites generated from " + source + "; add the outputs to the node groups' subnet_ids and security_group_ids",
+		"Parameters":               parameters,
+		"Resources":                resources,
+		"Outputs": map[string]interface{}{
+			"SubnetId":            map[string]interface{}{"Value": ref("BurstSubnet")},
+			"SecurityGroupId":     map[string]interface{}{"Value": ref("BurstNodesSecurityGroup")},
+			"ControllerPolicyArn": map[string]interface{}{"Value": ref("ControllerPolicy")},
+		},
+	}
+	return json.MarshalIndent(template, "", "  ")
+}
@@ -0,0 +1,381 @@
+// Package infra generates the AWS resources a configuration depends on, as
+// Terraform or CloudFormation: a single-AZ subnet that cluster placement
+// groups can use, a security group for Slurm and EFA traffic, the node
+// groups' IAM roles, instance profiles and launch templates, and a
+// least-privilege policy for the head node running aws-slurm-burst.
+package infra
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// managedByTag marks the instances and launch templates aws-slurm-burst owns;
+// the controller policy only stops, starts, terminates and retags those
+const (
+	managedByTag   = "ManagedBy"
+	managedByValue = "aws-slurm-burst"
+)
+
+// defaultInstanceProfile names the node role of node groups without iam_instance_profile
+const defaultInstanceProfile = "aws-slurm-burst-node"
+
+// Spec is the infrastructure a configuration needs in its region
+type Spec struct {
+	Region           string
+	InstanceProfiles []string // Sorted; one role and instance profile each
+	LaunchTemplates  []LaunchTemplate
+	Architectures    []string // Sorted; each needs an AMI parameter
+
+	MetricsBucket, MetricsPrefix string // Nodes upload metrics here; empty when node metrics stay local
+	EpilogBucket, EpilogPrefix   string // The controller exports here; empty without epilog.output
+
+	MungeKeyParameter              string // Nodes read the munge key from this SSM parameter
+	MungeKeyBucket, MungeKeyObject string // Or from this S3 object
+	MungeKeyKMSKeyID               string // Customer managed key the nodes decrypt it with
+	NodeBootstrap                  bool   // Nodes read their SlurmNode tag to start slurmd
+
+	DNSHostedZoneID string // The controller keeps node records in this hosted zone
+	SSMCommands     bool   // The controller runs health checks or the shutdown hook on nodes through SSM
+	BootDiagnostics bool   // The controller reads the console output of failed nodes
+
+	Skipped []string // Node groups left out, with the reason
+}
+
+// LaunchTemplate is a launch template created for the node groups naming it
+type LaunchTemplate struct {
+	Name            string
+	InstanceProfile string
+	Architecture    string
+}
+
+// FromConfig collects the resources cfg's node groups refer to. Node groups
+// in another region or with only a launch_template_id (an existing template)
+// are skipped and listed in Skipped.
+func FromConfig(cfg *config.Config) (*Spec, error) {
+	spec := &Spec{Region: cfg.AWS.Region}
+	profiles := make(map[string]bool)
+	architectures := make(map[string]bool)
+	templates := make(map[string]LaunchTemplate)
+
+	for _, partition := range cfg.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			label := partition.PartitionName + "/" + nodeGroup.NodeGroupName
+			if nodeGroup.Region != "" && nodeGroup.Region != spec.Region {
+				spec.Skipped = append(spec.Skipped, fmt.Sprintf("%s: launches in %s, generate from a configuration for that region", label, nodeGroup.Region))
+				continue
+			}
+
+			// Launch templates take the profile by name; ARNs end in it
+			profile := path.Base(nodeGroup.IAMInstanceProfile)
+			if nodeGroup.IAMInstanceProfile == "" {
+				profile = defaultInstanceProfile
+			}
+			profiles[profile] = true
+
+			name := nodeGroup.LaunchTemplateSpec.LaunchTemplateName
+			if name == "" {
+				spec.Skipped = append(spec.Skipped, fmt.Sprintf("%s: uses existing launch template %s", label, nodeGroup.LaunchTemplateSpec.LaunchTemplateID))
+				continue
+			}
+			template := LaunchTemplate{Name: name, InstanceProfile: profile, Architecture: nodeGroup.EffectiveArchitecture()}
+			if existing, ok := templates[name]; ok && existing != template {
+				return nil, fmt.Errorf("launch template %s is shared by node groups with different instance profiles or architectures", name)
+			}
+			templates[name] = template
+			architectures[template.Architecture] = true
+		}
+	}
+
+	spec.InstanceProfiles = sortedKeys(profiles)
+	spec.Architectures = sortedKeys(architectures)
+	for _, name := range sortedKeys(templates) {
+		spec.LaunchTemplates = append(spec.LaunchTemplates, templates[name])
+	}
+
+	var err error
+	if cfg.NodeMetrics.Enabled && aws.IsS3URL(cfg.NodeMetrics.Destination) {
+		if spec.MetricsBucket, spec.MetricsPrefix, err = aws.ParseS3URL(cfg.NodeMetrics.Destination); err != nil {
+			return nil, fmt.Errorf("node_metrics.destination: %w", err)
+		}
+	}
+	switch cfg.MungeKey.Source {
+	case config.MungeKeySourceSSM:
+		spec.MungeKeyParameter = cfg.MungeKey.Parameter
+	case config.MungeKeySourceS3:
+		if spec.MungeKeyBucket, spec.MungeKeyObject, err = aws.ParseS3URL(cfg.MungeKey.S3URL); err != nil {
+			return nil, fmt.Errorf("munge_key.s3_url: %w", err)
+		}
+	}
+	spec.MungeKeyKMSKeyID = cfg.MungeKey.KMSKeyID
+	spec.NodeBootstrap = cfg.NodeBootstrap.Enabled
+	spec.DNSHostedZoneID = cfg.DNS.HostedZoneID
+	spec.BootDiagnostics = cfg.BootDiagnostics.Enabled
+	spec.SSMCommands = cfg.HealthCheck.Enabled ||
+		(cfg.Slurm.Drain.ShutdownHook != "" && cfg.Slurm.Drain.HookTransport == config.HookTransportSSM)
+	if aws.IsS3URL(cfg.Epilog.Output) {
+		if spec.EpilogBucket, spec.EpilogPrefix, err = aws.ParseS3URL(cfg.Epilog.Output); err != nil {
+			return nil, fmt.Errorf("epilog.output: %w", err)
+		}
+	}
+	return spec, nil
+}
+
+// policy is an IAM policy document
+type policy struct {
+	Version   string      `json:"Version"`
+	Statement []statement `json:"Statement"`
+}
+
+type statement struct {
+	Sid       string        `json:"Sid"`
+	Effect    string        `json:"Effect"`
+	Action    []string      `json:"Action"`
+	Resource  []interface{} `json:"Resource"`
+	Condition condition     `json:"Condition,omitempty"`
+}
+
+// condition maps an operator to condition keys and their value or values
+type condition map[string]map[string]interface{}
+
+// arnFunc renders an ARN whose account is filled in by the template format,
+// e.g. arn:aws:iam::{account}:role/name
+type arnFunc func(pattern string) interface{}
+
+// controllerPolicy is what resume, suspend and the state manager call on EC2,
+// IAM and S3
+func controllerPolicy(spec *Spec, arn arnFunc) policy {
+	managed := condition{"StringEquals": {"aws:ResourceTag/" + managedByTag: managedByValue}}
+	inRegion := condition{"StringEquals": {"aws:RequestedRegion": spec.Region}}
+
+	var roles []interface{}
+	for _, profile := range spec.InstanceProfiles {
+		roles = append(roles, arn("arn:aws:iam::{account}:role/"+profile))
+	}
+
+	statements := []statement{
+		{
+			Sid:    "Describe",
+			Effect: "Allow",
+			Action: []string{
+				"ec2:DescribeAvailabilityZones", "ec2:DescribeImages", "ec2:DescribeInstanceTypeOfferings",
+				"ec2:DescribeInstanceTypes", "ec2:DescribeInstances", "ec2:DescribeLaunchTemplateVersions",
+				"ec2:DescribeLaunchTemplates", "ec2:DescribePlacementGroups", "ec2:DescribeRouteTables",
+				"ec2:DescribeSecurityGroupRules", "ec2:DescribeSecurityGroups", "ec2:DescribeSpotPriceHistory", "ec2:DescribeSubnets",
+				"ec2:DescribeTransitGatewayVpcAttachments", "ec2:DescribeVpcs", "ec2:DescribeVpnConnections",
+				"ec2:GetSpotPlacementScores",
+			},
+			Resource: []interface{}{"*"},
+		},
+		{
+			Sid:       "Launch",
+			Effect:    "Allow",
+			Action:    []string{"ec2:CreateFleet", "ec2:RunInstances", "ec2:CreatePlacementGroup"},
+			Resource:  []interface{}{"*"},
+			Condition: inRegion,
+		},
+		{
+			Sid:       "TagAtLaunch",
+			Effect:    "Allow",
+			Action:    []string{"ec2:CreateTags"},
+			Resource:  []interface{}{"*"},
+			Condition: condition{"StringEquals": {"ec2:CreateAction": []string{"CreateFleet", "RunInstances"}}},
+		},
+		{
+			Sid:       "ManageBurstInstances",
+			Effect:    "Allow",
+			Action:    []string{"ec2:CreateTags", "ec2:DeleteTags", "ec2:StartInstances", "ec2:StopInstances", "ec2:TerminateInstances"},
+			Resource:  []interface{}{arn("arn:aws:ec2:" + spec.Region + ":{account}:instance/*")},
+			Condition: managed,
+		},
+		{
+			Sid:       "UserDataVersions",
+			Effect:    "Allow",
+			Action:    []string{"ec2:CreateLaunchTemplateVersion", "ec2:DeleteLaunchTemplateVersions"},
+			Resource:  []interface{}{arn("arn:aws:ec2:" + spec.Region + ":{account}:launch-template/*")},
+			Condition: managed,
+		},
+		{
+			Sid:       "PassNodeRoles",
+			Effect:    "Allow",
+			Action:    []string{"iam:PassRole"},
+			Resource:  roles,
+			Condition: condition{"StringEquals": {"iam:PassedToService": "ec2.amazonaws.com"}},
+		},
+		{
+			// EC2 Fleet creates these once per account on the first spot launch
+			Sid:       "FleetServiceRoles",
+			Effect:    "Allow",
+			Action:    []string{"iam:CreateServiceLinkedRole"},
+			Resource:  []interface{}{"*"},
+			Condition: condition{"StringEquals": {"iam:AWSServiceName": []string{"spot.amazonaws.com", "ec2fleet.amazonaws.com"}}},
+		},
+	}
+
+	if spec.MetricsBucket != "" {
+		statements = append(statements,
+			statement{Sid: "ReadNodeMetrics", Effect: "Allow", Action: []string{"s3:GetObject"},
+				Resource: []interface{}{s3ObjectARN(spec.MetricsBucket, spec.MetricsPrefix)}},
+			statement{Sid: "ListNodeMetrics", Effect: "Allow", Action: []string{"s3:ListBucket"},
+				Resource: []interface{}{"arn:aws:s3:::" + spec.MetricsBucket}})
+	}
+	if spec.EpilogBucket != "" {
+		statements = append(statements, statement{Sid: "WriteExports", Effect: "Allow", Action: []string{"s3:PutObject"},
+			Resource: []interface{}{s3ObjectARN(spec.EpilogBucket, spec.EpilogPrefix)}})
+	}
+	if spec.DNSHostedZoneID != "" {
+		statements = append(statements, statement{Sid: "NodeRecords", Effect: "Allow",
+			Action:   []string{"route53:ChangeResourceRecordSets", "route53:ListResourceRecordSets"},
+			Resource: []interface{}{"arn:aws:route53:::hostedzone/" + spec.DNSHostedZoneID}})
+	}
+	if spec.SSMCommands {
+		statements = append(statements,
+			statement{Sid: "RunNodeCommands", Effect: "Allow", Action: []string{"ssm:SendCommand"},
+				Resource: []interface{}{"arn:aws:ssm:" + spec.Region + "::document/AWS-RunShellScript"}},
+			statement{Sid: "RunNodeCommandsOnBurstInstances", Effect: "Allow", Action: []string{"ssm:SendCommand"},
+				Resource: []interface{}{arn("arn:aws:ec2:" + spec.Region + ":{account}:instance/*")}, Condition: managed},
+			statement{Sid: "ReadNodeCommands", Effect: "Allow",
+				Action:   []string{"ssm:DescribeInstanceInformation", "ssm:GetCommandInvocation", "ssm:ListCommandInvocations"},
+				Resource: []interface{}{"*"}})
+	}
+	if spec.BootDiagnostics {
+		statements = append(statements, statement{Sid: "ReadConsoleOutput", Effect: "Allow", Action: []string{"ec2:GetConsoleOutput"},
+			Resource: []interface{}{arn("arn:aws:ec2:" + spec.Region + ":{account}:instance/*")}, Condition: managed})
+	}
+	return policy{Version: "2012-10-17", Statement: statements}
+}
+
+// nodePolicy is what burst nodes themselves call; nil when they need nothing
+func nodePolicy(spec *Spec, arn arnFunc) *policy {
+	var statements []statement
+	if spec.MetricsBucket != "" {
+		statements = append(statements, statement{
+			Sid: "UploadNodeMetrics", Effect: "Allow", Action: []string{"s3:PutObject"},
+			Resource: []interface{}{s3ObjectARN(spec.MetricsBucket, spec.MetricsPrefix)},
+		})
+	}
+
+	// Only the one munge key, decrypted only by the service storing it
+	viaService := ""
+	switch {
+	case spec.MungeKeyParameter != "":
+		statements = append(statements, statement{
+			Sid: "ReadMungeKey", Effect: "Allow", Action: []string{"ssm:GetParameter"},
+			Resource: []interface{}{arn("arn:aws:ssm:" + spec.Region + ":{account}:parameter" + spec.MungeKeyParameter)},
+		})
+		viaService = "ssm." + spec.Region + ".amazonaws.com"
+	case spec.MungeKeyBucket != "":
+		statements = append(statements, statement{
+			Sid: "ReadMungeKey", Effect: "Allow", Action: []string{"s3:GetObject"},
+			Resource: []interface{}{"arn:aws:s3:::" + spec.MungeKeyBucket + "/" + spec.MungeKeyObject},
+		})
+		viaService = "s3." + spec.Region + ".amazonaws.com"
+	}
+	if viaService != "" && spec.MungeKeyKMSKeyID != "" {
+		statements = append(statements, statement{
+			Sid: "DecryptMungeKey", Effect: "Allow", Action: []string{"kms:Decrypt"},
+			Resource:  []interface{}{kmsKeyARN(spec.Region, spec.MungeKeyKMSKeyID, arn)},
+			Condition: condition{"StringEquals": {"kms:ViaService": viaService}},
+		})
+	}
+
+	if spec.NodeBootstrap {
+		statements = append(statements, statement{
+			Sid: "ReadNodeName", Effect: "Allow", Action: []string{"ec2:DescribeTags"},
+			Resource: []interface{}{"*"},
+		})
+	}
+
+	// What the SSM agent needs to receive Run Command health checks and hooks
+	if spec.SSMCommands {
+		statements = append(statements, statement{
+			Sid: "SSMAgent", Effect: "Allow",
+			Action: []string{
+				"ec2messages:AcknowledgeMessage", "ec2messages:DeleteMessage", "ec2messages:FailMessage",
+				"ec2messages:GetEndpoint", "ec2messages:GetMessages", "ec2messages:SendReply",
+				"ssm:UpdateInstanceInformation", "ssmmessages:CreateControlChannel", "ssmmessages:OpenControlChannel",
+			},
+			Resource: []interface{}{"*"},
+		})
+	}
+
+	if len(statements) == 0 {
+		return nil
+	}
+	return &policy{Version: "2012-10-17", Statement: statements}
+}
+
+// kmsKeyARN is the ARN of a KMS key given by ID or ARN. Aliases cannot name
+// the key in a policy, so they allow any key the service decrypts with.
+func kmsKeyARN(region, keyID string, arn arnFunc) interface{} {
+	switch {
+	case strings.HasPrefix(keyID, "arn:"):
+		return keyID
+	case strings.HasPrefix(keyID, "alias/"):
+		return "*"
+	}
+	return arn("arn:aws:kms:" + region + ":{account}:key/" + keyID)
+}
+
+// assumeRolePolicy lets EC2 instances assume the node roles
+var assumeRolePolicy = map[string]interface{}{
+	"Version": "2012-10-17",
+	"Statement": []map[string]interface{}{{
+		"Effect":    "Allow",
+		"Principal": map[string]string{"Service": "ec2.amazonaws.com"},
+		"Action":    "sts:AssumeRole",
+	}},
+}
+
+func s3ObjectARN(bucket, prefix string) string {
+	if prefix == "" {
+		return "arn:aws:s3:::" + bucket + "/*"
+	}
+	return "arn:aws:s3:::" + bucket + "/" + prefix + "/*"
+}
+
+// amiParameter names the AMI input for an architecture
+func amiParameter(architecture string) string {
+	if architecture == types.ArchitectureARM64 {
+		return "ami_id_arm64"
+	}
+	return "ami_id"
+}
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// identifier turns a resource name into a Terraform name ("burst-nodes" ->
+// "burst_nodes") or, with camel set, a CloudFormation logical ID ("BurstNodes")
+func identifier(name string, camel bool) string {
+	parts := nonIdentifier.Split(name, -1)
+	var kept []string
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if camel {
+			part = strings.ToUpper(part[:1]) + part[1:]
+		}
+		kept = append(kept, part)
+	}
+	if camel {
+		return strings.Join(kept, "")
+	}
+	return strings.ToLower(strings.Join(kept, "_"))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,241 @@
+package infra
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+)
+
+func testConfig() *config.Config {
+	nodeGroup := func(name, template, profile, instanceType string) config.NodeGroupConfig {
+		return config.NodeGroupConfig{
+			NodeGroupName:           name,
+			Region:                  "us-west-2",
+			LaunchTemplateSpec:      config.LaunchTemplateSpec{LaunchTemplateName: template},
+			LaunchTemplateOverrides: []config.LaunchTemplateOverride{{InstanceType: instanceType}},
+			IAMInstanceProfile:      profile,
+		}
+	}
+	existing := nodeGroup("legacy", "", "", "c6i.xlarge")
+	existing.LaunchTemplateSpec.LaunchTemplateID = "lt-0123"
+	remote := nodeGroup("remote", "remote-nodes", "", "c6i.xlarge")
+	remote.Region = "eu-west-1"
+
+	cfg := &config.Config{}
+	cfg.AWS.Region = "us-west-2"
+	cfg.Slurm.Partitions = []config.PartitionConfig{{
+		PartitionName: "aws",
+		NodeGroups: []config.NodeGroupConfig{
+			nodeGroup("cpu", "burst-cpu", "", "c6i.xlarge"),
+			nodeGroup("arm", "burst-arm", "arn:aws:iam::123456789012:instance-profile/burst-arm-nodes", "c7g.xlarge"),
+			existing,
+			remote,
+		},
+	}}
+	cfg.NodeMetrics = config.NodeMetricsConfig{Enabled: true, Destination: "s3://metrics-bucket/nodes/"}
+	cfg.Epilog.Output = "s3://exports"
+	cfg.MungeKey = config.MungeKeyConfig{Source: config.MungeKeySourceSSM, Parameter: "/slurm/munge-key", KMSKeyID: "1234abcd-12ab-34cd-56ef-1234567890ab"}
+	cfg.NodeBootstrap.Enabled = true
+	return cfg
+}
+
+func TestFromConfig(t *testing.T) {
+	spec, err := FromConfig(testConfig())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"aws-slurm-burst-node", "burst-arm-nodes"}, spec.InstanceProfiles)
+	assert.Equal(t, []string{"arm64", "x86_64"}, spec.Architectures)
+	assert.Equal(t, []LaunchTemplate{
+		{Name: "burst-arm", InstanceProfile: "burst-arm-nodes", Architecture: "arm64"},
+		{Name: "burst-cpu", InstanceProfile: "aws-slurm-burst-node", Architecture: "x86_64"},
+	}, spec.LaunchTemplates)
+	assert.Equal(t, "metrics-bucket", spec.MetricsBucket)
+	assert.Equal(t, "nodes", spec.MetricsPrefix)
+	assert.Equal(t, "exports", spec.EpilogBucket)
+	require.Len(t, spec.Skipped, 2)
+	assert.Contains(t, spec.Skipped[0], "lt-0123")
+	assert.Contains(t, spec.Skipped[1], "eu-west-1")
+
+	// One launch template cannot carry two instance profiles
+	cfg := testConfig()
+	cfg.Slurm.Partitions[0].NodeGroups[1].LaunchTemplateSpec.LaunchTemplateName = "burst-cpu"
+	_, err = FromConfig(cfg)
+	assert.ErrorContains(t, err, "launch template burst-cpu is shared")
+}
+
+func TestTerraform(t *testing.T) {
+	spec, err := FromConfig(testConfig())
+	require.NoError(t, err)
+	out, err := Terraform(spec, "/etc/slurm/aws-burst.yaml")
+	require.NoError(t, err)
+
+	for _, want := range []string{
+		`region = "us-west-2"`,
+		`variable "ami_id_arm64"`,
+		`resource "aws_subnet" "burst"`,
+		`resource "aws_security_group" "burst_nodes"`,
+		`resource "aws_iam_instance_profile" "burst_arm_nodes"`,
+		`resource "aws_iam_role_policy" "aws_slurm_burst_node"`,
+		`"arn:aws:s3:::metrics-bucket/nodes/*"`,
+		`"arn:aws:s3:::exports/*"`,
+		`"arn:aws:iam::${data.aws_caller_identity.current.account_id}:role/burst-arm-nodes"`,
+		`resource "aws_launch_template" "burst_cpu"`,
+		"image_id               = var.ami_id\n",
+		"name = aws_iam_instance_profile.burst_arm_nodes.name",
+		`output "security_group_id"`,
+		`"arn:aws:ssm:us-west-2:${data.aws_caller_identity.current.account_id}:parameter/slurm/munge-key"`,
+		`"arn:aws:kms:us-west-2:${data.aws_caller_identity.current.account_id}:key/1234abcd-12ab-34cd-56ef-1234567890ab"`,
+		`"kms:ViaService": "ssm.us-west-2.amazonaws.com"`,
+		`"ec2:DescribeTags"`,
+	} {
+		assert.Contains(t, out, want)
+	}
+	assert.Equal(t, strings.Count(out, "{"), strings.Count(out, "}"), "balanced blocks")
+}
+
+func TestCloudFormation(t *testing.T) {
+	spec, err := FromConfig(testConfig())
+	require.NoError(t, err)
+	out, err := CloudFormation(spec, "/etc/slurm/aws-burst.yaml")
+	require.NoError(t, err)
+
+	var template struct {
+		Parameters map[string]json.RawMessage
+		Resources  map[string]struct {
+			Type       string
+			Properties map[string]json.RawMessage
+		}
+	}
+	require.NoError(t, json.Unmarshal(out, &template))
+
+	assert.Contains(t, template.Parameters, "AmiIdArm64")
+	types := make(map[string]string)
+	for id, resource := range template.Resources {
+		types[id] = resource.Type
+	}
+	assert.Equal(t, map[string]string{
+		"BurstSubnet":                      "AWS::EC2::Subnet",
+		"BurstNodesSecurityGroup":          "AWS::EC2::SecurityGroup",
+		"BurstNodesSelfIngress":            "AWS::EC2::SecurityGroupIngress",
+		"ControllerPolicy":                 "AWS::IAM::ManagedPolicy",
+		"AwsSlurmBurstNodeRole":            "AWS::IAM::Role",
+		"AwsSlurmBurstNodeInstanceProfile": "AWS::IAM::InstanceProfile",
+		"BurstArmNodesRole":                "AWS::IAM::Role",
+		"BurstArmNodesInstanceProfile":     "AWS::IAM::InstanceProfile",
+		"BurstCpuLaunchTemplate":           "AWS::EC2::LaunchTemplate",
+		"BurstArmLaunchTemplate":           "AWS::EC2::LaunchTemplate",
+	}, types)
+
+	policy := compact(t, template.Resources["ControllerPolicy"].Properties["PolicyDocument"])
+	assert.Contains(t, policy, `{"Fn::Sub":"arn:aws:iam::${AWS::AccountId}:role/burst-arm-nodes"}`)
+	assert.Contains(t, policy, `"aws:ResourceTag/ManagedBy":"aws-slurm-burst"`)
+	assert.Contains(t, compact(t, template.Resources["BurstArmLaunchTemplate"].Properties["LaunchTemplateData"]), `"Ref":"AmiIdArm64"`)
+}
+
+func TestNodePolicy_MungeKey(t *testing.T) {
+	arn := func(pattern string) interface{} { return pattern }
+
+	cfg := testConfig()
+	cfg.NodeMetrics.Enabled, cfg.NodeBootstrap.Enabled = false, false
+	cfg.MungeKey = config.MungeKeyConfig{Source: config.MungeKeySourceS3, S3URL: "s3://cluster-secrets/slurm/munge.key", KMSKeyID: "alias/slurm"}
+	spec, err := FromConfig(cfg)
+	require.NoError(t, err)
+	nodes := nodePolicy(spec, arn)
+	require.NotNil(t, nodes)
+	require.Len(t, nodes.Statement, 2)
+	assert.Equal(t, []string{"s3:GetObject"}, nodes.Statement[0].Action)
+	assert.Equal(t, []interface{}{"arn:aws:s3:::cluster-secrets/slurm/munge.key"}, nodes.Statement[0].Resource)
+	assert.Equal(t, []interface{}{"*"}, nodes.Statement[1].Resource, "aliases cannot name the key")
+	assert.Equal(t, "s3.us-west-2.amazonaws.com", nodes.Statement[1].Condition["StringEquals"]["kms:ViaService"])
+
+	// The AWS managed key needs no grant, and without a munge key nodes need nothing
+	cfg.MungeKey.KMSKeyID = ""
+	spec, err = FromConfig(cfg)
+	require.NoError(t, err)
+	assert.Len(t, nodePolicy(spec, arn).Statement, 1)
+	cfg.MungeKey = config.MungeKeyConfig{}
+	spec, err = FromConfig(cfg)
+	require.NoError(t, err)
+	assert.Nil(t, nodePolicy(spec, arn))
+}
+
+func TestControllerPolicy_DNS(t *testing.T) {
+	arn := func(pattern string) interface{} { return pattern }
+
+	cfg := testConfig()
+	spec, err := FromConfig(cfg)
+	require.NoError(t, err)
+	withoutDNS := controllerPolicy(spec, arn).Statement
+
+	cfg.DNS = config.DNSConfig{HostedZoneID: "Z0123456789ABC", Domain: "burst.cluster.internal"}
+	spec, err = FromConfig(cfg)
+	require.NoError(t, err)
+	statements := controllerPolicy(spec, arn).Statement
+	require.Len(t, statements, len(withoutDNS)+1)
+	records := statements[len(statements)-1]
+	assert.Equal(t, []string{"route53:ChangeResourceRecordSets", "route53:ListResourceRecordSets"}, records.Action)
+	assert.Equal(t, []interface{}{"arn:aws:route53:::hostedzone/Z0123456789ABC"}, records.Resource)
+}
+
+func TestControllerPolicy_BootDiagnostics(t *testing.T) {
+	arn := func(pattern string) interface{} { return pattern }
+
+	cfg := testConfig()
+	cfg.BootDiagnostics = config.BootDiagnosticsConfig{Enabled: true}
+	spec, err := FromConfig(cfg)
+	require.NoError(t, err)
+	statements := controllerPolicy(spec, arn).Statement
+	console := statements[len(statements)-1]
+	assert.Equal(t, "ReadConsoleOutput", console.Sid)
+	assert.Equal(t, []string{"ec2:GetConsoleOutput"}, console.Action)
+	assert.Equal(t, []interface{}{"arn:aws:ec2:us-west-2:{account}:instance/*"}, console.Resource)
+	assert.Equal(t, managedByValue, console.Condition["StringEquals"]["aws:ResourceTag/"+managedByTag])
+}
+
+func TestPolicies_HealthCheck(t *testing.T) {
+	arn := func(pattern string) interface{} { return pattern }
+
+	cfg := testConfig()
+	cfg.NodeMetrics.Enabled, cfg.NodeBootstrap.Enabled = false, false
+	cfg.MungeKey = config.MungeKeyConfig{}
+	cfg.HealthCheck = config.HealthCheckConfig{Enabled: true}
+	spec, err := FromConfig(cfg)
+	require.NoError(t, err)
+
+	statements := controllerPolicy(spec, arn).Statement
+	sids := make(map[string]statement)
+	for _, statement := range statements {
+		sids[statement.Sid] = statement
+	}
+	assert.Equal(t, []interface{}{"arn:aws:ssm:us-west-2::document/AWS-RunShellScript"}, sids["RunNodeCommands"].Resource)
+	assert.Equal(t, managedByValue, sids["RunNodeCommandsOnBurstInstances"].Condition["StringEquals"]["aws:ResourceTag/"+managedByTag])
+	assert.Equal(t, []string{"ssm:DescribeInstanceInformation", "ssm:GetCommandInvocation", "ssm:ListCommandInvocations"}, sids["ReadNodeCommands"].Action)
+
+	nodes := nodePolicy(spec, arn)
+	require.NotNil(t, nodes)
+	require.Len(t, nodes.Statement, 1)
+	assert.Contains(t, nodes.Statement[0].Action, "ssm:UpdateInstanceInformation")
+
+	// A shutdown hook run through SSM needs the same permissions
+	cfg.HealthCheck.Enabled = false
+	cfg.Slurm.Drain = config.DrainConfig{ShutdownHook: "/usr/local/sbin/flush-scratch", HookTransport: config.HookTransportSSM}
+	spec, err = FromConfig(cfg)
+	require.NoError(t, err)
+	assert.True(t, spec.SSMCommands)
+	cfg.Slurm.Drain.HookTransport = config.HookTransportSSH
+	spec, err = FromConfig(cfg)
+	require.NoError(t, err)
+	assert.False(t, spec.SSMCommands)
+}
+
+func compact(t *testing.T, raw json.RawMessage) string {
+	var buf bytes.Buffer
+	require.NoError(t, json.Compact(&buf, raw))
+	return buf.String()
+}
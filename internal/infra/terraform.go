@@ -0,0 +1,144 @@
+package infra
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Terraform renders spec as a Terraform configuration for the AWS provider.
+// source names the configuration it was generated from.
+func Terraform(spec *Spec, source string) (string, error) {
+	var b strings.Builder
+	q := strconv.Quote
+
+	fmt.Fprintf(&b, "# Generated by aws-slurm-burst-generate infra from %s.\n", source)
+	fmt.Fprintf(&b, "# Review before applying; add the outputs to the node groups' subnet_ids and security_group_ids.\n\n")
+	b.WriteString("terraform {\n  required_providers {\n    aws = {\n      source = \"hashicorp/aws\"\n    }\n  }\n}\n\n")
+	fmt.Fprintf(&b, "provider \"aws\" {\n  region = %s\n}\n\n", q(spec.Region))
+	b.WriteString("data \"aws_caller_identity\" \"current\" {}\n\n")
+
+	variable := func(name, description string) {
+		fmt.Fprintf(&b, "variable %s {\n  type        = string\n  description = %s\n}\n\n", q(name), q(description))
+	}
+	variable("vpc_id", "VPC the burst nodes launch in")
+	variable("availability_zone", "Availability zone of the burst subnet; cluster placement groups need a single zone")
+	variable("subnet_cidr", "CIDR block of the burst subnet")
+	variable("head_node_cidr", "CIDR block of the Slurm head node, allowed to reach slurmd and MPI ports")
+	for _, architecture := range spec.Architectures {
+		variable(amiParameter(architecture), "AMI of the "+architecture+" burst nodes, with slurmd installed")
+	}
+
+	fmt.Fprintf(&b, `resource "aws_subnet" "burst" {
+  vpc_id            = var.vpc_id
+  cidr_block        = var.subnet_cidr
+  availability_zone = var.availability_zone
+  tags = {
+    Name      = "aws-slurm-burst"
+    %s = %s
+  }
+}
+
+`, managedByTag, q(managedByValue))
+
+	// Slurm, MPI and EFA need all traffic between the nodes; EFA also needs
+	// the group to allow its own egress, which the all-egress rule covers
+	fmt.Fprintf(&b, `resource "aws_security_group" "burst_nodes" {
+  name        = "aws-slurm-burst-nodes"
+  description = "Slurm, MPI and EFA traffic for aws-slurm-burst nodes"
+  vpc_id      = var.vpc_id
+
+  ingress {
+    description = "Between burst nodes"
+    from_port   = 0
+    to_port     = 0
+    protocol    = "-1"
+    self        = true
+  }
+
+  ingress {
+    description = "From the Slurm head node"
+    from_port   = 0
+    to_port     = 0
+    protocol    = "-1"
+    cidr_blocks = [var.head_node_cidr]
+  }
+
+  egress {
+    from_port   = 0
+    to_port     = 0
+    protocol    = "-1"
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+
+  tags = {
+    %s = %s
+  }
+}
+
+`, managedByTag, q(managedByValue))
+
+	assumeRole, err := json.MarshalIndent(assumeRolePolicy, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	arn := func(pattern string) interface{} {
+		return strings.ReplaceAll(pattern, "{account}", "${data.aws_caller_identity.current.account_id}")
+	}
+	for _, profile := range spec.InstanceProfiles {
+		id := identifier(profile, false)
+		fmt.Fprintf(&b, "resource \"aws_iam_role\" %s {\n  name               = %s\n  assume_role_policy = <<-EOT\n%s\n  EOT\n}\n\n",
+			q(id), q(profile), indent(string(assumeRole), "    "))
+		fmt.Fprintf(&b, "resource \"aws_iam_instance_profile\" %s {\n  name = %s\n  role = aws_iam_role.%s.name\n}\n\n", q(id), q(profile), id)
+		if nodes := nodePolicy(spec, arn); nodes != nil {
+			document, err := json.MarshalIndent(nodes, "", "  ")
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&b, "resource \"aws_iam_role_policy\" %s {\n  name   = \"aws-slurm-burst-node\"\n  role   = aws_iam_role.%s.id\n  policy = <<-EOT\n%s\n  EOT\n}\n\n",
+				q(id), id, indent(string(document), "    "))
+		}
+	}
+
+	controller, err := json.MarshalIndent(controllerPolicy(spec, arn), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&b, "resource \"aws_iam_policy\" \"controller\" {\n  name        = \"aws-slurm-burst-controller\"\n  description = \"Attach to the head node role running aws-slurm-burst\"\n  policy      = <<-EOT\n%s\n  EOT\n}\n\n",
+		indent(string(controller), "    "))
+
+	for _, template := range spec.LaunchTemplates {
+		fmt.Fprintf(&b, `resource "aws_launch_template" %s {
+  name                   = %s
+  image_id               = var.%s
+  vpc_security_group_ids = [aws_security_group.burst_nodes.id]
+
+  iam_instance_profile {
+    name = aws_iam_instance_profile.%s.name
+  }
+
+  metadata_options {
+    http_endpoint = "enabled"
+    http_tokens   = "required"
+  }
+
+  tags = {
+    %s = %s
+  }
+}
+
+`, q(identifier(template.Name, false)), q(template.Name), amiParameter(template.Architecture),
+			identifier(template.InstanceProfile, false), managedByTag, q(managedByValue))
+	}
+
+	b.WriteString("output \"subnet_id\" {\n  value = aws_subnet.burst.id\n}\n\n")
+	b.WriteString("output \"security_group_id\" {\n  value = aws_security_group.burst_nodes.id\n}\n\n")
+	b.WriteString("output \"controller_policy_arn\" {\n  value = aws_iam_policy.controller.arn\n}\n")
+	return b.String(), nil
+}
+
+// indent prefixes every line of text
+func indent(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
@@ -0,0 +1,129 @@
+package learning
+
+import (
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// ColumnType is the logical type of a flat export column
+type ColumnType int
+
+// Column types and their Parquet representation
+const (
+	ColumnString    ColumnType = iota // BYTE_ARRAY (UTF8)
+	ColumnInt64                       // INT64
+	ColumnDouble                      // DOUBLE
+	ColumnBool                        // BOOLEAN
+	ColumnTimestamp                   // INT64 (TIMESTAMP_MILLIS, UTC)
+)
+
+// Column is one field of the flat performance export schema. Value returns nil
+// when the field does not apply to the job; nullable columns are OPTIONAL in Parquet
+// and empty in CSV.
+type Column struct {
+	Name     string
+	Type     ColumnType
+	Nullable bool
+	Value    func(perf *types.PerformanceFeedback) interface{}
+}
+
+// Columns is the stable flat schema shared by the CSV and Parquet exporters.
+// Columns are only ever appended so existing spreadsheets and Athena tables keep
+// working; see docs/PHASE3-LEARNING.md for descriptions.
+var Columns = []Column{
+	{Name: "job_id", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} { return p.JobMetadata.JobID }},
+	{Name: "job_name", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} { return p.JobMetadata.JobName }},
+	{Name: "user_id", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} { return p.JobMetadata.UserID }},
+	{Name: "project_id", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} { return p.JobMetadata.ProjectID }},
+	{Name: "partition", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} { return p.JobMetadata.Partition }},
+	{Name: "domain", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} { return domain(*p) }},
+	{Name: "execution_mode", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} { return p.ExecutionContext.ExecutionMode }},
+	{Name: "aws_region", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} { return p.ExecutionContext.AWSRegion }},
+	{Name: "plugin_version", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} { return p.ExecutionContext.PluginVersion }},
+	{Name: "instance_types", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} {
+		return strings.Join(p.JobMetadata.ActualExecution.InstanceTypesUsed, ";")
+	}},
+	{Name: "node_count", Type: ColumnInt64, Value: func(p *types.PerformanceFeedback) interface{} {
+		return int64(p.JobMetadata.ActualExecution.NodeCount)
+	}},
+	{Name: "start_time", Type: ColumnTimestamp, Nullable: true, Value: func(p *types.PerformanceFeedback) interface{} {
+		return optionalTime(p.JobMetadata.ActualExecution.StartTime)
+	}},
+	{Name: "end_time", Type: ColumnTimestamp, Nullable: true, Value: func(p *types.PerformanceFeedback) interface{} {
+		return optionalTime(p.JobMetadata.ActualExecution.EndTime)
+	}},
+	{Name: "duration_seconds", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} {
+		return time.Duration(p.JobMetadata.ActualExecution.ExecutionDuration).Seconds()
+	}},
+	{Name: "success", Type: ColumnBool, Value: func(p *types.PerformanceFeedback) interface{} { return p.JobMetadata.ActualExecution.Success }},
+	{Name: "error_details", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} { return p.JobMetadata.ActualExecution.ErrorDetails }},
+	{Name: "cost_accuracy", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return p.PredictionValidation.CostAccuracy }},
+	{Name: "runtime_accuracy", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return p.PredictionValidation.RuntimeAccuracy }},
+	{Name: "instance_type_optimal", Type: ColumnBool, Value: func(p *types.PerformanceFeedback) interface{} {
+		return p.PredictionValidation.InstanceTypeOptimal
+	}},
+	{Name: "domain_detection_correct", Type: ColumnBool, Value: func(p *types.PerformanceFeedback) interface{} {
+		return p.PredictionValidation.DomainDetectionCorrect
+	}},
+	{Name: "overall_accuracy_score", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} {
+		return p.PredictionValidation.OverallAccuracyScore
+	}},
+	{Name: "efa_utilization", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return p.AWSPerformanceMetrics.EFAUtilization }},
+	{Name: "placement_group_effectiveness", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} {
+		return p.AWSPerformanceMetrics.PlacementGroupEffectiveness
+	}},
+	{Name: "spot_interruptions", Type: ColumnInt64, Value: func(p *types.PerformanceFeedback) interface{} {
+		return int64(p.AWSPerformanceMetrics.SpotInterruptions)
+	}},
+	{Name: "network_throughput_gbps", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} {
+		return p.AWSPerformanceMetrics.NetworkThroughputGbps
+	}},
+	{Name: "cpu_utilization", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return p.AWSPerformanceMetrics.CPUUtilization }},
+	{Name: "memory_utilization", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return p.AWSPerformanceMetrics.MemoryUtilization }},
+	{Name: "provisioning_seconds", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} {
+		return time.Duration(p.AWSPerformanceMetrics.ProvisioningTime).Seconds()
+	}},
+	{Name: "availability_zones", Type: ColumnString, Value: func(p *types.PerformanceFeedback) interface{} {
+		return strings.Join(p.AWSPerformanceMetrics.AvailabilityZones, ";")
+	}},
+	{Name: "compute_cost_usd", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return p.CostAnalysis.ComputeCostUSD }},
+	{Name: "storage_cost_usd", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return p.CostAnalysis.StorageCostUSD }},
+	{Name: "network_cost_usd", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return p.CostAnalysis.NetworkCostUSD }},
+	{Name: "total_cost_usd", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return totalCost(*p) }},
+	{Name: "spot_savings_usd", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return p.CostAnalysis.SpotSavingsUSD }},
+	{Name: "cost_per_cpu_hour", Type: ColumnDouble, Value: func(p *types.PerformanceFeedback) interface{} { return p.CostAnalysis.CostPerCPUHour }},
+	{Name: "mpi_scaling_efficiency", Type: ColumnDouble, Nullable: true, Value: func(p *types.PerformanceFeedback) interface{} {
+		if p.MPIOptimizationResults == nil {
+			return nil
+		}
+		return p.MPIOptimizationResults.ScalingEfficiency
+	}},
+	{Name: "mpi_communication_overhead", Type: ColumnDouble, Nullable: true, Value: func(p *types.PerformanceFeedback) interface{} {
+		if p.MPIOptimizationResults == nil {
+			return nil
+		}
+		return p.MPIOptimizationResults.CommunicationOverhead
+	}},
+	{Name: "measured_nodes", Type: ColumnInt64, Value: func(p *types.PerformanceFeedback) interface{} {
+		return int64(p.AWSPerformanceMetrics.MeasuredNodes)
+	}},
+	{Name: "mpi_profiled_runs", Type: ColumnInt64, Nullable: true, Value: func(p *types.PerformanceFeedback) interface{} {
+		if p.MPIOptimizationResults == nil {
+			return nil
+		}
+		return int64(p.MPIOptimizationResults.ProfiledRuns)
+	}},
+	{Name: "prediction_validated", Type: ColumnBool, Value: func(p *types.PerformanceFeedback) interface{} {
+		return p.PredictionValidation.Validated
+	}},
+}
+
+// optionalTime maps the zero time to a null value
+func optionalTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,370 @@
+package learning
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// WriteCSV writes one row per job using the Columns schema, with a header row
+func WriteCSV(w io.Writer, feedback []types.PerformanceFeedback) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(Columns))
+	for i, column := range Columns {
+		header[i] = column.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	row := make([]string, len(Columns))
+	for i := range feedback {
+		for j, column := range Columns {
+			row[j] = formatCSV(column.Value(&feedback[i]))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatCSV(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Parquet physical types, repetitions, converted types and encodings used by the writer
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// parquetMagic opens and closes every Parquet file
+const parquetMagic = "PAR1"
+
+// WriteParquet writes the jobs as a single row group using the Columns schema.
+// Pages are PLAIN encoded and uncompressed, which Athena and Glue read directly.
+func WriteParquet(w io.Writer, feedback []types.PerformanceFeedback) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(Columns))
+
+	for i, column := range Columns {
+		values := make([]interface{}, len(feedback))
+		for j := range feedback {
+			values[j] = column.Value(&feedback[j])
+		}
+
+		page, err := encodePage(column, values)
+		if err != nil {
+			return fmt.Errorf("failed to encode column %s: %w", column.Name, err)
+		}
+
+		header := newThriftWriter()
+		header.i32Field(1, 0) // DATA_PAGE
+		header.i32Field(2, int32(len(page)))
+		header.i32Field(3, int32(len(page)))
+		header.structBegin(5)
+		header.i32Field(1, int32(len(values)))
+		header.i32Field(2, parquetPlain)
+		header.i32Field(3, parquetRLE)
+		header.i32Field(4, parquetRLE)
+		header.structEnd()
+		header.stop()
+
+		chunks[i] = chunk{offset: int64(file.Len()), size: int64(header.buf.Len() + len(page))}
+		file.Write(header.buf.Bytes())
+		file.Write(page)
+	}
+
+	var totalSize int64
+	for _, c := range chunks {
+		totalSize += c.size
+	}
+
+	meta := newThriftWriter()
+	meta.i32Field(1, 1)
+	meta.listBegin(2, thriftStruct, len(Columns)+1)
+	meta.elementBegin()
+	meta.stringField(4, "schema")
+	meta.i32Field(5, int32(len(Columns)))
+	meta.elementEnd()
+	for _, column := range Columns {
+		physical, converted := parquetType(column.Type)
+		meta.elementBegin()
+		meta.i32Field(1, physical)
+		if column.Nullable {
+			meta.i32Field(3, parquetOptional)
+		} else {
+			meta.i32Field(3, parquetRequired)
+		}
+		meta.stringField(4, column.Name)
+		if converted >= 0 {
+			meta.i32Field(6, converted)
+		}
+		meta.elementEnd()
+	}
+	meta.i64Field(3, int64(len(feedback)))
+	meta.listBegin(4, thriftStruct, 1)
+	meta.elementBegin()
+	meta.listBegin(1, thriftStruct, len(Columns))
+	for i, column := range Columns {
+		physical, _ := parquetType(column.Type)
+		meta.elementBegin()
+		meta.i64Field(2, chunks[i].offset)
+		meta.structBegin(3)
+		meta.i32Field(1, physical)
+		meta.listBegin(2, thriftI32, 2)
+		meta.i32Value(parquetPlain)
+		meta.i32Value(parquetRLE)
+		meta.listBegin(3, thriftBinary, 1)
+		meta.binaryValue(column.Name)
+		meta.i32Field(4, 0) // UNCOMPRESSED
+		meta.i64Field(5, int64(len(feedback)))
+		meta.i64Field(6, chunks[i].size)
+		meta.i64Field(7, chunks[i].size)
+		meta.i64Field(9, chunks[i].offset)
+		meta.structEnd()
+		meta.elementEnd()
+	}
+	meta.i64Field(2, totalSize)
+	meta.i64Field(3, int64(len(feedback)))
+	meta.elementEnd()
+	meta.stringField(6, "aws-slurm-burst")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	if err := binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len())); err != nil {
+		return err
+	}
+	file.WriteString(parquetMagic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// parquetType returns the physical and converted type of a column; -1 means no converted type
+func parquetType(columnType ColumnType) (int32, int32) {
+	switch columnType {
+	case ColumnString:
+		return parquetByteArray, parquetUTF8
+	case ColumnInt64:
+		return parquetInt64, -1
+	case ColumnDouble:
+		return parquetDouble, -1
+	case ColumnBool:
+		return parquetBoolean, -1
+	default:
+		return parquetInt64, parquetTimestampMillis
+	}
+}
+
+// encodePage encodes definition levels (for nullable columns) and PLAIN values
+func encodePage(column Column, values []interface{}) ([]byte, error) {
+	var page bytes.Buffer
+
+	if column.Nullable {
+		levels := encodeDefinitionLevels(values)
+		if err := binary.Write(&page, binary.LittleEndian, uint32(len(levels))); err != nil {
+			return nil, err
+		}
+		page.Write(levels)
+	}
+
+	var bits []bool
+	for _, value := range values {
+		if value == nil {
+			if !column.Nullable {
+				return nil, fmt.Errorf("null value in required column")
+			}
+			continue
+		}
+
+		var err error
+		switch v := value.(type) {
+		case string:
+			err = binary.Write(&page, binary.LittleEndian, uint32(len(v)))
+			page.WriteString(v)
+		case int64:
+			err = binary.Write(&page, binary.LittleEndian, v)
+		case float64:
+			err = binary.Write(&page, binary.LittleEndian, math.Float64bits(v))
+		case time.Time:
+			err = binary.Write(&page, binary.LittleEndian, v.UnixMilli())
+		case bool:
+			bits = append(bits, v)
+		default:
+			err = fmt.Errorf("unsupported value type %T", value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Booleans are bit-packed, least significant bit first
+	if column.Type == ColumnBool {
+		packed := make([]byte, (len(bits)+7)/8)
+		for i, bit := range bits {
+			if bit {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		page.Write(packed)
+	}
+
+	return page.Bytes(), nil
+}
+
+// encodeDefinitionLevels run-length encodes 1 (present) and 0 (null) levels with a bit width of 1
+func encodeDefinitionLevels(values []interface{}) []byte {
+	var levels []byte
+	for i := 0; i < len(values); {
+		present := values[i] != nil
+		run := 1
+		for i+run < len(values) && (values[i+run] != nil) == present {
+			run++
+		}
+		levels = binary.AppendUvarint(levels, uint64(run)<<1)
+		if present {
+			levels = append(levels, 1)
+		} else {
+			levels = append(levels, 0)
+		}
+		i += run
+	}
+	return levels
+}
+
+// Thrift compact protocol field types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Thrift compact protocol structures of the Parquet footer
+// and page headers
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // Last field id of each open struct
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func (t *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	top := len(t.last) - 1
+	if delta := id - t.last[top]; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		t.varint(int64(id))
+	}
+	t.last[top] = id
+}
+
+// varint writes a zigzag encoded varint
+func (t *thriftWriter) varint(v int64) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64((v<<1)^(v>>63))))
+}
+
+func (t *thriftWriter) i32Field(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64Field(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) stringField(id int16, v string) {
+	t.fieldHeader(id, thriftBinary)
+	t.binaryValue(v)
+}
+
+func (t *thriftWriter) i32Value(v int32) {
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) binaryValue(v string) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(v))))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) listBegin(id int16, elementType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		t.buf.WriteByte(0xf0 | elementType)
+		t.buf.Write(binary.AppendUvarint(nil, uint64(size)))
+	}
+}
+
+// structBegin opens a struct-valued field
+func (t *thriftWriter) structBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) structEnd() {
+	t.stop()
+	t.last = t.last[:len(t.last)-1]
+}
+
+// elementBegin opens a struct element of a list
+func (t *thriftWriter) elementBegin() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) elementEnd() {
+	t.structEnd()
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
@@ -0,0 +1,108 @@
+package learning
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func columnIndex(t *testing.T, name string) int {
+	for i, column := range Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+	t.Fatalf("column %s not in schema", name)
+	return -1
+}
+
+func TestColumnsUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, column := range Columns {
+		assert.False(t, seen[column.Name], "duplicate column %s", column.Name)
+		seen[column.Name] = true
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	end := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	mpi := feedback("7", "climate_modeling", end, 0.9, 12.5, 1, "hpc7a.2xlarge", "c6i.xlarge")
+	mpi.MPIOptimizationResults = &types.MPIOptimizationResults{ScalingEfficiency: 0.87}
+	serial := feedback("8", "", time.Time{}, 0.5, 3, 0, "c6i.xlarge")
+
+	var out bytes.Buffer
+	require.NoError(t, WriteCSV(&out, []types.PerformanceFeedback{mpi, serial}))
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "job_id", rows[0][0])
+	assert.Len(t, rows[1], len(Columns))
+
+	assert.Equal(t, "7", rows[1][columnIndex(t, "job_id")])
+	assert.Equal(t, "climate_modeling", rows[1][columnIndex(t, "domain")])
+	assert.Equal(t, "hpc7a.2xlarge;c6i.xlarge", rows[1][columnIndex(t, "instance_types")])
+	assert.Equal(t, "2026-01-31T12:00:00Z", rows[1][columnIndex(t, "end_time")])
+	assert.Equal(t, "7200", rows[1][columnIndex(t, "duration_seconds")])
+	assert.Equal(t, "true", rows[1][columnIndex(t, "success")])
+	assert.Equal(t, "12.5", rows[1][columnIndex(t, "total_cost_usd")])
+	assert.Equal(t, "0.87", rows[1][columnIndex(t, "mpi_scaling_efficiency")])
+
+	assert.Equal(t, UnclassifiedDomain, rows[2][columnIndex(t, "domain")])
+	assert.Empty(t, rows[2][columnIndex(t, "end_time")])
+	assert.Empty(t, rows[2][columnIndex(t, "mpi_scaling_efficiency")])
+}
+
+func TestWriteParquet(t *testing.T) {
+	jobs := []types.PerformanceFeedback{
+		feedback("7", "climate_modeling", time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC), 0.9, 12.5, 1, "hpc7a.2xlarge"),
+		feedback("8", "", time.Time{}, 0.5, 3, 0, "c6i.xlarge"),
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, WriteParquet(&out, jobs))
+	data := out.Bytes()
+
+	require.Greater(t, len(data), 12)
+	assert.Equal(t, parquetMagic, string(data[:4]))
+	assert.Equal(t, parquetMagic, string(data[len(data)-4:]))
+
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8 : len(data)-4]))
+	require.Less(t, footerLength, len(data)-12)
+	footer := data[len(data)-8-footerLength : len(data)-8]
+	for _, column := range Columns {
+		assert.Contains(t, string(footer), column.Name)
+	}
+	assert.Contains(t, string(data), "hpc7a.2xlarge")
+}
+
+func TestEncodeDefinitionLevels(t *testing.T) {
+	levels := encodeDefinitionLevels([]interface{}{1.0, 2.0, nil, 3.0})
+	// Runs of 2 present, 1 null, 1 present: varint(run<<1) followed by the level
+	assert.Equal(t, []byte{4, 1, 2, 0, 2, 1}, levels)
+}
+
+func TestThriftWriter(t *testing.T) {
+	writer := newThriftWriter()
+	writer.i32Field(1, 1)
+	writer.i64Field(3, -2)
+	writer.structBegin(20)
+	writer.stringField(1, "ab")
+	writer.structEnd()
+	writer.stop()
+
+	assert.Equal(t, []byte{
+		0x15, 0x02, // field 1 i32 = 1
+		0x26, 0x03, // field 3 i64 = -2
+		0x0c, 0x28, // field 20 struct (long form)
+		0x18, 0x02, 'a', 'b', // field 1 binary "ab"
+		0x00, // struct stop
+		0x00, // stop
+	}, writer.buf.Bytes())
+}
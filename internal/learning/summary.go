@@ -0,0 +1,314 @@
+// Package learning aggregates exported job performance data into the
+// LearningDataSummary used for institutional reporting.
+package learning
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// performancePattern matches the files written by export-performance --format=asba-learning
+const performancePattern = "job-*-performance.json"
+
+// UnclassifiedDomain groups jobs whose execution plan did not name a domain
+const UnclassifiedDomain = "unclassified"
+
+// domainTag is the execution plan tag ASBA uses to record the detected research domain
+const domainTag = "domain"
+
+// LoadFeedback reads every performance file in dir. Unreadable or malformed files
+// are returned in skipped rather than failing the summary.
+func LoadFeedback(dir string) (feedback []types.PerformanceFeedback, skipped []string, err error) {
+	files, err := filepath.Glob(filepath.Join(dir, performancePattern))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list performance files: %w", err)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			skipped = append(skipped, file)
+			continue
+		}
+
+		var perf types.PerformanceFeedback
+		if err := json.Unmarshal(data, &perf); err != nil {
+			skipped = append(skipped, file)
+			continue
+		}
+		feedback = append(feedback, perf)
+	}
+
+	return feedback, skipped, nil
+}
+
+// Summarize aggregates the jobs that ended in [since, until). Zero bounds are open
+// and are replaced in the summary by the earliest and latest job end times.
+func Summarize(feedback []types.PerformanceFeedback, since, until time.Time) types.LearningDataSummary {
+	var jobs []types.PerformanceFeedback
+	for _, perf := range feedback {
+		end := perf.JobMetadata.ActualExecution.EndTime
+		if !since.IsZero() && end.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !end.Before(until) {
+			continue
+		}
+		jobs = append(jobs, perf)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].JobMetadata.ActualExecution.EndTime.Before(jobs[j].JobMetadata.ActualExecution.EndTime)
+	})
+
+	summary := types.LearningDataSummary{
+		TimeRange:              types.DateRange{StartTime: since, EndTime: until},
+		JobCount:               len(jobs),
+		DomainBreakdown:        []types.DomainPerformance{},
+		InstanceTypeEfficiency: []types.InstanceTypeAnalysis{},
+	}
+	if len(jobs) == 0 {
+		return summary
+	}
+	if since.IsZero() {
+		summary.TimeRange.StartTime = jobs[0].JobMetadata.ActualExecution.EndTime
+	}
+	if until.IsZero() {
+		summary.TimeRange.EndTime = jobs[len(jobs)-1].JobMetadata.ActualExecution.EndTime
+	}
+
+	for _, perf := range jobs {
+		summary.TotalCostUSD += totalCost(perf)
+	}
+	summary.AverageAccuracy = averageAccuracy(jobs)
+	summary.DomainBreakdown = domainBreakdown(jobs)
+	summary.InstanceTypeEfficiency = instanceTypeEfficiency(jobs)
+	summary.ImprovementMetrics = improvement(jobs)
+
+	return summary
+}
+
+// totalCost prefers the cost analysis total and falls back to the cost recorded at launch
+func totalCost(perf types.PerformanceFeedback) float64 {
+	if perf.CostAnalysis.TotalCostUSD > 0 {
+		return perf.CostAnalysis.TotalCostUSD
+	}
+	return perf.JobMetadata.ActualExecution.ActualCostUSD
+}
+
+// costSavings is the fraction of on-demand cost avoided through spot pricing
+func costSavings(perf types.PerformanceFeedback) float64 {
+	onDemand := totalCost(perf) + perf.CostAnalysis.SpotSavingsUSD
+	if onDemand <= 0 {
+		return 0
+	}
+	return perf.CostAnalysis.SpotSavingsUSD / onDemand
+}
+
+// performanceGain is the fraction of the predicted on-premise queue wait avoided
+// by bursting. It is only known for jobs launched from an ASBA plan.
+func performanceGain(perf types.PerformanceFeedback) (float64, bool) {
+	plan := perf.JobMetadata.OriginalASBAPrediction
+	if plan == nil || plan.ExecutionMetadata.ExpectedPerformance.OnPremiseWaitTime <= 0 {
+		return 0, false
+	}
+	wait := time.Duration(plan.ExecutionMetadata.ExpectedPerformance.OnPremiseWaitTime)
+	provisioning := time.Duration(perf.AWSPerformanceMetrics.ProvisioningTime)
+	return float64(wait-provisioning) / float64(wait), true
+}
+
+// domain returns the research domain ASBA detected for the job
+func domain(perf types.PerformanceFeedback) string {
+	if plan := perf.JobMetadata.OriginalASBAPrediction; plan != nil {
+		if value := plan.ExecutionMetadata.Tags[domainTag]; value != "" {
+			return value
+		}
+	}
+	return UnclassifiedDomain
+}
+
+// averageAccuracy averages the scores and reports the boolean checks as true when
+// they held for most jobs
+func averageAccuracy(jobs []types.PerformanceFeedback) types.PredictionValidation {
+	var average types.PredictionValidation
+	optimal, detected := 0, 0
+	for _, perf := range jobs {
+		validation := perf.PredictionValidation
+		average.CostAccuracy += validation.CostAccuracy
+		average.RuntimeAccuracy += validation.RuntimeAccuracy
+		average.OverallAccuracyScore += validation.OverallAccuracyScore
+		if validation.InstanceTypeOptimal {
+			optimal++
+		}
+		if validation.DomainDetectionCorrect {
+			detected++
+		}
+	}
+
+	count := float64(len(jobs))
+	average.CostAccuracy /= count
+	average.RuntimeAccuracy /= count
+	average.OverallAccuracyScore /= count
+	average.InstanceTypeOptimal = 2*optimal > len(jobs)
+	average.DomainDetectionCorrect = 2*detected > len(jobs)
+	return average
+}
+
+// domainBreakdown groups jobs by research domain, largest first
+func domainBreakdown(jobs []types.PerformanceFeedback) []types.DomainPerformance {
+	type totals struct {
+		jobs, gains             int
+		accuracy, savings, gain float64
+	}
+	byDomain := make(map[string]*totals)
+	for _, perf := range jobs {
+		name := domain(perf)
+		t, ok := byDomain[name]
+		if !ok {
+			t = &totals{}
+			byDomain[name] = t
+		}
+		t.jobs++
+		t.accuracy += perf.PredictionValidation.OverallAccuracyScore
+		t.savings += costSavings(perf)
+		if gain, ok := performanceGain(perf); ok {
+			t.gains++
+			t.gain += gain
+		}
+	}
+
+	breakdown := make([]types.DomainPerformance, 0, len(byDomain))
+	for name, t := range byDomain {
+		entry := types.DomainPerformance{
+			Domain:             name,
+			JobCount:           t.jobs,
+			AverageAccuracy:    t.accuracy / float64(t.jobs),
+			AverageCostSavings: t.savings / float64(t.jobs),
+		}
+		if t.gains > 0 {
+			entry.AveragePerformanceGain = t.gain / float64(t.gains)
+		}
+		breakdown = append(breakdown, entry)
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].JobCount != breakdown[j].JobCount {
+			return breakdown[i].JobCount > breakdown[j].JobCount
+		}
+		return breakdown[i].Domain < breakdown[j].Domain
+	})
+	return breakdown
+}
+
+// instanceTypeEfficiency reports utilization and utilized CPU hours per dollar for
+// each instance type, most used first
+func instanceTypeEfficiency(jobs []types.PerformanceFeedback) []types.InstanceTypeAnalysis {
+	type totals struct {
+		uses, optimal               int
+		utilization, usedHours, usd float64
+	}
+	byType := make(map[string]*totals)
+	for _, perf := range jobs {
+		used := perf.JobMetadata.ActualExecution.InstanceTypesUsed
+		hours := time.Duration(perf.JobMetadata.ActualExecution.ExecutionDuration).Hours()
+		for _, instanceType := range used {
+			t, ok := byType[instanceType]
+			if !ok {
+				t = &totals{}
+				byType[instanceType] = t
+			}
+			t.uses++
+			t.utilization += perf.AWSPerformanceMetrics.CPUUtilization
+			// Cost and runtime are per job, so share them across the types it used
+			t.usedHours += perf.AWSPerformanceMetrics.CPUUtilization * hours / float64(len(used))
+			t.usd += totalCost(perf) / float64(len(used))
+			if perf.PredictionValidation.InstanceTypeOptimal {
+				t.optimal++
+			}
+		}
+	}
+
+	analysis := make([]types.InstanceTypeAnalysis, 0, len(byType))
+	for instanceType, t := range byType {
+		entry := types.InstanceTypeAnalysis{
+			InstanceType:        instanceType,
+			UsageCount:          t.uses,
+			AverageUtilization:  t.utilization / float64(t.uses),
+			RecommendationScore: float64(t.optimal) / float64(t.uses),
+		}
+		if t.usd > 0 {
+			entry.CostEfficiency = t.usedHours / t.usd
+		}
+		analysis = append(analysis, entry)
+	}
+	sort.Slice(analysis, func(i, j int) bool {
+		if analysis[i].UsageCount != analysis[j].UsageCount {
+			return analysis[i].UsageCount > analysis[j].UsageCount
+		}
+		return analysis[i].InstanceType < analysis[j].InstanceType
+	})
+	return analysis
+}
+
+// improvement compares the later half of the jobs (by end time) with the earlier
+// half. LearningVelocity is the accuracy improvement per 30 days between the halves.
+func improvement(jobs []types.PerformanceFeedback) types.ImprovementMetrics {
+	if len(jobs) < 2 {
+		return types.ImprovementMetrics{}
+	}
+	half := len(jobs) / 2
+	earlier, later := jobs[:half], jobs[len(jobs)-half:]
+
+	metrics := types.ImprovementMetrics{
+		AccuracyImprovement:     meanOf(later, accuracyScore) - meanOf(earlier, accuracyScore),
+		CostOptimization:        meanOf(later, costSavings) - meanOf(earlier, costSavings),
+		PerformanceOptimization: meanGain(later) - meanGain(earlier),
+	}
+
+	elapsed := midpoint(later).Sub(midpoint(earlier))
+	if days := elapsed.Hours() / 24; days > 0 {
+		metrics.LearningVelocity = metrics.AccuracyImprovement / days * 30
+	}
+	return metrics
+}
+
+func accuracyScore(perf types.PerformanceFeedback) float64 {
+	return perf.PredictionValidation.OverallAccuracyScore
+}
+
+func meanOf(jobs []types.PerformanceFeedback, value func(types.PerformanceFeedback) float64) float64 {
+	var sum float64
+	for _, perf := range jobs {
+		sum += value(perf)
+	}
+	return sum / float64(len(jobs))
+}
+
+func meanGain(jobs []types.PerformanceFeedback) float64 {
+	var sum float64
+	count := 0
+	for _, perf := range jobs {
+		if gain, ok := performanceGain(perf); ok {
+			sum += gain
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// midpoint is the mean end time of jobs
+func midpoint(jobs []types.PerformanceFeedback) time.Time {
+	first := jobs[0].JobMetadata.ActualExecution.EndTime
+	var offset time.Duration
+	for _, perf := range jobs {
+		offset += perf.JobMetadata.ActualExecution.EndTime.Sub(first) / time.Duration(len(jobs))
+	}
+	return first.Add(offset)
+}
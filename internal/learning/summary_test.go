@@ -0,0 +1,110 @@
+package learning
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func feedback(jobID, domainName string, end time.Time, accuracy, cost, savings float64, instanceTypes ...string) types.PerformanceFeedback {
+	perf := types.PerformanceFeedback{
+		JobMetadata: types.JobMetadata{
+			JobID: jobID,
+			ActualExecution: types.ActualExecution{
+				InstanceTypesUsed: instanceTypes,
+				ExecutionDuration: types.Duration(2 * time.Hour),
+				Success:           true,
+				EndTime:           end,
+			},
+		},
+		PredictionValidation: types.PredictionValidation{
+			OverallAccuracyScore: accuracy,
+			InstanceTypeOptimal:  true,
+		},
+		AWSPerformanceMetrics: types.AWSPerformanceMetrics{
+			CPUUtilization:   0.5,
+			ProvisioningTime: types.Duration(15 * time.Minute),
+		},
+		CostAnalysis: types.ActualCostAnalysis{TotalCostUSD: cost, SpotSavingsUSD: savings},
+	}
+	if domainName != "" {
+		perf.JobMetadata.OriginalASBAPrediction = &types.ExecutionPlan{
+			ExecutionMetadata: types.ExecutionMetadata{
+				Tags:                map[string]string{domainTag: domainName},
+				ExpectedPerformance: types.PerformanceModel{OnPremiseWaitTime: types.Duration(time.Hour)},
+			},
+		}
+	}
+	return perf
+}
+
+func TestLoadFeedback(t *testing.T) {
+	dir := t.TempDir()
+	data, err := json.Marshal(feedback("1", "", time.Now(), 0.9, 1, 0, "c6i.xlarge"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "job-1-performance.json"), data, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "job-2-performance.json"), []byte("not json"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "job-1-comment.txt"), []byte("aws_meta:{}"), 0600))
+
+	loaded, skipped, err := LoadFeedback(dir)
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, "1", loaded[0].JobMetadata.JobID)
+	assert.Equal(t, []string{filepath.Join(dir, "job-2-performance.json")}, skipped)
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	jobs := []types.PerformanceFeedback{
+		feedback("4", "climate_modeling", start.AddDate(0, 0, 30), 0.9, 8, 2, "hpc7a.2xlarge"),
+		feedback("1", "climate_modeling", start, 0.7, 10, 0, "hpc7a.2xlarge"),
+		feedback("2", "", start.AddDate(0, 0, 1), 0.7, 4, 0, "c6i.xlarge", "hpc7a.2xlarge"),
+		feedback("3", "machine_learning", start.AddDate(0, 0, 29), 0.9, 6, 6, "g5.xlarge"),
+		feedback("5", "", start.AddDate(0, 2, 0), 0.5, 100, 0, "c6i.xlarge"),
+	}
+
+	summary := Summarize(jobs, start, start.AddDate(0, 1, 1))
+
+	assert.Equal(t, 4, summary.JobCount)
+	assert.Equal(t, start, summary.TimeRange.StartTime)
+	assert.InDelta(t, 28, summary.TotalCostUSD, 1e-9)
+	assert.InDelta(t, 0.8, summary.AverageAccuracy.OverallAccuracyScore, 1e-9)
+	assert.True(t, summary.AverageAccuracy.InstanceTypeOptimal)
+
+	require.Len(t, summary.DomainBreakdown, 3)
+	climate := summary.DomainBreakdown[0]
+	assert.Equal(t, "climate_modeling", climate.Domain)
+	assert.Equal(t, 2, climate.JobCount)
+	assert.InDelta(t, 0.8, climate.AverageAccuracy, 1e-9)
+	assert.InDelta(t, 0.1, climate.AverageCostSavings, 1e-9) // 0 and 2/(8+2)
+	assert.InDelta(t, 0.75, climate.AveragePerformanceGain, 1e-9)
+	assert.Equal(t, UnclassifiedDomain, summary.DomainBreakdown[2].Domain)
+	assert.Zero(t, summary.DomainBreakdown[2].AveragePerformanceGain)
+
+	require.Len(t, summary.InstanceTypeEfficiency, 3)
+	hpc := summary.InstanceTypeEfficiency[0]
+	assert.Equal(t, "hpc7a.2xlarge", hpc.InstanceType)
+	assert.Equal(t, 3, hpc.UsageCount)
+	assert.InDelta(t, 0.5, hpc.AverageUtilization, 1e-9)
+	assert.InDelta(t, 1.0, hpc.RecommendationScore, 1e-9)
+	// 2.5 utilized hours over $20 (job 2 split across two types)
+	assert.InDelta(t, 2.5/20, hpc.CostEfficiency, 1e-9)
+
+	improvement := summary.ImprovementMetrics
+	assert.InDelta(t, 0.2, improvement.AccuracyImprovement, 1e-9)
+	assert.InDelta(t, 0.35, improvement.CostOptimization, 1e-9) // later half saves 0.5 and 0.2
+	assert.InDelta(t, 0.2/29*30, improvement.LearningVelocity, 1e-9)
+}
+
+func TestSummarizeEmpty(t *testing.T) {
+	summary := Summarize(nil, time.Time{}, time.Time{})
+	assert.Zero(t, summary.JobCount)
+	assert.NotNil(t, summary.DomainBreakdown)
+	assert.NotNil(t, summary.InstanceTypeEfficiency)
+}
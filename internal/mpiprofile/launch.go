@@ -0,0 +1,70 @@
+package mpiprofile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment returns env with the mpiP library preloaded and mpiP writing its
+// report to reportDir. Existing LD_PRELOAD entries and MPIP options are kept.
+func Environment(env []string, library, reportDir string) []string {
+	preload := library
+	options := "-f " + reportDir
+
+	var result []string
+	for _, entry := range env {
+		switch {
+		case strings.HasPrefix(entry, "LD_PRELOAD="):
+			if existing := strings.TrimPrefix(entry, "LD_PRELOAD="); existing != "" {
+				preload = library + ":" + existing
+			}
+		case strings.HasPrefix(entry, "MPIP="):
+			if existing := strings.TrimPrefix(entry, "MPIP="); existing != "" {
+				options = existing + " " + options
+			}
+		default:
+			result = append(result, entry)
+		}
+	}
+	return append(result, "LD_PRELOAD="+preload, "MPIP="+options)
+}
+
+// LauncherArgs returns the launcher arguments. Open MPI only forwards exported
+// variables to remote ranks, so they are added with -x.
+func LauncherArgs(openMPI bool, args []string) []string {
+	if !openMPI {
+		return args
+	}
+	return append([]string{"-x", "LD_PRELOAD", "-x", "MPIP"}, args...)
+}
+
+// IsOpenMPI reports whether launcher --version output identifies Open MPI
+func IsOpenMPI(versionOutput string) bool {
+	return strings.Contains(versionOutput, "Open MPI") || strings.Contains(versionOutput, "OpenRTE")
+}
+
+// LatestReport returns the most recently written mpiP report in dir
+func LatestReport(dir string) (string, error) {
+	reports, err := filepath.Glob(filepath.Join(dir, "*.mpiP"))
+	if err != nil {
+		return "", err
+	}
+
+	var latest string
+	var latestTime int64
+	for _, report := range reports {
+		info, err := os.Stat(report)
+		if err != nil {
+			continue
+		}
+		if modified := info.ModTime().UnixNano(); latest == "" || modified > latestTime {
+			latest, latestTime = report, modified
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no mpiP report in %s", dir)
+	}
+	return latest, nil
+}
@@ -0,0 +1,50 @@
+package mpiprofile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironment(t *testing.T) {
+	env := Environment([]string{"PATH=/usr/bin", "LD_PRELOAD=/opt/lib/libtrace.so", "MPIP=-k 2"}, "/usr/lib64/libmpiP.so", "/shared/run-1")
+	assert.Equal(t, []string{
+		"PATH=/usr/bin",
+		"LD_PRELOAD=/usr/lib64/libmpiP.so:/opt/lib/libtrace.so",
+		"MPIP=-k 2 -f /shared/run-1",
+	}, env)
+
+	env = Environment([]string{"PATH=/usr/bin"}, "/usr/lib64/libmpiP.so", "/shared/run-1")
+	assert.Contains(t, env, "LD_PRELOAD=/usr/lib64/libmpiP.so")
+	assert.Contains(t, env, "MPIP=-f /shared/run-1")
+}
+
+func TestLauncherArgs(t *testing.T) {
+	args := []string{"-np", "4", "./solver"}
+	assert.Equal(t, args, LauncherArgs(false, args))
+	assert.Equal(t, []string{"-x", "LD_PRELOAD", "-x", "MPIP", "-np", "4", "./solver"}, LauncherArgs(true, args))
+
+	assert.True(t, IsOpenMPI("mpirun (Open MPI) 4.1.5"))
+	assert.False(t, IsOpenMPI("Intel(R) MPI Library for Linux* OS, Version 2021.10"))
+}
+
+func TestLatestReport(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LatestReport(dir)
+	assert.Error(t, err)
+
+	older := filepath.Join(dir, "solver.4.100.1.mpiP")
+	newer := filepath.Join(dir, "solver.4.100.2.mpiP")
+	require.NoError(t, os.WriteFile(older, nil, 0644))
+	require.NoError(t, os.WriteFile(newer, nil, 0644))
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(older, past, past))
+
+	latest, err := LatestReport(dir)
+	require.NoError(t, err)
+	assert.Equal(t, newer, latest)
+}
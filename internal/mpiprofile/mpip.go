@@ -0,0 +1,219 @@
+// Package mpiprofile turns MPI profiler reports into the communication metrics
+// exported for ASBA learning
+package mpiprofile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// ToolMPIP identifies summaries taken from mpiP reports
+const ToolMPIP = "mpip"
+
+// Message size classes used by types.MessageSizeHistogram
+const (
+	smallMessageBytes = 1024
+	largeMessageBytes = 1024 * 1024
+)
+
+// table is one "@--- Title ---" section of an mpiP report
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// column returns a row's value for a header name, or "" if the column is missing
+func (t *table) column(row []string, name string) string {
+	for i, h := range t.header {
+		if h == name && i < len(row) {
+			return row[i]
+		}
+	}
+	return ""
+}
+
+// ParseMPIP summarizes an mpiP text report. It needs the "MPI Time" section; the
+// aggregate time and message size sections are used when present.
+func ParseMPIP(r io.Reader) (*types.MPIProfileSummary, error) {
+	sections, err := readSections(r)
+	if err != nil {
+		return nil, err
+	}
+
+	times := sections["MPI Time"]
+	if times == nil {
+		return nil, fmt.Errorf("mpiP report has no MPI Time section")
+	}
+
+	summary := &types.MPIProfileSummary{Tool: ToolMPIP}
+	var compute []float64
+	for _, row := range times.rows {
+		app := parseFloat(times.column(row, "AppTime"))
+		mpi := parseFloat(times.column(row, "MPITime"))
+		if times.column(row, "Task") == "*" {
+			continue
+		}
+		summary.Ranks++
+		summary.AppTimeSeconds += app
+		summary.MPITimeSeconds += mpi
+		compute = append(compute, app-mpi)
+	}
+	if summary.Ranks == 0 || summary.AppTimeSeconds <= 0 {
+		return nil, fmt.Errorf("mpiP report has no per-task times")
+	}
+
+	summary.CommunicationOverhead = summary.MPITimeSeconds / summary.AppTimeSeconds
+	summary.LoadBalance = loadBalance(compute)
+
+	if aggregate := sections["Aggregate Time"]; aggregate != nil {
+		summary.TopCalls = callTimes(aggregate)
+		if len(summary.TopCalls) > 0 {
+			summary.CommunicationPattern = CallPattern(summary.TopCalls[0].Call)
+		}
+		for _, call := range summary.TopCalls {
+			if call.Call == "Barrier" {
+				// Barrier counts are summed over ranks, as is application time
+				summary.SynchronizationFrequency = float64(call.Count) / summary.AppTimeSeconds
+			}
+		}
+	}
+	if sizes := sections["Aggregate Sent Message Size"]; sizes != nil {
+		summary.MessageSizeDistribution = messageSizes(sizes)
+	}
+
+	return summary, nil
+}
+
+// readSections splits a report into its tables, keyed by title without the
+// parenthesized detail, e.g. "Aggregate Time"
+func readSections(r io.Reader) (map[string]*table, error) {
+	sections := make(map[string]*table)
+	var current *table
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "@---"):
+			title := strings.Trim(strings.TrimPrefix(line, "@---"), "- ")
+			if idx := strings.IndexAny(title, "(:"); idx >= 0 {
+				title = strings.TrimSpace(title[:idx])
+			}
+			current = &table{}
+			if _, seen := sections[title]; !seen {
+				sections[title] = current
+			}
+		case current == nil || line == "" || strings.HasPrefix(line, "---"):
+			// Section rules and blank lines
+		case strings.HasPrefix(line, "@"):
+			current = nil
+		case current.header == nil:
+			current.header = strings.Fields(line)
+		default:
+			current.rows = append(current.rows, strings.Fields(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mpiP report: %w", err)
+	}
+	return sections, nil
+}
+
+// callTimes sums each call's time over its call sites, most MPI time first
+func callTimes(aggregate *table) []types.MPICallTime {
+	shares := make(map[string]float64)
+	counts := make(map[string]int64)
+	for _, row := range aggregate.rows {
+		call := aggregate.column(row, "Call")
+		shares[call] += parseFloat(aggregate.column(row, "MPI%")) / 100
+		counts[call] += int64(parseFloat(aggregate.column(row, "Count")))
+	}
+
+	calls := make([]types.MPICallTime, 0, len(shares))
+	for call, share := range shares {
+		calls = append(calls, types.MPICallTime{Call: call, MPIShare: share, Count: counts[call]})
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].MPIShare != calls[j].MPIShare {
+			return calls[i].MPIShare > calls[j].MPIShare
+		}
+		return calls[i].Call < calls[j].Call
+	})
+	return calls
+}
+
+// messageSizes buckets sent messages by each call site's average size
+func messageSizes(sizes *table) types.MessageSizeHistogram {
+	var histogram types.MessageSizeHistogram
+	var total, count float64
+	for _, row := range sizes.rows {
+		n := parseFloat(sizes.column(row, "Count"))
+		average := parseFloat(sizes.column(row, "Avrg"))
+		switch {
+		case average < smallMessageBytes:
+			histogram.SmallMessages += int(n)
+		case average <= largeMessageBytes:
+			histogram.MediumMessages += int(n)
+		default:
+			histogram.LargeMessages += int(n)
+		}
+		total += parseFloat(sizes.column(row, "Total"))
+		count += n
+	}
+	if count > 0 {
+		histogram.AverageSize = int(total / count)
+	}
+	return histogram
+}
+
+// loadBalance is mean over maximum per-rank compute (non-MPI) time
+func loadBalance(compute []float64) float64 {
+	var sum, max float64
+	for _, c := range compute {
+		sum += c
+		if c > max {
+			max = c
+		}
+	}
+	if max <= 0 {
+		return 0
+	}
+	return sum / float64(len(compute)) / max
+}
+
+// CallPattern names the communication pattern an MPI call implies
+func CallPattern(call string) string {
+	switch {
+	case call == "Allreduce" || call == "Reduce" || call == "Reduce_scatter":
+		return "all_reduce"
+	case strings.HasPrefix(call, "Alltoall"):
+		return "all_to_all"
+	case strings.HasPrefix(call, "Allgather") || strings.HasPrefix(call, "Gather"):
+		return "gather"
+	case call == "Bcast" || strings.HasPrefix(call, "Scatter"):
+		return "broadcast"
+	case call == "Barrier":
+		return "synchronization"
+	case strings.Contains(call, "send") || strings.Contains(call, "Send") ||
+		strings.Contains(call, "recv") || strings.Contains(call, "Recv") ||
+		strings.HasPrefix(call, "Wait") || strings.HasPrefix(call, "Test"):
+		return "point_to_point"
+	default:
+		return "other"
+	}
+}
+
+func parseFloat(value string) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
@@ -0,0 +1,76 @@
+package mpiprofile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mpipReport = `@ mpiP
+@ Command : ./solver
+@ Version                  : 3.5.0
+@ MPI Task Assignment      : 0 aws-cpu-001
+@ MPI Task Assignment      : 1 aws-cpu-002
+
+---------------------------------------------------------------------------
+@--- MPI Time (seconds) ---------------------------------------------------
+---------------------------------------------------------------------------
+Task    AppTime    MPITime     MPI%
+   0         10          2    20.00
+   1         10          4    40.00
+   *         20          6    30.00
+---------------------------------------------------------------------------
+@--- Callsites: 3 ---------------------------------------------------------
+---------------------------------------------------------------------------
+ ID Lev File/Address        Line Parent_Funct             MPI_Call
+  1   0 solver.c              42 main                     Allreduce
+---------------------------------------------------------------------------
+@--- Aggregate Time (top twenty, descending, milliseconds) ----------------
+---------------------------------------------------------------------------
+Call                 Site       Time    App%    MPI%      Count    COV
+Allreduce               1   4.20e+03   21.00   70.00        400   0.10
+Barrier                 2        900    4.50   15.00         40   0.00
+Send                    3        900    4.50   15.00       1000   0.00
+---------------------------------------------------------------------------
+@--- Aggregate Sent Message Size (top twenty, descending, bytes) ----------
+---------------------------------------------------------------------------
+Call                 Site      Count      Total       Avrg  Sent%
+Allreduce               1        400   3.20e+03          8   0.10
+Send                    3       1000   2.00e+09      2e+06  99.90
+---------------------------------------------------------------------------
+@--- End of Report --------------------------------------------------------
+`
+
+func TestParseMPIP(t *testing.T) {
+	summary, err := ParseMPIP(strings.NewReader(mpipReport))
+	require.NoError(t, err)
+
+	assert.Equal(t, ToolMPIP, summary.Tool)
+	assert.Equal(t, 2, summary.Ranks)
+	assert.Equal(t, 20.0, summary.AppTimeSeconds)
+	assert.InDelta(t, 0.3, summary.CommunicationOverhead, 0.0001)
+	assert.InDelta(t, 7.0/8.0, summary.LoadBalance, 0.0001, "compute 8s and 6s")
+	assert.Equal(t, "all_reduce", summary.CommunicationPattern)
+	assert.InDelta(t, 2.0, summary.SynchronizationFrequency, 0.0001)
+
+	require.Len(t, summary.TopCalls, 3)
+	assert.Equal(t, "Allreduce", summary.TopCalls[0].Call)
+	assert.InDelta(t, 0.7, summary.TopCalls[0].MPIShare, 0.0001)
+	assert.Equal(t, int64(400), summary.TopCalls[0].Count)
+
+	assert.Equal(t, 400, summary.MessageSizeDistribution.SmallMessages)
+	assert.Equal(t, 1000, summary.MessageSizeDistribution.LargeMessages)
+
+	_, err = ParseMPIP(strings.NewReader("@ mpiP\n"))
+	assert.Error(t, err)
+}
+
+func TestCallPattern(t *testing.T) {
+	assert.Equal(t, "all_to_all", CallPattern("Alltoallv"))
+	assert.Equal(t, "point_to_point", CallPattern("Isend"))
+	assert.Equal(t, "point_to_point", CallPattern("Waitall"))
+	assert.Equal(t, "broadcast", CallPattern("Bcast"))
+	assert.Equal(t, "other", CallPattern("Comm_split"))
+}
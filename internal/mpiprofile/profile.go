@@ -0,0 +1,102 @@
+package mpiprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// JobDir is the directory, relative to the output directory, holding a job's profiles
+func JobDir(jobID string) string {
+	return "job-" + jobID
+}
+
+// WriteSummary stores one launch's summary as name.json in the job's directory
+func WriteSummary(outputDir, name string, summary *types.MPIProfileSummary) (string, error) {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode MPI profile: %w", err)
+	}
+
+	path := filepath.Join(outputDir, JobDir(summary.JobID), name+".json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create MPI profile directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write MPI profile: %w", err)
+	}
+	return path, nil
+}
+
+// LoadDir reads the summaries of every profiled launch in a job, in name order.
+// A job without profiles returns none and no error.
+func LoadDir(outputDir, jobID string) ([]types.MPIProfileSummary, error) {
+	files, err := filepath.Glob(filepath.Join(outputDir, JobDir(jobID), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	profiles := make([]types.MPIProfileSummary, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MPI profile: %w", err)
+		}
+		var profile types.MPIProfileSummary
+		if err := json.Unmarshal(data, &profile); err != nil {
+			return nil, fmt.Errorf("invalid MPI profile %s: %w", filepath.Base(file), err)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// Apply replaces the estimated communication metrics in results with those
+// measured over the job's profiled launches, weighting each launch by its
+// application time. The pattern comes from the longest launch. Metrics the
+// profiles do not cover, such as scaling efficiency, are left as they are.
+func Apply(results *types.MPIOptimizationResults, profiles []types.MPIProfileSummary) {
+	var runs int
+	var appTime, mpiTime, balance, syncFrequency, longest float64
+	var sizes types.MessageSizeHistogram
+	var sizeTotal float64
+
+	for _, profile := range profiles {
+		if profile.AppTimeSeconds <= 0 {
+			continue
+		}
+		runs++
+		appTime += profile.AppTimeSeconds
+		mpiTime += profile.MPITimeSeconds
+		balance += profile.LoadBalance * profile.AppTimeSeconds
+		syncFrequency += profile.SynchronizationFrequency * profile.AppTimeSeconds
+		if profile.AppTimeSeconds > longest {
+			longest = profile.AppTimeSeconds
+			results.CommunicationPattern = profile.CommunicationPattern
+		}
+
+		histogram := profile.MessageSizeDistribution
+		messages := histogram.SmallMessages + histogram.MediumMessages + histogram.LargeMessages
+		sizes.SmallMessages += histogram.SmallMessages
+		sizes.MediumMessages += histogram.MediumMessages
+		sizes.LargeMessages += histogram.LargeMessages
+		sizeTotal += float64(histogram.AverageSize) * float64(messages)
+	}
+	if runs == 0 {
+		return
+	}
+
+	results.CommunicationOverhead = mpiTime / appTime
+	results.LoadBalance = balance / appTime
+	results.SynchronizationFrequency = syncFrequency / appTime
+	if messages := sizes.SmallMessages + sizes.MediumMessages + sizes.LargeMessages; messages > 0 {
+		sizes.AverageSize = int(sizeTotal / float64(messages))
+	}
+	results.MessageSizeDistribution = sizes
+	results.ProfiledRuns = runs
+}
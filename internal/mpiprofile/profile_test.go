@@ -0,0 +1,49 @@
+package mpiprofile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func TestWriteSummaryAndLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	_, err := WriteSummary(dir, "run-2", &types.MPIProfileSummary{JobID: "42", AppTimeSeconds: 20})
+	require.NoError(t, err)
+	_, err = WriteSummary(dir, "run-1", &types.MPIProfileSummary{JobID: "42", AppTimeSeconds: 10})
+	require.NoError(t, err)
+
+	profiles, err := LoadDir(dir, "42")
+	require.NoError(t, err)
+	require.Len(t, profiles, 2)
+	assert.Equal(t, 10.0, profiles[0].AppTimeSeconds)
+
+	none, err := LoadDir(dir, "43")
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestApply(t *testing.T) {
+	results := types.MPIOptimizationResults{CommunicationOverhead: 0.12, ScalingEfficiency: 0.87, CommunicationPattern: "nearest_neighbor"}
+
+	Apply(&results, nil)
+	assert.Equal(t, 0.12, results.CommunicationOverhead, "estimates stay without profiles")
+
+	Apply(&results, []types.MPIProfileSummary{
+		{AppTimeSeconds: 30, MPITimeSeconds: 3, LoadBalance: 0.9, CommunicationPattern: "all_reduce",
+			MessageSizeDistribution: types.MessageSizeHistogram{SmallMessages: 3, AverageSize: 100}},
+		{AppTimeSeconds: 10, MPITimeSeconds: 5, LoadBalance: 0.5, CommunicationPattern: "point_to_point", SynchronizationFrequency: 4,
+			MessageSizeDistribution: types.MessageSizeHistogram{LargeMessages: 1, AverageSize: 2000000}},
+		{},
+	})
+	assert.InDelta(t, 0.2, results.CommunicationOverhead, 0.0001)
+	assert.InDelta(t, 0.8, results.LoadBalance, 0.0001)
+	assert.InDelta(t, 1.0, results.SynchronizationFrequency, 0.0001)
+	assert.Equal(t, "all_reduce", results.CommunicationPattern)
+	assert.Equal(t, 500075, results.MessageSizeDistribution.AverageSize)
+	assert.Equal(t, 0.87, results.ScalingEfficiency)
+	assert.Equal(t, 2, results.ProfiledRuns)
+}
@@ -0,0 +1,97 @@
+// Package nodemetrics reads the per-node summaries written by the node metrics
+// collector and folds them into a job's performance metrics
+package nodemetrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// JobDir is the directory, relative to the destination, holding a job's summaries
+func JobDir(jobID string) string {
+	return "job-" + jobID
+}
+
+// LoadDir reads the summaries nodes wrote for a job under a shared directory.
+// A job without summaries returns none and no error.
+func LoadDir(dir, jobID string) ([]types.NodeMetricsSummary, error) {
+	files, err := filepath.Glob(filepath.Join(dir, JobDir(jobID), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string][]byte, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read node metrics: %w", err)
+		}
+		objects[filepath.Base(file)] = data
+	}
+	return Decode(objects)
+}
+
+// Decode parses summaries keyed by file name, in name order
+func Decode(objects map[string][]byte) ([]types.NodeMetricsSummary, error) {
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		if strings.HasSuffix(name, ".json") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	summaries := make([]types.NodeMetricsSummary, 0, len(names))
+	for _, name := range names {
+		var summary types.NodeMetricsSummary
+		if err := json.Unmarshal(objects[name], &summary); err != nil {
+			return nil, fmt.Errorf("invalid node metrics summary %s: %w", name, err)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// Apply replaces the estimated utilization in metrics with the measured values:
+// CPU and memory are averaged over all samples, network throughput is the
+// busiest node's peak and EFA utilization is averaged over nodes with EFA.
+// Summaries without samples are ignored; metrics are left unchanged if none remain.
+func Apply(metrics *types.AWSPerformanceMetrics, summaries []types.NodeMetricsSummary) {
+	var samples, nodes, efaNodes int
+	var cpu, memory, efa, networkPeak float64
+
+	for _, summary := range summaries {
+		if summary.Samples <= 0 {
+			continue
+		}
+		nodes++
+		samples += summary.Samples
+		cpu += summary.CPUUtilization * float64(summary.Samples)
+		memory += summary.MemoryUtilization * float64(summary.Samples)
+		if summary.NetworkPeakGbps > networkPeak {
+			networkPeak = summary.NetworkPeakGbps
+		}
+		if summary.EFAPeakGbps > 0 || summary.EFAUtilization > 0 {
+			efaNodes++
+			efa += summary.EFAUtilization
+		}
+	}
+	if nodes == 0 {
+		return
+	}
+
+	metrics.CPUUtilization = cpu / float64(samples)
+	metrics.MemoryUtilization = memory / float64(samples)
+	metrics.NetworkThroughputGbps = networkPeak
+	metrics.EFAUtilization = 0
+	if efaNodes > 0 {
+		metrics.EFAUtilization = efa / float64(efaNodes)
+	}
+	metrics.MeasuredNodes = nodes
+}
@@ -0,0 +1,51 @@
+package nodemetrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	jobDir := filepath.Join(dir, JobDir("42"))
+	require.NoError(t, os.MkdirAll(jobDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(jobDir, "aws-cpu-002.json"), []byte(`{"node_name":"aws-cpu-002","samples":3}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(jobDir, "aws-cpu-001.json"), []byte(`{"node_name":"aws-cpu-001","samples":4}`), 0644))
+
+	summaries, err := LoadDir(dir, "42")
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	assert.Equal(t, "aws-cpu-001", summaries[0].NodeName)
+
+	none, err := LoadDir(dir, "43")
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	require.NoError(t, os.WriteFile(filepath.Join(jobDir, "broken.json"), []byte("{"), 0644))
+	_, err = LoadDir(dir, "42")
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	metrics := types.AWSPerformanceMetrics{CPUUtilization: 0.87, EFAUtilization: 0.89, NetworkThroughputGbps: 45.2}
+
+	Apply(&metrics, nil)
+	assert.Equal(t, 0.87, metrics.CPUUtilization, "estimates stay without summaries")
+
+	Apply(&metrics, []types.NodeMetricsSummary{
+		{NodeName: "aws-cpu-001", Samples: 3, CPUUtilization: 0.9, MemoryUtilization: 0.5, NetworkPeakGbps: 12, EFAPeakGbps: 80, EFAUtilization: 0.6},
+		{NodeName: "aws-cpu-002", Samples: 1, CPUUtilization: 0.5, MemoryUtilization: 0.1, NetworkPeakGbps: 20},
+		{NodeName: "aws-cpu-003"},
+	})
+	assert.InDelta(t, 0.8, metrics.CPUUtilization, 0.0001)
+	assert.InDelta(t, 0.4, metrics.MemoryUtilization, 0.0001)
+	assert.Equal(t, 20.0, metrics.NetworkThroughputGbps)
+	assert.Equal(t, 0.6, metrics.EFAUtilization)
+	assert.Equal(t, 2, metrics.MeasuredNodes)
+}
@@ -0,0 +1,139 @@
+// Package planlint flags execution plans that are valid but risky, such as
+// large gang-scheduled spot jobs or plans without a cost ceiling. Findings are
+// advice; callers decide which severities should fail.
+package planlint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Severity ranks a finding
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error" // The plan will almost certainly not behave as intended
+)
+
+// rank orders severities for AtLeast
+var rank = map[Severity]int{SeverityInfo: 0, SeverityWarning: 1, SeverityError: 2}
+
+// AtLeast reports whether s is as severe as other
+func (s Severity) AtLeast(other Severity) bool {
+	return rank[s] >= rank[other]
+}
+
+// Rules, named in findings so they can be looked up and discussed
+const (
+	RuleSpotGang         = "spot-gang-large"
+	RuleNoCostCeiling    = "no-cost-ceiling"
+	RuleNoSpotPriceCap   = "no-spot-price-cap"
+	RuleEFAUnsupported   = "efa-unsupported"
+	RulePlacementMultiAZ = "placement-multi-az"
+	RuleNoAutoTerminate  = "no-auto-terminate"
+	largeGangNodes       = 16 // One reclaimed instance fails a gang; beyond this, interruptions become likely
+)
+
+// Finding is one lint result
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Options add context the plan does not carry
+type Options struct {
+	NodeCount int // Nodes the plan launches; inferred from node roles when zero
+}
+
+// Lint checks plan against the rules, most severe findings first. A plan
+// that does not burst launches nothing and has no findings.
+func Lint(plan *types.ExecutionPlan, opts Options) []Finding {
+	if !plan.ShouldBurst {
+		return nil
+	}
+
+	var findings []Finding
+	add := func(rule string, severity Severity, format string, args ...interface{}) {
+		findings = append(findings, Finding{Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	spec, mpi, network, costs := plan.InstanceSpec, plan.MPIConfig, plan.NetworkConfig, plan.CostConstraints
+	usesSpot := spec.PurchasingOption == "spot" || spec.PurchasingOption == "mixed"
+
+	nodeCount := opts.NodeCount
+	if nodeCount == 0 {
+		for _, role := range plan.NodeRoles {
+			// A role's node count includes its named nodes
+			nodeCount += max(role.NodeCount, len(role.NodeNames))
+		}
+	}
+	if usesSpot && mpi.RequiresGangScheduling && nodeCount > largeGangNodes {
+		add(RuleSpotGang, SeverityWarning,
+			"%d gang-scheduled nodes on %s capacity: one spot interruption fails the whole job; use on-demand or fewer nodes",
+			nodeCount, spec.PurchasingOption)
+	}
+
+	if costs.MaxTotalCost <= 0 && costs.MaxCostPerHour <= 0 {
+		add(RuleNoCostCeiling, SeverityWarning, "no max_total_cost or max_cost_per_hour; the job's spend is unbounded")
+	}
+	if usesSpot && spec.MaxSpotPrice <= 0 {
+		add(RuleNoSpotPriceCap, SeverityInfo, "no max_spot_price; spot instances may cost up to the on-demand price")
+	}
+
+	if mpi.RequiresEFA {
+		var withoutEFA []string
+		for _, instanceType := range spec.InstanceTypes {
+			if !types.KnownEFAFamily(types.InstanceFamilyOf(instanceType)) {
+				withoutEFA = append(withoutEFA, instanceType)
+			}
+		}
+		switch {
+		case len(spec.InstanceTypes) > 0 && len(withoutEFA) == len(spec.InstanceTypes):
+			add(RuleEFAUnsupported, SeverityError, "EFA requested but no instance type supports it: %s", strings.Join(withoutEFA, ", "))
+		case len(withoutEFA) > 0:
+			add(RuleEFAUnsupported, SeverityWarning, "EFA requested; these instance types will be dropped: %s", strings.Join(withoutEFA, ", "))
+		}
+	}
+
+	if network.PlacementGroupType == "cluster" {
+		switch {
+		case len(network.AvailabilityZones) > 1:
+			add(RulePlacementMultiAZ, SeverityError, "a cluster placement group lives in one AZ, but the plan lists %d: %s",
+				len(network.AvailabilityZones), strings.Join(network.AvailabilityZones, ", "))
+		case !network.SingleAZRequired && len(network.AvailabilityZones) == 0 && len(spec.SubnetIds) > 1:
+			add(RulePlacementMultiAZ, SeverityWarning,
+				"cluster placement group with %d subnets that may span AZs; set single_az_required or list one availability zone", len(spec.SubnetIds))
+		}
+	}
+
+	if costs.AutoTerminateHours <= 0 {
+		add(RuleNoAutoTerminate, SeverityWarning, "no auto_terminate_hours; instances of a hung job run until Slurm suspends them")
+	}
+
+	// Most severe first, keeping rule order within a severity
+	ordered := make([]Finding, 0, len(findings))
+	for _, severity := range []Severity{SeverityError, SeverityWarning, SeverityInfo} {
+		for _, finding := range findings {
+			if finding.Severity == severity {
+				ordered = append(ordered, finding)
+			}
+		}
+	}
+	return ordered
+}
+
+// Fails reports whether any finding is at least threshold, which is how
+// --strict decides the exit status
+func Fails(findings []Finding, threshold Severity) bool {
+	for _, finding := range findings {
+		if finding.Severity.AtLeast(threshold) {
+			return true
+		}
+	}
+	return false
+}
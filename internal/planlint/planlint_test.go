@@ -0,0 +1,106 @@
+package planlint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// cleanPlan has no findings; tests break it one rule at a time
+func cleanPlan() *types.ExecutionPlan {
+	return &types.ExecutionPlan{
+		ShouldBurst: true,
+		InstanceSpec: types.InstanceSpecification{
+			InstanceTypes:    []string{"hpc7a.96xlarge"},
+			PurchasingOption: "spot",
+			MaxSpotPrice:     5,
+			SubnetIds:        []string{"subnet-a", "subnet-b"},
+		},
+		MPIConfig: types.MPIConfiguration{IsMPIJob: true, RequiresEFA: true, RequiresGangScheduling: true},
+		CostConstraints: types.CostConstraints{
+			MaxTotalCost:       100,
+			AutoTerminateHours: 4,
+		},
+		NetworkConfig: types.NetworkConfiguration{PlacementGroupType: "cluster", SingleAZRequired: true},
+		NodeRoles:     []types.NodeRoleSpec{{Role: "compute", NodeCount: 8}},
+	}
+}
+
+func rules(findings []Finding) map[string]Severity {
+	bySeverity := make(map[string]Severity)
+	for _, finding := range findings {
+		bySeverity[finding.Rule] = finding.Severity
+	}
+	return bySeverity
+}
+
+func TestLint(t *testing.T) {
+	assert.Empty(t, Lint(cleanPlan(), Options{}))
+
+	noBurst := cleanPlan()
+	noBurst.ShouldBurst = false
+	noBurst.CostConstraints = types.CostConstraints{}
+	assert.Empty(t, Lint(noBurst, Options{}), "plans that do not burst launch nothing")
+
+	tests := []struct {
+		name   string
+		modify func(plan *types.ExecutionPlan)
+		opts   Options
+		want   map[string]Severity
+	}{
+		{"large spot gang from node roles", func(p *types.ExecutionPlan) {
+			p.NodeRoles = []types.NodeRoleSpec{{Role: "head", NodeNames: []string{"n1"}}, {Role: "compute", NodeCount: 16}}
+		}, Options{}, map[string]Severity{RuleSpotGang: SeverityWarning}},
+		{"large spot gang from options", func(p *types.ExecutionPlan) {}, Options{NodeCount: 32},
+			map[string]Severity{RuleSpotGang: SeverityWarning}},
+		{"large on-demand gang", func(p *types.ExecutionPlan) {
+			p.InstanceSpec.PurchasingOption = "on-demand"
+		}, Options{NodeCount: 32}, map[string]Severity{}},
+		{"no cost ceiling or spot cap", func(p *types.ExecutionPlan) {
+			p.CostConstraints.MaxTotalCost = 0
+			p.InstanceSpec.MaxSpotPrice = 0
+		}, Options{}, map[string]Severity{RuleNoCostCeiling: SeverityWarning, RuleNoSpotPriceCap: SeverityInfo}},
+		{"no EFA types", func(p *types.ExecutionPlan) {
+			p.InstanceSpec.InstanceTypes = []string{"t3.large", "m5.xlarge"}
+		}, Options{}, map[string]Severity{RuleEFAUnsupported: SeverityError}},
+		{"some EFA types", func(p *types.ExecutionPlan) {
+			p.InstanceSpec.InstanceTypes = []string{"c5n.18xlarge", "t3.large"}
+		}, Options{}, map[string]Severity{RuleEFAUnsupported: SeverityWarning}},
+		{"placement group across AZs", func(p *types.ExecutionPlan) {
+			p.NetworkConfig.AvailabilityZones = []string{"us-east-1a", "us-east-1b"}
+		}, Options{}, map[string]Severity{RulePlacementMultiAZ: SeverityError}},
+		{"placement group with subnets that may span AZs", func(p *types.ExecutionPlan) {
+			p.NetworkConfig.SingleAZRequired = false
+		}, Options{}, map[string]Severity{RulePlacementMultiAZ: SeverityWarning}},
+		{"no auto-terminate", func(p *types.ExecutionPlan) {
+			p.CostConstraints.AutoTerminateHours = 0
+		}, Options{}, map[string]Severity{RuleNoAutoTerminate: SeverityWarning}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := cleanPlan()
+			tt.modify(plan)
+			assert.Equal(t, tt.want, rules(Lint(plan, tt.opts)))
+		})
+	}
+}
+
+func TestLintOrderAndFails(t *testing.T) {
+	plan := cleanPlan()
+	plan.InstanceSpec.MaxSpotPrice = 0
+	plan.InstanceSpec.InstanceTypes = []string{"t3.large"}
+	plan.CostConstraints.AutoTerminateHours = 0
+
+	findings := Lint(plan, Options{})
+	var severities []Severity
+	for _, finding := range findings {
+		severities = append(severities, finding.Severity)
+	}
+	assert.Equal(t, []Severity{SeverityError, SeverityWarning, SeverityInfo}, severities)
+
+	assert.True(t, Fails(findings, SeverityWarning))
+	assert.False(t, Fails(findings[2:], SeverityWarning), "info findings never fail")
+	assert.False(t, Fails(nil, SeverityInfo))
+}
@@ -0,0 +1,152 @@
+// Package planmodule runs site decision modules on the execution plans resume
+// generates in standalone mode. A module is an external program speaking a
+// JSON protocol: it reads a Request on stdin and writes a Response on stdout
+// accepting, modifying or vetoing the plan, so sites can enforce policies such
+// as campus fair-share or data locality without changing aws-slurm-burst.
+package planmodule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// ProtocolVersion is the version of the Request and Response documents
+const ProtocolVersion = 1
+
+// A module's decision on a plan
+const (
+	DecisionAccept = "accept" // Launch the plan as given
+	DecisionModify = "modify" // Launch the plan in the response instead
+	DecisionVeto   = "veto"   // Do not launch the nodes
+)
+
+// Request is what a module reads on stdin
+type Request struct {
+	Version   int                  `json:"version"`
+	Module    string               `json:"module"`
+	Partition string               `json:"partition"`
+	NodeGroup string               `json:"node_group"`
+	Nodes     []string             `json:"nodes"`
+	Job       *types.SlurmJob      `json:"job,omitempty"` // The pending job, when Slurm has one for the nodes
+	Plan      *types.ExecutionPlan `json:"plan"`
+}
+
+// Response is what a module writes on stdout
+type Response struct {
+	Decision string               `json:"decision"`
+	Plan     *types.ExecutionPlan `json:"plan,omitempty"`   // The replacement plan of a modify decision
+	Reason   string               `json:"reason,omitempty"` // Logged, and why a veto refused the launch
+}
+
+// Outcome is what the modules decided for one plan
+type Outcome struct {
+	Plan     *types.ExecutionPlan // The plan to launch; nil when vetoed
+	Vetoed   bool
+	Module   string   // The module that vetoed
+	Reason   string   // Why it vetoed
+	Modified []string // Modules that changed the plan, in order
+}
+
+// Apply runs the modules for the request's partition in configuration order,
+// each on the plan the previous one returned, and stops at the first veto. A
+// module that fails, times out or answers with an invalid response is logged
+// and handled by its on_error setting.
+func Apply(ctx context.Context, logger *zap.Logger, modules []config.PlanModuleConfig, req Request) *Outcome {
+	outcome := &Outcome{Plan: req.Plan}
+	for i := range modules {
+		module := &modules[i]
+		if !module.Applies(req.Partition) {
+			continue
+		}
+		req.Version, req.Module, req.Plan = ProtocolVersion, module.Name, outcome.Plan
+
+		start := time.Now()
+		response, err := run(ctx, module, &req)
+		if err != nil {
+			logger.Error("Plan module failed",
+				zap.String("module", module.Name),
+				zap.String("on_error", module.OnError),
+				zap.String("nodes", slurm.CompressHostlist(req.Nodes)),
+				zap.Error(err))
+			if module.OnError == config.PlanModuleOnErrorVeto {
+				outcome.Plan, outcome.Vetoed, outcome.Module = nil, true, module.Name
+				outcome.Reason = fmt.Sprintf("module failed: %v", err)
+				return outcome
+			}
+			continue
+		}
+		logger.Info("Plan module decided",
+			zap.String("module", module.Name),
+			zap.String("decision", response.Decision),
+			zap.String("reason", response.Reason),
+			zap.String("nodes", slurm.CompressHostlist(req.Nodes)),
+			zap.Duration("duration", time.Since(start)))
+
+		switch response.Decision {
+		case DecisionVeto:
+			outcome.Plan, outcome.Vetoed, outcome.Module, outcome.Reason = nil, true, module.Name, response.Reason
+			return outcome
+		case DecisionModify:
+			outcome.Plan = response.Plan
+			outcome.Modified = append(outcome.Modified, module.Name)
+		}
+	}
+	return outcome
+}
+
+// run runs one module within its timeout and decodes its response
+func run(ctx context.Context, module *config.PlanModuleConfig, req *Request) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	body = append(body, '\n')
+
+	ctx, cancel := context.WithTimeout(ctx, module.Timeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", module.Command)
+	// A module past its timeout is killed with everything it started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"AWS_SLURM_BURST_PLAN_MODULE="+module.Name,
+		"AWS_SLURM_BURST_NODES="+slurm.CompressHostlist(req.Nodes),
+		"AWS_SLURM_BURST_PARTITION="+req.Partition,
+		"AWS_SLURM_BURST_NODE_GROUP="+req.NodeGroup)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("command failed: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	var response Response
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	switch response.Decision {
+	case DecisionAccept, DecisionVeto:
+	case DecisionModify:
+		if response.Plan == nil {
+			return nil, fmt.Errorf("modify decision without a plan")
+		}
+		if err := response.Plan.ValidateExecutionPlan(); err != nil {
+			return nil, fmt.Errorf("modified plan is invalid: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown decision %q (use %s, %s or %s)", response.Decision, DecisionAccept, DecisionModify, DecisionVeto)
+	}
+	return &response, nil
+}
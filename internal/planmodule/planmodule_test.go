@@ -0,0 +1,114 @@
+package planmodule
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testPlan(instanceType string) *types.ExecutionPlan {
+	return &types.ExecutionPlan{
+		ShouldBurst: true,
+		InstanceSpec: types.InstanceSpecification{
+			InstanceTypes: []string{instanceType}, SubnetIds: []string{"subnet-1"}, PurchasingOption: "on-demand",
+		},
+	}
+}
+
+func testRequest() Request {
+	return Request{Partition: "aws", NodeGroup: "cpu", Nodes: []string{"aws-cpu-001", "aws-cpu-002"},
+		Job: &types.SlurmJob{JobID: "42", Account: "physics"}, Plan: testPlan("c5.large")}
+}
+
+func module(name, command string) config.PlanModuleConfig {
+	return config.PlanModuleConfig{Name: name, Command: command, TimeoutSeconds: 5, OnError: config.PlanModuleOnErrorAccept}
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	requestOut := filepath.Join(dir, "request")
+	modified, err := json.Marshal(Response{Decision: DecisionModify, Plan: testPlan("c6i.large"), Reason: "data lives in us-east-1"})
+	require.NoError(t, err)
+
+	outcome := Apply(context.Background(), zap.NewNop(), []config.PlanModuleConfig{
+		module("fairshare", `cat > `+requestOut+`; echo '{"decision":"accept"}'`),
+		module("locality", `echo '`+string(modified)+`'`),
+	}, testRequest())
+	require.False(t, outcome.Vetoed)
+	assert.Equal(t, []string{"c6i.large"}, outcome.Plan.InstanceSpec.InstanceTypes)
+	assert.Equal(t, []string{"locality"}, outcome.Modified)
+
+	data, err := os.ReadFile(requestOut)
+	require.NoError(t, err)
+	var req Request
+	require.NoError(t, json.Unmarshal(data, &req))
+	assert.Equal(t, ProtocolVersion, req.Version)
+	assert.Equal(t, "fairshare", req.Module)
+	assert.Equal(t, "physics", req.Job.Account)
+	assert.Equal(t, []string{"c5.large"}, req.Plan.InstanceSpec.InstanceTypes)
+
+	// Later modules see the modified plan; a veto stops the chain
+	ran := filepath.Join(dir, "ran")
+	outcome = Apply(context.Background(), zap.NewNop(), []config.PlanModuleConfig{
+		module("locality", `echo '`+string(modified)+`'`),
+		module("fairshare", `grep -q c6i.large && echo '{"decision":"veto","reason":"physics is over its fair share"}'`),
+		module("after", `touch `+ran+`; echo '{"decision":"accept"}'`),
+	}, testRequest())
+	assert.True(t, outcome.Vetoed)
+	assert.Nil(t, outcome.Plan)
+	assert.Equal(t, "fairshare", outcome.Module)
+	assert.Equal(t, "physics is over its fair share", outcome.Reason)
+	assert.NoFileExists(t, ran)
+
+	// Modules for other partitions do not run
+	gpu := module("gpu", `echo '{"decision":"veto"}'`)
+	gpu.Partitions = []string{"gpu"}
+	outcome = Apply(context.Background(), zap.NewNop(), []config.PlanModuleConfig{gpu}, testRequest())
+	assert.False(t, outcome.Vetoed)
+	assert.Equal(t, testRequest().Plan, outcome.Plan)
+}
+
+func TestApply_Failures(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		reason  string
+	}{
+		{"exit", "echo no database >&2; exit 2", "module failed: command failed: exit status 2: no database"},
+		{"garbage", "echo yes", "module failed: invalid response"},
+		{"unknown", `echo '{"decision":"maybe"}'`, `module failed: unknown decision "maybe"`},
+		{"no plan", `echo '{"decision":"modify"}'`, "module failed: modify decision without a plan"},
+		{"bad plan", `echo '{"decision":"modify","plan":{"should_burst":true}}'`, "module failed: modified plan is invalid: no instance types"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// accept keeps the plan the module was given
+			outcome := Apply(context.Background(), zap.NewNop(), []config.PlanModuleConfig{module("policy", tt.command)}, testRequest())
+			assert.False(t, outcome.Vetoed)
+			assert.Equal(t, testRequest().Plan, outcome.Plan)
+
+			strict := module("policy", tt.command)
+			strict.OnError = config.PlanModuleOnErrorVeto
+			outcome = Apply(context.Background(), zap.NewNop(), []config.PlanModuleConfig{strict}, testRequest())
+			assert.True(t, outcome.Vetoed)
+			assert.Contains(t, outcome.Reason, tt.reason)
+		})
+	}
+
+	// A module past its timeout is killed
+	slow := module("slow", "sleep 5")
+	slow.TimeoutSeconds, slow.OnError = 1, config.PlanModuleOnErrorVeto
+	start := time.Now()
+	outcome := Apply(context.Background(), zap.NewNop(), []config.PlanModuleConfig{slow}, testRequest())
+	assert.True(t, outcome.Vetoed)
+	assert.Less(t, time.Since(start), 4*time.Second)
+}
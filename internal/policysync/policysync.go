@@ -0,0 +1,166 @@
+// Package policysync resolves the association selectors of burst policy
+// rules to Slurm users and accounts. The state manager reads the association
+// tree from sacctmgr, or from a site command such as an LDAP query, on a
+// schedule and writes what each rule selects to a state file; resume and the
+// spend tools load that file into the policy rules.
+package policysync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+)
+
+// commandTimeout bounds one run of sacctmgr or the sync command
+const commandTimeout = 2 * time.Minute
+
+// Snapshot is what each rule selected at a sync
+type Snapshot struct {
+	SyncedAt     time.Time                          `json:"synced_at"`
+	Associations int                                `json:"associations"`
+	Rules        map[string]config.PolicyIdentities `json:"rules"` // By rule name
+}
+
+// Due reports whether the snapshot is older than the sync interval, or missing
+func Due(snapshot *Snapshot, sync *config.PolicySyncConfig, now time.Time) bool {
+	return snapshot == nil || now.Sub(snapshot.SyncedAt) >= time.Duration(sync.IntervalMinutes)*time.Minute
+}
+
+// Sync reads the associations from the configured source, resolves the rules'
+// selectors and saves the snapshot to the state file
+func Sync(ctx context.Context, cfg *config.Config, slurmClient *slurm.Client, now time.Time) (*Snapshot, error) {
+	sync := &cfg.Policies.Sync
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	var associations []slurm.Association
+	if sync.Source == config.PolicySyncCommand {
+		output, err := exec.CommandContext(ctx, "/bin/sh", "-c", sync.Command).Output()
+		if err != nil {
+			return nil, fmt.Errorf("policy sync command failed: %w", err)
+		}
+		associations = slurm.ParseAssociations(string(output))
+	} else {
+		var err error
+		if associations, err = slurmClient.Associations(ctx); err != nil {
+			return nil, err
+		}
+	}
+	// An empty tree is more likely a broken source than a site with no users
+	if len(associations) == 0 {
+		return nil, fmt.Errorf("policy sync source returned no associations")
+	}
+
+	snapshot := &Snapshot{
+		SyncedAt:     now,
+		Associations: len(associations),
+		Rules:        Resolve(cfg.Policies.Rules, associations),
+	}
+	if err := Save(sync.StateFile, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Resolve finds the users and accounts each rule's association selectors
+// select: the users with an association that may use one of its qos, and its
+// parent_accounts with every account beneath them in the association tree
+func Resolve(rules []config.PolicyConfig, associations []slurm.Association) map[string]config.PolicyIdentities {
+	children := make(map[string][]string)
+	for _, association := range associations {
+		if association.User == "" && association.Parent != "" && !slices.Contains(children[association.Parent], association.Account) {
+			children[association.Parent] = append(children[association.Parent], association.Account)
+		}
+	}
+
+	resolved := make(map[string]config.PolicyIdentities)
+	for _, rule := range rules {
+		var identities config.PolicyIdentities
+		for _, association := range associations {
+			if association.User == "" || slices.Contains(identities.Users, association.User) {
+				continue
+			}
+			if slices.ContainsFunc(association.QOS, func(qos string) bool { return slices.Contains(rule.QOS, qos) }) {
+				identities.Users = append(identities.Users, association.User)
+			}
+		}
+
+		pending := slices.Clone(rule.ParentAccounts)
+		for len(pending) > 0 {
+			account := pending[0]
+			pending = pending[1:]
+			if slices.Contains(identities.Accounts, account) {
+				continue
+			}
+			identities.Accounts = append(identities.Accounts, account)
+			pending = append(pending, children[account]...)
+		}
+
+		if len(identities.Users) > 0 || len(identities.Accounts) > 0 {
+			slices.Sort(identities.Users)
+			slices.Sort(identities.Accounts)
+			resolved[rule.Name] = identities
+		}
+	}
+	return resolved
+}
+
+// Save writes the snapshot to path, replacing any earlier one atomically
+func Save(path string, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create policy sync directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write policy sync state %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads the snapshot at path. It returns nil and no error when there is
+// none yet.
+func Load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy sync state %s: %w", path, err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid policy sync state %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+// Apply loads the last sync into the configuration's policy rules, so their
+// association selectors match. It does nothing when sync is disabled; until
+// the first sync, rules match the users and accounts they name only.
+func Apply(cfg *config.Config) error {
+	if !cfg.Policies.Sync.Enabled {
+		return nil
+	}
+	snapshot, err := Load(cfg.Policies.Sync.StateFile)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		return fmt.Errorf("policy identities have not been synced yet")
+	}
+	cfg.Policies.SetSynced(snapshot.Rules)
+	return nil
+}
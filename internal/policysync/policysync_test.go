@@ -0,0 +1,80 @@
+package policysync
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// associations is a science account with chem and its class account beneath
+const associations = `root|||normal
+science||root|normal
+chem||science|normal
+chem-class||chem|student
+physics||root|normal
+chem|alice||normal,urgent
+chem-class|bob||student
+chem-class|carol||student
+physics|dave||normal,student
+`
+
+func TestResolve(t *testing.T) {
+	rules := []config.PolicyConfig{
+		{Name: "students", QOS: []string{"student"}},
+		{Name: "science", ParentAccounts: []string{"science"}},
+		{Name: "pi", Users: []string{"alice"}},
+	}
+	assert.Equal(t, map[string]config.PolicyIdentities{
+		"students": {Users: []string{"bob", "carol", "dave"}},
+		"science":  {Accounts: []string{"chem", "chem-class", "science"}},
+	}, Resolve(rules, slurm.ParseAssociations(associations)))
+}
+
+func TestSyncAndApply(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Policies.Rules = []config.PolicyConfig{
+		{Name: "students", QOS: []string{"student"}, MonthlyCeilingUSD: 50},
+		{Name: "science", ParentAccounts: []string{"science"}},
+		{Name: "alice", Users: []string{"alice"}},
+	}
+	cfg.Policies.Sync = config.PolicySyncConfig{
+		Enabled:         true,
+		Source:          config.PolicySyncCommand,
+		Command:         "printf '" + associations + "'",
+		IntervalMinutes: 60,
+		StateFile:       filepath.Join(t.TempDir(), "spool", "policy-identities.json"),
+	}
+	assert.Error(t, Apply(cfg), "not synced yet")
+	assert.Nil(t, cfg.Policies.For("bob", "chem-class"))
+
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	snapshot, err := Sync(context.Background(), cfg, nil, now)
+	require.NoError(t, err)
+	assert.Equal(t, 9, snapshot.Associations)
+	assert.False(t, Due(snapshot, &cfg.Policies.Sync, now.Add(59*time.Minute)))
+	assert.True(t, Due(snapshot, &cfg.Policies.Sync, now.Add(time.Hour)))
+
+	loaded, err := Load(cfg.Policies.Sync.StateFile)
+	require.NoError(t, err)
+	assert.Equal(t, snapshot.Rules, loaded.Rules)
+
+	require.NoError(t, Apply(cfg))
+	assert.Equal(t, "students", cfg.Policies.For("bob", "chem-class").Name, "synced users before synced accounts")
+	assert.Equal(t, "science", cfg.Policies.For("erin", "chem-class").Name)
+	assert.Equal(t, "alice", cfg.Policies.For("alice", "chem").Name, "named users first")
+	assert.Nil(t, cfg.Policies.For("frank", "physics"))
+	assert.Contains(t, cfg.SpendLimits(), config.CeilingConfig{User: "carol", MonthlyUSD: 50})
+
+	cfg.Policies.Sync.Command = "true"
+	_, err = Sync(context.Background(), cfg, nil, now)
+	assert.Error(t, err, "an empty association tree is not saved")
+	loaded, err = Load(cfg.Policies.Sync.StateFile)
+	require.NoError(t, err)
+	assert.Equal(t, snapshot.Rules, loaded.Rules)
+}
@@ -0,0 +1,97 @@
+// Package prediction keeps the execution plan ASBA chose for each job and
+// scores it against what the job actually did
+package prediction
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Record is the plan resume launched a job with, as predicted before the job ran
+type Record struct {
+	JobID            string               `json:"job_id"`
+	RecordedAt       time.Time            `json:"recorded_at"`
+	TraceID          string               `json:"trace_id,omitempty"`
+	NodeCount        int                  `json:"node_count"`
+	EstimatedCostUSD float64              `json:"estimated_cost_usd"`
+	Plan             *types.ExecutionPlan `json:"plan"`
+}
+
+// Save keeps the record in the data exchange directory, replacing any earlier
+// record for the job
+func Save(exchangeDir string, record *Record) (string, error) {
+	if record.JobID == "" {
+		return "", fmt.Errorf("plan record has no job ID")
+	}
+	return exchange.Write(exchangeDir, exchange.Records, record.JobID, record)
+}
+
+// Load reads the record for a job. A job without a record returns nil and no error.
+func Load(exchangeDir, jobID string) (*Record, error) {
+	var record Record
+	found, err := exchange.Read(exchangeDir, exchange.Records, jobID, &record)
+	if err != nil || !found {
+		return nil, err
+	}
+	if record.Plan == nil {
+		return nil, fmt.Errorf("plan record for job %s has no plan", jobID)
+	}
+	return &record, nil
+}
+
+// Validate scores the recorded plan against the job's actual execution.
+// Runtime is the plan's expected duration against elapsed time. Cost is the
+// plan's estimate against the recorded actual cost or, without one, the plan's
+// hourly rate over the nodes and time actually used. Instance types are correct
+// when every type launched was one the plan named. The workload class is
+// correct when the plan's MPI call matches whether the job ran MPI.
+func Validate(record *Record, actual types.ActualExecution, ranMPI bool) types.PredictionValidation {
+	plan := record.Plan
+	validation := types.PredictionValidation{Validated: true}
+
+	elapsedHours := time.Duration(actual.ExecutionDuration).Hours()
+	validation.RuntimeAccuracy = accuracy(plan.CostConstraints.MaxDurationHours, elapsedHours)
+
+	actualCost := actual.ActualCostUSD
+	if actualCost <= 0 {
+		actualCost = plan.GetCostEstimate(actual.NodeCount, elapsedHours)
+	}
+	validation.CostAccuracy = accuracy(record.EstimatedCostUSD, actualCost)
+
+	validation.InstanceTypeOptimal = len(actual.InstanceTypesUsed) > 0
+	for _, instanceType := range actual.InstanceTypesUsed {
+		if !contains(plan.InstanceSpec.InstanceTypes, instanceType) {
+			validation.InstanceTypeOptimal = false
+		}
+	}
+	validation.DomainDetectionCorrect = plan.MPIConfig.IsMPIJob == ranMPI
+
+	instanceScore := 0.0
+	if validation.InstanceTypeOptimal {
+		instanceScore = 1
+	}
+	validation.OverallAccuracyScore = (validation.CostAccuracy + validation.RuntimeAccuracy + instanceScore) / 3
+	return validation
+}
+
+// accuracy is 1 minus the relative error against the larger of the two values,
+// so over- and under-prediction by the same factor score the same
+func accuracy(predicted, actual float64) float64 {
+	if predicted <= 0 || actual <= 0 {
+		return 0
+	}
+	return 1 - math.Abs(predicted-actual)/math.Max(predicted, actual)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
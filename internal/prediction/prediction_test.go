@@ -0,0 +1,80 @@
+package prediction
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func testRecord() *Record {
+	return &Record{
+		JobID:            "42",
+		NodeCount:        4,
+		EstimatedCostUSD: 8,
+		Plan: &types.ExecutionPlan{
+			InstanceSpec:    types.InstanceSpecification{InstanceTypes: []string{"hpc7a.2xlarge", "c6i.xlarge"}},
+			MPIConfig:       types.MPIConfiguration{IsMPIJob: true},
+			CostConstraints: types.CostConstraints{MaxDurationHours: 2, MaxCostPerHour: 1},
+		},
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	path, err := Save(dir, testRecord())
+	require.NoError(t, err)
+	assert.Equal(t, exchange.Path(dir, exchange.Records, "42"), path)
+
+	record, err := Load(dir, "42")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, 8.0, record.EstimatedCostUSD)
+	assert.Equal(t, []string{"hpc7a.2xlarge", "c6i.xlarge"}, record.Plan.InstanceSpec.InstanceTypes)
+
+	missing, err := Load(dir, "43")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	_, err = Save(dir, &Record{Plan: &types.ExecutionPlan{}})
+	assert.Error(t, err, "records need a job ID")
+
+	require.NoError(t, os.WriteFile(exchange.Path(dir, exchange.Records, "44"), []byte(`{"job_id":"44"}`), 0644))
+	_, err = Load(dir, "44")
+	assert.Error(t, err, "records need a plan")
+}
+
+func TestValidate(t *testing.T) {
+	actual := types.ActualExecution{
+		InstanceTypesUsed: []string{"hpc7a.2xlarge"},
+		ExecutionDuration: types.Duration(4 * time.Hour),
+		NodeCount:         4,
+	}
+
+	validation := Validate(testRecord(), actual, true)
+	assert.True(t, validation.Validated)
+	assert.InDelta(t, 0.5, validation.RuntimeAccuracy, 1e-9, "predicted 2h, ran 4h")
+	assert.InDelta(t, 0.5, validation.CostAccuracy, 1e-9, "$8 predicted, $16 at the planned rate")
+	assert.True(t, validation.InstanceTypeOptimal)
+	assert.True(t, validation.DomainDetectionCorrect)
+	assert.InDelta(t, 2.0/3, validation.OverallAccuracyScore, 1e-9)
+
+	// A recorded actual cost wins over the planned rate
+	actual.ActualCostUSD = 10
+	assert.InDelta(t, 0.8, Validate(testRecord(), actual, true).CostAccuracy, 1e-9)
+
+	// A capacity fallback to an unplanned type and a non-MPI run are mispredictions
+	actual.InstanceTypesUsed = []string{"hpc7a.2xlarge", "m6i.xlarge"}
+	validation = Validate(testRecord(), actual, false)
+	assert.False(t, validation.InstanceTypeOptimal)
+	assert.False(t, validation.DomainDetectionCorrect)
+
+	// Unknown runtime scores zero rather than perfect
+	assert.Zero(t, Validate(testRecord(), types.ActualExecution{}, true).RuntimeAccuracy)
+}
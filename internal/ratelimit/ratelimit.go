@@ -0,0 +1,201 @@
+// Package ratelimit caps how many nodes are launched or terminated per minute.
+// Slurm runs a resume or suspend program per batch of nodes, so the budget is
+// shared between processes through a state file under an advisory lock.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/filelock"
+)
+
+// state is the token bucket persisted between processes
+type state struct {
+	Tokens  float64   `json:"tokens"` // Negative while callers wait for reserved nodes
+	Updated time.Time `json:"updated"`
+}
+
+// Limiter is a token bucket of perMinute nodes that refills continuously. A
+// caller reserves its nodes and sleeps until the bucket has covered them, so
+// batches larger than a minute's budget still go through, just later.
+type Limiter struct {
+	dir       string // Empty keeps the bucket in memory only
+	name      string
+	perMinute int // Zero disables the limiter
+	now       func() time.Time
+	sleep     func(ctx context.Context, d time.Duration) error
+
+	mu    sync.Mutex
+	local *state
+}
+
+// New creates a limiter for perMinute nodes sharing its bucket through
+// dir/<name>.json. A perMinute of zero never waits.
+func New(dir, name string, perMinute int) *Limiter {
+	return &Limiter{dir: dir, name: name, perMinute: perMinute, now: time.Now, sleep: sleepContext}
+}
+
+// Burst is the most nodes one batch should hold: a minute's budget, or zero
+// when the limiter is disabled
+func (l *Limiter) Burst() int {
+	if l == nil {
+		return 0
+	}
+	return l.perMinute
+}
+
+// Wait reserves n nodes and blocks until the budget covers them or ctx ends.
+// A wait that would outlast ctx's deadline is refused without reserving, and
+// a wait cut short by ctx gives its nodes back, so an abandoned caller leaves
+// no debt for the next. A nil limiter never waits.
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	if l == nil || l.perMinute <= 0 || n <= 0 {
+		return nil
+	}
+
+	deadline, _ := ctx.Deadline()
+	delay, err := l.reserve(n, deadline)
+	if err != nil {
+		return err
+	}
+	if delay <= 0 {
+		return nil
+	}
+	if err := l.sleep(ctx, delay); err != nil {
+		l.update(func(bucket *state) { bucket.Tokens += float64(n) })
+		return fmt.Errorf("waiting %s for %s rate limit: %w", delay.Round(time.Second), l.name, err)
+	}
+	return nil
+}
+
+// reserve takes n tokens and returns how long until the bucket is no longer
+// in debt. It takes nothing and fails when that is past a non-zero deadline.
+func (l *Limiter) reserve(n int, deadline time.Time) (time.Duration, error) {
+	ratePerSecond := float64(l.perMinute) / 60
+	var delay time.Duration
+	var err error
+	l.update(func(bucket *state) {
+		delay = 0
+		if tokens := bucket.Tokens - float64(n); tokens < 0 {
+			delay = time.Duration(-tokens / ratePerSecond * float64(time.Second))
+		}
+		if !deadline.IsZero() && bucket.Updated.Add(delay).After(deadline) {
+			err = fmt.Errorf("%s rate limit needs %s for %d nodes, beyond the deadline", l.name, delay.Round(time.Second), n)
+			return
+		}
+		bucket.Tokens -= float64(n)
+	})
+	return delay, err
+}
+
+// update refills the bucket up to now, applies change and saves it
+func (l *Limiter) update(change func(bucket *state)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	unlock, bucket := l.load(now)
+	if unlock != nil {
+		defer unlock()
+	}
+
+	ratePerSecond := float64(l.perMinute) / 60
+	if elapsed := now.Sub(bucket.Updated).Seconds(); elapsed > 0 {
+		bucket.Tokens += elapsed * ratePerSecond
+	}
+	if bucket.Tokens > float64(l.perMinute) {
+		bucket.Tokens = float64(l.perMinute)
+	}
+	bucket.Updated = now
+	change(bucket)
+
+	if unlock == nil || l.save(bucket) != nil {
+		l.local = bucket
+	}
+}
+
+// load locks and reads the shared bucket. Without a usable directory it
+// returns a nil unlock and this process's own bucket; throttling must never
+// be the reason a launch fails.
+func (l *Limiter) load(now time.Time) (func(), *state) {
+	fallback := func() (func(), *state) {
+		if l.local == nil {
+			l.local = &state{Tokens: float64(l.perMinute), Updated: now}
+		}
+		return nil, l.local
+	}
+	if l.dir == "" {
+		return fallback()
+	}
+
+	unlock, err := filelock.Lock(filepath.Join(l.dir, l.name+".lock"), 2*time.Second)
+	if err != nil {
+		return fallback()
+	}
+
+	bucket := &state{Tokens: float64(l.perMinute), Updated: now}
+	data, err := os.ReadFile(l.path())
+	if err == nil {
+		if err := json.Unmarshal(data, bucket); err != nil {
+			bucket = &state{Tokens: float64(l.perMinute), Updated: now}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		unlock()
+		return fallback()
+	}
+	return unlock, bucket
+}
+
+// save writes the shared bucket; the caller holds the lock
+func (l *Limiter) save(bucket *state) error {
+	data, err := json.Marshal(bucket)
+	if err != nil {
+		return err
+	}
+	tmp := l.path() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path())
+}
+
+func (l *Limiter) path() string {
+	return filepath.Join(l.dir, l.name+".json")
+}
+
+// Chunks splits nodes into batches of at most size; a size of zero or less
+// keeps them in one batch
+func Chunks(nodes []string, size int) [][]string {
+	if size <= 0 || len(nodes) <= size {
+		return [][]string{nodes}
+	}
+
+	var chunks [][]string
+	for start := 0; start < len(nodes); start += size {
+		end := start + size
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+		chunks = append(chunks, nodes[start:end])
+	}
+	return chunks
+}
+
+// sleepContext waits for d unless ctx ends first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
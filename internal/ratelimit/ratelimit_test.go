@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock drives a limiter's time and records its sleeps
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) install(l *Limiter) *Limiter {
+	l.now = func() time.Time { return c.now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		c.sleeps = append(c.sleeps, d)
+		c.now = c.now.Add(d)
+		return ctx.Err()
+	}
+	return l
+}
+
+func TestLimiterWait(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}
+	limiter := clock.install(New(t.TempDir(), "launch", 60))
+	ctx := context.Background()
+
+	// A minute's budget is available at once
+	require.NoError(t, limiter.Wait(ctx, 60))
+	assert.Empty(t, clock.sleeps)
+
+	// The next nodes wait for the bucket to refill at one per second
+	require.NoError(t, limiter.Wait(ctx, 30))
+	assert.Equal(t, []time.Duration{30 * time.Second}, clock.sleeps)
+
+	// Idle time refills the bucket, but never beyond a minute's budget
+	clock.now = clock.now.Add(10 * time.Minute)
+	clock.sleeps = nil
+	require.NoError(t, limiter.Wait(ctx, 90))
+	assert.Equal(t, []time.Duration{30 * time.Second}, clock.sleeps)
+}
+
+func TestLimiterSharedBetweenProcesses(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}
+	first := clock.install(New(dir, "terminate", 100))
+	second := clock.install(New(dir, "terminate", 100))
+
+	require.NoError(t, first.Wait(context.Background(), 100))
+	require.NoError(t, second.Wait(context.Background(), 50))
+	assert.Equal(t, []time.Duration{30 * time.Second}, clock.sleeps, "the second limiter sees the first one's reservation")
+}
+
+func TestLimiterDisabledAndCancelled(t *testing.T) {
+	var nilLimiter *Limiter
+	assert.NoError(t, nilLimiter.Wait(context.Background(), 1000))
+	assert.Equal(t, 0, nilLimiter.Burst())
+	assert.NoError(t, New("", "launch", 0).Wait(context.Background(), 1000))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter := New("", "launch", 1)
+	require.NoError(t, limiter.Wait(ctx, 1))
+	assert.ErrorContains(t, limiter.Wait(ctx, 1), "launch rate limit")
+}
+
+func TestLimiterLeavesNoDebt(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := clock.install(New(t.TempDir(), "terminate", 60))
+	require.NoError(t, limiter.Wait(context.Background(), 60))
+
+	// A wait past the deadline is refused up front
+	ctx, cancel := context.WithDeadline(context.Background(), clock.now.Add(10*time.Second))
+	defer cancel()
+	assert.ErrorContains(t, limiter.Wait(ctx, 30), "beyond the deadline")
+	assert.Empty(t, clock.sleeps)
+
+	// A cancelled wait gives its nodes back
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	assert.Error(t, limiter.Wait(cancelled, 30))
+
+	clock.sleeps = nil
+	require.NoError(t, limiter.Wait(context.Background(), 30))
+	assert.Empty(t, clock.sleeps, "the time the cancelled wait slept refilled the bucket, and it gave its nodes back")
+}
+
+func TestChunks(t *testing.T) {
+	nodes := []string{"a", "b", "c", "d", "e"}
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, Chunks(nodes, 2))
+	assert.Equal(t, [][]string{nodes}, Chunks(nodes, 5))
+	assert.Equal(t, [][]string{nodes}, Chunks(nodes, 0))
+}
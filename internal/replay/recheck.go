@@ -0,0 +1,157 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+)
+
+// CapacityChecker answers the questions a capacity re-check asks EC2;
+// aws.Diagnostics implements it
+type CapacityChecker interface {
+	OfferedInstanceTypes(ctx context.Context, region string, instanceTypes []string) (map[string]bool, error)
+	SubnetFreeIPs(ctx context.Context, region string, subnetIDs []string) (map[string]int, error)
+	DryRunFleet(ctx context.Context, region string, nodeGroup *config.NodeGroupConfig) error
+}
+
+// Check is whether one node group of a past resume could launch its nodes
+// now
+type Check struct {
+	Partition     string         `json:"partition"`
+	NodeGroup     string         `json:"node_group"`
+	Region        string         `json:"region"`
+	Nodes         int            `json:"nodes"`
+	InstanceTypes []string       `json:"instance_types"`
+	SubnetFreeIPs map[string]int `json:"subnet_free_ips,omitempty"`
+	Problems      []string       `json:"problems,omitempty"`
+}
+
+// Recheck runs the resume dry-run checks again, against current EC2, for
+// every node group the timeline launched or failed to launch: are the
+// instance types still offered, do the subnets have addresses for the nodes,
+// and would EC2 accept the fleet request
+func Recheck(ctx context.Context, checker CapacityChecker, cfg *config.Config, timeline *Timeline) ([]Check, error) {
+	type groupKey struct{ partition, nodeGroup string }
+	nodes := make(map[groupKey]int)
+	launchedTypes := make(map[groupKey][]string)
+
+	add := func(nodeName, instanceType string) {
+		partition, nodeGroup, _, ok := cfg.Slurm.NodeNaming.ParseNodeName(nodeName)
+		if !ok {
+			return
+		}
+		key := groupKey{partition, nodeGroup}
+		nodes[key]++
+		if instanceType != "" && !contains(launchedTypes[key], instanceType) {
+			launchedTypes[key] = append(launchedTypes[key], instanceType)
+		}
+	}
+	for _, instance := range timeline.Result.LaunchedInstances {
+		add(instance.NodeName, instance.InstanceType)
+	}
+	for _, failure := range timeline.Result.FailedInstances {
+		add(failure.NodeName, failure.InstanceType)
+	}
+
+	keys := make([]groupKey, 0, len(nodes))
+	for key := range nodes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].partition != keys[j].partition {
+			return keys[i].partition < keys[j].partition
+		}
+		return keys[i].nodeGroup < keys[j].nodeGroup
+	})
+
+	var checks []Check
+	for _, key := range keys {
+		nodeGroup := cfg.FindNodeGroup(key.partition, key.nodeGroup)
+		if nodeGroup == nil {
+			return nil, fmt.Errorf("node group %s/%s is no longer configured", key.partition, key.nodeGroup)
+		}
+
+		check := Check{Partition: key.partition, NodeGroup: key.nodeGroup, Region: nodeGroup.Region, Nodes: nodes[key]}
+		if check.Region == "" {
+			check.Region = cfg.AWS.Region
+		}
+
+		// The plan's types are what resume asked for; what launched is a subset
+		switch {
+		case timeline.Plan != nil && len(timeline.Plan.InstanceSpec.InstanceTypes) > 0:
+			check.InstanceTypes = timeline.Plan.InstanceSpec.InstanceTypes
+		case len(launchedTypes[key]) > 0:
+			check.InstanceTypes = launchedTypes[key]
+		default:
+			for _, override := range nodeGroup.LaunchTemplateOverrides {
+				check.InstanceTypes = append(check.InstanceTypes, override.InstanceType)
+			}
+		}
+
+		subnetIDs := nodeGroup.SubnetIds
+		if timeline.Plan != nil && len(timeline.Plan.InstanceSpec.SubnetIds) > 0 {
+			subnetIDs = timeline.Plan.InstanceSpec.SubnetIds
+		}
+
+		check.Problems = append(check.Problems, checkOffered(ctx, checker, check.Region, check.InstanceTypes)...)
+
+		freeIPs, err := checker.SubnetFreeIPs(ctx, check.Region, subnetIDs)
+		if err != nil {
+			check.Problems = append(check.Problems, fmt.Sprintf("cannot read subnets: %v", err))
+		} else {
+			check.SubnetFreeIPs = freeIPs
+			total := 0
+			for _, subnetID := range subnetIDs {
+				free, ok := freeIPs[subnetID]
+				if !ok {
+					check.Problems = append(check.Problems, "subnet "+subnetID+" not found")
+				}
+				total += free
+			}
+			if total < check.Nodes {
+				check.Problems = append(check.Problems, fmt.Sprintf("subnets have %d free addresses for %d nodes", total, check.Nodes))
+			}
+		}
+
+		if err := checker.DryRunFleet(ctx, check.Region, nodeGroup); err != nil {
+			check.Problems = append(check.Problems, fmt.Sprintf("fleet dry run failed: %v", err))
+		}
+
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// checkOffered reports instance types no longer offered in the region
+func checkOffered(ctx context.Context, checker CapacityChecker, region string, instanceTypes []string) []string {
+	if len(instanceTypes) == 0 {
+		return nil
+	}
+
+	offered, err := checker.OfferedInstanceTypes(ctx, region, instanceTypes)
+	if err != nil {
+		return []string{fmt.Sprintf("cannot read instance type offerings: %v", err)}
+	}
+	var missing []string
+	for _, instanceType := range instanceTypes {
+		if _, ok := offered[instanceType]; !ok {
+			missing = append(missing, instanceType)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return []string{"not offered: " + strings.Join(missing, ",")}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
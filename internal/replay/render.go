@@ -0,0 +1,55 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Render writes the timeline as text, one step per line with its offset from
+// the start of the resume, followed by any capacity re-checks
+func Render(w io.Writer, timeline *Timeline, checks []Check) error {
+	result := timeline.Result
+
+	fmt.Fprintf(w, "Job %s, trace %s, %s mode\n", orUnknown(timeline.JobID), orUnknown(result.TraceID), orUnknown(result.ExecutionMode))
+	if !result.ExecutionStartTime.IsZero() {
+		fmt.Fprintf(w, "Started %s, took %s\n", result.ExecutionStartTime.Format(time.RFC3339), time.Duration(result.ExecutionDuration))
+	}
+	fmt.Fprintln(w)
+
+	derived := false
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, event := range timeline.Events {
+		offset := event.Time.Sub(result.ExecutionStartTime).Round(100 * time.Millisecond)
+		summary := event.Summary
+		if len(event.Nodes) > 0 {
+			summary = strings.Join(event.Nodes, ",") + ": " + summary
+		}
+		if event.Derived {
+			summary += " *"
+			derived = true
+		}
+		fmt.Fprintf(tw, "+%s\t%s\t%s\n", offset, event.Kind, summary)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if derived {
+		fmt.Fprintln(w, "\n* implied by the result rather than recorded")
+	}
+
+	if len(checks) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "\nCapacity now:")
+	for _, check := range checks {
+		status := "ok"
+		if len(check.Problems) > 0 {
+			status = strings.Join(check.Problems, "; ")
+		}
+		fmt.Fprintf(w, "  %s/%s in %s (%d nodes): %s\n", check.Partition, check.NodeGroup, check.Region, check.Nodes, status)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+// Package replay reconstructs the timeline of a past resume from the
+// execution result it left in the data exchange directory, and the plan
+// recorded for the same job, for post-mortems
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/prediction"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Event kinds, in the order a resume produces them
+const (
+	KindStart    = "start"
+	KindPlan     = "plan"
+	KindCapacity = "capacity"
+	KindFallback = "fallback"
+	KindLaunch   = "launch"
+	KindFailure  = "failure"
+	KindError    = "error"
+	KindSlurm    = "slurm"
+	KindFinish   = "finish"
+)
+
+// Event is one step of a resume
+type Event struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Summary string    `json:"summary"`
+	Nodes   []string  `json:"nodes,omitempty"`
+	// Derived is set on events the result implies but does not record, such
+	// as the Slurm updates resume makes after launching
+	Derived bool `json:"derived,omitempty"`
+}
+
+// Timeline is a past resume, step by step
+type Timeline struct {
+	JobID  string                 `json:"job_id,omitempty"`
+	Plan   *types.ExecutionPlan   `json:"plan,omitempty"`
+	Result *types.ExecutionResult `json:"result"`
+	Events []Event                `json:"events"`
+}
+
+// resultFilePattern matches the file names exchange.Path gives job files
+var resultFilePattern = regexp.MustCompile(`^job-(.+)\.json$`)
+
+// Load reads an execution result and builds its timeline. The plan is read
+// from planPath, which may hold an execution plan or a prediction record, or
+// when planPath is empty and the result sits in a data exchange directory,
+// from the record kept there for the same job.
+func Load(resultPath, planPath string) (*Timeline, error) {
+	data, err := os.ReadFile(resultPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read execution result: %w", err)
+	}
+	var result types.ExecutionResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse execution result %s: %w", resultPath, err)
+	}
+
+	// Results name their job; older ones are only named by their file
+	jobID := result.JobID
+	if matches := resultFilePattern.FindStringSubmatch(filepath.Base(resultPath)); jobID == "" && matches != nil && !strings.HasPrefix(matches[1], "trace-") {
+		jobID = matches[1]
+	}
+
+	var plan *types.ExecutionPlan
+	switch {
+	case planPath != "":
+		if plan, err = loadPlan(planPath); err != nil {
+			return nil, err
+		}
+	case jobID != "" && filepath.Base(filepath.Dir(resultPath)) == string(exchange.Results):
+		record, err := prediction.Load(filepath.Dir(filepath.Dir(resultPath)), jobID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plan record for job %s: %w", jobID, err)
+		}
+		if record != nil {
+			plan = record.Plan
+		}
+	}
+
+	timeline := Build(&result, plan)
+	timeline.JobID = jobID
+	if timeline.JobID == "" && plan != nil {
+		timeline.JobID = plan.ExecutionMetadata.JobID
+	}
+	return timeline, nil
+}
+
+// loadPlan reads an execution plan, or the plan in a prediction record
+func loadPlan(path string) (*types.ExecutionPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read execution plan: %w", err)
+	}
+
+	var record prediction.Record
+	if err := json.Unmarshal(data, &record); err == nil && record.Plan != nil {
+		return record.Plan, nil
+	}
+	var plan types.ExecutionPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse execution plan %s: %w", path, err)
+	}
+	return &plan, nil
+}
+
+// Build orders what the result records into a timeline. Steps the result
+// keeps no time for are placed where resume takes them: the plan and spot
+// placement scores at the start, fallbacks before the launches, failed
+// nodes and Slurm updates at the end.
+func Build(result *types.ExecutionResult, plan *types.ExecutionPlan) *Timeline {
+	start, end := result.ExecutionStartTime, result.ExecutionEndTime
+	if end.IsZero() {
+		// Failed resumes return before recording their end
+		end = start
+		for _, executionErr := range result.Errors {
+			if executionErr.Timestamp.After(end) {
+				end = executionErr.Timestamp
+			}
+		}
+	}
+
+	events := []Event{{
+		Time:    start,
+		Kind:    KindStart,
+		Summary: fmt.Sprintf("Resume started in %s mode (trace %s)", orUnknown(result.ExecutionMode), orUnknown(result.TraceID)),
+	}}
+	if plan != nil {
+		events = append(events, Event{Time: start, Kind: KindPlan, Summary: describePlan(plan)})
+	}
+
+	zones := make([]string, 0, len(result.SpotPlacementScores))
+	for zone := range result.SpotPlacementScores {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		events = append(events, Event{
+			Time:    start,
+			Kind:    KindCapacity,
+			Summary: fmt.Sprintf("Spot placement score %d/10 in %s", result.SpotPlacementScores[zone], zone),
+		})
+	}
+
+	for _, fallback := range result.Fallbacks {
+		outcome := "succeeded"
+		if !fallback.Succeeded {
+			outcome = "failed"
+			if fallback.Error != "" {
+				outcome += ": " + fallback.Error
+			}
+		}
+		events = append(events, Event{
+			Time: start,
+			Kind: KindFallback,
+			Summary: fmt.Sprintf("Retried %s/%s in %s as %s (%s) after %s, %s",
+				fallback.Partition, fallback.NodeGroup, fallback.Region, fallback.Stage,
+				fallback.PurchaseOption, fallback.Reason, outcome),
+		})
+	}
+
+	for _, instance := range result.LaunchedInstances {
+		// EC2 reports launch times to the second, so they may round to before the start
+		launched := start
+		if parsed, err := time.Parse(time.RFC3339, instance.LaunchTime); err == nil && parsed.After(start) {
+			launched = parsed
+		}
+		events = append(events, Event{
+			Time:  launched,
+			Kind:  KindLaunch,
+			Nodes: []string{instance.NodeName},
+			Summary: fmt.Sprintf("Launched %s (%s, %s, %s) at %s",
+				instance.InstanceID, orUnknown(instance.InstanceType), orUnknown(instance.PurchaseOption),
+				orUnknown(instance.AvailabilityZone), orUnknown(instance.PrivateIP)),
+		})
+	}
+
+	for _, executionErr := range result.Errors {
+		at := executionErr.Timestamp
+		if at.IsZero() {
+			at = end
+		}
+		events = append(events, Event{
+			Time:    at,
+			Kind:    KindError,
+			Summary: fmt.Sprintf("%s error: %s", executionErr.Type, executionErr.Message),
+		})
+	}
+
+	var failed []string
+	for _, failure := range result.FailedInstances {
+		failed = append(failed, failure.NodeName)
+		events = append(events, Event{
+			Time:    end,
+			Kind:    KindFailure,
+			Nodes:   []string{failure.NodeName},
+			Summary: "Launch failed: " + failure.ErrorMessage,
+		})
+	}
+
+	// Resume only updates Slurm once the launch as a whole succeeded
+	if result.Success {
+		for _, instance := range result.LaunchedInstances {
+			events = append(events, Event{
+				Time:    end,
+				Kind:    KindSlurm,
+				Nodes:   []string{instance.NodeName},
+				Summary: fmt.Sprintf("Set NodeAddr and NodeHostname to %s", instance.PrivateIP),
+				Derived: true,
+			})
+		}
+		if len(failed) > 0 {
+			events = append(events, Event{
+				Time:    end,
+				Kind:    KindSlurm,
+				Nodes:   failed,
+				Summary: "Marked DOWN so Slurm requeues their jobs",
+				Derived: true,
+			})
+		}
+	}
+
+	outcome := "failed"
+	if result.Success {
+		outcome = "succeeded"
+	}
+	events = append(events, Event{
+		Time: end,
+		Kind: KindFinish,
+		Summary: fmt.Sprintf("Resume %s: %d launched, %d failed, estimated cost $%.2f",
+			outcome, len(result.LaunchedInstances), len(result.FailedInstances), result.TotalCostEstimate),
+	})
+
+	// Steps at the same time keep the order resume takes them in
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return &Timeline{Plan: plan, Result: result, Events: events}
+}
+
+// describePlan summarizes what the plan asked for
+func describePlan(plan *types.ExecutionPlan) string {
+	summary := fmt.Sprintf("Plan: %s %s", orUnknown(plan.InstanceSpec.PurchasingOption), orUnknown(strings.Join(plan.InstanceSpec.InstanceTypes, ",")))
+	if !plan.ShouldBurst {
+		summary += ", should not burst"
+	}
+	if plan.MPIConfig.IsMPIJob {
+		summary += fmt.Sprintf(", MPI with %d processes", plan.MPIConfig.ProcessCount)
+		if plan.MPIConfig.RequiresGangScheduling {
+			summary += ", gang scheduled"
+		}
+	}
+	if plan.MPIConfig.RequiresEFA {
+		summary += ", EFA"
+	}
+	if plan.NetworkConfig.PlacementGroupType != "" {
+		summary += ", " + plan.NetworkConfig.PlacementGroupType + " placement"
+	}
+	if plan.CostConstraints.MaxTotalCost > 0 {
+		summary += fmt.Sprintf(", max cost $%.2f", plan.CostConstraints.MaxTotalCost)
+	}
+	return summary
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
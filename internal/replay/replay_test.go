@@ -0,0 +1,171 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/exchange"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/prediction"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+var started = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// partialResult launched aws-cpu-1 after falling back to on-demand, and
+// could not launch aws-gpu-1
+func partialResult() *types.ExecutionResult {
+	return &types.ExecutionResult{
+		Success: true,
+		LaunchedInstances: []types.InstanceInfo{{
+			NodeName: "aws-cpu-1", InstanceID: "i-1", InstanceType: "c6i.large", PrivateIP: "10.0.0.1",
+			AvailabilityZone: "us-east-1a", PurchaseOption: "on-demand", LaunchTime: started.Add(20 * time.Second).Format(time.RFC3339),
+		}},
+		FailedInstances:     []types.FailedInstance{{NodeName: "aws-gpu-1", ErrorMessage: "InsufficientInstanceCapacity"}},
+		Errors:              []types.ExecutionError{{Type: "aws_api", Message: "gpu launch failed", Timestamp: started.Add(30 * time.Second)}},
+		SpotPlacementScores: map[string]int{"us-east-1a": 3},
+		Fallbacks: []types.LaunchFallback{{
+			Stage: "on-demand", Reason: "InsufficientInstanceCapacity", Partition: "aws", NodeGroup: "cpu",
+			Region: "us-east-1", PurchaseOption: "on-demand", Succeeded: true,
+		}},
+		TotalCostEstimate:  1.5,
+		ExecutionStartTime: started,
+		ExecutionEndTime:   started.Add(40 * time.Second),
+		ExecutionDuration:  types.Duration(40 * time.Second),
+		ExecutionMode:      "asba",
+		TraceID:            "abc123",
+	}
+}
+
+func TestBuild(t *testing.T) {
+	plan := &types.ExecutionPlan{ShouldBurst: true, InstanceSpec: types.InstanceSpecification{
+		InstanceTypes: []string{"c6i.large"}, PurchasingOption: "spot",
+	}}
+	timeline := Build(partialResult(), plan)
+
+	var kinds []string
+	for _, event := range timeline.Events {
+		kinds = append(kinds, event.Kind)
+	}
+	assert.Equal(t, []string{KindStart, KindPlan, KindCapacity, KindFallback, KindLaunch, KindError,
+		KindFailure, KindSlurm, KindSlurm, KindFinish}, kinds)
+
+	slurmUpdate := timeline.Events[7]
+	assert.Equal(t, []string{"aws-cpu-1"}, slurmUpdate.Nodes)
+	assert.Contains(t, slurmUpdate.Summary, "10.0.0.1")
+	assert.True(t, slurmUpdate.Derived)
+	assert.Equal(t, []string{"aws-gpu-1"}, timeline.Events[8].Nodes, "failed nodes marked down")
+}
+
+func TestBuild_Failed(t *testing.T) {
+	result := partialResult()
+	result.Success = false
+	result.ExecutionEndTime = time.Time{}
+
+	timeline := Build(result, nil)
+	for _, event := range timeline.Events {
+		assert.NotEqual(t, KindSlurm, event.Kind, "a failed resume does not update Slurm")
+		assert.NotEqual(t, KindPlan, event.Kind)
+	}
+	finish := timeline.Events[len(timeline.Events)-1]
+	assert.Equal(t, KindFinish, finish.Kind)
+	assert.Equal(t, started.Add(30*time.Second), finish.Time, "ends with the last error")
+}
+
+func TestLoad_ExchangeDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, exchange.Init(dir))
+	path, err := exchange.WriteResult(dir, "1234", partialResult())
+	require.NoError(t, err)
+
+	timeline, err := Load(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, "1234", timeline.JobID)
+	assert.Nil(t, timeline.Plan, "no plan recorded")
+
+	_, err = prediction.Save(dir, &prediction.Record{JobID: "1234", Plan: &types.ExecutionPlan{MPIConfig: types.MPIConfiguration{IsMPIJob: true}}})
+	require.NoError(t, err)
+	timeline, err = Load(path, "")
+	require.NoError(t, err)
+	require.NotNil(t, timeline.Plan)
+	assert.True(t, timeline.Plan.MPIConfig.IsMPIJob)
+
+	_, err = Load(path, exchange.Path(dir, exchange.Records, "1234"))
+	require.NoError(t, err, "a plan record may be named explicitly")
+
+	path, err = exchange.WriteResult(dir, "trace-abc123", partialResult())
+	require.NoError(t, err)
+	timeline, err = Load(path, "")
+	require.NoError(t, err)
+	assert.Empty(t, timeline.JobID, "resumed for no known job")
+}
+
+func TestRender(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, Render(&output, Build(partialResult(), nil), []Check{{
+		Partition: "aws", NodeGroup: "gpu", Region: "us-east-1", Nodes: 1, Problems: []string{"not offered: p5.48xlarge"},
+	}}))
+
+	text := output.String()
+	assert.Contains(t, text, "trace abc123, asba mode")
+	assert.Contains(t, text, "+20s  launch")
+	assert.Contains(t, text, "aws-cpu-1: Set NodeAddr and NodeHostname to 10.0.0.1 *")
+	assert.Contains(t, text, "aws/gpu in us-east-1 (1 nodes): not offered: p5.48xlarge")
+}
+
+// fakeChecker offers c6i.large, gives subnet-a one free address and fails
+// dry runs for node group gpu
+type fakeChecker struct{}
+
+func (fakeChecker) OfferedInstanceTypes(ctx context.Context, region string, instanceTypes []string) (map[string]bool, error) {
+	return map[string]bool{"c6i.large": false}, nil
+}
+
+func (fakeChecker) SubnetFreeIPs(ctx context.Context, region string, subnetIDs []string) (map[string]int, error) {
+	return map[string]int{"subnet-a": 1}, nil
+}
+
+func (fakeChecker) DryRunFleet(ctx context.Context, region string, nodeGroup *config.NodeGroupConfig) error {
+	if nodeGroup.NodeGroupName == "gpu" {
+		return errors.New("UnauthorizedOperation")
+	}
+	return nil
+}
+
+func TestRecheck(t *testing.T) {
+	cfg := &config.Config{
+		AWS: config.AWSConfig{Region: "us-east-1"},
+		Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{{
+			PartitionName: "aws",
+			NodeGroups: []config.NodeGroupConfig{
+				{NodeGroupName: "cpu", SubnetIds: []string{"subnet-a"}},
+				{NodeGroupName: "gpu", Region: "us-west-2", SubnetIds: []string{"subnet-b"},
+					LaunchTemplateOverrides: []config.LaunchTemplateOverride{{InstanceType: "p5.48xlarge"}}},
+			},
+		}}},
+	}
+
+	checks, err := Recheck(context.Background(), fakeChecker{}, cfg, Build(partialResult(), nil))
+	require.NoError(t, err)
+	require.Len(t, checks, 2)
+
+	assert.Equal(t, "cpu", checks[0].NodeGroup)
+	assert.Equal(t, "us-east-1", checks[0].Region)
+	assert.Equal(t, []string{"c6i.large"}, checks[0].InstanceTypes, "the types that launched")
+	assert.Empty(t, checks[0].Problems)
+
+	assert.Equal(t, "us-west-2", checks[1].Region)
+	assert.Equal(t, []string{"p5.48xlarge"}, checks[1].InstanceTypes, "the configured types when nothing launched")
+	assert.Equal(t, []string{
+		"not offered: p5.48xlarge",
+		"subnet subnet-b not found",
+		"subnets have 0 free addresses for 1 nodes",
+		"fleet dry run failed: UnauthorizedOperation",
+	}, checks[1].Problems)
+}
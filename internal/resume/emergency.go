@@ -0,0 +1,57 @@
+package resume
+
+import (
+	"fmt"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/emergency"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"go.uber.org/zap"
+)
+
+// EnforceEmergencyStop drops the node groups of partitions under an emergency
+// stop recorded in stateFile and powers their nodes back down. A stop state
+// that cannot be read refuses every node group, since the stop may be in
+// force.
+func (g *Gate) EnforceEmergencyStop(stateFile string, groups []slurm.NodeGroupNodes) ([]slurm.NodeGroupNodes, error) {
+	state, err := emergency.Load(stateFile)
+	if err != nil {
+		if !g.DryRun {
+			for _, group := range groups {
+				if err := g.Slurm.SetNodesState(group.Nodes, "POWER_DOWN", emergency.NodeReason); err != nil {
+					g.Logger.Error("Failed to power down refused nodes", zap.Error(err))
+				}
+			}
+		}
+		return nil, fmt.Errorf("refusing to burst: %w", err)
+	}
+
+	var allowed []slurm.NodeGroupNodes
+	var refusal error
+	for _, group := range groups {
+		stop := state.Stopped(group.Partition)
+		if stop == nil {
+			allowed = append(allowed, group)
+			continue
+		}
+
+		refusal = fmt.Errorf("partition %s is under an emergency stop since %s (%s)",
+			group.Partition, stop.StoppedAt.Format("2006-01-02 15:04 MST"), stop.Reason)
+		g.Logger.Error("Refusing to burst",
+			zap.String("reason", emergency.NodeReason),
+			zap.String("stop_reason", stop.Reason),
+			zap.String("operator", stop.Operator),
+			zap.Strings("nodes", group.Nodes),
+			zap.Bool("dry_run", g.DryRun))
+		if g.DryRun {
+			continue
+		}
+		if err := g.Slurm.SetNodesState(group.Nodes, "POWER_DOWN", emergency.NodeReason); err != nil {
+			g.Logger.Error("Failed to power down refused nodes", zap.Error(err))
+		}
+	}
+
+	if len(allowed) == 0 && refusal != nil {
+		return nil, refusal
+	}
+	return allowed, nil
+}
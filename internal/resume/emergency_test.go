@@ -0,0 +1,101 @@
+package resume
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/emergency"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGate_EnforceEmergencyStop(t *testing.T) {
+	stopped := func(partition string) func(t *testing.T, path string) {
+		return func(t *testing.T, path string) {
+			require.NoError(t, emergency.Engage(path, emergency.Stop{
+				Partition: partition, Reason: "runaway cost", Operator: "ops", StoppedAt: time.Now(),
+			}))
+		}
+	}
+
+	tests := []struct {
+		name          string
+		setup         func(t *testing.T, path string) // Writes the stop state
+		dryRun        bool
+		expected      []string // Partitions left to launch
+		expectError   bool
+		expectPowered []string
+	}{
+		{
+			name:     "no stop state",
+			expected: []string{"aws", "gpu"},
+		},
+		{
+			name:          "one partition stopped",
+			setup:         stopped("gpu"),
+			expected:      []string{"aws"},
+			expectPowered: []string{"gpu-a100-1"},
+		},
+		{
+			name:          "every partition stopped",
+			setup:         stopped(""),
+			expectError:   true,
+			expectPowered: []string{"aws-cpu-1", "gpu-a100-1"},
+		},
+		{
+			name: "unreadable stop state refuses everything",
+			setup: func(t *testing.T, path string) {
+				require.NoError(t, os.Mkdir(path, 0755))
+			},
+			expectError:   true,
+			expectPowered: []string{"aws-cpu-1", "gpu-a100-1"},
+		},
+		{
+			name: "invalid stop state refuses everything",
+			setup: func(t *testing.T, path string) {
+				require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+			},
+			expectError:   true,
+			expectPowered: []string{"aws-cpu-1", "gpu-a100-1"},
+		},
+		{
+			name: "dry run refuses without powering down",
+			setup: func(t *testing.T, path string) {
+				require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+			},
+			dryRun:      true,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "emergency-stop.json")
+			if tt.setup != nil {
+				tt.setup(t, path)
+			}
+			groups := []slurm.NodeGroupNodes{
+				{Partition: "aws", NodeGroup: "cpu", Nodes: []string{"aws-cpu-1"}},
+				{Partition: "gpu", NodeGroup: "a100", Nodes: []string{"gpu-a100-1"}},
+			}
+
+			slurmClient := &fakeSlurm{}
+			allowed, err := newTestGate(t, slurmClient, tt.dryRun).EnforceEmergencyStop(path, groups)
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Empty(t, allowed)
+			} else {
+				require.NoError(t, err)
+				var partitions []string
+				for _, group := range allowed {
+					partitions = append(partitions, group.Partition)
+				}
+				assert.Equal(t, tt.expected, partitions)
+			}
+			assert.Equal(t, tt.expectPowered, slurmClient.poweredOff[emergency.NodeReason])
+		})
+	}
+}
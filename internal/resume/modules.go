@@ -0,0 +1,59 @@
+package resume
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/planmodule"
+	"go.uber.org/zap"
+)
+
+// planVetoedReason prefixes the Slurm reason on nodes powered down because a
+// plan module vetoed their launch
+const planVetoedReason = "plan_vetoed_"
+
+// ApplyPlanModules runs the plan modules on each standalone launch. Vetoed
+// launches are dropped and their nodes powered back down; modified plans
+// replace the generated ones. It fails when every launch is vetoed.
+func (g *Gate) ApplyPlanModules(ctx context.Context, modules []config.PlanModuleConfig, launches []Launch) ([]Launch, error) {
+	if len(modules) == 0 {
+		return launches, nil
+	}
+
+	var allowed []Launch
+	var refusal error
+	for _, launch := range launches {
+		outcome := planmodule.Apply(ctx, g.Logger, modules, planmodule.Request{
+			Partition: launch.Partition,
+			NodeGroup: launch.NodeGroup,
+			Nodes:     launch.Nodes,
+			Job:       launch.Job,
+			Plan:      launch.Plan,
+		})
+		if !outcome.Vetoed {
+			launch.Plan = outcome.Plan
+			allowed = append(allowed, launch)
+			continue
+		}
+
+		refusal = fmt.Errorf("plan module %s vetoed the launch of partition '%s' nodegroup '%s': %s",
+			outcome.Module, launch.Partition, launch.NodeGroup, outcome.Reason)
+		g.Logger.Error("Refusing to burst",
+			zap.String("reason", planVetoedReason+outcome.Module),
+			zap.String("detail", outcome.Reason),
+			zap.Strings("nodes", launch.Nodes),
+			zap.Bool("dry_run", g.DryRun))
+		if g.DryRun {
+			continue
+		}
+		if err := g.Slurm.SetNodesState(launch.Nodes, "POWER_DOWN", planVetoedReason+outcome.Module); err != nil {
+			g.Logger.Error("Failed to power down vetoed nodes", zap.Error(err))
+		}
+	}
+
+	if len(allowed) == 0 {
+		return nil, refusal
+	}
+	return allowed, nil
+}
@@ -0,0 +1,76 @@
+package resume
+
+import (
+	"context"
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGate_ApplyPlanModules(t *testing.T) {
+	// Vetoes the gpu node group's launches and accepts the rest
+	vetoGPU := config.PlanModuleConfig{
+		Name:           "quota",
+		Command:        `grep -q '"node_group":"gpu"' && echo '{"decision":"veto","reason":"no GPU quota"}' || echo '{"decision":"accept"}'`,
+		TimeoutSeconds: 5,
+		OnError:        config.PlanModuleOnErrorAccept,
+	}
+
+	tests := []struct {
+		name          string
+		modules       []config.PlanModuleConfig
+		launches      []Launch
+		dryRun        bool
+		expected      []string // Node groups left to launch
+		expectError   bool
+		expectPowered []string // Nodes powered down as vetoed
+	}{
+		{
+			name:     "no modules",
+			launches: []Launch{testLaunch("aws", "cpu"), testLaunch("aws", "gpu")},
+			expected: []string{"cpu", "gpu"},
+		},
+		{
+			name:          "veto drops one of two groups",
+			modules:       []config.PlanModuleConfig{vetoGPU},
+			launches:      []Launch{testLaunch("aws", "cpu"), testLaunch("aws", "gpu")},
+			expected:      []string{"cpu"},
+			expectPowered: []string{"aws-gpu-1"},
+		},
+		{
+			name:          "every group vetoed",
+			modules:       []config.PlanModuleConfig{vetoGPU},
+			launches:      []Launch{testLaunch("aws", "gpu")},
+			expectError:   true,
+			expectPowered: []string{"aws-gpu-1"},
+		},
+		{
+			name:     "dry run leaves vetoed nodes alone",
+			modules:  []config.PlanModuleConfig{vetoGPU},
+			launches: []Launch{testLaunch("aws", "cpu"), testLaunch("aws", "gpu")},
+			dryRun:   true,
+			expected: []string{"cpu"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slurmClient := &fakeSlurm{}
+			allowed, err := newTestGate(t, slurmClient, tt.dryRun).ApplyPlanModules(context.Background(), tt.modules, tt.launches)
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Empty(t, allowed)
+			} else {
+				require.NoError(t, err)
+				var nodeGroups []string
+				for _, launch := range allowed {
+					nodeGroups = append(nodeGroups, launch.NodeGroup)
+				}
+				assert.Equal(t, tt.expected, nodeGroups)
+			}
+			assert.Equal(t, tt.expectPowered, slurmClient.poweredOff[planVetoedReason+"quota"])
+		})
+	}
+}
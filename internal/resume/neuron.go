@@ -0,0 +1,56 @@
+package resume
+
+import (
+	"slices"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/scheduler"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// ApplyNeuronHints narrows a job written for the Neuron SDK to the node
+// group's Trainium or Inferentia instance types, and marks plans that launch
+// only Neuron instances so their nodes get Neuron setup
+func ApplyNeuronHints(logger *zap.Logger, plan *types.ExecutionPlan, job *types.SlurmJob) {
+	detector := scheduler.DistributedMLDetector{}
+	if detector.UsesNeuron(job) && !types.NeuronOnly(plan.InstanceSpec.InstanceTypes) {
+		families := detector.NeuronFamilies(job)
+		var instanceTypes []string
+		for _, instanceType := range plan.InstanceSpec.InstanceTypes {
+			if slices.Contains(families, types.InstanceFamilyOf(instanceType)) {
+				instanceTypes = append(instanceTypes, instanceType)
+			}
+		}
+		if len(instanceTypes) == 0 {
+			logger.Warn("Job uses the Neuron SDK but the node group has no matching instance types",
+				zap.String("job_id", job.JobID),
+				zap.Strings("instance_families", families))
+		} else {
+			plan.InstanceSpec.InstanceTypes = instanceTypes
+			plan.ExecutionMetadata.DecisionFactors = append(plan.ExecutionMetadata.DecisionFactors, "neuron_hints")
+		}
+	}
+
+	plan.MPIConfig.Accelerator = NeuronAccelerator(plan.InstanceSpec.InstanceTypes)
+	if plan.MPIConfig.Accelerator == "" {
+		return
+	}
+	plan.InstanceSpec.GPUs = 0
+	// Trainium nodes of one job exchange Neuron collectives over EFA
+	if job.Resources.Nodes >= 2 && types.KnownEFAFamily(types.InstanceFamilyOf(plan.InstanceSpec.InstanceTypes[0])) {
+		plan.MPIConfig.RequiresEFA = true
+	}
+	logger.Info("Launching Neuron instances for job",
+		zap.String("job_id", job.JobID),
+		zap.Strings("instance_types", plan.InstanceSpec.InstanceTypes),
+		zap.Bool("requires_efa", plan.MPIConfig.RequiresEFA))
+}
+
+// NeuronAccelerator returns AcceleratorNeuron when every instance type is a
+// Neuron one, or ""
+func NeuronAccelerator(instanceTypes []string) string {
+	if types.NeuronOnly(instanceTypes) {
+		return types.AcceleratorNeuron
+	}
+	return ""
+}
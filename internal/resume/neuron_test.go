@@ -0,0 +1,81 @@
+package resume
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestApplyNeuronHints(t *testing.T) {
+	mixed := []string{"p4d.24xlarge", "trn1.32xlarge", "inf2.xlarge"}
+
+	tests := []struct {
+		name          string
+		instanceTypes []string
+		script        string
+		nodes         int
+		expected      []string
+		accelerator   string
+		requiresEFA   bool
+	}{
+		{
+			name:          "CUDA job keeps every type",
+			instanceTypes: mixed,
+			script:        "#!/bin/bash\nsrun python train.py --cuda",
+			nodes:         2,
+			expected:      mixed,
+		},
+		{
+			name:          "Neuron training narrows to Trainium",
+			instanceTypes: mixed,
+			script:        "#!/bin/bash\npip install torch-neuronx\nsrun python train.py",
+			nodes:         2,
+			expected:      []string{"trn1.32xlarge"},
+			accelerator:   types.AcceleratorNeuron,
+			requiresEFA:   true,
+		},
+		{
+			name:          "single-node Neuron inference narrows to Inferentia",
+			instanceTypes: mixed,
+			script:        "#!/bin/bash\npython serve.py  # transformers-neuronx on inferentia",
+			nodes:         1,
+			expected:      []string{"inf2.xlarge"},
+			accelerator:   types.AcceleratorNeuron,
+		},
+		{
+			name:          "Neuron job without Neuron types keeps the configured ones",
+			instanceTypes: []string{"c6i.large", "c6i.xlarge"},
+			script:        "#!/bin/bash\npip install torch-neuronx",
+			nodes:         1,
+			expected:      []string{"c6i.large", "c6i.xlarge"},
+		},
+		{
+			name:          "Neuron-only node group is marked whatever the script",
+			instanceTypes: []string{"trn1.32xlarge"},
+			script:        "#!/bin/bash\nsrun ./a.out",
+			nodes:         1,
+			expected:      []string{"trn1.32xlarge"},
+			accelerator:   types.AcceleratorNeuron,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &types.ExecutionPlan{InstanceSpec: types.InstanceSpecification{InstanceTypes: tt.instanceTypes, GPUs: 8}}
+			job := &types.SlurmJob{JobID: "42", Script: tt.script, Resources: types.ResourceSpec{Nodes: tt.nodes}}
+
+			ApplyNeuronHints(zaptest.NewLogger(t), plan, job)
+
+			assert.Equal(t, tt.expected, plan.InstanceSpec.InstanceTypes)
+			assert.Equal(t, tt.accelerator, plan.MPIConfig.Accelerator)
+			assert.Equal(t, tt.requiresEFA, plan.MPIConfig.RequiresEFA)
+			if tt.accelerator != "" {
+				assert.Zero(t, plan.InstanceSpec.GPUs)
+			} else {
+				assert.Equal(t, 8, plan.InstanceSpec.GPUs)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package resume
+
+import (
+	"slices"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"go.uber.org/zap"
+)
+
+// ApplyJobOverrides applies the #ASBX pragmas of a standalone job's script:
+// the instance types it names that the node group has, a purchasing option
+// the site allows and, for spot or mixed purchasing, its spot price cap per
+// instance-hour
+func ApplyJobOverrides(logger *zap.Logger, overridesConfig *config.JobOverridesConfig, launch *Launch) {
+	if launch.Job == nil || launch.Job.Overrides == nil {
+		return
+	}
+	overrides, jobID := launch.Job.Overrides, launch.Job.JobID
+	if !overridesConfig.Enabled {
+		logger.Warn("Job script has #ASBX pragmas, but job_overrides is not enabled; they are ignored", zap.String("job_id", jobID))
+		return
+	}
+	for _, ignored := range overrides.Ignored {
+		logger.Warn("Ignoring #ASBX pragma", zap.String("job_id", jobID), zap.String("pragma", ignored))
+	}
+
+	plan := launch.Plan
+	if len(overrides.InstanceTypes) > 0 {
+		var instanceTypes []string
+		for _, instanceType := range overrides.InstanceTypes {
+			if slices.Contains(plan.InstanceSpec.InstanceTypes, instanceType) {
+				instanceTypes = append(instanceTypes, instanceType)
+			}
+		}
+		if len(instanceTypes) == 0 {
+			logger.Warn("None of the job's instance types are configured for the node group; launching the configured ones",
+				zap.String("job_id", jobID),
+				zap.Strings("requested", overrides.InstanceTypes),
+				zap.Strings("configured", plan.InstanceSpec.InstanceTypes))
+		} else {
+			plan.InstanceSpec.InstanceTypes = instanceTypes
+			plan.MPIConfig.Accelerator = NeuronAccelerator(instanceTypes)
+		}
+	}
+
+	if option := overrides.PurchasingOption; option != "" {
+		if overridesConfig.AllowsPurchasing(option) {
+			plan.InstanceSpec.PurchasingOption = option
+			plan.CostConstraints.PreferSpot = option == "spot"
+			plan.CostConstraints.AllowMixedPricing = option == "mixed"
+			// The priority's reservations and capacity queueing suit its own purchasing only
+			if launch.Purchasing != nil && launch.Purchasing.PurchasingOption != option {
+				launch.Purchasing = nil
+			}
+		} else {
+			logger.Warn("Job's purchasing option is not allowed; keeping the configured one",
+				zap.String("job_id", jobID),
+				zap.String("requested", option),
+				zap.Strings("allowed", overridesConfig.PurchasingOptions))
+		}
+	}
+
+	// Only the spot price can be capped; on-demand instances, including the
+	// on-demand part of mixed launches, are launched at their list price
+	purchasing := plan.InstanceSpec.PurchasingOption
+	if cost := overrides.MaxCostPerHour; cost > 0 && purchasing != "spot" && purchasing != "mixed" {
+		logger.Warn("Job's max cost applies only to spot and mixed purchasing; ignoring it",
+			zap.String("job_id", jobID),
+			zap.Float64("max_cost_per_hour", cost),
+			zap.String("purchasing", purchasing))
+	} else if cost > 0 {
+		if plan.InstanceSpec.MaxSpotPrice <= 0 || cost < plan.InstanceSpec.MaxSpotPrice {
+			plan.InstanceSpec.MaxSpotPrice = cost
+		}
+		// Mixed launches' on-demand instances may cost more, so only spot caps the estimate
+		if purchasing == "spot" {
+			plan.CostConstraints.MaxCostPerHour = cost
+		}
+	}
+	plan.ExecutionMetadata.DecisionFactors = append(plan.ExecutionMetadata.DecisionFactors, "job_overrides")
+
+	logger.Info("Applying job's #ASBX overrides",
+		zap.String("job_id", jobID),
+		zap.Strings("instance_types", plan.InstanceSpec.InstanceTypes),
+		zap.String("purchasing", plan.InstanceSpec.PurchasingOption),
+		zap.Float64("max_spot_price", plan.InstanceSpec.MaxSpotPrice))
+}
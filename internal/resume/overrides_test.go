@@ -0,0 +1,148 @@
+package resume
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestApplyJobOverrides(t *testing.T) {
+	enabled := config.JobOverridesConfig{Enabled: true, PurchasingOptions: []string{"spot", "on-demand"}}
+
+	tests := []struct {
+		name             string
+		config           config.JobOverridesConfig
+		overrides        *types.JobOverrides
+		purchasing       string  // Planned purchasing option
+		spotPrice        float64 // Planned spot price cap
+		expectTypes      []string
+		expectPurchasing string
+		expectSpotPrice  float64
+		expectMaxCost    float64
+		expectPriority   bool // The priority's purchasing is kept
+	}{
+		{
+			name:             "no pragmas",
+			config:           enabled,
+			purchasing:       "on-demand",
+			expectTypes:      []string{"c6i.large", "c6i.xlarge"},
+			expectPurchasing: "on-demand",
+			expectPriority:   true,
+		},
+		{
+			name:             "overrides not enabled",
+			overrides:        &types.JobOverrides{InstanceTypes: []string{"c6i.xlarge"}, PurchasingOption: "spot"},
+			purchasing:       "on-demand",
+			expectTypes:      []string{"c6i.large", "c6i.xlarge"},
+			expectPurchasing: "on-demand",
+			expectPriority:   true,
+		},
+		{
+			name:             "instance types the node group has",
+			config:           enabled,
+			overrides:        &types.JobOverrides{InstanceTypes: []string{"c6i.xlarge", "m6i.large"}},
+			purchasing:       "on-demand",
+			expectTypes:      []string{"c6i.xlarge"},
+			expectPurchasing: "on-demand",
+			expectPriority:   true,
+		},
+		{
+			name:             "instance types the node group lacks",
+			config:           enabled,
+			overrides:        &types.JobOverrides{InstanceTypes: []string{"m6i.large"}},
+			purchasing:       "on-demand",
+			expectTypes:      []string{"c6i.large", "c6i.xlarge"},
+			expectPurchasing: "on-demand",
+			expectPriority:   true,
+		},
+		{
+			name:             "allowed purchasing option",
+			config:           enabled,
+			overrides:        &types.JobOverrides{PurchasingOption: "spot"},
+			purchasing:       "on-demand",
+			expectTypes:      []string{"c6i.large", "c6i.xlarge"},
+			expectPurchasing: "spot",
+		},
+		{
+			name:             "purchasing option not allowed",
+			config:           enabled,
+			overrides:        &types.JobOverrides{PurchasingOption: "mixed"},
+			purchasing:       "on-demand",
+			expectTypes:      []string{"c6i.large", "c6i.xlarge"},
+			expectPurchasing: "on-demand",
+			expectPriority:   true,
+		},
+		{
+			name:             "max cost caps the spot price",
+			config:           enabled,
+			overrides:        &types.JobOverrides{PurchasingOption: "spot", MaxCostPerHour: 0.05},
+			purchasing:       "on-demand",
+			expectTypes:      []string{"c6i.large", "c6i.xlarge"},
+			expectPurchasing: "spot",
+			expectSpotPrice:  0.05,
+			expectMaxCost:    0.05,
+		},
+		{
+			name:             "max cost keeps a lower spot price",
+			config:           enabled,
+			overrides:        &types.JobOverrides{MaxCostPerHour: 0.05},
+			purchasing:       "spot",
+			spotPrice:        0.03,
+			expectTypes:      []string{"c6i.large", "c6i.xlarge"},
+			expectPurchasing: "spot",
+			expectSpotPrice:  0.03,
+			expectMaxCost:    0.05,
+			expectPriority:   true,
+		},
+		{
+			name:             "max cost ignored for on-demand",
+			config:           enabled,
+			overrides:        &types.JobOverrides{MaxCostPerHour: 0.05},
+			purchasing:       "on-demand",
+			expectTypes:      []string{"c6i.large", "c6i.xlarge"},
+			expectPurchasing: "on-demand",
+			expectPriority:   true,
+		},
+		{
+			name:             "max cost caps the spot part of mixed",
+			config:           config.JobOverridesConfig{Enabled: true, PurchasingOptions: []string{"mixed"}},
+			overrides:        &types.JobOverrides{PurchasingOption: "mixed", MaxCostPerHour: 0.05},
+			purchasing:       "spot",
+			spotPrice:        0.08,
+			expectTypes:      []string{"c6i.large", "c6i.xlarge"},
+			expectPurchasing: "mixed",
+			expectSpotPrice:  0.05,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priority := &config.PriorityPurchasingConfig{Priority: "normal", PurchasingOption: tt.purchasing}
+			launch := Launch{
+				Plan: &types.ExecutionPlan{InstanceSpec: types.InstanceSpecification{
+					InstanceTypes:    []string{"c6i.large", "c6i.xlarge"},
+					PurchasingOption: tt.purchasing,
+					MaxSpotPrice:     tt.spotPrice,
+				}},
+				Purchasing: priority,
+				Job:        &types.SlurmJob{JobID: "42", Overrides: tt.overrides},
+			}
+
+			ApplyJobOverrides(zaptest.NewLogger(t), &tt.config, &launch)
+
+			spec := launch.Plan.InstanceSpec
+			assert.Equal(t, tt.expectTypes, spec.InstanceTypes)
+			assert.Equal(t, tt.expectPurchasing, spec.PurchasingOption)
+			assert.Equal(t, tt.expectSpotPrice, spec.MaxSpotPrice)
+			assert.Equal(t, tt.expectMaxCost, launch.Plan.CostConstraints.MaxCostPerHour)
+			if tt.expectPriority {
+				assert.Same(t, priority, launch.Purchasing)
+			} else {
+				assert.Nil(t, launch.Purchasing)
+			}
+		})
+	}
+}
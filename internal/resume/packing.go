@@ -0,0 +1,28 @@
+package resume
+
+import (
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// ApplyJobPacking sizes a packed node group's instances for as many jobs
+// like the pending one as each node hosts, so later jobs fit beside it
+func ApplyJobPacking(logger *zap.Logger, nodeGroup *config.NodeGroupConfig, plan *types.ExecutionPlan, job *types.SlurmJob) {
+	if job == nil || nodeGroup == nil || !nodeGroup.JobPacking.Enabled() {
+		return
+	}
+
+	jobs := nodeGroup.JobPacking.JobsPerNode
+	plan.InstanceSpec.MinCPUs *= jobs
+	plan.InstanceSpec.MinMemoryMB *= jobs
+	plan.InstanceSpec.GPUs *= jobs
+	plan.ExecutionMetadata.DecisionFactors = append(plan.ExecutionMetadata.DecisionFactors, "job_packing")
+
+	logger.Info("Sizing instances for packed jobs",
+		zap.String("job_id", job.JobID),
+		zap.Int("jobs_per_node", jobs),
+		zap.Int("cpus_per_node", plan.InstanceSpec.MinCPUs),
+		zap.Int("memory_mb_per_node", plan.InstanceSpec.MinMemoryMB),
+		zap.Int("gpus", plan.InstanceSpec.GPUs))
+}
@@ -0,0 +1,70 @@
+package resume
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestApplyJobPacking(t *testing.T) {
+	packed := &config.NodeGroupConfig{NodeGroupName: "cpu", JobPacking: config.JobPackingConfig{JobsPerNode: 4}}
+
+	tests := []struct {
+		name        string
+		nodeGroup   *config.NodeGroupConfig
+		job         *types.SlurmJob
+		expectCPUs  int
+		expectMemMB int
+		expectGPUs  int
+		expectInfo  bool // job_packing among the decision factors
+	}{
+		{
+			name:        "packed node group",
+			nodeGroup:   packed,
+			job:         &types.SlurmJob{JobID: "42"},
+			expectCPUs:  8,
+			expectMemMB: 16384,
+			expectGPUs:  4,
+			expectInfo:  true,
+		},
+		{
+			name:        "one job per node",
+			nodeGroup:   &config.NodeGroupConfig{NodeGroupName: "cpu", JobPacking: config.JobPackingConfig{JobsPerNode: 1}},
+			job:         &types.SlurmJob{JobID: "42"},
+			expectCPUs:  2,
+			expectMemMB: 4096,
+			expectGPUs:  1,
+		},
+		{
+			name:        "no pending job",
+			nodeGroup:   packed,
+			expectCPUs:  2,
+			expectMemMB: 4096,
+			expectGPUs:  1,
+		},
+		{
+			name:        "node group not configured",
+			job:         &types.SlurmJob{JobID: "42"},
+			expectCPUs:  2,
+			expectMemMB: 4096,
+			expectGPUs:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &types.ExecutionPlan{InstanceSpec: types.InstanceSpecification{MinCPUs: 2, MinMemoryMB: 4096, GPUs: 1}}
+
+			ApplyJobPacking(zaptest.NewLogger(t), tt.nodeGroup, plan, tt.job)
+
+			assert.Equal(t, tt.expectCPUs, plan.InstanceSpec.MinCPUs)
+			assert.Equal(t, tt.expectMemMB, plan.InstanceSpec.MinMemoryMB)
+			assert.Equal(t, tt.expectGPUs, plan.InstanceSpec.GPUs)
+			assert.Equal(t, tt.expectInfo, slices.Contains(plan.ExecutionMetadata.DecisionFactors, "job_packing"))
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package resume
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/spend"
+	"go.uber.org/zap"
+)
+
+// EnforceBurstPolicy drops the launches that may not burst now: those the
+// policy rule of the job's user or account refuses, those outside every burst
+// window that applies to them or in a blackout, unless the job's QOS or
+// IgnoreWindows overrides the windows, and those whose partition or job
+// account or user has reached its monthly spend ceiling. Their nodes are
+// powered back down with the refusal as the reason. The launches left buy
+// capacity in a way the policy rule allows.
+func (g *Gate) EnforceBurstPolicy(ctx context.Context, cfg *config.Config, nodes []string, launches []Launch) ([]Launch, error) {
+	windows := &cfg.BurstWindows
+	limits := cfg.SpendLimits()
+	checkWindows := !g.IgnoreWindows && (len(windows.Windows) > 0 || len(windows.Blackouts) > 0)
+	checkCeilings := len(limits) > 0
+	if !checkWindows && !checkCeilings && len(cfg.Policies.Rules) == 0 {
+		return launches, nil
+	}
+
+	var user, account, qos string
+	if job, err := g.Slurm.GetJobForNodes(ctx, nodes); err == nil {
+		user, account, qos = job.User, job.Account, job.QOS
+	}
+	if checkWindows && windows.Overridden(qos) {
+		g.Logger.Info("Job QOS overrides burst windows", zap.String("qos", qos))
+		checkWindows = false
+	}
+	policy := cfg.Policies.For(user, account)
+	jobNodes := 0
+	if policy != nil {
+		g.Logger.Info("Job is under burst policy", zap.String("policy", policy.Name), zap.String("user", user), zap.String("account", account))
+		for _, launch := range launches {
+			jobNodes += len(launch.Nodes)
+		}
+	}
+
+	var allowed []Launch
+	var refusal error
+	for _, launch := range launches {
+		reason := ""
+		if policy != nil {
+			if refused, err := checkPolicy(policy, launch, jobNodes); refused != "" {
+				reason, refusal = refused, err
+			}
+		}
+		if reason == "" && checkWindows {
+			if refused := windows.Check(time.Now(), launch.Partition, account); refused != nil {
+				reason = refused.Reason()
+				refusal = fmt.Errorf("partition %s may not burst for account %q now (%s)", launch.Partition, account, reason)
+				if !refused.Until.IsZero() {
+					refusal = fmt.Errorf("partition %s may not burst for account %q until %s (%s)",
+						launch.Partition, account, refused.Until.Format("2006-01-02 15:04 MST"), reason)
+				}
+			}
+		}
+		if reason == "" && checkCeilings {
+			if lockout := g.checkCeiling(ctx, &cfg.Costs.Ceilings, limits, launch, account, user); lockout != nil {
+				reason = lockout.Reason()
+				refusal = fmt.Errorf("%s has reached its monthly spend ceiling of $%.2f ($%.2f spent in %s)",
+					lockout.Scope, lockout.CeilingUSD, lockout.SpentUSD, lockout.Month)
+			}
+		}
+		if reason == "" {
+			if policy != nil {
+				g.applyPolicyPurchasing(policy, &launch)
+			}
+			allowed = append(allowed, launch)
+			continue
+		}
+
+		g.Logger.Error("Refusing to burst",
+			zap.String("reason", reason),
+			zap.Strings("nodes", launch.Nodes),
+			zap.Bool("dry_run", g.DryRun))
+		if g.DryRun {
+			continue
+		}
+		if err := g.Slurm.SetNodesState(launch.Nodes, "POWER_DOWN", reason); err != nil {
+			g.Logger.Error("Failed to power down refused nodes", zap.Error(err))
+		}
+	}
+
+	if len(allowed) == 0 {
+		return nil, refusal
+	}
+	return allowed, nil
+}
+
+// checkPolicy returns the Slurm reason and error when a policy rule refuses a
+// launch: a node group the rule does not allow, or a job larger than its max
+// nodes
+func checkPolicy(policy *config.PolicyConfig, launch Launch, jobNodes int) (string, error) {
+	if !policy.AllowsNodeGroup(launch.Partition, launch.NodeGroup) {
+		return fmt.Sprintf("policy_%s_node_group", policy.Name),
+			fmt.Errorf("policy %s does not allow node group %s/%s", policy.Name, launch.Partition, launch.NodeGroup)
+	}
+	if policy.MaxNodes > 0 && jobNodes > policy.MaxNodes {
+		return fmt.Sprintf("policy_%s_max_nodes", policy.Name),
+			fmt.Errorf("policy %s allows at most %d nodes per job, the job needs %d", policy.Name, policy.MaxNodes, jobNodes)
+	}
+	return "", nil
+}
+
+// applyPolicyPurchasing switches a launch the policy rule does not let buy
+// capacity its planned way to the rule's first purchasing option
+func (g *Gate) applyPolicyPurchasing(policy *config.PolicyConfig, launch *Launch) {
+	plan := launch.Plan
+	current := plan.InstanceSpec.PurchasingOption
+	if current == "" {
+		current = "on-demand"
+	}
+	if policy.AllowsPurchasing(current) {
+		return
+	}
+
+	option := policy.PurchasingOptions[0]
+	plan.InstanceSpec.PurchasingOption = option
+	plan.CostConstraints.PreferSpot = option == "spot"
+	plan.CostConstraints.AllowMixedPricing = option == "mixed"
+	// The priority's reservations and capacity queueing suit its own purchasing only
+	if launch.Purchasing != nil && launch.Purchasing.PurchasingOption != option {
+		launch.Purchasing = nil
+	}
+	if !slices.Contains(plan.ExecutionMetadata.DecisionFactors, "burst_policy") {
+		plan.ExecutionMetadata.DecisionFactors = append(plan.ExecutionMetadata.DecisionFactors, "burst_policy")
+	}
+
+	g.Logger.Info("Burst policy changes purchasing",
+		zap.String("policy", policy.Name),
+		zap.String("partition", launch.Partition),
+		zap.String("nodegroup", launch.NodeGroup),
+		zap.String("planned", current),
+		zap.String("purchasing", option))
+}
+
+// checkCeiling returns the spend ceiling of the limits the launch's partition
+// or job account or user has reached, notifying it the first time each month.
+// A ledger that cannot be read never blocks a launch.
+func (g *Gate) checkCeiling(ctx context.Context, ceilings *config.CeilingsConfig, limits []config.CeilingConfig, launch Launch, account, user string) *spend.Lockout {
+	ledger := spend.New(ceilings.LedgerDir)
+	lockout, err := ledger.Check(limits, launch.Partition, account, user)
+	if err != nil {
+		g.Logger.Warn("Failed to check spend ceilings, bursting anyway", zap.String("partition", launch.Partition), zap.Error(err))
+		return nil
+	}
+	if lockout == nil || g.DryRun {
+		return lockout
+	}
+
+	g.Logger.Error("Spend ceiling reached",
+		zap.String("scope", lockout.Scope),
+		zap.Float64("spent_usd", lockout.SpentUSD),
+		zap.Float64("ceiling_usd", lockout.CeilingUSD))
+	if first, err := ledger.MarkNotified(lockout); err != nil {
+		g.Logger.Warn("Failed to record spend ceiling notification", zap.Error(err))
+	} else if first {
+		if err := spend.Notify(ctx, ceilings.NotifyCommand, lockout); err != nil {
+			g.Logger.Error("Failed to notify spend ceiling lockout", zap.String("scope", lockout.Scope), zap.Error(err))
+		}
+	}
+	return lockout
+}
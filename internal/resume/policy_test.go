@@ -0,0 +1,150 @@
+package resume
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/spend"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGate_EnforceBurstPolicy(t *testing.T) {
+	research := config.PolicyConfig{
+		Name:              "research",
+		Accounts:          []string{"research"},
+		NodeGroups:        []string{"aws/cpu"},
+		PurchasingOptions: []string{"spot"},
+	}
+	// A blackout that is always in force
+	blackout := config.BurstWindowsConfig{
+		OverrideQOS: []string{"urgent"},
+		Blackouts:   []config.BlackoutConfig{{Name: "freeze", Start: "2000-01-01 00:00", End: "2100-01-01 00:00"}},
+	}
+
+	tests := []struct {
+		name          string
+		cfg           config.Config
+		job           *types.SlurmJob
+		ignoreWindows bool
+		spentUSD      float64 // Charged to the aws partition this month
+		expected      []string
+		expectError   bool
+		expectPowered map[string][]string
+		expectOption  string // Purchasing of the launches left
+	}{
+		{
+			name:         "nothing configured",
+			job:          &types.SlurmJob{Account: "research"},
+			expected:     []string{"cpu", "gpu"},
+			expectOption: "on-demand",
+		},
+		{
+			name:          "policy drops a node group it does not allow",
+			cfg:           config.Config{Policies: config.PoliciesConfig{Rules: []config.PolicyConfig{research}}},
+			job:           &types.SlurmJob{Account: "research"},
+			expected:      []string{"cpu"},
+			expectPowered: map[string][]string{"policy_research_node_group": {"aws-gpu-1"}},
+			expectOption:  "spot",
+		},
+		{
+			name: "policy max nodes refuses the job",
+			cfg: config.Config{Policies: config.PoliciesConfig{Rules: []config.PolicyConfig{
+				{Name: "small", Accounts: []string{"research"}, MaxNodes: 1},
+			}}},
+			job:         &types.SlurmJob{Account: "research"},
+			expectError: true,
+			expectPowered: map[string][]string{
+				"policy_small_max_nodes": {"aws-cpu-1", "aws-gpu-1"},
+			},
+		},
+		{
+			name:         "policy of another account",
+			cfg:          config.Config{Policies: config.PoliciesConfig{Rules: []config.PolicyConfig{research}}},
+			job:          &types.SlurmJob{Account: "physics"},
+			expected:     []string{"cpu", "gpu"},
+			expectOption: "on-demand",
+		},
+		{
+			name:        "blackout refuses everything",
+			cfg:         config.Config{BurstWindows: blackout},
+			job:         &types.SlurmJob{Account: "research"},
+			expectError: true,
+			expectPowered: map[string][]string{
+				"burst_blackout_freeze": {"aws-cpu-1", "aws-gpu-1"},
+			},
+		},
+		{
+			name:          "ignore windows",
+			cfg:           config.Config{BurstWindows: blackout},
+			job:           &types.SlurmJob{Account: "research"},
+			ignoreWindows: true,
+			expected:      []string{"cpu", "gpu"},
+			expectOption:  "on-demand",
+		},
+		{
+			name:         "QOS overrides windows",
+			cfg:          config.Config{BurstWindows: blackout},
+			job:          &types.SlurmJob{Account: "research", QOS: "urgent"},
+			expected:     []string{"cpu", "gpu"},
+			expectOption: "on-demand",
+		},
+		{
+			name: "partition under its spend ceiling",
+			cfg: config.Config{Costs: config.CostsConfig{Ceilings: config.CeilingsConfig{
+				Limits: []config.CeilingConfig{{Partition: "aws", MonthlyUSD: 100}},
+			}}},
+			job:          &types.SlurmJob{Account: "research"},
+			spentUSD:     40,
+			expected:     []string{"cpu", "gpu"},
+			expectOption: "on-demand",
+		},
+		{
+			name: "partition over its spend ceiling",
+			cfg: config.Config{Costs: config.CostsConfig{Ceilings: config.CeilingsConfig{
+				Limits: []config.CeilingConfig{{Partition: "aws", MonthlyUSD: 100}},
+			}}},
+			job:         &types.SlurmJob{Account: "research"},
+			spentUSD:    150,
+			expectError: true,
+			expectPowered: map[string][]string{
+				"spend_ceiling_partition:aws": {"aws-cpu-1", "aws-gpu-1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Costs.Ceilings.LedgerDir = t.TempDir()
+			if tt.spentUSD > 0 {
+				ledger := spend.New(cfg.Costs.Ceilings.LedgerDir)
+				require.NoError(t, ledger.Record("1", time.Now(), spend.Charge{Partition: "aws", CostUSD: tt.spentUSD}))
+			}
+
+			slurmClient := &fakeSlurm{job: tt.job}
+			gate := newTestGate(t, slurmClient, false)
+			gate.IgnoreWindows = tt.ignoreWindows
+			launches := []Launch{testLaunch("aws", "cpu"), testLaunch("aws", "gpu")}
+			nodes := []string{"aws-cpu-1", "aws-gpu-1"}
+
+			allowed, err := gate.EnforceBurstPolicy(context.Background(), &cfg, nodes, launches)
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Empty(t, allowed)
+			} else {
+				require.NoError(t, err)
+				var nodeGroups []string
+				for _, launch := range allowed {
+					nodeGroups = append(nodeGroups, launch.NodeGroup)
+					assert.Equal(t, tt.expectOption, launch.Plan.InstanceSpec.PurchasingOption)
+				}
+				assert.Equal(t, tt.expected, nodeGroups)
+			}
+			assert.Equal(t, tt.expectPowered, slurmClient.poweredOff)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+// Package resume holds the decisions the resume program makes for the node
+// groups Slurm asks it to power up: which may burst now, and how each
+// standalone execution plan is fitted to the pending job.
+package resume
+
+import (
+	"context"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// Launch is one fleet launch: the requested nodes of a node group and the
+// plan they follow
+type Launch struct {
+	slurm.NodeGroupNodes
+	Plan       *types.ExecutionPlan
+	Standalone bool                             // Plan generated from the node group configuration
+	Purchasing *config.PriorityPurchasingConfig // Standalone purchasing for the job's priority, when the policy is enabled
+	Job        *types.SlurmJob                  // Standalone pending job for the nodes, when Slurm has one
+	Staging    *types.StagingSpec               // Burst buffer directives of the job's script
+	NodeRoles  map[string][]string              // The nodes' roles from AssignNodeRoles, by role
+}
+
+// Slurm is the part of the Slurm client a Gate uses
+type Slurm interface {
+	GetJobForNodes(ctx context.Context, nodeIds []string) (*types.SlurmJob, error)
+	SetNodesState(nodeNames []string, state, reason string) error
+}
+
+// Gate drops the launches that may not burst and powers their nodes back
+// down, with the refusal as their Slurm reason, so Slurm retries them later.
+// In a dry run the refusals are only logged.
+type Gate struct {
+	Logger        *zap.Logger
+	Slurm         Slurm
+	DryRun        bool
+	IgnoreWindows bool // Burst outside burst windows and during blackouts
+}
@@ -0,0 +1,58 @@
+package resume
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeSlurm returns job for every node list and records the nodes each
+// reason powered down
+type fakeSlurm struct {
+	job        *types.SlurmJob
+	poweredOff map[string][]string
+}
+
+func (f *fakeSlurm) GetJobForNodes(ctx context.Context, nodeIds []string) (*types.SlurmJob, error) {
+	if f.job == nil {
+		return nil, errors.New("no job")
+	}
+	return f.job, nil
+}
+
+func (f *fakeSlurm) SetNodesState(nodeNames []string, state, reason string) error {
+	if state != "POWER_DOWN" {
+		return errors.New("unexpected state " + state)
+	}
+	if f.poweredOff == nil {
+		f.poweredOff = make(map[string][]string)
+	}
+	f.poweredOff[reason] = append(f.poweredOff[reason], nodeNames...)
+	return nil
+}
+
+func newTestGate(t *testing.T, slurmClient *fakeSlurm, dryRun bool) *Gate {
+	return &Gate{Logger: zaptest.NewLogger(t), Slurm: slurmClient, DryRun: dryRun}
+}
+
+// testLaunch returns a standalone launch of one node of a node group
+func testLaunch(partition, nodeGroup string) Launch {
+	return Launch{
+		NodeGroupNodes: slurm.NodeGroupNodes{
+			Partition: partition,
+			NodeGroup: nodeGroup,
+			Nodes:     []string{partition + "-" + nodeGroup + "-1"},
+		},
+		Plan: &types.ExecutionPlan{
+			ShouldBurst: true,
+			InstanceSpec: types.InstanceSpecification{
+				InstanceTypes: []string{"c6i.large"}, SubnetIds: []string{"subnet-1"}, PurchasingOption: "on-demand",
+			},
+		},
+		Standalone: true,
+	}
+}
@@ -0,0 +1,55 @@
+package resume
+
+import (
+	"fmt"
+	"slices"
+)
+
+// AssignNodeRoles assigns each plan's node roles once across every launch
+// following it, in the order Slurm requested the nodes, and gives each launch
+// the roles of its own nodes. A role's node_names may then be in any of the
+// job's node groups, and a node_count is filled once rather than per group.
+func AssignNodeRoles(nodes []string, launches []Launch) error {
+	assigned := make(map[int]bool)
+	for i, launch := range launches {
+		if assigned[i] || len(launch.Plan.NodeRoles) == 0 {
+			continue
+		}
+
+		// The launches sharing this plan and their nodes, in request order
+		var sharing []int
+		planNodes := make(map[string]bool)
+		for j := i; j < len(launches); j++ {
+			if launches[j].Plan == launch.Plan {
+				sharing = append(sharing, j)
+				for _, node := range launches[j].Nodes {
+					planNodes[node] = true
+				}
+			}
+		}
+		ordered := slices.DeleteFunc(slices.Clone(nodes), func(node string) bool { return !planNodes[node] })
+
+		assignments, err := launch.Plan.AssignNodeRoles(ordered)
+		if err != nil {
+			return fmt.Errorf("failed to assign node roles: %w", err)
+		}
+		for _, j := range sharing {
+			launches[j].NodeRoles = rolesOf(assignments, launches[j].Nodes)
+			assigned[j] = true
+		}
+	}
+	return nil
+}
+
+// rolesOf narrows role assignments to the given nodes
+func rolesOf(assignments map[string][]string, nodes []string) map[string][]string {
+	roles := make(map[string][]string)
+	for role, assigned := range assignments {
+		for _, node := range assigned {
+			if slices.Contains(nodes, node) {
+				roles[role] = append(roles[role], node)
+			}
+		}
+	}
+	return roles
+}
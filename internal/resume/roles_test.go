@@ -0,0 +1,60 @@
+package resume
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssignNodeRoles(t *testing.T) {
+	nodes := []string{"aws-cpu-1", "aws-cpu-2", "aws-gpu-1", "aws-gpu-2"}
+	launchesFor := func(cpuPlan, gpuPlan *types.ExecutionPlan) []Launch {
+		return []Launch{
+			{NodeGroupNodes: slurm.NodeGroupNodes{Partition: "aws", NodeGroup: "cpu", Nodes: nodes[:2]}, Plan: cpuPlan},
+			{NodeGroupNodes: slurm.NodeGroupNodes{Partition: "aws", NodeGroup: "gpu", Nodes: nodes[2:]}, Plan: gpuPlan},
+		}
+	}
+
+	t.Run("one plan across two node groups", func(t *testing.T) {
+		plan := &types.ExecutionPlan{NodeRoles: []types.NodeRoleSpec{
+			{Role: "head", NodeNames: []string{"aws-gpu-1"}, InstanceTypes: []string{"p4d.24xlarge"}},
+			{Role: "worker", NodeCount: 2, InstanceTypes: []string{"c6i.large"}},
+		}}
+		launches := launchesFor(plan, plan)
+
+		require.NoError(t, AssignNodeRoles(nodes, launches))
+		assert.Equal(t, map[string][]string{"worker": {"aws-cpu-1", "aws-cpu-2"}}, launches[0].NodeRoles)
+		assert.Equal(t, map[string][]string{"head": {"aws-gpu-1"}, types.DefaultNodeRole: {"aws-gpu-2"}}, launches[1].NodeRoles,
+			"the worker count is filled once, not again in the gpu group")
+	})
+
+	t.Run("plans of their own", func(t *testing.T) {
+		roles := []types.NodeRoleSpec{{Role: "head", NodeCount: 1, InstanceTypes: []string{"c6i.xlarge"}}}
+		launches := launchesFor(&types.ExecutionPlan{NodeRoles: roles}, &types.ExecutionPlan{NodeRoles: roles})
+
+		require.NoError(t, AssignNodeRoles(nodes, launches))
+		assert.Equal(t, []string{"aws-cpu-1"}, launches[0].NodeRoles["head"])
+		assert.Equal(t, []string{"aws-gpu-1"}, launches[1].NodeRoles["head"])
+	})
+
+	t.Run("plan without roles", func(t *testing.T) {
+		plan := &types.ExecutionPlan{}
+		launches := launchesFor(plan, plan)
+
+		require.NoError(t, AssignNodeRoles(nodes, launches))
+		assert.Nil(t, launches[0].NodeRoles)
+		assert.Nil(t, launches[1].NodeRoles)
+	})
+
+	t.Run("node not being resumed", func(t *testing.T) {
+		plan := &types.ExecutionPlan{NodeRoles: []types.NodeRoleSpec{
+			{Role: "head", NodeNames: []string{"aws-gpu-9"}, InstanceTypes: []string{"p4d.24xlarge"}},
+		}}
+
+		err := AssignNodeRoles(nodes, launchesFor(plan, plan))
+		assert.EqualError(t, err, "failed to assign node roles: node role head references node aws-gpu-9 which is not being resumed")
+	})
+}
@@ -0,0 +1,197 @@
+// Package scaling powers burst nodes up and down ahead of demand for array and
+// high-throughput partitions, where Slurm's reactive power-save starts nodes one
+// resume cycle too late for bursty queues.
+package scaling
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"go.uber.org/zap"
+)
+
+// scalingReason is recorded on nodes the policy powers down
+const scalingReason = "scaling_policy"
+
+// Decision is the scaling outcome for one partition
+type Decision struct {
+	Partition   string
+	PendingJobs int
+	Active      int // Nodes powered up or powering up
+	Target      int
+	PowerUp     []string
+	PowerDown   []string
+}
+
+// Engine applies partition scaling policies through Slurm, so Slurm's own
+// ResumeProgram and SuspendProgram launch and terminate the instances
+type Engine struct {
+	logger *zap.Logger
+	slurm  *slurm.Client
+	config *config.Config
+}
+
+// NewEngine creates a scaling policy engine
+func NewEngine(logger *zap.Logger, slurmClient *slurm.Client, cfg *config.Config) *Engine {
+	return &Engine{
+		logger: logger,
+		slurm:  slurmClient,
+		config: cfg,
+	}
+}
+
+// Run evaluates every partition with a scaling policy and powers nodes up or down
+// to match its queue. Failures in one partition do not stop the others.
+func (e *Engine) Run(ctx context.Context, dryRun bool) ([]Decision, error) {
+	var decisions []Decision
+	var failed []string
+
+	for _, partition := range e.config.Slurm.Partitions {
+		if !partition.Scaling.Enabled {
+			continue
+		}
+
+		decision, err := e.evaluate(ctx, partition)
+		if err != nil {
+			e.logger.Error("Failed to evaluate scaling policy",
+				zap.String("partition", partition.PartitionName),
+				zap.Error(err))
+			failed = append(failed, partition.PartitionName)
+			continue
+		}
+
+		if !dryRun {
+			e.apply(decision)
+		}
+		decisions = append(decisions, decision)
+	}
+
+	if len(failed) > 0 {
+		return decisions, fmt.Errorf("scaling failed for partitions: %s", strings.Join(failed, ","))
+	}
+	return decisions, nil
+}
+
+// evaluate reads a partition's nodes and queue and plans its scaling
+func (e *Engine) evaluate(ctx context.Context, partition config.PartitionConfig) (Decision, error) {
+	var nodeNames []string
+	for _, nodeGroup := range partition.NodeGroups {
+		nodeRange := e.config.GetNodeRange(partition.PartitionName, nodeGroup.NodeGroupName, nodeGroup.MaxNodes)
+		names, err := e.slurm.ParseNodeList(nodeRange)
+		if err != nil {
+			return Decision{}, fmt.Errorf("failed to parse node list %s: %w", nodeRange, err)
+		}
+		nodeNames = append(nodeNames, names...)
+	}
+
+	nodes, err := e.slurm.GetNodeState(nodeNames)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	pending, err := e.slurm.PendingJobs(ctx, partition.PartitionName)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	return Plan(partition.PartitionName, partition.Scaling, nodes, pending), nil
+}
+
+// apply powers nodes up and down through scontrol
+func (e *Engine) apply(decision Decision) {
+	if err := e.slurm.SetNodesState(decision.PowerUp, "POWER_UP", ""); err != nil {
+		e.logger.Warn("Failed to power up nodes", zap.String("nodes", slurm.CompressHostlist(decision.PowerUp)), zap.Error(err))
+	}
+	if err := e.slurm.SetNodesState(decision.PowerDown, "POWER_DOWN", scalingReason); err != nil {
+		e.logger.Warn("Failed to power down nodes", zap.String("nodes", slurm.CompressHostlist(decision.PowerDown)), zap.Error(err))
+	}
+}
+
+// Plan sizes a partition for its queue: busy nodes plus enough nodes for the pending
+// single-node jobs, kept within the policy's bounds. It powers up the first
+// powered-down nodes and powers down the last idle ones.
+func Plan(partition string, policy config.ScalingPolicyConfig, nodes []slurm.NodeInfo, pending []slurm.PendingJob) Decision {
+	decision := Decision{Partition: partition}
+
+	var poweredDown, idle []string
+	busy := 0
+	for _, node := range nodes {
+		switch nodeStatus(node.State) {
+		case statusPoweredDown:
+			poweredDown = append(poweredDown, node.NodeName)
+		case statusIdle:
+			idle = append(idle, node.NodeName)
+		case statusBusy:
+			busy++
+		}
+	}
+	decision.Active = busy + len(idle)
+
+	for _, job := range pending {
+		// Multi-node jobs need gang scheduling, which power-save handles per job
+		if job.Nodes == 1 {
+			decision.PendingJobs++
+		}
+	}
+
+	jobsPerNode := policy.JobsPerNode
+	if jobsPerNode <= 0 {
+		jobsPerNode = 1
+	}
+	needed := (decision.PendingJobs + jobsPerNode - 1) / jobsPerNode
+
+	// Idle nodes absorb pending jobs before new nodes are powered up, and none are
+	// powered down while jobs are still waiting
+	target := busy + needed
+	if decision.PendingJobs > 0 {
+		target = max(target, busy+len(idle))
+	}
+
+	maxNodes := policy.MaxNodes
+	if maxNodes <= 0 || maxNodes > len(nodes) {
+		maxNodes = len(nodes)
+	}
+	target = min(max(target, policy.MinNodes), maxNodes)
+	decision.Target = target
+
+	switch {
+	case target > decision.Active:
+		count := min(target-decision.Active, len(poweredDown))
+		decision.PowerUp = poweredDown[:count]
+	case target < decision.Active:
+		count := min(decision.Active-target, len(idle))
+		decision.PowerDown = idle[len(idle)-count:]
+	}
+
+	return decision
+}
+
+// Node statuses the policy distinguishes
+const (
+	statusPoweredDown = iota
+	statusIdle
+	statusBusy
+	statusUnavailable
+)
+
+// nodeStatus classifies a compound scontrol state such as IDLE+CLOUD+POWERED_DOWN
+func nodeStatus(state string) int {
+	flags := make(map[string]bool)
+	for _, flag := range strings.Split(state, "+") {
+		flags[strings.TrimRight(strings.ToUpper(flag), "*~#%")] = true
+	}
+
+	switch {
+	case flags["DOWN"] || flags["DRAIN"] || flags["FAIL"] || flags["POWERING_DOWN"]:
+		return statusUnavailable
+	case flags["POWERED_DOWN"] || flags["POWER"]:
+		return statusPoweredDown
+	case flags["ALLOCATED"] || flags["ALLOC"] || flags["MIXED"] || flags["COMPLETING"] || flags["POWERING_UP"]:
+		return statusBusy
+	default:
+		return statusIdle
+	}
+}
@@ -0,0 +1,91 @@
+package scaling
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/stretchr/testify/assert"
+)
+
+func testNodes(states ...string) []slurm.NodeInfo {
+	nodes := make([]slurm.NodeInfo, len(states))
+	for i, state := range states {
+		nodes[i] = slurm.NodeInfo{NodeName: "aws-cpu-" + string(rune('0'+i)), State: state}
+	}
+	return nodes
+}
+
+func pendingJobs(count, nodes int) []slurm.PendingJob {
+	jobs := make([]slurm.PendingJob, count)
+	for i := range jobs {
+		jobs[i] = slurm.PendingJob{JobID: "100_" + string(rune('0'+i)), Nodes: nodes}
+	}
+	return jobs
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    config.ScalingPolicyConfig
+		nodes     []slurm.NodeInfo
+		pending   []slurm.PendingJob
+		target    int
+		powerUp   []string
+		powerDown []string
+	}{
+		{
+			name:    "array tasks power up nodes",
+			policy:  config.ScalingPolicyConfig{JobsPerNode: 2},
+			nodes:   testNodes("ALLOCATED+CLOUD", "IDLE+CLOUD+POWERED_DOWN", "IDLE+CLOUD+POWERED_DOWN", "IDLE+CLOUD+POWERED_DOWN"),
+			pending: pendingJobs(3, 1),
+			target:  3,
+			powerUp: []string{"aws-cpu-1", "aws-cpu-2"},
+		},
+		{
+			name:    "max nodes caps the target",
+			policy:  config.ScalingPolicyConfig{MaxNodes: 2, JobsPerNode: 1},
+			nodes:   testNodes("MIXED+CLOUD", "IDLE+CLOUD+POWERED_DOWN", "IDLE+CLOUD+POWERED_DOWN"),
+			pending: pendingJobs(5, 1),
+			target:  2,
+			powerUp: []string{"aws-cpu-1"},
+		},
+		{
+			name:    "idle nodes absorb pending jobs",
+			policy:  config.ScalingPolicyConfig{JobsPerNode: 1},
+			nodes:   testNodes("IDLE+CLOUD", "IDLE+CLOUD", "IDLE+CLOUD+POWERED_DOWN"),
+			pending: pendingJobs(1, 1),
+			target:  2,
+		},
+		{
+			name:      "empty queue powers down to the minimum",
+			policy:    config.ScalingPolicyConfig{MinNodes: 1, JobsPerNode: 1},
+			nodes:     testNodes("ALLOCATED+CLOUD", "IDLE+CLOUD", "IDLE+CLOUD", "IDLE+CLOUD+POWERED_DOWN"),
+			target:    1,
+			powerDown: []string{"aws-cpu-1", "aws-cpu-2"},
+		},
+		{
+			name:    "minimum powers up nodes without a queue",
+			policy:  config.ScalingPolicyConfig{MinNodes: 2, JobsPerNode: 1},
+			nodes:   testNodes("IDLE+CLOUD+POWERED_DOWN", "IDLE+CLOUD+DRAIN+POWERED_DOWN", "IDLE+CLOUD+POWERED_DOWN"),
+			target:  2,
+			powerUp: []string{"aws-cpu-0", "aws-cpu-2"},
+		},
+		{
+			name:    "multi-node jobs and powering nodes are left alone",
+			policy:  config.ScalingPolicyConfig{JobsPerNode: 1},
+			nodes:   testNodes("IDLE+CLOUD+POWERING_UP", "IDLE+CLOUD+POWERING_DOWN", "IDLE+CLOUD+POWERED_DOWN"),
+			pending: pendingJobs(2, 4),
+			target:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := Plan("aws", tt.policy, tt.nodes, tt.pending)
+			assert.Equal(t, tt.target, decision.Target)
+			assert.Equal(t, tt.powerUp, decision.PowerUp)
+			assert.Equal(t, tt.powerDown, decision.PowerDown)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// DistributedMLDetector identifies distributed deep-learning launchers that use
+// NCCL collectives rather than MPI for communication
+type DistributedMLDetector struct{}
+
+// mlLauncherPatterns maps script patterns to the framework they indicate, checked in order
+var mlLauncherPatterns = []struct {
+	pattern   *regexp.Regexp
+	framework string
+}{
+	{regexp.MustCompile(`\bdeepspeed\b`), "deepspeed"},
+	{regexp.MustCompile(`\bhorovodrun\b|import horovod|hvd\.init\(`), "horovod"},
+	{regexp.MustCompile(`\btorchrun\b|torch\.distributed\.(launch|run)`), "pytorch"},
+	{regexp.MustCompile(`\baccelerate\s+launch\b`), "accelerate"},
+}
+
+// neuronPatterns indicate code compiled with the Neuron SDK rather than CUDA:
+// Neuron packages, tools and settings, or a Neuron GRES. A bare "neuron" is
+// not enough, since the NEURON simulator is common on HPC clusters.
+var neuronPatterns = regexp.MustCompile(`\bneuronx?[-_]|[-_]neuronx?\b|torch_xla|gres[= ]neuron`)
+
+// neuronInferencePatterns indicate serving a compiled model on Inferentia
+var neuronInferencePatterns = regexp.MustCompile(`\binf2\b|inferentia|transformers[-_]neuronx|neuronx[-_]distributed[-_]inference|torch_neuronx\.trace`)
+
+func (d *DistributedMLDetector) Name() string { return "distributed_ml" }
+
+// Framework returns the distributed ML framework the job uses, or "" if none is found
+func (d *DistributedMLDetector) Framework(job *types.SlurmJob) string {
+	script := strings.ToLower(job.Script)
+	for _, launcher := range mlLauncherPatterns {
+		if launcher.pattern.MatchString(script) {
+			return launcher.framework
+		}
+	}
+
+	for key := range job.Environment {
+		if strings.HasPrefix(key, "NCCL_") {
+			return "nccl"
+		}
+	}
+
+	return ""
+}
+
+// UsesNeuron reports whether the job targets Trainium or Inferentia through
+// the Neuron SDK
+func (d *DistributedMLDetector) UsesNeuron(job *types.SlurmJob) bool {
+	return neuronPatterns.MatchString(strings.ToLower(job.Script))
+}
+
+// NeuronFamilies returns the instance families for a Neuron job: Inferentia
+// for single-node inference, otherwise Trainium
+func (d *DistributedMLDetector) NeuronFamilies(job *types.SlurmJob) []string {
+	if job.Resources.Nodes < 2 && neuronInferencePatterns.MatchString(strings.ToLower(job.Script)) {
+		return slices.Clone(types.InferentiaFamilies)
+	}
+	return slices.Clone(types.TrainiumFamilies)
+}
+
+// classifyWorkload sets the job's workload class after MPI detection. Multi-node
+// distributed ML jobs are also flagged as MPI jobs so they get gang scheduling
+// and cluster placement like MPI workloads.
+func (m *MPIScheduler) classifyWorkload(job *types.SlurmJob) {
+	if m.mlDetector.UsesNeuron(job) {
+		job.Accelerator = types.AcceleratorNeuron
+	}
+
+	framework := m.mlDetector.Framework(job)
+	if framework == "" && job.Accelerator == types.AcceleratorNeuron && job.Resources.Nodes >= 2 {
+		// Multi-node Neuron jobs train over Neuron collectives without a known launcher
+		framework = types.AcceleratorNeuron
+	}
+	if framework != "" {
+		job.WorkloadClass = types.WorkloadDistributedML
+		job.MLFramework = framework
+
+		if job.Resources.Nodes >= 2 {
+			job.IsMPIJob = true
+			job.MPITopology = types.TopologyCluster
+		}
+
+		m.logger.Info("Job identified as distributed ML",
+			zap.String("job_id", job.JobID),
+			zap.String("framework", framework),
+			zap.Int("nodes", job.Resources.Nodes))
+		return
+	}
+
+	if job.IsMPIJob {
+		job.WorkloadClass = types.WorkloadMPI
+	} else {
+		job.WorkloadClass = types.WorkloadStandard
+	}
+}
+
+// determineDistributedMLRequirements builds requirements for NCCL-based training:
+// GPU or Trainium families, EFA with GPUDirect RDMA, and cluster placement
+func (m *MPIScheduler) determineDistributedMLRequirements(job *types.SlurmJob, req *types.InstanceRequirements) *types.InstanceRequirements {
+	multiNode := job.Resources.Nodes >= 2
+	efaDisabled := m.explicitEFARequirement(job) == types.EFADisabled
+
+	if job.Accelerator == types.AcceleratorNeuron {
+		req.InstanceFamilies = m.mlDetector.NeuronFamilies(job)
+		req.Accelerator = types.AcceleratorNeuron
+		req.GPUs = 0
+		if !types.KnownEFAFamily(req.InstanceFamilies[0]) {
+			// Inferentia has no EFA
+			efaDisabled = true
+		}
+	} else {
+		switch strings.ToLower(job.Resources.GPUType) {
+		case "h100":
+			req.InstanceFamilies = []string{"p5"}
+		case "a100":
+			req.InstanceFamilies = []string{"p4d"}
+		default:
+			req.InstanceFamilies = []string{"p5", "p4d"}
+		}
+		if req.GPUs == 0 {
+			req.GPUs = 1
+		}
+	}
+
+	req.RequiresEFA = multiNode && !efaDisabled
+	req.EFAPreferred = !efaDisabled
+	req.EnhancedNetworking = true
+	req.HPCOptimized = false
+	if multiNode {
+		req.NetworkTopology = types.TopologyCluster
+		req.PlacementGroupType = "cluster"
+	}
+
+	m.logger.Info("Determined distributed ML instance requirements",
+		zap.String("job_id", job.JobID),
+		zap.String("framework", job.MLFramework),
+		zap.Bool("requires_efa", req.RequiresEFA),
+		zap.Strings("instance_families", req.InstanceFamilies),
+		zap.String("placement_group", req.PlacementGroupType))
+
+	return req
+}
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestMPIScheduler_DistributedML(t *testing.T) {
+	scheduler := NewMPIScheduler(zaptest.NewLogger(t))
+
+	tests := []struct {
+		name              string
+		job               *types.SlurmJob
+		expectedFramework string
+		expectedFamilies  []string
+		expectedEFA       bool
+		expectedPlacement string
+	}{
+		{
+			name: "torchrun multi-node",
+			job: &types.SlurmJob{
+				JobID:     "ml1",
+				Script:    "#!/bin/bash\nsrun torchrun --nnodes 4 --nproc_per_node 8 train.py",
+				Resources: types.ResourceSpec{Nodes: 4, CPUsPerNode: 96, GPUs: 8, GPUType: "a100"},
+			},
+			expectedFramework: "pytorch",
+			expectedFamilies:  []string{"p4d"},
+			expectedEFA:       true,
+			expectedPlacement: "cluster",
+		},
+		{
+			name: "deepspeed on Trainium",
+			job: &types.SlurmJob{
+				JobID:     "ml2",
+				Script:    "#!/bin/bash\nexport NEURON_CC_FLAGS=--model-type=transformer\ndeepspeed train.py",
+				Resources: types.ResourceSpec{Nodes: 2, CPUsPerNode: 128},
+			},
+			expectedFramework: "deepspeed",
+			expectedFamilies:  []string{"trn1n", "trn1"},
+			expectedEFA:       true,
+			expectedPlacement: "cluster",
+		},
+		{
+			name: "single-node horovod",
+			job: &types.SlurmJob{
+				JobID:     "ml3",
+				Script:    "#!/bin/bash\nhorovodrun -np 4 python train.py",
+				Resources: types.ResourceSpec{Nodes: 1, CPUsPerNode: 48, GPUs: 4},
+			},
+			expectedFramework: "horovod",
+			expectedFamilies:  []string{"p5", "p4d"},
+			expectedEFA:       false,
+			expectedPlacement: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, scheduler.AnalyzeJob(context.Background(), tt.job))
+			assert.Equal(t, types.WorkloadDistributedML, tt.job.WorkloadClass)
+			assert.Equal(t, tt.expectedFramework, tt.job.MLFramework)
+			assert.Equal(t, tt.job.Resources.Nodes >= 2, tt.job.IsMPIJob, "multi-node training needs gang scheduling")
+
+			req := scheduler.DetermineInstanceRequirements(tt.job)
+			assert.Equal(t, tt.expectedFamilies, req.InstanceFamilies)
+			assert.Equal(t, tt.expectedEFA, req.RequiresEFA)
+			assert.Equal(t, tt.expectedPlacement, req.PlacementGroupType)
+		})
+	}
+}
+
+func TestMPIScheduler_WorkloadClass(t *testing.T) {
+	scheduler := NewMPIScheduler(zaptest.NewLogger(t))
+
+	mpiJob := &types.SlurmJob{
+		JobID:     "mpi1",
+		Script:    "#!/bin/bash\nmpirun -np 16 ./app",
+		Resources: types.ResourceSpec{Nodes: 2, CPUsPerNode: 8},
+	}
+	require.NoError(t, scheduler.AnalyzeJob(context.Background(), mpiJob))
+	assert.Equal(t, types.WorkloadMPI, mpiJob.WorkloadClass)
+
+	serialJob := &types.SlurmJob{
+		JobID:     "serial1",
+		Script:    "#!/bin/bash\n./analyze",
+		Resources: types.ResourceSpec{Nodes: 1, CPUsPerNode: 4},
+	}
+	require.NoError(t, scheduler.AnalyzeJob(context.Background(), serialJob))
+	assert.Equal(t, types.WorkloadStandard, serialJob.WorkloadClass)
+}
+
+func TestMPIScheduler_Neuron(t *testing.T) {
+	scheduler := NewMPIScheduler(zaptest.NewLogger(t))
+
+	training := &types.SlurmJob{
+		JobID:     "trn1",
+		Script:    "#!/bin/bash\n#SBATCH --gres=neuron:16\nsrun python train.py --backend xla",
+		Resources: types.ResourceSpec{Nodes: 4, CPUsPerNode: 128},
+	}
+	require.NoError(t, scheduler.AnalyzeJob(context.Background(), training))
+	assert.Equal(t, types.AcceleratorNeuron, training.Accelerator)
+	assert.Equal(t, types.WorkloadDistributedML, training.WorkloadClass, "multi-node Neuron jobs use Neuron collectives")
+	assert.Equal(t, "neuron", training.MLFramework)
+	req := scheduler.DetermineInstanceRequirements(training)
+	assert.Equal(t, []string{"trn1n", "trn1"}, req.InstanceFamilies)
+	assert.Equal(t, types.AcceleratorNeuron, req.Accelerator)
+	assert.True(t, req.RequiresEFA)
+
+	inference := &types.SlurmJob{
+		JobID:     "inf1",
+		Script:    "#!/bin/bash\npip install transformers-neuronx\npython serve.py",
+		Resources: types.ResourceSpec{Nodes: 1, CPUsPerNode: 4},
+	}
+	require.NoError(t, scheduler.AnalyzeJob(context.Background(), inference))
+	assert.Equal(t, types.WorkloadStandard, inference.WorkloadClass)
+	req = scheduler.DetermineInstanceRequirements(inference)
+	assert.Equal(t, []string{"inf2"}, req.InstanceFamilies)
+	assert.False(t, req.RequiresEFA)
+	assert.False(t, req.EFAPreferred)
+
+	inferenceLauncher := &types.SlurmJob{
+		JobID:     "inf2",
+		Script:    "#!/bin/bash\ntorchrun --nproc_per_node 2 serve.py  # torch_neuronx.trace model on inf2",
+		Resources: types.ResourceSpec{Nodes: 1, CPUsPerNode: 32},
+	}
+	require.NoError(t, scheduler.AnalyzeJob(context.Background(), inferenceLauncher))
+	req = scheduler.DetermineInstanceRequirements(inferenceLauncher)
+	assert.Equal(t, []string{"inf2"}, req.InstanceFamilies)
+	assert.False(t, req.EFAPreferred, "Inferentia has no EFA")
+	assert.Zero(t, req.GPUs)
+
+	simulator := &types.SlurmJob{
+		JobID:     "sim1",
+		Script:    "#!/bin/bash\nmodule load neuron\nnrniv -python model.py",
+		Resources: types.ResourceSpec{Nodes: 1, CPUsPerNode: 8},
+	}
+	require.NoError(t, scheduler.AnalyzeJob(context.Background(), simulator))
+	assert.Empty(t, simulator.Accelerator, "the NEURON simulator is not the Neuron SDK")
+}
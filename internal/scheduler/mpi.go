@@ -2,26 +2,38 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/mpidetect"
 	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"go.uber.org/zap"
 )
 
 // MPIScheduler handles MPI-specific job analysis and scheduling requirements
 type MPIScheduler struct {
-	logger    *zap.Logger
-	detectors []MPIDetector
+	logger     *zap.Logger
+	detectors  []weightedDetector
+	threshold  float64
+	mlDetector *DistributedMLDetector
 }
 
-type MPIDetector interface {
-	Name() string
-	Detect(job *types.SlurmJob) (bool, float64) // returns (isMPI, confidence)
-	RequiredTopology(job *types.SlurmJob) types.NetworkTopology
+// MPIDetector identifies MPI jobs; implementations are registered through pkg/mpidetect
+type MPIDetector = mpidetect.Detector
+
+// weightedDetector pairs a detector with its configured confidence weight
+type weightedDetector struct {
+	detector MPIDetector
+	weight   float64
 }
 
+// DefaultDetectionThreshold is the confidence above which a job is treated as MPI
+const DefaultDetectionThreshold = 0.5
+
 type Confidence float64
 
 const (
@@ -30,16 +42,82 @@ const (
 	ConfidenceHigh   Confidence = 0.9
 )
 
+func init() {
+	// Built-in detectors register first so they run before site detectors
+	mpidetect.MustRegister(&TaskCountDetector{})
+	mpidetect.MustRegister(&ScriptContentDetector{})
+	mpidetect.MustRegister(&ApplicationDetector{})
+	mpidetect.MustRegister(&EnvironmentDetector{})
+}
+
+// NewMPIScheduler creates a scheduler using every registered detector at full weight
 func NewMPIScheduler(logger *zap.Logger) *MPIScheduler {
-	return &MPIScheduler{
-		logger: logger,
-		detectors: []MPIDetector{
-			&TaskCountDetector{},
-			&ScriptContentDetector{},
-			&ApplicationDetector{},
-			&EnvironmentDetector{},
-		},
+	scheduler := &MPIScheduler{
+		logger:     logger,
+		threshold:  DefaultDetectionThreshold,
+		mlDetector: &DistributedMLDetector{},
 	}
+	for _, detector := range mpidetect.Registered() {
+		scheduler.detectors = append(scheduler.detectors, weightedDetector{detector: detector, weight: 1.0})
+	}
+	return scheduler
+}
+
+// NewMPISchedulerFromConfig creates a scheduler with detectors enabled, weighted
+// and extended according to the MPI configuration
+func NewMPISchedulerFromConfig(logger *zap.Logger, mpiConfig *config.MPIConfig) (*MPIScheduler, error) {
+	for name := range mpiConfig.Detectors {
+		if _, ok := mpidetect.Lookup(name); !ok && !hasCustomDetector(mpiConfig, name) {
+			return nil, fmt.Errorf("mpi.detectors references unknown detector %q (registered: %s)",
+				name, strings.Join(mpidetect.Names(), ", "))
+		}
+	}
+
+	detectors := mpidetect.Registered()
+	for _, custom := range mpiConfig.CustomDetectors {
+		topology, err := mpidetect.ParseTopology(custom.Topology)
+		if err != nil {
+			return nil, fmt.Errorf("custom detector %q: %w", custom.Name, err)
+		}
+		detector, err := mpidetect.NewPatternDetector(custom.Name, custom.ScriptPatterns, custom.Confidence, topology)
+		if err != nil {
+			return nil, err
+		}
+		detectors = append(detectors, detector)
+	}
+
+	scheduler := &MPIScheduler{
+		logger:     logger,
+		threshold:  DefaultDetectionThreshold,
+		mlDetector: &DistributedMLDetector{},
+	}
+	if mpiConfig.DetectionThreshold > 0 {
+		scheduler.threshold = mpiConfig.DetectionThreshold
+	}
+
+	for _, detector := range detectors {
+		settings := mpiConfig.Detectors[detector.Name()]
+		if !settings.IsEnabled() {
+			logger.Debug("MPI detector disabled by configuration", zap.String("detector", detector.Name()))
+			continue
+		}
+		scheduler.detectors = append(scheduler.detectors, weightedDetector{
+			detector: detector,
+			weight:   settings.EffectiveWeight(),
+		})
+	}
+
+	return scheduler, nil
+}
+
+// hasCustomDetector reports whether a detector name is defined in custom_detectors
+func hasCustomDetector(mpiConfig *config.MPIConfig, name string) bool {
+	for _, custom := range mpiConfig.CustomDetectors {
+		if custom.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 // AnalyzeJob determines if a job is MPI and its requirements
@@ -49,23 +127,26 @@ func (m *MPIScheduler) AnalyzeJob(ctx context.Context, job *types.SlurmJob) erro
 	var maxConfidence float64
 	var requiredTopology types.NetworkTopology = types.TopologyAny
 
-	for _, detector := range m.detectors {
-		isMPI, confidence := detector.Detect(job)
+	for _, weighted := range m.detectors {
+		isMPI, confidence := weighted.detector.Detect(job)
+		confidence = math.Min(confidence*weighted.weight, 1.0)
 		m.logger.Debug("MPI detector result",
-			zap.String("detector", detector.Name()),
+			zap.String("detector", weighted.detector.Name()),
 			zap.Bool("is_mpi", isMPI),
 			zap.Float64("confidence", confidence))
 
 		if isMPI && confidence > maxConfidence {
 			maxConfidence = confidence
-			requiredTopology = detector.RequiredTopology(job)
+			requiredTopology = weighted.detector.RequiredTopology(job)
 		}
 	}
 
-	// Consider it MPI if confidence > 0.5
-	job.IsMPIJob = maxConfidence > 0.5
+	// Consider it MPI if confidence exceeds the detection threshold
+	job.IsMPIJob = maxConfidence > m.threshold
 	job.MPITopology = requiredTopology
 
+	m.classifyWorkload(job)
+
 	if job.IsMPIJob {
 		m.calculateMPIProcesses(job)
 		m.logger.Info("Job identified as MPI",
@@ -91,9 +172,20 @@ func (m *MPIScheduler) DetermineInstanceRequirements(job *types.SlurmJob) *types
 		GPUs:            job.Resources.GPUs,
 		GPUType:         job.Resources.GPUType,
 		NetworkTopology: job.MPITopology,
+		Architecture:    m.determineArchitecture(job),
+	}
+
+	if job.WorkloadClass == types.WorkloadDistributedML {
+		return m.determineDistributedMLRequirements(job, req)
 	}
 
 	if !job.IsMPIJob {
+		if job.Accelerator == types.AcceleratorNeuron {
+			req.InstanceFamilies = m.mlDetector.NeuronFamilies(job)
+			req.Accelerator = types.AcceleratorNeuron
+			req.GPUs = 0
+		}
+
 		// Non-MPI jobs don't need EFA
 		req.RequiresEFA = false
 		req.EFAPreferred = false
@@ -149,15 +241,8 @@ func (m *MPIScheduler) DetermineInstanceRequirements(job *types.SlurmJob) *types
 // determineEFARequirement analyzes job characteristics to determine EFA needs
 func (m *MPIScheduler) determineEFARequirement(job *types.SlurmJob) types.EFACapability {
 	// Check for explicit EFA configuration in constraints
-	for _, feature := range job.Constraints.Features {
-		switch strings.ToLower(feature) {
-		case "efa", "efa-required":
-			return types.EFARequired
-		case "no-efa", "efa-disabled":
-			return types.EFADisabled
-		case "efa-preferred":
-			return types.EFAPreferred
-		}
+	if explicit := m.explicitEFARequirement(job); explicit != "" {
+		return explicit
 	}
 
 	// Heuristics based on job characteristics
@@ -183,10 +268,49 @@ func (m *MPIScheduler) determineEFARequirement(job *types.SlurmJob) types.EFACap
 	return types.EFADisabled
 }
 
+// explicitEFARequirement returns the EFA requirement requested through job constraints, if any
+func (m *MPIScheduler) explicitEFARequirement(job *types.SlurmJob) types.EFACapability {
+	for _, feature := range job.Constraints.Features {
+		switch strings.ToLower(feature) {
+		case "efa", "efa-required":
+			return types.EFARequired
+		case "no-efa", "efa-disabled":
+			return types.EFADisabled
+		case "efa-preferred":
+			return types.EFAPreferred
+		}
+	}
+	return ""
+}
+
+// determineArchitecture reads the requested CPU architecture from job constraints
+func (m *MPIScheduler) determineArchitecture(job *types.SlurmJob) string {
+	for _, feature := range job.Constraints.Features {
+		switch strings.ToLower(feature) {
+		case "arm64", "aarch64", "graviton":
+			return types.ArchitectureARM64
+		case "x86_64", "x86", "amd64":
+			return types.ArchitectureX86_64
+		}
+	}
+	return ""
+}
+
 // selectOptimalInstanceFamilies chooses the best instance families for the job
 func (m *MPIScheduler) selectOptimalInstanceFamilies(job *types.SlurmJob, efaCapability types.EFACapability) []string {
 	var families []string
 
+	// ARM-built applications burst to Graviton capacity
+	if m.determineArchitecture(job) == types.ArchitectureARM64 {
+		if efaCapability == types.EFARequired || efaCapability == types.EFAPreferred {
+			if job.Resources.Nodes >= 8 {
+				families = append(families, "hpc7g")
+			}
+			return append(families, "c7gn", "c8g", "c7g", "r7g")
+		}
+		return append(families, "c8g", "c7g", "m7g", "r7g")
+	}
+
 	// If EFA is required or preferred, prioritize EFA-capable instances
 	if efaCapability == types.EFARequired || efaCapability == types.EFAPreferred {
 		// HPC-optimized instances first for large MPI jobs
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
 	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -293,6 +294,20 @@ func TestInstanceFamilySelection(t *testing.T) {
 			expectedFamilies: []string{"r6i", "r5n"},
 			shouldContainHPC: false,
 		},
+		{
+			name: "Graviton MPI job",
+			job: &types.SlurmJob{
+				Resources: types.ResourceSpec{
+					Nodes:       8,
+					CPUsPerNode: 16,
+					MemoryMB:    32768,
+				},
+				Constraints: types.JobConstraints{Features: []string{"graviton"}},
+			},
+			efaCapability:    types.EFARequired,
+			expectedFamilies: []string{"hpc7g", "c7gn"},
+			shouldContainHPC: true,
+		},
 		{
 			name: "GPU job",
 			job: &types.SlurmJob{
@@ -367,3 +382,66 @@ func BenchmarkMPIAnalysis(b *testing.B) {
 		scheduler.DetermineInstanceRequirements(job)
 	}
 }
+
+func TestNewMPISchedulerFromConfig(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	disabled := false
+
+	job := func() *types.SlurmJob {
+		return &types.SlurmJob{
+			JobID:     "site1",
+			Name:      "amber-run",
+			Script:    "#!/bin/bash\nsite_launch --ranks 64 ./model",
+			Resources: types.ResourceSpec{Nodes: 4, CPUsPerNode: 16},
+		}
+	}
+
+	// Default scheduler: amber is only medium confidence via the application detector
+	defaultJob := job()
+	require.NoError(t, NewMPIScheduler(logger).AnalyzeJob(context.Background(), defaultJob))
+	assert.True(t, defaultJob.IsMPIJob)
+
+	// Disabling the application detector removes the only signal
+	scheduler, err := NewMPISchedulerFromConfig(logger, &config.MPIConfig{
+		Detectors: map[string]config.DetectorConfig{"application": {Enabled: &disabled}},
+	})
+	require.NoError(t, err)
+	disabledJob := job()
+	require.NoError(t, scheduler.AnalyzeJob(context.Background(), disabledJob))
+	assert.False(t, disabledJob.IsMPIJob)
+
+	// A raised threshold rejects medium-confidence detections
+	scheduler, err = NewMPISchedulerFromConfig(logger, &config.MPIConfig{DetectionThreshold: 0.7})
+	require.NoError(t, err)
+	strictJob := job()
+	require.NoError(t, scheduler.AnalyzeJob(context.Background(), strictJob))
+	assert.False(t, strictJob.IsMPIJob)
+
+	// A site wrapper detector recognises the custom launcher
+	scheduler, err = NewMPISchedulerFromConfig(logger, &config.MPIConfig{
+		DetectionThreshold: 0.7,
+		CustomDetectors: []config.CustomDetectorConfig{
+			{Name: "site_launch", ScriptPatterns: []string{`site_launch\s+--ranks`}, Confidence: 0.9},
+		},
+	})
+	require.NoError(t, err)
+	siteJob := job()
+	require.NoError(t, scheduler.AnalyzeJob(context.Background(), siteJob))
+	assert.True(t, siteJob.IsMPIJob)
+	assert.Equal(t, types.TopologyCluster, siteJob.MPITopology)
+
+	// Weights scale confidence
+	scheduler, err = NewMPISchedulerFromConfig(logger, &config.MPIConfig{
+		DetectionThreshold: 0.7,
+		Detectors:          map[string]config.DetectorConfig{"application": {Weight: 1.5}},
+	})
+	require.NoError(t, err)
+	weightedJob := job()
+	require.NoError(t, scheduler.AnalyzeJob(context.Background(), weightedJob))
+	assert.True(t, weightedJob.IsMPIJob)
+
+	_, err = NewMPISchedulerFromConfig(logger, &config.MPIConfig{
+		Detectors: map[string]config.DetectorConfig{"nonexistent": {}},
+	})
+	assert.Error(t, err)
+}
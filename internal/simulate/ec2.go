@@ -0,0 +1,482 @@
+package simulate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	burstAWS "github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+)
+
+var _ burstAWS.EC2API = (*EC2)(nil)
+
+// errNotSimulated is returned by EC2 calls the simulation has no model for;
+// callers treat them like any unavailable API and carry on
+var errNotSimulated = errors.New("not available in simulation")
+
+// EC2 is an in-memory EC2 region. Fleets launch instances that reach running
+// after the configured boot time, up to the configured capacity; launches
+// beyond it fail with InsufficientInstanceCapacity. Every call takes the
+// configured latency.
+type EC2 struct {
+	region  string
+	latency time.Duration
+	boot    time.Duration
+	limit   int
+	now     func() time.Time
+
+	mu              sync.Mutex
+	instances       []*instance
+	fleets          int
+	templateVersion int
+	placementGroups []string
+}
+
+// instance is a simulated EC2 instance
+type instance struct {
+	types.Instance
+	launched time.Time
+}
+
+// NewEC2 creates an empty simulated region
+func NewEC2(region string, simulation config.SimulationConfig) *EC2 {
+	return &EC2{
+		region:  region,
+		latency: time.Duration(simulation.EC2LatencyMS) * time.Millisecond,
+		boot:    time.Duration(simulation.BootSeconds) * time.Second,
+		limit:   simulation.Capacity,
+		now:     time.Now,
+	}
+}
+
+// Instances returns the simulated instances, in launch order
+func (e *EC2) Instances() []types.Instance {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	instances := make([]types.Instance, 0, len(e.instances))
+	for _, instance := range e.instances {
+		instances = append(instances, e.current(instance))
+	}
+	return instances
+}
+
+func (e *EC2) CreateFleet(ctx context.Context, params *ec2.CreateFleetInput, optFns ...func(*ec2.Options)) (*ec2.CreateFleetOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.fleets++
+
+	capacity := params.TargetCapacitySpecification
+	requested := int(aws.ToInt32(capacity.TotalTargetCapacity))
+	onDemand := requested
+	if capacity.DefaultTargetCapacityType == types.DefaultTargetCapacityTypeSpot {
+		onDemand = int(aws.ToInt32(capacity.OnDemandTargetCapacity))
+	}
+
+	instanceType, subnetID := "c5.large", ""
+	if len(params.LaunchTemplateConfigs) > 0 && len(params.LaunchTemplateConfigs[0].Overrides) > 0 {
+		override := params.LaunchTemplateConfigs[0].Overrides[0]
+		if override.InstanceType != "" {
+			instanceType = string(override.InstanceType)
+		}
+		subnetID = aws.ToString(override.SubnetId)
+	}
+
+	launched := requested
+	if e.limit > 0 {
+		launched = min(requested, max(e.limit-e.running(), 0))
+	}
+
+	output := &ec2.CreateFleetOutput{FleetId: aws.String(fmt.Sprintf("fleet-sim%05d", e.fleets))}
+	byLifecycle := make(map[types.InstanceLifecycle][]string)
+	for i := 0; i < launched; i++ {
+		lifecycle := types.InstanceLifecycleOnDemand
+		if i >= onDemand {
+			lifecycle = types.InstanceLifecycleSpot
+		}
+		id := e.launch(instanceType, subnetID, lifecycle == types.InstanceLifecycleSpot)
+		byLifecycle[lifecycle] = append(byLifecycle[lifecycle], id)
+	}
+	for _, lifecycle := range []types.InstanceLifecycle{types.InstanceLifecycleOnDemand, types.InstanceLifecycleSpot} {
+		if ids := byLifecycle[lifecycle]; len(ids) > 0 {
+			output.Instances = append(output.Instances, types.CreateFleetInstance{
+				InstanceIds:  ids,
+				InstanceType: types.InstanceType(instanceType),
+				Lifecycle:    lifecycle,
+			})
+		}
+	}
+	if launched < requested {
+		output.Errors = append(output.Errors, types.CreateFleetError{
+			ErrorCode:    aws.String("InsufficientInstanceCapacity"),
+			ErrorMessage: aws.String(fmt.Sprintf("simulated capacity of %d instances reached", e.limit)),
+		})
+	}
+	return output, nil
+}
+
+// launch adds a pending instance and returns its ID
+func (e *EC2) launch(instanceType, subnetID string, spot bool) string {
+	n := len(e.instances) + 1
+	launched := &instance{
+		Instance: types.Instance{
+			InstanceId:       aws.String(fmt.Sprintf("i-sim%012d", n)),
+			InstanceType:     types.InstanceType(instanceType),
+			PrivateIpAddress: aws.String(fmt.Sprintf("10.%d.%d.%d", 100+n/65536, n/256%256, n%256)),
+			SubnetId:         aws.String(subnetID),
+			Placement:        &types.Placement{AvailabilityZone: aws.String(e.zoneOf(subnetID))},
+			State:            &types.InstanceState{Name: types.InstanceStateNamePending},
+		},
+		launched: e.now(),
+	}
+	if spot {
+		launched.InstanceLifecycle = types.InstanceLifecycleTypeSpot
+	}
+	launched.LaunchTime = aws.Time(launched.launched)
+	e.instances = append(e.instances, launched)
+	return aws.ToString(launched.InstanceId)
+}
+
+// running counts instances not shutting down or stopped
+func (e *EC2) running() int {
+	count := 0
+	for _, instance := range e.instances {
+		switch e.current(instance).State.Name {
+		case types.InstanceStateNamePending, types.InstanceStateNameRunning:
+			count++
+		}
+	}
+	return count
+}
+
+// current returns an instance with pending advanced to running once booted
+func (e *EC2) current(i *instance) types.Instance {
+	if i.State.Name == types.InstanceStateNamePending && e.now().Sub(i.launched) >= e.boot {
+		i.State = &types.InstanceState{Name: types.InstanceStateNameRunning}
+	}
+	return i.Instance
+}
+
+// zoneOf places a subnet in one of the region's zones, a to c
+func (e *EC2) zoneOf(subnetID string) string {
+	sum := 0
+	for _, c := range subnetID {
+		sum += int(c)
+	}
+	return fmt.Sprintf("%s%c", e.region, 'a'+sum%3)
+}
+
+func (e *EC2) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var matched []types.Instance
+	for _, i := range e.instances {
+		current := e.current(i)
+		if len(params.InstanceIds) > 0 && !slices.Contains(params.InstanceIds, aws.ToString(current.InstanceId)) {
+			continue
+		}
+		if matchesFilters(current, params.Filters) {
+			matched = append(matched, current)
+		}
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: matched}}}, nil
+}
+
+// matchesFilters applies the instance filters aws-slurm-burst uses; others
+// match everything
+func matchesFilters(instance types.Instance, filters []types.Filter) bool {
+	for _, filter := range filters {
+		name := aws.ToString(filter.Name)
+		var values []string
+		switch {
+		case name == "instance-state-name":
+			values = []string{string(instance.State.Name)}
+		case name == "tag-key":
+			for _, tag := range instance.Tags {
+				values = append(values, aws.ToString(tag.Key))
+			}
+		case len(name) > 4 && name[:4] == "tag:":
+			for _, tag := range instance.Tags {
+				if aws.ToString(tag.Key) == name[4:] {
+					values = append(values, aws.ToString(tag.Value))
+				}
+			}
+		default:
+			continue
+		}
+		if !slices.ContainsFunc(values, func(value string) bool { return slices.Contains(filter.Values, value) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *EC2) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	e.update(params.Resources, func(i *instance) {
+		for _, tag := range params.Tags {
+			i.Tags = slices.DeleteFunc(i.Tags, func(existing types.Tag) bool { return aws.ToString(existing.Key) == aws.ToString(tag.Key) })
+			i.Tags = append(i.Tags, tag)
+		}
+	})
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (e *EC2) DeleteTags(ctx context.Context, params *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	e.update(params.Resources, func(i *instance) {
+		for _, tag := range params.Tags {
+			i.Tags = slices.DeleteFunc(i.Tags, func(existing types.Tag) bool { return aws.ToString(existing.Key) == aws.ToString(tag.Key) })
+		}
+	})
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+func (e *EC2) TerminateInstances(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	e.update(params.InstanceIds, func(i *instance) {
+		i.State = &types.InstanceState{Name: types.InstanceStateNameTerminated}
+	})
+	return &ec2.TerminateInstancesOutput{}, nil
+}
+
+func (e *EC2) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	e.update(params.InstanceIds, func(i *instance) {
+		i.State = &types.InstanceState{Name: types.InstanceStateNameStopped}
+	})
+	return &ec2.StopInstancesOutput{}, nil
+}
+
+func (e *EC2) StartInstances(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	e.update(params.InstanceIds, func(i *instance) {
+		i.State = &types.InstanceState{Name: types.InstanceStateNamePending}
+		i.launched = e.now()
+	})
+	return &ec2.StartInstancesOutput{}, nil
+}
+
+// update applies change to the instances with the IDs
+func (e *EC2) update(instanceIds []string, change func(*instance)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, instance := range e.instances {
+		if slices.Contains(instanceIds, aws.ToString(instance.InstanceId)) {
+			change(instance)
+		}
+	}
+}
+
+func (e *EC2) CreatePlacementGroup(ctx context.Context, params *ec2.CreatePlacementGroupInput, optFns ...func(*ec2.Options)) (*ec2.CreatePlacementGroupOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.placementGroups = append(e.placementGroups, aws.ToString(params.GroupName))
+	return &ec2.CreatePlacementGroupOutput{PlacementGroup: &types.PlacementGroup{
+		GroupName: params.GroupName,
+		Strategy:  params.Strategy,
+		State:     types.PlacementGroupStateAvailable,
+	}}, nil
+}
+
+func (e *EC2) DescribePlacementGroups(ctx context.Context, params *ec2.DescribePlacementGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribePlacementGroupsOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	output := &ec2.DescribePlacementGroupsOutput{}
+	for _, name := range e.placementGroups {
+		if len(params.GroupNames) == 0 || slices.Contains(params.GroupNames, name) {
+			output.PlacementGroups = append(output.PlacementGroups, types.PlacementGroup{
+				GroupName: aws.String(name),
+				State:     types.PlacementGroupStateAvailable,
+			})
+		}
+	}
+	return output, nil
+}
+
+func (e *EC2) CreateLaunchTemplateVersion(ctx context.Context, params *ec2.CreateLaunchTemplateVersionInput, optFns ...func(*ec2.Options)) (*ec2.CreateLaunchTemplateVersionOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.templateVersion++
+	return &ec2.CreateLaunchTemplateVersionOutput{LaunchTemplateVersion: &types.LaunchTemplateVersion{
+		LaunchTemplateId:   params.LaunchTemplateId,
+		LaunchTemplateName: params.LaunchTemplateName,
+		VersionNumber:      aws.Int64(int64(e.templateVersion)),
+	}}, nil
+}
+
+func (e *EC2) DeleteLaunchTemplateVersions(ctx context.Context, params *ec2.DeleteLaunchTemplateVersionsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteLaunchTemplateVersionsOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	return &ec2.DeleteLaunchTemplateVersionsOutput{}, nil
+}
+
+// DescribeLaunchTemplateVersions finds no template, so launches skip the
+// AMI architecture check
+func (e *EC2) DescribeLaunchTemplateVersions(ctx context.Context, params *ec2.DescribeLaunchTemplateVersionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeLaunchTemplateVersionsOutput{}, nil
+}
+
+func (e *EC2) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	return nil, errNotSimulated
+}
+
+func (e *EC2) DescribeInstanceTypes(ctx context.Context, params *ec2.DescribeInstanceTypesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error) {
+	return nil, errNotSimulated
+}
+
+func (e *EC2) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+	return nil, errNotSimulated
+}
+
+// DescribeInstanceTypeOfferings offers every instance type asked about in
+// every zone asked about
+func (e *EC2) DescribeInstanceTypeOfferings(ctx context.Context, params *ec2.DescribeInstanceTypeOfferingsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypeOfferingsOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+
+	var instanceTypes, zones []string
+	for _, filter := range params.Filters {
+		switch aws.ToString(filter.Name) {
+		case "instance-type":
+			instanceTypes = filter.Values
+		case "location":
+			zones = filter.Values
+		}
+	}
+
+	output := &ec2.DescribeInstanceTypeOfferingsOutput{}
+	for _, instanceType := range instanceTypes {
+		for _, zone := range zones {
+			output.InstanceTypeOfferings = append(output.InstanceTypeOfferings, types.InstanceTypeOffering{
+				InstanceType: types.InstanceType(instanceType),
+				Location:     aws.String(zone),
+				LocationType: types.LocationTypeAvailabilityZone,
+			})
+		}
+	}
+	return output, nil
+}
+
+func (e *EC2) DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+
+	output := &ec2.DescribeSubnetsOutput{}
+	for _, subnetID := range params.SubnetIds {
+		output.Subnets = append(output.Subnets, types.Subnet{
+			SubnetId:         aws.String(subnetID),
+			AvailabilityZone: aws.String(e.zoneOf(subnetID)),
+		})
+	}
+	return output, nil
+}
+
+func (e *EC2) DescribeAvailabilityZones(ctx context.Context, params *ec2.DescribeAvailabilityZonesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+
+	output := &ec2.DescribeAvailabilityZonesOutput{}
+	for _, suffix := range "abc" {
+		output.AvailabilityZones = append(output.AvailabilityZones, types.AvailabilityZone{
+			ZoneName:   aws.String(fmt.Sprintf("%s%c", e.region, suffix)),
+			RegionName: aws.String(e.region),
+			State:      types.AvailabilityZoneStateAvailable,
+		})
+	}
+	return output, nil
+}
+
+// DescribeSpotPriceHistory has no prices, so spot decisions fall back to
+// the configured limits
+func (e *EC2) DescribeSpotPriceHistory(ctx context.Context, params *ec2.DescribeSpotPriceHistoryInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSpotPriceHistoryOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeSpotPriceHistoryOutput{}, nil
+}
+
+// DescribeSpotInstanceRequests finds no requests: simulated spot instances are
+// never interrupted
+func (e *EC2) DescribeSpotInstanceRequests(ctx context.Context, params *ec2.DescribeSpotInstanceRequestsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSpotInstanceRequestsOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeSpotInstanceRequestsOutput{}, nil
+}
+
+// DescribeVolumes finds no volumes: simulated instances keep none past
+// termination
+func (e *EC2) DescribeVolumes(ctx context.Context, params *ec2.DescribeVolumesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVolumesOutput, error) {
+	if err := wait(ctx, e.latency); err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeVolumesOutput{}, nil
+}
+
+func (e *EC2) DeleteVolume(ctx context.Context, params *ec2.DeleteVolumeInput, optFns ...func(*ec2.Options)) (*ec2.DeleteVolumeOutput, error) {
+	return nil, errNotSimulated
+}
+
+func (e *EC2) GetSpotPlacementScores(ctx context.Context, params *ec2.GetSpotPlacementScoresInput, optFns ...func(*ec2.Options)) (*ec2.GetSpotPlacementScoresOutput, error) {
+	return nil, errNotSimulated
+}
+
+func (e *EC2) GetConsoleOutput(ctx context.Context, params *ec2.GetConsoleOutputInput, optFns ...func(*ec2.Options)) (*ec2.GetConsoleOutputOutput, error) {
+	return nil, errNotSimulated
+}
+
+func (e *EC2) DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	return nil, errNotSimulated
+}
+
+func (e *EC2) DescribeVpnConnections(ctx context.Context, params *ec2.DescribeVpnConnectionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpnConnectionsOutput, error) {
+	return nil, errNotSimulated
+}
+
+func (e *EC2) DescribeTransitGatewayVpcAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayVpcAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayVpcAttachmentsOutput, error) {
+	return nil, errNotSimulated
+}
@@ -0,0 +1,27 @@
+// Package simulate replaces EC2 and Slurm with in-memory fakes so that
+// resume can run end to end without either, on a laptop. Each fake call
+// takes a configurable latency and EC2 has a configurable capacity, so the
+// timing and cost of an execution plan can be observed before it meets a
+// real cluster.
+package simulate
+
+import (
+	"context"
+	"time"
+)
+
+// wait sleeps for the simulated latency of a call, or until ctx ends
+func wait(ctx context.Context, latency time.Duration) error {
+	if latency <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,126 @@
+package simulate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	burstAWS "github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	burstTypes "github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func TestEC2_LaunchAndTerminate(t *testing.T) {
+	simulated := NewEC2("us-east-1", config.SimulationConfig{})
+	appConfig := &config.Config{Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{{
+		PartitionName: "aws",
+		NodeGroups: []config.NodeGroupConfig{{
+			NodeGroupName:      "cpu",
+			LaunchTemplateSpec: config.LaunchTemplateSpec{LaunchTemplateName: "compute", Version: "$Latest"},
+			SubnetIds:          []string{"subnet-a"},
+		}},
+	}}}}
+	client := burstAWS.NewClientWithEC2API(zaptest.NewLogger(t), &config.AWSConfig{Region: "us-east-1"}, appConfig, simulated)
+
+	nodes := []string{"aws-cpu-1", "aws-cpu-2"}
+	result, err := client.LaunchInstances(context.Background(), &burstAWS.LaunchRequest{
+		NodeIds:              nodes,
+		Partition:            "aws",
+		NodeGroup:            "cpu",
+		InstanceRequirements: &burstTypes.InstanceRequirements{InstanceFamilies: []string{"c5.large"}},
+		Job:                  &burstTypes.SlurmJob{JobID: "42"},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Instances, 2)
+	for i, instance := range result.Instances {
+		assert.Equal(t, nodes[i], instance.NodeName)
+		assert.Equal(t, "running", instance.State)
+		assert.Equal(t, "c5.large", instance.InstanceType)
+		assert.NotEmpty(t, instance.PrivateIP)
+	}
+
+	require.NoError(t, client.TerminateInstances(context.Background(), nodes))
+	for _, instance := range simulated.Instances() {
+		assert.Equal(t, types.InstanceStateNameTerminated, instance.State.Name)
+	}
+}
+
+func TestEC2_CreateFleet_Capacity(t *testing.T) {
+	simulated := NewEC2("us-east-1", config.SimulationConfig{Capacity: 3})
+	createFleet := func(count, onDemand int32) *ec2.CreateFleetOutput {
+		output, err := simulated.CreateFleet(context.Background(), &ec2.CreateFleetInput{
+			TargetCapacitySpecification: &types.TargetCapacitySpecificationRequest{
+				TotalTargetCapacity:       aws.Int32(count),
+				OnDemandTargetCapacity:    aws.Int32(onDemand),
+				DefaultTargetCapacityType: types.DefaultTargetCapacityTypeSpot,
+			},
+		})
+		require.NoError(t, err)
+		return output
+	}
+
+	output := createFleet(2, 1)
+	require.Len(t, output.Instances, 2)
+	assert.Equal(t, types.InstanceLifecycleOnDemand, output.Instances[0].Lifecycle)
+	assert.Equal(t, types.InstanceLifecycleSpot, output.Instances[1].Lifecycle)
+	assert.Empty(t, output.Errors)
+
+	output = createFleet(2, 2)
+	require.Len(t, output.Instances, 1)
+	assert.Len(t, output.Instances[0].InstanceIds, 1, "only one instance fits")
+	require.Len(t, output.Errors, 1)
+	assert.Equal(t, "InsufficientInstanceCapacity", aws.ToString(output.Errors[0].ErrorCode))
+
+	_, err := simulated.TerminateInstances(context.Background(), &ec2.TerminateInstancesInput{InstanceIds: output.Instances[0].InstanceIds})
+	require.NoError(t, err)
+	assert.Len(t, createFleet(1, 1).Instances, 1, "terminating frees capacity")
+}
+
+func TestEC2_BootTime(t *testing.T) {
+	simulated := NewEC2("us-east-1", config.SimulationConfig{BootSeconds: 60})
+	_, err := simulated.CreateFleet(context.Background(), &ec2.CreateFleetInput{
+		TargetCapacitySpecification: &types.TargetCapacitySpecificationRequest{TotalTargetCapacity: aws.Int32(1)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.InstanceStateNamePending, simulated.Instances()[0].State.Name)
+
+	launched := simulated.now()
+	simulated.now = func() time.Time { return launched.Add(time.Minute) }
+	assert.Equal(t, types.InstanceStateNameRunning, simulated.Instances()[0].State.Name)
+}
+
+func TestSlurm(t *testing.T) {
+	simulated := NewSlurm(config.SimulationConfig{})
+	client := slurm.NewClientWithRunner(zaptest.NewLogger(t), &config.SlurmConfig{}, simulated.Run)
+
+	nodes, err := client.ParseNodeList("aws-cpu-[1-3]")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws-cpu-1", "aws-cpu-2", "aws-cpu-3"}, nodes)
+
+	version, err := client.Version()
+	require.NoError(t, err)
+	assert.Equal(t, SimulatedSlurmVersion, version.String())
+
+	require.NoError(t, client.UpdateNode("aws-cpu-1", "NodeAddr=10.0.0.1 NodeHostname=10.0.0.1"))
+	require.NoError(t, client.SetNodesState([]string{"aws-cpu-2", "aws-cpu-3"}, "DOWN", "aws_launch_failed"))
+
+	state, err := client.GetNodeState(nodes)
+	require.NoError(t, err)
+	require.Len(t, state, 3)
+	assert.Equal(t, "IDLE+CLOUD+POWERED_DOWN", state[0].State)
+	assert.Equal(t, "DOWN+CLOUD", state[1].State)
+	assert.Equal(t, "aws_launch_failed", state[2].Reason)
+	assert.Equal(t, "10.0.0.1", simulated.Node("aws-cpu-1")["NodeAddr"])
+
+	updates := simulated.Updates()
+	require.Len(t, updates, 2)
+	assert.Equal(t, "aws-cpu-[2-3]", updates[1].Nodes)
+}
@@ -0,0 +1,177 @@
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+)
+
+// SimulatedSlurmVersion is the release the simulated scontrol reports
+const SimulatedSlurmVersion = "23.11.0"
+
+// Update is one scontrol node update the simulation received
+type Update struct {
+	Nodes      string   `json:"nodes"`
+	Parameters []string `json:"parameters"`
+}
+
+// Slurm is an in-memory slurmctld answering the scontrol, squeue and sacct
+// calls aws-slurm-burst makes. Node updates are recorded and reflected in
+// later node queries; there are no jobs. Every call takes the configured
+// latency.
+type Slurm struct {
+	latency time.Duration
+
+	mu      sync.Mutex
+	nodes   map[string]map[string]string
+	updates []Update
+}
+
+// NewSlurm creates a simulated cluster whose nodes start idle and powered down
+func NewSlurm(simulation config.SimulationConfig) *Slurm {
+	return &Slurm{
+		latency: time.Duration(simulation.SlurmLatencyMS) * time.Millisecond,
+		nodes:   make(map[string]map[string]string),
+	}
+}
+
+// Updates returns the node updates received, in order
+func (s *Slurm) Updates() []Update {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Update(nil), s.updates...)
+}
+
+// Node returns the fields of a node, such as State and NodeAddr
+func (s *Slurm) Node(name string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fields := map[string]string{"NodeName": name}
+	for key, value := range s.node(name) {
+		fields[key] = value
+	}
+	return fields
+}
+
+// Run answers a Slurm command; it is the slurm.CommandRunner of a client
+// talking to the simulation
+func (s *Slurm) Run(ctx context.Context, program string, args ...string) ([]byte, error) {
+	if err := wait(ctx, s.latency); err != nil {
+		return nil, err
+	}
+
+	command := filepath.Base(program)
+	if len(args) > 0 && args[0] == "--json" {
+		return nil, fmt.Errorf("%s --json: not available in simulation", command)
+	}
+
+	switch command {
+	case "scontrol":
+		return s.scontrol(args)
+	case "squeue", "sacct":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s: not available in simulation", command)
+	}
+}
+
+func (s *Slurm) scontrol(args []string) ([]byte, error) {
+	switch {
+	case len(args) == 1 && args[0] == "--version":
+		return []byte("slurm " + SimulatedSlurmVersion + "\n"), nil
+	case len(args) >= 3 && args[0] == "show" && args[1] == "hostnames":
+		return []byte(strings.Join(slurm.ExpandHostlist(args[2]), "\n") + "\n"), nil
+	case len(args) >= 3 && args[0] == "show" && args[1] == "node":
+		return s.showNodes(args[2]), nil
+	case len(args) >= 2 && args[0] == "update" && strings.HasPrefix(strings.ToLower(args[1]), "nodename="):
+		s.update(args[1][len("nodename="):], args[2:])
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("scontrol %s: not available in simulation", strings.Join(args, " "))
+	}
+}
+
+// node returns the fields set on a node, creating it idle and powered down
+func (s *Slurm) node(name string) map[string]string {
+	fields, ok := s.nodes[name]
+	if !ok {
+		fields = map[string]string{"State": "IDLE+CLOUD+POWERED_DOWN"}
+		s.nodes[name] = fields
+	}
+	return fields
+}
+
+// update applies key=value parameters to the nodes; scontrol receives
+// values with spaces split across arguments, which are joined back here
+func (s *Slurm) update(hostlist string, parameters []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updates = append(s.updates, Update{Nodes: hostlist, Parameters: parameters})
+
+	values := make(map[string]string)
+	var last string
+	for _, parameter := range parameters {
+		key, value, ok := strings.Cut(parameter, "=")
+		if !ok {
+			if last != "" {
+				values[last] += " " + parameter
+			}
+			continue
+		}
+		last = normalizeKey(key)
+		values[last] = value
+	}
+
+	for _, name := range slurm.ExpandHostlist(hostlist) {
+		fields := s.node(name)
+		for key, value := range values {
+			if key == "State" {
+				value = strings.ToUpper(value) + "+CLOUD"
+			}
+			fields[key] = strings.Trim(value, `"`)
+		}
+	}
+}
+
+// normalizeKey spells scontrol's case-insensitive keys the way show node does
+func normalizeKey(key string) string {
+	for _, known := range []string{"State", "Reason", "Comment", "NodeAddr", "NodeHostname", "Features", "Weight"} {
+		if strings.EqualFold(key, known) {
+			return known
+		}
+	}
+	return key
+}
+
+// showNodes prints the nodes one per line, like scontrol show node -o
+func (s *Slurm) showNodes(hostlist string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var output strings.Builder
+	for _, name := range slurm.ExpandHostlist(hostlist) {
+		fields := s.node(name)
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		output.WriteString("NodeName=" + name)
+		for _, key := range keys {
+			// show node -o separates fields with spaces, so values cannot hold them
+			output.WriteString(" " + key + "=" + strings.ReplaceAll(fields[key], " ", "_"))
+		}
+		output.WriteString("\n")
+	}
+	return []byte(output.String())
+}
@@ -0,0 +1,150 @@
+package slurm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// sacctTimeFormat is the timestamp layout sacct reads and prints
+const sacctTimeFormat = "2006-01-02T15:04:05"
+
+// AccountingRecord is a job's sacct entry
+type AccountingRecord struct {
+	JobID     string
+	Account   string
+	Partition string
+	State     string
+	Nodes     int
+	Elapsed   time.Duration
+	End       time.Time // Zero while the job runs
+	Metadata  string    // Burst metadata field(s), chunks joined
+}
+
+// BurstJobs returns the accounting records of jobs in the partitions since a time
+func (c *Client) BurstJobs(ctx context.Context, partitions []string, since time.Time) ([]AccountingRecord, error) {
+	metadataFields := c.config.JobMetadata.Fields()
+
+	var response sacctResponse
+	if c.runJSON(ctx, &response, "sacct", "--allusers", "--starttime", since.Format(sacctTimeFormat), "--partition", strings.Join(partitions, ",")) {
+		return response.records(metadataFields), nil
+	}
+
+	output, err := c.command(ctx, "sacct", "--allusers", "--allocations", "--noheader", "--parsable2",
+		"--starttime", since.Format(sacctTimeFormat),
+		"--partition", strings.Join(partitions, ","),
+		"--format", "JobID,Account,Partition,State,NNodes,ElapsedRaw,End,"+strings.Join(metadataFields, ","))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job accounting: %w", err)
+	}
+	return parseAccounting(string(output), len(metadataFields)), nil
+}
+
+// parseAccounting parses sacct --parsable2 lines in BurstJobs' field order, ending
+// with the given number of metadata fields
+func parseAccounting(output string, metadataFields int) []AccountingRecord {
+	var records []AccountingRecord
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Metadata fields are last so a stray "|" in one cannot shift the others
+		fields := strings.SplitN(scanner.Text(), "|", 7+metadataFields)
+		if len(fields) != 7+metadataFields || fields[0] == "" {
+			continue
+		}
+
+		record := AccountingRecord{
+			JobID:     fields[0],
+			Account:   fields[1],
+			Partition: fields[2],
+			State:     strings.Fields(fields[3] + " ")[0], // "CANCELLED by 1000" -> "CANCELLED"
+			Metadata:  types.JoinAWSJobMetadata(fields[7:]...),
+		}
+		record.Nodes, _ = strconv.Atoi(fields[4])
+		if seconds, err := strconv.Atoi(fields[5]); err == nil {
+			record.Elapsed = time.Duration(seconds) * time.Second
+		}
+		if end, err := time.ParseInLocation(sacctTimeFormat, fields[6], time.Local); err == nil {
+			record.End = end
+		}
+
+		records = append(records, record)
+	}
+	return records
+}
+
+// sacctResponse is the part of sacct --json output BurstJobs needs
+type sacctResponse struct {
+	Jobs *[]struct {
+		JobID slurmNumber `json:"job_id"`
+		Array struct {
+			JobID  slurmNumber `json:"job_id"`
+			TaskID slurmNumber `json:"task_id"`
+			Tasks  string      `json:"task"` // Tasks not yet started, e.g. 1-100%10
+		} `json:"array"`
+		Account   string `json:"account"`
+		Partition string `json:"partition"`
+		State     struct {
+			Current stringList `json:"current"`
+		} `json:"state"`
+		Nodes slurmNumber `json:"allocation_nodes"`
+		Time  struct {
+			Elapsed slurmNumber `json:"elapsed"` // Seconds
+			End     slurmNumber `json:"end"`     // Unix time, zero while running
+		} `json:"time"`
+		Comment struct {
+			Administrator string `json:"administrator"`
+			Job           string `json:"job"`
+			System        string `json:"system"`
+		} `json:"comment"`
+	} `json:"jobs"`
+}
+
+func (r *sacctResponse) complete() bool { return r.Jobs != nil }
+
+// records converts the jobs to AccountingRecords, reading metadata from the
+// named fields
+func (r *sacctResponse) records(metadataFields []string) []AccountingRecord {
+	var records []AccountingRecord
+	for _, job := range *r.Jobs {
+		record := AccountingRecord{
+			JobID:     strconv.FormatInt(int64(job.JobID), 10),
+			Account:   job.Account,
+			Partition: job.Partition,
+			Nodes:     int(job.Nodes),
+			Elapsed:   time.Duration(job.Time.Elapsed) * time.Second,
+		}
+		if job.Array.Tasks != "" {
+			record.JobID = fmt.Sprintf("%d_[%s]", job.Array.JobID, job.Array.Tasks)
+		} else if job.Array.JobID != 0 {
+			record.JobID = fmt.Sprintf("%d_%d", job.Array.JobID, job.Array.TaskID)
+		}
+		if len(job.State.Current) > 0 {
+			record.State = strings.Fields(job.State.Current[0] + " ")[0]
+		}
+		if job.Time.End > 0 {
+			record.End = time.Unix(int64(job.Time.End), 0)
+		}
+
+		var metadata []string
+		for _, field := range metadataFields {
+			switch field {
+			case "AdminComment":
+				metadata = append(metadata, job.Comment.Administrator)
+			case "Comment":
+				metadata = append(metadata, job.Comment.Job)
+			case "SystemComment":
+				metadata = append(metadata, job.Comment.System)
+			}
+		}
+		record.Metadata = types.JoinAWSJobMetadata(metadata...)
+
+		records = append(records, record)
+	}
+	return records
+}
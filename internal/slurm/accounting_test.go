@@ -0,0 +1,32 @@
+package slurm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAccounting(t *testing.T) {
+	records := parseAccounting("101|chem|aws|COMPLETED|4|7200|2026-03-02T10:00:00|aws_meta:{\"cost\":3.5}\n"+
+		"102|bio|aws|CANCELLED by 1000|1|60|Unknown|\n"+
+		"short|line\n", 1)
+
+	assert.Len(t, records, 2)
+	assert.Equal(t, "chem", records[0].Account)
+	assert.Equal(t, 4, records[0].Nodes)
+	assert.Equal(t, 2*time.Hour, records[0].Elapsed)
+	assert.Equal(t, 2026, records[0].End.Year())
+	assert.Equal(t, `aws_meta:{"cost":3.5}`, records[0].Metadata)
+
+	assert.Equal(t, "CANCELLED", records[1].State)
+	assert.True(t, records[1].End.IsZero())
+}
+
+func TestParseAccounting_ChunkedMetadata(t *testing.T) {
+	records := parseAccounting("101|chem|aws|COMPLETED|4|7200|2026-03-02T10:00:00|note aws_meta.1/2:{\"cost\"|aws_meta.2/2::3.5}\n", 2)
+
+	require.Len(t, records, 1)
+	assert.Equal(t, `note aws_meta:{"cost":3.5}`, records[0].Metadata)
+}
@@ -0,0 +1,52 @@
+package slurm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Association is one row of the Slurm association tree: an account under its
+// parent, or a user in an account, and the QOS it may use
+type Association struct {
+	Account string
+	User    string // Empty for an account's own association
+	Parent  string // Parent account of an account association
+	QOS     []string
+}
+
+// Associations returns the associations in the Slurm accounting database
+func (c *Client) Associations(ctx context.Context) ([]Association, error) {
+	output, err := c.command(ctx, "sacctmgr", "show", "associations", "--noheader", "--parsable2", "format=Account,User,ParentName,QOS")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query associations: %w", err)
+	}
+	return ParseAssociations(string(output)), nil
+}
+
+// ParseAssociations parses "account|user|parent|qos" lines, as sacctmgr
+// --parsable2 prints them, with the QOS comma-separated. Lines that are not
+// associations are skipped.
+func ParseAssociations(output string) []Association {
+	var associations []Association
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) != 4 || strings.TrimSpace(fields[0]) == "" {
+			continue
+		}
+		association := Association{
+			Account: strings.TrimSpace(fields[0]),
+			User:    strings.TrimSpace(fields[1]),
+			Parent:  strings.TrimSpace(fields[2]),
+		}
+		for _, qos := range strings.Split(fields[3], ",") {
+			if qos = strings.TrimSpace(qos); qos != "" {
+				association.QOS = append(association.QOS, qos)
+			}
+		}
+		associations = append(associations, association)
+	}
+	return associations
+}
@@ -0,0 +1,25 @@
+package slurm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Associations(t *testing.T) {
+	client := newFakeCommandsClient(t, map[string]string{
+		"sacctmgr": catScript(t, "root|||normal\nscience||root|normal,student\n chem||science|normal\nchem|alice||normal,urgent\nchem|bob||\nbad line\n"),
+	})
+
+	associations, err := client.Associations(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Association{
+		{Account: "root", QOS: []string{"normal"}},
+		{Account: "science", Parent: "root", QOS: []string{"normal", "student"}},
+		{Account: "chem", Parent: "science", QOS: []string{"normal"}},
+		{Account: "chem", User: "alice", QOS: []string{"normal", "urgent"}},
+		{Account: "chem", User: "bob"},
+	}, associations)
+}
@@ -2,14 +2,20 @@ package slurm
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/scttfrdmn/aws-slurm-burst/internal/chaos"
 	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
 	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
 	"go.uber.org/zap"
@@ -19,33 +25,80 @@ import (
 type Client struct {
 	logger *zap.Logger
 	config *config.SlurmConfig
+	run    CommandRunner
+	faults *chaos.Injector // Game-day failure injection, nil unless configured
+
+	noJSON sync.Map // Commands that failed to produce --json output
+
+	versionOnce sync.Once
+	version     Version
+	versionErr  error
 }
 
 // NodeInfo represents information about a Slurm node
 type NodeInfo struct {
-	NodeName string
-	State    string
-	Reason   string
+	NodeName         string
+	State            string
+	Reason           string
+	Features         string    // AvailableFeatures, comma-separated
+	AvailabilityZone string    // From the node's az- feature, if set
+	Comment          string    // Burst placement metadata set at resume, if any
+	SlurmdStartTime  time.Time // When the node's slurmd last registered, if known
+}
+
+// CommandRunner executes a Slurm command and returns its standard output; it
+// is replaced in tests
+type CommandRunner func(ctx context.Context, program string, args ...string) ([]byte, error)
+
+// execRunner runs program as a subprocess, adding its stderr to any error
+func execRunner(ctx context.Context, program string, args ...string) ([]byte, error) {
+	output, err := exec.CommandContext(ctx, program, args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) > 0 {
+		err = fmt.Errorf("%w: %s", err, bytes.TrimSpace(exitErr.Stderr))
+	}
+	return output, err
 }
 
 // NewClient creates a new Slurm client
 func NewClient(logger *zap.Logger, slurmConfig *config.SlurmConfig) *Client {
+	return NewClientWithRunner(logger, slurmConfig, execRunner)
+}
+
+// NewClientWithRunner creates a Slurm client that runs scontrol, squeue and
+// sacct through run
+func NewClientWithRunner(logger *zap.Logger, slurmConfig *config.SlurmConfig, run CommandRunner) *Client {
 	return &Client{
 		logger: logger,
 		config: slurmConfig,
+		run:    run,
 	}
 }
 
+// InjectFailures makes the failure_injection share of node updates fail, when
+// failure injection is active
+func (c *Client) InjectFailures(failureInjection config.FailureInjectionConfig) {
+	c.faults = chaos.New(c.logger, failureInjection)
+}
+
+// command runs a Slurm command from BinPath
+func (c *Client) command(ctx context.Context, name string, args ...string) ([]byte, error) {
+	run := c.run
+	if run == nil {
+		run = execRunner
+	}
+	return run(ctx, c.config.BinPath+name, args...)
+}
+
 // ParseNodeList expands a Slurm hostlist using scontrol show hostnames (following original plugin)
 func (c *Client) ParseNodeList(hostlist string) ([]string, error) {
 	// Check if Slurm is available, fallback to mock parsing if not
-	if !DetectSlurmAvailability(c.logger, c.config) {
+	if _, err := c.Version(); err != nil {
 		c.logger.Warn("Slurm not available, using mock node list parsing")
 		return c.parseNodeListMock(hostlist), nil
 	}
 
-	cmd := exec.Command(c.config.BinPath+"scontrol", "show", "hostnames", hostlist)
-	output, err := cmd.Output()
+	output, err := c.command(context.Background(), "scontrol", "show", "hostnames", hostlist)
 	if err != nil {
 		c.logger.Warn("Slurm command failed, using mock node list parsing", zap.Error(err))
 		return c.parseNodeListMock(hostlist), nil
@@ -101,15 +154,19 @@ func (c *Client) parseNodeListMock(hostlist string) []string {
 	return []string{hostlist}
 }
 
-// ParseNodeNames parses node names into partition/nodegroup structure (following original plugin pattern)
+// NodeName rebuilds a node name from the parts ParseNodeNames returns
+func (c *Client) NodeName(partitionName, nodeGroupName, nodeID string) string {
+	return c.config.NodeNaming.NodeName(partitionName, nodeGroupName, nodeID)
+}
+
+// ParseNodeNames parses node names into partition/nodegroup structure, with
+// names built as slurm.node_naming configures (e.g., aws-gpu-001)
 func (c *Client) ParseNodeNames(nodeNames []string) map[string]map[string][]string {
 	result := make(map[string]map[string][]string)
-
-	// Pattern matches: partition-nodegroup-id (e.g., aws-gpu-001)
-	pattern := regexp.MustCompile(`^([a-zA-Z0-9]+)-([a-zA-Z0-9]+)-([0-9]+)$`)
+	nodeNamePattern := c.config.NodeNaming.Pattern()
 
 	for _, nodeName := range nodeNames {
-		matches := pattern.FindStringSubmatch(nodeName)
+		matches := nodeNamePattern.FindStringSubmatch(nodeName)
 		if len(matches) != 4 {
 			c.logger.Warn("Invalid node name format", zap.String("node", nodeName))
 			continue
@@ -129,42 +186,80 @@ func (c *Client) ParseNodeNames(nodeNames []string) map[string]map[string][]stri
 	return result
 }
 
+// NodeGroupNodes is the requested nodes of one partition and node group
+type NodeGroupNodes struct {
+	Partition string
+	NodeGroup string
+	Nodes     []string // Full node names, e.g. aws-cpu-001
+}
+
+// GroupNodes groups node names by partition and node group, ordered by partition
+// then node group. It fails on names ParseNodeNames cannot place.
+func (c *Client) GroupNodes(nodeNames []string) ([]NodeGroupNodes, error) {
+	parsed := c.ParseNodeNames(nodeNames)
+
+	var groups []NodeGroupNodes
+	grouped := 0
+	for partition, byGroup := range parsed {
+		for nodeGroup, nodeIDs := range byGroup {
+			group := NodeGroupNodes{Partition: partition, NodeGroup: nodeGroup}
+			for _, nodeID := range nodeIDs {
+				group.Nodes = append(group.Nodes, c.NodeName(partition, nodeGroup, nodeID))
+			}
+			groups = append(groups, group)
+			grouped += len(nodeIDs)
+		}
+	}
+	if grouped != len(nodeNames) {
+		var invalid []string
+		for _, nodeName := range nodeNames {
+			if _, _, _, ok := c.config.NodeNaming.ParseNodeName(nodeName); !ok {
+				invalid = append(invalid, nodeName)
+			}
+		}
+		return nil, fmt.Errorf("node names not in %s form: %s",
+			c.config.NodeNaming.NodeName("partition", "nodegroup", "id"), strings.Join(invalid, ","))
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Partition != groups[j].Partition {
+			return groups[i].Partition < groups[j].Partition
+		}
+		return groups[i].NodeGroup < groups[j].NodeGroup
+	})
+	return groups, nil
+}
+
 // GetJobForNodes attempts to find the job associated with the given nodes
 func (c *Client) GetJobForNodes(ctx context.Context, nodeIds []string) (*types.SlurmJob, error) {
-	// Try to get job information from squeue
-	cmd := exec.CommandContext(ctx, c.config.BinPath+"squeue", "-w", strings.Join(nodeIds, ","), "-o", "%i,%j,%P,%D,%C,%m,%t,%S,%L", "--noheader")
-	output, err := cmd.Output()
+	queued, err := c.nodeJob(ctx, nodeIds)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query jobs for nodes: %w", err)
+		return nil, err
 	}
 
-	if strings.TrimSpace(string(output)) == "" {
-		return nil, fmt.Errorf("no job found for nodes")
-	}
+	jobID := queued.ID
+	jobName := queued.Name
+	partition := queued.Partition
+	nodes := queued.Nodes
+	cpus := queued.CPUs
+	memory := queued.MemoryMB
 
-	// Parse first job found (format: jobid,name,partition,nodes,cpus,memory,state,start_time,time_limit)
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	fields := strings.Split(lines[0], ",")
-	if len(fields) < 9 {
-		return nil, fmt.Errorf("invalid squeue output format")
+	cpusPerNode := cpus
+	if nodes > 0 {
+		cpusPerNode = cpus / nodes // Approximate
 	}
 
-	jobID := strings.TrimSpace(fields[0])
-	jobName := strings.TrimSpace(fields[1])
-	partition := strings.TrimSpace(fields[2])
-
-	nodes, _ := strconv.Atoi(strings.TrimSpace(fields[3]))
-	cpus, _ := strconv.Atoi(strings.TrimSpace(fields[4]))
-	memory := c.parseMemory(strings.TrimSpace(fields[5]))
-
 	job := &types.SlurmJob{
 		JobID:     jobID,
 		Name:      jobName,
 		Partition: partition,
+		User:      queued.User,
+		Account:   queued.Account,
+		QOS:       queued.QOS,
 		NodeList:  nodeIds,
 		Resources: types.ResourceSpec{
 			Nodes:       nodes,
-			CPUsPerNode: cpus / nodes, // Approximate
+			CPUsPerNode: cpusPerNode,
 			MemoryMB:    memory,
 		},
 		Constraints: types.JobConstraints{},
@@ -173,6 +268,9 @@ func (c *Client) GetJobForNodes(ctx context.Context, nodeIds []string) (*types.S
 		SubmitTime:  time.Now(), // Approximation
 	}
 
+	job.Constraints.Features = ParseConstraintFeatures(queued.Features)
+	job.Constraints.FeatureGroups = ParseConstraintGroups(queued.Features)
+
 	// Try to get job script for better analysis
 	if script, err := c.getJobScript(ctx, jobID); err == nil {
 		job.Script = script
@@ -189,10 +287,24 @@ func (c *Client) GetJobForNodes(ctx context.Context, nodeIds []string) (*types.S
 	return job, nil
 }
 
-// getJobScript attempts to retrieve the job script
+// getJobScript retrieves the full batch script so #SBATCH directives can be analyzed
 func (c *Client) getJobScript(ctx context.Context, jobID string) (string, error) {
-	cmd := exec.CommandContext(ctx, c.config.BinPath+"scontrol", "show", "job", jobID)
-	output, err := cmd.Output()
+	output, err := c.command(ctx, "scontrol", "write", "batch_script", jobID, "-")
+	if err == nil && strings.TrimSpace(string(output)) != "" {
+		return string(output), nil
+	}
+
+	// Interactive jobs have no batch script, and older Slurm cannot write to stdout
+	c.logger.Debug("Batch script unavailable, falling back to job command",
+		zap.String("job_id", jobID),
+		zap.Error(err))
+
+	return c.getJobCommand(ctx, jobID)
+}
+
+// getJobCommand reads the job's Command= field, loading the script file when it is readable
+func (c *Client) getJobCommand(ctx context.Context, jobID string) (string, error) {
+	output, err := c.command(ctx, "scontrol", "show", "job", jobID)
 	if err != nil {
 		return "", err
 	}
@@ -203,7 +315,11 @@ func (c *Client) getJobScript(ctx context.Context, jobID string) (string, error)
 		if strings.Contains(line, "Command=") {
 			parts := strings.SplitN(line, "Command=", 2)
 			if len(parts) == 2 {
-				return parts[1], nil
+				command := strings.TrimSpace(parts[1])
+				if script, err := os.ReadFile(command); err == nil {
+					return string(script), nil
+				}
+				return command, nil
 			}
 		}
 	}
@@ -222,25 +338,52 @@ func (c *Client) parseJobScript(job *types.SlurmJob) {
 
 	// Check for MPI indicators in script content
 	c.checkMPIIndicators(job)
+
+	// Burst buffer directives declare scratch and data staging
+	if job.Staging = parseStagingDirectives(job.Script); job.Staging != nil {
+		job.Resources.LocalStorage = job.Staging.ScratchGB
+	}
+
+	// #ASBX pragmas steer how the job bursts in standalone mode
+	job.Overrides = parseJobPragmas(job.Script)
+
+	// srun steps can run in a container the batch script itself does not
+	if job.Container == "" {
+		if match := srunContainerPattern.FindStringSubmatch(job.Script); match != nil {
+			job.Container = strings.Trim(match[1], `"'`)
+		}
+	}
+}
+
+// srunContainerPattern matches an srun step run in a container image by pyxis
+var srunContainerPattern = regexp.MustCompile(`(?m)^\s*srun\b.*\s--container-image[=\s]+([^\s]+)`)
+
+// sbatchShortOptions maps single-letter #SBATCH options to their long names
+var sbatchShortOptions = map[string]string{
+	"C": "constraint",
+	"G": "gpus",
+	"N": "nodes",
+	"c": "cpus-per-task",
+	"n": "ntasks",
+	"t": "time",
+	"x": "exclude",
 }
 
 // parseSBatchDirectives parses SBATCH directives from the job script
 func (c *Client) parseSBatchDirectives(job *types.SlurmJob) {
-	sbatchPattern := regexp.MustCompile(`#SBATCH\s+--([a-zA-Z-]+)(?:[=\s]+([^\s]+))?`)
+	sbatchPattern := regexp.MustCompile(`(?m)^#SBATCH\s+(?:--([a-zA-Z-]+)|-([a-zA-Z]))(?:[=\s]+([^\s]+))?`)
 	matches := sbatchPattern.FindAllStringSubmatch(job.Script, -1)
 
 	for _, match := range matches {
-		if len(match) < 2 {
-			continue
-		}
-
 		directive := match[1]
-		value := ""
-		if len(match) > 2 {
-			value = match[2]
+		if directive == "" {
+			directive = sbatchShortOptions[match[2]]
+		}
+		if directive == "" {
+			continue
 		}
 
-		c.processSBatchDirective(job, directive, value)
+		c.processSBatchDirective(job, directive, strings.Trim(match[3], `"'`))
 	}
 }
 
@@ -249,7 +392,8 @@ func (c *Client) processSBatchDirective(job *types.SlurmJob, directive, value st
 	switch directive {
 	case "constraint", "C":
 		if value != "" {
-			job.Constraints.Features = strings.Split(value, "&")
+			job.Constraints.Features = ParseConstraintFeatures(value)
+			job.Constraints.FeatureGroups = ParseConstraintGroups(value)
 		}
 	case "exclude":
 		if value != "" {
@@ -259,13 +403,61 @@ func (c *Client) processSBatchDirective(job *types.SlurmJob, directive, value st
 		if memory := c.parseMemory(value); memory > 0 {
 			job.Resources.MemoryMB = memory
 		}
+	case "mem-per-cpu":
+		if memory := c.parseMemory(value); memory > 0 {
+			job.Resources.MemoryPerCPUMB = memory
+		}
 	case "gres":
 		c.parseGRESDirective(job, value)
+	case "gpus", "gpus-per-node":
+		c.parseGPUsDirective(job, value)
 	case "time":
 		if duration, err := c.parseDuration(value); err == nil {
 			job.TimeLimit = types.Duration(duration)
 		}
+	case "container", "container-image":
+		job.Container = value
+	}
+}
+
+// constraintTerms splits a constraint expression at its & (or ,) operators
+var constraintTerms = regexp.MustCompile(`[&,]+`)
+
+// ParseConstraintFeatures extracts feature names from a Slurm constraint expression
+// such as "avx512&nvme", "[icelake|sapphirerapids]" or "a100*2"
+func ParseConstraintFeatures(constraint string) []string {
+	var features []string
+	for _, alternatives := range ParseConstraintGroups(constraint) {
+		features = append(features, alternatives...)
 	}
+	return features
+}
+
+// ParseConstraintGroups splits a Slurm constraint expression into the terms
+// that must all hold, each listing the features it accepts: "[icelake|
+// sapphirerapids]&nvme" gives [[icelake sapphirerapids] [nvme]]. Brackets,
+// parentheses and node counts such as "*2" are dropped.
+func ParseConstraintGroups(constraint string) [][]string {
+	if constraint == "" || constraint == "(null)" {
+		return nil
+	}
+
+	var groups [][]string
+	for _, term := range constraintTerms.Split(constraint, -1) {
+		var alternatives []string
+		for _, feature := range strings.Split(term, "|") {
+			if idx := strings.Index(feature, "*"); idx >= 0 {
+				feature = feature[:idx]
+			}
+			if feature = strings.Trim(feature, "[]() \t"); feature != "" {
+				alternatives = append(alternatives, feature)
+			}
+		}
+		if len(alternatives) > 0 {
+			groups = append(groups, alternatives)
+		}
+	}
+	return groups
 }
 
 // parseGRESDirective parses GRES (Generic Resource) directives
@@ -285,6 +477,17 @@ func (c *Client) parseGRESDirective(job *types.SlurmJob, value string) {
 	}
 }
 
+// parseGPUsDirective parses --gpus / --gpus-per-node values like "4" or "a100:4"
+func (c *Client) parseGPUsDirective(job *types.SlurmJob, value string) {
+	parts := strings.Split(value, ":")
+	if gpus, err := strconv.Atoi(parts[len(parts)-1]); err == nil {
+		job.Resources.GPUs = gpus
+	}
+	if len(parts) >= 2 {
+		job.Resources.GPUType = parts[0]
+	}
+}
+
 // checkMPIIndicators checks for MPI indicators in the script
 func (c *Client) checkMPIIndicators(job *types.SlurmJob) {
 	script := strings.ToLower(job.Script)
@@ -339,6 +542,25 @@ func (c *Client) parseDuration(timeStr string) (time.Duration, error) {
 		return 0, fmt.Errorf("empty time string")
 	}
 
+	// Slurm allows a day prefix: "days-hours[:minutes[:seconds]]"
+	var days time.Duration
+	if idx := strings.Index(timeStr, "-"); idx > 0 {
+		d, err := strconv.Atoi(timeStr[:idx])
+		if err != nil {
+			return 0, fmt.Errorf("unable to parse time: %s", timeStr)
+		}
+		days = time.Duration(d) * 24 * time.Hour
+		timeStr = timeStr[idx+1:]
+		if !strings.Contains(timeStr, ":") {
+			hours, err := strconv.Atoi(timeStr)
+			if err != nil {
+				return 0, fmt.Errorf("unable to parse time: %s", timeStr)
+			}
+			return days + time.Duration(hours)*time.Hour, nil
+		}
+		timeStr += strings.Repeat(":00", 2-strings.Count(timeStr, ":"))
+	}
+
 	// Try different formats
 	if strings.Contains(timeStr, ":") {
 		// Format like "02:00:00" or "120:00"
@@ -365,7 +587,7 @@ func (c *Client) parseDuration(timeStr string) (time.Duration, error) {
 			}
 		}
 
-		return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second, nil
+		return days + time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second, nil
 	}
 
 	// Try as minutes
@@ -401,11 +623,14 @@ func (c *Client) UpdateNodesWithInstanceInfo(ctx context.Context, instances []ty
 
 // UpdateNode updates a Slurm node using scontrol (following original plugin pattern)
 func (c *Client) UpdateNode(nodeName, parameters string) error {
+	if err := c.faults.SlurmUpdate(nodeName); err != nil {
+		return fmt.Errorf("failed to update node %s: %w", nodeName, err)
+	}
+
 	args := []string{"update", "nodename=" + nodeName}
 	args = append(args, strings.Split(parameters, " ")...)
 
-	cmd := exec.Command(c.config.BinPath+"scontrol", args...)
-	if err := cmd.Run(); err != nil {
+	if _, err := c.command(context.Background(), "scontrol", args...); err != nil {
 		return fmt.Errorf("failed to update node %s: %w", nodeName, err)
 	}
 
@@ -416,14 +641,32 @@ func (c *Client) UpdateNode(nodeName, parameters string) error {
 	return nil
 }
 
-// GetNodeState retrieves the state of specified nodes
-func (c *Client) GetNodeState(nodeNames []string) ([]NodeInfo, error) {
+// UpdateNodes applies the same update to all nodes with one scontrol call
+func (c *Client) UpdateNodes(nodeNames []string, parameters string) error {
 	if len(nodeNames) == 0 {
-		return nil, nil
+		return nil
 	}
+	return c.UpdateNode(CompressHostlist(nodeNames), parameters)
+}
 
-	cmd := exec.Command(c.config.BinPath+"scontrol", "show", "node", strings.Join(nodeNames, ","), "-o")
-	output, err := cmd.Output()
+// GetNodeState retrieves the state of specified nodes, querying scontrol in
+// batches of nodeStateBatchSize
+func (c *Client) GetNodeState(nodeNames []string) ([]NodeInfo, error) {
+	var nodes []NodeInfo
+	for start := 0; start < len(nodeNames); start += nodeStateBatchSize {
+		end := min(start+nodeStateBatchSize, len(nodeNames))
+		batch, err := c.showNodes(CompressHostlist(nodeNames[start:end]))
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, batch...)
+	}
+	return nodes, nil
+}
+
+// showNodeText reads node state from scontrol's one-line-per-node text output
+func (c *Client) showNodeText(hostlist string) ([]NodeInfo, error) {
+	output, err := c.command(context.Background(), "scontrol", "show", "node", hostlist, "-o")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get node state: %w", err)
 	}
@@ -458,6 +701,18 @@ func (c *Client) GetNodeState(nodeNames []string) ([]NodeInfo, error) {
 					nodeInfo.State = value
 				case "Reason":
 					nodeInfo.Reason = value
+				case "AvailableFeatures", "Features":
+					nodeInfo.Features = value
+					nodeInfo.AvailabilityZone = AvailabilityZoneFromFeatures(value)
+				case "Comment":
+					if value != "(null)" {
+						nodeInfo.Comment = value
+					}
+				case "SlurmdStartTime":
+					// Local time; "None" before the node has registered
+					if started, err := time.ParseInLocation("2006-01-02T15:04:05", value, time.Local); err == nil {
+						nodeInfo.SlurmdStartTime = started
+					}
 				}
 			}
 		}
@@ -470,7 +725,15 @@ func (c *Client) GetNodeState(nodeNames []string) ([]NodeInfo, error) {
 	return nodes, nil
 }
 
-// SetNodeState sets the state of a node (following original change_state.py patterns)
+// SetNodesState sets the state of all nodes with one scontrol call
+func (c *Client) SetNodesState(nodeNames []string, state, reason string) error {
+	if len(nodeNames) == 0 {
+		return nil
+	}
+	return c.SetNodeState(CompressHostlist(nodeNames), state, reason)
+}
+
+// SetNodeState sets the state of a node or hostlist (following original change_state.py patterns)
 func (c *Client) SetNodeState(nodeName, state, reason string) error {
 	parameters := fmt.Sprintf("state=%s", state)
 	if reason != "" {
@@ -0,0 +1,244 @@
+package slurm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/chaos"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// newFakeScontrolClient returns a client whose BinPath holds a fake scontrol
+// script. Like Slurm before 23.02, it has no --json output.
+func newFakeScontrolClient(t *testing.T, script string) *Client {
+	return newFakeScontrolClientJSON(t, "[ \"$1\" = --version ] && echo 'slurm 22.05.9' && exit 0\n"+
+		"[ \"$1\" = --json ] && exit 1\n"+script)
+}
+
+// newFakeScontrolClientJSON is newFakeScontrolClient with script also handling --json
+func newFakeScontrolClientJSON(t *testing.T, script string) *Client {
+	return newFakeCommandsClient(t, map[string]string{"scontrol": script})
+}
+
+// newFakeCommandsClient returns a client whose BinPath holds fake scripts for
+// Slurm commands, by command name
+func newFakeCommandsClient(t *testing.T, scripts map[string]string) *Client {
+	dir := t.TempDir()
+	for command, script := range scripts {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, command), []byte("#!/bin/sh\n"+script), 0755))
+	}
+
+	return NewClient(zaptest.NewLogger(t), &config.SlurmConfig{BinPath: dir + "/"})
+}
+
+func TestNewClientWithRunner(t *testing.T) {
+	var calls []string
+	run := func(ctx context.Context, program string, args ...string) ([]byte, error) {
+		calls = append(calls, program+" "+strings.Join(args, " "))
+		switch {
+		case args[0] == "--version":
+			return []byte("slurm 23.02.7\n"), nil
+		case args[0] == "--json":
+			return nil, errors.New("exit status 1: scontrol: unrecognized option '--json'")
+		case args[0] == "show":
+			return []byte("NodeName=aws-cpu-001 State=IDLE+CLOUD\n"), nil
+		}
+		return nil, nil
+	}
+	client := NewClientWithRunner(zaptest.NewLogger(t), &config.SlurmConfig{BinPath: "/opt/slurm/bin/"}, run)
+
+	drained, err := client.DrainNodes(context.Background(), []string{"aws-cpu-001"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws-cpu-001"}, drained)
+	assert.Equal(t, []string{
+		"/opt/slurm/bin/scontrol --version",
+		"/opt/slurm/bin/scontrol --json show node aws-cpu-001",
+		"/opt/slurm/bin/scontrol show node aws-cpu-001 -o",
+		"/opt/slurm/bin/scontrol update nodename=aws-cpu-001 state=DRAIN reason=" + drainReason,
+	}, calls)
+}
+
+func TestClient_InjectFailures(t *testing.T) {
+	t.Setenv(config.FailureInjectionEnv, "1")
+	var calls int
+	client := NewClientWithRunner(zaptest.NewLogger(t), &config.SlurmConfig{}, func(ctx context.Context, program string, args ...string) ([]byte, error) {
+		calls++
+		return nil, nil
+	})
+	client.InjectFailures(config.FailureInjectionConfig{Enabled: true, SlurmUpdateErrorRate: 1})
+
+	err := client.SetNodesState([]string{"aws-cpu-1", "aws-cpu-2"}, "DOWN", "injected")
+	var injected *chaos.InjectedError
+	require.ErrorAs(t, err, &injected)
+	assert.Equal(t, "aws-cpu-[1-2]", injected.Target)
+	assert.Zero(t, calls, "scontrol not run")
+}
+
+func TestClient_getJobScript(t *testing.T) {
+	t.Run("batch script from scontrol write", func(t *testing.T) {
+		client := newFakeScontrolClient(t, `
+if [ "$1" = "write" ] && [ "$2" = "batch_script" ] && [ "$4" = "-" ]; then
+  printf '#!/bin/bash\n#SBATCH --nodes=4\nmpirun ./solver\n'
+  exit 0
+fi
+exit 1
+`)
+
+		script, err := client.getJobScript(context.Background(), "1234")
+		require.NoError(t, err)
+		assert.Contains(t, script, "#SBATCH --nodes=4")
+		assert.Contains(t, script, "mpirun ./solver")
+	})
+
+	t.Run("falls back to command file", func(t *testing.T) {
+		commandFile := filepath.Join(t.TempDir(), "job.sh")
+		require.NoError(t, os.WriteFile(commandFile, []byte("#!/bin/bash\nsrun ./app\n"), 0644))
+
+		client := newFakeScontrolClient(t, `
+if [ "$1" = "show" ]; then
+  echo "JobId=1234 JobName=app"
+  echo "   Command=`+commandFile+`"
+  exit 0
+fi
+echo "batch script unavailable" >&2
+exit 1
+`)
+
+		script, err := client.getJobScript(context.Background(), "1234")
+		require.NoError(t, err)
+		assert.Equal(t, "#!/bin/bash\nsrun ./app\n", script)
+	})
+
+	t.Run("falls back to inline command", func(t *testing.T) {
+		client := newFakeScontrolClient(t, `
+if [ "$1" = "show" ]; then
+  echo "   Command=/nonexistent/run.sh --flag"
+  exit 0
+fi
+exit 1
+`)
+
+		script, err := client.getJobScript(context.Background(), "1234")
+		require.NoError(t, err)
+		assert.Equal(t, "/nonexistent/run.sh --flag", script)
+	})
+}
+
+func TestClient_parseSBatchDirectives(t *testing.T) {
+	client := NewClient(zaptest.NewLogger(t), &config.SlurmConfig{})
+
+	job := &types.SlurmJob{Script: `#!/bin/bash
+#SBATCH -C "avx512&nvme"
+#SBATCH -t 1-02:30:00
+#SBATCH -x node[1-2]
+#SBATCH --gpus=a100:4
+#SBATCH --mem-per-cpu=2G
+srun ./app
+`}
+	client.parseSBatchDirectives(job)
+
+	assert.Equal(t, []string{"avx512", "nvme"}, job.Constraints.Features)
+	assert.Equal(t, [][]string{{"avx512"}, {"nvme"}}, job.Constraints.FeatureGroups)
+	assert.Equal(t, []string{"node[1-2]"}, job.Constraints.ExcludeNodes)
+	assert.Equal(t, types.Duration(26*time.Hour+30*time.Minute), job.TimeLimit)
+	assert.Equal(t, 4, job.Resources.GPUs)
+	assert.Equal(t, "a100", job.Resources.GPUType)
+	assert.Equal(t, 2048, job.Resources.MemoryPerCPUMB)
+	assert.Empty(t, job.Container)
+}
+
+func TestParseConstraintGroups(t *testing.T) {
+	tests := []struct {
+		constraint string
+		expected   [][]string
+	}{
+		{"", nil},
+		{"(null)", nil},
+		{"avx512&nvme", [][]string{{"avx512"}, {"nvme"}}},
+		{"[icelake|sapphirerapids]", [][]string{{"icelake", "sapphirerapids"}}},
+		{"[icelake|sapphirerapids]&nvme", [][]string{{"icelake", "sapphirerapids"}, {"nvme"}}},
+		{"(a100|h100)*2&efa", [][]string{{"a100", "h100"}, {"efa"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseConstraintGroups(tt.constraint))
+		})
+	}
+	assert.Equal(t, []string{"icelake", "sapphirerapids", "nvme"}, ParseConstraintFeatures("[icelake|sapphirerapids]&nvme"))
+}
+
+func TestClient_parseJobScriptContainer(t *testing.T) {
+	client := NewClient(zaptest.NewLogger(t), &config.SlurmConfig{})
+
+	job := &types.SlurmJob{Script: "#!/bin/bash\n#SBATCH --container=/shared/bundles/app\nsrun ./app\n"}
+	client.parseJobScript(job)
+	assert.Equal(t, "/shared/bundles/app", job.Container)
+
+	job = &types.SlurmJob{Script: "#!/bin/bash\n#SBATCH -N 2\nsrun --mpi=pmix --container-image=nvcr.io#nvidia/pytorch:24.05 python train.py\n"}
+	client.parseJobScript(job)
+	assert.Equal(t, "nvcr.io#nvidia/pytorch:24.05", job.Container)
+}
+
+func TestClient_parseDuration(t *testing.T) {
+	client := NewClient(zaptest.NewLogger(t), &config.SlurmConfig{})
+
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{"30", 30 * time.Minute},
+		{"1:30:00", 90 * time.Minute},
+		{"2-00:00:00", 48 * time.Hour},
+		{"1-12", 36 * time.Hour},
+		{"1-01:30", 25*time.Hour + 30*time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			duration, err := client.parseDuration(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, duration)
+		})
+	}
+}
+
+func TestClient_GroupNodes(t *testing.T) {
+	client := NewClient(zaptest.NewLogger(t), &config.SlurmConfig{})
+
+	groups, err := client.GroupNodes([]string{"aws-gpu-001", "aws-cpu-002", "awswest-cpu-001", "aws-cpu-001"})
+	require.NoError(t, err)
+	assert.Equal(t, []NodeGroupNodes{
+		{Partition: "aws", NodeGroup: "cpu", Nodes: []string{"aws-cpu-002", "aws-cpu-001"}},
+		{Partition: "aws", NodeGroup: "gpu", Nodes: []string{"aws-gpu-001"}},
+		{Partition: "awswest", NodeGroup: "cpu", Nodes: []string{"awswest-cpu-001"}},
+	}, groups)
+
+	_, err = client.GroupNodes([]string{"aws-cpu-001", "login1", "aws-cpu-x"})
+	assert.ErrorContains(t, err, "login1,aws-cpu-x")
+}
+
+func TestClient_GroupNodesCustomNaming(t *testing.T) {
+	client := NewClient(zaptest.NewLogger(t), &config.SlurmConfig{
+		NodeNaming: config.NodeNamingConfig{Prefix: "prod", Separator: "_", IDFormat: "%03d"},
+	})
+
+	groups, err := client.GroupNodes([]string{"prod_aws_cpu_001", "prod_aws_cpu_002"})
+	require.NoError(t, err)
+	assert.Equal(t, []NodeGroupNodes{
+		{Partition: "aws", NodeGroup: "cpu", Nodes: []string{"prod_aws_cpu_001", "prod_aws_cpu_002"}},
+	}, groups)
+
+	_, err = client.GroupNodes([]string{"aws-cpu-001"})
+	assert.ErrorContains(t, err, "prod_partition_nodegroup_id")
+}
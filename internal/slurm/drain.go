@@ -0,0 +1,111 @@
+package slurm
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// drainReason marks nodes drained by suspend, so only those are undrained afterwards
+const drainReason = "aws-slurm-burst-suspend"
+
+// drainPollInterval is how often drained nodes are checked for running work
+var drainPollInterval = 5 * time.Second
+
+// DrainNodes sets nodes to DRAIN so no new work lands on them before suspend. Nodes
+// already drained (e.g. by an administrator) are left alone; it returns the nodes
+// it drained.
+func (c *Client) DrainNodes(ctx context.Context, nodeNames []string) ([]string, error) {
+	nodes, err := c.GetNodeState(nodeNames)
+	if err != nil {
+		return nil, err
+	}
+
+	var drained []string
+	for _, node := range nodes {
+		if !hasStateFlag(node.State, "DRAIN") {
+			drained = append(drained, node.NodeName)
+		}
+	}
+
+	if err := c.SetNodesState(drained, "DRAIN", drainReason); err != nil {
+		return nil, fmt.Errorf("failed to drain nodes %s: %w", CompressHostlist(drained), err)
+	}
+	return drained, nil
+}
+
+// WaitForNodesIdle waits until no job step or epilog runs on the nodes, up to the
+// timeout. It returns the nodes still busy when the wait ends.
+func (c *Client) WaitForNodesIdle(ctx context.Context, nodeNames []string, timeout time.Duration) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		nodes, err := c.GetNodeState(nodeNames)
+		if err != nil {
+			return nodeNames, err
+		}
+
+		var busy []string
+		for _, node := range nodes {
+			if nodeBusy(node.State) {
+				busy = append(busy, node.NodeName)
+			}
+		}
+		if len(busy) == 0 {
+			return nil, nil
+		}
+
+		c.logger.Debug("Waiting for nodes to finish running work", zap.Strings("nodes", busy))
+
+		select {
+		case <-ctx.Done():
+			return busy, nil
+		case <-time.After(drainPollInterval):
+		}
+	}
+}
+
+// RunShutdownHook runs a command on the node over ssh, e.g. to flush local scratch
+// to S3 before the instance goes away
+func (c *Client) RunShutdownHook(ctx context.Context, nodeName, hook string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ssh", "-o", "BatchMode=yes", "-o", "ConnectTimeout=10", nodeName, hook)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("shutdown hook failed on %s: %w: %s", nodeName, err, strings.TrimSpace(string(output)))
+	}
+
+	c.logger.Info("Ran shutdown hook", zap.String("node", nodeName), zap.String("hook", hook))
+	return nil
+}
+
+// UndrainNodes clears the DRAIN set by DrainNodes once the nodes are powered down,
+// so Slurm can power them up again for new jobs
+func (c *Client) UndrainNodes(ctx context.Context, nodeNames []string) error {
+	if err := c.SetNodesState(nodeNames, "UNDRAIN", ""); err != nil {
+		return fmt.Errorf("failed to undrain nodes %s: %w", CompressHostlist(nodeNames), err)
+	}
+	return nil
+}
+
+// nodeBusy reports whether a node state shows allocated work or a running epilog
+func nodeBusy(state string) bool {
+	return hasStateFlag(state, "ALLOCATED") || hasStateFlag(state, "ALLOC") ||
+		hasStateFlag(state, "MIXED") || hasStateFlag(state, "COMPLETING")
+}
+
+// hasStateFlag reports whether a compound scontrol state (e.g. MIXED+DRAIN) includes flag
+func hasStateFlag(state, flag string) bool {
+	for _, part := range strings.Split(state, "+") {
+		if strings.EqualFold(part, flag) {
+			return true
+		}
+	}
+	return false
+}
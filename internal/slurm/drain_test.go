@@ -0,0 +1,78 @@
+package slurm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeBusy(t *testing.T) {
+	assert.True(t, nodeBusy("MIXED+DRAIN"))
+	assert.True(t, nodeBusy("ALLOCATED+CLOUD+DRAIN"))
+	assert.True(t, nodeBusy("IDLE+COMPLETING+DRAIN"))
+	assert.False(t, nodeBusy("IDLE+CLOUD+DRAIN"))
+	assert.False(t, nodeBusy("IDLE+POWERING_DOWN"))
+}
+
+func TestClient_DrainNodes(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "scontrol.log")
+	client := newFakeScontrolClient(t, `
+if [ "$1" = "show" ]; then
+  echo "NodeName=aws-cpu-001 State=MIXED+CLOUD"
+  echo "NodeName=aws-cpu-002 State=IDLE+CLOUD+DRAIN Reason=maintenance"
+  exit 0
+fi
+echo "$@" >> `+logFile+`
+`)
+
+	drained, err := client.DrainNodes(context.Background(), []string{"aws-cpu-001", "aws-cpu-002"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws-cpu-001"}, drained, "administrator drain is left alone")
+
+	require.NoError(t, client.UndrainNodes(context.Background(), drained))
+
+	updates, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"update nodename=aws-cpu-001 state=DRAIN reason=" + drainReason,
+		"update nodename=aws-cpu-001 state=UNDRAIN",
+	}, strings.Split(strings.TrimSpace(string(updates)), "\n"))
+}
+
+func TestClient_WaitForNodesIdle(t *testing.T) {
+	defer func(interval time.Duration) { drainPollInterval = interval }(drainPollInterval)
+	drainPollInterval = 10 * time.Millisecond
+
+	t.Run("returns once work finishes", func(t *testing.T) {
+		counter := filepath.Join(t.TempDir(), "polls")
+		client := newFakeScontrolClient(t, `
+echo x >> `+counter+`
+if [ "$(wc -l < `+counter+`)" -lt 3 ]; then
+  echo "NodeName=aws-cpu-001 State=IDLE+COMPLETING+DRAIN"
+else
+  echo "NodeName=aws-cpu-001 State=IDLE+DRAIN"
+fi
+`)
+
+		busy, err := client.WaitForNodesIdle(context.Background(), []string{"aws-cpu-001"}, time.Second)
+		require.NoError(t, err)
+		assert.Empty(t, busy)
+	})
+
+	t.Run("reports nodes still busy at the timeout", func(t *testing.T) {
+		client := newFakeScontrolClient(t, `
+echo "NodeName=aws-cpu-001 State=IDLE+DRAIN"
+echo "NodeName=aws-cpu-002 State=MIXED+DRAIN"
+`)
+
+		busy, err := client.WaitForNodesIdle(context.Background(), []string{"aws-cpu-001", "aws-cpu-002"}, 50*time.Millisecond)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"aws-cpu-002"}, busy)
+	})
+}
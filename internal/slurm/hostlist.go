@@ -0,0 +1,154 @@
+package slurm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hostname is a node name split at its trailing number, e.g. aws-cpu- and 007
+type hostname struct {
+	prefix string
+	digits string // Empty for names without a trailing number
+	number int
+}
+
+func splitHostname(name string) hostname {
+	i := len(name)
+	for i > 0 && name[i-1] >= '0' && name[i-1] <= '9' {
+		i--
+	}
+	// Numbers too long to count in are treated as part of the name
+	number, err := strconv.Atoi(name[i:])
+	if i == len(name) || err != nil {
+		return hostname{prefix: name}
+	}
+	return hostname{prefix: name[:i], digits: name[i:], number: number}
+}
+
+// CompressHostlist writes node names as a sorted Slurm hostlist, e.g.
+// aws-cpu-[001-004,007],aws-gpu-1, the form scontrol show hostlistsorted
+// prints. Zero padding is kept; duplicates are dropped. Slurm takes the
+// result wherever it takes a node list, so bulk updates need one scontrol
+// call with a short command line instead of one call per node.
+func CompressHostlist(nodeNames []string) string {
+	hosts := make([]hostname, 0, len(nodeNames))
+	for _, name := range nodeNames {
+		hosts = append(hosts, splitHostname(name))
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].prefix != hosts[j].prefix {
+			return hosts[i].prefix < hosts[j].prefix
+		}
+		if hosts[i].number != hosts[j].number {
+			return hosts[i].number < hosts[j].number
+		}
+		return len(hosts[i].digits) < len(hosts[j].digits)
+	})
+
+	var entries []string
+	for start := 0; start < len(hosts); {
+		end := start
+		for end < len(hosts) && hosts[end].prefix == hosts[start].prefix {
+			end++
+		}
+		entries = append(entries, compressPrefix(hosts[start:end]))
+		start = end
+	}
+	return strings.Join(entries, ",")
+}
+
+// compressPrefix writes hosts sharing a prefix, sorted by number, as one entry
+func compressPrefix(hosts []hostname) string {
+	prefix := hosts[0].prefix
+	if hosts[0].digits == "" {
+		// The name itself, before any numbered names with the same prefix
+		rest := hosts[1:]
+		for len(rest) > 0 && rest[0].digits == "" {
+			rest = rest[1:]
+		}
+		if len(rest) == 0 {
+			return prefix
+		}
+		return prefix + "," + compressPrefix(rest)
+	}
+
+	var ranges []string
+	for i := 0; i < len(hosts); {
+		first, last := hosts[i], hosts[i]
+		width := len(first.digits)
+		for i++; i < len(hosts); i++ {
+			next := hosts[i]
+			if next.number == last.number && next.digits == last.digits {
+				continue // Duplicate
+			}
+			if next.number != last.number+1 || fmt.Sprintf("%0*d", width, next.number) != next.digits {
+				break
+			}
+			last = next
+		}
+		if first.digits == last.digits {
+			ranges = append(ranges, first.digits)
+		} else {
+			ranges = append(ranges, first.digits+"-"+last.digits)
+		}
+	}
+
+	if len(ranges) == 1 && !strings.Contains(ranges[0], "-") {
+		return prefix + ranges[0]
+	}
+	return prefix + "[" + strings.Join(ranges, ",") + "]"
+}
+
+// ExpandHostlist is the inverse of CompressHostlist for hostlists with at most
+// one bracket per entry, e.g. aws-cpu-[001-003,007],aws-gpu-1. Malformed
+// entries are kept as they are.
+func ExpandHostlist(hostlist string) []string {
+	var nodes []string
+	for _, entry := range splitHostlist(hostlist) {
+		open, close := strings.Index(entry, "["), strings.Index(entry, "]")
+		if open < 0 || close < open {
+			nodes = append(nodes, entry)
+			continue
+		}
+
+		prefix, suffix := entry[:open], entry[close+1:]
+		for _, part := range strings.Split(entry[open+1:close], ",") {
+			first, last, isRange := strings.Cut(part, "-")
+			start, err1 := strconv.Atoi(first)
+			end, err2 := strconv.Atoi(last)
+			if !isRange || err1 != nil || err2 != nil {
+				nodes = append(nodes, prefix+part+suffix)
+				continue
+			}
+			for number := start; number <= end; number++ {
+				nodes = append(nodes, fmt.Sprintf("%s%0*d%s", prefix, len(first), number, suffix))
+			}
+		}
+	}
+	return nodes
+}
+
+// splitHostlist splits a hostlist at the commas outside brackets
+func splitHostlist(hostlist string) []string {
+	var entries []string
+	depth, start := 0, 0
+	for i, r := range hostlist {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				entries = append(entries, hostlist[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(hostlist) {
+		entries = append(entries, hostlist[start:])
+	}
+	return entries
+}
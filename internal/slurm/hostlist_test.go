@@ -0,0 +1,70 @@
+package slurm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressHostlist(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodes    []string
+		expected string
+	}{
+		{name: "empty", nodes: nil, expected: ""},
+		{name: "single node", nodes: []string{"aws-cpu-001"}, expected: "aws-cpu-001"},
+		{name: "zero-padded range", nodes: []string{"aws-cpu-003", "aws-cpu-001", "aws-cpu-002"}, expected: "aws-cpu-[001-003]"},
+		{name: "gaps", nodes: []string{"aws-cpu-1", "aws-cpu-2", "aws-cpu-7", "aws-cpu-9", "aws-cpu-10"}, expected: "aws-cpu-[1-2,7,9-10]"},
+		{name: "padding rolls over", nodes: []string{"aws-cpu-099", "aws-cpu-100"}, expected: "aws-cpu-[099-100]"},
+		{name: "different widths", nodes: []string{"aws-cpu-1", "aws-cpu-002"}, expected: "aws-cpu-[1,002]"},
+		{name: "duplicates", nodes: []string{"aws-cpu-1", "aws-cpu-2", "aws-cpu-1"}, expected: "aws-cpu-[1-2]"},
+		{
+			name:     "several node groups",
+			nodes:    []string{"aws-gpu-0", "aws-cpu-4", "aws-cpu-5", "awswest-cpu-0"},
+			expected: "aws-cpu-[4-5],aws-gpu-0,awswest-cpu-0",
+		},
+		{name: "names without numbers", nodes: []string{"login1", "login", "head"}, expected: "head,login,login1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CompressHostlist(tt.nodes))
+		})
+	}
+}
+
+func TestExpandHostlist(t *testing.T) {
+	assert.Equal(t, []string{"aws-cpu-001", "aws-cpu-002", "aws-cpu-007", "aws-gpu-1", "login"},
+		ExpandHostlist("aws-cpu-[001-002,007],aws-gpu-1,login"))
+	assert.Equal(t, []string{"aws-cpu-9", "aws-cpu-10"}, ExpandHostlist("aws-cpu-[9-10]"))
+	assert.Empty(t, ExpandHostlist(""))
+
+	nodes := []string{"aws-cpu-098", "aws-cpu-099", "aws-cpu-100", "aws-gpu-3"}
+	assert.Equal(t, nodes, ExpandHostlist(CompressHostlist(nodes)))
+}
+
+func TestClient_SetNodesState(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "scontrol.log")
+	client := newFakeScontrolClient(t, `echo "$@" >> `+logFile+"\n")
+
+	var nodes []string
+	for i := 0; i < 128; i++ {
+		nodes = append(nodes, fmt.Sprintf("aws-cpu-%03d", i))
+	}
+	require.NoError(t, client.SetNodesState(nodes, "POWER_DOWN", ""))
+	require.NoError(t, client.UpdateNodes(nodes[:2], "Comment="))
+	require.NoError(t, client.SetNodesState(nil, "POWER_UP", ""))
+
+	updates, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"update nodename=aws-cpu-[000-127] state=POWER_DOWN",
+		"update nodename=aws-cpu-[000-001] Comment=",
+	}, strings.Split(strings.TrimSpace(string(updates)), "\n"), "one scontrol call per update, none for no nodes")
+}
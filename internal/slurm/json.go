@@ -0,0 +1,106 @@
+package slurm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// jsonResponse is a decoded --json document; complete reports whether it
+// held what the caller asked for
+type jsonResponse interface {
+	complete() bool
+}
+
+// runJSON runs a Slurm command with --json (Slurm 23.02 and later) and
+// decodes its output into response. It returns false when the release or
+// the command cannot produce the JSON, and the caller falls back to parsing
+// text; after the first failure the command is not asked for JSON again.
+func (c *Client) runJSON(ctx context.Context, response jsonResponse, command string, args ...string) bool {
+	if _, unsupported := c.noJSON.Load(command); unsupported || c.lacks(CapabilityJSON) {
+		return false
+	}
+
+	output, err := c.command(ctx, command, append([]string{"--json"}, args...)...)
+	if err == nil {
+		err = json.Unmarshal(output, response)
+	}
+	if err == nil && response.complete() {
+		return true
+	}
+
+	c.noJSON.Store(command, true)
+	c.logger.Debug("Slurm JSON output unavailable, using text output", zap.String("command", command), zap.Error(err))
+	return false
+}
+
+// slurmNumber is a number in Slurm JSON, given plainly or, from 23.02 on
+// for some fields, as {"set": true, "infinite": false, "number": 4}. Unset
+// and infinite numbers read as zero.
+type slurmNumber int64
+
+func (n *slurmNumber) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var value struct {
+			Set      bool  `json:"set"`
+			Infinite bool  `json:"infinite"`
+			Number   int64 `json:"number"`
+		}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*n = 0
+		if value.Set && !value.Infinite {
+			*n = slurmNumber(value.Number)
+		}
+		return nil
+	}
+
+	var value *int64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*n = 0
+	if value != nil {
+		*n = slurmNumber(*value)
+	}
+	return nil
+}
+
+// stringList is a list in Slurm JSON that older versions give as a single
+// string, e.g. job states and features
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var list []string
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		*l = list
+		return nil
+	}
+
+	var value *string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*l = nil
+	if value != nil && *value != "" {
+		*l = stringList{*value}
+	}
+	return nil
+}
+
+// contains reports whether the list holds value, ignoring case
+func (l stringList) contains(value string) bool {
+	for _, item := range l {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
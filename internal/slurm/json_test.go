@@ -0,0 +1,138 @@
+package slurm
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// catScript returns a fake command script printing output
+func catScript(t *testing.T, output string) string {
+	path := filepath.Join(t.TempDir(), "output")
+	require.NoError(t, os.WriteFile(path, []byte(output), 0644))
+	return "cat " + path + "\n"
+}
+
+func TestSlurmNumber(t *testing.T) {
+	tests := map[string]slurmNumber{
+		`4`:    4,
+		`null`: 0,
+		`{"set": true, "infinite": false, "number": 16}`:  16,
+		`{"set": false, "infinite": false, "number": 16}`: 0,
+		`{"set": true, "infinite": true, "number": 0}`:    0,
+	}
+	for input, expected := range tests {
+		var n slurmNumber
+		require.NoError(t, json.Unmarshal([]byte(input), &n), input)
+		assert.Equal(t, expected, n, input)
+	}
+}
+
+func TestStringList(t *testing.T) {
+	tests := map[string]stringList{
+		`"PENDING"`:               {"PENDING"},
+		`["RUNNING", "REQUEUED"]`: {"RUNNING", "REQUEUED"},
+		`""`:                      nil,
+		`null`:                    nil,
+	}
+	for input, expected := range tests {
+		var l stringList
+		require.NoError(t, json.Unmarshal([]byte(input), &l), input)
+		assert.Equal(t, expected, l, input)
+	}
+	assert.True(t, stringList{"RUNNING"}.contains("running"))
+}
+
+func TestClient_runJSON_Fallback(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "squeue.log")
+	client := newFakeCommandsClient(t, map[string]string{"squeue": `
+echo "$@" >> ` + logFile + `
+[ "$1" = --json ] && echo "squeue: unrecognized option '--json'" >&2 && exit 1
+echo "1300,4"
+`})
+
+	for i := 0; i < 2; i++ {
+		jobs, err := client.PendingJobs(context.Background(), "aws")
+		require.NoError(t, err)
+		assert.Equal(t, []PendingJob{{JobID: "1300", Nodes: 4}}, jobs)
+	}
+
+	calls, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(calls)), "\n")
+	require.Len(t, lines, 3, "JSON is only tried once")
+	assert.True(t, strings.HasPrefix(lines[0], "--json "))
+	assert.False(t, strings.HasPrefix(lines[2], "--json "))
+}
+
+func TestClient_PendingJobs_JSON(t *testing.T) {
+	client := newFakeCommandsClient(t, map[string]string{"squeue": catScript(t, `{"jobs": [
+  {"job_id": 1234, "array_job_id": {"set": true, "number": 1234}, "array_task_string": "7-9%2", "partition": "aws", "job_state": ["PENDING"], "node_count": {"set": true, "number": 1}},
+  {"job_id": 1300, "array_job_id": {"set": true, "number": 0}, "partition": "batch,aws", "job_state": "PENDING", "node_count": {"set": true, "number": 4}},
+  {"job_id": 1301, "partition": "aws", "job_state": ["RUNNING"], "node_count": 2},
+  {"job_id": 1302, "partition": "batch", "job_state": ["PENDING"], "node_count": 2}
+]}`)})
+
+	jobs, err := client.PendingJobs(context.Background(), "aws")
+	require.NoError(t, err)
+	assert.Equal(t, []PendingJob{
+		{JobID: "1234_7", Nodes: 1},
+		{JobID: "1234_8", Nodes: 1},
+		{JobID: "1234_9", Nodes: 1},
+		{JobID: "1300", Nodes: 4},
+	}, jobs)
+}
+
+func TestClient_GetJobForNodes_JSON(t *testing.T) {
+	client := newFakeCommandsClient(t, map[string]string{
+		"squeue": catScript(t, `{"jobs": [
+  {"job_id": 41, "name": "other", "partition": "aws", "job_state": ["RUNNING"], "nodes": "aws-cpu-[007-008]"},
+  {"job_id": 42, "name": "md, large", "partition": "aws", "user_name": "alice", "account": "chem", "qos": "urgent", "job_state": ["CONFIGURING"], "nodes": "aws-cpu-[001-002]",
+   "node_count": {"set": true, "number": 2}, "cpus": {"set": true, "number": 16},
+   "memory_per_node": {"set": false, "number": 0}, "memory_per_cpu": {"set": true, "number": 1024}, "features": "c5n&efa"}
+]}`),
+		"scontrol": "exit 1\n",
+	})
+
+	job, err := client.GetJobForNodes(context.Background(), []string{"aws-cpu-001", "aws-cpu-002"})
+	require.NoError(t, err)
+	assert.Equal(t, "42", job.JobID)
+	assert.Equal(t, "md, large", job.Name, "commas in job names survive")
+	assert.Equal(t, "alice", job.User)
+	assert.Equal(t, "chem", job.Account)
+	assert.Equal(t, "urgent", job.QOS)
+	assert.Equal(t, 8, job.Resources.CPUsPerNode)
+	assert.Equal(t, 8192, job.Resources.MemoryMB)
+	assert.Equal(t, []string{"c5n", "efa"}, job.Constraints.Features)
+}
+
+func TestClient_BurstJobs_JSON(t *testing.T) {
+	client := newFakeCommandsClient(t, map[string]string{"sacct": catScript(t, `{"jobs": [
+  {"job_id": 101, "account": "chem", "partition": "aws", "state": {"current": ["COMPLETED"], "reason": "None"},
+   "allocation_nodes": 4, "time": {"elapsed": 7200, "end": 1772445600},
+   "comment": {"administrator": "aws_meta:{\"cost\":3.5}", "job": "", "system": ""}},
+  {"job_id": 103, "array": {"job_id": 102, "task_id": {"set": true, "number": 1}}, "account": "bio", "partition": "aws",
+   "state": {"current": "CANCELLED by 1000"}, "allocation_nodes": 1, "time": {"elapsed": 60, "end": 0}}
+]}`)})
+
+	records, err := client.BurstJobs(context.Background(), []string{"aws"}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	assert.Equal(t, "101", records[0].JobID)
+	assert.Equal(t, 4, records[0].Nodes)
+	assert.Equal(t, 2*time.Hour, records[0].Elapsed)
+	assert.Equal(t, int64(1772445600), records[0].End.Unix())
+	assert.Equal(t, `aws_meta:{"cost":3.5}`, records[0].Metadata)
+
+	assert.Equal(t, "102_1", records[1].JobID)
+	assert.Equal(t, "CANCELLED", records[1].State)
+	assert.True(t, records[1].End.IsZero())
+}
@@ -0,0 +1,56 @@
+package slurm
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// nodeStateBatchSize bounds the nodes per scontrol show node call, keeping
+// the hostlist argument short even when the nodes do not compress well
+const nodeStateBatchSize = 1000
+
+// showNodes reads node state as JSON where scontrol supports it, otherwise
+// from its text output
+func (c *Client) showNodes(hostlist string) ([]NodeInfo, error) {
+	var response nodesResponse
+	if !c.runJSON(context.Background(), &response, "scontrol", "show", "node", hostlist) {
+		return c.showNodeText(hostlist)
+	}
+
+	var nodes []NodeInfo
+	for _, node := range *response.Nodes {
+		if node.Name == "" {
+			continue
+		}
+		features := strings.Join(node.Features, ",")
+		info := NodeInfo{
+			NodeName:         node.Name,
+			State:            strings.Join(node.State, "+"),
+			Reason:           node.Reason,
+			Features:         features,
+			AvailabilityZone: AvailabilityZoneFromFeatures(features),
+			Comment:          node.Comment,
+		}
+		if node.SlurmdStartTime > 0 {
+			info.SlurmdStartTime = time.Unix(int64(node.SlurmdStartTime), 0)
+		}
+		nodes = append(nodes, info)
+	}
+	return nodes, nil
+}
+
+// nodesResponse is the part of scontrol --json show node output NodeInfo needs
+type nodesResponse struct {
+	Nodes *[]struct {
+		Name     string     `json:"name"`
+		State    stringList `json:"state"`
+		Reason   string     `json:"reason"`
+		Features stringList `json:"features"` // A string before 23.11
+		Comment  string     `json:"comment"`
+
+		SlurmdStartTime slurmNumber `json:"slurmd_start_time"` // Unix seconds
+	} `json:"nodes"`
+}
+
+func (r *nodesResponse) complete() bool { return r.Nodes != nil }
@@ -0,0 +1,78 @@
+package slurm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetNodeState_JSON(t *testing.T) {
+	client := newFakeScontrolClientJSON(t, catScript(t, `{
+  "nodes": [
+    {"name": "aws-cpu-001", "state": ["IDLE", "CLOUD", "DRAIN"], "reason": "maintenance", "features": "c5n,az-us-east-1a", "comment": ""},
+    {"name": "aws-cpu-002", "state": ["MIXED"], "features": ["c5n", "az-us-east-1b"], "comment": "{\"fleet_id\":\"fleet-1\"}",
+     "slurmd_start_time": {"set": true, "infinite": false, "number": 1791201600}}
+  ],
+  "errors": []
+}`))
+
+	nodes, err := client.GetNodeState([]string{"aws-cpu-001", "aws-cpu-002"})
+	require.NoError(t, err)
+	assert.Equal(t, []NodeInfo{
+		{NodeName: "aws-cpu-001", State: "IDLE+CLOUD+DRAIN", Reason: "maintenance", Features: "c5n,az-us-east-1a", AvailabilityZone: "us-east-1a"},
+		{NodeName: "aws-cpu-002", State: "MIXED", Features: "c5n,az-us-east-1b", AvailabilityZone: "us-east-1b", Comment: `{"fleet_id":"fleet-1"}`,
+			SlurmdStartTime: time.Unix(1791201600, 0)},
+	}, nodes)
+}
+
+func TestClient_GetNodeState(t *testing.T) {
+	t.Run("JSON output in batches", func(t *testing.T) {
+		logFile := filepath.Join(t.TempDir(), "scontrol.log")
+		client := newFakeScontrolClientJSON(t, `
+[ "$1" = --version ] && echo "slurm 23.11.4" && exit 0
+echo "$@" >> `+logFile+`
+echo '{"nodes": [{"name": "aws-cpu-0", "state": ["IDLE"]}]}'
+`)
+
+		var names []string
+		for i := 0; i < nodeStateBatchSize+1; i++ {
+			names = append(names, fmt.Sprintf("aws-cpu-%d", i))
+		}
+		nodes, err := client.GetNodeState(names)
+		require.NoError(t, err)
+		assert.Len(t, nodes, 2, "one node per fake batch")
+
+		calls, err := os.ReadFile(logFile)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			fmt.Sprintf("--json show node aws-cpu-[0-%d]", nodeStateBatchSize-1),
+			fmt.Sprintf("--json show node aws-cpu-%d", nodeStateBatchSize),
+		}, strings.Split(strings.TrimSpace(string(calls)), "\n"))
+	})
+
+	t.Run("falls back to text once", func(t *testing.T) {
+		logFile := filepath.Join(t.TempDir(), "scontrol.log")
+		client := newFakeScontrolClient(t, `
+echo "$@" >> `+logFile+`
+echo "NodeName=aws-cpu-0 State=IDLE+CLOUD SlurmdStartTime=2026-10-18T12:00:00"
+`)
+
+		for i := 0; i < 2; i++ {
+			nodes, err := client.GetNodeState([]string{"aws-cpu-0"})
+			require.NoError(t, err)
+			assert.Equal(t, []NodeInfo{{NodeName: "aws-cpu-0", State: "IDLE+CLOUD",
+				SlurmdStartTime: time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)}}, nodes)
+		}
+
+		calls, err := os.ReadFile(logFile)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"show node aws-cpu-0 -o", "show node aws-cpu-0 -o"},
+			strings.Split(strings.TrimSpace(string(calls)), "\n"), "text output, one call per query")
+	})
+}
@@ -0,0 +1,73 @@
+package slurm
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// jobPragmaPattern matches an #ASBX pragma of key=value settings; #ASBX lines
+// that start with a verb are burst buffer directives
+var jobPragmaPattern = regexp.MustCompile(`(?m)^#ASBX[ \t]+(\S+=.*)$`)
+
+// instanceTypePattern matches an EC2 instance type such as c6i.4xlarge
+var instanceTypePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*\.[a-z0-9-]+$`)
+
+// pragmaPurchasingOptions are the purchasing options a pragma may choose
+var pragmaPurchasingOptions = []string{"spot", "on-demand", "mixed"}
+
+// parseJobPragmas reads the per-job overrides a batch script sets with #ASBX
+// pragmas. A later setting of the same key wins; settings that cannot be
+// honoured are listed in Ignored. It returns nil when the script sets none.
+func parseJobPragmas(script string) *types.JobOverrides {
+	overrides := &types.JobOverrides{}
+	found := false
+	for _, match := range jobPragmaPattern.FindAllStringSubmatch(script, -1) {
+		for key, value := range parseDirectiveOptions(match[1]) {
+			found = true
+			if reason := applyJobPragma(overrides, key, value); reason != "" {
+				overrides.Ignored = append(overrides.Ignored, fmt.Sprintf("#ASBX %s=%s: %s", key, value, reason))
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	slices.Sort(overrides.Ignored)
+	return overrides
+}
+
+// applyJobPragma sets one pragma and returns why it cannot be honoured, if it cannot
+func applyJobPragma(overrides *types.JobOverrides, key, value string) string {
+	switch key {
+	case "instance-type", "instance-types":
+		var instanceTypes []string
+		for _, instanceType := range strings.Split(value, ",") {
+			instanceType = strings.ToLower(strings.TrimSpace(instanceType))
+			if !instanceTypePattern.MatchString(instanceType) {
+				return fmt.Sprintf("invalid instance type %q", instanceType)
+			}
+			instanceTypes = append(instanceTypes, instanceType)
+		}
+		overrides.InstanceTypes = instanceTypes
+	case "purchasing":
+		option := strings.ToLower(value)
+		if !slices.Contains(pragmaPurchasingOptions, option) {
+			return fmt.Sprintf("must be one of %v", pragmaPurchasingOptions)
+		}
+		overrides.PurchasingOption = option
+	case "max-cost":
+		cost, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(value, "$"), "/hr"), "/h"), 64)
+		if err != nil || cost <= 0 {
+			return "must be a positive price per instance-hour in USD"
+		}
+		overrides.MaxCostPerHour = cost
+	default:
+		return "unknown pragma"
+	}
+	return ""
+}
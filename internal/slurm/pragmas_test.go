@@ -0,0 +1,53 @@
+package slurm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func TestParseJobPragmas(t *testing.T) {
+	assert.Nil(t, parseJobPragmas("#!/bin/bash\n#SBATCH -N 2\n#ASBX scratch capacity=100G\nsrun ./solver\n"))
+
+	overrides := parseJobPragmas(`#!/bin/bash
+#SBATCH --nodes=2
+#ASBX instance-type=c6i.4xlarge,C6A.4xlarge
+#ASBX purchasing=on-demand max-cost=$1.25/h
+#ASBX purchasing=Spot
+#ASBX stage_in source=s3://bucket/input destination=$ASB_SCRATCH/input
+#ASBX max-cost=free region=us-west-2
+srun ./solver
+`)
+	require.NotNil(t, overrides)
+	assert.Equal(t, &types.JobOverrides{
+		InstanceTypes:    []string{"c6i.4xlarge", "c6a.4xlarge"},
+		PurchasingOption: "spot",
+		MaxCostPerHour:   1.25,
+		Ignored: []string{
+			"#ASBX max-cost=free: must be a positive price per instance-hour in USD",
+			"#ASBX region=us-west-2: unknown pragma",
+		},
+	}, overrides)
+
+	overrides = parseJobPragmas("#ASBX instance-types=c6i.4xlarge, purchasing=lottery\n")
+	require.NotNil(t, overrides)
+	assert.Empty(t, overrides.InstanceTypes)
+	assert.Empty(t, overrides.PurchasingOption)
+	assert.Equal(t, []string{
+		`#ASBX instance-types=c6i.4xlarge,: invalid instance type ""`,
+		"#ASBX purchasing=lottery: must be one of [spot on-demand mixed]",
+	}, overrides.Ignored)
+}
+
+func TestClient_parseJobScript_Pragmas(t *testing.T) {
+	client := newFakeScontrolClient(t, "exit 0")
+	job := &types.SlurmJob{Script: "#!/bin/bash\n#ASBX purchasing=mixed\nsrun ./solver\n"}
+	client.parseJobScript(job)
+
+	require.NotNil(t, job.Overrides)
+	assert.Equal(t, "mixed", job.Overrides.PurchasingOption)
+	assert.Nil(t, job.Staging)
+}
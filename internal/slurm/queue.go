@@ -0,0 +1,401 @@
+package slurm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queuedJob is the squeue fields resume reads about a job
+type queuedJob struct {
+	ID        string
+	Name      string
+	Partition string
+	User      string
+	Account   string
+	QOS       string
+	Nodes     int
+	CPUs      int
+	MemoryMB  int // Per node
+	Features  string
+}
+
+// nodeJob returns the first job squeue lists on the nodes
+func (c *Client) nodeJob(ctx context.Context, nodeNames []string) (*queuedJob, error) {
+	var response squeueResponse
+	if c.runJSON(ctx, &response, "squeue", "-w", CompressHostlist(nodeNames)) {
+		requested := make(map[string]bool)
+		for _, node := range nodeNames {
+			requested[node] = true
+		}
+		for _, job := range *response.Jobs {
+			onNodes := false
+			for _, node := range ExpandHostlist(job.Nodes) {
+				onNodes = onNodes || requested[node]
+			}
+			if !onNodes {
+				continue
+			}
+
+			queued := &queuedJob{
+				ID:        job.jobIDs()[0],
+				Name:      job.Name,
+				Partition: job.Partition,
+				User:      job.UserName,
+				Account:   job.Account,
+				QOS:       job.QOS,
+				Nodes:     int(job.NodeCount),
+				CPUs:      int(job.CPUs),
+				MemoryMB:  int(job.MemoryPerNode),
+				Features:  job.Features,
+			}
+			if queued.MemoryMB == 0 && queued.Nodes > 0 {
+				queued.MemoryMB = int(job.MemoryPerCPU) * queued.CPUs / queued.Nodes
+			}
+			return queued, nil
+		}
+		return nil, fmt.Errorf("no job found for nodes")
+	}
+
+	output, err := c.command(ctx, "squeue", "-w", strings.Join(nodeNames, ","), "-o", "%i,%j,%P,%D,%C,%m,%t,%S,%L,%a,%q,%u,%f", "--noheader")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs for nodes: %w", err)
+	}
+
+	if strings.TrimSpace(string(output)) == "" {
+		return nil, fmt.Errorf("no job found for nodes")
+	}
+
+	// Parse first job found (format: jobid,name,partition,nodes,cpus,memory,state,start_time,time_limit,account,qos,user,features)
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	fields := strings.Split(lines[0], ",")
+	if len(fields) < 9 {
+		return nil, fmt.Errorf("invalid squeue output format")
+	}
+
+	queued := &queuedJob{
+		ID:        strings.TrimSpace(fields[0]),
+		Name:      strings.TrimSpace(fields[1]),
+		Partition: strings.TrimSpace(fields[2]),
+		MemoryMB:  c.parseMemory(strings.TrimSpace(fields[5])),
+	}
+	queued.Nodes, _ = strconv.Atoi(strings.TrimSpace(fields[3]))
+	queued.CPUs, _ = strconv.Atoi(strings.TrimSpace(fields[4]))
+	if len(fields) > 9 {
+		queued.Account = strings.TrimSpace(fields[9])
+	}
+	if len(fields) > 10 {
+		queued.QOS = strings.TrimSpace(fields[10])
+	}
+	if len(fields) > 11 {
+		queued.User = strings.TrimSpace(fields[11])
+	}
+	if len(fields) > 12 {
+		// Features come last as they may hold commas themselves
+		queued.Features = strings.TrimSpace(strings.Join(fields[12:], ","))
+	}
+	return queued, nil
+}
+
+// PendingJob is a job (or array task) waiting in a partition's queue
+type PendingJob struct {
+	JobID string
+	Nodes int
+}
+
+// PendingJobs lists a partition's pending jobs, with array tasks expanded so each
+// counts toward demand
+func (c *Client) PendingJobs(ctx context.Context, partition string) ([]PendingJob, error) {
+	var response squeueResponse
+	if c.runJSON(ctx, &response, "squeue", "--array", "--states=PENDING", "--partition="+partition) {
+		var jobs []PendingJob
+		for _, job := range *response.Jobs {
+			if !job.State.contains("PENDING") || !job.inPartition(partition) {
+				continue
+			}
+			nodes := max(int(job.NodeCount), 1)
+			for _, jobID := range job.jobIDs() {
+				jobs = append(jobs, PendingJob{JobID: jobID, Nodes: nodes})
+			}
+		}
+		return jobs, nil
+	}
+
+	output, err := c.command(ctx, "squeue", "--noheader", "--array", "--states=PENDING", "--partition="+partition, "-o", "%i,%D")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending jobs for partition %s: %w", partition, err)
+	}
+	return parsePendingJobs(string(output)), nil
+}
+
+// DeferJob requeues a job being resumed for and holds it pending until begin,
+// when Slurm schedules it again
+func (c *Client) DeferJob(ctx context.Context, jobID string, begin time.Time) error {
+	if _, err := c.command(ctx, "scontrol", "requeue", jobID); err != nil {
+		return fmt.Errorf("failed to requeue job %s: %w", jobID, err)
+	}
+	// slurmctld reads times without a zone in its own local time
+	if _, err := c.command(ctx, "scontrol", "update", "jobid="+jobID, "StartTime="+begin.Local().Format("2006-01-02T15:04:05")); err != nil {
+		return fmt.Errorf("failed to defer job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// ArrayTask is a task of an array job that has not started running: pending,
+// or configuring while the nodes allocated to it power up
+type ArrayTask struct {
+	Task  int
+	State string
+	Nodes []string
+}
+
+// UnstartedArrayTasks lists the array job's pending and configuring tasks
+func (c *Client) UnstartedArrayTasks(ctx context.Context, arrayJobID string) ([]ArrayTask, error) {
+	output, err := c.command(ctx, "squeue", "--noheader", "--array", "--jobs="+arrayJobID, "--states=PENDING,CONFIGURING", "-o", "%K|%T|%N")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tasks of array job %s: %w", arrayJobID, err)
+	}
+
+	var tasks []ArrayTask
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), "|")
+		if len(fields) != 3 {
+			continue
+		}
+		task, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		arrayTask := ArrayTask{Task: task, State: fields[1]}
+		if fields[2] != "" {
+			arrayTask.Nodes = ExpandHostlist(fields[2])
+		}
+		tasks = append(tasks, arrayTask)
+	}
+	return tasks, nil
+}
+
+// CancelArrayTasks cancels the given tasks of an array job, leaving the rest
+func (c *Client) CancelArrayTasks(ctx context.Context, arrayJobID string, tasks []int) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	if _, err := c.command(ctx, "scancel", fmt.Sprintf("%s_[%s]", arrayJobID, ArrayTaskRanges(tasks))); err != nil {
+		return fmt.Errorf("failed to cancel tasks of array job %s: %w", arrayJobID, err)
+	}
+	return nil
+}
+
+// JobStatus is where a job is in its life and which node runs its batch script
+type JobStatus struct {
+	State     string // RUNNING, PENDING, COMPLETING, ...
+	BatchHost string
+}
+
+// JobStatus returns the job's state and batch host, or nil once slurmctld no
+// longer knows the job
+func (c *Client) JobStatus(ctx context.Context, jobID string) (*JobStatus, error) {
+	output, err := c.command(ctx, "squeue", "--noheader", "--jobs="+jobID, "--states=all", "-o", "%T|%B")
+	if err != nil {
+		if strings.Contains(err.Error(), "Invalid job id") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query job %s: %w", jobID, err)
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	state, batchHost, found := strings.Cut(line, "|")
+	if !found {
+		return nil, nil
+	}
+	return &JobStatus{State: state, BatchHost: strings.TrimSpace(batchHost)}, nil
+}
+
+// SignalJob sends signal, such as USR2, to the job's batch script
+func (c *Client) SignalJob(ctx context.Context, jobID, signal string) error {
+	if _, err := c.command(ctx, "scancel", "--batch", "--signal="+signal, jobID); err != nil {
+		return fmt.Errorf("failed to signal job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// ArrayTaskRanges compresses task IDs into an array expression such as 1-3,7
+func ArrayTaskRanges(tasks []int) string {
+	sorted := slices.Clone(tasks)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	var ranges []string
+	for start := 0; start < len(sorted); {
+		end := start
+		for end+1 < len(sorted) && sorted[end+1] == sorted[end]+1 {
+			end++
+		}
+		if end == start {
+			ranges = append(ranges, strconv.Itoa(sorted[start]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", sorted[start], sorted[end]))
+		}
+		start = end + 1
+	}
+	return strings.Join(ranges, ",")
+}
+
+// parsePendingJobs parses squeue "jobid,nodes" lines
+func parsePendingJobs(output string) []PendingJob {
+	var jobs []PendingJob
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) != 2 || fields[0] == "" {
+			continue
+		}
+
+		nodes, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil || nodes <= 0 {
+			nodes = 1
+		}
+		jobs = append(jobs, PendingJob{JobID: strings.TrimSpace(fields[0]), Nodes: nodes})
+	}
+	return jobs
+}
+
+// NodeJobs maps each node in the partitions to the jobs running or completing on it
+func (c *Client) NodeJobs(ctx context.Context, partitions []string) (map[string][]string, error) {
+	jobs := make(map[string][]string)
+
+	var response squeueResponse
+	if c.runJSON(ctx, &response, "squeue", "--states=RUNNING,COMPLETING", "--partition="+strings.Join(partitions, ",")) {
+		for _, job := range *response.Jobs {
+			if (!job.State.contains("RUNNING") && !job.State.contains("COMPLETING")) || job.Nodes == "" {
+				continue
+			}
+			inPartitions := false
+			for _, partition := range partitions {
+				inPartitions = inPartitions || job.inPartition(partition)
+			}
+			if !inPartitions {
+				continue
+			}
+
+			nodes, err := c.ParseNodeList(job.Nodes)
+			if err != nil {
+				continue
+			}
+			for _, node := range nodes {
+				jobs[node] = append(jobs[node], job.jobIDs()[0])
+			}
+		}
+		return jobs, nil
+	}
+
+	output, err := c.command(ctx, "squeue", "--noheader", "--states=RUNNING,COMPLETING", "--partition="+strings.Join(partitions, ","), "-o", "%i|%N")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query running jobs: %w", err)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), "|", 2)
+		if len(fields) != 2 || fields[1] == "" {
+			continue
+		}
+
+		nodes, err := c.ParseNodeList(fields[1])
+		if err != nil {
+			continue
+		}
+		for _, node := range nodes {
+			jobs[node] = append(jobs[node], fields[0])
+		}
+	}
+	return jobs, nil
+}
+
+// squeueResponse is the part of squeue --json output the queue queries need
+type squeueResponse struct {
+	Jobs *[]squeueJob `json:"jobs"`
+}
+
+func (r *squeueResponse) complete() bool { return r.Jobs != nil }
+
+type squeueJob struct {
+	JobID           slurmNumber `json:"job_id"`
+	ArrayJobID      slurmNumber `json:"array_job_id"`
+	ArrayTaskID     slurmNumber `json:"array_task_id"`
+	ArrayTaskString string      `json:"array_task_string"` // Tasks not yet started, e.g. 1-100%10
+	Name            string      `json:"name"`
+	Partition       string      `json:"partition"` // Comma-separated while pending in several
+	UserName        string      `json:"user_name"`
+	Account         string      `json:"account"`
+	QOS             string      `json:"qos"`
+	State           stringList  `json:"job_state"`
+	NodeCount       slurmNumber `json:"node_count"`
+	CPUs            slurmNumber `json:"cpus"`
+	MemoryPerNode   slurmNumber `json:"memory_per_node"` // MB
+	MemoryPerCPU    slurmNumber `json:"memory_per_cpu"`  // MB
+	Features        string      `json:"features"`
+	Nodes           string      `json:"nodes"` // Hostlist of allocated nodes
+	Priority        slurmNumber `json:"priority"`
+}
+
+// jobIDs returns the job's ID as squeue prints it, e.g. 1234 or 1234_7, or one
+// ID per task for array tasks that have not started
+func (j *squeueJob) jobIDs() []string {
+	if j.ArrayJobID == 0 {
+		return []string{strconv.FormatInt(int64(j.JobID), 10)}
+	}
+	if j.ArrayTaskString == "" {
+		return []string{fmt.Sprintf("%d_%d", j.ArrayJobID, j.ArrayTaskID)}
+	}
+
+	var ids []string
+	for _, task := range arrayTasks(j.ArrayTaskString) {
+		ids = append(ids, fmt.Sprintf("%d_%d", j.ArrayJobID, task))
+	}
+	return ids
+}
+
+func (j *squeueJob) inPartition(partition string) bool {
+	for _, name := range strings.Split(j.Partition, ",") {
+		if name == partition {
+			return true
+		}
+	}
+	return false
+}
+
+// arrayTasks expands an array task expression such as 1-7:2,10%4 into task IDs
+func arrayTasks(expression string) []int {
+	expression, _, _ = strings.Cut(expression, "%")
+
+	var tasks []int
+	for _, part := range strings.Split(expression, ",") {
+		part, stepText, _ := strings.Cut(part, ":")
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			continue
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil {
+				continue
+			}
+		}
+		step := 1
+		if stepText != "" {
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				continue
+			}
+		}
+		for task := start; task <= end; task += step {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
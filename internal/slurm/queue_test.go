@@ -0,0 +1,82 @@
+package slurm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePendingJobs(t *testing.T) {
+	jobs := parsePendingJobs("1234_7,1\n1234_8,1\n1300,4\n\nbad line\n")
+	assert.Equal(t, []PendingJob{
+		{JobID: "1234_7", Nodes: 1},
+		{JobID: "1234_8", Nodes: 1},
+		{JobID: "1300", Nodes: 4},
+	}, jobs)
+}
+
+func TestClient_DeferJob(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "scontrol.log")
+	client := newFakeScontrolClient(t, `echo "$@" >> `+logFile)
+
+	begin := time.Date(2026, 11, 2, 11, 30, 0, 0, time.Local)
+	require.NoError(t, client.DeferJob(context.Background(), "42", begin))
+
+	commands, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, "requeue 42\nupdate jobid=42 StartTime=2026-11-02T11:30:00\n", string(commands))
+}
+
+func TestClient_UnstartedArrayTasks(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "scancel.log")
+	client := newFakeCommandsClient(t, map[string]string{
+		"squeue":  "printf '4|CONFIGURING|aws-cpu-[001-002]\\n5|PENDING|\\n6|PENDING|\\n'\n",
+		"scancel": `echo "$@" >> ` + logFile,
+	})
+
+	tasks, err := client.UnstartedArrayTasks(context.Background(), "1234")
+	require.NoError(t, err)
+	assert.Equal(t, []ArrayTask{
+		{Task: 4, State: "CONFIGURING", Nodes: []string{"aws-cpu-001", "aws-cpu-002"}},
+		{Task: 5, State: "PENDING"},
+		{Task: 6, State: "PENDING"},
+	}, tasks)
+
+	require.NoError(t, client.CancelArrayTasks(context.Background(), "1234", []int{6, 4, 5, 9}))
+	commands, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, "1234_[4-6,9]\n", string(commands))
+}
+
+func TestClient_JobStatus(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "scancel.log")
+	client := newFakeCommandsClient(t, map[string]string{
+		"squeue": `case "$*" in
+  *--jobs=42*) echo 'RUNNING|aws-cpu-001' ;;
+  *--jobs=43*) echo 'slurm_load_jobs error: Invalid job id specified' >&2; exit 1 ;;
+esac`,
+		"scancel": `echo "$@" >> ` + logFile,
+	})
+	ctx := context.Background()
+
+	status, err := client.JobStatus(ctx, "42")
+	require.NoError(t, err)
+	assert.Equal(t, &JobStatus{State: "RUNNING", BatchHost: "aws-cpu-001"}, status)
+
+	status, err = client.JobStatus(ctx, "43")
+	require.NoError(t, err)
+	assert.Nil(t, status, "purged from slurmctld")
+	status, err = client.JobStatus(ctx, "44")
+	require.NoError(t, err)
+	assert.Nil(t, status)
+
+	require.NoError(t, client.SignalJob(ctx, "42", "USR2"))
+	commands, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, "--batch --signal=USR2 42\n", string(commands))
+}
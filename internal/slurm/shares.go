@@ -0,0 +1,79 @@
+package slurm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JobPriorities returns the Slurm priority of each of the jobs still waiting
+// to start, including those waiting for their nodes to power up. Jobs that
+// have left the queue are missing from the map.
+func (c *Client) JobPriorities(ctx context.Context, jobIDs []string) (map[string]int64, error) {
+	wanted := make(map[string]bool, len(jobIDs))
+	for _, jobID := range jobIDs {
+		wanted[jobID] = true
+	}
+	priorities := make(map[string]int64, len(jobIDs))
+
+	var response squeueResponse
+	if c.runJSON(ctx, &response, "squeue", "--states=PENDING,CONFIGURING") {
+		for _, job := range *response.Jobs {
+			for _, jobID := range job.jobIDs() {
+				if wanted[jobID] {
+					priorities[jobID] = int64(job.Priority)
+				}
+			}
+		}
+		return priorities, nil
+	}
+
+	output, err := c.command(ctx, "squeue", "--noheader", "--array", "--states=PENDING,CONFIGURING", "-o", "%i,%Q")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job priorities: %w", err)
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		jobID, priority, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ",")
+		if !ok || !wanted[jobID] {
+			continue
+		}
+		if value, err := strconv.ParseInt(strings.TrimSpace(priority), 10, 64); err == nil {
+			priorities[jobID] = value
+		}
+	}
+	return priorities, nil
+}
+
+// AccountFairShares returns each account's fair-share factor from sshare,
+// between 0 and 1; accounts that have used less than their share rank higher
+func (c *Client) AccountFairShares(ctx context.Context) (map[string]float64, error) {
+	output, err := c.command(ctx, "sshare", "--noheader", "--parsable2", "--all", "--format=Account,User,FairShare")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fair share: %w", err)
+	}
+	return parseFairShares(string(output)), nil
+}
+
+// parseFairShares parses sshare "account|user|fairshare" lines, keeping the
+// account rows, which have no user
+func parseFairShares(output string) map[string]float64 {
+	shares := make(map[string]float64)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) != 3 || strings.TrimSpace(fields[1]) != "" {
+			continue
+		}
+		// sshare indents accounts by their depth in the hierarchy
+		account := strings.TrimSpace(fields[0])
+		share, err := strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
+		if account == "" || err != nil {
+			continue
+		}
+		shares[account] = share
+	}
+	return shares
+}
@@ -0,0 +1,34 @@
+package slurm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_JobPriorities(t *testing.T) {
+	client := newFakeCommandsClient(t, map[string]string{"squeue": catScript(t, `{"jobs": [
+  {"job_id": 42, "job_state": ["CONFIGURING"], "priority": {"set": true, "number": 9000}},
+  {"job_id": 1234, "array_job_id": {"set": true, "number": 1234}, "array_task_id": {"set": true, "number": 7}, "job_state": ["CONFIGURING"], "priority": 1200},
+  {"job_id": 43, "job_state": ["PENDING"], "priority": {"set": true, "number": 10}}
+]}`)})
+
+	priorities, err := client.JobPriorities(context.Background(), []string{"42", "1234_7", "99"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"42": 9000, "1234_7": 1200}, priorities, "jobs not asked for or gone are left out")
+
+	client = newFakeCommandsClient(t, map[string]string{"squeue": `
+[ "$1" = --json ] && exit 1
+printf '42,9000\n1234_7,1200\n43,10\n'
+`})
+	priorities, err = client.JobPriorities(context.Background(), []string{"42", "1234_7"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"42": 9000, "1234_7": 1200}, priorities)
+}
+
+func TestParseFairShares(t *testing.T) {
+	shares := parseFairShares("root||0.500000\n physics||0.250000\n  physics|alice|0.200000\n chem||0.900000\nbad line\n")
+	assert.Equal(t, map[string]float64{"root": 0.5, "physics": 0.25, "chem": 0.9}, shares)
+}
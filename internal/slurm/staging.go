@@ -0,0 +1,160 @@
+package slurm
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// stagingDirectivePattern matches a burst buffer directive: #BB, #DW or #ASBX,
+// a verb and its key=value options
+var stagingDirectivePattern = regexp.MustCompile(`(?m)^#(BB|DW|ASBX)[ \t]+(\S+)[ \t]*(.*)$`)
+
+// capacityPattern matches a burst buffer size such as 200GiB, 1.5TB or 500G
+var capacityPattern = regexp.MustCompile(`(?i)^([0-9]+(?:\.[0-9]+)?)\s*([KMGTP]?)(?:I?B)?$`)
+
+// capacityGiB is the size of each capacity unit in GiB; a bare number is GiB
+var capacityGiB = map[string]float64{"K": 1.0 / (1 << 20), "M": 1.0 / (1 << 10), "": 1, "G": 1, "T": 1 << 10, "P": 1 << 20}
+
+// parseStagingDirectives reads the data staging a batch script declares in
+// DataWarp-style burst buffer directives:
+//
+//	#DW jobdw capacity=200GiB type=scratch retention=72h
+//	#DW stage_in source=s3://bucket/input destination=$DW_JOB_STRIPED/input type=directory
+//	#DW stage_out source=$DW_JOB_STRIPED/results destination=s3://bucket/results type=directory
+//
+// #BB directives take the same form, and so does the #ASBX pragma family,
+// where scratch is another name for jobdw. Directives that cannot be honoured
+// are listed in Ignored. It returns nil when the script declares none.
+func parseStagingDirectives(script string) *types.StagingSpec {
+	spec := &types.StagingSpec{}
+	found := false
+	for _, match := range stagingDirectivePattern.FindAllStringSubmatch(script, -1) {
+		prefix, verb := match[1], match[2]
+		options := parseDirectiveOptions(match[3])
+		directive := "#" + prefix + " " + verb
+
+		switch verb {
+		case "jobdw", "scratch":
+			if verb == "scratch" && prefix != "ASBX" {
+				continue
+			}
+			found = true
+			if reason := applyScratchDirective(spec, options); reason != "" {
+				spec.Ignored = append(spec.Ignored, directive+": "+reason)
+			}
+		case "stage_in", "stage_out":
+			found = true
+			transfer, reason := parseStagingTransfer(options)
+			switch {
+			case reason != "":
+				spec.Ignored = append(spec.Ignored, directive+": "+reason)
+			case verb == "stage_in":
+				spec.StageIn = append(spec.StageIn, transfer)
+			default:
+				spec.StageOut = append(spec.StageOut, transfer)
+			}
+		case "persistentdw", "create_persistent", "destroy_persistent", "swap":
+			found = true
+			spec.Ignored = append(spec.Ignored, directive+": persistent burst buffers and swap are not supported")
+		default:
+			// Other #ASBX pragmas are not about staging
+			if prefix != "ASBX" {
+				found = true
+				spec.Ignored = append(spec.Ignored, directive+": unknown directive")
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	return spec
+}
+
+// parseDirectiveOptions splits a directive's key=value options, unquoting values
+func parseDirectiveOptions(text string) map[string]string {
+	options := make(map[string]string)
+	for _, field := range strings.Fields(text) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			options[strings.ToLower(key)] = strings.Trim(value, `"'`)
+		}
+	}
+	return options
+}
+
+// applyScratchDirective sets the scratch size and retention of a jobdw
+// directive, keeping the largest size when a script has several, and
+// returns why it cannot be honoured, if it cannot
+func applyScratchDirective(spec *types.StagingSpec, options map[string]string) string {
+	if kind := options["type"]; kind != "" && kind != "scratch" {
+		return fmt.Sprintf("type=%s is not supported, only scratch", kind)
+	}
+	capacity, ok := options["capacity"]
+	if !ok {
+		return "capacity is required"
+	}
+	gb, err := parseCapacityGB(capacity)
+	if err != nil {
+		return err.Error()
+	}
+	if retention, ok := options["retention"]; ok {
+		duration, err := parseRetention(retention)
+		if err != nil {
+			return err.Error()
+		}
+		spec.Retention = types.Duration(duration)
+	}
+	spec.ScratchGB = max(spec.ScratchGB, gb)
+	return ""
+}
+
+// parseStagingTransfer reads a stage_in or stage_out directive's options
+func parseStagingTransfer(options map[string]string) (types.StagingTransfer, string) {
+	transfer := types.StagingTransfer{
+		Source:      options["source"],
+		Destination: options["destination"],
+		Type:        options["type"],
+	}
+	if transfer.Type == "" {
+		transfer.Type = types.StagingFile
+	}
+	switch {
+	case transfer.Source == "" || transfer.Destination == "":
+		return transfer, "source and destination are required"
+	case transfer.Type != types.StagingFile && transfer.Type != types.StagingDirectory:
+		return transfer, fmt.Sprintf("type=%s is not supported, only file or directory", transfer.Type)
+	}
+	return transfer, ""
+}
+
+// parseCapacityGB converts a burst buffer size to whole GiB, rounding up
+func parseCapacityGB(capacity string) (int, error) {
+	match := capacityPattern.FindStringSubmatch(strings.TrimSpace(capacity))
+	if match == nil {
+		return 0, fmt.Errorf("invalid capacity %q", capacity)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid capacity %q", capacity)
+	}
+	return int(math.Ceil(value * capacityGiB[strings.ToUpper(match[2])])), nil
+}
+
+// parseRetention reads a retention period such as 72h or 7d
+func parseRetention(retention string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(retention, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	duration, err := time.ParseDuration(retention)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid retention %q", retention)
+	}
+	return duration, nil
+}
@@ -0,0 +1,73 @@
+package slurm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func TestParseStagingDirectives(t *testing.T) {
+	assert.Nil(t, parseStagingDirectives("#!/bin/bash\n#SBATCH -N 2\n#ASBX instance-type=c6i.4xlarge\nsrun ./solver\n"))
+
+	spec := parseStagingDirectives(`#!/bin/bash
+#SBATCH --nodes=2
+#DW jobdw type=scratch access_mode=striped capacity=200GiB
+#BB jobdw capacity=1.5TB retention=3d
+#DW stage_in source=s3://bucket/input destination=$DW_JOB_STRIPED/input type=directory
+#DW stage_in source="/home/alice/params.txt" destination=$DW_JOB_STRIPED/params.txt
+#ASBX stage_out source=$ASB_SCRATCH/results destination=s3://bucket/results/ type=directory
+#DW stage_out source=$DW_JOB_STRIPED/files.txt destination=s3://bucket/ type=list
+#DW persistentdw name=shared
+#DW jobdw type=cache capacity=10GiB
+srun ./solver
+`)
+	require.NotNil(t, spec)
+	assert.Equal(t, 1536, spec.ScratchGB, "the largest scratch")
+	assert.Equal(t, types.Duration(72*time.Hour), spec.Retention)
+	assert.Equal(t, []types.StagingTransfer{
+		{Source: "s3://bucket/input", Destination: "$DW_JOB_STRIPED/input", Type: types.StagingDirectory},
+		{Source: "/home/alice/params.txt", Destination: "$DW_JOB_STRIPED/params.txt", Type: types.StagingFile},
+	}, spec.StageIn)
+	assert.Equal(t, []types.StagingTransfer{
+		{Source: "$ASB_SCRATCH/results", Destination: "s3://bucket/results/", Type: types.StagingDirectory},
+	}, spec.StageOut)
+	assert.Equal(t, []string{
+		"#DW stage_out: type=list is not supported, only file or directory",
+		"#DW persistentdw: persistent burst buffers and swap are not supported",
+		"#DW jobdw: type=cache is not supported, only scratch",
+	}, spec.Ignored)
+}
+
+func TestParseCapacityGB(t *testing.T) {
+	for capacity, expected := range map[string]int{
+		"200GiB": 200,
+		"200GB":  200,
+		"500g":   500,
+		"2TiB":   2048,
+		"1.5T":   1536,
+		"1500M":  2,
+		"64":     64,
+	} {
+		gb, err := parseCapacityGB(capacity)
+		require.NoError(t, err, capacity)
+		assert.Equal(t, expected, gb, capacity)
+	}
+	for _, capacity := range []string{"", "0GB", "lots", "-5G"} {
+		_, err := parseCapacityGB(capacity)
+		assert.Error(t, err, capacity)
+	}
+}
+
+func TestClient_parseJobScript_Staging(t *testing.T) {
+	client := newFakeScontrolClient(t, "exit 0")
+	job := &types.SlurmJob{Script: "#!/bin/bash\n#ASBX scratch capacity=100G retention=12h\nsrun ./solver\n"}
+	client.parseJobScript(job)
+
+	require.NotNil(t, job.Staging)
+	assert.Equal(t, 100, job.Resources.LocalStorage)
+	assert.Equal(t, types.Duration(12*time.Hour), job.Staging.Retention)
+}
@@ -0,0 +1,298 @@
+package slurm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// AZFeaturePrefix prefixes the node feature recording a burst node's availability zone,
+// so jobs can pin themselves with e.g. --constraint=az-us-east-1a
+const AZFeaturePrefix = "az-"
+
+// AZFeature returns the node feature for an availability zone
+func AZFeature(az string) string {
+	return AZFeaturePrefix + az
+}
+
+// AvailabilityZoneFromFeatures returns the AZ recorded in a comma-separated feature list
+func AvailabilityZoneFromFeatures(features string) string {
+	for _, feature := range strings.Split(features, ",") {
+		if strings.HasPrefix(feature, AZFeaturePrefix) {
+			return strings.TrimPrefix(feature, AZFeaturePrefix)
+		}
+	}
+	return ""
+}
+
+// withAZFeature replaces any AZ feature in a feature list; an empty az only removes it
+func withAZFeature(features, az string) string {
+	var merged []string
+	for _, feature := range strings.Split(features, ",") {
+		if feature == "" || feature == "(null)" || strings.HasPrefix(feature, AZFeaturePrefix) {
+			continue
+		}
+		merged = append(merged, feature)
+	}
+	if az != "" {
+		merged = append(merged, AZFeature(az))
+	}
+	return strings.Join(merged, ",")
+}
+
+// AvailabilityZones returns the distinct, sorted AZs the instances landed in
+func AvailabilityZones(instances []types.InstanceInfo) []string {
+	seen := make(map[string]bool)
+	var zones []string
+	for _, instance := range instances {
+		if instance.AvailabilityZone != "" && !seen[instance.AvailabilityZone] {
+			seen[instance.AvailabilityZone] = true
+			zones = append(zones, instance.AvailabilityZone)
+		}
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+// NeedsAZHints reports whether nodes should carry AZ features: spread and partition
+// placements may cross AZs, and any launch that actually did so benefits from them
+func NeedsAZHints(placementGroupType string, instances []types.InstanceInfo) bool {
+	switch types.NetworkTopology(placementGroupType) {
+	case types.TopologySpread, types.TopologyPartition:
+		return true
+	}
+	return len(AvailabilityZones(instances)) > 1
+}
+
+// SetNodeAvailabilityZones adds an az- feature to each node so multi-node jobs that
+// tolerate spread can still ask Slurm to keep their ranks within one AZ
+func (c *Client) SetNodeAvailabilityZones(ctx context.Context, instances []types.InstanceInfo) error {
+	zones := make(map[string]string)
+	var nodeNames []string
+	for _, instance := range instances {
+		if instance.AvailabilityZone == "" {
+			continue
+		}
+		zones[instance.NodeName] = instance.AvailabilityZone
+		nodeNames = append(nodeNames, instance.NodeName)
+	}
+
+	return c.updateAZFeatures(nodeNames, zones)
+}
+
+// ClearNodeAvailabilityZones removes AZ features from nodes whose instances are gone,
+// so a powered-down node is not scheduled for an AZ it may not return to
+func (c *Client) ClearNodeAvailabilityZones(ctx context.Context, nodeNames []string) error {
+	return c.updateAZFeatures(nodeNames, nil)
+}
+
+// updateAZFeatures rewrites each node's AZ feature, preserving its other features
+func (c *Client) updateAZFeatures(nodeNames []string, zones map[string]string) error {
+	if len(nodeNames) == 0 {
+		return nil
+	}
+
+	nodes, err := c.GetNodeState(nodeNames)
+	if err != nil {
+		return fmt.Errorf("failed to read node features: %w", err)
+	}
+
+	var failed []string
+	for _, node := range nodes {
+		az := zones[node.NodeName]
+		if node.AvailabilityZone == az {
+			continue
+		}
+
+		features := withAZFeature(node.Features, az)
+		if features == "" {
+			features = "(null)"
+		}
+		parameters := fmt.Sprintf("AvailableFeatures=%s ActiveFeatures=%s", features, features)
+		if err := c.UpdateNode(node.NodeName, parameters); err != nil {
+			failed = append(failed, node.NodeName)
+			continue
+		}
+
+		c.logger.Debug("Updated node availability zone feature",
+			zap.String("node", node.NodeName),
+			zap.String("availability_zone", az))
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to update AZ features for nodes: %s", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+// RecordJobPlacement stores launch placement metadata in the job's metadata field,
+// where it outlives the instances for performance export
+func (c *Client) RecordJobPlacement(ctx context.Context, jobID string, meta *types.AWSJobMetadata) error {
+	if jobID == "" {
+		return nil
+	}
+
+	if err := c.SetJobMetadata(ctx, jobID, meta); err != nil {
+		return fmt.Errorf("failed to record placement for job %s: %w", jobID, err)
+	}
+
+	c.logger.Debug("Recorded job placement",
+		zap.String("job_id", jobID),
+		zap.Int("nodes", len(meta.NodeAvailabilityZones)))
+
+	return nil
+}
+
+// SetNodePlacement stamps each launched node's Comment with its own burst metadata
+// (instance type, AZ and the job-level fields of base), so the prolog can attach it
+// to whichever job lands on the node
+func (c *Client) SetNodePlacement(ctx context.Context, instances []types.InstanceInfo, base *types.AWSJobMetadata) error {
+	var failed []string
+	for _, instance := range instances {
+		meta := types.NewAWSJobMetadata([]types.InstanceInfo{instance})
+		meta.FleetID = base.FleetID
+		meta.ExecutionMode = base.ExecutionMode
+		meta.TraceID = base.TraceID
+
+		if err := c.UpdateNode(instance.NodeName, "Comment="+meta.String()); err != nil {
+			failed = append(failed, instance.NodeName)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to record placement on nodes: %s", strings.Join(failed, ","))
+	}
+	return nil
+}
+
+// ClearNodePlacement removes burst metadata from nodes whose instances are gone
+func (c *Client) ClearNodePlacement(ctx context.Context, nodeNames []string) error {
+	if err := c.UpdateNodes(nodeNames, "Comment="); err != nil {
+		return fmt.Errorf("failed to clear placement on nodes %s: %w", CompressHostlist(nodeNames), err)
+	}
+	return nil
+}
+
+// NodePlacement merges the burst metadata recorded on the nodes. Nodes without
+// metadata are skipped; nil is returned if none has any.
+func (c *Client) NodePlacement(ctx context.Context, nodeNames []string) (*types.AWSJobMetadata, error) {
+	nodes, err := c.GetNodeState(nodeNames)
+	if err != nil {
+		return nil, err
+	}
+
+	var merged *types.AWSJobMetadata
+	for _, node := range nodes {
+		meta, err := types.ParseAWSJobMetadata(node.Comment)
+		if err != nil {
+			continue
+		}
+		if merged == nil {
+			merged = &types.AWSJobMetadata{}
+		}
+		merged.Merge(meta)
+	}
+	return merged, nil
+}
+
+// JobMetadata reads a job's burst metadata from the configured comment fields. It
+// returns nil if the job has none.
+func (c *Client) JobMetadata(ctx context.Context, jobID string) (*types.AWSJobMetadata, error) {
+	values, err := c.jobFields(ctx, jobID, c.config.JobMetadata.Fields())
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := types.ParseAWSJobMetadata(types.JoinAWSJobMetadata(values...))
+	if err != nil {
+		return nil, nil
+	}
+	return meta, nil
+}
+
+// SetJobMetadata writes burst metadata to the job's configured comment fields,
+// keeping any other text in the first. Metadata larger than max_bytes is chunked
+// over the overflow fields, or truncated when they are full.
+func (c *Client) SetJobMetadata(ctx context.Context, jobID string, meta *types.AWSJobMetadata) error {
+	fields := c.config.JobMetadata.Fields()
+	current, err := c.jobFields(ctx, jobID, fields)
+	if err != nil {
+		return err
+	}
+
+	maxBytes := c.config.JobMetadata.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 4096
+	}
+	values, err := types.EncodeAWSJobMetadata(meta, types.AWSMetadataText(current[0]), maxBytes, len(fields))
+	if err != nil {
+		return err
+	}
+
+	args := []string{"update", "jobid=" + jobID}
+	for i, field := range fields {
+		switch {
+		case i < len(values):
+			args = append(args, field+"="+values[i])
+		case types.AWSMetadataText(current[i]) != current[i]:
+			// Drop chunks left over from larger metadata
+			args = append(args, field+"="+types.AWSMetadataText(current[i]))
+		}
+	}
+
+	if _, err := c.command(ctx, "scontrol", args...); err != nil {
+		return fmt.Errorf("failed to update job %s metadata: %w", jobID, err)
+	}
+
+	if len(values) > 1 {
+		c.logger.Debug("Chunked job metadata across fields",
+			zap.String("job_id", jobID),
+			zap.Strings("fields", fields[:len(values)]))
+	}
+	return nil
+}
+
+// jobFields returns the values of fields from scontrol show job, in order
+func (c *Client) jobFields(ctx context.Context, jobID string, fields []string) ([]string, error) {
+	output, err := c.command(ctx, "scontrol", "show", "job", jobID, "-o")
+	if err != nil {
+		return nil, fmt.Errorf("failed to show job %s: %w", jobID, err)
+	}
+
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = jobField(string(output), field)
+	}
+	return values, nil
+}
+
+// jobFieldStart matches the start of the next Key=value pair in scontrol -o output
+var jobFieldStart = regexp.MustCompile(` [A-Za-z][A-Za-z0-9/:_]*=`)
+
+// jobField extracts a field from one-line scontrol show job output. Comment values
+// may contain spaces, so a value runs until the next " Key=" rather than the next space.
+func jobField(line, key string) string {
+	line = strings.TrimSpace(line)
+	var start int
+	if strings.HasPrefix(line, key+"=") {
+		start = len(key) + 1
+	} else if idx := strings.Index(line, " "+key+"="); idx >= 0 {
+		start = idx + len(key) + 2
+	} else {
+		return ""
+	}
+
+	value := line[start:]
+	if loc := jobFieldStart.FindStringIndex(value); loc != nil {
+		value = value[:loc[0]]
+	}
+	if value == "(null)" {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,116 @@
+package slurm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func TestWithAZFeature(t *testing.T) {
+	assert.Equal(t, "c5n,az-us-east-1b", withAZFeature("c5n,az-us-east-1a", "us-east-1b"))
+	assert.Equal(t, "az-us-east-1a", withAZFeature("(null)", "us-east-1a"))
+	assert.Equal(t, "c5n,efa", withAZFeature("c5n,az-us-east-1a,efa", ""))
+	assert.Equal(t, "us-east-1a", AvailabilityZoneFromFeatures("c5n,az-us-east-1a"))
+	assert.Empty(t, AvailabilityZoneFromFeatures("c5n,efa"))
+}
+
+func TestNeedsAZHints(t *testing.T) {
+	sameAZ := []types.InstanceInfo{
+		{NodeName: "aws-cpu-001", AvailabilityZone: "us-east-1a"},
+		{NodeName: "aws-cpu-002", AvailabilityZone: "us-east-1a"},
+	}
+	multiAZ := []types.InstanceInfo{
+		{NodeName: "aws-cpu-001", AvailabilityZone: "us-east-1b"},
+		{NodeName: "aws-cpu-002", AvailabilityZone: "us-east-1a"},
+	}
+
+	assert.True(t, NeedsAZHints("spread", sameAZ))
+	assert.True(t, NeedsAZHints("partition", sameAZ))
+	assert.False(t, NeedsAZHints("cluster", sameAZ))
+	assert.False(t, NeedsAZHints("", sameAZ))
+	assert.True(t, NeedsAZHints("", multiAZ))
+	assert.Equal(t, []string{"us-east-1a", "us-east-1b"}, AvailabilityZones(multiAZ))
+}
+
+func TestClient_SetNodeAvailabilityZones(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "scontrol.log")
+	client := newFakeScontrolClient(t, `
+if [ "$1" = "show" ]; then
+  echo "NodeName=aws-cpu-001 AvailableFeatures=c5n,az-us-east-1c State=IDLE+CLOUD"
+  echo "NodeName=aws-cpu-002 AvailableFeatures=c5n,az-us-east-1b State=IDLE+CLOUD"
+  exit 0
+fi
+echo "$@" >> `+logFile+`
+`)
+
+	err := client.SetNodeAvailabilityZones(context.Background(), []types.InstanceInfo{
+		{NodeName: "aws-cpu-001", AvailabilityZone: "us-east-1a"},
+		{NodeName: "aws-cpu-002", AvailabilityZone: "us-east-1b"},
+	})
+	require.NoError(t, err)
+
+	updates, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(updates)), "\n")
+	require.Len(t, lines, 1, "node already in the right AZ should not be updated")
+	assert.Equal(t, "update nodename=aws-cpu-001 AvailableFeatures=c5n,az-us-east-1a ActiveFeatures=c5n,az-us-east-1a", lines[0])
+}
+
+func TestClient_NodePlacement(t *testing.T) {
+	client := newFakeScontrolClient(t, `
+echo 'NodeName=aws-cpu-001 State=ALLOCATED+CLOUD Comment=aws_meta:{"instances":["c5n.xlarge"],"node_azs":{"aws-cpu-001":"us-east-1a"},"fleet_id":"fleet-1","execution_mode":"standalone","trace_id":"t1"}'
+echo 'NodeName=aws-cpu-002 State=ALLOCATED+CLOUD Comment=aws_meta:{"instances":["c5n.2xlarge"],"node_azs":{"aws-cpu-002":"us-east-1b"},"fleet_id":"fleet-1","execution_mode":"standalone","trace_id":"t1"}'
+echo 'NodeName=aws-cpu-003 State=ALLOCATED+CLOUD Comment=(null)'
+`)
+
+	meta, err := client.NodePlacement(context.Background(), []string{"aws-cpu-001", "aws-cpu-002", "aws-cpu-003"})
+	require.NoError(t, err)
+	require.NotNil(t, meta)
+	assert.Equal(t, []string{"c5n.xlarge", "c5n.2xlarge"}, meta.Instances)
+	assert.Len(t, meta.NodeAvailabilityZones, 2)
+	assert.Equal(t, "fleet-1", meta.FleetID)
+	assert.Equal(t, "standalone", meta.ExecutionMode)
+	assert.Equal(t, "t1", meta.TraceID)
+}
+
+func TestJobField(t *testing.T) {
+	line := `JobId=42 JobName=run Comment=needs review today AdminComment=aws_meta:{"cost":1} Partition=aws`
+	assert.Equal(t, "needs review today", jobField(line, "Comment"))
+	assert.Equal(t, `aws_meta:{"cost":1}`, jobField(line, "AdminComment"))
+	assert.Equal(t, "42", jobField(line, "JobId"))
+	assert.Equal(t, "", jobField("JobId=42 AdminComment=(null)", "AdminComment"))
+	assert.Equal(t, "", jobField("JobId=42", "Comment"))
+}
+
+func TestClient_SetJobMetadata(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "scontrol.log")
+	client := newFakeScontrolClient(t, `
+if [ "$1" = "show" ]; then
+  echo 'JobId=42 Comment=(null) AdminComment=reviewed aws_meta.1/2:{"cost" SystemComment=aws_meta.2/2::1} Partition=aws'
+  exit 0
+fi
+echo "$@" >> `+logFile+`
+`)
+	client.config.JobMetadata = config.JobMetadataConfig{Field: "AdminComment", MaxBytes: 256, OverflowFields: []string{"SystemComment"}}
+
+	meta, err := client.JobMetadata(context.Background(), "42")
+	require.NoError(t, err)
+	require.NotNil(t, meta)
+	assert.Equal(t, 1.0, meta.Cost)
+
+	meta.TraceID = "t1"
+	require.NoError(t, client.SetJobMetadata(context.Background(), "42", meta))
+
+	updates, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, `update jobid=42 AdminComment=reviewed aws_meta:{"cost":1,"trace_id":"t1"} SystemComment=`,
+		strings.TrimSpace(string(updates)), "metadata that fits again clears the stale overflow chunk")
+}
@@ -0,0 +1,123 @@
+package slurm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is a Slurm release, e.g. 23.02.7
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// MinimumVersion is the oldest Slurm release aws-slurm-burst supports
+var MinimumVersion = Version{Major: 21, Minor: 8}
+
+// Capability is a Slurm feature aws-slurm-burst relies on or uses when present
+type Capability string
+
+// Capabilities gated on the Slurm release
+const (
+	// CapabilityPowerSave is power saving with POWER_DOWN_ASAP and
+	// POWER_DOWN_FORCE node states, which suspend and scaling rely on
+	CapabilityPowerSave Capability = "power_save"
+	// CapabilityCloudRegAddrs is SlurmctldParameters=cloud_reg_addrs, which
+	// lets cloud nodes register their own address
+	CapabilityCloudRegAddrs Capability = "cloud_reg_addrs"
+	// CapabilityJSON is --json output from scontrol, squeue and sacct; older
+	// releases are read through their text output
+	CapabilityJSON Capability = "json"
+)
+
+// capabilitySince is the release each capability arrived in
+var capabilitySince = map[Capability]Version{
+	CapabilityPowerSave:     {Major: 21, Minor: 8},
+	CapabilityCloudRegAddrs: {Major: 22, Minor: 5},
+	CapabilityJSON:          {Major: 23, Minor: 2},
+}
+
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseVersion reads the release from scontrol --version output, e.g.
+// "slurm 23.02.7" or "slurm-wlm 21.08.5"
+func ParseVersion(output string) (Version, error) {
+	matches := versionPattern.FindStringSubmatch(output)
+	if matches == nil {
+		return Version{}, fmt.Errorf("no Slurm version in %q", strings.TrimSpace(output))
+	}
+
+	var version Version
+	version.Major, _ = strconv.Atoi(matches[1])
+	version.Minor, _ = strconv.Atoi(matches[2])
+	if matches[3] != "" {
+		version.Patch, _ = strconv.Atoi(matches[3])
+	}
+	return version, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%02d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether v is an older release than other
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+// Supports reports whether the release has the capability
+func (v Version) Supports(capability Capability) bool {
+	since, ok := capabilitySince[capability]
+	return ok && !v.Less(since)
+}
+
+// VersionWarnings lists what limits aws-slurm-burst on a Slurm release, given
+// the SlurmctldParameters from slurm.conf
+func VersionWarnings(version Version, slurmctldParameters string) []string {
+	var warnings []string
+	if version.Less(MinimumVersion) {
+		warnings = append(warnings, fmt.Sprintf("Slurm %s is not supported; upgrade to %d.%02d or later", version, MinimumVersion.Major, MinimumVersion.Minor))
+	}
+	for _, parameter := range strings.Split(slurmctldParameters, ",") {
+		if strings.TrimSpace(parameter) == string(CapabilityCloudRegAddrs) && !version.Supports(CapabilityCloudRegAddrs) {
+			warnings = append(warnings, fmt.Sprintf("SlurmctldParameters=cloud_reg_addrs needs Slurm 22.05 or later, this is %s", version))
+		}
+	}
+	if !version.Supports(CapabilityJSON) {
+		warnings = append(warnings, fmt.Sprintf("Slurm %s has no --json output; job and node queries fall back to parsing text", version))
+	}
+	return warnings
+}
+
+// Version returns the installed Slurm release, probing scontrol --version on
+// the first call
+func (c *Client) Version() (Version, error) {
+	c.versionOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		output, err := c.command(ctx, "scontrol", "--version")
+		if err != nil {
+			c.versionErr = fmt.Errorf("failed to read Slurm version: %w", err)
+			return
+		}
+		c.version, c.versionErr = ParseVersion(string(output))
+	})
+	return c.version, c.versionErr
+}
+
+// lacks reports whether the installed release is known to be without the
+// capability; an unknown release is assumed to have it
+func (c *Client) lacks(capability Capability) bool {
+	version, err := c.Version()
+	return err == nil && !version.Supports(capability)
+}
@@ -0,0 +1,60 @@
+package slurm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]Version{
+		"slurm 23.02.7\n":    {Major: 23, Minor: 2, Patch: 7},
+		"slurm-wlm 21.08.5":  {Major: 21, Minor: 8, Patch: 5},
+		"slurm 24.05.0-0rc1": {Major: 24, Minor: 5},
+		"slurm 25.05":        {Major: 25, Minor: 5},
+	}
+	for output, expected := range tests {
+		version, err := ParseVersion(output)
+		require.NoError(t, err, output)
+		assert.Equal(t, expected, version, output)
+	}
+
+	_, err := ParseVersion("scontrol: command not found")
+	assert.Error(t, err)
+	assert.Equal(t, "23.02.7", Version{Major: 23, Minor: 2, Patch: 7}.String())
+}
+
+func TestVersion_Supports(t *testing.T) {
+	assert.True(t, Version{Major: 23, Minor: 2}.Supports(CapabilityJSON))
+	assert.False(t, Version{Major: 22, Minor: 5, Patch: 9}.Supports(CapabilityJSON))
+	assert.True(t, Version{Major: 22, Minor: 5}.Supports(CapabilityCloudRegAddrs))
+	assert.False(t, Version{Major: 21, Minor: 8}.Supports(CapabilityCloudRegAddrs))
+	assert.False(t, Version{Major: 30}.Supports("unknown"))
+}
+
+func TestVersionWarnings(t *testing.T) {
+	assert.Empty(t, VersionWarnings(Version{Major: 23, Minor: 11}, "cloud_reg_addrs,idle_on_node_suspend"))
+
+	warnings := VersionWarnings(Version{Major: 21, Minor: 8, Patch: 8}, "enable_configless,cloud_reg_addrs")
+	require.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "cloud_reg_addrs needs Slurm 22.05")
+	assert.Contains(t, warnings[1], "no --json output")
+
+	warnings = VersionWarnings(Version{Major: 20, Minor: 11}, "")
+	assert.Contains(t, warnings[0], "Slurm 20.11.0 is not supported")
+}
+
+func TestClient_Version(t *testing.T) {
+	client := newFakeScontrolClientJSON(t, `echo "slurm 22.05.9"`)
+
+	version, err := client.Version()
+	require.NoError(t, err)
+	assert.Equal(t, Version{Major: 22, Minor: 5, Patch: 9}, version)
+	assert.True(t, client.lacks(CapabilityJSON), "JSON is not attempted on releases without it")
+
+	unknown := newFakeScontrolClientJSON(t, "exit 1")
+	_, err = unknown.Version()
+	assert.Error(t, err)
+	assert.False(t, unknown.lacks(CapabilityJSON), "unknown releases are tried")
+}
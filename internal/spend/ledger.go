@@ -0,0 +1,246 @@
+// Package spend keeps the monthly burst spend of partitions, accounts and users and
+// enforces their ceilings. Export records each job's cost in the ledger of the
+// month the job ended and resume checks it before bursting, so the ledger is a
+// state file per month shared between processes under an advisory lock.
+package spend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/filelock"
+)
+
+// monthLayout names a month's ledger
+const monthLayout = "2006-01"
+
+// Charge is the cost of one job
+type Charge struct {
+	Partition string  `json:"partition"`
+	Account   string  `json:"account,omitempty"`
+	User      string  `json:"user,omitempty"`
+	CostUSD   float64 `json:"cost_usd"`
+}
+
+// month is one month's ledger
+type month struct {
+	Jobs      map[string]Charge `json:"jobs"`                // By job ID, so exporting a job again replaces its charge
+	Overrides []string          `json:"overrides,omitempty"` // Scopes an administrator unlocked for the month
+	Notified  []string          `json:"notified,omitempty"`  // Scopes whose lockout has been notified
+}
+
+// Lockout is a ceiling a partition, account or user has reached
+type Lockout struct {
+	Scope      string  `json:"scope"`
+	Month      string  `json:"month"`
+	CeilingUSD float64 `json:"ceiling_usd"`
+	SpentUSD   float64 `json:"spent_usd"`
+}
+
+// Reason is the Slurm node reason for the lockout
+func (l *Lockout) Reason() string {
+	return fmt.Sprintf("spend_ceiling_%s", l.Scope)
+}
+
+// Total is the month's spend of one scope
+type Total struct {
+	Scope      string  `json:"scope"`
+	SpentUSD   float64 `json:"spent_usd"`
+	CeilingUSD float64 `json:"ceiling_usd,omitempty"` // Zero when uncapped
+	Overridden bool    `json:"overridden,omitempty"`
+}
+
+// Ledger is the monthly spend under a directory
+type Ledger struct {
+	dir string
+	now func() time.Time
+}
+
+// New creates a ledger keeping dir/<YYYY-MM>.json
+func New(dir string) *Ledger {
+	return &Ledger{dir: dir, now: time.Now}
+}
+
+// Scope names what a ceiling caps, "partition:<name>", "account:<name>" or
+// "user:<name>"
+func Scope(ceiling config.CeilingConfig) string {
+	switch {
+	case ceiling.Partition != "":
+		return "partition:" + ceiling.Partition
+	case ceiling.User != "":
+		return "user:" + ceiling.User
+	}
+	return "account:" + ceiling.Account
+}
+
+// Record charges a job's cost to the month it ended in
+func (l *Ledger) Record(jobID string, ended time.Time, charge Charge) error {
+	if ended.IsZero() {
+		ended = l.now()
+	}
+	return l.update(ended.UTC().Format(monthLayout), func(ledger *month) bool {
+		ledger.Jobs[jobID] = charge
+		return true
+	})
+}
+
+// Check returns the first ceiling of the limits the partition, account or user
+// has reached this month that no administrator has overridden, or nil
+func (l *Ledger) Check(limits []config.CeilingConfig, partition, account, user string) (*Lockout, error) {
+	name := l.now().UTC().Format(monthLayout)
+	var lockout *Lockout
+	err := l.update(name, func(ledger *month) bool {
+		for _, ceiling := range limits {
+			if ceiling.Partition != "" && ceiling.Partition != partition || ceiling.Account != "" && ceiling.Account != account ||
+				ceiling.User != "" && ceiling.User != user {
+				continue
+			}
+			scope := Scope(ceiling)
+			spent := ledger.spent(ceiling)
+			if spent >= ceiling.MonthlyUSD && !slices.Contains(ledger.Overrides, scope) {
+				lockout = &Lockout{Scope: scope, Month: name, CeilingUSD: ceiling.MonthlyUSD, SpentUSD: spent}
+				return false
+			}
+		}
+		return false
+	})
+	return lockout, err
+}
+
+// MarkNotified records that a lockout has been notified, and reports whether
+// it had not been before this month
+func (l *Ledger) MarkNotified(lockout *Lockout) (bool, error) {
+	first := false
+	err := l.update(lockout.Month, func(ledger *month) bool {
+		first = !slices.Contains(ledger.Notified, lockout.Scope)
+		if first {
+			ledger.Notified = append(ledger.Notified, lockout.Scope)
+		}
+		return first
+	})
+	return first, err
+}
+
+// Override unlocks a scope until the month rolls over
+func (l *Ledger) Override(scope string) error {
+	return l.update(l.now().UTC().Format(monthLayout), func(ledger *month) bool {
+		if slices.Contains(ledger.Overrides, scope) {
+			return false
+		}
+		ledger.Overrides = append(ledger.Overrides, scope)
+		return true
+	})
+}
+
+// Totals returns this month's spend of every partition, account and user charged
+// or capped, sorted by scope
+func (l *Ledger) Totals(limits []config.CeilingConfig) ([]Total, error) {
+	var totals []Total
+	err := l.update(l.now().UTC().Format(monthLayout), func(ledger *month) bool {
+		byScope := make(map[string]*Total)
+		total := func(scope string) *Total {
+			if byScope[scope] == nil {
+				byScope[scope] = &Total{Scope: scope, Overridden: slices.Contains(ledger.Overrides, scope)}
+			}
+			return byScope[scope]
+		}
+		for _, charge := range ledger.Jobs {
+			total("partition:" + charge.Partition).SpentUSD += charge.CostUSD
+			if charge.Account != "" {
+				total("account:" + charge.Account).SpentUSD += charge.CostUSD
+			}
+			if charge.User != "" {
+				total("user:" + charge.User).SpentUSD += charge.CostUSD
+			}
+		}
+		for _, ceiling := range limits {
+			total(Scope(ceiling)).CeilingUSD = ceiling.MonthlyUSD
+		}
+
+		for _, scopeTotal := range byScope {
+			totals = append(totals, *scopeTotal)
+		}
+		sort.Slice(totals, func(i, j int) bool { return totals[i].Scope < totals[j].Scope })
+		return false
+	})
+	return totals, err
+}
+
+// spent totals the month's charges a ceiling caps
+func (m *month) spent(ceiling config.CeilingConfig) float64 {
+	total := 0.0
+	for _, charge := range m.Jobs {
+		if ceiling.Partition != "" && charge.Partition == ceiling.Partition ||
+			ceiling.Account != "" && charge.Account == ceiling.Account ||
+			ceiling.User != "" && charge.User == ceiling.User {
+			total += charge.CostUSD
+		}
+	}
+	return total
+}
+
+// update locks and reads a month's ledger and saves it when change reports a change
+func (l *Ledger) update(name string, change func(ledger *month) bool) error {
+	if l.dir == "" {
+		return fmt.Errorf("no spend ledger directory configured")
+	}
+	unlock, err := filelock.Lock(filepath.Join(l.dir, name+".lock"), 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to lock spend ledger %s: %w", name, err)
+	}
+	defer unlock()
+
+	path := filepath.Join(l.dir, name+".json")
+	ledger := &month{}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, ledger); err != nil {
+			return fmt.Errorf("failed to parse spend ledger %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read spend ledger %s: %w", path, err)
+	}
+	if ledger.Jobs == nil {
+		ledger.Jobs = make(map[string]Charge)
+	}
+
+	if !change(ledger) {
+		return nil
+	}
+	if data, err = json.MarshalIndent(ledger, "", "  "); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write spend ledger %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Notify runs the notify command for a lockout through the shell, with the
+// lockout in its environment
+func Notify(ctx context.Context, command string, lockout *Lockout) error {
+	if command == "" {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"AWS_SLURM_BURST_CEILING_SCOPE="+lockout.Scope,
+		"AWS_SLURM_BURST_CEILING_MONTH="+lockout.Month,
+		"AWS_SLURM_BURST_CEILING_USD="+strconv.FormatFloat(lockout.CeilingUSD, 'f', 2, 64),
+		"AWS_SLURM_BURST_SPENT_USD="+strconv.FormatFloat(lockout.SpentUSD, 'f', 2, 64))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notify command failed: %w: %s", err, output)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package spend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedgerCeilings(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	ledger := New(t.TempDir())
+	ledger.now = func() time.Time { return now }
+	limits := []config.CeilingConfig{{Partition: "aws", MonthlyUSD: 100}, {Account: "chem", MonthlyUSD: 50}}
+
+	require.NoError(t, ledger.Record("1", now, Charge{Partition: "aws", Account: "bio", CostUSD: 60}))
+	require.NoError(t, ledger.Record("2", now, Charge{Partition: "aws", Account: "chem", CostUSD: 30}))
+	require.NoError(t, ledger.Record("2", now, Charge{Partition: "aws", Account: "chem", CostUSD: 35}), "a re-export replaces the charge")
+	require.NoError(t, ledger.Record("3", now.AddDate(0, -1, 0), Charge{Partition: "aws", CostUSD: 500}), "last month's spend")
+
+	lockout, err := ledger.Check(limits, "aws", "bio", "")
+	require.NoError(t, err)
+	assert.Nil(t, lockout, "95 of 100")
+
+	require.NoError(t, ledger.Record("4", now, Charge{Partition: "aws", Account: "bio", CostUSD: 10}))
+	lockout, err = ledger.Check(limits, "aws", "bio", "")
+	require.NoError(t, err)
+	require.NotNil(t, lockout)
+	assert.Equal(t, "partition:aws", lockout.Scope)
+	assert.Equal(t, "2026-10", lockout.Month)
+	assert.InDelta(t, 105, lockout.SpentUSD, 1e-9)
+	assert.Equal(t, "spend_ceiling_partition:aws", lockout.Reason())
+
+	first, err := ledger.MarkNotified(lockout)
+	require.NoError(t, err)
+	assert.True(t, first)
+	first, err = ledger.MarkNotified(lockout)
+	require.NoError(t, err)
+	assert.False(t, first, "notified once a month")
+
+	require.NoError(t, ledger.Override("partition:aws"))
+	lockout, err = ledger.Check(limits, "aws", "bio", "")
+	require.NoError(t, err)
+	assert.Nil(t, lockout, "overridden by an administrator")
+
+	require.NoError(t, ledger.Record("5", now, Charge{Partition: "aws", Account: "chem", CostUSD: 20}))
+	lockout, err = ledger.Check(limits, "aws", "chem", "")
+	require.NoError(t, err)
+	require.NotNil(t, lockout)
+	assert.Equal(t, "account:chem", lockout.Scope)
+
+	// Users are capped on their own charges, whatever their account
+	userLimits := append(limits, config.CeilingConfig{User: "alice", MonthlyUSD: 40})
+	require.NoError(t, ledger.Record("6", now, Charge{Partition: "aws", Account: "bio", User: "alice", CostUSD: 40}))
+	lockout, err = ledger.Check(userLimits, "aws", "bio", "bob")
+	require.NoError(t, err)
+	assert.Nil(t, lockout)
+	lockout, err = ledger.Check(userLimits, "aws", "bio", "alice")
+	require.NoError(t, err)
+	require.NotNil(t, lockout)
+	assert.Equal(t, "user:alice", lockout.Scope)
+
+	totals, err := ledger.Totals(limits)
+	require.NoError(t, err)
+	assert.Equal(t, []Total{
+		{Scope: "account:bio", SpentUSD: 110},
+		{Scope: "account:chem", SpentUSD: 55, CeilingUSD: 50},
+		{Scope: "partition:aws", SpentUSD: 165, CeilingUSD: 100, Overridden: true},
+		{Scope: "user:alice", SpentUSD: 40},
+	}, totals)
+
+	// The month rolls over
+	now = now.AddDate(0, 1, 0)
+	lockout, err = ledger.Check(limits, "aws", "chem", "")
+	require.NoError(t, err)
+	assert.Nil(t, lockout)
+}
+
+func TestNotify(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "notified")
+	lockout := &Lockout{Scope: "account:chem", Month: "2026-10", CeilingUSD: 50, SpentUSD: 55.5}
+
+	require.NoError(t, Notify(context.Background(), `echo "$AWS_SLURM_BURST_CEILING_SCOPE $AWS_SLURM_BURST_SPENT_USD" > `+output, lockout))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "account:chem 55.50\n", string(data))
+
+	assert.Error(t, Notify(context.Background(), "exit 3", lockout))
+}
@@ -0,0 +1,157 @@
+package ssm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// StatusUndeliverable is the status of a node the command could not be sent
+// to; the result's output says why
+const StatusUndeliverable = "Undeliverable"
+
+// Command is a shell script to run as root on nodes
+type Command struct {
+	Script     string
+	Comment    string        // Shown in the SSM console and CloudTrail
+	Timeout    time.Duration // How long the script may run on a node
+	FullOutput bool          // Fetch the full output of finished scripts, for logs
+}
+
+// Nodes runs commands on burst nodes by node name. Each node's command goes
+// to the SSM client of the region its instance runs in.
+type Nodes struct {
+	logger    *zap.Logger
+	cfg       *config.Config
+	instances map[string]types.InstanceInfo // Running instances by node name
+
+	newClient func(ctx context.Context, region string) (*Client, error)
+}
+
+// NewNodes addresses the nodes of the instances listed, as returned by the
+// AWS client's ListInstances
+func NewNodes(logger *zap.Logger, cfg *config.Config, instances []types.InstanceInfo) *Nodes {
+	nodes := &Nodes{
+		logger:    logger,
+		cfg:       cfg,
+		instances: make(map[string]types.InstanceInfo, len(instances)),
+	}
+	for _, instance := range instances {
+		if instance.State == "pending" || instance.State == "running" {
+			nodes.instances[instance.NodeName] = instance
+		}
+	}
+	nodes.newClient = func(ctx context.Context, region string) (*Client, error) {
+		return NewClient(ctx, logger, &cfg.AWS, region)
+	}
+	return nodes
+}
+
+// Instance returns the running instance of a node
+func (n *Nodes) Instance(node string) (types.InstanceInfo, bool) {
+	instance, ok := n.instances[node]
+	return instance, ok
+}
+
+// region returns the region a node's instance runs in
+func (n *Nodes) region(instance types.InstanceInfo) string {
+	if nodeGroup := n.cfg.FindNodeGroup(instance.Partition, instance.NodeGroup); nodeGroup != nil && nodeGroup.Region != "" {
+		return nodeGroup.Region
+	}
+	return n.cfg.AWS.Region
+}
+
+// Run runs the command on the nodes and returns each node's result. Nodes
+// without a running instance or a connected SSM agent are returned as
+// undeliverable. A region whose API calls fail is reported in the error while
+// the other regions' results are still returned.
+func (n *Nodes) Run(ctx context.Context, nodes []string, command Command) (map[string]CommandResult, error) {
+	results := make(map[string]CommandResult, len(nodes))
+	byRegion := make(map[string]map[string]string) // Node names by instance ID, by region
+	for _, node := range nodes {
+		instance, ok := n.instances[node]
+		if !ok {
+			results[node] = CommandResult{Status: StatusUndeliverable, Output: "no running instance"}
+			continue
+		}
+		region := n.region(instance)
+		if byRegion[region] == nil {
+			byRegion[region] = make(map[string]string)
+		}
+		byRegion[region][instance.InstanceID] = node
+	}
+
+	regions := make([]string, 0, len(byRegion))
+	for region := range byRegion {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	var errs []error
+	for _, region := range regions {
+		if err := n.runInRegion(ctx, region, byRegion[region], command, results); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", region, err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// runInRegion runs the command on one region's nodes, given by instance ID
+func (n *Nodes) runInRegion(ctx context.Context, region string, nodes map[string]string, command Command, results map[string]CommandResult) error {
+	client, err := n.newClient(ctx, region)
+	if err != nil {
+		return err
+	}
+	instanceIDs := make([]string, 0, len(nodes))
+	for instanceID := range nodes {
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+	sort.Strings(instanceIDs)
+
+	online, err := client.Online(ctx, instanceIDs)
+	if err != nil {
+		return err
+	}
+	onlineSet := make(map[string]bool, len(online))
+	for _, instanceID := range online {
+		onlineSet[instanceID] = true
+	}
+	for _, instanceID := range instanceIDs {
+		if !onlineSet[instanceID] {
+			results[nodes[instanceID]] = CommandResult{InstanceID: instanceID, Status: StatusUndeliverable, Output: "SSM agent not online"}
+		}
+	}
+	if len(online) == 0 {
+		return nil
+	}
+
+	ran, err := client.RunShellScript(ctx, online, command.Script, command.Comment, command.Timeout)
+	if err != nil {
+		return err
+	}
+	for _, result := range ran {
+		if command.FullOutput && result.Done() {
+			output, err := client.FullOutput(ctx, result)
+			if err != nil {
+				n.logger.Warn("Failed to get full command output, keeping the truncated output",
+					zap.String("node", nodes[result.InstanceID]), zap.Error(err))
+			} else {
+				result.Output = output
+			}
+		}
+		results[nodes[result.InstanceID]] = result
+	}
+	// SSM may not list an invocation yet when the context ends
+	for _, instanceID := range online {
+		if _, ok := results[nodes[instanceID]]; !ok {
+			results[nodes[instanceID]] = CommandResult{InstanceID: instanceID, Status: "Pending"}
+		}
+	}
+	return nil
+}
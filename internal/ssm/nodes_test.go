@@ -0,0 +1,78 @@
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNodes_Run(t *testing.T) {
+	east := &fakeSSM{online: map[string]bool{"i-good": true, "i-bad": true}, commands: map[string][]string{}}
+	west := &fakeSSM{online: map[string]bool{"i-west": true}, commands: map[string][]string{}}
+
+	cfg := &config.Config{AWS: config.AWSConfig{Region: "us-east-1"}}
+	cfg.Slurm.Partitions = []config.PartitionConfig{{
+		PartitionName: "gpu",
+		NodeGroups:    []config.NodeGroupConfig{{NodeGroupName: "a100", Region: "us-west-2"}},
+	}}
+	nodes := NewNodes(zap.NewNop(), cfg, []types.InstanceInfo{
+		{NodeName: "aws-cpu-001", InstanceID: "i-good", State: "running", Partition: "cpu", NodeGroup: "c5"},
+		{NodeName: "aws-cpu-002", InstanceID: "i-bad", State: "running", Partition: "cpu", NodeGroup: "c5"},
+		{NodeName: "aws-cpu-003", InstanceID: "i-booting", State: "pending", Partition: "cpu", NodeGroup: "c5"},
+		{NodeName: "aws-cpu-004", InstanceID: "i-gone", State: "terminated", Partition: "cpu", NodeGroup: "c5"},
+		{NodeName: "aws-gpu-001", InstanceID: "i-west", State: "running", Partition: "gpu", NodeGroup: "a100"},
+	})
+	nodes.newClient = func(ctx context.Context, region string) (*Client, error) {
+		switch region {
+		case "us-east-1":
+			return newTestClient(east), nil
+		case "us-west-2":
+			return newTestClient(west), nil
+		}
+		return nil, fmt.Errorf("unexpected region %s", region)
+	}
+
+	results, err := nodes.Run(context.Background(), []string{"aws-cpu-001", "aws-cpu-002", "aws-cpu-003", "aws-cpu-004", "aws-gpu-001"},
+		Command{Script: "true", Comment: "test", Timeout: time.Minute, FullOutput: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]CommandResult{
+		"aws-cpu-001": {CommandID: "cmd-a", InstanceID: "i-good", Status: "Success", Output: "full i-good\nwarning\n"},
+		"aws-cpu-002": {CommandID: "cmd-a", InstanceID: "i-bad", Status: "Failed", Output: "full i-bad\nwarning\n"},
+		"aws-cpu-003": {InstanceID: "i-booting", Status: StatusUndeliverable, Output: "SSM agent not online"},
+		"aws-cpu-004": {Status: StatusUndeliverable, Output: "no running instance"},
+		"aws-gpu-001": {CommandID: "cmd-a", InstanceID: "i-west", Status: "Success", Output: "full i-west\nwarning\n"},
+	}, results)
+	assert.Equal(t, []string{"i-west"}, west.commands["cmd-a"])
+
+	// A failing region is reported while the others' results are kept
+	west.down = true
+	east.polls = 0
+	results, err = nodes.Run(context.Background(), []string{"aws-cpu-001", "aws-gpu-001"}, Command{Script: "true", Timeout: time.Minute})
+	assert.ErrorContains(t, err, "us-west-2: failed to describe SSM instances")
+	assert.Equal(t, "ok i-good\n", results["aws-cpu-001"].Output)
+	assert.NotContains(t, results, "aws-gpu-001")
+
+	instance, ok := nodes.Instance("aws-cpu-003")
+	assert.True(t, ok)
+	assert.Equal(t, "i-booting", instance.InstanceID)
+	_, ok = nodes.Instance("aws-cpu-004")
+	assert.False(t, ok)
+}
+
+func TestScripts(t *testing.T) {
+	assert.Contains(t, LogsScript(50), "lines=50\n")
+	assert.Contains(t, LogsScript(50), "/var/log/cloud-init-output.log")
+	assert.Contains(t, CleanupScript(), `pkill -KILL -U "$uid"`)
+	assert.Contains(t, CleanupScript(), "systemctl restart slurmd")
+	assert.Contains(t, LingerStopScript(), "systemctl stop slurmd")
+	assert.Contains(t, ClaimScript(""), `sed -i -E "s/-N [^ \"]+/-N $NODE/"`)
+	assert.True(t, strings.HasSuffix(ClaimScript("/opt/site/claim.sh"), "export NODE\n/opt/site/claim.sh\n"))
+}
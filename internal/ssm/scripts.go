@@ -0,0 +1,107 @@
+package ssm
+
+import "fmt"
+
+// LogsScript prints the last lines of a node's slurmd, cloud-init and
+// aws-slurm-burst logs, each under a header naming it
+func LogsScript(lines int) string {
+	return fmt.Sprintf(`#!/bin/bash
+# Generated by aws-slurm-burst: node log collection
+lines=%d
+section() { echo "===== $1 ====="; }
+section "slurmd"
+if [ -s /var/log/slurm/slurmd.log ]; then
+  tail -n "$lines" /var/log/slurm/slurmd.log
+else
+  journalctl -u slurmd -n "$lines" --no-pager 2>&1
+fi
+section "cloud-init-output"
+tail -n "$lines" /var/log/cloud-init-output.log 2>&1
+for log in /var/log/aws-slurm-burst-*.log; do
+  [ -e "$log" ] || continue
+  section "$(basename "$log" .log)"
+  tail -n "$lines" "$log"
+done
+exit 0
+`, lines)
+}
+
+// CleanupScript kills every user process on a node and restarts slurmd, for
+// jobs Slurm could not clean up itself. System accounts (UID below 1000) are
+// left alone.
+func CleanupScript() string {
+	return `#!/bin/bash
+# Generated by aws-slurm-burst: emergency node cleanup
+for uid in $(ps -eo uid= | sort -un); do
+  if [ "$uid" -ge 1000 ] && [ "$uid" -ne 65534 ]; then
+    echo "killing processes of uid $uid"
+    pkill -KILL -U "$uid"
+  fi
+done
+systemctl restart slurmd
+systemctl is-active --quiet slurmd || { echo "slurmd failed to restart"; exit 1; }
+echo "slurmd restarted"
+`
+}
+
+// LingerStopScript stops slurmd on a node whose instance will linger, so it
+// no longer answers as the suspended node while it waits to be claimed. A
+// node whose slurmd was not running fails, so its instance is not reused.
+func LingerStopScript() string {
+	return `#!/bin/bash
+# Generated by aws-slurm-burst: stop slurmd before lingering
+if ! systemctl is-active --quiet slurmd; then
+  echo "slurmd is not running; the instance is not reused"
+  exit 1
+fi
+systemctl stop slurmd
+if systemctl is-active --quiet slurmd; then
+  echo "slurmd failed to stop"
+  exit 1
+fi
+echo "slurmd stopped"
+`
+}
+
+// ClaimScript restarts slurmd on a claimed lingering instance as the node the
+// instance is now tagged with. slurmd takes the node name from -N in
+// SLURMD_OPTIONS, as node bootstrap writes it, or else from the hostname. A
+// non-empty command replaces the restart and runs with NODE exported.
+func ClaimScript(command string) string {
+	script := `#!/bin/bash
+# Generated by aws-slurm-burst: start slurmd as the node claiming this instance
+set -uo pipefail
+TOKEN=$(curl -sS -X PUT http://169.254.169.254/latest/api/token -H "X-aws-ec2-metadata-token-ttl-seconds: 300")
+metadata() {
+  curl -sfS -H "X-aws-ec2-metadata-token: $TOKEN" "http://169.254.169.254/latest/meta-data/$1"
+}
+NODE=$(metadata tags/instance/SlurmNode 2>/dev/null)
+if [ -z "$NODE" ]; then
+  NODE=$(aws ec2 describe-tags --region "$(metadata placement/region)" \
+    --filters "Name=resource-id,Values=$(metadata instance-id)" "Name=key,Values=SlurmNode" \
+    --query 'Tags[0].Value' --output text 2>/dev/null)
+fi
+if [ -z "$NODE" ] || [ "$NODE" = "None" ]; then
+  echo "Instance is not tagged with its Slurm node name"
+  exit 1
+fi
+export NODE
+`
+	if command != "" {
+		return script + command + "\n"
+	}
+	return script + `renamed=""
+for env in /etc/sysconfig/slurmd /etc/default/slurmd; do
+  if [ -f "$env" ] && grep -q -- '-N ' "$env"; then
+    sed -i -E "s/-N [^ \"]+/-N $NODE/" "$env"
+    renamed=1
+  fi
+done
+if [ -z "$renamed" ]; then
+  hostnamectl set-hostname "$NODE"
+fi
+systemctl restart slurmd
+systemctl is-active --quiet slurmd || { echo "slurmd failed to start as $NODE"; exit 1; }
+echo "slurmd started as $NODE"
+`
+}
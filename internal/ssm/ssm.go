@@ -0,0 +1,181 @@
+// Package ssm runs commands on burst nodes through AWS Systems Manager Run
+// Command: health checks, log collection, emergency cleanup and shutdown
+// hooks. Nodes need the SSM agent and an outbound path to SSM, but no SSH keys
+// or inbound ports.
+package ssm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsSSM "github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	burstAWS "github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"go.uber.org/zap"
+)
+
+// maxInstances is the most instances one SendCommand or
+// DescribeInstanceInformation filter takes
+const maxInstances = 50
+
+// CommandResult is the outcome of a shell script on one instance
+type CommandResult struct {
+	CommandID  string
+	InstanceID string
+	Status     string // SSM's invocation status: Success, Failed, TimedOut, ...
+	Output     string // Standard output and error, truncated by SSM to 2500 characters
+}
+
+// Done reports whether the script has finished
+func (r CommandResult) Done() bool {
+	return !slices.Contains([]string{"Pending", "InProgress", "Delayed", "Cancelling"}, r.Status)
+}
+
+// API is the part of the SSM client Run Command needs; tests substitute a fake
+type API interface {
+	DescribeInstanceInformation(ctx context.Context, params *awsSSM.DescribeInstanceInformationInput, optFns ...func(*awsSSM.Options)) (*awsSSM.DescribeInstanceInformationOutput, error)
+	SendCommand(ctx context.Context, params *awsSSM.SendCommandInput, optFns ...func(*awsSSM.Options)) (*awsSSM.SendCommandOutput, error)
+	ListCommandInvocations(ctx context.Context, params *awsSSM.ListCommandInvocationsInput, optFns ...func(*awsSSM.Options)) (*awsSSM.ListCommandInvocationsOutput, error)
+	GetCommandInvocation(ctx context.Context, params *awsSSM.GetCommandInvocationInput, optFns ...func(*awsSSM.Options)) (*awsSSM.GetCommandInvocationOutput, error)
+}
+
+var _ API = (*awsSSM.Client)(nil)
+
+// Client runs shell scripts on the instances in one region
+type Client struct {
+	logger *zap.Logger
+	api    API
+
+	pollInterval time.Duration
+}
+
+// NewClient creates a client for instances in region
+func NewClient(ctx context.Context, logger *zap.Logger, awsConfig *config.AWSConfig, region string) (*Client, error) {
+	cfg, err := burstAWS.LoadConfig(ctx, logger, awsConfig, region)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(logger, awsSSM.NewFromConfig(cfg)), nil
+}
+
+func newClient(logger *zap.Logger, api API) *Client {
+	return &Client{
+		logger:       logger,
+		api:          api,
+		pollInterval: 3 * time.Second,
+	}
+}
+
+// Online returns the instances whose SSM agent is connected; the others
+// cannot run commands yet
+func (c *Client) Online(ctx context.Context, instanceIDs []string) ([]string, error) {
+	var online []string
+	for start := 0; start < len(instanceIDs); start += maxInstances {
+		input := &awsSSM.DescribeInstanceInformationInput{
+			Filters:    []ssmTypes.InstanceInformationStringFilter{{Key: aws.String("InstanceIds"), Values: instanceIDs[start:min(start+maxInstances, len(instanceIDs))]}},
+			MaxResults: aws.Int32(maxInstances),
+		}
+		for {
+			output, err := c.api.DescribeInstanceInformation(ctx, input)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe SSM instances: %w", err)
+			}
+			for _, information := range output.InstanceInformationList {
+				if information.PingStatus == ssmTypes.PingStatusOnline {
+					online = append(online, aws.ToString(information.InstanceId))
+				}
+			}
+			if output.NextToken == nil {
+				break
+			}
+			input.NextToken = output.NextToken
+		}
+	}
+	return online, nil
+}
+
+// RunShellScript runs script as root on the instances with AWS-RunShellScript
+// and waits for it to finish on all of them, or for ctx to end. Instances
+// still running the script are returned with their current status.
+func (c *Client) RunShellScript(ctx context.Context, instanceIDs []string, script, comment string, timeout time.Duration) ([]CommandResult, error) {
+	var commandIDs []string
+	for start := 0; start < len(instanceIDs); start += maxInstances {
+		output, err := c.api.SendCommand(ctx, &awsSSM.SendCommandInput{
+			DocumentName:   aws.String("AWS-RunShellScript"),
+			InstanceIds:    instanceIDs[start:min(start+maxInstances, len(instanceIDs))],
+			Parameters:     map[string][]string{"commands": {script}},
+			TimeoutSeconds: aws.Int32(int32(max(timeout, 30*time.Second).Seconds())),
+			Comment:        aws.String(comment),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to send SSM command: %w", err)
+		}
+		commandIDs = append(commandIDs, aws.ToString(output.Command.CommandId))
+	}
+
+	var results []CommandResult
+	for {
+		var polled []CommandResult
+		for _, commandID := range commandIDs {
+			invocations, err := c.invocations(ctx, commandID)
+			if ctx.Err() != nil {
+				return results, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			polled = append(polled, invocations...)
+		}
+		results = polled
+		finished := len(results) == len(instanceIDs) && !slices.ContainsFunc(results, func(result CommandResult) bool { return !result.Done() })
+		if finished {
+			return results, nil
+		}
+		select {
+		case <-ctx.Done():
+			return results, nil
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
+
+// FullOutput returns a finished command's standard output and error on one
+// instance, which SSM keeps up to 24000 characters each rather than the 2500
+// the status listing returns
+func (c *Client) FullOutput(ctx context.Context, result CommandResult) (string, error) {
+	output, err := c.api.GetCommandInvocation(ctx, &awsSSM.GetCommandInvocationInput{
+		CommandId:  aws.String(result.CommandID),
+		InstanceId: aws.String(result.InstanceID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get SSM command output: %w", err)
+	}
+	return aws.ToString(output.StandardOutputContent) + aws.ToString(output.StandardErrorContent), nil
+}
+
+// invocations returns a command's status on each instance
+func (c *Client) invocations(ctx context.Context, commandID string) ([]CommandResult, error) {
+	input := &awsSSM.ListCommandInvocationsInput{CommandId: aws.String(commandID), Details: true}
+	var results []CommandResult
+	for {
+		output, err := c.api.ListCommandInvocations(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list SSM command invocations: %w", err)
+		}
+		for _, invocation := range output.CommandInvocations {
+			result := CommandResult{CommandID: commandID, InstanceID: aws.ToString(invocation.InstanceId), Status: string(invocation.Status)}
+			for _, plugin := range invocation.CommandPlugins {
+				result.Output += aws.ToString(plugin.Output)
+			}
+			results = append(results, result)
+		}
+		if output.NextToken == nil {
+			return results, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
@@ -0,0 +1,114 @@
+package ssm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsSSM "github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeSSM runs commands that finish on the second poll
+type fakeSSM struct {
+	online   map[string]bool
+	commands map[string][]string // Instance IDs by command ID
+	polls    int
+	down     bool // Instance lookups fail as if SSM were unreachable
+}
+
+func (f *fakeSSM) DescribeInstanceInformation(ctx context.Context, params *awsSSM.DescribeInstanceInformationInput, optFns ...func(*awsSSM.Options)) (*awsSSM.DescribeInstanceInformationOutput, error) {
+	if f.down {
+		return nil, errors.New("connection refused")
+	}
+	output := &awsSSM.DescribeInstanceInformationOutput{}
+	for _, id := range params.Filters[0].Values {
+		if f.online[id] {
+			output.InstanceInformationList = append(output.InstanceInformationList, ssmTypes.InstanceInformation{InstanceId: aws.String(id), PingStatus: ssmTypes.PingStatusOnline})
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeSSM) SendCommand(ctx context.Context, params *awsSSM.SendCommandInput, optFns ...func(*awsSSM.Options)) (*awsSSM.SendCommandOutput, error) {
+	for _, id := range params.InstanceIds {
+		if !f.online[id] {
+			return nil, &ssmTypes.InvalidInstanceId{Message: aws.String("Instances not in a valid state")}
+		}
+	}
+	commandID := "cmd-" + string(rune('a'+len(f.commands)))
+	f.commands[commandID] = params.InstanceIds
+	return &awsSSM.SendCommandOutput{Command: &ssmTypes.Command{CommandId: aws.String(commandID)}}, nil
+}
+
+func (f *fakeSSM) ListCommandInvocations(ctx context.Context, params *awsSSM.ListCommandInvocationsInput, optFns ...func(*awsSSM.Options)) (*awsSSM.ListCommandInvocationsOutput, error) {
+	f.polls++
+	output := &awsSSM.ListCommandInvocationsOutput{}
+	for _, id := range f.commands[aws.ToString(params.CommandId)] {
+		invocation := ssmTypes.CommandInvocation{InstanceId: aws.String(id), Status: ssmTypes.CommandInvocationStatusInProgress}
+		if f.polls > 1 {
+			invocation.Status = ssmTypes.CommandInvocationStatusSuccess
+			invocation.CommandPlugins = []ssmTypes.CommandPlugin{{Output: aws.String("ok " + id + "\n")}}
+			if id == "i-bad" {
+				invocation.Status = ssmTypes.CommandInvocationStatusFailed
+				invocation.CommandPlugins = []ssmTypes.CommandPlugin{{Output: aws.String("FAIL efa: no EFA device\n")}}
+			}
+		}
+		output.CommandInvocations = append(output.CommandInvocations, invocation)
+	}
+	return output, nil
+}
+
+func (f *fakeSSM) GetCommandInvocation(ctx context.Context, params *awsSSM.GetCommandInvocationInput, optFns ...func(*awsSSM.Options)) (*awsSSM.GetCommandInvocationOutput, error) {
+	return &awsSSM.GetCommandInvocationOutput{
+		StandardOutputContent: aws.String("full " + aws.ToString(params.InstanceId) + "\n"),
+		StandardErrorContent:  aws.String("warning\n"),
+	}, nil
+}
+
+// newTestClient returns a client of the fake SSM service that polls quickly
+func newTestClient(api API) *Client {
+	client := newClient(zap.NewNop(), api)
+	client.pollInterval = time.Millisecond
+	return client
+}
+
+func TestClient(t *testing.T) {
+	ssm := &fakeSSM{online: map[string]bool{"i-good": true, "i-bad": true}, commands: map[string][]string{}}
+	commander := newTestClient(ssm)
+
+	online, err := commander.Online(context.Background(), []string{"i-good", "i-booting", "i-bad"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i-good", "i-bad"}, online)
+
+	results, err := commander.RunShellScript(context.Background(), online, "true", "test", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, []CommandResult{
+		{CommandID: "cmd-a", InstanceID: "i-good", Status: "Success", Output: "ok i-good\n"},
+		{CommandID: "cmd-a", InstanceID: "i-bad", Status: "Failed", Output: "FAIL efa: no EFA device\n"},
+	}, results)
+	assert.Equal(t, 2, ssm.polls)
+
+	output, err := commander.FullOutput(context.Background(), results[0])
+	require.NoError(t, err)
+	assert.Equal(t, "full i-good\nwarning\n", output)
+
+	_, err = commander.RunShellScript(context.Background(), []string{"i-booting"}, "true", "test", time.Minute)
+	var invalid *ssmTypes.InvalidInstanceId
+	assert.ErrorAs(t, err, &invalid)
+	assert.ErrorContains(t, err, "failed to send SSM command")
+
+	// Commands still running when the context ends are returned as they are
+	ssm.polls = -100
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	results, err = commander.RunShellScript(ctx, []string{"i-good"}, "true", "test", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.False(t, results[0].Done())
+}
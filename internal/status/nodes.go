@@ -0,0 +1,85 @@
+package status
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// NodeRow is one node in the operator status view
+type NodeRow struct {
+	Node             string   `json:"node"`
+	State            string   `json:"state"`
+	InstanceID       string   `json:"instance_id,omitempty"`
+	InstanceType     string   `json:"instance_type,omitempty"`
+	InstanceState    string   `json:"instance_state,omitempty"`
+	AvailabilityZone string   `json:"availability_zone,omitempty"`
+	UptimeSeconds    int64    `json:"uptime_seconds,omitempty"`
+	EstimatedCostUSD float64  `json:"estimated_cost_usd,omitempty"` // Since the instance launched
+	Jobs             []string `json:"jobs,omitempty"`
+}
+
+// ConfiguredNodes expands every configured node group into its Slurm node names
+func ConfiguredNodes(cfg *config.Config, slurmClient *slurm.Client) ([]string, error) {
+	var nodeNames []string
+	for _, partition := range cfg.Slurm.Partitions {
+		for _, nodeGroup := range partition.NodeGroups {
+			nodeRange := cfg.GetNodeRange(partition.PartitionName, nodeGroup.NodeGroupName, nodeGroup.MaxNodes)
+			names, err := slurmClient.ParseNodeList(nodeRange)
+			if err != nil {
+				return nodeNames, fmt.Errorf("failed to parse node list %s: %w", nodeRange, err)
+			}
+			nodeNames = append(nodeNames, names...)
+		}
+	}
+	return nodeNames, nil
+}
+
+// BuildNodeRows joins Slurm node states, running jobs and EC2 instances. Without
+// all, powered-down nodes with no instance or job are left out.
+func BuildNodeRows(nodes []slurm.NodeInfo, instances []types.InstanceInfo, jobs map[string][]string, pricing map[string]float64, now time.Time, all bool) []NodeRow {
+	byNode := make(map[string]types.InstanceInfo)
+	for _, instance := range instances {
+		// Prefer the live instance over a stopped one left by a previous suspend
+		if existing, ok := byNode[instance.NodeName]; ok && existing.State == "running" {
+			continue
+		}
+		byNode[instance.NodeName] = instance
+	}
+
+	rows := []NodeRow{}
+	for _, node := range nodes {
+		row := NodeRow{Node: node.NodeName, State: node.State, Jobs: jobs[node.NodeName]}
+
+		instance, ok := byNode[node.NodeName]
+		if ok {
+			row.InstanceID = instance.InstanceID
+			row.InstanceType = instance.InstanceType
+			row.InstanceState = instance.State
+			row.AvailabilityZone = instance.AvailabilityZone
+			if len(row.Jobs) == 0 && instance.JobID != "" {
+				row.Jobs = []string{instance.JobID}
+			}
+
+			launched, err := time.Parse(time.RFC3339, instance.LaunchTime)
+			if err == nil && instance.State == "running" && now.After(launched) {
+				uptime := now.Sub(launched)
+				row.UptimeSeconds = int64(uptime.Seconds())
+				row.EstimatedCostUSD = pricing[instance.InstanceType] * uptime.Hours()
+			}
+		}
+
+		if !all && !ok && len(row.Jobs) == 0 && strings.Contains(node.State, "POWERED_DOWN") {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Node < rows[j].Node })
+	return rows
+}
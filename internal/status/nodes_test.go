@@ -0,0 +1,42 @@
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildNodeRows(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	nodes := []slurm.NodeInfo{
+		{NodeName: "aws-cpu-0", State: "ALLOCATED+CLOUD"},
+		{NodeName: "aws-cpu-1", State: "IDLE+CLOUD+POWERED_DOWN"},
+		{NodeName: "aws-cpu-2", State: "IDLE+CLOUD+POWERED_DOWN"},
+	}
+	instances := []types.InstanceInfo{
+		{NodeName: "aws-cpu-0", InstanceID: "i-old", InstanceType: "c6i.xlarge", State: "stopped", LaunchTime: now.Add(-24 * time.Hour).Format(time.RFC3339)},
+		{NodeName: "aws-cpu-0", InstanceID: "i-0", InstanceType: "c6i.xlarge", State: "running", JobID: "99", AvailabilityZone: "us-east-1a", LaunchTime: now.Add(-90 * time.Minute).Format(time.RFC3339)},
+		{NodeName: "aws-cpu-1", InstanceID: "i-1", InstanceType: "c6i.xlarge", State: "stopped", LaunchTime: now.Add(-time.Hour).Format(time.RFC3339)},
+	}
+	jobs := map[string][]string{"aws-cpu-0": {"101"}}
+	pricing := map[string]float64{"c6i.xlarge": 0.2}
+
+	rows := BuildNodeRows(nodes, instances, jobs, pricing, now, false)
+	require.Len(t, rows, 2, "powered-down node without an instance is hidden")
+
+	assert.Equal(t, "i-0", rows[0].InstanceID)
+	assert.Equal(t, int64(5400), rows[0].UptimeSeconds)
+	assert.InDelta(t, 0.3, rows[0].EstimatedCostUSD, 1e-9)
+	assert.Equal(t, []string{"101"}, rows[0].Jobs)
+
+	assert.Equal(t, "stopped", rows[1].InstanceState)
+	assert.Zero(t, rows[1].EstimatedCostUSD)
+
+	rows = BuildNodeRows(nodes, instances, nil, pricing, now, true)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"99"}, rows[0].Jobs, "falls back to the instance's launching job")
+}
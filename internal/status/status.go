@@ -0,0 +1,310 @@
+// Package status builds the burst status document portals such as Open OnDemand
+// display, so researchers can see burst state without shell access.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/asba"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ecosystem"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"go.uber.org/zap"
+)
+
+// maxFailures caps the failures listed, newest first
+const maxFailures = 20
+
+// Document is the burst status snapshot
+type Document struct {
+	GeneratedAt     time.Time                  `json:"generated_at"`
+	BurstNodes      []BurstNode                `json:"burst_nodes"`
+	PendingLaunches []string                   `json:"pending_launches"` // Nodes Slurm is powering up
+	PendingJobs     int                        `json:"pending_jobs"`
+	Costs           Costs                      `json:"costs"`
+	RecentFailures  []Failure                  `json:"recent_failures"`
+	Ecosystem       *ecosystem.EcosystemStatus `json:"ecosystem,omitempty"`         // Cached companion tool detection
+	ASBA            *asba.Availability         `json:"asba_availability,omitempty"` // ASBA call counts and circuit breaker
+	Errors          []string                   `json:"errors,omitempty"`            // Sections that could not be collected
+}
+
+// BurstNode is a node running on an EC2 instance
+type BurstNode struct {
+	Node             string `json:"node"`
+	State            string `json:"state"`
+	Partition        string `json:"partition"`
+	InstanceID       string `json:"instance_id"`
+	InstanceType     string `json:"instance_type"`
+	AvailabilityZone string `json:"availability_zone,omitempty"`
+	PurchaseOption   string `json:"purchase_option,omitempty"`
+	LaunchTime       string `json:"launch_time"`
+}
+
+// Costs summarizes burst spend
+type Costs struct {
+	Month          string             `json:"month"` // YYYY-MM
+	RunRateUSD     float64            `json:"run_rate_usd_per_hour"`
+	MonthToDateUSD float64            `json:"month_to_date_usd"`
+	ByAccount      map[string]float64 `json:"month_to_date_usd_by_account"`
+}
+
+// Failure is a burst node or job failure
+type Failure struct {
+	Time    string `json:"time,omitempty"`
+	Node    string `json:"node,omitempty"`
+	JobID   string `json:"job_id,omitempty"`
+	Account string `json:"account,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// Collector gathers the status document from Slurm, Slurm accounting and EC2
+type Collector struct {
+	logger *zap.Logger
+	config *config.Config
+	slurm  *slurm.Client
+	aws    *aws.Client
+}
+
+// NewCollector creates a status collector
+func NewCollector(logger *zap.Logger, cfg *config.Config, slurmClient *slurm.Client, awsClient *aws.Client) *Collector {
+	return &Collector{
+		logger: logger,
+		config: cfg,
+		slurm:  slurmClient,
+		aws:    awsClient,
+	}
+}
+
+// Collect builds the status document. A section that cannot be collected is left
+// empty and reported in Errors rather than failing the whole document.
+func (c *Collector) Collect(ctx context.Context, now time.Time) *Document {
+	doc := &Document{
+		GeneratedAt: now.UTC(),
+		Costs:       Costs{Month: now.Format("2006-01"), ByAccount: make(map[string]float64)},
+	}
+	fail := func(section string, err error) {
+		c.logger.Warn("Failed to collect status section", zap.String("section", section), zap.Error(err))
+		doc.Errors = append(doc.Errors, fmt.Sprintf("%s: %v", section, err))
+	}
+
+	var partitions []string
+	for _, partition := range c.config.Slurm.Partitions {
+		partitions = append(partitions, partition.PartitionName)
+	}
+
+	nodeNames, err := ConfiguredNodes(c.config, c.slurm)
+	if err != nil {
+		fail("nodes", err)
+	}
+
+	nodes, err := c.slurm.GetNodeState(nodeNames)
+	if err != nil {
+		fail("nodes", err)
+	}
+	doc.PendingLaunches = pendingLaunches(nodes)
+
+	for _, partition := range partitions {
+		pending, err := c.slurm.PendingJobs(ctx, partition)
+		if err != nil {
+			fail("pending_jobs", err)
+			break
+		}
+		doc.PendingJobs += len(pending)
+	}
+
+	instances, err := c.aws.ListInstances(ctx)
+	if err != nil {
+		fail("burst_nodes", err)
+	}
+	doc.BurstNodes = burstNodes(nodes, instances)
+
+	if summary, err := c.aws.SummarizeCosts(ctx); err != nil {
+		fail("costs", err)
+	} else {
+		doc.Costs.RunRateUSD = summary.HourlyUSD
+	}
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	records, err := c.slurm.BurstJobs(ctx, partitions, monthStart)
+	if err != nil {
+		fail("costs", err)
+	} else {
+		pricing, err := c.aws.InstancePricing(ctx, recordInstanceTypes(records))
+		if err != nil {
+			fail("costs", err)
+		}
+		doc.Costs.ByAccount = accountCosts(records, pricing)
+		for _, cost := range doc.Costs.ByAccount {
+			doc.Costs.MonthToDateUSD += cost
+		}
+	}
+
+	window := time.Duration(c.config.Status.FailureWindowHours) * time.Hour
+	doc.RecentFailures = recentFailures(nodes, records, now.Add(-window))
+
+	// Reading the cache keeps the companion tools' probe timeouts out of the cycle
+	if c.config.Ecosystem.DataExchangeDir != "" {
+		if doc.Ecosystem, err = ecosystem.LoadCache(c.config.Ecosystem.DataExchangeDir); err != nil {
+			fail("ecosystem", err)
+		}
+		if doc.ASBA, err = asba.LoadAvailability(c.config.Ecosystem.DataExchangeDir); err != nil {
+			fail("asba_availability", err)
+		}
+	}
+
+	return doc
+}
+
+// WriteFile writes the document atomically, readable by the portal's web user
+func WriteFile(doc *Document, path string) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".status-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// burstNodes lists the Slurm nodes with a pending or running instance
+func burstNodes(nodes []slurm.NodeInfo, instances []types.InstanceInfo) []BurstNode {
+	states := make(map[string]string)
+	for _, node := range nodes {
+		states[node.NodeName] = node.State
+	}
+
+	burst := []BurstNode{}
+	for _, instance := range instances {
+		if instance.NodeName == "" || (instance.State != "running" && instance.State != "pending") {
+			continue
+		}
+		// Warm pool instances are not Slurm nodes yet
+		if len(states) > 0 && states[instance.NodeName] == "" {
+			continue
+		}
+		burst = append(burst, BurstNode{
+			Node:             instance.NodeName,
+			State:            states[instance.NodeName],
+			Partition:        instance.Partition,
+			InstanceID:       instance.InstanceID,
+			InstanceType:     instance.InstanceType,
+			AvailabilityZone: instance.AvailabilityZone,
+			PurchaseOption:   instance.PurchaseOption,
+			LaunchTime:       instance.LaunchTime,
+		})
+	}
+
+	sort.Slice(burst, func(i, j int) bool { return burst[i].Node < burst[j].Node })
+	return burst
+}
+
+// pendingLaunches lists the nodes Slurm is powering up
+func pendingLaunches(nodes []slurm.NodeInfo) []string {
+	pending := []string{}
+	for _, node := range nodes {
+		if strings.Contains(node.State, "POWERING_UP") {
+			pending = append(pending, node.NodeName)
+		}
+	}
+	return pending
+}
+
+// accountCosts totals each account's job costs: the cost resume recorded on the
+// job when present, otherwise the instance price times nodes and run time
+func accountCosts(records []slurm.AccountingRecord, pricing map[string]float64) map[string]float64 {
+	costs := make(map[string]float64)
+	for _, record := range records {
+		meta, err := types.ParseAWSJobMetadata(record.Metadata)
+		if err != nil {
+			continue // Never launched on AWS
+		}
+
+		cost := meta.Cost
+		if cost == 0 && len(meta.Instances) > 0 {
+			cost = pricing[meta.Instances[0]] * float64(record.Nodes) * record.Elapsed.Hours()
+		}
+		costs[record.Account] += cost
+	}
+	return costs
+}
+
+// recordInstanceTypes lists the instance types recorded on jobs
+func recordInstanceTypes(records []slurm.AccountingRecord) []string {
+	seen := make(map[string]bool)
+	var instanceTypes []string
+	for _, record := range records {
+		meta, err := types.ParseAWSJobMetadata(record.Metadata)
+		if err != nil {
+			continue
+		}
+		for _, instanceType := range meta.Instances {
+			if !seen[instanceType] {
+				seen[instanceType] = true
+				instanceTypes = append(instanceTypes, instanceType)
+			}
+		}
+	}
+	return instanceTypes
+}
+
+// recentFailures lists down or failed nodes and jobs lost to node or boot failures
+// since a time, newest first
+func recentFailures(nodes []slurm.NodeInfo, records []slurm.AccountingRecord, since time.Time) []Failure {
+	failures := []Failure{}
+	for _, node := range nodes {
+		if strings.Contains(node.State, "DOWN") || strings.Contains(node.State, "FAIL") {
+			failures = append(failures, Failure{Node: node.NodeName, Reason: nodeReason(node)})
+		}
+	}
+
+	var jobs []Failure
+	for _, record := range records {
+		if (record.State == "NODE_FAIL" || record.State == "BOOT_FAIL") && record.End.After(since) {
+			jobs = append(jobs, Failure{
+				Time:    record.End.UTC().Format(time.RFC3339),
+				JobID:   record.JobID,
+				Account: record.Account,
+				Reason:  record.State,
+			})
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Time > jobs[j].Time })
+
+	failures = append(failures, jobs...)
+	if len(failures) > maxFailures {
+		failures = failures[:maxFailures]
+	}
+	return failures
+}
+
+// nodeReason describes why a node is down
+func nodeReason(node slurm.NodeInfo) string {
+	if node.Reason != "" {
+		return node.Reason
+	}
+	return node.State
+}
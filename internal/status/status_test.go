@@ -0,0 +1,80 @@
+package status
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/slurm"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountCosts(t *testing.T) {
+	records := []slurm.AccountingRecord{
+		{Account: "chem", Nodes: 4, Elapsed: 2 * time.Hour, Metadata: `aws_meta:{"instances":["c6i.xlarge"],"cost":3.5}`},
+		{Account: "chem", Nodes: 2, Elapsed: 3 * time.Hour, Metadata: `aws_meta:{"instances":["c6i.xlarge"]}`},
+		{Account: "bio", Nodes: 1, Elapsed: time.Hour, Metadata: `aws_meta:{"instances":["c6i.2xlarge"]}`},
+		{Account: "physics", Nodes: 8, Elapsed: time.Hour}, // Ran on-premises
+	}
+	pricing := map[string]float64{"c6i.xlarge": 0.2, "c6i.2xlarge": 0.4}
+
+	assert.Equal(t, []string{"c6i.xlarge", "c6i.2xlarge"}, recordInstanceTypes(records))
+
+	costs := accountCosts(records, pricing)
+	assert.InDelta(t, 3.5+1.2, costs["chem"], 1e-9)
+	assert.InDelta(t, 0.4, costs["bio"], 1e-9)
+	assert.NotContains(t, costs, "physics")
+}
+
+func TestRecentFailures(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	nodes := []slurm.NodeInfo{
+		{NodeName: "aws-cpu-0", State: "DOWN+CLOUD", Reason: "InsufficientInstanceCapacity"},
+		{NodeName: "aws-cpu-1", State: "IDLE+CLOUD"},
+	}
+	records := []slurm.AccountingRecord{
+		{JobID: "101", Account: "chem", State: "NODE_FAIL", End: now.Add(-2 * time.Hour)},
+		{JobID: "102", Account: "chem", State: "BOOT_FAIL", End: now.Add(-time.Hour)},
+		{JobID: "103", Account: "bio", State: "NODE_FAIL", End: now.Add(-48 * time.Hour)},
+		{JobID: "104", Account: "bio", State: "FAILED", End: now.Add(-time.Hour)},
+	}
+
+	failures := recentFailures(nodes, records, now.Add(-24*time.Hour))
+	require.Len(t, failures, 3)
+	assert.Equal(t, Failure{Node: "aws-cpu-0", Reason: "InsufficientInstanceCapacity"}, failures[0])
+	assert.Equal(t, "102", failures[1].JobID)
+	assert.Equal(t, "101", failures[2].JobID)
+}
+
+func TestBurstNodes(t *testing.T) {
+	nodes := []slurm.NodeInfo{{NodeName: "aws-cpu-1", State: "ALLOCATED+CLOUD"}, {NodeName: "aws-cpu-2", State: "IDLE+CLOUD+POWERING_UP"}}
+	instances := []types.InstanceInfo{
+		{NodeName: "aws-cpu-1", InstanceID: "i-1", State: "running", Partition: "aws"},
+		{NodeName: "aws-cpu-0", InstanceID: "i-0", State: "stopped", Partition: "aws"},
+		{NodeName: "aws-cpu-warm", InstanceID: "i-warm", State: "running"},
+	}
+
+	burst := burstNodes(nodes, instances)
+	require.Len(t, burst, 1)
+	assert.Equal(t, "ALLOCATED+CLOUD", burst[0].State)
+	assert.Equal(t, []string{"aws-cpu-2"}, pendingLaunches(nodes))
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "burst-status.json")
+	require.NoError(t, WriteFile(&Document{PendingJobs: 2, Costs: Costs{Month: "2026-03"}}, path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var doc Document
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, 2, doc.PendingJobs)
+}
@@ -0,0 +1,326 @@
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// CheckpointFilename sorts after the node metrics collector; nothing depends on it
+const CheckpointFilename = "03-aws-slurm-burst-checkpoint.sh"
+
+// CheckpointProgramPath is where user data installs the checkpointer on each
+// node. Job scripts run DMTCP steps through it and ask it for their
+// checkpoint directory.
+const CheckpointProgramPath = "/usr/local/bin/aws-slurm-burst-checkpoint"
+
+// Checkpoint configures the spot interruption checkpointer of a launch
+type Checkpoint struct {
+	Method       string   // Default method: types.CheckpointDMTCP, CheckpointSignal or CheckpointCommand
+	Signal       string   // Sent by the signal method, e.g. "USR1"
+	Command      string   // Shell run by the command method
+	Directory    string   // Shared directory holding a checkpoint directory per job
+	GraceSeconds int      // Time a checkpoint may take before the job is requeued anyway
+	PollSeconds  int      // How often the node checks for an interruption warning
+	Partitions   []string // Partitions whose jobs are all checkpointable
+
+	// The execution plan's job, checkpointable as its plan says
+	PlanJobID string
+	Plan      *types.CheckpointSpec
+}
+
+// jobIDPattern matches a Slurm job ID, or an array task's as 1234_5
+var jobIDPattern = regexp.MustCompile(`^[0-9]+(_[0-9]+)?$`)
+
+// checkpointData feeds the checkpointer and its install script
+type checkpointData struct {
+	Checkpoint
+	PartitionList string
+	PlanMethod    string
+	PlanSignal    string
+	ProgramPath   string
+}
+
+// checkpointProgramTemplate watches for the spot interruption warning and
+// checkpoints and requeues the node's checkpointable jobs when it comes
+var checkpointProgramTemplate = template.Must(template.New("checkpoint-program").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: spot interruption checkpointer
+#   watch           poll for the spot interruption warning every {{.PollSeconds}}s (systemd service)
+#   interrupted     checkpoint and requeue this node's checkpointable jobs now
+#   run COMMAND...  run COMMAND under DMTCP, restoring it from the job's checkpoint if it has one
+#   dir [JOBID]     print the job's checkpoint directory
+#   prolog JOBID    prepare the job's checkpoint directory (node prolog)
+#   epilog JOBID    remove the checkpoint of a job that finished (node epilog)
+set -uo pipefail
+
+METHOD={{.Method}}
+SIGNAL={{.Signal}}
+DIRECTORY="{{.Directory}}"
+GRACE={{.GraceSeconds}}
+POLL={{.PollSeconds}}
+PARTITIONS="{{.PartitionList}}"
+PLAN_JOB="{{.PlanJobID}}"
+PLAN_METHOD="{{.PlanMethod}}"
+PLAN_SIGNAL="{{.PlanSignal}}"
+NODE=${SLURMD_NODENAME:-$(hostname -s)}
+
+site_checkpoint() {
+{{- if .Command}}
+{{.Command}}
+{{- else}}
+  echo "No checkpoint command is configured" >&2
+  return 1
+{{- end}}
+}
+
+imds() {
+  local token
+  token=$(curl -sf -m 2 -X PUT -H "X-aws-ec2-metadata-token-ttl-seconds: 60" http://169.254.169.254/latest/api/token) &&
+    curl -sf -m 2 -H "X-aws-ec2-metadata-token: $token" "http://169.254.169.254/latest/meta-data/$1"
+}
+
+job_dir() {
+  echo "$DIRECTORY/job-$1"
+}
+
+# method_for JOBID ARRAYID PARTITION COMMENT prints how the job is
+# checkpointed and with which signal, or nothing when it is not checkpointable
+method_for() {
+  local tag
+  tag=$(grep -o 'asb:checkpoint\(=[a-z]*\)\?' <<< "$4" | head -n 1)
+  if [ -n "$tag" ]; then
+    [ "$tag" = asb:checkpoint ] && echo "$METHOD $SIGNAL" || echo "${tag#asb:checkpoint=} $SIGNAL"
+  elif [ -n "$PLAN_JOB" ] && { [ "$1" = "$PLAN_JOB" ] || [ "$2" = "$PLAN_JOB" ]; }; then
+    echo "${PLAN_METHOD:-$METHOD} ${PLAN_SIGNAL:-$SIGNAL}"
+  elif [[ " $PARTITIONS " == *" $3 "* ]]; then
+    echo "$METHOD $SIGNAL"
+  fi
+}
+
+# checkpoint JOBID METHOD SIGNAL checkpoints the job within the grace period
+checkpoint() {
+  local job=$1 method=$2 signal=$3 dir port deadline
+  dir=$(job_dir "$job")
+  case "$method" in
+    dmtcp)
+      port=$(cat "$dir/coordinator-$NODE.port" 2>/dev/null) || { echo "Job $job has no DMTCP coordinator on $NODE"; return 1; }
+      timeout "$GRACE" dmtcp_command --coord-port "$port" --bcheckpoint ;;
+    signal)
+      scancel --signal="$signal" --full "$job" || return 1
+      # The application has the grace period, unless the job ends first
+      deadline=$((SECONDS + GRACE))
+      while [ $SECONDS -lt $deadline ] && [ "$(squeue -h -j "$job" -o %T 2>/dev/null)" = RUNNING ]; do
+        sleep 1
+      done ;;
+    command)
+      SLURM_JOB_ID=$job ASB_CHECKPOINT_DIR=$dir timeout "$GRACE" bash -c "$(declare -f site_checkpoint); site_checkpoint" ;;
+    *)
+      echo "Unknown checkpoint method $method for job $job"
+      return 1 ;;
+  esac
+}
+
+# handle JOBID METHOD SIGNAL checkpoints the job and requeues it; a failed
+# checkpoint still requeues, so the job restarts from its last one
+handle() {
+  local job=$1 started=$SECONDS
+  if checkpoint "$@"; then
+    echo "Checkpointed job $job ($2) in $((SECONDS - started))s"
+  else
+    echo "Checkpoint of job $job ($2) failed; requeueing it from its last checkpoint"
+  fi
+  mkdir -p "$(job_dir "$job")" && touch "$(job_dir "$job")/requeued"
+  scontrol requeue "$job" && echo "Requeued job $job"
+}
+
+interrupted() {
+  local job array_id partition comment spec
+  echo "$(date -u +%Y-%m-%dT%H:%M:%SZ) Spot interruption warning on $NODE: $(imds spot/instance-action)"
+  while IFS='|' read -r job array_id partition comment; do
+    spec=$(method_for "$job" "$array_id" "$partition" "$comment")
+    [ -n "$spec" ] || continue
+    # shellcheck disable=SC2086
+    handle "$job" $spec < /dev/null &
+  done < <(squeue -h -w "$NODE" -t RUNNING -o '%A|%i|%P|%k')
+  wait
+}
+
+watch() {
+  if [ "$(imds instance-life-cycle)" = on-demand ]; then
+    echo "On-demand instance; no spot interruptions to watch for"
+    return 0
+  fi
+  until imds spot/instance-action >/dev/null; do
+    sleep "$POLL"
+  done
+  interrupted
+  # The instance is about to be reclaimed; the warning is handled once
+  sleep infinity
+}
+
+run() {
+  local dir port_file images
+  if [ -z "${SLURM_JOB_ID:-}" ] || [ $# -eq 0 ]; then
+    echo "usage: $0 run COMMAND... (inside a Slurm job)" >&2
+    exit 2
+  fi
+  if ! command -v dmtcp_launch >/dev/null 2>&1; then
+    echo "DMTCP is not installed on $NODE; running without checkpoints" >&2
+    exec "$@"
+  fi
+  dir=$(job_dir "$SLURM_JOB_ID")
+  port_file="$dir/coordinator-$NODE.port"
+  mkdir -p "$dir" && rm -f "$port_file"
+  dmtcp_coordinator --daemon --exit-on-last --coord-port 0 --port-file "$port_file" --ckptdir "$dir" >/dev/null || exit 1
+  for _ in $(seq 50); do
+    [ -s "$port_file" ] && break
+    sleep 0.1
+  done
+  images=("$dir"/ckpt_*.dmtcp)
+  if [ -e "${images[0]}" ]; then
+    exec dmtcp_restart --coord-port "$(cat "$port_file")" --ckptdir "$dir" "${images[@]}"
+  fi
+  exec dmtcp_launch --coord-port "$(cat "$port_file")" --ckptdir "$dir" "$@"
+}
+
+# prolog JOBID gives a checkpointable job a directory it owns
+prolog() {
+  local dir job array_id partition comment
+  dir=$(job_dir "$1")
+  rm -f "$dir/requeued"
+  IFS='|' read -r job array_id partition comment < <(squeue -h -j "$1" -o '%A|%i|%P|%k' 2>/dev/null)
+  [ -n "$(method_for "$1" "${array_id:-}" "${partition:-}" "${comment:-}")" ] || return 0
+  install -d -m 700 -o "${SLURM_JOB_UID:-$(id -u)}" -g "${SLURM_JOB_GID:-$(id -g)}" "$dir"
+}
+
+# epilog JOBID removes the checkpoint of a job that finished; a requeued job
+# restores from it
+epilog() {
+  local dir
+  dir=$(job_dir "$1")
+  [ -e "$dir/requeued" ] || rm -rf "$dir"
+}
+
+case "${1:-}" in
+  watch) watch ;;
+  interrupted) interrupted ;;
+  run) shift; run "$@" ;;
+  dir) job_dir "${2:-${SLURM_JOB_ID:?}}" ;;
+  prolog) prolog "$2" ;;
+  epilog) epilog "$2" ;;
+  *) echo "usage: $0 watch|interrupted|run COMMAND...|dir [JOBID]|prolog JOBID|epilog JOBID" >&2; exit 2 ;;
+esac
+`))
+
+// checkpointSetupTemplate installs the checkpointer with a systemd watcher and
+// node prolog/epilog hooks that manage each job's checkpoint directory
+var checkpointSetupTemplate = template.Must(template.Must(checkpointProgramTemplate.Clone()).New("checkpoint-setup").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: spot interruption checkpointer setup
+set -uo pipefail
+exec >> /var/log/aws-slurm-burst-checkpoint.log 2>&1
+
+cat > {{.ProgramPath}} <<'PROGRAM_EOF'
+{{template "checkpoint-program" .}}PROGRAM_EOF
+chmod 755 {{.ProgramPath}}
+
+cat > /etc/systemd/system/aws-slurm-burst-checkpoint.service <<'UNIT_EOF'
+[Unit]
+Description=aws-slurm-burst spot interruption checkpointer
+After=network-online.target slurmd.service
+
+[Service]
+ExecStart={{.ProgramPath}} watch
+StandardOutput=append:/var/log/aws-slurm-burst-checkpoint.log
+StandardError=append:/var/log/aws-slurm-burst-checkpoint.log
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+UNIT_EOF
+systemctl daemon-reload
+systemctl enable --now aws-slurm-burst-checkpoint.service
+
+# Hooks never fail the job
+mkdir -p /etc/slurm/prolog.d /etc/slurm/epilog.d
+cat > /etc/slurm/prolog.d/50-aws-slurm-burst-checkpoint.sh <<'HOOK_EOF'
+#!/bin/bash
+timeout 30 {{.ProgramPath}} prolog "$SLURM_JOB_ID" >> /var/log/aws-slurm-burst-checkpoint.log 2>&1
+exit 0
+HOOK_EOF
+cat > /etc/slurm/epilog.d/50-aws-slurm-burst-checkpoint.sh <<'HOOK_EOF'
+#!/bin/bash
+timeout 60 {{.ProgramPath}} epilog "$SLURM_JOB_ID" >> /var/log/aws-slurm-burst-checkpoint.log 2>&1
+exit 0
+HOOK_EOF
+chmod 755 /etc/slurm/prolog.d/50-aws-slurm-burst-checkpoint.sh /etc/slurm/epilog.d/50-aws-slurm-burst-checkpoint.sh
+echo "Spot interruption checkpointer installed"
+`))
+
+// CheckpointProgram renders the checkpointer that user data installs at
+// CheckpointProgramPath
+func CheckpointProgram(checkpoint Checkpoint) (string, error) {
+	return renderCheckpoint(checkpointProgramTemplate, checkpoint)
+}
+
+// CheckpointScript generates a user-data script that installs the
+// checkpointer, its interruption watcher service and the node prolog/epilog
+// hooks
+func CheckpointScript(checkpoint Checkpoint) (string, error) {
+	return renderCheckpoint(checkpointSetupTemplate, checkpoint)
+}
+
+func renderCheckpoint(tmpl *template.Template, checkpoint Checkpoint) (string, error) {
+	if !slices.Contains(types.CheckpointMethods, checkpoint.Method) {
+		return "", fmt.Errorf("invalid checkpoint method %q", checkpoint.Method)
+	}
+	if checkpoint.Signal == "" || !types.ValidSignalName(checkpoint.Signal) {
+		return "", fmt.Errorf("invalid checkpoint signal %q", checkpoint.Signal)
+	}
+	if !filepath.IsAbs(checkpoint.Directory) || strings.ContainsAny(checkpoint.Directory, "\"$`\\ \n") {
+		return "", fmt.Errorf("invalid checkpoint directory %q", checkpoint.Directory)
+	}
+	if checkpoint.GraceSeconds <= 0 || checkpoint.PollSeconds <= 0 {
+		return "", fmt.Errorf("checkpoint grace and poll intervals must be positive")
+	}
+	if strings.Contains(checkpoint.Command, "PROGRAM_EOF") {
+		return "", fmt.Errorf("checkpoint command cannot contain PROGRAM_EOF")
+	}
+	for _, partition := range checkpoint.Partitions {
+		if strings.ContainsAny(partition, "\"$`\\ \n") {
+			return "", fmt.Errorf("invalid partition name %q", partition)
+		}
+	}
+
+	data := checkpointData{
+		Checkpoint:    checkpoint,
+		PartitionList: strings.Join(checkpoint.Partitions, " "),
+		ProgramPath:   CheckpointProgramPath,
+	}
+	data.Directory = strings.TrimSuffix(checkpoint.Directory, "/")
+	if plan := checkpoint.Plan; plan != nil {
+		if !jobIDPattern.MatchString(checkpoint.PlanJobID) {
+			return "", fmt.Errorf("a checkpointable plan needs the job ID, got %q", checkpoint.PlanJobID)
+		}
+		if plan.Method != "" && !slices.Contains(types.CheckpointMethods, plan.Method) {
+			return "", fmt.Errorf("invalid checkpoint method %q", plan.Method)
+		}
+		if !types.ValidSignalName(plan.Signal) {
+			return "", fmt.Errorf("invalid checkpoint signal %q", plan.Signal)
+		}
+		data.PlanMethod, data.PlanSignal = plan.Method, plan.Signal
+	} else {
+		data.PlanJobID = ""
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render checkpointer: %w", err)
+	}
+	return buf.String(), nil
+}
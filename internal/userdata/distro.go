@@ -0,0 +1,205 @@
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Distro is a Linux distribution node bootstrap knows how to set up
+type Distro string
+
+const (
+	DistroAmazonLinux2023 Distro = "al2023"
+	DistroUbuntu2204      Distro = "ubuntu22.04"
+	DistroRocky9          Distro = "rocky9"
+)
+
+// Distros are the distributions node bootstrap supports
+var Distros = []Distro{DistroAmazonLinux2023, DistroUbuntu2204, DistroRocky9}
+
+// Node bootstrap parts bracket the other setup scripts: packages are installed
+// first so EFA can build its driver and the munge key has a munge user, and
+// slurmd starts last so jobs only land once the node is fully set up.
+const (
+	NodeBootstrapFilename = "00-aws-slurm-burst-bootstrap.sh"
+	SlurmdStartFilename   = "99-aws-slurm-burst-slurmd.sh"
+)
+
+// distroPatterns recognise a distribution from an AMI's name and description,
+// including Deep Learning and other AMIs built on it
+var distroPatterns = []struct {
+	pattern *regexp.Regexp
+	distro  Distro
+}{
+	{regexp.MustCompile(`al2023|amazon linux 2023`), DistroAmazonLinux2023},
+	{regexp.MustCompile(`ubuntu.*(22\.04|jammy)|(22\.04|jammy).*ubuntu`), DistroUbuntu2204},
+	{regexp.MustCompile(`rocky[- _]?(linux[- _]?)?9`), DistroRocky9},
+}
+
+// distroSetup is how a distribution installs slurmd, munge and EFA prerequisites
+type distroSetup struct {
+	Prepare       string // Enables repositories and refreshes package metadata
+	Install       string // Installs the packages that follow it
+	Packages      string // slurmd and munge; empty when the distro has none
+	CLIPackage    string // The AWS CLI, which fetches the munge key and node name
+	KernelHeaders string // Needed by the EFA installer to build its driver
+	SlurmdEnvFile string // Read by the packaged slurmd unit for SLURMD_OPTIONS
+}
+
+var distroSetups = map[Distro]distroSetup{
+	// Amazon Linux 2023 ships the AWS CLI but no Slurm or munge packages
+	DistroAmazonLinux2023: {
+		Install:       "dnf install -y",
+		CLIPackage:    "awscli-2",
+		KernelHeaders: `"kernel-devel-$(uname -r)"`,
+		SlurmdEnvFile: "/etc/sysconfig/slurmd",
+	},
+	DistroUbuntu2204: {
+		Prepare:       "export DEBIAN_FRONTEND=noninteractive && apt-get update",
+		Install:       "apt-get install -y",
+		Packages:      "munge slurmd",
+		CLIPackage:    "awscli",
+		KernelHeaders: `"linux-headers-$(uname -r)"`,
+		SlurmdEnvFile: "/etc/default/slurmd",
+	},
+	DistroRocky9: {
+		Prepare:       "dnf install -y epel-release && dnf config-manager --set-enabled crb",
+		Install:       "dnf install -y",
+		Packages:      "munge slurm-slurmd",
+		CLIPackage:    "awscli2",
+		KernelHeaders: `"kernel-devel-$(uname -r)"`,
+		SlurmdEnvFile: "/etc/sysconfig/slurmd",
+	},
+}
+
+// NodeBootstrap installs and starts slurmd on nodes whose AMI does not carry it
+type NodeBootstrap struct {
+	Distro         Distro
+	ConfServer     string // slurmctld host[:port] slurmd fetches its configuration from
+	InstallCommand string // Installs slurmd and munge instead of distro packages
+	EFA            bool   // Install what the EFA installer needs to build its driver
+}
+
+// DetectDistro identifies the distribution of an AMI from its name and
+// description
+func DetectDistro(name, description string) (Distro, error) {
+	text := strings.ToLower(name + " " + description)
+	for _, candidate := range distroPatterns {
+		if candidate.pattern.MatchString(text) {
+			return candidate.distro, nil
+		}
+	}
+	return "", fmt.Errorf("AMI %q is not a supported distribution (%s)", name, distroNames())
+}
+
+// ParseDistro checks that a configured distribution is supported
+func ParseDistro(value string) (Distro, error) {
+	if _, ok := distroSetups[Distro(value)]; !ok {
+		return "", fmt.Errorf("unsupported distribution %q (%s)", value, distroNames())
+	}
+	return Distro(value), nil
+}
+
+func distroNames() string {
+	names := make([]string, len(Distros))
+	for i, distro := range Distros {
+		names[i] = string(distro)
+	}
+	return strings.Join(names, ", ")
+}
+
+// nodeBootstrapData feeds the node bootstrap script templates
+type nodeBootstrapData struct {
+	distroSetup
+	NodeBootstrap
+}
+
+var nodeBootstrapTemplate = template.Must(template.New("node-bootstrap").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: slurmd and munge setup for {{.Distro}}
+set -uo pipefail
+exec >> /var/log/aws-slurm-burst-bootstrap.log 2>&1
+
+{{- if .Prepare}}
+
+{{.Prepare}}
+{{- end}}
+{{- if .EFA}}
+
+# The EFA installer builds its kernel module against these headers
+{{.Install}} {{.KernelHeaders}} || echo "Kernel headers installation failed"
+{{- end}}
+
+if ! command -v slurmd >/dev/null 2>&1; then
+  echo "Installing slurmd and munge"
+{{- if .InstallCommand}}
+  {{.InstallCommand}} || { echo "node_bootstrap.install_command failed"; exit 1; }
+{{- else if .Packages}}
+  {{.Install}} {{.Packages}} || { echo "slurmd installation failed"; exit 1; }
+{{- else}}
+  echo "{{.Distro}} has no Slurm packages; set node_bootstrap.install_command or use an AMI with slurmd"
+  exit 1
+{{- end}}
+fi
+
+if ! command -v aws >/dev/null 2>&1; then
+  {{.Install}} {{.CLIPackage}} || { echo "AWS CLI installation failed"; exit 1; }
+fi
+`))
+
+var slurmdStartTemplate = template.Must(template.New("slurmd-start").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: start slurmd as the Slurm node this instance was launched for
+set -uo pipefail
+exec >> /var/log/aws-slurm-burst-bootstrap.log 2>&1
+
+TOKEN=$(curl -sS -X PUT http://169.254.169.254/latest/api/token -H "X-aws-ec2-metadata-token-ttl-seconds: 300")
+metadata() {
+  curl -sS -H "X-aws-ec2-metadata-token: $TOKEN" "http://169.254.169.254/latest/meta-data/$1"
+}
+INSTANCE_ID=$(metadata instance-id)
+REGION=$(metadata placement/region)
+
+# Resume tags the instance with its node name once the fleet returns
+NODE=""
+for _ in $(seq 60); do
+  NODE=$(aws ec2 describe-tags --region "$REGION" \
+    --filters "Name=resource-id,Values=$INSTANCE_ID" "Name=key,Values=SlurmNode" \
+    --query 'Tags[0].Value' --output text 2>/dev/null)
+  if [ -n "$NODE" ] && [ "$NODE" != "None" ]; then
+    break
+  fi
+  NODE=""
+  sleep 5
+done
+if [ -z "$NODE" ]; then
+  echo "Instance was never tagged with its Slurm node name"
+  exit 1
+fi
+
+echo "SLURMD_OPTIONS=\"-N $NODE --conf-server {{.ConfServer}}\"" > {{.SlurmdEnvFile}}
+systemctl enable --now munge
+systemctl enable --now slurmd
+echo "slurmd started as $NODE"
+`))
+
+// NodeBootstrapParts generates the user-data parts that install slurmd and
+// munge with the distribution's packages, and start slurmd once the other
+// setup scripts have run
+func NodeBootstrapParts(bootstrap NodeBootstrap) ([]Part, error) {
+	setup, ok := distroSetups[bootstrap.Distro]
+	if !ok {
+		return nil, fmt.Errorf("unsupported distribution %q (%s)", bootstrap.Distro, distroNames())
+	}
+	data := nodeBootstrapData{distroSetup: setup, NodeBootstrap: bootstrap}
+
+	var install, start bytes.Buffer
+	if err := nodeBootstrapTemplate.Execute(&install, data); err != nil {
+		return nil, fmt.Errorf("failed to render node bootstrap script: %w", err)
+	}
+	if err := slurmdStartTemplate.Execute(&start, data); err != nil {
+		return nil, fmt.Errorf("failed to render slurmd start script: %w", err)
+	}
+	return []Part{NewPart(NodeBootstrapFilename, install.String()), NewPart(SlurmdStartFilename, start.String())}, nil
+}
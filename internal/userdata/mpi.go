@@ -0,0 +1,182 @@
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// EFAInstallerURL is the AWS-hosted EFA installer bundle (driver, libfabric, Open MPI)
+const EFAInstallerURL = "https://efa-installer.amazonaws.com/aws-efa-installer-latest.tar.gz"
+
+// Supported MPI implementations for user-data setup
+const (
+	MPIOpenMPI  = "openmpi"
+	MPIIntelMPI = "intelmpi"
+	MPIMPICH    = "mpich"
+)
+
+// MPISetupFilename sorts first because cloud-init runs shell-script parts in filename order
+const MPISetupFilename = "00-aws-slurm-burst-mpi-setup.sh"
+
+// mpiSetupData feeds the MPI setup script template
+type mpiSetupData struct {
+	Implementation string
+	InstallerURL   string
+	InstallerFlags string
+	DeviceRDMA     bool
+	ModulePath     string
+	ModuleName     string
+}
+
+// efaInstallTemplate installs EFA software when libfabric cannot find the EFA provider
+var efaInstallTemplate = template.Must(template.New("efa-install").Parse(`EFA_BIN=/opt/amazon/efa/bin
+
+if ! "$EFA_BIN/fi_info" -p efa >/dev/null 2>&1; then
+  echo "Installing EFA software"
+  workdir=$(mktemp -d)
+  if curl -sSfL -o "$workdir/efa.tar.gz" "{{.InstallerURL}}" &&
+     tar -xf "$workdir/efa.tar.gz" -C "$workdir" &&
+     (cd "$workdir/aws-efa-installer" && ./efa_installer.sh -y{{.InstallerFlags}}); then
+    echo "EFA software installed"
+  else
+    echo "EFA installation failed"
+  fi
+  rm -rf "$workdir"
+fi
+`))
+
+var mpiSetupTemplate = template.Must(template.Must(efaInstallTemplate.Clone()).New("mpi-setup").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: EFA and {{.Implementation}} setup for MPI jobs
+set -uo pipefail
+exec >> /var/log/aws-slurm-burst-mpi-setup.log 2>&1
+
+PROFILE=/etc/profile.d/aws-slurm-burst-mpi.sh
+
+{{template "efa-install" .}}
+# Only export EFA fabric settings once libfabric can see an EFA device
+if "$EFA_BIN/fi_info" -p efa -t FI_EP_RDM >/dev/null 2>&1; then
+  cat > "$PROFILE" <<'PROFILE_EOF'
+export PATH=/opt/amazon/efa/bin:$PATH
+export FI_PROVIDER=efa
+{{- if .DeviceRDMA}}
+export FI_EFA_USE_DEVICE_RDMA=1
+{{- end}}
+{{- if eq .Implementation "openmpi"}}
+export PATH=/opt/amazon/openmpi/bin:$PATH
+export OMPI_MCA_pml=cm
+export OMPI_MCA_mtl=ofi
+export OMPI_MCA_mtl_ofi_provider_include=efa
+{{- else if eq .Implementation "intelmpi"}}
+export I_MPI_FABRICS=shm:ofi
+export I_MPI_OFI_PROVIDER=efa
+export I_MPI_OFI_LIBRARY_INTERNAL=0
+export FI_PROVIDER_PATH=/opt/amazon/efa/lib64/libfabric
+{{- else if eq .Implementation "mpich"}}
+export MPIR_CVAR_OFI_USE_PROVIDER=efa
+export LD_LIBRARY_PATH=/opt/amazon/efa/lib64:${LD_LIBRARY_PATH:-}
+{{- end}}
+{{- if .ModuleName}}
+if command -v module >/dev/null 2>&1; then
+  module use {{.ModulePath}} 2>/dev/null
+  module load {{.ModuleName}} 2>/dev/null
+fi
+{{- end}}
+PROFILE_EOF
+  echo "EFA validated, fabric settings exported to $PROFILE"
+else
+  echo "EFA not available on this instance; MPI will use the default TCP fabric" > "$PROFILE.disabled"
+  rm -f "$PROFILE"
+  echo "EFA validation failed"
+fi
+`))
+
+// MPISetupScript generates a user-data script that installs EFA software and
+// exports fabric settings for the plan's MPI implementation. It returns an
+// empty string when the plan does not need MPI setup.
+func MPISetupScript(mpi types.MPIConfiguration) (string, error) {
+	if !mpi.RequiresEFA || mpi.MPIImplementation == "" {
+		return "", nil
+	}
+
+	data := mpiSetupData{
+		Implementation: strings.ToLower(mpi.MPIImplementation),
+		InstallerURL:   EFAInstallerURL,
+		DeviceRDMA:     mpi.EFAGeneration >= 2,
+	}
+
+	switch data.Implementation {
+	case MPIOpenMPI:
+		// The full installer ships Open MPI and its modulefile under /opt/amazon
+		data.ModulePath = "/opt/amazon/modules/modulefiles"
+		data.ModuleName = "openmpi"
+	case MPIIntelMPI:
+		// Intel MPI comes from the AMI's oneAPI install; only libfabric is needed
+		data.InstallerFlags = " --minimal"
+		data.ModulePath = "/opt/intel/oneapi/modulefiles"
+		data.ModuleName = "mpi"
+	case MPIMPICH:
+		data.InstallerFlags = " --minimal"
+	default:
+		return "", fmt.Errorf("unsupported MPI implementation %q (supported: %s, %s, %s)",
+			mpi.MPIImplementation, MPIOpenMPI, MPIIntelMPI, MPIMPICH)
+	}
+
+	var buf bytes.Buffer
+	if err := mpiSetupTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render MPI setup script: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// ForExecutionPlan returns the user-data parts an execution plan adds on top of
+// the launch template: the plan's own bootstrap script and, when enabled, MPI,
+// NCCL or Neuron setup.
+func ForExecutionPlan(plan *types.ExecutionPlan, mpiSetup bool) ([]Part, error) {
+	var parts []Part
+
+	if strings.TrimSpace(plan.InstanceSpec.UserData) != "" {
+		planParts, err := ParseParts("execution-plan", plan.InstanceSpec.UserData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid execution plan user data: %w", err)
+		}
+		parts = append(parts, planParts...)
+	}
+
+	if !mpiSetup {
+		return parts, nil
+	}
+
+	var setup []Part
+	script, err := MPISetupScript(plan.MPIConfig)
+	if err != nil {
+		return nil, err
+	}
+	if script != "" {
+		setup = append(setup, NewPart(MPISetupFilename, script))
+	}
+
+	ncclScript, err := NCCLSetupScript(plan.MPIConfig)
+	if err != nil {
+		return nil, err
+	}
+	if ncclScript != "" {
+		setup = append(setup, NewPart(NCCLSetupFilename, ncclScript))
+	}
+
+	neuronScript, err := NeuronSetupScript(plan.MPIConfig)
+	if err != nil {
+		return nil, err
+	}
+	if neuronScript != "" {
+		setup = append(setup, NewPart(NeuronSetupFilename, neuronScript))
+	}
+
+	// Fabric setup runs before the plan's bootstrap so job tooling sees the settings
+	parts = append(setup, parts...)
+
+	return parts, nil
+}
@@ -0,0 +1,108 @@
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// MungeKeyFilename sorts after node bootstrap, which installs munge and the
+// AWS CLI, and before anything that needs munge running
+const MungeKeyFilename = "00-aws-slurm-burst-munge-key.sh"
+
+// munge refuses keys outside these sizes
+const (
+	MungeKeyMinBytes = 32
+	MungeKeyMaxBytes = 1024
+)
+
+// MungeKey is where nodes fetch the controller's munge key; exactly one of
+// Parameter and S3URL is set
+type MungeKey struct {
+	Parameter string // SSM SecureString holding the base64 key
+	S3URL     string // s3://bucket/key of the raw key, stored with SSE-KMS
+}
+
+// Location names where the key is fetched from
+func (k MungeKey) Location() string {
+	if k.S3URL != "" {
+		return k.S3URL
+	}
+	return "ssm:" + k.Parameter
+}
+
+// mungeKeyData feeds the munge key script template
+type mungeKeyData struct {
+	MungeKey
+	MinBytes, MaxBytes int
+}
+
+var mungeKeyTemplate = template.Must(template.New("munge-key").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: install the controller's munge key
+set -uo pipefail
+exec >> /var/log/aws-slurm-burst-munge-key.log 2>&1
+
+if ! command -v aws >/dev/null 2>&1; then
+  echo "The AWS CLI is needed to fetch the munge key from {{.Location}}"
+  exit 1
+fi
+
+umask 077
+mkdir -p /etc/munge
+NEW_KEY=$(mktemp /etc/munge/.munge.key.XXXXXX)
+{{- if .Parameter}}
+TOKEN=$(curl -sS -X PUT http://169.254.169.254/latest/api/token -H "X-aws-ec2-metadata-token-ttl-seconds: 300")
+REGION=$(curl -sS -H "X-aws-ec2-metadata-token: $TOKEN" http://169.254.169.254/latest/meta-data/placement/region)
+aws ssm get-parameter --region "$REGION" --name "{{.Parameter}}" --with-decryption \
+  --query Parameter.Value --output text | base64 -d > "$NEW_KEY"
+{{- else}}
+aws s3 cp --only-show-errors "{{.S3URL}}" "$NEW_KEY"
+{{- end}}
+if [ $? -ne 0 ]; then
+  rm -f "$NEW_KEY"
+  echo "Failed to fetch the munge key from {{.Location}}"
+  exit 1
+fi
+
+SIZE=$(stat -c %s "$NEW_KEY")
+if [ "$SIZE" -lt {{.MinBytes}} ] || [ "$SIZE" -gt {{.MaxBytes}} ]; then
+  rm -f "$NEW_KEY"
+  echo "Munge key from {{.Location}} is $SIZE bytes; munge needs {{.MinBytes}} to {{.MaxBytes}}"
+  exit 1
+fi
+
+if ! chown munge:munge "$NEW_KEY"; then
+  rm -f "$NEW_KEY"
+  echo "No munge user; install munge in the AMI or enable node_bootstrap"
+  exit 1
+fi
+chmod 400 "$NEW_KEY"
+mv -f "$NEW_KEY" /etc/munge/munge.key
+
+# An AMI with munge enabled started it with the baked-in key, if any
+systemctl try-restart munge
+echo "Munge key installed from {{.Location}}"
+`))
+
+// MungeKeyScript generates a user-data script that installs the controller's
+// munge key from SSM Parameter Store or S3, so burst nodes authenticate to
+// slurmctld without a key in the AMI
+func MungeKeyScript(key MungeKey) (string, error) {
+	if (key.Parameter == "") == (key.S3URL == "") {
+		return "", fmt.Errorf("munge key needs exactly one of an SSM parameter and an S3 URL")
+	}
+
+	var buf bytes.Buffer
+	if err := mungeKeyTemplate.Execute(&buf, mungeKeyData{MungeKey: key, MinBytes: MungeKeyMinBytes, MaxBytes: MungeKeyMaxBytes}); err != nil {
+		return "", fmt.Errorf("failed to render munge key script: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// CheckMungeKey reports whether key is a size munge accepts
+func CheckMungeKey(key []byte) error {
+	if len(key) < MungeKeyMinBytes || len(key) > MungeKeyMaxBytes {
+		return fmt.Errorf("munge key is %d bytes; munge needs %d to %d", len(key), MungeKeyMinBytes, MungeKeyMaxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// NCCLSetupFilename sorts after MPI setup and before launch template scripts
+const NCCLSetupFilename = "01-aws-slurm-burst-nccl-setup.sh"
+
+// ncclSetupData feeds the NCCL setup script template
+type ncclSetupData struct {
+	InstallerURL   string
+	InstallerFlags string
+	GPUDirectRDMA  bool
+}
+
+var ncclSetupTemplate = template.Must(template.Must(efaInstallTemplate.Clone()).New("nccl-setup").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: EFA and NCCL setup for distributed ML jobs
+set -uo pipefail
+exec >> /var/log/aws-slurm-burst-nccl-setup.log 2>&1
+
+PROFILE=/etc/profile.d/aws-slurm-burst-nccl.sh
+
+{{template "efa-install" .}}
+# Only point NCCL at EFA once libfabric can see an EFA device
+if "$EFA_BIN/fi_info" -p efa -t FI_EP_RDM >/dev/null 2>&1; then
+  cat > "$PROFILE" <<'PROFILE_EOF'
+export PATH=/opt/amazon/efa/bin:$PATH
+export FI_PROVIDER=efa
+export FI_EFA_FORK_SAFE=1
+{{- if .GPUDirectRDMA}}
+export FI_EFA_USE_DEVICE_RDMA=1
+{{- end}}
+export NCCL_SOCKET_IFNAME=^lo,docker
+export NCCL_DEBUG=WARN
+# aws-ofi-nccl plugin location differs between the EFA installer and Deep Learning AMIs
+for plugin_dir in /opt/amazon/ofi-nccl/lib /opt/aws-ofi-nccl/lib; do
+  if [ -d "$plugin_dir" ]; then
+    export LD_LIBRARY_PATH=$plugin_dir:/opt/amazon/efa/lib64:${LD_LIBRARY_PATH:-}
+    break
+  fi
+done
+PROFILE_EOF
+  echo "EFA validated, NCCL settings exported to $PROFILE"
+else
+  rm -f "$PROFILE"
+  echo "EFA validation failed; NCCL will use sockets"
+fi
+`))
+
+// NCCLSetupScript generates a user-data script that prepares EFA and the
+// aws-ofi-nccl plugin for distributed ML jobs. It returns an empty string for
+// other workload classes and for Neuron jobs, which NeuronSetupScript covers.
+func NCCLSetupScript(mpi types.MPIConfiguration) (string, error) {
+	if mpi.WorkloadClass != types.WorkloadDistributedML || !mpi.RequiresEFA || mpi.Accelerator == types.AcceleratorNeuron {
+		return "", nil
+	}
+
+	data := ncclSetupData{
+		InstallerURL:  EFAInstallerURL,
+		GPUDirectRDMA: mpi.GPUDirectRDMA,
+	}
+
+	var buf bytes.Buffer
+	if err := ncclSetupTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render NCCL setup script: %w", err)
+	}
+	return buf.String(), nil
+}
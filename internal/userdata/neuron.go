@@ -0,0 +1,111 @@
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Neuron package repositories, used when the AMI does not ship the Neuron SDK
+const (
+	NeuronYumRepoURL = "https://yum.repos.neuron.amazonaws.com"
+	NeuronAptRepoURL = "https://apt.repos.neuron.amazonaws.com"
+)
+
+// NeuronSetupFilename sorts after MPI setup and before launch template scripts.
+// Neuron and NCCL setup never run together.
+const NeuronSetupFilename = "01-aws-slurm-burst-neuron-setup.sh"
+
+// neuronSetupData feeds the Neuron setup script template
+type neuronSetupData struct {
+	YumRepoURL     string
+	AptRepoURL     string
+	EFA            bool
+	InstallerURL   string
+	InstallerFlags string
+}
+
+var neuronSetupTemplate = template.Must(template.Must(efaInstallTemplate.Clone()).New("neuron-setup").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: Neuron SDK setup for Trainium and Inferentia jobs
+set -uo pipefail
+exec >> /var/log/aws-slurm-burst-neuron-setup.log 2>&1
+
+PROFILE=/etc/profile.d/aws-slurm-burst-neuron.sh
+NEURON_BIN=/opt/aws/neuron/bin
+PACKAGES="aws-neuronx-dkms aws-neuronx-runtime-lib aws-neuronx-collectives aws-neuronx-tools"
+
+# Neuron Deep Learning AMIs ship the driver and runtime already
+if [ ! -x "$NEURON_BIN/neuron-ls" ]; then
+  echo "Installing Neuron driver and runtime"
+  . /etc/os-release
+  if command -v dnf >/dev/null 2>&1 || command -v yum >/dev/null 2>&1; then
+    cat > /etc/yum.repos.d/neuron.repo <<'REPO_EOF'
+[neuron]
+name=Neuron YUM Repository
+baseurl={{.YumRepoURL}}
+enabled=1
+metadata_expire=0
+REPO_EOF
+    rpm --import "{{.YumRepoURL}}/GPG-PUB-KEY-AMAZON-AWS-NEURON.PUB"
+    installer=$(command -v dnf || command -v yum)
+    "$installer" install -y "kernel-devel-$(uname -r)" $PACKAGES || echo "Neuron installation failed"
+  elif command -v apt-get >/dev/null 2>&1; then
+    curl -sSfL "{{.AptRepoURL}}/GPG-PUB-KEY-AMAZON-AWS-NEURON.PUB" | gpg --dearmor -o /usr/share/keyrings/neuron.gpg
+    echo "deb [signed-by=/usr/share/keyrings/neuron.gpg] {{.AptRepoURL}} ${VERSION_CODENAME} main" > /etc/apt/sources.list.d/neuron.list
+    apt-get update && apt-get install -y "linux-headers-$(uname -r)" $PACKAGES || echo "Neuron installation failed"
+  else
+    echo "No supported package manager for the Neuron SDK"
+  fi
+fi
+{{if .EFA}}
+{{template "efa-install" .}}{{end}}
+# Only export Neuron settings once the runtime can see a Neuron device
+if [ -x "$NEURON_BIN/neuron-ls" ] && "$NEURON_BIN/neuron-ls" >/dev/null 2>&1; then
+  cat > "$PROFILE" <<'PROFILE_EOF'
+export PATH=/opt/aws/neuron/bin:$PATH
+PROFILE_EOF
+{{- if .EFA}}
+  # Neuron collectives run over EFA between Trainium nodes
+  if "$EFA_BIN/fi_info" -p efa -t FI_EP_RDM >/dev/null 2>&1; then
+    cat >> "$PROFILE" <<'PROFILE_EOF'
+export PATH=/opt/amazon/efa/bin:$PATH
+export FI_PROVIDER=efa
+export FI_EFA_USE_DEVICE_RDMA=1
+export FI_EFA_FORK_SAFE=1
+PROFILE_EOF
+    echo "EFA validated for Neuron collectives"
+  else
+    echo "EFA validation failed; multi-node Neuron collectives will not work"
+  fi
+{{- end}}
+  echo "Neuron devices found, settings exported to $PROFILE"
+else
+  rm -f "$PROFILE"
+  echo "No Neuron devices found"
+fi
+`))
+
+// NeuronSetupScript generates a user-data script that installs the Neuron
+// driver and runtime when the AMI lacks them and, for multi-node Trainium jobs,
+// prepares EFA for Neuron collectives. It returns an empty string for plans
+// that do not use Neuron instances.
+func NeuronSetupScript(mpi types.MPIConfiguration) (string, error) {
+	if mpi.Accelerator != types.AcceleratorNeuron {
+		return "", nil
+	}
+
+	data := neuronSetupData{
+		YumRepoURL:   NeuronYumRepoURL,
+		AptRepoURL:   NeuronAptRepoURL,
+		EFA:          mpi.RequiresEFA,
+		InstallerURL: EFAInstallerURL,
+	}
+
+	var buf bytes.Buffer
+	if err := neuronSetupTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render Neuron setup script: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,199 @@
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// NodeMetricsFilename sorts after fabric setup so the collector sees EFA devices
+const NodeMetricsFilename = "02-aws-slurm-burst-node-metrics.sh"
+
+// NodeMetricsCollectorPath is where user data installs the collector on each node
+const NodeMetricsCollectorPath = "/usr/local/sbin/aws-slurm-burst-node-metrics"
+
+// nodeMetricsData feeds the collector and its install script
+type nodeMetricsData struct {
+	IntervalSeconds int
+	Destination     string
+	CollectorPath   string
+}
+
+// nodeMetricsCollectorTemplate samples /proc and EFA hardware counters and
+// summarizes the samples taken during a job as types.NodeMetricsSummary JSON
+var nodeMetricsCollectorTemplate = template.Must(template.New("node-metrics-collector").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: node metrics collector
+#   sample           append counters every {{.IntervalSeconds}}s (systemd service)
+#   start JOBID      record the job start (node prolog)
+#   summarize JOBID  summarize the job's samples and write them to the destination (node epilog)
+set -uo pipefail
+
+INTERVAL={{.IntervalSeconds}}
+DESTINATION="{{.Destination}}"
+STATE_DIR=${AWS_SLURM_BURST_METRICS_DIR:-/var/lib/aws-slurm-burst/node-metrics}
+SAMPLES="$STATE_DIR/samples"
+NODE=${SLURMD_NODENAME:-$(hostname -s)}
+
+# epoch cpu_busy cpu_total mem_total_kb mem_available_kb net_bytes efa_bytes net_tx_bytes
+# net_tx_bytes is the interface counter CloudWatch reports as NetworkOut
+counters() {
+  local efa=0 f net
+  for f in /sys/class/infiniband/*/ports/*/hw_counters/rx_bytes /sys/class/infiniband/*/ports/*/hw_counters/tx_bytes; do
+    [ -r "$f" ] && efa=$((efa + $(cat "$f")))
+  done
+  net=$(awk 'NR>2{sub(/^ +/, ""); split($0, f, /[: ]+/); if (f[1] != "lo") { sum += f[2] + f[10]; tx += f[10] }} END{printf "%.0f %.0f", sum, tx}' /proc/net/dev)
+  echo "$(date +%s)" \
+    "$(awk '/^cpu /{busy=$2+$3+$4+$7+$8+$9; print busy, busy+$5+$6}' /proc/stat)" \
+    "$(awk '/^MemTotal:/{t=$2} /^MemAvailable:/{a=$2} END{print t, a}' /proc/meminfo)" \
+    "${net% *}" "$efa" "${net#* }"
+}
+
+# Sum of EFA link rates in Gb/s, e.g. "100 Gb/sec (4X EDR)"
+efa_rate() {
+  cat /sys/class/infiniband/*/ports/*/rate 2>/dev/null | awk '{sum += $1} END{print sum + 0}'
+}
+
+imds() {
+  local token
+  token=$(curl -sf -m 2 -X PUT -H "X-aws-ec2-metadata-token-ttl-seconds: 60" http://169.254.169.254/latest/api/token) &&
+    curl -sf -m 2 -H "X-aws-ec2-metadata-token: $token" "http://169.254.169.254/latest/meta-data/$1"
+}
+
+iso() {
+  date -u -d "@$1" +%Y-%m-%dT%H:%M:%SZ
+}
+
+sample() {
+  mkdir -p "$STATE_DIR"
+  echo "$(imds instance-id) $(imds instance-type)" > "$STATE_DIR/instance"
+  while true; do
+    counters >> "$SAMPLES"
+    # Keep about a week of samples
+    if [ "$(wc -l < "$SAMPLES")" -gt $((7 * 86400 / INTERVAL)) ]; then
+      tail -n $((86400 / INTERVAL)) "$SAMPLES" > "$SAMPLES.tmp" && mv "$SAMPLES.tmp" "$SAMPLES"
+    fi
+    sleep "$INTERVAL"
+  done
+}
+
+summarize() {
+  local job=$1 start end instance_id="" instance_type="" out
+  start=$(cat "$STATE_DIR/job-$job.start" 2>/dev/null || awk 'NR==1{print $1}' "$SAMPLES")
+  end=$(date +%s)
+  [ -r "$STATE_DIR/instance" ] && read -r instance_id instance_type < "$STATE_DIR/instance"
+  out="$STATE_DIR/job-$job-$NODE.json"
+
+  awk -v start="${start:-0}" -v rate="$(efa_rate)" -v job="$job" -v node="$NODE" \
+      -v instance_id="$instance_id" -v instance_type="$instance_type" \
+      -v start_iso="$(iso "${start:-0}")" -v end_iso="$(iso "$end")" '
+    $1 >= start {
+      if (n > 0 && $1 > t) {
+        dt = $1 - t
+        if ($3 > total) { c = ($2 - busy) / ($3 - total); cpu += c; if (c > cpu_peak) cpu_peak = c; intervals++ }
+        g = ($6 - net) * 8 / dt / 1e9; if (g > net_peak) net_peak = g
+        e = ($7 - efa) * 8 / dt / 1e9; if (e < 0) e = 0; efa_sum += e; if (e > efa_peak) efa_peak = e
+        # Counters restart from zero when an interface resets
+        if ($8 > tx) out += $8 - tx
+      }
+      if ($4 > 0) { m = ($4 - $5) / $4; mem += m; if (m > mem_peak) mem_peak = m }
+      t = $1; busy = $2; total = $3; net = $6; efa = $7; tx = $8; n++
+    }
+    END {
+      printf "{\"job_id\":\"%s\",\"node_name\":\"%s\",\"instance_id\":\"%s\",\"instance_type\":\"%s\",", job, node, instance_id, instance_type
+      printf "\"start\":\"%s\",\"end\":\"%s\",\"samples\":%d,", start_iso, end_iso, n
+      printf "\"cpu_utilization\":%.4f,\"cpu_peak\":%.4f,", intervals ? cpu / intervals : 0, cpu_peak
+      printf "\"memory_utilization\":%.4f,\"memory_peak\":%.4f,", n ? mem / n : 0, mem_peak
+      printf "\"network_peak_gbps\":%.3f,\"network_out_bytes\":%.0f,\"efa_peak_gbps\":%.3f,", net_peak, out, efa_peak
+      printf "\"efa_utilization\":%.4f}\n", (intervals && rate > 0) ? efa_sum / intervals / rate : 0
+    }' "$SAMPLES" > "$out" || return 1
+
+  case "$DESTINATION" in
+    s3://*) aws s3 cp --only-show-errors "$out" "$DESTINATION/job-$job/$NODE.json" || return 1 ;;
+    *) mkdir -p "$DESTINATION/job-$job" && cp "$out" "$DESTINATION/job-$job/$NODE.json" || return 1 ;;
+  esac
+  rm -f "$STATE_DIR/job-$job.start" "$out"
+}
+
+case "${1:-}" in
+  sample) sample ;;
+  start) mkdir -p "$STATE_DIR" && date +%s > "$STATE_DIR/job-$2.start" ;;
+  summarize) summarize "$2" ;;
+  *) echo "usage: $0 sample|start JOBID|summarize JOBID" >&2; exit 2 ;;
+esac
+`))
+
+// nodeMetricsSetupTemplate installs the collector with a systemd sampler and
+// node prolog/epilog hooks that bracket each job
+var nodeMetricsSetupTemplate = template.Must(template.Must(nodeMetricsCollectorTemplate.Clone()).New("node-metrics-setup").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: node metrics collector setup
+set -uo pipefail
+exec >> /var/log/aws-slurm-burst-node-metrics.log 2>&1
+
+cat > {{.CollectorPath}} <<'COLLECTOR_EOF'
+{{template "node-metrics-collector" .}}COLLECTOR_EOF
+chmod 755 {{.CollectorPath}}
+
+cat > /etc/systemd/system/aws-slurm-burst-node-metrics.service <<'UNIT_EOF'
+[Unit]
+Description=aws-slurm-burst node metrics sampler
+After=network-online.target
+
+[Service]
+ExecStart={{.CollectorPath}} sample
+Restart=always
+
+[Install]
+WantedBy=multi-user.target
+UNIT_EOF
+systemctl daemon-reload
+systemctl enable --now aws-slurm-burst-node-metrics.service
+
+# Hooks never fail the job; the summary is best effort
+mkdir -p /etc/slurm/prolog.d /etc/slurm/epilog.d
+cat > /etc/slurm/prolog.d/50-aws-slurm-burst-node-metrics.sh <<'HOOK_EOF'
+#!/bin/bash
+{{.CollectorPath}} start "$SLURM_JOB_ID" >/dev/null 2>&1
+exit 0
+HOOK_EOF
+cat > /etc/slurm/epilog.d/50-aws-slurm-burst-node-metrics.sh <<'HOOK_EOF'
+#!/bin/bash
+timeout 60 {{.CollectorPath}} summarize "$SLURM_JOB_ID" >> /var/log/aws-slurm-burst-node-metrics.log 2>&1
+exit 0
+HOOK_EOF
+chmod 755 /etc/slurm/prolog.d/50-aws-slurm-burst-node-metrics.sh /etc/slurm/epilog.d/50-aws-slurm-burst-node-metrics.sh
+echo "Node metrics collector installed"
+`))
+
+// NodeMetricsCollector renders the collector program that user data installs at
+// NodeMetricsCollectorPath. Summaries are written to destination/job-<id>/<node>.json.
+func NodeMetricsCollector(intervalSeconds int, destination string) (string, error) {
+	return renderNodeMetrics(nodeMetricsCollectorTemplate, intervalSeconds, destination)
+}
+
+// NodeMetricsScript generates a user-data script that installs the node metrics
+// collector, its sampling service and the node prolog/epilog hooks
+func NodeMetricsScript(intervalSeconds int, destination string) (string, error) {
+	return renderNodeMetrics(nodeMetricsSetupTemplate, intervalSeconds, destination)
+}
+
+func renderNodeMetrics(tmpl *template.Template, intervalSeconds int, destination string) (string, error) {
+	if intervalSeconds <= 0 {
+		return "", fmt.Errorf("node metrics interval must be positive")
+	}
+	if destination == "" || strings.ContainsAny(destination, "\"$`\\ \n") {
+		return "", fmt.Errorf("invalid node metrics destination %q", destination)
+	}
+
+	data := nodeMetricsData{
+		IntervalSeconds: intervalSeconds,
+		Destination:     strings.TrimSuffix(destination, "/"),
+		CollectorPath:   NodeMetricsCollectorPath,
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render node metrics collector: %w", err)
+	}
+	return buf.String(), nil
+}
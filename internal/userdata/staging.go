@@ -0,0 +1,273 @@
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// StagingFilename sorts after the checkpointer and before slurmd starts, so
+// stage_in data is in place before the node takes the job
+const StagingFilename = "04-aws-slurm-burst-staging.sh"
+
+// StagingProgramPath is where user data installs the stager on each node
+const StagingProgramPath = "/usr/local/bin/aws-slurm-burst-staging"
+
+// stageInFailedPath marks a node whose stage_in failed, so its prolog fails the job
+const stageInFailedPath = "/var/lib/aws-slurm-burst/stage-in-failed"
+
+// Staging configures the burst buffer staging of a launch: the job's scratch
+// volume and the transfers its directives declare
+type Staging struct {
+	JobID                  string
+	ScratchMount           string // Where the scratch volume is mounted
+	ScratchGB              int    // Size of the scratch volume; zero stages onto the root volume
+	TransferTimeoutMinutes int    // Per stage_in or stage_out pass
+	StageIn                []types.StagingTransfer
+	StageOut               []types.StagingTransfer
+}
+
+// scratchVariables are the names job scripts use for the node's scratch in
+// transfer paths, replaced with the mount point when the stager is rendered
+var scratchVariables = []string{"DW_JOB_STRIPED", "DW_JOB_PRIVATE", "ASB_SCRATCH"}
+
+// stagingData feeds the stager and its install script
+type stagingData struct {
+	Staging
+	ScratchBytes   int64
+	TimeoutSeconds int
+	ProgramPath    string
+	FailedPath     string
+}
+
+// stagingProgramTemplate mounts the scratch volume and runs the job's transfers
+var stagingProgramTemplate = template.Must(template.New("staging-program").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: burst buffer staging for job {{.JobID}}
+#   mount           format and mount the scratch volume at {{.ScratchMount}}
+#   stage-in        copy the job's stage_in sources onto this node
+#   stage-out       copy the job's stage_out sources off this node
+#   prolog JOBID    give the job its scratch, or fail it when stage_in failed (node prolog)
+#   epilog JOBID    stage out from the job's batch host (node epilog)
+set -uo pipefail
+
+JOB={{.JobID}}
+SCRATCH="{{.ScratchMount}}"
+SCRATCH_BYTES={{.ScratchBytes}}
+FAILED="{{.FailedPath}}"
+NODE=${SLURMD_NODENAME:-$(hostname -s)}
+
+# scratch_disk prints the unformatted, unpartitioned disk of the scratch
+# volume's size; NVMe device names do not follow the launch template's
+scratch_disk() {
+  local name size type fstype
+  while read -r name size type fstype; do
+    [ "$type" = disk ] && [ "$size" = "$SCRATCH_BYTES" ] && [ -z "$fstype" ] &&
+      [ "$(lsblk -nr "$name" | wc -l)" = 1 ] && { echo "$name"; return 0; }
+  done < <(lsblk -dnbpo NAME,SIZE,TYPE,FSTYPE)
+  return 1
+}
+
+mount_scratch() {
+  local disk=""
+  mkdir -p "$SCRATCH"
+  [ "$SCRATCH_BYTES" -gt 0 ] || return 0
+  for _ in $(seq 60); do
+    disk=$(scratch_disk) && break
+    sleep 2
+  done
+  if [ -z "$disk" ]; then
+    echo "No unformatted scratch disk of $SCRATCH_BYTES bytes attached"
+    return 1
+  fi
+  if command -v mkfs.xfs >/dev/null 2>&1; then
+    mkfs.xfs -q "$disk" || return 1
+  else
+    mkfs.ext4 -q "$disk" || return 1
+  fi
+  mount -o noatime "$disk" "$SCRATCH" && echo "Mounted $disk at $SCRATCH"
+}
+
+# transfer TYPE SOURCE DESTINATION copies a file or directory between S3 and
+# local paths
+transfer() {
+  local kind=$1 source=$2 destination=$3
+  echo "$(date -u +%Y-%m-%dT%H:%M:%SZ) Copying $kind $source to $destination"
+  if [[ $destination != s3://* ]]; then
+    if [ "$kind" = directory ] || [[ $destination == */ ]]; then
+      mkdir -p "$destination"
+    else
+      mkdir -p "$(dirname "$destination")"
+    fi
+  fi
+  if [[ $source == s3://* || $destination == s3://* ]]; then
+    if [ "$kind" = directory ]; then
+      aws s3 sync --only-show-errors "$source" "$destination"
+    else
+      aws s3 cp --only-show-errors "$source" "$destination"
+    fi
+  elif [ "$kind" = directory ]; then
+    cp -a "$source/." "$destination/"
+  else
+    cp -a "$source" "$destination"
+  fi
+}
+
+stage_in() {
+  local failed=0
+{{- range .StageIn}}
+  transfer {{.Type}} "{{.Source}}" "{{.Destination}}" || failed=1
+{{- end}}
+  return $failed
+}
+
+stage_out() {
+  local failed=0
+{{- range .StageOut}}
+  transfer {{.Type}} "{{.Source}}" "{{.Destination}}" || failed=1
+{{- end}}
+  return $failed
+}
+
+# prolog JOBID hands the scratch to the job; a failed stage_in fails the
+# job, which drains the node and requeues the job elsewhere
+prolog() {
+  [ "$1" = "$JOB" ] || return 0
+  if [ -e "$FAILED" ]; then
+    echo "Stage-in for job $JOB failed on $NODE"
+    return 1
+  fi
+  chown -R "${SLURM_JOB_UID:?}:${SLURM_JOB_GID:?}" "$SCRATCH" && chmod 700 "$SCRATCH"
+}
+
+# epilog JOBID stages out from the batch host only; the other nodes hold no
+# copy the job wrote results to
+epilog() {
+  [ "$1" = "$JOB" ] || return 0
+  [ "$(scontrol show job -o "$JOB" 2>/dev/null | grep -o 'BatchHost=[^ ]*')" = "BatchHost=$NODE" ] || return 0
+  if stage_out; then
+    echo "Staged out job $JOB"
+  else
+    echo "Stage-out for job $JOB failed"
+  fi
+}
+
+case "${1:-}" in
+  mount) mount_scratch ;;
+  stage-in) stage_in ;;
+  stage-out) stage_out ;;
+  prolog) prolog "$2" ;;
+  epilog) epilog "$2" ;;
+  *) echo "usage: $0 mount|stage-in|stage-out|prolog JOBID|epilog JOBID" >&2; exit 2 ;;
+esac
+`))
+
+// stagingSetupTemplate installs the stager, mounts the scratch volume, stages
+// in and installs the node prolog/epilog hooks
+var stagingSetupTemplate = template.Must(template.Must(stagingProgramTemplate.Clone()).New("staging-setup").Parse(`#!/bin/bash
+# Generated by aws-slurm-burst: burst buffer staging setup for job {{.JobID}}
+set -uo pipefail
+exec >> /var/log/aws-slurm-burst-staging.log 2>&1
+
+cat > {{.ProgramPath}} <<'PROGRAM_EOF'
+{{template "staging-program" .}}PROGRAM_EOF
+chmod 755 {{.ProgramPath}}
+
+mkdir -p "$(dirname "{{.FailedPath}}")"
+rm -f "{{.FailedPath}}"
+if {{.ProgramPath}} mount && timeout {{.TimeoutSeconds}} {{.ProgramPath}} stage-in; then
+  echo "Staged in job {{.JobID}}"
+else
+  echo "Stage-in for job {{.JobID}} failed"
+  touch "{{.FailedPath}}"
+fi
+
+# The prolog hook fails the job only when stage_in failed; the epilog hook never does
+mkdir -p /etc/slurm/prolog.d /etc/slurm/epilog.d
+cat > /etc/slurm/prolog.d/50-aws-slurm-burst-staging.sh <<'HOOK_EOF'
+#!/bin/bash
+timeout 60 {{.ProgramPath}} prolog "$SLURM_JOB_ID" >> /var/log/aws-slurm-burst-staging.log 2>&1
+HOOK_EOF
+cat > /etc/slurm/epilog.d/50-aws-slurm-burst-staging.sh <<'HOOK_EOF'
+#!/bin/bash
+timeout {{.TimeoutSeconds}} {{.ProgramPath}} epilog "$SLURM_JOB_ID" >> /var/log/aws-slurm-burst-staging.log 2>&1
+exit 0
+HOOK_EOF
+chmod 755 /etc/slurm/prolog.d/50-aws-slurm-burst-staging.sh /etc/slurm/epilog.d/50-aws-slurm-burst-staging.sh
+`))
+
+// StagingScript generates a user-data script that mounts the job's scratch
+// volume, runs its stage_in transfers and installs the hooks that hand the
+// scratch to the job and stage out when it ends
+func StagingScript(staging Staging) (string, error) {
+	return renderStaging(stagingSetupTemplate, staging)
+}
+
+func renderStaging(tmpl *template.Template, staging Staging) (string, error) {
+	if !jobIDPattern.MatchString(staging.JobID) {
+		return "", fmt.Errorf("invalid staging job ID %q", staging.JobID)
+	}
+	mount := filepath.Clean(staging.ScratchMount)
+	if !filepath.IsAbs(mount) || mount == "/" || strings.ContainsAny(mount, unsafeStagingChars) {
+		return "", fmt.Errorf("invalid scratch mount %q", staging.ScratchMount)
+	}
+	if staging.ScratchGB < 0 || staging.TransferTimeoutMinutes <= 0 {
+		return "", fmt.Errorf("scratch size cannot be negative and the transfer timeout must be positive")
+	}
+
+	data := stagingData{
+		Staging:        staging,
+		ScratchBytes:   int64(staging.ScratchGB) << 30,
+		TimeoutSeconds: staging.TransferTimeoutMinutes * 60,
+		ProgramPath:    StagingProgramPath,
+		FailedPath:     stageInFailedPath,
+	}
+	data.ScratchMount = mount
+	var err error
+	if data.StageIn, err = resolveTransfers(staging.StageIn, mount); err != nil {
+		return "", err
+	}
+	if data.StageOut, err = resolveTransfers(staging.StageOut, mount); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render stager: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// unsafeStagingChars cannot appear in a staging path, which is rendered
+// inside double quotes
+const unsafeStagingChars = "\"$`\\\n'"
+
+// resolveTransfers replaces the scratch variables in transfer paths with the
+// mount point and checks the result is an s3:// URL or an absolute path
+func resolveTransfers(transfers []types.StagingTransfer, mount string) ([]types.StagingTransfer, error) {
+	var pairs []string
+	for _, name := range scratchVariables {
+		pairs = append(pairs, "${"+name+"}", mount, "$"+name, mount)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	resolved := make([]types.StagingTransfer, 0, len(transfers))
+	for _, transfer := range transfers {
+		if transfer.Type != types.StagingFile && transfer.Type != types.StagingDirectory {
+			return nil, fmt.Errorf("invalid staging transfer type %q", transfer.Type)
+		}
+		transfer.Source = replacer.Replace(transfer.Source)
+		transfer.Destination = replacer.Replace(transfer.Destination)
+		for _, path := range []string{transfer.Source, transfer.Destination} {
+			if strings.ContainsAny(path, unsafeStagingChars) || strings.ContainsAny(path, " \t") ||
+				(!strings.HasPrefix(path, "s3://") && !filepath.IsAbs(path)) {
+				return nil, fmt.Errorf("invalid staging path %q: use an s3:// URL or an absolute path", path)
+			}
+		}
+		resolved = append(resolved, transfer)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,151 @@
+package userdata
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// MaxUserDataBytes is the EC2 limit for raw (pre-base64) user data
+const MaxUserDataBytes = 16 * 1024
+
+// Part is one section of a multi-part cloud-init user-data document
+type Part struct {
+	Filename    string
+	ContentType string
+	Content     string
+}
+
+// NewPart creates a part, detecting the cloud-init content type from the first line
+func NewPart(filename, content string) Part {
+	return Part{
+		Filename:    filename,
+		ContentType: detectContentType(content),
+		Content:     content,
+	}
+}
+
+// ParseParts splits existing user data into parts. Plain scripts become a single
+// part; MIME multipart documents are unpacked so they can be merged with new parts.
+func ParseParts(filename, content string) ([]Part, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(content, "\x1f\x8b") {
+		return nil, fmt.Errorf("gzip-compressed user data cannot be merged")
+	}
+	if !strings.HasPrefix(strings.ToLower(content), "content-type:") &&
+		!strings.HasPrefix(strings.ToLower(content), "mime-version:") {
+		return []Part{NewPart(filename, content)}, nil
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse MIME user data: %w", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse user data content type: %w", err)
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		body, err := io.ReadAll(msg.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user data body: %w", err)
+		}
+		return []Part{{Filename: filename, ContentType: mediaType, Content: string(body)}}, nil
+	}
+
+	var parts []Part
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for index := 0; ; index++ {
+		mimePart, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user data part: %w", err)
+		}
+
+		body, err := io.ReadAll(mimePart)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user data part: %w", err)
+		}
+
+		partName := mimePart.FileName()
+		if partName == "" {
+			partName = fmt.Sprintf("%s-%d", filename, index)
+		}
+
+		contentType := mimePart.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = detectContentType(string(body))
+		}
+
+		parts = append(parts, Part{Filename: partName, ContentType: contentType, Content: string(body)})
+	}
+
+	return parts, nil
+}
+
+// Combine assembles parts into a cloud-init MIME multipart document
+// (cloud-init runs shell-script parts in filename order, not document order)
+func Combine(parts []Part) (string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n", writer.Boundary())
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n\r\n")
+
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		contentType := part.ContentType
+		if !strings.Contains(contentType, "charset") {
+			contentType += `; charset="us-ascii"`
+		}
+		header.Set("Content-Type", contentType)
+		header.Set("MIME-Version", "1.0")
+		header.Set("Content-Transfer-Encoding", "7bit")
+		header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", part.Filename))
+
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return "", fmt.Errorf("failed to create user data part %s: %w", part.Filename, err)
+		}
+		if _, err := io.WriteString(partWriter, part.Content); err != nil {
+			return "", fmt.Errorf("failed to write user data part %s: %w", part.Filename, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to finalize user data: %w", err)
+	}
+
+	if buf.Len() > MaxUserDataBytes {
+		return "", fmt.Errorf("combined user data is %d bytes, exceeding the EC2 limit of %d", buf.Len(), MaxUserDataBytes)
+	}
+
+	return buf.String(), nil
+}
+
+// detectContentType maps cloud-init's first-line markers to MIME types
+func detectContentType(content string) string {
+	switch {
+	case strings.HasPrefix(content, "#cloud-config"):
+		return "text/cloud-config"
+	case strings.HasPrefix(content, "#cloud-boothook"):
+		return "text/cloud-boothook"
+	case strings.HasPrefix(content, "#include"):
+		return "text/x-include-url"
+	case strings.HasPrefix(content, "#part-handler"):
+		return "text/part-handler"
+	default:
+		return "text/x-shellscript"
+	}
+}
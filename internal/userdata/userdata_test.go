@@ -0,0 +1,609 @@
+package userdata
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPISetupScript(t *testing.T) {
+	tests := []struct {
+		name        string
+		mpi         types.MPIConfiguration
+		contains    []string
+		excludes    []string
+		empty       bool
+		expectError string
+	}{
+		{
+			name:  "no EFA means no setup",
+			mpi:   types.MPIConfiguration{IsMPIJob: true, MPIImplementation: "openmpi"},
+			empty: true,
+		},
+		{
+			name: "Open MPI with EFA generation 2",
+			mpi:  types.MPIConfiguration{IsMPIJob: true, RequiresEFA: true, MPIImplementation: "openmpi", EFAGeneration: 2},
+			contains: []string{
+				"./efa_installer.sh -y)",
+				"export FI_PROVIDER=efa",
+				"export FI_EFA_USE_DEVICE_RDMA=1",
+				"export OMPI_MCA_mtl=ofi",
+				"module load openmpi",
+			},
+			excludes: []string{"I_MPI_", "--minimal"},
+		},
+		{
+			name: "Intel MPI uses minimal installer",
+			mpi:  types.MPIConfiguration{IsMPIJob: true, RequiresEFA: true, MPIImplementation: "IntelMPI", EFAGeneration: 1},
+			contains: []string{
+				"./efa_installer.sh -y --minimal",
+				"export I_MPI_OFI_PROVIDER=efa",
+				"module load mpi",
+			},
+			excludes: []string{"FI_EFA_USE_DEVICE_RDMA", "OMPI_MCA"},
+		},
+		{
+			name:        "unsupported implementation",
+			mpi:         types.MPIConfiguration{RequiresEFA: true, MPIImplementation: "lam"},
+			expectError: `unsupported MPI implementation "lam" (supported: openmpi, intelmpi, mpich)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := MPISetupScript(tt.mpi)
+			if tt.expectError != "" {
+				assert.EqualError(t, err, tt.expectError)
+				return
+			}
+			require.NoError(t, err)
+			if tt.empty {
+				assert.Empty(t, script)
+				return
+			}
+			assert.True(t, strings.HasPrefix(script, "#!/bin/bash"))
+			for _, expected := range tt.contains {
+				assert.Contains(t, script, expected)
+			}
+			for _, unexpected := range tt.excludes {
+				assert.NotContains(t, script, unexpected)
+			}
+		})
+	}
+}
+
+func TestCombineAndParseParts(t *testing.T) {
+	parts := []Part{
+		NewPart("bootstrap.sh", "#!/bin/bash\necho hello\n"),
+		NewPart("config.yaml", "#cloud-config\npackages: [htop]\n"),
+	}
+	assert.Equal(t, "text/x-shellscript", parts[0].ContentType)
+	assert.Equal(t, "text/cloud-config", parts[1].ContentType)
+
+	combined, err := Combine(parts)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(combined, "Content-Type: multipart/mixed"))
+
+	// Round-trip so existing MIME user data can be merged with new parts
+	parsed, err := ParseParts("template", combined)
+	require.NoError(t, err)
+	require.Len(t, parsed, 2)
+	assert.Equal(t, "bootstrap.sh", parsed[0].Filename)
+	assert.Equal(t, "#!/bin/bash\necho hello\n", parsed[0].Content)
+	assert.Contains(t, parsed[1].ContentType, "text/cloud-config")
+
+	// Plain scripts become a single part
+	plain, err := ParseParts("template", "#!/bin/bash\nsystemctl start slurmd\n")
+	require.NoError(t, err)
+	require.Len(t, plain, 1)
+	assert.Equal(t, "template", plain[0].Filename)
+
+	empty, err := ParseParts("template", "   ")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	_, err = Combine([]Part{NewPart("big.sh", strings.Repeat("x", MaxUserDataBytes))})
+	assert.Error(t, err)
+}
+
+func TestForExecutionPlan(t *testing.T) {
+	plan := &types.ExecutionPlan{
+		InstanceSpec: types.InstanceSpecification{UserData: "#!/bin/bash\necho bootstrap\n"},
+		MPIConfig:    types.MPIConfiguration{IsMPIJob: true, RequiresEFA: true, MPIImplementation: "openmpi"},
+	}
+
+	parts, err := ForExecutionPlan(plan, true)
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	assert.Equal(t, MPISetupFilename, parts[0].Filename)
+	assert.Equal(t, "execution-plan", parts[1].Filename)
+
+	parts, err = ForExecutionPlan(plan, false)
+	require.NoError(t, err)
+	require.Len(t, parts, 1)
+	assert.Equal(t, "execution-plan", parts[0].Filename)
+}
+
+func TestNCCLSetupScript(t *testing.T) {
+	mpi := types.MPIConfiguration{
+		IsMPIJob:      true,
+		RequiresEFA:   true,
+		WorkloadClass: types.WorkloadDistributedML,
+		GPUDirectRDMA: true,
+	}
+
+	script, err := NCCLSetupScript(mpi)
+	require.NoError(t, err)
+	assert.Contains(t, script, "export FI_EFA_USE_DEVICE_RDMA=1")
+	assert.Contains(t, script, "/opt/amazon/ofi-nccl/lib")
+
+	// MPI workloads get MPI setup, not NCCL setup
+	mpi.WorkloadClass = types.WorkloadMPI
+	script, err = NCCLSetupScript(mpi)
+	require.NoError(t, err)
+	assert.Empty(t, script)
+
+	plan := &types.ExecutionPlan{MPIConfig: types.MPIConfiguration{
+		RequiresEFA:   true,
+		WorkloadClass: types.WorkloadDistributedML,
+	}}
+	parts, err := ForExecutionPlan(plan, true)
+	require.NoError(t, err)
+	require.Len(t, parts, 1)
+	assert.Equal(t, NCCLSetupFilename, parts[0].Filename)
+}
+
+func TestNeuronSetupScript(t *testing.T) {
+	mpi := types.MPIConfiguration{
+		IsMPIJob:      true,
+		RequiresEFA:   true,
+		WorkloadClass: types.WorkloadDistributedML,
+		Accelerator:   types.AcceleratorNeuron,
+	}
+
+	script, err := NeuronSetupScript(mpi)
+	require.NoError(t, err)
+	assert.Contains(t, script, "aws-neuronx-collectives")
+	assert.Contains(t, script, "baseurl="+NeuronYumRepoURL)
+	assert.Contains(t, script, EFAInstallerURL)
+	assert.Contains(t, script, "export FI_EFA_USE_DEVICE_RDMA=1")
+
+	// Neuron setup replaces NCCL setup for Trainium training
+	script, err = NCCLSetupScript(mpi)
+	require.NoError(t, err)
+	assert.Empty(t, script)
+	parts, err := ForExecutionPlan(&types.ExecutionPlan{MPIConfig: mpi}, true)
+	require.NoError(t, err)
+	require.Len(t, parts, 1)
+	assert.Equal(t, NeuronSetupFilename, parts[0].Filename)
+
+	// Inferentia has no EFA
+	script, err = NeuronSetupScript(types.MPIConfiguration{Accelerator: types.AcceleratorNeuron})
+	require.NoError(t, err)
+	assert.Contains(t, script, "neuron-ls")
+	assert.NotContains(t, script, EFAInstallerURL)
+	assert.NotContains(t, script, "FI_PROVIDER")
+
+	script, err = NeuronSetupScript(types.MPIConfiguration{})
+	require.NoError(t, err)
+	assert.Empty(t, script)
+}
+
+func TestDetectDistro(t *testing.T) {
+	for name, expected := range map[string]Distro{
+		"al2023-ami-2023.5.20240722.0-kernel-6.1-x86_64":                            DistroAmazonLinux2023,
+		"ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20240701":            DistroUbuntu2204,
+		"Deep Learning Base OSS Nvidia Driver GPU AMI (Ubuntu 22.04) 20240730":      DistroUbuntu2204,
+		"Rocky-9-EC2-Base-9.4-20240523.0.x86_64":                                    DistroRocky9,
+		"Deep Learning Base OSS Nvidia Driver GPU AMI (Amazon Linux 2023) 20240730": DistroAmazonLinux2023,
+	} {
+		distro, err := DetectDistro(name, "")
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, distro, name)
+	}
+
+	distro, err := DetectDistro("site-hpc-image-v7", "Rocky Linux 9 with site packages")
+	require.NoError(t, err)
+	assert.Equal(t, DistroRocky9, distro, "detected from the description")
+
+	_, err = DetectDistro("ubuntu/images/hvm-ssd/ubuntu-focal-20.04-amd64-server", "")
+	assert.Error(t, err)
+	_, err = ParseDistro("centos7")
+	assert.Error(t, err)
+}
+
+func TestNodeBootstrapParts(t *testing.T) {
+	bootstrap := NodeBootstrap{Distro: DistroUbuntu2204, ConfServer: "head-node", EFA: true}
+	parts, err := NodeBootstrapParts(bootstrap)
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	assert.Equal(t, NodeBootstrapFilename, parts[0].Filename)
+	assert.Contains(t, parts[0].Content, "apt-get install -y munge slurmd")
+	assert.Contains(t, parts[0].Content, `"linux-headers-$(uname -r)"`)
+	assert.Equal(t, SlurmdStartFilename, parts[1].Filename)
+	assert.Contains(t, parts[1].Content, "-N $NODE --conf-server head-node")
+	assert.Contains(t, parts[1].Content, "> /etc/default/slurmd")
+
+	bootstrap.Distro, bootstrap.EFA = DistroRocky9, false
+	parts, err = NodeBootstrapParts(bootstrap)
+	require.NoError(t, err)
+	assert.Contains(t, parts[0].Content, "dnf install -y munge slurm-slurmd")
+	assert.NotContains(t, parts[0].Content, "kernel-devel")
+	assert.Contains(t, parts[1].Content, "/etc/sysconfig/slurmd")
+
+	// Amazon Linux 2023 has no Slurm packages
+	bootstrap.Distro = DistroAmazonLinux2023
+	parts, err = NodeBootstrapParts(bootstrap)
+	require.NoError(t, err)
+	assert.Contains(t, parts[0].Content, "set node_bootstrap.install_command")
+	bootstrap.InstallCommand = "rpm -i https://packages.example.edu/slurm-23.11.rpm"
+	parts, err = NodeBootstrapParts(bootstrap)
+	require.NoError(t, err)
+	assert.Contains(t, parts[0].Content, "  rpm -i https://packages.example.edu/slurm-23.11.rpm ||")
+
+	_, err = NodeBootstrapParts(NodeBootstrap{Distro: "windows"})
+	assert.Error(t, err)
+}
+
+func TestMungeKeyScript(t *testing.T) {
+	script, err := MungeKeyScript(MungeKey{Parameter: "/slurm/munge-key"})
+	require.NoError(t, err)
+	assert.Contains(t, script, `--name "/slurm/munge-key" --with-decryption`)
+	assert.Contains(t, script, `-lt 32 ] || [ "$SIZE" -gt 1024`)
+	assert.Contains(t, script, "systemctl try-restart munge")
+	assert.NotContains(t, script, "aws s3 cp")
+
+	script, err = MungeKeyScript(MungeKey{S3URL: "s3://cluster-secrets/munge.key"})
+	require.NoError(t, err)
+	assert.Contains(t, script, `aws s3 cp --only-show-errors "s3://cluster-secrets/munge.key" "$NEW_KEY"`)
+	assert.NotContains(t, script, "ssm get-parameter")
+	assert.Less(t, MungeKeyFilename, SlurmdStartFilename)
+	assert.Greater(t, MungeKeyFilename, NodeBootstrapFilename, "munge is installed first")
+
+	_, err = MungeKeyScript(MungeKey{})
+	assert.Error(t, err)
+
+	assert.NoError(t, CheckMungeKey(make([]byte, 1024)))
+	assert.Error(t, CheckMungeKey([]byte("short")))
+}
+
+func TestNodeMetricsScript(t *testing.T) {
+	script, err := NodeMetricsScript(15, "s3://metrics/cluster-a/")
+	require.NoError(t, err)
+	assert.Contains(t, script, `DESTINATION="s3://metrics/cluster-a"`)
+	assert.Contains(t, script, "ExecStart="+NodeMetricsCollectorPath+" sample")
+	assert.Contains(t, script, "/etc/slurm/epilog.d/50-aws-slurm-burst-node-metrics.sh")
+	assert.Less(t, len(script), MaxUserDataBytes/2)
+
+	_, err = NodeMetricsScript(15, "/shared/$(reboot)")
+	assert.Error(t, err)
+	_, err = NodeMetricsScript(0, "/shared/metrics")
+	assert.Error(t, err)
+}
+
+func TestNodeMetricsCollector_Summarize(t *testing.T) {
+	stateDir := t.TempDir()
+	destination := t.TempDir()
+
+	collector, err := NodeMetricsCollector(10, destination)
+	require.NoError(t, err)
+	collectorPath := filepath.Join(t.TempDir(), "collector")
+	require.NoError(t, os.WriteFile(collectorPath, []byte(collector), 0755))
+
+	// Samples before the job start are ignored
+	samples := "1000 100 1000 1000 500 0 0 0\n" +
+		"1010 600 2000 1000 250 1250000000 0 500000000\n" +
+		"1020 1400 3000 1000 500 2500000000 0 900000000\n"
+	require.NoError(t, os.WriteFile(filepath.Join(stateDir, "samples"), []byte(samples), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(stateDir, "job-42.start"), []byte("1005\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(stateDir, "instance"), []byte("i-0abc c5n.xlarge\n"), 0644))
+
+	cmd := exec.Command(collectorPath, "summarize", "42")
+	cmd.Env = append(os.Environ(), "AWS_SLURM_BURST_METRICS_DIR="+stateDir, "SLURMD_NODENAME=aws-cpu-001")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	data, err := os.ReadFile(filepath.Join(destination, "job-42", "aws-cpu-001.json"))
+	require.NoError(t, err)
+	var summary types.NodeMetricsSummary
+	require.NoError(t, json.Unmarshal(data, &summary))
+
+	assert.Equal(t, "42", summary.JobID)
+	assert.Equal(t, "aws-cpu-001", summary.NodeName)
+	assert.Equal(t, "c5n.xlarge", summary.InstanceType)
+	assert.Equal(t, 2, summary.Samples)
+	assert.InDelta(t, 0.8, summary.CPUUtilization, 0.0001)
+	assert.InDelta(t, 0.625, summary.MemoryUtilization, 0.0001)
+	assert.InDelta(t, 0.75, summary.MemoryPeak, 0.0001)
+	assert.InDelta(t, 1.0, summary.NetworkPeakGbps, 0.001)
+	assert.Equal(t, 400000000.0, summary.NetworkOutBytes, "sent during the job")
+	assert.Equal(t, int64(1005), summary.Start.Unix())
+	assert.NoFileExists(t, filepath.Join(stateDir, "job-42.start"))
+}
+
+func TestCheckpointScript(t *testing.T) {
+	checkpoint := Checkpoint{
+		Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/checkpoints/",
+		GraceSeconds: 90, PollSeconds: 5, Partitions: []string{"aws", "aws-spot"},
+	}
+	script, err := CheckpointScript(checkpoint)
+	require.NoError(t, err)
+	assert.Contains(t, script, `DIRECTORY="/fsx/checkpoints"`)
+	assert.Contains(t, script, `PARTITIONS="aws aws-spot"`)
+	assert.Contains(t, script, `PLAN_JOB=""`)
+	assert.Contains(t, script, "ExecStart="+CheckpointProgramPath+" watch")
+	assert.Contains(t, script, "/etc/slurm/epilog.d/50-aws-slurm-burst-checkpoint.sh")
+	assert.Less(t, len(script), MaxUserDataBytes/2)
+
+	planned := checkpoint
+	planned.PlanJobID, planned.Plan = "1234", &types.CheckpointSpec{Method: types.CheckpointDMTCP}
+	script, err = CheckpointScript(planned)
+	require.NoError(t, err)
+	assert.Contains(t, script, `PLAN_JOB="1234"`)
+	assert.Contains(t, script, `PLAN_METHOD="dmtcp"`)
+
+	tests := []struct {
+		name        string
+		checkpoint  Checkpoint
+		expectError string
+	}{
+		{
+			name: "directory with shell",
+			checkpoint: Checkpoint{
+				Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/$(reboot)", GraceSeconds: 90, PollSeconds: 5,
+			},
+			expectError: `invalid checkpoint directory "/fsx/$(reboot)"`,
+		},
+		{
+			name: "unknown method",
+			checkpoint: Checkpoint{
+				Method: "criu", Signal: "USR1", Directory: "/fsx/checkpoints", GraceSeconds: 90, PollSeconds: 5,
+			},
+			expectError: `invalid checkpoint method "criu"`,
+		},
+		{
+			name: "signal with shell",
+			checkpoint: Checkpoint{
+				Method: types.CheckpointSignal, Signal: "USR1;reboot", Directory: "/fsx/checkpoints", GraceSeconds: 90, PollSeconds: 5,
+			},
+			expectError: `invalid checkpoint signal "USR1;reboot"`,
+		},
+		{
+			name: "plan without job",
+			checkpoint: Checkpoint{
+				Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/checkpoints", GraceSeconds: 90, PollSeconds: 5,
+				Plan: &types.CheckpointSpec{},
+			},
+			expectError: `a checkpointable plan needs the job ID, got ""`,
+		},
+		{
+			name: "no grace",
+			checkpoint: Checkpoint{
+				Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/checkpoints", PollSeconds: 5,
+			},
+			expectError: "checkpoint grace and poll intervals must be positive",
+		},
+		{
+			name: "partition with shell",
+			checkpoint: Checkpoint{
+				Method: types.CheckpointSignal, Signal: "USR1", Directory: "/fsx/checkpoints", GraceSeconds: 90, PollSeconds: 5,
+				Partitions: []string{"aws$(reboot)"},
+			},
+			expectError: `invalid partition name "aws$(reboot)"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CheckpointScript(tt.checkpoint)
+			assert.EqualError(t, err, tt.expectError)
+		})
+	}
+}
+
+func TestCheckpointProgram_Interrupted(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	directory := t.TempDir()
+	bin := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+
+	// Four jobs run on the node: one in a checkpointable partition, one that
+	// opted in through its comment, one the plan declared and one that is
+	// not checkpointable. Jobs are gone by the time squeue is asked again.
+	fakes := map[string]string{
+		"squeue": `case "$*" in
+  *-w*) printf '%s\n' '101|101|aws|' '102|100_2|cpu|sweep asb:checkpoint=command' '103|103|cpu|' '104|104|cpu|' ;;
+  *"-j 105"*) echo '105|105|cpu|asb:checkpoint' ;;
+esac`,
+		"scancel":  `echo "scancel $*" >> "$COMMANDS_LOG"`,
+		"scontrol": `echo "scontrol $*" >> "$COMMANDS_LOG"`,
+		"curl":     `echo '{"action": "terminate", "time": "2026-10-18T12:00:00Z"}'`,
+	}
+	for name, body := range fakes {
+		require.NoError(t, os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/bash\n"+body+"\n"), 0755))
+	}
+
+	program, err := CheckpointProgram(Checkpoint{
+		Method: types.CheckpointSignal, Signal: "USR1", Directory: directory,
+		Command:      `echo "site $SLURM_JOB_ID $ASB_CHECKPOINT_DIR" >> "$COMMANDS_LOG"`,
+		GraceSeconds: 30, PollSeconds: 5, Partitions: []string{"aws"},
+		PlanJobID: "104", Plan: &types.CheckpointSpec{Method: types.CheckpointSignal, Signal: "TERM"},
+	})
+	require.NoError(t, err)
+	programPath := filepath.Join(t.TempDir(), "checkpoint")
+	require.NoError(t, os.WriteFile(programPath, []byte(program), 0755))
+
+	runProgram := func(args ...string) string {
+		cmd := exec.Command(programPath, args...)
+		cmd.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"), "COMMANDS_LOG="+logPath, "SLURMD_NODENAME=aws-cpu-001")
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+		return string(output)
+	}
+
+	output := runProgram("interrupted")
+	assert.Contains(t, output, "Spot interruption warning on aws-cpu-001")
+	logged, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	commands := strings.Split(strings.TrimSpace(string(logged)), "\n")
+	assert.ElementsMatch(t, []string{
+		"scancel --signal=USR1 --full 101",
+		"scontrol requeue 101",
+		"site 102 " + filepath.Join(directory, "job-102"),
+		"scontrol requeue 102",
+		"scancel --signal=TERM --full 104",
+		"scontrol requeue 104",
+	}, commands)
+	assert.FileExists(t, filepath.Join(directory, "job-101", "requeued"))
+	assert.NoDirExists(t, filepath.Join(directory, "job-103"))
+
+	// A requeued job keeps its checkpoint until it finishes after restoring
+	runProgram("epilog", "101")
+	assert.DirExists(t, filepath.Join(directory, "job-101"))
+	runProgram("prolog", "101")
+	runProgram("epilog", "101")
+	assert.NoDirExists(t, filepath.Join(directory, "job-101"))
+
+	runProgram("prolog", "105")
+	assert.DirExists(t, filepath.Join(directory, "job-105"))
+	assert.Equal(t, filepath.Join(directory, "job-105")+"\n", runProgram("dir", "105"))
+}
+
+func TestStagingScript(t *testing.T) {
+	staging := Staging{
+		JobID: "1234", ScratchMount: "/mnt/scratch/", ScratchGB: 200, TransferTimeoutMinutes: 60,
+		StageIn: []types.StagingTransfer{
+			{Source: "s3://bucket/input", Destination: "$DW_JOB_STRIPED/input", Type: types.StagingDirectory},
+			{Source: "/home/alice/params.txt", Destination: "${ASB_SCRATCH}/params.txt", Type: types.StagingFile},
+		},
+		StageOut: []types.StagingTransfer{{Source: "$DW_JOB_PRIVATE/results", Destination: "s3://bucket/results/", Type: types.StagingDirectory}},
+	}
+	script, err := StagingScript(staging)
+	require.NoError(t, err)
+	assert.Contains(t, script, `SCRATCH="/mnt/scratch"`)
+	assert.Contains(t, script, "SCRATCH_BYTES=214748364800")
+	assert.Contains(t, script, `transfer directory "s3://bucket/input" "/mnt/scratch/input" || failed=1`)
+	assert.Contains(t, script, `transfer file "/home/alice/params.txt" "/mnt/scratch/params.txt" || failed=1`)
+	assert.Contains(t, script, `transfer directory "/mnt/scratch/results" "s3://bucket/results/" || failed=1`)
+	assert.Contains(t, script, "timeout 3600 "+StagingProgramPath+" stage-in")
+	assert.Contains(t, script, "/etc/slurm/epilog.d/50-aws-slurm-burst-staging.sh")
+	assert.Less(t, len(script), MaxUserDataBytes/2)
+
+	stageIn := []types.StagingTransfer{{Source: "s3://bucket/input", Destination: "$DW_JOB_STRIPED/input", Type: types.StagingDirectory}}
+	stageOut := []types.StagingTransfer{{Source: "$DW_JOB_PRIVATE/results", Destination: "s3://bucket/results/", Type: types.StagingDirectory}}
+	tests := []struct {
+		name        string
+		staging     Staging
+		expectError string
+	}{
+		{
+			name:        "job ID with shell",
+			staging:     Staging{JobID: "1234;reboot", ScratchMount: "/mnt/scratch", ScratchGB: 200, TransferTimeoutMinutes: 60, StageIn: stageIn},
+			expectError: `invalid staging job ID "1234;reboot"`,
+		},
+		{
+			name:        "root mount",
+			staging:     Staging{JobID: "1234", ScratchMount: "/", ScratchGB: 200, TransferTimeoutMinutes: 60, StageIn: stageIn},
+			expectError: `invalid scratch mount "/"`,
+		},
+		{
+			name: "relative path",
+			staging: Staging{
+				JobID: "1234", ScratchMount: "/mnt/scratch", ScratchGB: 200, TransferTimeoutMinutes: 60,
+				StageIn: []types.StagingTransfer{{Source: "s3://bucket/input", Destination: "input", Type: types.StagingDirectory}},
+			},
+			expectError: `invalid staging path "input": use an s3:// URL or an absolute path`,
+		},
+		{
+			name: "other variable",
+			staging: Staging{
+				JobID: "1234", ScratchMount: "/mnt/scratch", ScratchGB: 200, TransferTimeoutMinutes: 60,
+				StageIn: []types.StagingTransfer{{Source: "s3://bucket/input", Destination: "$HOME/input", Type: types.StagingDirectory}},
+			},
+			expectError: `invalid staging path "$HOME/input": use an s3:// URL or an absolute path`,
+		},
+		{
+			name: "path with quote",
+			staging: Staging{
+				JobID: "1234", ScratchMount: "/mnt/scratch", ScratchGB: 200, TransferTimeoutMinutes: 60, StageIn: stageIn,
+				StageOut: []types.StagingTransfer{{Source: "$DW_JOB_PRIVATE/results", Destination: `s3://bucket/"results`, Type: types.StagingDirectory}},
+			},
+			expectError: `invalid staging path "s3://bucket/\"results": use an s3:// URL or an absolute path`,
+		},
+		{
+			name: "unknown type",
+			staging: Staging{
+				JobID: "1234", ScratchMount: "/mnt/scratch", ScratchGB: 200, TransferTimeoutMinutes: 60, StageIn: stageIn,
+				StageOut: []types.StagingTransfer{{Source: "$DW_JOB_PRIVATE/results", Destination: "s3://bucket/results/", Type: "list"}},
+			},
+			expectError: `invalid staging transfer type "list"`,
+		},
+		{
+			name:        "no transfer budget",
+			staging:     Staging{JobID: "1234", ScratchMount: "/mnt/scratch", ScratchGB: 200, StageIn: stageIn, StageOut: stageOut},
+			expectError: "scratch size cannot be negative and the transfer timeout must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := StagingScript(tt.staging)
+			assert.EqualError(t, err, tt.expectError)
+		})
+	}
+}
+
+func TestStagingProgram(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	scratch := t.TempDir()
+	home := t.TempDir()
+	bin := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "input", "mesh"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "input", "mesh", "grid.dat"), []byte("grid"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(home, "params.txt"), []byte("params"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "scontrol"), []byte("#!/bin/bash\necho 'JobId=77 JobState=COMPLETING BatchHost=aws-cpu-001 NumNodes=2'\n"), 0755))
+
+	program, err := renderStaging(stagingProgramTemplate, Staging{
+		JobID: "77", ScratchMount: scratch, TransferTimeoutMinutes: 5,
+		StageIn: []types.StagingTransfer{
+			{Source: filepath.Join(home, "input"), Destination: "$DW_JOB_STRIPED/input", Type: types.StagingDirectory},
+			{Source: filepath.Join(home, "params.txt"), Destination: "$DW_JOB_STRIPED/", Type: types.StagingFile},
+		},
+		StageOut: []types.StagingTransfer{{Source: "$DW_JOB_STRIPED/input/mesh", Destination: filepath.Join(home, "results"), Type: types.StagingDirectory}},
+	})
+	require.NoError(t, err)
+	programPath := filepath.Join(t.TempDir(), "staging")
+	require.NoError(t, os.WriteFile(programPath, []byte(program), 0755))
+
+	runProgram := func(node string, args ...string) string {
+		cmd := exec.Command(programPath, args...)
+		cmd.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"), "SLURMD_NODENAME="+node)
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+		return string(output)
+	}
+
+	runProgram("aws-cpu-001", "mount")
+	runProgram("aws-cpu-001", "stage-in")
+	assert.FileExists(t, filepath.Join(scratch, "input", "mesh", "grid.dat"))
+	assert.FileExists(t, filepath.Join(scratch, "params.txt"))
+
+	// Only the batch host stages out, and only for the job it was launched for
+	runProgram("aws-cpu-002", "epilog", "77")
+	assert.NoDirExists(t, filepath.Join(home, "results"))
+	runProgram("aws-cpu-001", "epilog", "78")
+	assert.NoDirExists(t, filepath.Join(home, "results"))
+	assert.Contains(t, runProgram("aws-cpu-001", "epilog", "77"), "Staged out job 77")
+	assert.FileExists(t, filepath.Join(home, "results", "grid.dat"))
+}
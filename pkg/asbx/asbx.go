@@ -0,0 +1,118 @@
+// Package asbx embeds aws-slurm-burst in Go services. A Client launches burst
+// nodes for an execution plan, suspends them, and reports their status and
+// cost, the same way the REST API served by aws-slurm-burst-api does:
+//
+//	client, err := asbx.New("/etc/slurm/aws-burst.yaml", asbx.Options{})
+//	err = client.LaunchPlan(ctx, "aws-cpu-[0-3]", plan, asbx.LaunchOptions{})
+//	costs, err := client.Cost(ctx)
+//
+// Launch and suspend run the resume and suspend programs Slurm uses, so nodes
+// embedded services burst behave exactly like power-save ones. Every method
+// honors its context. The exported API follows the module's semantic version.
+package asbx
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/api"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/ecosystem"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/status"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Status is the burst status document: burst nodes, pending launches, costs,
+// recent failures and companion tool state
+type Status = status.Document
+
+// CostSummary estimates the cost of the running burst instances
+type CostSummary = aws.CostSummary
+
+// NodeStatus is a burst node's Slurm state and the instance serving it, if any
+type NodeStatus = api.NodeStatus
+
+// LaunchOptions adjust LaunchPlan
+type LaunchOptions struct {
+	DryRun bool // Plan the launch without starting instances
+}
+
+// SuspendOptions adjust Suspend
+type SuspendOptions struct {
+	Force  bool // Skip draining the nodes
+	DryRun bool
+}
+
+// Options configure a client; the zero value is ready to use
+type Options struct {
+	Logger *zap.Logger // Nil discards log output
+}
+
+// Client drives bursting for one aws-slurm-burst configuration. It is safe for
+// concurrent use.
+type Client struct {
+	backend api.Backend
+}
+
+// New creates a client for the configuration file. It needs the same AWS
+// credentials and Slurm commands as the resume program.
+func New(configPath string, opts Options) (*Client, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	backend, err := api.NewLocalBackend(logger, configPath, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{backend: backend}, nil
+}
+
+// LaunchPlan powers up Slurm nodes (a hostlist such as aws-cpu-[0-3]) on EC2.
+// A nil plan launches from the node group configuration in standalone mode.
+// Plans with a schema this release cannot execute are refused before anything
+// runs.
+func (c *Client) LaunchPlan(ctx context.Context, nodes string, plan *types.ExecutionPlan, opts LaunchOptions) error {
+	if nodes == "" {
+		return fmt.Errorf("no nodes to launch")
+	}
+	if plan != nil {
+		if _, err := ecosystem.NegotiatePlan(plan); err != nil {
+			return err
+		}
+	}
+	return c.backend.Launch(ctx, &api.LaunchRequest{Nodes: nodes, ExecutionPlan: plan, DryRun: opts.DryRun})
+}
+
+// Suspend powers down Slurm nodes, draining them first unless forced, and
+// releases their instances
+func (c *Client) Suspend(ctx context.Context, nodes string, opts SuspendOptions) error {
+	if nodes == "" {
+		return fmt.Errorf("no nodes to suspend")
+	}
+	return c.backend.Terminate(ctx, &api.TerminateRequest{Nodes: nodes, Force: opts.Force, DryRun: opts.DryRun})
+}
+
+// Status collects the burst status document. Sections that cannot be collected
+// are listed in its Errors rather than failing the call.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	return c.backend.Status(ctx)
+}
+
+// Nodes reports every configured burst node with its Slurm state and instance
+func (c *Client) Nodes(ctx context.Context) ([]NodeStatus, error) {
+	return c.backend.Nodes(ctx)
+}
+
+// Cost estimates the run rate and accrued cost of the running burst instances
+func (c *Client) Cost(ctx context.Context) (*CostSummary, error) {
+	return c.backend.Costs(ctx)
+}
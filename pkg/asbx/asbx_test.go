@@ -0,0 +1,85 @@
+package asbx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/api"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/status"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// fakeBackend records the requests it receives
+type fakeBackend struct {
+	launched   *api.LaunchRequest
+	terminated *api.TerminateRequest
+}
+
+func (b *fakeBackend) Launch(ctx context.Context, req *api.LaunchRequest) error {
+	b.launched = req
+	return nil
+}
+
+func (b *fakeBackend) Terminate(ctx context.Context, req *api.TerminateRequest) error {
+	b.terminated = req
+	return nil
+}
+
+func (b *fakeBackend) Nodes(ctx context.Context) ([]api.NodeStatus, error) {
+	return []api.NodeStatus{{Node: "aws-cpu-0", State: "IDLE+CLOUD"}}, nil
+}
+
+func (b *fakeBackend) Costs(ctx context.Context) (*aws.CostSummary, error) {
+	return &aws.CostSummary{Instances: 2, HourlyUSD: 0.384}, nil
+}
+
+func (b *fakeBackend) Validate(ctx context.Context, req *api.ValidateRequest) (*api.ValidationReport, error) {
+	return &api.ValidationReport{ConfigValid: true}, nil
+}
+
+func (b *fakeBackend) Status(ctx context.Context) (*status.Document, error) {
+	return &status.Document{PendingJobs: 3}, nil
+}
+
+func TestLaunchPlan(t *testing.T) {
+	backend := &fakeBackend{}
+	client := &Client{backend: backend}
+	ctx := context.Background()
+
+	plan := &types.ExecutionPlan{SchemaVersion: types.PlanSchemaVersion, ShouldBurst: true}
+	require.NoError(t, client.LaunchPlan(ctx, "aws-cpu-[0-3]", plan, LaunchOptions{DryRun: true}))
+	assert.Equal(t, &api.LaunchRequest{Nodes: "aws-cpu-[0-3]", ExecutionPlan: plan, DryRun: true}, backend.launched)
+
+	backend.launched = nil
+	err := client.LaunchPlan(ctx, "aws-cpu-0", &types.ExecutionPlan{SchemaVersion: "2.0"}, LaunchOptions{})
+	assert.ErrorContains(t, err, "upgrade aws-slurm-burst")
+	assert.Nil(t, backend.launched, "incompatible plans never reach resume")
+
+	assert.Error(t, client.LaunchPlan(ctx, "", nil, LaunchOptions{}))
+}
+
+func TestSuspendStatusAndCost(t *testing.T) {
+	backend := &fakeBackend{}
+	client := &Client{backend: backend}
+	ctx := context.Background()
+
+	require.NoError(t, client.Suspend(ctx, "aws-cpu-[0-1]", SuspendOptions{Force: true}))
+	assert.Equal(t, &api.TerminateRequest{Nodes: "aws-cpu-[0-1]", Force: true}, backend.terminated)
+	assert.Error(t, client.Suspend(ctx, "", SuspendOptions{}))
+
+	doc, err := client.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, doc.PendingJobs)
+
+	nodes, err := client.Nodes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "aws-cpu-0", nodes[0].Node)
+
+	costs, err := client.Cost(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, costs.Instances)
+}
@@ -0,0 +1,73 @@
+package mpidetect
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// PatternDetector flags jobs whose script or name matches any of a set of
+// regular expressions. It backs config-defined detectors for site-specific
+// MPI wrapper scripts.
+type PatternDetector struct {
+	name       string
+	patterns   []*regexp.Regexp
+	confidence float64
+	topology   types.NetworkTopology
+}
+
+// NewPatternDetector compiles a pattern detector. Patterns are matched
+// case-insensitively against the job script and job name.
+func NewPatternDetector(name string, patterns []string, confidence float64, topology types.NetworkTopology) (*PatternDetector, error) {
+	if name == "" {
+		return nil, fmt.Errorf("pattern detector requires a name")
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("pattern detector %q requires at least one pattern", name)
+	}
+	if confidence <= 0 || confidence > 1 {
+		return nil, fmt.Errorf("pattern detector %q confidence must be in (0, 1]", name)
+	}
+	if topology == "" {
+		topology = types.TopologyCluster
+	}
+
+	detector := &PatternDetector{name: name, confidence: confidence, topology: topology}
+	for _, pattern := range patterns {
+		compiled, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern detector %q has invalid pattern %q: %w", name, pattern, err)
+		}
+		detector.patterns = append(detector.patterns, compiled)
+	}
+
+	return detector, nil
+}
+
+func (p *PatternDetector) Name() string { return p.name }
+
+func (p *PatternDetector) Detect(job *types.SlurmJob) (bool, float64) {
+	for _, pattern := range p.patterns {
+		if pattern.MatchString(job.Script) || pattern.MatchString(job.Name) {
+			return true, p.confidence
+		}
+	}
+	return false, 0.0
+}
+
+func (p *PatternDetector) RequiredTopology(job *types.SlurmJob) types.NetworkTopology {
+	return p.topology
+}
+
+// ParseTopology converts a configured topology name into a NetworkTopology
+func ParseTopology(value string) (types.NetworkTopology, error) {
+	switch types.NetworkTopology(strings.ToLower(value)) {
+	case "":
+		return "", nil
+	case types.TopologyCluster, types.TopologySpread, types.TopologyPartition, types.TopologyAny:
+		return types.NetworkTopology(strings.ToLower(value)), nil
+	}
+	return "", fmt.Errorf("invalid topology %q (must be cluster, spread, partition or any)", value)
+}
@@ -0,0 +1,87 @@
+// Package mpidetect lets sites plug their own MPI job detectors into the
+// aws-slurm-burst scheduler without modifying it.
+//
+// A detector registered from an init function in a site-specific build is
+// picked up by every scheduler created afterwards:
+//
+//	func init() {
+//		mpidetect.MustRegister(&siteWrapperDetector{})
+//	}
+package mpidetect
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// Detector identifies MPI jobs from their Slurm metadata
+type Detector interface {
+	Name() string
+	Detect(job *types.SlurmJob) (bool, float64) // returns (isMPI, confidence)
+	RequiredTopology(job *types.SlurmJob) types.NetworkTopology
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Detector)
+	order      []string
+)
+
+// Register adds a detector to the global registry. Names must be unique.
+func Register(detector Detector) error {
+	if detector == nil || detector.Name() == "" {
+		return fmt.Errorf("detector must have a name")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[detector.Name()]; exists {
+		return fmt.Errorf("detector %q is already registered", detector.Name())
+	}
+
+	registry[detector.Name()] = detector
+	order = append(order, detector.Name())
+	return nil
+}
+
+// MustRegister is like Register but panics on error, for use in init functions
+func MustRegister(detector Detector) {
+	if err := Register(detector); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns a registered detector by name
+func Lookup(name string) (Detector, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	detector, ok := registry[name]
+	return detector, ok
+}
+
+// Registered returns all registered detectors in registration order
+func Registered() []Detector {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	detectors := make([]Detector, 0, len(order))
+	for _, name := range order {
+		detectors = append(detectors, registry[name])
+	}
+	return detectors
+}
+
+// Names returns the names of all registered detectors, sorted
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := append([]string{}, order...)
+	sort.Strings(names)
+	return names
+}
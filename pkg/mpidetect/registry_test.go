@@ -0,0 +1,43 @@
+package mpidetect
+
+import (
+	"testing"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	detector, err := NewPatternDetector("registry_test", []string{"site-mpirun"}, 0.8, "")
+	require.NoError(t, err)
+
+	require.NoError(t, Register(detector))
+	assert.Error(t, Register(detector), "duplicate names are rejected")
+
+	found, ok := Lookup("registry_test")
+	require.True(t, ok)
+	assert.Equal(t, detector, found)
+	assert.Contains(t, Names(), "registry_test")
+	assert.Contains(t, Registered(), Detector(detector))
+}
+
+func TestPatternDetector(t *testing.T) {
+	detector, err := NewPatternDetector("site_wrapper", []string{`run_parallel\s+-n`}, 0.9, types.TopologySpread)
+	require.NoError(t, err)
+
+	isMPI, confidence := detector.Detect(&types.SlurmJob{Script: "#!/bin/bash\nRUN_PARALLEL -n 64 ./model"})
+	assert.True(t, isMPI)
+	assert.Equal(t, 0.9, confidence)
+	assert.Equal(t, types.TopologySpread, detector.RequiredTopology(nil))
+
+	isMPI, _ = detector.Detect(&types.SlurmJob{Script: "#!/bin/bash\n./serial"})
+	assert.False(t, isMPI)
+
+	_, err = NewPatternDetector("bad", []string{"("}, 0.9, "")
+	assert.Error(t, err)
+	_, err = NewPatternDetector("bad", []string{"x"}, 1.5, "")
+	assert.Error(t, err)
+	_, err = NewPatternDetector("", []string{"x"}, 0.5, "")
+	assert.Error(t, err)
+}
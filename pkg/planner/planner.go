@@ -0,0 +1,234 @@
+// Package planner builds execution plans in process, so ASBA and other Go tools
+// can produce the plans aws-slurm-burst-resume executes without writing JSON
+// and running a subprocess:
+//
+//	p, err := planner.NewFromConfig("/etc/slurm/aws-burst.yaml", planner.Options{})
+//	target, err := p.TargetFor("aws", "cpu")
+//	plan, err := p.Plan(ctx, job, target)
+//
+// Jobs are analyzed with the same MPI detectors and instance family selection
+// as aws-slurm-burst itself, including detectors registered through
+// pkg/mpidetect. The exported API follows the module's semantic version: it
+// only changes incompatibly in a new major version, and plans always carry
+// types.PlanSchemaVersion.
+package planner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/scheduler"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// defaultDurationHours is the expected runtime of jobs without a time limit
+const defaultDurationHours = 24
+
+// Target is where a job's nodes launch, usually a node group from the
+// aws-slurm-burst configuration
+type Target struct {
+	InstanceTypes      []string // Candidates; MPI jobs keep those in the selected families
+	PurchasingOption   string   // "spot", "on-demand" or "mixed"
+	SubnetIDs          []string
+	SecurityGroupIDs   []string
+	LaunchTemplateName string
+	LaunchTemplateID   string
+	IAMInstanceProfile string
+	Architecture       string  // "x86_64" or "arm64"; empty infers it from the instance types
+	MaxSpotPrice       float64 // Per hour; zero leaves it to EC2
+}
+
+// Options tune a planner; the zero value is ready to use
+type Options struct {
+	Logger *zap.Logger      // Nil discards log output
+	Now    func() time.Time // Nil uses time.Now
+}
+
+// Planner turns Slurm jobs into execution plans
+type Planner struct {
+	logger    *zap.Logger
+	now       func() time.Time
+	scheduler *scheduler.MPIScheduler
+	config    *config.Config
+}
+
+// New creates a planner using every registered MPI detector at full weight
+func New(opts Options) *Planner {
+	p := newPlanner(opts)
+	p.scheduler = scheduler.NewMPIScheduler(p.logger)
+	return p
+}
+
+// NewFromConfig creates a planner from an aws-slurm-burst configuration file.
+// MPI detection follows its mpi section and TargetFor reads its node groups.
+func NewFromConfig(configPath string, opts Options) (*Planner, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newPlanner(opts)
+	p.config = cfg
+	if p.scheduler, err = scheduler.NewMPISchedulerFromConfig(p.logger, &cfg.MPI); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newPlanner(opts Options) *Planner {
+	p := &Planner{logger: opts.Logger, now: opts.Now}
+	if p.logger == nil {
+		p.logger = zap.NewNop()
+	}
+	if p.now == nil {
+		p.now = time.Now
+	}
+	return p
+}
+
+// TargetFor returns the launch target of a configured node group. It needs a
+// planner created by NewFromConfig.
+func (p *Planner) TargetFor(partition, nodeGroup string) (Target, error) {
+	if p.config == nil {
+		return Target{}, fmt.Errorf("planner has no configuration; use NewFromConfig")
+	}
+	group := p.config.FindNodeGroup(partition, nodeGroup)
+	if group == nil {
+		return Target{}, fmt.Errorf("no configuration found for partition '%s' nodegroup '%s'", partition, nodeGroup)
+	}
+
+	target := Target{
+		PurchasingOption:   group.PurchasingOption,
+		SubnetIDs:          group.SubnetIds,
+		SecurityGroupIDs:   group.SecurityGroupIds,
+		LaunchTemplateName: group.LaunchTemplateSpec.LaunchTemplateName,
+		LaunchTemplateID:   group.LaunchTemplateSpec.LaunchTemplateID,
+		IAMInstanceProfile: group.IAMInstanceProfile,
+		Architecture:       group.EffectiveArchitecture(),
+	}
+	for _, override := range group.LaunchTemplateOverrides {
+		target.InstanceTypes = append(target.InstanceTypes, override.InstanceType)
+	}
+	return target, nil
+}
+
+// Plan analyzes job and returns a validated plan for launching it on target.
+// The job is not modified.
+func (p *Planner) Plan(ctx context.Context, job *types.SlurmJob, target Target) (*types.ExecutionPlan, error) {
+	if job.Resources.Nodes <= 0 || job.Resources.CPUsPerNode <= 0 {
+		return nil, fmt.Errorf("job %s needs positive node and CPU counts", job.JobID)
+	}
+
+	analyzed := *job
+	if err := p.scheduler.AnalyzeJob(ctx, &analyzed); err != nil {
+		return nil, fmt.Errorf("failed to analyze job %s: %w", job.JobID, err)
+	}
+	req := p.scheduler.DetermineInstanceRequirements(&analyzed)
+
+	architecture := target.Architecture
+	if req.Architecture != "" {
+		if architecture != "" && architecture != req.Architecture {
+			return nil, fmt.Errorf("job %s requires %s but the target is %s", job.JobID, req.Architecture, architecture)
+		}
+		architecture = req.Architecture
+	}
+
+	factors := []string{"planner"}
+	instanceTypes := target.InstanceTypes
+	if len(req.InstanceFamilies) > 0 {
+		instanceTypes = inFamilies(target.InstanceTypes, req.InstanceFamilies)
+		factors = append(factors, "mpi_instance_families")
+	}
+
+	durationHours := time.Duration(job.TimeLimit).Hours()
+	if durationHours <= 0 {
+		durationHours = defaultDurationHours
+	}
+	maxSpotPrice := target.MaxSpotPrice
+	if job.Constraints.MaxSpotPrice > 0 && (maxSpotPrice == 0 || job.Constraints.MaxSpotPrice < maxSpotPrice) {
+		maxSpotPrice = job.Constraints.MaxSpotPrice
+	}
+
+	plan := &types.ExecutionPlan{
+		SchemaVersion: types.PlanSchemaVersion,
+		ShouldBurst:   true,
+		InstanceSpec: types.InstanceSpecification{
+			InstanceTypes:      instanceTypes,
+			PurchasingOption:   target.PurchasingOption,
+			MaxSpotPrice:       maxSpotPrice,
+			SubnetIds:          target.SubnetIDs,
+			LaunchTemplateName: target.LaunchTemplateName,
+			LaunchTemplateID:   target.LaunchTemplateID,
+			SecurityGroupIds:   target.SecurityGroupIDs,
+			IAMInstanceProfile: target.IAMInstanceProfile,
+			Architecture:       architecture,
+			MinCPUs:            job.Resources.CPUsPerNode,
+			MinMemoryMB:        job.Resources.NodeMemoryMB(),
+			GPUs:               job.Resources.GPUs,
+		},
+		MPIConfig: types.MPIConfiguration{
+			IsMPIJob:               analyzed.IsMPIJob,
+			ProcessCount:           analyzed.MPIProcesses,
+			RequiresGangScheduling: analyzed.IsMPIJob && job.Resources.Nodes > 1,
+			RequiresEFA:            req.RequiresEFA,
+			WorkloadClass:          analyzed.WorkloadClass,
+			Accelerator:            analyzed.Accelerator,
+		},
+		CostConstraints: types.CostConstraints{
+			MaxDurationHours: durationHours,
+			PreferSpot:       target.PurchasingOption == "spot" || req.PreferSpot,
+		},
+		NetworkConfig: types.NetworkConfiguration{
+			PlacementGroupType: req.PlacementGroupType,
+			EnhancedNetworking: true,
+			SingleAZRequired:   req.PlacementGroupType == "cluster",
+		},
+		ExecutionMetadata: types.ExecutionMetadata{
+			JobID:             job.JobID,
+			Priority:          "normal",
+			AnalysisTimestamp: p.now().UTC(),
+			DecisionFactors:   factors,
+		},
+	}
+	if analyzed.IsMPIJob && plan.NetworkConfig.PlacementGroupType == "" {
+		// Single-node MPI jobs still need a group to pass plan validation
+		plan.NetworkConfig.PlacementGroupType = "cluster"
+	}
+	if job.Constraints.AvailabilityZone != "" {
+		plan.NetworkConfig.AvailabilityZones = []string{job.Constraints.AvailabilityZone}
+	}
+
+	if err := plan.ValidateExecutionPlan(); err != nil {
+		return nil, fmt.Errorf("plan for job %s is not executable: %w", job.JobID, err)
+	}
+
+	p.logger.Debug("Planned job",
+		zap.String("job_id", job.JobID),
+		zap.Bool("mpi_job", analyzed.IsMPIJob),
+		zap.Strings("instance_types", instanceTypes))
+	return plan, nil
+}
+
+// inFamilies keeps the candidates in the preferred families. Without candidates,
+// or when none match, the families themselves are returned for the fleet to size.
+func inFamilies(candidates, families []string) []string {
+	allowed := make(map[string]bool, len(families))
+	for _, family := range families {
+		allowed[family] = true
+	}
+
+	var kept []string
+	for _, instanceType := range candidates {
+		if allowed[types.InstanceFamilyOf(instanceType)] {
+			kept = append(kept, instanceType)
+		}
+	}
+	if len(kept) == 0 {
+		return families
+	}
+	return kept
+}
@@ -0,0 +1,89 @@
+package planner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+func testTarget() Target {
+	return Target{
+		InstanceTypes:      []string{"m6i.4xlarge", "c6i.8xlarge", "hpc7a.96xlarge"},
+		PurchasingOption:   "spot",
+		SubnetIDs:          []string{"subnet-1"},
+		LaunchTemplateName: "burst",
+		MaxSpotPrice:       2.0,
+	}
+}
+
+func TestPlanMPIJob(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	p := New(Options{Now: func() time.Time { return now }})
+
+	job := &types.SlurmJob{
+		JobID:       "1234",
+		Script:      "#!/bin/bash\nmpiexec -n 128 ./app",
+		Resources:   types.ResourceSpec{Nodes: 16, CPUsPerNode: 8, MemoryMB: 8192},
+		TimeLimit:   types.Duration(2 * time.Hour),
+		Constraints: types.JobConstraints{MaxSpotPrice: 1.5},
+	}
+	plan, err := p.Plan(context.Background(), job, testTarget())
+	require.NoError(t, err)
+
+	assert.Equal(t, types.PlanSchemaVersion, plan.SchemaVersion)
+	assert.Equal(t, []string{"c6i.8xlarge", "hpc7a.96xlarge"}, plan.InstanceSpec.InstanceTypes, "only MPI families are kept")
+	assert.True(t, plan.MPIConfig.IsMPIJob)
+	assert.True(t, plan.MPIConfig.RequiresGangScheduling)
+	assert.True(t, plan.MPIConfig.RequiresEFA)
+	assert.Equal(t, "cluster", plan.NetworkConfig.PlacementGroupType)
+	assert.Equal(t, 1.5, plan.InstanceSpec.MaxSpotPrice, "the job's lower limit wins")
+	assert.Equal(t, 2.0, plan.CostConstraints.MaxDurationHours)
+	assert.Equal(t, now, plan.ExecutionMetadata.AnalysisTimestamp)
+	assert.False(t, job.IsMPIJob, "the caller's job is not modified")
+}
+
+func TestPlanStandardJob(t *testing.T) {
+	job := &types.SlurmJob{
+		JobID:     "77",
+		Script:    "#!/bin/bash\n./sequential_app",
+		Resources: types.ResourceSpec{Nodes: 1, CPUsPerNode: 4},
+	}
+	plan, err := New(Options{}).Plan(context.Background(), job, testTarget())
+	require.NoError(t, err)
+
+	assert.Equal(t, testTarget().InstanceTypes, plan.InstanceSpec.InstanceTypes)
+	assert.False(t, plan.MPIConfig.IsMPIJob)
+	assert.Empty(t, plan.NetworkConfig.PlacementGroupType)
+	assert.Equal(t, float64(defaultDurationHours), plan.CostConstraints.MaxDurationHours)
+}
+
+func TestPlanErrors(t *testing.T) {
+	p := New(Options{})
+	ctx := context.Background()
+
+	_, err := p.Plan(ctx, &types.SlurmJob{JobID: "1"}, testTarget())
+	assert.ErrorContains(t, err, "positive node and CPU counts")
+
+	target := testTarget()
+	target.SubnetIDs = nil
+	_, err = p.Plan(ctx, &types.SlurmJob{JobID: "2", Resources: types.ResourceSpec{Nodes: 1, CPUsPerNode: 2}}, target)
+	assert.ErrorContains(t, err, "no subnet IDs")
+
+	target = testTarget()
+	target.Architecture = "x86_64"
+	_, err = p.Plan(ctx, &types.SlurmJob{
+		JobID:       "3",
+		Script:      "#!/bin/bash\nmpirun ./app",
+		Resources:   types.ResourceSpec{Nodes: 8, CPUsPerNode: 16, MemoryMB: 32768},
+		Constraints: types.JobConstraints{Features: []string{"graviton"}},
+	}, target)
+	assert.ErrorContains(t, err, "requires arm64")
+
+	_, err = p.TargetFor("aws", "cpu")
+	assert.ErrorContains(t, err, "NewFromConfig")
+}
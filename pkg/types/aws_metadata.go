@@ -0,0 +1,299 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// AWSMetadataPrefix marks burst metadata embedded in a Slurm job comment
+const AWSMetadataPrefix = "aws_meta:"
+
+// awsMetadataChunk matches one chunk of metadata too large for a single comment
+// field, e.g. "aws_meta.2/3:...", which runs to the end of its field
+var awsMetadataChunk = regexp.MustCompile(`aws_meta\.(\d+)/(\d+):(.*)$`)
+
+// AWSJobMetadata is the placement record kept on a Slurm job so the performance
+// export can recover where each node actually ran after the instances are gone
+type AWSJobMetadata struct {
+	Instances             []string          `json:"instances,omitempty"` // Instance types used
+	Cost                  float64           `json:"cost,omitempty"`
+	EFA                   bool              `json:"efa,omitempty"`
+	NodeAvailabilityZones map[string]string `json:"node_azs,omitempty"`       // Node name -> AZ
+	SpotPlacementScores   map[string]int    `json:"spot_scores,omitempty"`    // AZ -> spot placement score at launch
+	Fallbacks             []LaunchFallback  `json:"fallbacks,omitempty"`      // Retries after capacity failures
+	FleetID               string            `json:"fleet_id,omitempty"`       // Comma-separated when several fleets launched
+	ExecutionMode         string            `json:"execution_mode,omitempty"` // "standalone" or "asba"
+	TraceID               string            `json:"trace_id,omitempty"`       // Links resume, prolog and export logs
+	Truncated             bool              `json:"truncated,omitempty"`      // Detail dropped to fit the comment size limit
+}
+
+// NewAWSJobMetadata builds job metadata from launched instances
+func NewAWSJobMetadata(instances []InstanceInfo) *AWSJobMetadata {
+	meta := &AWSJobMetadata{NodeAvailabilityZones: make(map[string]string)}
+	seen := make(map[string]bool)
+
+	for _, instance := range instances {
+		if instance.InstanceType != "" && !seen[instance.InstanceType] {
+			seen[instance.InstanceType] = true
+			meta.Instances = append(meta.Instances, instance.InstanceType)
+		}
+		if instance.AvailabilityZone != "" {
+			meta.NodeAvailabilityZones[instance.NodeName] = instance.AvailabilityZone
+		}
+	}
+
+	return meta
+}
+
+// String formats the metadata as an "aws_meta:{...}" comment
+func (m *AWSJobMetadata) String() string {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return AWSMetadataPrefix + "{}"
+	}
+	return AWSMetadataPrefix + string(data)
+}
+
+// ParseAWSJobMetadata extracts burst metadata from a Slurm job comment. The
+// metadata may follow other comment text but must run to the end of the comment.
+func ParseAWSJobMetadata(comment string) (*AWSJobMetadata, error) {
+	idx := strings.Index(comment, AWSMetadataPrefix)
+	if idx < 0 {
+		return nil, fmt.Errorf("comment contains no %s metadata", strings.TrimSuffix(AWSMetadataPrefix, ":"))
+	}
+
+	var meta AWSJobMetadata
+	if err := json.Unmarshal([]byte(comment[idx+len(AWSMetadataPrefix):]), &meta); err != nil {
+		return nil, fmt.Errorf("invalid AWS job metadata: %w", err)
+	}
+
+	return &meta, nil
+}
+
+// Merge adds other's placement to m: instance types, node AZs and spot scores are
+// combined, and distinct fleet and trace IDs are joined with commas. Fields already
+// set on m take precedence.
+func (m *AWSJobMetadata) Merge(other *AWSJobMetadata) {
+	if other == nil {
+		return
+	}
+
+	for _, instanceType := range other.Instances {
+		if !containsString(m.Instances, instanceType) {
+			m.Instances = append(m.Instances, instanceType)
+		}
+	}
+	for node, az := range other.NodeAvailabilityZones {
+		if m.NodeAvailabilityZones == nil {
+			m.NodeAvailabilityZones = make(map[string]string)
+		}
+		if _, ok := m.NodeAvailabilityZones[node]; !ok {
+			m.NodeAvailabilityZones[node] = az
+		}
+	}
+	for az, score := range other.SpotPlacementScores {
+		if m.SpotPlacementScores == nil {
+			m.SpotPlacementScores = make(map[string]int)
+		}
+		if _, ok := m.SpotPlacementScores[az]; !ok {
+			m.SpotPlacementScores[az] = score
+		}
+	}
+	if len(m.Fallbacks) == 0 {
+		m.Fallbacks = other.Fallbacks
+	}
+	if m.Cost == 0 {
+		m.Cost = other.Cost
+	}
+	m.EFA = m.EFA || other.EFA
+	m.FleetID = joinDistinct(m.FleetID, other.FleetID)
+	m.TraceID = joinDistinct(m.TraceID, other.TraceID)
+	if m.ExecutionMode == "" {
+		m.ExecutionMode = other.ExecutionMode
+	}
+}
+
+// WithAWSJobMetadata returns comment with its burst metadata replaced by meta,
+// keeping any text before it
+func WithAWSJobMetadata(comment string, meta *AWSJobMetadata) string {
+	if comment = AWSMetadataText(comment); comment == "" {
+		return meta.String()
+	}
+	return comment + " " + meta.String()
+}
+
+// EncodeAWSJobMetadata lays meta out over up to fields comment fields of at most
+// maxBytes each, with text (existing comment text) kept ahead of it in the first.
+// Metadata that fits goes into the first field as "aws_meta:{...}". Larger
+// metadata is split into "aws_meta.i/n:" chunks, one per field. If it does not
+// fit even then, fallbacks, spot scores and node AZs are dropped in that order
+// and the result is marked truncated.
+func EncodeAWSJobMetadata(meta *AWSJobMetadata, text string, maxBytes, fields int) ([]string, error) {
+	if text = AWSMetadataText(text); text != "" {
+		text += " "
+	}
+	if fields < 1 {
+		fields = 1
+	}
+
+	trimmed := *meta
+	drops := []func(){
+		func() { trimmed.Fallbacks = nil },
+		func() { trimmed.SpotPlacementScores = nil },
+		func() { trimmed.NodeAvailabilityZones = nil },
+	}
+	for i := 0; ; i++ {
+		data, err := json.Marshal(&trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode AWS job metadata: %w", err)
+		}
+		if values := chunkAWSMetadata(string(data), text, maxBytes, fields); values != nil {
+			return values, nil
+		}
+		if i == len(drops) {
+			return nil, fmt.Errorf("AWS job metadata needs more than %d bytes in %d field(s)", maxBytes, fields)
+		}
+		drops[i]()
+		trimmed.Truncated = true
+	}
+}
+
+// chunkAWSMetadata returns the field values for payload, or nil if it needs more fields
+func chunkAWSMetadata(payload, text string, maxBytes, fields int) []string {
+	if len(text)+len(AWSMetadataPrefix)+len(payload) <= maxBytes {
+		return []string{text + AWSMetadataPrefix + payload}
+	}
+
+	// Chunk headers are sized for the total chunk count, which is at most fields
+	header := len(fmt.Sprintf("aws_meta.%d/%d:", fields, fields))
+	if fields < 2 || len(text)+header >= maxBytes {
+		return nil
+	}
+
+	var values []string
+	for rest, n := payload, 0; rest != ""; n++ {
+		room := maxBytes - header
+		if n == 0 {
+			room -= len(text)
+		}
+		if room >= len(rest) {
+			room = len(rest)
+		} else {
+			for room > 0 && !utf8.RuneStart(rest[room]) {
+				room--
+			}
+		}
+		values = append(values, rest[:room])
+		rest = rest[room:]
+	}
+	if len(values) > fields {
+		return nil
+	}
+
+	for i := range values {
+		values[i] = fmt.Sprintf("aws_meta.%d/%d:%s", i+1, len(values), values[i])
+	}
+	values[0] = text + values[0]
+	return values
+}
+
+// JoinAWSJobMetadata reassembles metadata written by EncodeAWSJobMetadata from the
+// values of the fields it was written to, in order. The result keeps the first
+// field's comment text and can be read with ParseAWSJobMetadata. Without a complete
+// set of chunks, the first value holding whole metadata is returned.
+func JoinAWSJobMetadata(values ...string) string {
+	chunks := make(map[int]string)
+	total := 0
+	text := ""
+	for _, value := range values {
+		match := awsMetadataChunk.FindStringSubmatchIndex(value)
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(value[match[2]:match[3]])
+		count, _ := strconv.Atoi(value[match[4]:match[5]])
+		if total == 0 {
+			total = count
+		}
+		if count == total && index >= 1 && index <= total {
+			chunks[index] = value[match[6]:match[7]]
+		}
+		if index == 1 {
+			text = value[:match[0]]
+		}
+	}
+
+	if total > 0 && len(chunks) == total {
+		indexes := make([]int, 0, total)
+		for index := range chunks {
+			indexes = append(indexes, index)
+		}
+		sort.Ints(indexes)
+
+		var payload strings.Builder
+		for _, index := range indexes {
+			payload.WriteString(chunks[index])
+		}
+		return text + AWSMetadataPrefix + payload.String()
+	}
+
+	for _, value := range values {
+		if strings.Contains(value, AWSMetadataPrefix) {
+			return value
+		}
+	}
+	if len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// AWSMetadataText returns a comment without its burst metadata or metadata chunk
+func AWSMetadataText(comment string) string {
+	if idx := strings.Index(comment, AWSMetadataPrefix); idx >= 0 {
+		comment = comment[:idx]
+	}
+	if loc := awsMetadataChunk.FindStringIndex(comment); loc != nil {
+		comment = comment[:loc[0]]
+	}
+	comment = strings.TrimSpace(comment)
+	if comment == "(null)" {
+		return ""
+	}
+	return comment
+}
+
+// joinDistinct appends the comma-separated IDs in add that are not already in ids
+func joinDistinct(ids, add string) string {
+	existing := splitIDs(ids)
+	for _, id := range splitIDs(add) {
+		if !containsString(existing, id) {
+			existing = append(existing, id)
+		}
+	}
+	return strings.Join(existing, ",")
+}
+
+func splitIDs(ids string) []string {
+	var result []string
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
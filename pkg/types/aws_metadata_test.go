@@ -0,0 +1,110 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSJobMetadata_RoundTrip(t *testing.T) {
+	meta := NewAWSJobMetadata([]InstanceInfo{
+		{NodeName: "aws-cpu-001", InstanceType: "c5n.xlarge", AvailabilityZone: "us-east-1a"},
+		{NodeName: "aws-cpu-002", InstanceType: "c5n.xlarge", AvailabilityZone: "us-east-1b"},
+	})
+	assert.Equal(t, []string{"c5n.xlarge"}, meta.Instances)
+
+	parsed, err := ParseAWSJobMetadata("user note " + meta.String())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"aws-cpu-001": "us-east-1a",
+		"aws-cpu-002": "us-east-1b",
+	}, parsed.NodeAvailabilityZones)
+
+	_, err = ParseAWSJobMetadata("no metadata here")
+	assert.Error(t, err)
+}
+
+func TestAWSJobMetadata_Merge(t *testing.T) {
+	meta := &AWSJobMetadata{
+		Instances:           []string{"c5n.xlarge"},
+		SpotPlacementScores: map[string]int{"us-east-1a": 9},
+		FleetID:             "fleet-1",
+	}
+	meta.Merge(&AWSJobMetadata{
+		Instances:             []string{"c5n.xlarge", "c5n.2xlarge"},
+		NodeAvailabilityZones: map[string]string{"aws-cpu-001": "us-east-1a"},
+		SpotPlacementScores:   map[string]int{"us-east-1a": 3},
+		FleetID:               "fleet-1,fleet-2",
+		ExecutionMode:         "asba",
+		TraceID:               "abc123",
+	})
+	meta.Merge(nil)
+
+	assert.Equal(t, []string{"c5n.xlarge", "c5n.2xlarge"}, meta.Instances)
+	assert.Equal(t, map[string]string{"aws-cpu-001": "us-east-1a"}, meta.NodeAvailabilityZones)
+	assert.Equal(t, 9, meta.SpotPlacementScores["us-east-1a"])
+	assert.Equal(t, "fleet-1,fleet-2", meta.FleetID)
+	assert.Equal(t, "asba", meta.ExecutionMode)
+	assert.Equal(t, "abc123", meta.TraceID)
+}
+
+func TestWithAWSJobMetadata(t *testing.T) {
+	meta := &AWSJobMetadata{TraceID: "abc123"}
+	assert.Equal(t, `aws_meta:{"trace_id":"abc123"}`, WithAWSJobMetadata("(null)", meta))
+	assert.Equal(t, `reviewed aws_meta:{"trace_id":"abc123"}`,
+		WithAWSJobMetadata(`reviewed aws_meta:{"cost":1}`, meta))
+}
+
+func TestEncodeAWSJobMetadata(t *testing.T) {
+	meta := &AWSJobMetadata{
+		Instances:           []string{"c5n.xlarge"},
+		SpotPlacementScores: map[string]int{"us-east-1a": 9},
+		TraceID:             "9f2c4e1a7b3d5f60",
+	}
+	full := meta.String()
+
+	t.Run("fits in one field", func(t *testing.T) {
+		values, err := EncodeAWSJobMetadata(meta, "(null)", 1024, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{full}, values)
+	})
+
+	t.Run("chunks across fields", func(t *testing.T) {
+		values, err := EncodeAWSJobMetadata(meta, "reviewed", 70, 2)
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+		for _, value := range values {
+			assert.LessOrEqual(t, len(value), 70)
+		}
+		assert.Contains(t, values[0], "reviewed aws_meta.1/2:")
+
+		joined := JoinAWSJobMetadata(values...)
+		assert.Equal(t, "reviewed "+full, joined)
+		parsed, err := ParseAWSJobMetadata(joined)
+		require.NoError(t, err)
+		assert.Equal(t, meta, parsed)
+	})
+
+	t.Run("truncates when chunks do not fit", func(t *testing.T) {
+		values, err := EncodeAWSJobMetadata(meta, "", 90, 1)
+		require.NoError(t, err)
+		require.Len(t, values, 1)
+		parsed, err := ParseAWSJobMetadata(values[0])
+		require.NoError(t, err)
+		assert.True(t, parsed.Truncated)
+		assert.Nil(t, parsed.SpotPlacementScores)
+		assert.Equal(t, meta.Instances, parsed.Instances)
+	})
+
+	t.Run("fails when nothing fits", func(t *testing.T) {
+		_, err := EncodeAWSJobMetadata(meta, "", 20, 1)
+		assert.Error(t, err)
+	})
+}
+
+func TestJoinAWSJobMetadata(t *testing.T) {
+	assert.Equal(t, `aws_meta:{"cost":1}`, JoinAWSJobMetadata("user text", `aws_meta:{"cost":1}`))
+	assert.Equal(t, `aws_meta:{"cost":1}`, JoinAWSJobMetadata(`aws_meta.2/2:1}`, `aws_meta.1/2:{"cost":`))
+	assert.Equal(t, "aws_meta.1/2:{", JoinAWSJobMetadata("aws_meta.1/2:{", ""), "incomplete chunks are not joined")
+}
@@ -2,30 +2,98 @@ package types
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 )
 
+// Execution plan schema versions. Minor versions only add optional fields, so
+// a plan with a newer minor version can run with those fields ignored; a new
+// major version cannot.
+const (
+	// PlanSchemaVersion is the newest schema this release executes
+	PlanSchemaVersion = "1.1"
+	// DefaultPlanSchemaVersion is assumed for plans that name neither a schema nor an ASBA version
+	DefaultPlanSchemaVersion = "1.0"
+)
+
 // ExecutionPlan represents a complete execution plan from ASBA
 type ExecutionPlan struct {
+	SchemaVersion     string                `json:"schema_version,omitempty"` // "1.1"; inferred from asba_version when absent
 	ShouldBurst       bool                  `json:"should_burst"`
 	InstanceSpec      InstanceSpecification `json:"instance_specification"`
 	MPIConfig         MPIConfiguration      `json:"mpi_configuration"`
 	CostConstraints   CostConstraints       `json:"cost_constraints"`
 	NetworkConfig     NetworkConfiguration  `json:"network_configuration"`
 	ExecutionMetadata ExecutionMetadata     `json:"execution_metadata"`
+	NodeRoles         []NodeRoleSpec        `json:"node_roles,omitempty"`     // Heterogeneous per-role specs
+	CapacityBlock     *CapacityBlockSpec    `json:"capacity_block,omitempty"` // Reserved capacity to launch into
+	Checkpoint        *CheckpointSpec       `json:"checkpoint,omitempty"`     // The job survives spot interruptions
+}
+
+// Ways a checkpointable job is checkpointed when its spot instance is reclaimed
+const (
+	CheckpointDMTCP   = "dmtcp"   // DMTCP images of processes started through the checkpoint wrapper
+	CheckpointSignal  = "signal"  // The application checkpoints itself on a signal
+	CheckpointCommand = "command" // The site's checkpoint command
+)
+
+// CheckpointMethods lists the checkpoint methods
+var CheckpointMethods = []string{CheckpointDMTCP, CheckpointSignal, CheckpointCommand}
+
+// CheckpointSpec declares the plan's job checkpointable. When a node's spot
+// instance gets its interruption warning, the job is checkpointed and
+// requeued, and restores from its checkpoint on the replacement nodes.
+type CheckpointSpec struct {
+	Method string `json:"method,omitempty"` // "dmtcp", "signal" or "command"; the site's default when empty
+	Signal string `json:"signal,omitempty"` // Signal the signal method sends, e.g. "USR1"
 }
 
+// CapacityBlockSpec is an EC2 Capacity Block for ML the plan launches into. A
+// Capacity Block reserves instances of one type in one AZ for a fixed window,
+// and its instances are terminated when the window ends.
+type CapacityBlockSpec struct {
+	ReservationID    string    `json:"reservation_id"`              // "cr-..."
+	InstanceType     string    `json:"instance_type,omitempty"`     // Replaces the plan's instance types
+	AvailabilityZone string    `json:"availability_zone,omitempty"` // Only subnets in this AZ are used
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+}
+
+// Active reports whether the block can be launched into at now
+func (b *CapacityBlockSpec) Active(now time.Time) bool {
+	return !now.Before(b.Start) && now.Before(b.End)
+}
+
+// NodeRoleSpec assigns a subset of a job's nodes to a specific instance specification,
+// e.g. one large-memory head node plus compute nodes for a coupled solver
+type NodeRoleSpec struct {
+	Role          string   `json:"role"`                 // "head", "compute", "io"
+	NodeCount     int      `json:"node_count,omitempty"` // Nodes taken in request order
+	NodeNames     []string `json:"node_names,omitempty"` // Explicit node assignment
+	InstanceTypes []string `json:"instance_types"`       // Exact types for this role
+}
+
+// DefaultNodeRole is the role for nodes not claimed by any NodeRoleSpec
+const DefaultNodeRole = "default"
+
 // InstanceSpecification defines exactly what instances to launch
 type InstanceSpecification struct {
-	InstanceTypes      []string `json:"instance_types"`       // Exact types: ["hpc7a.2xlarge", "c6i.xlarge"]
-	PurchasingOption   string   `json:"purchasing_option"`    // "spot", "on-demand", "mixed"
-	MaxSpotPrice       float64  `json:"max_spot_price"`       // Per-hour limit
-	SubnetIds          []string `json:"subnet_ids"`           // Target subnets
-	LaunchTemplateName string   `json:"launch_template_name"` // AWS launch template
-	LaunchTemplateID   string   `json:"launch_template_id"`   // Alternative to name
-	SecurityGroupIds   []string `json:"security_group_ids"`   // Security groups
-	IAMInstanceProfile string   `json:"iam_instance_profile"` // Instance role
-	UserData           string   `json:"user_data,omitempty"`  // Bootstrap script
+	InstanceTypes      []string `json:"instance_types"`         // Exact types: ["hpc7a.2xlarge", "c6i.xlarge"]
+	PurchasingOption   string   `json:"purchasing_option"`      // "spot", "on-demand", "mixed"
+	MaxSpotPrice       float64  `json:"max_spot_price"`         // Per-hour limit
+	SubnetIds          []string `json:"subnet_ids"`             // Target subnets
+	LaunchTemplateName string   `json:"launch_template_name"`   // AWS launch template
+	LaunchTemplateID   string   `json:"launch_template_id"`     // Alternative to name
+	SecurityGroupIds   []string `json:"security_group_ids"`     // Security groups
+	IAMInstanceProfile string   `json:"iam_instance_profile"`   // Instance role
+	UserData           string   `json:"user_data,omitempty"`    // Bootstrap script
+	Architecture       string   `json:"architecture,omitempty"` // "x86_64" or "arm64"; inferred from instance types if empty
+
+	// Per-node job requirements; instance types that are too small are resized within their family
+	MinCPUs     int `json:"min_cpus,omitempty"`
+	MinMemoryMB int `json:"min_memory_mb,omitempty"`
+	GPUs        int `json:"gpus,omitempty"`
 }
 
 // MPIConfiguration defines MPI-specific requirements
@@ -36,6 +104,11 @@ type MPIConfiguration struct {
 	MPIImplementation      string `json:"mpi_implementation"` // "openmpi", "intelmpi", "mpich"
 	RequiresEFA            bool   `json:"requires_efa"`
 	EFAGeneration          int    `json:"efa_generation"` // 1 or 2
+
+	WorkloadClass WorkloadClass `json:"workload_class,omitempty"` // "mpi" or "distributed-ml" selects user-data setup
+	GPUDirectRDMA bool          `json:"gpudirect_rdma,omitempty"` // GPUDirect RDMA over EFA (p4d, p5)
+	Accelerator   string        `json:"accelerator,omitempty"`    // AcceleratorNeuron selects Neuron SDK setup
+	Elastic       bool          `json:"elastic,omitempty"`        // Interrupted spot nodes are replaced mid-job (experimental)
 }
 
 // CostConstraints defines cost and budget limits
@@ -72,6 +145,16 @@ type ExecutionMetadata struct {
 	Tags                map[string]string `json:"tags"` // Additional AWS tags
 }
 
+// Job priorities an execution plan may carry
+const (
+	PriorityUrgent = "urgent"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// Priorities lists the job priorities
+var Priorities = []string{PriorityUrgent, PriorityNormal, PriorityLow}
+
 // ExecutionResult represents the result of executing an execution plan
 type ExecutionResult struct {
 	Success            bool             `json:"success"`
@@ -84,6 +167,30 @@ type ExecutionResult struct {
 	ExecutionEndTime   time.Time        `json:"execution_end_time"`
 	ExecutionDuration  Duration         `json:"execution_duration"`
 	Errors             []ExecutionError `json:"errors,omitempty"`
+
+	SpotPlacementScores map[string]int   `json:"spot_placement_scores,omitempty"` // Spot placement score by AZ at launch
+	Fallbacks           []LaunchFallback `json:"fallbacks,omitempty"`             // Retries after capacity failures, in order
+	WarmPoolInstances   int              `json:"warm_pool_instances,omitempty"`   // Instances started from a warm pool
+	RestartedInstances  int              `json:"restarted_instances,omitempty"`   // Stopped or hibernated instances restarted
+	LingeringInstances  int              `json:"lingering_instances,omitempty"`   // Lingering instances of suspended nodes claimed
+	ExecutionMode       string           `json:"execution_mode,omitempty"`        // "standalone" or "asba"
+	TraceID             string           `json:"trace_id,omitempty"`              // Random ID linking resume, prolog and export records
+	JobID               string           `json:"job_id,omitempty"`                // Job the nodes were resumed for, when known
+	WaitingForCapacity  []string         `json:"waiting_for_capacity,omitempty"`  // Nodes whose launch was queued until capacity frees up
+}
+
+// LaunchFallback records one retry after a launch failed for lack of capacity,
+// so ASBA can learn which pools and regions to avoid
+type LaunchFallback struct {
+	Stage            string   `json:"stage"`  // "on-demand", "alternate-families", "secondary-node-group"
+	Reason           string   `json:"reason"` // EC2 error code that triggered the retry
+	Partition        string   `json:"partition"`
+	NodeGroup        string   `json:"node_group"`
+	Region           string   `json:"region"`
+	InstanceFamilies []string `json:"instance_families,omitempty"`
+	PurchaseOption   string   `json:"purchase_option"` // "spot", "on-demand", "mixed"
+	Succeeded        bool     `json:"succeeded"`
+	Error            string   `json:"error,omitempty"`
 }
 
 // FailedInstance represents an instance that failed to launch
@@ -126,9 +233,186 @@ func (ep *ExecutionPlan) ValidateExecutionPlan() error {
 		return fmt.Errorf("MPI jobs require placement group configuration")
 	}
 
+	switch ep.MPIConfig.WorkloadClass {
+	case "", WorkloadStandard, WorkloadMPI, WorkloadDistributedML:
+	default:
+		return fmt.Errorf("invalid workload class: %s", ep.MPIConfig.WorkloadClass)
+	}
+
+	if ep.MPIConfig.Accelerator != "" && ep.MPIConfig.Accelerator != AcceleratorNeuron {
+		return fmt.Errorf("invalid accelerator: %s", ep.MPIConfig.Accelerator)
+	}
+
+	if _, err := ep.ResolveArchitecture(); err != nil {
+		return err
+	}
+
+	if err := ep.validateNodeRoles(); err != nil {
+		return err
+	}
+
+	if err := ep.validateCapacityBlock(); err != nil {
+		return err
+	}
+
+	if err := ep.validateCheckpoint(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// validateCapacityBlock checks the reservation and window of a capacity block,
+// which only on-demand launches of one instance specification can use
+func (ep *ExecutionPlan) validateCapacityBlock() error {
+	block := ep.CapacityBlock
+	if block == nil {
+		return nil
+	}
+	if !strings.HasPrefix(block.ReservationID, "cr-") {
+		return fmt.Errorf("capacity_block.reservation_id must be a capacity reservation ID (cr-...), got %q", block.ReservationID)
+	}
+	if block.Start.IsZero() || !block.End.After(block.Start) {
+		return fmt.Errorf("capacity_block must have a start and an end after it")
+	}
+	if ep.InstanceSpec.PurchasingOption != "on-demand" {
+		return fmt.Errorf("capacity_block plans must use purchasing option on-demand, got %s", ep.InstanceSpec.PurchasingOption)
+	}
+	if len(ep.NodeRoles) > 0 {
+		return fmt.Errorf("capacity_block plans cannot have node roles")
+	}
+	return nil
+}
+
+// validateCheckpoint checks the checkpoint method and signal
+func (ep *ExecutionPlan) validateCheckpoint() error {
+	checkpoint := ep.Checkpoint
+	if checkpoint == nil {
+		return nil
+	}
+	if checkpoint.Method != "" && !slices.Contains(CheckpointMethods, checkpoint.Method) {
+		return fmt.Errorf("invalid checkpoint method: %s", checkpoint.Method)
+	}
+	if !ValidSignalName(checkpoint.Signal) {
+		return fmt.Errorf("invalid checkpoint signal: %s", checkpoint.Signal)
+	}
+	return nil
+}
+
+// ValidSignalName reports whether name is empty or a signal name scancel
+// accepts without its SIG prefix, such as USR1 or TERM
+func ValidSignalName(name string) bool {
+	for _, r := range name {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return !strings.HasPrefix(name, "SIG")
+}
+
+// validateNodeRoles validates heterogeneous node role specifications
+func (ep *ExecutionPlan) validateNodeRoles() error {
+	seen := make(map[string]bool)
+	for i, role := range ep.NodeRoles {
+		if role.Role == "" {
+			return fmt.Errorf("node_roles[%d].role is required", i)
+		}
+		if role.Role == DefaultNodeRole {
+			return fmt.Errorf("node_roles[%d].role '%s' is reserved", i, DefaultNodeRole)
+		}
+		if seen[role.Role] {
+			return fmt.Errorf("duplicate node role: %s", role.Role)
+		}
+		seen[role.Role] = true
+
+		if len(role.InstanceTypes) == 0 {
+			return fmt.Errorf("node role %s has no instance types", role.Role)
+		}
+		if role.NodeCount < 0 {
+			return fmt.Errorf("node role %s has negative node_count", role.Role)
+		}
+		if role.NodeCount == 0 && len(role.NodeNames) == 0 {
+			return fmt.Errorf("node role %s must specify node_count or node_names", role.Role)
+		}
+	}
+	return nil
+}
+
+// AssignNodeRoles maps the requested nodes onto node roles. Explicit node names are
+// assigned first, then counted roles take unassigned nodes in request order. Nodes left
+// over use the plan's default InstanceSpec under DefaultNodeRole.
+func (ep *ExecutionPlan) AssignNodeRoles(nodes []string) (map[string][]string, error) {
+	assignments := make(map[string][]string)
+	assigned := make(map[string]string)
+
+	requested := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		requested[node] = true
+	}
+
+	for _, role := range ep.NodeRoles {
+		for _, node := range role.NodeNames {
+			if !requested[node] {
+				return nil, fmt.Errorf("node role %s references node %s which is not being resumed", role.Role, node)
+			}
+			if owner, ok := assigned[node]; ok {
+				return nil, fmt.Errorf("node %s assigned to both %s and %s roles", node, owner, role.Role)
+			}
+			assigned[node] = role.Role
+			assignments[role.Role] = append(assignments[role.Role], node)
+		}
+	}
+
+	next := 0
+	for _, role := range ep.NodeRoles {
+		for count := len(role.NodeNames); count < role.NodeCount; count++ {
+			for next < len(nodes) && assigned[nodes[next]] != "" {
+				next++
+			}
+			if next >= len(nodes) {
+				return nil, fmt.Errorf("not enough nodes for role %s: need %d", role.Role, role.NodeCount)
+			}
+			assigned[nodes[next]] = role.Role
+			assignments[role.Role] = append(assignments[role.Role], nodes[next])
+		}
+	}
+
+	for _, node := range nodes {
+		if assigned[node] == "" {
+			assignments[DefaultNodeRole] = append(assignments[DefaultNodeRole], node)
+		}
+	}
+
+	return assignments, nil
+}
+
+// ResolveArchitecture returns the CPU architecture the plan targets. Instance types
+// spanning several architectures are rejected so x86 jobs never land on ARM nodes.
+func (ep *ExecutionPlan) ResolveArchitecture() (string, error) {
+	arch := ep.InstanceSpec.Architecture
+	if arch != "" && arch != ArchitectureX86_64 && arch != ArchitectureARM64 {
+		return "", fmt.Errorf("invalid architecture: %s", arch)
+	}
+
+	instanceTypes := append([]string{}, ep.InstanceSpec.InstanceTypes...)
+	for _, role := range ep.NodeRoles {
+		instanceTypes = append(instanceTypes, role.InstanceTypes...)
+	}
+
+	for _, instanceType := range instanceTypes {
+		typeArch := ArchitectureOf(instanceType)
+		if arch == "" {
+			arch = typeArch
+			continue
+		}
+		if typeArch != arch {
+			return "", fmt.Errorf("instance type %s is %s but execution plan targets %s", instanceType, typeArch, arch)
+		}
+	}
+
+	return arch, nil
+}
+
 // GetRequiredInstanceCount calculates how many instances are needed
 func (ep *ExecutionPlan) GetRequiredInstanceCount(nodeIds []string) int {
 	return len(nodeIds)
@@ -0,0 +1,164 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionPlan_AssignNodeRoles(t *testing.T) {
+	nodes := []string{"aws-hpc-000", "aws-hpc-001", "aws-hpc-002", "aws-hpc-003"}
+
+	tests := []struct {
+		name        string
+		roles       []NodeRoleSpec
+		expected    map[string][]string
+		expectError bool
+	}{
+		{
+			name:  "no roles uses default spec",
+			roles: nil,
+			expected: map[string][]string{
+				DefaultNodeRole: nodes,
+			},
+		},
+		{
+			name: "fat head node plus compute nodes",
+			roles: []NodeRoleSpec{
+				{Role: "head", NodeCount: 1, InstanceTypes: []string{"r6i.8xlarge"}},
+				{Role: "compute", NodeCount: 3, InstanceTypes: []string{"hpc7a.12xlarge"}},
+			},
+			expected: map[string][]string{
+				"head":    {"aws-hpc-000"},
+				"compute": {"aws-hpc-001", "aws-hpc-002", "aws-hpc-003"},
+			},
+		},
+		{
+			name: "explicit node names take precedence",
+			roles: []NodeRoleSpec{
+				{Role: "compute", NodeCount: 2, InstanceTypes: []string{"c6i.xlarge"}},
+				{Role: "head", NodeNames: []string{"aws-hpc-000"}, InstanceTypes: []string{"r6i.8xlarge"}},
+			},
+			expected: map[string][]string{
+				"head":          {"aws-hpc-000"},
+				"compute":       {"aws-hpc-001", "aws-hpc-002"},
+				DefaultNodeRole: {"aws-hpc-003"},
+			},
+		},
+		{
+			name: "too many nodes requested",
+			roles: []NodeRoleSpec{
+				{Role: "compute", NodeCount: 5, InstanceTypes: []string{"c6i.xlarge"}},
+			},
+			expectError: true,
+		},
+		{
+			name: "unknown node name",
+			roles: []NodeRoleSpec{
+				{Role: "head", NodeNames: []string{"aws-hpc-009"}, InstanceTypes: []string{"r6i.8xlarge"}},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &ExecutionPlan{NodeRoles: tt.roles}
+			assignments, err := plan.AssignNodeRoles(nodes)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, assignments)
+		})
+	}
+}
+
+func TestExecutionPlan_ValidateNodeRoles(t *testing.T) {
+	base := ExecutionPlan{
+		ShouldBurst: true,
+		InstanceSpec: InstanceSpecification{
+			InstanceTypes:    []string{"c6i.xlarge"},
+			PurchasingOption: "on-demand",
+			SubnetIds:        []string{"subnet-123"},
+		},
+	}
+
+	valid := base
+	valid.NodeRoles = []NodeRoleSpec{{Role: "head", NodeCount: 1, InstanceTypes: []string{"r6i.8xlarge"}}}
+	assert.NoError(t, valid.ValidateExecutionPlan())
+
+	duplicate := base
+	duplicate.NodeRoles = []NodeRoleSpec{
+		{Role: "head", NodeCount: 1, InstanceTypes: []string{"r6i.8xlarge"}},
+		{Role: "head", NodeCount: 1, InstanceTypes: []string{"r6i.8xlarge"}},
+	}
+	assert.Error(t, duplicate.ValidateExecutionPlan())
+
+	mixedArch := base
+	mixedArch.NodeRoles = []NodeRoleSpec{{Role: "head", NodeCount: 1, InstanceTypes: []string{"r7g.8xlarge"}}}
+	assert.Error(t, mixedArch.ValidateExecutionPlan())
+
+	reserved := base
+	reserved.NodeRoles = []NodeRoleSpec{{Role: DefaultNodeRole, NodeCount: 1, InstanceTypes: []string{"c6i.xlarge"}}}
+	assert.Error(t, reserved.ValidateExecutionPlan())
+}
+
+func TestExecutionPlan_ValidateCapacityBlock(t *testing.T) {
+	start := time.Date(2026, 11, 2, 11, 30, 0, 0, time.UTC)
+	block := &CapacityBlockSpec{ReservationID: "cr-0123456789abcdef0", InstanceType: "p5.48xlarge", Start: start, End: start.Add(72 * time.Hour)}
+	base := ExecutionPlan{
+		ShouldBurst: true,
+		InstanceSpec: InstanceSpecification{
+			InstanceTypes:    []string{"p5.48xlarge"},
+			PurchasingOption: "on-demand",
+			SubnetIds:        []string{"subnet-123"},
+		},
+		CapacityBlock: block,
+	}
+	assert.NoError(t, base.ValidateExecutionPlan())
+
+	assert.False(t, block.Active(start.Add(-time.Minute)))
+	assert.True(t, block.Active(start))
+	assert.False(t, block.Active(block.End), "the window ends at its end time")
+
+	spot := base
+	spot.InstanceSpec.PurchasingOption = "spot"
+	assert.Error(t, spot.ValidateExecutionPlan())
+
+	unknown := base
+	unknown.CapacityBlock = &CapacityBlockSpec{ReservationID: "p5-block", Start: start, End: block.End}
+	assert.Error(t, unknown.ValidateExecutionPlan())
+
+	backwards := base
+	backwards.CapacityBlock = &CapacityBlockSpec{ReservationID: block.ReservationID, Start: block.End, End: start}
+	assert.Error(t, backwards.ValidateExecutionPlan())
+}
+
+func TestExecutionPlan_ValidateCheckpoint(t *testing.T) {
+	base := ExecutionPlan{
+		ShouldBurst: true,
+		InstanceSpec: InstanceSpecification{
+			InstanceTypes:    []string{"c6i.xlarge"},
+			PurchasingOption: "spot",
+			SubnetIds:        []string{"subnet-123"},
+		},
+		Checkpoint: &CheckpointSpec{},
+	}
+	assert.NoError(t, base.ValidateExecutionPlan(), "the site's default method")
+
+	for _, spec := range []CheckpointSpec{{Method: CheckpointDMTCP}, {Method: CheckpointSignal, Signal: "USR1"}, {Method: CheckpointCommand}} {
+		plan := base
+		plan.Checkpoint = &spec
+		assert.NoError(t, plan.ValidateExecutionPlan(), spec.Method)
+	}
+
+	for _, spec := range []CheckpointSpec{{Method: "criu"}, {Signal: "SIGUSR1"}, {Signal: "USR1; reboot"}} {
+		plan := base
+		plan.Checkpoint = &spec
+		assert.Error(t, plan.ValidateExecutionPlan(), spec)
+	}
+}
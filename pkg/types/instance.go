@@ -1,5 +1,11 @@
 package types
 
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
 // InstanceRequirements defines what type of instance is needed for a job
 type InstanceRequirements struct {
 	// Basic compute requirements
@@ -7,6 +13,7 @@ type InstanceRequirements struct {
 	MinMemoryMB int    `json:"min_memory_mb"`
 	GPUs        int    `json:"gpus,omitempty"`
 	GPUType     string `json:"gpu_type,omitempty"`
+	Accelerator string `json:"accelerator,omitempty"` // AcceleratorNeuron for Trainium and Inferentia
 
 	// Network requirements
 	RequiresEFA        bool            `json:"requires_efa"`
@@ -15,6 +22,7 @@ type InstanceRequirements struct {
 	PlacementGroupType string          `json:"placement_group_type,omitempty"`
 
 	// Instance preferences
+	Architecture       string   `json:"architecture,omitempty"`      // "x86_64" or "arm64"
 	InstanceFamilies   []string `json:"instance_families,omitempty"` // ["c5n", "c6i", "hpc6a"]
 	ExcludeInstances   []string `json:"exclude_instances,omitempty"` // Specific instances to avoid
 	HPCOptimized       bool     `json:"hpc_optimized"`               // Prefer HPC-optimized instances
@@ -36,9 +44,44 @@ const (
 	EFADisabled  EFACapability = "disabled"  // Don't use EFA
 )
 
+// CPU architectures supported by burst node groups
+const (
+	ArchitectureX86_64 = "x86_64"
+	ArchitectureARM64  = "arm64"
+)
+
+// AcceleratorNeuron marks jobs and plans for AWS Trainium and Inferentia
+// instances, which run code compiled with the Neuron SDK instead of CUDA
+const AcceleratorNeuron = "neuron"
+
+// Neuron instance families: Trainium for training, Inferentia for inference
+var (
+	TrainiumFamilies   = []string{"trn1n", "trn1"}
+	InferentiaFamilies = []string{"inf2"}
+)
+
+// IsNeuronFamily reports whether an instance family has Neuron devices
+func IsNeuronFamily(family string) bool {
+	return slices.Contains(TrainiumFamilies, family) || slices.Contains(InferentiaFamilies, family)
+}
+
+// NeuronOnly reports whether every instance type or family is a Neuron one
+func NeuronOnly(instanceTypes []string) bool {
+	for _, instanceType := range instanceTypes {
+		if !IsNeuronFamily(InstanceFamilyOf(instanceType)) {
+			return false
+		}
+	}
+	return len(instanceTypes) > 0
+}
+
+// gravitonFamilyPattern matches Graviton families: a generation digit followed by "g" (c7g, hpc7g, x2gd, im4gn)
+var gravitonFamilyPattern = regexp.MustCompile(`^[a-z]+[0-9]+g[a-z]*$`)
+
 // InstanceFamily represents AWS instance family characteristics
 type InstanceFamily struct {
 	Name               string   `json:"name"` // "c5n", "hpc6a", etc.
+	Architecture       string   `json:"architecture"`
 	SupportsEFA        bool     `json:"supports_efa"`
 	EFAGeneration      int      `json:"efa_generation"`      // 1 or 2
 	NetworkPerformance string   `json:"network_performance"` // "Up to 100 Gbps"
@@ -46,10 +89,48 @@ type InstanceFamily struct {
 	AvailableRegions   []string `json:"available_regions"`
 }
 
+// InstanceTypeInfo describes a single EC2 instance type as reported by
+// DescribeInstanceTypes. It is the unit stored in the instance catalog.
+type InstanceTypeInfo struct {
+	InstanceType       string   `json:"instance_type"` // "c6i.xlarge"
+	Family             string   `json:"family"`        // "c6i"
+	VCPUs              int      `json:"vcpus"`
+	MemoryMiB          int      `json:"memory_mib"`
+	SupportsEFA        bool     `json:"supports_efa"`
+	MaxEFAInterfaces   int      `json:"max_efa_interfaces,omitempty"`
+	GPUCount           int      `json:"gpu_count,omitempty"`
+	GPUType            string   `json:"gpu_type,omitempty"` // "a100", "h100"
+	NeuronDevices      int      `json:"neuron_devices,omitempty"`
+	NetworkPerformance string   `json:"network_performance"`
+	Architectures      []string `json:"architectures"` // "x86_64", "arm64"
+}
+
+// ArchitectureOf returns the CPU architecture of an instance type or family
+// based on AWS naming conventions ("c7g.xlarge" -> arm64, "c6i.xlarge" -> x86_64)
+func ArchitectureOf(instanceType string) string {
+	family := InstanceFamilyOf(instanceType)
+	if family == "a1" || gravitonFamilyPattern.MatchString(family) {
+		return ArchitectureARM64
+	}
+	return ArchitectureX86_64
+}
+
+// InstanceFamilyOf returns the family portion of an instance type ("c6i.xlarge" -> "c6i")
+func InstanceFamilyOf(instanceType string) string {
+	if idx := strings.Index(instanceType, "."); idx >= 0 {
+		return instanceType[:idx]
+	}
+	return instanceType
+}
+
+// The family maps below are static seed data used when the EC2-backed
+// instance catalog is unavailable (no credentials, offline validation, tests).
+
 // HPC-Optimized Instance Types with EFA Support
 var HPCInstanceFamilies = map[string]InstanceFamily{
 	"hpc6a": {
 		Name:               "hpc6a",
+		Architecture:       ArchitectureX86_64,
 		SupportsEFA:        true,
 		EFAGeneration:      2,
 		NetworkPerformance: "100 Gbps",
@@ -58,6 +139,7 @@ var HPCInstanceFamilies = map[string]InstanceFamily{
 	},
 	"hpc6id": {
 		Name:               "hpc6id",
+		Architecture:       ArchitectureX86_64,
 		SupportsEFA:        true,
 		EFAGeneration:      2,
 		NetworkPerformance: "200 Gbps",
@@ -66,18 +148,29 @@ var HPCInstanceFamilies = map[string]InstanceFamily{
 	},
 	"hpc7a": {
 		Name:               "hpc7a",
+		Architecture:       ArchitectureX86_64,
 		SupportsEFA:        true,
 		EFAGeneration:      2,
 		NetworkPerformance: "300 Gbps",
 		HPCOptimized:       true,
 		AvailableRegions:   []string{"us-east-1", "us-west-2"},
 	},
+	"hpc7g": {
+		Name:               "hpc7g",
+		Architecture:       ArchitectureARM64,
+		SupportsEFA:        true,
+		EFAGeneration:      2,
+		NetworkPerformance: "200 Gbps",
+		HPCOptimized:       true,
+		AvailableRegions:   []string{"us-east-1", "eu-west-1"},
+	},
 }
 
 // Compute-Optimized with EFA Support
 var ComputeEFAFamilies = map[string]InstanceFamily{
 	"c5n": {
 		Name:               "c5n",
+		Architecture:       ArchitectureX86_64,
 		SupportsEFA:        true,
 		EFAGeneration:      1,
 		NetworkPerformance: "Up to 100 Gbps",
@@ -86,6 +179,7 @@ var ComputeEFAFamilies = map[string]InstanceFamily{
 	},
 	"c6i": {
 		Name:               "c6i",
+		Architecture:       ArchitectureX86_64,
 		SupportsEFA:        true,
 		EFAGeneration:      2,
 		NetworkPerformance: "Up to 50 Gbps",
@@ -94,18 +188,47 @@ var ComputeEFAFamilies = map[string]InstanceFamily{
 	},
 	"c6in": {
 		Name:               "c6in",
+		Architecture:       ArchitectureX86_64,
 		SupportsEFA:        true,
 		EFAGeneration:      2,
 		NetworkPerformance: "200 Gbps",
 		HPCOptimized:       false,
 		AvailableRegions:   []string{"us-east-1", "us-west-2", "eu-west-1"},
 	},
+	"c7g": {
+		Name:               "c7g",
+		Architecture:       ArchitectureARM64,
+		SupportsEFA:        true,
+		EFAGeneration:      2,
+		NetworkPerformance: "Up to 30 Gbps",
+		HPCOptimized:       false,
+		AvailableRegions:   []string{"us-east-1", "us-west-2", "eu-west-1", "ap-southeast-1"},
+	},
+	"c7gn": {
+		Name:               "c7gn",
+		Architecture:       ArchitectureARM64,
+		SupportsEFA:        true,
+		EFAGeneration:      2,
+		NetworkPerformance: "200 Gbps",
+		HPCOptimized:       false,
+		AvailableRegions:   []string{"us-east-1", "us-west-2", "eu-west-1"},
+	},
+	"c8g": {
+		Name:               "c8g",
+		Architecture:       ArchitectureARM64,
+		SupportsEFA:        true,
+		EFAGeneration:      2,
+		NetworkPerformance: "Up to 50 Gbps",
+		HPCOptimized:       false,
+		AvailableRegions:   []string{"us-east-1", "us-west-2"},
+	},
 }
 
 // Memory-Optimized with EFA Support
 var MemoryEFAFamilies = map[string]InstanceFamily{
 	"r5n": {
 		Name:               "r5n",
+		Architecture:       ArchitectureX86_64,
 		SupportsEFA:        true,
 		EFAGeneration:      1,
 		NetworkPerformance: "Up to 100 Gbps",
@@ -114,12 +237,22 @@ var MemoryEFAFamilies = map[string]InstanceFamily{
 	},
 	"r6i": {
 		Name:               "r6i",
+		Architecture:       ArchitectureX86_64,
 		SupportsEFA:        true,
 		EFAGeneration:      2,
 		NetworkPerformance: "Up to 50 Gbps",
 		HPCOptimized:       false,
 		AvailableRegions:   []string{"us-east-1", "us-west-2", "eu-west-1"},
 	},
+	"r7g": {
+		Name:               "r7g",
+		Architecture:       ArchitectureARM64,
+		SupportsEFA:        true,
+		EFAGeneration:      2,
+		NetworkPerformance: "Up to 30 Gbps",
+		HPCOptimized:       false,
+		AvailableRegions:   []string{"us-east-1", "us-west-2", "eu-west-1"},
+	},
 }
 
 // GetEFASupportedFamilies returns all instance families that support EFA
@@ -139,6 +272,26 @@ func GetEFASupportedFamilies() []InstanceFamily {
 	return families
 }
 
+// otherEFAFamilies support EFA but have no seed data above
+var otherEFAFamilies = []string{
+	"c7i", "m7g", "m5n", "m5dn", "m6i", "m6in", "m7i",
+	"r5dn", "r6in", "r7i", "p3dn", "p4d", "p5", "trn1", "trn1n", "trn2",
+}
+
+// KnownEFAFamily reports whether an instance family is known to support EFA
+// without asking EC2
+func KnownEFAFamily(instanceFamily string) bool {
+	if supported, _ := IsEFASupported(instanceFamily); supported {
+		return true
+	}
+	for _, family := range otherEFAFamilies {
+		if family == instanceFamily {
+			return true
+		}
+	}
+	return false
+}
+
 // IsEFASupported checks if an instance family supports EFA
 func IsEFASupported(instanceFamily string) (bool, int) {
 	allFamilies := map[string]InstanceFamily{}
@@ -1,11 +1,51 @@
 package types
 
+import "time"
+
 // InstanceInfo represents information about a launched AWS instance
 type InstanceInfo struct {
-	NodeName   string `json:"node_name"`
-	InstanceID string `json:"instance_id"`
-	PrivateIP  string `json:"private_ip"`
-	PublicIP   string `json:"public_ip,omitempty"`
-	State      string `json:"state"`
-	LaunchTime string `json:"launch_time"`
+	NodeName         string `json:"node_name"`
+	InstanceID       string `json:"instance_id"`
+	InstanceType     string `json:"instance_type,omitempty"`
+	Role             string `json:"role,omitempty"` // Node role for heterogeneous launches
+	PrivateIP        string `json:"private_ip"`
+	PublicIP         string `json:"public_ip,omitempty"`
+	AvailabilityZone string `json:"availability_zone,omitempty"`
+	PurchaseOption   string `json:"purchase_option,omitempty"` // "spot" or "on-demand"
+	State            string `json:"state"`
+	LaunchTime       string `json:"launch_time"`
+	Partition        string `json:"partition,omitempty"`
+	NodeGroup        string `json:"node_group,omitempty"`
+	JobID            string `json:"job_id,omitempty"` // Job the instance was launched for
+
+	// Hourly prices when the instance launched: the spot price in its zone
+	// for spot instances, and the on-demand price of its type
+	SpotPriceUSD     float64 `json:"spot_price_usd,omitempty"`
+	OnDemandPriceUSD float64 `json:"on_demand_price_usd,omitempty"`
+
+	// EBS volumes the instance launched with, for storage costs
+	Volumes []EBSVolume `json:"volumes,omitempty"`
+
+	// Capacity of the instance's type, for splitting its cost between the
+	// jobs packed onto it
+	VCPUs     int `json:"vcpus,omitempty"`
+	MemoryMiB int `json:"memory_mib,omitempty"`
+}
+
+// EBSVolume is an EBS volume attached to an instance at launch
+type EBSVolume struct {
+	DeviceName     string `json:"device_name,omitempty"`
+	VolumeType     string `json:"volume_type"` // "gp3", "io2", ...
+	SizeGB         int    `json:"size_gb"`
+	IOPS           int    `json:"iops,omitempty"`            // Provisioned IOPS
+	ThroughputMBps int    `json:"throughput_mbps,omitempty"` // Provisioned gp3 throughput
+}
+
+// RunHours is how long the instance ran until end, or elapsed when its launch
+// time is unknown
+func (i InstanceInfo) RunHours(end time.Time, elapsed time.Duration) float64 {
+	if launched, err := time.Parse(time.RFC3339, i.LaunchTime); err == nil && end.After(launched) {
+		return end.Sub(launched).Hours()
+	}
+	return elapsed.Hours()
 }
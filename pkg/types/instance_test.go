@@ -0,0 +1,105 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchitectureOf(t *testing.T) {
+	tests := []struct {
+		instanceType string
+		expected     string
+	}{
+		{"c7g.xlarge", ArchitectureARM64},
+		{"hpc7g.16xlarge", ArchitectureARM64},
+		{"c7gn.16xlarge", ArchitectureARM64},
+		{"x2gd.large", ArchitectureARM64},
+		{"im4gn.large", ArchitectureARM64},
+		{"a1.large", ArchitectureARM64},
+		{"g5g.xlarge", ArchitectureARM64},
+		{"c6i.xlarge", ArchitectureX86_64},
+		{"g4dn.xlarge", ArchitectureX86_64},
+		{"hpc7a.96xlarge", ArchitectureX86_64},
+		{"p5.48xlarge", ArchitectureX86_64},
+		{"c7g", ArchitectureARM64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.instanceType, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ArchitectureOf(tt.instanceType))
+		})
+	}
+}
+
+func TestExecutionPlan_ResolveArchitecture(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        InstanceSpecification
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "inferred x86",
+			spec:     InstanceSpecification{InstanceTypes: []string{"c6i.xlarge", "c5n.large"}},
+			expected: ArchitectureX86_64,
+		},
+		{
+			name:     "inferred graviton",
+			spec:     InstanceSpecification{InstanceTypes: []string{"c7g.xlarge", "hpc7g.16xlarge"}},
+			expected: ArchitectureARM64,
+		},
+		{
+			name:        "mixed architectures rejected",
+			spec:        InstanceSpecification{InstanceTypes: []string{"c6i.xlarge", "c7g.xlarge"}},
+			expectError: true,
+		},
+		{
+			name:        "explicit architecture conflicts with types",
+			spec:        InstanceSpecification{InstanceTypes: []string{"c6i.xlarge"}, Architecture: ArchitectureARM64},
+			expectError: true,
+		},
+		{
+			name:        "invalid architecture",
+			spec:        InstanceSpecification{InstanceTypes: []string{"c6i.xlarge"}, Architecture: "sparc"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &ExecutionPlan{InstanceSpec: tt.spec}
+			arch, err := plan.ResolveArchitecture()
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, arch)
+		})
+	}
+}
+
+func TestIsEFASupported_Graviton(t *testing.T) {
+	supported, generation := IsEFASupported("hpc7g")
+	assert.True(t, supported)
+	assert.Equal(t, 2, generation)
+}
+
+func TestKnownEFAFamily(t *testing.T) {
+	assert.True(t, KnownEFAFamily("hpc7a"), "seed data")
+	assert.True(t, KnownEFAFamily("p5"), "EFA family without seed data")
+	assert.False(t, KnownEFAFamily("t3"))
+	assert.False(t, KnownEFAFamily(""))
+}
+
+func TestIsNeuronFamily(t *testing.T) {
+	assert.True(t, IsNeuronFamily("trn1n"))
+	assert.True(t, IsNeuronFamily(InstanceFamilyOf("inf2.xlarge")))
+	assert.False(t, IsNeuronFamily("p5"))
+	assert.False(t, IsNeuronFamily("inf2.xlarge"), "instance types are not families")
+
+	assert.True(t, NeuronOnly([]string{"trn1.32xlarge", "trn1n"}))
+	assert.False(t, NeuronOnly([]string{"trn1.32xlarge", "p5.48xlarge"}))
+	assert.False(t, NeuronOnly(nil))
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"strings"
 	"time"
 )
 
@@ -9,6 +10,9 @@ type SlurmJob struct {
 	JobID       string            `json:"job_id"`
 	Name        string            `json:"name"`
 	Partition   string            `json:"partition"`
+	User        string            `json:"user,omitempty"`
+	Account     string            `json:"account,omitempty"`
+	QOS         string            `json:"qos,omitempty"`
 	NodeList    []string          `json:"node_list"`
 	Resources   ResourceSpec      `json:"resources"`
 	Constraints JobConstraints    `json:"constraints"`
@@ -20,29 +24,128 @@ type SlurmJob struct {
 	MPIProcesses int             `json:"mpi_processes,omitempty"`
 	MPITopology  NetworkTopology `json:"mpi_topology,omitempty"`
 
+	// Workload classification
+	WorkloadClass WorkloadClass `json:"workload_class,omitempty"`
+	MLFramework   string        `json:"ml_framework,omitempty"` // "pytorch", "horovod", "deepspeed", ...
+	Accelerator   string        `json:"accelerator,omitempty"`  // AcceleratorNeuron for Neuron SDK jobs
+	Container     string        `json:"container,omitempty"`    // OCI bundle or image from --container or pyxis's --container-image
+
+	// Data staging declared by burst buffer directives in the job script
+	Staging *StagingSpec `json:"staging,omitempty"`
+
+	// Per-job bursting overrides from #ASBX pragmas in the job script
+	Overrides *JobOverrides `json:"overrides,omitempty"`
+
 	// Timing
 	SubmitTime time.Time  `json:"submit_time"`
 	StartTime  *time.Time `json:"start_time,omitempty"`
 	TimeLimit  Duration   `json:"time_limit"`
 }
 
+// ArrayJobID returns the array job an array task such as 1234_7 belongs to,
+// or "" for a job that is not an array task
+func (j *SlurmJob) ArrayJobID() string {
+	arrayJobID, _, isTask := strings.Cut(j.JobID, "_")
+	if !isTask {
+		return ""
+	}
+	return arrayJobID
+}
+
 type ResourceSpec struct {
-	Nodes        int    `json:"nodes"`
-	CPUsPerNode  int    `json:"cpus_per_node"`
-	MemoryMB     int    `json:"memory_mb"`
-	GPUs         int    `json:"gpus,omitempty"`
-	GPUType      string `json:"gpu_type,omitempty"`
-	LocalStorage int    `json:"local_storage_gb,omitempty"`
+	Nodes          int    `json:"nodes"`
+	CPUsPerNode    int    `json:"cpus_per_node"`
+	MemoryMB       int    `json:"memory_mb"`
+	MemoryPerCPUMB int    `json:"memory_per_cpu_mb,omitempty"` // From --mem-per-cpu
+	GPUs           int    `json:"gpus,omitempty"`
+	GPUType        string `json:"gpu_type,omitempty"`
+	LocalStorage   int    `json:"local_storage_gb,omitempty"`
+}
+
+// NodeMemoryMB returns the memory each node needs, accounting for --mem-per-cpu requests
+func (r ResourceSpec) NodeMemoryMB() int {
+	if r.MemoryPerCPUMB > 0 && r.CPUsPerNode > 0 {
+		if perNode := r.MemoryPerCPUMB * r.CPUsPerNode; perNode > r.MemoryMB {
+			return perNode
+		}
+	}
+	return r.MemoryMB
+}
+
+// JobOverrides steer how a job bursts in standalone mode, set by #ASBX
+// pragmas in its script:
+//
+//	#ASBX instance-type=c6i.4xlarge,c6a.4xlarge
+//	#ASBX purchasing=spot max-cost=0.80
+type JobOverrides struct {
+	InstanceTypes    []string `json:"instance_types,omitempty"`    // Launch only these of the node group's types
+	PurchasingOption string   `json:"purchasing_option,omitempty"` // "spot", "on-demand" or "mixed"
+	MaxCostPerHour   float64  `json:"max_cost_per_hour,omitempty"` // USD per instance-hour
+	Ignored          []string `json:"ignored,omitempty"`           // Pragmas that cannot be honoured, with why
+}
+
+// Staging transfer types: one file, or a directory copied recursively
+const (
+	StagingFile      = "file"
+	StagingDirectory = "directory"
+)
+
+// StagingSpec is the data staging a job script declares with burst buffer
+// style directives (#BB, #DW or #ASBX): a scratch volume on each node, data
+// copied onto it before the job starts and results copied off after it ends
+type StagingSpec struct {
+	ScratchGB int               `json:"scratch_gb,omitempty"` // Scratch volume per node
+	Retention Duration          `json:"retention,omitempty"`  // How long scratch volumes outlive their nodes
+	StageIn   []StagingTransfer `json:"stage_in,omitempty"`
+	StageOut  []StagingTransfer `json:"stage_out,omitempty"`
+	Ignored   []string          `json:"ignored,omitempty"` // Directives that cannot be honoured, with why
+}
+
+// Empty reports whether the spec asks for no scratch and no transfers
+func (s *StagingSpec) Empty() bool {
+	return s == nil || (s.ScratchGB == 0 && len(s.StageIn) == 0 && len(s.StageOut) == 0)
+}
+
+// StagingTransfer copies Source to Destination. Either may be an s3:// URL;
+// paths may refer to the node's scratch as $DW_JOB_STRIPED, $DW_JOB_PRIVATE
+// or $ASB_SCRATCH.
+type StagingTransfer struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Type        string `json:"type"` // StagingFile or StagingDirectory
 }
 
 type JobConstraints struct {
-	Features         []string `json:"features,omitempty"`
-	ExcludeNodes     []string `json:"exclude_nodes,omitempty"`
-	RequiredNodes    []string `json:"required_nodes,omitempty"`
-	AvailabilityZone string   `json:"availability_zone,omitempty"`
-	MaxSpotPrice     float64  `json:"max_spot_price,omitempty"`
+	Features         []string   `json:"features,omitempty"`
+	FeatureGroups    [][]string `json:"feature_groups,omitempty"` // Features by & term; any feature of a term satisfies it
+	ExcludeNodes     []string   `json:"exclude_nodes,omitempty"`
+	RequiredNodes    []string   `json:"required_nodes,omitempty"`
+	AvailabilityZone string     `json:"availability_zone,omitempty"`
+	MaxSpotPrice     float64    `json:"max_spot_price,omitempty"`
+}
+
+// FeatureTerms returns the feature groups that must all hold, or each feature
+// as a term of its own when the constraint was not parsed into groups
+func (c JobConstraints) FeatureTerms() [][]string {
+	if len(c.FeatureGroups) > 0 {
+		return c.FeatureGroups
+	}
+	terms := make([][]string, 0, len(c.Features))
+	for _, feature := range c.Features {
+		terms = append(terms, []string{feature})
+	}
+	return terms
 }
 
+// WorkloadClass categorizes how a job's nodes communicate
+type WorkloadClass string
+
+const (
+	WorkloadStandard      WorkloadClass = "standard"       // Independent or single-node work
+	WorkloadMPI           WorkloadClass = "mpi"            // Tightly-coupled MPI
+	WorkloadDistributedML WorkloadClass = "distributed-ml" // NCCL collectives (torchrun, Horovod, DeepSpeed)
+)
+
 type NetworkTopology string
 
 const (
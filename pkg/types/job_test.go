@@ -320,3 +320,14 @@ func BenchmarkSlurmJob_JSON(b *testing.B) {
 		}
 	}
 }
+
+func TestResourceSpec_NodeMemoryMB(t *testing.T) {
+	assert.Equal(t, 8192, ResourceSpec{CPUsPerNode: 4, MemoryMB: 8192}.NodeMemoryMB())
+	assert.Equal(t, 16384, ResourceSpec{CPUsPerNode: 4, MemoryMB: 4096, MemoryPerCPUMB: 4096}.NodeMemoryMB())
+	assert.Equal(t, 4096, ResourceSpec{MemoryMB: 4096, MemoryPerCPUMB: 4096}.NodeMemoryMB())
+}
+
+func TestSlurmJob_ArrayJobID(t *testing.T) {
+	assert.Equal(t, "1234", (&SlurmJob{JobID: "1234_7"}).ArrayJobID())
+	assert.Equal(t, "", (&SlurmJob{JobID: "1234"}).ArrayJobID())
+}
@@ -31,6 +31,9 @@ type ActualExecution struct {
 	Success           bool      `json:"success"`
 	ErrorDetails      string    `json:"error_details,omitempty"`
 	NodeCount         int       `json:"node_count"`
+	Nodes             []string  `json:"nodes,omitempty"`     // Nodes the job ran on
+	CPUs              int       `json:"cpus,omitempty"`      // Allocated across all its nodes
+	MemoryMB          int       `json:"memory_mb,omitempty"` // Allocated across all its nodes
 	StartTime         time.Time `json:"start_time"`
 	EndTime           time.Time `json:"end_time"`
 }
@@ -42,19 +45,44 @@ type PredictionValidation struct {
 	InstanceTypeOptimal    bool    `json:"instance_type_optimal"`    // Were chosen instance types actually best?
 	DomainDetectionCorrect bool    `json:"domain_detection_correct"` // Was domain correctly identified?
 	OverallAccuracyScore   float64 `json:"overall_accuracy_score"`   // Combined accuracy metric
+	Validated              bool    `json:"validated"`                // A recorded plan was compared; otherwise the scores are zero
 }
 
 // AWSPerformanceMetrics contains AWS-specific performance data
 type AWSPerformanceMetrics struct {
-	EFAUtilization              float64    `json:"efa_utilization"`               // 0.0-1.0, EFA bandwidth utilization
-	PlacementGroupEffectiveness float64    `json:"placement_group_effectiveness"` // 0.0-1.0, placement group benefit
-	SpotInterruptions           int        `json:"spot_interruptions"`            // Number of spot interruptions
-	NetworkThroughputGbps       float64    `json:"network_throughput_gbps"`       // Peak network throughput
-	CPUUtilization              float64    `json:"cpu_utilization"`               // Average CPU utilization
-	MemoryUtilization           float64    `json:"memory_utilization"`            // Average memory utilization
-	ProvisioningTime            Duration   `json:"provisioning_time"`             // Time to launch instances
-	AvailabilityZones           []string   `json:"availability_zones"`            // AZs where instances ran
-	InstanceLaunchTimes         []Duration `json:"instance_launch_times"`         // Individual instance launch times
+	EFAUtilization              float64           `json:"efa_utilization"`                   // 0.0-1.0, EFA bandwidth utilization
+	PlacementGroupEffectiveness float64           `json:"placement_group_effectiveness"`     // 0.0-1.0, placement group benefit
+	SpotInterruptions           int               `json:"spot_interruptions"`                // Number of spot interruptions
+	NetworkThroughputGbps       float64           `json:"network_throughput_gbps"`           // Peak network throughput
+	CPUUtilization              float64           `json:"cpu_utilization"`                   // Average CPU utilization
+	MemoryUtilization           float64           `json:"memory_utilization"`                // Average memory utilization
+	ProvisioningTime            Duration          `json:"provisioning_time"`                 // Time to launch instances
+	AvailabilityZones           []string          `json:"availability_zones"`                // AZs where instances ran
+	NodeAvailabilityZones       map[string]string `json:"node_availability_zones,omitempty"` // Node name -> AZ
+	SpotPlacementScores         map[string]int    `json:"spot_placement_scores,omitempty"`   // AZ -> spot placement score (1-10) at launch
+	LaunchFallbacks             []LaunchFallback  `json:"launch_fallbacks,omitempty"`        // Capacity fallbacks taken at launch
+	InstanceLaunchTimes         []Duration        `json:"instance_launch_times"`             // Individual instance launch times
+	MeasuredNodes               int               `json:"measured_nodes,omitempty"`          // Nodes whose collector summaries replaced estimates
+}
+
+// NodeMetricsSummary is the per-node summary the node metrics collector writes at
+// job end, covering the samples taken while the job ran
+type NodeMetricsSummary struct {
+	JobID             string    `json:"job_id"`
+	NodeName          string    `json:"node_name"`
+	InstanceID        string    `json:"instance_id,omitempty"`
+	InstanceType      string    `json:"instance_type,omitempty"`
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	Samples           int       `json:"samples"`
+	CPUUtilization    float64   `json:"cpu_utilization"`             // 0.0-1.0, average
+	CPUPeak           float64   `json:"cpu_peak"`                    // 0.0-1.0
+	MemoryUtilization float64   `json:"memory_utilization"`          // 0.0-1.0, average
+	MemoryPeak        float64   `json:"memory_peak"`                 // 0.0-1.0
+	NetworkPeakGbps   float64   `json:"network_peak_gbps"`           // Receive plus transmit on all interfaces but loopback
+	NetworkOutBytes   float64   `json:"network_out_bytes,omitempty"` // Sent on all interfaces but loopback, as CloudWatch NetworkOut
+	EFAPeakGbps       float64   `json:"efa_peak_gbps,omitempty"`
+	EFAUtilization    float64   `json:"efa_utilization,omitempty"` // 0.0-1.0, average throughput over link rate
 }
 
 // MPIOptimizationResults contains MPI-specific performance metrics (only for MPI jobs)
@@ -67,6 +95,53 @@ type MPIOptimizationResults struct {
 	MessageSizeDistribution  MessageSizeHistogram `json:"message_size_distribution"`
 	SynchronizationFrequency float64              `json:"synchronization_frequency"` // MPI barriers per second
 	NetworkBottlenecks       []NetworkBottleneck  `json:"network_bottlenecks"`
+	ProfiledRuns             int                  `json:"profiled_runs,omitempty"`   // Profiled launches that replaced estimates
+	ElasticResizes           []ElasticResizeEvent `json:"elastic_resizes,omitempty"` // Spot nodes replaced while the job ran
+}
+
+// Outcomes of replacing an interrupted node of an elastic MPI job
+const (
+	ElasticReplacing = "replacing" // Replacement launched, waiting for it to register with Slurm
+	ElasticResized   = "resized"   // Replacement registered and the job's resize hook signalled
+	ElasticFailed    = "failed"    // No replacement; the job continues without the node, if it can
+)
+
+// ElasticResizeEvent is one interrupted spot node of an elastic MPI job and
+// its replacement
+type ElasticResizeEvent struct {
+	NodeName                string    `json:"node_name"`
+	InterruptedInstanceID   string    `json:"interrupted_instance_id"`
+	InterruptedAt           time.Time `json:"interrupted_at"` // When EC2 issued the interruption notice
+	ReplacementInstanceID   string    `json:"replacement_instance_id,omitempty"`
+	ReplacementInstanceType string    `json:"replacement_instance_type,omitempty"`
+	ReplacementPurchase     string    `json:"replacement_purchase,omitempty"` // "spot" or "on-demand"
+	LaunchedAt              time.Time `json:"launched_at,omitempty"`
+	ReadyAt                 time.Time `json:"ready_at,omitempty"` // When the job's resize hook was signalled
+	Status                  string    `json:"status"`
+	Error                   string    `json:"error,omitempty"`
+}
+
+// MPIProfileSummary is what aws-slurm-burst-mpirun records for one profiled launch.
+// Times are summed over ranks.
+type MPIProfileSummary struct {
+	JobID                    string               `json:"job_id"`
+	Tool                     string               `json:"tool"` // "mpip"
+	Ranks                    int                  `json:"ranks"`
+	AppTimeSeconds           float64              `json:"app_time_seconds"`
+	MPITimeSeconds           float64              `json:"mpi_time_seconds"`
+	CommunicationOverhead    float64              `json:"communication_overhead"` // 0.0-1.0, MPI share of application time
+	LoadBalance              float64              `json:"load_balance"`           // 0.0-1.0, mean over max per-rank compute time
+	CommunicationPattern     string               `json:"communication_pattern"`  // From the call with the most MPI time
+	SynchronizationFrequency float64              `json:"synchronization_frequency"`
+	MessageSizeDistribution  MessageSizeHistogram `json:"message_size_distribution"`
+	TopCalls                 []MPICallTime        `json:"top_calls,omitempty"`
+}
+
+// MPICallTime is one MPI call's share of a profiled launch's MPI time
+type MPICallTime struct {
+	Call     string  `json:"call"`
+	MPIShare float64 `json:"mpi_share"` // 0.0-1.0
+	Count    int64   `json:"count,omitempty"`
 }
 
 // MessageSizeHistogram represents distribution of MPI message sizes
@@ -92,19 +167,110 @@ type ActualCostAnalysis struct {
 	NetworkCostUSD        float64               `json:"network_cost_usd"`
 	TotalCostUSD          float64               `json:"total_cost_usd"`
 	SpotSavingsUSD        float64               `json:"spot_savings_usd"`
+	CommittedCostUSD      float64               `json:"committed_cost_usd,omitempty"` // Part of the compute cost on reserved instances and savings plans
 	CostPerCPUHour        float64               `json:"cost_per_cpu_hour"`
 	CostPerGPUHour        float64               `json:"cost_per_gpu_hour,omitempty"`
 	InstanceCostBreakdown []InstanceCostDetails `json:"instance_cost_breakdown"`
+	DataTransfer          *DataTransferCost     `json:"data_transfer,omitempty"` // How NetworkCostUSD was reached
+	Storage               *StorageCost          `json:"storage,omitempty"`       // How StorageCostUSD was reached
+}
+
+// StorageCost is the EBS and FSx storage attributed to a job
+type StorageCost struct {
+	VolumeGBHours float64            `json:"volume_gb_hours"`        // EBS capacity the job's instances held
+	EBSCostUSD    float64            `json:"ebs_cost_usd"`           // Capacity plus provisioned IOPS and throughput
+	FileSystems   map[string]float64 `json:"file_systems,omitempty"` // FSx filesystem name -> attributed cost
+	FSxCostUSD    float64            `json:"fsx_cost_usd"`
+	CostUSD       float64            `json:"cost_usd"`
+}
+
+// DataTransferCost is the data a job's nodes moved and what it cost
+type DataTransferCost struct {
+	EgressClass string  `json:"egress_class"`
+	EgressGB    float64 `json:"egress_gb"`   // Left AWS
+	CrossAZGB   float64 `json:"cross_az_gb"` // Between the job's nodes in different zones
+	Measured    bool    `json:"measured"`    // From node measurements rather than the configured estimate
+	CostUSD     float64 `json:"cost_usd"`
+}
+
+// CostReconciliation is the per-job cost record exported for ASBB reconciliation
+// and aggregated by cost reports
+type CostReconciliation struct {
+	JobID         string   `json:"job_id"`
+	Account       string   `json:"account"`
+	UserID        string   `json:"user_id"`
+	Partition     string   `json:"partition"`
+	ActualCost    float64  `json:"actual_cost"`
+	ComputeCost   float64  `json:"compute_cost"`
+	StorageCost   float64  `json:"storage_cost"`
+	NetworkCost   float64  `json:"network_cost"`
+	SpotSavings   float64  `json:"spot_savings"`
+	CommittedCost float64  `json:"committed_cost,omitempty"` // Part of ActualCost on committed capacity, already paid for
+	InstanceTypes []string `json:"instance_types"`
+	DurationHours float64  `json:"duration_hours"`
+	Success       bool     `json:"success"`
+	EndTime       string   `json:"end_time,omitempty"` // RFC3339
+	ExportTime    string   `json:"export_time"`        // RFC3339
+	PluginVersion string   `json:"plugin_version"`
+}
+
+// CompletedAt returns when the job ended, falling back to the export time for
+// records written before end times were recorded
+func (r *CostReconciliation) CompletedAt() time.Time {
+	for _, value := range []string{r.EndTime, r.ExportTime} {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
 }
 
 // InstanceCostDetails provides per-instance cost information
 type InstanceCostDetails struct {
-	InstanceID     string  `json:"instance_id"`
-	InstanceType   string  `json:"instance_type"`
-	PurchaseOption string  `json:"purchase_option"` // "spot", "on-demand"
-	CostUSD        float64 `json:"cost_usd"`
-	DurationHours  float64 `json:"duration_hours"`
-	Interrupted    bool    `json:"interrupted"` // Was this a spot interruption?
+	InstanceID      string  `json:"instance_id"`
+	InstanceType    string  `json:"instance_type"`
+	PurchaseOption  string  `json:"purchase_option"` // "spot", "on-demand"
+	CostUSD         float64 `json:"cost_usd"`
+	OnDemandCostUSD float64 `json:"on_demand_cost_usd,omitempty"` // What it would have cost on demand
+	CommittedShare  float64 `json:"committed_share,omitempty"`    // Share of its usage on committed capacity, 0-1
+	DurationHours   float64 `json:"duration_hours"`
+	Interrupted     bool    `json:"interrupted"` // Was this a spot interruption?
+}
+
+// ApplyLaunchPrices prices each instance at the hourly prices recorded when it
+// launched, for the hours from its launch to end (elapsed when its launch time
+// is unknown), and sets the spot savings to what the spot instances would have
+// cost on demand less what they cost. Instances launched without recorded
+// prices are left out.
+func (a *ActualCostAnalysis) ApplyLaunchPrices(instances []InstanceInfo, end time.Time, elapsed time.Duration) {
+	a.InstanceCostBreakdown = nil
+	a.SpotSavingsUSD = 0
+
+	for _, instance := range instances {
+		if instance.OnDemandPriceUSD <= 0 {
+			continue
+		}
+		spot := instance.PurchaseOption == "spot"
+		if spot && instance.SpotPriceUSD <= 0 {
+			continue
+		}
+
+		hours := instance.RunHours(end, elapsed)
+
+		details := InstanceCostDetails{
+			InstanceID:      instance.InstanceID,
+			InstanceType:    instance.InstanceType,
+			PurchaseOption:  instance.PurchaseOption,
+			CostUSD:         instance.OnDemandPriceUSD * hours,
+			OnDemandCostUSD: instance.OnDemandPriceUSD * hours,
+			DurationHours:   hours,
+		}
+		if spot {
+			details.CostUSD = instance.SpotPriceUSD * hours
+			a.SpotSavingsUSD += details.OnDemandCostUSD - details.CostUSD
+		}
+		a.InstanceCostBreakdown = append(a.InstanceCostBreakdown, details)
+	}
 }
 
 // ExecutionContext contains environment and configuration details
@@ -114,7 +280,8 @@ type ExecutionContext struct {
 	PluginVersion        string            `json:"plugin_version"`
 	ASBAVersion          string            `json:"asba_version,omitempty"`
 	SlurmVersion         string            `json:"slurm_version"`
-	ExecutionMode        string            `json:"execution_mode"` // "standalone", "asba"
+	ExecutionMode        string            `json:"execution_mode"`     // "standalone", "asba"
+	TraceID              string            `json:"trace_id,omitempty"` // Of the resumes that launched the job's nodes, comma-separated
 	ConfigurationUsed    map[string]string `json:"configuration_used"`
 	EnvironmentVariables map[string]string `json:"environment_variables"`
 }
@@ -0,0 +1,34 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActualCostAnalysis_ApplyLaunchPrices(t *testing.T) {
+	end := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	instances := []InstanceInfo{
+		{InstanceID: "i-1", InstanceType: "c6i.large", PurchaseOption: "on-demand", OnDemandPriceUSD: 0.1,
+			LaunchTime: end.Add(-2 * time.Hour).Format(time.RFC3339)},
+		{InstanceID: "i-2", InstanceType: "c6i.large", PurchaseOption: "spot", OnDemandPriceUSD: 0.1, SpotPriceUSD: 0.04,
+			LaunchTime: end.Add(-2 * time.Hour).Format(time.RFC3339)},
+		{InstanceID: "i-3", InstanceType: "c6i.large", PurchaseOption: "spot", OnDemandPriceUSD: 0.1, SpotPriceUSD: 0.03},
+		{InstanceID: "i-4", InstanceType: "c6i.large", PurchaseOption: "spot", OnDemandPriceUSD: 0.1},
+	}
+
+	analysis := ActualCostAnalysis{SpotSavingsUSD: 3.21}
+	analysis.ApplyLaunchPrices(instances, end, time.Hour)
+
+	require.Len(t, analysis.InstanceCostBreakdown, 3, "instances without a spot price are left out")
+	assert.InDelta(t, 0.2, analysis.InstanceCostBreakdown[0].CostUSD, 1e-9)
+	assert.InDelta(t, 0.08, analysis.InstanceCostBreakdown[1].CostUSD, 1e-9)
+	assert.InDelta(t, 0.2, analysis.InstanceCostBreakdown[1].OnDemandCostUSD, 1e-9)
+	assert.InDelta(t, 1.0, analysis.InstanceCostBreakdown[2].DurationHours, 1e-9, "elapsed when the launch time is unknown")
+	assert.InDelta(t, 0.12+0.07, analysis.SpotSavingsUSD, 1e-9)
+
+	analysis.ApplyLaunchPrices(nil, end, time.Hour)
+	assert.Zero(t, analysis.SpotSavingsUSD, "no savings without recorded prices")
+}
@@ -12,6 +12,8 @@ fi
 PLUGIN_DIR="/usr/local/bin"
 CONFIG_FILE="/etc/slurm/aws-burst.yaml"
 OUTPUT_DIR="/var/spool/asba/learning"
+COST_DIR="/var/spool/asbb/costs"
+S3_OUTPUT=""  # e.g. s3://learning-bucket/clusters; empty keeps exports local
 LOG_FILE="/var/log/slurm/aws-burst-epilog.log"
 
 # Ensure output directory exists
@@ -40,6 +42,26 @@ if [ $EXIT_CODE -eq 0 ]; then
         --output-dir="$OUTPUT_DIR" \
         --format=slurm-comment \
         >> "$LOG_FILE" 2>&1
+
+    # Record per-job costs for aws-slurm-burst-cost report
+    "$PLUGIN_DIR/aws-slurm-burst-export-performance" \
+        --job-id="$SLURM_JOB_ID" \
+        --config="$CONFIG_FILE" \
+        --output-dir="$COST_DIR" \
+        --format=asbb-reconciliation \
+        >> "$LOG_FILE" 2>&1
+
+    # Centralize learning and cost data in S3 for multi-cluster analytics
+    if [ -n "$S3_OUTPUT" ]; then
+        for FORMAT in parquet asbb-reconciliation; do
+            "$PLUGIN_DIR/aws-slurm-burst-export-performance" \
+                --job-id="$SLURM_JOB_ID" \
+                --config="$CONFIG_FILE" \
+                --output="$S3_OUTPUT" \
+                --format="$FORMAT" \
+                >> "$LOG_FILE" 2>&1
+        done
+    fi
 else
     echo "$(date): Failed to export performance data for job $SLURM_JOB_ID (exit code: $EXIT_CODE)" >> "$LOG_FILE"
 fi
@@ -0,0 +1,331 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// localStackEndpoint, when set (e.g. http://localhost:4566), runs the EC2
+// tests against LocalStack instead of the in-process mock
+var localStackEndpoint = os.Getenv("LOCALSTACK_ENDPOINT")
+
+// newEC2 returns an EC2 client for the tests: against LocalStack when
+// LOCALSTACK_ENDPOINT is set, otherwise against a fresh ec2Mock, which is
+// returned too. Tests that script failures skip under LocalStack.
+func newEC2(t *testing.T, scripted bool) (*ec2.Client, *ec2Mock) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	endpoint := localStackEndpoint
+	var mock *ec2Mock
+	if endpoint == "" {
+		mock = newEC2Mock()
+		server := httptest.NewServer(mock)
+		t.Cleanup(server.Close)
+		endpoint = server.URL
+	} else if scripted {
+		t.Skip("Skipping scripted EC2 failure test against LocalStack")
+	}
+
+	client := ec2.New(ec2.Options{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(endpoint),
+	})
+	return client, mock
+}
+
+// mockInstance is an instance held by ec2Mock
+type mockInstance struct {
+	id    string
+	zone  string
+	state string
+	tags  map[string]string
+	keys  []string // Tag keys in the order they were added
+}
+
+// ec2Mock serves the EC2 query API calls fleet launches and terminations
+// make, replaying canned responses over in-memory instances. Fields set
+// before a test script its failures.
+type ec2Mock struct {
+	// Subnets by ID, with their availability zones
+	subnets map[string]string
+	// Instances each subnet can launch per fleet before it runs out of
+	// capacity; subnets not listed are unlimited
+	capacity map[string]int
+	// New instances stay pending, so waiters time out
+	pending bool
+
+	mu         sync.Mutex
+	instances  []*mockInstance
+	fleets     int
+	calls      []string
+	terminated []string
+}
+
+func newEC2Mock() *ec2Mock {
+	return &ec2Mock{
+		subnets:  map[string]string{"subnet-a": "us-east-1a", "subnet-b": "us-east-1b"},
+		capacity: make(map[string]int),
+	}
+}
+
+// addInstance adds a running instance serving nodeName
+func (m *ec2Mock) addInstance(id, nodeName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	instance := &mockInstance{id: id, zone: "us-east-1a", state: "running", tags: make(map[string]string)}
+	instance.setTag("Name", nodeName)
+	m.instances = append(m.instances, instance)
+}
+
+// nodeName returns the Name tag of an instance
+func (m *ec2Mock) nodeName(id string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, instance := range m.instances {
+		if instance.id == id {
+			return instance.tags["Name"]
+		}
+	}
+	return ""
+}
+
+// actions returns the EC2 actions called, in order
+func (m *ec2Mock) actions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.calls)
+}
+
+func (i *mockInstance) setTag(key, value string) {
+	if _, ok := i.tags[key]; !ok {
+		i.keys = append(i.keys, key)
+	}
+	i.tags[key] = value
+}
+
+func (m *ec2Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := r.Form.Get("Action")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, action)
+
+	var body string
+	switch action {
+	case "CreateFleet":
+		body = m.createFleet(r.Form)
+	case "DescribeInstances":
+		body = m.describeInstances(r.Form)
+	case "CreateTags":
+		body = m.createTags(r.Form)
+	case "TerminateInstances":
+		body = m.terminateInstances(r.Form)
+	case "DescribeSubnets":
+		body = m.describeSubnets(r.Form)
+	case "DescribeInstanceTypeOfferings":
+		body = m.describeInstanceTypeOfferings(r.Form)
+	case "DescribeLaunchTemplateVersions":
+		body = "<launchTemplateVersionSet/>"
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<Response><Errors><Error><Code>InvalidAction</Code><Message>ec2Mock does not implement %s</Message></Error></Errors><RequestID>mock</RequestID></Response>`, action)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<%sResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>mock</requestId>%s</%sResponse>`, action, body, action)
+}
+
+// createFleet launches the fleet's target capacity into its first subnet,
+// up to the subnet's capacity
+func (m *ec2Mock) createFleet(form url.Values) string {
+	m.fleets++
+	subnet := form.Get("LaunchTemplateConfigs.1.Overrides.1.SubnetId")
+	requested := atoi(form.Get("TargetCapacitySpecification.TotalTargetCapacity"))
+	launched := requested
+	if limit, ok := m.capacity[subnet]; ok && limit < requested {
+		launched = limit
+	}
+
+	state := "running"
+	if m.pending {
+		state = "pending"
+	}
+
+	var ids strings.Builder
+	for i := 0; i < launched; i++ {
+		instance := &mockInstance{
+			id:    fmt.Sprintf("i-%04d", len(m.instances)+1),
+			zone:  m.subnets[subnet],
+			state: state,
+			tags:  make(map[string]string),
+		}
+		m.instances = append(m.instances, instance)
+		fmt.Fprintf(&ids, "<item>%s</item>", instance.id)
+	}
+
+	var errors string
+	if launched < requested {
+		errors = `<errorSet><item><errorCode>InsufficientInstanceCapacity</errorCode><errorMessage>We currently do not have sufficient capacity in the Availability Zone you requested.</errorMessage><lifecycle>on-demand</lifecycle></item></errorSet>`
+	}
+	var instances string
+	if launched > 0 {
+		instances = fmt.Sprintf("<fleetInstanceSet><item><instanceIds>%s</instanceIds><lifecycle>on-demand</lifecycle></item></fleetInstanceSet>", ids.String())
+	}
+	return fmt.Sprintf("<fleetId>fleet-%d</fleetId>%s%s", m.fleets, errors, instances)
+}
+
+// describeInstances filters instances by ID and by the tag:Name,
+// instance-state-name and tag-key filters
+func (m *ec2Mock) describeInstances(form url.Values) string {
+	ids := listParam(form, "InstanceId")
+	filters := filterParams(form)
+
+	var items strings.Builder
+	for n, instance := range m.instances {
+		if len(ids) > 0 && !slices.Contains(ids, instance.id) {
+			continue
+		}
+		if !instance.matches(filters) {
+			continue
+		}
+
+		var tags strings.Builder
+		for _, key := range instance.keys {
+			fmt.Fprintf(&tags, "<item><key>%s</key><value>%s</value></item>", key, instance.tags[key])
+		}
+		fmt.Fprintf(&items, `<item><instanceId>%s</instanceId><instanceType>c5n.18xlarge</instanceType><instanceState><code>%d</code><name>%s</name></instanceState><privateIpAddress>10.0.0.%d</privateIpAddress><placement><availabilityZone>%s</availabilityZone></placement><launchTime>2026-01-01T00:00:00.000Z</launchTime><tagSet>%s</tagSet></item>`,
+			instance.id, stateCode(instance.state), instance.state, n+1, instance.zone, tags.String())
+	}
+	return fmt.Sprintf("<reservationSet><item><reservationId>r-mock</reservationId><instancesSet>%s</instancesSet></item></reservationSet>", items.String())
+}
+
+func (i *mockInstance) matches(filters map[string][]string) bool {
+	for name, values := range filters {
+		var value string
+		switch name {
+		case "tag:Name":
+			value = i.tags["Name"]
+		case "instance-state-name":
+			value = i.state
+		case "tag-key":
+			for _, key := range values {
+				if _, ok := i.tags[key]; ok {
+					value = key
+				}
+			}
+		}
+		if !slices.Contains(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *ec2Mock) createTags(form url.Values) string {
+	resources := listParam(form, "ResourceId")
+	for _, instance := range m.instances {
+		if !slices.Contains(resources, instance.id) {
+			continue
+		}
+		for n := 1; form.Has(fmt.Sprintf("Tag.%d.Key", n)); n++ {
+			instance.setTag(form.Get(fmt.Sprintf("Tag.%d.Key", n)), form.Get(fmt.Sprintf("Tag.%d.Value", n)))
+		}
+	}
+	return "<return>true</return>"
+}
+
+func (m *ec2Mock) terminateInstances(form url.Values) string {
+	ids := listParam(form, "InstanceId")
+	var items strings.Builder
+	for _, instance := range m.instances {
+		if slices.Contains(ids, instance.id) {
+			fmt.Fprintf(&items, "<item><instanceId>%s</instanceId><currentState><code>32</code><name>shutting-down</name></currentState><previousState><code>%d</code><name>%s</name></previousState></item>",
+				instance.id, stateCode(instance.state), instance.state)
+			instance.state = "shutting-down"
+		}
+	}
+	m.terminated = append(m.terminated, ids...)
+	return fmt.Sprintf("<instancesSet>%s</instancesSet>", items.String())
+}
+
+func (m *ec2Mock) describeSubnets(form url.Values) string {
+	var items strings.Builder
+	for _, id := range listParam(form, "SubnetId") {
+		if zone, ok := m.subnets[id]; ok {
+			fmt.Fprintf(&items, "<item><subnetId>%s</subnetId><availabilityZone>%s</availabilityZone></item>", id, zone)
+		}
+	}
+	return fmt.Sprintf("<subnetSet>%s</subnetSet>", items.String())
+}
+
+// describeInstanceTypeOfferings offers every requested instance type in every zone
+func (m *ec2Mock) describeInstanceTypeOfferings(form url.Values) string {
+	filters := filterParams(form)
+	var items strings.Builder
+	for _, instanceType := range filters["instance-type"] {
+		for _, zone := range filters["location"] {
+			fmt.Fprintf(&items, "<item><instanceType>%s</instanceType><locationType>availability-zone</locationType><location>%s</location></item>", instanceType, zone)
+		}
+	}
+	return fmt.Sprintf("<instanceTypeOfferingSet>%s</instanceTypeOfferingSet>", items.String())
+}
+
+// listParam reads a query list parameter, e.g. InstanceId.1, InstanceId.2
+func listParam(form url.Values, name string) []string {
+	var values []string
+	for n := 1; form.Has(fmt.Sprintf("%s.%d", name, n)); n++ {
+		values = append(values, form.Get(fmt.Sprintf("%s.%d", name, n)))
+	}
+	return values
+}
+
+// filterParams reads Filter.N.Name and Filter.N.Value.M into values by filter name
+func filterParams(form url.Values) map[string][]string {
+	filters := make(map[string][]string)
+	for n := 1; form.Has(fmt.Sprintf("Filter.%d.Name", n)); n++ {
+		name := form.Get(fmt.Sprintf("Filter.%d.Name", n))
+		filters[name] = append(filters[name], listParam(form, fmt.Sprintf("Filter.%d.Value", n))...)
+	}
+	return filters
+}
+
+func stateCode(state string) int {
+	return map[string]int{"pending": 0, "running": 16, "shutting-down": 32, "terminated": 48, "stopping": 64, "stopped": 80}[state]
+}
+
+func atoi(s string) int {
+	var n int
+	fmt.Sscan(s, &n)
+	return n
+}
+
+// withTimeout returns a context for one EC2 flow
+func withTimeout(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
@@ -0,0 +1,150 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	burstAWS "github.com/scttfrdmn/aws-slurm-burst/internal/aws"
+	"github.com/scttfrdmn/aws-slurm-burst/internal/config"
+	"github.com/scttfrdmn/aws-slurm-burst/pkg/types"
+)
+
+// burstConfig has partition "aws" with node group "cpu" launching into
+// subnet-a, then subnet-b
+func burstConfig() (*config.AWSConfig, *config.Config) {
+	appConfig := &config.Config{Slurm: config.SlurmConfig{Partitions: []config.PartitionConfig{{
+		PartitionName: "aws",
+		NodeGroups: []config.NodeGroupConfig{{
+			NodeGroupName:      "cpu",
+			LaunchTemplateSpec: config.LaunchTemplateSpec{LaunchTemplateName: "compute", Version: "$Latest"},
+			SubnetIds:          []string{"subnet-a", "subnet-b"},
+		}},
+	}}}}
+	return &config.AWSConfig{Region: "us-east-1"}, appConfig
+}
+
+// TestFleetIntegration_LaunchAndTerminate launches nodes through CreateFleet,
+// waits for them to run, then terminates them by their Name tags
+func TestFleetIntegration_LaunchAndTerminate(t *testing.T) {
+	ec2Client, mock := newEC2(t, false)
+	awsConfig, appConfig := burstConfig()
+	client := burstAWS.NewClientWithEC2API(zaptest.NewLogger(t), awsConfig, appConfig, ec2Client)
+	ctx := withTimeout(t)
+
+	nodes := []string{"aws-cpu-1", "aws-cpu-2"}
+	result, err := client.LaunchInstances(ctx, &burstAWS.LaunchRequest{
+		NodeIds:              nodes,
+		Partition:            "aws",
+		NodeGroup:            "cpu",
+		InstanceRequirements: &types.InstanceRequirements{InstanceFamilies: []string{"c5n.18xlarge"}},
+		Job:                  &types.SlurmJob{JobID: "1001"},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Instances, 2)
+	assert.NotEmpty(t, result.FleetId)
+
+	var launched []string
+	for i, instance := range result.Instances {
+		assert.Equal(t, nodes[i], instance.NodeName)
+		assert.Equal(t, "running", instance.State)
+		assert.NotEmpty(t, instance.PrivateIP)
+		launched = append(launched, instance.InstanceID)
+	}
+
+	require.NoError(t, client.TerminateInstances(ctx, nodes))
+
+	if mock != nil {
+		for i, instanceID := range launched {
+			assert.Equal(t, nodes[i], mock.nodeName(instanceID), "instance tagged with its node")
+		}
+		assert.ElementsMatch(t, launched, mock.terminated, "instances found by Name tag")
+		assert.Equal(t, []string{"DescribeLaunchTemplateVersions", "CreateFleet", "DescribeInstances", "DescribeInstances",
+			"CreateTags", "CreateTags", "DescribeInstances", "TerminateInstances"}, mock.actions())
+	}
+}
+
+// TestFleetIntegration_TerminateByTag terminates only the instances tagged
+// with the suspended nodes
+func TestFleetIntegration_TerminateByTag(t *testing.T) {
+	ec2Client, mock := newEC2(t, true)
+	mock.addInstance("i-0001", "aws-cpu-1")
+	mock.addInstance("i-0002", "aws-cpu-2")
+	mock.addInstance("i-0003", "aws-cpu-3")
+
+	awsConfig, appConfig := burstConfig()
+	client := burstAWS.NewClientWithEC2API(zaptest.NewLogger(t), awsConfig, appConfig, ec2Client)
+
+	require.NoError(t, client.TerminateInstances(withTimeout(t), []string{"aws-cpu-1", "aws-cpu-3", "aws-cpu-9"}))
+	assert.ElementsMatch(t, []string{"i-0001", "i-0003"}, mock.terminated, "nodes without instances are skipped")
+	assert.Equal(t, []string{"DescribeInstances", "TerminateInstances"}, mock.actions())
+}
+
+// TestFleetIntegration_GangRollback launches an MPI gang that only partly fits
+// the first availability zone: the partial launch is terminated and the whole
+// gang launches in the next zone
+func TestFleetIntegration_GangRollback(t *testing.T) {
+	ec2Client, mock := newEC2(t, true)
+	mock.capacity["subnet-a"] = 1
+
+	awsConfig, appConfig := burstConfig()
+	client := burstAWS.NewClientWithEC2API(zaptest.NewLogger(t), awsConfig, appConfig, ec2Client)
+
+	result, err := client.LaunchInstances(withTimeout(t), &burstAWS.LaunchRequest{
+		NodeIds:              []string{"aws-cpu-1", "aws-cpu-2", "aws-cpu-3"},
+		Partition:            "aws",
+		NodeGroup:            "cpu",
+		InstanceRequirements: &types.InstanceRequirements{InstanceFamilies: []string{"c5n.18xlarge"}, RequiresEFA: true},
+		Job:                  &types.SlurmJob{JobID: "1002", IsMPIJob: true},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"i-0001"}, mock.terminated, "partial launch in us-east-1a rolled back")
+	require.Len(t, result.Instances, 3)
+	for _, instance := range result.Instances {
+		assert.Equal(t, "us-east-1b", instance.AvailabilityZone)
+		assert.NotEqual(t, "i-0001", instance.InstanceID)
+	}
+}
+
+// TestFleetIntegration_WaiterTimeout launches instances that never reach
+// running: the launch fails once the context ends and still returns the
+// instance IDs so they can be cleaned up
+func TestFleetIntegration_WaiterTimeout(t *testing.T) {
+	ec2Client, mock := newEC2(t, true)
+	mock.pending = true
+
+	awsConfig, _ := burstConfig()
+	fleetManager := burstAWS.NewFleetManagerWithAPI(zaptest.NewLogger(t), awsConfig, ec2Client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	started := time.Now()
+	response, err := fleetManager.LaunchInstanceFleet(ctx, &burstAWS.FleetRequest{
+		NodeIds:              []string{"aws-cpu-1", "aws-cpu-2"},
+		Partition:            "aws",
+		NodeGroup:            "cpu",
+		InstanceRequirements: &types.InstanceRequirements{InstanceFamilies: []string{"c5n.18xlarge"}},
+		Job:                  &types.SlurmJob{JobID: "1003"},
+		LaunchTemplate:       burstAWS.LaunchTemplateConfig{Name: "compute", Version: "$Latest"},
+		SubnetIds:            []string{"subnet-a"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "running state")
+	assert.Less(t, time.Since(started), 30*time.Second, "the context bounds the wait")
+
+	require.NotNil(t, response)
+	var instanceIDs []string
+	for _, instance := range response.Instances {
+		instanceIDs = append(instanceIDs, instance.InstanceID)
+	}
+	assert.Equal(t, []string{"i-0001", "i-0002"}, instanceIDs)
+}